@@ -30,11 +30,23 @@ const (
 	F_GETSIG        = 11
 	F_SETOWN_EX     = 15
 	F_GETOWN_EX     = 16
+	F_NOTIFY        = 1024 + 2
 	F_DUPFD_CLOEXEC = 1024 + 6
 	F_SETPIPE_SZ    = 1024 + 7
 	F_GETPIPE_SZ    = 1024 + 8
 )
 
+// Events for F_NOTIFY, from linux/fcntl.h.
+const (
+	DN_ACCESS    = 0x00000001
+	DN_MODIFY    = 0x00000002
+	DN_CREATE    = 0x00000004
+	DN_DELETE    = 0x00000008
+	DN_RENAME    = 0x00000010
+	DN_ATTRIB    = 0x00000020
+	DN_MULTISHOT = 0x80000000
+)
+
 // Commands for F_SETLK.
 const (
 	F_RDLCK = 0
@@ -42,6 +42,12 @@ const (
 	O_TMPFILE  = 020000000 // __O_TMPFILE in Linux
 )
 
+// Constants for close_range(2), from include/uapi/linux/close_range.h.
+const (
+	CLOSE_RANGE_UNSHARE = 1 << 1
+	CLOSE_RANGE_CLOEXEC = 1 << 2
+)
+
 // Constants for fstatat(2).
 const (
 	AT_SYMLINK_NOFOLLOW = 0x100
@@ -96,6 +102,13 @@ const (
 	AT_EMPTY_PATH     = 0x1000
 )
 
+// Constants for name_to_handle_at(2) and open_by_handle_at(2).
+const (
+	// MAX_HANDLE_SZ is the maximum size of the handle in struct
+	// file_handle.
+	MAX_HANDLE_SZ = 128
+)
+
 // Constants for all file-related ...at(2) syscalls.
 const (
 	AT_FDCWD = -100
@@ -150,6 +150,26 @@ func IOC(dir, typ, nr, size uint32) uint32 {
 	return uint32(dir)<<_IOC_DIRSHIFT | typ<<_IOC_TYPESHIFT | nr<<_IOC_NRSHIFT | size<<_IOC_SIZESHIFT
 }
 
+// IOC_DIR outputs the result of _IOC_DIR macro in asm-generic/ioctl.h.
+func IOC_DIR(nr uint32) uint32 {
+	return (nr >> _IOC_DIRSHIFT) & (1<<_IOC_DIRBITS - 1)
+}
+
+// IOC_TYPE outputs the result of _IOC_TYPE macro in asm-generic/ioctl.h.
+func IOC_TYPE(nr uint32) uint32 {
+	return (nr >> _IOC_TYPESHIFT) & (1<<_IOC_TYPEBITS - 1)
+}
+
+// IOC_NR outputs the result of _IOC_NR macro in asm-generic/ioctl.h.
+func IOC_NR(nr uint32) uint32 {
+	return (nr >> _IOC_NRSHIFT) & (1<<_IOC_NRBITS - 1)
+}
+
+// IOC_SIZE outputs the result of _IOC_SIZE macro in asm-generic/ioctl.h.
+func IOC_SIZE(nr uint32) uint32 {
+	return (nr >> _IOC_SIZESHIFT) & (1<<_IOC_SIZEBITS - 1)
+}
+
 // Kcov ioctls from kernel/kcov.h.
 var (
 	KCOV_INIT_TRACE = IOC(_IOC_READ, 'c', 1, 8)
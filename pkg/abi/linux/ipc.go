@@ -38,6 +38,11 @@ const (
 	IPC_PRIVATE = 0
 )
 
+// IPCMNI is the maximum number of objects of each SysV IPC type.
+//
+// Source: include/linux/ipc_namespace.h
+const IPCMNI = 32768
+
 // In Linux, amd64 does not enable CONFIG_ARCH_WANT_IPC_PARSE_VERSION, so SysV
 // IPC unconditionally uses the "new" 64-bit structures that are needed for
 // features like 32-bit UIDs.
@@ -352,3 +352,38 @@ type RtAttr struct {
 
 // SizeOfRtAttr is the size of RtAttr.
 const SizeOfRtAttr = 4
+
+// NeighborMessage is struct ndmsg, from uapi/linux/neighbour.h.
+//
+// +marshal
+type NeighborMessage struct {
+	Family uint8
+	_      uint8
+	_      uint16
+	Index  int32
+	State  uint16
+	Flags  uint8
+	Type   uint8
+}
+
+// Neighbor attributes, from uapi/linux/neighbour.h.
+const (
+	NDA_UNSPEC    = 0
+	NDA_DST       = 1
+	NDA_LLADDR    = 2
+	NDA_CACHEINFO = 3
+	NDA_PROBES    = 4
+)
+
+// Neighbor cache entry states, from uapi/linux/neighbour.h.
+const (
+	NUD_INCOMPLETE = 0x01
+	NUD_REACHABLE  = 0x02
+	NUD_STALE      = 0x04
+	NUD_DELAY      = 0x08
+	NUD_PROBE      = 0x10
+	NUD_FAILED     = 0x20
+	NUD_NOARP      = 0x40
+	NUD_PERMANENT  = 0x80
+	NUD_NONE       = 0x00
+)
@@ -568,6 +568,13 @@ const SizeOfControlMessageTOS = 1
 // SizeOfControlMessageTClass is the size of an IPV6_TCLASS control message.
 const SizeOfControlMessageTClass = 4
 
+// SizeOfControlMessageTTL is the size of an IP_TTL control message.
+const SizeOfControlMessageTTL = 4
+
+// SizeOfControlMessageHopLimit is the size of an IPV6_HOPLIMIT control
+// message.
+const SizeOfControlMessageHopLimit = 4
+
 // SizeOfControlMessageIPPacketInfo is the size of an IP_PKTINFO
 // control message.
 const SizeOfControlMessageIPPacketInfo = 12
@@ -53,6 +53,42 @@ const (
 	TCP_INQ                  = 36
 )
 
+// Values of TCP_REPAIR from uapi/linux/tcp.h.
+const (
+	TCP_REPAIR_ON        = 1
+	TCP_REPAIR_OFF       = 0
+	TCP_REPAIR_OFF_NO_WP = -1
+)
+
+// Queues selected by TCP_REPAIR_QUEUE from uapi/linux/tcp.h.
+const (
+	TCP_NO_QUEUE   = 0
+	TCP_RECV_QUEUE = 1
+	TCP_SEND_QUEUE = 2
+)
+
+// Options of TCPInfo from uapi/linux/tcp.h.
+const (
+	TCPI_OPT_TIMESTAMPS = 1
+	TCPI_OPT_SACK       = 2
+	TCPI_OPT_WSCALE     = 4
+	TCPI_OPT_ECN        = 8
+	TCPI_OPT_ECN_SEEN   = 16
+	TCPI_OPT_SYN_DATA   = 32
+)
+
+// TCP options from include/net/tcp.h, used with TCP_REPAIR_OPTIONS.
+const (
+	TCPOPT_MSS       = 2
+	TCPOPT_WINDOW    = 3
+	TCPOPT_SACK_PERM = 4
+	TCPOPT_TIMESTAMP = 8
+)
+
+// SizeOfTCPRepairWindow is the size of struct tcp_repair_window from
+// uapi/linux/tcp.h, used with TCP_REPAIR_WINDOW.
+const SizeOfTCPRepairWindow = 20
+
 // Socket constants from include/net/tcp.h.
 const (
 	MAX_TCP_KEEPIDLE  = 32767
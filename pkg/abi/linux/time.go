@@ -70,6 +70,7 @@ const (
 	CLOCK_BOOTTIME           = 7
 	CLOCK_REALTIME_ALARM     = 8
 	CLOCK_BOOTTIME_ALARM     = 9
+	CLOCK_TAI                = 11
 )
 
 // Flags for clock_nanosleep(2).
@@ -0,0 +1,41 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package linux
+
+// Well-known vsock addresses, from uapi/linux/vm_sockets.h.
+const (
+	VMADDR_CID_ANY        = 0xffffffff
+	VMADDR_PORT_ANY       = 0xffffffff
+	VMADDR_CID_HYPERVISOR = 0
+	VMADDR_CID_LOCAL      = 1
+	VMADDR_CID_HOST       = 2
+)
+
+// SockAddrVM is struct sockaddr_vm, from uapi/linux/vm_sockets.h.
+//
+// +marshal
+type SockAddrVM struct {
+	Family    uint16
+	Reserved1 uint16
+	Port      uint32
+	CID       uint32
+	Flags     uint8
+	Zero      [3]uint8
+}
+
+// SockAddrVMSize is the size of SockAddrVM.
+const SockAddrVMSize = 16
+
+func (s *SockAddrVM) implementsSockAddr() {}
@@ -78,6 +78,33 @@ func (b *Bitmap) FirstZero(start uint32) uint32 {
 	return math.MaxInt32
 }
 
+// FirstOne returns the first set bit from the range [start, ).
+func (b *Bitmap) FirstOne(start uint32) uint32 {
+	i, nbit := int(start/64), start%64
+	n := len(b.bitBlock)
+	if i >= n {
+		return math.MaxInt32
+	}
+	w := b.bitBlock[i] &^ ((1 << nbit) - 1)
+	for {
+		if w != uint64(0) {
+			r := bits.TrailingZeros64(w)
+			return uint32(r + i*64)
+		}
+		i++
+		if i == n {
+			break
+		}
+		w = b.bitBlock[i]
+	}
+	return math.MaxInt32
+}
+
+// Size returns the number of bits that the Bitmap can hold without growing.
+func (b *Bitmap) Size() uint32 {
+	return uint32(len(b.bitBlock)) * 64
+}
+
 // Maximum return the largest value in the Bitmap.
 func (b *Bitmap) Maximum() uint32 {
 	for i := len(b.bitBlock) - 1; i >= 0; i-- {
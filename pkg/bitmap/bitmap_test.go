@@ -304,3 +304,25 @@ func TestFirstZero(t *testing.T) {
 		}
 	}
 }
+
+func TestFirstOne(t *testing.T) {
+	bitmap := New(uint32(1000))
+	bitmap.FlipRange(200, 400)
+	for i, j := range map[uint32]uint32{0: 200, 199: 200, 200: 200, 201: 201, 399: 399, 400: math.MaxInt32, 10000: math.MaxInt32} {
+		v := bitmap.FirstOne(i)
+		if v != j {
+			t.Errorf("FirstOne(%v) returns: %v, wanted: %v", i, v, j)
+		}
+	}
+}
+
+func TestSize(t *testing.T) {
+	bitmap := New(uint32(1000))
+	if got, want := bitmap.Size(), uint32(1024); got != want {
+		t.Errorf("Size() returns: %v, wanted: %v", got, want)
+	}
+	bitmap.Add(2000)
+	if got, want := bitmap.Size(), uint32(2048); got != want {
+		t.Errorf("After Add(2000), Size() returns: %v, wanted: %v", got, want)
+	}
+}
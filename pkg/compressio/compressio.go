@@ -41,6 +41,9 @@
 //
 // so the stream integrity cannot be compromised by switching and mixing
 // compressed chunks.
+//
+// Chunks are compressed with DEFLATE, or with Zstandard. The algorithm is not
+// recorded in the stream, and must be given to the reader.
 package compressio
 
 import (
@@ -50,13 +53,39 @@ import (
 	"crypto/sha256"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"hash"
 	"io"
 	"runtime"
 
+	"github.com/klauspost/compress/zstd"
 	"gvisor.dev/gvisor/pkg/sync"
 )
 
+// Algorithm is a compression algorithm.
+type Algorithm int
+
+const (
+	// Flate is the DEFLATE algorithm, see compress/flate.
+	Flate Algorithm = iota
+
+	// Zstd is the Zstandard algorithm, which is faster than Flate at similar
+	// compression ratios.
+	Zstd
+)
+
+// String implements fmt.Stringer.
+func (a Algorithm) String() string {
+	switch a {
+	case Flate:
+		return "flate"
+	case Zstd:
+		return "zstd"
+	default:
+		return fmt.Sprintf("Algorithm(%d)", int(a))
+	}
+}
+
 var bufPool = sync.Pool{
 	New: func() interface{} {
 		return bytes.NewBuffer(nil)
@@ -134,14 +163,44 @@ type worker struct {
 	// scratch is a temporary buffer used for marshalling. This is declared
 	// unfront here to avoid reallocation.
 	scratch [4]byte
+
+	// zbuf is the output buffer of Zstandard, reused across chunks.
+	zbuf []byte
 }
 
 // work is the main work routine; see worker.
-func (w *worker) work(compress bool, level int) {
+func (w *worker) work(compress bool, algo Algorithm, level int) {
 	defer close(w.output)
 
 	var h hash.Hash
 
+	// Zstandard encoders and decoders are reused across chunks. They are
+	// only used by this goroutine.
+	var (
+		zenc *zstd.Encoder
+		zdec *zstd.Decoder
+	)
+	if algo == Zstd {
+		var err error
+		if compress {
+			zenc, err = zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1), zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+		} else {
+			zdec, err = zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+		}
+		if err != nil {
+			for c := range w.input {
+				w.output <- result{c, err}
+			}
+			return
+		}
+		if zenc != nil {
+			defer zenc.Close()
+		}
+		if zdec != nil {
+			defer zdec.Close()
+		}
+	}
+
 	for c := range w.input {
 		if h == nil && w.hashPool != nil {
 			h = w.hashPool.getHash()
@@ -153,18 +212,7 @@ func (w *worker) work(compress bool, level int) {
 			}
 
 			// Encode this slice.
-			fw, err := flate.NewWriter(mw, level)
-			if err != nil {
-				w.output <- result{c, err}
-				continue
-			}
-
-			// Encode the input.
-			if _, err := io.CopyN(fw, c.uncompressed, int64(c.uncompressed.Len())); err != nil {
-				w.output <- result{c, err}
-				continue
-			}
-			if err := fw.Close(); err != nil {
+			if err := w.compress(mw, c.uncompressed, zenc, level); err != nil {
 				w.output <- result{c, err}
 				continue
 			}
@@ -193,10 +241,7 @@ func (w *worker) work(compress bool, level int) {
 			}
 
 			// Decode this slice.
-			fr := flate.NewReader(c.compressed)
-
-			// Decode the input.
-			if _, err := io.Copy(c.uncompressed, fr); err != nil {
+			if err := w.decompress(c.uncompressed, c.compressed, zdec); err != nil {
 				w.output <- result{c, err}
 				continue
 			}
@@ -207,6 +252,44 @@ func (w *worker) work(compress bool, level int) {
 	}
 }
 
+// compress writes the compressed contents of in to out, using zenc if it's
+// not nil, or else DEFLATE.
+func (w *worker) compress(out io.Writer, in *bytes.Buffer, zenc *zstd.Encoder, level int) error {
+	if zenc != nil {
+		w.zbuf = zenc.EncodeAll(in.Bytes(), w.zbuf[:0])
+		in.Reset()
+		_, err := out.Write(w.zbuf)
+		return err
+	}
+
+	fw, err := flate.NewWriter(out, level)
+	if err != nil {
+		return err
+	}
+	if _, err := io.CopyN(fw, in, int64(in.Len())); err != nil {
+		return err
+	}
+	return fw.Close()
+}
+
+// decompress writes the decompressed contents of in to out, using zdec if
+// it's not nil, or else DEFLATE.
+func (w *worker) decompress(out *bytes.Buffer, in *bytes.Buffer, zdec *zstd.Decoder) error {
+	if zdec != nil {
+		var err error
+		w.zbuf, err = zdec.DecodeAll(in.Bytes(), w.zbuf[:0])
+		if err != nil {
+			return err
+		}
+		_, err = out.Write(w.zbuf)
+		return err
+	}
+
+	fr := flate.NewReader(in)
+	_, err := io.Copy(out, fr)
+	return err
+}
+
 type hashPool struct {
 	// mu protexts the hash list.
 	mu sync.Mutex
@@ -278,7 +361,7 @@ type pool struct {
 // init initializes the worker pool.
 //
 // This should only be called once.
-func (p *pool) init(key []byte, workers int, compress bool, level int) {
+func (p *pool) init(key []byte, workers int, compress bool, algo Algorithm, level int) {
 	if key != nil {
 		p.hashPool = &hashPool{key: key}
 	}
@@ -289,7 +372,7 @@ func (p *pool) init(key []byte, workers int, compress bool, level int) {
 			input:    make(chan *chunk, 1),
 			output:   make(chan result, 1),
 		}
-		go p.workers[i].work(compress, level) // S/R-SAFE: In save path only.
+		go p.workers[i].work(compress, algo, level) // S/R-SAFE: In save path only.
 	}
 	runtime.SetFinalizer(p, (*pool).stop)
 }
@@ -371,12 +454,17 @@ var _ io.Reader = (*Reader)(nil)
 // hash values computed from the compressed bytes. See package comments for
 // details.
 func NewReader(in io.Reader, key []byte) (*Reader, error) {
+	return NewReaderAlgorithm(in, key, Flate)
+}
+
+// NewReaderAlgorithm is like NewReader, for data compressed with algo.
+func NewReaderAlgorithm(in io.Reader, key []byte, algo Algorithm) (*Reader, error) {
 	r := &Reader{
 		in: in,
 	}
 
 	// Use double buffering for read.
-	r.init(key, 2*runtime.GOMAXPROCS(0), false, 0)
+	r.init(key, 2*runtime.GOMAXPROCS(0), false, algo, 0)
 
 	if _, err := io.ReadFull(in, r.scratch[:4]); err != nil {
 		return nil, err
@@ -600,6 +688,12 @@ var _ io.Writer = (*Writer)(nil)
 // buffered (in the form of read-ahead, or buffered writes), and is limited to
 // O(chunkSize * [1+GOMAXPROCS]).
 func NewWriter(out io.Writer, key []byte, chunkSize uint32, level int) (*Writer, error) {
+	return NewWriterAlgorithm(out, key, chunkSize, Flate, level)
+}
+
+// NewWriterAlgorithm is like NewWriter, but compresses with algo. level is the
+// compression level of algo, e.g. flate.BestSpeed or 1 for Zstd.
+func NewWriterAlgorithm(out io.Writer, key []byte, chunkSize uint32, algo Algorithm, level int) (*Writer, error) {
 	w := &Writer{
 		pool: pool{
 			chunkSize: chunkSize,
@@ -607,7 +701,7 @@ func NewWriter(out io.Writer, key []byte, chunkSize uint32, level int) (*Writer,
 		},
 		out: out,
 	}
-	w.init(key, 1+runtime.GOMAXPROCS(0), true, level)
+	w.init(key, 1+runtime.GOMAXPROCS(0), true, algo, level)
 
 	binary.BigEndian.PutUint32(w.scratch[:], chunkSize)
 	if _, err := w.out.Write(w.scratch[:4]); err != nil {
@@ -176,18 +176,24 @@ func TestCompress(t *testing.T) {
 						// case when not doing hashing.
 						continue
 					}
-					// Do the compress test.
-					doTest(t, testOpts{
-						Name: fmt.Sprintf("len(data)=%d, blockSize=%d, key=%s, corruptData=%v", len(data), blockSize, string(key), corruptData),
-						Data: data,
-						NewWriter: func(b *bytes.Buffer) (io.Writer, error) {
-							return NewWriter(b, key, blockSize, flate.BestSpeed)
-						},
-						NewReader: func(b *bytes.Buffer) (io.Reader, error) {
-							return NewReader(b, key)
-						},
-						CorruptData: corruptData,
-					})
+					for _, algo := range []Algorithm{Flate, Zstd} {
+						level := flate.BestSpeed
+						if algo == Zstd {
+							level = 1
+						}
+						// Do the compress test.
+						doTest(t, testOpts{
+							Name: fmt.Sprintf("len(data)=%d, blockSize=%d, key=%s, corruptData=%v, algo=%v", len(data), blockSize, string(key), corruptData, algo),
+							Data: data,
+							NewWriter: func(b *bytes.Buffer) (io.Writer, error) {
+								return NewWriterAlgorithm(b, key, blockSize, algo, level)
+							},
+							NewReader: func(b *bytes.Buffer) (io.Reader, error) {
+								return NewReaderAlgorithm(b, key, algo)
+							},
+							CorruptData: corruptData,
+						})
+					}
 				}
 			}
 		}
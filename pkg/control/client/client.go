@@ -16,6 +16,8 @@
 package client
 
 import (
+	"fmt"
+
 	"gvisor.dev/gvisor/pkg/unet"
 	"gvisor.dev/gvisor/pkg/urpc"
 )
@@ -31,3 +33,17 @@ func ConnectTo(addr string) (*urpc.Client, error) {
 	// Wrap in our stream codec.
 	return urpc.NewClient(conn), nil
 }
+
+// ConnectWithToken is like ConnectTo, but authenticates with the given token to
+// a server that has a policy, see server.Credentials.
+func ConnectWithToken(addr, token string) (*urpc.Client, error) {
+	conn, err := unet.Connect(addr, false)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write([]byte(token)); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("sending token: %v", err)
+	}
+	return urpc.NewClient(conn), nil
+}
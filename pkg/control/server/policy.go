@@ -0,0 +1,232 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// TokenLen is the length of the tokens that clients present to authenticate as
+// a principal.
+const TokenLen = 64
+
+// Principal is a host user or group that control clients authenticate as.
+type Principal struct {
+	// Group is true if ID is a group ID, and false if it's a user ID.
+	Group bool `json:"group,omitempty"`
+
+	// ID is the user or group ID.
+	ID uint32 `json:"id"`
+}
+
+// String returns the principal as written in a policy, e.g. "uid=1000".
+func (p Principal) String() string {
+	if p.Group {
+		return fmt.Sprintf("gid=%d", p.ID)
+	}
+	return fmt.Sprintf("uid=%d", p.ID)
+}
+
+// Credentials are the tokens that authenticate control clients.
+//
+// Peer credentials can't be used for this: the sandbox user namespace only maps
+// the user running the sandbox, so every other host user shows up as the
+// overflow UID. Instead, runsc gives each principal a random token, in a file
+// that only the principal can read, and passes the tokens to the sandbox. A
+// client presents the token before its first call.
+type Credentials struct {
+	// Owner is the user that created the sandbox. It may call all methods
+	// unless the policy names it.
+	Owner Principal `json:"owner"`
+
+	// Tokens maps tokens to the principals they authenticate.
+	Tokens map[string]Principal `json:"tokens"`
+}
+
+// NewCredentials generates a token for the owner and for each principal named
+// by the policy.
+func NewCredentials(p *Policy, owner uint32) (*Credentials, error) {
+	c := &Credentials{
+		Owner:  Principal{ID: owner},
+		Tokens: make(map[string]Principal),
+	}
+	for _, pr := range append([]Principal{c.Owner}, p.Principals()...) {
+		if c.Token(pr) != "" {
+			continue
+		}
+		b := make([]byte, TokenLen/2)
+		if _, err := rand.Read(b); err != nil {
+			return nil, fmt.Errorf("generating token: %v", err)
+		}
+		c.Tokens[hex.EncodeToString(b)] = pr
+	}
+	return c, nil
+}
+
+// Token returns the token of the principal, or "" if it has none.
+func (c *Credentials) Token(pr Principal) string {
+	for token, p := range c.Tokens {
+		if p == pr {
+			return token
+		}
+	}
+	return ""
+}
+
+// Authenticate returns the principal that the token authenticates, and false if
+// the token is invalid.
+func (c *Credentials) Authenticate(token []byte) (Principal, bool) {
+	var (
+		pr    Principal
+		found bool
+	)
+	// Compare against all tokens in constant time, so that the time taken
+	// doesn't leak how much of a token is right.
+	for t, p := range c.Tokens {
+		if subtle.ConstantTimeCompare([]byte(t), token) == 1 {
+			pr, found = p, true
+		}
+	}
+	return pr, found
+}
+
+// Policy grants host users and groups access to some of the methods of the
+// control server. Clients authenticate as a principal using Credentials.
+//
+// A policy is a list of rules separated by semicolons. Each rule is a list of
+// principals, a colon, and a list of methods, e.g.
+// "uid=1000,gid=100:containerManager.Processes,containerManager.Event". A
+// principal is either "uid=<UID>", for clients running as the user, or
+// "gid=<GID>", for clients running with the group. A method is either the name
+// of a method, "<Object>.*" for all methods of an object, or "*" for all
+// methods.
+//
+// The policy applies to root and to the owner of the sandbox too, if it names
+// them. The owner may call all methods otherwise.
+type Policy struct {
+	rules []rule
+}
+
+// rule allows the principals it names to call some methods.
+type rule struct {
+	principals []Principal
+	methods    []string
+}
+
+// ParsePolicy parses a policy, see Policy for the format.
+func ParsePolicy(s string) (*Policy, error) {
+	p := &Policy{}
+	for _, r := range strings.Split(s, ";") {
+		r = strings.TrimSpace(r)
+		if r == "" {
+			continue
+		}
+		parts := strings.SplitN(r, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid rule %q: missing ':' between principals and methods", r)
+		}
+		var rl rule
+		for _, principal := range strings.Split(parts[0], ",") {
+			kv := strings.SplitN(strings.TrimSpace(principal), "=", 2)
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("invalid principal %q in rule %q, must be uid=<UID> or gid=<GID>", principal, r)
+			}
+			id, err := strconv.ParseUint(kv[1], 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid ID %q in rule %q: %v", kv[1], r, err)
+			}
+			switch kv[0] {
+			case "uid":
+				rl.principals = append(rl.principals, Principal{ID: uint32(id)})
+			case "gid":
+				rl.principals = append(rl.principals, Principal{Group: true, ID: uint32(id)})
+			default:
+				return nil, fmt.Errorf("invalid principal %q in rule %q, must be uid=<UID> or gid=<GID>", principal, r)
+			}
+		}
+		for _, method := range strings.Split(parts[1], ",") {
+			method = strings.TrimSpace(method)
+			if method != "*" && !strings.Contains(method, ".") {
+				return nil, fmt.Errorf("invalid method %q in rule %q, must be <Object>.<Method>, <Object>.* or *", method, r)
+			}
+			rl.methods = append(rl.methods, method)
+		}
+		p.rules = append(p.rules, rl)
+	}
+	return p, nil
+}
+
+// matches returns true if the rule names the principal.
+func (r *rule) matches(pr Principal) bool {
+	for _, p := range r.principals {
+		if p == pr {
+			return true
+		}
+	}
+	return false
+}
+
+// allows returns true if the rule allows calling method.
+func (r *rule) allows(method string) bool {
+	for _, m := range r.methods {
+		if m == "*" || m == method {
+			return true
+		}
+		if strings.HasSuffix(m, ".*") && strings.HasPrefix(method, m[:len(m)-1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Principals returns the principals named by the policy.
+func (p *Policy) Principals() []Principal {
+	var prs []Principal
+	seen := make(map[Principal]struct{})
+	for i := range p.rules {
+		for _, pr := range p.rules[i].principals {
+			if _, ok := seen[pr]; !ok {
+				seen[pr] = struct{}{}
+				prs = append(prs, pr)
+			}
+		}
+	}
+	return prs
+}
+
+// Names returns true if the policy has rules for the principal.
+func (p *Policy) Names(pr Principal) bool {
+	for i := range p.rules {
+		if p.rules[i].matches(pr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Allows returns true if the principal is allowed to call method.
+func (p *Policy) Allows(pr Principal, method string) bool {
+	for i := range p.rules {
+		if p.rules[i].matches(pr) && p.rules[i].allows(method) {
+			return true
+		}
+	}
+	return false
+}
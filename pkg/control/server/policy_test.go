@@ -0,0 +1,92 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"testing"
+)
+
+func TestPolicy(t *testing.T) {
+	p, err := ParsePolicy("uid=1000,gid=100:containerManager.Processes,containerManager.Event; uid=2000:Lifecycle.*;uid=0:*")
+	if err != nil {
+		t.Fatalf("ParsePolicy() failed: %v", err)
+	}
+	for _, tc := range []struct {
+		pr     Principal
+		method string
+		want   bool
+	}{
+		{pr: Principal{ID: 1000}, method: "containerManager.Processes", want: true},
+		{pr: Principal{Group: true, ID: 100}, method: "containerManager.Event", want: true},
+		{pr: Principal{ID: 100}, method: "containerManager.Event", want: false},
+		{pr: Principal{ID: 1000}, method: "containerManager.ExecuteAsync", want: false},
+		{pr: Principal{ID: 2000}, method: "Lifecycle.StartContainer", want: true},
+		{pr: Principal{ID: 2000}, method: "LifecycleX.StartContainer", want: false},
+		{pr: Principal{ID: 2000}, method: "containerManager.Processes", want: false},
+		{pr: Principal{ID: 0}, method: "containerManager.ExecuteAsync", want: true},
+		{pr: Principal{ID: 3000}, method: "containerManager.Processes", want: false},
+	} {
+		if got := p.Allows(tc.pr, tc.method); got != tc.want {
+			t.Errorf("Allows(%s, %q) = %t, want %t", tc.pr, tc.method, got, tc.want)
+		}
+	}
+
+	if !p.Names(Principal{Group: true, ID: 100}) {
+		t.Errorf("Names(gid=100) = false, want true")
+	}
+	if p.Names(Principal{ID: 3000}) {
+		t.Errorf("Names(uid=3000) = true, want false")
+	}
+}
+
+func TestCredentials(t *testing.T) {
+	p, err := ParsePolicy("uid=1000,gid=100:containerManager.Processes;uid=1000:containerManager.Event")
+	if err != nil {
+		t.Fatalf("ParsePolicy() failed: %v", err)
+	}
+	c, err := NewCredentials(p, 0)
+	if err != nil {
+		t.Fatalf("NewCredentials() failed: %v", err)
+	}
+	if len(c.Tokens) != 3 {
+		t.Errorf("got %d tokens, want 3: %v", len(c.Tokens), c.Tokens)
+	}
+	for _, pr := range []Principal{c.Owner, {ID: 1000}, {Group: true, ID: 100}} {
+		token := c.Token(pr)
+		if len(token) != TokenLen {
+			t.Errorf("token of %s has length %d, want %d", pr, len(token), TokenLen)
+		}
+		if got, ok := c.Authenticate([]byte(token)); !ok || got != pr {
+			t.Errorf("Authenticate(token of %s) = %s, %t, want %s, true", pr, got, ok, pr)
+		}
+	}
+	if _, ok := c.Authenticate(make([]byte, TokenLen)); ok {
+		t.Errorf("Authenticate(invalid token) succeeded")
+	}
+}
+
+func TestParsePolicyError(t *testing.T) {
+	for _, s := range []string{
+		"uid=1000",
+		"user=1000:*",
+		"uid=-1:*",
+		"uid=1000:Processes",
+		"uid=1000:*;gid:*",
+	} {
+		if _, err := ParsePolicy(s); err == nil {
+			t.Errorf("ParsePolicy(%q) succeeded, want error", s)
+		}
+	}
+}
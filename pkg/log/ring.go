@@ -0,0 +1,100 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"gvisor.dev/gvisor/pkg/sync"
+)
+
+// RingHeaderSize is the size of the header of the buffer of a Ring, holding
+// the number of bytes ever written as a little-endian uint64.
+const RingHeaderSize = 8
+
+// Ring is an io.Writer keeping the last bytes written in a fixed size buffer.
+// It's used to keep the most recent log records, e.g. to see what happened
+// before a crash. The buffer may be shared memory, such that its contents
+// outlive the process writing it, see ReadRing.
+type Ring struct {
+	// mu protects buf.
+	mu sync.Mutex
+
+	// buf is the header, followed by the data written.
+	buf []byte
+}
+
+// NewRing returns a Ring writing to buf, which must be larger than
+// RingHeaderSize. The previous contents of buf are discarded.
+func NewRing(buf []byte) *Ring {
+	if len(buf) <= RingHeaderSize {
+		panic("log ring buffer too small")
+	}
+	binary.LittleEndian.PutUint64(buf, 0)
+	return &Ring{buf: buf}
+}
+
+// Write implements io.Writer.Write. It never fails.
+func (r *Ring) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := len(p)
+	data := r.buf[RingHeaderSize:]
+	size := uint64(len(data))
+	written := binary.LittleEndian.Uint64(r.buf)
+	if uint64(len(p)) > size {
+		// Only the end of p is kept.
+		written += uint64(len(p)) - size
+		p = p[uint64(len(p))-size:]
+	}
+	for len(p) > 0 {
+		c := copy(data[written%size:], p)
+		p = p[c:]
+		written += uint64(c)
+	}
+	binary.LittleEndian.PutUint64(r.buf, written)
+	return n, nil
+}
+
+// Contents returns the bytes kept by r, oldest first.
+func (r *Ring) Contents() []byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return ReadRing(r.buf)
+}
+
+// ReadRing returns the bytes kept in buf, the buffer of a Ring, oldest first.
+// If the oldest line was partially overwritten, it's skipped.
+func ReadRing(buf []byte) []byte {
+	if len(buf) <= RingHeaderSize {
+		return nil
+	}
+	data := buf[RingHeaderSize:]
+	size := uint64(len(data))
+	written := binary.LittleEndian.Uint64(buf)
+	if written <= size {
+		return append([]byte(nil), data[:written]...)
+	}
+	off := written % size
+	out := make([]byte, 0, size)
+	out = append(out, data[off:]...)
+	out = append(out, data[:off]...)
+	if i := bytes.IndexByte(out, '\n'); i >= 0 {
+		out = out[i+1:]
+	}
+	return out
+}
@@ -0,0 +1,75 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"testing"
+)
+
+func TestRing(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		size   int
+		writes []string
+		want   string
+	}{
+		{
+			name: "empty",
+			size: 16,
+			want: "",
+		},
+		{
+			name:   "fits",
+			size:   16,
+			writes: []string{"a\n", "bc\n"},
+			want:   "a\nbc\n",
+		},
+		{
+			name:   "full",
+			size:   6,
+			writes: []string{"ab\n", "cd\n"},
+			want:   "ab\ncd\n",
+		},
+		{
+			name:   "wraps",
+			size:   8,
+			writes: []string{"abc\n", "def\n", "gh\n"},
+			want:   "def\ngh\n",
+		},
+		{
+			name:   "larger than buffer",
+			size:   4,
+			writes: []string{"abcdef\ngh\n"},
+			want:   "gh\n",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			buf := make([]byte, RingHeaderSize+tc.size)
+			r := NewRing(buf)
+			for _, w := range tc.writes {
+				if n, err := r.Write([]byte(w)); n != len(w) || err != nil {
+					t.Fatalf("Write(%q) = %d, %v, want %d, nil", w, n, err, len(w))
+				}
+			}
+			if got := string(r.Contents()); got != tc.want {
+				t.Errorf("Contents() = %q, want %q", got, tc.want)
+			}
+			// The buffer alone is enough to read the contents.
+			if got := string(ReadRing(buf)); got != tc.want {
+				t.Errorf("ReadRing() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -17,6 +17,7 @@ package p9
 import (
 	"errors"
 	"fmt"
+	"sync/atomic"
 
 	"golang.org/x/sys/unix"
 	"gvisor.dev/gvisor/pkg/flipcall"
@@ -70,7 +71,7 @@ var responsePool = sync.Pool{
 
 // Client is at least a 9P2000.L client.
 type Client struct {
-	// socket is the connected socket.
+	// socket is the connected socket. It's the socket of conns[0].
 	socket *unet.Socket
 
 	// tagPool is the collection of available tags.
@@ -120,6 +121,21 @@ type Client struct {
 
 	// -- below corresponds to sendRecvLegacy --
 
+	// conns are the connections to the server, requests are distributed
+	// over them.
+	conns []*clientConn
+
+	// nextConn is the index in conns of the connection to use for the next
+	// request, modulo len(conns). It is accessed using atomic memory
+	// operations.
+	nextConn uint32
+}
+
+// clientConn is a connection to the server used by the legacy transport.
+type clientConn struct {
+	// socket is the connected socket.
+	socket *unet.Socket
+
 	// pending is the set of pending messages.
 	pending   map[Tag]*response
 	pendingMu sync.Mutex
@@ -134,11 +150,35 @@ type Client struct {
 	recvr chan bool
 }
 
+func newClientConn(socket *unet.Socket) *clientConn {
+	return &clientConn{
+		socket:  socket,
+		pending: make(map[Tag]*response),
+		recvr:   make(chan bool, 1),
+	}
+}
+
 // NewClient creates a new client.  It performs a Tversion exchange with
 // the server to assert that messageSize is ok to use.
 //
 // If NewClient succeeds, ownership of socket is transferred to the new Client.
 func NewClient(socket *unet.Socket, messageSize uint32, version string) (*Client, error) {
+	return NewMultiClient([]*unet.Socket{socket}, messageSize, version)
+}
+
+// NewMultiClient creates a new client connected to the server with several
+// sockets, which must be handled by the same call to Server.HandleGroup.
+// Requests are distributed over the sockets, so that concurrent requests are
+// not serialized on a single connection when flipcall channels are not
+// available. The first socket is used to negotiate the version, and the
+// others must accept it.
+//
+// If NewMultiClient succeeds, ownership of sockets is transferred to the new
+// Client.
+func NewMultiClient(sockets []*unet.Socket, messageSize uint32, version string) (*Client, error) {
+	if len(sockets) == 0 {
+		return nil, fmt.Errorf("no sockets")
+	}
 	// Need at least one byte of payload.
 	if messageSize <= msgRegistry.largestFixedSize {
 		return nil, &ErrMessageTooLarge{
@@ -154,11 +194,10 @@ func NewClient(socket *unet.Socket, messageSize uint32, version string) (*Client
 		payloadSize -= (payloadSize % 512)
 	}
 	c := &Client{
-		socket:      socket,
+		socket:      sockets[0],
 		tagPool:     pool.Pool{Start: 1, Limit: uint64(NoTag)},
 		fidPool:     pool.Pool{Start: 1, Limit: uint64(NoFID)},
-		pending:     make(map[Tag]*response),
-		recvr:       make(chan bool, 1),
+		conns:       []*clientConn{newClientConn(sockets[0])},
 		messageSize: messageSize,
 		payloadSize: payloadSize,
 	}
@@ -173,7 +212,7 @@ func NewClient(socket *unet.Socket, messageSize uint32, version string) (*Client
 		// our sendRecv function to use that functionality.  Otherwise,
 		// we stick to sendRecvLegacy.
 		rversion := Rversion{}
-		_, err := c.sendRecvLegacy(&Tversion{
+		_, err := c.conns[0].sendRecv(c, &Tversion{
 			Version: versionString(requested),
 			MSize:   messageSize,
 		}, &rversion)
@@ -203,6 +242,23 @@ func NewClient(socket *unet.Socket, messageSize uint32, version string) (*Client
 		break
 	}
 
+	// Negotiate the same version on the other sockets.
+	for _, socket := range sockets[1:] {
+		conn := newClientConn(socket)
+		rversion := Rversion{}
+		if _, err := conn.sendRecv(c, &Tversion{
+			Version: versionString(c.version),
+			MSize:   messageSize,
+		}, &rversion); err != nil {
+			return nil, err
+		}
+		if version, ok := parseVersion(rversion.Version); !ok || version != c.version {
+			log.Warningf("server returned version string %q on additional socket, want %q", rversion.Version, versionString(c.version))
+			return nil, ErrBadVersionString
+		}
+		c.conns = append(c.conns, conn)
+	}
+
 	// Can we switch to use the more advanced channels and create
 	// independent channels for communication? Prefer it if possible.
 	if versionSupportsFlipcall(c.version) {
@@ -225,10 +281,10 @@ func NewClient(socket *unet.Socket, messageSize uint32, version string) (*Client
 		c.sendRecv = c.sendRecvLegacySyscallErr
 	}
 
-	// Ensure that the socket and channels are closed when the socket is shut
+	// Ensure that the sockets and channels are closed when the socket is shut
 	// down.
 	c.closedWg.Add(1)
-	go c.watch(socket) // S/R-SAFE: not relevant.
+	go c.watch(c.socket) // S/R-SAFE: not relevant.
 
 	return c, nil
 }
@@ -287,8 +343,10 @@ func (c *Client) watch(socket *unet.Socket) {
 	}
 	c.channelsMu.Unlock()
 
-	// Close the main socket.
-	c.socket.Close()
+	// Close the main socket, and the other ones.
+	for _, conn := range c.conns {
+		conn.socket.Close()
+	}
 }
 
 // openChannel attempts to open a client channel.
@@ -303,8 +361,9 @@ func (c *Client) openChannel(id int) error {
 		res       = new(channel)
 	)
 
-	// Open the data channel.
-	if _, err := c.sendRecvLegacy(&Tchannel{
+	// Open the data channel. Channels belong to the connection they are
+	// opened on, so always use the first one.
+	if _, err := c.conns[0].sendRecv(c, &Tchannel{
 		ID:      uint32(id),
 		Control: 0,
 	}, &rchannel0); err != nil {
@@ -318,7 +377,7 @@ func (c *Client) openChannel(id int) error {
 	defer rchannel0.FilePayload().Close()
 
 	// Open the channel for file descriptors.
-	if _, err := c.sendRecvLegacy(&Tchannel{
+	if _, err := c.conns[0].sendRecv(c, &Tchannel{
 		ID:      uint32(id),
 		Control: 1,
 	}, &rchannel1); err != nil {
@@ -355,11 +414,11 @@ func (c *Client) openChannel(id int) error {
 //
 // This should only be called with the token from recvr. Note that the received
 // tag will automatically be cleared from pending.
-func (c *Client) handleOne() {
-	tag, r, err := recv(c.socket, c.messageSize, func(tag Tag, t MsgType) (message, error) {
-		c.pendingMu.Lock()
-		resp := c.pending[tag]
-		c.pendingMu.Unlock()
+func (cc *clientConn) handleOne(messageSize uint32) {
+	tag, r, err := recv(cc.socket, messageSize, func(tag Tag, t MsgType) (message, error) {
+		cc.pendingMu.Lock()
+		resp := cc.pending[tag]
+		cc.pendingMu.Unlock()
 
 		// Not expecting this message?
 		if resp == nil {
@@ -386,45 +445,45 @@ func (c *Client) handleOne() {
 		// No tag was extracted (probably a socket error).
 		//
 		// Likely catastrophic. Notify all waiters and clear pending.
-		c.pendingMu.Lock()
-		for _, resp := range c.pending {
+		cc.pendingMu.Lock()
+		for _, resp := range cc.pending {
 			resp.done <- err
 		}
-		c.pending = make(map[Tag]*response)
-		c.pendingMu.Unlock()
+		cc.pending = make(map[Tag]*response)
+		cc.pendingMu.Unlock()
 	} else {
 		// Process the tag.
 		//
 		// We know that is is contained in the map because our lookup function
 		// above must have succeeded (found the tag) to return nil err.
-		c.pendingMu.Lock()
-		resp := c.pending[tag]
-		delete(c.pending, tag)
-		c.pendingMu.Unlock()
+		cc.pendingMu.Lock()
+		resp := cc.pending[tag]
+		delete(cc.pending, tag)
+		cc.pendingMu.Unlock()
 		resp.r = r
 		resp.done <- err
 	}
 }
 
 // waitAndRecv co-ordinates with other receivers to handle responses.
-func (c *Client) waitAndRecv(done chan error) error {
+func (cc *clientConn) waitAndRecv(messageSize uint32, done chan error) error {
 	for {
 		select {
 		case err := <-done:
 			return err
-		case c.recvr <- true:
+		case cc.recvr <- true:
 			select {
 			case err := <-done:
 				// It's possible that we got the token, despite
 				// done also being available. Check for that.
-				<-c.recvr
+				<-cc.recvr
 				return err
 			default:
 				// Handle receiving one tag.
-				c.handleOne()
+				cc.handleOne(messageSize)
 
 				// Return the token.
-				<-c.recvr
+				<-cc.recvr
 			}
 		}
 	}
@@ -449,6 +508,16 @@ func (c *Client) sendRecvLegacySyscallErr(t message, r message) error {
 //
 // This is called by internal functions.
 func (c *Client) sendRecvLegacy(t message, r message) (bool, error) {
+	conn := c.conns[0]
+	if len(c.conns) > 1 {
+		conn = c.conns[atomic.AddUint32(&c.nextConn, 1)%uint32(len(c.conns))]
+	}
+	return conn.sendRecv(c, t, r)
+}
+
+// sendRecv performs a roundtrip message exchange on cc, see
+// Client.sendRecvLegacy.
+func (cc *clientConn) sendRecv(c *Client, t message, r message) (bool, error) {
 	tag, ok := c.tagPool.Get()
 	if !ok {
 		return false, ErrOutOfTags
@@ -462,20 +531,20 @@ func (c *Client) sendRecvLegacy(t message, r message) (bool, error) {
 	resp := responsePool.Get().(*response)
 	defer responsePool.Put(resp)
 	resp.r = r
-	c.pendingMu.Lock()
-	c.pending[Tag(tag)] = resp
-	c.pendingMu.Unlock()
+	cc.pendingMu.Lock()
+	cc.pending[Tag(tag)] = resp
+	cc.pendingMu.Unlock()
 
 	// Send the request over the wire.
-	c.sendMu.Lock()
-	err := send(c.socket, Tag(tag), t)
-	c.sendMu.Unlock()
+	cc.sendMu.Lock()
+	err := send(cc.socket, Tag(tag), t)
+	cc.sendMu.Unlock()
 	if err != nil {
 		return false, err
 	}
 
 	// Co-ordinate with other receivers.
-	if err := c.waitAndRecv(resp.done); err != nil {
+	if err := cc.waitAndRecv(c.messageSize, resp.done); err != nil {
 		return false, err
 	}
 
@@ -565,12 +634,14 @@ func (c *Client) Version() uint32 {
 	return c.version
 }
 
-// Close closes the underlying socket and channels.
+// Close closes the underlying sockets and channels.
 func (c *Client) Close() {
 	// unet.Socket.Shutdown() has no effect if unet.Socket.Close() has already
 	// been called (by c.watch()).
-	if err := c.socket.Shutdown(); err != nil {
-		log.Warningf("Socket.Shutdown() failed (FD: %d): %v", c.socket.FD(), err)
+	for _, conn := range c.conns {
+		if err := conn.socket.Shutdown(); err != nil {
+			log.Warningf("Socket.Shutdown() failed (FD: %d): %v", conn.socket.FD(), err)
+		}
 	}
 	c.closedWg.Wait()
 }
@@ -61,6 +61,69 @@ func TestVersion(t *testing.T) {
 	}
 }
 
+// TestMultiClient tests a client with several sockets.
+func TestMultiClient(t *testing.T) {
+	var serverSockets, clientSockets []*unet.Socket
+	for i := 0; i < 3; i++ {
+		serverSocket, clientSocket, err := unet.SocketPair(false)
+		if err != nil {
+			t.Fatalf("socketpair got err %v expected nil", err)
+		}
+		serverSockets = append(serverSockets, serverSocket)
+		clientSockets = append(clientSockets, clientSocket)
+	}
+
+	s := NewServer(nil)
+	done := make(chan struct{})
+	go func() {
+		s.HandleGroup(serverSockets)
+		close(done)
+	}()
+
+	c, err := NewMultiClient(clientSockets, DefaultMessageSize, HighestVersionString())
+	if err != nil {
+		t.Fatalf("got %v, expected nil", err)
+	}
+
+	// Requests are distributed over all sockets.
+	for i := 0; i < 2*len(clientSockets); i++ {
+		if _, err := c.sendRecvLegacy(&Tversion{Version: versionString(c.Version()), MSize: DefaultMessageSize}, &Rversion{}); err != nil {
+			t.Errorf("got %v expected nil", err)
+		}
+	}
+	if got, want := c.nextConn, uint32(2*len(clientSockets)); got != want {
+		t.Errorf("got %d distributed requests, want %d", got, want)
+	}
+
+	// Closing the client closes all connections.
+	c.Close()
+	<-done
+}
+
+// TestHandleGroupFIDs tests that connections of a group share FIDs.
+func TestHandleGroupFIDs(t *testing.T) {
+	s := NewServer(nil)
+	fids := &fidTable{fids: make(map[FID]*fidRef)}
+	cs1 := &connState{server: s, fids: fids}
+	cs2 := &connState{server: s, fids: fids}
+
+	// Hold a reference, so that the nil file is never closed.
+	ref := &fidRef{server: s}
+	ref.IncRef()
+	cs1.InsertFID(1, ref)
+	got, ok := cs2.LookupFID(1)
+	if !ok || got != ref {
+		t.Fatalf("LookupFID(1) got (%p, %t), want (%p, true)", got, ok, ref)
+	}
+	got.DecRef()
+	if !cs2.DeleteFID(1) {
+		t.Errorf("DeleteFID(1) got false, want true")
+	}
+	if _, ok := cs1.LookupFID(1); ok {
+		t.Errorf("LookupFID(1) succeeded after DeleteFID(1)")
+	}
+}
+
 func benchmarkSendRecv(b *testing.B, fn func(c *Client) func(message, message) error) {
 	b.ReportAllocs()
 
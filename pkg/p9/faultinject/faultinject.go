@@ -0,0 +1,532 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package faultinject provides a p9.Attacher that wraps another one and fails
+// selected operations with injected errors. It's used to test how the sentry
+// handles gofer failures.
+//
+// Faults are described by rules, which can be read from a file that's reloaded
+// whenever it changes, so that tests can inject faults into a running gofer.
+// Each rule has the format:
+//
+//	<op>:<errno>[:<count>]
+//
+// op is the name of a p9.File method in lower case, e.g. "walk" or "open", or
+// "*" to match all methods. errno is the name of the error, e.g. "EIO". count
+// is the number of times the fault is injected, forever if omitted. Rules are
+// separated by commas or new lines, and the first matching rule applies.
+package faultinject
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+	"gvisor.dev/gvisor/pkg/fd"
+	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/pkg/p9"
+	"gvisor.dev/gvisor/pkg/sync"
+)
+
+// maxErrno is the highest errno value on Linux.
+const maxErrno = 133
+
+// Rule injects Errno into operations named Op.
+type Rule struct {
+	// Op is the name of the operation, or "*" for all operations.
+	Op string
+
+	// Errno is the error returned by the operation.
+	Errno unix.Errno
+
+	// Count is the number of times the fault is injected. Zero means forever.
+	Count int
+}
+
+// String implements fmt.Stringer.
+func (r Rule) String() string {
+	s := r.Op + ":" + unix.ErrnoName(r.Errno)
+	if r.Count != 0 {
+		s += ":" + strconv.Itoa(r.Count)
+	}
+	return s
+}
+
+// errnoValue returns the errno with the given name, e.g. "EIO".
+func errnoValue(name string) (unix.Errno, bool) {
+	for i := 1; i <= maxErrno; i++ {
+		if e := unix.Errno(i); unix.ErrnoName(e) == name {
+			return e, true
+		}
+	}
+	return 0, false
+}
+
+// ParseRules parses rules in the format described in the package comment.
+func ParseRules(s string) ([]Rule, error) {
+	var rules []Rule
+	for _, field := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == '\n' }) {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		parts := strings.Split(field, ":")
+		if len(parts) != 2 && len(parts) != 3 {
+			return nil, fmt.Errorf("invalid rule %q, want <op>:<errno>[:<count>]", field)
+		}
+		r := Rule{Op: strings.ToLower(parts[0])}
+		errno, ok := errnoValue(parts[1])
+		if !ok {
+			return nil, fmt.Errorf("invalid rule %q: unknown errno %q", field, parts[1])
+		}
+		r.Errno = errno
+		if len(parts) == 3 {
+			count, err := strconv.Atoi(parts[2])
+			if err != nil || count <= 0 {
+				return nil, fmt.Errorf("invalid rule %q: invalid count %q", field, parts[2])
+			}
+			r.Count = count
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// Injector decides which operations fail. It's safe for concurrent use.
+type Injector struct {
+	// dirFD and name are the directory and name of the file rules are loaded
+	// from. dirFD is -1 if rules are only set with SetRules. The directory
+	// is opened upfront, so that the file can be found after the gofer
+	// changes its root.
+	dirFD int
+	name  string
+
+	mu sync.Mutex
+
+	// loaded identifies the file rules were loaded from. It's zero if no
+	// rules were loaded from a file.
+	loaded fileVersion
+
+	// rules are the active rules. Count is decremented as faults are
+	// injected, and rules are removed once it reaches zero.
+	rules []Rule
+}
+
+// fileVersion identifies a version of the rules file. WriteRules replaces the
+// file, so the inode changes even if the modification time doesn't.
+type fileVersion struct {
+	ino   uint64
+	mtime unix.Timespec
+}
+
+// NewInjector returns an Injector whose rules are set with SetRules.
+func NewInjector() *Injector {
+	return &Injector{dirFD: -1}
+}
+
+// NewFileInjector returns an Injector that loads rules from path. The file
+// doesn't need to exist, no faults are injected until it does, but its
+// directory does.
+func NewFileInjector(path string) (*Injector, error) {
+	dirFD, err := unix.Open(filepath.Dir(path), unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("opening directory of %q: %v", path, err)
+	}
+	return NewDirInjector(dirFD, filepath.Base(path)), nil
+}
+
+// NewDirInjector returns an Injector that loads rules from the file called
+// name in the directory dirFD. It takes ownership of dirFD.
+func NewDirInjector(dirFD int, name string) *Injector {
+	return &Injector{dirFD: dirFD, name: name}
+}
+
+// SetRules replaces the active rules.
+func (i *Injector) SetRules(rules []Rule) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.rules = append([]Rule(nil), rules...)
+}
+
+// WriteRules atomically writes rules to path, to be picked up by the Injector
+// loading rules from it.
+func WriteRules(path string, rules ...Rule) error {
+	var b strings.Builder
+	for _, r := range rules {
+		b.WriteString(r.String())
+		b.WriteString("\n")
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(b.String()), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// reloadLocked reloads the rules if the file changed since they were loaded.
+//
+// Preconditions: i.mu is locked.
+func (i *Injector) reloadLocked() {
+	if i.dirFD < 0 {
+		return
+	}
+	fd, err := unix.Openat(i.dirFD, i.name, unix.O_RDONLY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		if i.loaded != (fileVersion{}) {
+			log.Infof("Fault injection rules removed, disabling faults")
+			i.loaded = fileVersion{}
+			i.rules = nil
+		}
+		return
+	}
+	f := os.NewFile(uintptr(fd), i.name)
+	defer f.Close()
+
+	var st unix.Stat_t
+	if err := unix.Fstat(fd, &st); err != nil {
+		log.Warningf("Error reading fault injection rules from %q: %v", i.name, err)
+		return
+	}
+	version := fileVersion{ino: st.Ino, mtime: st.Mtim}
+	if version == i.loaded {
+		return
+	}
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		log.Warningf("Error reading fault injection rules from %q: %v", i.name, err)
+		return
+	}
+	rules, err := ParseRules(string(data))
+	if err != nil {
+		log.Warningf("Error parsing fault injection rules from %q: %v", i.name, err)
+		return
+	}
+	log.Infof("Fault injection rules loaded: %v", rules)
+	i.loaded = version
+	i.rules = rules
+}
+
+// Fault returns the error to inject into op, or nil if op must run.
+func (i *Injector) Fault(op string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.reloadLocked()
+	for idx := range i.rules {
+		r := &i.rules[idx]
+		if r.Op != "*" && r.Op != op {
+			continue
+		}
+		errno := r.Errno
+		if r.Count != 0 {
+			r.Count--
+			if r.Count == 0 {
+				i.rules = append(i.rules[:idx], i.rules[idx+1:]...)
+			}
+		}
+		log.Debugf("Injecting %v into %s", errno, op)
+		return errno
+	}
+	return nil
+}
+
+// attacher wraps a p9.Attacher.
+type attacher struct {
+	p9.Attacher
+	injector *Injector
+}
+
+// NewAttacher returns a p9.Attacher that injects the faults decided by
+// injector into the files attached by at.
+func NewAttacher(at p9.Attacher, injector *Injector) p9.Attacher {
+	return &attacher{Attacher: at, injector: injector}
+}
+
+// Attach implements p9.Attacher.Attach.
+func (a *attacher) Attach() (p9.File, error) {
+	if err := a.injector.Fault("attach"); err != nil {
+		return nil, err
+	}
+	f, err := a.Attacher.Attach()
+	if err != nil {
+		return nil, err
+	}
+	return a.wrap(f), nil
+}
+
+// wrap wraps f, which may be nil.
+func (a *attacher) wrap(f p9.File) p9.File {
+	if f == nil {
+		return nil
+	}
+	return &file{file: f, injector: a.injector, attacher: a}
+}
+
+// unwrap returns the file wrapped by f, if f was returned by this package.
+func unwrap(f p9.File) p9.File {
+	if w, ok := f.(*file); ok {
+		return w.file
+	}
+	return f
+}
+
+// file wraps a p9.File, injecting faults before calling it.
+type file struct {
+	file     p9.File
+	injector *Injector
+	attacher *attacher
+}
+
+var _ p9.File = (*file)(nil)
+
+// Walk implements p9.File.Walk.
+func (f *file) Walk(names []string) ([]p9.QID, p9.File, error) {
+	if err := f.injector.Fault("walk"); err != nil {
+		return nil, nil, err
+	}
+	qids, newFile, err := f.file.Walk(names)
+	return qids, f.attacher.wrap(newFile), err
+}
+
+// WalkGetAttr implements p9.File.WalkGetAttr.
+func (f *file) WalkGetAttr(names []string) ([]p9.QID, p9.File, p9.AttrMask, p9.Attr, error) {
+	if err := f.injector.Fault("walkgetattr"); err != nil {
+		return nil, nil, p9.AttrMask{}, p9.Attr{}, err
+	}
+	qids, newFile, mask, attr, err := f.file.WalkGetAttr(names)
+	return qids, f.attacher.wrap(newFile), mask, attr, err
+}
+
+// MultiGetAttr implements p9.File.MultiGetAttr.
+func (f *file) MultiGetAttr(names []string) ([]p9.FullStat, error) {
+	if err := f.injector.Fault("multigetattr"); err != nil {
+		return nil, err
+	}
+	return f.file.MultiGetAttr(names)
+}
+
+// StatFS implements p9.File.StatFS.
+func (f *file) StatFS() (p9.FSStat, error) {
+	if err := f.injector.Fault("statfs"); err != nil {
+		return p9.FSStat{}, err
+	}
+	return f.file.StatFS()
+}
+
+// GetAttr implements p9.File.GetAttr.
+func (f *file) GetAttr(req p9.AttrMask) (p9.QID, p9.AttrMask, p9.Attr, error) {
+	if err := f.injector.Fault("getattr"); err != nil {
+		return p9.QID{}, p9.AttrMask{}, p9.Attr{}, err
+	}
+	return f.file.GetAttr(req)
+}
+
+// SetAttr implements p9.File.SetAttr.
+func (f *file) SetAttr(valid p9.SetAttrMask, attr p9.SetAttr) error {
+	if err := f.injector.Fault("setattr"); err != nil {
+		return err
+	}
+	return f.file.SetAttr(valid, attr)
+}
+
+// GetXattr implements p9.File.GetXattr.
+func (f *file) GetXattr(name string, size uint64) (string, error) {
+	if err := f.injector.Fault("getxattr"); err != nil {
+		return "", err
+	}
+	return f.file.GetXattr(name, size)
+}
+
+// SetXattr implements p9.File.SetXattr.
+func (f *file) SetXattr(name, value string, flags uint32) error {
+	if err := f.injector.Fault("setxattr"); err != nil {
+		return err
+	}
+	return f.file.SetXattr(name, value, flags)
+}
+
+// ListXattr implements p9.File.ListXattr.
+func (f *file) ListXattr(size uint64) (map[string]struct{}, error) {
+	if err := f.injector.Fault("listxattr"); err != nil {
+		return nil, err
+	}
+	return f.file.ListXattr(size)
+}
+
+// RemoveXattr implements p9.File.RemoveXattr.
+func (f *file) RemoveXattr(name string) error {
+	if err := f.injector.Fault("removexattr"); err != nil {
+		return err
+	}
+	return f.file.RemoveXattr(name)
+}
+
+// Allocate implements p9.File.Allocate.
+func (f *file) Allocate(mode p9.AllocateMode, offset, length uint64) error {
+	if err := f.injector.Fault("allocate"); err != nil {
+		return err
+	}
+	return f.file.Allocate(mode, offset, length)
+}
+
+// Close implements p9.File.Close. Faults are never injected, since the file
+// must be released regardless.
+func (f *file) Close() error {
+	return f.file.Close()
+}
+
+// SetAttrClose implements p9.File.SetAttrClose.
+func (f *file) SetAttrClose(valid p9.SetAttrMask, attr p9.SetAttr) error {
+	if err := f.injector.Fault("setattrclose"); err != nil {
+		return err
+	}
+	return f.file.SetAttrClose(valid, attr)
+}
+
+// Open implements p9.File.Open.
+func (f *file) Open(flags p9.OpenFlags) (*fd.FD, p9.QID, uint32, error) {
+	if err := f.injector.Fault("open"); err != nil {
+		return nil, p9.QID{}, 0, err
+	}
+	return f.file.Open(flags)
+}
+
+// ReadAt implements p9.File.ReadAt.
+func (f *file) ReadAt(p []byte, offset uint64) (int, error) {
+	if err := f.injector.Fault("readat"); err != nil {
+		return 0, err
+	}
+	return f.file.ReadAt(p, offset)
+}
+
+// WriteAt implements p9.File.WriteAt.
+func (f *file) WriteAt(p []byte, offset uint64) (int, error) {
+	if err := f.injector.Fault("writeat"); err != nil {
+		return 0, err
+	}
+	return f.file.WriteAt(p, offset)
+}
+
+// FSync implements p9.File.FSync.
+func (f *file) FSync() error {
+	if err := f.injector.Fault("fsync"); err != nil {
+		return err
+	}
+	return f.file.FSync()
+}
+
+// Create implements p9.File.Create.
+func (f *file) Create(name string, flags p9.OpenFlags, permissions p9.FileMode, uid p9.UID, gid p9.GID) (*fd.FD, p9.File, p9.QID, uint32, error) {
+	if err := f.injector.Fault("create"); err != nil {
+		return nil, nil, p9.QID{}, 0, err
+	}
+	hostFD, newFile, qid, ioUnit, err := f.file.Create(name, flags, permissions, uid, gid)
+	return hostFD, f.attacher.wrap(newFile), qid, ioUnit, err
+}
+
+// Mkdir implements p9.File.Mkdir.
+func (f *file) Mkdir(name string, permissions p9.FileMode, uid p9.UID, gid p9.GID) (p9.QID, error) {
+	if err := f.injector.Fault("mkdir"); err != nil {
+		return p9.QID{}, err
+	}
+	return f.file.Mkdir(name, permissions, uid, gid)
+}
+
+// Symlink implements p9.File.Symlink.
+func (f *file) Symlink(oldName string, newName string, uid p9.UID, gid p9.GID) (p9.QID, error) {
+	if err := f.injector.Fault("symlink"); err != nil {
+		return p9.QID{}, err
+	}
+	return f.file.Symlink(oldName, newName, uid, gid)
+}
+
+// Link implements p9.File.Link.
+func (f *file) Link(target p9.File, newName string) error {
+	if err := f.injector.Fault("link"); err != nil {
+		return err
+	}
+	return f.file.Link(unwrap(target), newName)
+}
+
+// Mknod implements p9.File.Mknod.
+func (f *file) Mknod(name string, mode p9.FileMode, major uint32, minor uint32, uid p9.UID, gid p9.GID) (p9.QID, error) {
+	if err := f.injector.Fault("mknod"); err != nil {
+		return p9.QID{}, err
+	}
+	return f.file.Mknod(name, mode, major, minor, uid, gid)
+}
+
+// Rename implements p9.File.Rename.
+func (f *file) Rename(newDir p9.File, newName string) error {
+	if err := f.injector.Fault("rename"); err != nil {
+		return err
+	}
+	return f.file.Rename(unwrap(newDir), newName)
+}
+
+// RenameAt implements p9.File.RenameAt.
+func (f *file) RenameAt(oldName string, newDir p9.File, newName string) error {
+	if err := f.injector.Fault("renameat"); err != nil {
+		return err
+	}
+	return f.file.RenameAt(oldName, unwrap(newDir), newName)
+}
+
+// UnlinkAt implements p9.File.UnlinkAt.
+func (f *file) UnlinkAt(name string, flags uint32) error {
+	if err := f.injector.Fault("unlinkat"); err != nil {
+		return err
+	}
+	return f.file.UnlinkAt(name, flags)
+}
+
+// Readdir implements p9.File.Readdir.
+func (f *file) Readdir(offset uint64, count uint32) ([]p9.Dirent, error) {
+	if err := f.injector.Fault("readdir"); err != nil {
+		return nil, err
+	}
+	return f.file.Readdir(offset, count)
+}
+
+// Readlink implements p9.File.Readlink.
+func (f *file) Readlink() (string, error) {
+	if err := f.injector.Fault("readlink"); err != nil {
+		return "", err
+	}
+	return f.file.Readlink()
+}
+
+// Flush implements p9.File.Flush.
+func (f *file) Flush() error {
+	if err := f.injector.Fault("flush"); err != nil {
+		return err
+	}
+	return f.file.Flush()
+}
+
+// Connect implements p9.File.Connect.
+func (f *file) Connect(flags p9.ConnectFlags) (*fd.FD, error) {
+	if err := f.injector.Fault("connect"); err != nil {
+		return nil, err
+	}
+	return f.file.Connect(flags)
+}
+
+// Renamed implements p9.File.Renamed. It can't fail.
+func (f *file) Renamed(newDir p9.File, newName string) {
+	f.file.Renamed(unwrap(newDir), newName)
+}
@@ -0,0 +1,193 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package faultinject
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/unix"
+	"gvisor.dev/gvisor/pkg/p9"
+)
+
+func TestParseRules(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want []Rule
+	}{
+		{in: "", want: nil},
+		{in: "open:EIO", want: []Rule{{Op: "open", Errno: unix.EIO}}},
+		{
+			in: "Walk:ENOENT:2, *:EACCES\nreadat:EIO",
+			want: []Rule{
+				{Op: "walk", Errno: unix.ENOENT, Count: 2},
+				{Op: "*", Errno: unix.EACCES},
+				{Op: "readat", Errno: unix.EIO},
+			},
+		},
+	} {
+		got, err := ParseRules(tc.in)
+		if err != nil {
+			t.Errorf("ParseRules(%q) failed: %v", tc.in, err)
+			continue
+		}
+		if len(got) != len(tc.want) {
+			t.Errorf("ParseRules(%q) = %v, want %v", tc.in, got, tc.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Errorf("ParseRules(%q)[%d] = %v, want %v", tc.in, i, got[i], tc.want[i])
+			}
+		}
+	}
+
+	for _, in := range []string{"open", "open:EFOO", "open:EIO:0", "open:EIO:x", "open:EIO:1:2"} {
+		if _, err := ParseRules(in); err == nil {
+			t.Errorf("ParseRules(%q) succeeded, want error", in)
+		}
+	}
+}
+
+func TestFaultCount(t *testing.T) {
+	i := NewInjector()
+	i.SetRules([]Rule{
+		{Op: "open", Errno: unix.EIO, Count: 2},
+		{Op: "*", Errno: unix.EACCES, Count: 1},
+	})
+	for n, want := range []error{unix.EIO, unix.EIO, unix.EACCES, nil} {
+		if got := i.Fault("open"); got != want {
+			t.Errorf("Fault #%d: got %v, want %v", n, got, want)
+		}
+	}
+	if got := i.Fault("walk"); got != nil {
+		t.Errorf("Fault(walk): got %v, want nil", got)
+	}
+}
+
+func TestFaultFile(t *testing.T) {
+	dir, err := ioutil.TempDir(testDir(), "faultinject")
+	if err != nil {
+		t.Fatalf("TempDir failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "rules")
+
+	i, err := NewFileInjector(path)
+	if err != nil {
+		t.Fatalf("NewFileInjector failed: %v", err)
+	}
+	if got := i.Fault("open"); got != nil {
+		t.Errorf("Fault without rules: got %v, want nil", got)
+	}
+	if err := WriteRules(path, Rule{Op: "open", Errno: unix.EIO}); err != nil {
+		t.Fatalf("WriteRules failed: %v", err)
+	}
+	if got := i.Fault("open"); got != unix.EIO {
+		t.Errorf("Fault after WriteRules: got %v, want %v", got, unix.EIO)
+	}
+
+	if err := WriteRules(path, Rule{Op: "walk", Errno: unix.ENOENT}); err != nil {
+		t.Fatalf("WriteRules failed: %v", err)
+	}
+	if got := i.Fault("open"); got != nil {
+		t.Errorf("Fault(open) after rewrite: got %v, want nil", got)
+	}
+	if got := i.Fault("walk"); got != unix.ENOENT {
+		t.Errorf("Fault(walk) after rewrite: got %v, want %v", got, unix.ENOENT)
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if got := i.Fault("walk"); got != nil {
+		t.Errorf("Fault after removing rules: got %v, want nil", got)
+	}
+}
+
+// stubAttacher attaches stubFiles.
+type stubAttacher struct {
+	p9.NoServerOptions
+}
+
+// Attach implements p9.Attacher.Attach.
+func (*stubAttacher) Attach() (p9.File, error) {
+	return &stubFile{}, nil
+}
+
+// stubFile implements the few p9.File methods used in tests.
+type stubFile struct {
+	p9.File
+	renamedTo p9.File
+}
+
+// Walk implements p9.File.Walk.
+func (*stubFile) Walk(names []string) ([]p9.QID, p9.File, error) {
+	return nil, &stubFile{}, nil
+}
+
+// Readlink implements p9.File.Readlink.
+func (*stubFile) Readlink() (string, error) {
+	return "target", nil
+}
+
+// Renamed implements p9.File.Renamed.
+func (s *stubFile) Renamed(newDir p9.File, newName string) {
+	s.renamedTo = newDir
+}
+
+func TestAttacher(t *testing.T) {
+	i := NewInjector()
+	at := NewAttacher(&stubAttacher{}, i)
+
+	root, err := at.Attach()
+	if err != nil {
+		t.Fatalf("Attach failed: %v", err)
+	}
+	_, child, err := root.Walk([]string{"child"})
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+	if _, err := child.Readlink(); err != nil {
+		t.Errorf("Readlink failed: %v", err)
+	}
+
+	i.SetRules([]Rule{{Op: "readlink", Errno: unix.EIO}})
+	if _, err := child.Readlink(); err != unix.EIO {
+		t.Errorf("Readlink on walked file: got %v, want %v", err, unix.EIO)
+	}
+	if _, _, err := root.Walk(nil); err != nil {
+		t.Errorf("Walk with readlink fault: got %v, want nil", err)
+	}
+
+	// Files passed to the wrapped files must be unwrapped, since backends
+	// expect their own type.
+	child.Renamed(root, "child")
+	if got, want := child.(*file).file.(*stubFile).renamedTo, root.(*file).file; got != want {
+		t.Errorf("Renamed got %v, want %v", got, want)
+	}
+
+	i.SetRules([]Rule{{Op: "attach", Errno: unix.EACCES}})
+	if _, err := at.Attach(); err != unix.EACCES {
+		t.Errorf("Attach: got %v, want %v", err, unix.EACCES)
+	}
+}
+
+// testDir returns a directory for temporary files.
+func testDir() string {
+	return os.Getenv("TEST_TMPDIR")
+}
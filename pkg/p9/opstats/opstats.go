@@ -0,0 +1,574 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package opstats provides a p9.Attacher that wraps another one and records
+// statistics of the operations on the files it attaches: per-operation counts,
+// errors and latency percentiles, and a log of slow operations with the path
+// of their file. It's used to diagnose slow I/O on gofer mounts.
+package opstats
+
+import (
+	"math/bits"
+	"path"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"gvisor.dev/gvisor/pkg/fd"
+	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/pkg/p9"
+	"gvisor.dev/gvisor/pkg/sync"
+)
+
+// numBuckets is the number of latency buckets. Bucket i holds latencies below
+// 2^i microseconds, the last one holds all the others.
+const numBuckets = 28
+
+// maxSlowOps is the number of slow operations kept by a Recorder.
+const maxSlowOps = 100
+
+// OpStats are the statistics of an operation.
+type OpStats struct {
+	// Count is the number of times the operation ran.
+	Count uint64 `json:"count"`
+
+	// Errors is the number of times the operation failed.
+	Errors uint64 `json:"errors"`
+
+	// Total is the total time spent in the operation.
+	Total time.Duration `json:"total"`
+
+	// P50, P90 and P99 are latency percentiles. They are upper bounds,
+	// accurate within a factor of 2.
+	P50 time.Duration `json:"p50"`
+	P90 time.Duration `json:"p90"`
+	P99 time.Duration `json:"p99"`
+
+	// Max is the highest latency.
+	Max time.Duration `json:"max"`
+}
+
+// SlowOp is an operation that took longer than the slow operation threshold.
+type SlowOp struct {
+	// Time is the time the operation started.
+	Time time.Time `json:"time"`
+
+	// Op is the name of the operation, e.g. "walk".
+	Op string `json:"op"`
+
+	// Path is the path of the file in the container.
+	Path string `json:"path"`
+
+	// Duration is the time the operation took.
+	Duration time.Duration `json:"duration"`
+
+	// Error is the error returned by the operation, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// Stats are the statistics recorded by a Recorder.
+type Stats struct {
+	// Ops maps operation names to their statistics.
+	Ops map[string]OpStats `json:"ops"`
+
+	// SlowOps are the last slow operations, oldest first.
+	SlowOps []SlowOp `json:"slowOps"`
+
+	// SlowThreshold is the latency above which operations are slow.
+	SlowThreshold time.Duration `json:"slowThreshold"`
+}
+
+// opRecord accumulates the statistics of an operation. Its fields are
+// accessed atomically, so that operations don't contend on a lock.
+type opRecord struct {
+	errors  uint64
+	total   int64
+	max     int64
+	buckets [numBuckets]uint64
+}
+
+// record records an operation that took d and returned err.
+func (r *opRecord) record(d time.Duration, err error) {
+	if err != nil {
+		atomic.AddUint64(&r.errors, 1)
+	}
+	atomic.AddInt64(&r.total, int64(d))
+	for {
+		max := atomic.LoadInt64(&r.max)
+		if int64(d) <= max || atomic.CompareAndSwapInt64(&r.max, max, int64(d)) {
+			break
+		}
+	}
+	atomic.AddUint64(&r.buckets[bucket(d)], 1)
+}
+
+// opSnapshot is a snapshot of an opRecord.
+type opSnapshot struct {
+	count   uint64
+	errors  uint64
+	total   time.Duration
+	max     time.Duration
+	buckets [numBuckets]uint64
+}
+
+// snapshot returns a snapshot of r. The count of operations is the sum of
+// the buckets, so that percentiles are consistent with it.
+func (r *opRecord) snapshot() opSnapshot {
+	s := opSnapshot{
+		errors: atomic.LoadUint64(&r.errors),
+		total:  time.Duration(atomic.LoadInt64(&r.total)),
+		max:    time.Duration(atomic.LoadInt64(&r.max)),
+	}
+	for i := range r.buckets {
+		s.buckets[i] = atomic.LoadUint64(&r.buckets[i])
+		s.count += s.buckets[i]
+	}
+	return s
+}
+
+// bucket returns the latency bucket of d.
+func bucket(d time.Duration) int {
+	b := bits.Len64(uint64(d / time.Microsecond))
+	if b >= numBuckets {
+		b = numBuckets - 1
+	}
+	return b
+}
+
+// percentile returns an upper bound of the latency below which a fraction q of
+// the operations completed.
+func (r *opSnapshot) percentile(q float64) time.Duration {
+	target := uint64(q * float64(r.count))
+	if target == 0 {
+		target = 1
+	}
+	var seen uint64
+	for i, n := range r.buckets {
+		seen += n
+		if seen >= target {
+			if upper := time.Duration(1<<i) * time.Microsecond; i < numBuckets-1 && upper < r.max {
+				return upper
+			}
+			return r.max
+		}
+	}
+	return r.max
+}
+
+// Recorder records operation statistics. It's safe for concurrent use.
+type Recorder struct {
+	// slowThreshold is the latency above which operations are logged and
+	// kept in slowOps. Zero disables it.
+	slowThreshold time.Duration
+
+	// ops maps operation names to their *opRecord. It's only written the
+	// first time an operation is recorded.
+	ops sync.Map
+
+	// slowMu protects slowOps and slowNext. It's only taken by slow
+	// operations.
+	slowMu sync.Mutex
+
+	// slowOps is a ring buffer of the last slow operations, and slowNext
+	// the index of the next one.
+	slowOps  []SlowOp
+	slowNext int
+}
+
+// NewRecorder returns a Recorder keeping operations slower than
+// slowThreshold, or none if it's zero.
+func NewRecorder(slowThreshold time.Duration) *Recorder {
+	return &Recorder{slowThreshold: slowThreshold}
+}
+
+// op returns the record of operation op.
+func (r *Recorder) op(op string) *opRecord {
+	if rec, ok := r.ops.Load(op); ok {
+		return rec.(*opRecord)
+	}
+	rec, _ := r.ops.LoadOrStore(op, &opRecord{})
+	return rec.(*opRecord)
+}
+
+// Record records operation op on the file at p, started at start.
+func (r *Recorder) Record(op, p string, start time.Time, err error) {
+	d := time.Since(start)
+	slow := r.slowThreshold != 0 && d >= r.slowThreshold
+
+	r.op(op).record(d, err)
+	if !slow {
+		return
+	}
+
+	s := SlowOp{
+		Time:     start,
+		Op:       op,
+		Path:     p,
+		Duration: d,
+	}
+	if err != nil {
+		s.Error = err.Error()
+	}
+	r.slowMu.Lock()
+	if len(r.slowOps) < maxSlowOps {
+		r.slowOps = append(r.slowOps, s)
+	} else {
+		r.slowOps[r.slowNext] = s
+	}
+	r.slowNext = (r.slowNext + 1) % maxSlowOps
+	r.slowMu.Unlock()
+
+	log.Warningf("Slow gofer operation: %s %q took %v, error: %v", op, p, d, err)
+}
+
+// Stats returns a snapshot of the statistics.
+func (r *Recorder) Stats() Stats {
+	s := Stats{
+		Ops:           make(map[string]OpStats),
+		SlowThreshold: r.slowThreshold,
+	}
+	r.ops.Range(func(key, value interface{}) bool {
+		rec := value.(*opRecord).snapshot()
+		s.Ops[key.(string)] = OpStats{
+			Count:  rec.count,
+			Errors: rec.errors,
+			Total:  rec.total,
+			P50:    rec.percentile(0.5),
+			P90:    rec.percentile(0.9),
+			P99:    rec.percentile(0.99),
+			Max:    rec.max,
+		}
+		return true
+	})
+	r.slowMu.Lock()
+	s.SlowOps = append(s.SlowOps, r.slowOps...)
+	r.slowMu.Unlock()
+	sort.Slice(s.SlowOps, func(i, j int) bool {
+		return s.SlowOps[i].Time.Before(s.SlowOps[j].Time)
+	})
+	return s
+}
+
+// attacher wraps a p9.Attacher.
+type attacher struct {
+	p9.Attacher
+	recorder *Recorder
+
+	// root is the path of the mount in the container.
+	root string
+}
+
+// NewAttacher returns a p9.Attacher that records the operations on the files
+// attached by at to recorder. root is the path of the mount served by at in the
+// container, used to log the paths of files.
+func NewAttacher(at p9.Attacher, recorder *Recorder, root string) p9.Attacher {
+	return &attacher{Attacher: at, recorder: recorder, root: root}
+}
+
+// Attach implements p9.Attacher.Attach.
+func (a *attacher) Attach() (p9.File, error) {
+	start := time.Now()
+	f, err := a.Attacher.Attach()
+	a.recorder.Record("attach", a.root, start, err)
+	return a.wrap(f, a.root), err
+}
+
+// wrap wraps f, which may be nil, at path p.
+func (a *attacher) wrap(f p9.File, p string) p9.File {
+	if f == nil {
+		return nil
+	}
+	return &file{file: f, attacher: a, path: p}
+}
+
+// unwrap returns the file wrapped by f, if f was returned by this package.
+func unwrap(f p9.File) p9.File {
+	if w, ok := f.(*file); ok {
+		return w.file
+	}
+	return f
+}
+
+// file wraps a p9.File, recording its operations.
+type file struct {
+	file     p9.File
+	attacher *attacher
+
+	// mu protects path.
+	mu sync.Mutex
+
+	// path is the path of the file, updated when it's renamed.
+	path string
+}
+
+var _ p9.File = (*file)(nil)
+
+// getPath returns the path of f.
+func (f *file) getPath() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.path
+}
+
+// record records operation op on the file at p, started at start.
+func (f *file) record(op, p string, start time.Time, err error) {
+	f.attacher.recorder.Record(op, p, start, err)
+}
+
+// Walk implements p9.File.Walk.
+func (f *file) Walk(names []string) ([]p9.QID, p9.File, error) {
+	start := time.Now()
+	p := path.Join(append([]string{f.getPath()}, names...)...)
+	qids, newFile, err := f.file.Walk(names)
+	f.record("walk", p, start, err)
+	return qids, f.attacher.wrap(newFile, p), err
+}
+
+// WalkGetAttr implements p9.File.WalkGetAttr.
+func (f *file) WalkGetAttr(names []string) ([]p9.QID, p9.File, p9.AttrMask, p9.Attr, error) {
+	start := time.Now()
+	p := path.Join(append([]string{f.getPath()}, names...)...)
+	qids, newFile, mask, attr, err := f.file.WalkGetAttr(names)
+	f.record("walkgetattr", p, start, err)
+	return qids, f.attacher.wrap(newFile, p), mask, attr, err
+}
+
+// MultiGetAttr implements p9.File.MultiGetAttr.
+func (f *file) MultiGetAttr(names []string) ([]p9.FullStat, error) {
+	start := time.Now()
+	stats, err := f.file.MultiGetAttr(names)
+	f.record("multigetattr", path.Join(append([]string{f.getPath()}, names...)...), start, err)
+	return stats, err
+}
+
+// StatFS implements p9.File.StatFS.
+func (f *file) StatFS() (p9.FSStat, error) {
+	start := time.Now()
+	st, err := f.file.StatFS()
+	f.record("statfs", f.getPath(), start, err)
+	return st, err
+}
+
+// GetAttr implements p9.File.GetAttr.
+func (f *file) GetAttr(req p9.AttrMask) (p9.QID, p9.AttrMask, p9.Attr, error) {
+	start := time.Now()
+	qid, mask, attr, err := f.file.GetAttr(req)
+	f.record("getattr", f.getPath(), start, err)
+	return qid, mask, attr, err
+}
+
+// SetAttr implements p9.File.SetAttr.
+func (f *file) SetAttr(valid p9.SetAttrMask, attr p9.SetAttr) error {
+	start := time.Now()
+	err := f.file.SetAttr(valid, attr)
+	f.record("setattr", f.getPath(), start, err)
+	return err
+}
+
+// GetXattr implements p9.File.GetXattr.
+func (f *file) GetXattr(name string, size uint64) (string, error) {
+	start := time.Now()
+	value, err := f.file.GetXattr(name, size)
+	f.record("getxattr", f.getPath(), start, err)
+	return value, err
+}
+
+// SetXattr implements p9.File.SetXattr.
+func (f *file) SetXattr(name, value string, flags uint32) error {
+	start := time.Now()
+	err := f.file.SetXattr(name, value, flags)
+	f.record("setxattr", f.getPath(), start, err)
+	return err
+}
+
+// ListXattr implements p9.File.ListXattr.
+func (f *file) ListXattr(size uint64) (map[string]struct{}, error) {
+	start := time.Now()
+	names, err := f.file.ListXattr(size)
+	f.record("listxattr", f.getPath(), start, err)
+	return names, err
+}
+
+// RemoveXattr implements p9.File.RemoveXattr.
+func (f *file) RemoveXattr(name string) error {
+	start := time.Now()
+	err := f.file.RemoveXattr(name)
+	f.record("removexattr", f.getPath(), start, err)
+	return err
+}
+
+// Allocate implements p9.File.Allocate.
+func (f *file) Allocate(mode p9.AllocateMode, offset, length uint64) error {
+	start := time.Now()
+	err := f.file.Allocate(mode, offset, length)
+	f.record("allocate", f.getPath(), start, err)
+	return err
+}
+
+// Close implements p9.File.Close.
+func (f *file) Close() error {
+	start := time.Now()
+	err := f.file.Close()
+	f.record("close", f.getPath(), start, err)
+	return err
+}
+
+// SetAttrClose implements p9.File.SetAttrClose.
+func (f *file) SetAttrClose(valid p9.SetAttrMask, attr p9.SetAttr) error {
+	start := time.Now()
+	err := f.file.SetAttrClose(valid, attr)
+	f.record("setattrclose", f.getPath(), start, err)
+	return err
+}
+
+// Open implements p9.File.Open.
+func (f *file) Open(flags p9.OpenFlags) (*fd.FD, p9.QID, uint32, error) {
+	start := time.Now()
+	hostFD, qid, ioUnit, err := f.file.Open(flags)
+	f.record("open", f.getPath(), start, err)
+	return hostFD, qid, ioUnit, err
+}
+
+// ReadAt implements p9.File.ReadAt.
+func (f *file) ReadAt(p []byte, offset uint64) (int, error) {
+	start := time.Now()
+	n, err := f.file.ReadAt(p, offset)
+	f.record("readat", f.getPath(), start, err)
+	return n, err
+}
+
+// WriteAt implements p9.File.WriteAt.
+func (f *file) WriteAt(p []byte, offset uint64) (int, error) {
+	start := time.Now()
+	n, err := f.file.WriteAt(p, offset)
+	f.record("writeat", f.getPath(), start, err)
+	return n, err
+}
+
+// FSync implements p9.File.FSync.
+func (f *file) FSync() error {
+	start := time.Now()
+	err := f.file.FSync()
+	f.record("fsync", f.getPath(), start, err)
+	return err
+}
+
+// Create implements p9.File.Create.
+func (f *file) Create(name string, flags p9.OpenFlags, permissions p9.FileMode, uid p9.UID, gid p9.GID) (*fd.FD, p9.File, p9.QID, uint32, error) {
+	start := time.Now()
+	p := path.Join(f.getPath(), name)
+	hostFD, newFile, qid, ioUnit, err := f.file.Create(name, flags, permissions, uid, gid)
+	f.record("create", p, start, err)
+	return hostFD, f.attacher.wrap(newFile, p), qid, ioUnit, err
+}
+
+// Mkdir implements p9.File.Mkdir.
+func (f *file) Mkdir(name string, permissions p9.FileMode, uid p9.UID, gid p9.GID) (p9.QID, error) {
+	start := time.Now()
+	qid, err := f.file.Mkdir(name, permissions, uid, gid)
+	f.record("mkdir", path.Join(f.getPath(), name), start, err)
+	return qid, err
+}
+
+// Symlink implements p9.File.Symlink.
+func (f *file) Symlink(oldName string, newName string, uid p9.UID, gid p9.GID) (p9.QID, error) {
+	start := time.Now()
+	qid, err := f.file.Symlink(oldName, newName, uid, gid)
+	f.record("symlink", path.Join(f.getPath(), newName), start, err)
+	return qid, err
+}
+
+// Link implements p9.File.Link.
+func (f *file) Link(target p9.File, newName string) error {
+	start := time.Now()
+	err := f.file.Link(unwrap(target), newName)
+	f.record("link", path.Join(f.getPath(), newName), start, err)
+	return err
+}
+
+// Mknod implements p9.File.Mknod.
+func (f *file) Mknod(name string, mode p9.FileMode, major uint32, minor uint32, uid p9.UID, gid p9.GID) (p9.QID, error) {
+	start := time.Now()
+	qid, err := f.file.Mknod(name, mode, major, minor, uid, gid)
+	f.record("mknod", path.Join(f.getPath(), name), start, err)
+	return qid, err
+}
+
+// Rename implements p9.File.Rename.
+func (f *file) Rename(newDir p9.File, newName string) error {
+	start := time.Now()
+	err := f.file.Rename(unwrap(newDir), newName)
+	f.record("rename", f.getPath(), start, err)
+	return err
+}
+
+// RenameAt implements p9.File.RenameAt.
+func (f *file) RenameAt(oldName string, newDir p9.File, newName string) error {
+	start := time.Now()
+	err := f.file.RenameAt(oldName, unwrap(newDir), newName)
+	f.record("renameat", path.Join(f.getPath(), oldName), start, err)
+	return err
+}
+
+// UnlinkAt implements p9.File.UnlinkAt.
+func (f *file) UnlinkAt(name string, flags uint32) error {
+	start := time.Now()
+	err := f.file.UnlinkAt(name, flags)
+	f.record("unlinkat", path.Join(f.getPath(), name), start, err)
+	return err
+}
+
+// Readdir implements p9.File.Readdir.
+func (f *file) Readdir(offset uint64, count uint32) ([]p9.Dirent, error) {
+	start := time.Now()
+	dirents, err := f.file.Readdir(offset, count)
+	f.record("readdir", f.getPath(), start, err)
+	return dirents, err
+}
+
+// Readlink implements p9.File.Readlink.
+func (f *file) Readlink() (string, error) {
+	start := time.Now()
+	target, err := f.file.Readlink()
+	f.record("readlink", f.getPath(), start, err)
+	return target, err
+}
+
+// Flush implements p9.File.Flush.
+func (f *file) Flush() error {
+	start := time.Now()
+	err := f.file.Flush()
+	f.record("flush", f.getPath(), start, err)
+	return err
+}
+
+// Connect implements p9.File.Connect.
+func (f *file) Connect(flags p9.ConnectFlags) (*fd.FD, error) {
+	start := time.Now()
+	hostFD, err := f.file.Connect(flags)
+	f.record("connect", f.getPath(), start, err)
+	return hostFD, err
+}
+
+// Renamed implements p9.File.Renamed.
+func (f *file) Renamed(newDir p9.File, newName string) {
+	p := newName
+	if w, ok := newDir.(*file); ok {
+		p = path.Join(w.getPath(), newName)
+	}
+	f.mu.Lock()
+	f.path = p
+	f.mu.Unlock()
+	f.file.Renamed(unwrap(newDir), newName)
+}
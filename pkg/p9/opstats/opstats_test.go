@@ -0,0 +1,191 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package opstats
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gvisor.dev/gvisor/pkg/p9"
+	"gvisor.dev/gvisor/pkg/sync"
+)
+
+func TestPercentiles(t *testing.T) {
+	r := NewRecorder(0)
+	rec := r.op("read")
+	// 90 fast operations and 10 slow ones.
+	for i := 0; i < 100; i++ {
+		d := 10 * time.Microsecond
+		if i >= 90 {
+			d = 50 * time.Millisecond
+		}
+		rec.record(d, nil)
+	}
+
+	s := r.Stats().Ops["read"]
+	if s.Count != 100 {
+		t.Errorf("Count: got %d, want 100", s.Count)
+	}
+	if s.P50 < 10*time.Microsecond || s.P50 >= 20*time.Microsecond {
+		t.Errorf("P50: got %v, want within [10µs, 20µs)", s.P50)
+	}
+	if s.P90 >= 20*time.Microsecond {
+		t.Errorf("P90: got %v, want below 20µs", s.P90)
+	}
+	if s.P99 != 50*time.Millisecond {
+		t.Errorf("P99: got %v, want 50ms", s.P99)
+	}
+	if s.Max != 50*time.Millisecond {
+		t.Errorf("Max: got %v, want 50ms", s.Max)
+	}
+}
+
+func TestSlowOps(t *testing.T) {
+	r := NewRecorder(time.Millisecond)
+	now := time.Now()
+	r.Record("walk", "/fast", now, nil)
+	for i := 0; i < maxSlowOps+10; i++ {
+		r.Record("readat", "/slow", now.Add(time.Duration(i-maxSlowOps-10)*time.Second), errors.New("EIO"))
+	}
+
+	s := r.Stats()
+	if got := s.Ops["readat"].Errors; got != maxSlowOps+10 {
+		t.Errorf("Errors: got %d, want %d", got, maxSlowOps+10)
+	}
+	if len(s.SlowOps) != maxSlowOps {
+		t.Fatalf("got %d slow operations, want %d", len(s.SlowOps), maxSlowOps)
+	}
+	for i, op := range s.SlowOps {
+		if op.Op != "readat" || op.Path != "/slow" || op.Error != "EIO" {
+			t.Errorf("SlowOps[%d]: got %+v, want readat of /slow failing with EIO", i, op)
+		}
+		if i > 0 && op.Time.Before(s.SlowOps[i-1].Time) {
+			t.Errorf("SlowOps[%d] at %v is before SlowOps[%d] at %v", i, op.Time, i-1, s.SlowOps[i-1].Time)
+		}
+	}
+}
+
+func TestConcurrentRecord(t *testing.T) {
+	const (
+		goroutines = 8
+		ops        = 1000
+	)
+	r := NewRecorder(0)
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < ops; j++ {
+				r.Record("readat", "/file", time.Now(), nil)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := r.Stats().Ops["readat"].Count; got != goroutines*ops {
+		t.Errorf("Count: got %d, want %d", got, goroutines*ops)
+	}
+}
+
+func BenchmarkRecord(b *testing.B) {
+	r := NewRecorder(0)
+	start := time.Now()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			r.Record("readat", "/file", start, nil)
+		}
+	})
+}
+
+// stubAttacher attaches stubFiles.
+type stubAttacher struct {
+	p9.NoServerOptions
+}
+
+// Attach implements p9.Attacher.Attach.
+func (*stubAttacher) Attach() (p9.File, error) {
+	return &stubFile{}, nil
+}
+
+// stubFile implements the few p9.File methods used in tests.
+type stubFile struct {
+	p9.File
+	renamedTo p9.File
+}
+
+// Walk implements p9.File.Walk.
+func (*stubFile) Walk(names []string) ([]p9.QID, p9.File, error) {
+	return nil, &stubFile{}, nil
+}
+
+// Readlink implements p9.File.Readlink.
+func (*stubFile) Readlink() (string, error) {
+	time.Sleep(time.Millisecond)
+	return "target", nil
+}
+
+// Renamed implements p9.File.Renamed.
+func (s *stubFile) Renamed(newDir p9.File, newName string) {
+	s.renamedTo = newDir
+}
+
+func TestAttacher(t *testing.T) {
+	r := NewRecorder(time.Nanosecond)
+	at := NewAttacher(&stubAttacher{}, r, "/mnt")
+
+	root, err := at.Attach()
+	if err != nil {
+		t.Fatalf("Attach failed: %v", err)
+	}
+	_, dir, err := root.Walk([]string{"dir"})
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+	_, child, err := dir.Walk([]string{"child"})
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+	if _, err := child.Readlink(); err != nil {
+		t.Errorf("Readlink failed: %v", err)
+	}
+
+	// Files passed to the wrapped files must be unwrapped, since backends
+	// expect their own type.
+	child.Renamed(root, "renamed")
+	if got, want := child.(*file).file.(*stubFile).renamedTo, root.(*file).file; got != want {
+		t.Errorf("Renamed got %v, want %v", got, want)
+	}
+	if _, err := child.Readlink(); err != nil {
+		t.Errorf("Readlink failed: %v", err)
+	}
+
+	s := r.Stats()
+	for op, want := range map[string]uint64{"attach": 1, "walk": 2, "readlink": 2} {
+		if got := s.Ops[op].Count; got != want {
+			t.Errorf("%s count: got %d, want %d", op, got, want)
+		}
+	}
+	var paths []string
+	for _, op := range s.SlowOps {
+		if op.Op == "readlink" {
+			paths = append(paths, op.Path)
+		}
+	}
+	if len(paths) != 2 || paths[0] != "/mnt/dir/child" || paths[1] != "/mnt/renamed" {
+		t.Errorf("readlink paths: got %v, want [/mnt/dir/child /mnt/renamed]", paths)
+	}
+}
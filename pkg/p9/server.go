@@ -68,11 +68,11 @@ type connState struct {
 	// server is the backing server.
 	server *Server
 
-	// fids is the set of active FIDs.
+	// fids is the set of active FIDs. It may be shared with other
+	// connections, see Server.HandleGroup.
 	//
 	// This is used to find FIDs for files.
-	fidMu sync.Mutex
-	fids  map[FID]*fidRef
+	fids *fidTable
 
 	// tags is the set of active tags.
 	//
@@ -129,6 +129,12 @@ type connState struct {
 	channels []*channel
 }
 
+// fidTable is a set of active FIDs.
+type fidTable struct {
+	mu   sync.Mutex
+	fids map[FID]*fidRef
+}
+
 // fidRef wraps a node and tracks references.
 type fidRef struct {
 	// server is the associated server.
@@ -332,9 +338,9 @@ func (f *fidRef) safelyGlobal(fn func() error) (err error) {
 //
 // You should call fid.DecRef when you are finished using the fid.
 func (cs *connState) LookupFID(fid FID) (*fidRef, bool) {
-	cs.fidMu.Lock()
-	defer cs.fidMu.Unlock()
-	fidRef, ok := cs.fids[fid]
+	cs.fids.mu.Lock()
+	defer cs.fids.mu.Unlock()
+	fidRef, ok := cs.fids.fids[fid]
 	if ok {
 		fidRef.IncRef()
 		return fidRef, true
@@ -347,27 +353,27 @@ func (cs *connState) LookupFID(fid FID) (*fidRef, bool) {
 // This fid starts with a reference count of one. If a FID exists in
 // the slot already it is closed, per the specification.
 func (cs *connState) InsertFID(fid FID, newRef *fidRef) {
-	cs.fidMu.Lock()
-	defer cs.fidMu.Unlock()
-	origRef, ok := cs.fids[fid]
+	cs.fids.mu.Lock()
+	defer cs.fids.mu.Unlock()
+	origRef, ok := cs.fids.fids[fid]
 	if ok {
 		defer origRef.DecRef()
 	}
 	newRef.IncRef()
-	cs.fids[fid] = newRef
+	cs.fids.fids[fid] = newRef
 }
 
 // DeleteFID removes the given FID.
 //
 // This simply removes it from the map and drops a reference.
 func (cs *connState) DeleteFID(fid FID) bool {
-	cs.fidMu.Lock()
-	defer cs.fidMu.Unlock()
-	fidRef, ok := cs.fids[fid]
+	cs.fids.mu.Lock()
+	defer cs.fids.mu.Unlock()
+	fidRef, ok := cs.fids.fids[fid]
 	if !ok {
 		return false
 	}
-	delete(cs.fids, fid)
+	delete(cs.fids.fids, fid)
 	fidRef.DecRef()
 	return true
 }
@@ -639,31 +645,57 @@ func (cs *connState) stop() {
 
 	// Ensure the connection is closed.
 	cs.conn.Close()
+}
 
-	// Close all remaining fids.
-	for fid, fidRef := range cs.fids {
-		delete(cs.fids, fid)
+// closeAll closes all remaining fids.
+//
+// Preconditions: All connections using the table have been stopped.
+func (ft *fidTable) closeAll() {
+	for fid, fidRef := range ft.fids {
+		delete(ft.fids, fid)
 
 		// Drop final reference in the FID table. Note this should
 		// always close the file, since we've ensured that there are no
-		// handlers running via the wait for Pending => 0 below.
+		// handlers running via the wait for Pending => 0 in stop().
 		fidRef.DecRef()
 	}
 }
 
 // Handle handles a single connection.
 func (s *Server) Handle(conn *unet.Socket) error {
-	cs := &connState{
-		server: s,
-		fids:   make(map[FID]*fidRef),
-		tags:   make(map[Tag]chan struct{}),
-		conn:   conn,
-	}
-	defer cs.stop()
+	return s.HandleGroup([]*unet.Socket{conn})
+}
+
+// HandleGroup handles a group of connections from the same client. The
+// connections share FIDs: a FID created by a request on one connection can be
+// used by requests on all others, which allows the client to distribute
+// requests over the connections. Each connection negotiates its version
+// independently.
+//
+// HandleGroup returns when all connections are closed.
+func (s *Server) HandleGroup(conns []*unet.Socket) error {
+	fids := &fidTable{fids: make(map[FID]*fidRef)}
+	defer fids.closeAll()
 
-	// Serve requests from conn in the current goroutine; handleRequests() will
-	// create more goroutines as needed.
-	cs.handleRequests()
+	var wg sync.WaitGroup
+	for _, conn := range conns {
+		cs := &connState{
+			server: s,
+			fids:   fids,
+			tags:   make(map[Tag]chan struct{}),
+			conn:   conn,
+		}
+		wg.Add(1)
+		go func() { // S/R-SAFE: Irrelevant.
+			defer wg.Done()
+			defer cs.stop()
+
+			// Serve requests from conn; handleRequests() will create more
+			// goroutines as needed.
+			cs.handleRequests()
+		}()
+	}
+	wg.Wait()
 
 	return nil
 }
@@ -0,0 +1,174 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rand
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"io"
+	"time"
+
+	"gvisor.dev/gvisor/pkg/sync"
+)
+
+const (
+	// generatorKeySize is the size of the AES-256 key of a generator.
+	generatorKeySize = 32
+
+	// generatorSeedSize is the number of bytes read from the seed source
+	// when a generator is reseeded: a key and an initial counter.
+	generatorSeedSize = generatorKeySize + aes.BlockSize
+
+	// generatorReseedInterval is how often a generator is reseeded, like
+	// Linux's CRNG does.
+	generatorReseedInterval = time.Minute
+
+	// generatorReseedBytes is the number of bytes after which a generator is
+	// reseeded, whatever the time since it was last seeded.
+	generatorReseedBytes = 1 << 30
+
+	// generatorMaxRead is the number of bytes generated at once. The key is
+	// erased between them, such that the output that was already returned
+	// can't be recovered from the state of the generator.
+	generatorMaxRead = 64 << 10
+)
+
+// generator is a cryptographically secure pseudorandom number generator
+// producing AES-256-CTR keystream, with fast key erasure. It's seeded from
+// seed, and reseeded periodically and after generating
+// generatorReseedBytes bytes.
+//
+// It's much cheaper than reading from seed when many small reads are done,
+// since it doesn't make syscalls.
+type generator struct {
+	// seed fills a buffer with seed bytes. It's immutable.
+	seed func([]byte) (int, error)
+
+	// mu protects the fields below.
+	mu sync.Mutex
+
+	// now returns the current time. If it's nil, the generator isn't
+	// reseeded periodically.
+	now func() time.Time
+
+	// stream is the keystream generator. It's nil until the first read.
+	stream cipher.Stream
+
+	// seededAt is the time of the last reseed.
+	seededAt time.Time
+
+	// generated is the number of bytes generated since the last reseed.
+	generated uint64
+}
+
+// Generator is a cryptographically secure pseudorandom number generator
+// seeded from Reader. It's meant for the sentry to serve large amounts of
+// random bytes to applications, e.g. getrandom(2), without a host syscall per
+// read. Since it reads its seed from Reader when it's needed, it follows
+// replacements of Reader, e.g. to record random bytes.
+var Generator io.Reader = defaultGenerator
+
+// defaultGenerator is the generator of Generator.
+var defaultGenerator = newGenerator(Read, time.Now)
+
+// DisableTimedReseed makes Generator reseed only after generating a fixed
+// number of bytes, and not periodically. It then reads its seed from Reader
+// at the same points whenever the same reads are done, which is required to
+// record and replay the reads of Reader.
+func DisableTimedReseed() {
+	defaultGenerator.mu.Lock()
+	defer defaultGenerator.mu.Unlock()
+	defaultGenerator.now = nil
+}
+
+// newGenerator returns a generator seeded with seed. If now is nil, the
+// generator isn't reseeded periodically.
+func newGenerator(seed func([]byte) (int, error), now func() time.Time) *generator {
+	return &generator{seed: seed, now: now}
+}
+
+// reseedDueLocked returns true if g must be reseeded before generating more
+// bytes.
+//
+// Preconditions: g.mu is locked.
+func (g *generator) reseedDueLocked() bool {
+	if g.stream == nil || g.generated >= generatorReseedBytes {
+		return true
+	}
+	return g.now != nil && g.now().Sub(g.seededAt) >= generatorReseedInterval
+}
+
+// reseedLocked rekeys g from its seed source.
+//
+// Preconditions: g.mu is locked.
+func (g *generator) reseedLocked() error {
+	var seed [generatorSeedSize]byte
+	if _, err := g.seed(seed[:]); err != nil {
+		return err
+	}
+	g.rekeyLocked(seed[:])
+	if g.now != nil {
+		g.seededAt = g.now()
+	}
+	g.generated = 0
+	return nil
+}
+
+// rekeyLocked replaces the keystream of g with one using the key and initial
+// counter in seed, which is cleared.
+//
+// Preconditions: g.mu is locked.
+func (g *generator) rekeyLocked(seed []byte) {
+	block, err := aes.NewCipher(seed[:generatorKeySize])
+	if err != nil {
+		// The key size is valid.
+		panic(err)
+	}
+	g.stream = cipher.NewCTR(block, seed[generatorKeySize:generatorSeedSize])
+	for i := range seed {
+		seed[i] = 0
+	}
+}
+
+// Read implements io.Reader.Read. It fills p, unless reseeding fails.
+func (g *generator) Read(p []byte) (int, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	n := 0
+	for n < len(p) {
+		if g.reseedDueLocked() {
+			if err := g.reseedLocked(); err != nil {
+				return n, err
+			}
+		}
+		chunk := p[n:]
+		if len(chunk) > generatorMaxRead {
+			chunk = chunk[:generatorMaxRead]
+		}
+		for i := range chunk {
+			chunk[i] = 0
+		}
+		g.stream.XORKeyStream(chunk, chunk)
+		n += len(chunk)
+		g.generated += uint64(len(chunk))
+
+		// Erase the key that generated chunk.
+		var next [generatorSeedSize]byte
+		g.stream.XORKeyStream(next[:], next[:])
+		g.rekeyLocked(next[:])
+	}
+	return n, nil
+}
@@ -0,0 +1,114 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rand
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+// countingSeed is a seed source returning distinct bytes on each call.
+type countingSeed struct {
+	calls int
+}
+
+func (s *countingSeed) read(p []byte) (int, error) {
+	s.calls++
+	for i := range p {
+		p[i] = byte(s.calls)
+	}
+	return len(p), nil
+}
+
+func TestGeneratorOutput(t *testing.T) {
+	seed := &countingSeed{}
+	now := time.Unix(0, 0)
+	g := newGenerator(seed.read, func() time.Time { return now })
+
+	a := make([]byte, 3*generatorMaxRead+5)
+	if n, err := g.Read(a); n != len(a) || err != nil {
+		t.Fatalf("Read() = %d, %v, want %d, nil", n, err, len(a))
+	}
+	b := make([]byte, len(a))
+	if n, err := g.Read(b); n != len(b) || err != nil {
+		t.Fatalf("Read() = %d, %v, want %d, nil", n, err, len(b))
+	}
+	if bytes.Equal(a, b) {
+		t.Errorf("consecutive reads returned the same bytes")
+	}
+	if bytes.Equal(a[:generatorMaxRead], a[generatorMaxRead:2*generatorMaxRead]) {
+		t.Errorf("consecutive chunks are the same")
+	}
+	if seed.calls != 1 {
+		t.Errorf("generator seeded %d times, want 1", seed.calls)
+	}
+
+	// The same seed gives the same output.
+	other := newGenerator((&countingSeed{}).read, func() time.Time { return now })
+	c := make([]byte, len(a))
+	other.Read(c)
+	if !bytes.Equal(a, c) {
+		t.Errorf("generators with the same seed returned different bytes")
+	}
+}
+
+func TestGeneratorReseed(t *testing.T) {
+	seed := &countingSeed{}
+	now := time.Unix(0, 0)
+	g := newGenerator(seed.read, func() time.Time { return now })
+
+	buf := make([]byte, 16)
+	g.Read(buf)
+	g.Read(buf)
+	if seed.calls != 1 {
+		t.Fatalf("generator seeded %d times, want 1", seed.calls)
+	}
+	now = now.Add(generatorReseedInterval)
+	g.Read(buf)
+	if seed.calls != 2 {
+		t.Errorf("generator seeded %d times after the reseed interval, want 2", seed.calls)
+	}
+}
+
+func TestGeneratorNoTimedReseed(t *testing.T) {
+	seed := &countingSeed{}
+	g := newGenerator(seed.read, nil)
+
+	buf := make([]byte, generatorMaxRead)
+	g.Read(buf)
+	if seed.calls != 1 {
+		t.Fatalf("generator seeded %d times, want 1", seed.calls)
+	}
+	// Only the number of generated bytes triggers a reseed.
+	g.generated = generatorReseedBytes - 1
+	g.Read(buf[:1])
+	if seed.calls != 1 {
+		t.Fatalf("generator seeded %d times before generatorReseedBytes, want 1", seed.calls)
+	}
+	g.Read(buf[:1])
+	if seed.calls != 2 {
+		t.Errorf("generator seeded %d times after generatorReseedBytes, want 2", seed.calls)
+	}
+}
+
+func TestGeneratorSeedError(t *testing.T) {
+	want := errors.New("no entropy")
+	g := newGenerator(func([]byte) (int, error) { return 0, want }, time.Now)
+	if n, err := g.Read(make([]byte, 16)); n != 0 || err != want {
+		t.Errorf("Read() = %d, %v, want 0, %v", n, err, want)
+	}
+}
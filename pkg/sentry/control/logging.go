@@ -15,12 +15,14 @@
 package control
 
 import (
+	"errors"
 	"fmt"
 	"sync/atomic"
 
 	"gvisor.dev/gvisor/pkg/log"
 	"gvisor.dev/gvisor/pkg/sentry/strace"
 	"gvisor.dev/gvisor/pkg/tcpip/link/sniffer"
+	"gvisor.dev/gvisor/pkg/urpc"
 )
 
 // LoggingArgs are the arguments to use for changing the logging
@@ -134,3 +136,28 @@ func (l *Logging) configureEventStrace(args *LoggingArgs) error {
 	}
 	return nil
 }
+
+// StraceFollowArgs are the arguments to Logging.StraceFollow.
+type StraceFollowArgs struct {
+	// PIDs are the thread group IDs, in the root PID namespace of the
+	// sandbox, of the processes to trace. If empty, all processes are
+	// traced.
+	PIDs []int32
+
+	// Syscalls are the names of the syscalls to trace. If empty, all
+	// syscalls are traced.
+	Syscalls []string
+
+	// FilePayload contains the file straces are written to.
+	urpc.FilePayload
+}
+
+// StraceFollow starts writing straces of the selected processes and syscalls
+// to the file passed in, until writing to it fails, e.g. because the client
+// closed it. It doesn't change the straces sent to the log or event log.
+func (l *Logging) StraceFollow(args *StraceFollowArgs, _ *struct{}) error {
+	if len(args.Files) != 1 {
+		return errors.New("strace follow requires exactly one file")
+	}
+	return strace.Follow(args.Files[0], args.PIDs, args.Syscalls)
+}
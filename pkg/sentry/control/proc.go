@@ -34,6 +34,7 @@ import (
 	"gvisor.dev/gvisor/pkg/sentry/kernel/auth"
 	ktime "gvisor.dev/gvisor/pkg/sentry/kernel/time"
 	"gvisor.dev/gvisor/pkg/sentry/limits"
+	"gvisor.dev/gvisor/pkg/sentry/mm"
 	"gvisor.dev/gvisor/pkg/sentry/usage"
 	"gvisor.dev/gvisor/pkg/sentry/vfs"
 	"gvisor.dev/gvisor/pkg/urpc"
@@ -97,11 +98,29 @@ type ExecArgs struct {
 	// ContainerID is the container for the process being executed.
 	ContainerID string
 
+	// ExecID is an optional name for the exec session, which can later be
+	// used to find or signal the process. It must be unique within the
+	// container.
+	ExecID string `json:"execID,omitempty"`
+
+	// TraceParent is the W3C traceparent of the runsc span executing the
+	// process, used as the parent of the span of the sandbox, if any.
+	TraceParent string `json:"traceParent,omitempty"`
+
 	// PIDNamespace is the pid namespace for the process being executed.
 	PIDNamespace *kernel.PIDNamespace
 
+	// UTSNamespace is the UTS namespace for the process being executed. If
+	// UTSNamespace is nil, it will default to the root UTS namespace.
+	UTSNamespace *kernel.UTSNamespace
+
 	// Limits is the limit set for the process being executed.
 	Limits *limits.LimitSet
+
+	// RLimits are resource limits that override the ones in Limits for the
+	// process being executed. Unlike Limits, they are preserved when ExecArgs
+	// is sent to the sandbox.
+	RLimits map[limits.LimitType]limits.Limit `json:"rlimits,omitempty"`
 }
 
 // String prints the arguments as a string.
@@ -154,10 +173,20 @@ func (proc *Proc) execAsync(args *ExecArgs) (*kernel.ThreadGroup, kernel.ThreadI
 	if pidns == nil {
 		pidns = proc.Kernel.RootPIDNamespace()
 	}
+	utsns := args.UTSNamespace
+	if utsns == nil {
+		utsns = proc.Kernel.RootUTSNamespace()
+	}
 	limitSet := args.Limits
 	if limitSet == nil {
 		limitSet = limits.NewLimitSet()
 	}
+	if len(args.RLimits) > 0 {
+		limitSet = limitSet.GetCopy()
+		for lt, l := range args.RLimits {
+			limitSet.SetUnchecked(lt, l)
+		}
+	}
 	initArgs := kernel.CreateProcessArgs{
 		Filename:                args.Filename,
 		Argv:                    args.Argv,
@@ -170,7 +199,7 @@ func (proc *Proc) execAsync(args *ExecArgs) (*kernel.ThreadGroup, kernel.ThreadI
 		Umask:                   0022,
 		Limits:                  limitSet,
 		MaxSymlinkTraversals:    linux.MaxSymlinkTraversals,
-		UTSNamespace:            proc.Kernel.RootUTSNamespace(),
+		UTSNamespace:            utsns,
 		IPCNamespace:            proc.Kernel.RootIPCNamespace(),
 		AbstractSocketNamespace: proc.Kernel.RootAbstractSocketNamespace(),
 		ContainerID:             args.ContainerID,
@@ -428,3 +457,52 @@ func ContainerUsage(kr *kernel.Kernel) map[string]uint64 {
 	}
 	return cusage
 }
+
+// ContainerStat contains resource usage for a single container.
+type ContainerStat struct {
+	// UserTime and SysTime are the CPU time, in nanoseconds, used by the
+	// container's processes, including reaped children.
+	UserTime uint64 `json:"userTime"`
+	SysTime  uint64 `json:"sysTime"`
+
+	// RSS is the resident set size of the container's processes, in bytes.
+	// Memory shared between processes is counted once per address space.
+	RSS uint64 `json:"rss"`
+
+	// Pids is the number of processes in the container.
+	Pids uint64 `json:"pids"`
+}
+
+// ContainerStats retrieves per-container resource usage.
+func ContainerStats(kr *kernel.Kernel) map[string]*ContainerStat {
+	ctx := kr.SupervisorContext()
+	cstats := make(map[string]*ContainerStat)
+	for _, tg := range kr.TaskSet().Root.ThreadGroups() {
+		leader := tg.Leader()
+		cid := leader.ContainerID()
+		cs, ok := cstats[cid]
+		if !ok {
+			cs = &ContainerStat{}
+			cstats[cid] = cs
+		}
+
+		// We want each tg's usage including reaped children.
+		stats := tg.CPUStats()
+		stats.Accumulate(tg.JoinedChildCPUStats())
+		cs.UserTime += uint64(stats.UserTime.Nanoseconds())
+		cs.SysTime += uint64(stats.SysTime.Nanoseconds())
+		cs.Pids++
+
+		var m *mm.MemoryManager
+		leader.WithMuLocked(func(t *kernel.Task) {
+			if m = t.MemoryManager(); m != nil && !m.IncUsers() {
+				m = nil
+			}
+		})
+		if m != nil {
+			cs.RSS += m.ResidentSetSize()
+			m.DecUsers(ctx)
+		}
+	}
+	return cstats
+}
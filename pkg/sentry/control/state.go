@@ -16,12 +16,14 @@ package control
 
 import (
 	"errors"
+	"fmt"
 
 	"gvisor.dev/gvisor/pkg/abi/linux"
 	"gvisor.dev/gvisor/pkg/log"
 	"gvisor.dev/gvisor/pkg/sentry/kernel"
 	"gvisor.dev/gvisor/pkg/sentry/state"
 	"gvisor.dev/gvisor/pkg/sentry/watchdog"
+	"gvisor.dev/gvisor/pkg/state/statefile"
 	"gvisor.dev/gvisor/pkg/urpc"
 )
 
@@ -43,23 +45,38 @@ type SaveOpts struct {
 	// Metadata is the set of metadata to prepend to the state file.
 	Metadata map[string]string `json:"metadata"`
 
-	// FilePayload contains the destination for the state.
+	// Compression is the compression algorithm of the state file, "flate"
+	// if empty.
+	Compression string `json:"compression"`
+
+	// Dedup deduplicates identical memory pages. It can't be used with a
+	// pages file.
+	Dedup bool `json:"dedup"`
+
+	// FilePayload contains the destination for the state, optionally
+	// followed by the destination for memory pages.
 	urpc.FilePayload
 }
 
 // Save saves the running system.
 func (s *State) Save(o *SaveOpts, _ *struct{}) error {
 	// Create an output stream.
-	if len(o.FilePayload.Files) != 1 {
+	if len(o.FilePayload.Files) != 1 && len(o.FilePayload.Files) != 2 {
 		return ErrInvalidFiles
 	}
 	defer o.FilePayload.Files[0].Close()
+	if o.Dedup && len(o.FilePayload.Files) == 2 {
+		return fmt.Errorf("deduplicating pages isn't supported with a pages file")
+	}
 
-	// Save to the first provided stream.
+	// Save to the first provided stream, and memory pages to the second one
+	// if provided.
 	saveOpts := state.SaveOpts{
 		Destination: o.FilePayload.Files[0],
 		Key:         o.Key,
 		Metadata:    o.Metadata,
+		Compression: statefile.Compression(o.Compression),
+		Dedup:       o.Dedup,
 		Callback: func(err error) {
 			if err == nil {
 				log.Infof("Save succeeded: exiting...")
@@ -71,5 +88,9 @@ func (s *State) Save(o *SaveOpts, _ *struct{}) error {
 			s.Kernel.Kill(linux.WaitStatusExit(0))
 		},
 	}
+	if len(o.FilePayload.Files) == 2 {
+		defer o.FilePayload.Files[1].Close()
+		saveOpts.PagesFile = o.FilePayload.Files[1]
+	}
 	return saveOpts.Save(s.Kernel.SupervisorContext(), s.Kernel, s.Watchdog)
 }
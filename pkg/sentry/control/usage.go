@@ -18,9 +18,11 @@ import (
 	"fmt"
 	"os"
 	"runtime"
+	"time"
 
 	"golang.org/x/sys/unix"
 	"gvisor.dev/gvisor/pkg/sentry/kernel"
+	"gvisor.dev/gvisor/pkg/sentry/socket/netstack"
 	"gvisor.dev/gvisor/pkg/sentry/usage"
 	"gvisor.dev/gvisor/pkg/urpc"
 )
@@ -28,8 +30,16 @@ import (
 // Usage includes usage-related RPC stubs.
 type Usage struct {
 	Kernel *kernel.Kernel
+
+	// ApplicationInProcess is set if application code runs in threads of the
+	// sandbox process, e.g. with KVM, rather than in separate host processes,
+	// e.g. with ptrace.
+	ApplicationInProcess bool
 }
 
+// sentryStart is the time the sentry started, approximately.
+var sentryStart = time.Now()
+
 // MemoryUsageOpts contains usage options.
 type MemoryUsageOpts struct {
 	// Full indicates that a full accounting should be done. If Full is not
@@ -123,6 +133,121 @@ func (u *Usage) Collect(opts *MemoryUsageOpts, out *MemoryUsage) error {
 	return nil
 }
 
+// MemoryBreakdown is a breakdown of the memory used by the sandbox, including
+// the memory of the sentry itself.
+type MemoryBreakdown struct {
+	// Application is the memory used by the sandboxed application, with a
+	// full accounting. Anonymous and Mapped hold application mappings,
+	// PageCache and Tmpfs hold file data.
+	Application MemoryUsage `json:"Application"`
+
+	// NetstackBuffers is the amount of data queued in the receive and send
+	// buffers of netstack sockets. This data is held in the Go heap.
+	NetstackBuffers uint64 `json:"NetstackBuffers"`
+
+	// GoHeapInUse is the memory used by live and not yet collected objects of
+	// the sentry Go heap.
+	GoHeapInUse uint64 `json:"GoHeapInUse"`
+
+	// GoHeapIdle is memory of the Go heap that is unused, including memory
+	// not returned to the host yet.
+	GoHeapIdle uint64 `json:"GoHeapIdle"`
+
+	// GoStacks is the memory used by goroutine stacks.
+	GoStacks uint64 `json:"GoStacks"`
+
+	// GoSys is the total memory obtained from the host by the Go runtime.
+	GoSys uint64 `json:"GoSys"`
+}
+
+// Breakdown returns a breakdown of the memory used by the sandbox.
+func (u *Usage) Breakdown(_ *struct{}, out *MemoryBreakdown) error {
+	if err := u.Collect(&MemoryUsageOpts{Full: true}, &out.Application); err != nil {
+		return err
+	}
+	if s, ok := u.Kernel.RootNetworkNamespace().Stack().(*netstack.Stack); ok {
+		out.NetstackBuffers = s.QueuedBytes()
+	}
+
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	out.GoHeapInUse = ms.HeapInuse
+	out.GoHeapIdle = ms.HeapIdle
+	out.GoStacks = ms.StackSys
+	out.GoSys = ms.Sys
+	return nil
+}
+
+// CPUBreakdown is a breakdown of the CPU time used by the sandbox, in
+// nanoseconds, between the application and the sentry.
+type CPUBreakdown struct {
+	// ApplicationUser is the time spent running application code.
+	ApplicationUser uint64 `json:"ApplicationUser"`
+
+	// ApplicationSys is the time spent in the sentry on behalf of
+	// application tasks, e.g. handling their system calls and faults. This
+	// is the equivalent of the system time of Linux processes.
+	ApplicationSys uint64 `json:"ApplicationSys"`
+
+	// SentryOverhead is the CPU time of the sandbox process that isn't spent
+	// on behalf of application tasks, e.g. in netstack, timers, or the Go
+	// garbage collector.
+	SentryOverhead uint64 `json:"SentryOverhead"`
+
+	// GC is an estimate of the time spent by the Go garbage collector since
+	// the sentry started. It's included in SentryOverhead.
+	GC uint64 `json:"GC"`
+
+	// ProcessUser and ProcessSys are the CPU time of the sandbox process, as
+	// accounted by the host. Application code running in separate host
+	// processes isn't included.
+	ProcessUser uint64 `json:"ProcessUser"`
+	ProcessSys  uint64 `json:"ProcessSys"`
+
+	// Gofer is the CPU time of the gofer process serving the filesystem of
+	// the container. It's not known to the sentry, and is set by runsc.
+	Gofer uint64 `json:"Gofer,omitempty"`
+
+	// Containers maps container IDs to the time spent by their tasks,
+	// running application code and in the sentry.
+	Containers map[string]uint64 `json:"Containers"`
+}
+
+// CPUBreakdown returns a breakdown of the CPU time used by the sandbox.
+func (u *Usage) CPUBreakdown(_ *struct{}, out *CPUBreakdown) error {
+	out.Containers = make(map[string]uint64)
+	for _, tg := range u.Kernel.TaskSet().Root.ThreadGroups() {
+		// We want each tg's usage including reaped children.
+		stats := tg.CPUStats()
+		stats.Accumulate(tg.JoinedChildCPUStats())
+		out.ApplicationUser += uint64(stats.UserTime.Nanoseconds())
+		out.ApplicationSys += uint64(stats.SysTime.Nanoseconds())
+		out.Containers[tg.Leader().ContainerID()] += uint64(stats.UserTime.Nanoseconds()) + uint64(stats.SysTime.Nanoseconds())
+	}
+
+	var ru unix.Rusage
+	if err := unix.Getrusage(unix.RUSAGE_SELF, &ru); err != nil {
+		return fmt.Errorf("getrusage: %v", err)
+	}
+	out.ProcessUser = uint64(ru.Utime.Nano())
+	out.ProcessSys = uint64(ru.Stime.Nano())
+	app := out.ApplicationSys
+	if u.ApplicationInProcess {
+		app += out.ApplicationUser
+	}
+	if process := out.ProcessUser + out.ProcessSys; process > app {
+		out.SentryOverhead = process - app
+	}
+
+	// GCCPUFraction is relative to the CPU time available to the sentry
+	// since it started.
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	available := time.Since(sentryStart) * time.Duration(runtime.GOMAXPROCS(0))
+	out.GC = uint64(ms.GCCPUFraction * float64(available.Nanoseconds()))
+	return nil
+}
+
 // UsageReduceOpts contains options to Usage.Reduce().
 type UsageReduceOpts struct {
 	// If Wait is true, Reduce blocks until all activity initiated by
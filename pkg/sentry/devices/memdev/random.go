@@ -15,6 +15,7 @@
 package memdev
 
 import (
+	"io"
 	"sync/atomic"
 
 	"gvisor.dev/gvisor/pkg/context"
@@ -29,6 +30,25 @@ const (
 	urandomDevMinor = 9
 )
 
+// entropySource, if not nil, is read by /dev/random and /dev/urandom instead
+// of rand.Generator. See SetEntropySource.
+var entropySource io.Reader
+
+// SetEntropySource makes /dev/random and /dev/urandom read from r, e.g. a
+// host entropy device, instead of the sentry's random number generator. It
+// must be called before the devices are read.
+func SetEntropySource(r io.Reader) {
+	entropySource = r
+}
+
+// entropyReader returns the reader of /dev/random and /dev/urandom.
+func entropyReader() io.Reader {
+	if entropySource != nil {
+		return entropySource
+	}
+	return rand.Generator
+}
+
 // randomDevice implements vfs.Device for /dev/random and /dev/urandom.
 //
 // +stateify savable
@@ -66,12 +86,12 @@ func (fd *randomFD) Release(context.Context) {
 
 // PRead implements vfs.FileDescriptionImpl.PRead.
 func (fd *randomFD) PRead(ctx context.Context, dst usermem.IOSequence, offset int64, opts vfs.ReadOptions) (int64, error) {
-	return dst.CopyOutFrom(ctx, safemem.FromIOReader{rand.Reader})
+	return dst.CopyOutFrom(ctx, safemem.FromIOReader{entropyReader()})
 }
 
 // Read implements vfs.FileDescriptionImpl.Read.
 func (fd *randomFD) Read(ctx context.Context, dst usermem.IOSequence, opts vfs.ReadOptions) (int64, error) {
-	n, err := dst.CopyOutFrom(ctx, safemem.FromIOReader{rand.Reader})
+	n, err := dst.CopyOutFrom(ctx, safemem.FromIOReader{entropyReader()})
 	atomic.AddInt64(&fd.off, n)
 	return n, err
 }
@@ -48,14 +48,24 @@ type TTYFileOperations struct {
 
 	// termios contains the terminal attributes for this TTY.
 	termios linux.KernelTermios
+
+	// winsize is the last window size seen by the sandbox, used to decide if
+	// SetWinsize changes it. The host TTY can't be used for this, since it may
+	// already have been resized from outside of the sandbox. It's zero if
+	// unknown.
+	winsize linux.Winsize `state:"nosave"`
 }
 
 // newTTYFile returns a new fs.File that wraps a TTY FD.
 func newTTYFile(ctx context.Context, dirent *fs.Dirent, flags fs.FileFlags, iops *inodeOperations) *fs.File {
-	return fs.NewFile(ctx, dirent, flags, &TTYFileOperations{
+	t := &TTYFileOperations{
 		fileOperations: fileOperations{iops: iops},
 		termios:        linux.DefaultReplicaTermios,
-	})
+	}
+	if ws, err := ioctlGetWinsize(iops.fileState.FD()); err == nil {
+		t.winsize = *ws
+	}
+	return fs.NewFile(ctx, dirent, flags, t)
 }
 
 // InitForegroundProcessGroup sets the foreground process group and session for
@@ -78,6 +88,23 @@ func (t *TTYFileOperations) ForegroundProcessGroup() *kernel.ProcessGroup {
 	return t.fgProcessGroup
 }
 
+// SetWinsize sets the window size of the TTY. If the size changes, SIGWINCH
+// is sent to the foreground process group, as in Linux's tty_do_resize().
+func (t *TTYFileOperations) SetWinsize(winsize *linux.Winsize) error {
+	if err := ioctlSetWinsize(t.fileOperations.iops.fileState.FD(), winsize); err != nil {
+		return err
+	}
+	t.mu.Lock()
+	changed := t.winsize != *winsize
+	t.winsize = *winsize
+	pg := t.fgProcessGroup
+	t.mu.Unlock()
+	if changed && pg != nil {
+		_ = pg.SendSignal(kernel.SignalInfoPriv(linux.SIGWINCH))
+	}
+	return nil
+}
+
 // Read implements fs.FileOperations.Read.
 //
 // Reading from a TTY is only allowed for foreground process groups. Background
@@ -250,8 +277,7 @@ func (t *TTYFileOperations) Ioctl(ctx context.Context, _ *fs.File, io usermem.IO
 		if _, err := winsize.CopyIn(task, args[2].Pointer()); err != nil {
 			return 0, err
 		}
-		err := ioctlSetWinsize(fd, &winsize)
-		return 0, err
+		return 0, t.SetWinsize(&winsize)
 
 	// Unimplemented commands.
 	case linux.TIOCSETD,
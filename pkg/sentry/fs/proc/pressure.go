@@ -0,0 +1,87 @@
+// Copyright 2022 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proc
+
+import (
+	"bytes"
+	"fmt"
+
+	"gvisor.dev/gvisor/pkg/context"
+	"gvisor.dev/gvisor/pkg/sentry/fs"
+	"gvisor.dev/gvisor/pkg/sentry/fs/proc/seqfile"
+	"gvisor.dev/gvisor/pkg/sentry/fs/ramfs"
+	"gvisor.dev/gvisor/pkg/sentry/kernel"
+)
+
+// LINT.IfChange
+
+// newPressureDir returns the inode of the /proc/pressure directory.
+func (p *proc) newPressureDir(ctx context.Context, msrc *fs.MountSource) *fs.Inode {
+	children := make(map[string]*fs.Inode)
+	for r := kernel.PressureResource(0); r < kernel.NumPressureResources; r++ {
+		children[r.String()] = seqfile.NewSeqFileInode(ctx, &pressureData{k: p.k, resource: r}, msrc)
+	}
+
+	d := ramfs.NewDir(ctx, children, fs.RootOwner, fs.FilePermsFromMode(0555))
+	return newProcInode(ctx, d, msrc, fs.SpecialDirectory, nil)
+}
+
+// pressureData backs the files of /proc/pressure, which contain pressure stall
+// information on a resource for the container of the reading task.
+//
+// +stateify savable
+type pressureData struct {
+	k        *kernel.Kernel
+	resource kernel.PressureResource
+}
+
+// NeedsUpdate implements seqfile.SeqSource.NeedsUpdate.
+func (*pressureData) NeedsUpdate(generation int64) bool {
+	return true
+}
+
+// ReadSeqFileData implements seqfile.SeqSource.ReadSeqFileData.
+func (d *pressureData) ReadSeqFileData(ctx context.Context, h seqfile.SeqHandle) ([]seqfile.SeqData, int64) {
+	if h != nil {
+		return nil, 0
+	}
+
+	var cid string
+	if t := kernel.TaskFromContext(ctx); t != nil {
+		cid = t.ContainerID()
+	}
+	ps, _ := d.k.ContainerPressure(cid)
+	rp := ps[d.resource]
+
+	var buf bytes.Buffer
+	for _, l := range []struct {
+		name string
+		line *kernel.PressureLine
+	}{
+		{"some", &rp.Some},
+		{"full", &rp.Full},
+	} {
+		fmt.Fprintf(&buf, "%s avg10=%.2f avg60=%.2f avg300=%.2f total=%d\n", l.name, l.line.Avg10, l.line.Avg60, l.line.Avg300, l.line.Total.Microseconds())
+	}
+
+	return []seqfile.SeqData{
+		{
+			Buf:    buf.Bytes(),
+			Handle: (*pressureData)(nil),
+		},
+	}, 0
+}
+
+// LINT.ThenChange(../../fsimpl/proc/tasks_files.go)
@@ -88,6 +88,7 @@ func New(ctx context.Context, msrc *fs.MountSource, cgroupControllers map[string
 
 	// Add more contents that need proc to be initialized.
 	p.AddChild(ctx, "sys", p.newSysDir(ctx, msrc))
+	p.AddChild(ctx, "pressure", p.newPressureDir(ctx, msrc))
 
 	return newProcInode(ctx, p, msrc, fs.SpecialDirectory, nil), nil
 }
@@ -98,7 +98,7 @@ var allControllers = []kernel.CgroupControllerType{
 }
 
 // SupportedMountOptions is the set of supported mount options for cgroupfs.
-var SupportedMountOptions = []string{"all", "cpu", "cpuacct", "cpuset", "job", "memory"}
+var SupportedMountOptions = []string{"all", "cpu", "cpuacct", "cpuset", "job", "memory", "name", "none"}
 
 // FilesystemType implements vfs.FilesystemType.
 //
@@ -211,10 +211,38 @@ func (fsType FilesystemType) GetFilesystem(ctx context.Context, vfsObj *vfs.Virt
 		wantControllers = allControllers
 	}
 
-	if len(wantControllers) == 0 {
+	// "none" explicitly requests no controllers, which requires a named
+	// hierarchy.
+	_, none := mopts["none"]
+	delete(mopts, "none")
+	if none && len(wantControllers) > 0 {
+		ctx.Debugf("cgroupfs.FilesystemType.GetFilesystem: controllers specified with none: %v", wantControllers)
+		return nil, nil, linuxerr.EINVAL
+	}
+
+	// A named hierarchy, e.g. "name=systemd", is represented by a pseudo
+	// controller without control files. It's used by init systems to track
+	// processes.
+	var name kernel.CgroupControllerType
+	if n, ok := mopts["name"]; ok {
+		delete(mopts, "name")
+		if !validHierarchyName(n) {
+			ctx.Debugf("cgroupfs.FilesystemType.GetFilesystem: invalid hierarchy name: %q", n)
+			return nil, nil, linuxerr.EINVAL
+		}
+		name = namedHierarchyType(n)
+	} else if none {
+		ctx.Debugf("cgroupfs.FilesystemType.GetFilesystem: none requires a hierarchy name")
+		return nil, nil, linuxerr.EINVAL
+	}
+
+	if len(wantControllers) == 0 && name == "" {
 		// Specifying no controllers implies all controllers.
 		wantControllers = allControllers
 	}
+	if name != "" {
+		wantControllers = append(wantControllers, name)
+	}
 
 	if len(mopts) != 0 {
 		ctx.Debugf("cgroupfs.FilesystemType.GetFilesystem: unknown options: %v", mopts)
@@ -274,6 +302,8 @@ func (fsType FilesystemType) GetFilesystem(ctx context.Context, vfsObj *vfs.Virt
 			c = newJobController(fs)
 		case controllerMemory:
 			c = newMemoryController(fs, defaults)
+		case name:
+			c = newNamedController(ty, fs)
 		default:
 			panic(fmt.Sprintf("Unreachable: unknown cgroup controller %q", ty))
 		}
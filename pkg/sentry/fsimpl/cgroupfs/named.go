@@ -0,0 +1,69 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cgroupfs
+
+import (
+	"gvisor.dev/gvisor/pkg/context"
+	"gvisor.dev/gvisor/pkg/sentry/fsimpl/kernfs"
+	"gvisor.dev/gvisor/pkg/sentry/kernel"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/auth"
+)
+
+// maxHierarchyNameLen is the maximum length of a hierarchy name, from
+// MAX_CGROUP_ROOT_NAMELEN in Linux.
+const maxHierarchyNameLen = 64
+
+// namedHierarchyType returns the controller type that represents the named
+// hierarchy. It matches how the hierarchy is displayed in /proc/[pid]/cgroup
+// and in mount options.
+func namedHierarchyType(name string) kernel.CgroupControllerType {
+	return kernel.CgroupControllerType(kernel.NamedCgroupHierarchyPrefix + name)
+}
+
+// validHierarchyName returns whether name is a valid hierarchy name. Like
+// Linux, names may only contain alphanumeric characters, '_', '-' and '.'.
+func validHierarchyName(name string) bool {
+	if len(name) == 0 || len(name) >= maxHierarchyNameLen {
+		return false
+	}
+	for _, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '_', c == '-', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// namedController is the pseudo controller of a named hierarchy. It has no
+// control files, and only tracks task membership.
+//
+// +stateify savable
+type namedController struct {
+	controllerCommon
+}
+
+var _ controller = (*namedController)(nil)
+
+func newNamedController(ty kernel.CgroupControllerType, fs *filesystem) *namedController {
+	c := &namedController{}
+	c.controllerCommon.init(ty, fs)
+	return c
+}
+
+// AddControlFiles implements controller.AddControlFiles.
+func (*namedController) AddControlFiles(context.Context, *auth.Credentials, *cgroupInode, map[string]kernfs.Inode) {
+}
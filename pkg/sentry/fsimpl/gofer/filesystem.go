@@ -17,6 +17,7 @@ package gofer
 import (
 	"fmt"
 	"math"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -26,6 +27,7 @@ import (
 	"gvisor.dev/gvisor/pkg/context"
 	"gvisor.dev/gvisor/pkg/errors/linuxerr"
 	"gvisor.dev/gvisor/pkg/fspath"
+	"gvisor.dev/gvisor/pkg/hostarch"
 	"gvisor.dev/gvisor/pkg/lisafs"
 	"gvisor.dev/gvisor/pkg/p9"
 	"gvisor.dev/gvisor/pkg/sentry/fsimpl/host"
@@ -1859,6 +1861,103 @@ func (fs *filesystem) PrependPath(ctx context.Context, vfsroot, vd vfs.VirtualDe
 	return genericPrependPath(vfsroot, vd.Mount(), vd.Dentry().Impl().(*dentry), b)
 }
 
+// maxFileHandles is the maximum number of files whose dentry is kept for
+// file handles, see filesystem.fileHandles. Handles of older files become
+// stale beyond it.
+const maxFileHandles = 1024
+
+// FileHandleID implements vfs.FileHandleFilesystemImpl.FileHandleID. File
+// handles are backed by the identifiers of files provided by the gofer: the
+// QID path with 9P, or the device and inode numbers of the host file with
+// lisafs. The dentry of the file is kept to be found by FileHandleDentry.
+func (fs *filesystem) FileHandleID(ctx context.Context, vd *vfs.Dentry) ([vfs.FileHandleIDSize]byte, error) {
+	var id [vfs.FileHandleIDSize]byte
+	d := vd.Impl().(*dentry)
+	if d.isSynthetic() {
+		return id, linuxerr.EOPNOTSUPP
+	}
+	if fs.opts.lisaEnabled {
+		hostarch.ByteOrder.PutUint64(id[0:], d.inoKey.ino)
+		hostarch.ByteOrder.PutUint32(id[8:], d.inoKey.devMajor)
+		hostarch.ByteOrder.PutUint32(id[12:], d.inoKey.devMinor)
+	} else {
+		hostarch.ByteOrder.PutUint64(id[0:], d.qidPath)
+	}
+
+	// Dentries are released after unlocking fs.fileHandlesMu, since
+	// releasing them may lock fs.renameMu.
+	var released []*dentry
+	fs.fileHandlesMu.Lock()
+	if old, ok := fs.fileHandles[id]; !ok || old != d {
+		if fs.fileHandles == nil {
+			fs.fileHandles = make(map[[vfs.FileHandleIDSize]byte]*dentry)
+		}
+		if ok {
+			released = append(released, old)
+		} else {
+			fs.fileHandleOrder = append(fs.fileHandleOrder, id)
+		}
+		d.IncRef()
+		fs.fileHandles[id] = d
+		for len(fs.fileHandleOrder) > maxFileHandles {
+			oldest := fs.fileHandleOrder[0]
+			fs.fileHandleOrder = fs.fileHandleOrder[1:]
+			released = append(released, fs.fileHandles[oldest])
+			delete(fs.fileHandles, oldest)
+		}
+	}
+	fs.fileHandlesMu.Unlock()
+	for _, d := range released {
+		d.DecRef(ctx)
+	}
+	return id, nil
+}
+
+// FileHandleDentry implements vfs.FileHandleFilesystemImpl.FileHandleDentry.
+func (fs *filesystem) FileHandleDentry(ctx context.Context, id [vfs.FileHandleIDSize]byte) (*vfs.Dentry, error) {
+	fs.fileHandlesMu.Lock()
+	d, ok := fs.fileHandles[id]
+	if ok {
+		d.IncRef()
+	}
+	fs.fileHandlesMu.Unlock()
+	if !ok {
+		return nil, linuxerr.ESTALE
+	}
+	if d.isDeleted() || d.vfsd.IsDead() {
+		// The file was deleted, or invalidated by revalidation, so the
+		// handle won't be valid again.
+		fs.fileHandlesMu.Lock()
+		if fs.fileHandles[id] == d {
+			delete(fs.fileHandles, id)
+			for i, other := range fs.fileHandleOrder {
+				if other == id {
+					fs.fileHandleOrder = append(fs.fileHandleOrder[:i], fs.fileHandleOrder[i+1:]...)
+					break
+				}
+			}
+			// Drop the reference of fs.fileHandles.
+			d.decRefNoCaching()
+		}
+		fs.fileHandlesMu.Unlock()
+		d.DecRef(ctx)
+		return nil, linuxerr.ESTALE
+	}
+	return &d.vfsd, nil
+}
+
+// releaseFileHandles releases the dentries kept for file handles.
+func (fs *filesystem) releaseFileHandles(ctx context.Context) {
+	fs.fileHandlesMu.Lock()
+	handles := fs.fileHandles
+	fs.fileHandles = nil
+	fs.fileHandleOrder = nil
+	fs.fileHandlesMu.Unlock()
+	for _, d := range handles {
+		d.DecRef(ctx)
+	}
+}
+
 type mopt struct {
 	key   string
 	value interface{}
@@ -1909,6 +2008,16 @@ func (fs *filesystem) MountOptions() string {
 	if fs.opts.lisaEnabled {
 		optsKV = append(optsKV, mopt{moptLisafs, nil})
 	}
+	if fs.opts.overlayXattrs {
+		optsKV = append(optsKV, mopt{moptOverlayXattrs, nil})
+	}
+	if len(fs.opts.extraFDs) != 0 {
+		fds := make([]string, 0, len(fs.opts.extraFDs))
+		for _, fd := range fs.opts.extraFDs {
+			fds = append(fds, strconv.Itoa(fd))
+		}
+		optsKV = append(optsKV, mopt{moptExtraFDs, strings.Join(fds, ":")})
+	}
 
 	opts := make([]string, 0, len(optsKV))
 	for _, opt := range optsKV {
@@ -42,6 +42,7 @@ import (
 	"strconv"
 	"strings"
 	"sync/atomic"
+	"time"
 
 	"golang.org/x/sys/unix"
 	"gvisor.dev/gvisor/pkg/abi/linux"
@@ -74,6 +75,7 @@ const (
 	moptTransport              = "trans"
 	moptReadFD                 = "rfdno"
 	moptWriteFD                = "wfdno"
+	moptExtraFDs               = "extra_fds"
 	moptAname                  = "aname"
 	moptDfltUID                = "dfltuid"
 	moptDfltGID                = "dfltgid"
@@ -85,8 +87,15 @@ const (
 	moptLimitHostFDTranslation = "limit_host_fd_translation"
 	moptOverlayfsStaleRead     = "overlayfs_stale_read"
 	moptLisafs                 = "lisafs"
+	moptWatchPollInterval      = "watch_poll_interval"
+	moptRevalidateInterval     = "revalidate_interval"
+	moptOverlayXattrs          = "overlay_xattrs"
 )
 
+// overlayXattrPrefix is the prefix of the extended attributes used by overlay
+// filesystems, see filesystemOptions.overlayXattrs.
+const overlayXattrPrefix = linux.XATTR_TRUSTED_PREFIX + "overlay."
+
 // Valid values for the "cache" mount option.
 const (
 	cacheNone                = "none"
@@ -183,12 +192,34 @@ type filesystem struct {
 	// released is nonzero once filesystem.Release has been called. It is accessed
 	// with atomic memory operations.
 	released int32
+
+	// pollStop is closed to stop the goroutine polling watched dentries, which
+	// closes pollDone when it exits. Both are nil if polling is disabled.
+	pollStop chan struct{} `state:"nosave"`
+	pollDone chan struct{} `state:"nosave"`
+
+	// fileHandles maps the identifiers of the file handles returned by
+	// name_to_handle_at(2) to the dentry of their file, on which a reference
+	// is held so that handles are valid for as long as the file exists,
+	// even if it's renamed. fileHandleOrder contains the identifiers in the
+	// order they were added to fileHandles, from which the oldest are
+	// removed beyond maxFileHandles. These fields are protected by
+	// fileHandlesMu.
+	fileHandlesMu   sync.Mutex `state:"nosave"`
+	fileHandles     map[[vfs.FileHandleIDSize]byte]*dentry
+	fileHandleOrder [][vfs.FileHandleIDSize]byte
 }
 
 // +stateify savable
 type filesystemOptions struct {
 	// "Standard" 9P options.
 	fd      int
+
+	// extraFDs are FDs of additional connections to the server, given as a
+	// colon-separated list by the "extra_fds" mount option. Requests are
+	// distributed over fd and extraFDs.
+	extraFDs []int
+
 	aname   string
 	interop InteropMode // derived from the "cache" mount option
 	dfltuid auth.KUID
@@ -230,6 +261,23 @@ type filesystemOptions struct {
 	// lisaEnabled indicates whether the client will use lisafs protocol to
 	// communicate with the server instead of 9P.
 	lisaEnabled bool
+
+	// If watchPollInterval is non-zero, dentries watched by inotify are
+	// revalidated at this interval, so that changes made by other users of
+	// the remote filesystem generate inotify events. It is only effective
+	// with InteropModeShared.
+	watchPollInterval time.Duration
+
+	// If revalidateInterval is non-zero, dentries revalidated within this
+	// interval are assumed not to have changed, so that repeated accesses to
+	// the same files don't each require a round trip to the server. It is
+	// only effective with InteropModeShared.
+	revalidateInterval time.Duration
+
+	// If overlayXattrs is true, the trusted.overlay.* extended attributes
+	// used by overlay filesystems are passed through to the remote
+	// filesystem, which backs the upper layer of an overlay.
+	overlayXattrs bool
 }
 
 // InteropMode controls the client's interaction with other remote filesystem
@@ -443,6 +491,10 @@ func (fstype FilesystemType) GetFilesystem(ctx context.Context, vfsObj *vfs.Virt
 		delete(mopts, moptOverlayfsStaleRead)
 		fsopts.overlayfsStaleRead = true
 	}
+	if _, ok := mopts[moptOverlayXattrs]; ok {
+		delete(mopts, moptOverlayXattrs)
+		fsopts.overlayXattrs = true
+	}
 	if lisafs, ok := mopts[moptLisafs]; ok {
 		delete(mopts, moptLisafs)
 		fsopts.lisaEnabled, err = strconv.ParseBool(lisafs)
@@ -451,6 +503,39 @@ func (fstype FilesystemType) GetFilesystem(ctx context.Context, vfsObj *vfs.Virt
 			return nil, nil, linuxerr.EINVAL
 		}
 	}
+	if str, ok := mopts[moptExtraFDs]; ok {
+		delete(mopts, moptExtraFDs)
+		for _, fdstr := range strings.Split(str, ":") {
+			fd, err := strconv.Atoi(fdstr)
+			if err != nil {
+				ctx.Warningf("gofer.FilesystemType.GetFilesystem: invalid extra FD: %s=%s", moptExtraFDs, str)
+				return nil, nil, linuxerr.EINVAL
+			}
+			fsopts.extraFDs = append(fsopts.extraFDs, fd)
+		}
+		if fsopts.lisaEnabled {
+			ctx.Warningf("gofer.FilesystemType.GetFilesystem: %s is not supported with lisafs", moptExtraFDs)
+			return nil, nil, linuxerr.EINVAL
+		}
+	}
+	if str, ok := mopts[moptWatchPollInterval]; ok {
+		delete(mopts, moptWatchPollInterval)
+		interval, err := time.ParseDuration(str)
+		if err != nil || interval < 0 {
+			ctx.Warningf("gofer.FilesystemType.GetFilesystem: invalid watch poll interval: %s=%s", moptWatchPollInterval, str)
+			return nil, nil, linuxerr.EINVAL
+		}
+		fsopts.watchPollInterval = interval
+	}
+	if str, ok := mopts[moptRevalidateInterval]; ok {
+		delete(mopts, moptRevalidateInterval)
+		interval, err := time.ParseDuration(str)
+		if err != nil || interval < 0 {
+			ctx.Warningf("gofer.FilesystemType.GetFilesystem: invalid revalidate interval: %s=%s", moptRevalidateInterval, str)
+			return nil, nil, linuxerr.EINVAL
+		}
+		fsopts.revalidateInterval = interval
+	}
 	// fsopts.regularFilesUseSpecialFileFD can only be enabled by specifying
 	// "cache=none".
 
@@ -490,6 +575,7 @@ func (fstype FilesystemType) GetFilesystem(ctx context.Context, vfsObj *vfs.Virt
 		fs.vfsfs.DecRef(ctx)
 		return nil, nil, err
 	}
+	fs.startPollingWatches()
 
 	return &fs.vfsfs, &fs.root.vfsd, nil
 }
@@ -602,21 +688,30 @@ func getFDFromMountOptionsMap(ctx context.Context, mopts map[string]string) (int
 
 // Preconditions: fs.client == nil.
 func (fs *filesystem) dial(ctx context.Context) error {
-	// Establish a connection with the server.
-	conn, err := unet.NewSocket(fs.opts.fd)
-	if err != nil {
-		return err
+	// Establish connections with the server.
+	conns := make([]*unet.Socket, 0, 1+len(fs.opts.extraFDs))
+	for _, fd := range append([]int{fs.opts.fd}, fs.opts.extraFDs...) {
+		conn, err := unet.NewSocket(fd)
+		if err != nil {
+			for _, conn := range conns {
+				conn.Close()
+			}
+			return err
+		}
+		conns = append(conns, conn)
 	}
 
 	// Perform version negotiation with the server.
 	ctx.UninterruptibleSleepStart(false)
-	client, err := p9.NewClient(conn, fs.opts.msize, fs.opts.version)
+	client, err := p9.NewMultiClient(conns, fs.opts.msize, fs.opts.version)
 	ctx.UninterruptibleSleepFinish(false)
 	if err != nil {
-		conn.Close()
+		for _, conn := range conns {
+			conn.Close()
+		}
 		return err
 	}
-	// Ownership of conn has been transferred to client.
+	// Ownership of conns has been transferred to client.
 
 	fs.client = client
 	return nil
@@ -625,6 +720,7 @@ func (fs *filesystem) dial(ctx context.Context) error {
 // Release implements vfs.FilesystemImpl.Release.
 func (fs *filesystem) Release(ctx context.Context) {
 	atomic.StoreInt32(&fs.released, 1)
+	fs.stopPollingWatches()
 
 	mf := fs.mfp.MemoryFile()
 	fs.syncMu.Lock()
@@ -664,6 +760,7 @@ func (fs *filesystem) Release(ctx context.Context) {
 	// have released all external resources above rather than relying on dentry
 	// destructors.
 	if refs_vfs1.GetLeakMode() != refs_vfs1.NoLeakChecking {
+		fs.releaseFileHandles(ctx)
 		fs.renameMu.Lock()
 		fs.root.releaseSyntheticRecursiveLocked(ctx)
 		fs.evictAllCachedDentriesLocked(ctx)
@@ -853,6 +950,11 @@ type dentry struct {
 	// other metadata fields.
 	nlink uint32
 
+	// revalidated is the monotonic time, as returned by monotonicNow, at
+	// which the dentry's metadata was last fetched from the server. It is
+	// accessed using atomic operations.
+	revalidated int64 `state:"nosave"`
+
 	mapsMu sync.Mutex `state:"nosave"`
 
 	// If this dentry represents a regular file, mappings tracks mappings of
@@ -1009,6 +1111,7 @@ func (fs *filesystem) newDentry(ctx context.Context, file p9file, qid p9.QID, ma
 	if mask.NLink {
 		d.nlink = uint32(attr.NLink)
 	}
+	d.revalidated = monotonicNow()
 	d.vfsd.Init(d)
 	refsvfs2.Register(d)
 	fs.syncMu.Lock()
@@ -1070,6 +1173,7 @@ func (fs *filesystem) newDentryLisa(ctx context.Context, ino *lisafs.Inode) (*de
 	if ino.Stat.Mask&linux.STATX_NLINK != 0 {
 		d.nlink = ino.Stat.Nlink
 	}
+	d.revalidated = monotonicNow()
 	d.vfsd.Init(d)
 	refsvfs2.Register(d)
 	fs.syncMu.Lock()
@@ -1597,8 +1701,12 @@ func (d *dentry) checkXattrPermissions(creds *auth.Credentials, name string, ats
 	// but consistent with other filesystems (e.g. FUSE).
 	//
 	// NOTE(b/202533394): Also disallow "trusted" namespace for now. This is
-	// consistent with the VFS1 gofer client.
-	if strings.HasPrefix(name, linux.XATTR_SECURITY_PREFIX) || strings.HasPrefix(name, linux.XATTR_SYSTEM_PREFIX) || strings.HasPrefix(name, linux.XATTR_TRUSTED_PREFIX) {
+	// consistent with the VFS1 gofer client. Overlay attributes are allowed
+	// if the filesystem backs the upper layer of an overlay.
+	if strings.HasPrefix(name, linux.XATTR_SECURITY_PREFIX) || strings.HasPrefix(name, linux.XATTR_SYSTEM_PREFIX) {
+		return linuxerr.EOPNOTSUPP
+	}
+	if strings.HasPrefix(name, linux.XATTR_TRUSTED_PREFIX) && !(d.fs.opts.overlayXattrs && strings.HasPrefix(name, overlayXattrPrefix)) {
 		return linuxerr.EOPNOTSUPP
 	}
 	mode := linux.FileMode(atomic.LoadUint32(&d.mode))
@@ -17,6 +17,7 @@ package gofer
 import (
 	"sync/atomic"
 	"testing"
+	"time"
 
 	"gvisor.dev/gvisor/pkg/p9"
 	"gvisor.dev/gvisor/pkg/sentry/contexttest"
@@ -67,3 +68,69 @@ func TestDestroyIdempotent(t *testing.T) {
 	child.checkCachingLocked(ctx, true /* renameMuWriteLocked */)
 	child.checkCachingLocked(ctx, true /* renameMuWriteLocked */)
 }
+
+func TestPollingWatches(t *testing.T) {
+	ctx := contexttest.Context(t)
+	for _, tc := range []struct {
+		name     string
+		interop  InteropMode
+		interval time.Duration
+		want     bool
+	}{
+		{
+			name:     "disabled",
+			interop:  InteropModeShared,
+			interval: 0,
+			want:     false,
+		},
+		{
+			name:     "exclusive",
+			interop:  InteropModeExclusive,
+			interval: time.Millisecond,
+			want:     false,
+		},
+		{
+			name:     "shared",
+			interop:  InteropModeShared,
+			interval: time.Millisecond,
+			want:     true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fs := filesystem{
+				mfp: pgalloc.MemoryFileProviderFromContext(ctx),
+				opts: filesystemOptions{
+					interop:           tc.interop,
+					watchPollInterval: tc.interval,
+				},
+				syncableDentries: make(map[*dentry]struct{}),
+				inoByQIDPath:     make(map[uint64]uint64),
+				inoByKey:         make(map[inoKey]uint64),
+			}
+			root, err := fs.newDentry(ctx, p9file{}, p9.QID{}, p9.AttrMask{Mode: true}, &p9.Attr{Mode: p9.ModeDirectory})
+			if err != nil {
+				t.Fatalf("fs.newDentry(): %v", err)
+			}
+			fs.root = root
+
+			fs.startPollingWatches()
+			if got := fs.pollStop != nil; got != tc.want {
+				t.Fatalf("polling after start: got %t, want %t", got, tc.want)
+			}
+			// Let the filesystem be polled, which finds nothing to revalidate.
+			time.Sleep(10 * tc.interval)
+
+			// Polling stops for saves, and is restarted when the sandbox keeps
+			// running.
+			fs.stopPollingWatches()
+			if fs.pollStop != nil {
+				t.Fatalf("polling after stop")
+			}
+			fs.ResumeAfterSave(ctx)
+			if got := fs.pollStop != nil; got != tc.want {
+				t.Errorf("polling after ResumeAfterSave: got %t, want %t", got, tc.want)
+			}
+			fs.stopPollingWatches()
+		})
+	}
+}
@@ -15,6 +15,8 @@
 package gofer
 
 import (
+	"sync/atomic"
+
 	"gvisor.dev/gvisor/pkg/abi/linux"
 	"gvisor.dev/gvisor/pkg/context"
 	"gvisor.dev/gvisor/pkg/p9"
@@ -234,6 +236,12 @@ func (fs *filesystem) revalidateHelper(ctx context.Context, vfsObj *vfs.VirtualF
 	if len(state.names) == 0 {
 		return nil
 	}
+	// With a revalidation interval, don't ask the gofer about dentries that
+	// were all revalidated recently.
+	now := monotonicNow()
+	if interval := fs.opts.revalidateInterval; interval != 0 && state.revalidatedSince(now-int64(interval)) {
+		return nil
+	}
 	// Lock metadata on all dentries *before* getting attributes for them.
 	state.lockAllMetadata()
 
@@ -271,6 +279,7 @@ func (fs *filesystem) revalidateHelper(ctx context.Context, vfsObj *vfs.VirtualF
 				} else {
 					d.updateFromP9AttrsLocked(stats[i].Valid, &stats[i].Attr) // +checklocksforce: acquired by lockAllMetadata.
 				}
+				atomic.StoreInt64(&d.revalidated, now)
 			}
 			d.metadataMu.Unlock() // +checklocksforce: see above.
 			continue
@@ -322,6 +331,28 @@ func (fs *filesystem) revalidateHelper(ctx context.Context, vfsObj *vfs.VirtualF
 			}
 			d.parent.dirMu.Unlock()
 
+			// The change was made outside of the sandbox, so no inotify events
+			// were generated for it. Report the file as deleted, and as created
+			// again if it was replaced (e.g. Kubernetes atomically replacing the
+			// "..data" symlink of ConfigMap and Secret volumes).
+			var dirEv uint32
+			if d.isDir() {
+				dirEv = linux.IN_ISDIR
+			}
+			d.watches.Notify(ctx, "", linux.IN_ATTRIB, 0, vfs.InodeEvent, true /* unlinked */)
+			d.parent.watches.Notify(ctx, name, dirEv|linux.IN_DELETE, 0, vfs.InodeEvent, true /* unlinked */)
+			// The watches can't follow the file to its new dentry, so remove
+			// them like Linux does when the file is deleted.
+			d.watches.HandleDeletion(ctx)
+			if found {
+				dirEv = 0
+				if fs.opts.lisaEnabled && statsLisa[i].Mode&linux.S_IFMT == linux.S_IFDIR ||
+					!fs.opts.lisaEnabled && stats[i].Attr.Mode.IsDir() {
+					dirEv = linux.IN_ISDIR
+				}
+				d.parent.watches.Notify(ctx, name, dirEv|linux.IN_CREATE, 0, vfs.InodeEvent, false /* unlinked */)
+			}
+
 			return nil
 		}
 
@@ -331,6 +362,7 @@ func (fs *filesystem) revalidateHelper(ctx context.Context, vfsObj *vfs.VirtualF
 		} else {
 			d.updateFromP9AttrsLocked(stats[i].Valid, &stats[i].Attr) // +checklocksforce: see above.
 		}
+		atomic.StoreInt64(&d.revalidated, now)
 		d.metadataMu.Unlock()
 	}
 
@@ -394,6 +426,17 @@ func (r *revalidateState) lockAllMetadata() {
 	r.locked = true
 }
 
+// revalidatedSince returns true if all dentries in r were revalidated at or
+// after the monotonic time since.
+func (r *revalidateState) revalidatedSince(since int64) bool {
+	for _, d := range r.dentries {
+		if atomic.LoadInt64(&d.revalidated) < since {
+			return false
+		}
+	}
+	return true
+}
+
 func (r *revalidateState) popFront() *dentry {
 	if len(r.dentries) == 0 {
 		return nil
@@ -52,6 +52,11 @@ func (fs *filesystem) PrepareSave(ctx context.Context) error {
 		return fmt.Errorf("gofer.filesystem with no UniqueID cannot be saved")
 	}
 
+	// Stop polling watched dentries, which would otherwise change the dentry
+	// tree while it's saved. Polling is restarted by CompleteRestore, or by
+	// ResumeAfterSave if the sandbox keeps running after the save.
+	fs.stopPollingWatches()
+
 	// Purge cached dentries, which may not be reopenable after restore due to
 	// permission changes.
 	fs.renameMu.Lock()
@@ -80,6 +85,15 @@ func (fs *filesystem) PrepareSave(ctx context.Context) error {
 	return fs.root.prepareSaveRecursive(ctx)
 }
 
+// ResumeAfterSave implements
+// vfs.FilesystemImplSaveRestoreExtension.ResumeAfterSave.
+func (fs *filesystem) ResumeAfterSave(ctx context.Context) {
+	// Pipe data buffered by PrepareSave is read before new data, so it can be
+	// left in place.
+	fs.savedDentryRW = nil
+	fs.startPollingWatches()
+}
+
 // Preconditions:
 // * fd represents a pipe.
 // * fd is readable.
@@ -187,6 +201,8 @@ func (fs *filesystem) CompleteRestore(ctx context.Context, opts vfs.CompleteRest
 		return fmt.Errorf("no server FD available for filesystem with unique ID %q", fs.iopts.UniqueID)
 	}
 	fs.opts.fd = fd
+	// Restored filesystems use a single connection.
+	fs.opts.extraFDs = nil
 	fs.inoByQIDPath = make(map[uint64]uint64)
 	fs.inoByKey = make(map[inoKey]uint64)
 
@@ -253,6 +269,8 @@ func (fs *filesystem) CompleteRestore(ctx context.Context, opts vfs.CompleteRest
 	// Discard state only required during restore.
 	fs.savedDentryRW = nil
 
+	fs.startPollingWatches()
+
 	return nil
 }
 
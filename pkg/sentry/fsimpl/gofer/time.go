@@ -16,11 +16,21 @@ package gofer
 
 import (
 	"sync/atomic"
+	"time"
 
 	"gvisor.dev/gvisor/pkg/abi/linux"
 	"gvisor.dev/gvisor/pkg/sentry/vfs"
 )
 
+// monotonicBase is the origin of monotonicNow.
+var monotonicBase = time.Now()
+
+// monotonicNow returns the current monotonic time in nanoseconds. Unlike
+// filesystem.clock, it doesn't follow changes to the realtime clock.
+func monotonicNow() int64 {
+	return int64(time.Since(monotonicBase))
+}
+
 func dentryTimestampFromP9(s, ns uint64) int64 {
 	return int64(s*1e9 + ns)
 }
@@ -0,0 +1,113 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gofer
+
+import (
+	"time"
+
+	"gvisor.dev/gvisor/pkg/context"
+	"gvisor.dev/gvisor/pkg/log"
+)
+
+// Applications commonly watch files with inotify to reload them when they
+// change, e.g. Kubernetes ConfigMap and Secret volumes, which are updated by
+// atomically replacing their "..data" symlink. Changes made outside of the
+// sandbox don't generate inotify events until the sentry revalidates the
+// changed dentries, which only happens when the application walks to them.
+// The filesystem thus polls the dentries watched by inotify, and the children
+// of watched directories, so that the events are generated without the
+// application having to access the files.
+
+// startPollingWatches starts polling watched dentries if it's enabled and not
+// already started.
+func (fs *filesystem) startPollingWatches() {
+	if fs.opts.interop != InteropModeShared || fs.opts.watchPollInterval == 0 || fs.pollStop != nil {
+		return
+	}
+	fs.pollStop = make(chan struct{})
+	fs.pollDone = make(chan struct{})
+	go fs.pollWatches(fs.pollStop, fs.pollDone, fs.opts.watchPollInterval) // S/R-SAFE: restarted by CompleteRestore or ResumeAfterSave.
+}
+
+// stopPollingWatches stops polling watched dentries, and waits for an ongoing
+// poll to complete.
+func (fs *filesystem) stopPollingWatches() {
+	if fs.pollStop == nil {
+		return
+	}
+	close(fs.pollStop)
+	<-fs.pollDone
+	fs.pollStop = nil
+	fs.pollDone = nil
+}
+
+func (fs *filesystem) pollWatches(stop, done chan struct{}, interval time.Duration) {
+	defer close(done)
+	ctx := context.Background()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		fs.revalidateWatched(ctx)
+	}
+}
+
+// watchedChild is a child dentry to revalidate, identified by its parent and
+// name.
+type watchedChild struct {
+	parent *dentry
+	name   string
+}
+
+// revalidateWatched revalidates the cached dentries watched by inotify and
+// the cached children of watched directories. Dentries that changed are
+// invalidated, generating inotify events.
+func (fs *filesystem) revalidateWatched(ctx context.Context) {
+	var ds *[]*dentry
+	fs.renameMu.RLock()
+	defer fs.renameMuRUnlockAndCheckCaching(ctx, &ds)
+
+	var children []watchedChild
+	dirs := []*dentry{fs.root}
+	for len(dirs) != 0 {
+		dir := dirs[len(dirs)-1]
+		dirs = dirs[:len(dirs)-1]
+		dirWatched := dir.watches.Size() != 0
+		dir.dirMu.Lock()
+		for name, child := range dir.children {
+			if child == nil {
+				continue
+			}
+			if dirWatched || child.watches.Size() != 0 {
+				children = append(children, watchedChild{parent: dir, name: name})
+			}
+			if child.isDir() {
+				dirs = append(dirs, child)
+			}
+		}
+		dir.dirMu.Unlock()
+	}
+
+	vfsObj := fs.vfsfs.VirtualFilesystem()
+	for _, c := range children {
+		if err := fs.revalidateOne(ctx, vfsObj, c.parent, c.name, &ds); err != nil {
+			log.Debugf("gofer.filesystem.revalidateWatched: failed to revalidate %q: %v", c.name, err)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package host
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	"golang.org/x/sys/unix"
+	"gvisor.dev/gvisor/pkg/context"
+	"gvisor.dev/gvisor/pkg/sentry/vfs"
+	"gvisor.dev/gvisor/pkg/usermem"
+	"gvisor.dev/gvisor/pkg/waiter"
+)
+
+// maxMirrorChunk is the maximum amount of data copied at once when writing to
+// a file with output mirrors.
+const maxMirrorChunk = 64 << 10
+
+// hostInode returns the inode backing fd, which must be a host file
+// description.
+func hostInode(fd *vfs.FileDescription) (*inode, error) {
+	switch impl := fd.Impl().(type) {
+	case *fileDescription:
+		return impl.inode, nil
+	case *TTYFileDescription:
+		return impl.inode, nil
+	default:
+		return nil, fmt.Errorf("not a host file description: %T", impl)
+	}
+}
+
+// AddOutputMirror arranges for all data written to fd to be also written to w.
+// fd must be a non-seekable host file description, e.g. a pipe or TTY used for
+// a container's stdio. Writes to w are best effort: errors are ignored, and
+// must not block. The returned function removes the mirror.
+func AddOutputMirror(fd *vfs.FileDescription, w io.Writer) (func(), error) {
+	i, err := hostInode(fd)
+	if err != nil {
+		return nil, err
+	}
+	if i.seekable {
+		return nil, fmt.Errorf("output mirrors are not supported for seekable files")
+	}
+
+	i.mirrorsMu.Lock()
+	defer i.mirrorsMu.Unlock()
+	if i.mirrors == nil {
+		i.mirrors = make(map[io.Writer]struct{})
+	}
+	i.mirrors[w] = struct{}{}
+	atomic.StoreUint32(&i.haveMirrors, 1)
+	return func() {
+		i.mirrorsMu.Lock()
+		defer i.mirrorsMu.Unlock()
+		delete(i.mirrors, w)
+		if len(i.mirrors) == 0 {
+			atomic.StoreUint32(&i.haveMirrors, 0)
+		}
+	}, nil
+}
+
+// InjectInput queues data to be returned by reads from fd before any data
+// from the host file. fd must be a non-seekable host file description, e.g. a
+// pipe or TTY used for a container's stdin.
+func InjectInput(fd *vfs.FileDescription, data []byte) error {
+	i, err := hostInode(fd)
+	if err != nil {
+		return err
+	}
+	if i.seekable {
+		return fmt.Errorf("input injection is not supported for seekable files")
+	}
+	if len(data) == 0 {
+		return nil
+	}
+
+	i.bufMu.Lock()
+	i.buf = append(i.buf, data...)
+	atomic.StoreUint32(&i.haveBuf, 1)
+	i.bufMu.Unlock()
+	i.queue.Notify(waiter.ReadableEvents)
+	return nil
+}
+
+// writeMirrorsLocked writes buf to all output mirrors.
+//
+// Preconditions: i.mirrorsMu must be locked.
+func (i *inode) writeMirrorsLocked(buf []byte) {
+	for w := range i.mirrors {
+		_, _ = w.Write(buf)
+	}
+}
+
+// writeToHostFDMirrored is like writeToHostFD, but also writes the data to the
+// inode's output mirrors. It's only used for non-seekable files.
+func (f *fileDescription) writeToHostFDMirrored(ctx context.Context, src usermem.IOSequence) (int64, error) {
+	i := f.inode
+	var done int64
+	buf := make([]byte, min(src.NumBytes(), maxMirrorChunk))
+	for src.NumBytes() > 0 {
+		n, err := src.CopyIn(ctx, buf[:min(src.NumBytes(), maxMirrorChunk)])
+		if n == 0 {
+			return done, err
+		}
+		written, werr := unix.Write(i.hostFD, buf[:n])
+		if written < 0 {
+			written = 0
+		}
+		if written > 0 {
+			i.mirrorsMu.Lock()
+			i.writeMirrorsLocked(buf[:written])
+			i.mirrorsMu.Unlock()
+		}
+		done += int64(written)
+		src = src.DropFirst(written)
+		if werr != nil {
+			if done > 0 && isBlockError(werr) {
+				// Report a partial write instead of blocking.
+				return done, nil
+			}
+			return done, werr
+		}
+		if err != nil {
+			return done, err
+		}
+		if written < n {
+			break
+		}
+	}
+	return done, nil
+}
+
+func min(a int64, b int) int {
+	if a < int64(b) {
+		return int(a)
+	}
+	return b
+}
@@ -18,6 +18,7 @@ package host
 
 import (
 	"fmt"
+	"io"
 	"math"
 	"sync/atomic"
 
@@ -133,13 +134,24 @@ type inode struct {
 	// application to change these fields without affecting the host.
 	virtualOwner virtualOwner
 
-	// If haveBuf is non-zero, hostFD represents a pipe, and buf contains data
-	// read from the pipe from previous calls to inode.beforeSave(). haveBuf
-	// and buf are protected by bufMu. haveBuf is accessed using atomic memory
-	// operations.
+	// If haveBuf is non-zero, buf contains data that reads return before any
+	// data from hostFD: data read from a pipe by previous calls to
+	// inode.beforeSave(), and data queued by InjectInput for non-seekable
+	// files. Both append to buf, and readFromBuf consumes it. haveBuf and buf
+	// are protected by bufMu. haveBuf is accessed using atomic memory
+	// operations; it may be read without bufMu to skip locking when it's zero,
+	// but is only stored with bufMu held.
 	bufMu   sync.Mutex `state:"nosave"`
 	haveBuf uint32
 	buf     []byte
+
+	// mirrors receive a copy of all data written to hostFD, see
+	// AddOutputMirror. haveMirrors is non-zero if mirrors is not empty, and is
+	// accessed using atomic memory operations. mirrors is protected by
+	// mirrorsMu.
+	mirrorsMu   sync.Mutex             `state:"nosave"`
+	haveMirrors uint32                 `state:"nosave"`
+	mirrors     map[io.Writer]struct{} `state:"nosave"`
 }
 
 func newInode(ctx context.Context, fs *filesystem, hostFD int, savable bool, fileType linux.FileMode, isTTY bool) (*inode, error) {
@@ -601,6 +613,9 @@ func (i *inode) open(ctx context.Context, d *kernfs.Dentry, mnt *vfs.Mount, file
 				fileDescription: fileDescription{inode: i},
 				termios:         linux.DefaultReplicaTermios,
 			}
+			if ws, err := ioctlGetWinsize(i.hostFD); err == nil {
+				fd.winsize = *ws
+			}
 			if task := kernel.TaskFromContext(ctx); task != nil {
 				fd.fgProcessGroup = task.ThreadGroup().ProcessGroup()
 				fd.session = fd.fgProcessGroup.Session()
@@ -764,7 +779,15 @@ func (f *fileDescription) PWrite(ctx context.Context, src usermem.IOSequence, of
 func (f *fileDescription) Write(ctx context.Context, src usermem.IOSequence, opts vfs.WriteOptions) (int64, error) {
 	i := f.inode
 	if !i.seekable {
-		n, err := f.writeToHostFD(ctx, src, -1, opts.Flags)
+		var (
+			n   int64
+			err error
+		)
+		if atomic.LoadUint32(&i.haveMirrors) != 0 && opts.Flags == 0 {
+			n, err = f.writeToHostFDMirrored(ctx, src)
+		} else {
+			n, err = f.writeToHostFD(ctx, src, -1, opts.Flags)
+		}
 		if isBlockError(err) {
 			err = linuxerr.ErrWouldBlock
 		}
@@ -909,7 +932,12 @@ func (f *fileDescription) EventUnregister(e *waiter.Entry) {
 
 // Readiness uses the poll() syscall to check the status of the underlying FD.
 func (f *fileDescription) Readiness(mask waiter.EventMask) waiter.EventMask {
-	return fdnotifier.NonBlockingPoll(int32(f.inode.hostFD), mask)
+	ready := fdnotifier.NonBlockingPoll(int32(f.inode.hostFD), mask)
+	if atomic.LoadUint32(&f.inode.haveBuf) != 0 {
+		// Data injected with InjectInput, or saved before checkpoint.
+		ready |= mask & waiter.ReadableEvents
+	}
+	return ready
 }
 
 // Ioctl queries the underlying FD for allowed ioctl commands.
@@ -46,6 +46,12 @@ type TTYFileDescription struct {
 
 	// termios contains the terminal attributes for this TTY.
 	termios linux.KernelTermios
+
+	// winsize is the last window size seen by the sandbox, used to decide if
+	// SetWinsize changes it. The host TTY can't be used for this, since it may
+	// already have been resized from outside of the sandbox. It's zero if
+	// unknown.
+	winsize linux.Winsize `state:"nosave"`
 }
 
 // InitForegroundProcessGroup sets the foreground process group and session for
@@ -68,6 +74,23 @@ func (t *TTYFileDescription) ForegroundProcessGroup() *kernel.ProcessGroup {
 	return t.fgProcessGroup
 }
 
+// SetWinsize sets the window size of the TTY. If the size changes, SIGWINCH
+// is sent to the foreground process group, as in Linux's tty_do_resize().
+func (t *TTYFileDescription) SetWinsize(winsize *linux.Winsize) error {
+	if err := ioctlSetWinsize(t.inode.hostFD, winsize); err != nil {
+		return err
+	}
+	t.mu.Lock()
+	changed := t.winsize != *winsize
+	t.winsize = *winsize
+	pg := t.fgProcessGroup
+	t.mu.Unlock()
+	if changed && pg != nil {
+		_ = pg.SendSignal(kernel.SignalInfoPriv(linux.SIGWINCH))
+	}
+	return nil
+}
+
 // Release implements fs.FileOperations.Release.
 func (t *TTYFileDescription) Release(ctx context.Context) {
 	t.mu.Lock()
@@ -269,8 +292,7 @@ func (t *TTYFileDescription) Ioctl(ctx context.Context, io usermem.IO, args arch
 		if _, err := winsize.CopyIn(task, args[2].Pointer()); err != nil {
 			return 0, err
 		}
-		err := ioctlSetWinsize(fd, &winsize)
-		return 0, err
+		return 0, t.SetWinsize(&winsize)
 
 	// Unimplemented commands.
 	case linux.TIOCSETD,
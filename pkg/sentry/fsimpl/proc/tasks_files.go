@@ -251,6 +251,47 @@ func (*loadavgData) Generate(ctx context.Context, buf *bytes.Buffer) error {
 	return nil
 }
 
+// newPressureDir returns the inode of the /proc/pressure directory.
+func (fs *filesystem) newPressureDir(ctx context.Context, root *auth.Credentials) kernfs.Inode {
+	contents := make(map[string]kernfs.Inode)
+	for r := kernel.PressureResource(0); r < kernel.NumPressureResources; r++ {
+		contents[r.String()] = fs.newInode(ctx, root, 0444, &pressureData{resource: r})
+	}
+	return fs.newStaticDir(ctx, root, contents)
+}
+
+// pressureData backs the files of /proc/pressure, which contain pressure stall
+// information on a resource for the container of the reading task.
+//
+// +stateify savable
+type pressureData struct {
+	dynamicBytesFileSetAttr
+
+	resource kernel.PressureResource
+}
+
+var _ dynamicInode = (*pressureData)(nil)
+
+// Generate implements vfs.DynamicBytesSource.Generate.
+func (d *pressureData) Generate(ctx context.Context, buf *bytes.Buffer) error {
+	var cid string
+	if t := kernel.TaskFromContext(ctx); t != nil {
+		cid = t.ContainerID()
+	}
+	ps, _ := kernel.KernelFromContext(ctx).ContainerPressure(cid)
+	rp := ps[d.resource]
+	for _, l := range []struct {
+		name string
+		line *kernel.PressureLine
+	}{
+		{"some", &rp.Some},
+		{"full", &rp.Full},
+	} {
+		fmt.Fprintf(buf, "%s avg10=%.2f avg60=%.2f avg300=%.2f total=%d\n", l.name, l.line.Avg10, l.line.Avg60, l.line.Avg300, l.line.Total.Microseconds())
+	}
+	return nil
+}
+
 // meminfoData implements vfs.DynamicBytesSource for /proc/meminfo.
 //
 // +stateify savable
@@ -47,9 +47,9 @@ func (fs *filesystem) newSysDir(ctx context.Context, root *auth.Credentials, k *
 		"kernel": fs.newStaticDir(ctx, root, map[string]kernfs.Inode{
 			"hostname": fs.newInode(ctx, root, 0444, &hostnameData{}),
 			"sem":      fs.newInode(ctx, root, 0444, newStaticFile(fmt.Sprintf("%d\t%d\t%d\t%d\n", linux.SEMMSL, linux.SEMMNS, linux.SEMOPM, linux.SEMMNI))),
-			"shmall":   fs.newInode(ctx, root, 0444, ipcData(linux.SHMALL)),
-			"shmmax":   fs.newInode(ctx, root, 0444, ipcData(linux.SHMMAX)),
-			"shmmni":   fs.newInode(ctx, root, 0444, ipcData(linux.SHMMNI)),
+			"shmall":   fs.newInode(ctx, root, 0444, &shmData{param: shmAll}),
+			"shmmax":   fs.newInode(ctx, root, 0444, &shmData{param: shmMax}),
+			"shmmni":   fs.newInode(ctx, root, 0444, &shmData{param: shmMni}),
 			"msgmni":   fs.newInode(ctx, root, 0444, ipcData(linux.MSGMNI)),
 			"msgmax":   fs.newInode(ctx, root, 0444, ipcData(linux.MSGMAX)),
 			"msgmnb":   fs.newInode(ctx, root, 0444, ipcData(linux.MSGMNB)),
@@ -129,7 +129,7 @@ func (fs *filesystem) newSysNetDir(ctx context.Context, root *auth.Credentials,
 				"optmem_max":    fs.newInode(ctx, root, 0444, newStaticFile("0")),
 				"rmem_default":  fs.newInode(ctx, root, 0444, newStaticFile("212992")),
 				"rmem_max":      fs.newInode(ctx, root, 0444, newStaticFile("212992")),
-				"somaxconn":     fs.newInode(ctx, root, 0444, newStaticFile("128")),
+				"somaxconn":     fs.newInode(ctx, root, 0444, &somaxconnData{netns: k.RootNetworkNamespace()}),
 				"wmem_default":  fs.newInode(ctx, root, 0444, newStaticFile("212992")),
 				"wmem_max":      fs.newInode(ctx, root, 0444, newStaticFile("212992")),
 			}),
@@ -174,6 +174,65 @@ func (*hostnameData) Generate(ctx context.Context, buf *bytes.Buffer) error {
 	return nil
 }
 
+// somaxconnData implements vfs.DynamicBytesSource for
+// /proc/sys/net/core/somaxconn.
+//
+// +stateify savable
+type somaxconnData struct {
+	kernfs.DynamicBytesFile
+
+	netns *inet.Namespace
+}
+
+var _ dynamicInode = (*somaxconnData)(nil)
+
+// Generate implements vfs.DynamicBytesSource.Generate.
+func (d *somaxconnData) Generate(ctx context.Context, buf *bytes.Buffer) error {
+	fmt.Fprintf(buf, "%d\n", d.netns.Somaxconn())
+	return nil
+}
+
+// shmParam identifies a SysV shared memory limit.
+//
+// +stateify savable
+type shmParam int
+
+const (
+	shmAll shmParam = iota
+	shmMax
+	shmMni
+)
+
+// shmData implements vfs.DynamicBytesSource for /proc/sys/kernel/shm*.
+//
+// +stateify savable
+type shmData struct {
+	kernfs.DynamicBytesFile
+
+	param shmParam
+}
+
+var _ dynamicInode = (*shmData)(nil)
+
+// Generate implements vfs.DynamicBytesSource.Generate.
+func (d *shmData) Generate(ctx context.Context, buf *bytes.Buffer) error {
+	ipcns := kernel.IPCNamespaceFromContext(ctx)
+	if ipcns == nil {
+		return linuxerr.ESRCH
+	}
+	defer ipcns.DecRef(ctx)
+	params := ipcns.ShmRegistry().IPCInfo()
+	switch d.param {
+	case shmAll:
+		fmt.Fprintf(buf, "%d\n", params.ShmAll)
+	case shmMax:
+		fmt.Fprintf(buf, "%d\n", params.ShmMax)
+	case shmMni:
+		fmt.Fprintf(buf, "%d\n", params.ShmMni)
+	}
+	return nil
+}
+
 // tcpSackData implements vfs.WritableDynamicBytesSource for
 // /proc/sys/net/tcp_sack.
 //
@@ -54,6 +54,7 @@ var (
 		"meminfo":     linux.DT_REG,
 		"mounts":      linux.DT_LNK,
 		"net":         linux.DT_LNK,
+		"pressure":    linux.DT_DIR,
 		"self":        linux.DT_LNK,
 		"stat":        linux.DT_REG,
 		"sys":         linux.DT_DIR,
@@ -0,0 +1,304 @@
+// Copyright 2022 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sys
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/context"
+	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+	"gvisor.dev/gvisor/pkg/sentry/fsimpl/kernfs"
+	"gvisor.dev/gvisor/pkg/sentry/inet"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/auth"
+	"gvisor.dev/gvisor/pkg/sentry/vfs"
+)
+
+// netInterfaceFiles are the attribute files in /sys/class/net/<iface>.
+var netInterfaceFiles = []string{
+	"addr_len",
+	"address",
+	"broadcast",
+	"carrier",
+	"flags",
+	"ifindex",
+	"mtu",
+	"operstate",
+	"type",
+}
+
+// netStatisticsFiles maps the files in /sys/class/net/<iface>/statistics to
+// indices in inet.StatDev.
+var netStatisticsFiles = map[string]int{
+	"rx_bytes":          0,
+	"rx_packets":        1,
+	"rx_errors":         2,
+	"rx_dropped":        3,
+	"rx_fifo_errors":    4,
+	"rx_frame_errors":   5,
+	"rx_compressed":     6,
+	"multicast":         7,
+	"tx_bytes":          8,
+	"tx_packets":        9,
+	"tx_errors":         10,
+	"tx_dropped":        11,
+	"tx_fifo_errors":    12,
+	"collisions":        13,
+	"tx_carrier_errors": 14,
+	"tx_compressed":     15,
+}
+
+// netDir returns the inode of /sys/class/net, which contains a directory for
+// each interface of the network stack of ctx when sysfs is mounted.
+//
+// Unlike Linux, where /sys/class/net/<iface> is a symlink to the device
+// directory, interface directories are created directly in /sys/class/net.
+func netDir(ctx context.Context, fs *filesystem, creds *auth.Credentials) kernfs.Inode {
+	d := &netClassDir{
+		fs:    fs,
+		creds: creds,
+		stack: inet.StackFromContext(ctx),
+	}
+	d.InodeAttrs.Init(ctx, creds, linux.UNNAMED_MAJOR, fs.devMinor, fs.NextIno(), linux.ModeDirectory|0755)
+	d.OrderedChildren.Init(kernfs.OrderedChildrenOptions{})
+	return d
+}
+
+// netClassDir implements kernfs.Inode for /sys/class/net. Interface
+// directories are created when they are looked up, so that interfaces added
+// to or removed from the network stack after sysfs is mounted are reflected,
+// like in /proc/net.
+//
+// +stateify savable
+type netClassDir struct {
+	implStatFS
+	kernfs.InodeAlwaysValid
+	kernfs.InodeAttrs
+	kernfs.InodeDirectoryNoNewChildren
+	kernfs.InodeNoopRefCount
+	kernfs.InodeNotSymlink
+	kernfs.InodeTemporary
+	kernfs.OrderedChildren
+
+	locks vfs.FileLocks
+
+	fs    *filesystem
+	creds *auth.Credentials
+
+	// stack is the network stack whose interfaces are listed. It is nil if
+	// there is no network stack.
+	stack inet.Stack
+}
+
+var _ kernfs.Inode = (*netClassDir)(nil)
+
+// Lookup implements kernfs.inodeDirectory.Lookup.
+func (d *netClassDir) Lookup(ctx context.Context, name string) (kernfs.Inode, error) {
+	if d.stack != nil {
+		for idx, iface := range d.stack.Interfaces() {
+			if iface.Name == name {
+				return d.fs.newNetInterfaceDir(ctx, d.creds, d.stack, idx, name), nil
+			}
+		}
+	}
+	return nil, linuxerr.ENOENT
+}
+
+// IterDirents implements kernfs.inodeDirectory.IterDirents. Interfaces are
+// listed by index, which is also their offset in the directory.
+func (d *netClassDir) IterDirents(ctx context.Context, mnt *vfs.Mount, cb vfs.IterDirentsCallback, offset, relOffset int64) (int64, error) {
+	if d.stack == nil {
+		return offset, nil
+	}
+	ifaces := d.stack.Interfaces()
+	idxs := make([]int32, 0, len(ifaces))
+	for idx := range ifaces {
+		if int64(idx) >= relOffset {
+			idxs = append(idxs, idx)
+		}
+	}
+	sort.Slice(idxs, func(i, j int) bool { return idxs[i] < idxs[j] })
+	for _, idx := range idxs {
+		dirent := vfs.Dirent{
+			Name:    ifaces[idx].Name,
+			Type:    linux.DT_DIR,
+			Ino:     d.fs.NextIno(),
+			NextOff: offset - relOffset + int64(idx) + 1,
+		}
+		if err := cb.Handle(dirent); err != nil {
+			return offset, err
+		}
+		offset = dirent.NextOff
+	}
+	return offset, nil
+}
+
+// Open implements kernfs.Inode.Open.
+func (d *netClassDir) Open(ctx context.Context, rp *vfs.ResolvingPath, kd *kernfs.Dentry, opts vfs.OpenOptions) (*vfs.FileDescription, error) {
+	fd, err := kernfs.NewGenericDirectoryFD(rp.Mount(), kd, &d.OrderedChildren, &d.locks, &opts, kernfs.GenericDirectoryFDOptions{
+		SeekEnd: kernfs.SeekEndZero,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fd.VFSFileDescription(), nil
+}
+
+// SetStat implements kernfs.Inode.SetStat not allowing inode attributes to be changed.
+func (*netClassDir) SetStat(context.Context, *vfs.Filesystem, *auth.Credentials, vfs.SetStatOptions) error {
+	return linuxerr.EPERM
+}
+
+// netInterfaceDir implements kernfs.Inode for /sys/class/net/<iface>.
+//
+// +stateify savable
+type netInterfaceDir struct {
+	dir
+
+	stack inet.Stack
+	idx   int32
+	name  string
+}
+
+func (fs *filesystem) newNetInterfaceDir(ctx context.Context, creds *auth.Credentials, stack inet.Stack, idx int32, name string) kernfs.Inode {
+	children := make(map[string]kernfs.Inode)
+	for _, file := range netInterfaceFiles {
+		children[file] = fs.newNetFile(ctx, creds, stack, idx, file, -1)
+	}
+	stats := make(map[string]kernfs.Inode)
+	for file, stat := range netStatisticsFiles {
+		stats[file] = fs.newNetFile(ctx, creds, stack, idx, file, stat)
+	}
+	children["statistics"] = fs.newDir(ctx, creds, defaultSysDirMode, stats)
+	d := &netInterfaceDir{
+		stack: stack,
+		idx:   idx,
+		name:  name,
+	}
+	d.dir.init(ctx, fs, creds, children)
+	return d
+}
+
+// Valid implements kernfs.Inode.Valid. The directory is invalidated when its
+// interface is removed or renamed, so that it's looked up again.
+func (d *netInterfaceDir) Valid(ctx context.Context) bool {
+	iface, ok := d.stack.Interfaces()[d.idx]
+	return ok && iface.Name == d.name
+}
+
+// netFile implements kernfs.Inode for an attribute file of a network
+// interface.
+//
+// +stateify savable
+type netFile struct {
+	implStatFS
+	kernfs.DynamicBytesFile
+
+	stack inet.Stack
+	idx   int32
+	name  string
+
+	// stat is the index of the file's statistic in inet.StatDev, or -1 if
+	// the file isn't in the statistics directory.
+	stat int
+}
+
+func (fs *filesystem) newNetFile(ctx context.Context, creds *auth.Credentials, stack inet.Stack, idx int32, name string, stat int) kernfs.Inode {
+	f := &netFile{
+		stack: stack,
+		idx:   idx,
+		name:  name,
+		stat:  stat,
+	}
+	f.DynamicBytesFile.Init(ctx, creds, linux.UNNAMED_MAJOR, fs.devMinor, fs.NextIno(), f, 0444)
+	return f
+}
+
+// Generate implements vfs.DynamicBytesSource.Generate.
+func (f *netFile) Generate(ctx context.Context, buf *bytes.Buffer) error {
+	iface, ok := f.stack.Interfaces()[f.idx]
+	if !ok {
+		// The interface was removed.
+		return linuxerr.ENODEV
+	}
+
+	if f.stat >= 0 {
+		var stats inet.StatDev
+		if err := f.stack.Statistics(&stats, iface.Name); err != nil {
+			return err
+		}
+		fmt.Fprintf(buf, "%d\n", stats[f.stat])
+		return nil
+	}
+
+	addr := iface.Addr
+	if len(addr) == 0 {
+		// Like Linux's loopback device.
+		addr = make([]byte, 6)
+	}
+	up := iface.Flags&linux.IFF_UP != 0 && iface.Flags&linux.IFF_RUNNING != 0
+	switch f.name {
+	case "addr_len":
+		fmt.Fprintf(buf, "%d\n", len(addr))
+	case "address":
+		fmt.Fprintf(buf, "%s\n", hardwareAddr(addr))
+	case "broadcast":
+		bcast := make([]byte, len(addr))
+		if iface.Flags&linux.IFF_LOOPBACK == 0 {
+			for i := range bcast {
+				bcast[i] = 0xff
+			}
+		}
+		fmt.Fprintf(buf, "%s\n", hardwareAddr(bcast))
+	case "carrier":
+		if !up {
+			return linuxerr.EINVAL
+		}
+		buf.WriteString("1\n")
+	case "flags":
+		fmt.Fprintf(buf, "0x%x\n", iface.Flags)
+	case "ifindex":
+		fmt.Fprintf(buf, "%d\n", f.idx)
+	case "mtu":
+		fmt.Fprintf(buf, "%d\n", iface.MTU)
+	case "operstate":
+		// See Linux's net/core/net-sysfs.c:operstate_show.
+		switch {
+		case iface.Flags&linux.IFF_LOOPBACK != 0:
+			buf.WriteString("unknown\n")
+		case up:
+			buf.WriteString("up\n")
+		default:
+			buf.WriteString("down\n")
+		}
+	case "type":
+		fmt.Fprintf(buf, "%d\n", iface.DeviceType)
+	default:
+		panic(fmt.Sprintf("unknown network interface file %q", f.name))
+	}
+	return nil
+}
+
+// hardwareAddr formats addr like Linux's sysfs_format_mac.
+func hardwareAddr(addr []byte) string {
+	parts := make([]string, len(addr))
+	for i, b := range addr {
+		parts[i] = fmt.Sprintf("%02x", b)
+	}
+	return strings.Join(parts, ":")
+}
@@ -100,6 +100,7 @@ func (fsType FilesystemType) GetFilesystem(ctx context.Context, vfsObj *vfs.Virt
 		"block": fs.newDir(ctx, creds, defaultSysDirMode, nil),
 		"bus":   fs.newDir(ctx, creds, defaultSysDirMode, nil),
 		"class": fs.newDir(ctx, creds, defaultSysDirMode, map[string]kernfs.Inode{
+			"net":          netDir(ctx, fs, creds),
 			"power_supply": fs.newDir(ctx, creds, defaultSysDirMode, nil),
 		}),
 		"dev": fs.newDir(ctx, creds, defaultSysDirMode, nil),
@@ -177,11 +178,15 @@ type dir struct {
 
 func (fs *filesystem) newDir(ctx context.Context, creds *auth.Credentials, mode linux.FileMode, contents map[string]kernfs.Inode) kernfs.Inode {
 	d := &dir{}
+	d.init(ctx, fs, creds, contents)
+	return d
+}
+
+func (d *dir) init(ctx context.Context, fs *filesystem, creds *auth.Credentials, contents map[string]kernfs.Inode) {
 	d.InodeAttrs.Init(ctx, creds, linux.UNNAMED_MAJOR, fs.devMinor, fs.NextIno(), linux.ModeDirectory|0755)
 	d.OrderedChildren.Init(kernfs.OrderedChildrenOptions{})
 	d.InitRefs()
 	d.IncLinks(d.OrderedChildren.Populate(contents))
-	return d
 }
 
 // SetStat implements kernfs.Inode.SetStat not allowing inode attributes to be changed.
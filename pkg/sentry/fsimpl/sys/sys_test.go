@@ -20,19 +20,31 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/context"
+	"gvisor.dev/gvisor/pkg/errors/linuxerr"
 	"gvisor.dev/gvisor/pkg/sentry/fsimpl/sys"
 	"gvisor.dev/gvisor/pkg/sentry/fsimpl/testutil"
+	"gvisor.dev/gvisor/pkg/sentry/inet"
 	"gvisor.dev/gvisor/pkg/sentry/kernel"
 	"gvisor.dev/gvisor/pkg/sentry/kernel/auth"
 	"gvisor.dev/gvisor/pkg/sentry/vfs"
 )
 
 func newTestSystem(t *testing.T) *testutil.System {
+	return newTestSystemWithStack(t, nil)
+}
+
+// newTestSystemWithStack returns a test system with sysfs mounted with the
+// network stack stack, if not nil.
+func newTestSystemWithStack(t *testing.T, stack inet.Stack) *testutil.System {
 	k, err := testutil.Boot()
 	if err != nil {
 		t.Fatalf("Failed to create test kernel: %v", err)
 	}
 	ctx := k.SupervisorContext()
+	if stack != nil {
+		ctx = context.WithValue(ctx, inet.CtxStack, stack)
+	}
 	creds := auth.CredentialsFromContext(ctx)
 	k.VFS().MustRegisterFilesystemType(sys.Name, sys.FilesystemType{}, &vfs.RegisterFilesystemTypeOptions{
 		AllowUserMount: true,
@@ -101,3 +113,86 @@ func TestCgroupMountpointExists(t *testing.T) {
 	pop = s.PathOpAtRoot("/fs/cgroup")
 	s.AssertAllDirentTypes(s.ListDirents(pop), map[string]testutil.DirentType{ /*empty*/ })
 }
+
+func TestNetInterfaceFiles(t *testing.T) {
+	stack := inet.NewTestStack()
+	stack.InterfacesMap[1] = inet.Interface{
+		DeviceType: linux.ARPHRD_LOOPBACK,
+		Flags:      linux.IFF_UP | linux.IFF_LOOPBACK | linux.IFF_RUNNING,
+		Name:       "lo",
+		MTU:        65536,
+	}
+	stack.InterfacesMap[2] = inet.Interface{
+		DeviceType: linux.ARPHRD_ETHER,
+		Flags:      linux.IFF_UP | linux.IFF_RUNNING,
+		Name:       "eth0",
+		Addr:       []byte{0x02, 0x42, 0xac, 0x11, 0x00, 0x02},
+		MTU:        1500,
+	}
+	s := newTestSystemWithStack(t, stack)
+	defer s.Destroy()
+
+	s.AssertAllDirentTypes(s.ListDirents(s.PathOpAtRoot("class/net")), map[string]testutil.DirentType{
+		"eth0": linux.DT_DIR,
+		"lo":   linux.DT_DIR,
+	})
+
+	for _, tc := range []struct {
+		path string
+		want string
+	}{
+		{"class/net/lo/address", "00:00:00:00:00:00\n"},
+		{"class/net/lo/mtu", "65536\n"},
+		{"class/net/lo/operstate", "unknown\n"},
+		{"class/net/eth0/address", "02:42:ac:11:00:02\n"},
+		{"class/net/eth0/broadcast", "ff:ff:ff:ff:ff:ff\n"},
+		{"class/net/eth0/ifindex", "2\n"},
+		{"class/net/eth0/mtu", "1500\n"},
+		{"class/net/eth0/operstate", "up\n"},
+		{"class/net/eth0/type", "1\n"},
+		{"class/net/eth0/statistics/rx_bytes", "0\n"},
+	} {
+		pop := s.PathOpAtRoot(tc.path)
+		fd, err := s.VFS.OpenAt(s.Ctx, s.Creds, pop, &vfs.OpenOptions{})
+		if err != nil {
+			t.Fatalf("OpenAt(%q) failed: %v", tc.path, err)
+		}
+		content, err := s.ReadToEnd(fd)
+		fd.DecRef(s.Ctx)
+		if err != nil {
+			t.Fatalf("Read(%q) failed: %v", tc.path, err)
+		}
+		if content != tc.want {
+			t.Errorf("Read(%q) = %q, want %q", tc.path, content, tc.want)
+		}
+	}
+
+	// Interfaces added or removed after sysfs is mounted are reflected.
+	delete(stack.InterfacesMap, 2)
+	stack.InterfacesMap[3] = inet.Interface{
+		DeviceType: linux.ARPHRD_ETHER,
+		Flags:      linux.IFF_UP | linux.IFF_RUNNING,
+		Name:       "eth1",
+		Addr:       []byte{0x02, 0x42, 0xac, 0x11, 0x00, 0x03},
+		MTU:        1500,
+	}
+	s.AssertAllDirentTypes(s.ListDirents(s.PathOpAtRoot("class/net")), map[string]testutil.DirentType{
+		"eth1": linux.DT_DIR,
+		"lo":   linux.DT_DIR,
+	})
+	if _, err := s.VFS.OpenAt(s.Ctx, s.Creds, s.PathOpAtRoot("class/net/eth0/mtu"), &vfs.OpenOptions{}); !linuxerr.Equals(linuxerr.ENOENT, err) {
+		t.Errorf("OpenAt(class/net/eth0/mtu) got error %v, want ENOENT", err)
+	}
+	fd, err := s.VFS.OpenAt(s.Ctx, s.Creds, s.PathOpAtRoot("class/net/eth1/ifindex"), &vfs.OpenOptions{})
+	if err != nil {
+		t.Fatalf("OpenAt(class/net/eth1/ifindex) failed: %v", err)
+	}
+	content, err := s.ReadToEnd(fd)
+	fd.DecRef(s.Ctx)
+	if err != nil {
+		t.Fatalf("Read(class/net/eth1/ifindex) failed: %v", err)
+	}
+	if want := "3\n"; content != want {
+		t.Errorf("Read(class/net/eth1/ifindex) = %q, want %q", content, want)
+	}
+}
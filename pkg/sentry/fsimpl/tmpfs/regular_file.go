@@ -219,7 +219,9 @@ func (rf *regularFile) truncateLocked(newSize uint64) (bool, error) {
 	// We are now guaranteed that there are no translations of truncated pages,
 	// and can remove them.
 	rf.dataMu.Lock()
+	spanBefore := rf.data.Span()
 	rf.data.Truncate(newSize, rf.memFile)
+	rf.inode.fs.unaccountPages((spanBefore - rf.data.Span()) / hostarch.PageSize)
 	rf.dataMu.Unlock()
 	return true, nil
 }
@@ -295,10 +297,24 @@ func (rf *regularFile) Translate(ctx context.Context, required, optional memmap.
 		optional.End = pgend
 	}
 
+	// Charge the pages that may be allocated below to the filesystem's size
+	// limit. If the filesystem is size-limited, don't allocate more than what
+	// is required.
+	if rf.inode.fs.maxSizeInPages != 0 {
+		optional = required
+	}
+	pages := (optional.Length() - rf.data.SpanRange(optional)) / hostarch.PageSize
+	if !rf.inode.fs.accountPages(pages) {
+		// Compare Linux's mm/shmem.c:shmem_fault() => VM_FAULT_SIGBUS.
+		return nil, &memmap.BusError{linuxerr.ENOSPC}
+	}
+	spanBefore := rf.data.Span()
 	cerr := rf.data.Fill(ctx, required, optional, rf.size, rf.memFile, rf.memoryUsageKind, func(_ context.Context, dsts safemem.BlockSeq, _ uint64) (uint64, error) {
 		// Newly-allocated pages are zeroed, so we don't need to do anything.
 		return dsts.NumBytes(), nil
 	})
+	// Return the charge for any pages that were not allocated.
+	rf.inode.fs.unaccountPages(pages - (rf.data.Span()-spanBefore)/hostarch.PageSize)
 
 	var ts []memmap.Translation
 	var translatedEnd uint64
@@ -657,8 +673,14 @@ func (rw *regularFileReadWriter) WriteFromBlocks(srcs safemem.BlockSeq) (uint64,
 		case gap.Ok():
 			// Allocate memory for the write.
 			gapMR := gap.Range().Intersect(pgMR)
+			pages := gapMR.Length() / hostarch.PageSize
+			if !rw.file.inode.fs.accountPages(pages) {
+				retErr = linuxerr.ENOSPC
+				goto exitLoop
+			}
 			fr, err := rw.file.memFile.Allocate(gapMR.Length(), pgalloc.AllocOpts{Kind: rw.file.memoryUsageKind})
 			if err != nil {
+				rw.file.inode.fs.unaccountPages(pages)
 				retErr = err
 				goto exitLoop
 			}
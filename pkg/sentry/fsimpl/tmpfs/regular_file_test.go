@@ -22,8 +22,11 @@ import (
 
 	"gvisor.dev/gvisor/pkg/abi/linux"
 	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+	"gvisor.dev/gvisor/pkg/fspath"
+	"gvisor.dev/gvisor/pkg/hostarch"
 	"gvisor.dev/gvisor/pkg/sentry/contexttest"
 	"gvisor.dev/gvisor/pkg/sentry/fs/lock"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/auth"
 	"gvisor.dev/gvisor/pkg/sentry/vfs"
 	"gvisor.dev/gvisor/pkg/usermem"
 )
@@ -347,3 +350,147 @@ func TestTruncate(t *testing.T) {
 		t.Errorf("fd.Stat got Ctime %v, want %v", got, statAfterTruncateUp.Ctime)
 	}
 }
+
+func TestSizeLimit(t *testing.T) {
+	ctx := contexttest.Context(t)
+	creds := auth.CredentialsFromContext(ctx)
+	vfsObj, root, cleanup, err := newTmpfsRootWithData(ctx, "size=8k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	fd, err := vfsObj.OpenAt(ctx, creds, &vfs.PathOperation{
+		Root:  root,
+		Start: root,
+		Path:  fspath.Parse("file"),
+	}, &vfs.OpenOptions{
+		Flags: linux.O_RDWR | linux.O_CREAT | linux.O_EXCL,
+		Mode:  linux.ModeRegular | 0644,
+	})
+	if err != nil {
+		t.Fatalf("OpenAt failed: %v", err)
+	}
+	defer fd.DecRef(ctx)
+
+	// Fill the filesystem.
+	data := bytes.Repeat([]byte{'a'}, 2*hostarch.PageSize)
+	if n, err := fd.PWrite(ctx, usermem.BytesIOSequence(data), 0, vfs.WriteOptions{}); err != nil || n != int64(len(data)) {
+		t.Fatalf("fd.PWrite got (%d, %v), want (%d, nil)", n, err, len(data))
+	}
+	statfs, err := fd.StatFS(ctx)
+	if err != nil {
+		t.Fatalf("fd.StatFS failed: %v", err)
+	}
+	if statfs.Blocks != 2 || statfs.BlocksFree != 0 {
+		t.Errorf("fd.StatFS got blocks: %d, free: %d, want blocks: 2, free: 0", statfs.Blocks, statfs.BlocksFree)
+	}
+
+	// Writing past the limit fails.
+	if _, err := fd.PWrite(ctx, usermem.BytesIOSequence([]byte{'b'}), int64(len(data)), vfs.WriteOptions{}); !linuxerr.Equals(linuxerr.ENOSPC, err) {
+		t.Errorf("fd.PWrite past size limit got err %v, want ENOSPC", err)
+	}
+
+	// Truncating releases space.
+	if err := fd.SetStat(ctx, vfs.SetStatOptions{
+		Stat: linux.Statx{
+			Mask: linux.STATX_SIZE,
+			Size: 0,
+		},
+	}); err != nil {
+		t.Fatalf("fd.SetStat failed: %v", err)
+	}
+	if n, err := fd.PWrite(ctx, usermem.BytesIOSequence(data), 0, vfs.WriteOptions{}); err != nil || n != int64(len(data)) {
+		t.Errorf("fd.PWrite after truncate got (%d, %v), want (%d, nil)", n, err, len(data))
+	}
+}
+
+func TestInodeLimit(t *testing.T) {
+	ctx := contexttest.Context(t)
+	creds := auth.CredentialsFromContext(ctx)
+	// The root directory takes one of the inodes.
+	vfsObj, root, cleanup, err := newTmpfsRootWithData(ctx, "nr_inodes=3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cleanup()
+
+	pop := func(name string) *vfs.PathOperation {
+		return &vfs.PathOperation{
+			Root:  root,
+			Start: root,
+			Path:  fspath.Parse(name),
+		}
+	}
+	if err := vfsObj.MkdirAt(ctx, creds, pop("dir"), &vfs.MkdirOptions{Mode: 0755}); err != nil {
+		t.Fatalf("MkdirAt failed: %v", err)
+	}
+	fd, err := vfsObj.OpenAt(ctx, creds, pop("file"), &vfs.OpenOptions{
+		Flags: linux.O_RDWR | linux.O_CREAT | linux.O_EXCL,
+		Mode:  linux.ModeRegular | 0644,
+	})
+	if err != nil {
+		t.Fatalf("OpenAt failed: %v", err)
+	}
+	defer fd.DecRef(ctx)
+	statfs, err := fd.StatFS(ctx)
+	if err != nil {
+		t.Fatalf("fd.StatFS failed: %v", err)
+	}
+	if statfs.Files != 3 || statfs.FilesFree != 0 {
+		t.Errorf("fd.StatFS got files: %d, free: %d, want files: 3, free: 0", statfs.Files, statfs.FilesFree)
+	}
+
+	// Creating files past the limit fails, but hard links don't take inodes.
+	if _, err := vfsObj.OpenAt(ctx, creds, pop("file2"), &vfs.OpenOptions{
+		Flags: linux.O_RDWR | linux.O_CREAT | linux.O_EXCL,
+		Mode:  linux.ModeRegular | 0644,
+	}); !linuxerr.Equals(linuxerr.ENOSPC, err) {
+		t.Errorf("OpenAt past inode limit got err %v, want ENOSPC", err)
+	}
+	if err := vfsObj.MkdirAt(ctx, creds, pop("dir2"), &vfs.MkdirOptions{Mode: 0755}); !linuxerr.Equals(linuxerr.ENOSPC, err) {
+		t.Errorf("MkdirAt past inode limit got err %v, want ENOSPC", err)
+	}
+	if err := vfsObj.SymlinkAt(ctx, creds, pop("symlink"), "file"); !linuxerr.Equals(linuxerr.ENOSPC, err) {
+		t.Errorf("SymlinkAt past inode limit got err %v, want ENOSPC", err)
+	}
+	if err := vfsObj.LinkAt(ctx, creds, pop("file"), pop("link")); err != nil {
+		t.Errorf("LinkAt failed: %v", err)
+	}
+
+	// Removing a file releases its inode.
+	if err := vfsObj.RmdirAt(ctx, creds, pop("dir")); err != nil {
+		t.Fatalf("RmdirAt failed: %v", err)
+	}
+	if err := vfsObj.MkdirAt(ctx, creds, pop("dir2"), &vfs.MkdirOptions{Mode: 0755}); err != nil {
+		t.Errorf("MkdirAt after rmdir failed: %v", err)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want uint64
+		err  bool
+	}{
+		{in: "4096", want: 4096},
+		{in: "64k", want: 64 << 10},
+		{in: "10M", want: 10 << 20},
+		{in: "1g", want: 1 << 30},
+		{in: "", err: true},
+		{in: "k", err: true},
+		{in: "-1", err: true},
+		{in: "1t", err: true},
+	} {
+		got, err := parseSize(tc.in)
+		if tc.err {
+			if err == nil {
+				t.Errorf("parseSize(%q) = %d, want error", tc.in, got)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("parseSize(%q) = (%d, %v), want (%d, nil)", tc.in, got, err, tc.want)
+		}
+	}
+}
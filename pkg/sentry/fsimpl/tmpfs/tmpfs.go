@@ -79,6 +79,24 @@ type filesystem struct {
 	// files in this filesystem are accounted.
 	usage usage.MemoryKind
 
+	// maxSizeInPages is the maximum number of pages that may be allocated to
+	// store regular file contents in this filesystem, or 0 if unlimited.
+	// maxSizeInPages is immutable.
+	maxSizeInPages uint64
+
+	// pagesUsed is the number of pages currently allocated to store regular
+	// file contents in this filesystem. pagesUsed is accessed using atomic
+	// memory operations.
+	pagesUsed uint64
+
+	// maxInodes is the maximum number of inodes in this filesystem, or 0 if
+	// unlimited. maxInodes is immutable.
+	maxInodes uint64
+
+	// inodesUsed is the number of inodes currently in this filesystem.
+	// inodesUsed is accessed using atomic memory operations.
+	inodesUsed uint64
+
 	// mu serializes changes to the Dentry tree.
 	mu sync.RWMutex `state:"nosave"`
 
@@ -183,6 +201,30 @@ func (fstype FilesystemType) GetFilesystem(ctx context.Context, vfsObj *vfs.Virt
 		}
 		rootKGID = kgid
 	}
+	var maxSizeInPages uint64
+	sizeStr, ok := mopts["size"]
+	if ok {
+		delete(mopts, "size")
+		size, err := parseSize(sizeStr)
+		if err != nil {
+			ctx.Warningf("tmpfs.FilesystemType.GetFilesystem: invalid size: %q", sizeStr)
+			return nil, nil, linuxerr.EINVAL
+		}
+		// Round up to a whole number of pages, like Linux.
+		maxSizeInPages = (size + hostarch.PageSize - 1) / hostarch.PageSize
+	}
+	var maxInodes uint64
+	inodesStr, ok := mopts["nr_inodes"]
+	if ok {
+		delete(mopts, "nr_inodes")
+		// Like Linux, nr_inodes accepts the same suffixes as size.
+		n, err := parseSize(inodesStr)
+		if err != nil {
+			ctx.Warningf("tmpfs.FilesystemType.GetFilesystem: invalid nr_inodes: %q", inodesStr)
+			return nil, nil, linuxerr.EINVAL
+		}
+		maxInodes = n
+	}
 	if len(mopts) != 0 {
 		ctx.Warningf("tmpfs.FilesystemType.GetFilesystem: unknown options: %v", mopts)
 		return nil, nil, linuxerr.EINVAL
@@ -198,11 +240,13 @@ func (fstype FilesystemType) GetFilesystem(ctx context.Context, vfsObj *vfs.Virt
 		memUsage = *tmpfsOpts.Usage
 	}
 	fs := filesystem{
-		mfp:      mfp,
-		clock:    clock,
-		devMinor: devMinor,
-		mopts:    opts.Data,
-		usage:    memUsage,
+		mfp:            mfp,
+		clock:          clock,
+		devMinor:       devMinor,
+		mopts:          opts.Data,
+		usage:          memUsage,
+		maxSizeInPages: maxSizeInPages,
+		maxInodes:      maxInodes,
 	}
 	fs.vfsfs.Init(vfsObj, newFSType, &fs)
 
@@ -222,6 +266,34 @@ func (fstype FilesystemType) GetFilesystem(ctx context.Context, vfsObj *vfs.Virt
 	return &fs.vfsfs, &root.vfsd, nil
 }
 
+// parseSize parses the value of the "size" mount option, which is a number of
+// bytes with an optional k, m or g suffix.
+func parseSize(s string) (uint64, error) {
+	if len(s) == 0 {
+		return 0, fmt.Errorf("empty size")
+	}
+	shift := 0
+	switch s[len(s)-1] {
+	case 'k', 'K':
+		shift = 10
+	case 'm', 'M':
+		shift = 20
+	case 'g', 'G':
+		shift = 30
+	}
+	if shift != 0 {
+		s = s[:len(s)-1]
+	}
+	size, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if size > math.MaxInt64>>shift {
+		return 0, fmt.Errorf("size too large: %s", s)
+	}
+	return size << shift, nil
+}
+
 // NewFilesystem returns a new tmpfs filesystem.
 func NewFilesystem(ctx context.Context, vfsObj *vfs.VirtualFilesystem, creds *auth.Credentials) (*vfs.Filesystem, *vfs.Dentry, error) {
 	return FilesystemType{}.GetFilesystem(ctx, vfsObj, creds, "", vfs.GetFilesystemOptions{})
@@ -237,6 +309,68 @@ func (fs *filesystem) Release(ctx context.Context) {
 	fs.mu.Unlock()
 }
 
+// accountPages charges the given number of pages to the filesystem's size
+// limit. It returns false if doing so would exceed the limit.
+func (fs *filesystem) accountPages(pages uint64) bool {
+	if pages == 0 {
+		return true
+	}
+	if fs.maxSizeInPages == 0 {
+		atomic.AddUint64(&fs.pagesUsed, pages)
+		return true
+	}
+	for {
+		used := atomic.LoadUint64(&fs.pagesUsed)
+		if used+pages > fs.maxSizeInPages {
+			return false
+		}
+		if atomic.CompareAndSwapUint64(&fs.pagesUsed, used, used+pages) {
+			return true
+		}
+	}
+}
+
+// unaccountPages releases pages previously charged by accountPages.
+func (fs *filesystem) unaccountPages(pages uint64) {
+	if pages == 0 {
+		return
+	}
+	atomic.AddUint64(&fs.pagesUsed, ^(pages - 1))
+}
+
+// hasFreeInode returns true if a new inode may be created without exceeding
+// the filesystem's inode limit.
+//
+// Preconditions: filesystem.mu must be locked for writing, such that the inode
+// is created before other inodes may be.
+func (fs *filesystem) hasFreeInode() bool {
+	return fs.maxInodes == 0 || atomic.LoadUint64(&fs.inodesUsed) < fs.maxInodes
+}
+
+// statFS returns the statfs(2) information for the filesystem.
+func (fs *filesystem) statFS() linux.Statfs {
+	st := globalStatfs
+	used := atomic.LoadUint64(&fs.pagesUsed)
+	if fs.maxSizeInPages != 0 {
+		st.Blocks = fs.maxSizeInPages
+	}
+	st.BlocksFree = 0
+	if used < st.Blocks {
+		st.BlocksFree = st.Blocks - used
+	}
+	st.BlocksAvailable = st.BlocksFree
+	if fs.maxInodes != 0 {
+		// Without a limit, report no inodes like Linux does.
+		inodes := atomic.LoadUint64(&fs.inodesUsed)
+		st.Files = fs.maxInodes
+		st.FilesFree = 0
+		if inodes < fs.maxInodes {
+			st.FilesFree = fs.maxInodes - inodes
+		}
+	}
+	return st
+}
+
 // releaseChildrenLocked is called on the mount point by filesystem.Release() to
 // destroy all objects in the mount. It performs a depth-first walk of the
 // filesystem and "unlinks" everything by decrementing link counts
@@ -270,13 +404,13 @@ var globalStatfs = linux.Statfs{
 	FragmentSize: hostarch.PageSize,
 	NameLength:   linux.NAME_MAX,
 
-	// tmpfs currently does not support configurable size limits. In Linux,
-	// such a tmpfs mount will return f_blocks == f_bfree == f_bavail == 0 from
-	// statfs(2). However, many applications treat this as having a size limit
-	// of 0. To work around this, claim to have a very large but non-zero size,
-	// chosen to ensure that BlockSize * Blocks does not overflow int64 (which
-	// applications may also handle incorrectly).
-	// TODO(b/29637826): allow configuring a tmpfs size and enforce it.
+	// In Linux, a tmpfs mount without a size limit will return f_blocks ==
+	// f_bfree == f_bavail == 0 from statfs(2). However, many applications
+	// treat this as having a size limit of 0. To work around this, claim to
+	// have a very large but non-zero size, chosen to ensure that BlockSize *
+	// Blocks does not overflow int64 (which applications may also handle
+	// incorrectly). Mounts with the "size" option report their actual limit,
+	// and all mounts report the blocks used, see filesystem.statFS.
 	Blocks:          math.MaxInt64 / hostarch.PageSize,
 	BlocksFree:      math.MaxInt64 / hostarch.PageSize,
 	BlocksAvailable: math.MaxInt64 / hostarch.PageSize,
@@ -422,6 +556,7 @@ func (i *inode) init(impl interface{}, fs *filesystem, kuid auth.KUID, kgid auth
 	i.uid = uint32(kuid)
 	i.gid = uint32(kgid)
 	i.ino = atomic.AddUint64(&fs.nextInoMinusOne, 1)
+	atomic.AddUint64(&fs.inodesUsed, 1)
 	// Tmpfs creation sets atime, ctime, and mtime to current time.
 	now := fs.clock.Now().Nanoseconds()
 	i.atime = now
@@ -474,10 +609,12 @@ func (i *inode) tryIncRef() bool {
 func (i *inode) decRef(ctx context.Context) {
 	i.refs.DecRef(func() {
 		i.watches.HandleDeletion(ctx)
+		atomic.AddUint64(&i.fs.inodesUsed, ^uint64(0))
 		if regFile, ok := i.impl.(*regularFile); ok {
 			// Release memory used by regFile to store data. Since regFile is
 			// no longer usable, we don't need to grab any locks or update any
 			// metadata.
+			i.fs.unaccountPages(regFile.data.Span() / hostarch.PageSize)
 			regFile.data.DropAll(regFile.memFile)
 		}
 	})
@@ -835,7 +972,7 @@ func (fd *fileDescription) SetStat(ctx context.Context, opts vfs.SetStatOptions)
 
 // StatFS implements vfs.FileDescriptionImpl.StatFS.
 func (fd *fileDescription) StatFS(ctx context.Context) (linux.Statfs, error) {
-	return globalStatfs, nil
+	return fd.filesystem().statFS(), nil
 }
 
 // ListXattr implements vfs.FileDescriptionImpl.ListXattr.
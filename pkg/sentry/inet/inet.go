@@ -78,9 +78,40 @@ type Stack interface {
 	// RouteTable returns the network stack's route table.
 	RouteTable() []Route
 
+	// AddRoute adds a route to the network stack's route table, or replaces
+	// a route with the same destination, according to opts.
+	AddRoute(route Route, opts AddRouteOptions) error
+
+	// RemoveRoute removes the routes matching route from the network stack's
+	// route table.
+	RemoveRoute(route Route) error
+
+	// Neighbors returns the entries of the network stack's neighbor tables.
+	Neighbors() []Neighbor
+
+	// AddNeighbor adds a permanent entry to the network stack's neighbor
+	// table, replacing any existing entry for the same address.
+	AddNeighbor(neigh Neighbor) error
+
+	// RemoveNeighbor removes an entry from the network stack's neighbor
+	// table.
+	RemoveNeighbor(neigh Neighbor) error
+
 	// Resume restarts the network stack after restore.
 	Resume()
 
+	// PrepareResumableSave prepares the network stack to be saved while it
+	// keeps running after the save.
+	PrepareResumableSave()
+
+	// ResumeAfterSave restarts the network stack after a save prepared by
+	// PrepareResumableSave.
+	ResumeAfterSave()
+
+	// AbortSave undoes what can be undone of the changes made to the network
+	// stack by a save that failed, since the sandbox keeps running.
+	AbortSave()
+
 	// RegisteredEndpoints returns all endpoints which are currently registered.
 	RegisteredEndpoints() []stack.TransportEndpoint
 
@@ -101,6 +132,14 @@ type Stack interface {
 	// SetPortRange sets the UDP and TCP IPv4 and IPv6 ephemeral port range
 	// (inclusive).
 	SetPortRange(start uint16, end uint16) error
+
+	// ReservedPorts returns the ports that are excluded from the ephemeral
+	// port range, in ascending order.
+	ReservedPorts() []uint16
+
+	// SetReservedPorts sets the ports that are excluded from the ephemeral
+	// port range. They can still be bound explicitly.
+	SetReservedPorts(ports []uint16) error
 }
 
 // Interface contains information about a network interface.
@@ -137,6 +176,25 @@ type InterfaceAddr struct {
 	Addr []byte
 }
 
+// Neighbor contains information about a neighbor table entry, i.e. an
+// association between a network address and a link address.
+type Neighbor struct {
+	// Family is the address family, a Linux AF_* constant.
+	Family uint8
+
+	// Index is the index of the network interface.
+	Index int32
+
+	// State is the entry state, a Linux NUD_* constant.
+	State uint16
+
+	// Addr is the network address (NDA_DST).
+	Addr []byte
+
+	// LinkAddr is the link address (NDA_LLADDR).
+	LinkAddr []byte
+}
+
 // TCPBufferSize contains settings controlling TCP buffer sizing.
 //
 // +stateify savable
@@ -197,6 +255,20 @@ type Route struct {
 	GatewayAddr []byte
 }
 
+// AddRouteOptions are options for Stack.AddRoute, which follow the
+// NLM_F_REPLACE, NLM_F_CREATE and NLM_F_EXCL flags of RTM_NEWROUTE.
+type AddRouteOptions struct {
+	// Replace replaces the first route with the same destination, if any.
+	Replace bool
+
+	// Create allows adding the route when it doesn't replace another route.
+	Create bool
+
+	// Exclusive fails with EEXIST if a route with the same destination
+	// exists.
+	Exclusive bool
+}
+
 // Below SNMP metrics are from Linux/usr/include/linux/snmp.h.
 
 // StatSNMPIP describes Ip line of /proc/net/snmp.
@@ -14,6 +14,12 @@
 
 package inet
 
+import "sync/atomic"
+
+// DefaultSomaxconn is the default value of net.core.somaxconn, the maximum
+// listen backlog.
+const DefaultSomaxconn = 1024
+
 // Namespace represents a network namespace. See network_namespaces(7).
 //
 // +stateify savable
@@ -30,6 +36,10 @@ type Namespace struct {
 
 	// isRoot indicates whether this is the root network namespace.
 	isRoot bool
+
+	// somaxconn is the maximum listen backlog, equivalent to the
+	// net.core.somaxconn sysctl. Accessed atomically.
+	somaxconn uint32
 }
 
 // NewRootNamespace creates the root network namespace, with creator
@@ -37,16 +47,18 @@ type Namespace struct {
 // networking will function if the network is namespaced.
 func NewRootNamespace(stack Stack, creator NetworkStackCreator) *Namespace {
 	return &Namespace{
-		stack:   stack,
-		creator: creator,
-		isRoot:  true,
+		stack:     stack,
+		creator:   creator,
+		isRoot:    true,
+		somaxconn: DefaultSomaxconn,
 	}
 }
 
 // NewNamespace creates a new network namespace from the root.
 func NewNamespace(root *Namespace) *Namespace {
 	n := &Namespace{
-		creator: root.creator,
+		creator:   root.creator,
+		somaxconn: DefaultSomaxconn,
 	}
 	n.init()
 	return n
@@ -63,6 +75,16 @@ func (n *Namespace) IsRoot() bool {
 	return n.isRoot
 }
 
+// Somaxconn returns the maximum listen backlog of sockets in n.
+func (n *Namespace) Somaxconn() uint32 {
+	return atomic.LoadUint32(&n.somaxconn)
+}
+
+// SetSomaxconn sets the maximum listen backlog of sockets in n.
+func (n *Namespace) SetSomaxconn(v uint32) {
+	atomic.StoreUint32(&n.somaxconn, v)
+}
+
 // RestoreRootStack restores the root network namespace with stack. This should
 // only be called when restoring kernel.
 func (n *Namespace) RestoreRootStack(stack Stack) {
@@ -17,6 +17,7 @@ package inet
 import (
 	"bytes"
 	"fmt"
+	"reflect"
 
 	"gvisor.dev/gvisor/pkg/tcpip"
 	"gvisor.dev/gvisor/pkg/tcpip/stack"
@@ -27,12 +28,14 @@ type TestStack struct {
 	InterfacesMap     map[int32]Interface
 	InterfaceAddrsMap map[int32][]InterfaceAddr
 	RouteList         []Route
+	NeighborList      []Neighbor
 	SupportsIPv6Flag  bool
 	TCPRecvBufSize    TCPBufferSize
 	TCPSendBufSize    TCPBufferSize
 	TCPSACKFlag       bool
 	Recovery          TCPLossRecovery
 	IPForwarding      bool
+	ReservedPortList  []uint16
 }
 
 // NewTestStack returns a TestStack with no network interfaces. The value of
@@ -144,9 +147,59 @@ func (s *TestStack) RouteTable() []Route {
 	return s.RouteList
 }
 
+// AddRoute implements Stack.
+func (s *TestStack) AddRoute(route Route, _ AddRouteOptions) error {
+	s.RouteList = append(s.RouteList, route)
+	return nil
+}
+
+// RemoveRoute implements Stack.
+func (s *TestStack) RemoveRoute(route Route) error {
+	var filteredRoutes []Route
+	for _, rt := range s.RouteList {
+		if !reflect.DeepEqual(rt, route) {
+			filteredRoutes = append(filteredRoutes, rt)
+		}
+	}
+	s.RouteList = filteredRoutes
+	return nil
+}
+
+// Neighbors implements Stack.
+func (s *TestStack) Neighbors() []Neighbor {
+	return s.NeighborList
+}
+
+// AddNeighbor implements Stack.
+func (s *TestStack) AddNeighbor(neigh Neighbor) error {
+	s.NeighborList = append(s.NeighborList, neigh)
+	return nil
+}
+
+// RemoveNeighbor implements Stack.
+func (s *TestStack) RemoveNeighbor(neigh Neighbor) error {
+	var filteredNeighbors []Neighbor
+	for _, n := range s.NeighborList {
+		if n.Index != neigh.Index || !bytes.Equal(n.Addr, neigh.Addr) {
+			filteredNeighbors = append(filteredNeighbors, n)
+		}
+	}
+	s.NeighborList = filteredNeighbors
+	return nil
+}
+
 // Resume implements Stack.
 func (s *TestStack) Resume() {}
 
+// PrepareResumableSave implements Stack.
+func (s *TestStack) PrepareResumableSave() {}
+
+// ResumeAfterSave implements Stack.
+func (s *TestStack) ResumeAfterSave() {}
+
+// AbortSave implements Stack.
+func (s *TestStack) AbortSave() {}
+
 // RegisteredEndpoints implements Stack.
 func (s *TestStack) RegisteredEndpoints() []stack.TransportEndpoint {
 	return nil
@@ -177,3 +230,14 @@ func (*TestStack) SetPortRange(start uint16, end uint16) error {
 	// No-op.
 	return nil
 }
+
+// ReservedPorts implements Stack.
+func (s *TestStack) ReservedPorts() []uint16 {
+	return s.ReservedPortList
+}
+
+// SetReservedPorts implements Stack.
+func (s *TestStack) SetReservedPorts(ports []uint16) error {
+	s.ReservedPortList = ports
+	return nil
+}
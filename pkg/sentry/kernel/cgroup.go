@@ -18,6 +18,7 @@ import (
 	"bytes"
 	"fmt"
 	"sort"
+	"strings"
 	"sync/atomic"
 
 	"gvisor.dev/gvisor/pkg/context"
@@ -32,6 +33,11 @@ const InvalidCgroupHierarchyID uint32 = 0
 // CgroupControllerType is the name of a cgroup controller.
 type CgroupControllerType string
 
+// NamedCgroupHierarchyPrefix is the prefix of pseudo controller types that
+// represent named hierarchies, e.g. "name=systemd". Named hierarchies have no
+// real controllers.
+const NamedCgroupHierarchyPrefix = "name="
+
 // CgroupController is the common interface to cgroup controllers available to
 // the entire sentry. The controllers themselves are defined by cgroupfs.
 //
@@ -305,6 +311,10 @@ func (r *CgroupRegistry) GenerateProcCgroups(buf *bytes.Buffer) {
 	r.mu.Lock()
 	entries := make([]string, 0, len(r.controllers))
 	for _, c := range r.controllers {
+		if strings.HasPrefix(string(c.Type()), NamedCgroupHierarchyPrefix) {
+			// Like Linux, named hierarchies aren't listed.
+			continue
+		}
 		en := 0
 		if c.Enabled() {
 			en = 1
@@ -102,6 +102,21 @@ func (a *FileAsync) NotifyEvent(mask waiter.EventMask) {
 	if !a.registered {
 		return
 	}
+	a.sendSignalLocked(mask)
+}
+
+// NotifyDir implements vfs.DirNotifier.NotifyDir. Directory notifications are
+// signaled whether or not the file is registered for I/O events.
+func (a *FileAsync) NotifyDir() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.sendSignalLocked(waiter.ReadableEvents)
+}
+
+// sendSignalLocked sends the signal to the recipient for the events in mask.
+//
+// Preconditions: a.mu must be locked.
+func (a *FileAsync) sendSignalLocked(mask waiter.EventMask) {
 	t := a.recipientT
 	tg := a.recipientTG
 	if a.recipientPG != nil {
@@ -87,6 +87,12 @@ type FDTable struct {
 	// fdBitmap shows which fds are already in use.
 	fdBitmap bitmap.Bitmap `state:"nosave"`
 
+	// next is a hint for the lowest free fd: all fds less than next are in
+	// use. This allows the lowest free fd to be found without scanning the
+	// beginning of fdBitmap in tables with many fds, as with Linux's
+	// files_struct::next_fd.
+	next int32 `state:"nosave"`
+
 	// descriptorTable holds descriptors.
 	descriptorTable `state:".(map[int32]descriptor)"`
 }
@@ -278,41 +284,22 @@ func (f *FDTable) NewFDs(ctx context.Context, minFD int32, files []*fs.File, fla
 
 	f.mu.Lock()
 
-	// max is used as the largest number in fdBitmap + 1.
-	max := int32(0)
-
-	if !f.fdBitmap.IsEmpty() {
-		max = int32(f.fdBitmap.Maximum())
-		max++
-	}
-
-	// Adjust max in case it is less than minFD.
-	if max < minFD {
-		max = minFD
-	}
 	// Install all entries.
 	for len(fds) < len(files) {
-		// Try to use free bit in fdBitmap.
-		// If all bits in fdBitmap are used, expand fd to the max.
-		fd := f.fdBitmap.FirstZero(uint32(minFD))
-		if fd == math.MaxInt32 {
-			fd = uint32(max)
-			max++
-		}
-		if fd >= uint32(end) {
+		fd, ok := f.allocFDLocked(minFD, end)
+		if !ok {
 			break
 		}
-		f.fdBitmap.Add(fd)
-		f.set(ctx, int32(fd), files[len(fds)], flags)
-		fds = append(fds, int32(fd))
-		minFD = int32(fd)
+		f.set(ctx, fd, files[len(fds)], flags)
+		fds = append(fds, fd)
+		minFD = fd
 	}
 
 	// Failure? Unwind existing FDs.
 	if len(fds) < len(files) {
 		for _, i := range fds {
 			f.set(ctx, i, nil, FDFlags{})
-			f.fdBitmap.Remove(uint32(i))
+			f.freeFDLocked(i)
 		}
 		f.mu.Unlock()
 
@@ -355,40 +342,20 @@ func (f *FDTable) NewFDsVFS2(ctx context.Context, minFD int32, files []*vfs.File
 
 	f.mu.Lock()
 
-	// max is used as the largest number in fdBitmap + 1.
-	max := int32(0)
-
-	if !f.fdBitmap.IsEmpty() {
-		max = int32(f.fdBitmap.Maximum())
-		max++
-	}
-
-	// Adjust max in case it is less than minFD.
-	if max < minFD {
-		max = minFD
-	}
-
 	for len(fds) < len(files) {
-		// Try to use free bit in fdBitmap.
-		// If all bits in fdBitmap are used, expand fd to the max.
-		fd := f.fdBitmap.FirstZero(uint32(minFD))
-		if fd == math.MaxInt32 {
-			fd = uint32(max)
-			max++
-		}
-		if fd >= uint32(end) {
+		fd, ok := f.allocFDLocked(minFD, end)
+		if !ok {
 			break
 		}
-		f.fdBitmap.Add(fd)
-		f.setVFS2(ctx, int32(fd), files[len(fds)], flags)
-		fds = append(fds, int32(fd))
-		minFD = int32(fd)
+		f.setVFS2(ctx, fd, files[len(fds)], flags)
+		fds = append(fds, fd)
+		minFD = fd
 	}
 	// Failure? Unwind existing FDs.
 	if len(fds) < len(files) {
 		for _, i := range fds {
 			f.setVFS2(ctx, i, nil, FDFlags{})
-			f.fdBitmap.Remove(uint32(i))
+			f.freeFDLocked(i)
 		}
 		f.mu.Unlock()
 
@@ -406,6 +373,44 @@ func (f *FDTable) NewFDsVFS2(ctx context.Context, minFD int32, files []*vfs.File
 	return fds, nil
 }
 
+// allocFDLocked marks the lowest free fd greater than or equal to minFD as
+// used and returns it, or returns false if that fd isn't less than end.
+//
+// Precondition: mu must be held.
+func (f *FDTable) allocFDLocked(minFD, end int32) (int32, bool) {
+	start := minFD
+	if start < f.next {
+		start = f.next
+	}
+	fd := f.fdBitmap.FirstZero(uint32(start))
+	if fd == math.MaxInt32 {
+		// All fds from start to the end of fdBitmap are in use.
+		fd = f.fdBitmap.Size()
+		if fd < uint32(start) {
+			fd = uint32(start)
+		}
+	}
+	if fd >= uint32(end) {
+		return 0, false
+	}
+	f.fdBitmap.Add(fd)
+	if start == f.next {
+		// All fds in [f.next, fd] are now in use.
+		f.next = int32(fd) + 1
+	}
+	return int32(fd), true
+}
+
+// freeFDLocked marks fd as free.
+//
+// Precondition: mu must be held.
+func (f *FDTable) freeFDLocked(fd int32) {
+	f.fdBitmap.Remove(uint32(fd))
+	if fd < f.next {
+		f.next = fd
+	}
+}
+
 // NewFDVFS2 allocates a file descriptor greater than or equal to minFD for
 // the given file description. If it succeeds, it takes a reference on file.
 func (f *FDTable) NewFDVFS2(ctx context.Context, minFD int32, file *vfs.FileDescription, flags FDFlags) (int32, error) {
@@ -517,6 +522,23 @@ func (f *FDTable) SetFlagsVFS2(ctx context.Context, fd int32, flags FDFlags) err
 	return nil
 }
 
+// SetFlagsForRange sets the flags for all file descriptors in the range
+// [startFD, endFD].
+func (f *FDTable) SetFlagsForRange(ctx context.Context, startFD, endFD int32, flags FDFlags) error {
+	if startFD < 0 || startFD > endFD {
+		return unix.EBADF
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for fd := f.fdBitmap.FirstOne(uint32(startFD)); fd != math.MaxInt32 && fd <= uint32(endFD); fd = f.fdBitmap.FirstOne(fd + 1) {
+		file, fileVFS2, _, _ := f.getAll(int32(fd))
+		f.setAll(ctx, int32(fd), file, fileVFS2, flags)
+	}
+	return nil
+}
+
 // Get returns a reference to the file and the flags for the FD or nil if no
 // file is defined for the given fd.
 //
@@ -606,21 +628,37 @@ func (f *FDTable) Remove(ctx context.Context, fd int32) (*fs.File, *vfs.FileDesc
 	}
 
 	f.mu.Lock()
+	orig, orig2 := f.removeLocked(ctx, fd)
+	f.mu.Unlock()
 
-	orig, orig2, _, _ := f.getAll(fd)
+	if orig != nil {
+		f.drop(ctx, orig)
+	}
+	if orig2 != nil {
+		f.dropVFS2(ctx, orig2)
+	}
 
-	// Add reference for caller.
-	switch {
-	case orig != nil:
-		orig.IncRef()
-	case orig2 != nil:
-		orig2.IncRef()
+	return orig, orig2
+}
+
+// RemoveNextInRange removes the lowest FD in the range [startFD, endFD], and
+// returns it along with its file. If there is no FD in the range, it returns a
+// nil file.
+//
+// N.B. Callers are required to use DecRef on the returned file when they are
+// done.
+func (f *FDTable) RemoveNextInRange(ctx context.Context, startFD, endFD int32) (int32, *fs.File, *vfs.FileDescription) {
+	if startFD < 0 || startFD > endFD {
+		return 0, nil, nil
 	}
 
-	if orig != nil || orig2 != nil {
-		orig, orig2 = f.setAll(ctx, fd, nil, nil, FDFlags{}) // Zap entry.
-		f.fdBitmap.Remove(uint32(fd))
+	f.mu.Lock()
+	fd := f.fdBitmap.FirstOne(uint32(startFD))
+	if fd == math.MaxInt32 || fd > uint32(endFD) {
+		f.mu.Unlock()
+		return 0, nil, nil
 	}
+	orig, orig2 := f.removeLocked(ctx, int32(fd))
 	f.mu.Unlock()
 
 	if orig != nil {
@@ -630,6 +668,29 @@ func (f *FDTable) Remove(ctx context.Context, fd int32) (*fs.File, *vfs.FileDesc
 		f.dropVFS2(ctx, orig2)
 	}
 
+	return int32(fd), orig, orig2
+}
+
+// removeLocked removes fd from f, and returns its file with a reference for
+// the caller. The caller must call f.drop/dropVFS2() on the returned file
+// after unlocking f.mu.
+//
+// Precondition: mu must be held.
+func (f *FDTable) removeLocked(ctx context.Context, fd int32) (*fs.File, *vfs.FileDescription) {
+	orig, orig2, _, _ := f.getAll(fd)
+
+	// Add reference for caller.
+	switch {
+	case orig != nil:
+		orig.IncRef()
+	case orig2 != nil:
+		orig2.IncRef()
+	}
+
+	if orig != nil || orig2 != nil {
+		orig, orig2 = f.setAll(ctx, fd, nil, nil, FDFlags{}) // Zap entry.
+		f.freeFDLocked(fd)
+	}
 	return orig, orig2
 }
 
@@ -643,7 +704,7 @@ func (f *FDTable) RemoveIf(ctx context.Context, cond func(*fs.File, *vfs.FileDes
 	f.forEach(ctx, func(fd int32, file *fs.File, fileVFS2 *vfs.FileDescription, flags FDFlags) {
 		if cond(file, fileVFS2, flags) {
 			df, dfVFS2 := f.setAll(ctx, fd, nil, nil, FDFlags{}) // Clear from table.
-			f.fdBitmap.Remove(uint32(fd))
+			f.freeFDLocked(fd)
 			if df != nil {
 				files = append(files, df)
 			}
@@ -179,6 +179,85 @@ func TestDescriptorFlags(t *testing.T) {
 	})
 }
 
+// TestFDTableLowestFree makes sure that new FDs are always the lowest
+// available, regardless of the order in which FDs are removed.
+func TestFDTableLowestFree(t *testing.T) {
+	runTest(t, func(ctx context.Context, fdTable *FDTable, file *fs.File, _ *limits.LimitSet) {
+		for i := 0; i < 200; i++ {
+			if _, err := fdTable.NewFDs(ctx, 0, []*fs.File{file}, FDFlags{}); err != nil {
+				t.Fatalf("fdTable.NewFDs(0, r): got %v, wanted nil", err)
+			}
+		}
+		for _, fd := range []int32{150, 7, 100} {
+			if ref, _ := fdTable.Remove(ctx, fd); ref == nil {
+				t.Fatalf("fdTable.Remove(%d) for an existing FD: failed, want success", fd)
+			} else {
+				ref.DecRef(ctx)
+			}
+		}
+		for _, want := range []int32{7, 100, 150, 200, 201} {
+			if fds, err := fdTable.NewFDs(ctx, 0, []*fs.File{file}, FDFlags{}); err != nil || fds[0] != want {
+				t.Fatalf("fdTable.NewFDs(0, r): got %v, %v, wanted %d", fds, err, want)
+			}
+		}
+		if fds, err := fdTable.NewFDs(ctx, 1000, []*fs.File{file}, FDFlags{}); err != nil || fds[0] != 1000 {
+			t.Fatalf("fdTable.NewFDs(1000, r): got %v, %v, wanted 1000", fds, err)
+		}
+		if fds, err := fdTable.NewFDs(ctx, 0, []*fs.File{file}, FDFlags{}); err != nil || fds[0] != 202 {
+			t.Fatalf("fdTable.NewFDs(0, r): got %v, %v, wanted 202", fds, err)
+		}
+	})
+}
+
+func TestFDTableRemoveNextInRange(t *testing.T) {
+	runTest(t, func(ctx context.Context, fdTable *FDTable, file *fs.File, _ *limits.LimitSet) {
+		for _, fd := range []int32{1, 3, 5, 7} {
+			if err := fdTable.NewFDAt(ctx, fd, file, FDFlags{}); err != nil {
+				t.Fatalf("fdTable.NewFDAt(%d, r, FDFlags{}): got %v, wanted nil", fd, err)
+			}
+		}
+		var removed []int32
+		for start := int32(2); ; {
+			fd, ref, _ := fdTable.RemoveNextInRange(ctx, start, 6)
+			if ref == nil {
+				break
+			}
+			ref.DecRef(ctx)
+			removed = append(removed, fd)
+			start = fd + 1
+		}
+		if len(removed) != 2 || removed[0] != 3 || removed[1] != 5 {
+			t.Fatalf("fdTable.RemoveNextInRange(2, 6) removed %v, wanted [3 5]", removed)
+		}
+		if got := fdTable.GetFDs(ctx); len(got) != 2 || got[0] != 1 || got[1] != 7 {
+			t.Fatalf("fdTable.GetFDs(): got %v, wanted [1 7]", got)
+		}
+	})
+}
+
+func TestFDTableSetFlagsForRange(t *testing.T) {
+	runTest(t, func(ctx context.Context, fdTable *FDTable, file *fs.File, _ *limits.LimitSet) {
+		for _, fd := range []int32{1, 3, 5} {
+			if err := fdTable.NewFDAt(ctx, fd, file, FDFlags{}); err != nil {
+				t.Fatalf("fdTable.NewFDAt(%d, r, FDFlags{}): got %v, wanted nil", fd, err)
+			}
+		}
+		if err := fdTable.SetFlagsForRange(ctx, 2, 5, FDFlags{CloseOnExec: true}); err != nil {
+			t.Fatalf("fdTable.SetFlagsForRange(2, 5): got %v, wanted nil", err)
+		}
+		for fd, want := range map[int32]bool{1: false, 3: true, 5: true} {
+			ref, flags := fdTable.Get(fd)
+			if ref == nil {
+				t.Fatalf("fdTable.Get(%d): got nil, wanted %v", fd, file)
+			}
+			ref.DecRef(ctx)
+			if flags.CloseOnExec != want {
+				t.Errorf("fdTable.Get(%d): got CloseOnExec %v, wanted %v", fd, flags.CloseOnExec, want)
+			}
+		}
+	})
+}
+
 func BenchmarkFDLookupAndDecRef(b *testing.B) {
 	b.StopTimer() // Setup.
 
@@ -635,9 +635,8 @@ func (m *Manager) WaitComplete(w *Waiter, t Target) {
 // calling task is set to 'addr' to indicate the futex is owned. It returns true
 // if the futex was successfully acquired.
 //
-// FUTEX_OWNER_DIED is only set by the Linux when robust lists are in use (see
-// exit_robust_list()). Given we don't support robust lists, although handled
-// below, it's never set.
+// FUTEX_OWNER_DIED is only set when robust lists are in use (see
+// HandleOwnerDeathPI).
 func (m *Manager) LockPI(w *Waiter, t Target, addr hostarch.Addr, tid uint32, private, try bool) (bool, error) {
 	k, err := getKey(t, addr, private)
 	if err != nil {
@@ -755,21 +754,7 @@ func (m *Manager) unlockPILocked(t Target, addr hostarch.Addr, tid uint32, b *bu
 		return linuxerr.EPERM
 	}
 
-	var next *Waiter  // Who's the next owner?
-	var next2 *Waiter // Who's the one after that?
-	for w := b.waiters.Front(); w != nil; w = w.Next() {
-		if !w.key.matches(key) {
-			continue
-		}
-
-		if next == nil {
-			next = w
-		} else {
-			next2 = w
-			break
-		}
-	}
-
+	next, next2 := nextPIWaitersLocked(b, key)
 	if next == nil {
 		// It's safe to set 0 because there are no waiters, no new owner, and the
 		// executing task is the current owner (no owner died bit).
@@ -803,3 +788,83 @@ func (m *Manager) unlockPILocked(t Target, addr hostarch.Addr, tid uint32, b *bu
 	b.wakeWaiterLocked(next)
 	return nil
 }
+
+// HandleOwnerDeathPI is called when tid, the owner of the PI futex at addr,
+// exits without unlocking it. If there are waiters, ownership is handed to the
+// next waiter (FIFO), which is woken up. Otherwise the futex is left unowned.
+// In both cases FUTEX_OWNER_DIED is set, so that the next owner knows that the
+// state protected by the futex may be inconsistent.
+//
+// This corresponds to Linux's handling of PI futexes in exit_robust_list() and
+// exit_pi_state_list().
+func (m *Manager) HandleOwnerDeathPI(t Target, addr hostarch.Addr, tid uint32, private bool) error {
+	k, err := getKey(t, addr, private)
+	if err != nil {
+		return err
+	}
+	b := m.lockBucket(&k)
+
+	err = m.handleOwnerDeathPILocked(t, addr, tid, b, &k)
+
+	k.release(t)
+	b.mu.Unlock()
+	return err
+}
+
+func (m *Manager) handleOwnerDeathPILocked(t Target, addr hostarch.Addr, tid uint32, b *bucket, key *Key) error {
+	next, next2 := nextPIWaitersLocked(b, key)
+	for {
+		cur, err := t.LoadUint32(addr)
+		if err != nil {
+			return err
+		}
+		if (cur & linux.FUTEX_TID_MASK) != tid {
+			// Someone else owns the futex now.
+			return nil
+		}
+
+		val := uint32(linux.FUTEX_OWNER_DIED)
+		if next == nil {
+			// Preserve the waiters bit, since there may be waiters that we
+			// can't see (e.g. using a different kind of key).
+			val |= cur & linux.FUTEX_WAITERS
+		} else {
+			val |= next.tid
+			if next2 != nil {
+				val |= linux.FUTEX_WAITERS
+			}
+		}
+
+		prev, err := t.CompareAndSwapUint32(addr, cur, val)
+		if err != nil {
+			return err
+		}
+		if prev != cur {
+			// The waiters bit may have been set concurrently by the
+			// application, retry.
+			continue
+		}
+		if next != nil {
+			b.wakeWaiterLocked(next)
+		}
+		return nil
+	}
+}
+
+// nextPIWaitersLocked returns the first two waiters in b that are waiting on
+// key. Either may be nil.
+func nextPIWaitersLocked(b *bucket, key *Key) (next, next2 *Waiter) {
+	for w := b.waiters.Front(); w != nil; w = w.Next() {
+		if !w.key.matches(key) {
+			continue
+		}
+
+		if next == nil {
+			next = w
+		} else {
+			next2 = w
+			break
+		}
+	}
+	return next, next2
+}
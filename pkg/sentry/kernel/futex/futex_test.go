@@ -21,6 +21,7 @@ import (
 	"testing"
 	"unsafe"
 
+	"gvisor.dev/gvisor/pkg/abi/linux"
 	"gvisor.dev/gvisor/pkg/context"
 	"gvisor.dev/gvisor/pkg/errors/linuxerr"
 	"gvisor.dev/gvisor/pkg/hostarch"
@@ -534,3 +535,50 @@ func TestMutexStress(t *testing.T) {
 		<-c
 	}
 }
+
+func TestHandleOwnerDeathPI(t *testing.T) {
+	const (
+		ownerTID  = 1
+		waiterTID = 2
+	)
+	m := NewManager()
+	d := newTestData(sizeofInt32)
+	atomic.StoreUint32((*uint32)(unsafe.Pointer(&d.data[0])), ownerTID)
+
+	// Block a waiter on the futex.
+	w := NewWaiter()
+	if locked, err := m.LockPI(w, d, 0, waiterTID, false, false); err != nil || locked {
+		t.Fatalf("LockPI: got (%t, %v), wanted (false, nil)", locked, err)
+	}
+	defer m.WaitComplete(w, d)
+	if got, want := atomic.LoadUint32((*uint32)(unsafe.Pointer(&d.data[0]))), uint32(ownerTID|linux.FUTEX_WAITERS); got != want {
+		t.Fatalf("futex value after LockPI: got %#x, wanted %#x", got, want)
+	}
+
+	// The owner dies, so ownership is handed over to the waiter.
+	if err := m.HandleOwnerDeathPI(d, 0, ownerTID, false); err != nil {
+		t.Fatalf("HandleOwnerDeathPI: %v", err)
+	}
+	if !w.woken() {
+		t.Error("waiter not woken")
+	}
+	if got, want := atomic.LoadUint32((*uint32)(unsafe.Pointer(&d.data[0]))), uint32(waiterTID|linux.FUTEX_OWNER_DIED); got != want {
+		t.Errorf("futex value after owner death: got %#x, wanted %#x", got, want)
+	}
+
+	// Now the new owner dies without waiters.
+	if err := m.HandleOwnerDeathPI(d, 0, waiterTID, false); err != nil {
+		t.Fatalf("HandleOwnerDeathPI: %v", err)
+	}
+	if got, want := atomic.LoadUint32((*uint32)(unsafe.Pointer(&d.data[0]))), uint32(linux.FUTEX_OWNER_DIED); got != want {
+		t.Errorf("futex value after second owner death: got %#x, wanted %#x", got, want)
+	}
+
+	// A task that doesn't own the futex must not change it.
+	if err := m.HandleOwnerDeathPI(d, 0, ownerTID, false); err != nil {
+		t.Fatalf("HandleOwnerDeathPI: %v", err)
+	}
+	if got, want := atomic.LoadUint32((*uint32)(unsafe.Pointer(&d.data[0]))), uint32(linux.FUTEX_OWNER_DIED); got != want {
+		t.Errorf("futex value after non-owner death: got %#x, wanted %#x", got, want)
+	}
+}
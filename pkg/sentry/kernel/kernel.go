@@ -136,6 +136,15 @@ type Kernel struct {
 	rootUTSNamespace            *UTSNamespace
 	rootIPCNamespace            *IPCNamespace
 	rootAbstractSocketNamespace *AbstractSocketNamespace
+	timerSlack                  time.Duration
+	highResolutionTimers        bool
+
+	// pressureMu protects pressure.
+	pressureMu sync.Mutex `state:"nosave"`
+
+	// pressure maps container IDs to the pressure stall information of their
+	// tasks.
+	pressure map[string]*pressureGroup
 
 	// futexes is the "root" futex.Manager, from which all others are forked.
 	// This is necessary to ensure that shared futexes are coherent across all
@@ -348,6 +357,17 @@ type InitKernelArgs struct {
 
 	// PIDNamespace is the root PID namespace.
 	PIDNamespace *PIDNamespace
+
+	// TimerSlack is the initial timer slack of tasks. The blocking timeouts
+	// of a task may expire up to its timer slack late, so that nearby
+	// expirations can be coalesced. Tasks can change their timer slack with
+	// prctl(PR_SET_TIMERSLACK).
+	TimerSlack time.Duration
+
+	// If HighResolutionTimers is true, the blocking timeouts of tasks with
+	// no timer slack expire precisely, at the cost of polling for the end of
+	// the timeout instead of sleeping for the last hrtimerPollDuration.
+	HighResolutionTimers bool
 }
 
 // Init initialize the Kernel with no tasks.
@@ -397,7 +417,10 @@ func (k *Kernel) Init(args InitKernelArgs) error {
 	}
 	k.extraAuxv = args.ExtraAuxv
 	k.vdso = args.Vdso
+	k.timerSlack = args.TimerSlack
+	k.highResolutionTimers = args.HighResolutionTimers
 	k.futexes = futex.NewManager()
+	k.pressure = make(map[string]*pressureGroup)
 	k.netlinkPorts = port.New()
 	k.ptraceExceptions = make(map[*Task]*Task)
 	k.YAMAPtraceScope = linux.YAMA_SCOPE_RELATIONAL
@@ -443,10 +466,11 @@ func (k *Kernel) Init(args InitKernelArgs) error {
 	return nil
 }
 
-// SaveTo saves the state of k to w.
+// SaveTo saves the state of k to w. mfOpts are the options used to save the
+// memory file.
 //
 // Preconditions: The kernel must be paused throughout the call to SaveTo.
-func (k *Kernel) SaveTo(ctx context.Context, w wire.Writer) error {
+func (k *Kernel) SaveTo(ctx context.Context, w wire.Writer, mfOpts pgalloc.SaveOpts) error {
 	saveStart := time.Now()
 
 	// Do not allow other Kernel methods to affect it while it's being saved.
@@ -531,7 +555,7 @@ func (k *Kernel) SaveTo(ctx context.Context, w wire.Writer) error {
 
 	// Save the memory file's state.
 	memoryStart := time.Now()
-	if err := k.mf.SaveTo(ctx, w); err != nil {
+	if err := k.mf.SaveTo(ctx, w, mfOpts); err != nil {
 		return err
 	}
 	log.Infof("Memory save took [%s].", time.Since(memoryStart))
@@ -541,6 +565,63 @@ func (k *Kernel) SaveTo(ctx context.Context, w wire.Writer) error {
 	return nil
 }
 
+// PrepareResumableSave prepares k to be saved by SaveTo while it keeps
+// running after the save, e.g. for snapshots. ResumeAfterSave must be called
+// after SaveTo, whether it succeeded or not.
+//
+// Preconditions:
+// * The kernel must be paused.
+// * VFS2 must be enabled.
+func (k *Kernel) PrepareResumableSave() {
+	for _, s := range k.networkStacks() {
+		s.PrepareResumableSave()
+	}
+}
+
+// ResumeAfterSave undoes the changes made by SaveTo to prepare k for saving,
+// after a save prepared by PrepareResumableSave.
+//
+// Preconditions:
+// * The kernel must be paused.
+// * VFS2 must be enabled.
+func (k *Kernel) ResumeAfterSave(ctx context.Context) {
+	k.vfs.ResumeAfterSave(ctx)
+	for _, s := range k.networkStacks() {
+		s.ResumeAfterSave()
+	}
+}
+
+// AbortSave undoes what can be undone of the changes made by a SaveTo that
+// failed, when k keeps running. Resumable saves are undone by
+// ResumeAfterSave instead.
+//
+// Preconditions: The kernel must be paused.
+func (k *Kernel) AbortSave() {
+	for _, s := range k.networkStacks() {
+		s.AbortSave()
+	}
+}
+
+// networkStacks returns the network stacks of the network namespaces used by
+// tasks.
+func (k *Kernel) networkStacks() []inet.Stack {
+	var stacks []inet.Stack
+	seen := make(map[*inet.Namespace]struct{})
+	k.tasks.mu.RLock()
+	defer k.tasks.mu.RUnlock()
+	k.tasks.forEachTaskLocked(func(t *Task) {
+		ns := t.NetworkNamespace()
+		if _, ok := seen[ns]; ok || ns == nil {
+			return
+		}
+		seen[ns] = struct{}{}
+		if s := ns.Stack(); s != nil {
+			stacks = append(stacks, s)
+		}
+	})
+	return stacks
+}
+
 // flushMountSourceRefs flushes the MountSources for all mounted filesystems
 // and open FDs.
 //
@@ -638,7 +719,7 @@ func (k *Kernel) invalidateUnsavableMappings(ctx context.Context) error {
 }
 
 // LoadFrom returns a new Kernel loaded from args.
-func (k *Kernel) LoadFrom(ctx context.Context, r wire.Reader, timeReady chan struct{}, net inet.Stack, clocks sentrytime.Clocks, vfsOpts *vfs.CompleteRestoreOptions) error {
+func (k *Kernel) LoadFrom(ctx context.Context, r wire.Reader, timeReady chan struct{}, net inet.Stack, clocks sentrytime.Clocks, vfsOpts *vfs.CompleteRestoreOptions, mfOpts pgalloc.LoadOpts) error {
 	loadStart := time.Now()
 
 	initAppCores := k.applicationCores
@@ -677,7 +758,7 @@ func (k *Kernel) LoadFrom(ctx context.Context, r wire.Reader, timeReady chan str
 
 	// Load the memory file's state.
 	memoryStart := time.Now()
-	if err := k.mf.LoadFrom(ctx, r); err != nil {
+	if err := k.mf.LoadFrom(ctx, r, mfOpts); err != nil {
 		return err
 	}
 	log.Infof("Memory load took [%s].", time.Since(memoryStart))
@@ -1033,6 +1114,7 @@ func (k *Kernel) CreateProcess(args CreateProcessArgs) (*ThreadGroup, ThreadID,
 		AbstractSocketNamespace: args.AbstractSocketNamespace,
 		MountNamespaceVFS2:      mntnsVFS2,
 		ContainerID:             args.ContainerID,
+		TimerSlack:              k.timerSlack,
 	}
 	t, err := k.tasks.NewTask(ctx, config)
 	if err != nil {
@@ -1345,6 +1427,32 @@ func (k *Kernel) SendContainerSignal(cid string, info *linux.SignalInfo) error {
 	return lastErr
 }
 
+// RenameContainers changes the IDs of the containers that tasks belong to, as
+// given by ids, which maps old container IDs to new ones. It's used when the
+// containers of a sandbox are restored under new IDs.
+//
+// Preconditions: Tasks must not be running, e.g. the kernel is being restored.
+func (k *Kernel) RenameContainers(ids map[string]string) {
+	k.tasks.mu.RLock()
+	defer k.tasks.mu.RUnlock()
+	for t := range k.tasks.Root.tids {
+		if id, ok := ids[t.containerID]; ok {
+			t.containerID = id
+		}
+	}
+
+	k.pressureMu.Lock()
+	defer k.pressureMu.Unlock()
+	pressure := make(map[string]*pressureGroup, len(k.pressure))
+	for cid, pg := range k.pressure {
+		if id, ok := ids[cid]; ok {
+			cid = id
+		}
+		pressure[cid] = pg
+	}
+	k.pressure = pressure
+}
+
 // RebuildTraceContexts rebuilds the trace context for all tasks.
 //
 // Unfortunately, if these are built while tracing is not enabled, then we will
@@ -1439,6 +1547,11 @@ func (k *Kernel) RealtimeClock() ktime.Clock {
 	return k.timekeeper.realtimeClock
 }
 
+// TAIClock returns the application CLOCK_TAI clock.
+func (k *Kernel) TAIClock() ktime.Clock {
+	return k.timekeeper.taiClock
+}
+
 // MonotonicClock returns the application CLOCK_MONOTONIC clock.
 func (k *Kernel) MonotonicClock() ktime.Clock {
 	return k.timekeeper.monotonicClock
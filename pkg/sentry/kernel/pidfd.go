@@ -0,0 +1,114 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kernel
+
+import (
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/context"
+	"gvisor.dev/gvisor/pkg/hostarch"
+	"gvisor.dev/gvisor/pkg/marshal/primitive"
+	"gvisor.dev/gvisor/pkg/sentry/vfs"
+	"gvisor.dev/gvisor/pkg/waiter"
+)
+
+// PIDFD implements vfs.FileDescriptionImpl for pidfds, which refer to a
+// thread group (process).
+//
+// A pidfd is readable once all tasks in its thread group have exited.
+//
+// +stateify savable
+type PIDFD struct {
+	vfsfd vfs.FileDescription
+	vfs.FileDescriptionDefaultImpl
+	vfs.DentryMetadataFileDescriptionImpl
+	vfs.NoLockFD
+
+	// tg is the thread group referred to by the pidfd. tg is immutable.
+	tg *ThreadGroup
+}
+
+var _ vfs.FileDescriptionImpl = (*PIDFD)(nil)
+
+// NewPIDFD returns a new pidfd referring to tg.
+func (k *Kernel) NewPIDFD(tg *ThreadGroup, flags uint32) (*vfs.FileDescription, error) {
+	vd := k.VFS().NewAnonVirtualDentry("[pidfd]")
+	defer vd.DecRef(k.SupervisorContext())
+	fd := &PIDFD{
+		tg: tg,
+	}
+	if err := fd.vfsfd.Init(fd, flags, vd.Mount(), vd.Dentry(), &vfs.FileDescriptionOptions{
+		UseDentryMetadata: true,
+		DenyPRead:         true,
+		DenyPWrite:        true,
+	}); err != nil {
+		return nil, err
+	}
+	return &fd.vfsfd, nil
+}
+
+// ThreadGroup returns the thread group referred to by fd.
+func (fd *PIDFD) ThreadGroup() *ThreadGroup {
+	return fd.tg
+}
+
+// Readiness implements waiter.Waitable.Readiness.
+func (fd *PIDFD) Readiness(mask waiter.EventMask) waiter.EventMask {
+	if mask&waiter.ReadableEvents != 0 && fd.tg.Exited() {
+		return waiter.ReadableEvents
+	}
+	return 0
+}
+
+// EventRegister implements waiter.Waitable.EventRegister.
+func (fd *PIDFD) EventRegister(e *waiter.Entry) {
+	fd.tg.pidfdQueue.EventRegister(e)
+}
+
+// EventUnregister implements waiter.Waitable.EventUnregister.
+func (fd *PIDFD) EventUnregister(e *waiter.Entry) {
+	fd.tg.pidfdQueue.EventUnregister(e)
+}
+
+// Release implements vfs.FileDescriptionImpl.Release.
+func (fd *PIDFD) Release(context.Context) {}
+
+// Exited returns true if all tasks in tg have exited.
+func (tg *ThreadGroup) Exited() bool {
+	tg.pidns.owner.mu.RLock()
+	defer tg.pidns.owner.mu.RUnlock()
+	return tg.liveTasks == 0
+}
+
+// installPIDFD implements CLONE_PIDFD: it installs a pidfd referring to tg in
+// t's file descriptor table, and copies its number out to addr.
+func (t *Task) installPIDFD(tg *ThreadGroup, addr hostarch.Addr) error {
+	file, err := t.k.NewPIDFD(tg, linux.O_RDWR)
+	if err != nil {
+		return err
+	}
+	defer file.DecRef(t)
+	// "The close-on-exec flag is set on the new file descriptor." - clone(2)
+	fd, err := t.NewFDFromVFS2(0, file, FDFlags{CloseOnExec: true})
+	if err != nil {
+		return err
+	}
+	if _, err := primitive.CopyInt32Out(t, addr, fd); err != nil {
+		if _, f := t.fdTable.Remove(t, fd); f != nil {
+			f.DecRef(t)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,328 @@
+// Copyright 2022 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kernel
+
+// Pressure stall information (PSI), see Linux's
+// Documentation/accounting/psi.rst.
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+
+	"gvisor.dev/gvisor/pkg/sync"
+)
+
+// PressureResource is a resource whose stalls are tracked by pressure stall
+// information.
+type PressureResource int
+
+// Resources tracked by pressure stall information, in the order of
+// PressureStats.
+const (
+	PressureCPU PressureResource = iota
+	PressureMemory
+	PressureIO
+
+	// NumPressureResources is the number of PressureResources.
+	NumPressureResources
+)
+
+// String implements fmt.Stringer.String.
+func (r PressureResource) String() string {
+	switch r {
+	case PressureCPU:
+		return "cpu"
+	case PressureMemory:
+		return "memory"
+	case PressureIO:
+		return "io"
+	default:
+		return "unknown"
+	}
+}
+
+// PressureLine contains statistics on the time during which tasks stalled on
+// a resource. It corresponds to a line of the files in /proc/pressure.
+type PressureLine struct {
+	// Avg10, Avg60 and Avg300 are the percentages of time spent stalled,
+	// averaged over the last 10, 60 and 300 seconds respectively.
+	Avg10  float64
+	Avg60  float64
+	Avg300 float64
+
+	// Total is the total time spent stalled.
+	Total time.Duration
+}
+
+// ResourcePressure contains statistics on the stalls on a resource.
+type ResourcePressure struct {
+	// Some is the time during which at least one task stalled on the
+	// resource.
+	Some PressureLine
+
+	// Full is the time during which all non-idle tasks stalled on the
+	// resource at the same time.
+	Full PressureLine
+}
+
+// PressureStats contains statistics on the stalls on each resource, indexed
+// by PressureResource.
+type PressureStats [NumPressureResources]ResourcePressure
+
+const (
+	// pressureAvgPeriod is the minimum interval between updates of the
+	// running averages of a pressureGroup, as for Linux's psi_period.
+	pressureAvgPeriod = 2 * time.Second
+
+	pressureSome = 0
+	pressureFull = 1
+)
+
+// pressureAvgWindows are the windows of PressureLine.Avg10, Avg60 and Avg300.
+var pressureAvgWindows = [3]time.Duration{10 * time.Second, 60 * time.Second, 300 * time.Second}
+
+// pressureGroup tracks pressure stall information for the tasks of a
+// container.
+//
+// Since the sentry doesn't know when the host deschedules task goroutines,
+// stalls are estimated from the states of task goroutines:
+//
+// - Tasks stall on CPU when there are more running tasks than application
+// cores. CPU is never fully stalled.
+//
+// - Tasks stall on IO while blocked uninterruptibly, e.g. in gofer
+// operations. IO is fully stalled when no task is running.
+//
+// - The sentry doesn't reclaim memory, so tasks never stall on memory and
+// the memory stall information is always zero.
+//
+// Task state changes are on the hot path of blocking, so the numbers of
+// running and waiting tasks are counted atomically, and mu is only locked
+// when the set of stalls changes.
+//
+// +stateify savable
+type pressureGroup struct {
+	mu sync.Mutex `state:"nosave"`
+
+	// cores is the number of application cores. It is immutable.
+	cores int64
+
+	// nrTasks contains the numbers of tasks in the group that are running
+	// and blocked uninterruptibly, packed by pressureTasks.
+	//
+	// nrTasks is accessed using atomic memory operations.
+	nrTasks uint64
+
+	// lastStalls are the stalls at the last update. It is protected by mu.
+	lastStalls pressureStalls
+
+	// lastUpdate is the time at which total was last updated, in
+	// nanoseconds of the monotonic clock. It is protected by mu.
+	lastUpdate int64
+
+	// total is the total stall time in nanoseconds, indexed by
+	// PressureResource and then pressureSome or pressureFull. It is protected
+	// by mu.
+	total [NumPressureResources][2]int64
+
+	// avgs are the running averages of stall time in percent, indexed like
+	// total and then by window in pressureAvgWindows. avgTotal is the value
+	// of total when avgs were last updated at avgUpdate. They are protected
+	// by mu.
+	avgs      [NumPressureResources][2][len(pressureAvgWindows)]float64
+	avgTotal  [NumPressureResources][2]int64
+	avgUpdate int64
+}
+
+// newPressureGroup returns a pressureGroup with no tasks at time now.
+func newPressureGroup(cores uint, now int64) *pressureGroup {
+	return &pressureGroup{
+		cores:      int64(cores),
+		lastUpdate: now,
+		avgUpdate:  now,
+	}
+}
+
+// pressureStalls is a set of stalls, indexed like pressureGroup.total.
+type pressureStalls [NumPressureResources][2]bool
+
+// pressureTasks packs the numbers of running and uninterruptibly blocked tasks
+// into the value of pressureGroup.nrTasks.
+func pressureTasks(nrRunning, nrIOWait int64) uint64 {
+	return uint64(uint32(nrRunning)) | uint64(uint32(nrIOWait))<<32
+}
+
+// stalls returns the stalls happening with the numbers of tasks packed in
+// nrTasks.
+func (pg *pressureGroup) stalls(nrTasks uint64) pressureStalls {
+	nrRunning := int64(int32(nrTasks))
+	nrIOWait := int64(int32(nrTasks >> 32))
+	var s pressureStalls
+	s[PressureCPU][pressureSome] = nrRunning > pg.cores
+	s[PressureIO][pressureSome] = nrIOWait > 0
+	s[PressureIO][pressureFull] = nrIOWait > 0 && nrRunning == 0
+	return s
+}
+
+// updateLocked accounts for the stalls at the last update until now, and
+// starts accounting for the current stalls.
+//
+// Preconditions: pg.mu must be locked.
+func (pg *pressureGroup) updateLocked(now int64) {
+	if elapsed := now - pg.lastUpdate; elapsed > 0 {
+		for r := range pg.lastStalls {
+			for kind, stalled := range pg.lastStalls[r] {
+				if stalled {
+					pg.total[r][kind] += elapsed
+				}
+			}
+		}
+	}
+	pg.lastUpdate = now
+	pg.lastStalls = pg.stalls(atomic.LoadUint64(&pg.nrTasks))
+}
+
+// taskStateChanged accounts for a task goroutine in pg going from state from
+// to state to. now returns the current time in nanoseconds of the monotonic
+// clock; it is only called if a stall starts or ends.
+func (pg *pressureGroup) taskStateChanged(from, to TaskGoroutineState, now func() int64) {
+	dRunning := pressureRunning(to) - pressureRunning(from)
+	dIOWait := pressureIOWait(to) - pressureIOWait(from)
+	if dRunning == 0 && dIOWait == 0 {
+		return
+	}
+	for {
+		old := atomic.LoadUint64(&pg.nrTasks)
+		nrTasks := pressureTasks(int64(int32(old))+dRunning, int64(int32(old>>32))+dIOWait)
+		if !atomic.CompareAndSwapUint64(&pg.nrTasks, old, nrTasks) {
+			continue
+		}
+		// Stall time only needs to be accounted when the set of stalls
+		// changes.
+		if pg.stalls(old) != pg.stalls(nrTasks) {
+			pg.mu.Lock()
+			pg.updateLocked(now())
+			pg.mu.Unlock()
+		}
+		return
+	}
+}
+
+// stats returns pressure statistics at time now.
+func (pg *pressureGroup) stats(now int64) PressureStats {
+	pg.mu.Lock()
+	defer pg.mu.Unlock()
+	pg.updateLocked(now)
+	pg.updateAvgsLocked(now)
+
+	var ps PressureStats
+	for r := range ps {
+		for kind, l := range [2]*PressureLine{&ps[r].Some, &ps[r].Full} {
+			avgs := &pg.avgs[r][kind]
+			*l = PressureLine{
+				Avg10:  avgs[0],
+				Avg60:  avgs[1],
+				Avg300: avgs[2],
+				Total:  time.Duration(pg.total[r][kind]),
+			}
+		}
+	}
+	return ps
+}
+
+// updateAvgsLocked updates the running averages of pg if at least
+// pressureAvgPeriod elapsed since they were last updated.
+//
+// Preconditions: pg.mu must be locked.
+func (pg *pressureGroup) updateAvgsLocked(now int64) {
+	elapsed := now - pg.avgUpdate
+	if elapsed < pressureAvgPeriod.Nanoseconds() {
+		return
+	}
+	for r := range pg.avgs {
+		for kind := range pg.avgs[r] {
+			stalled := pg.total[r][kind] - pg.avgTotal[r][kind]
+			pg.avgTotal[r][kind] = pg.total[r][kind]
+			pct := math.Min(100*float64(stalled)/float64(elapsed), 100)
+			for i, window := range pressureAvgWindows {
+				// Decay the average as if the stall time was spread
+				// evenly over the elapsed time, which also accounts for
+				// any periods during which averages weren't updated.
+				decay := math.Exp(-float64(elapsed) / float64(window.Nanoseconds()))
+				pg.avgs[r][kind][i] = pg.avgs[r][kind][i]*decay + pct*(1-decay)
+			}
+		}
+	}
+	pg.avgUpdate = now
+}
+
+// pressureRunning returns 1 if a task goroutine in state is considered
+// running for pressure stall information, and 0 otherwise.
+func pressureRunning(state TaskGoroutineState) int64 {
+	if state == TaskGoroutineRunningSys || state == TaskGoroutineRunningApp {
+		return 1
+	}
+	return 0
+}
+
+// pressureIOWait returns 1 if a task goroutine in state is considered
+// waiting for IO for pressure stall information, and 0 otherwise.
+func pressureIOWait(state TaskGoroutineState) int64 {
+	if state == TaskGoroutineBlockedUninterruptible {
+		return 1
+	}
+	return 0
+}
+
+// pressureGroupFor returns the pressureGroup of the tasks in container cid,
+// creating it if necessary.
+func (k *Kernel) pressureGroupFor(cid string) *pressureGroup {
+	k.pressureMu.Lock()
+	defer k.pressureMu.Unlock()
+	pg, ok := k.pressure[cid]
+	if !ok {
+		pg = newPressureGroup(k.applicationCores, k.MonotonicClock().Now().Nanoseconds())
+		k.pressure[cid] = pg
+	}
+	return pg
+}
+
+// ReleaseContainerPressure releases pressure stall information for the tasks of
+// container cid, once the container is destroyed.
+func (k *Kernel) ReleaseContainerPressure(cid string) {
+	k.pressureMu.Lock()
+	defer k.pressureMu.Unlock()
+	delete(k.pressure, cid)
+}
+
+// ContainerPressure returns pressure stall information for the tasks of
+// container cid. It returns false if no task was ever started in the
+// container.
+func (k *Kernel) ContainerPressure(cid string) (PressureStats, bool) {
+	k.pressureMu.Lock()
+	pg, ok := k.pressure[cid]
+	k.pressureMu.Unlock()
+	if !ok {
+		return PressureStats{}, false
+	}
+	return pg.stats(k.MonotonicClock().Now().Nanoseconds()), true
+}
+
+// pressureNow returns the current time in nanoseconds of t's monotonic clock,
+// as passed to pressureGroup.taskStateChanged.
+func (t *Task) pressureNow() int64 {
+	return t.k.MonotonicClock().Now().Nanoseconds()
+}
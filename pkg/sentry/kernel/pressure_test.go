@@ -0,0 +1,84 @@
+// Copyright 2022 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kernel
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPressureStalls(t *testing.T) {
+	var now int64
+	clock := func() int64 { return now }
+	pg := newPressureGroup(1 /* cores */, now)
+
+	// Two tasks running on a single core for 1s stall on CPU.
+	pg.taskStateChanged(TaskGoroutineNonexistent, TaskGoroutineRunningSys, clock)
+	pg.taskStateChanged(TaskGoroutineNonexistent, TaskGoroutineRunningSys, clock)
+	now += time.Second.Nanoseconds()
+
+	// One task blocked uninterruptibly for 2s stalls on IO, but not fully.
+	pg.taskStateChanged(TaskGoroutineRunningSys, TaskGoroutineBlockedUninterruptible, clock)
+	now += 2 * time.Second.Nanoseconds()
+
+	// Both tasks blocked uninterruptibly for 3s fully stall on IO.
+	pg.taskStateChanged(TaskGoroutineRunningSys, TaskGoroutineBlockedUninterruptible, clock)
+	now += 3 * time.Second.Nanoseconds()
+
+	// Idle tasks don't stall.
+	pg.taskStateChanged(TaskGoroutineBlockedUninterruptible, TaskGoroutineRunningSys, clock)
+	pg.taskStateChanged(TaskGoroutineRunningSys, TaskGoroutineBlockedInterruptible, clock)
+	pg.taskStateChanged(TaskGoroutineBlockedUninterruptible, TaskGoroutineRunningSys, clock)
+	pg.taskStateChanged(TaskGoroutineRunningSys, TaskGoroutineStopped, clock)
+	now += 4 * time.Second.Nanoseconds()
+
+	ps := pg.stats(now)
+	for _, test := range []struct {
+		name string
+		got  time.Duration
+		want time.Duration
+	}{
+		{"cpu some", ps[PressureCPU].Some.Total, time.Second},
+		{"cpu full", ps[PressureCPU].Full.Total, 0},
+		{"memory some", ps[PressureMemory].Some.Total, 0},
+		{"memory full", ps[PressureMemory].Full.Total, 0},
+		{"io some", ps[PressureIO].Some.Total, 5 * time.Second},
+		{"io full", ps[PressureIO].Full.Total, 3 * time.Second},
+	} {
+		if test.got != test.want {
+			t.Errorf("%s total got %v, want %v", test.name, test.got, test.want)
+		}
+	}
+
+	// 5s of the 10s elapsed were spent stalled on IO.
+	if avg := ps[PressureIO].Some.Avg10; avg < 25 || avg > 50 {
+		t.Errorf("io some avg10 got %.2f, want between 25 and 50", avg)
+	}
+	if avg := ps[PressureCPU].Some.Avg300; avg <= 0 || avg >= ps[PressureCPU].Some.Avg10 {
+		t.Errorf("cpu some avg300 got %.2f, want between 0 and avg10 %.2f", avg, ps[PressureCPU].Some.Avg10)
+	}
+}
+
+func BenchmarkPressureTaskStateChanged(b *testing.B) {
+	clock := func() int64 { return 0 }
+	pg := newPressureGroup(4 /* cores */, 0)
+	b.RunParallel(func(pb *testing.PB) {
+		pg.taskStateChanged(TaskGoroutineNonexistent, TaskGoroutineRunningSys, clock)
+		for pb.Next() {
+			pg.taskStateChanged(TaskGoroutineRunningSys, TaskGoroutineBlockedInterruptible, clock)
+			pg.taskStateChanged(TaskGoroutineBlockedInterruptible, TaskGoroutineRunningSys, clock)
+		}
+	})
+}
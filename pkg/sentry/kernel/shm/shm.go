@@ -84,6 +84,10 @@ type Registry struct {
 	// Sum of the sizes of all existing segments rounded up to page size, in
 	// units of page size.
 	totalPages uint64
+
+	// params are the limits enforced by the registry, equivalent to the
+	// kernel.shm* sysctls.
+	params linux.ShmParams
 }
 
 // NewRegistry creates a new shm registry.
@@ -91,6 +95,13 @@ func NewRegistry(userNS *auth.UserNamespace) *Registry {
 	return &Registry{
 		userNS: userNS,
 		reg:    ipc.NewRegistry(userNS),
+		params: linux.ShmParams{
+			ShmMax: linux.SHMMAX,
+			ShmMin: linux.SHMMIN,
+			ShmMni: linux.SHMMNI,
+			ShmSeg: linux.SHMSEG,
+			ShmAll: linux.SHMALL,
+		},
 	}
 }
 
@@ -135,7 +146,10 @@ func (r *Registry) dissociateKey(s *Shm) {
 //
 // FindOrCreate returns a reference on Shm.
 func (r *Registry) FindOrCreate(ctx context.Context, pid int32, key ipc.Key, size uint64, mode linux.FileMode, private, create, exclusive bool) (*Shm, error) {
-	if (create || private) && (size < linux.SHMMIN || size > linux.SHMMAX) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if (create || private) && (size < r.params.ShmMin || size > r.params.ShmMax) {
 		// "A new segment was to be created and size is less than SHMMIN or
 		// greater than SHMMAX." - man shmget(2)
 		//
@@ -144,10 +158,7 @@ func (r *Registry) FindOrCreate(ctx context.Context, pid int32, key ipc.Key, siz
 		return nil, linuxerr.EINVAL
 	}
 
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
-	if r.reg.ObjectCount() >= linux.SHMMNI {
+	if uint64(r.reg.ObjectCount()) >= r.params.ShmMni {
 		// "All possible shared memory IDs have been taken (SHMMNI) ..."
 		//   - man shmget(2)
 		return nil, linuxerr.ENOSPC
@@ -179,7 +190,7 @@ func (r *Registry) FindOrCreate(ctx context.Context, pid int32, key ipc.Key, siz
 		return nil, linuxerr.EINVAL
 	}
 
-	if numPages := sizeAligned / hostarch.PageSize; r.totalPages+numPages > linux.SHMALL {
+	if numPages := sizeAligned / hostarch.PageSize; r.totalPages+numPages > r.params.ShmAll {
 		// "... allocating a segment of the requested size would cause the
 		// system to exceed the system-wide limit on shared memory (SHMALL)."
 		//   - man shmget(2)
@@ -235,13 +246,26 @@ func (r *Registry) newShmLocked(ctx context.Context, pid int32, key ipc.Key, cre
 // IPCInfo reports global parameters for sysv shared memory segments on this
 // system. See shmctl(IPC_INFO).
 func (r *Registry) IPCInfo() *linux.ShmParams {
-	return &linux.ShmParams{
-		ShmMax: linux.SHMMAX,
-		ShmMin: linux.SHMMIN,
-		ShmMni: linux.SHMMNI,
-		ShmSeg: linux.SHMSEG,
-		ShmAll: linux.SHMALL,
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	params := r.params
+	return &params
+}
+
+// SetIPCInfo changes the limits enforced for new segments, as reported by
+// IPCInfo. Only ShmMax, ShmMni and ShmAll can be changed, like the
+// corresponding sysctls.
+func (r *Registry) SetIPCInfo(params *linux.ShmParams) error {
+	if params.ShmMin != linux.SHMMIN || params.ShmSeg != linux.SHMSEG {
+		return linuxerr.EINVAL
 	}
+	if params.ShmMni > linux.IPCMNI {
+		return linuxerr.EINVAL
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.params = *params
+	return nil
 }
 
 // ShmInfo reports linux-specific global parameters for sysv shared memory
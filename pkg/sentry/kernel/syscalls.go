@@ -96,6 +96,10 @@ const (
 	// StraceEnableEvent enables syscall event tracing.
 	StraceEnableEvent
 
+	// StraceEnableFollow enables syscall tracing to followers, see
+	// strace.Follow.
+	StraceEnableFollow
+
 	// ExternalBeforeEnable enables the external hook before syscall execution.
 	ExternalBeforeEnable
 
@@ -103,8 +107,8 @@ const (
 	ExternalAfterEnable
 )
 
-// StraceEnableBits combines both strace log and event flags.
-const StraceEnableBits = StraceEnableLog | StraceEnableEvent
+// StraceEnableBits combines the strace log, event and follow flags.
+const StraceEnableBits = StraceEnableLog | StraceEnableEvent | StraceEnableFollow
 
 // SyscallFlagsTable manages a set of enable/disable bit fields on a per-syscall
 // basis.
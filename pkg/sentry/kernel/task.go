@@ -18,6 +18,7 @@ import (
 	gocontext "context"
 	"runtime/trace"
 	"sync/atomic"
+	"time"
 
 	"gvisor.dev/gvisor/pkg/abi/linux"
 	"gvisor.dev/gvisor/pkg/bpf"
@@ -250,11 +251,16 @@ type Task struct {
 
 	// containerID has no equivalent in Linux; it's used by runsc to track all
 	// tasks that belong to a given containers since cgroups aren't implemented.
-	// It's inherited by the children, is immutable, and may be empty.
+	// It's inherited by the children, is immutable once the task runs (see
+	// Kernel.RenameContainers), and may be empty.
 	//
 	// NOTE: cgroups can be used to track this when implemented.
 	containerID string
 
+	// pressure tracks pressure stall information for the tasks of the
+	// container. The pressure pointer is immutable.
+	pressure *pressureGroup
+
 	// mu protects some of the following fields.
 	mu sync.Mutex `state:"nosave"`
 
@@ -566,6 +572,15 @@ type Task struct {
 	blockingTimer     *ktime.Timer    `state:"nosave"`
 	blockingTimerChan <-chan struct{} `state:"nosave"`
 
+	// timerSlack is the amount of time by which blocking timeouts may be
+	// delayed, so that they can be coalesced with other expirations.
+	// defaultTimerSlack is the value that timerSlack is reset to by
+	// prctl(PR_SET_TIMERSLACK, 0).
+	//
+	// timerSlack and defaultTimerSlack are exclusive to the task goroutine.
+	timerSlack        time.Duration
+	defaultTimerSlack time.Duration
+
 	// futexWaiter is used for futex(FUTEX_WAIT) syscalls.
 	//
 	// futexWaiter is exclusive to the task goroutine.
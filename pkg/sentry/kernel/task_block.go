@@ -15,6 +15,7 @@
 package kernel
 
 import (
+	"math"
 	"runtime"
 	"runtime/trace"
 	"time"
@@ -73,7 +74,84 @@ func (t *Task) BlockWithDeadline(C <-chan struct{}, haveDeadline bool, deadline
 	if !haveDeadline {
 		return t.block(C, nil)
 	}
+	if t.timerSlack == 0 && t.k.highResolutionTimers {
+		return t.blockWithDeadlineHighResolution(C, deadline)
+	}
+	return t.blockWithDeadline(C, t.coalesceDeadline(deadline))
+}
 
+// TimerSlack returns t's timer slack, as for PR_GET_TIMERSLACK.
+//
+// Preconditions: The caller must be running on the task goroutine.
+func (t *Task) TimerSlack() time.Duration {
+	return t.timerSlack
+}
+
+// SetTimerSlack sets t's timer slack, as for PR_SET_TIMERSLACK. If d is 0,
+// t's timer slack is reset to its default value.
+//
+// Preconditions: The caller must be running on the task goroutine.
+func (t *Task) SetTimerSlack(d time.Duration) {
+	if d == 0 {
+		d = t.defaultTimerSlack
+	}
+	t.timerSlack = d
+}
+
+// coalesceDeadline returns deadline rounded up to a multiple of t's timer
+// slack. Timeouts of tasks with the same timer slack that end within the same
+// slack interval thus expire together, reducing wakeups.
+func (t *Task) coalesceDeadline(deadline ktime.Time) ktime.Time {
+	slack := t.timerSlack.Nanoseconds()
+	if slack <= 1 {
+		return deadline
+	}
+	ns := deadline.Nanoseconds()
+	r := ns % slack
+	if r == 0 || ns > math.MaxInt64-slack {
+		return deadline
+	}
+	return ktime.FromNanoseconds(ns + slack - r)
+}
+
+// hrtimerPollDuration is the time before the end of a timeout at which
+// blockWithDeadlineHighResolution stops sleeping and starts polling. Go
+// timers may expire up to about a millisecond late.
+const hrtimerPollDuration = time.Millisecond
+
+// blockWithDeadlineHighResolution is equivalent to BlockWithDeadline, but
+// returns ETIMEDOUT as close to deadline as possible by polling the clock for
+// the last hrtimerPollDuration of the timeout.
+//
+// Preconditions: The caller must be running on the task goroutine.
+func (t *Task) blockWithDeadlineHighResolution(C <-chan struct{}, deadline ktime.Time) error {
+	clock := t.k.MonotonicClock()
+	if early := deadline.Add(-hrtimerPollDuration); clock.Now().Before(early) {
+		if err := t.blockWithDeadline(C, early); !linuxerr.Equals(linuxerr.ETIMEDOUT, err) {
+			return err
+		}
+	}
+	for {
+		select {
+		case <-C:
+			return nil
+		default:
+		}
+		if t.interrupted() {
+			return linuxerr.ErrInterrupted
+		}
+		if !clock.Now().Before(deadline) {
+			return linuxerr.ETIMEDOUT
+		}
+		runtime.Gosched()
+	}
+}
+
+// blockWithDeadline implements BlockWithDeadline when there is a deadline,
+// without adjusting it.
+//
+// Preconditions: The caller must be running on the task goroutine.
+func (t *Task) blockWithDeadline(C <-chan struct{}, deadline ktime.Time) error {
 	// Start the timeout timer.
 	t.blockingTimer.Swap(ktime.Setting{
 		Enabled: true,
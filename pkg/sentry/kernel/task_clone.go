@@ -58,6 +58,11 @@ func (t *Task) Clone(args *linux.CloneArgs) (ThreadID, *SyscallControl, error) {
 	if args.Flags&linux.CLONE_NEWUSER != 0 && args.Flags&(linux.CLONE_THREAD|linux.CLONE_FS) != 0 {
 		return 0, nil, linuxerr.EINVAL
 	}
+	// A pidfd refers to a process, not a thread; and pidfds are only
+	// implemented for VFS2.
+	if args.Flags&linux.CLONE_PIDFD != 0 && (args.Flags&(linux.CLONE_THREAD|linux.CLONE_DETACHED) != 0 || !VFS2Enabled) {
+		return 0, nil, linuxerr.EINVAL
+	}
 	// args.ExitSignal must be a valid signal.
 	if args.ExitSignal != 0 && !linux.Signal(args.ExitSignal).IsValid() {
 		return 0, nil, linuxerr.EINVAL
@@ -204,6 +209,7 @@ func (t *Task) Clone(args *linux.CloneArgs) (ThreadID, *SyscallControl, error) {
 		RSeqAddr:                rseqAddr,
 		RSeqSignature:           rseqSignature,
 		ContainerID:             t.ContainerID(),
+		TimerSlack:              t.timerSlack,
 	}
 	if args.Flags&linux.CLONE_THREAD == 0 {
 		cfg.Parent = t
@@ -248,11 +254,15 @@ func (t *Task) Clone(args *linux.CloneArgs) (ThreadID, *SyscallControl, error) {
 			// for group signal delivery, had children reparented to it, etc.
 			// Thus we can't just drop it on the floor. Instead, instruct the
 			// task goroutine to exit immediately, as quietly as possible.
-			nt.exitTracerNotified = true
-			nt.exitTracerAcked = true
-			nt.exitParentNotified = true
-			nt.exitParentAcked = true
-			nt.runState = (*runExitMain)(nil)
+			nt.exitBeforeStart()
+			return 0, nil, err
+		}
+	}
+
+	if args.Flags&linux.CLONE_PIDFD != 0 {
+		if err := t.installPIDFD(nt.tg, hostarch.Addr(args.Pidfd)); err != nil {
+			// As above.
+			nt.exitBeforeStart()
 			return 0, nil, err
 		}
 	}
@@ -300,6 +310,16 @@ func (t *Task) Clone(args *linux.CloneArgs) (ThreadID, *SyscallControl, error) {
 	return ntid, nil, nil
 }
 
+// exitBeforeStart instructs the task goroutine of t, which must not have been
+// started yet, to exit immediately without notifying its parent or tracer.
+func (t *Task) exitBeforeStart() {
+	t.exitTracerNotified = true
+	t.exitTracerAcked = true
+	t.exitParentNotified = true
+	t.exitParentAcked = true
+	t.runState = (*runExitMain)(nil)
+}
+
 func getCloneSeccheckInfo(t, nt *Task, args *linux.CloneArgs) (seccheck.CloneFieldSet, seccheck.CloneInfo) {
 	req := seccheck.Global.CloneReq()
 	info := seccheck.CloneInfo{
@@ -110,7 +110,7 @@ func (t *Task) exitRobustList() {
 
 		// Wakeup the current futex if it's not pending.
 		if thisLockAddr != pendingLockAddr {
-			t.wakeRobustListOne(thisLockAddr)
+			t.wakeRobustListOne(thisLockAddr, false /* pending */)
 		}
 
 		// If there was an error copying the next futex, we must bail.
@@ -129,12 +129,18 @@ func (t *Task) exitRobustList() {
 
 	// Is there a pending entry to wake?
 	if pendingLockAddr != 0 {
-		t.wakeRobustListOne(pendingLockAddr)
+		t.wakeRobustListOne(pendingLockAddr, true /* pending */)
 	}
 }
 
-// wakeRobustListOne wakes a single futex from the robust list.
-func (t *Task) wakeRobustListOne(addr hostarch.Addr) {
+// wakeRobustListOne wakes a single futex from the robust list. pending
+// indicates that addr is the robust list's pending entry.
+//
+// Robust futexes are always woken as shared futexes, so that waiters in other
+// processes (when the futex is in shared memory) are woken as well. This
+// matches Linux, and userspace (e.g. glibc) uses shared futex operations for
+// all robust mutexes for that reason.
+func (t *Task) wakeRobustListOne(addr hostarch.Addr, pending bool) {
 	// Bit 0 in address signals PI futex.
 	pi := addr&1 == 1
 	addr = addr &^ 1
@@ -147,7 +153,27 @@ func (t *Task) wakeRobustListOne(addr hostarch.Addr) {
 		return
 	}
 
+	// The thread may have died after releasing a non-PI futex but before
+	// waking its waiters, or after being woken but before acquiring the futex.
+	// In both cases the futex is unowned, and a waiter must be woken without
+	// setting the owner died bit, which would corrupt the userspace state.
+	if pending && !pi && f == 0 {
+		t.Futex().Wake(t, addr, false, linux.FUTEX_BITSET_MATCH_ANY, 1)
+		return
+	}
+
 	tid := uint32(t.ThreadID())
+	if pi {
+		// Is this held by someone else?
+		if f&linux.FUTEX_TID_MASK != tid {
+			return
+		}
+		// This thread is dying and it's holding this futex. Hand it over to
+		// the next waiter, if any.
+		t.Futex().HandleOwnerDeathPI(t, addr, tid, false)
+		return
+	}
+
 	for {
 		// Is this held by someone else?
 		if f&linux.FUTEX_TID_MASK != tid {
@@ -167,12 +193,7 @@ func (t *Task) wakeRobustListOne(addr hostarch.Addr) {
 
 		// Wake waiters if there are any.
 		if f&linux.FUTEX_WAITERS != 0 {
-			private := f&linux.FUTEX_PRIVATE_FLAG != 0
-			if pi {
-				t.Futex().UnlockPI(t, addr, tid, private)
-				return
-			}
-			t.Futex().Wake(t, addr, private, linux.FUTEX_BITSET_MATCH_ANY, 1)
+			t.Futex().Wake(t, addr, false, linux.FUTEX_BITSET_MATCH_ANY, 1)
 		}
 
 		// Done.
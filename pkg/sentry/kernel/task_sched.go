@@ -130,6 +130,7 @@ func (t *Task) accountTaskGoroutineEnter(state TaskGoroutineState) {
 	if state != TaskGoroutineRunningApp {
 		// Task is blocking/stopping.
 		t.k.decRunningTasks()
+		t.pressure.taskStateChanged(TaskGoroutineRunningSys, state, t.pressureNow)
 	}
 }
 
@@ -141,6 +142,7 @@ func (t *Task) accountTaskGoroutineLeave(state TaskGoroutineState) {
 	if state != TaskGoroutineRunningApp {
 		// Task is unblocking/continuing.
 		t.k.incRunningTasks()
+		t.pressure.taskStateChanged(state, TaskGoroutineRunningSys, t.pressureNow)
 	}
 
 	now := t.k.CPUClockNow()
@@ -15,6 +15,8 @@
 package kernel
 
 import (
+	"time"
+
 	"gvisor.dev/gvisor/pkg/abi/linux"
 	"gvisor.dev/gvisor/pkg/context"
 	"gvisor.dev/gvisor/pkg/errors/linuxerr"
@@ -93,6 +95,10 @@ type TaskConfig struct {
 
 	// ContainerID is the container the new task belongs to.
 	ContainerID string
+
+	// TimerSlack is the timer slack of the new task. It is also the value
+	// that the timer slack is reset to by prctl(PR_SET_TIMERSLACK, 0).
+	TimerSlack time.Duration
 }
 
 // NewTask creates a new task defined by cfg.
@@ -149,7 +155,10 @@ func (ts *TaskSet) newTask(cfg *TaskConfig) (*Task, error) {
 		rseqSignature:      cfg.RSeqSignature,
 		futexWaiter:        futex.NewWaiter(),
 		containerID:        cfg.ContainerID,
+		pressure:           cfg.Kernel.pressureGroupFor(cfg.ContainerID),
 		cgroups:            make(map[Cgroup]struct{}),
+		timerSlack:         cfg.TimerSlack,
+		defaultTimerSlack:  cfg.TimerSlack,
 	}
 	t.netns.Store(cfg.NetworkNamespace)
 	t.creds.Store(cfg.Credentials)
@@ -15,9 +15,12 @@
 package kernel
 
 import (
+	"math"
 	"testing"
+	"time"
 
 	"gvisor.dev/gvisor/pkg/sentry/kernel/sched"
+	ktime "gvisor.dev/gvisor/pkg/sentry/kernel/time"
 )
 
 func TestTaskCPU(t *testing.T) {
@@ -67,3 +70,48 @@ func TestTaskCPU(t *testing.T) {
 	}
 
 }
+
+func TestCoalesceDeadline(t *testing.T) {
+	for _, test := range []struct {
+		slack    time.Duration
+		deadline int64
+		want     int64
+	}{
+		{
+			slack:    0,
+			deadline: 1234,
+			want:     1234,
+		},
+		{
+			slack:    1,
+			deadline: 1234,
+			want:     1234,
+		},
+		{
+			slack:    100,
+			deadline: 1200,
+			want:     1200,
+		},
+		{
+			slack:    100,
+			deadline: 1201,
+			want:     1300,
+		},
+		{
+			slack:    100,
+			deadline: 1299,
+			want:     1300,
+		},
+		{
+			// rounding up would overflow.
+			slack:    100,
+			deadline: math.MaxInt64 - 1,
+			want:     math.MaxInt64 - 1,
+		},
+	} {
+		task := &Task{timerSlack: test.slack}
+		if got := task.coalesceDeadline(ktime.FromNanoseconds(test.deadline)).Nanoseconds(); got != test.want {
+			t.Errorf("coalesceDeadline(%d) with slack %v got %d, want %d", test.deadline, test.slack, got, test.want)
+		}
+	}
+}
@@ -326,6 +326,10 @@ type threadGroupNode struct {
 	// thread group. Events are defined in task_exit.go.
 	eventQueue waiter.Queue
 
+	// pidfdQueue is notified with waiter.ReadableEvents when all tasks in the
+	// thread group have exited, for pidfds referring to it.
+	pidfdQueue waiter.Queue
+
 	// leader is the thread group's leader, which is the oldest task in the
 	// thread group; usually the last task in the thread group to call
 	// execve(), or if no such task exists then the first task in the thread
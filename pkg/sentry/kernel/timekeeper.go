@@ -46,6 +46,17 @@ type Timekeeper struct {
 	// monotonicClock is a ktime.Clock based on timekeeper's Monotonic.
 	monotonicClock *timekeeperClock
 
+	// taiClock is a ktime.Clock based on timekeeper's Realtime, offset by
+	// taiOffset.
+	taiClock *taiClock
+
+	// taiOffset is the offset in seconds between CLOCK_TAI and
+	// CLOCK_REALTIME. It follows the host's offset, which is updated by the
+	// host's NTP daemon (e.g. when a leap second is inserted).
+	//
+	// taiOffset is accessed using atomic memory operations.
+	taiOffset int64
+
 	// bootTime is the realtime when the system "booted". i.e., when
 	// SetClocks was called in the initial (not restored) run.
 	bootTime ktime.Time
@@ -103,6 +114,7 @@ func NewTimekeeper(mfp pgalloc.MemoryFileProvider, paramPage memmap.FileRange) *
 	}
 	t.realtimeClock = &timekeeperClock{tk: &t, c: sentrytime.Realtime}
 	t.monotonicClock = &timekeeperClock{tk: &t, c: sentrytime.Monotonic}
+	t.taiClock = &taiClock{tk: &t}
 	return &t
 }
 
@@ -250,6 +262,7 @@ func (t *Timekeeper) startUpdater() {
 			}); err != nil {
 				log.Warningf("Unable to update VDSO parameter page: %v", err)
 			}
+			t.updateTAIOffset()
 
 			select {
 			case <-timer.C:
@@ -327,6 +340,25 @@ func (t *Timekeeper) GetTime(c sentrytime.ClockID) (int64, error) {
 	return now, err
 }
 
+// updateTAIOffset updates the TAI offset from the host.
+func (t *Timekeeper) updateTAIOffset() {
+	offset, err := sentrytime.HostTAIOffset()
+	if err != nil {
+		// The offset is unchanged, which is fine as it changes rarely.
+		log.Debugf("Unable to get host TAI offset: %v", err)
+		return
+	}
+	if old := atomic.SwapInt64(&t.taiOffset, offset); old != offset {
+		log.Infof("TAI offset changed from %ds to %ds", old, offset)
+	}
+}
+
+// TAIOffset returns the offset in seconds between CLOCK_TAI and
+// CLOCK_REALTIME.
+func (t *Timekeeper) TAIOffset() int64 {
+	return atomic.LoadInt64(&t.taiOffset)
+}
+
 // BootTime returns the system boot real time.
 func (t *Timekeeper) BootTime() ktime.Time {
 	return t.bootTime
@@ -356,3 +388,23 @@ func (tc *timekeeperClock) Now() ktime.Time {
 	}
 	return ktime.FromNanoseconds(now)
 }
+
+// taiClock is a ktime.Clock that implements CLOCK_TAI on top of the
+// Timekeeper's realtime clock.
+//
+// +stateify savable
+type taiClock struct {
+	tk *Timekeeper
+
+	// Implements ktime.Clock.WallTimeUntil.
+	ktime.WallRateClock `state:"nosave"`
+
+	// Implements waiter.Waitable. Like CLOCK_REALTIME, we can't detect
+	// discontinuities.
+	ktime.NoClockEvents `state:"nosave"`
+}
+
+// Now implements ktime.Clock.Now.
+func (tc *taiClock) Now() ktime.Time {
+	return tc.tk.realtimeClock.Now().Add(time.Duration(tc.tk.TAIOffset()) * time.Second)
+}
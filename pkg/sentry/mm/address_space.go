@@ -20,6 +20,7 @@ import (
 
 	"gvisor.dev/gvisor/pkg/context"
 	"gvisor.dev/gvisor/pkg/hostarch"
+	"gvisor.dev/gvisor/pkg/sentry/pgalloc"
 	"gvisor.dev/gvisor/pkg/sentry/platform"
 )
 
@@ -206,7 +207,12 @@ func (mm *MemoryManager) mapASLocked(pseg pmaIterator, ar hostarch.AddrRange, pr
 			perms.Write = false
 		}
 		if perms.Any() { // MapFile precondition
-			if err := mm.as.MapFile(pmaMapAR.Start, pma.file, pseg.fileRangeOf(pmaMapAR), perms, precommit); err != nil {
+			fr := pseg.fileRangeOf(pmaMapAR)
+			if mf, ok := pma.file.(*pgalloc.MemoryFile); ok {
+				// Application accesses don't go through MapInternal.
+				mf.AwaitLoad(fr)
+			}
+			if err := mm.as.MapFile(pmaMapAR.Start, pma.file, fr, perms, precommit); err != nil {
 				return err
 			}
 		}
@@ -129,7 +129,10 @@ func (mm *MemoryManager) Fork(ctx context.Context) (*MemoryManager, error) {
 	// immediately followed by execve(2), copying non-private pmas that can be
 	// regenerated by calling memmap.Mappable.Translate is a waste of time.
 	// (Linux does the same; compare kernel/fork.c:dup_mmap() =>
-	// mm/memory.c:copy_page_range().)
+	// mm/memory.c:copy_page_range().) Private pmas can't be copied lazily,
+	// since they hold the only references on the memory of their private
+	// copy-on-write data; copying them only takes references on that memory,
+	// which is itself copied when either MemoryManager writes to it.
 	mm2.activeMu.Lock()
 	defer mm2.activeMu.Unlock()
 	mm.activeMu.Lock()
@@ -139,10 +142,29 @@ func (mm *MemoryManager) Fork(ctx context.Context) (*MemoryManager, error) {
 	}
 	srcvseg := mm.vmas.FirstSegment()
 	dstpgap := mm2.pmas.FirstGap()
-	var unmapAR hostarch.AddrRange
+	var (
+		// unmapAR is the range of mm's platform.AddressSpace that will be
+		// unmapped next, to make the pmas it contains copy-on-write.
+		unmapAR hostarch.AddrRange
+
+		// If unmapAdjacent is true, no pma lies between unmapAR.End and the
+		// next pma, so unmapAR can be extended to the latter; unmapping the
+		// gap in between is harmless since only pmas can be mapped into the
+		// platform.AddressSpace. This reduces the number of calls to
+		// unmapASLocked() when private pmas are interleaved with gaps.
+		unmapAdjacent bool
+
+		// refFile and refFR are the file and contiguous range of it, spanning
+		// one or more pmas, on which references haven't been taken for mm2
+		// yet. Taking references on such ranges at once rather than per pma
+		// reduces the number of updates of the reference count sets.
+		refFile memmap.File
+		refFR   memmap.FileRange
+	)
 	for srcpseg := mm.pmas.FirstSegment(); srcpseg.Ok(); srcpseg = srcpseg.NextSegment() {
 		pma := srcpseg.ValuePtr()
 		if !pma.private {
+			unmapAdjacent = false
 			continue
 		}
 
@@ -161,11 +183,13 @@ func (mm *MemoryManager) Fork(ctx context.Context) (*MemoryManager, error) {
 
 			srcpseg = mm.pmas.Isolate(srcpseg, srcvseg.Range())
 			if srcvseg.ValuePtr().dontfork {
+				unmapAdjacent = false
 				continue
 			}
 			pma = srcpseg.ValuePtr()
 		}
 
+		unmapped := false
 		if !pma.needCOW {
 			pma.needCOW = true
 			if pma.effectivePerms.Write {
@@ -175,7 +199,7 @@ func (mm *MemoryManager) Fork(ctx context.Context) (*MemoryManager, error) {
 				// unmapping pmas unnecessarily will result in extra page
 				// faults. But we do want to merge consecutive AddrRanges
 				// across pma boundaries.
-				if unmapAR.End == srcpseg.Start() {
+				if unmapAdjacent {
 					unmapAR.End = srcpseg.End()
 				} else {
 					if unmapAR.Length() != 0 {
@@ -183,17 +207,30 @@ func (mm *MemoryManager) Fork(ctx context.Context) (*MemoryManager, error) {
 					}
 					unmapAR = srcpseg.Range()
 				}
+				unmapped = true
 				pma.effectivePerms.Write = false
 			}
 			pma.maxPerms.Write = false
 		}
+		unmapAdjacent = unmapped
 		fr := srcpseg.fileRange()
-		mm2.incPrivateRef(fr)
-		srcpseg.ValuePtr().file.IncRef(fr)
+		if refFile == pma.file && refFR.End == fr.Start {
+			refFR.End = fr.End
+		} else {
+			if refFR.Length() != 0 {
+				mm2.incPrivateRef(refFR)
+				refFile.IncRef(refFR)
+			}
+			refFile, refFR = pma.file, fr
+		}
 		addrRange := srcpseg.Range()
 		mm2.addRSSLocked(addrRange)
 		dstpgap = mm2.pmas.Insert(dstpgap, addrRange, *pma).NextGap()
 	}
+	if refFR.Length() != 0 {
+		mm2.incPrivateRef(refFR)
+		refFile.IncRef(refFR)
+	}
 	if unmapAR.Length() != 0 {
 		mm.unmapASLocked(unmapAR)
 	}
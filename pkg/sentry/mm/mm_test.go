@@ -109,7 +109,7 @@ func TestDataASUpdates(t *testing.T) {
 		t.Fatalf("dataAS believes %v bytes are mapped; %v bytes are actually mapped", mm.dataAS, realDataAS)
 	}
 
-	mm.MProtect(addr+hostarch.PageSize, hostarch.PageSize, hostarch.Read, false)
+	mm.MProtect(ctx, addr+hostarch.PageSize, hostarch.PageSize, hostarch.Read, false)
 	realDataAS = mm.realDataAS()
 	if mm.dataAS != realDataAS {
 		t.Fatalf("dataAS believes %v bytes are mapped; %v bytes are actually mapped", mm.dataAS, realDataAS)
@@ -139,6 +139,66 @@ func TestBrkDataLimitUpdates(t *testing.T) {
 	}
 }
 
+func TestMMapDataLimit(t *testing.T) {
+	limitSet := limits.NewLimitSet()
+	limitSet.Set(limits.Data, limits.Limit{Cur: 2 * hostarch.PageSize, Max: 2 * hostarch.PageSize}, true /* privileged */)
+
+	ctx := contexttest.WithLimitSet(contexttest.Context(t), limitSet)
+	mm := testMemoryManager(ctx)
+	defer mm.DecUsers(ctx)
+
+	// Private writable mappings count against RLIMIT_DATA.
+	if _, err := mm.MMap(ctx, memmap.MMapOpts{
+		Length:   3 * hostarch.PageSize,
+		Private:  true,
+		Perms:    hostarch.ReadWrite,
+		MaxPerms: hostarch.AnyAccess,
+	}); !linuxerr.Equals(linuxerr.ENOMEM, err) {
+		t.Errorf("MMap above RLIMIT_DATA got err %v want ENOMEM", err)
+	}
+	addr, err := mm.MMap(ctx, memmap.MMapOpts{
+		Length:   2 * hostarch.PageSize,
+		Private:  true,
+		Perms:    hostarch.ReadWrite,
+		MaxPerms: hostarch.AnyAccess,
+	})
+	if err != nil {
+		t.Fatalf("MMap within RLIMIT_DATA got err %v want nil", err)
+	}
+
+	// Growing the mapping would exceed the limit.
+	if _, err := mm.MRemap(ctx, addr, 2*hostarch.PageSize, 3*hostarch.PageSize, MRemapOpts{
+		Move: MRemapMayMove,
+	}); !linuxerr.Equals(linuxerr.ENOMEM, err) {
+		t.Errorf("MRemap above RLIMIT_DATA got err %v want ENOMEM", err)
+	}
+
+	// Read-only mappings don't count against RLIMIT_DATA.
+	roAddr, err := mm.MMap(ctx, memmap.MMapOpts{
+		Length:   3 * hostarch.PageSize,
+		Private:  true,
+		Perms:    hostarch.Read,
+		MaxPerms: hostarch.AnyAccess,
+	})
+	if err != nil {
+		t.Fatalf("read-only MMap got err %v want nil", err)
+	}
+
+	// But making them writable does.
+	if err := mm.MProtect(ctx, roAddr, 3*hostarch.PageSize, hostarch.ReadWrite, false); !linuxerr.Equals(linuxerr.ENOMEM, err) {
+		t.Errorf("MProtect above RLIMIT_DATA got err %v want ENOMEM", err)
+	}
+	if err := mm.MUnmap(ctx, addr, 2*hostarch.PageSize); err != nil {
+		t.Fatalf("MUnmap got err %v want nil", err)
+	}
+	if err := mm.MProtect(ctx, roAddr, 2*hostarch.PageSize, hostarch.ReadWrite, false); err != nil {
+		t.Errorf("MProtect within RLIMIT_DATA got err %v want nil", err)
+	}
+	if mm.dataAS != 2*hostarch.PageSize {
+		t.Errorf("dataAS is %d want %d", mm.dataAS, 2*hostarch.PageSize)
+	}
+}
+
 // TestIOAfterUnmap ensures that IO fails after unmap.
 func TestIOAfterUnmap(t *testing.T) {
 	ctx := contexttest.Context(t)
@@ -205,7 +265,7 @@ func TestIOAfterMProtect(t *testing.T) {
 		t.Errorf("CopyOut got %d want 1", n)
 	}
 
-	err = mm.MProtect(addr, hostarch.PageSize, hostarch.Read, false)
+	err = mm.MProtect(ctx, addr, hostarch.PageSize, hostarch.Read, false)
 	if err != nil {
 		t.Errorf("MProtect got err %v want nil", err)
 	}
@@ -231,6 +291,68 @@ func TestIOAfterMProtect(t *testing.T) {
 	}
 }
 
+// TestForkCopyOnWrite tests that private memory written after fork is not
+// shared between the parent and child.
+func TestForkCopyOnWrite(t *testing.T) {
+	ctx := contexttest.Context(t)
+	mm := testMemoryManager(ctx)
+	defer mm.DecUsers(ctx)
+
+	const pages = 4
+	addr, err := mm.MMap(ctx, memmap.MMapOpts{
+		Length:   pages * hostarch.PageSize,
+		Private:  true,
+		Perms:    hostarch.ReadWrite,
+		MaxPerms: hostarch.AnyAccess,
+	})
+	if err != nil {
+		t.Fatalf("MMap got err %v want nil", err)
+	}
+	for i := 0; i < pages; i++ {
+		if _, err := mm.CopyOut(ctx, addr+hostarch.Addr(i*hostarch.PageSize), []byte{byte(i)}, usermem.IOOpts{}); err != nil {
+			t.Fatalf("CopyOut to page %d got err %v want nil", i, err)
+		}
+	}
+	// Split the pmas with a read-only page.
+	if err := mm.MProtect(ctx, addr+hostarch.PageSize, hostarch.PageSize, hostarch.Read, false); err != nil {
+		t.Fatalf("MProtect got err %v want nil", err)
+	}
+
+	mm2, err := mm.Fork(ctx)
+	if err != nil {
+		t.Fatalf("Fork got err %v want nil", err)
+	}
+	defer mm2.DecUsers(ctx)
+
+	// Write to the first page in the parent and the last page in the child.
+	if _, err := mm.CopyOut(ctx, addr, []byte{0xff}, usermem.IOOpts{}); err != nil {
+		t.Fatalf("CopyOut to parent got err %v want nil", err)
+	}
+	lastAddr := addr + hostarch.Addr((pages-1)*hostarch.PageSize)
+	if _, err := mm2.CopyOut(ctx, lastAddr, []byte{0xfe}, usermem.IOOpts{}); err != nil {
+		t.Fatalf("CopyOut to child got err %v want nil", err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		mm   *MemoryManager
+		want []byte
+	}{
+		{"parent", mm, []byte{0xff, 1, 2, 3}},
+		{"child", mm2, []byte{0, 1, 2, 0xfe}},
+	} {
+		for i := 0; i < pages; i++ {
+			b := make([]byte, 1)
+			if _, err := tc.mm.CopyIn(ctx, addr+hostarch.Addr(i*hostarch.PageSize), b, usermem.IOOpts{}); err != nil {
+				t.Fatalf("CopyIn from %s page %d got err %v want nil", tc.name, i, err)
+			}
+			if b[0] != tc.want[i] {
+				t.Errorf("%s page %d got %d want %d", tc.name, i, b[0], tc.want[i])
+			}
+		}
+	}
+}
+
 // TestAIOPrepareAfterDestroy tests that AIOContext should not be able to be
 // prepared after destruction.
 func TestAIOPrepareAfterDestroy(t *testing.T) {
@@ -502,6 +502,14 @@ func (mm *MemoryManager) MRemap(ctx context.Context, oldAddr hostarch.Addr, oldS
 		return 0, linuxerr.ENOMEM
 	}
 
+	// Check against RLIMIT_DATA.
+	if vseg.ValuePtr().isPrivateDataLocked() {
+		newDataAS := mm.dataAS - uint64(oldAR.Length()) + uint64(newAR.Length())
+		if limitData := limits.FromContext(ctx).Get(limits.Data).Cur; newDataAS > limitData {
+			return 0, linuxerr.ENOMEM
+		}
+	}
+
 	if vma := vseg.ValuePtr(); vma.mappable != nil {
 		// Check that offset+length does not overflow.
 		if vma.off+uint64(newAR.Length()) < vma.off {
@@ -585,7 +593,7 @@ func (mm *MemoryManager) MRemap(ctx context.Context, oldAddr hostarch.Addr, oldS
 }
 
 // MProtect implements the semantics of Linux's mprotect(2).
-func (mm *MemoryManager) MProtect(addr hostarch.Addr, length uint64, realPerms hostarch.AccessType, growsDown bool) error {
+func (mm *MemoryManager) MProtect(ctx context.Context, addr hostarch.Addr, length uint64, realPerms hostarch.AccessType, growsDown bool) error {
 	if addr.RoundDown() != addr {
 		return linuxerr.EINVAL
 	}
@@ -601,6 +609,7 @@ func (mm *MemoryManager) MProtect(addr hostarch.Addr, length uint64, realPerms h
 		return linuxerr.ENOMEM
 	}
 	effectivePerms := realPerms.Effective()
+	limitData := limits.FromContext(ctx).Get(limits.Data).Cur
 
 	mm.mappingMu.Lock()
 	defer mm.mappingMu.Unlock()
@@ -645,9 +654,17 @@ func (mm *MemoryManager) MProtect(addr hostarch.Addr, length uint64, realPerms h
 		}
 		vseg = mm.vmas.Isolate(vseg, ar)
 
-		// Update vma permissions.
+		// Check against RLIMIT_DATA if the vma becomes a private data mapping.
+		// Compare Linux's mm/mprotect.c:mprotect_fixup().
 		vma := vseg.ValuePtr()
 		vmaLength := vseg.Range().Length()
+		if realPerms.Write && vma.private && !vma.growsDown && !vma.isPrivateDataLocked() {
+			if mm.dataAS+uint64(vmaLength) > limitData {
+				return linuxerr.ENOMEM
+			}
+		}
+
+		// Update vma permissions.
 		if vma.isPrivateDataLocked() {
 			mm.dataAS -= uint64(vmaLength)
 		}
@@ -711,11 +728,10 @@ func (mm *MemoryManager) Brk(ctx context.Context, addr hostarch.Addr) (hostarch.
 		return addr, linuxerr.EINVAL
 	}
 
-	// TODO(gvisor.dev/issue/156): This enforces RLIMIT_DATA, but is
-	// slightly more permissive than the usual data limit. In particular,
-	// this only limits the size of the heap; a true RLIMIT_DATA limits the
-	// size of heap + data + bss. The segment sizes need to be plumbed from
-	// the loader package to fully enforce RLIMIT_DATA.
+	// This only limits the size of the heap. The size of the heap and all
+	// other private data mappings, including the data and bss segments, is
+	// limited by createVMALocked below, like Linux's mm/mmap.c:brk() =>
+	// do_brk_flags() => may_expand_vm().
 	if uint64(addr-mm.brk.Start) > limits.FromContext(ctx).Get(limits.Data).Cur {
 		addr = mm.brk.End
 		mm.mappingMu.Unlock()
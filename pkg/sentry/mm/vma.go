@@ -62,6 +62,18 @@ func (mm *MemoryManager) createVMALocked(ctx context.Context, opts memmap.MMapOp
 		return vmaIterator{}, hostarch.AddrRange{}, linuxerr.ENOMEM
 	}
 
+	// Check against RLIMIT_DATA, which applies to all private data mappings
+	// including the heap. Compare Linux's mm/mmap.c:may_expand_vm().
+	if opts.Perms.Write && opts.Private && !opts.GrowsDown {
+		newDataAS := mm.dataAS + opts.Length
+		if opts.Unmap {
+			newDataAS -= mm.privateDataBytesRangeLocked(ar)
+		}
+		if limitData := limits.FromContext(ctx).Get(limits.Data).Cur; newDataAS > limitData {
+			return vmaIterator{}, hostarch.AddrRange{}, linuxerr.ENOMEM
+		}
+	}
+
 	if opts.MLockMode != memmap.MLockNone {
 		// Check against RLIMIT_MEMLOCK.
 		if creds := auth.CredentialsFromContext(ctx); !creds.HasCapabilityIn(linux.CAP_IPC_LOCK, creds.UserNamespace.Root()) {
@@ -250,6 +262,20 @@ func (mm *MemoryManager) mlockedBytesRangeLocked(ar hostarch.AddrRange) uint64 {
 	return total
 }
 
+// privateDataBytesRangeLocked returns the number of bytes in ar that belong to
+// private data vmas, as defined by vma.isPrivateDataLocked.
+//
+// Preconditions: mm.mappingMu must be locked.
+func (mm *MemoryManager) privateDataBytesRangeLocked(ar hostarch.AddrRange) uint64 {
+	var total uint64
+	for vseg := mm.vmas.LowerBoundSegment(ar.Start); vseg.Ok() && vseg.Start() < ar.End; vseg = vseg.NextSegment() {
+		if vseg.ValuePtr().isPrivateDataLocked() {
+			total += uint64(vseg.Range().Intersect(ar).Length())
+		}
+	}
+	return total
+}
+
 // getVMAsLocked ensures that vmas exist for all addresses in ar, and support
 // access of type (at, ignorePermissions). It returns:
 //
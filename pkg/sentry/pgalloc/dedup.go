@@ -0,0 +1,160 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pgalloc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/maphash"
+	"io"
+
+	"gvisor.dev/gvisor/pkg/hostarch"
+	"gvisor.dev/gvisor/pkg/sentry/memmap"
+	"gvisor.dev/gvisor/pkg/state/wire"
+)
+
+// Deduplicated pages are saved as a sequence of records, one per page. Each
+// record starts with a uvarint, which is either pageLiteral followed by the
+// contents of the page, or 1 + the offset in the MemoryFile of an identical
+// page saved before.
+const pageLiteral = 0
+
+// dedupSaver saves committed pages, replacing pages identical to a page saved
+// before by a reference to it. Identical pages are common across processes,
+// e.g. pages of shared libraries copied to memory by each container.
+type dedupSaver struct {
+	f *MemoryFile
+	w wire.Writer
+
+	// h hashes the contents of pages.
+	h maphash.Hash
+
+	// offsets maps hashes of the pages saved so far to their offset in f.
+	offsets map[uint64]uint64
+
+	// scratch is used to encode records.
+	scratch [binary.MaxVarintLen64]byte
+
+	// pages and deduped are the number of pages saved, and of the ones
+	// replaced by a reference.
+	pages   uint64
+	deduped uint64
+}
+
+func newDedupSaver(f *MemoryFile, w wire.Writer) *dedupSaver {
+	return &dedupSaver{
+		f:       f,
+		w:       w,
+		offsets: make(map[uint64]uint64),
+	}
+}
+
+// save saves the pages in fr.
+func (d *dedupSaver) save(fr memmap.FileRange) error {
+	var ioErr error
+	off := fr.Start
+	err := d.f.forEachMappingSlice(fr, func(s []byte) {
+		for i := 0; i < len(s) && ioErr == nil; i += hostarch.PageSize {
+			ioErr = d.savePage(off, s[i:i+hostarch.PageSize])
+			off += hostarch.PageSize
+		}
+	})
+	if ioErr != nil {
+		return ioErr
+	}
+	return err
+}
+
+// savePage saves the page at offset off, with contents pg.
+func (d *dedupSaver) savePage(off uint64, pg []byte) error {
+	d.pages++
+	d.h.Reset()
+	d.h.Write(pg)
+	sum := d.h.Sum64()
+	if prev, ok := d.offsets[sum]; ok {
+		prevPg, err := d.f.page(prev)
+		if err != nil {
+			return err
+		}
+		// Pages with the same hash are almost always identical, but hash
+		// collisions must not corrupt memory.
+		if bytes.Equal(pg, prevPg) {
+			d.deduped++
+			return d.writeUvarint(prev + 1)
+		}
+	} else {
+		d.offsets[sum] = off
+	}
+	if err := d.writeUvarint(pageLiteral); err != nil {
+		return err
+	}
+	_, err := d.w.Write(pg)
+	return err
+}
+
+func (d *dedupSaver) writeUvarint(v uint64) error {
+	n := binary.PutUvarint(d.scratch[:], v)
+	_, err := d.w.Write(d.scratch[:n])
+	return err
+}
+
+// loadDedup loads the pages in fr, saved by dedupSaver.
+func (f *MemoryFile) loadDedup(r wire.Reader, fr memmap.FileRange) error {
+	var ioErr error
+	off := fr.Start
+	err := f.forEachMappingSlice(fr, func(s []byte) {
+		for i := 0; i < len(s) && ioErr == nil; i += hostarch.PageSize {
+			ioErr = f.loadDedupPage(r, off, s[i:i+hostarch.PageSize])
+			off += hostarch.PageSize
+		}
+	})
+	if ioErr != nil {
+		return ioErr
+	}
+	return err
+}
+
+// loadDedupPage loads the page at offset off, with contents pg.
+func (f *MemoryFile) loadDedupPage(r wire.Reader, off uint64, pg []byte) error {
+	v, err := binary.ReadUvarint(r)
+	if err != nil {
+		return err
+	}
+	if v == pageLiteral {
+		_, err := io.ReadFull(r, pg)
+		return err
+	}
+	prev := v - 1
+	// Pages only refer to pages loaded before them.
+	if prev >= off || prev%hostarch.PageSize != 0 {
+		return fmt.Errorf("invalid reference to page at offset %#x from page at offset %#x", prev, off)
+	}
+	prevPg, err := f.page(prev)
+	if err != nil {
+		return err
+	}
+	copy(pg, prevPg)
+	return nil
+}
+
+// page returns the contents of the page at offset off.
+func (f *MemoryFile) page(off uint64) ([]byte, error) {
+	var pg []byte
+	err := f.forEachMappingSlice(memmap.FileRange{off, off + hostarch.PageSize}, func(s []byte) {
+		pg = s
+	})
+	return pg, err
+}
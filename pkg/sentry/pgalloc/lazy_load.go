@@ -0,0 +1,228 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pgalloc
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"gvisor.dev/gvisor/pkg/hostarch"
+	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/pkg/sentry/memmap"
+	"gvisor.dev/gvisor/pkg/sync"
+)
+
+// lazyBlockSize is the granularity at which pages saved to a pages file are
+// loaded on demand.
+const lazyBlockSize = hostarch.HugePageSize
+
+// States of a lazyBlock.
+const (
+	blockPending = iota
+	blockLoading
+	blockLoaded
+)
+
+// lazyBlock is a range of pages saved to the pages file.
+type lazyBlock struct {
+	// fr is the range of the pages in the MemoryFile.
+	fr memmap.FileRange
+
+	// off is the offset of the pages in the pages file.
+	off int64
+
+	// state is blockPending, blockLoading or blockLoaded.
+	state int
+}
+
+// lazyLoader loads the pages saved to a pages file after restore, either in
+// the background or when they are first accessed.
+type lazyLoader struct {
+	// file is the pages file. It is closed once all blocks are loaded.
+	file *os.File
+
+	// start is the time loading started.
+	start time.Time
+
+	mu sync.Mutex
+
+	// cond is broadcast when a block is loaded.
+	cond sync.Cond
+
+	// blocks are the blocks to load, sorted by fr. The slice is immutable,
+	// the state of its blocks is protected by mu.
+	blocks []lazyBlock
+
+	// remaining is the number of blocks that aren't loaded yet. It is
+	// protected by mu.
+	remaining int
+
+	// canceled is set once the MemoryFile is destroyed, after which pending
+	// blocks are dropped rather than loaded. It is protected by mu.
+	canceled bool
+
+	// done is closed when the background goroutine exits.
+	done chan struct{}
+}
+
+// startLazyLoad starts loading the pages of segments from file in the
+// background, in order. segments are the committed ranges of f, sorted, and
+// are stored contiguously in file.
+func (f *MemoryFile) startLazyLoad(file *os.File, segments []memmap.FileRange) {
+	l := &lazyLoader{
+		file:  file,
+		start: time.Now(),
+		done:  make(chan struct{}),
+	}
+	l.cond.L = &l.mu
+	var off int64
+	for _, fr := range segments {
+		for start := fr.Start; start < fr.End; start += lazyBlockSize {
+			end := start + lazyBlockSize
+			if end > fr.End {
+				end = fr.End
+			}
+			l.blocks = append(l.blocks, lazyBlock{
+				fr:  memmap.FileRange{start, end},
+				off: off,
+			})
+			off += int64(end - start)
+		}
+	}
+	l.remaining = len(l.blocks)
+	if l.remaining == 0 {
+		file.Close()
+		close(l.done)
+		f.lazy = l
+		return
+	}
+	log.Infof("Loading %d bytes of memory lazily", off)
+	f.lazy = l
+	atomic.StoreInt32(&f.lazyLoading, 1)
+	go f.runLazyLoad() // S/R-SAFE: the MemoryFile isn't saved while loading.
+}
+
+// runLazyLoad implements the background goroutine loading pending blocks.
+func (f *MemoryFile) runLazyLoad() {
+	l := f.lazy
+	defer close(l.done)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i := range l.blocks {
+		if l.canceled {
+			return
+		}
+		f.loadBlockLocked(i)
+	}
+}
+
+// awaitLoad blocks until the pages in fr that were saved to the pages file
+// are loaded, loading them if needed. It must be called before pages are
+// accessed, or decommitted since the background goroutine would otherwise
+// overwrite them.
+func (f *MemoryFile) awaitLoad(fr memmap.FileRange) {
+	if atomic.LoadInt32(&f.lazyLoading) == 0 {
+		return
+	}
+	l := f.lazy
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	i := sort.Search(len(l.blocks), func(i int) bool {
+		return l.blocks[i].fr.End > fr.Start
+	})
+	for ; i < len(l.blocks) && l.blocks[i].fr.Start < fr.End; i++ {
+		f.loadBlockLocked(i)
+	}
+}
+
+// AwaitLoad blocks until the pages in fr are loaded after restore. It must be
+// called before pages of f are mapped into application address spaces, which
+// don't go through f.MapInternal.
+func (f *MemoryFile) AwaitLoad(fr memmap.FileRange) {
+	f.awaitLoad(fr)
+}
+
+// loadBlockLocked loads the block at index i of f.lazy.blocks, or waits for
+// it to be loaded by another goroutine.
+//
+// Preconditions: f.lazy.mu must be locked.
+func (f *MemoryFile) loadBlockLocked(i int) {
+	l := f.lazy
+	b := &l.blocks[i]
+	for b.state == blockLoading {
+		l.cond.Wait()
+	}
+	if b.state == blockLoaded {
+		return
+	}
+
+	if !l.canceled {
+		b.state = blockLoading
+		l.mu.Unlock()
+		off := b.off
+		var ioErr error
+		err := f.forEachMappingSlice(b.fr, func(s []byte) {
+			if ioErr != nil {
+				return
+			}
+			_, ioErr = l.file.ReadAt(s, off)
+			off += int64(len(s))
+		})
+		l.mu.Lock()
+		if ioErr != nil {
+			err = ioErr
+		}
+		if err != nil {
+			// The contents of application memory are lost.
+			panic(fmt.Sprintf("loading pages %v from offset %d of the pages file failed: %v", b.fr, b.off, err))
+		}
+	}
+	b.state = blockLoaded
+	l.cond.Broadcast()
+
+	l.remaining--
+	if l.remaining == 0 {
+		atomic.StoreInt32(&f.lazyLoading, 0)
+		l.file.Close()
+		if !l.canceled {
+			log.Infof("Lazy memory load took [%s].", time.Since(l.start))
+		}
+	}
+}
+
+// waitLazyLoad blocks until all pages saved to the pages file are loaded.
+func (f *MemoryFile) waitLazyLoad() {
+	if f.lazy != nil {
+		<-f.lazy.done
+	}
+}
+
+// cancelLazyLoad stops loading pages, which are dropped.
+func (f *MemoryFile) cancelLazyLoad() {
+	l := f.lazy
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	l.canceled = true
+	for i := range l.blocks {
+		f.loadBlockLocked(i)
+	}
+	l.mu.Unlock()
+	<-l.done
+}
@@ -182,6 +182,14 @@ type MemoryFile struct {
 	// notifications used to drive eviction. stopNotifyPressure is
 	// immutable.
 	stopNotifyPressure func()
+
+	// lazy loads the pages saved to a pages file, if any. lazy is set by
+	// LoadFrom and is immutable thereafter.
+	lazy *lazyLoader
+
+	// lazyLoading is non-zero while pages are being loaded by lazy. It is
+	// accessed using atomic memory operations.
+	lazyLoading int32
 }
 
 // MemoryFileOpts provides options to NewMemoryFile.
@@ -393,6 +401,8 @@ func NewMemoryFile(file *os.File, opts MemoryFileOpts) (*MemoryFile, error) {
 //
 // Postconditions: None of f's methods may be called after Destroy.
 func (f *MemoryFile) Destroy() {
+	f.cancelLazyLoad()
+
 	f.mu.Lock()
 	defer f.mu.Unlock()
 	f.destroyed = true
@@ -610,6 +620,7 @@ func (f *MemoryFile) Decommit(fr memmap.FileRange) error {
 	if !fr.WellFormed() || fr.Length() == 0 || fr.Start%hostarch.PageSize != 0 || fr.End%hostarch.PageSize != 0 {
 		panic(fmt.Sprintf("invalid range: %v", fr))
 	}
+	f.awaitLoad(fr)
 
 	if f.opts.ManualZeroing {
 		// FALLOC_FL_PUNCH_HOLE may not zero pages if ManualZeroing is in
@@ -731,6 +742,7 @@ func (f *MemoryFile) MapInternal(fr memmap.FileRange, at hostarch.AccessType) (s
 	if at.Execute {
 		return safemem.BlockSeq{}, linuxerr.EACCES
 	}
+	f.awaitLoad(fr)
 
 	chunks := ((fr.End + chunkMask) >> chunkShift) - (fr.Start >> chunkShift)
 	if chunks == 1 {
@@ -885,9 +897,47 @@ func (f *MemoryFile) MarkAllUnevictable(user EvictableMemoryUser) {
 // evictable memory. The value returned by ShouldCacheEvictable may change
 // between calls.
 func (f *MemoryFile) ShouldCacheEvictable() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	return f.opts.DelayedEviction == DelayedEvictionManual || f.opts.UseHostMemcgPressure
 }
 
+// DelayedEviction returns the current delayed eviction mode of f.
+func (f *MemoryFile) DelayedEviction() DelayedEvictionType {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.opts.DelayedEviction
+}
+
+// SetDelayedEviction changes the delayed eviction mode of f. Only switching
+// between DelayedEvictionEnabled and DelayedEvictionDisabled is supported,
+// since users of DelayedEvictionManual rely on evictions only happening when
+// they request them. Switching to DelayedEvictionDisabled evicts all pending
+// evictable allocations.
+func (f *MemoryFile) SetDelayedEviction(de DelayedEvictionType) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if de != DelayedEvictionEnabled && de != DelayedEvictionDisabled {
+		return fmt.Errorf("invalid delayed eviction mode: %v", de)
+	}
+	if f.opts.DelayedEviction == DelayedEvictionManual {
+		return fmt.Errorf("delayed eviction mode can't be changed from manual")
+	}
+	if f.opts.DelayedEviction == de {
+		return nil
+	}
+	if de == DelayedEvictionEnabled && f.opts.UseHostMemcgPressure {
+		// The memcg pressure notifier is only registered when the MemoryFile is
+		// created with delayed eviction enabled.
+		return fmt.Errorf("delayed eviction can't be enabled with host memcg pressure")
+	}
+	f.opts.DelayedEviction = de
+	if de == DelayedEvictionDisabled {
+		f.startEvictionsLocked()
+	}
+	return nil
+}
+
 // UpdateUsage ensures that the memory usage statistics in
 // usage.MemoryAccounting are up to date.
 func (f *MemoryFile) UpdateUsage() error {
@@ -1116,6 +1166,7 @@ func (f *MemoryFile) runReclaim() {
 		if !ok {
 			break
 		}
+		f.awaitLoad(fr)
 
 		if f.opts.ManualZeroing {
 			// If ManualZeroing is in effect, only hugepage-aligned regions may
@@ -19,19 +19,47 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"os"
 	"runtime"
 	"sync/atomic"
 
 	"golang.org/x/sys/unix"
 	"gvisor.dev/gvisor/pkg/hostarch"
 	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/pkg/sentry/memmap"
 	"gvisor.dev/gvisor/pkg/sentry/usage"
 	"gvisor.dev/gvisor/pkg/state"
 	"gvisor.dev/gvisor/pkg/state/wire"
 )
 
+// SaveOpts provides options to MemoryFile.SaveTo.
+type SaveOpts struct {
+	// If PagesFile is not nil, committed pages are written to it rather than
+	// to the state stream, so that they can be loaded lazily on restore.
+	PagesFile *os.File
+
+	// If Dedup is true, pages identical to a page saved before are replaced
+	// by a reference to it in the state stream. It's ignored if PagesFile is
+	// set.
+	Dedup bool
+}
+
+// LoadOpts provides options to MemoryFile.LoadFrom.
+type LoadOpts struct {
+	// PagesFile is the file committed pages were saved to, if any. Pages are
+	// then loaded in the background, or when they are first accessed.
+	// LoadFrom takes ownership of PagesFile.
+	PagesFile *os.File
+
+	// Dedup must be true if pages were saved with SaveOpts.Dedup.
+	Dedup bool
+}
+
 // SaveTo writes f's state to the given stream.
-func (f *MemoryFile) SaveTo(ctx context.Context, w wire.Writer) error {
+func (f *MemoryFile) SaveTo(ctx context.Context, w wire.Writer, opts SaveOpts) error {
+	// Pages that weren't loaded yet after a lazy restore can't be saved.
+	f.waitLazyLoad()
+
 	// Wait for reclaim.
 	f.mu.Lock()
 	defer f.mu.Unlock()
@@ -88,13 +116,29 @@ func (f *MemoryFile) SaveTo(ctx context.Context, w wire.Writer) error {
 	}
 
 	// Dump out committed pages.
+	var out io.Writer = w
+	var dedup *dedupSaver
+	if opts.PagesFile != nil {
+		out = opts.PagesFile
+	} else if opts.Dedup {
+		dedup = newDedupSaver(f, w)
+	}
 	for seg := f.usage.FirstSegment(); seg.Ok(); seg = seg.NextSegment() {
 		if !seg.Value().knownCommitted {
 			continue
 		}
-		// Write a header to distinguish from objects.
-		if err := state.WriteHeader(w, uint64(seg.Range().Length()), false); err != nil {
-			return err
+		// Write a header to distinguish from objects. Pages in the pages
+		// file are found using the segments, and need none.
+		if opts.PagesFile == nil {
+			if err := state.WriteHeader(w, uint64(seg.Range().Length()), false); err != nil {
+				return err
+			}
+		}
+		if dedup != nil {
+			if err := dedup.save(seg.Range()); err != nil {
+				return err
+			}
+			continue
 		}
 		// Write out data.
 		var ioErr error
@@ -102,7 +146,7 @@ func (f *MemoryFile) SaveTo(ctx context.Context, w wire.Writer) error {
 			if ioErr != nil {
 				return
 			}
-			_, ioErr = w.Write(s)
+			_, ioErr = out.Write(s)
 		})
 		if ioErr != nil {
 			return ioErr
@@ -111,12 +155,15 @@ func (f *MemoryFile) SaveTo(ctx context.Context, w wire.Writer) error {
 			return err
 		}
 	}
+	if dedup != nil {
+		log.Infof("Saved %d pages, %d deduplicated", dedup.pages, dedup.deduped)
+	}
 
 	return nil
 }
 
 // LoadFrom loads MemoryFile state from the given stream.
-func (f *MemoryFile) LoadFrom(ctx context.Context, r wire.Reader) error {
+func (f *MemoryFile) LoadFrom(ctx context.Context, r wire.Reader, opts LoadOpts) error {
 	// Load metadata.
 	if _, err := state.Load(ctx, r, &f.fileSize); err != nil {
 		return err
@@ -129,6 +176,9 @@ func (f *MemoryFile) LoadFrom(ctx context.Context, r wire.Reader) error {
 	if _, err := state.Load(ctx, r, &f.usage); err != nil {
 		return err
 	}
+	if opts.PagesFile != nil {
+		return f.loadLazily(opts.PagesFile)
+	}
 
 	// Try to map committed chunks concurrently: For any given chunk, either
 	// this loop or the following one will mmap the chunk first and cache it in
@@ -172,15 +222,19 @@ func (f *MemoryFile) LoadFrom(ctx context.Context, r wire.Reader) error {
 			return fmt.Errorf("mismatched segment: expected %d, got %d", expected, length)
 		}
 		// Read data.
-		var ioErr error
-		err = f.forEachMappingSlice(seg.Range(), func(s []byte) {
+		if opts.Dedup {
+			err = f.loadDedup(r, seg.Range())
+		} else {
+			var ioErr error
+			err = f.forEachMappingSlice(seg.Range(), func(s []byte) {
+				if ioErr != nil {
+					return
+				}
+				_, ioErr = io.ReadFull(r, s)
+			})
 			if ioErr != nil {
-				return
+				err = ioErr
 			}
-			_, ioErr = io.ReadFull(r, s)
-		})
-		if ioErr != nil {
-			return ioErr
 		}
 		if err != nil {
 			return err
@@ -195,6 +249,35 @@ func (f *MemoryFile) LoadFrom(ctx context.Context, r wire.Reader) error {
 	return nil
 }
 
+// loadLazily arranges for committed pages to be loaded from pagesFile after
+// LoadFrom returns.
+func (f *MemoryFile) loadLazily(pagesFile *os.File) error {
+	var (
+		segments []memmap.FileRange
+		size     int64
+	)
+	for seg := f.usage.FirstSegment(); seg.Ok(); seg = seg.NextSegment() {
+		if !seg.Value().knownCommitted {
+			continue
+		}
+		segments = append(segments, seg.Range())
+		size += int64(seg.Range().Length())
+		// See LoadFrom.
+		usage.MemoryAccounting.Inc(seg.End()-seg.Start(), seg.Value().kind)
+	}
+	fi, err := pagesFile.Stat()
+	if err != nil {
+		pagesFile.Close()
+		return err
+	}
+	if fi.Size() != size {
+		pagesFile.Close()
+		return fmt.Errorf("mismatched pages file: expected %d bytes, got %d", size, fi.Size())
+	}
+	f.startLazyLoad(pagesFile, segments)
+	return nil
+}
+
 // MemoryFileProvider provides the MemoryFile method.
 //
 // This type exists to work around a save/restore defect. The only object in a
@@ -0,0 +1,74 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replay
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"gvisor.dev/gvisor/pkg/sentry/time"
+)
+
+// clockInputSize is the size of a clock input: the clock ID as a
+// little-endian int32, followed by the time as a little-endian int64.
+const clockInputSize = 12
+
+// clocks implements time.Clocks, recording or replaying the times read.
+//
+// Updates of the timekeeping parameters used by the vDSO aren't recorded,
+// since the vDSO reads the TSC directly.
+type clocks struct {
+	log  *Log
+	live time.Clocks
+}
+
+// Clocks returns a time.Clocks that reads time from live and records it in
+// l, or replays it from l.
+func Clocks(l *Log, live time.Clocks) time.Clocks {
+	return &clocks{log: l, live: live}
+}
+
+// Update implements time.Clocks.Update.
+func (c *clocks) Update() (time.Parameters, bool, time.Parameters, bool) {
+	return c.live.Update()
+}
+
+// GetTime implements time.Clocks.GetTime.
+func (c *clocks) GetTime(id time.ClockID) (int64, error) {
+	if !c.log.Replaying() {
+		ns, err := c.live.GetTime(id)
+		if err == nil {
+			var data [clockInputSize]byte
+			binary.LittleEndian.PutUint32(data[:], uint32(id))
+			binary.LittleEndian.PutUint64(data[4:], uint64(ns))
+			c.log.record(KindClock, data[:])
+		}
+		return ns, err
+	}
+
+	data, ok := c.log.next(KindClock)
+	if !ok {
+		return c.live.GetTime(id)
+	}
+	if len(data) != clockInputSize {
+		c.log.diverge(KindClock, fmt.Sprintf("malformed clock input of %d bytes", len(data)))
+		return c.live.GetTime(id)
+	}
+	if recorded := time.ClockID(binary.LittleEndian.Uint32(data)); recorded != id {
+		c.log.diverge(KindClock, fmt.Sprintf("read of clock %d, recorded clock %d", id, recorded))
+		return c.live.GetTime(id)
+	}
+	return int64(binary.LittleEndian.Uint64(data[4:])), nil
+}
@@ -0,0 +1,54 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replay
+
+import (
+	"fmt"
+	"io"
+)
+
+// randomReader is an io.Reader of random bytes that records or replays the
+// bytes read.
+type randomReader struct {
+	log  *Log
+	live io.Reader
+}
+
+// RandomReader returns an io.Reader that reads random bytes from live and
+// records them in l, or replays them from l.
+func RandomReader(l *Log, live io.Reader) io.Reader {
+	return &randomReader{log: l, live: live}
+}
+
+// Read implements io.Reader.Read.
+func (r *randomReader) Read(p []byte) (int, error) {
+	if !r.log.Replaying() {
+		n, err := r.live.Read(p)
+		if n > 0 {
+			r.log.record(KindRandom, p[:n])
+		}
+		return n, err
+	}
+
+	data, ok := r.log.next(KindRandom)
+	if !ok {
+		return r.live.Read(p)
+	}
+	if len(data) > len(p) {
+		// The application is reading less than it did when recording.
+		r.log.diverge(KindRandom, fmt.Sprintf("read of %d random bytes, recorded %d", len(p), len(data)))
+	}
+	return copy(p, data), nil
+}
@@ -0,0 +1,182 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package replay records nondeterministic inputs to the sentry, and replays
+// them in a later run of the same container.
+//
+// Inputs are recorded in the order in which they are consumed, separately
+// for each kind of input. When replaying, each input is served from the
+// recording, in order, as long as the run doesn't diverge from the recorded
+// one. Once the recording of a kind of input is exhausted, or an input
+// doesn't match what was recorded, live inputs are used again and a warning
+// is logged.
+//
+// Known limitations:
+//
+//   - Only inputs that flow through the sentry are recorded: random bytes
+//     (getrandom(2), /dev/[u]random, AT_RANDOM) and clock reads. The output
+//     of the sentry's random generator is recorded through its seeds, so it
+//     must be reseeded at the same points, see rand.DisableTimedReseed. Time read
+//     through the vDSO is computed by the application from the TSC and isn't
+//     replayed.
+//
+//   - Results of host syscalls aren't recorded: file contents and metadata
+//     read through the gofer, and I/O on host FDs such as stdio. Runs are
+//     only deterministic if these are the same as when recording.
+//
+//   - Network packets aren't recorded, so runsc requires --network=none to
+//     record or replay.
+//
+//   - Thread scheduling isn't recorded, so the order in which inputs are
+//     consumed by multithreaded applications may differ between runs.
+package replay
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/pkg/sync"
+)
+
+// Kind identifies a kind of nondeterministic input.
+type Kind uint8
+
+const (
+	// KindRandom is a read of random bytes.
+	KindRandom Kind = iota + 1
+
+	// KindClock is a read of a sentry clock.
+	KindClock
+)
+
+// String implements fmt.Stringer.
+func (k Kind) String() string {
+	switch k {
+	case KindRandom:
+		return "random"
+	case KindClock:
+		return "clock"
+	default:
+		return fmt.Sprintf("Kind(%d)", uint8(k))
+	}
+}
+
+// recordHeaderSize is the size of the header preceding each input in a log:
+// the kind of input, followed by the length of the input as a little-endian
+// uint32.
+const recordHeaderSize = 5
+
+// Log records or replays nondeterministic inputs.
+type Log struct {
+	// mu protects the fields below.
+	mu sync.Mutex
+
+	// w is where inputs are recorded. It's nil when replaying.
+	w io.Writer
+
+	// inputs are the recorded inputs that haven't been replayed yet, for each
+	// kind of input. It's nil when recording.
+	inputs map[Kind][][]byte
+
+	// diverged is set for kinds of inputs that are no longer replayed.
+	diverged map[Kind]bool
+}
+
+// NewRecorder returns a Log that records inputs to w.
+func NewRecorder(w io.Writer) *Log {
+	return &Log{w: w}
+}
+
+// NewReplayer returns a Log that replays the inputs recorded in r.
+func NewReplayer(r io.Reader) (*Log, error) {
+	l := &Log{
+		inputs:   make(map[Kind][][]byte),
+		diverged: make(map[Kind]bool),
+	}
+	br := bufio.NewReader(r)
+	var hdr [recordHeaderSize]byte
+	for {
+		if _, err := io.ReadFull(br, hdr[:]); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("reading replay log: %w", err)
+		}
+		kind := Kind(hdr[0])
+		data := make([]byte, binary.LittleEndian.Uint32(hdr[1:]))
+		if _, err := io.ReadFull(br, data); err != nil {
+			return nil, fmt.Errorf("reading %s input from replay log: %w", kind, err)
+		}
+		l.inputs[kind] = append(l.inputs[kind], data)
+	}
+	for kind, inputs := range l.inputs {
+		log.Infof("Replay log contains %d %s inputs", len(inputs), kind)
+	}
+	return l, nil
+}
+
+// Replaying returns true if l replays inputs, and false if it records them.
+func (l *Log) Replaying() bool {
+	return l.w == nil
+}
+
+// record appends an input to the log. Errors are logged, but otherwise
+// ignored, so that recording never affects the application.
+func (l *Log) record(kind Kind, data []byte) {
+	var hdr [recordHeaderSize]byte
+	hdr[0] = byte(kind)
+	binary.LittleEndian.PutUint32(hdr[1:], uint32(len(data)))
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := l.w.Write(append(hdr[:], data...)); err != nil {
+		log.Warningf("Recording %s input: %v", kind, err)
+	}
+}
+
+// next returns the next recorded input of the given kind, or false if the
+// run has diverged from the recording.
+func (l *Log) next(kind Kind) ([]byte, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.diverged[kind] {
+		return nil, false
+	}
+	inputs := l.inputs[kind]
+	if len(inputs) == 0 {
+		l.divergeLocked(kind, "recording exhausted")
+		return nil, false
+	}
+	l.inputs[kind] = inputs[1:]
+	return inputs[0], true
+}
+
+// diverge stops replaying inputs of the given kind.
+func (l *Log) diverge(kind Kind, reason string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.divergeLocked(kind, reason)
+}
+
+// Preconditions: l.mu is locked.
+func (l *Log) divergeLocked(kind Kind, reason string) {
+	if l.diverged[kind] {
+		return
+	}
+	l.diverged[kind] = true
+	log.Warningf("Execution diverged from the recording (%s), %s inputs are live from now on", reason, kind)
+}
@@ -0,0 +1,145 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replay
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"gvisor.dev/gvisor/pkg/sentry/time"
+)
+
+// counterReader returns bytes from an incrementing counter.
+type counterReader struct {
+	next byte
+}
+
+func (r *counterReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = r.next
+		r.next++
+	}
+	return len(p), nil
+}
+
+// fakeClocks returns an incrementing time.
+type fakeClocks struct {
+	now int64
+}
+
+func (*fakeClocks) Update() (time.Parameters, bool, time.Parameters, bool) {
+	return time.Parameters{}, false, time.Parameters{}, false
+}
+
+func (c *fakeClocks) GetTime(time.ClockID) (int64, error) {
+	c.now += 1000
+	return c.now, nil
+}
+
+func readAll(t *testing.T, r io.Reader, sizes []int) [][]byte {
+	t.Helper()
+	var out [][]byte
+	for _, n := range sizes {
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			t.Fatalf("Read() failed: %v", err)
+		}
+		out = append(out, buf)
+	}
+	return out
+}
+
+func TestRecordReplay(t *testing.T) {
+	var buf bytes.Buffer
+	rec := NewRecorder(&buf)
+
+	sizes := []int{16, 1, 32}
+	recorded := readAll(t, RandomReader(rec, &counterReader{}), sizes)
+	recClocks := Clocks(rec, &fakeClocks{})
+	var recordedTimes []int64
+	for _, id := range []time.ClockID{time.Monotonic, time.Realtime, time.Monotonic} {
+		ns, err := recClocks.GetTime(id)
+		if err != nil {
+			t.Fatalf("GetTime(%v) failed: %v", id, err)
+		}
+		recordedTimes = append(recordedTimes, ns)
+	}
+
+	rep, err := NewReplayer(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("NewReplayer() failed: %v", err)
+	}
+	if !rep.Replaying() {
+		t.Fatalf("Replaying() = false, want true")
+	}
+
+	// Live inputs differ from the recorded ones.
+	replayed := readAll(t, RandomReader(rep, &counterReader{next: 100}), sizes)
+	for i := range recorded {
+		if !bytes.Equal(replayed[i], recorded[i]) {
+			t.Errorf("random read %d got %v, want %v", i, replayed[i], recorded[i])
+		}
+	}
+	repClocks := Clocks(rep, &fakeClocks{now: 1 << 40})
+	for i, id := range []time.ClockID{time.Monotonic, time.Realtime, time.Monotonic} {
+		ns, err := repClocks.GetTime(id)
+		if err != nil {
+			t.Fatalf("GetTime(%v) failed: %v", id, err)
+		}
+		if ns != recordedTimes[i] {
+			t.Errorf("clock read %d got %d, want %d", i, ns, recordedTimes[i])
+		}
+	}
+
+	// Once the recording is exhausted, live inputs are used.
+	if ns, err := repClocks.GetTime(time.Monotonic); err != nil || ns < 1<<40 {
+		t.Errorf("GetTime() after the recording got (%d, %v), want live time", ns, err)
+	}
+}
+
+func TestReplayDiverges(t *testing.T) {
+	var buf bytes.Buffer
+	rec := NewRecorder(&buf)
+	recClocks := Clocks(rec, &fakeClocks{})
+	for _, id := range []time.ClockID{time.Monotonic, time.Monotonic} {
+		if _, err := recClocks.GetTime(id); err != nil {
+			t.Fatalf("GetTime(%v) failed: %v", id, err)
+		}
+	}
+
+	rep, err := NewReplayer(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("NewReplayer() failed: %v", err)
+	}
+	repClocks := Clocks(rep, &fakeClocks{now: 1 << 40})
+
+	// Reading a different clock than recorded diverges, and the recording
+	// isn't used anymore even if the next read would match.
+	for _, id := range []time.ClockID{time.Realtime, time.Monotonic} {
+		if ns, err := repClocks.GetTime(id); err != nil || ns < 1<<40 {
+			t.Errorf("GetTime(%v) got (%d, %v), want live time", id, ns, err)
+		}
+	}
+}
+
+func TestReplayTruncatedLog(t *testing.T) {
+	var buf bytes.Buffer
+	rec := NewRecorder(&buf)
+	readAll(t, RandomReader(rec, &counterReader{}), []int{8})
+	if _, err := NewReplayer(bytes.NewReader(buf.Bytes()[:buf.Len()-1])); err == nil {
+		t.Errorf("NewReplayer() succeeded with a truncated log")
+	}
+}
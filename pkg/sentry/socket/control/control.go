@@ -151,7 +151,9 @@ func PackRights(t *kernel.Task, rights SCMRights, cloexec bool, buf []byte, flag
 //
 // +stateify savable
 type scmCredentials struct {
-	t    *kernel.Task
+	// tg is the sender's thread group, whose thread group ID is reported to
+	// the receiver.
+	tg   *kernel.ThreadGroup
 	kuid auth.KUID
 	kgid auth.KGID
 }
@@ -168,10 +170,19 @@ func NewSCMCredentials(t *kernel.Task, cred linux.ControlMessageCredentials) (SC
 	if err != nil {
 		return nil, err
 	}
-	if kernel.ThreadID(cred.PID) != t.ThreadGroup().ID() && !t.HasCapabilityIn(linux.CAP_SYS_ADMIN, t.PIDNamespace().UserNamespace()) {
-		return nil, linuxerr.EPERM
+	// The PID is in the sender's PID namespace. Only privileged senders can
+	// send the PID of another process.
+	pidns := t.PIDNamespace()
+	tg := t.ThreadGroup()
+	if kernel.ThreadID(cred.PID) != pidns.IDOfThreadGroup(tg) {
+		if !t.HasCapabilityIn(linux.CAP_SYS_ADMIN, pidns.UserNamespace()) {
+			return nil, linuxerr.EPERM
+		}
+		if tg = pidns.ThreadGroupWithID(kernel.ThreadID(cred.PID)); tg == nil {
+			return nil, linuxerr.ESRCH
+		}
 	}
-	return &scmCredentials{t, kuid, kgid}, nil
+	return &scmCredentials{tg, kuid, kgid}, nil
 }
 
 // Equals implements transport.CredentialsControlMessage.Equals.
@@ -263,7 +274,7 @@ func (c *scmCredentials) Credentials(t *kernel.Task) (kernel.ThreadID, auth.UID,
 	// of SCM_CREDENTIALS in unix(7)), they are translated into the
 	// corresponding values as per the receiving process's user and group ID
 	// mappings." - user_namespaces(7)
-	pid := t.PIDNamespace().IDOfTask(c.t)
+	pid := t.PIDNamespace().IDOfThreadGroup(c.tg)
 	uid := c.kuid.In(t.UserNamespace()).OrOverflow()
 	gid := c.kgid.In(t.UserNamespace()).OrOverflow()
 
@@ -287,6 +298,26 @@ func PackCredentials(t *kernel.Task, creds SCMCredentials, buf []byte, flags int
 	return putCmsg(buf, flags, linux.SCM_CREDENTIALS, align, c)
 }
 
+// PeerCredentials returns the credentials reported by SO_PEERCRED for the
+// peer credentials creds of a Unix socket, translated into t's namespaces.
+// Like Linux, the PID is 0 and the UID and GID are -1 if creds are unknown,
+// e.g. because the socket isn't connected.
+func PeerCredentials(t *kernel.Task, creds transport.CredentialsControlMessage) linux.ControlMessageCredentials {
+	c, ok := creds.(SCMCredentials)
+	if !ok {
+		return linux.ControlMessageCredentials{
+			UID: auth.NoID,
+			GID: auth.NoID,
+		}
+	}
+	pid, uid, gid := c.Credentials(t)
+	return linux.ControlMessageCredentials{
+		PID: int32(pid),
+		UID: uint32(uid),
+		GID: uint32(gid),
+	}
+}
+
 // alignSlice extends a slice's length (up to the capacity) to align it.
 func alignSlice(buf []byte, align uint) []byte {
 	aligned := bits.AlignUp(len(buf), align)
@@ -344,6 +375,28 @@ func PackTClass(t *kernel.Task, tClass uint32, buf []byte) []byte {
 	)
 }
 
+// PackTTL packs an IP_TTL socket control message.
+func PackTTL(t *kernel.Task, ttl uint32, buf []byte) []byte {
+	return putCmsgStruct(
+		buf,
+		linux.SOL_IP,
+		linux.IP_TTL,
+		t.Arch().Width(),
+		primitive.AllocateUint32(ttl),
+	)
+}
+
+// PackHopLimit packs an IPV6_HOPLIMIT socket control message.
+func PackHopLimit(t *kernel.Task, hoplimit uint32, buf []byte) []byte {
+	return putCmsgStruct(
+		buf,
+		linux.SOL_IPV6,
+		linux.IPV6_HOPLIMIT,
+		t.Arch().Width(),
+		primitive.AllocateUint32(hoplimit),
+	)
+}
+
 // PackIPPacketInfo packs an IP_PKTINFO socket control message.
 func PackIPPacketInfo(t *kernel.Task, packetInfo *linux.ControlMessageIPPacketInfo, buf []byte) []byte {
 	return putCmsgStruct(
@@ -419,6 +472,14 @@ func PackControlMessages(t *kernel.Task, cmsgs socket.ControlMessages, buf []byt
 		buf = PackTClass(t, cmsgs.IP.TClass, buf)
 	}
 
+	if cmsgs.IP.HasTTL {
+		buf = PackTTL(t, cmsgs.IP.TTL, buf)
+	}
+
+	if cmsgs.IP.HasHopLimit {
+		buf = PackHopLimit(t, cmsgs.IP.HopLimit, buf)
+	}
+
 	if cmsgs.IP.HasIPPacketInfo {
 		buf = PackIPPacketInfo(t, &cmsgs.IP.PacketInfo, buf)
 	}
@@ -464,6 +525,14 @@ func CmsgsSpace(t *kernel.Task, cmsgs socket.ControlMessages) int {
 		space += cmsgSpace(t, linux.SizeOfControlMessageTClass)
 	}
 
+	if cmsgs.IP.HasTTL {
+		space += cmsgSpace(t, linux.SizeOfControlMessageTTL)
+	}
+
+	if cmsgs.IP.HasHopLimit {
+		space += cmsgSpace(t, linux.SizeOfControlMessageHopLimit)
+	}
+
 	if cmsgs.IP.HasIPPacketInfo {
 		space += cmsgSpace(t, linux.SizeOfControlMessageIPPacketInfo)
 	}
@@ -669,7 +738,7 @@ func MakeCreds(t *kernel.Task) SCMCredentials {
 		return nil
 	}
 	tcred := t.Credentials()
-	return &scmCredentials{t, tcred.EffectiveKUID, tcred.EffectiveKGID}
+	return &scmCredentials{t.ThreadGroup(), tcred.EffectiveKUID, tcred.EffectiveKGID}
 }
 
 // LINT.IfChange
@@ -13,5 +13,6 @@
 // limitations under the License.
 
 // Package hostinet implements AF_INET and AF_INET6 sockets using the host's
-// network stack.
+// network stack, and AF_VSOCK sockets using the host's vsock transport or
+// UNIX sockets.
 package hostinet
@@ -14,7 +14,485 @@
 
 package hostinet
 
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/fdnotifier"
+	"gvisor.dev/gvisor/pkg/hostarch"
+	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/pkg/sync"
+)
+
 // beforeSave is invoked by stateify.
 func (*socketOperations) beforeSave() {
 	panic("host.socketOperations is not savable")
 }
+
+// savedSocket is the state of a host socket saved in checkpoints, from which
+// an equivalent host socket is created on restore.
+//
+// +stateify savable
+type savedSocket struct {
+	// local is the address the socket is bound to, or nil if it is not
+	// bound.
+	local []byte
+
+	// peer is the address the socket is connected to, or nil if it is not
+	// connected.
+	peer []byte
+
+	// listening is set if the socket is listening, with backlog.
+	listening bool
+	backlog   int
+
+	// opts are the values of the options in savedSockOpts.
+	opts []int
+
+	// tcp is the state of an established TCP connection, or nil.
+	tcp *tcpRepairState
+}
+
+// tcpRepairState is the state of an established TCP connection, saved and
+// restored using TCP_REPAIR. Saving it requires CAP_NET_ADMIN.
+//
+// +stateify savable
+type tcpRepairState struct {
+	// sendSeq and recvSeq are the sequence numbers following the data in
+	// the send and receive queues.
+	sendSeq uint32
+	recvSeq uint32
+
+	// sendQueue and recvQueue are the data in the send and receive queues.
+	// The send queue includes data that was sent but not acknowledged.
+	sendQueue []byte
+	recvQueue []byte
+
+	// options, windowScale and mss are the options negotiated with the
+	// peer, as reported by TCP_INFO.
+	options     uint8
+	windowScale uint8
+	mss         uint32
+
+	// timestamp is the TCP timestamp of the connection.
+	timestamp uint32
+
+	// window is the struct tcp_repair_window of the connection, or nil if
+	// the host doesn't support TCP_REPAIR_WINDOW.
+	window []byte
+}
+
+// sockOpt is an integer socket option.
+type sockOpt struct {
+	level int
+	name  int
+}
+
+// savedSockOpts returns the integer options that the application may set on
+// sockets of family, which are saved and restored along with them.
+func savedSockOpts(family int) []sockOpt {
+	opts := []sockOpt{
+		{linux.SOL_SOCKET, linux.SO_REUSEADDR},
+		{linux.SOL_SOCKET, linux.SO_TIMESTAMP},
+	}
+	switch family {
+	case unix.AF_INET:
+		opts = append(opts,
+			sockOpt{linux.SOL_IP, linux.IP_TOS},
+			sockOpt{linux.SOL_IP, linux.IP_RECVTOS},
+			sockOpt{linux.SOL_IP, linux.IP_RECVTTL},
+			sockOpt{linux.SOL_IP, linux.IP_PKTINFO},
+			sockOpt{linux.SOL_IP, linux.IP_RECVORIGDSTADDR},
+			sockOpt{linux.SOL_IP, linux.IP_RECVERR},
+		)
+	case unix.AF_INET6:
+		opts = append(opts,
+			sockOpt{linux.SOL_IPV6, linux.IPV6_V6ONLY},
+			sockOpt{linux.SOL_IPV6, linux.IPV6_TCLASS},
+			sockOpt{linux.SOL_IPV6, linux.IPV6_RECVTCLASS},
+			sockOpt{linux.SOL_IPV6, linux.IPV6_RECVHOPLIMIT},
+			sockOpt{linux.SOL_IPV6, linux.IPV6_RECVORIGDSTADDR},
+			sockOpt{linux.SOL_IPV6, linux.IPV6_RECVERR},
+		)
+	}
+	return opts
+}
+
+// savedSockets are the host sockets saved since the last abortSave, which
+// are restored to their state before the save if it fails.
+var savedSockets struct {
+	mu    sync.Mutex
+	socks map[*socketOpsCommon]struct{}
+}
+
+// beforeSave is invoked by stateify.
+//
+// Established TCP connections are left in repair mode, so that closing the
+// host socket when the sandbox exits after the checkpoint doesn't notify the
+// peer. They leave it in abortSave if the save fails.
+func (s *socketOpsCommon) beforeSave() {
+	saved, err := s.save()
+	if err != nil {
+		panic(fmt.Sprintf("saving host socket (family %d, type %d): %v", s.family, s.stype, err))
+	}
+	s.saved = saved
+
+	savedSockets.mu.Lock()
+	defer savedSockets.mu.Unlock()
+	if savedSockets.socks == nil {
+		savedSockets.socks = make(map[*socketOpsCommon]struct{})
+	}
+	savedSockets.socks[s] = struct{}{}
+}
+
+// abortSave resumes the TCP connections of the host sockets saved by a save
+// that failed, since the sandbox keeps running.
+func abortSave() {
+	savedSockets.mu.Lock()
+	defer savedSockets.mu.Unlock()
+	for s := range savedSockets.socks {
+		if s.saved.tcp != nil {
+			// The queues are unchanged, so leaving repair mode resumes the
+			// connection.
+			if err := unix.SetsockoptInt(s.fd, unix.SOL_TCP, linux.TCP_REPAIR, linux.TCP_REPAIR_OFF); err != nil {
+				log.Warningf("Disabling TCP_REPAIR on host socket %d after failed save: %v", s.fd, err)
+			}
+		}
+		s.saved = nil
+	}
+	savedSockets.socks = nil
+}
+
+// afterLoad is invoked by stateify.
+func (s *socketOpsCommon) afterLoad() {
+	if s.saved == nil {
+		panic("host socket was not saved")
+	}
+	fd, err := s.saved.restore(s.family, s.stype)
+	if err != nil {
+		panic(fmt.Sprintf("restoring host socket (family %d, type %d): %v", s.family, s.stype, err))
+	}
+	s.fd = fd
+	s.saved = nil
+	if err := fdnotifier.AddFD(int32(fd), &s.queue); err != nil {
+		panic(fmt.Sprintf("registering restored host socket: %v", err))
+	}
+}
+
+func (s *socketOpsCommon) save() (*savedSocket, error) {
+	if s.family != unix.AF_INET && s.family != unix.AF_INET6 {
+		return nil, fmt.Errorf("only AF_INET and AF_INET6 sockets are savable")
+	}
+	saved := &savedSocket{}
+	for _, opt := range savedSockOpts(s.family) {
+		v, err := unix.GetsockoptInt(s.fd, opt.level, opt.name)
+		if err != nil {
+			return nil, fmt.Errorf("getting option %d:%d: %v", opt.level, opt.name, err)
+		}
+		saved.opts = append(saved.opts, v)
+	}
+
+	local, err := getsockname(s.fd)
+	if err != nil {
+		return nil, fmt.Errorf("getting local address: %v", err)
+	}
+	if !isUnspecified(s.family, local) {
+		saved.local = local
+	}
+	peer, err := getpeername(s.fd)
+	switch err {
+	case nil:
+		saved.peer = peer
+	case unix.ENOTCONN:
+	default:
+		return nil, fmt.Errorf("getting peer address: %v", err)
+	}
+
+	if s.stype != linux.SOCK_STREAM {
+		return saved, nil
+	}
+	info, err := tcpInfo(s.fd)
+	if err != nil {
+		return nil, err
+	}
+	switch uint32(info.State) {
+	case linux.TCP_CLOSE:
+		if saved.peer != nil {
+			return nil, fmt.Errorf("connection is closed")
+		}
+	case linux.TCP_LISTEN:
+		saved.listening = true
+		saved.backlog = s.backlog
+	case linux.TCP_ESTABLISHED:
+		saved.tcp, err = saveTCPRepair(s.fd, &info)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("TCP connections in state %d are not savable", info.State)
+	}
+	return saved, nil
+}
+
+// saveTCPRepair returns the state of the established TCP connection of fd,
+// which is left in repair mode unless an error is returned.
+func saveTCPRepair(fd int, info *linux.TCPInfo) (_ *tcpRepairState, retErr error) {
+	if err := unix.SetsockoptInt(fd, unix.SOL_TCP, linux.TCP_REPAIR, linux.TCP_REPAIR_ON); err != nil {
+		return nil, fmt.Errorf("enabling TCP_REPAIR, which requires CAP_NET_ADMIN: %v", err)
+	}
+	defer func() {
+		if retErr == nil {
+			return
+		}
+		if err := unix.SetsockoptInt(fd, unix.SOL_TCP, linux.TCP_REPAIR, linux.TCP_REPAIR_OFF); err != nil {
+			log.Warningf("Disabling TCP_REPAIR after failed save: %v", err)
+		}
+	}()
+	st := &tcpRepairState{
+		options:     info.Options,
+		windowScale: info.WindowScale,
+		mss:         info.SndMss,
+	}
+	for _, q := range []struct {
+		queue int
+		ioctl uint
+		seq   *uint32
+		data  *[]byte
+	}{
+		{linux.TCP_SEND_QUEUE, unix.TIOCOUTQ, &st.sendSeq, &st.sendQueue},
+		{linux.TCP_RECV_QUEUE, unix.TIOCINQ, &st.recvSeq, &st.recvQueue},
+	} {
+		if err := unix.SetsockoptInt(fd, unix.SOL_TCP, linux.TCP_REPAIR_QUEUE, q.queue); err != nil {
+			return nil, fmt.Errorf("selecting queue %d: %v", q.queue, err)
+		}
+		seq, err := unix.GetsockoptInt(fd, unix.SOL_TCP, linux.TCP_QUEUE_SEQ)
+		if err != nil {
+			return nil, fmt.Errorf("getting sequence number of queue %d: %v", q.queue, err)
+		}
+		*q.seq = uint32(seq)
+		size, err := unix.IoctlGetInt(fd, q.ioctl)
+		if err != nil {
+			return nil, fmt.Errorf("getting size of queue %d: %v", q.queue, err)
+		}
+		if size == 0 {
+			continue
+		}
+		// In repair mode, peeking reads the selected queue.
+		data := make([]byte, size)
+		from := make([]byte, sizeofSockaddr)
+		n, err := recvfrom(fd, data, unix.MSG_PEEK|unix.MSG_DONTWAIT, &from)
+		if err != nil {
+			return nil, fmt.Errorf("reading queue %d: %v", q.queue, err)
+		}
+		if int(n) != size {
+			return nil, fmt.Errorf("reading queue %d: got %d bytes, want %d", q.queue, n, size)
+		}
+		*q.data = data
+	}
+	if err := unix.SetsockoptInt(fd, unix.SOL_TCP, linux.TCP_REPAIR_QUEUE, linux.TCP_NO_QUEUE); err != nil {
+		return nil, fmt.Errorf("deselecting queue: %v", err)
+	}
+
+	ts, err := unix.GetsockoptInt(fd, unix.SOL_TCP, linux.TCP_TIMESTAMP)
+	if err != nil {
+		return nil, fmt.Errorf("getting timestamp: %v", err)
+	}
+	st.timestamp = uint32(ts)
+	window, err := getsockopt(fd, unix.SOL_TCP, linux.TCP_REPAIR_WINDOW, linux.SizeOfTCPRepairWindow)
+	switch {
+	case err == nil && len(window) == linux.SizeOfTCPRepairWindow:
+		st.window = window
+	case err == nil || err == unix.ENOPROTOOPT:
+		// Not supported by the host, the window is probed after restore.
+		log.Infof("TCP_REPAIR_WINDOW isn't supported, not saving TCP window")
+	default:
+		return nil, fmt.Errorf("getting window: %v", err)
+	}
+	return st, nil
+}
+
+// restore creates a host socket from saved, and returns its FD.
+func (saved *savedSocket) restore(family int, stype linux.SockType) (int, error) {
+	// See socketProvider.Socket.
+	fd, err := unix.Socket(family, int(stype)|unix.SOCK_NONBLOCK|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return -1, fmt.Errorf("creating socket: %v", err)
+	}
+	if err := saved.restoreFD(fd, family); err != nil {
+		unix.Close(fd)
+		return -1, err
+	}
+	return fd, nil
+}
+
+func (saved *savedSocket) restoreFD(fd, family int) error {
+	for i, opt := range savedSockOpts(family) {
+		if i >= len(saved.opts) {
+			break
+		}
+		if err := unix.SetsockoptInt(fd, opt.level, opt.name, saved.opts[i]); err != nil {
+			return fmt.Errorf("setting option %d:%d: %v", opt.level, opt.name, err)
+		}
+	}
+	if saved.tcp != nil {
+		// Repair mode allows binding to the address of the old connection,
+		// and connecting without a handshake.
+		if err := unix.SetsockoptInt(fd, unix.SOL_TCP, linux.TCP_REPAIR, linux.TCP_REPAIR_ON); err != nil {
+			return fmt.Errorf("enabling TCP_REPAIR, which requires CAP_NET_ADMIN: %v", err)
+		}
+	}
+	if saved.local != nil {
+		if _, _, errno := unix.Syscall(unix.SYS_BIND, uintptr(fd), uintptr(firstBytePtr(saved.local)), uintptr(len(saved.local))); errno != 0 {
+			return fmt.Errorf("binding: %v", errno)
+		}
+	}
+	if saved.listening {
+		if err := unix.Listen(fd, saved.backlog); err != nil {
+			return fmt.Errorf("listening: %v", err)
+		}
+	}
+	if saved.tcp != nil {
+		if err := saved.tcp.restoreQueueSeqs(fd); err != nil {
+			return err
+		}
+	}
+	if saved.peer != nil {
+		if _, _, errno := unix.Syscall(unix.SYS_CONNECT, uintptr(fd), uintptr(firstBytePtr(saved.peer)), uintptr(len(saved.peer))); errno != 0 {
+			return fmt.Errorf("connecting: %v", errno)
+		}
+	}
+	if saved.tcp != nil {
+		return saved.tcp.restore(fd)
+	}
+	return nil
+}
+
+// restoreQueueSeqs sets the sequence numbers of the queues of fd, which must be
+// in repair mode and not yet connected.
+func (st *tcpRepairState) restoreQueueSeqs(fd int) error {
+	for _, q := range []struct {
+		queue int
+		seq   uint32
+	}{
+		// The queues are filled again after connecting, which advances the
+		// sequence numbers.
+		{linux.TCP_SEND_QUEUE, st.sendSeq - uint32(len(st.sendQueue))},
+		{linux.TCP_RECV_QUEUE, st.recvSeq - uint32(len(st.recvQueue))},
+	} {
+		if err := unix.SetsockoptInt(fd, unix.SOL_TCP, linux.TCP_REPAIR_QUEUE, q.queue); err != nil {
+			return fmt.Errorf("selecting queue %d: %v", q.queue, err)
+		}
+		if err := unix.SetsockoptInt(fd, unix.SOL_TCP, linux.TCP_QUEUE_SEQ, int(int32(q.seq))); err != nil {
+			return fmt.Errorf("setting sequence number of queue %d: %v", q.queue, err)
+		}
+	}
+	return nil
+}
+
+// restore restores the connection state of fd, which must be in repair mode
+// and connected, and leaves repair mode.
+func (st *tcpRepairState) restore(fd int) error {
+	var opts []byte
+	addOpt := func(code, val uint32) {
+		var buf [8]byte
+		hostarch.ByteOrder.PutUint32(buf[0:], code)
+		hostarch.ByteOrder.PutUint32(buf[4:], val)
+		opts = append(opts, buf[:]...)
+	}
+	addOpt(linux.TCPOPT_MSS, st.mss)
+	if st.options&linux.TCPI_OPT_WSCALE != 0 {
+		// snd_wscale and rcv_wscale are the low and high 4 bits of
+		// windowScale.
+		addOpt(linux.TCPOPT_WINDOW, uint32(st.windowScale&0xf)|uint32(st.windowScale>>4)<<16)
+	}
+	if st.options&linux.TCPI_OPT_SACK != 0 {
+		addOpt(linux.TCPOPT_SACK_PERM, 0)
+	}
+	if st.options&linux.TCPI_OPT_TIMESTAMPS != 0 {
+		addOpt(linux.TCPOPT_TIMESTAMP, 0)
+	}
+	if err := setsockopt(fd, unix.SOL_TCP, linux.TCP_REPAIR_OPTIONS, opts); err != nil {
+		return fmt.Errorf("setting options: %v", err)
+	}
+	if err := unix.SetsockoptInt(fd, unix.SOL_TCP, linux.TCP_TIMESTAMP, int(int32(st.timestamp))); err != nil {
+		return fmt.Errorf("setting timestamp: %v", err)
+	}
+
+	for _, q := range []struct {
+		queue int
+		data  []byte
+	}{
+		{linux.TCP_SEND_QUEUE, st.sendQueue},
+		{linux.TCP_RECV_QUEUE, st.recvQueue},
+	} {
+		if len(q.data) == 0 {
+			continue
+		}
+		if err := unix.SetsockoptInt(fd, unix.SOL_TCP, linux.TCP_REPAIR_QUEUE, q.queue); err != nil {
+			return fmt.Errorf("selecting queue %d: %v", q.queue, err)
+		}
+		// In repair mode, sending fills the selected queue.
+		for data := q.data; len(data) > 0; {
+			n, err := unix.Write(fd, data)
+			if err != nil {
+				return fmt.Errorf("filling queue %d: %v", q.queue, err)
+			}
+			data = data[n:]
+		}
+	}
+	if err := unix.SetsockoptInt(fd, unix.SOL_TCP, linux.TCP_REPAIR_QUEUE, linux.TCP_NO_QUEUE); err != nil {
+		return fmt.Errorf("deselecting queue: %v", err)
+	}
+
+	// The window must be restored last, since it is updated above.
+	if st.window != nil {
+		if err := setsockopt(fd, unix.SOL_TCP, linux.TCP_REPAIR_WINDOW, st.window); err != nil {
+			return fmt.Errorf("setting window: %v", err)
+		}
+	}
+	// Leaving repair mode sends a window probe, which lets the peer know
+	// about the restored connection.
+	if err := unix.SetsockoptInt(fd, unix.SOL_TCP, linux.TCP_REPAIR, linux.TCP_REPAIR_OFF); err != nil {
+		return fmt.Errorf("disabling TCP_REPAIR: %v", err)
+	}
+	return nil
+}
+
+// isUnspecified returns true if addr is the unspecified address of family
+// with port 0, i.e. the address of an unbound socket.
+func isUnspecified(family int, addr []byte) bool {
+	var zero []byte
+	switch family {
+	case unix.AF_INET:
+		zero = make([]byte, unix.SizeofSockaddrInet4)
+	case unix.AF_INET6:
+		zero = make([]byte, unix.SizeofSockaddrInet6)
+	default:
+		return false
+	}
+	if len(addr) != len(zero) {
+		return false
+	}
+	// Skip the family.
+	for i := 2; i < len(addr); i++ {
+		if addr[i] != zero[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// tcpInfo returns the TCP_INFO of TCP socket fd.
+func tcpInfo(fd int) (linux.TCPInfo, error) {
+	var info linux.TCPInfo
+	buf, err := getsockopt(fd, unix.SOL_TCP, unix.TCP_INFO, linux.SizeOfTCPInfo)
+	if err != nil {
+		return info, fmt.Errorf("getting TCP_INFO: %v", err)
+	}
+	if len(buf) != linux.SizeOfTCPInfo {
+		return info, fmt.Errorf("getting TCP_INFO: getsockopt(2) returned %d bytes, expecting %d bytes", len(buf), linux.SizeOfTCPInfo)
+	}
+	info.UnmarshalUnsafe(buf[:info.SizeBytes()])
+	return info, nil
+}
@@ -201,7 +201,19 @@ type socketOpsCommon struct {
 	// fd is the host socket fd. It must have O_NONBLOCK, so that operations
 	// will return EWOULDBLOCK instead of blocking on the host. This allows us to
 	// handle blocking behavior independently in the sentry.
-	fd int
+	//
+	// The host socket is recreated from saved on restore.
+	fd int `state:"nosave"`
+
+	// backlog is the backlog given to Listen.
+	backlog int
+
+	// saved is the state of the host socket while the socket is saved.
+	saved *savedSocket
+
+	// vsockPeerPort is the port that a vsock socket backed by a UNIX socket
+	// is connected to.
+	vsockPeerPort uint32
 }
 
 // Release implements fs.FileOperations.Release.
@@ -232,6 +244,14 @@ func (s *socketOpsCommon) Connect(t *kernel.Task, sockaddr []byte, blocking bool
 	if len(sockaddr) > sizeofSockaddr {
 		sockaddr = sockaddr[:sizeofSockaddr]
 	}
+	if s.vsockUDS() {
+		uaddr, port, err := vsockUDSAddr(sockaddr)
+		if err != nil {
+			return err
+		}
+		sockaddr = uaddr
+		s.vsockPeerPort = port
+	}
 
 	_, _, errno := unix.Syscall(unix.SYS_CONNECT, uintptr(s.fd), uintptr(firstBytePtr(sockaddr)), uintptr(len(sockaddr)))
 
@@ -344,6 +364,10 @@ func (s *socketOpsCommon) Accept(t *kernel.Task, peerRequested bool, flags int,
 
 // Bind implements socket.Socket.Bind.
 func (s *socketOpsCommon) Bind(_ *kernel.Task, sockaddr []byte) *syserr.Error {
+	if s.vsockUDS() {
+		// Only outgoing connections are supported by the UNIX socket backend.
+		return syserr.ErrEndpointOperation
+	}
 	if len(sockaddr) > sizeofSockaddr {
 		sockaddr = sockaddr[:sizeofSockaddr]
 	}
@@ -357,7 +381,14 @@ func (s *socketOpsCommon) Bind(_ *kernel.Task, sockaddr []byte) *syserr.Error {
 
 // Listen implements socket.Socket.Listen.
 func (s *socketOpsCommon) Listen(_ *kernel.Task, backlog int) *syserr.Error {
-	return syserr.FromError(unix.Listen(s.fd, backlog))
+	if s.vsockUDS() {
+		return syserr.ErrEndpointOperation
+	}
+	if err := unix.Listen(s.fd, backlog); err != nil {
+		return syserr.FromError(err)
+	}
+	s.backlog = backlog
+	return nil
 }
 
 // Shutdown implements socket.Socket.Shutdown.
@@ -376,17 +407,23 @@ func (s *socketOpsCommon) GetSockOpt(t *kernel.Task, level int, name int, _ host
 		return nil, syserr.ErrInvalidArgument
 	}
 
+	// Timeouts are handled by the sentry, since host sockets are always
+	// non-blocking.
+	if level == linux.SOL_SOCKET && (name == linux.SO_SNDTIMEO || name == linux.SO_RCVTIMEO) {
+		return s.GetSockOptTimeout(name, outLen)
+	}
+
 	// Only allow known and safe options.
 	optlen := getSockOptLen(t, level, name)
 	switch level {
 	case linux.SOL_IP:
 		switch name {
-		case linux.IP_TOS, linux.IP_RECVTOS, linux.IP_PKTINFO, linux.IP_RECVORIGDSTADDR, linux.IP_RECVERR:
+		case linux.IP_TOS, linux.IP_RECVTOS, linux.IP_RECVTTL, linux.IP_PKTINFO, linux.IP_RECVORIGDSTADDR, linux.IP_RECVERR:
 			optlen = sizeofInt32
 		}
 	case linux.SOL_IPV6:
 		switch name {
-		case linux.IPV6_TCLASS, linux.IPV6_RECVTCLASS, linux.IPV6_RECVERR, linux.IPV6_V6ONLY, linux.IPV6_RECVORIGDSTADDR:
+		case linux.IPV6_TCLASS, linux.IPV6_RECVTCLASS, linux.IPV6_RECVHOPLIMIT, linux.IPV6_RECVERR, linux.IPV6_V6ONLY, linux.IPV6_RECVORIGDSTADDR:
 			optlen = sizeofInt32
 		}
 	case linux.SOL_SOCKET:
@@ -422,17 +459,22 @@ func (s *socketOpsCommon) GetSockOpt(t *kernel.Task, level int, name int, _ host
 
 // SetSockOpt implements socket.Socket.SetSockOpt.
 func (s *socketOpsCommon) SetSockOpt(t *kernel.Task, level int, name int, opt []byte) *syserr.Error {
+	// See GetSockOpt.
+	if level == linux.SOL_SOCKET && (name == linux.SO_SNDTIMEO || name == linux.SO_RCVTIMEO) {
+		return s.SetSockOptTimeout(name, opt)
+	}
+
 	// Only allow known and safe options.
 	optlen := setSockOptLen(t, level, name)
 	switch level {
 	case linux.SOL_IP:
 		switch name {
-		case linux.IP_TOS, linux.IP_RECVTOS, linux.IP_PKTINFO, linux.IP_RECVORIGDSTADDR, linux.IP_RECVERR:
+		case linux.IP_TOS, linux.IP_RECVTOS, linux.IP_RECVTTL, linux.IP_PKTINFO, linux.IP_RECVORIGDSTADDR, linux.IP_RECVERR:
 			optlen = sizeofInt32
 		}
 	case linux.SOL_IPV6:
 		switch name {
-		case linux.IPV6_TCLASS, linux.IPV6_RECVTCLASS, linux.IPV6_RECVERR, linux.IPV6_V6ONLY, linux.IPV6_RECVORIGDSTADDR:
+		case linux.IPV6_TCLASS, linux.IPV6_RECVTCLASS, linux.IPV6_RECVHOPLIMIT, linux.IPV6_RECVERR, linux.IPV6_V6ONLY, linux.IPV6_RECVORIGDSTADDR:
 			optlen = sizeofInt32
 		}
 	case linux.SOL_SOCKET:
@@ -590,6 +632,12 @@ func parseUnixControlMessages(unixControlMessages []unix.SocketControlMessage) s
 				tos.UnmarshalUnsafe(unixCmsg.Data)
 				controlMessages.IP.TOS = uint8(tos)
 
+			case linux.IP_TTL:
+				controlMessages.IP.HasTTL = true
+				var ttl primitive.Uint32
+				ttl.UnmarshalUnsafe(unixCmsg.Data)
+				controlMessages.IP.TTL = uint32(ttl)
+
 			case linux.IP_PKTINFO:
 				controlMessages.IP.HasIPPacketInfo = true
 				var packetInfo linux.ControlMessageIPPacketInfo
@@ -615,6 +663,12 @@ func parseUnixControlMessages(unixControlMessages []unix.SocketControlMessage) s
 				tclass.UnmarshalUnsafe(unixCmsg.Data)
 				controlMessages.IP.TClass = uint32(tclass)
 
+			case linux.IPV6_HOPLIMIT:
+				controlMessages.IP.HasHopLimit = true
+				var hoplimit primitive.Uint32
+				hoplimit.UnmarshalUnsafe(unixCmsg.Data)
+				controlMessages.IP.HopLimit = uint32(hoplimit)
+
 			case linux.IPV6_RECVORIGDSTADDR:
 				var addr linux.SockAddrInet6
 				addr.UnmarshalUnsafe(unixCmsg.Data)
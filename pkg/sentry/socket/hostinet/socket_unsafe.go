@@ -107,8 +107,40 @@ func getsockopt(fd int, level, name int, optlen int) ([]byte, error) {
 	return opt[:optlen32], nil
 }
 
+func setsockopt(fd int, level, name int, opt []byte) error {
+	_, _, errno := unix.Syscall6(unix.SYS_SETSOCKOPT, uintptr(fd), uintptr(level), uintptr(name), uintptr(firstBytePtr(opt)), uintptr(len(opt)), 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func getsockname(fd int) ([]byte, error) {
+	addr := make([]byte, sizeofSockaddr)
+	addrlen := uint32(len(addr))
+	_, _, errno := unix.Syscall(unix.SYS_GETSOCKNAME, uintptr(fd), uintptr(unsafe.Pointer(&addr[0])), uintptr(unsafe.Pointer(&addrlen)))
+	if errno != 0 {
+		return nil, errno
+	}
+	return addr[:addrlen], nil
+}
+
+func getpeername(fd int) ([]byte, error) {
+	addr := make([]byte, sizeofSockaddr)
+	addrlen := uint32(len(addr))
+	_, _, errno := unix.Syscall(unix.SYS_GETPEERNAME, uintptr(fd), uintptr(unsafe.Pointer(&addr[0])), uintptr(unsafe.Pointer(&addrlen)))
+	if errno != 0 {
+		return nil, errno
+	}
+	return addr[:addrlen], nil
+}
+
 // GetSockName implements socket.Socket.GetSockName.
 func (s *socketOpsCommon) GetSockName(t *kernel.Task) (linux.SockAddr, uint32, *syserr.Error) {
+	if s.vsockUDS() {
+		local, _ := s.vsockUDSNames()
+		return local, linux.SockAddrVMSize, nil
+	}
 	addr := make([]byte, sizeofSockaddr)
 	addrlen := uint32(len(addr))
 	_, _, errno := unix.Syscall(unix.SYS_GETSOCKNAME, uintptr(s.fd), uintptr(unsafe.Pointer(&addr[0])), uintptr(unsafe.Pointer(&addrlen)))
@@ -126,6 +158,10 @@ func (s *socketOpsCommon) GetPeerName(t *kernel.Task) (linux.SockAddr, uint32, *
 	if errno != 0 {
 		return nil, 0, syserr.FromError(errno)
 	}
+	if s.vsockUDS() {
+		_, peer := s.vsockUDSNames()
+		return peer, linux.SockAddrVMSize, nil
+	}
 	return socket.UnmarshalSockAddr(s.family, addr), addrlen, nil
 }
 
@@ -474,9 +474,45 @@ func (s *Stack) RouteTable() []inet.Route {
 	return append([]inet.Route(nil), s.routes...)
 }
 
+// AddRoute implements inet.Stack.AddRoute.
+func (*Stack) AddRoute(inet.Route, inet.AddRouteOptions) error {
+	return linuxerr.EACCES
+}
+
+// RemoveRoute implements inet.Stack.RemoveRoute.
+func (*Stack) RemoveRoute(inet.Route) error {
+	return linuxerr.EACCES
+}
+
+// Neighbors implements inet.Stack.Neighbors.
+func (*Stack) Neighbors() []inet.Neighbor {
+	return nil
+}
+
+// AddNeighbor implements inet.Stack.AddNeighbor.
+func (*Stack) AddNeighbor(inet.Neighbor) error {
+	return linuxerr.EACCES
+}
+
+// RemoveNeighbor implements inet.Stack.RemoveNeighbor.
+func (*Stack) RemoveNeighbor(inet.Neighbor) error {
+	return linuxerr.EACCES
+}
+
 // Resume implements inet.Stack.Resume.
 func (*Stack) Resume() {}
 
+// PrepareResumableSave implements inet.Stack.PrepareResumableSave.
+func (*Stack) PrepareResumableSave() {}
+
+// ResumeAfterSave implements inet.Stack.ResumeAfterSave.
+func (*Stack) ResumeAfterSave() {}
+
+// AbortSave implements inet.Stack.AbortSave.
+func (*Stack) AbortSave() {
+	abortSave()
+}
+
 // RegisteredEndpoints implements inet.Stack.RegisteredEndpoints.
 func (*Stack) RegisteredEndpoints() []stack.TransportEndpoint { return nil }
 
@@ -501,3 +537,13 @@ func (*Stack) PortRange() (uint16, uint16) {
 func (*Stack) SetPortRange(uint16, uint16) error {
 	return linuxerr.EACCES
 }
+
+// ReservedPorts implements inet.Stack.ReservedPorts.
+func (*Stack) ReservedPorts() []uint16 {
+	return nil
+}
+
+// SetReservedPorts implements inet.Stack.SetReservedPorts.
+func (*Stack) SetReservedPorts([]uint16) error {
+	return linuxerr.EACCES
+}
@@ -0,0 +1,140 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hostinet
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/sentry/kernel"
+	"gvisor.dev/gvisor/pkg/sentry/socket"
+	"gvisor.dev/gvisor/pkg/sentry/vfs"
+	"gvisor.dev/gvisor/pkg/syserr"
+)
+
+// VsockUDSPrefix is the prefix of the names of UNIX sockets that back vsock
+// ports when using a UNIX socket backend. Connections to port P of the host
+// are made to the socket named VsockUDSPrefix + P.
+const VsockUDSPrefix = "vsock_"
+
+// vsockGuestCID is the CID reported as the local address of vsock sockets
+// using a UNIX socket backend. It's the lowest CID available to guests.
+const vsockGuestCID = 3
+
+// vsockConfig configures AF_VSOCK sockets. It's set before the kernel starts
+// and is immutable afterwards.
+var vsockConfig = struct {
+	enabled bool
+
+	// udsDirFD is a host directory FD containing the UNIX sockets that back
+	// vsock ports. If negative, sockets are backed by host vsock sockets.
+	udsDirFD int
+}{udsDirFD: -1}
+
+// EnableVsock enables AF_VSOCK sockets. If udsDirFD is negative, vsock
+// sockets are passed through to the host's vsock transport. Otherwise, only
+// connections to the host (VMADDR_CID_HOST) are supported, and they are
+// bridged to UNIX sockets in the directory udsDirFD refers to.
+func EnableVsock(udsDirFD int) {
+	vsockConfig.enabled = true
+	vsockConfig.udsDirFD = udsDirFD
+}
+
+// vsockUDS returns true if s is a vsock socket backed by a host UNIX socket.
+func (s *socketOpsCommon) vsockUDS() bool {
+	return s.family == linux.AF_VSOCK && vsockConfig.udsDirFD >= 0
+}
+
+// vsockUDSAddr translates a vsock address to the address of the UNIX socket
+// that backs it.
+func vsockUDSAddr(sockaddr []byte) ([]byte, uint32, *syserr.Error) {
+	if len(sockaddr) < linux.SockAddrVMSize {
+		return nil, 0, syserr.ErrInvalidArgument
+	}
+	var addr linux.SockAddrVM
+	addr.UnmarshalUnsafe(sockaddr[:linux.SockAddrVMSize])
+	if addr.Family != linux.AF_VSOCK {
+		return nil, 0, syserr.ErrAddressFamilyNotSupported
+	}
+	if addr.CID != linux.VMADDR_CID_HOST {
+		return nil, 0, syserr.ErrNetworkUnreachable
+	}
+	path := fmt.Sprintf("/proc/self/fd/%d/%s%d", vsockConfig.udsDirFD, VsockUDSPrefix, addr.Port)
+	if len(path) >= linux.UnixPathMax {
+		return nil, 0, syserr.ErrNameTooLong
+	}
+	uaddr := linux.SockAddrUnix{Family: linux.AF_UNIX}
+	for i := range path {
+		uaddr.Path[i] = int8(path[i])
+	}
+	buf := make([]byte, uaddr.SizeBytes())
+	uaddr.MarshalUnsafe(buf)
+	return buf[:2+len(path)+1], addr.Port, nil
+}
+
+// vsockUDSNames returns the local and peer addresses of a vsock socket backed
+// by a UNIX socket.
+func (s *socketOpsCommon) vsockUDSNames() (local, peer *linux.SockAddrVM) {
+	local = &linux.SockAddrVM{
+		Family: linux.AF_VSOCK,
+		CID:    vsockGuestCID,
+		Port:   linux.VMADDR_PORT_ANY,
+	}
+	peer = &linux.SockAddrVM{
+		Family: linux.AF_VSOCK,
+		CID:    linux.VMADDR_CID_HOST,
+		Port:   s.vsockPeerPort,
+	}
+	return local, peer
+}
+
+type vsockProviderVFS2 struct{}
+
+// Socket implements socket.ProviderVFS2.Socket.
+func (*vsockProviderVFS2) Socket(t *kernel.Task, stypeflags linux.SockType, protocol int) (*vfs.FileDescription, *syserr.Error) {
+	if !vsockConfig.enabled {
+		return nil, nil
+	}
+
+	// Only stream sockets are supported.
+	stype := stypeflags & linux.SOCK_TYPE_MASK
+	if stype != unix.SOCK_STREAM {
+		return nil, syserr.ErrSocketNotSupported
+	}
+	if protocol != 0 {
+		return nil, syserr.ErrProtocolNotSupported
+	}
+
+	family := unix.AF_VSOCK
+	if vsockConfig.udsDirFD >= 0 {
+		family = unix.AF_UNIX
+	}
+	fd, err := unix.Socket(family, int(stype)|unix.SOCK_NONBLOCK|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil, syserr.FromError(err)
+	}
+	return newVFS2Socket(t, linux.AF_VSOCK, stype, protocol, fd, uint32(stypeflags&unix.SOCK_NONBLOCK))
+}
+
+// Pair implements socket.ProviderVFS2.Pair.
+func (*vsockProviderVFS2) Pair(*kernel.Task, linux.SockType, int) (*vfs.FileDescription, *vfs.FileDescription, *syserr.Error) {
+	// Not supported by AF_VSOCK.
+	return nil, nil, nil
+}
+
+func init() {
+	socket.RegisterProviderVFS2(linux.AF_VSOCK, &vsockProviderVFS2{})
+}
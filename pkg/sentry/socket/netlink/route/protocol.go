@@ -21,6 +21,7 @@ import (
 	"golang.org/x/sys/unix"
 	"gvisor.dev/gvisor/pkg/abi/linux"
 	"gvisor.dev/gvisor/pkg/context"
+	"gvisor.dev/gvisor/pkg/hostarch"
 	"gvisor.dev/gvisor/pkg/marshal/primitive"
 	"gvisor.dev/gvisor/pkg/sentry/inet"
 	"gvisor.dev/gvisor/pkg/sentry/kernel"
@@ -446,6 +447,229 @@ func (p *Protocol) dumpRoutes(ctx context.Context, msg *netlink.Message, ms *net
 	return nil
 }
 
+// parseRoute parses a RTM_NEWROUTE or RTM_DELROUTE request.
+func parseRoute(msg *netlink.Message) (inet.Route, *syserr.Error) {
+	var rtMsg linux.RouteMessage
+	attrs, ok := msg.GetData(&rtMsg)
+	if !ok {
+		return inet.Route{}, syserr.ErrInvalidArgument
+	}
+	route := inet.Route{
+		Family:   rtMsg.Family,
+		DstLen:   rtMsg.DstLen,
+		SrcLen:   rtMsg.SrcLen,
+		TOS:      rtMsg.TOS,
+		Table:    rtMsg.Table,
+		Protocol: rtMsg.Protocol,
+		Scope:    rtMsg.Scope,
+		Type:     rtMsg.Type,
+		Flags:    rtMsg.Flags,
+	}
+	table := uint32(rtMsg.Table)
+
+	for !attrs.Empty() {
+		ahdr, value, rest, ok := attrs.ParseFirst()
+		if !ok {
+			return inet.Route{}, syserr.ErrInvalidArgument
+		}
+		attrs = rest
+
+		switch ahdr.Type {
+		case linux.RTA_DST:
+			route.DstAddr = value
+		case linux.RTA_GATEWAY:
+			route.GatewayAddr = value
+		case linux.RTA_OIF:
+			if len(value) != 4 {
+				return inet.Route{}, syserr.ErrInvalidArgument
+			}
+			route.OutputInterface = int32(hostarch.ByteOrder.Uint32(value))
+		case linux.RTA_TABLE:
+			if len(value) != 4 {
+				return inet.Route{}, syserr.ErrInvalidArgument
+			}
+			table = hostarch.ByteOrder.Uint32(value)
+		case linux.RTA_PRIORITY, linux.RTA_PREFSRC:
+			// Netstack has neither route metrics nor preferred source
+			// addresses, ignore them.
+		default:
+			return inet.Route{}, tcpip.SyserrNotSupported
+		}
+	}
+
+	// There is a single routing table, see dumpRoutes, and no routing policy
+	// database to select other tables.
+	if table != linux.RT_TABLE_UNSPEC && table != linux.RT_TABLE_MAIN {
+		return inet.Route{}, tcpip.SyserrNotSupported
+	}
+	// Source routes and routes other than unicast ones aren't supported.
+	if route.SrcLen != 0 || (route.Type != linux.RTN_UNSPEC && route.Type != linux.RTN_UNICAST) {
+		return inet.Route{}, tcpip.SyserrNotSupported
+	}
+	return route, nil
+}
+
+// newRoute handles RTM_NEWROUTE requests.
+func (p *Protocol) newRoute(ctx context.Context, msg *netlink.Message, ms *netlink.MessageSet) *syserr.Error {
+	stack := inet.StackFromContext(ctx)
+	if stack == nil {
+		// No network stack.
+		return syserr.ErrProtocolNotSupported
+	}
+
+	route, err := parseRoute(msg)
+	if err != nil {
+		return err
+	}
+	flags := msg.Header().Flags
+	opts := inet.AddRouteOptions{
+		Replace:   flags&linux.NLM_F_REPLACE != 0,
+		Create:    flags&linux.NLM_F_CREATE != 0,
+		Exclusive: flags&linux.NLM_F_EXCL != 0,
+	}
+	if err := stack.AddRoute(route, opts); err != nil {
+		return syserr.FromError(err)
+	}
+	return nil
+}
+
+// delRoute handles RTM_DELROUTE requests.
+func (p *Protocol) delRoute(ctx context.Context, msg *netlink.Message, ms *netlink.MessageSet) *syserr.Error {
+	stack := inet.StackFromContext(ctx)
+	if stack == nil {
+		// No network stack.
+		return syserr.ErrProtocolNotSupported
+	}
+
+	route, err := parseRoute(msg)
+	if err != nil {
+		return err
+	}
+	if err := stack.RemoveRoute(route); err != nil {
+		return syserr.FromError(err)
+	}
+	return nil
+}
+
+// dumpNeighbors handles RTM_GETNEIGH dump requests.
+func (p *Protocol) dumpNeighbors(ctx context.Context, msg *netlink.Message, ms *netlink.MessageSet) *syserr.Error {
+	// RTM_GETNEIGH dump requests need not contain anything more than the
+	// netlink header and 1 byte protocol family common to all
+	// NETLINK_ROUTE requests.
+	var family primitive.Uint8
+	msg.GetData(&family)
+
+	// The RTM_GETNEIGH dump response is a set of RTM_NEWNEIGH messages each
+	// containing a NeighborMessage followed by a set of netlink attributes.
+
+	// We always send back an NLMSG_DONE.
+	ms.Multi = true
+
+	stack := inet.StackFromContext(ctx)
+	if stack == nil {
+		// No network devices.
+		return nil
+	}
+
+	for _, n := range stack.Neighbors() {
+		if family != linux.AF_UNSPEC && uint8(family) != n.Family {
+			continue
+		}
+
+		m := ms.AddMessage(linux.NetlinkMessageHeader{
+			Type: linux.RTM_NEWNEIGH,
+		})
+
+		m.Put(&linux.NeighborMessage{
+			Family: n.Family,
+			Index:  n.Index,
+			State:  n.State,
+		})
+
+		m.PutAttr(linux.NDA_DST, primitive.AsByteSlice(n.Addr))
+		if len(n.LinkAddr) > 0 {
+			m.PutAttr(linux.NDA_LLADDR, primitive.AsByteSlice(n.LinkAddr))
+		}
+	}
+
+	return nil
+}
+
+// parseNeighbor parses a RTM_NEWNEIGH or RTM_DELNEIGH request.
+func parseNeighbor(msg *netlink.Message) (inet.Neighbor, *syserr.Error) {
+	var ndm linux.NeighborMessage
+	attrs, ok := msg.GetData(&ndm)
+	if !ok {
+		return inet.Neighbor{}, syserr.ErrInvalidArgument
+	}
+	neigh := inet.Neighbor{
+		Family: ndm.Family,
+		Index:  ndm.Index,
+		State:  ndm.State,
+	}
+
+	for !attrs.Empty() {
+		ahdr, value, rest, ok := attrs.ParseFirst()
+		if !ok {
+			return inet.Neighbor{}, syserr.ErrInvalidArgument
+		}
+		attrs = rest
+
+		switch ahdr.Type {
+		case linux.NDA_DST:
+			neigh.Addr = value
+		case linux.NDA_LLADDR:
+			neigh.LinkAddr = value
+		case linux.NDA_PROBES:
+			// Netstack doesn't support setting the number of probes,
+			// ignore it.
+		default:
+			return inet.Neighbor{}, tcpip.SyserrNotSupported
+		}
+	}
+
+	if neigh.Index <= 0 || len(neigh.Addr) == 0 {
+		return inet.Neighbor{}, syserr.ErrInvalidArgument
+	}
+	return neigh, nil
+}
+
+// newNeighbor handles RTM_NEWNEIGH requests.
+func (p *Protocol) newNeighbor(ctx context.Context, msg *netlink.Message, ms *netlink.MessageSet) *syserr.Error {
+	stack := inet.StackFromContext(ctx)
+	if stack == nil {
+		// No network stack.
+		return syserr.ErrProtocolNotSupported
+	}
+
+	neigh, err := parseNeighbor(msg)
+	if err != nil {
+		return err
+	}
+	if err := stack.AddNeighbor(neigh); err != nil {
+		return syserr.FromError(err)
+	}
+	return nil
+}
+
+// delNeighbor handles RTM_DELNEIGH requests.
+func (p *Protocol) delNeighbor(ctx context.Context, msg *netlink.Message, ms *netlink.MessageSet) *syserr.Error {
+	stack := inet.StackFromContext(ctx)
+	if stack == nil {
+		// No network stack.
+		return syserr.ErrProtocolNotSupported
+	}
+
+	neigh, err := parseNeighbor(msg)
+	if err != nil {
+		return err
+	}
+	if err := stack.RemoveNeighbor(neigh); err != nil {
+		return syserr.FromError(err)
+	}
+	return nil
+}
+
 // newAddr handles RTM_NEWADDR requests.
 func (p *Protocol) newAddr(ctx context.Context, msg *netlink.Message, ms *netlink.MessageSet) *syserr.Error {
 	stack := inet.StackFromContext(ctx)
@@ -572,6 +796,8 @@ func (p *Protocol) ProcessMessage(ctx context.Context, msg *netlink.Message, ms
 			return p.dumpAddrs(ctx, msg, ms)
 		case linux.RTM_GETROUTE:
 			return p.dumpRoutes(ctx, msg, ms)
+		case linux.RTM_GETNEIGH:
+			return p.dumpNeighbors(ctx, msg, ms)
 		default:
 			return tcpip.SyserrNotSupported
 		}
@@ -583,6 +809,14 @@ func (p *Protocol) ProcessMessage(ctx context.Context, msg *netlink.Message, ms
 			return p.delLink(ctx, msg, ms)
 		case linux.RTM_GETROUTE:
 			return p.dumpRoutes(ctx, msg, ms)
+		case linux.RTM_NEWROUTE:
+			return p.newRoute(ctx, msg, ms)
+		case linux.RTM_DELROUTE:
+			return p.delRoute(ctx, msg, ms)
+		case linux.RTM_NEWNEIGH:
+			return p.newNeighbor(ctx, msg, ms)
+		case linux.RTM_DELNEIGH:
+			return p.delNeighbor(ctx, msg, ms)
 		case linux.RTM_NEWADDR:
 			return p.newAddr(ctx, msg, ms)
 		case linux.RTM_DELADDR:
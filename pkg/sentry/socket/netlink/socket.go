@@ -357,6 +357,9 @@ func (s *socketOpsCommon) GetSockOpt(t *kernel.Task, level int, name int, outPtr
 			}
 			return &passcred, nil
 
+		case linux.SO_SNDTIMEO, linux.SO_RCVTIMEO:
+			return s.GetSockOptTimeout(name, outLen)
+
 		default:
 			socket.GetSockOptEmitUnimplementedEvent(t, name)
 		}
@@ -415,6 +418,9 @@ func (s *socketOpsCommon) SetSockOpt(t *kernel.Task, level int, name int, opt []
 			s.ep.SocketOptions().SetPassCred(passcred != 0)
 			return nil
 
+		case linux.SO_SNDTIMEO, linux.SO_RCVTIMEO:
+			return s.SetSockOptTimeout(name, opt)
+
 		case linux.SO_ATTACH_FILTER:
 			// TODO(gvisor.dev/issue/1119): We don't actually
 			// support filtering. If this socket can't ever send
@@ -86,6 +86,7 @@ var Metrics = tcpip.Stats{
 			Packets: mustCreateMetric("/netstack/nic/tx/packets", "Number of packets transmitted."),
 			Bytes:   mustCreateMetric("/netstack/nic/tx/bytes", "Number of bytes transmitted."),
 		},
+		TxPacketsDropped: mustCreateMetric("/netstack/nic/tx_packets_dropped", "Number of packets dropped because the link endpoint failed to transmit them."),
 		Rx: tcpip.NICPacketStats{
 			Packets: mustCreateMetric("/netstack/nic/rx/packets", "Number of packets received."),
 			Bytes:   mustCreateMetric("/netstack/nic/rx/bytes", "Number of bytes received."),
@@ -895,17 +896,9 @@ func getSockOptSocket(t *kernel.Task, s socket.SocketOps, ep commonEndpoint, fam
 		return &optP, nil
 
 	case linux.SO_PEERCRED:
-		if family != linux.AF_UNIX || outLen < unix.SizeofUcred {
-			return nil, syserr.ErrInvalidArgument
-		}
-
-		tcred := t.Credentials()
-		creds := linux.ControlMessageCredentials{
-			PID: int32(t.ThreadGroup().ID()),
-			UID: uint32(tcred.EffectiveKUID.In(tcred.UserNamespace).OrOverflow()),
-			GID: uint32(tcred.EffectiveKGID.In(tcred.UserNamespace).OrOverflow()),
-		}
-		return &creds, nil
+		// Unix sockets report the credentials of their peer, and handle
+		// SO_PEERCRED themselves.
+		return nil, syserr.ErrInvalidArgument
 
 	case linux.SO_PASSCRED:
 		if outLen < sizeOfInt32 {
@@ -1345,6 +1338,14 @@ func getSockOptIPv6(t *kernel.Task, s socket.SocketOps, ep commonEndpoint, name
 
 		v := primitive.Int32(boolToInt32(ep.SocketOptions().GetReceiveTClass()))
 		return &v, nil
+
+	case linux.IPV6_RECVHOPLIMIT:
+		if outLen < sizeOfInt32 {
+			return nil, syserr.ErrInvalidArgument
+		}
+
+		v := primitive.Int32(boolToInt32(ep.SocketOptions().GetReceiveHopLimit()))
+		return &v, nil
 	case linux.IPV6_RECVERR:
 		if outLen < sizeOfInt32 {
 			return nil, syserr.ErrInvalidArgument
@@ -1474,6 +1475,19 @@ func getSockOptIP(t *kernel.Task, s socket.SocketOps, ep commonEndpoint, name in
 
 		return &vP, nil
 
+	case linux.IP_MTU_DISCOVER:
+		if outLen < sizeOfInt32 {
+			return nil, syserr.ErrInvalidArgument
+		}
+
+		v, err := ep.GetSockOptInt(tcpip.MTUDiscoverOption)
+		if err != nil {
+			return nil, tcpip.TranslateNetstackError(err)
+		}
+
+		vP := primitive.Int32(pmtudToLinux(v))
+		return &vP, nil
+
 	case linux.IP_MULTICAST_TTL:
 		if outLen < sizeOfInt32 {
 			return nil, syserr.ErrInvalidArgument
@@ -1534,6 +1548,14 @@ func getSockOptIP(t *kernel.Task, s socket.SocketOps, ep commonEndpoint, name in
 		v := primitive.Int32(boolToInt32(ep.SocketOptions().GetReceiveTOS()))
 		return &v, nil
 
+	case linux.IP_RECVTTL:
+		if outLen < sizeOfInt32 {
+			return nil, syserr.ErrInvalidArgument
+		}
+
+		v := primitive.Int32(boolToInt32(ep.SocketOptions().GetReceiveTTL()))
+		return &v, nil
+
 	case linux.IP_RECVERR:
 		if outLen < sizeOfInt32 {
 			return nil, syserr.ErrInvalidArgument
@@ -2155,6 +2177,15 @@ func setSockOptIPv6(t *kernel.Task, s socket.SocketOps, ep commonEndpoint, name
 
 		ep.SocketOptions().SetReceiveTClass(v != 0)
 		return nil
+
+	case linux.IPV6_RECVHOPLIMIT:
+		v, err := parseIntOrChar(optVal)
+		if err != nil {
+			return err
+		}
+
+		ep.SocketOptions().SetReceiveHopLimit(v != 0)
+		return nil
 	case linux.IPV6_RECVERR:
 		if len(optVal) == 0 {
 			return nil
@@ -2356,6 +2387,17 @@ func setSockOptIP(t *kernel.Task, s socket.SocketOps, ep commonEndpoint, name in
 		}
 		return tcpip.TranslateNetstackError(ep.SetSockOptInt(tcpip.IPv4TOSOption, int(v)))
 
+	case linux.IP_MTU_DISCOVER:
+		v, err := parseIntOrChar(optVal)
+		if err != nil {
+			return err
+		}
+		pmtud, ok := pmtudFromLinux(v)
+		if !ok {
+			return syserr.ErrInvalidArgument
+		}
+		return tcpip.TranslateNetstackError(ep.SetSockOptInt(tcpip.MTUDiscoverOption, pmtud))
+
 	case linux.IP_RECVTOS:
 		v, err := parseIntOrChar(optVal)
 		if err != nil {
@@ -2364,6 +2406,14 @@ func setSockOptIP(t *kernel.Task, s socket.SocketOps, ep commonEndpoint, name in
 		ep.SocketOptions().SetReceiveTOS(v != 0)
 		return nil
 
+	case linux.IP_RECVTTL:
+		v, err := parseIntOrChar(optVal)
+		if err != nil {
+			return err
+		}
+		ep.SocketOptions().SetReceiveTTL(v != 0)
+		return nil
+
 	case linux.IP_RECVERR:
 		if len(optVal) == 0 {
 			return nil
@@ -2439,14 +2489,12 @@ func setSockOptIP(t *kernel.Task, s socket.SocketOps, ep commonEndpoint, name in
 		linux.IP_IPSEC_POLICY,
 		linux.IP_MINTTL,
 		linux.IP_MSFILTER,
-		linux.IP_MTU_DISCOVER,
 		linux.IP_MULTICAST_ALL,
 		linux.IP_NODEFRAG,
 		linux.IP_OPTIONS,
 		linux.IP_PASSSEC,
 		linux.IP_RECVFRAGSIZE,
 		linux.IP_RECVOPTS,
-		linux.IP_RECVTTL,
 		linux.IP_RETOPTS,
 		linux.IP_TRANSPARENT,
 		linux.IP_UNBLOCK_SOURCE,
@@ -2465,6 +2513,41 @@ func setSockOptIP(t *kernel.Task, s socket.SocketOps, ep commonEndpoint, name in
 	return nil
 }
 
+// pmtudFromLinux translates a Linux IP_PMTUDISC_* value to the equivalent
+// tcpip.MTUDiscoverOption setting.
+func pmtudFromLinux(v int32) (int, bool) {
+	switch v {
+	case linux.IP_PMTUDISC_WANT:
+		return tcpip.PMTUDiscoveryWant, true
+	case linux.IP_PMTUDISC_DONT:
+		return tcpip.PMTUDiscoveryDont, true
+	case linux.IP_PMTUDISC_DO:
+		return tcpip.PMTUDiscoveryDo, true
+	case linux.IP_PMTUDISC_PROBE:
+		return tcpip.PMTUDiscoveryProbe, true
+	case linux.IP_PMTUDISC_INTERFACE, linux.IP_PMTUDISC_OMIT:
+		// Like on Linux, the DF bit isn't set with these.
+		return tcpip.PMTUDiscoveryDont, true
+	default:
+		return 0, false
+	}
+}
+
+// pmtudToLinux translates a tcpip.MTUDiscoverOption setting to the equivalent
+// Linux IP_PMTUDISC_* value.
+func pmtudToLinux(v int) int32 {
+	switch v {
+	case tcpip.PMTUDiscoveryWant:
+		return linux.IP_PMTUDISC_WANT
+	case tcpip.PMTUDiscoveryDo:
+		return linux.IP_PMTUDISC_DO
+	case tcpip.PMTUDiscoveryProbe:
+		return linux.IP_PMTUDISC_PROBE
+	default:
+		return linux.IP_PMTUDISC_DONT
+	}
+}
+
 // emitUnimplementedEventTCP emits unimplemented event if name is valid. This
 // function contains names that are common between Get and SetSockOpt when
 // level is SOL_TCP.
@@ -2746,6 +2829,10 @@ func (s *socketOpsCommon) controlMessages(cm tcpip.ControlMessages) socket.Contr
 			TOS:                readCM.TOS,
 			HasTClass:          readCM.HasTClass,
 			TClass:             readCM.TClass,
+			HasTTL:             readCM.HasTTL,
+			TTL:                readCM.TTL,
+			HasHopLimit:        readCM.HasHopLimit,
+			HopLimit:           readCM.HopLimit,
 			HasIPPacketInfo:    readCM.HasIPPacketInfo,
 			PacketInfo:         readCM.PacketInfo,
 			HasIPv6PacketInfo:  readCM.HasIPv6PacketInfo,
@@ -24,6 +24,7 @@ import (
 	"gvisor.dev/gvisor/pkg/syserr"
 	"gvisor.dev/gvisor/pkg/tcpip"
 	"gvisor.dev/gvisor/pkg/tcpip/header"
+	"gvisor.dev/gvisor/pkg/tcpip/network/arp"
 	"gvisor.dev/gvisor/pkg/tcpip/network/ipv4"
 	"gvisor.dev/gvisor/pkg/tcpip/network/ipv6"
 	"gvisor.dev/gvisor/pkg/tcpip/stack"
@@ -158,6 +159,7 @@ func (s *Stack) AddInterfaceAddr(idx int32, addr inet.InterfaceAddr) error {
 	if err := s.Stack.AddProtocolAddress(nicID, protocolAddress, stack.AddressProperties{}); err != nil {
 		return tcpip.TranslateNetstackError(err).ToError()
 	}
+	AnnounceAddress(s.Stack, nicID, protocolAddress)
 
 	// Add route for local network if it doesn't exist already.
 	localRoute := tcpip.Route{
@@ -178,6 +180,46 @@ func (s *Stack) AddInterfaceAddr(idx int32, addr inet.InterfaceAddr) error {
 	return nil
 }
 
+// AnnounceAddress announces that addr is assigned to the interface identified
+// by nicID, so that neighbors update their neighbor caches, e.g. when a
+// virtual IP address moves between hosts. IPv4 addresses are announced with a
+// gratuitous ARP, and IPv6 addresses with an unsolicited Neighbor
+// Advertisement. Only addresses of Ethernet interfaces are announced.
+func AnnounceAddress(s *stack.Stack, nicID tcpip.NICID, addr tcpip.ProtocolAddress) {
+	if info, ok := s.NICInfo()[nicID]; !ok || info.ARPHardwareType != header.ARPHardwareEther {
+		return
+	}
+	address := addr.AddressWithPrefix.Address
+	switch addr.Protocol {
+	case ipv4.ProtocolNumber:
+		ep, err := s.GetNetworkEndpoint(nicID, arp.ProtocolNumber)
+		if err != nil {
+			return
+		}
+		res, ok := ep.(stack.LinkAddressResolver)
+		if !ok {
+			return
+		}
+		// A gratuitous ARP is an ARP request for the sender's own address,
+		// broadcast to the link. See RFC 5227 section 3.
+		if err := res.LinkAddressRequest(address, address, "" /* remoteLinkAddr */); err != nil {
+			log.Warningf("Failed to send gratuitous ARP for %s on NIC %d: %s", address, nicID, err)
+		}
+	case ipv6.ProtocolNumber:
+		ep, err := s.GetNetworkEndpoint(nicID, ipv6.ProtocolNumber)
+		if err != nil {
+			return
+		}
+		ndp, ok := ep.(ipv6.NDPEndpoint)
+		if !ok {
+			return
+		}
+		if err := ndp.SendUnsolicitedNeighborAdvert(address); err != nil {
+			log.Warningf("Failed to send unsolicited neighbor advertisement for %s on NIC %d: %s", address, nicID, err)
+		}
+	}
+}
+
 // RemoveInterfaceAddr implements inet.Stack.RemoveInterfaceAddr.
 func (s *Stack) RemoveInterfaceAddr(idx int32, addr inet.InterfaceAddr) error {
 	protocolAddress, err := convertAddr(addr)
@@ -282,26 +324,34 @@ func (s *Stack) Statistics(stat interface{}, arg string) error {
 			if ni.Name != arg {
 				continue
 			}
+			// Received packets are dropped if the NIC is disabled, or if their
+			// network protocol is unknown.
+			rxDropped := ni.Stats.DisabledRx.Packets.Value()
+			for _, proto := range ni.Stats.UnknownL3ProtocolRcvdPacketCounts.Keys() {
+				if c, ok := ni.Stats.UnknownL3ProtocolRcvdPacketCounts.Get(proto); ok {
+					rxDropped += c.Value()
+				}
+			}
 			// TODO(gvisor.dev/issue/2103) Support stubbed stats.
 			*stats = inet.StatDev{
 				// Receive section.
-				ni.Stats.Rx.Bytes.Value(),   // bytes.
-				ni.Stats.Rx.Packets.Value(), // packets.
-				0,                           // errs.
-				0,                           // drop.
-				0,                           // fifo.
-				0,                           // frame.
-				0,                           // compressed.
-				0,                           // multicast.
+				ni.Stats.Rx.Bytes.Value(),               // bytes.
+				ni.Stats.Rx.Packets.Value(),             // packets.
+				ni.Stats.MalformedL4RcvdPackets.Value(), // errs.
+				rxDropped,                               // drop.
+				0,                                       // fifo.
+				0,                                       // frame.
+				0,                                       // compressed.
+				0,                                       // multicast.
 				// Transmit section.
-				ni.Stats.Tx.Bytes.Value(),   // bytes.
-				ni.Stats.Tx.Packets.Value(), // packets.
-				0,                           // errs.
-				0,                           // drop.
-				0,                           // fifo.
-				0,                           // colls.
-				0,                           // carrier.
-				0,                           // compressed.
+				ni.Stats.Tx.Bytes.Value(),         // bytes.
+				ni.Stats.Tx.Packets.Value(),       // packets.
+				0,                                 // errs.
+				ni.Stats.TxPacketsDropped.Value(), // drop.
+				0,                                 // fifo.
+				0,                                 // colls.
+				0,                                 // carrier.
+				0,                                 // compressed.
 			}
 			break
 		}
@@ -439,6 +489,209 @@ func (s *Stack) RouteTable() []inet.Route {
 	return routeTable
 }
 
+// convertRoute converts route to a tcpip.Route.
+func convertRoute(route inet.Route) (tcpip.Route, error) {
+	var addrSize int
+	switch route.Family {
+	case linux.AF_INET:
+		addrSize = header.IPv4AddressSize
+	case linux.AF_INET6:
+		addrSize = header.IPv6AddressSize
+	default:
+		return tcpip.Route{}, linuxerr.ENOTSUP
+	}
+	if int(route.DstLen) > addrSize*8 {
+		return tcpip.Route{}, linuxerr.EINVAL
+	}
+	dst := route.DstAddr
+	if len(dst) == 0 {
+		// A default route.
+		dst = make([]byte, addrSize)
+	}
+	if len(dst) != addrSize {
+		return tcpip.Route{}, linuxerr.EINVAL
+	}
+	if len(route.GatewayAddr) != 0 && len(route.GatewayAddr) != addrSize {
+		return tcpip.Route{}, linuxerr.EINVAL
+	}
+
+	subnet := tcpip.AddressWithPrefix{
+		Address:   tcpip.Address(dst),
+		PrefixLen: int(route.DstLen),
+	}.Subnet()
+	// Like Linux, IPv4 destinations can't have host bits set, while they are
+	// cleared for IPv6.
+	if route.Family == linux.AF_INET && subnet.ID() != tcpip.Address(dst) {
+		return tcpip.Route{}, linuxerr.EINVAL
+	}
+
+	return tcpip.Route{
+		Destination: subnet,
+		Gateway:     tcpip.Address(route.GatewayAddr),
+		NIC:         tcpip.NICID(route.OutputInterface),
+	}, nil
+}
+
+// AddRoute implements inet.Stack.AddRoute.
+func (s *Stack) AddRoute(route inet.Route, opts inet.AddRouteOptions) error {
+	rt, err := convertRoute(route)
+	if err != nil {
+		return err
+	}
+
+	if rt.NIC == 0 {
+		// Like Linux, use the interface of the local network containing the
+		// gateway.
+		if rt.Gateway == "" {
+			return linuxerr.ENODEV
+		}
+		for _, r := range s.Stack.GetRouteTable() {
+			if r.Gateway == "" && r.Destination.Contains(rt.Gateway) {
+				rt.NIC = r.NIC
+				break
+			}
+		}
+		if rt.NIC == 0 {
+			return linuxerr.ENETUNREACH
+		}
+	} else if !s.Stack.HasNIC(rt.NIC) {
+		return linuxerr.ENODEV
+	}
+
+	switch err := s.Stack.InsertRoute(rt, stack.InsertRouteOptions{
+		Replace:   opts.Replace,
+		Create:    opts.Create,
+		Exclusive: opts.Exclusive,
+	}); err.(type) {
+	case nil:
+		return nil
+	case *tcpip.ErrDuplicateAddress:
+		return linuxerr.EEXIST
+	case *tcpip.ErrNoRoute:
+		// Like Linux, there is no route to replace.
+		return linuxerr.ENOENT
+	default:
+		return syserr.TranslateNetstackError(err).ToError()
+	}
+}
+
+// RemoveRoute implements inet.Stack.RemoveRoute.
+func (s *Stack) RemoveRoute(route inet.Route) error {
+	rt, err := convertRoute(route)
+	if err != nil {
+		return err
+	}
+
+	// Like Linux, the gateway and output interface only need to match if
+	// they are specified.
+	removed := s.Stack.RemoveRoutes(func(r tcpip.Route) bool {
+		return r.Destination.Equal(rt.Destination) &&
+			(rt.Gateway == "" || r.Gateway == rt.Gateway) &&
+			(rt.NIC == 0 || r.NIC == rt.NIC)
+	})
+	if removed == 0 {
+		return linuxerr.ESRCH
+	}
+	return nil
+}
+
+// toLinuxNeighborState converts a netstack neighbor state to the equivalent
+// Linux NUD_* constant.
+func toLinuxNeighborState(state stack.NeighborState) uint16 {
+	switch state {
+	case stack.Incomplete:
+		return linux.NUD_INCOMPLETE
+	case stack.Reachable:
+		return linux.NUD_REACHABLE
+	case stack.Stale:
+		return linux.NUD_STALE
+	case stack.Delay:
+		return linux.NUD_DELAY
+	case stack.Probe:
+		return linux.NUD_PROBE
+	case stack.Static:
+		return linux.NUD_PERMANENT
+	case stack.Unreachable:
+		return linux.NUD_FAILED
+	default:
+		return linux.NUD_NONE
+	}
+}
+
+// neighborProtocol returns the network protocol of the neighbor table for
+// family.
+func neighborProtocol(family uint8) (tcpip.NetworkProtocolNumber, int, error) {
+	switch family {
+	case linux.AF_INET:
+		return ipv4.ProtocolNumber, header.IPv4AddressSize, nil
+	case linux.AF_INET6:
+		return ipv6.ProtocolNumber, header.IPv6AddressSize, nil
+	default:
+		return 0, 0, linuxerr.ENOTSUP
+	}
+}
+
+// Neighbors implements inet.Stack.Neighbors.
+func (s *Stack) Neighbors() []inet.Neighbor {
+	var neighbors []inet.Neighbor
+	for nicID := range s.Stack.NICInfo() {
+		for _, family := range []uint8{linux.AF_INET, linux.AF_INET6} {
+			protocol, _, _ := neighborProtocol(family)
+			// Interfaces that don't need link address resolution, e.g.
+			// loopback, have no neighbor table.
+			entries, err := s.Stack.Neighbors(nicID, protocol)
+			if err != nil {
+				continue
+			}
+			for _, e := range entries {
+				neighbors = append(neighbors, inet.Neighbor{
+					Family:   family,
+					Index:    int32(nicID),
+					State:    toLinuxNeighborState(e.State),
+					Addr:     []byte(e.Addr),
+					LinkAddr: []byte(e.LinkAddr),
+				})
+			}
+		}
+	}
+	return neighbors
+}
+
+// AddNeighbor implements inet.Stack.AddNeighbor.
+func (s *Stack) AddNeighbor(neigh inet.Neighbor) error {
+	protocol, addrSize, err := neighborProtocol(neigh.Family)
+	if err != nil {
+		return err
+	}
+	if len(neigh.Addr) != addrSize || len(neigh.LinkAddr) != header.EthernetAddressSize {
+		return linuxerr.EINVAL
+	}
+	// Netstack only supports adding static entries.
+	if neigh.State&(linux.NUD_PERMANENT|linux.NUD_NOARP) == 0 {
+		return linuxerr.EOPNOTSUPP
+	}
+	return tcpip.TranslateNetstackError(s.Stack.AddStaticNeighbor(tcpip.NICID(neigh.Index), protocol, tcpip.Address(neigh.Addr), tcpip.LinkAddress(neigh.LinkAddr))).ToError()
+}
+
+// RemoveNeighbor implements inet.Stack.RemoveNeighbor.
+func (s *Stack) RemoveNeighbor(neigh inet.Neighbor) error {
+	protocol, addrSize, err := neighborProtocol(neigh.Family)
+	if err != nil {
+		return err
+	}
+	if len(neigh.Addr) != addrSize {
+		return linuxerr.EINVAL
+	}
+	switch err := s.Stack.RemoveNeighbor(tcpip.NICID(neigh.Index), protocol, tcpip.Address(neigh.Addr)); err.(type) {
+	case nil:
+		return nil
+	case *tcpip.ErrBadAddress:
+		return linuxerr.ENOENT
+	default:
+		return tcpip.TranslateNetstackError(err).ToError()
+	}
+}
+
 // IPTables returns the stack's iptables.
 func (s *Stack) IPTables() (*stack.IPTables, error) {
 	return s.Stack.IPTables(), nil
@@ -449,6 +702,22 @@ func (s *Stack) Resume() {
 	s.Stack.Resume()
 }
 
+// PrepareResumableSave implements inet.Stack.PrepareResumableSave.
+func (s *Stack) PrepareResumableSave() {
+	s.Stack.PrepareResumableSave()
+}
+
+// ResumeAfterSave implements inet.Stack.ResumeAfterSave.
+func (s *Stack) ResumeAfterSave() {
+	s.Stack.ResumeAfterSave()
+}
+
+// AbortSave implements inet.Stack.AbortSave.
+func (*Stack) AbortSave() {
+	// Endpoints are only restarted after resumable saves, see
+	// ResumeAfterSave.
+}
+
 // RegisteredEndpoints implements inet.Stack.RegisteredEndpoints.
 func (s *Stack) RegisteredEndpoints() []stack.TransportEndpoint {
 	return s.Stack.RegisteredEndpoints()
@@ -481,3 +750,32 @@ func (s *Stack) PortRange() (uint16, uint16) {
 func (s *Stack) SetPortRange(start uint16, end uint16) error {
 	return tcpip.TranslateNetstackError(s.Stack.SetPortRange(start, end)).ToError()
 }
+
+// ReservedPorts implements inet.Stack.ReservedPorts.
+func (s *Stack) ReservedPorts() []uint16 {
+	return s.Stack.ReservedPorts()
+}
+
+// SetReservedPorts implements inet.Stack.SetReservedPorts.
+func (s *Stack) SetReservedPorts(ports []uint16) error {
+	s.Stack.SetReservedPorts(ports)
+	return nil
+}
+
+// QueuedBytes returns the number of bytes held in the receive and send queues
+// of all transport endpoints of the stack.
+func (s *Stack) QueuedBytes() uint64 {
+	var total uint64
+	for _, e := range s.Stack.RegisteredEndpoints() {
+		ep, ok := e.(tcpip.Endpoint)
+		if !ok {
+			continue
+		}
+		for _, opt := range []tcpip.SockOptInt{tcpip.ReceiveQueueSizeOption, tcpip.SendQueueSizeOption} {
+			if v, err := ep.GetSockOptInt(opt); err == nil && v > 0 {
+				total += uint64(v)
+			}
+		}
+	}
+	return total
+}
@@ -135,6 +135,10 @@ func NewIPControlMessages(family int, cmgs tcpip.ControlMessages) IPControlMessa
 		TOS:                cmgs.TOS,
 		HasTClass:          cmgs.HasTClass,
 		TClass:             cmgs.TClass,
+		HasTTL:             cmgs.HasTTL,
+		TTL:                uint32(cmgs.TTL),
+		HasHopLimit:        cmgs.HasHopLimit,
+		HopLimit:           uint32(cmgs.HopLimit),
 		HasIPPacketInfo:    cmgs.HasIPPacketInfo,
 		PacketInfo:         packetInfoToLinux(cmgs.PacketInfo),
 		HasIPv6PacketInfo:  cmgs.HasIPv6PacketInfo,
@@ -179,6 +183,18 @@ type IPControlMessages struct {
 	// TClass is the IPv6 traffic class of the associated packet.
 	TClass uint32
 
+	// HasTTL indicates whether TTL is valid/set.
+	HasTTL bool
+
+	// TTL is the IPv4 time to live of the associated packet.
+	TTL uint32
+
+	// HasHopLimit indicates whether HopLimit is valid/set.
+	HasHopLimit bool
+
+	// HopLimit is the IPv6 hop limit of the associated packet.
+	HopLimit uint32
+
 	// HasIPPacketInfo indicates whether PacketInfo is set.
 	HasIPPacketInfo bool
 
@@ -497,6 +513,46 @@ func (to *SendReceiveTimeout) SendTimeout() int64 {
 	return atomic.LoadInt64(&to.send)
 }
 
+// GetSockOptTimeout returns the value of the SO_SNDTIMEO or SO_RCVTIMEO socket
+// option, depending on name.
+func (to *SendReceiveTimeout) GetSockOptTimeout(name, outLen int) (marshal.Marshallable, *syserr.Error) {
+	if outLen < linux.SizeOfTimeval {
+		return nil, syserr.ErrInvalidArgument
+	}
+	var tv linux.Timeval
+	switch name {
+	case linux.SO_SNDTIMEO:
+		tv = linux.NsecToTimeval(to.SendTimeout())
+	case linux.SO_RCVTIMEO:
+		tv = linux.NsecToTimeval(to.RecvTimeout())
+	default:
+		return nil, syserr.ErrProtocolNotAvailable
+	}
+	return &tv, nil
+}
+
+// SetSockOptTimeout sets the SO_SNDTIMEO or SO_RCVTIMEO socket option,
+// depending on name.
+func (to *SendReceiveTimeout) SetSockOptTimeout(name int, optVal []byte) *syserr.Error {
+	if len(optVal) < linux.SizeOfTimeval {
+		return syserr.ErrInvalidArgument
+	}
+	var tv linux.Timeval
+	tv.UnmarshalBytes(optVal)
+	if tv.Usec < 0 || tv.Usec >= int64(time.Second/time.Microsecond) {
+		return syserr.ErrDomain
+	}
+	switch name {
+	case linux.SO_SNDTIMEO:
+		to.SetSendTimeout(tv.ToNsecCapped())
+	case linux.SO_RCVTIMEO:
+		to.SetRecvTimeout(tv.ToNsecCapped())
+	default:
+		return syserr.ErrProtocolNotAvailable
+	}
+	return nil
+}
+
 // GetSockOptEmitUnimplementedEvent emits unimplemented event if name is valid.
 // It contains names that are valid for GetSockOpt when level is SOL_SOCKET.
 func GetSockOptEmitUnimplementedEvent(t *kernel.Task, name int) {
@@ -609,6 +665,10 @@ func UnmarshalSockAddr(family int, data []byte) linux.SockAddr {
 		var addr linux.SockAddrNetlink
 		addr.UnmarshalUnsafe(data)
 		return &addr
+	case unix.AF_VSOCK:
+		var addr linux.SockAddrVM
+		addr.UnmarshalUnsafe(data)
+		return &addr
 	default:
 		panic(fmt.Sprintf("Unsupported socket family %v", family))
 	}
@@ -66,6 +66,14 @@ type ConnectingEndpoint interface {
 
 	// WaiterQueue returns a pointer to the endpoint's waiter queue.
 	WaiterQueue() *waiter.Queue
+
+	// Credentials returns the credentials of the process connecting the
+	// endpoint, which become the peer credentials of the accepted endpoint.
+	Credentials() CredentialsControlMessage
+
+	// SetPeerCredentials sets the credentials of the peer of the endpoint,
+	// i.e. of the process that listened on the endpoint it connected to.
+	SetPeerCredentials(creds CredentialsControlMessage)
 }
 
 // connectionedEndpoint is a Unix-domain connected or connectable endpoint and implements
@@ -136,9 +144,13 @@ func newConnectioned(ctx context.Context, stype linux.SockType, uid UniqueIDProv
 }
 
 // NewPair allocates a new pair of connected unix-domain connectionedEndpoints.
-func NewPair(ctx context.Context, stype linux.SockType, uid UniqueIDProvider) (Endpoint, Endpoint) {
+// Each endpoint reports creds, the credentials of the process creating the
+// pair, as its peer credentials.
+func NewPair(ctx context.Context, stype linux.SockType, uid UniqueIDProvider, creds CredentialsControlMessage) (Endpoint, Endpoint) {
 	a := newConnectioned(ctx, stype, uid)
 	b := newConnectioned(ctx, stype, uid)
+	a.creds, a.peerCreds = creds, creds
+	b.creds, b.peerCreds = creds, creds
 
 	q1 := &queue{ReaderQueue: a.Queue, WriterQueue: b.Queue, limit: defaultBufferSize}
 	q1.InitRefs()
@@ -305,6 +317,12 @@ func (e *connectionedEndpoint) BidirectionalConnect(ctx context.Context, ce Conn
 	ne.ops.SetSendBufferSize(defaultBufferSize, false /* notify */)
 	ne.ops.SetReceiveBufferSize(defaultBufferSize, false /* notify */)
 
+	// Like Linux, the accepted endpoint reports the credentials of the
+	// connecting process, while the connecting endpoint reports those of
+	// the listening process.
+	ne.creds = e.creds
+	ne.peerCreds = ce.Credentials()
+
 	readQueue := &queue{ReaderQueue: ce.WaiterQueue(), WriterQueue: ne.Queue, limit: defaultBufferSize}
 	readQueue.InitRefs()
 	ne.connected = &connectedEndpoint{
@@ -324,6 +342,7 @@ func (e *connectionedEndpoint) BidirectionalConnect(ctx context.Context, ce Conn
 	select {
 	case e.acceptedChan <- ne:
 		// Commit state.
+		ce.SetPeerCredentials(e.creds)
 		writeQueue.IncRef()
 		connected := &connectedEndpoint{
 			endpoint:   ne,
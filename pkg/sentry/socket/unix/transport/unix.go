@@ -207,6 +207,15 @@ type Endpoint interface {
 	// SocketOptions returns the structure which contains all the socket
 	// level options.
 	SocketOptions() *tcpip.SocketOptions
+
+	// SetCredentials sets the credentials of the process that is about to
+	// listen on, connect or create the endpoint. They are reported by
+	// SO_PEERCRED to the peers of the connections made with it.
+	SetCredentials(creds CredentialsControlMessage)
+
+	// PeerCredentials returns the credentials of the peer, reported by
+	// SO_PEERCRED, or nil if they are unknown.
+	PeerCredentials() CredentialsControlMessage
 }
 
 // A Credentialer is a socket or endpoint that supports the SO_PASSCRED socket
@@ -771,6 +780,40 @@ type baseEndpoint struct {
 
 	// ops is used to get socket level options.
 	ops tcpip.SocketOptions
+
+	// creds is the credentials of the process that listened on, connected
+	// or created the endpoint. peerCreds is the credentials of the peer, as
+	// captured when the connection was made. Both may be nil.
+	creds     CredentialsControlMessage
+	peerCreds CredentialsControlMessage
+}
+
+// SetCredentials implements Endpoint.SetCredentials.
+func (e *baseEndpoint) SetCredentials(creds CredentialsControlMessage) {
+	e.Lock()
+	defer e.Unlock()
+	e.creds = creds
+}
+
+// PeerCredentials implements Endpoint.PeerCredentials.
+func (e *baseEndpoint) PeerCredentials() CredentialsControlMessage {
+	e.Lock()
+	defer e.Unlock()
+	return e.peerCreds
+}
+
+// Credentials implements ConnectingEndpoint.Credentials.
+//
+// Preconditions: e.Mutex must be locked.
+func (e *baseEndpoint) Credentials() CredentialsControlMessage {
+	return e.creds
+}
+
+// SetPeerCredentials implements ConnectingEndpoint.SetPeerCredentials.
+//
+// Preconditions: e.Mutex must be locked.
+func (e *baseEndpoint) SetPeerCredentials(creds CredentialsControlMessage) {
+	e.peerCreds = creds
 }
 
 // EventRegister implements waiter.Waitable.EventRegister.
@@ -194,15 +194,29 @@ func (s *SocketOperations) Ioctl(ctx context.Context, _ *fs.File, io usermem.IO,
 // GetSockOpt implements the linux syscall getsockopt(2) for sockets backed by
 // a transport.Endpoint.
 func (s *SocketOperations) GetSockOpt(t *kernel.Task, level, name int, outPtr hostarch.Addr, outLen int) (marshal.Marshallable, *syserr.Error) {
+	if level == linux.SOL_SOCKET && name == linux.SO_PEERCRED {
+		return s.getPeerCred(t, outLen)
+	}
 	return netstack.GetSockOpt(t, s, s.ep, linux.AF_UNIX, s.ep.Type(), level, name, outPtr, outLen)
 }
 
 // Listen implements the linux syscall listen(2) for sockets backed by
 // a transport.Endpoint.
 func (s *socketOpsCommon) Listen(t *kernel.Task, backlog int) *syserr.Error {
+	s.ep.SetCredentials(control.MakeCreds(t))
 	return s.ep.Listen(backlog)
 }
 
+// getPeerCred implements getsockopt(SO_PEERCRED), which returns the
+// credentials of the peer captured when the socket was connected.
+func (s *socketOpsCommon) getPeerCred(t *kernel.Task, outLen int) (marshal.Marshallable, *syserr.Error) {
+	if outLen < linux.SizeOfControlMessageCredentials {
+		return nil, syserr.ErrInvalidArgument
+	}
+	creds := control.PeerCredentials(t, s.ep.PeerCredentials())
+	return &creds, nil
+}
+
 // blockingAccept implements a blocking version of accept(2), that is, if no
 // connections are ready to be accept, it will block until one becomes ready.
 func (s *SocketOperations) blockingAccept(t *kernel.Task, peerAddr *tcpip.FullAddress) (transport.Endpoint, *syserr.Error) {
@@ -428,6 +442,7 @@ func (s *socketOpsCommon) Connect(t *kernel.Task, sockaddr []byte, blocking bool
 	defer ep.Release(t)
 
 	// Connect the server endpoint.
+	s.ep.SetCredentials(control.MakeCreds(t))
 	err = s.ep.Connect(t, ep)
 
 	if err == syserr.ErrWrongProtocolForSocket {
@@ -777,7 +792,7 @@ func (*provider) Pair(t *kernel.Task, stype linux.SockType, protocol int) (*fs.F
 	}
 
 	// Create the endpoints and sockets.
-	ep1, ep2 := transport.NewPair(t, stype, t.Kernel())
+	ep1, ep2 := transport.NewPair(t, stype, t.Kernel(), control.MakeCreds(t))
 	s1 := New(t, ep1, stype)
 	s2 := New(t, ep2, stype)
 
@@ -18,12 +18,14 @@ package state
 import (
 	"fmt"
 	"io"
+	"os"
 
 	"gvisor.dev/gvisor/pkg/context"
 	"gvisor.dev/gvisor/pkg/errors/linuxerr"
 	"gvisor.dev/gvisor/pkg/log"
 	"gvisor.dev/gvisor/pkg/sentry/inet"
 	"gvisor.dev/gvisor/pkg/sentry/kernel"
+	"gvisor.dev/gvisor/pkg/sentry/pgalloc"
 	"gvisor.dev/gvisor/pkg/sentry/time"
 	"gvisor.dev/gvisor/pkg/sentry/vfs"
 	"gvisor.dev/gvisor/pkg/sentry/watchdog"
@@ -32,6 +34,10 @@ import (
 
 var previousMetadata map[string]string
 
+// MetadataPagesFile is the metadata key set when memory pages were saved to a
+// separate pages file, which is then required to restore.
+const MetadataPagesFile = "pages_file"
+
 // ErrStateFile is returned when an error is encountered writing the statefile
 // (which may occur during open or close calls in addition to write).
 type ErrStateFile struct {
@@ -54,8 +60,26 @@ type SaveOpts struct {
 	// Metadata is save metadata.
 	Metadata map[string]string
 
+	// PagesFile is the optional destination of memory pages. If it is set,
+	// pages are not saved to Destination, and can be loaded lazily on
+	// restore.
+	PagesFile *os.File
+
+	// Compression is the compression algorithm of the state file. It
+	// defaults to flate.
+	Compression statefile.Compression
+
+	// Dedup replaces memory pages identical to a page saved before by a
+	// reference to it. It's ignored if PagesFile is set.
+	Dedup bool
+
 	// Callback is called prior to unpause, with any save error.
 	Callback func(err error)
+
+	// Resume is true if the sandbox keeps running after the save, as with
+	// snapshots. The changes made to the kernel to save it are then undone
+	// before tasks are unpaused. It requires VFS2.
+	Resume bool
 }
 
 // Save saves the system state.
@@ -71,19 +95,34 @@ func (opts SaveOpts) Save(ctx context.Context, k *kernel.Kernel, w *watchdog.Wat
 	w.Stop()
 	defer w.Start()
 
+	if opts.Resume {
+		k.PrepareResumableSave()
+		defer k.ResumeAfterSave(ctx)
+	}
+
 	// Supplement the metadata.
 	if opts.Metadata == nil {
 		opts.Metadata = make(map[string]string)
 	}
 	addSaveMetadata(opts.Metadata)
+	mfOpts := pgalloc.SaveOpts{PagesFile: opts.PagesFile}
+	if opts.PagesFile != nil {
+		opts.Metadata[MetadataPagesFile] = "true"
+	} else if opts.Dedup {
+		mfOpts.Dedup = true
+	}
 
 	// Open the statefile.
-	wc, err := statefile.NewWriter(opts.Destination, opts.Key, opts.Metadata)
+	wc, err := statefile.NewWriterOpts(opts.Destination, opts.Key, opts.Metadata, statefile.WriterOpts{
+		Version:     statefile.CurrentVersion,
+		Compression: opts.Compression,
+		Dedup:       mfOpts.Dedup,
+	})
 	if err != nil {
 		err = ErrStateFile{err}
 	} else {
 		// Save the kernel.
-		err = k.SaveTo(ctx, wc)
+		err = k.SaveTo(ctx, wc, mfOpts)
 
 		// ENOSPC is a state file error. This error can only come from
 		// writing the state file, and not from fs.FileOperations.Fsync
@@ -96,6 +135,9 @@ func (opts SaveOpts) Save(ctx context.Context, k *kernel.Kernel, w *watchdog.Wat
 			err = ErrStateFile{closeErr}
 		}
 	}
+	if err != nil && !opts.Resume {
+		k.AbortSave()
+	}
 	opts.Callback(err)
 	return err
 }
@@ -107,6 +149,11 @@ type LoadOpts struct {
 
 	// Key is used for state integrity check.
 	Key []byte
+
+	// PagesFile is the file memory pages were saved to, if any. Pages are
+	// loaded from it after Load returns, in the background or when they are
+	// first accessed. Load takes ownership of PagesFile.
+	PagesFile *os.File
 }
 
 // Load loads the given kernel, setting the provided platform and stack.
@@ -114,11 +161,22 @@ func (opts LoadOpts) Load(ctx context.Context, k *kernel.Kernel, timeReady chan
 	// Open the file.
 	r, m, err := statefile.NewReader(opts.Source, opts.Key)
 	if err != nil {
+		if opts.PagesFile != nil {
+			opts.PagesFile.Close()
+		}
 		return ErrStateFile{err}
 	}
 
 	previousMetadata = m
 
+	if _, ok := m[MetadataPagesFile]; ok != (opts.PagesFile != nil) {
+		if ok {
+			return ErrStateFile{fmt.Errorf("memory pages were saved to a separate pages file, which must be provided")}
+		}
+		opts.PagesFile.Close()
+		return ErrStateFile{fmt.Errorf("memory pages were saved to the state file, but a pages file was provided")}
+	}
+
 	// Restore the Kernel object graph.
-	return k.LoadFrom(ctx, r, timeReady, n, clocks, vfsOpts)
+	return k.LoadFrom(ctx, r, timeReady, n, clocks, vfsOpts, pgalloc.LoadOpts{PagesFile: opts.PagesFile, Dedup: statefile.Deduplicated(m)})
 }
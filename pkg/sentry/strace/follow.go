@@ -0,0 +1,214 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package strace
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gvisor.dev/gvisor/pkg/abi"
+	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/pkg/sentry/arch"
+	"gvisor.dev/gvisor/pkg/sentry/kernel"
+	"gvisor.dev/gvisor/pkg/sync"
+)
+
+// followBufferSize is the number of straces buffered for a follower. Straces
+// are dropped, rather than blocking the traced tasks, once it's reached.
+const followBufferSize = 1024
+
+// follower receives the straces of some processes, see Follow.
+type follower struct {
+	// tgids are the thread group IDs, in the root PID namespace, of the
+	// processes traced. If empty, all processes are traced.
+	tgids map[kernel.ThreadID]struct{}
+
+	// syscalls are the names of the syscalls traced. If empty, all
+	// syscalls are traced.
+	syscalls map[string]struct{}
+
+	// lines are the straces not written to out yet.
+	lines chan string
+
+	// out is where straces are written to.
+	out *os.File
+
+	// dropped is the number of straces dropped because lines was full.
+	// Protected by followMu.
+	dropped uint64
+}
+
+var (
+	// followMu protects followers.
+	followMu sync.Mutex
+
+	// followers are the followers receiving straces.
+	followers []*follower
+)
+
+// wants returns true if f traces syscall name of the process tgid.
+func (f *follower) wants(tgid kernel.ThreadID, name string) bool {
+	if len(f.tgids) > 0 {
+		if _, ok := f.tgids[tgid]; !ok {
+			return false
+		}
+	}
+	if len(f.syscalls) > 0 {
+		if _, ok := f.syscalls[name]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// run writes the straces of f to its output, until writing fails, e.g.
+// because the client went away.
+func (f *follower) run() {
+	defer f.out.Close()
+	for line := range f.lines {
+		if _, err := f.out.WriteString(line); err != nil {
+			log.Infof("Strace follower stopped: %v", err)
+			break
+		}
+	}
+	unfollow(f)
+}
+
+// Follow writes the straces of the processes with thread group IDs pids, in
+// the root PID namespace, to out, until writing to it fails. If pids is empty,
+// all processes are traced. If syscalls is empty, all syscalls are traced.
+// Follow takes ownership of out.
+//
+// Straces are written like strace(1) does when following multiple processes,
+// one line per syscall once it returns. They are independent of the straces
+// sent to the log and event sinks.
+//
+// Preconditions: Initialize has been called.
+func Follow(out *os.File, pids []int32, syscalls []string) error {
+	if sys, ok := Lookup(abi.Host, arch.Host); ok {
+		if _, err := sys.ConvertToSysnoMap(syscalls); err != nil {
+			out.Close()
+			return err
+		}
+	}
+	f := &follower{
+		tgids:    make(map[kernel.ThreadID]struct{}),
+		syscalls: make(map[string]struct{}),
+		lines:    make(chan string, followBufferSize),
+		out:      out,
+	}
+	for _, pid := range pids {
+		f.tgids[kernel.ThreadID(pid)] = struct{}{}
+	}
+	for _, name := range syscalls {
+		f.syscalls[name] = struct{}{}
+	}
+
+	followMu.Lock()
+	followers = append(followers, f)
+	err := updateFollowLocked()
+	followMu.Unlock()
+	if err != nil {
+		unfollow(f)
+		out.Close()
+		return err
+	}
+	go f.run() // S/R-SAFE: not saved, followers are debugging aids.
+	return nil
+}
+
+// unfollow stops sending straces to f.
+func unfollow(f *follower) {
+	followMu.Lock()
+	defer followMu.Unlock()
+	for i, other := range followers {
+		if other == f {
+			followers = append(followers[:i], followers[i+1:]...)
+			close(f.lines)
+			if f.dropped > 0 {
+				log.Warningf("Strace follower dropped %d straces", f.dropped)
+			}
+			break
+		}
+	}
+	if err := updateFollowLocked(); err != nil {
+		log.Warningf("Error updating strace followers: %v", err)
+	}
+}
+
+// updateFollowLocked enables SinkTypeFollow for the syscalls traced by any
+// follower.
+//
+// Preconditions: followMu is locked.
+func updateFollowLocked() error {
+	if len(followers) == 0 {
+		Disable(SinkTypeFollow)
+		return nil
+	}
+	var allowlist []string
+	for _, f := range followers {
+		if len(f.syscalls) == 0 {
+			EnableAll(SinkTypeFollow)
+			return nil
+		}
+		for name := range f.syscalls {
+			allowlist = append(allowlist, name)
+		}
+	}
+	return Enable(allowlist, SinkTypeFollow)
+}
+
+// followed returns true if a follower traces syscall name of t.
+func followed(t *kernel.Task, name string) bool {
+	tgid := t.Kernel().RootPIDNamespace().IDOfThreadGroup(t.ThreadGroup())
+	followMu.Lock()
+	defer followMu.Unlock()
+	for _, f := range followers {
+		if f.wants(tgid, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// sendFollow sends the syscall to the followers tracing it.
+func (i *SyscallInfo) sendFollow(t *kernel.Task, elapsed time.Duration, output []string, args arch.SyscallArguments, retval uintptr, err error, errno int) {
+	var rval string
+	if err == nil {
+		// Fill in the output after successful execution.
+		i.post(t, args, retval, output, LogMaximumSize)
+		rval = fmt.Sprintf("%d (%#x) <%v>", retval, retval, elapsed)
+	} else {
+		rval = fmt.Sprintf("%d (%#x) errno=%d (%s) <%v>", retval, retval, errno, err, elapsed)
+	}
+	pidns := t.Kernel().RootPIDNamespace()
+	line := fmt.Sprintf("[pid %d] %s(%s) = %s\n", pidns.IDOfTask(t), i.name, strings.Join(output, ", "), rval)
+
+	tgid := pidns.IDOfThreadGroup(t.ThreadGroup())
+	followMu.Lock()
+	defer followMu.Unlock()
+	for _, f := range followers {
+		if !f.wants(tgid, i.name) {
+			continue
+		}
+		select {
+		case f.lines <- line:
+		default:
+			f.dropped++
+		}
+	}
+}
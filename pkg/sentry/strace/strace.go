@@ -686,12 +686,13 @@ func (i *SyscallInfo) sendExit(t *kernel.Task, elapsed time.Duration, output []s
 }
 
 type syscallContext struct {
-	info        SyscallInfo
-	args        arch.SyscallArguments
-	start       time.Time
-	logOutput   []string
-	eventOutput []string
-	flags       uint32
+	info         SyscallInfo
+	args         arch.SyscallArguments
+	start        time.Time
+	logOutput    []string
+	eventOutput  []string
+	followOutput []string
+	flags        uint32
 }
 
 // SyscallEnter implements kernel.Stracer.SyscallEnter. It logs the syscall
@@ -712,14 +713,23 @@ func (s SyscallMap) SyscallEnter(t *kernel.Task, sysno uintptr, args arch.Syscal
 	if bits.IsOn32(flags, kernel.StraceEnableEvent) {
 		eventOutput = info.sendEnter(t, args)
 	}
+	var followOutput []string
+	if bits.IsOn32(flags, kernel.StraceEnableFollow) {
+		if followed(t, info.name) {
+			followOutput = info.pre(t, args, LogMaximumSize)
+		} else {
+			flags &^= kernel.StraceEnableFollow
+		}
+	}
 
 	return &syscallContext{
-		info:        info,
-		args:        args,
-		start:       time.Now(),
-		logOutput:   output,
-		eventOutput: eventOutput,
-		flags:       flags,
+		info:         info,
+		args:         args,
+		start:        time.Now(),
+		logOutput:    output,
+		eventOutput:  eventOutput,
+		followOutput: followOutput,
+		flags:        flags,
 	}
 }
 
@@ -736,6 +746,9 @@ func (s SyscallMap) SyscallExit(context interface{}, t *kernel.Task, sysno, rval
 	if bits.IsOn32(c.flags, kernel.StraceEnableEvent) {
 		c.info.sendExit(t, elapsed, c.eventOutput, c.args, rval, err, errno)
 	}
+	if bits.IsOn32(c.flags, kernel.StraceEnableFollow) {
+		c.info.sendFollow(t, elapsed, c.followOutput, c.args, rval, err, errno)
+	}
 }
 
 // ConvertToSysnoMap converts the names to a map keyed on the syscall number
@@ -806,6 +819,9 @@ const (
 
 	// SinkTypeEvent sends strace to event log
 	SinkTypeEvent
+
+	// SinkTypeFollow sends strace to followers, see Follow.
+	SinkTypeFollow
 )
 
 func convertToSyscallFlag(sinks SinkType) uint32 {
@@ -816,6 +832,9 @@ func convertToSyscallFlag(sinks SinkType) uint32 {
 	if bits.IsOn32(uint32(sinks), uint32(SinkTypeEvent)) {
 		ret |= kernel.StraceEnableEvent
 	}
+	if bits.IsOn32(uint32(sinks), uint32(SinkTypeFollow)) {
+		ret |= kernel.StraceEnableFollow
+	}
 	return ret
 }
 
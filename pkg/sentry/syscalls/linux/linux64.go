@@ -392,7 +392,7 @@ var AMD64 = &kernel.SyscallTable{
 		334: syscalls.PartiallySupported("rseq", RSeq, "Not supported on all platforms.", nil),
 
 		// Linux skips ahead to syscall 424 to sync numbers between arches.
-		424: syscalls.ErrorWithEvent("pidfd_send_signal", linuxerr.ENOSYS, "", nil),
+		424: syscalls.PartiallySupported("pidfd_send_signal", PidfdSendSignal, "Not supported with VFS1.", nil),
 		425: syscalls.ErrorWithEvent("io_uring_setup", linuxerr.ENOSYS, "", nil),
 		426: syscalls.ErrorWithEvent("io_uring_enter", linuxerr.ENOSYS, "", nil),
 		427: syscalls.ErrorWithEvent("io_uring_register", linuxerr.ENOSYS, "", nil),
@@ -402,8 +402,9 @@ var AMD64 = &kernel.SyscallTable{
 		431: syscalls.ErrorWithEvent("fsconfig", linuxerr.ENOSYS, "", nil),
 		432: syscalls.ErrorWithEvent("fsmount", linuxerr.ENOSYS, "", nil),
 		433: syscalls.ErrorWithEvent("fspick", linuxerr.ENOSYS, "", nil),
-		434: syscalls.ErrorWithEvent("pidfd_open", linuxerr.ENOSYS, "", nil),
+		434: syscalls.PartiallySupported("pidfd_open", PidfdOpen, "Not supported with VFS1.", nil),
 		435: syscalls.ErrorWithEvent("clone3", linuxerr.ENOSYS, "", nil),
+		436: syscalls.ErrorWithEvent("close_range", linuxerr.ENOSYS, "", nil),
 		441: syscalls.Supported("epoll_pwait2", EpollPwait2),
 	},
 	Emulate: map[hostarch.Addr]uintptr{
@@ -711,7 +712,7 @@ var ARM64 = &kernel.SyscallTable{
 		293: syscalls.PartiallySupported("rseq", RSeq, "Not supported on all platforms.", nil),
 
 		// Linux skips ahead to syscall 424 to sync numbers between arches.
-		424: syscalls.ErrorWithEvent("pidfd_send_signal", linuxerr.ENOSYS, "", nil),
+		424: syscalls.PartiallySupported("pidfd_send_signal", PidfdSendSignal, "Not supported with VFS1.", nil),
 		425: syscalls.ErrorWithEvent("io_uring_setup", linuxerr.ENOSYS, "", nil),
 		426: syscalls.ErrorWithEvent("io_uring_enter", linuxerr.ENOSYS, "", nil),
 		427: syscalls.ErrorWithEvent("io_uring_register", linuxerr.ENOSYS, "", nil),
@@ -721,8 +722,9 @@ var ARM64 = &kernel.SyscallTable{
 		431: syscalls.ErrorWithEvent("fsconfig", linuxerr.ENOSYS, "", nil),
 		432: syscalls.ErrorWithEvent("fsmount", linuxerr.ENOSYS, "", nil),
 		433: syscalls.ErrorWithEvent("fspick", linuxerr.ENOSYS, "", nil),
-		434: syscalls.ErrorWithEvent("pidfd_open", linuxerr.ENOSYS, "", nil),
+		434: syscalls.PartiallySupported("pidfd_open", PidfdOpen, "Not supported with VFS1.", nil),
 		435: syscalls.ErrorWithEvent("clone3", linuxerr.ENOSYS, "", nil),
+		436: syscalls.ErrorWithEvent("close_range", linuxerr.ENOSYS, "", nil),
 		441: syscalls.Supported("epoll_pwait2", EpollPwait2),
 	},
 	Emulate: map[hostarch.Addr]uintptr{},
@@ -0,0 +1,131 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package linux
+
+import (
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+	"gvisor.dev/gvisor/pkg/sentry/arch"
+	"gvisor.dev/gvisor/pkg/sentry/kernel"
+)
+
+// pidfdThreadGroup returns the thread group referred to by the pidfd fd, and
+// the pidfd's status flags.
+func pidfdThreadGroup(t *kernel.Task, fd int32) (*kernel.ThreadGroup, uint32, error) {
+	if fd < 0 {
+		return nil, 0, linuxerr.EINVAL
+	}
+	file := t.GetFileVFS2(fd)
+	if file == nil {
+		return nil, 0, linuxerr.EBADF
+	}
+	defer file.DecRef(t)
+	pidfd, ok := file.Impl().(*kernel.PIDFD)
+	if !ok {
+		return nil, 0, linuxerr.EINVAL
+	}
+	return pidfd.ThreadGroup(), file.StatusFlags(), nil
+}
+
+// PidfdOpen implements linux syscall pidfd_open(2).
+func PidfdOpen(t *kernel.Task, args arch.SyscallArguments) (uintptr, *kernel.SyscallControl, error) {
+	pid := kernel.ThreadID(args[0].Int())
+	flags := args[1].Uint()
+
+	// pidfds are only implemented for VFS2.
+	if !kernel.VFS2Enabled {
+		return 0, nil, linuxerr.ENOSYS
+	}
+	if flags&^linux.PIDFD_NONBLOCK != 0 || pid <= 0 {
+		return 0, nil, linuxerr.EINVAL
+	}
+	target := t.PIDNamespace().TaskWithID(pid)
+	if target == nil {
+		return 0, nil, linuxerr.ESRCH
+	}
+	// "EINVAL: pid refers to a thread that is not a thread-group leader." -
+	// pidfd_open(2)
+	tg := target.ThreadGroup()
+	if tg.Leader() != target {
+		return 0, nil, linuxerr.EINVAL
+	}
+
+	file, err := t.Kernel().NewPIDFD(tg, linux.O_RDWR|flags)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer file.DecRef(t)
+	// "The close-on-exec flag is set on the file descriptor." - pidfd_open(2)
+	fd, err := t.NewFDFromVFS2(0, file, kernel.FDFlags{CloseOnExec: true})
+	return uintptr(fd), nil, err
+}
+
+// PidfdSendSignal implements linux syscall pidfd_send_signal(2).
+func PidfdSendSignal(t *kernel.Task, args arch.SyscallArguments) (uintptr, *kernel.SyscallControl, error) {
+	pidfd := args[0].Int()
+	sig := linux.Signal(args[1].Int())
+	infoAddr := args[2].Pointer()
+	flags := args[3].Uint()
+
+	if flags != 0 {
+		return 0, nil, linuxerr.EINVAL
+	}
+	tg, _, err := pidfdThreadGroup(t, pidfd)
+	if err != nil {
+		if linuxerr.Equals(linuxerr.EINVAL, err) {
+			// Unlike waitid(P_PIDFD), pidfd_send_signal fails with EBADF
+			// when given a file descriptor that isn't a pidfd.
+			err = linuxerr.EBADF
+		}
+		return 0, nil, err
+	}
+	// "EINVAL: The calling process is not in a PID namespace from which it
+	// can send a signal to the target process." - pidfd_send_signal(2)
+	if t.PIDNamespace().IDOfThreadGroup(tg) == 0 {
+		if tg.ID() == 0 {
+			// The thread group has been reaped.
+			return 0, nil, linuxerr.ESRCH
+		}
+		return 0, nil, linuxerr.EINVAL
+	}
+
+	var info linux.SignalInfo
+	if infoAddr != 0 {
+		if _, err := info.CopyIn(t, infoAddr); err != nil {
+			return 0, nil, err
+		}
+		if info.Signo != int32(sig) {
+			return 0, nil, linuxerr.EINVAL
+		}
+		// As in RtSigqueueinfo, if the sender is not the receiver, it can't
+		// use si_codes used by the kernel or SI_TKILL.
+		if (info.Code >= 0 || info.Code == linux.SI_TKILL) && tg != t.ThreadGroup() {
+			return 0, nil, linuxerr.EPERM
+		}
+	} else {
+		// As in Kill.
+		info = linux.SignalInfo{
+			Signo: int32(sig),
+			Code:  linux.SI_USER,
+		}
+		info.SetPID(int32(tg.PIDNamespace().IDOfTask(t)))
+		info.SetUID(int32(t.Credentials().RealKUID.In(tg.Leader().UserNamespace()).OrOverflow()))
+	}
+
+	if !mayKill(t, tg.Leader(), sig) {
+		return 0, nil, linuxerr.EPERM
+	}
+	return 0, nil, tg.SendSignal(&info)
+}
@@ -16,6 +16,8 @@ package linux
 
 import (
 	"fmt"
+	"math"
+	"time"
 
 	"gvisor.dev/gvisor/pkg/abi/linux"
 	"gvisor.dev/gvisor/pkg/errors/linuxerr"
@@ -234,14 +236,27 @@ func Prctl(t *kernel.Task, args arch.SyscallArguments) (uintptr, *kernel.Syscall
 		t.Kernel().EmitUnimplementedEvent(t)
 		return 0, nil, linuxerr.EINVAL
 
+	case linux.PR_GET_TIMERSLACK:
+		return uintptr(t.TimerSlack().Nanoseconds()), nil, nil
+
+	case linux.PR_SET_TIMERSLACK:
+		// "If the nanosecond value supplied in arg2 is greater than zero,
+		// then the "current" value is set to this value. If arg2 is equal
+		// to zero, the "current" timer slack is reset to the thread's
+		// "default" timer slack value."
+		ns := args[1].Uint64()
+		if ns > math.MaxInt64 {
+			ns = math.MaxInt64
+		}
+		t.SetTimerSlack(time.Duration(ns))
+		return 0, nil, nil
+
 	case linux.PR_GET_TIMING,
 		linux.PR_SET_TIMING,
 		linux.PR_GET_TSC,
 		linux.PR_SET_TSC,
 		linux.PR_TASK_PERF_EVENTS_DISABLE,
 		linux.PR_TASK_PERF_EVENTS_ENABLE,
-		linux.PR_GET_TIMERSLACK,
-		linux.PR_SET_TIMERSLACK,
 		linux.PR_MCE_KILL,
 		linux.PR_MCE_KILL_GET,
 		linux.PR_GET_TID_ADDRESS,
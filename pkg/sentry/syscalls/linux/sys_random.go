@@ -30,6 +30,7 @@ import (
 const (
 	_GRND_NONBLOCK = 0x1
 	_GRND_RANDOM   = 0x2
+	_GRND_INSECURE = 0x4
 )
 
 // GetRandom implements the linux syscall getrandom(2).
@@ -37,15 +38,23 @@ const (
 // In a multi-tenant/shared environment, the only valid implementation is to
 // fetch data from the urandom pool, otherwise starvation attacks become
 // possible. The urandom pool is also expected to have plenty of entropy, thus
-// the GRND_RANDOM flag is ignored. The GRND_NONBLOCK flag does not apply, as
-// the pool will already be initialized.
+// the GRND_RANDOM flag is ignored, like Linux does since 5.6. The
+// GRND_NONBLOCK and GRND_INSECURE flags do not apply, as the pool will already
+// be initialized.
+//
+// Bytes are generated by rand.Generator, which is seeded from the host and
+// doesn't make a host syscall per call.
 func GetRandom(t *kernel.Task, args arch.SyscallArguments) (uintptr, *kernel.SyscallControl, error) {
 	addr := args[0].Pointer()
 	length := args[1].SizeT()
 	flags := args[2].Int()
 
 	// Flags are checked for validity but otherwise ignored. See above.
-	if flags & ^(_GRND_NONBLOCK|_GRND_RANDOM) != 0 {
+	if flags & ^(_GRND_NONBLOCK|_GRND_RANDOM|_GRND_INSECURE) != 0 {
+		return 0, nil, linuxerr.EINVAL
+	}
+	// Linux: drivers/char/random.c:getrandom
+	if flags&(_GRND_INSECURE|_GRND_RANDOM) == _GRND_INSECURE|_GRND_RANDOM {
 		return 0, nil, linuxerr.EINVAL
 	}
 
@@ -74,7 +83,7 @@ func GetRandom(t *kernel.Task, args arch.SyscallArguments) (uintptr, *kernel.Sys
 	return 0, nil, err
 }
 
-// randReader is a io.Reader that handles partial reads from rand.Reader.
+// randReader is a io.Reader that handles partial reads from rand.Generator.
 type randReader struct {
 	done int
 	min  int
@@ -83,11 +92,11 @@ type randReader struct {
 // Read implements io.Reader.Read.
 func (r *randReader) Read(dst []byte) (int, error) {
 	if r.done >= r.min {
-		return rand.Reader.Read(dst)
+		return rand.Generator.Read(dst)
 	}
 	min := r.min - r.done
 	if min > len(dst) {
 		min = len(dst)
 	}
-	return io.ReadAtLeast(rand.Reader, dst, min)
+	return io.ReadAtLeast(rand.Generator, dst, min)
 }
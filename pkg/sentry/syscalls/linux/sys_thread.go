@@ -195,6 +195,11 @@ func ExitGroup(t *kernel.Task, args arch.SyscallArguments) (uintptr, *kernel.Sys
 
 // clone is used by Clone, Fork, and VFork.
 func clone(t *kernel.Task, flags int, stack hostarch.Addr, parentTID hostarch.Addr, childTID hostarch.Addr, tls hostarch.Addr) (uintptr, *kernel.SyscallControl, error) {
+	// clone(2) returns both the pidfd and the child's thread ID through
+	// parentTID, so CLONE_PIDFD and CLONE_PARENT_SETTID can't be combined.
+	if flags&linux.CLONE_PIDFD != 0 && flags&linux.CLONE_PARENT_SETTID != 0 {
+		return 0, nil, linuxerr.EINVAL
+	}
 	args := linux.CloneArgs{
 		Flags:      uint64(uint32(flags) &^ linux.CSIGNAL),
 		Pidfd:      uint64(parentTID),
@@ -344,12 +349,30 @@ func Waitid(t *kernel.Task, args arch.SyscallArguments) (uintptr, *kernel.Syscal
 		Events:       kernel.EventTraceeStop,
 		ConsumeEvent: options&linux.WNOWAIT == 0,
 	}
+	nonblock := false
 	switch idtype {
 	case linux.P_ALL:
 	case linux.P_PID:
 		wopts.SpecificTID = kernel.ThreadID(id)
 	case linux.P_PGID:
 		wopts.SpecificPGID = kernel.ProcessGroupID(id)
+	case linux.P_PIDFD:
+		tg, flags, err := pidfdThreadGroup(t, id)
+		if err != nil {
+			return 0, nil, err
+		}
+		// The thread group may have been reaped, or may not be visible
+		// in t's PID namespace; either way, it isn't a waitable child.
+		wopts.SpecificTID = t.PIDNamespace().IDOfThreadGroup(tg)
+		if wopts.SpecificTID == 0 {
+			return 0, nil, linuxerr.ECHILD
+		}
+		// Waiting through a non-blocking pidfd doesn't block, but fails
+		// with EAGAIN rather than returning 0 unless WNOHANG is specified.
+		if flags&linux.O_NONBLOCK != 0 && options&linux.WNOHANG == 0 {
+			nonblock = true
+			options |= linux.WNOHANG
+		}
 	default:
 		return 0, nil, linuxerr.EINVAL
 	}
@@ -378,6 +401,9 @@ func Waitid(t *kernel.Task, args arch.SyscallArguments) (uintptr, *kernel.Syscal
 				var si linux.SignalInfo
 				_, err = si.CopyOut(t, infop)
 			}
+			if err == nil && nonblock {
+				err = linuxerr.EAGAIN
+			}
 		}
 		return 0, nil, err
 	}
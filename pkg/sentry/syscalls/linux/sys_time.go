@@ -123,6 +123,8 @@ func getClock(t *kernel.Task, clockID int32) (ktime.Clock, error) {
 	switch clockID {
 	case linux.CLOCK_REALTIME, linux.CLOCK_REALTIME_COARSE:
 		return t.Kernel().RealtimeClock(), nil
+	case linux.CLOCK_TAI:
+		return t.Kernel().TAIClock(), nil
 	case linux.CLOCK_MONOTONIC, linux.CLOCK_MONOTONIC_COARSE,
 		linux.CLOCK_MONOTONIC_RAW, linux.CLOCK_BOOTTIME:
 		// CLOCK_MONOTONIC approximates CLOCK_MONOTONIC_RAW.
@@ -212,12 +214,12 @@ func ClockNanosleep(t *kernel.Task, args arch.SyscallArguments) (uintptr, *kerne
 		return 0, nil, linuxerr.EINVAL
 	}
 
-	// Only allow clock constants also allowed by Linux. (CLOCK_TAI is
-	// unimplemented.)
+	// Only allow clock constants also allowed by Linux.
 	if clockID > 0 {
 		if clockID != linux.CLOCK_REALTIME &&
 			clockID != linux.CLOCK_MONOTONIC &&
 			clockID != linux.CLOCK_BOOTTIME &&
+			clockID != linux.CLOCK_TAI &&
 			clockID != linux.CLOCK_PROCESS_CPUTIME_ID {
 			return 0, nil, linuxerr.EINVAL
 		}
@@ -15,6 +15,8 @@
 package vfs2
 
 import (
+	"math"
+
 	"gvisor.dev/gvisor/pkg/abi/linux"
 	"gvisor.dev/gvisor/pkg/errors/linuxerr"
 	"gvisor.dev/gvisor/pkg/sentry/arch"
@@ -44,6 +46,49 @@ func Close(t *kernel.Task, args arch.SyscallArguments) (uintptr, *kernel.Syscall
 	return 0, nil, slinux.HandleIOErrorVFS2(t, false /* partial */, err, linuxerr.EINTR, "close", file)
 }
 
+// CloseRange implements Linux syscall close_range(2).
+func CloseRange(t *kernel.Task, args arch.SyscallArguments) (uintptr, *kernel.SyscallControl, error) {
+	first := args[0].Uint()
+	last := args[1].Uint()
+	flags := args[2].Uint()
+
+	if flags&^(linux.CLOSE_RANGE_UNSHARE|linux.CLOSE_RANGE_CLOEXEC) != 0 || first > last {
+		return 0, nil, linuxerr.EINVAL
+	}
+	if first > math.MaxInt32 {
+		// There are no file descriptors in the range.
+		return 0, nil, nil
+	}
+	if last > math.MaxInt32 {
+		last = math.MaxInt32
+	}
+
+	if flags&linux.CLOSE_RANGE_UNSHARE != 0 {
+		if err := t.Unshare(linux.CLONE_FILES); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	if flags&linux.CLOSE_RANGE_CLOEXEC != 0 {
+		return 0, nil, t.FDTable().SetFlagsForRange(t, int32(first), int32(last), kernel.FDFlags{CloseOnExec: true})
+	}
+
+	for fd := int32(first); fd <= int32(last); {
+		removed, _, file := t.FDTable().RemoveNextInRange(t, fd, int32(last))
+		if file == nil {
+			break
+		}
+		// As in Linux, errors from closing each file are ignored.
+		file.OnClose(t)
+		file.DecRef(t)
+		if removed == math.MaxInt32 {
+			break
+		}
+		fd = removed + 1
+	}
+	return 0, nil, nil
+}
+
 // Dup implements Linux syscall dup(2).
 func Dup(t *kernel.Task, args arch.SyscallArguments) (uintptr, *kernel.SyscallControl, error) {
 	fd := args[0].Int()
@@ -230,12 +275,29 @@ func Fcntl(t *kernel.Task, args arch.SyscallArguments) (uintptr, *kernel.Syscall
 	case linux.F_SETSIG:
 		a := file.SetAsyncHandler(fasync.NewVFS2(int(fd))).(*fasync.FileAsync)
 		return 0, nil, a.SetSignal(linux.Signal(args[2].Int()))
+	case linux.F_NOTIFY:
+		return 0, nil, setDirNotify(t, int(fd), file, args[2].Uint())
 	default:
 		// Everything else is not yet supported.
 		return 0, nil, linuxerr.EINVAL
 	}
 }
 
+// setDirNotify implements fcntl(F_NOTIFY). Directory notifications are
+// signaled like O_ASYNC I/O events, with the signal set by F_SETSIG.
+func setDirNotify(t *kernel.Task, fd int, file *vfs.FileDescription, mask uint32) error {
+	a := file.SetAsyncHandler(fasync.NewVFS2(fd)).(*fasync.FileAsync)
+	if err := file.SetDirNotify(t, mask, a); err != nil {
+		return err
+	}
+	if mask&^linux.DN_MULTISHOT != 0 {
+		// As in Linux, the signals are sent to the calling process,
+		// regardless of the owner previously set with F_SETOWN.
+		a.SetOwnerThreadGroup(t, t.ThreadGroup())
+	}
+	return nil
+}
+
 func getAsyncOwner(t *kernel.Task, fd *vfs.FileDescription) (ownerEx linux.FOwnerEx, hasOwner bool) {
 	a := fd.AsyncHandler()
 	if a == nil {
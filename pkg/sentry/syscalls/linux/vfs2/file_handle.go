@@ -0,0 +1,145 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vfs2
+
+import (
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+	"gvisor.dev/gvisor/pkg/hostarch"
+	"gvisor.dev/gvisor/pkg/marshal/primitive"
+	"gvisor.dev/gvisor/pkg/sentry/arch"
+	"gvisor.dev/gvisor/pkg/sentry/kernel"
+	"gvisor.dev/gvisor/pkg/sentry/vfs"
+)
+
+// fileHandleHeaderSize is the size of the fixed part of struct file_handle:
+// u32 handle_bytes and int handle_type.
+const fileHandleHeaderSize = 8
+
+// NameToHandleAt implements Linux syscall name_to_handle_at(2).
+func NameToHandleAt(t *kernel.Task, args arch.SyscallArguments) (uintptr, *kernel.SyscallControl, error) {
+	dirfd := args[0].Int()
+	pathAddr := args[1].Pointer()
+	handleAddr := args[2].Pointer()
+	mountIDAddr := args[3].Pointer()
+	flags := args[4].Int()
+
+	if flags&^(linux.AT_EMPTY_PATH|linux.AT_SYMLINK_FOLLOW) != 0 {
+		return 0, nil, linuxerr.EINVAL
+	}
+	var handleBytes uint32
+	if _, err := primitive.CopyUint32In(t, handleAddr, &handleBytes); err != nil {
+		return 0, nil, err
+	}
+	if handleBytes > linux.MAX_HANDLE_SZ {
+		return 0, nil, linuxerr.EINVAL
+	}
+
+	path, err := copyInPath(t, pathAddr)
+	if err != nil {
+		return 0, nil, err
+	}
+	tpop, err := getTaskPathOperation(t, dirfd, path, shouldAllowEmptyPath(flags&linux.AT_EMPTY_PATH != 0), shouldFollowFinalSymlink(flags&linux.AT_SYMLINK_FOLLOW != 0))
+	if err != nil {
+		return 0, nil, err
+	}
+	defer tpop.Release(t)
+
+	handle, mnt, err := t.Kernel().VFS().NameToHandleAt(t, t.Credentials(), &tpop.pop)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer mnt.DecRef(t)
+
+	if len(handle) > int(handleBytes) {
+		// "If the value in handle_bytes is too small, the call fails with
+		// EOVERFLOW and handle_bytes is set to the required size" -
+		// name_to_handle_at(2)
+		if _, err := primitive.CopyUint32Out(t, handleAddr, uint32(len(handle))); err != nil {
+			return 0, nil, err
+		}
+		return 0, nil, linuxerr.EOVERFLOW
+	}
+	buf := make([]byte, fileHandleHeaderSize+len(handle))
+	hostarch.ByteOrder.PutUint32(buf[0:], uint32(len(handle)))
+	hostarch.ByteOrder.PutUint32(buf[4:], vfs.FileHandleType)
+	copy(buf[fileHandleHeaderSize:], handle)
+	if _, err := t.CopyOutBytes(handleAddr, buf); err != nil {
+		return 0, nil, err
+	}
+	_, err = primitive.CopyInt32Out(t, mountIDAddr, int32(mnt.ID))
+	return 0, nil, err
+}
+
+// OpenByHandleAt implements Linux syscall open_by_handle_at(2).
+func OpenByHandleAt(t *kernel.Task, args arch.SyscallArguments) (uintptr, *kernel.SyscallControl, error) {
+	mountFD := args[0].Int()
+	handleAddr := args[1].Pointer()
+	flags := args[2].Uint()
+
+	// Opening a file by handle doesn't resolve a path to it, so search
+	// permission on the directories leading to the file isn't checked. As in
+	// Linux, this requires CAP_DAC_READ_SEARCH in the initial user namespace.
+	if !t.HasCapabilityIn(linux.CAP_DAC_READ_SEARCH, t.Kernel().RootUserNamespace()) {
+		return 0, nil, linuxerr.EPERM
+	}
+
+	var hdr [fileHandleHeaderSize]byte
+	if _, err := t.CopyInBytes(handleAddr, hdr[:]); err != nil {
+		return 0, nil, err
+	}
+	handleBytes := hostarch.ByteOrder.Uint32(hdr[0:])
+	handleType := int32(hostarch.ByteOrder.Uint32(hdr[4:]))
+	if handleBytes == 0 || handleBytes > linux.MAX_HANDLE_SZ {
+		return 0, nil, linuxerr.EINVAL
+	}
+	handle := make([]byte, handleBytes)
+	if _, err := t.CopyInBytes(handleAddr+fileHandleHeaderSize, handle); err != nil {
+		return 0, nil, err
+	}
+	if handleType != vfs.FileHandleType {
+		return 0, nil, linuxerr.ESTALE
+	}
+
+	var mnt *vfs.Mount
+	if mountFD == linux.AT_FDCWD {
+		wd := t.FSContext().WorkingDirectoryVFS2()
+		mnt = wd.Mount()
+		mnt.IncRef()
+		wd.DecRef(t)
+	} else {
+		file := t.GetFileVFS2(mountFD)
+		if file == nil {
+			return 0, nil, linuxerr.EBADF
+		}
+		mnt = file.Mount()
+		mnt.IncRef()
+		file.DecRef(t)
+	}
+	defer mnt.DecRef(t)
+
+	file, err := t.Kernel().VFS().OpenByHandle(t, t.Credentials(), mnt, handle, &vfs.OpenOptions{
+		Flags: flags | linux.O_LARGEFILE,
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	defer file.DecRef(t)
+
+	fd, err := t.NewFDFromVFS2(0, file, kernel.FDFlags{
+		CloseOnExec: flags&linux.O_CLOEXEC != 0,
+	})
+	return uintptr(fd), nil, err
+}
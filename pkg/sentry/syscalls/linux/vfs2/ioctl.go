@@ -15,11 +15,15 @@
 package vfs2
 
 import (
+	"fmt"
+
 	"gvisor.dev/gvisor/pkg/abi/linux"
 	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+	"gvisor.dev/gvisor/pkg/log"
 	"gvisor.dev/gvisor/pkg/marshal/primitive"
 	"gvisor.dev/gvisor/pkg/sentry/arch"
 	"gvisor.dev/gvisor/pkg/sentry/kernel"
+	"gvisor.dev/gvisor/pkg/sentry/vfs"
 )
 
 // Ioctl implements Linux syscall ioctl(2).
@@ -108,5 +112,20 @@ func Ioctl(t *kernel.Task, args arch.SyscallArguments) (uintptr, *kernel.Syscall
 	}
 
 	ret, err := file.Ioctl(t, t.MemoryManager(), args)
+	if vfs.IoctlAuditEnabled && linuxerr.Equals(linuxerr.ENOTTY, err) {
+		auditIoctl(t, fd, file, args[1].Uint())
+	}
 	return ret, nil, err
 }
+
+// auditIoctl records an ioctl request that isn't handled for file.
+func auditIoctl(t *kernel.Task, fd int32, file *vfs.FileDescription, request uint32) {
+	dev := "not a device"
+	if kind, major, minor, ok := file.DeviceNumber(t); ok {
+		dev = fmt.Sprintf("%s device (%d, %d)", kind, major, minor)
+	}
+	log.Warningf("Unhandled ioctl %#x (dir: %d, type: %#x, nr: %d, size: %d) on fd %d, %s, path %q, by %q (tid %d)",
+		request, linux.IOC_DIR(request), linux.IOC_TYPE(request), linux.IOC_NR(request), linux.IOC_SIZE(request),
+		fd, dev, file.MappedName(t), t.Name(), t.ThreadID())
+	t.Kernel().EmitUnimplementedEvent(t)
+}
@@ -45,9 +45,6 @@ const maxOptLen = 1024 * 8
 // buffers upto INT_MAX.
 const maxControlLen = 10 * 1024 * 1024
 
-// maxListenBacklog is the maximum limit of listen backlog supported.
-const maxListenBacklog = 1024
-
 // nameLenOffset is the offset from the start of the MessageHeader64 struct to
 // the NameLen field.
 const nameLenOffset = 8
@@ -382,11 +379,11 @@ func Listen(t *kernel.Task, args arch.SyscallArguments) (uintptr, *kernel.Syscal
 		return 0, nil, linuxerr.ENOTSOCK
 	}
 
-	if backlog > maxListenBacklog {
+	if somaxconn := t.NetworkNamespace().Somaxconn(); backlog > somaxconn {
 		// Linux treats incoming backlog as uint with a limit defined by
 		// sysctl_somaxconn.
 		// https://github.com/torvalds/linux/blob/7acac4b3196/net/socket.c#L1666
-		backlog = maxListenBacklog
+		backlog = somaxconn
 	}
 
 	// Accept one more than the configured listen backlog to keep in parity with
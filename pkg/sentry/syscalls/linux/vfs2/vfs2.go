@@ -153,6 +153,8 @@ func Override() {
 	s.Table[295] = syscalls.Supported("preadv", Preadv)
 	s.Table[296] = syscalls.Supported("pwritev", Pwritev)
 	s.Table[299] = syscalls.Supported("recvmmsg", RecvMMsg)
+	s.Table[303] = syscalls.PartiallySupported("name_to_handle_at", NameToHandleAt, "Only supported on gofer mounts. Handles can only be opened through mounts from which the file is reachable.", nil)
+	s.Table[304] = syscalls.PartiallySupported("open_by_handle_at", OpenByHandleAt, "Only supported on gofer mounts. Handles can only be opened through mounts from which the file is reachable.", nil)
 	s.Table[306] = syscalls.Supported("syncfs", Syncfs)
 	s.Table[307] = syscalls.Supported("sendmmsg", SendMMsg)
 	s.Table[316] = syscalls.Supported("renameat2", Renameat2)
@@ -161,6 +163,7 @@ func Override() {
 	s.Table[327] = syscalls.Supported("preadv2", Preadv2)
 	s.Table[328] = syscalls.Supported("pwritev2", Pwritev2)
 	s.Table[332] = syscalls.Supported("statx", Statx)
+	s.Table[436] = syscalls.Supported("close_range", CloseRange)
 	s.Table[441] = syscalls.Supported("epoll_pwait2", EpollPwait2)
 	s.Init()
 
@@ -266,6 +269,8 @@ func Override() {
 	s.Table[223] = syscalls.PartiallySupported("fadvise64", Fadvise64, "Not all options are supported.", nil)
 	s.Table[242] = syscalls.Supported("accept4", Accept4)
 	s.Table[243] = syscalls.Supported("recvmmsg", RecvMMsg)
+	s.Table[264] = syscalls.PartiallySupported("name_to_handle_at", NameToHandleAt, "Only supported on gofer mounts. Handles can only be opened through mounts from which the file is reachable.", nil)
+	s.Table[265] = syscalls.PartiallySupported("open_by_handle_at", OpenByHandleAt, "Only supported on gofer mounts. Handles can only be opened through mounts from which the file is reachable.", nil)
 	s.Table[267] = syscalls.Supported("syncfs", Syncfs)
 	s.Table[269] = syscalls.Supported("sendmmsg", SendMMsg)
 	s.Table[276] = syscalls.Supported("renameat2", Renameat2)
@@ -274,6 +279,7 @@ func Override() {
 	s.Table[286] = syscalls.Supported("preadv2", Preadv2)
 	s.Table[287] = syscalls.Supported("pwritev2", Pwritev2)
 	s.Table[291] = syscalls.Supported("statx", Statx)
+	s.Table[436] = syscalls.Supported("close_range", CloseRange)
 	s.Table[441] = syscalls.Supported("epoll_pwait2", EpollPwait2)
 	s.Init()
 }
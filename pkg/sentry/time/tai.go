@@ -0,0 +1,36 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package time
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// HostTAIOffset returns the offset in seconds between the host's CLOCK_TAI and
+// CLOCK_REALTIME. The offset is maintained by the host's NTP daemon, and is 0
+// if it was never set.
+func HostTAIOffset() (int64, error) {
+	var real, tai unix.Timespec
+	if err := unix.ClockGettime(unix.CLOCK_REALTIME, &real); err != nil {
+		return 0, err
+	}
+	if err := unix.ClockGettime(unix.CLOCK_TAI, &tai); err != nil {
+		return 0, err
+	}
+	// The offset is always a whole number of seconds, round to account for
+	// the time elapsed between both calls.
+	diff := tai.Nano() - real.Nano()
+	return (diff + 5e8) / 1e9, nil
+}
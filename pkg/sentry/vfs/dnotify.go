@@ -0,0 +1,138 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vfs
+
+import (
+	"sync/atomic"
+
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/context"
+	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+	"gvisor.dev/gvisor/pkg/sentry/uniqueid"
+)
+
+// DirNotifier is notified of the events in directories watched with
+// fcntl(F_NOTIFY).
+type DirNotifier interface {
+	// NotifyDir is called when an event occurs in a watched directory.
+	NotifyDir()
+}
+
+// SetDirNotify implements fcntl(F_NOTIFY). It requests that n be notified of
+// the events in mask, a set of DN_* flags, that occur in the directory at
+// which fd was opened. A mask without any event stops the notifications.
+//
+// Directory notifications (dnotify) are implemented with an inotify instance
+// that isn't installed in any file descriptor table and forwards its events to
+// n. Unlike Linux, the notifications stop when the FileDescription is released
+// rather than when any file descriptor referring to it is closed.
+func (fd *FileDescription) SetDirNotify(ctx context.Context, mask uint32, n DirNotifier) error {
+	stat, err := fd.Stat(ctx, StatOptions{Mask: linux.STATX_TYPE})
+	if err != nil {
+		return err
+	}
+	if stat.Mode&linux.S_IFMT != linux.S_IFDIR {
+		return linuxerr.ENOTDIR
+	}
+
+	fd.flagsMu.Lock()
+	i := fd.dnotify
+	if mask&^linux.DN_MULTISHOT == 0 {
+		fd.dnotify = nil
+		fd.flagsMu.Unlock()
+		if i != nil {
+			i.Release(ctx)
+		}
+		return nil
+	}
+	if i == nil {
+		i = &Inotify{
+			id:          uniqueid.GlobalFromContext(ctx),
+			watches:     make(map[int32]*Watch),
+			dirNotifier: n,
+		}
+		fd.dnotify = i
+	}
+	defer fd.flagsMu.Unlock()
+	return i.setDirWatch(fd.Dentry(), mask)
+}
+
+// releaseDirNotify stops the notifications requested by SetDirNotify.
+func (fd *FileDescription) releaseDirNotify(ctx context.Context) {
+	fd.flagsMu.Lock()
+	i := fd.dnotify
+	fd.dnotify = nil
+	fd.flagsMu.Unlock()
+	if i != nil {
+		i.Release(ctx)
+	}
+}
+
+// setDirWatch adds the events in mask, a set of DN_* flags, to the watch of i
+// on target, creating it if it doesn't exist.
+//
+// The caller must hold a reference on target.
+func (i *Inotify) setDirWatch(target *Dentry, mask uint32) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	ws := target.Watches()
+	if ws == nil {
+		// As with inotify, directories of filesystems that don't support
+		// watches never generate events.
+		return nil
+	}
+	newmask := dirNotifyEvents(mask)
+	if mask&linux.DN_MULTISHOT == 0 {
+		newmask |= linux.IN_ONESHOT
+	}
+	if existing := ws.Lookup(i.id); existing != nil {
+		// As in Linux, the events are added to the existing ones, and the
+		// watch remains multishot once it has been made so.
+		oldmask := atomic.LoadUint32(&existing.mask)
+		if oldmask&linux.IN_ONESHOT == 0 {
+			newmask &^= linux.IN_ONESHOT
+		}
+		atomic.StoreUint32(&existing.mask, newmask|oldmask&^linux.IN_ONESHOT)
+		return nil
+	}
+	i.newWatchLocked(target, ws, newmask)
+	return nil
+}
+
+// dirNotifyEvents converts the DN_* events in mask to inotify events, as
+// fs/notify/dnotify/dnotify.c:convert_arg does.
+func dirNotifyEvents(mask uint32) uint32 {
+	var events uint32
+	if mask&linux.DN_ACCESS != 0 {
+		events |= linux.IN_ACCESS
+	}
+	if mask&linux.DN_MODIFY != 0 {
+		events |= linux.IN_MODIFY
+	}
+	if mask&linux.DN_CREATE != 0 {
+		events |= linux.IN_CREATE | linux.IN_MOVED_TO
+	}
+	if mask&linux.DN_DELETE != 0 {
+		events |= linux.IN_DELETE | linux.IN_MOVED_FROM
+	}
+	if mask&linux.DN_RENAME != 0 {
+		events |= linux.IN_MOVED_FROM | linux.IN_MOVED_TO
+	}
+	if mask&linux.DN_ATTRIB != 0 {
+		events |= linux.IN_ATTRIB
+	}
+	return events
+}
@@ -44,7 +44,8 @@ import (
 type FileDescription struct {
 	FileDescriptionRefs
 
-	// flagsMu protects `statusFlags`, `saved`, and `asyncHandler` below.
+	// flagsMu protects `statusFlags`, `saved`, `asyncHandler`, and `dnotify`
+	// below.
 	flagsMu sync.Mutex `state:"nosave"`
 
 	// statusFlags contains status flags, "initialized by open(2) and possibly
@@ -63,6 +64,10 @@ type FileDescription struct {
 	// also be set by fcntl(2).
 	asyncHandler FileAsync
 
+	// dnotify is the inotify instance implementing the directory
+	// notifications requested with fcntl(F_NOTIFY), or nil if there are none.
+	dnotify *Inotify
+
 	// epolls is the set of epollInterests registered for this FileDescription.
 	// epolls is protected by epollMu.
 	epollMu sync.Mutex `state:"nosave"`
@@ -185,6 +190,9 @@ func (fd *FileDescription) DecRef(ctx context.Context) {
 			ep.interestMu.Unlock()
 		}
 
+		// Stop directory notifications.
+		fd.releaseDirNotify(ctx)
+
 		// If BSD locks were used, release any lock that it may have acquired.
 		if atomic.LoadUint32(&fd.usedLockBSD) != 0 {
 			fd.impl.UnlockBSD(context.Background(), fd)
@@ -686,7 +694,11 @@ func (fd *FileDescription) ConfigureMMap(ctx context.Context, opts *memmap.MMapO
 
 // Ioctl implements the ioctl(2) syscall.
 func (fd *FileDescription) Ioctl(ctx context.Context, uio usermem.IO, args arch.SyscallArguments) (uintptr, error) {
-	return fd.impl.Ioctl(ctx, uio, args)
+	ret, err := fd.impl.Ioctl(ctx, uio, args)
+	if linuxerr.Equals(linuxerr.ENOTTY, err) {
+		return fd.registeredIoctl(ctx, uio, args)
+	}
+	return ret, err
 }
 
 // ListXattr returns all extended attribute names for the file represented by
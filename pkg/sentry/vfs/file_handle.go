@@ -0,0 +1,111 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vfs
+
+import (
+	"gvisor.dev/gvisor/pkg/context"
+	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/auth"
+)
+
+// FileHandleIDSize is the size of the file identifiers returned by
+// FileHandleFilesystemImpl.FileHandleID.
+const FileHandleIDSize = 16
+
+// FileHandleType is the handle_type of the file handles returned by
+// VirtualFilesystem.NameToHandleAt.
+const FileHandleType = 1
+
+// FileHandleFilesystemImpl is implemented by FilesystemImpls that support
+// name_to_handle_at(2) and open_by_handle_at(2).
+type FileHandleFilesystemImpl interface {
+	// FileHandleID returns an identifier of the file represented by d, which
+	// is stable for as long as the file exists, and unique among the files
+	// of the filesystem. The file can then be found by FileHandleDentry.
+	//
+	// Preconditions: d is a Dentry of the FilesystemImpl.
+	FileHandleID(ctx context.Context, d *Dentry) ([FileHandleIDSize]byte, error)
+
+	// FileHandleDentry returns the Dentry of the file identified by id, as
+	// returned by FileHandleID, with a reference taken on it. It returns
+	// ESTALE if the file doesn't exist anymore, or can't be found.
+	FileHandleDentry(ctx context.Context, id [FileHandleIDSize]byte) (*Dentry, error)
+}
+
+// NameToHandleAt returns a file handle for the file at the given path, along
+// with the mount containing it.
+//
+// A file handle is the identifier of the file returned by its filesystem, so
+// it remains valid when the file is renamed, until it's deleted.
+//
+// Unlike Linux, handles can only be opened through a mount from which the
+// file is reachable, which prevents them from being used to reach files
+// outside of the mounts visible to the caller.
+func (vfs *VirtualFilesystem) NameToHandleAt(ctx context.Context, creds *auth.Credentials, pop *PathOperation) ([]byte, *Mount, error) {
+	vd, err := vfs.GetDentryAt(ctx, creds, pop, &GetDentryOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+	defer vd.DecRef(ctx)
+
+	impl, ok := vd.mount.fs.impl.(FileHandleFilesystemImpl)
+	if !ok {
+		return nil, nil, linuxerr.EOPNOTSUPP
+	}
+	id, err := impl.FileHandleID(ctx, vd.dentry)
+	if err != nil {
+		return nil, nil, err
+	}
+	vd.mount.IncRef()
+	return id[:], vd.mount, nil
+}
+
+// OpenByHandle returns a FileDescription for the file identified by handle,
+// which must have been returned by NameToHandleAt for a file reachable from
+// mnt. A reference is taken on the returned FileDescription.
+//
+// Like in Linux, the file is opened without resolving a path to it, so search
+// permission on the directories leading to it isn't checked.
+func (vfs *VirtualFilesystem) OpenByHandle(ctx context.Context, creds *auth.Credentials, mnt *Mount, handle []byte, opts *OpenOptions) (*FileDescription, error) {
+	impl, ok := mnt.fs.impl.(FileHandleFilesystemImpl)
+	if !ok {
+		return nil, linuxerr.EOPNOTSUPP
+	}
+	var id [FileHandleIDSize]byte
+	if len(handle) != len(id) {
+		return nil, linuxerr.ESTALE
+	}
+	copy(id[:], handle)
+	d, err := impl.FileHandleDentry(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	vd := VirtualDentry{mount: mnt, dentry: d}
+	defer d.DecRef(ctx)
+
+	mntRoot := VirtualDentry{mount: mnt, dentry: mnt.root}
+	path, err := vfs.PathnameReachable(ctx, mntRoot, vd)
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		// The file isn't under the root of mnt, e.g. a bind mount.
+		return nil, linuxerr.ESTALE
+	}
+	return vfs.OpenAt(ctx, creds, &PathOperation{
+		Root:  mntRoot,
+		Start: vd,
+	}, opts)
+}
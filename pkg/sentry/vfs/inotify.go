@@ -89,6 +89,12 @@ type Inotify struct {
 
 	// Map from watch descriptors to watch objects.
 	watches map[int32]*Watch
+
+	// dirNotifier is notified of the events of this inotify instance instead
+	// of queuing them, if it isn't nil. See FileDescription.SetDirNotify.
+	//
+	// dirNotifier is immutable.
+	dirNotifier DirNotifier
 }
 
 var _ FileDescriptionImpl = (*Inotify)(nil)
@@ -267,6 +273,15 @@ func (i *Inotify) Ioctl(ctx context.Context, uio usermem.IO, args arch.SyscallAr
 }
 
 func (i *Inotify) queueEvent(ev *Event) {
+	if i.dirNotifier != nil {
+		// Directory notifications don't describe the event, and aren't sent
+		// when the watch is removed.
+		if ev.mask&linux.IN_IGNORED == 0 {
+			i.dirNotifier.NotifyDir()
+		}
+		return
+	}
+
 	i.evMu.Lock()
 
 	// Check if we should coalesce the event we're about to queue with the last
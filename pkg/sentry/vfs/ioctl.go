@@ -0,0 +1,130 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vfs
+
+import (
+	"fmt"
+
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/context"
+	"gvisor.dev/gvisor/pkg/errors/linuxerr"
+	"gvisor.dev/gvisor/pkg/sentry/arch"
+	"gvisor.dev/gvisor/pkg/sync"
+	"gvisor.dev/gvisor/pkg/usermem"
+)
+
+// IoctlAuditEnabled is set to true to record ioctls that aren't handled by
+// any implementation, so that compatibility gaps can be identified.
+var IoctlAuditEnabled = false
+
+// AnyMinor can be passed to RegisterIoctl to handle a request for all minor
+// device numbers of a major device number.
+const AnyMinor = ^uint32(0)
+
+// IoctlHandler handles an ioctl(2) request on a file representing a device.
+// It has the same semantics as FileDescriptionImpl.Ioctl.
+type IoctlHandler func(ctx context.Context, fd *FileDescription, uio usermem.IO, args arch.SyscallArguments) (uintptr, error)
+
+type ioctlKey struct {
+	dev     devTuple
+	request uint32
+}
+
+// ioctlHandlers contains all registered IoctlHandlers. Handlers are
+// registered at init time and aren't saved, so they are global instead of
+// being part of VirtualFilesystem.
+var ioctlHandlers struct {
+	mu       sync.RWMutex
+	handlers map[ioctlKey]IoctlHandler
+}
+
+// RegisterIoctl registers h to handle the given ioctl request on files
+// representing the given device. minor may be AnyMinor.
+//
+// Registered handlers are only used for requests that the file's
+// FileDescriptionImpl doesn't handle, i.e. for which it returns ENOTTY. This
+// allows support for ioctls to be added to existing devices without changing
+// their implementation.
+//
+// RegisterIoctl is meant to be called from init functions; it panics if a
+// handler is already registered for the same device and request.
+func RegisterIoctl(kind DeviceKind, major, minor, request uint32, h IoctlHandler) {
+	key := ioctlKey{dev: devTuple{kind, major, minor}, request: request}
+	ioctlHandlers.mu.Lock()
+	defer ioctlHandlers.mu.Unlock()
+	if ioctlHandlers.handlers == nil {
+		ioctlHandlers.handlers = make(map[ioctlKey]IoctlHandler)
+	}
+	if _, ok := ioctlHandlers.handlers[key]; ok {
+		panic(fmt.Sprintf("ioctl %#x is already registered for %s device (%d, %d)", request, kind, major, minor))
+	}
+	ioctlHandlers.handlers[key] = h
+}
+
+// findIoctlHandler returns the handler registered for the device and request,
+// or nil if there is none.
+func findIoctlHandler(kind DeviceKind, major, minor, request uint32) IoctlHandler {
+	ioctlHandlers.mu.RLock()
+	defer ioctlHandlers.mu.RUnlock()
+	if len(ioctlHandlers.handlers) == 0 {
+		return nil
+	}
+	if h, ok := ioctlHandlers.handlers[ioctlKey{dev: devTuple{kind, major, minor}, request: request}]; ok {
+		return h
+	}
+	return ioctlHandlers.handlers[ioctlKey{dev: devTuple{kind, major, AnyMinor}, request: request}]
+}
+
+// hasIoctlHandlers returns true if any IoctlHandler is registered.
+func hasIoctlHandlers() bool {
+	ioctlHandlers.mu.RLock()
+	defer ioctlHandlers.mu.RUnlock()
+	return len(ioctlHandlers.handlers) != 0
+}
+
+// DeviceNumber returns the device kind and number of the device represented
+// by fd. ok is false if fd doesn't represent a device special file.
+func (fd *FileDescription) DeviceNumber(ctx context.Context) (kind DeviceKind, major, minor uint32, ok bool) {
+	stat, err := fd.Stat(ctx, StatOptions{Mask: linux.STATX_TYPE})
+	if err != nil || stat.Mask&linux.STATX_TYPE == 0 {
+		return 0, 0, 0, false
+	}
+	switch stat.Mode & linux.S_IFMT {
+	case linux.S_IFCHR:
+		kind = CharDevice
+	case linux.S_IFBLK:
+		kind = BlockDevice
+	default:
+		return 0, 0, 0, false
+	}
+	return kind, stat.RdevMajor, stat.RdevMinor, true
+}
+
+// registeredIoctl handles an ioctl request not handled by fd.impl using
+// registered IoctlHandlers.
+func (fd *FileDescription) registeredIoctl(ctx context.Context, uio usermem.IO, args arch.SyscallArguments) (uintptr, error) {
+	if !hasIoctlHandlers() {
+		return 0, linuxerr.ENOTTY
+	}
+	kind, major, minor, ok := fd.DeviceNumber(ctx)
+	if !ok {
+		return 0, linuxerr.ENOTTY
+	}
+	h := findIoctlHandler(kind, major, minor, args[1].Uint())
+	if h == nil {
+		return 0, linuxerr.ENOTTY
+	}
+	return h(ctx, fd, uio, args)
+}
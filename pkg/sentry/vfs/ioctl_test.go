@@ -0,0 +1,81 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vfs
+
+import (
+	"testing"
+
+	"gvisor.dev/gvisor/pkg/context"
+	"gvisor.dev/gvisor/pkg/sentry/arch"
+	"gvisor.dev/gvisor/pkg/usermem"
+)
+
+func ioctlHandlerReturning(ret uintptr) IoctlHandler {
+	return func(context.Context, *FileDescription, usermem.IO, arch.SyscallArguments) (uintptr, error) {
+		return ret, nil
+	}
+}
+
+func TestFindIoctlHandler(t *testing.T) {
+	const (
+		major   = 1000
+		request = 0x5401
+	)
+	RegisterIoctl(CharDevice, major, 1, request, ioctlHandlerReturning(1))
+	RegisterIoctl(CharDevice, major, AnyMinor, request, ioctlHandlerReturning(2))
+
+	for _, tc := range []struct {
+		name    string
+		kind    DeviceKind
+		minor   uint32
+		request uint32
+		want    uintptr
+	}{
+		{name: "exact", kind: CharDevice, minor: 1, request: request, want: 1},
+		{name: "any-minor", kind: CharDevice, minor: 2, request: request, want: 2},
+		{name: "other-request", kind: CharDevice, minor: 1, request: request + 1},
+		{name: "other-kind", kind: BlockDevice, minor: 1, request: request},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			h := findIoctlHandler(tc.kind, major, tc.minor, tc.request)
+			if tc.want == 0 {
+				if h != nil {
+					t.Errorf("findIoctlHandler() returned a handler, want nil")
+				}
+				return
+			}
+			if h == nil {
+				t.Fatalf("findIoctlHandler() returned nil, want handler")
+			}
+			if got, _ := h(nil, nil, nil, arch.SyscallArguments{}); got != tc.want {
+				t.Errorf("handler returned %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRegisterIoctlDuplicate(t *testing.T) {
+	const (
+		major   = 1001
+		request = 0x5401
+	)
+	RegisterIoctl(CharDevice, major, 0, request, ioctlHandlerReturning(1))
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("RegisterIoctl() didn't panic for a duplicate registration")
+		}
+	}()
+	RegisterIoctl(CharDevice, major, 0, request, ioctlHandlerReturning(1))
+}
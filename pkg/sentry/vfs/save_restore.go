@@ -44,6 +44,11 @@ type FilesystemImplSaveRestoreExtension interface {
 	// CompleteRestore completes restoration from checkpoint for this
 	// filesystem after deserialization.
 	CompleteRestore(ctx context.Context, opts CompleteRestoreOptions) error
+
+	// ResumeAfterSave undoes PrepareSave when the filesystem keeps being used
+	// after serialization, e.g. for snapshots. It's called even if
+	// PrepareSave wasn't, or failed.
+	ResumeAfterSave(ctx context.Context)
 }
 
 // PrepareSave prepares all filesystems for serialization.
@@ -60,6 +65,17 @@ func (vfs *VirtualFilesystem) PrepareSave(ctx context.Context) error {
 	return nil
 }
 
+// ResumeAfterSave resumes all filesystems after serialization, when they keep
+// being used.
+func (vfs *VirtualFilesystem) ResumeAfterSave(ctx context.Context) {
+	for fs := range vfs.getFilesystems() {
+		if ext, ok := fs.impl.(FilesystemImplSaveRestoreExtension); ok {
+			ext.ResumeAfterSave(ctx)
+		}
+		fs.DecRef(ctx)
+	}
+}
+
 // CompleteRestore completes restoration from checkpoint for all filesystems
 // after deserialization.
 func (vfs *VirtualFilesystem) CompleteRestore(ctx context.Context, opts *CompleteRestoreOptions) error {
@@ -196,7 +196,13 @@ func (w *Watchdog) Start() {
 	if w.running {
 		return
 	}
+	w.startLocked()
+}
 
+// startLocked starts the watchdog loop unless the task timeout is disabled.
+//
+// Preconditions: w.mu must be locked and the watchdog must not be running.
+func (w *Watchdog) startLocked() {
 	if w.TaskTimeout == 0 {
 		log.Infof("Watchdog task timeout disabled")
 		return
@@ -210,15 +216,18 @@ func (w *Watchdog) Start() {
 
 // Stop requests the watchdog to stop and wait for it.
 func (w *Watchdog) Stop() {
-	if w.TaskTimeout == 0 {
-		return
-	}
-
 	w.mu.Lock()
 	defer w.mu.Unlock()
 	if !w.running {
 		return
 	}
+	w.stopLocked()
+}
+
+// stopLocked stops the watchdog loop and waits for it.
+//
+// Preconditions: w.mu must be locked and the watchdog must be running.
+func (w *Watchdog) stopLocked() {
 	log.Infof("Stopping watchdog")
 	w.stop <- struct{}{}
 	<-w.done
@@ -226,6 +235,34 @@ func (w *Watchdog) Stop() {
 	log.Infof("Watchdog stopped")
 }
 
+// CurrentTaskTimeout returns the task timeout in effect. It may differ from
+// the one the watchdog was created with if SetTaskTimeout was called.
+func (w *Watchdog) CurrentTaskTimeout() time.Duration {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.TaskTimeout
+}
+
+// SetTaskTimeout changes the task timeout of a live watchdog. A timeout of 0
+// disables task monitoring, and setting a non-zero timeout after that enables
+// it again, provided that Start was called.
+func (w *Watchdog) SetTaskTimeout(timeout time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	restart := w.running || (w.startCalled && w.TaskTimeout == 0)
+	if w.running {
+		w.stopLocked()
+	}
+	w.TaskTimeout = timeout
+	w.period = timeout / 4
+	// Forget about offenders found with the previous timeout.
+	w.offenders = make(map[*kernel.Task]*offender)
+	if restart {
+		w.startLocked()
+	}
+}
+
 // waitForStart waits for Start to be called and takes action if it does not
 // happen within the startup timeout.
 func (w *Watchdog) waitForStart() {
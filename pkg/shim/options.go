@@ -47,4 +47,9 @@ type options struct {
 
 	// RunscConfig is a key/value map of all runsc flags.
 	RunscConfig map[string]string `toml:"runsc_config" json:"runscConfig"`
+
+	// Native makes the shim query, signal, pause and resume containers, and
+	// get their statistics, by talking to their sandboxes directly instead
+	// of running the runsc binary.
+	Native bool `toml:"native" json:"native"`
 }
@@ -134,7 +134,16 @@ func (e *execProcess) resize(ws console.WinSize) error {
 	if e.console == nil {
 		return nil
 	}
-	return e.console.Resize(ws)
+	if err := e.console.Resize(ws); err != nil {
+		return err
+	}
+	if e.internalPid == 0 {
+		// Not started yet, the process gets the new size when it starts.
+		return nil
+	}
+	// Resizing the console doesn't notify the sandbox, which needs to send
+	// SIGWINCH to the process itself.
+	return e.parent.runtime.ResizeTTY(context.Background(), e.parent.id, e.internalPid, ws.Height, ws.Width)
 }
 
 func (e *execProcess) Kill(ctx context.Context, sig uint32, _ bool) error {
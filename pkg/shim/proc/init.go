@@ -316,17 +316,19 @@ func (p *Init) Resize(ws console.WinSize) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	if p.console == nil {
-		return nil
-	}
-	return p.console.Resize(ws)
+	return p.resize(ws)
 }
 
 func (p *Init) resize(ws console.WinSize) error {
 	if p.console == nil {
 		return nil
 	}
-	return p.console.Resize(ws)
+	if err := p.console.Resize(ws); err != nil {
+		return err
+	}
+	// Resizing the console doesn't notify the sandbox, which needs to send
+	// SIGWINCH to the process itself.
+	return p.runtime.ResizeTTY(context.Background(), p.id, 0, ws.Height, ws.Width)
 }
 
 // Kill kills the init process.
@@ -421,14 +423,14 @@ func (p *Init) exec(path string, r *ExecConfig) (process.Process, error) {
 	return e, nil
 }
 
-func (p *Init) Stats(ctx context.Context, id string) (*runc.Stats, error) {
+func (p *Init) Stats(ctx context.Context, id string) (*runsc.Stats, error) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
 	return p.initState.Stats(ctx, id)
 }
 
-func (p *Init) stats(ctx context.Context, id string) (*runc.Stats, error) {
+func (p *Init) stats(ctx context.Context, id string) (*runsc.Stats, error) {
 	return p.Runtime().Stats(ctx, id)
 }
 
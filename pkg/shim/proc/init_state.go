@@ -21,8 +21,8 @@ import (
 
 	"github.com/containerd/containerd/errdefs"
 	"github.com/containerd/containerd/pkg/process"
-	runc "github.com/containerd/go-runc"
 	"golang.org/x/sys/unix"
+	"gvisor.dev/gvisor/pkg/shim/runsc"
 	"gvisor.dev/gvisor/pkg/shim/utils"
 )
 
@@ -52,7 +52,7 @@ type initState interface {
 	Delete(context.Context) error
 	Exec(context.Context, string, *ExecConfig) (process.Process, error)
 	State(ctx context.Context) (string, error)
-	Stats(context.Context, string) (*runc.Stats, error)
+	Stats(context.Context, string) (*runsc.Stats, error)
 	Kill(context.Context, uint32, bool) error
 	SetExited(int)
 }
@@ -126,7 +126,7 @@ func (s *createdState) State(ctx context.Context) (string, error) {
 	return state, err
 }
 
-func (s *createdState) Stats(ctx context.Context, id string) (*runc.Stats, error) {
+func (s *createdState) Stats(ctx context.Context, id string) (*runsc.Stats, error) {
 	return s.p.stats(ctx, id)
 }
 
@@ -176,7 +176,7 @@ func (s *runningState) State(ctx context.Context) (string, error) {
 	return state, err
 }
 
-func (s *runningState) Stats(ctx context.Context, id string) (*runc.Stats, error) {
+func (s *runningState) Stats(ctx context.Context, id string) (*runsc.Stats, error) {
 	return s.p.stats(ctx, id)
 }
 
@@ -225,7 +225,7 @@ func (s *stoppedState) State(context.Context) (string, error) {
 	return "stopped", nil
 }
 
-func (s *stoppedState) Stats(context.Context, string) (*runc.Stats, error) {
+func (s *stoppedState) Stats(context.Context, string) (*runsc.Stats, error) {
 	return nil, fmt.Errorf("cannot stat a stopped container")
 }
 
@@ -0,0 +1,154 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runsc
+
+import (
+	"encoding/json"
+	"fmt"
+
+	runc "github.com/containerd/go-runc"
+	"golang.org/x/sys/unix"
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/runsc/container"
+)
+
+// The operations below are used instead of the runsc CLI when Runsc.Native is
+// set. They only talk to running sandboxes and read the container metadata in
+// the root directory, so they don't depend on the configuration of runsc.
+// Creating and starting containers still runs the runsc binary, which starts
+// the sandbox and gofer processes by executing itself.
+
+// rootDir returns the root directory used by runsc. Like the other flags,
+// --root can be overridden in Config, which is passed after it.
+func (r *Runsc) rootDir() string {
+	if root, ok := r.Config["root"]; ok {
+		return root
+	}
+	return r.Root
+}
+
+// load loads the container with the given ID from the root directory.
+func (r *Runsc) load(id string) (*container.Container, error) {
+	root := r.rootDir()
+	c, err := container.Load(root, container.FullID{ContainerID: id}, container.LoadOpts{})
+	if err != nil {
+		return nil, fmt.Errorf("loading container %q from %q: %w", id, root, err)
+	}
+	return c, nil
+}
+
+func (r *Runsc) nativeState(id string) (*runc.Container, error) {
+	c, err := r.load(id)
+	if err != nil {
+		return nil, err
+	}
+	state := c.State()
+	return &runc.Container{
+		ID:          state.ID,
+		Pid:         state.Pid,
+		Status:      state.Status,
+		Bundle:      state.Bundle,
+		Created:     c.CreatedAt,
+		Annotations: state.Annotations,
+	}, nil
+}
+
+func (r *Runsc) nativePause(id string) error {
+	c, err := r.load(id)
+	if err != nil {
+		return err
+	}
+	if err := c.Pause(); err != nil {
+		return fmt.Errorf("unable to pause: %w", err)
+	}
+	return nil
+}
+
+func (r *Runsc) nativeResume(id string) error {
+	c, err := r.load(id)
+	if err != nil {
+		return err
+	}
+	if err := c.Resume(); err != nil {
+		return fmt.Errorf("unable to resume: %w", err)
+	}
+	return nil
+}
+
+func (r *Runsc) nativeKill(id string, sig int, opts *KillOpts) error {
+	if opts == nil {
+		opts = &KillOpts{}
+	}
+	if opts.Pid != 0 && opts.All {
+		return fmt.Errorf("it is invalid to specify both All and Pid")
+	}
+	c, err := r.load(id)
+	if err != nil {
+		return err
+	}
+	if opts.Pid != 0 {
+		return c.SignalProcess(unix.Signal(sig), int32(opts.Pid))
+	}
+	return c.SignalContainer(unix.Signal(sig), opts.All)
+}
+
+func (r *Runsc) nativeResizeTTY(id string, pid int, rows, cols uint16) error {
+	c, err := r.load(id)
+	if err != nil {
+		return err
+	}
+	if err := c.ResizeTTY(int32(pid), &linux.Winsize{Row: rows, Col: cols}); err != nil {
+		return fmt.Errorf("unable to resize: %w", err)
+	}
+	return nil
+}
+
+func (r *Runsc) nativeStats(id string) (*Stats, error) {
+	c, err := r.load(id)
+	if err != nil {
+		return nil, err
+	}
+	ev, err := c.Event()
+	if err != nil {
+		return nil, err
+	}
+	// Convert the statistics like the shim does for the output of
+	// "runsc events --stats".
+	data, err := json.Marshal(ev.Event.Data)
+	if err != nil {
+		return nil, err
+	}
+	var stats Stats
+	if err := json.Unmarshal(data, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+func (r *Runsc) nativePs(id string) ([]int, error) {
+	c, err := r.load(id)
+	if err != nil {
+		return nil, err
+	}
+	procs, err := c.Processes()
+	if err != nil {
+		return nil, err
+	}
+	pids := make([]int, 0, len(procs))
+	for _, p := range procs {
+		pids = append(pids, int(p.PID))
+	}
+	return pids, nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runsc
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestRootDir(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		runsc Runsc
+		want  string
+	}{
+		{
+			name:  "default",
+			runsc: Runsc{Root: "/run/containerd/runsc/k8s.io"},
+			want:  "/run/containerd/runsc/k8s.io",
+		},
+		{
+			name: "override",
+			runsc: Runsc{
+				Root:   "/run/containerd/runsc/k8s.io",
+				Config: map[string]string{"root": "/var/run/runsc", "debug": "true"},
+			},
+			want: "/var/run/runsc",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.runsc.rootDir(); got != tc.want {
+				t.Errorf("rootDir() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+// TestNativeRoot checks that native operations look for containers in the
+// root directory that runsc would use.
+func TestNativeRoot(t *testing.T) {
+	dir := t.TempDir()
+	r := Runsc{
+		Root:   "/nonexistent",
+		Config: map[string]string{"root": dir},
+		Native: true,
+	}
+	if _, err := r.nativeState("missing"); err == nil || !strings.Contains(err.Error(), dir) {
+		t.Errorf("nativeState() = %v, want error loading from %q", err, dir)
+	}
+}
+
+func TestNativeKillInvalid(t *testing.T) {
+	r := Runsc{Root: t.TempDir(), Native: true}
+	err := r.nativeKill("missing", int(unix.SIGKILL), &KillOpts{All: true, Pid: 1})
+	if err == nil || !strings.Contains(err.Error(), "All and Pid") {
+		t.Errorf("nativeKill() = %v, want invalid options error", err)
+	}
+}
+
+func TestKillArgs(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opts KillOpts
+		want string
+	}{
+		{
+			name: "none",
+		},
+		{
+			name: "all",
+			opts: KillOpts{All: true},
+			want: "--all",
+		},
+		{
+			// Pid is in the root PID namespace, like kill --pid.
+			name: "pid",
+			opts: KillOpts{Pid: 10},
+			want: "--pid 10",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := strings.Join(tc.opts.args(), " "); got != tc.want {
+				t.Errorf("args() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
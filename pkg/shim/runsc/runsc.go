@@ -70,6 +70,10 @@ type Runsc struct {
 	Log          string
 	LogFormat    runc.Format
 	Config       map[string]string
+
+	// Native calls the runsc/container package directly instead of running
+	// the runsc binary to query and signal containers.
+	Native bool
 }
 
 // List returns all containers created inside the provided runsc root directory.
@@ -87,6 +91,9 @@ func (r *Runsc) List(context context.Context) ([]*runc.Container, error) {
 
 // State returns the state for the container provided by id.
 func (r *Runsc) State(context context.Context, id string) (*runc.Container, error) {
+	if r.Native {
+		return r.nativeState(id)
+	}
 	data, stderr, err := cmdOutput(r.command(context, "state", id), false)
 	if err != nil {
 		return nil, fmt.Errorf("%w: %s", err, stderr)
@@ -169,6 +176,9 @@ func (r *Runsc) Create(context context.Context, id, bundle string, opts *CreateO
 }
 
 func (r *Runsc) Pause(context context.Context, id string) error {
+	if r.Native {
+		return r.nativePause(id)
+	}
 	if out, _, err := cmdOutput(r.command(context, "pause", id), true); err != nil {
 		return fmt.Errorf("unable to pause: %w: %s", err, out)
 	}
@@ -176,6 +186,9 @@ func (r *Runsc) Pause(context context.Context, id string) error {
 }
 
 func (r *Runsc) Resume(context context.Context, id string) error {
+	if r.Native {
+		return r.nativeResume(id)
+	}
 	if out, _, err := cmdOutput(r.command(context, "resume", id), true); err != nil {
 		return fmt.Errorf("unable to resume: %w: %s", err, out)
 	}
@@ -363,6 +376,7 @@ func (r *Runsc) Delete(context context.Context, id string, opts *DeleteOpts) err
 // KillOpts specifies options for killing a container and its processes.
 type KillOpts struct {
 	All bool
+	// Pid is relative to the sandbox root PID namespace.
 	Pid int
 }
 
@@ -378,6 +392,9 @@ func (o *KillOpts) args() (out []string) {
 
 // Kill sends the specified signal to the container.
 func (r *Runsc) Kill(context context.Context, id string, sig int, opts *KillOpts) error {
+	if r.Native {
+		return r.nativeKill(id, sig, opts)
+	}
 	args := []string{
 		"kill",
 	}
@@ -387,14 +404,38 @@ func (r *Runsc) Kill(context context.Context, id string, sig int, opts *KillOpts
 	return r.runOrError(r.command(context, append(args, id, strconv.Itoa(sig))...))
 }
 
+// ResizeTTY changes the window size of the TTY of the given process, relative
+// to the sandbox root PID namespace, or of the init process of the container if
+// pid is 0. The process gets SIGWINCH if the size changed.
+func (r *Runsc) ResizeTTY(context context.Context, id string, pid int, rows, cols uint16) error {
+	if r.Native {
+		return r.nativeResizeTTY(id, pid, rows, cols)
+	}
+	args := []string{
+		"resize",
+		"--rows", strconv.Itoa(int(rows)),
+		"--cols", strconv.Itoa(int(cols)),
+	}
+	if pid != 0 {
+		args = append(args, "--pid", strconv.Itoa(pid))
+	}
+	if out, _, err := cmdOutput(r.command(context, append(args, id)...), true); err != nil {
+		return fmt.Errorf("unable to resize: %w: %s", err, out)
+	}
+	return nil
+}
+
 // Stats return the stats for a container like cpu, memory, and I/O.
-func (r *Runsc) Stats(context context.Context, id string) (*runc.Stats, error) {
+func (r *Runsc) Stats(context context.Context, id string) (*Stats, error) {
+	if r.Native {
+		return r.nativeStats(id)
+	}
 	cmd := r.command(context, "events", "--stats", id)
 	data, stderr, err := cmdOutput(cmd, false)
 	if err != nil {
 		return nil, fmt.Errorf("%w: %s", err, stderr)
 	}
-	var e runc.Event
+	var e event
 	if err := json.Unmarshal(data, &e); err != nil {
 		log.L.Debugf("Parsing events error: %v", err)
 		return nil, err
@@ -409,6 +450,36 @@ func (r *Runsc) Stats(context context.Context, id string) (*runc.Stats, error) {
 	return e.Stats, nil
 }
 
+// Stats is the statistics of a container returned by "runsc events --stats".
+// It adds the statistics of the network interfaces of the sandbox, which runc
+// doesn't report, to the ones of runc.
+type Stats struct {
+	runc.Stats
+	NetworkInterfaces []*NetworkInterface `json:"network_interfaces,omitempty"`
+}
+
+// NetworkInterface contains the statistics of a network interface of the
+// sandbox.
+type NetworkInterface struct {
+	Name      string
+	RxBytes   uint64
+	RxPackets uint64
+	RxErrors  uint64
+	RxDropped uint64
+	TxBytes   uint64
+	TxPackets uint64
+	TxErrors  uint64
+	TxDropped uint64
+}
+
+// event is a stats event of "runsc events", like runc.Event with Stats
+// instead of runc.Stats.
+type event struct {
+	Type  string `json:"type"`
+	ID    string `json:"id"`
+	Stats *Stats `json:"data,omitempty"`
+}
+
 // Events returns an event stream from runsc for a container with stats and OOM notifications.
 func (r *Runsc) Events(context context.Context, id string, interval time.Duration) (chan *runc.Event, error) {
 	cmd := r.command(context, "events", fmt.Sprintf("--interval=%ds", int(interval.Seconds())), id)
@@ -450,6 +521,9 @@ func (r *Runsc) Events(context context.Context, id string, interval time.Duratio
 
 // Ps lists all the processes inside the container returning their pids.
 func (r *Runsc) Ps(context context.Context, id string) ([]int, error) {
+	if r.Native {
+		return r.nativePs(id)
+	}
 	data, stderr, err := cmdOutput(r.command(context, "ps", "--format", "json", id), false)
 	if err != nil {
 		return nil, fmt.Errorf("%w: %s", err, stderr)
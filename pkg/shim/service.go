@@ -756,13 +756,11 @@ func (s *service) Stats(ctx context.Context, r *taskAPI.StatsRequest) (*taskAPI.
 		return nil, err
 	}
 
-	// gvisor currently (as of 2020-03-03) only returns the total memory
-	// usage and current PID value[0]. However, we copy the common fields here
-	// so that future updates will propagate correct information.  We're
-	// using the cgroups.Metrics structure so we're returning the same type
-	// as runc.
-	//
-	// [0]: https://github.com/google/gvisor/blob/277a0d5a1fbe8272d4729c01ee4c6e374d047ebc/runsc/boot/events.go#L61-L81
+	// gvisor returns the memory usage and limits, CPU usage, process count
+	// and network statistics of the container. Copy the common fields of
+	// runc's stats so that future updates propagate correct information.
+	// We're using the cgroups.Metrics structure so we're returning the same
+	// type as runc.
 	metrics := &cgroupsstats.Metrics{
 		CPU: &cgroupsstats.CPUStat{
 			Usage: &cgroupsstats.CPUUsage{
@@ -778,7 +776,9 @@ func (s *service) Stats(ctx context.Context, r *taskAPI.StatsRequest) (*taskAPI.
 			},
 		},
 		Memory: &cgroupsstats.MemoryStat{
-			Cache: stats.Memory.Cache,
+			Cache:      stats.Memory.Cache,
+			RSS:        stats.Memory.Raw["rss"],
+			MappedFile: stats.Memory.Raw["mapped_file"],
 			Usage: &cgroupsstats.MemoryEntry{
 				Limit:   stats.Memory.Usage.Limit,
 				Usage:   stats.Memory.Usage.Usage,
@@ -809,6 +809,19 @@ func (s *service) Stats(ctx context.Context, r *taskAPI.StatsRequest) (*taskAPI.
 			Limit:   stats.Pids.Limit,
 		},
 	}
+	for _, i := range stats.NetworkInterfaces {
+		metrics.Network = append(metrics.Network, &cgroupsstats.NetworkStat{
+			Name:      i.Name,
+			RxBytes:   i.RxBytes,
+			RxPackets: i.RxPackets,
+			RxErrors:  i.RxErrors,
+			RxDropped: i.RxDropped,
+			TxBytes:   i.TxBytes,
+			TxPackets: i.TxPackets,
+			TxErrors:  i.TxErrors,
+			TxDropped: i.TxDropped,
+		})
+	}
 	data, err := typeurl.MarshalAny(metrics)
 	if err != nil {
 		log.L.Debugf("Stats error, id: %s: %v", r.ID, err)
@@ -973,6 +986,7 @@ func newInit(path, workDir, namespace string, platform stdio.Platform, r *proc.C
 
 	runsc.FormatRunscLogPath(r.ID, options.RunscConfig)
 	runtime := proc.NewRunsc(options.Root, path, namespace, options.BinaryName, options.RunscConfig)
+	runtime.Native = options.Native
 	p := proc.New(r.ID, runtime, stdio.Stdio{
 		Stdin:    r.Stdin,
 		Stdout:   r.Stdout,
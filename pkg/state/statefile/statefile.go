@@ -37,8 +37,11 @@
 //
 // This map includes only strings for keys and strings for values. Keys in the
 // map that begin with "_" are for internal use only. They may be read, but may
-// not be provided by the user. In the future, this metadata may contain some
-// information relating to the state encoding itself.
+// not be provided by the user. The "_version" key holds the version of the
+// file format, see CurrentVersion. Files without it are version 1. The
+// "_compression" key holds the compression algorithm of the state data, see
+// Compression. Files without it use flate. The "_dedup" key is set when
+// identical memory pages were saved once, see Deduplicated.
 //
 // After the map, the remainder of the file is the state data.
 package statefile
@@ -53,6 +56,7 @@ import (
 	"fmt"
 	"hash"
 	"io"
+	"strconv"
 	"strings"
 	"time"
 
@@ -69,6 +73,74 @@ const compressionChunkSize = 1024 * 1024
 // maxMetadataSize is the size limit of metadata section.
 const maxMetadataSize = 16 * 1024 * 1024
 
+// Versions of the file format.
+const (
+	// Version1 is the original format, without a version in the metadata.
+	Version1 = 1
+
+	// Version2 records the format version in the metadata.
+	Version2 = 2
+
+	// Version3 records the compression algorithm in the metadata.
+	Version3 = 3
+
+	// CurrentVersion is the version of the files written by NewWriter.
+	CurrentVersion = Version3
+)
+
+// versionKey is the metadata key holding the format version.
+const versionKey = "_version"
+
+// compressionKey is the metadata key holding the compression algorithm.
+const compressionKey = "_compression"
+
+// dedupKey is the metadata key set when memory pages were deduplicated.
+const dedupKey = "_dedup"
+
+// Compression is a compression algorithm of the state data.
+type Compression string
+
+const (
+	// CompressionFlate compresses with DEFLATE. It's the only algorithm
+	// supported by files older than Version3.
+	CompressionFlate Compression = "flate"
+
+	// CompressionZstd compresses with Zstandard, which is much faster for
+	// similar file sizes.
+	CompressionZstd Compression = "zstd"
+)
+
+// algorithm returns the compressio algorithm implementing c.
+func (c Compression) algorithm() (compressio.Algorithm, int, error) {
+	switch c {
+	case "", CompressionFlate:
+		// We always use "best speed" mode here. When using "best
+		// compression" mode, there is usually only a little gain in file
+		// size reduction, which translate to even smaller gain in restore
+		// latency reduction, while inccuring much more CPU usage at save
+		// time.
+		return compressio.Flate, flate.BestSpeed, nil
+	case CompressionZstd:
+		return compressio.Zstd, 1, nil
+	default:
+		return 0, 0, fmt.Errorf("unsupported compression %q", c)
+	}
+}
+
+// WriterOpts are options of NewWriterOpts.
+type WriterOpts struct {
+	// Version is the version of the file format, e.g. CurrentVersion.
+	Version int
+
+	// Compression is the compression algorithm. It defaults to
+	// CompressionFlate.
+	Compression Compression
+
+	// Dedup records that identical memory pages were saved once, which
+	// changes how they must be loaded.
+	Dedup bool
+}
+
 // magicHeader is the byte sequence beginning each file.
 var magicHeader = []byte("\x67\x56\x69\x73\x6f\x72\x53\x46")
 
@@ -84,6 +156,9 @@ var ErrInvalidMetadataLength = fmt.Errorf("metadata length invalid, maximum size
 // ErrMetadataInvalid is returned if passed metadata is invalid.
 var ErrMetadataInvalid = fmt.Errorf("metadata invalid, can't start with _")
 
+// ErrUnsupportedVersion is returned if the file format version is unknown.
+var ErrUnsupportedVersion = fmt.Errorf("unsupported file format version, maximum version is %d", CurrentVersion)
+
 // WriteCloser is an io.Closer and wire.Writer.
 type WriteCloser interface {
 	wire.Writer
@@ -97,10 +172,37 @@ func writeMetadataLen(w io.Writer, val uint64) error {
 	return err
 }
 
-// NewWriter returns a state data writer for a statefile.
+// NewWriter returns a state data writer for a statefile, using CurrentVersion
+// of the file format.
 //
 // Note that the returned WriteCloser must be closed.
 func NewWriter(w io.Writer, key []byte, metadata map[string]string) (WriteCloser, error) {
+	return NewWriterOpts(w, key, metadata, WriterOpts{Version: CurrentVersion})
+}
+
+// NewWriterVersion is like NewWriter, but writes the given version of the file
+// format. It's used to convert files for older readers.
+//
+// Note that the returned WriteCloser must be closed.
+func NewWriterVersion(w io.Writer, key []byte, metadata map[string]string, version int) (WriteCloser, error) {
+	return NewWriterOpts(w, key, metadata, WriterOpts{Version: version})
+}
+
+// NewWriterOpts is like NewWriter, with the given options.
+//
+// Note that the returned WriteCloser must be closed.
+func NewWriterOpts(w io.Writer, key []byte, metadata map[string]string, opts WriterOpts) (WriteCloser, error) {
+	version := opts.Version
+	if version < Version1 || version > CurrentVersion {
+		return nil, ErrUnsupportedVersion
+	}
+	algo, level, err := opts.Compression.algorithm()
+	if err != nil {
+		return nil, err
+	}
+	if algo != compressio.Flate && version < Version3 {
+		return nil, fmt.Errorf("compression %q requires format version %d", opts.Compression, Version3)
+	}
 	if metadata == nil {
 		metadata = make(map[string]string)
 	}
@@ -122,6 +224,18 @@ func NewWriter(w io.Writer, key []byte, metadata map[string]string) (WriteCloser
 	// Generate a timestamp, for convenience only.
 	metadata["_timestamp"] = time.Now().UTC().String()
 	defer delete(metadata, "_timestamp")
+	if version > Version1 {
+		metadata[versionKey] = strconv.Itoa(version)
+		defer delete(metadata, versionKey)
+	}
+	if version >= Version3 {
+		metadata[compressionKey] = algo.String()
+		defer delete(metadata, compressionKey)
+	}
+	if opts.Dedup {
+		metadata[dedupKey] = "true"
+		defer delete(metadata, dedupKey)
+	}
 
 	// Write the metadata.
 	b, err := json.Marshal(metadata)
@@ -152,11 +266,8 @@ func NewWriter(w io.Writer, key []byte, metadata map[string]string) (WriteCloser
 		}
 	}
 
-	// Wrap in compression. We always use "best speed" mode here. When using
-	// "best compression" mode, there is usually only a little gain in file
-	// size reduction, which translate to even smaller gain in restore
-	// latency reduction, while inccuring much more CPU usage at save time.
-	return compressio.NewWriter(w, key, compressionChunkSize, flate.BestSpeed)
+	// Wrap in compression.
+	return compressio.NewWriterAlgorithm(w, key, compressionChunkSize, algo, level)
 }
 
 // MetadataUnsafe reads out the metadata from a state file without verifying any
@@ -236,7 +347,43 @@ func metadata(r io.Reader, h hash.Hash) (map[string]string, error) {
 	return metadata, nil
 }
 
+// Version returns the file format version recorded in metadata, as returned
+// by MetadataUnsafe or NewReader.
+func Version(metadata map[string]string) (int, error) {
+	v, ok := metadata[versionKey]
+	if !ok {
+		return Version1, nil
+	}
+	version, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid version %q: %v", v, err)
+	}
+	if version < Version1 || version > CurrentVersion {
+		return 0, ErrUnsupportedVersion
+	}
+	return version, nil
+}
+
+// CompressionOf returns the compression algorithm recorded in metadata, as
+// returned by MetadataUnsafe or NewReader.
+func CompressionOf(metadata map[string]string) Compression {
+	if c, ok := metadata[compressionKey]; ok {
+		return Compression(c)
+	}
+	return CompressionFlate
+}
+
+// Deduplicated returns whether metadata, as returned by MetadataUnsafe or
+// NewReader, records that identical memory pages were saved once.
+func Deduplicated(metadata map[string]string) bool {
+	_, ok := metadata[dedupKey]
+	return ok
+}
+
 // NewReader returns a reader for a statefile.
+//
+// Files of all versions up to CurrentVersion can be read. The returned
+// metadata includes internal keys, see Version.
 func NewReader(r io.Reader, key []byte) (wire.Reader, map[string]string, error) {
 	// Read the metadata with the hash.
 	h := hmac.New(sha256.New, key)
@@ -244,9 +391,16 @@ func NewReader(r io.Reader, key []byte) (wire.Reader, map[string]string, error)
 	if err != nil {
 		return nil, nil, err
 	}
+	if _, err := Version(metadata); err != nil {
+		return nil, nil, err
+	}
+	algo, _, err := Compression(metadata[compressionKey]).algorithm()
+	if err != nil {
+		return nil, nil, err
+	}
 
 	// Wrap in compression.
-	cr, err := compressio.NewReader(r, key)
+	cr, err := compressio.NewReaderAlgorithm(r, key, algo)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -19,8 +19,10 @@ import (
 	crand "crypto/rand"
 	"encoding/base64"
 	"io"
+	"io/ioutil"
 	"math/rand"
 	"runtime"
+	"strconv"
 	"testing"
 	"time"
 
@@ -163,6 +165,109 @@ func TestStatefile(t *testing.T) {
 	}
 }
 
+func TestVersion(t *testing.T) {
+	for _, version := range []int{Version1, Version2, Version3} {
+		t.Run(strconv.Itoa(version), func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewWriterVersion(&buf, nil, map[string]string{"foo": "bar"}, version)
+			if err != nil {
+				t.Fatalf("error creating writer: got %v, expected nil", err)
+			}
+			if _, err := w.Write([]byte("data")); err != nil {
+				t.Fatalf("error during write: got %v, expected nil", err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("error during close: got %v, expected nil", err)
+			}
+
+			_, metadata, err := NewReader(bytes.NewReader(buf.Bytes()), nil)
+			if err != nil {
+				t.Fatalf("error creating reader: got %v, expected nil", err)
+			}
+			got, err := Version(metadata)
+			if err != nil {
+				t.Fatalf("error getting version: got %v, expected nil", err)
+			}
+			if got != version {
+				t.Errorf("got version %d, expected %d", got, version)
+			}
+		})
+	}
+}
+
+func TestCompression(t *testing.T) {
+	for _, c := range []Compression{CompressionFlate, CompressionZstd} {
+		t.Run(string(c), func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewWriterOpts(&buf, nil, nil, WriterOpts{Version: CurrentVersion, Compression: c})
+			if err != nil {
+				t.Fatalf("error creating writer: got %v, expected nil", err)
+			}
+			data := bytes.Repeat([]byte("data"), 1024)
+			if _, err := w.Write(data); err != nil {
+				t.Fatalf("error during write: got %v, expected nil", err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("error during close: got %v, expected nil", err)
+			}
+
+			r, metadata, err := NewReader(bytes.NewReader(buf.Bytes()), nil)
+			if err != nil {
+				t.Fatalf("error creating reader: got %v, expected nil", err)
+			}
+			if got := CompressionOf(metadata); got != c {
+				t.Errorf("got compression %q, expected %q", got, c)
+			}
+			got, err := ioutil.ReadAll(r)
+			if err != nil {
+				t.Fatalf("error during read: got %v, expected nil", err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Errorf("read data doesn't match written data")
+			}
+		})
+	}
+
+	// Older versions only support flate.
+	if _, err := NewWriterOpts(&bytes.Buffer{}, nil, nil, WriterOpts{Version: Version2, Compression: CompressionZstd}); err == nil {
+		t.Errorf("got no error for zstd with version %d: expected error", Version2)
+	}
+}
+
+func TestDedup(t *testing.T) {
+	for _, dedup := range []bool{false, true} {
+		t.Run(strconv.FormatBool(dedup), func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewWriterOpts(&buf, nil, nil, WriterOpts{Version: CurrentVersion, Dedup: dedup})
+			if err != nil {
+				t.Fatalf("error creating writer: got %v, expected nil", err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("error during close: got %v, expected nil", err)
+			}
+
+			_, metadata, err := NewReader(bytes.NewReader(buf.Bytes()), nil)
+			if err != nil {
+				t.Fatalf("error creating reader: got %v, expected nil", err)
+			}
+			if got := Deduplicated(metadata); got != dedup {
+				t.Errorf("got deduplicated %t, expected %t", got, dedup)
+			}
+		})
+	}
+}
+
+func TestVersionUnsupported(t *testing.T) {
+	if _, err := NewWriterVersion(&bytes.Buffer{}, nil, nil, CurrentVersion+1); err != ErrUnsupportedVersion {
+		t.Errorf("got error: %v, expected ErrUnsupportedVersion", err)
+	}
+	for _, v := range []string{"0", "foo", strconv.Itoa(CurrentVersion + 1)} {
+		if _, err := Version(map[string]string{versionKey: v}); err == nil {
+			t.Errorf("got no error for version %q: expected error", v)
+		}
+	}
+}
+
 const benchmarkDataSize = 100 * 1024 * 1024
 
 func benchmark(b *testing.B, size int, write bool, compressible bool) {
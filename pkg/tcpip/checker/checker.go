@@ -311,6 +311,31 @@ func ReceiveTOS(want uint8) ControlMessagesChecker {
 	}
 }
 
+// ReceiveTTL creates a checker that checks the TTL field in ControlMessages.
+func ReceiveTTL(want uint8) ControlMessagesChecker {
+	return func(t *testing.T, cm tcpip.ControlMessages) {
+		t.Helper()
+		if !cm.HasTTL {
+			t.Errorf("got cm.HasTTL = %t, want = true", cm.HasTTL)
+		} else if got := cm.TTL; got != want {
+			t.Errorf("got cm.TTL = %d, want %d", got, want)
+		}
+	}
+}
+
+// ReceiveHopLimit creates a checker that checks the HopLimit field in
+// ControlMessages.
+func ReceiveHopLimit(want uint8) ControlMessagesChecker {
+	return func(t *testing.T, cm tcpip.ControlMessages) {
+		t.Helper()
+		if !cm.HasHopLimit {
+			t.Errorf("got cm.HasHopLimit = %t, want = true", cm.HasHopLimit)
+		} else if got := cm.HopLimit; got != want {
+			t.Errorf("got cm.HopLimit = %d, want %d", got, want)
+		}
+	}
+}
+
 // ReceiveIPPacketInfo creates a checker that checks the PacketInfo field in
 // ControlMessages.
 func ReceiveIPPacketInfo(want tcpip.IPPacketInfo) ControlMessagesChecker {
@@ -0,0 +1,195 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+// Package bridge provides a learning Ethernet switch that connects the shared
+// memory endpoints of several network stacks, e.g. the sandboxes of a pod.
+//
+// Each stack gets a port of the bridge with AddPort, and uses the returned
+// queues to create its endpoint with sharedmem.New. Frames are forwarded
+// between the ports directly, without involving the host network stack.
+package bridge
+
+import (
+	"fmt"
+
+	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/pkg/sync"
+	"gvisor.dev/gvisor/pkg/tcpip"
+	"gvisor.dev/gvisor/pkg/tcpip/header"
+	"gvisor.dev/gvisor/pkg/tcpip/link/sharedmem"
+	"gvisor.dev/gvisor/pkg/tcpip/stack"
+)
+
+// portLinkAddress is the link address of the bridge side of all ports. It's
+// never used as a source or destination of frames, but it must be set so that
+// the endpoints of the ports handle Ethernet headers.
+const portLinkAddress = tcpip.LinkAddress("\x02\x00\x00\x00\x00\x00")
+
+// PortOptions are the options of a bridge port.
+type PortOptions struct {
+	// MTU is the MTU of the port, which must match the MTU of the endpoint
+	// connected to it.
+	MTU uint32
+
+	// BufferSize is the size of the buffers of the shared memory queues.
+	BufferSize uint32
+
+	// PeerFD is an fd that becomes readable when the peer goes away, after
+	// which the port is removed, or -1.
+	PeerFD int
+}
+
+// Bridge forwards Ethernet frames between its ports. It learns the link
+// addresses behind each port from the source address of the frames it
+// receives, and floods frames to unknown, broadcast, and multicast addresses
+// to all ports.
+type Bridge struct {
+	mu sync.Mutex
+
+	// ports are the ports of the bridge.
+	// +checklocks:mu
+	ports map[*port]struct{}
+
+	// fdb maps link addresses to the port they were last seen on.
+	// +checklocks:mu
+	fdb map[tcpip.LinkAddress]*port
+}
+
+// port is a port of a bridge. It implements stack.NetworkDispatcher to
+// receive the frames sent by the peer.
+type port struct {
+	bridge *Bridge
+	ep     stack.LinkEndpoint
+}
+
+// New creates a bridge without ports.
+func New() *Bridge {
+	return &Bridge{
+		ports: make(map[*port]struct{}),
+		fdb:   make(map[tcpip.LinkAddress]*port),
+	}
+}
+
+// AddPort adds a port to the bridge and returns the queues that connect it to
+// its peer. The peer must create its endpoint with sharedmem.New, using the
+// TX and RX queue configurations of the returned queue pair. The caller must
+// close the queue pair once it's been passed to the peer; the port keeps its
+// own references to the queues.
+func (b *Bridge) AddPort(opts PortOptions) (*sharedmem.QueuePair, error) {
+	queues, err := sharedmem.NewQueuePair()
+	if err != nil {
+		return nil, fmt.Errorf("creating queues: %v", err)
+	}
+	p := &port{bridge: b}
+	ep, err := sharedmem.NewServerEndpoint(sharedmem.Options{
+		MTU:         opts.MTU,
+		BufferSize:  opts.BufferSize,
+		LinkAddress: portLinkAddress,
+		TX:          queues.TXQueueConfig(),
+		RX:          queues.RXQueueConfig(),
+		PeerFD:      opts.PeerFD,
+		OnClosed: func(err tcpip.Error) {
+			log.Infof("Bridge port peer went away: %v", err)
+			b.removePort(p)
+		},
+	})
+	if err != nil {
+		queues.Close()
+		return nil, fmt.Errorf("creating endpoint: %v", err)
+	}
+	p.ep = ep
+
+	b.mu.Lock()
+	b.ports[p] = struct{}{}
+	b.mu.Unlock()
+	ep.Attach(p)
+	return queues, nil
+}
+
+// NumPorts returns the number of ports of the bridge.
+func (b *Bridge) NumPorts() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.ports)
+}
+
+// Close removes all ports of the bridge.
+func (b *Bridge) Close() {
+	b.mu.Lock()
+	ports := make([]*port, 0, len(b.ports))
+	for p := range b.ports {
+		ports = append(ports, p)
+	}
+	b.mu.Unlock()
+
+	for _, p := range ports {
+		b.removePort(p)
+	}
+}
+
+// removePort removes p from the bridge, if it's still there, and releases its
+// resources.
+func (b *Bridge) removePort(p *port) {
+	b.mu.Lock()
+	if _, ok := b.ports[p]; !ok {
+		b.mu.Unlock()
+		return
+	}
+	delete(b.ports, p)
+	for addr, fp := range b.fdb {
+		if fp == p {
+			delete(b.fdb, addr)
+		}
+	}
+	b.mu.Unlock()
+
+	// The endpoint releases the queues once its dispatch goroutine stops.
+	p.ep.Close()
+}
+
+// DeliverNetworkPacket implements stack.NetworkDispatcher.DeliverNetworkPacket.
+// It forwards the frames received from the peer of p.
+func (p *port) DeliverNetworkPacket(src, dst tcpip.LinkAddress, _ tcpip.NetworkProtocolNumber, pkt *stack.PacketBuffer) {
+	b := p.bridge
+	b.mu.Lock()
+	if header.IsValidUnicastEthernetAddress(src) {
+		b.fdb[src] = p
+	}
+	var out []*port
+	if fp, ok := b.fdb[dst]; ok && header.IsValidUnicastEthernetAddress(dst) {
+		if fp != p {
+			out = append(out, fp)
+		}
+	} else {
+		for op := range b.ports {
+			if op != p {
+				out = append(out, op)
+			}
+		}
+	}
+	b.mu.Unlock()
+
+	// The frame, including its Ethernet header, is copied to the queues of
+	// each output port.
+	for _, op := range out {
+		if err := op.ep.WriteRawPacket(pkt); err != nil {
+			// The queue of the peer is full, drop the frame like a congested
+			// switch would.
+			log.Debugf("Bridge dropped frame to %s: %s", dst, err)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package bridge
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+	"gvisor.dev/gvisor/pkg/tcpip"
+	"gvisor.dev/gvisor/pkg/tcpip/adapters/gonet"
+	"gvisor.dev/gvisor/pkg/tcpip/header"
+	"gvisor.dev/gvisor/pkg/tcpip/link/sharedmem"
+	"gvisor.dev/gvisor/pkg/tcpip/network/arp"
+	"gvisor.dev/gvisor/pkg/tcpip/network/ipv4"
+	"gvisor.dev/gvisor/pkg/tcpip/stack"
+	"gvisor.dev/gvisor/pkg/tcpip/transport/tcp"
+)
+
+const (
+	testMTU        = 1500
+	testBufferSize = 1500
+	testPort       = 10001
+)
+
+type testPeer struct {
+	stack  *stack.Stack
+	addr   tcpip.Address
+	peerFD int
+}
+
+// newTestPeer creates a stack connected to a new port of b, with address
+// 10.0.0.<n> and link address 02:00:00:00:00:<n>.
+func newTestPeer(t *testing.T, b *Bridge, n byte) *testPeer {
+	fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_SEQPACKET|unix.SOCK_NONBLOCK, 0)
+	if err != nil {
+		t.Fatalf("Socketpair() failed: %v", err)
+	}
+	queues, err := b.AddPort(PortOptions{MTU: testMTU, BufferSize: testBufferSize, PeerFD: fds[1]})
+	if err != nil {
+		t.Fatalf("AddPort() failed: %v", err)
+	}
+	// The queues are used directly by the endpoint below, rather than passed
+	// to another process, so they're not closed here.
+
+	ep, err := sharedmem.New(sharedmem.Options{
+		MTU:         testMTU,
+		BufferSize:  testBufferSize,
+		LinkAddress: tcpip.LinkAddress([]byte{2, 0, 0, 0, 0, n}),
+		TX:          queues.TXQueueConfig(),
+		RX:          queues.RXQueueConfig(),
+		PeerFD:      fds[0],
+	})
+	if err != nil {
+		t.Fatalf("sharedmem.New() failed: %v", err)
+	}
+
+	s := stack.New(stack.Options{
+		NetworkProtocols:   []stack.NetworkProtocolFactory{arp.NewProtocol, ipv4.NewProtocol},
+		TransportProtocols: []stack.TransportProtocolFactory{tcp.NewProtocol},
+	})
+	const nicID = 1
+	if err := s.CreateNIC(nicID, ep); err != nil {
+		t.Fatalf("CreateNIC() failed: %s", err)
+	}
+	addr := tcpip.Address([]byte{10, 0, 0, n})
+	protocolAddr := tcpip.ProtocolAddress{
+		Protocol:          ipv4.ProtocolNumber,
+		AddressWithPrefix: tcpip.AddressWithPrefix{Address: addr, PrefixLen: 24},
+	}
+	if err := s.AddProtocolAddress(nicID, protocolAddr, stack.AddressProperties{}); err != nil {
+		t.Fatalf("AddProtocolAddress() failed: %s", err)
+	}
+	s.SetRouteTable([]tcpip.Route{{Destination: header.IPv4EmptySubnet, NIC: nicID}})
+
+	p := &testPeer{stack: s, addr: addr, peerFD: fds[0]}
+	t.Cleanup(func() {
+		s.Close()
+		unix.Close(fds[0])
+		unix.Close(fds[1])
+	})
+	return p
+}
+
+func TestBridgeForwarding(t *testing.T) {
+	b := New()
+	defer b.Close()
+	peers := []*testPeer{
+		newTestPeer(t, b, 1),
+		newTestPeer(t, b, 2),
+		newTestPeer(t, b, 3),
+	}
+	if got := b.NumPorts(); got != len(peers) {
+		t.Fatalf("NumPorts() = %d, want %d", got, len(peers))
+	}
+
+	// Every peer can reach every other peer.
+	for _, server := range peers {
+		listenAddr := tcpip.FullAddress{Addr: server.addr, Port: testPort}
+		l, err := gonet.ListenTCP(server.stack, listenAddr, ipv4.ProtocolNumber)
+		if err != nil {
+			t.Fatalf("ListenTCP(%v) failed: %v", listenAddr, err)
+		}
+		go func() {
+			for {
+				c, err := l.Accept()
+				if err != nil {
+					return
+				}
+				io.Copy(c, c)
+				c.Close()
+			}
+		}()
+
+		for _, client := range peers {
+			if client == server {
+				continue
+			}
+			want := []byte(fmt.Sprintf("from %v to %v", client.addr, server.addr))
+			c, err := gonet.DialTCP(client.stack, listenAddr, ipv4.ProtocolNumber)
+			if err != nil {
+				t.Fatalf("DialTCP(%v) from %v failed: %v", listenAddr, client.addr, err)
+			}
+			if _, err := c.Write(want); err != nil {
+				t.Fatalf("Write() failed: %v", err)
+			}
+			got := make([]byte, len(want))
+			if _, err := io.ReadFull(c, got); err != nil {
+				t.Fatalf("ReadFull() failed: %v", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("got %q, want %q", got, want)
+			}
+			c.Close()
+		}
+		l.Close()
+	}
+}
+
+func TestBridgeRemovesPortOfClosedPeer(t *testing.T) {
+	b := New()
+	defer b.Close()
+	newTestPeer(t, b, 1)
+	p := newTestPeer(t, b, 2)
+
+	// Shutting down the peer's end of the socket pair is seen by the bridge
+	// as the peer going away.
+	if err := unix.Shutdown(p.peerFD, unix.SHUT_RDWR); err != nil {
+		t.Fatalf("Shutdown() failed: %v", err)
+	}
+	for start := time.Now(); b.NumPorts() != 1; {
+		if time.Since(start) > 10*time.Second {
+			t.Fatalf("NumPorts() = %d after closing a peer, want 1", b.NumPorts())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestServeDial(t *testing.T) {
+	b := New()
+	defer b.Close()
+	path := filepath.Join(t.TempDir(), "bridge.sock")
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		t.Fatalf("ListenUnix() failed: %v", err)
+	}
+	defer l.Close()
+	go b.Serve(l, PortOptions{MTU: testMTU, BufferSize: testBufferSize})
+
+	p, err := Dial(path)
+	if err != nil {
+		t.Fatalf("Dial() failed: %v", err)
+	}
+	if p.MTU != testMTU || p.BufferSize != testBufferSize {
+		t.Errorf("Dial() got MTU %d and buffer size %d, want %d and %d", p.MTU, p.BufferSize, testMTU, testBufferSize)
+	}
+	if len(p.FDs) != queueFDs+1 {
+		t.Errorf("Dial() got %d fds, want %d", len(p.FDs), queueFDs+1)
+	}
+	if got := b.NumPorts(); got != 1 {
+		t.Errorf("NumPorts() = %d, want 1", got)
+	}
+
+	// Closing all fds of the port, including the peer fd, removes it.
+	for _, fd := range p.FDs {
+		unix.Close(fd)
+	}
+	for start := time.Now(); b.NumPorts() != 0; {
+		if time.Since(start) > 10*time.Second {
+			t.Fatalf("NumPorts() = %d after closing the port fds, want 0", b.NumPorts())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package bridge
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+
+	"golang.org/x/sys/unix"
+	"gvisor.dev/gvisor/pkg/log"
+)
+
+// Ports are handed out over unix stream sockets. For each connection, the
+// bridge adds a port and sends a message with the MTU and buffer size of the
+// port, and the fds of the queues as SCM_RIGHTS. The connection then serves
+// as the peer fd of both ends: closing it removes the port.
+
+// queueFDs is the number of fds sent for a port: five for each queue, see
+// sharedmem.QueueConfig.FDs.
+const queueFDs = 10
+
+// portMessageSize is the size of the message describing a port.
+const portMessageSize = 8
+
+// Port is a port of a bridge received from a bridge server.
+type Port struct {
+	// MTU is the MTU of the port.
+	MTU uint32
+
+	// BufferSize is the size of the buffers of the queues.
+	BufferSize uint32
+
+	// FDs are the fds of the TX queue followed by the fds of the RX queue, in
+	// the order of sharedmem.QueueConfig.FDs, and finally the peer fd.
+	FDs []int
+}
+
+// Serve accepts connections on l and adds a port with the given options for
+// each of them, until l is closed. opts.PeerFD is ignored.
+func (b *Bridge) Serve(l *net.UnixListener, opts PortOptions) error {
+	for {
+		conn, err := l.AcceptUnix()
+		if err != nil {
+			return err
+		}
+		if err := b.servePort(conn, opts); err != nil {
+			log.Warningf("Adding bridge port: %v", err)
+		}
+		// The port keeps its own copy of the connection fd.
+		conn.Close()
+	}
+}
+
+func (b *Bridge) servePort(conn *net.UnixConn, opts PortOptions) error {
+	peerFD, err := dupConnFD(conn)
+	if err != nil {
+		return err
+	}
+	opts.PeerFD = peerFD
+	queues, err := b.AddPort(opts)
+	if err != nil {
+		unix.Close(peerFD)
+		return err
+	}
+	defer queues.Close()
+
+	tx, rx := queues.TXQueueConfig(), queues.RXQueueConfig()
+	fds := append(tx.FDs(), rx.FDs()...)
+	msg := make([]byte, portMessageSize)
+	binary.LittleEndian.PutUint32(msg, opts.MTU)
+	binary.LittleEndian.PutUint32(msg[4:], opts.BufferSize)
+	if _, _, err := conn.WriteMsgUnix(msg, unix.UnixRights(fds...), nil); err != nil {
+		// The port is removed when the peer closes the connection.
+		return fmt.Errorf("sending port: %v", err)
+	}
+	return nil
+}
+
+// Dial connects to a bridge served at path and returns a new port.
+func Dial(path string) (*Port, error) {
+	conn, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	msg := make([]byte, portMessageSize)
+	oob := make([]byte, unix.CmsgSpace(queueFDs*4))
+	n, oobn, _, _, err := conn.ReadMsgUnix(msg, oob)
+	if err != nil {
+		return nil, fmt.Errorf("receiving port: %v", err)
+	}
+	scms, err := unix.ParseSocketControlMessage(oob[:oobn])
+	if err != nil {
+		return nil, fmt.Errorf("parsing control message: %v", err)
+	}
+	var fds []int
+	for i := range scms {
+		rights, err := unix.ParseUnixRights(&scms[i])
+		if err != nil {
+			continue
+		}
+		fds = append(fds, rights...)
+	}
+	if n != portMessageSize || len(fds) != queueFDs {
+		for _, fd := range fds {
+			unix.Close(fd)
+		}
+		return nil, fmt.Errorf("invalid port message: %d bytes and %d fds", n, len(fds))
+	}
+
+	peerFD, err := dupConnFD(conn)
+	if err != nil {
+		for _, fd := range fds {
+			unix.Close(fd)
+		}
+		return nil, err
+	}
+	return &Port{
+		MTU:        binary.LittleEndian.Uint32(msg),
+		BufferSize: binary.LittleEndian.Uint32(msg[4:]),
+		FDs:        append(fds, peerFD),
+	}, nil
+}
+
+// dupConnFD returns a copy of the fd of conn, which stays open after conn is
+// closed.
+func dupConnFD(conn *net.UnixConn) (int, error) {
+	f, err := conn.File()
+	if err != nil {
+		return -1, err
+	}
+	defer f.Close()
+	return unix.Dup(int(f.Fd()))
+}
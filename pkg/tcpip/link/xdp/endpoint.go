@@ -0,0 +1,360 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+// Package xdp provides link layer endpoints backed by AF_XDP sockets.
+//
+// Packets are exchanged with a queue of a host device through the rings of
+// the socket, without going through the host network stack. An XDP program
+// must redirect the packets of the queue to the socket, see pkg/xdp.
+package xdp
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+	"gvisor.dev/gvisor/pkg/sync"
+	"gvisor.dev/gvisor/pkg/tcpip"
+	"gvisor.dev/gvisor/pkg/tcpip/buffer"
+	"gvisor.dev/gvisor/pkg/tcpip/header"
+	"gvisor.dev/gvisor/pkg/tcpip/link/rawfile"
+	"gvisor.dev/gvisor/pkg/tcpip/stack"
+	"gvisor.dev/gvisor/pkg/xdp"
+)
+
+// Options specify the details about the AF_XDP endpoint to be created.
+type Options struct {
+	// FD is an unbound AF_XDP socket. The endpoint takes ownership of it.
+	FD int
+
+	// InterfaceIndex is the index of the host device to bind the socket to.
+	InterfaceIndex int
+
+	// QueueID is the queue of the device to bind the socket to.
+	QueueID uint32
+
+	// MTU is the mtu to use for this endpoint.
+	MTU uint32
+
+	// Address is the link address for this endpoint.
+	Address tcpip.LinkAddress
+
+	// ClosedFunc is a function to be called when an endpoint's peer (if
+	// any) closes its end of the communication pipe.
+	ClosedFunc func(tcpip.Error)
+
+	// XDP are the options of the UMEM and rings of the socket. If zero,
+	// xdp.DefaultOptions is used.
+	XDP xdp.Options
+}
+
+type endpoint struct {
+	// control holds the UMEM and rings of the socket.
+	control *xdp.ControlBlock
+
+	mtu    uint32
+	addr   tcpip.LinkAddress
+	closed func(tcpip.Error)
+
+	// stopFD is an eventfd used to stop the dispatch goroutine.
+	stopFD int
+
+	// wg keeps track of the dispatch goroutine.
+	wg sync.WaitGroup
+
+	// dispatcher is only accessed by Attach and the dispatch goroutine.
+	dispatcher stack.NetworkDispatcher
+
+	// mu protects the TX side of control: the TX and completion queues, and
+	// the free frames of the UMEM. The RX side is only used by the dispatch
+	// goroutine.
+	mu sync.Mutex
+}
+
+// New creates a new AF_XDP endpoint, bound to opts.QueueID of the device with
+// index opts.InterfaceIndex.
+func New(opts *Options) (stack.LinkEndpoint, error) {
+	xdpOpts := opts.XDP
+	if xdpOpts == (xdp.Options{}) {
+		xdpOpts = xdp.DefaultOptions()
+	}
+	if max := xdpOpts.MaxPacketSize(); opts.MTU+header.EthernetMinimumSize > max {
+		unix.Close(opts.FD)
+		return nil, fmt.Errorf("MTU %d too large, packets are limited to %d bytes", opts.MTU, max)
+	}
+
+	stopFD, err := unix.Eventfd(0, unix.EFD_NONBLOCK)
+	if err != nil {
+		unix.Close(opts.FD)
+		return nil, fmt.Errorf("failed to create eventfd: %v", err)
+	}
+	control, err := xdp.New(opts.FD, uint32(opts.InterfaceIndex), opts.QueueID, xdpOpts)
+	if err != nil {
+		unix.Close(stopFD)
+		return nil, fmt.Errorf("setting up AF_XDP socket: %v", err)
+	}
+	return &endpoint{
+		control: control,
+		mtu:     opts.MTU,
+		addr:    opts.Address,
+		closed:  opts.ClosedFunc,
+		stopFD:  stopFD,
+	}, nil
+}
+
+// Attach implements stack.LinkEndpoint.Attach. It launches the goroutine that
+// reads packets from the RX queue and dispatches them via the provided
+// dispatcher.
+func (e *endpoint) Attach(dispatcher stack.NetworkDispatcher) {
+	// nil means the NIC is being removed.
+	if dispatcher == nil && e.dispatcher != nil {
+		increment := []byte{1, 0, 0, 0, 0, 0, 0, 0}
+		if n, err := unix.Write(e.stopFD, increment); n != len(increment) || err != nil {
+			panic(fmt.Sprintf("write(efd) = (%d, %s), want (%d, nil)", n, err, len(increment)))
+		}
+		e.Wait()
+		e.dispatcher = nil
+		return
+	}
+	if dispatcher != nil && e.dispatcher == nil {
+		e.dispatcher = dispatcher
+		// Link endpoints are not savable. When transportation endpoints are
+		// saved, they stop sending outgoing packets and all incoming packets
+		// are rejected.
+		e.wg.Add(1)
+		go func() { // S/R-SAFE: See above.
+			defer e.wg.Done()
+			if err := e.dispatchLoop(); err != nil && e.closed != nil {
+				e.closed(err)
+			}
+		}()
+	}
+}
+
+// IsAttached implements stack.LinkEndpoint.IsAttached.
+func (e *endpoint) IsAttached() bool {
+	return e.dispatcher != nil
+}
+
+// MTU implements stack.LinkEndpoint.MTU. It returns the value initialized
+// during construction.
+func (e *endpoint) MTU() uint32 {
+	return e.mtu
+}
+
+// Capabilities implements stack.LinkEndpoint.Capabilities.
+func (*endpoint) Capabilities() stack.LinkEndpointCapabilities {
+	return stack.CapabilityResolutionRequired
+}
+
+// MaxHeaderLength implements stack.LinkEndpoint.MaxHeaderLength. It returns the
+// ethernet frame header size.
+func (*endpoint) MaxHeaderLength() uint16 {
+	return header.EthernetMinimumSize
+}
+
+// LinkAddress implements stack.LinkEndpoint.LinkAddress. It returns the local
+// link address.
+func (e *endpoint) LinkAddress() tcpip.LinkAddress {
+	return e.addr
+}
+
+// Wait implements stack.LinkEndpoint.Wait. It waits for the dispatch goroutine
+// to stop.
+func (e *endpoint) Wait() {
+	e.wg.Wait()
+}
+
+// ARPHardwareType implements stack.LinkEndpoint.ARPHardwareType.
+func (*endpoint) ARPHardwareType() header.ARPHardwareType {
+	return header.ARPHardwareEther
+}
+
+// AddHeader implements stack.LinkEndpoint.AddHeader.
+func (e *endpoint) AddHeader(local, remote tcpip.LinkAddress, protocol tcpip.NetworkProtocolNumber, pkt *stack.PacketBuffer) {
+	eth := header.Ethernet(pkt.LinkHeader().Push(header.EthernetMinimumSize))
+	ethHdr := &header.EthernetFields{
+		DstAddr: remote,
+		Type:    protocol,
+	}
+
+	// Preserve the src address if it's set in the route.
+	if local != "" {
+		ethHdr.SrcAddr = local
+	} else {
+		ethHdr.SrcAddr = e.addr
+	}
+	eth.Encode(ethHdr)
+}
+
+// WriteRawPacket implements stack.LinkEndpoint.
+func (*endpoint) WriteRawPacket(*stack.PacketBuffer) tcpip.Error { return &tcpip.ErrNotSupported{} }
+
+// WritePacket writes outbound packets to the TX queue. If it's full, the
+// packet is dropped.
+func (e *endpoint) WritePacket(r stack.RouteInfo, protocol tcpip.NetworkProtocolNumber, pkt *stack.PacketBuffer) tcpip.Error {
+	e.AddHeader(r.LocalLinkAddress, r.RemoteLinkAddress, protocol, pkt)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := e.writePacketLocked(pkt); err != nil {
+		return err
+	}
+	return e.wakeupLocked()
+}
+
+// WritePackets implements stack.LinkEndpoint.WritePackets.
+func (e *endpoint) WritePackets(_ stack.RouteInfo, pkts stack.PacketBufferList, _ tcpip.NetworkProtocolNumber) (int, tcpip.Error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	n := 0
+	var err tcpip.Error
+	for pkt := pkts.Front(); pkt != nil; pkt = pkt.Next() {
+		e.AddHeader(pkt.EgressRoute.LocalLinkAddress, pkt.EgressRoute.RemoteLinkAddress, pkt.NetworkProtocolNumber, pkt)
+		if err = e.writePacketLocked(pkt); err != nil {
+			break
+		}
+		n++
+	}
+	if n > 0 {
+		// Send the packets written so far even if one of them failed.
+		if werr := e.wakeupLocked(); werr != nil {
+			return 0, werr
+		}
+	}
+	return n, err
+}
+
+// reclaimLocked returns the frames of sent packets to the free frames of the
+// UMEM.
+//
+// +checklocks:e.mu
+func (e *endpoint) reclaimLocked() {
+	cq := &e.control.Completion
+	n, index := cq.Peek()
+	for i := uint32(0); i < n; i++ {
+		e.control.UMEM.FreeFrame(cq.Get(index + i))
+	}
+	if n > 0 {
+		cq.Release(n)
+	}
+}
+
+// writePacketLocked copies pkt to a frame of the UMEM, and adds it to the TX
+// queue. The packet is only sent after wakeupLocked.
+//
+// +checklocks:e.mu
+func (e *endpoint) writePacketLocked(pkt *stack.PacketBuffer) tcpip.Error {
+	umem := &e.control.UMEM
+	if umem.NumFreeFrames() == 0 {
+		e.reclaimLocked()
+	}
+	addr, ok := umem.AllocFrame()
+	if !ok {
+		return &tcpip.ErrWouldBlock{}
+	}
+	index, ok := e.control.TX.Reserve(1)
+	if !ok {
+		// There are fewer frames than TX descriptors, so this can only
+		// happen if the kernel is misbehaving.
+		umem.FreeFrame(addr)
+		return &tcpip.ErrWouldBlock{}
+	}
+
+	frame := umem.Frame(addr)
+	n := 0
+	for _, v := range pkt.Views() {
+		if n+len(v) > len(frame) {
+			// The packet doesn't fit in the frame. Send the truncated
+			// frame, it will be dropped by the kernel.
+			break
+		}
+		n += copy(frame[n:], v)
+	}
+	e.control.TX.Set(index, unix.XDPDesc{Addr: addr, Len: uint32(n)})
+	e.control.TX.Notify()
+	return nil
+}
+
+// wakeupLocked asks the kernel to send the packets of the TX queue.
+//
+// +checklocks:e.mu
+func (e *endpoint) wakeupLocked() tcpip.Error {
+	if err := e.control.Wakeup(); err != nil {
+		if errno, ok := err.(unix.Errno); ok {
+			return rawfile.TranslateErrno(errno)
+		}
+		return &tcpip.ErrClosedForSend{}
+	}
+	return nil
+}
+
+// dispatchLoop reads packets from the RX queue in a loop and dispatches them
+// to the network stack.
+func (e *endpoint) dispatchLoop() tcpip.Error {
+	for {
+		stopped, errno := rawfile.BlockingPollUntilStopped(e.stopFD, e.control.FD(), unix.POLLIN|unix.POLLERR)
+		if stopped {
+			return nil
+		}
+		if errno != 0 {
+			if errno == unix.EINTR {
+				continue
+			}
+			return rawfile.TranslateErrno(errno)
+		}
+
+		// Copy the received packets out of the UMEM, so that their frames
+		// can be given back to the kernel right away.
+		rx := &e.control.RX
+		n, index := rx.Peek()
+		if n == 0 {
+			continue
+		}
+		views := make([]buffer.View, 0, n)
+		fillIndex, ok := e.control.Fill.Reserve(n)
+		if !ok {
+			panic(fmt.Sprintf("fill queue can't hold %d frames", n))
+		}
+		for i := uint32(0); i < n; i++ {
+			desc := rx.Get(index + i)
+			views = append(views, buffer.NewViewFromBytes(e.control.UMEM.Get(desc)))
+			e.control.Fill.Set(fillIndex+i, e.control.UMEM.FrameAddr(desc.Addr))
+		}
+		rx.Release(n)
+		e.control.Fill.Notify()
+
+		for _, v := range views {
+			e.deliverPacket(v)
+		}
+	}
+}
+
+// deliverPacket dispatches the ethernet frame v to the network stack.
+func (e *endpoint) deliverPacket(v buffer.View) {
+	pkt := stack.NewPacketBuffer(stack.PacketBufferOptions{
+		Data: v.ToVectorisedView(),
+	})
+	defer pkt.DecRef()
+
+	hdr, ok := pkt.LinkHeader().Consume(header.EthernetMinimumSize)
+	if !ok {
+		return
+	}
+	eth := header.Ethernet(hdr)
+	e.dispatcher.DeliverNetworkPacket(eth.SourceAddress(), eth.DestinationAddress(), eth.Type(), pkt)
+}
@@ -132,6 +132,33 @@ func (e *endpoint) checkLocalAddress(addr tcpip.Address) bool {
 	return false
 }
 
+// mtuPlateaus are the common MTUs of links, as listed by RFC 1191 section 7.
+var mtuPlateaus = [...]uint16{65535, 32000, 17914, 8166, 4352, 2002, 1492, 1006, 508, 296, 68}
+
+// estimatePathMTU estimates the MTU of the path when a Fragmentation Needed
+// message doesn't include the MTU of the next hop, as sent by routers which
+// predate RFC 1191. pkt must hold the IP header of the original packet. 0 is
+// returned if the original packet can't be parsed.
+func estimatePathMTU(pkt *stack.PacketBuffer) uint16 {
+	// RFC 1191 section 5:
+	//   A more sophisticated approach is to "search" for an accurate PMTU
+	//   estimate, by continuing to send datagrams with the DF bit while
+	//   varying their sizes.
+	//
+	// Like Linux, use the next plateau below the size of the original packet.
+	h, ok := pkt.Data().PullUp(header.IPv4MinimumSize)
+	if !ok {
+		return 0
+	}
+	totalLen := header.IPv4(h).TotalLength()
+	for _, plateau := range mtuPlateaus {
+		if plateau < totalLen {
+			return plateau
+		}
+	}
+	return 0
+}
+
 // handleControl handles the case when an ICMP error packet contains the headers
 // of the original packet that caused the ICMP one to be sent. This information
 // is used to find out which transport endpoint must be notified about the ICMP
@@ -347,6 +374,9 @@ func (e *endpoint) handleICMP(pkt *stack.PacketBuffer) {
 		case header.ICMPv4PortUnreachable:
 			e.handleControl(&icmpv4DestinationPortUnreachableSockError{}, pkt)
 		case header.ICMPv4FragmentationNeeded:
+			if mtu == 0 {
+				mtu = estimatePathMTU(pkt)
+			}
 			networkMTU, err := calculateNetworkMTU(uint32(mtu), header.IPv4MinimumSize)
 			if err != nil {
 				networkMTU = 0
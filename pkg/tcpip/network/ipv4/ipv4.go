@@ -377,12 +377,17 @@ func (e *endpoint) addIPHeader(srcAddr, dstAddr tcpip.Address, pkt *stack.Packet
 		return &tcpip.ErrMessageTooLong{}
 	}
 	// RFC 6864 section 4.3 mandates uniqueness of ID values for non-atomic
-	// datagrams. Since the DF bit is never being set here, all datagrams
-	// are non-atomic and need an ID.
+	// datagrams. Atomic datagrams, with the DF bit set, get an ID too, like
+	// on Linux.
 	id := atomic.AddUint32(&e.protocol.ids[hashRoute(srcAddr, dstAddr, params.Protocol, e.protocol.hashIV)%buckets], 1)
+	var flags uint8
+	if params.DF {
+		flags = header.IPv4FlagDontFragment
+	}
 	ipH.Encode(&header.IPv4Fields{
 		TotalLength: uint16(length),
 		ID:          uint16(id),
+		Flags:       flags,
 		TTL:         params.TTL,
 		TOS:         params.TOS,
 		Protocol:    uint8(params.Protocol),
@@ -483,9 +488,10 @@ func (e *endpoint) writePacket(r *stack.Route, pkt *stack.PacketBuffer, headerIn
 
 	if packetMustBeFragmented(pkt, networkMTU) {
 		h := header.IPv4(pkt.NetworkHeader().View())
-		if h.Flags()&header.IPv4FlagDontFragment != 0 && pkt.NetworkPacketInfo.IsForwardedPacket {
-			// TODO(gvisor.dev/issue/5919): Handle error condition in which DontFragment
-			// is set but the packet must be fragmented for the non-forwarding case.
+		if h.Flags()&header.IPv4FlagDontFragment != 0 {
+			// Packets with DF set are never fragmented, e.g. sockets doing path
+			// MTU discovery must send smaller packets instead.
+			stats.OutgoingPacketErrors.Increment()
 			return &tcpip.ErrMessageTooLong{}
 		}
 		sent, remain, err := e.handleFragments(r, networkMTU, pkt, func(fragPkt *stack.PacketBuffer) tcpip.Error {
@@ -531,6 +537,10 @@ func (e *endpoint) WritePackets(r *stack.Route, pkts stack.PacketBufferList, par
 		}
 
 		if packetMustBeFragmented(pkt, networkMTU) {
+			if params.DF {
+				stats.OutgoingPacketErrors.IncrementBy(uint64(pkts.Len()))
+				return 0, &tcpip.ErrMessageTooLong{}
+			}
 			// Keep track of the packet that is about to be fragmented so it can be
 			// removed once the fragmentation is done.
 			originalPkt := pkt
@@ -1665,6 +1665,7 @@ func TestFragmentationErrors(t *testing.T) {
 		mtu                   uint32
 		transportHeaderLength int
 		payloadSize           int
+		df                    bool
 		allowPackets          int
 		outgoingErrors        int
 		mockError             tcpip.Error
@@ -1720,6 +1721,17 @@ func TestFragmentationErrors(t *testing.T) {
 			mockError:             nil,
 			wantError:             &tcpip.ErrInvalidEndpointState{},
 		},
+		{
+			description:           "Error when DF is set and packet is larger than MTU",
+			mtu:                   500,
+			transportHeaderLength: 0,
+			payloadSize:           1000,
+			df:                    true,
+			allowPackets:          0,
+			outgoingErrors:        1,
+			mockError:             nil,
+			wantError:             &tcpip.ErrMessageTooLong{},
+		},
 	}
 
 	for _, ft := range tests {
@@ -1731,6 +1743,7 @@ func TestFragmentationErrors(t *testing.T) {
 				Protocol: tcp.ProtocolNumber,
 				TTL:      ttl,
 				TOS:      stack.DefaultTOS,
+				DF:       ft.df,
 			}, pkt)
 			if diff := cmp.Diff(ft.wantError, err); diff != "" {
 				t.Fatalf("unexpected error from r.WritePacket(_, _, _), (-want, +got):\n%s", diff)
@@ -20,6 +20,7 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"golang.org/x/time/rate"
@@ -1304,6 +1305,125 @@ func TestLinkAddressRequest(t *testing.T) {
 	}
 }
 
+func TestSendUnsolicitedNeighborAdvert(t *testing.T) {
+	const nicID = 1
+
+	s := stack.New(stack.Options{
+		NetworkProtocols: []stack.NetworkProtocolFactory{NewProtocol},
+	})
+	linkEP := channel.New(defaultChannelSize, defaultMTU, linkAddr0)
+	if err := s.CreateNIC(nicID, linkEP); err != nil {
+		t.Fatalf("s.CreateNIC(%d, _): %s", nicID, err)
+	}
+	ep, err := s.GetNetworkEndpoint(nicID, ProtocolNumber)
+	if err != nil {
+		t.Fatalf("s.GetNetworkEndpoint(%d, %d): %s", nicID, ProtocolNumber, err)
+	}
+	ndpEP, ok := ep.(NDPEndpoint)
+	if !ok {
+		t.Fatalf("expected %T to implement NDPEndpoint", ep)
+	}
+
+	// The address must be assigned to the endpoint.
+	if diff := cmp.Diff(&tcpip.ErrBadLocalAddress{}, ndpEP.SendUnsolicitedNeighborAdvert(lladdr1)); diff != "" {
+		t.Fatalf("unexpected error from SendUnsolicitedNeighborAdvert(%s) with unassigned address, (-want, +got):\n%s", lladdr1, diff)
+	}
+
+	protocolAddr := tcpip.ProtocolAddress{
+		Protocol:          ProtocolNumber,
+		AddressWithPrefix: lladdr1.WithPrefix(),
+	}
+	if err := s.AddProtocolAddress(nicID, protocolAddr, stack.AddressProperties{}); err != nil {
+		t.Fatalf("AddProtocolAddress(%d, %+v, {}): %s", nicID, protocolAddr, err)
+	}
+	if err := ndpEP.SendUnsolicitedNeighborAdvert(lladdr1); err != nil {
+		t.Fatalf("SendUnsolicitedNeighborAdvert(%s): %s", lladdr1, err)
+	}
+
+	pkt, ok := linkEP.Read()
+	if !ok {
+		t.Fatal("expected to send a neighbor advertisement")
+	}
+	if want := header.EthernetAddressFromMulticastIPv6Address(header.IPv6AllNodesMulticastAddress); pkt.Route.RemoteLinkAddress != want {
+		t.Errorf("got pkt.Route.RemoteLinkAddress = %s, want = %s", pkt.Route.RemoteLinkAddress, want)
+	}
+	checker.IPv6(t, stack.PayloadSince(pkt.Pkt.NetworkHeader()),
+		checker.SrcAddr(lladdr1),
+		checker.DstAddr(header.IPv6AllNodesMulticastAddress),
+		checker.TTL(header.NDPHopLimit),
+		checker.NDPNA(
+			checker.NDPNATargetAddress(lladdr1),
+			checker.NDPNASolicitedFlag(false),
+			checker.NDPNAOptions([]header.NDPOption{header.NDPTargetLinkLayerAddressOption(linkAddr0)}),
+		))
+}
+
+func TestSendUnsolicitedNeighborAdvertAfterDAD(t *testing.T) {
+	const nicID = 1
+
+	clock := faketime.NewManualClock()
+	dadConfigs := stack.DADConfigurations{
+		DupAddrDetectTransmits: 1,
+		RetransmitTimer:        time.Second,
+	}
+	s := stack.New(stack.Options{
+		Clock: clock,
+		NetworkProtocols: []stack.NetworkProtocolFactory{NewProtocolWithOptions(Options{
+			DADConfigs: dadConfigs,
+		})},
+	})
+	linkEP := channel.New(defaultChannelSize, defaultMTU, linkAddr0)
+	if err := s.CreateNIC(nicID, linkEP); err != nil {
+		t.Fatalf("s.CreateNIC(%d, _): %s", nicID, err)
+	}
+	ep, err := s.GetNetworkEndpoint(nicID, ProtocolNumber)
+	if err != nil {
+		t.Fatalf("s.GetNetworkEndpoint(%d, %d): %s", nicID, ProtocolNumber, err)
+	}
+	ndpEP, ok := ep.(NDPEndpoint)
+	if !ok {
+		t.Fatalf("expected %T to implement NDPEndpoint", ep)
+	}
+
+	protocolAddr := tcpip.ProtocolAddress{
+		Protocol:          ProtocolNumber,
+		AddressWithPrefix: lladdr1.WithPrefix(),
+	}
+	if err := s.AddProtocolAddress(nicID, protocolAddr, stack.AddressProperties{}); err != nil {
+		t.Fatalf("AddProtocolAddress(%d, %+v, {}): %s", nicID, protocolAddr, err)
+	}
+	clock.RunImmediatelyScheduledJobs()
+	if _, ok := linkEP.Read(); !ok {
+		t.Fatal("expected to send a DAD message")
+	}
+
+	// The address is tentative, the advertisement must wait for DAD.
+	if err := ndpEP.SendUnsolicitedNeighborAdvert(lladdr1); err != nil {
+		t.Fatalf("SendUnsolicitedNeighborAdvert(%s): %s", lladdr1, err)
+	}
+	if pkt, ok := linkEP.Read(); ok {
+		t.Fatalf("unexpected packet sent while the address is tentative: %#v", pkt)
+	}
+
+	clock.Advance(dadConfigs.RetransmitTimer)
+	pkt, ok := linkEP.Read()
+	if !ok {
+		t.Fatal("expected to send a neighbor advertisement once DAD succeeded")
+	}
+	checker.IPv6(t, stack.PayloadSince(pkt.Pkt.NetworkHeader()),
+		checker.SrcAddr(lladdr1),
+		checker.DstAddr(header.IPv6AllNodesMulticastAddress),
+		checker.TTL(header.NDPHopLimit),
+		checker.NDPNA(
+			checker.NDPNATargetAddress(lladdr1),
+			checker.NDPNASolicitedFlag(false),
+			checker.NDPNAOptions([]header.NDPOption{header.NDPTargetLinkLayerAddressOption(linkAddr0)}),
+		))
+	if pkt, ok := linkEP.Read(); ok {
+		t.Fatalf("unexpected packet sent after the advertisement: %#v", pkt)
+	}
+}
+
 func TestPacketQueing(t *testing.T) {
 	const nicID = 1
 
@@ -167,6 +167,13 @@ const (
 type NDPEndpoint interface {
 	// SetNDPConfigurations sets the NDP configurations.
 	SetNDPConfigurations(NDPConfigurations)
+
+	// SendUnsolicitedNeighborAdvert sends an unsolicited Neighbor
+	// Advertisement for addr, which must be added to the endpoint, to the
+	// all-nodes multicast address, so that neighbors update their neighbor
+	// caches as per RFC 4861 section 7.2.6. If addr is tentative, the
+	// advertisement is sent once DAD for it succeeds.
+	SendUnsolicitedNeighborAdvert(addr tcpip.Address) tcpip.Error
 }
 
 // DHCPv6ConfigurationFromNDPRA is a configuration available via DHCPv6 that an
@@ -479,6 +486,10 @@ type ndpState struct {
 	// The DAD timers to send the next NS message, or resolve the address.
 	dad ip.DAD
 
+	// The tentative addresses to send an unsolicited Neighbor Advertisement
+	// for once DAD for them succeeds.
+	pendingAdverts map[tcpip.Address]struct{}
+
 	// The off-link routes discovered through Router Advertisements.
 	offLinkRoutes map[offLinkRoute]offLinkRouteState
 
@@ -631,6 +642,9 @@ func (ndp *ndpState) startDuplicateAddressDetection(addr tcpip.Address, addressE
 			panic(fmt.Sprintf("ndpdad: addr %s is no longer tentative on NIC(%d)", addr, ndp.ep.nic.ID()))
 		}
 
+		_, advertise := ndp.pendingAdverts[addr]
+		delete(ndp.pendingAdverts, addr)
+
 		var dadSucceeded bool
 		switch r.(type) {
 		case *stack.DADAborted, *stack.DADError, *stack.DADDupAddrDetected:
@@ -657,6 +671,11 @@ func (ndp *ndpState) startDuplicateAddressDetection(addr tcpip.Address, addressE
 			}
 
 			ndp.ep.onAddressAssignedLocked(addr)
+
+			if advertise {
+				// Failures are counted in the ICMP stats.
+				_ = ndp.ep.sendUnsolicitedNeighborAdvert(addr)
+			}
 		}
 	})
 
@@ -1895,6 +1914,7 @@ func (ndp *ndpState) init(ep *endpoint, dadOptions ip.DADOptions) {
 	ndp.configs = ep.protocol.options.NDPConfigs
 	ndp.dad.Init(&ndp.ep.mu, ep.protocol.options.DADConfigs, dadOptions)
 	ndp.offLinkRoutes = make(map[offLinkRoute]offLinkRouteState)
+	ndp.pendingAdverts = make(map[tcpip.Address]struct{})
 	ndp.onLinkPrefixes = make(map[tcpip.Subnet]onLinkPrefixState)
 	ndp.slaacPrefixes = make(map[tcpip.Subnet]slaacPrefixState)
 
@@ -1909,6 +1929,75 @@ func (ndp *ndpState) SendDADMessage(addr tcpip.Address, nonce []byte) tcpip.Erro
 	})
 }
 
+// SendUnsolicitedNeighborAdvert implements NDPEndpoint.
+func (e *endpoint) SendUnsolicitedNeighborAdvert(addr tcpip.Address) tcpip.Error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	addressEndpoint := e.getAddressRLocked(addr)
+	if addressEndpoint == nil {
+		return &tcpip.ErrBadLocalAddress{}
+	}
+	if addressEndpoint.GetKind() == stack.PermanentTentative {
+		// As per RFC 4862 section 5.4, a tentative address must not be
+		// used until DAD for it succeeds.
+		e.mu.ndp.pendingAdverts[addr] = struct{}{}
+		return nil
+	}
+	if !addressEndpoint.IsAssigned(false /* allowExpired */) {
+		return &tcpip.ErrBadLocalAddress{}
+	}
+	return e.sendUnsolicitedNeighborAdvert(addr)
+}
+
+// sendUnsolicitedNeighborAdvert sends an unsolicited Neighbor Advertisement
+// for addr, which must be assigned to e.
+//
+// Precondition: e.mu must be locked.
+func (e *endpoint) sendUnsolicitedNeighborAdvert(addr tcpip.Address) tcpip.Error {
+	opts := header.NDPOptionsSerializer{
+		header.NDPTargetLinkLayerAddressOption(e.nic.LinkAddress()),
+	}
+	icmp := header.ICMPv6(buffer.NewView(header.ICMPv6NeighborAdvertMinimumSize + opts.Length()))
+	icmp.SetType(header.ICMPv6NeighborAdvert)
+	na := header.NDPNeighborAdvert(icmp.MessageBody())
+	// As per RFC 4861 section 7.2.6, the Solicited flag of unsolicited
+	// advertisements is zero, and the Override flag may be set so that
+	// neighbors update their cached link-layer address.
+	na.SetSolicitedFlag(false)
+	na.SetOverrideFlag(true)
+	na.SetTargetAddress(addr)
+	na.Options().Serialize(opts)
+	dstAddr := header.IPv6AllNodesMulticastAddress
+	icmp.SetChecksum(header.ICMPv6Checksum(header.ICMPv6ChecksumParams{
+		Header: icmp,
+		Src:    addr,
+		Dst:    dstAddr,
+	}))
+
+	pkt := stack.NewPacketBuffer(stack.PacketBufferOptions{
+		ReserveHeaderBytes: int(e.MaxHeaderLength()),
+		Data:               buffer.View(icmp).ToVectorisedView(),
+	})
+	defer pkt.DecRef()
+
+	if err := addIPHeader(addr, dstAddr, pkt, stack.NetworkHeaderParams{
+		Protocol: header.ICMPv6ProtocolNumber,
+		TTL:      header.NDPHopLimit,
+	}, nil /* extensionHeaders */); err != nil {
+		panic(fmt.Sprintf("failed to add IP header: %s", err))
+	}
+
+	sent := e.stats.icmp.packetsSent
+	err := e.nic.WritePacketToRemote(header.EthernetAddressFromMulticastIPv6Address(dstAddr), ProtocolNumber, pkt)
+	if err != nil {
+		sent.dropped.Increment()
+	} else {
+		sent.neighborAdvert.Increment()
+	}
+	return err
+}
+
 func (e *endpoint) sendNDPNS(srcAddr, dstAddr, targetAddr tcpip.Address, remoteLinkAddr tcpip.LinkAddress, opts header.NDPOptionsSerializer) tcpip.Error {
 	icmp := header.ICMPv6(buffer.NewView(header.ICMPv6NeighborSolicitMinimumSize + opts.Length()))
 	icmp.SetType(header.ICMPv6NeighborSolicit)
@@ -19,6 +19,7 @@ package ports
 import (
 	"math"
 	"math/rand"
+	"sort"
 	"sync/atomic"
 
 	"gvisor.dev/gvisor/pkg/sync"
@@ -221,11 +222,17 @@ type PortManager struct {
 	// be reused.
 	allocatedPorts map[portDescriptor]addrToDevice
 
-	// ephemeralMu protects firstEphemeral and numEphemeral.
+	// ephemeralMu protects firstEphemeral, numEphemeral and reservedPorts.
 	ephemeralMu    sync.RWMutex
 	firstEphemeral uint16
 	numEphemeral   uint16
 
+	// reservedPorts are never picked as ephemeral ports, but can still be
+	// reserved explicitly, like ip_local_reserved_ports on Linux. The map
+	// is replaced instead of modified, so it can be used without holding
+	// ephemeralMu once loaded.
+	reservedPorts map[uint16]struct{}
+
 	// hint is used to pick ports ephemeral ports in a stable order for
 	// a given port offset.
 	//
@@ -256,10 +263,11 @@ func (pm *PortManager) PickEphemeralPort(rng *rand.Rand, testPort PortTester) (p
 	pm.ephemeralMu.RLock()
 	firstEphemeral := pm.firstEphemeral
 	numEphemeral := pm.numEphemeral
+	reservedPorts := pm.reservedPorts
 	pm.ephemeralMu.RUnlock()
 
 	offset := uint32(rng.Int31n(int32(numEphemeral)))
-	return pickEphemeralPort(offset, firstEphemeral, numEphemeral, testPort)
+	return pickEphemeralPort(offset, firstEphemeral, numEphemeral, skipReservedPorts(reservedPorts, testPort))
 }
 
 // portHint atomically reads and returns the pm.hint value.
@@ -280,15 +288,30 @@ func (pm *PortManager) PickEphemeralPortStable(offset uint32, testPort PortTeste
 	pm.ephemeralMu.RLock()
 	firstEphemeral := pm.firstEphemeral
 	numEphemeral := pm.numEphemeral
+	reservedPorts := pm.reservedPorts
 	pm.ephemeralMu.RUnlock()
 
-	p, err := pickEphemeralPort(pm.portHint()+offset, firstEphemeral, numEphemeral, testPort)
+	p, err := pickEphemeralPort(pm.portHint()+offset, firstEphemeral, numEphemeral, skipReservedPorts(reservedPorts, testPort))
 	if err == nil {
 		pm.incPortHint()
 	}
 	return p, err
 }
 
+// skipReservedPorts returns a PortTester that rejects the reserved ports, and
+// otherwise defers to testPort.
+func skipReservedPorts(reservedPorts map[uint16]struct{}, testPort PortTester) PortTester {
+	if len(reservedPorts) == 0 {
+		return testPort
+	}
+	return func(port uint16) (bool, tcpip.Error) {
+		if _, ok := reservedPorts[port]; ok {
+			return false, nil
+		}
+		return testPort(port)
+	}
+}
+
 // pickEphemeralPort starts at the offset specified from the FirstEphemeral port
 // and iterates over the number of ports specified by count and allows the
 // caller to decide whether a given port is suitable for its needs, and stopping
@@ -524,3 +547,29 @@ func (pm *PortManager) SetPortRange(start uint16, end uint16) tcpip.Error {
 	pm.numEphemeral = end - start + 1
 	return nil
 }
+
+// ReservedPorts returns the ports that are never picked as ephemeral ports, in
+// ascending order.
+func (pm *PortManager) ReservedPorts() []uint16 {
+	pm.ephemeralMu.RLock()
+	defer pm.ephemeralMu.RUnlock()
+	ports := make([]uint16, 0, len(pm.reservedPorts))
+	for port := range pm.reservedPorts {
+		ports = append(ports, port)
+	}
+	sort.Slice(ports, func(i, j int) bool { return ports[i] < ports[j] })
+	return ports
+}
+
+// SetReservedPorts sets the ports that are never picked as ephemeral ports.
+// They can still be reserved explicitly, e.g. by bind(2) with a non-zero port.
+// Ports that are already reserved are not affected.
+func (pm *PortManager) SetReservedPorts(ports []uint16) {
+	reservedPorts := make(map[uint16]struct{}, len(ports))
+	for _, port := range ports {
+		reservedPorts[port] = struct{}{}
+	}
+	pm.ephemeralMu.Lock()
+	defer pm.ephemeralMu.Unlock()
+	pm.reservedPorts = reservedPorts
+}
@@ -434,6 +434,53 @@ func TestPickEphemeralPort(t *testing.T) {
 	}
 }
 
+func TestReservedPorts(t *testing.T) {
+	const (
+		firstEphemeral    = 32000
+		numEphemeralPorts = 10
+	)
+
+	pm := NewPortManager()
+	if err := pm.SetPortRange(firstEphemeral, firstEphemeral+numEphemeralPorts-1); err != nil {
+		t.Fatalf("failed to set ephemeral port range: %s", err)
+	}
+	// Reserve all ports of the range but one, and a port outside of it.
+	var reserved []uint16
+	for port := uint16(firstEphemeral + 1); port < firstEphemeral+numEphemeralPorts; port++ {
+		reserved = append(reserved, port)
+	}
+	pm.SetReservedPorts(append([]uint16{80}, reserved...))
+	if diff := cmp.Diff(append([]uint16{80}, reserved...), pm.ReservedPorts()); diff != "" {
+		t.Errorf("unexpected ReservedPorts(), (-want, +got):\n%s", diff)
+	}
+
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	accept := func(uint16) (bool, tcpip.Error) { return true, nil }
+	for i := 0; i < numEphemeralPorts; i++ {
+		if port, err := pm.PickEphemeralPort(rng, accept); err != nil || port != firstEphemeral {
+			t.Fatalf("got PickEphemeralPort(..) = (%d, %v); want (%d, nil)", port, err, firstEphemeral)
+		}
+		if port, err := pm.PickEphemeralPortStable(uint32(i), accept); err != nil || port != firstEphemeral {
+			t.Fatalf("got PickEphemeralPortStable(..) = (%d, %v); want (%d, nil)", port, err, firstEphemeral)
+		}
+	}
+
+	// Reserved ports can still be reserved explicitly.
+	res := Reservation{
+		Networks:  []tcpip.NetworkProtocolNumber{fakeNetworkNumber},
+		Transport: fakeTransNumber,
+		Port:      firstEphemeral + 1,
+	}
+	if _, err := pm.ReservePort(rng, res, nil); err != nil {
+		t.Errorf("ReservePort(%d) failed: %s", res.Port, err)
+	}
+
+	pm.SetReservedPorts(nil)
+	if got := pm.ReservedPorts(); len(got) != 0 {
+		t.Errorf("got ReservedPorts() = %v after clearing them, want none", got)
+	}
+}
+
 func TestPickEphemeralPortStable(t *testing.T) {
 	const (
 		firstEphemeral    = 32000
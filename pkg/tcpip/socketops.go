@@ -170,6 +170,14 @@ type SocketOptions struct {
 	// message is passed with incoming packets.
 	receiveTClassEnabled uint32
 
+	// receiveTTLEnabled is used to specify if the IP_TTL ancillary message is
+	// passed with incoming packets.
+	receiveTTLEnabled uint32
+
+	// receiveHopLimitEnabled is used to specify if the IPV6_HOPLIMIT ancillary
+	// message is passed with incoming packets.
+	receiveHopLimitEnabled uint32
+
 	// receivePacketInfoEnabled is used to specify if more information is
 	// provided with incoming IPv4 packets.
 	receivePacketInfoEnabled uint32
@@ -354,6 +362,26 @@ func (so *SocketOptions) SetReceiveTClass(v bool) {
 	storeAtomicBool(&so.receiveTClassEnabled, v)
 }
 
+// GetReceiveTTL gets value for IP_RECVTTL option.
+func (so *SocketOptions) GetReceiveTTL() bool {
+	return atomic.LoadUint32(&so.receiveTTLEnabled) != 0
+}
+
+// SetReceiveTTL sets value for IP_RECVTTL option.
+func (so *SocketOptions) SetReceiveTTL(v bool) {
+	storeAtomicBool(&so.receiveTTLEnabled, v)
+}
+
+// GetReceiveHopLimit gets value for IPV6_RECVHOPLIMIT option.
+func (so *SocketOptions) GetReceiveHopLimit() bool {
+	return atomic.LoadUint32(&so.receiveHopLimitEnabled) != 0
+}
+
+// SetReceiveHopLimit sets value for IPV6_RECVHOPLIMIT option.
+func (so *SocketOptions) SetReceiveHopLimit(v bool) {
+	storeAtomicBool(&so.receiveHopLimitEnabled, v)
+}
+
 // GetReceivePacketInfo gets value for IP_PKTINFO option.
 func (so *SocketOptions) GetReceivePacketInfo() bool {
 	return atomic.LoadUint32(&so.receivePacketInfoEnabled) != 0
@@ -380,6 +380,7 @@ func (n *nic) writePacket(r RouteInfo, protocol tcpip.NetworkProtocolNumber, pkt
 	n.deliverOutboundPacket(r.RemoteLinkAddress, pkt)
 
 	if err := n.LinkEndpoint.WritePacket(r, protocol, pkt); err != nil {
+		n.stats.txPacketsDropped.Increment()
 		return err
 	}
 
@@ -394,14 +395,19 @@ func (n *nic) WritePackets(r *Route, pkts PacketBufferList, protocol tcpip.Netwo
 }
 
 func (n *nic) writePackets(r RouteInfo, protocol tcpip.NetworkProtocolNumber, pkts PacketBufferList) (int, tcpip.Error) {
+	numPackets := 0
 	for pkt := pkts.Front(); pkt != nil; pkt = pkt.Next() {
 		pkt.EgressRoute = r
 		pkt.NetworkProtocolNumber = protocol
 		n.deliverOutboundPacket(r.RemoteLinkAddress, pkt)
+		numPackets++
 	}
 
 	writtenPackets, err := n.LinkEndpoint.WritePackets(r, pkts, protocol)
 	n.stats.tx.packets.IncrementBy(uint64(writtenPackets))
+	if err != nil {
+		n.stats.txPacketsDropped.IncrementBy(uint64(numPackets - writtenPackets))
+	}
 	writtenBytes := 0
 	for i, pb := 0, pkts.Front(); i < writtenPackets && pb != nil; i, pb = i+1, pb.Next() {
 		writtenBytes += pb.Size()
@@ -295,6 +295,12 @@ type NetworkHeaderParams struct {
 
 	// TOS refers to TypeOfService or TrafficClass field of the IP-header.
 	TOS uint8
+
+	// DF indicates that the Don't Fragment flag must be set in the IPv4
+	// header. Such packets are never fragmented, writing them fails with
+	// ErrMessageTooLong if they don't fit in the MTU. It has no effect on
+	// IPv6 packets.
+	DF bool
 }
 
 // GroupAddressableEndpoint is an endpoint that supports group addressing.
@@ -57,6 +57,14 @@ type ResumableEndpoint interface {
 	Resume(*Stack)
 }
 
+// SavedEndpoint is an endpoint that stops receiving packets when it's saved,
+// and must be restarted if the stack keeps running after the save.
+type SavedEndpoint interface {
+	// ResumeAfterSave restarts an endpoint stopped by its save, when the stack
+	// keeps running after the save instead of being restored.
+	ResumeAfterSave()
+}
+
 // uniqueIDGenerator is a default unique ID generator.
 type uniqueIDGenerator atomicbitops.AlignedAtomicUint64
 
@@ -116,6 +124,14 @@ type Stack struct {
 	// stack is being restored.
 	resumableEndpoints []ResumableEndpoint
 
+	// resumableSave is true while the stack is saved to keep running after
+	// the save. See PrepareResumableSave.
+	resumableSave bool
+
+	// savedEndpoints is a list of endpoints stopped by a resumable save, which
+	// are restarted by ResumeAfterSave.
+	savedEndpoints []SavedEndpoint
+
 	// icmpRateLimiter is a global rate limiter for all ICMP messages generated
 	// by the stack.
 	icmpRateLimiter *ICMPRateLimiter
@@ -574,6 +590,18 @@ func (s *Stack) SetPortRange(start uint16, end uint16) tcpip.Error {
 	return s.PortManager.SetPortRange(start, end)
 }
 
+// ReservedPorts returns the ports that are excluded from the ephemeral port
+// range, in ascending order.
+func (s *Stack) ReservedPorts() []uint16 {
+	return s.PortManager.ReservedPorts()
+}
+
+// SetReservedPorts sets the ports that are excluded from the ephemeral port
+// range. They can still be bound explicitly.
+func (s *Stack) SetReservedPorts(ports []uint16) {
+	s.PortManager.SetReservedPorts(ports)
+}
+
 // SetRouteTable assigns the route table to be used by this stack. It
 // specifies which NIC to use for given destination address ranges.
 //
@@ -598,8 +626,73 @@ func (s *Stack) AddRoute(route tcpip.Route) {
 	s.route.mu.table = append(s.route.mu.table, route)
 }
 
-// RemoveRoutes removes matching routes from the route table.
-func (s *Stack) RemoveRoutes(match func(tcpip.Route) bool) {
+// InsertRouteOptions are options for InsertRoute. They follow the
+// NLM_F_REPLACE, NLM_F_CREATE and NLM_F_EXCL flags of Linux's RTM_NEWROUTE.
+type InsertRouteOptions struct {
+	// Replace makes the route replace the first route with the same
+	// destination, if any.
+	Replace bool
+
+	// Create allows adding the route when it doesn't replace another route.
+	Create bool
+
+	// Exclusive fails the insertion if a route with the same destination
+	// exists.
+	Exclusive bool
+}
+
+// InsertRoute inserts route into the route table before the routes with a
+// shorter destination prefix, so that it's preferred over less specific routes
+// since the first matching route is used. Among routes with the same
+// destination, the new route is inserted first. Unlike AddRoute, the route
+// table is checked and updated atomically.
+//
+// It returns *tcpip.ErrDuplicateAddress if route is already in the table and
+// isn't replaced, or if opts.Exclusive is set and a route with the same
+// destination exists. It returns *tcpip.ErrNoRoute if route doesn't replace
+// another route and opts.Create isn't set.
+func (s *Stack) InsertRoute(route tcpip.Route, opts InsertRouteOptions) tcpip.Error {
+	s.route.mu.Lock()
+	defer s.route.mu.Unlock()
+
+	table := s.route.mu.table
+	pos := len(table)
+	for i, r := range table {
+		if !r.Destination.Equal(route.Destination) {
+			if pos == len(table) && r.Destination.Prefix() < route.Destination.Prefix() {
+				pos = i
+			}
+			continue
+		}
+		if opts.Exclusive {
+			return &tcpip.ErrDuplicateAddress{}
+		}
+		if opts.Replace {
+			table[i] = route
+			return nil
+		}
+		if pos == len(table) {
+			pos = i
+		}
+		if r.Equal(route) {
+			return &tcpip.ErrDuplicateAddress{}
+		}
+	}
+	if !opts.Create {
+		return &tcpip.ErrNoRoute{}
+	}
+
+	newTable := make([]tcpip.Route, 0, len(table)+1)
+	newTable = append(newTable, table[:pos]...)
+	newTable = append(newTable, route)
+	newTable = append(newTable, table[pos:]...)
+	s.route.mu.table = newTable
+	return nil
+}
+
+// RemoveRoutes removes matching routes from the route table, it returns the
+// number of routes that are removed.
+func (s *Stack) RemoveRoutes(match func(tcpip.Route) bool) int {
 	s.route.mu.Lock()
 	defer s.route.mu.Unlock()
 
@@ -609,7 +702,9 @@ func (s *Stack) RemoveRoutes(match func(tcpip.Route) bool) {
 			filteredRoutes = append(filteredRoutes, route)
 		}
 	}
+	removed := len(s.route.mu.table) - len(filteredRoutes)
 	s.route.mu.table = filteredRoutes
+	return removed
 }
 
 // NewEndpoint creates a new transport layer endpoint of the given protocol.
@@ -1456,6 +1551,50 @@ func (s *Stack) RegisterRestoredEndpoint(e ResumableEndpoint) {
 	s.mu.Unlock()
 }
 
+// PrepareResumableSave prepares the stack to be saved while it keeps running
+// after the save, e.g. for snapshots. Until ResumeAfterSave is called,
+// endpoints record themselves when they are saved so that they can be
+// restarted, and connections that can't be saved fail the save instead of
+// being reset.
+func (s *Stack) PrepareResumableSave() {
+	s.mu.Lock()
+	s.resumableSave = true
+	s.mu.Unlock()
+}
+
+// ResumableSave returns true if the stack is saved to keep running after the
+// save.
+func (s *Stack) ResumableSave() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.resumableSave
+}
+
+// RegisterSavedEndpoint records e as an endpoint stopped by its save. It's a
+// no-op unless the stack is saved to keep running after the save.
+func (s *Stack) RegisterSavedEndpoint(e SavedEndpoint) {
+	s.mu.Lock()
+	if s.resumableSave {
+		s.savedEndpoints = append(s.savedEndpoints, e)
+	}
+	s.mu.Unlock()
+}
+
+// ResumeAfterSave restarts the endpoints stopped by a save prepared by
+// PrepareResumableSave, whether it succeeded or not.
+func (s *Stack) ResumeAfterSave() {
+	// SavedEndpoint.ResumeAfterSave() may call other methods on s, so we
+	// can't hold s.mu while resuming the endpoints.
+	s.mu.Lock()
+	eps := s.savedEndpoints
+	s.savedEndpoints = nil
+	s.resumableSave = false
+	s.mu.Unlock()
+	for _, e := range eps {
+		e.ResumeAfterSave()
+	}
+}
+
 // RegisteredEndpoints returns all endpoints which are currently registered.
 func (s *Stack) RegisteredEndpoints() []TransportEndpoint {
 	s.mu.Lock()
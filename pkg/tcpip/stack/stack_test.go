@@ -4043,6 +4043,117 @@ func TestAddRoute(t *testing.T) {
 	}
 }
 
+// TestInsertRoute tests Stack.InsertRoute.
+func TestInsertRoute(t *testing.T) {
+	mustSubnet := func(addr, mask tcpip.Address) tcpip.Subnet {
+		subnet, err := tcpip.NewSubnet(addr, mask)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return subnet
+	}
+	defaultRoute := tcpip.Route{Destination: mustSubnet("\x00\x00", "\x00\x00"), Gateway: "\x01\x01", NIC: 1}
+	subnetRoute := tcpip.Route{Destination: mustSubnet("\x0a\x00", "\xff\x00"), NIC: 1}
+	subnetRoute2 := tcpip.Route{Destination: mustSubnet("\x0a\x00", "\xff\x00"), NIC: 2}
+	hostRoute := tcpip.Route{Destination: mustSubnet("\x0a\x01", "\xff\xff"), NIC: 1}
+	create := stack.InsertRouteOptions{Create: true}
+
+	tests := []struct {
+		name    string
+		table   []tcpip.Route
+		route   tcpip.Route
+		opts    stack.InsertRouteOptions
+		wantErr tcpip.Error
+		want    []tcpip.Route
+	}{
+		{
+			name:  "empty table",
+			route: subnetRoute,
+			opts:  create,
+			want:  []tcpip.Route{subnetRoute},
+		},
+		{
+			name:  "before less specific",
+			table: []tcpip.Route{defaultRoute},
+			route: subnetRoute,
+			opts:  create,
+			want:  []tcpip.Route{subnetRoute, defaultRoute},
+		},
+		{
+			name:  "after more specific",
+			table: []tcpip.Route{hostRoute, defaultRoute},
+			route: subnetRoute,
+			opts:  create,
+			want:  []tcpip.Route{hostRoute, subnetRoute, defaultRoute},
+		},
+		{
+			name:  "before same destination",
+			table: []tcpip.Route{subnetRoute, defaultRoute},
+			route: subnetRoute2,
+			opts:  create,
+			want:  []tcpip.Route{subnetRoute2, subnetRoute, defaultRoute},
+		},
+		{
+			name:    "duplicate",
+			table:   []tcpip.Route{subnetRoute, defaultRoute},
+			route:   subnetRoute,
+			opts:    create,
+			wantErr: &tcpip.ErrDuplicateAddress{},
+			want:    []tcpip.Route{subnetRoute, defaultRoute},
+		},
+		{
+			name:    "exclusive",
+			table:   []tcpip.Route{subnetRoute, defaultRoute},
+			route:   subnetRoute2,
+			opts:    stack.InsertRouteOptions{Create: true, Exclusive: true},
+			wantErr: &tcpip.ErrDuplicateAddress{},
+			want:    []tcpip.Route{subnetRoute, defaultRoute},
+		},
+		{
+			name:  "replace",
+			table: []tcpip.Route{hostRoute, subnetRoute, defaultRoute},
+			route: subnetRoute2,
+			opts:  stack.InsertRouteOptions{Replace: true},
+			want:  []tcpip.Route{hostRoute, subnetRoute2, defaultRoute},
+		},
+		{
+			name:  "replace creates",
+			table: []tcpip.Route{defaultRoute},
+			route: subnetRoute,
+			opts:  stack.InsertRouteOptions{Replace: true, Create: true},
+			want:  []tcpip.Route{subnetRoute, defaultRoute},
+		},
+		{
+			name:    "replace without create",
+			table:   []tcpip.Route{defaultRoute},
+			route:   subnetRoute,
+			opts:    stack.InsertRouteOptions{Replace: true},
+			wantErr: &tcpip.ErrNoRoute{},
+			want:    []tcpip.Route{defaultRoute},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := stack.New(stack.Options{})
+			s.SetRouteTable(append([]tcpip.Route(nil), test.table...))
+
+			if diff := cmp.Diff(test.wantErr, s.InsertRoute(test.route, test.opts)); diff != "" {
+				t.Errorf("s.InsertRoute(%#v, %#v) error mismatch (-want +got):\n%s", test.route, test.opts, diff)
+			}
+			rt := s.GetRouteTable()
+			if got, want := len(rt), len(test.want); got != want {
+				t.Fatalf("Unexpected route table length got = %d, want = %d", got, want)
+			}
+			for i, route := range rt {
+				if got, want := route, test.want[i]; got != want {
+					t.Errorf("Unexpected route %d got = %#v, want = %#v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
 // TestRemoveRoutes tests Stack.RemoveRoutes
 func TestRemoveRoutes(t *testing.T) {
 	s := stack.New(stack.Options{})
@@ -4071,9 +4182,11 @@ func TestRemoveRoutes(t *testing.T) {
 	})
 
 	// Remove routes with the specific address.
-	s.RemoveRoutes(func(r tcpip.Route) bool {
+	if got, want := s.RemoveRoutes(func(r tcpip.Route) bool {
 		return r.Destination.ID() == addressToRemove
-	})
+	}), 2; got != want {
+		t.Fatalf("got s.RemoveRoutes(...) = %d, want = %d", got, want)
+	}
 
 	expected := []tcpip.Route{{Destination: subnet3, Gateway: "\x00", NIC: 1}}
 	rt := s.GetRouteTable()
@@ -4678,3 +4791,44 @@ func TestStaticGetLinkAddress(t *testing.T) {
 		})
 	}
 }
+
+type fakeSavedEndpoint struct {
+	resumed int
+}
+
+// ResumeAfterSave implements stack.SavedEndpoint.ResumeAfterSave.
+func (e *fakeSavedEndpoint) ResumeAfterSave() {
+	e.resumed++
+}
+
+func TestResumeAfterSave(t *testing.T) {
+	s := stack.New(stack.Options{})
+
+	// Endpoints aren't recorded by saves that stop the stack.
+	var stopped fakeSavedEndpoint
+	s.RegisterSavedEndpoint(&stopped)
+	s.ResumeAfterSave()
+	if stopped.resumed != 0 {
+		t.Errorf("endpoint saved without PrepareResumableSave resumed %d times, want 0", stopped.resumed)
+	}
+
+	s.PrepareResumableSave()
+	if !s.ResumableSave() {
+		t.Errorf("got s.ResumableSave() = false after PrepareResumableSave")
+	}
+	var ep fakeSavedEndpoint
+	s.RegisterSavedEndpoint(&ep)
+	s.ResumeAfterSave()
+	if ep.resumed != 1 {
+		t.Errorf("endpoint resumed %d times, want 1", ep.resumed)
+	}
+	if s.ResumableSave() {
+		t.Errorf("got s.ResumableSave() = true after ResumeAfterSave")
+	}
+
+	// Endpoints are only resumed once.
+	s.ResumeAfterSave()
+	if ep.resumed != 1 {
+		t.Errorf("endpoint resumed %d times after second ResumeAfterSave, want 1", ep.resumed)
+	}
+}
@@ -445,6 +445,18 @@ type ControlMessages struct {
 	// TClass is the IPv6 traffic class of the associated packet.
 	TClass uint32
 
+	// HasTTL indicates whether TTL is valid/set.
+	HasTTL bool
+
+	// TTL is the IPv4 time to live of the associated packet.
+	TTL uint8
+
+	// HasHopLimit indicates whether HopLimit is valid/set.
+	HasHopLimit bool
+
+	// HopLimit is the IPv6 hop limit of the associated packet.
+	HopLimit uint8
+
 	// HasIPPacketInfo indicates whether PacketInfo is set.
 	HasIPPacketInfo bool
 
@@ -714,9 +726,10 @@ const (
 
 	// MTUDiscoverOption is used to set/get the path MTU discovery setting.
 	//
-	// NOTE: Setting this option to any other value than PMTUDiscoveryDont
-	// is not supported and will fail as such, and getting this option will
-	// always return PMTUDiscoveryDont.
+	// TCP endpoints set the DF bit unless the setting is PMTUDiscoveryDont,
+	// and reduce their MSS when the path MTU is found to be smaller. Datagram
+	// endpoints only set the DF bit with PMTUDiscoveryDo and
+	// PMTUDiscoveryProbe, since they don't track the path MTU.
 	MTUDiscoverOption
 
 	// MulticastTTLOption is used by SetSockOptInt/GetSockOptInt to control
@@ -2016,6 +2029,10 @@ type NICStats struct {
 	// Tx contains statistics about transmitted packets.
 	Tx NICPacketStats
 
+	// TxPacketsDropped is the number of packets that the link endpoint failed
+	// to transmit, e.g. because its queue was full.
+	TxPacketsDropped *StatCounter
+
 	// Rx contains statistics about received packets.
 	Rx NICPacketStats
 
@@ -69,6 +69,10 @@ type Endpoint struct {
 	ipv4TOS uint8
 	// +checklocks:mu
 	ipv6TClass uint8
+	// pmtud is the path MTU discovery setting, see tcpip.MTUDiscoverOption.
+	//
+	// +checklocks:mu
+	pmtud int
 
 	// Lock ordering: mu > infoMu.
 	infoMu sync.RWMutex `state:"nosave"`
@@ -209,6 +213,7 @@ type WriteContext struct {
 	route      *stack.Route
 	ttl        uint8
 	tos        uint8
+	df         bool
 	owner      tcpip.PacketOwner
 }
 
@@ -249,6 +254,7 @@ func (c *WriteContext) WritePacket(pkt *stack.PacketBuffer, headerIncluded bool)
 		Protocol: c.transProto,
 		TTL:      c.ttl,
 		TOS:      c.tos,
+		DF:       c.df,
 	}, pkt)
 }
 
@@ -329,7 +335,10 @@ func (e *Endpoint) AcquireContextForWrite(opts tcpip.WriteOptions) (WriteContext
 		route:      route,
 		ttl:        calculateTTL(route, e.ttl, e.multicastTTL),
 		tos:        tos,
-		owner:      e.owner,
+		// The path MTU isn't tracked for datagram endpoints, the DF bit is
+		// only set when asked for explicitly.
+		df:    e.pmtud == tcpip.PMTUDiscoveryDo || e.pmtud == tcpip.PMTUDiscoveryProbe,
+		owner: e.owner,
 	}, nil
 }
 
@@ -591,11 +600,14 @@ func (e *Endpoint) GetRemoteAddress() (tcpip.FullAddress, bool) {
 func (e *Endpoint) SetSockOptInt(opt tcpip.SockOptInt, v int) tcpip.Error {
 	switch opt {
 	case tcpip.MTUDiscoverOption:
-		// Return not supported if the value is not disabling path
-		// MTU discovery.
-		if v != tcpip.PMTUDiscoveryDont {
+		switch v {
+		case tcpip.PMTUDiscoveryWant, tcpip.PMTUDiscoveryDont, tcpip.PMTUDiscoveryDo, tcpip.PMTUDiscoveryProbe:
+		default:
 			return &tcpip.ErrNotSupported{}
 		}
+		e.mu.Lock()
+		e.pmtud = v
+		e.mu.Unlock()
 
 	case tcpip.MulticastTTLOption:
 		e.mu.Lock()
@@ -625,8 +637,10 @@ func (e *Endpoint) SetSockOptInt(opt tcpip.SockOptInt, v int) tcpip.Error {
 func (e *Endpoint) GetSockOptInt(opt tcpip.SockOptInt) (int, tcpip.Error) {
 	switch opt {
 	case tcpip.MTUDiscoverOption:
-		// The only supported setting is path MTU discovery disabled.
-		return tcpip.PMTUDiscoveryDont, nil
+		e.mu.Lock()
+		v := e.pmtud
+		e.mu.Unlock()
+		return v, nil
 
 	case tcpip.MulticastTTLOption:
 		e.mu.Lock()
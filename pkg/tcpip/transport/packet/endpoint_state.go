@@ -48,6 +48,14 @@ func (ep *endpoint) beforeSave() {
 	ep.rcvMu.Lock()
 	defer ep.rcvMu.Unlock()
 	ep.rcvDisabled = true
+	ep.stack.RegisterSavedEndpoint(ep)
+}
+
+// ResumeAfterSave implements stack.SavedEndpoint.ResumeAfterSave.
+func (ep *endpoint) ResumeAfterSave() {
+	ep.rcvMu.Lock()
+	defer ep.rcvMu.Unlock()
+	ep.rcvDisabled = false
 }
 
 // afterLoad is invoked by stateify.
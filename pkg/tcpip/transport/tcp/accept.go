@@ -354,6 +354,7 @@ func (e *endpoint) propagateInheritableOptionsLocked(n *endpoint) {
 	n.boundBindToDevice = e.boundBindToDevice
 	n.boundPortFlags = e.boundPortFlags
 	n.userMSS = e.userMSS
+	n.pmtud = e.pmtud
 }
 
 // reserveTupleLocked reserves an accepted endpoint's tuple.
@@ -439,6 +439,11 @@ type endpoint struct {
 	ttl               uint8
 	isConnectNotified bool
 
+	// pmtud is the path MTU discovery setting of the endpoint, as set by
+	// MTUDiscoverOption. The DF bit is set on outgoing packets unless it's
+	// PMTUDiscoveryDont.
+	pmtud int
+
 	// h stores a reference to the current handshake state if the endpoint is in
 	// the SYN-SENT or SYN-RECV states, in which case endpoint == endpoint.h.ep.
 	// nil otherwise.
@@ -1769,11 +1774,14 @@ func (e *endpoint) SetSockOptInt(opt tcpip.SockOptInt, v int) tcpip.Error {
 		e.notifyProtocolGoroutine(notifyMSSChanged)
 
 	case tcpip.MTUDiscoverOption:
-		// Return not supported if attempting to set this option to
-		// anything other than path MTU discovery disabled.
-		if v != tcpip.PMTUDiscoveryDont {
+		switch v {
+		case tcpip.PMTUDiscoveryWant, tcpip.PMTUDiscoveryDont, tcpip.PMTUDiscoveryDo, tcpip.PMTUDiscoveryProbe:
+		default:
 			return &tcpip.ErrNotSupported{}
 		}
+		e.LockUser()
+		e.pmtud = v
+		e.UnlockUser()
 
 	case tcpip.TTLOption:
 		e.LockUser()
@@ -1953,9 +1961,10 @@ func (e *endpoint) GetSockOptInt(opt tcpip.SockOptInt) (int, tcpip.Error) {
 		return v, nil
 
 	case tcpip.MTUDiscoverOption:
-		// Always return the path MTU discovery disabled setting since
-		// it's the only one supported.
-		return tcpip.PMTUDiscoveryDont, nil
+		e.LockUser()
+		v := e.pmtud
+		e.UnlockUser()
+		return v, nil
 
 	case tcpip.ReceiveQueueSizeOption:
 		return e.readyReceiveSize()
@@ -47,6 +47,7 @@ func (e *endpoint) drainSegmentLocked() {
 func (e *endpoint) beforeSave() {
 	// Stop incoming packets.
 	e.segmentQueue.freeze()
+	e.stack.RegisterSavedEndpoint(e)
 
 	e.mu.Lock()
 	defer e.mu.Unlock()
@@ -56,7 +57,9 @@ func (e *endpoint) beforeSave() {
 	case epState == StateInitial || epState == StateBound:
 	case epState.connected() || epState.handshake():
 		if !e.route.HasSaveRestoreCapability() {
-			if !e.route.HasDisconncetOkCapability() {
+			if !e.route.HasDisconncetOkCapability() || e.stack.ResumableSave() {
+				// The endpoint must not be reset if it keeps running after
+				// the save.
 				panic(&tcpip.ErrSaveRejection{
 					Err: fmt.Errorf("endpoint cannot be saved in connected state: local %s:%d, remote %s:%d", e.TransportEndpointInfo.ID.LocalAddress, e.TransportEndpointInfo.ID.LocalPort, e.TransportEndpointInfo.ID.RemoteAddress, e.TransportEndpointInfo.ID.RemotePort),
 				})
@@ -161,6 +164,11 @@ func (e *endpoint) afterLoad() {
 	stack.StackFromEnv.RegisterRestoredEndpoint(e)
 }
 
+// ResumeAfterSave implements stack.SavedEndpoint.ResumeAfterSave.
+func (e *endpoint) ResumeAfterSave() {
+	e.segmentQueue.thaw()
+}
+
 // Resume implements tcpip.ResumableEndpoint.Resume.
 func (e *endpoint) Resume(s *stack.Stack) {
 	e.keepalive.timer.init(s.Clock(), &e.keepalive.waker)
@@ -4001,6 +4001,48 @@ func TestRetransmitIPv4IDUniqueness(t *testing.T) {
 	}
 }
 
+// TestDontFragment tests that the DF bit is set on sent packets unless path
+// MTU discovery is disabled.
+func TestDontFragment(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		pmtud     int
+		wantFlags uint8
+	}{
+		{"Want", tcpip.PMTUDiscoveryWant, header.IPv4FlagDontFragment},
+		{"Dont", tcpip.PMTUDiscoveryDont, 0},
+		{"Do", tcpip.PMTUDiscoveryDo, header.IPv4FlagDontFragment},
+		{"Probe", tcpip.PMTUDiscoveryProbe, header.IPv4FlagDontFragment},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := context.New(t, defaultMTU)
+			defer c.Cleanup()
+
+			c.CreateConnected(context.TestInitialSequenceNumber, 30000 /* rcvWnd */, -1 /* epRcvBuf */)
+
+			if err := c.EP.SetSockOptInt(tcpip.MTUDiscoverOption, tc.pmtud); err != nil {
+				t.Fatalf("SetSockOptInt(MTUDiscoverOption, %d): %s", tc.pmtud, err)
+			}
+			if v, err := c.EP.GetSockOptInt(tcpip.MTUDiscoverOption); err != nil || v != tc.pmtud {
+				t.Fatalf("GetSockOptInt(MTUDiscoverOption) = (%d, %v), want = (%d, nil)", v, err, tc.pmtud)
+			}
+
+			var r bytes.Reader
+			r.Reset(make([]byte, 512))
+			if _, err := c.EP.Write(&r, tcpip.WriteOptions{}); err != nil {
+				t.Fatalf("Write failed: %s", err)
+			}
+			checker.IPv4(t, c.GetPacket(),
+				checker.FragmentFlags(tc.wantFlags),
+				checker.TCP(
+					checker.DstPort(context.TestPort),
+					checker.TCPFlagsMatch(header.TCPFlagAck, ^header.TCPFlagPsh),
+				),
+			)
+		})
+	}
+}
+
 func TestFinImmediately(t *testing.T) {
 	c := context.New(t, defaultMTU)
 	defer c.Cleanup()
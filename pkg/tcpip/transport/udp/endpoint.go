@@ -41,6 +41,8 @@ type udpPacket struct {
 	receivedAt         time.Time             `state:".(int64)"`
 	// tos stores either the receiveTOS or receiveTClass value.
 	tos uint8
+	// ttl stores either the IPv4 TTL or the IPv6 hop limit.
+	ttl uint8
 }
 
 // endpoint represents a UDP endpoint. This struct serves as the interface
@@ -243,6 +245,11 @@ func (e *endpoint) Read(dst io.Writer, opts tcpip.ReadOptions) (tcpip.ReadResult
 			cm.TOS = p.tos
 		}
 
+		if e.ops.GetReceiveTTL() {
+			cm.HasTTL = true
+			cm.TTL = p.ttl
+		}
+
 		if e.ops.GetReceivePacketInfo() {
 			cm.HasIPPacketInfo = true
 			cm.PacketInfo = p.packetInfo
@@ -254,6 +261,11 @@ func (e *endpoint) Read(dst io.Writer, opts tcpip.ReadOptions) (tcpip.ReadResult
 			cm.TClass = uint32(p.tos)
 		}
 
+		if e.ops.GetReceiveHopLimit() {
+			cm.HasHopLimit = true
+			cm.HopLimit = p.ttl
+		}
+
 		if e.ops.GetIPv6ReceivePacketInfo() {
 			cm.HasIPv6PacketInfo = true
 			cm.IPv6PacketInfo = tcpip.IPv6PacketInfo{
@@ -925,9 +937,13 @@ func (e *endpoint) HandlePacket(id stack.TransportEndpointID, pkt *stack.PacketB
 	// Save any useful information from the network header to the packet.
 	switch pkt.NetworkProtocolNumber {
 	case header.IPv4ProtocolNumber:
-		packet.tos, _ = header.IPv4(pkt.NetworkHeader().View()).TOS()
+		h := header.IPv4(pkt.NetworkHeader().View())
+		packet.tos, _ = h.TOS()
+		packet.ttl = h.TTL()
 	case header.IPv6ProtocolNumber:
-		packet.tos, _ = header.IPv6(pkt.NetworkHeader().View()).TOS()
+		h := header.IPv6(pkt.NetworkHeader().View())
+		packet.tos, _ = h.TOS()
+		packet.ttl = h.HopLimit()
 	}
 
 	// TODO(gvisor.dev/issue/3556): r.LocalAddress may be a multicast or broadcast
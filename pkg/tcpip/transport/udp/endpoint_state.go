@@ -52,6 +52,12 @@ func (e *endpoint) afterLoad() {
 // beforeSave is invoked by stateify.
 func (e *endpoint) beforeSave() {
 	e.freeze()
+	e.stack.RegisterSavedEndpoint(e)
+}
+
+// ResumeAfterSave implements stack.SavedEndpoint.ResumeAfterSave.
+func (e *endpoint) ResumeAfterSave() {
+	e.thaw()
 }
 
 // Resume implements tcpip.ResumableEndpoint.Resume.
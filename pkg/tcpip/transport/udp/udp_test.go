@@ -63,6 +63,7 @@ const (
 	multicastV6Addr = "\xff\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00"
 	broadcastAddr   = header.IPv4Broadcast
 	testTOS         = 0x80
+	testTTL         = 65
 
 	// defaultMTU is the MTU, in bytes, used throughout the tests, except
 	// where another value is explicitly used. It is chosen to match the MTU
@@ -473,7 +474,7 @@ func (c *testContext) buildV6Packet(payload []byte, h *header4Tuple) buffer.View
 		TrafficClass:      testTOS,
 		PayloadLength:     uint16(header.UDPMinimumSize + len(payload)),
 		TransportProtocol: udp.ProtocolNumber,
-		HopLimit:          65,
+		HopLimit:          testTTL,
 		SrcAddr:           h.srcAddr.Addr,
 		DstAddr:           h.dstAddr.Addr,
 	})
@@ -509,7 +510,7 @@ func (c *testContext) buildV4Packet(payload []byte, h *header4Tuple) buffer.View
 	ip.Encode(&header.IPv4Fields{
 		TOS:         testTOS,
 		TotalLength: uint16(len(buf)),
-		TTL:         65,
+		TTL:         testTTL,
 		Protocol:    uint8(udp.ProtocolNumber),
 		SrcAddr:     h.srcAddr.Addr,
 		DstAddr:     h.dstAddr.Addr,
@@ -1826,6 +1827,77 @@ func TestReceiveTosTClass(t *testing.T) {
 	}
 }
 
+func TestReceiveTTLHopLimit(t *testing.T) {
+	const RcvTTLOpt = "ReceiveTTLOption"
+	const RcvHopLimitOpt = "ReceiveHopLimitOption"
+
+	testCases := []struct {
+		name  string
+		tests []testFlow
+	}{
+		{
+			name:  RcvTTLOpt,
+			tests: v4PacketFlows[:],
+		},
+		{
+			name:  RcvHopLimitOpt,
+			tests: v6PacketFlows[:],
+		},
+	}
+	for _, testCase := range testCases {
+		for _, flow := range testCase.tests {
+			t.Run(fmt.Sprintf("%s:flow:%s", testCase.name, flow), func(t *testing.T) {
+				c := newDualTestContext(t, defaultMTU)
+				defer c.cleanup()
+
+				c.createEndpointForFlow(flow)
+				name := testCase.name
+
+				if flow.isMulticast() {
+					netProto := flow.netProto()
+					addr := flow.getMcastAddr()
+					if err := c.s.JoinGroup(netProto, c.nicID, addr); err != nil {
+						c.t.Fatalf("JoinGroup(%d, %d, %s): %s", netProto, c.nicID, addr, err)
+					}
+				}
+
+				var optionGetter func() bool
+				var optionSetter func(bool)
+				switch name {
+				case RcvTTLOpt:
+					optionGetter = c.ep.SocketOptions().GetReceiveTTL
+					optionSetter = c.ep.SocketOptions().SetReceiveTTL
+				case RcvHopLimitOpt:
+					optionGetter = c.ep.SocketOptions().GetReceiveHopLimit
+					optionSetter = c.ep.SocketOptions().SetReceiveHopLimit
+				default:
+					t.Fatalf("unknown test variant: %s", name)
+				}
+
+				if v := optionGetter(); v != false {
+					c.t.Errorf("got GetSockOptBool(%s) = %t, want = %t", name, v, false)
+				}
+				optionSetter(true)
+				if v := optionGetter(); v != true {
+					c.t.Errorf("got GetSockOptBool(%s) = %t, want = %t", name, v, true)
+				}
+
+				if err := c.ep.Bind(tcpip.FullAddress{Port: stackPort}); err != nil {
+					c.t.Fatalf("Bind failed: %s", err)
+				}
+				switch name {
+				case RcvTTLOpt:
+					testRead(c, flow, checker.ReceiveTTL(testTTL))
+				case RcvHopLimitOpt:
+					testRead(c, flow, checker.ReceiveHopLimit(testTTL))
+				default:
+					t.Fatalf("unknown test variant: %s", name)
+				}
+			})
+		}
+	}
+}
+
 func TestMulticastInterfaceOption(t *testing.T) {
 	for _, flow := range []testFlow{multicastV4, multicastV4in6, multicastV6, multicastV6Only} {
 		t.Run(fmt.Sprintf("flow:%s", flow), func(t *testing.T) {
@@ -2125,7 +2197,7 @@ func TestShortHeader(t *testing.T) {
 		TrafficClass:      testTOS,
 		PayloadLength:     uint16(udpSize),
 		TransportProtocol: udp.ProtocolNumber,
-		HopLimit:          65,
+		HopLimit:          testTTL,
 		SrcAddr:           h.srcAddr.Addr,
 		DstAddr:           h.dstAddr.Addr,
 	})
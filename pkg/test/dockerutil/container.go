@@ -100,6 +100,10 @@ type RunOpts struct {
 
 	// Links is the list of containers to be connected to the container.
 	Links []string
+
+	// Network is the name of the network to connect the container to,
+	// instead of the default bridge network. See Network.
+	Network string
 }
 
 func makeContainer(ctx context.Context, logger testutil.Logger, runtime string) *Container {
@@ -121,11 +125,12 @@ func makeContainer(ctx context.Context, logger testutil.Logger, runtime string)
 
 // MakeContainer constructs a suitable Container object.
 //
-// The runtime used is determined by the runtime flag.
+// The runtime used is determined by the runtime flag, unless logger is a test
+// run by RunMatrix, in which case the runtime of the matrix entry is used.
 //
 // Containers will check flags for profiling requests.
 func MakeContainer(ctx context.Context, logger testutil.Logger) *Container {
-	return makeContainer(ctx, logger, *runtime)
+	return makeContainer(ctx, logger, runtimeFor(logger))
 }
 
 // MakeNativeContainer constructs a suitable Container object.
@@ -259,6 +264,7 @@ func (c *Container) hostConfig(r RunOpts) *container.HostConfig {
 		CapDrop:         r.CapDrop,
 		Privileged:      r.Privileged,
 		ReadonlyRootfs:  r.ReadOnly,
+		NetworkMode:     container.NetworkMode(r.Network),
 		Resources: container.Resources{
 			Memory:     int64(r.Memory), // In bytes.
 			CpusetCpus: r.CpusetCpus,
@@ -361,6 +367,31 @@ func (c *Container) FindIP(ctx context.Context, ipv6 bool) (net.IP, error) {
 	return ip, nil
 }
 
+// FindNetworkIP returns the IP address of the container in the given network,
+// which the container was connected to with RunOpts.Network or
+// Network.Connect.
+func (c *Container) FindNetworkIP(ctx context.Context, n *Network, ipv6 bool) (net.IP, error) {
+	resp, err := c.client.ContainerInspect(ctx, c.id)
+	if err != nil {
+		return nil, err
+	}
+	settings, ok := resp.NetworkSettings.Networks[n.Name]
+	if !ok {
+		return nil, fmt.Errorf("container isn't connected to network %q", n.Name)
+	}
+
+	var ip net.IP
+	if ipv6 {
+		ip = net.ParseIP(settings.GlobalIPv6Address)
+	} else {
+		ip = net.ParseIP(settings.IPAddress)
+	}
+	if ip == nil {
+		return net.IP{}, ErrNoIP
+	}
+	return ip, nil
+}
+
 // FindPort returns the host port that is mapped to 'sandboxPort'.
 func (c *Container) FindPort(ctx context.Context, sandboxPort int) (int, error) {
 	desc, err := c.client.ContainerInspect(ctx, c.id)
@@ -118,6 +118,26 @@ func UsingVFS2() (bool, error) {
 }
 
 func runtimeMap() (map[string]interface{}, error) {
+	rs, err := runtimesMap()
+	if err != nil {
+		return nil, err
+	}
+	r, ok := rs[*runtime]
+	if !ok {
+		// The expected runtime is not declared.
+		return nil, fmt.Errorf("runtime %q not found: %v", *runtime, rs)
+	}
+	rm, ok := r.(map[string]interface{})
+	if !ok {
+		// The runtime is not a map.
+		return nil, fmt.Errorf("unexpected format: %v", r)
+	}
+	return rm, nil
+}
+
+// runtimesMap returns the runtimes declared in the Docker daemon
+// configuration.
+func runtimesMap() (map[string]interface{}, error) {
 	// Read the configuration data; the file must exist.
 	configBytes, err := ioutil.ReadFile(*config)
 	if err != nil {
@@ -140,16 +160,6 @@ func runtimeMap() (map[string]interface{}, error) {
 		// The runtimes are not a map.
 		return nil, fmt.Errorf("unexpected format: %v", rs)
 	}
-	r, ok = rs[*runtime]
-	if !ok {
-		// The expected runtime is not declared.
-		return nil, fmt.Errorf("runtime %q not found: %v", *runtime, rs)
-	}
-	rs, ok = r.(map[string]interface{})
-	if !ok {
-		// The runtime is not a map.
-		return nil, fmt.Errorf("unexpected format: %v", r)
-	}
 	return rs, nil
 }
 
@@ -0,0 +1,190 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dockerutil
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"gvisor.dev/gvisor/pkg/test/testutil"
+)
+
+// runtimeMatrix is the set of runtimes that RunMatrix runs tests against.
+var runtimeMatrix = flag.String("runtime-matrix", "", "comma-separated list of runtimes that matrix tests run against, or \"all\" for all runsc runtimes configured in Docker. If empty, only --runtime is used.")
+
+// RuntimeConfig describes a runsc runtime configured in Docker.
+type RuntimeConfig struct {
+	// Name is the name of the runtime in Docker.
+	Name string
+
+	// Platform is the platform used by the runtime.
+	Platform string
+
+	// Overlay is true if the runtime uses an overlay on the root filesystem.
+	Overlay bool
+
+	// Network is the network mode of the runtime.
+	Network string
+}
+
+// String returns a name for the configuration, suitable for subtest names.
+func (r RuntimeConfig) String() string {
+	overlay := "no-overlay"
+	if r.Overlay {
+		overlay = "overlay"
+	}
+	return fmt.Sprintf("%s/%s/%s/network-%s", r.Name, r.Platform, overlay, r.Network)
+}
+
+// available returns an error if the runtime can't be used on this host.
+func (r RuntimeConfig) available() error {
+	if r.Platform == "kvm" {
+		if _, err := os.Stat("/dev/kvm"); err != nil {
+			return fmt.Errorf("platform kvm not available: %v", err)
+		}
+	}
+	return nil
+}
+
+// parseRuntimeConfig returns the configuration of a runtime declared in the
+// Docker daemon configuration, or false if it isn't a runsc runtime.
+func parseRuntimeConfig(name string, r interface{}) (RuntimeConfig, bool) {
+	rm, ok := r.(map[string]interface{})
+	if !ok {
+		return RuntimeConfig{}, false
+	}
+	path, _ := rm["path"].(string)
+	if !strings.HasPrefix(filepath.Base(path), "runsc") {
+		return RuntimeConfig{}, false
+	}
+
+	conf := RuntimeConfig{
+		Name:     name,
+		Platform: "ptrace",
+		Network:  "sandbox",
+	}
+	args, _ := rm["runtimeArgs"].([]interface{})
+	for i := 0; i < len(args); i++ {
+		arg, _ := args[i].(string)
+		key := strings.TrimLeft(arg, "-")
+		val := ""
+		if j := strings.Index(key, "="); j >= 0 {
+			key, val = key[:j], key[j+1:]
+		} else if key != "overlay" && i+1 < len(args) {
+			// Flags with values may be passed as two arguments.
+			val, _ = args[i+1].(string)
+			if key == "platform" || key == "network" {
+				i++
+			}
+		}
+		switch key {
+		case "platform":
+			conf.Platform = val
+		case "network":
+			conf.Network = val
+		case "overlay":
+			conf.Overlay = val == "" || val == "true"
+		}
+	}
+	return conf, true
+}
+
+// MatrixRuntimes returns the runtimes that matrix tests run against, as
+// selected by the --runtime-matrix flag.
+func MatrixRuntimes() ([]RuntimeConfig, error) {
+	rs, err := runtimesMap()
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	switch *runtimeMatrix {
+	case "":
+		names = []string{*runtime}
+	case "all":
+		for name := range rs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	default:
+		names = strings.Split(*runtimeMatrix, ",")
+	}
+
+	var confs []RuntimeConfig
+	for _, name := range names {
+		r, ok := rs[name]
+		if !ok {
+			return nil, fmt.Errorf("runtime %q not found: %v", name, rs)
+		}
+		conf, ok := parseRuntimeConfig(name, r)
+		if !ok {
+			if *runtimeMatrix == "all" {
+				// Skip runtimes that aren't runsc, e.g. runc.
+				continue
+			}
+			return nil, fmt.Errorf("runtime %q is not a runsc runtime", name)
+		}
+		confs = append(confs, conf)
+	}
+	return confs, nil
+}
+
+// matrixTests maps the names of the subtests created by RunMatrix to the
+// runtime they use.
+var matrixTests sync.Map
+
+// runtimeFor returns the runtime used by containers created by logger. If
+// logger is a test within a subtest created by RunMatrix, it's the runtime
+// of the subtest. Otherwise, it's the runtime set with --runtime.
+func runtimeFor(logger testutil.Logger) string {
+	for name := logger.Name(); ; {
+		if r, ok := matrixTests.Load(name); ok {
+			return r.(string)
+		}
+		i := strings.LastIndex(name, "/")
+		if i < 0 {
+			return *runtime
+		}
+		name = name[:i]
+	}
+}
+
+// RunMatrix runs fn as a subtest for each runtime selected with
+// --runtime-matrix. Containers created with MakeContainer within the subtest
+// use its runtime. Runtimes that can't run on this host, e.g. KVM without
+// /dev/kvm, are skipped.
+func RunMatrix(t *testing.T, fn func(t *testing.T, conf RuntimeConfig)) {
+	confs, err := MatrixRuntimes()
+	if err != nil {
+		t.Fatalf("error getting runtimes: %v", err)
+	}
+	for _, conf := range confs {
+		conf := conf
+		t.Run(conf.String(), func(t *testing.T) {
+			if err := conf.available(); err != nil {
+				t.Skipf("Skipping runtime %q: %v", conf.Name, err)
+			}
+			matrixTests.Store(t.Name(), conf.Name)
+			defer matrixTests.Delete(t.Name())
+			fn(t, conf)
+		})
+	}
+}
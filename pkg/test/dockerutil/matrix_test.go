@@ -0,0 +1,89 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dockerutil
+
+import (
+	"testing"
+)
+
+func TestParseRuntimeConfig(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		runtime interface{}
+		want    RuntimeConfig
+		wantOK  bool
+	}{
+		{
+			name: "defaults",
+			runtime: map[string]interface{}{
+				"path": "/usr/local/bin/runsc",
+			},
+			want:   RuntimeConfig{Name: "defaults", Platform: "ptrace", Network: "sandbox"},
+			wantOK: true,
+		},
+		{
+			name: "flags",
+			runtime: map[string]interface{}{
+				"path":        "/tmp/runsc/runsc-kvm",
+				"runtimeArgs": []interface{}{"--debug", "--platform=kvm", "--overlay", "--network", "host"},
+			},
+			want:   RuntimeConfig{Name: "flags", Platform: "kvm", Overlay: true, Network: "host"},
+			wantOK: true,
+		},
+		{
+			name: "overlay-false",
+			runtime: map[string]interface{}{
+				"path":        "/usr/local/bin/runsc",
+				"runtimeArgs": []interface{}{"--overlay=false", "--platform", "ptrace"},
+			},
+			want:   RuntimeConfig{Name: "overlay-false", Platform: "ptrace", Network: "sandbox"},
+			wantOK: true,
+		},
+		{
+			name: "runc",
+			runtime: map[string]interface{}{
+				"path": "/usr/bin/runc",
+			},
+		},
+		{
+			name:    "invalid",
+			runtime: "runsc",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := parseRuntimeConfig(tc.name, tc.runtime)
+			if ok != tc.wantOK {
+				t.Fatalf("parseRuntimeConfig() got ok %t, want %t", ok, tc.wantOK)
+			}
+			if ok && got != tc.want {
+				t.Errorf("parseRuntimeConfig() got %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRuntimeFor(t *testing.T) {
+	if got := runtimeFor(t); got != *runtime {
+		t.Errorf("runtimeFor() outside of a matrix got %q, want %q", got, *runtime)
+	}
+
+	matrixTests.Store(t.Name(), "runsc-matrix")
+	defer matrixTests.Delete(t.Name())
+	t.Run("subtest", func(t *testing.T) {
+		if got := runtimeFor(t); got != "runsc-matrix" {
+			t.Errorf("runtimeFor() got %q, want %q", got, "runsc-matrix")
+		}
+	})
+}
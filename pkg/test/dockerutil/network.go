@@ -32,6 +32,10 @@ type Network struct {
 	Name       string
 	containers []*Container
 	Subnet     *net.IPNet
+
+	// Subnetv6 is the IPv6 subnet of the network. If set, IPv6 is enabled
+	// and the network is dual-stack.
+	Subnetv6 *net.IPNet
 }
 
 // NewNetwork sets up the struct for a Docker network. Names of networks
@@ -64,8 +68,17 @@ func (n *Network) networkCreate() types.NetworkCreate {
 		}},
 	}
 
+	enableIPv6 := false
+	if n.Subnetv6 != nil {
+		ipam.Config = append(ipam.Config, network.IPAMConfig{
+			Subnet: n.Subnetv6.String(),
+		})
+		enableIPv6 = true
+	}
+
 	return types.NetworkCreate{
 		CheckDuplicate: true,
+		EnableIPv6:     enableIPv6,
 		IPAM:           &ipam,
 	}
 }
@@ -0,0 +1,269 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dockerutil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/kr/pty"
+)
+
+// Keys that can be sent to a TTYProcess, as a terminal sends them.
+const (
+	KeyEnter     = "\r"
+	KeyCtrlC     = "\x03"
+	KeyCtrlD     = "\x04"
+	KeyCtrlZ     = "\x1a"
+	KeyBackspace = "\x7f"
+	KeyEscape    = "\x1b"
+	KeyUp        = "\x1b[A"
+	KeyDown      = "\x1b[B"
+	KeyRight     = "\x1b[C"
+	KeyLeft      = "\x1b[D"
+)
+
+// Default size of the terminal of a TTYProcess.
+const (
+	defaultTTYRows = 24
+	defaultTTYCols = 80
+)
+
+// TTYProcess is a process started with "docker exec -it", with the docker
+// client attached to a pseudo-terminal, as if it was run interactively by a
+// user. Output is kept as written to the terminal, including control
+// sequences.
+type TTYProcess struct {
+	cmd *exec.Cmd
+
+	// ptyMaster is the master side of the terminal of the docker client.
+	ptyMaster *os.File
+
+	// done is closed once the docker client exits, and exitErr is set.
+	done    chan struct{}
+	exitErr error
+
+	// notify receives a value when output is read or reading stops.
+	notify chan struct{}
+
+	mu sync.Mutex
+
+	// output is all the output read so far.
+	output bytes.Buffer
+
+	// consumed is the length of output already matched by Expect.
+	consumed int
+
+	// readErr is set when reading stops, usually because the docker client
+	// exited and the terminal was closed.
+	readErr error
+}
+
+// ExecTTY starts a process inside the container with "docker exec -it". The
+// terminal is 80x24, use Resize to change it. The process is killed by Close.
+func (c *Container) ExecTTY(ctx context.Context, opts ExecOpts, args ...string) (*TTYProcess, error) {
+	cmdArgs := []string{"exec", "--interactive", "--tty"}
+	for _, e := range append(opts.Env, fmt.Sprintf("RUNSC_TEST_NAME=%s", c.Name)) {
+		cmdArgs = append(cmdArgs, "--env", e)
+	}
+	if opts.Privileged {
+		cmdArgs = append(cmdArgs, "--privileged")
+	}
+	if opts.User != "" {
+		cmdArgs = append(cmdArgs, "--user", opts.User)
+	}
+	if opts.WorkDir != "" {
+		cmdArgs = append(cmdArgs, "--workdir", opts.WorkDir)
+	}
+	cmdArgs = append(cmdArgs, c.id)
+	cmdArgs = append(cmdArgs, args...)
+
+	cmd := exec.CommandContext(ctx, "docker", cmdArgs...)
+	c.logger.Logf("Running interactively: %s", strings.Join(cmd.Args, " "))
+	p, err := startTTY(cmd)
+	if err != nil {
+		return nil, fmt.Errorf("docker exec with TTY failed: %v", err)
+	}
+	return p, nil
+}
+
+// startTTY starts cmd with its stdio connected to a new terminal.
+func startTTY(cmd *exec.Cmd) (*TTYProcess, error) {
+	ptyMaster, err := pty.StartWithSize(cmd, &pty.Winsize{Rows: defaultTTYRows, Cols: defaultTTYCols})
+	if err != nil {
+		return nil, err
+	}
+	p := &TTYProcess{
+		cmd:       cmd,
+		ptyMaster: ptyMaster,
+		done:      make(chan struct{}),
+		notify:    make(chan struct{}, 1),
+	}
+	go p.read()
+	go func() {
+		p.exitErr = cmd.Wait()
+		close(p.done)
+	}()
+	return p, nil
+}
+
+// read reads the output of the terminal until it's closed.
+func (p *TTYProcess) read() {
+	buf := make([]byte, 4096)
+	for {
+		n, err := p.ptyMaster.Read(buf)
+		p.mu.Lock()
+		p.output.Write(buf[:n])
+		if err != nil {
+			p.readErr = err
+		}
+		p.mu.Unlock()
+
+		select {
+		case p.notify <- struct{}{}:
+		default:
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Write writes s to the terminal, as if typed by the user. It can contain
+// keys, e.g. "ls" + KeyEnter.
+func (p *TTYProcess) Write(s string) error {
+	_, err := p.ptyMaster.Write([]byte(s))
+	return err
+}
+
+// Resize changes the size of the terminal. The docker client forwards the new
+// size to the process.
+func (p *TTYProcess) Resize(rows, cols uint16) error {
+	return pty.Setsize(p.ptyMaster, &pty.Winsize{Rows: rows, Cols: cols})
+}
+
+// Output returns all the output written to the terminal so far, including
+// output already matched by Expect.
+func (p *TTYProcess) Output() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.output.String()
+}
+
+// expect waits for match to find something in the output that wasn't
+// consumed yet. match returns the end of the match in the output passed to
+// it, or -1. The output up to the end of the match is consumed and returned.
+func (p *TTYProcess) expect(ctx context.Context, desc string, match func([]byte) int) (string, error) {
+	for {
+		p.mu.Lock()
+		pending := p.output.Bytes()[p.consumed:]
+		if end := match(pending); end >= 0 {
+			got := string(pending[:end])
+			p.consumed += end
+			p.mu.Unlock()
+			return got, nil
+		}
+		readErr := p.readErr
+		p.mu.Unlock()
+
+		if readErr != nil {
+			return string(pending), fmt.Errorf("terminal closed before %s was found (%v), output: %q", desc, readErr, pending)
+		}
+		select {
+		case <-p.notify:
+		case <-ctx.Done():
+			return string(pending), fmt.Errorf("%s not found: %w, output: %q", desc, ctx.Err(), pending)
+		}
+	}
+}
+
+// Expect waits for want to be written to the terminal, and returns the output
+// up to and including it. want can contain control sequences, e.g. "\x1b[1m"
+// to make text bold. Output returned by Expect isn't considered again by
+// following calls.
+func (p *TTYProcess) Expect(ctx context.Context, want string) (string, error) {
+	return p.expect(ctx, fmt.Sprintf("%q", want), func(b []byte) int {
+		if i := bytes.Index(b, []byte(want)); i >= 0 {
+			return i + len(want)
+		}
+		return -1
+	})
+}
+
+// ExpectRegexp is like Expect, but waits for output matching re. It returns
+// the submatches, the first one being the whole match.
+func (p *TTYProcess) ExpectRegexp(ctx context.Context, re *regexp.Regexp) ([]string, error) {
+	var matches []string
+	_, err := p.expect(ctx, re.String(), func(b []byte) int {
+		loc := re.FindSubmatchIndex(b)
+		if loc == nil {
+			return -1
+		}
+		matches = make([]string, len(loc)/2)
+		for i := range matches {
+			if loc[2*i] >= 0 {
+				matches[i] = string(b[loc[2*i]:loc[2*i+1]])
+			}
+		}
+		return loc[1]
+	})
+	return matches, err
+}
+
+// Wait waits for the docker client to exit and returns the exit status of the
+// process.
+func (p *TTYProcess) Wait(ctx context.Context) (int, error) {
+	select {
+	case <-p.done:
+	case <-ctx.Done():
+		return -1, ctx.Err()
+	}
+	var exitErr *exec.ExitError
+	if errors.As(p.exitErr, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+	if p.exitErr != nil {
+		return -1, p.exitErr
+	}
+	return 0, nil
+}
+
+// Close kills the docker client if it's still running, and closes the
+// terminal.
+func (p *TTYProcess) Close() error {
+	select {
+	case <-p.done:
+	default:
+		p.cmd.Process.Kill()
+		<-p.done
+	}
+	return p.ptyMaster.Close()
+}
+
+// controlSequence matches ANSI escape sequences and carriage returns.
+var controlSequence = regexp.MustCompile("\x1b\\[[0-9;?]*[ -/]*[@-~]|\x1b[@-Z\\\\-_]|\r")
+
+// StripControlSequences removes ANSI escape sequences, e.g. to change colors
+// or move the cursor, and carriage returns from terminal output.
+func StripControlSequences(s string) string {
+	return controlSequence.ReplaceAllString(s, "")
+}
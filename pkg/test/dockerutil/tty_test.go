@@ -0,0 +1,87 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dockerutil
+
+import (
+	"context"
+	"os/exec"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestTTYProcess(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// cat echoes back lines typed in the terminal, and the terminal echoes
+	// control characters, e.g. "^C", since echoctl is on by default.
+	p, err := startTTY(exec.Command("/bin/sh", "-c", `printf '\033[1mready\033[0m\n'; stty size; exec cat`))
+	if err != nil {
+		t.Fatalf("startTTY failed: %v", err)
+	}
+	defer p.Close()
+
+	if _, err := p.Expect(ctx, "\x1b[1mready\x1b[0m"); err != nil {
+		t.Fatalf("Expect failed: %v", err)
+	}
+	m, err := p.ExpectRegexp(ctx, regexp.MustCompile(`(\d+) (\d+)`))
+	if err != nil {
+		t.Fatalf("ExpectRegexp failed: %v", err)
+	}
+	if m[1] != "24" || m[2] != "80" {
+		t.Errorf("got terminal size %sx%s, want 24x80", m[1], m[2])
+	}
+
+	if err := p.Write("hello" + KeyEnter); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	// Once echoed by the terminal, once written by cat.
+	for i := 0; i < 2; i++ {
+		if _, err := p.Expect(ctx, "hello\r\n"); err != nil {
+			t.Fatalf("Expect #%d failed: %v", i, err)
+		}
+	}
+
+	if err := p.Write(KeyCtrlC); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if _, err := p.Expect(ctx, "^C"); err != nil {
+		t.Fatalf("Expect failed: %v", err)
+	}
+	code, err := p.Wait(ctx)
+	if err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+	if code == 0 {
+		t.Errorf("cat exited with status 0 after SIGINT")
+	}
+}
+
+func TestStripControlSequences(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want string
+	}{
+		{in: "plain", want: "plain"},
+		{in: "\x1b[1;31mred\x1b[0m\r\n", want: "red\n"},
+		{in: "\x1b[?2004h$ ls\x1b[K", want: "$ ls"},
+		{in: "\x1b[2J\x1b[Hclear", want: "clear"},
+	} {
+		if got := StripControlSequences(tc.in); got != tc.want {
+			t.Errorf("StripControlSequences(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
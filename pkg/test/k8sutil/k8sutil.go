@@ -0,0 +1,201 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package k8sutil contains utility functions for running tests against a
+// Kubernetes cluster, principally via the kubectl command line tool. The
+// cluster must have a RuntimeClass that runs pods with runsc, see
+// tools/installers/kind.sh for how to set one up locally.
+package k8sutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	"gvisor.dev/gvisor/pkg/test/testutil"
+)
+
+var (
+	kubectl      = flag.String("kubectl", "kubectl", "kubectl binary to use")
+	kubeContext  = flag.String("kube-context", "", "kubeconfig context of the cluster to test, empty for the current context")
+	runtimeClass = flag.String("runtime-class", "gvisor", "RuntimeClass that runs pods with runsc")
+)
+
+// RuntimeClass returns the name of the RuntimeClass pods are run with.
+func RuntimeClass() string {
+	return *runtimeClass
+}
+
+// Kubectl runs kubectl commands against a namespace of the cluster.
+type Kubectl struct {
+	logger    testutil.Logger
+	namespace string
+}
+
+// NewKubectl creates a new namespace in the cluster and returns a Kubectl that
+// runs commands in it. Each test should use its own namespace, so that tests
+// can't interfere with each other and everything they create is removed by
+// CleanUp.
+func NewKubectl(logger testutil.Logger) (*Kubectl, error) {
+	k := &Kubectl{
+		logger:    logger,
+		namespace: strings.ToLower(testutil.RandomID("gvisor-e2e")),
+	}
+	if _, err := k.run(nil, "create", "namespace", k.namespace); err != nil {
+		return nil, fmt.Errorf("creating namespace: %v", err)
+	}
+	return k, nil
+}
+
+// Namespace returns the namespace commands are run in.
+func (k *Kubectl) Namespace() string {
+	return k.namespace
+}
+
+// CleanUp deletes the namespace and everything in it. It doesn't wait for
+// the deletion to complete.
+func (k *Kubectl) CleanUp() {
+	if _, err := k.run(nil, "delete", "namespace", k.namespace, "--wait=false"); err != nil {
+		k.logger.Logf("deleting namespace %q: %v", k.namespace, err)
+	}
+}
+
+// Apply creates or updates the objects in the given manifest, in YAML or JSON.
+// It corresponds to `kubectl apply`.
+func (k *Kubectl) Apply(manifest string) error {
+	if _, err := k.run(strings.NewReader(manifest), "apply", "-f", "-"); err != nil {
+		return fmt.Errorf("apply failed: %v", err)
+	}
+	return nil
+}
+
+// CreatePod creates the given pod.
+func (k *Kubectl) CreatePod(pod *Pod) error {
+	manifest, err := json.Marshal(pod.object())
+	if err != nil {
+		return err
+	}
+	return k.Apply(string(manifest))
+}
+
+// Delete deletes an object, e.g. "pod/name". It corresponds to `kubectl
+// delete`.
+func (k *Kubectl) Delete(object string, timeout time.Duration) error {
+	if _, err := k.run(nil, "delete", object, fmt.Sprintf("--timeout=%s", timeout)); err != nil {
+		return fmt.Errorf("delete failed: %v", err)
+	}
+	return nil
+}
+
+// Wait waits for the condition on an object, e.g. "condition=Ready" on
+// "pod/name". It corresponds to `kubectl wait`.
+func (k *Kubectl) Wait(object, condition string, timeout time.Duration) error {
+	if _, err := k.run(nil, "wait", object, "--for", condition, fmt.Sprintf("--timeout=%s", timeout)); err != nil {
+		return fmt.Errorf("waiting for %s on %s: %v", condition, object, err)
+	}
+	return nil
+}
+
+// WaitForPhase waits for the pod to reach the given phase, e.g. "Succeeded".
+func (k *Kubectl) WaitForPhase(name, phase string, timeout time.Duration) (*PodStatus, error) {
+	var status *PodStatus
+	err := testutil.Poll(func() error {
+		var err error
+		status, err = k.Status(name)
+		if err != nil {
+			return err
+		}
+		if status.Phase == phase {
+			return nil
+		}
+		if status.Phase == "Failed" || (status.Phase == "Succeeded" && phase != "Succeeded") {
+			// Terminal phase, it will never change.
+			return backoff.Permanent(fmt.Errorf("pod %q is %s, want %s", name, status.Phase, phase))
+		}
+		return fmt.Errorf("pod %q is %s, want %s", name, status.Phase, phase)
+	}, timeout)
+	return status, err
+}
+
+// Exec execs a program inside a container of the pod. It corresponds to
+// `kubectl exec`.
+func (k *Kubectl) Exec(pod, container string, args ...string) (string, error) {
+	a := []string{"exec", pod, "-c", container, "--"}
+	a = append(a, args...)
+	out, err := k.run(nil, a...)
+	if err != nil {
+		return out, fmt.Errorf("exec failed: %v", err)
+	}
+	return out, nil
+}
+
+// Logs retrieves the logs of a container of the pod. It corresponds to
+// `kubectl logs`.
+func (k *Kubectl) Logs(pod, container string, args ...string) (string, error) {
+	a := []string{"logs", pod, "-c", container}
+	a = append(a, args...)
+	out, err := k.run(nil, a...)
+	if err != nil {
+		return "", fmt.Errorf("logs failed: %v", err)
+	}
+	return out, nil
+}
+
+// Status returns the status of the pod.
+func (k *Kubectl) Status(name string) (*PodStatus, error) {
+	out, err := k.run(nil, "get", "pod", name, "-o", "json")
+	if err != nil {
+		return nil, err
+	}
+	var pod struct {
+		Status PodStatus `json:"status"`
+	}
+	if err := json.Unmarshal([]byte(out), &pod); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal JSON: %v, %s", err, out)
+	}
+	return &pod.Status, nil
+}
+
+// run runs kubectl with the given args in the namespace. The output doesn't
+// include stderr, so that it can be parsed, but stderr is logged and included
+// in the error.
+func (k *Kubectl) run(stdin *strings.Reader, args ...string) (string, error) {
+	fullArgs := []string{*kubectl, "--namespace", k.namespace}
+	if *kubeContext != "" {
+		fullArgs = append(fullArgs, "--context", *kubeContext)
+	}
+	fullArgs = append(fullArgs, args...)
+	cmd := testutil.Command(k.logger, fullArgs...)
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if stderr.Len() > 0 {
+		k.logger.Logf("stderr: %s", stderr.String())
+	}
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); ok {
+			err = fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+		}
+		return string(out), err
+	}
+	return string(out), nil
+}
@@ -0,0 +1,182 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package k8sutil
+
+import (
+	"gvisor.dev/gvisor/pkg/test/testutil"
+)
+
+// Pod describes a pod to create. Only the fields needed for testing are
+// supported; they are translated to a manifest by object.
+type Pod struct {
+	// Name is the name of the pod.
+	Name string
+
+	// Containers are the containers of the pod.
+	Containers []Container
+
+	// RestartPolicy is the restart policy of the pod. Defaults to "Never", so
+	// that failures aren't hidden by restarts.
+	RestartPolicy string
+}
+
+// Container describes a container of a Pod.
+type Container struct {
+	// Name is the name of the container.
+	Name string
+
+	// Image is the name of the test image, e.g. "basic/alpine". See
+	// testutil.ImageByName.
+	Image string
+
+	// Command is the command to run, overriding the image's entrypoint.
+	Command []string
+
+	// Port is a TCP port the container listens on, or zero.
+	Port int
+
+	// ReadinessProbe and LivenessProbe are optional probes.
+	ReadinessProbe *Probe
+	LivenessProbe  *Probe
+}
+
+// Probe describes a readiness or liveness probe. Exactly one of Exec and
+// HTTPPort must be set.
+type Probe struct {
+	// Exec is a command run in the container, which succeeds if it exits with
+	// status 0.
+	Exec []string
+
+	// HTTPPort is a port on which GET requests to HTTPPath are made, which
+	// succeed if they return a 2xx or 3xx status.
+	HTTPPort int
+	HTTPPath string
+
+	// PeriodSeconds and FailureThreshold are passed through. Zero selects
+	// the defaults.
+	PeriodSeconds    int
+	FailureThreshold int
+}
+
+// object returns the manifest of the pod, as expected by kubectl.
+func (p *Pod) object() map[string]interface{} {
+	restartPolicy := p.RestartPolicy
+	if restartPolicy == "" {
+		restartPolicy = "Never"
+	}
+	var containers []interface{}
+	for _, c := range p.Containers {
+		containers = append(containers, c.object())
+	}
+	return map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Pod",
+		"metadata": map[string]interface{}{
+			"name": p.Name,
+		},
+		"spec": map[string]interface{}{
+			"runtimeClassName": RuntimeClass(),
+			"restartPolicy":    restartPolicy,
+			"containers":       containers,
+			// Pods are deleted in tests, there is no need to wait.
+			"terminationGracePeriodSeconds": 1,
+		},
+	}
+}
+
+func (c *Container) object() map[string]interface{} {
+	o := map[string]interface{}{
+		"name":  c.Name,
+		"image": testutil.ImageByName(c.Image),
+		// Images are preloaded in the cluster's nodes.
+		"imagePullPolicy": "Never",
+	}
+	if len(c.Command) > 0 {
+		o["command"] = c.Command
+	}
+	if c.Port != 0 {
+		o["ports"] = []interface{}{
+			map[string]interface{}{"containerPort": c.Port},
+		}
+	}
+	if c.ReadinessProbe != nil {
+		o["readinessProbe"] = c.ReadinessProbe.object()
+	}
+	if c.LivenessProbe != nil {
+		o["livenessProbe"] = c.LivenessProbe.object()
+	}
+	return o
+}
+
+func (p *Probe) object() map[string]interface{} {
+	o := make(map[string]interface{})
+	if len(p.Exec) > 0 {
+		o["exec"] = map[string]interface{}{"command": p.Exec}
+	} else {
+		path := p.HTTPPath
+		if path == "" {
+			path = "/"
+		}
+		o["httpGet"] = map[string]interface{}{
+			"port": p.HTTPPort,
+			"path": path,
+		}
+	}
+	if p.PeriodSeconds != 0 {
+		o["periodSeconds"] = p.PeriodSeconds
+	}
+	if p.FailureThreshold != 0 {
+		o["failureThreshold"] = p.FailureThreshold
+	}
+	return o
+}
+
+// PodStatus is a minimal copy of the status of a pod, as returned by the
+// Kubernetes API. It only contains fields needed for testing.
+type PodStatus struct {
+	Phase             string            `json:"phase"`
+	PodIP             string            `json:"podIP"`
+	ContainerStatuses []ContainerStatus `json:"containerStatuses"`
+}
+
+// ContainerStatus is the status of a container of a pod.
+type ContainerStatus struct {
+	Name         string         `json:"name"`
+	Ready        bool           `json:"ready"`
+	RestartCount int            `json:"restartCount"`
+	State        ContainerState `json:"state"`
+}
+
+// ContainerState is the state of a container. Only one of the fields is set.
+type ContainerState struct {
+	Running *struct {
+		StartedAt string `json:"startedAt"`
+	} `json:"running"`
+	Terminated *struct {
+		ExitCode int    `json:"exitCode"`
+		Reason   string `json:"reason"`
+	} `json:"terminated"`
+}
+
+// Container returns the status of the named container, or nil if there is
+// none.
+func (s *PodStatus) Container(name string) *ContainerStatus {
+	for i := range s.ContainerStatuses {
+		if s.ContainerStatuses[i].Name == name {
+			return &s.ContainerStatuses[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testutil
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// The helpers below inject failures into the processes of a running sandbox,
+// e.g. the gofer or the sandbox itself, so that recovery paths can be tested.
+// Faults in 9P operations are injected by the gofer itself, see
+// pkg/p9/faultinject and config.TestOnlyGoferFaults.
+
+// chaosTimeout is how long the helpers wait for a fault to take effect.
+const chaosTimeout = 10 * time.Second
+
+// processState returns the state of the process, e.g. 'R' or 'T', as found in
+// /proc/[pid]/stat.
+func processState(pid int) (byte, error) {
+	data, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0, err
+	}
+	// The command name may contain spaces and parentheses, the state is the
+	// first field after the last parenthesis.
+	stat := string(data)
+	i := strings.LastIndex(stat, ")")
+	if i < 0 || i+2 >= len(stat) {
+		return 0, fmt.Errorf("invalid /proc/%d/stat: %q", pid, stat)
+	}
+	return stat[i+2], nil
+}
+
+// KillProcess kills the process with SIGKILL, e.g. the gofer of a container,
+// and waits for it to be dead. The process isn't reaped.
+func KillProcess(pid int) error {
+	if err := unix.Kill(pid, unix.SIGKILL); err != nil {
+		return fmt.Errorf("kill(%d, SIGKILL): %v", pid, err)
+	}
+	return Poll(func() error {
+		state, err := processState(pid)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if state != 'Z' && state != 'X' {
+			return fmt.Errorf("process %d is still alive, state: %c", pid, state)
+		}
+		return nil
+	}, chaosTimeout)
+}
+
+// StopProcess stops all threads of the process with SIGSTOP, e.g. the sandbox,
+// and waits for it to be stopped. The returned function resumes the process.
+func StopProcess(pid int) (func() error, error) {
+	if err := unix.Kill(pid, unix.SIGSTOP); err != nil {
+		return nil, fmt.Errorf("kill(%d, SIGSTOP): %v", pid, err)
+	}
+	resume := func() error {
+		if err := unix.Kill(pid, unix.SIGCONT); err != nil {
+			return fmt.Errorf("kill(%d, SIGCONT): %v", pid, err)
+		}
+		return nil
+	}
+	err := Poll(func() error {
+		state, err := processState(pid)
+		if err != nil {
+			return err
+		}
+		if state != 'T' {
+			return fmt.Errorf("process %d isn't stopped yet, state: %c", pid, state)
+		}
+		return nil
+	}, chaosTimeout)
+	if err != nil {
+		resume()
+		return nil, err
+	}
+	return resume, nil
+}
+
+// unixSocketInode returns the inode of the UNIX socket bound to addr in the
+// network namespace of the process. Abstract addresses start with a NUL byte.
+func unixSocketInode(pid int, addr string) (uint64, error) {
+	// /proc/net/unix shows abstract addresses with a leading '@'.
+	if strings.HasPrefix(addr, "\x00") {
+		addr = "@" + addr[1:]
+	}
+	f, err := os.Open(fmt.Sprintf("/proc/%d/net/unix", pid))
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	// Format: Num RefCount Protocol Flags Type St Inode [Path].
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) < 8 || fields[7] != addr {
+			continue
+		}
+		return strconv.ParseUint(fields[6], 10, 64)
+	}
+	if err := s.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("no UNIX socket bound to %q in process %d", addr, pid)
+}
+
+// socketFD returns the FD of the socket with the given inode in the process.
+func socketFD(pid int, inode uint64) (int, error) {
+	dir := fmt.Sprintf("/proc/%d/fd", pid)
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+	want := fmt.Sprintf("socket:[%d]", inode)
+	for _, e := range entries {
+		link, err := os.Readlink(filepath.Join(dir, e.Name()))
+		if err != nil || link != want {
+			continue
+		}
+		return strconv.Atoi(e.Name())
+	}
+	return 0, fmt.Errorf("socket %d not found in process %d", inode, pid)
+}
+
+// DropSocket shuts down the listening UNIX socket bound to addr in the
+// process, e.g. the control socket of the sandbox, which is returned by
+// boot.ControlSocketAddr. New connections to the socket are refused and the
+// process can't accept them anymore, while established connections keep
+// working. It requires pidfd_getfd(2), from Linux 5.6, and permission to
+// ptrace the process.
+func DropSocket(pid int, addr string) error {
+	inode, err := unixSocketInode(pid, addr)
+	if err != nil {
+		return err
+	}
+	targetFD, err := socketFD(pid, inode)
+	if err != nil {
+		return err
+	}
+	pidFD, err := unix.PidfdOpen(pid, 0)
+	if err != nil {
+		return fmt.Errorf("pidfd_open(%d): %v", pid, err)
+	}
+	defer unix.Close(pidFD)
+	fd, err := unix.PidfdGetfd(pidFD, targetFD, 0)
+	if err != nil {
+		return fmt.Errorf("pidfd_getfd(%d, %d): %v", pid, targetFD, err)
+	}
+	defer unix.Close(fd)
+
+	// The duplicated FD refers to the same socket, shutting it down affects
+	// the process too.
+	if err := unix.Shutdown(fd, unix.SHUT_RDWR); err != nil {
+		return fmt.Errorf("shutdown(%q): %v", addr, err)
+	}
+	return nil
+}
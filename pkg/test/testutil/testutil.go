@@ -28,6 +28,7 @@ import (
 	"log"
 	"math"
 	"math/rand"
+	"net"
 	"net/http"
 	"os"
 	"os/exec"
@@ -381,7 +382,7 @@ func WaitForHTTP(ip string, port int, timeout time.Duration) error {
 			// Calculate timeout to be able to do minimum 5 attempts.
 			Timeout: timeout / 5,
 		}
-		url := fmt.Sprintf("http://%s:%d/", ip, port)
+		url := fmt.Sprintf("http://%s/", net.JoinHostPort(ip, strconv.Itoa(port)))
 		resp, err := c.Get(url)
 		if err != nil {
 			log.Printf("Waiting %s: %v", url, err)
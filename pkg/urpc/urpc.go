@@ -289,8 +289,9 @@ func (s *Server) lookup(method string) (registeredMethod, bool) {
 	return rm, ok
 }
 
-// handleOne handles a single call.
-func (s *Server) handleOne(client *unet.Socket) error {
+// handleOne handles a single call. If authorize isn't nil, it's called with the
+// name of the method before the call.
+func (s *Server) handleOne(client *unet.Socket, authorize func(method string) error) error {
 	// Unmarshal the call.
 	var c serverCall
 	newFs, err := unmarshal(client, &c)
@@ -315,6 +316,13 @@ func (s *Server) handleOne(client *unet.Socket) error {
 	}
 	defer s.clientEndRequest(client)
 
+	// Check that the client is allowed to make the call.
+	if authorize != nil {
+		if err := authorize(c.Method); err != nil {
+			return marshal(client, &callResult{Err: err.Error()}, nil)
+		}
+	}
+
 	// Lookup the method.
 	rm, ok := s.lookup(c.Method)
 	if !ok {
@@ -427,10 +435,10 @@ func (s *Server) clientUnregister(client *unet.Socket) {
 }
 
 // handleRegistered handles calls from a registered client.
-func (s *Server) handleRegistered(client *unet.Socket) error {
+func (s *Server) handleRegistered(client *unet.Socket, authorize func(method string) error) error {
 	for {
 		// Handle one call.
-		if err := s.handleOne(client); err != nil {
+		if err := s.handleOne(client, authorize); err != nil {
 			// Client is dead.
 			return err
 		}
@@ -441,16 +449,23 @@ func (s *Server) handleRegistered(client *unet.Socket) error {
 func (s *Server) Handle(client *unet.Socket) error {
 	s.clientRegister(client)
 	defer s.clientUnregister(client)
-	return s.handleRegistered(client)
+	return s.handleRegistered(client, nil)
 }
 
 // StartHandling creates a goroutine that handles a single client over a
 // connection.
 func (s *Server) StartHandling(client *unet.Socket) {
+	s.StartHandlingAuthorized(client, nil)
+}
+
+// StartHandlingAuthorized is like StartHandling, but calls authorize with the
+// name of the method before each call from the client. If it returns an error,
+// the method isn't called and the error is returned to the client instead.
+func (s *Server) StartHandlingAuthorized(client *unet.Socket, authorize func(method string) error) {
 	s.clientRegister(client)
 	go func() { // S/R-SAFE: out of scope
 		defer s.clientUnregister(client)
-		s.handleRegistered(client)
+		s.handleRegistered(client, authorize)
 	}()
 }
 
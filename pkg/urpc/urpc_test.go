@@ -208,3 +208,30 @@ func TestTooManyFiles(t *testing.T) {
 		t.Errorf("expected too many files, got %v", err.Error())
 	}
 }
+
+func TestAuthorizer(t *testing.T) {
+	serverSock, clientSock, err := unet.SocketPair(false)
+	if err != nil {
+		t.Fatalf("error creating socket pair: %v", err)
+	}
+	s := NewServer()
+	s.Register(test{})
+	s.StartHandlingAuthorized(serverSock, func(method string) error {
+		if method == "test.Err" {
+			return errors.New("denied")
+		}
+		return nil
+	})
+	c := NewClient(clientSock)
+	defer c.Close()
+
+	var r testResult
+	if err := c.Call("test.Err", &testArg{}, &r); err == nil || err.Error() != "denied" {
+		t.Errorf("denied call got error %v, want denied", err)
+	}
+	if err := c.Call("test.Func", &testArg{StringArg: "hello"}, &r); err != nil {
+		t.Errorf("allowed call failed: %v", err)
+	} else if r.StringResult != "hello" {
+		t.Errorf("unexpected result, got %v expected hello", r.StringResult)
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package xdp
+
+import (
+	"runtime"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// bpfInsn is struct bpf_insn, an eBPF instruction.
+type bpfInsn struct {
+	code uint8
+	// regs holds the destination register in its low 4 bits and the source
+	// register in its high 4 bits.
+	regs uint8
+	off  int16
+	imm  int32
+}
+
+// bpfMapCreateAttr is the BPF_MAP_CREATE variant of union bpf_attr.
+type bpfMapCreateAttr struct {
+	mapType    uint32
+	keySize    uint32
+	valueSize  uint32
+	maxEntries uint32
+	mapFlags   uint32
+}
+
+// bpfMapElemAttr is the BPF_MAP_*_ELEM variant of union bpf_attr.
+type bpfMapElemAttr struct {
+	mapFD uint32
+	_     uint32
+	key   uint64
+	value uint64
+	flags uint64
+}
+
+// bpfProgLoadAttr is the BPF_PROG_LOAD variant of union bpf_attr.
+type bpfProgLoadAttr struct {
+	progType    uint32
+	insnCnt     uint32
+	insns       uint64
+	license     uint64
+	logLevel    uint32
+	logSize     uint32
+	logBuf      uint64
+	kernVersion uint32
+	progFlags   uint32
+}
+
+// Constants from linux/bpf.h.
+const (
+	bpfPseudoMapFD       = 1
+	bpfFuncRedirectMap   = 51
+	xdpPass              = 2
+	xdpMDRXQueueIndexOff = 16
+)
+
+// redirectProgram returns the instructions of an XDP program redirecting all
+// packets to the AF_XDP socket bound to their RX queue in the XSKMAP mapFD.
+// Packets of queues without a socket are passed to the host network stack.
+//
+// It's the equivalent of:
+//
+//   return bpf_redirect_map(&xsks_map, ctx->rx_queue_index, XDP_PASS);
+func redirectProgram(mapFD int) []bpfInsn {
+	return []bpfInsn{
+		// r2 = ctx->rx_queue_index
+		{code: unix.BPF_LDX | unix.BPF_MEM | unix.BPF_W, regs: 1<<4 | 2, off: xdpMDRXQueueIndexOff},
+		// r1 = map, on two instructions.
+		{code: unix.BPF_LD | unix.BPF_DW | unix.BPF_IMM, regs: bpfPseudoMapFD<<4 | 1, imm: int32(mapFD)},
+		{},
+		// r3 = XDP_PASS
+		{code: unix.BPF_ALU64 | unix.BPF_MOV | unix.BPF_K, regs: 3, imm: xdpPass},
+		// r0 = bpf_redirect_map(r1, r2, r3)
+		{code: unix.BPF_JMP | unix.BPF_CALL, imm: bpfFuncRedirectMap},
+		// return r0
+		{code: unix.BPF_JMP | unix.BPF_EXIT},
+	}
+}
+
+func bpf(cmd uintptr, attr unsafe.Pointer, size uintptr) (int, error) {
+	fd, _, errno := unix.Syscall(unix.SYS_BPF, cmd, uintptr(attr), size)
+	if errno != 0 {
+		return -1, errno
+	}
+	return int(fd), nil
+}
+
+// NewXSKMap creates an XSKMAP, which maps the queues of a device to the AF_XDP
+// sockets bound to them, for queues 0 to numQueues-1. It returns the fd of the
+// map.
+func NewXSKMap(numQueues uint32) (int, error) {
+	attr := bpfMapCreateAttr{
+		mapType:    unix.BPF_MAP_TYPE_XSKMAP,
+		keySize:    4,
+		valueSize:  4,
+		maxEntries: numQueues,
+	}
+	return bpf(unix.BPF_MAP_CREATE, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+}
+
+// UpdateXSKMap sets the socket of queue queueID in the XSKMAP mapFD to sockFD.
+// The socket must be bound to the queue.
+func UpdateXSKMap(mapFD int, queueID uint32, sockFD int) error {
+	value := uint32(sockFD)
+	attr := bpfMapElemAttr{
+		mapFD: uint32(mapFD),
+		key:   uint64(uintptr(unsafe.Pointer(&queueID))),
+		value: uint64(uintptr(unsafe.Pointer(&value))),
+	}
+	_, err := bpf(unix.BPF_MAP_UPDATE_ELEM, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	runtime.KeepAlive(&queueID)
+	runtime.KeepAlive(&value)
+	return err
+}
+
+// LoadRedirectProgram loads an XDP program redirecting the packets of each
+// queue of a device to the AF_XDP socket of the queue in the XSKMAP mapFD, and
+// returns its fd. The program must then be attached to the device, e.g. with
+// netlink.LinkSetXdpFd.
+func LoadRedirectProgram(mapFD int) (int, error) {
+	insns := redirectProgram(mapFD)
+	license := []byte("Apache-2.0\x00")
+	attr := bpfProgLoadAttr{
+		progType: unix.BPF_PROG_TYPE_XDP,
+		insnCnt:  uint32(len(insns)),
+		insns:    uint64(uintptr(unsafe.Pointer(&insns[0]))),
+		license:  uint64(uintptr(unsafe.Pointer(&license[0]))),
+	}
+	fd, err := bpf(unix.BPF_PROG_LOAD, unsafe.Pointer(&attr), unsafe.Sizeof(attr))
+	runtime.KeepAlive(insns)
+	runtime.KeepAlive(license)
+	return fd, err
+}
@@ -0,0 +1,178 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package xdp
+
+import (
+	"sync/atomic"
+
+	"golang.org/x/sys/unix"
+)
+
+// sizeofAddr is the size of the descriptors of the fill and completion rings,
+// which are UMEM addresses.
+const sizeofAddr = 8
+
+// ring holds the state common to all queues. The producer and consumer
+// indices are shared with the kernel, and only ever increase: descriptor i is
+// at i&mask. Each side keeps a cached copy of the index of the other side, to
+// avoid accessing shared memory when possible.
+type ring struct {
+	producer *uint32
+	consumer *uint32
+	mask     uint32
+
+	cachedProducer uint32
+	cachedConsumer uint32
+}
+
+func (r *ring) init(producer, consumer *uint32, n uint32) {
+	r.producer = producer
+	r.consumer = consumer
+	r.mask = n - 1
+	r.cachedProducer = atomic.LoadUint32(producer)
+	r.cachedConsumer = atomic.LoadUint32(consumer)
+}
+
+// free returns the number of descriptors that can be produced, based on the
+// cached consumer index.
+func (r *ring) free() uint32 {
+	return r.mask + 1 - (r.cachedProducer - r.cachedConsumer)
+}
+
+// reserve reserves n descriptors for the producer, and returns the index of
+// the first one.
+func (r *ring) reserve(n uint32) (uint32, bool) {
+	if r.free() < n {
+		r.cachedConsumer = atomic.LoadUint32(r.consumer)
+		if r.free() < n {
+			return 0, false
+		}
+	}
+	index := r.cachedProducer
+	r.cachedProducer += n
+	return index, true
+}
+
+// notify makes the reserved descriptors available to the consumer.
+func (r *ring) notify() {
+	atomic.StoreUint32(r.producer, r.cachedProducer)
+}
+
+// peek returns the number of descriptors available to the consumer, and the
+// index of the first one.
+func (r *ring) peek() (uint32, uint32) {
+	n := r.cachedProducer - r.cachedConsumer
+	if n == 0 {
+		r.cachedProducer = atomic.LoadUint32(r.producer)
+		n = r.cachedProducer - r.cachedConsumer
+	}
+	return n, r.cachedConsumer
+}
+
+// release gives n consumed descriptors back to the producer.
+func (r *ring) release(n uint32) {
+	r.cachedConsumer += n
+	atomic.StoreUint32(r.consumer, r.cachedConsumer)
+}
+
+// FillQueue gives frames to the kernel to receive packets into.
+type FillQueue struct {
+	ring
+	descs []uint64
+}
+
+// Reserve reserves n descriptors, and returns the index of the first one.
+func (q *FillQueue) Reserve(n uint32) (uint32, bool) {
+	return q.reserve(n)
+}
+
+// Set sets the reserved descriptor at index to the address of a frame.
+func (q *FillQueue) Set(index uint32, addr uint64) {
+	q.descs[index&q.mask] = addr
+}
+
+// Notify gives the reserved descriptors to the kernel.
+func (q *FillQueue) Notify() {
+	q.notify()
+}
+
+// RXQueue holds the packets received by the kernel.
+type RXQueue struct {
+	ring
+	descs []unix.XDPDesc
+}
+
+// Peek returns the number of received packets, and the index of the first
+// one.
+func (q *RXQueue) Peek() (uint32, uint32) {
+	return q.peek()
+}
+
+// Get returns the descriptor of the packet at index.
+func (q *RXQueue) Get(index uint32) unix.XDPDesc {
+	return q.descs[index&q.mask]
+}
+
+// Release gives n descriptors back to the kernel. The frames of the packets
+// must have been copied or given back through the fill queue.
+func (q *RXQueue) Release(n uint32) {
+	q.release(n)
+}
+
+// TXQueue gives packets to the kernel to send.
+type TXQueue struct {
+	ring
+	descs []unix.XDPDesc
+}
+
+// Reserve reserves n descriptors, and returns the index of the first one.
+func (q *TXQueue) Reserve(n uint32) (uint32, bool) {
+	return q.reserve(n)
+}
+
+// Set sets the reserved descriptor at index.
+func (q *TXQueue) Set(index uint32, desc unix.XDPDesc) {
+	q.descs[index&q.mask] = desc
+}
+
+// Notify gives the reserved descriptors to the kernel, which sends them after
+// ControlBlock.Wakeup.
+func (q *TXQueue) Notify() {
+	q.notify()
+}
+
+// CompletionQueue holds the frames of packets sent by the kernel.
+type CompletionQueue struct {
+	ring
+	descs []uint64
+}
+
+// Peek returns the number of sent packets, and the index of the first one.
+func (q *CompletionQueue) Peek() (uint32, uint32) {
+	return q.peek()
+}
+
+// Get returns the frame address of the sent packet at index.
+func (q *CompletionQueue) Get(index uint32) uint64 {
+	return q.descs[index&q.mask]
+}
+
+// Release gives n descriptors back to the kernel.
+func (q *CompletionQueue) Release(n uint32) {
+	q.release(n)
+}
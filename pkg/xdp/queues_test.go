@@ -0,0 +1,138 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package xdp
+
+import (
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+const testDescriptors = 8
+
+// testRingOffset lays out a ring like the kernel: the indices on separate
+// cache lines, followed by the descriptors.
+var testRingOffset = unix.XDPRingOffset{
+	Producer: 0,
+	Consumer: 64,
+	Desc:     128,
+}
+
+func TestQueuesWrapAround(t *testing.T) {
+	// The fill and completion rings have the same layout, so a completion
+	// queue on the memory of a fill queue sees what the kernel would.
+	mem := make([]byte, testRingOffset.Desc+testDescriptors*sizeofAddr)
+	var fill FillQueue
+	fill.init(mem, testRingOffset, testDescriptors)
+	var kernel CompletionQueue
+	kernel.init(mem, testRingOffset, testDescriptors)
+
+	next := uint64(0)
+	want := uint64(0)
+	for round := 0; round < 5; round++ {
+		// Fill the ring, which then can't take more descriptors.
+		for i := 0; i < testDescriptors; i += 2 {
+			index, ok := fill.Reserve(2)
+			if !ok {
+				t.Fatalf("round %d: Reserve(2) failed with %d descriptors produced", round, i)
+			}
+			fill.Set(index, next)
+			fill.Set(index+1, next+1)
+			next += 2
+		}
+		if _, ok := fill.Reserve(1); ok {
+			t.Fatalf("round %d: Reserve(1) succeeded on a full ring", round)
+		}
+
+		// Nothing is visible before Notify.
+		if n, _ := kernel.Peek(); n != 0 {
+			t.Fatalf("round %d: Peek() = %d before Notify, want 0", round, n)
+		}
+		fill.Notify()
+		n, index := kernel.Peek()
+		if n != testDescriptors {
+			t.Fatalf("round %d: Peek() = %d, want %d", round, n, testDescriptors)
+		}
+		for i := uint32(0); i < n; i++ {
+			if got := kernel.Get(index + i); got != want {
+				t.Errorf("round %d: Get(%d) = %d, want %d", round, index+i, got, want)
+			}
+			want++
+		}
+
+		// Space is only available again after Release.
+		if _, ok := fill.Reserve(1); ok {
+			t.Fatalf("round %d: Reserve(1) succeeded before Release", round)
+		}
+		kernel.Release(n)
+	}
+}
+
+func TestQueuesIndicesOverflow(t *testing.T) {
+	mem := make([]byte, testRingOffset.Desc+testDescriptors*sizeofDesc)
+	// Start close to the maximum index, as after a long time of use.
+	start := ^uint32(0) - 2
+	producer, consumer := ringIndices(mem, testRingOffset)
+	*producer, *consumer = start, start
+
+	var tx TXQueue
+	tx.init(mem, testRingOffset, testDescriptors)
+	var kernel RXQueue
+	kernel.init(mem, testRingOffset, testDescriptors)
+
+	index, ok := tx.Reserve(testDescriptors)
+	if !ok {
+		t.Fatalf("Reserve(%d) failed", testDescriptors)
+	}
+	for i := uint32(0); i < testDescriptors; i++ {
+		tx.Set(index+i, unix.XDPDesc{Addr: uint64(i), Len: i})
+	}
+	tx.Notify()
+	n, index := kernel.Peek()
+	if n != testDescriptors || index != start {
+		t.Fatalf("Peek() = (%d, %d), want (%d, %d)", n, index, testDescriptors, start)
+	}
+	for i := uint32(0); i < n; i++ {
+		if got := kernel.Get(index + i); got.Addr != uint64(i) || got.Len != i {
+			t.Errorf("Get(%d) = %+v, want address and length %d", index+i, got, i)
+		}
+	}
+	kernel.Release(n)
+	if _, ok := tx.Reserve(testDescriptors); !ok {
+		t.Errorf("Reserve(%d) failed after Release", testDescriptors)
+	}
+}
+
+func TestUMEMFrames(t *testing.T) {
+	const frameSize = 2048
+	u := UMEM{mem: make([]byte, 4*frameSize), frameSize: frameSize}
+	u.FreeFrame(2*frameSize + unix.XDP_PACKET_HEADROOM)
+	if got := u.NumFreeFrames(); got != 1 {
+		t.Fatalf("NumFreeFrames() = %d, want 1", got)
+	}
+	addr, ok := u.AllocFrame()
+	if !ok || addr != 2*frameSize {
+		t.Errorf("AllocFrame() = (%d, %t), want (%d, true)", addr, ok, 2*frameSize)
+	}
+	if _, ok := u.AllocFrame(); ok {
+		t.Errorf("AllocFrame() succeeded without free frames")
+	}
+	if got := len(u.Frame(addr)); got != frameSize {
+		t.Errorf("len(Frame(%d)) = %d, want %d", addr, got, frameSize)
+	}
+}
@@ -0,0 +1,271 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+// Package xdp provides tools for working with AF_XDP sockets.
+//
+// AF_XDP sockets exchange packets with a NIC queue through rings of
+// descriptors that point into a shared memory area, the UMEM. Packets are
+// steered from the NIC to the socket by an XDP program attached to the device,
+// bypassing the rest of the host network stack.
+//
+// A socket is set up in two steps, which may happen in different processes:
+//  - The socket is created with socket(AF_XDP), which requires CAP_NET_RAW,
+//    and the XDP program returned by LoadRedirectProgram is attached to the
+//    device. See NewXSKMap.
+//  - New registers the UMEM, maps the rings and binds the socket to a queue
+//    of the device. The socket must then be inserted in the XSKMAP of the
+//    program with UpdateXSKMap before it receives packets.
+//
+// Half of the frames of the UMEM are used to receive packets: they're given to
+// the kernel through the fill queue and come back through the RX queue. The
+// other half are used to send packets: they're given to the kernel through the
+// TX queue and come back through the completion queue.
+package xdp
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// Options are the options of a ControlBlock.
+type Options struct {
+	// NumFrames is the number of frames of the UMEM. It must be a power of 2.
+	NumFrames uint32
+
+	// FrameSize is the size of each frame of the UMEM, which bounds the size
+	// of packets. It must be a power of 2 of at least 2048 bytes.
+	FrameSize uint32
+
+	// NumDescriptors is the number of descriptors of each ring. It must be a
+	// power of 2 of at least NumFrames/2.
+	NumDescriptors uint32
+}
+
+// DefaultOptions returns the default options of a ControlBlock, with a UMEM
+// of 16MB.
+func DefaultOptions() Options {
+	return Options{
+		NumFrames:      4096,
+		FrameSize:      4096,
+		NumDescriptors: 2048,
+	}
+}
+
+// MaxPacketSize returns the size of the largest packet, including its link
+// header, that fits in a frame.
+func (o Options) MaxPacketSize() uint32 {
+	return o.FrameSize - unix.XDP_PACKET_HEADROOM
+}
+
+func (o Options) validate() error {
+	isPowerOf2 := func(n uint32) bool { return n != 0 && n&(n-1) == 0 }
+	if !isPowerOf2(o.NumFrames) {
+		return fmt.Errorf("number of frames %d is not a power of 2", o.NumFrames)
+	}
+	if !isPowerOf2(o.FrameSize) || o.FrameSize < 2048 {
+		return fmt.Errorf("frame size %d is not a power of 2 of at least 2048", o.FrameSize)
+	}
+	if !isPowerOf2(o.NumDescriptors) || o.NumDescriptors < o.NumFrames/2 {
+		return fmt.Errorf("number of descriptors %d is not a power of 2 of at least %d", o.NumDescriptors, o.NumFrames/2)
+	}
+	return nil
+}
+
+// ControlBlock holds the UMEM and the rings of a bound AF_XDP socket.
+//
+// The RX side (RX and fill queues) and the TX side (TX and completion queues,
+// and the free frames of the UMEM) may be used concurrently, but each side
+// must only be used by one goroutine at a time.
+type ControlBlock struct {
+	UMEM       UMEM
+	Fill       FillQueue
+	RX         RXQueue
+	TX         TXQueue
+	Completion CompletionQueue
+
+	fd int
+
+	// mappings are the memory mappings of the UMEM and the rings.
+	mappings [][]byte
+}
+
+// New prepares sockfd, an unbound AF_XDP socket, and binds it to queue
+// queueID of the device with index ifaceIndex. It takes ownership of sockfd,
+// which is closed by ControlBlock.Close.
+func New(sockfd int, ifaceIndex, queueID uint32, opts Options) (*ControlBlock, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	cb := &ControlBlock{fd: sockfd}
+	if err := cb.init(ifaceIndex, queueID, opts); err != nil {
+		cb.Close()
+		return nil, err
+	}
+	return cb, nil
+}
+
+func (cb *ControlBlock) init(ifaceIndex, queueID uint32, opts Options) error {
+	// Register the UMEM. Its pages are pinned by the kernel.
+	mem, err := unix.Mmap(-1, 0, int(opts.NumFrames*opts.FrameSize), unix.PROT_READ|unix.PROT_WRITE, unix.MAP_PRIVATE|unix.MAP_ANONYMOUS)
+	if err != nil {
+		return fmt.Errorf("allocating UMEM: %v", err)
+	}
+	cb.mappings = append(cb.mappings, mem)
+	if err := registerUMEM(cb.fd, mem, opts.FrameSize); err != nil {
+		return fmt.Errorf("registering UMEM: %v", err)
+	}
+	cb.UMEM = UMEM{mem: mem, frameSize: opts.FrameSize}
+
+	// Size the rings, then map them. Rings can only be mapped before the
+	// socket is bound.
+	for _, opt := range []int{unix.XDP_UMEM_FILL_RING, unix.XDP_UMEM_COMPLETION_RING, unix.XDP_RX_RING, unix.XDP_TX_RING} {
+		if err := unix.SetsockoptInt(cb.fd, unix.SOL_XDP, opt, int(opts.NumDescriptors)); err != nil {
+			return fmt.Errorf("setting size of ring %d: %v", opt, err)
+		}
+	}
+	off, err := getMmapOffsets(cb.fd)
+	if err != nil {
+		return fmt.Errorf("getting ring offsets: %v", err)
+	}
+	n := opts.NumDescriptors
+	fill, err := cb.mapRing(unix.XDP_UMEM_PGOFF_FILL_RING, off.Fr, n, sizeofAddr)
+	if err != nil {
+		return fmt.Errorf("mapping fill ring: %v", err)
+	}
+	cb.Fill.init(fill, off.Fr, n)
+	completion, err := cb.mapRing(unix.XDP_UMEM_PGOFF_COMPLETION_RING, off.Cr, n, sizeofAddr)
+	if err != nil {
+		return fmt.Errorf("mapping completion ring: %v", err)
+	}
+	cb.Completion.init(completion, off.Cr, n)
+	rx, err := cb.mapRing(unix.XDP_PGOFF_RX_RING, off.Rx, n, sizeofDesc)
+	if err != nil {
+		return fmt.Errorf("mapping RX ring: %v", err)
+	}
+	cb.RX.init(rx, off.Rx, n)
+	tx, err := cb.mapRing(unix.XDP_PGOFF_TX_RING, off.Tx, n, sizeofDesc)
+	if err != nil {
+		return fmt.Errorf("mapping TX ring: %v", err)
+	}
+	cb.TX.init(tx, off.Tx, n)
+
+	// Give the first half of the frames to the kernel for RX, and keep the
+	// other half for TX.
+	rxFrames := opts.NumFrames / 2
+	index, ok := cb.Fill.Reserve(rxFrames)
+	if !ok {
+		panic(fmt.Sprintf("fill queue with %d descriptors can't hold %d frames", n, rxFrames))
+	}
+	for i := uint32(0); i < rxFrames; i++ {
+		cb.Fill.Set(index+i, uint64(i*opts.FrameSize))
+	}
+	cb.Fill.Notify()
+	for i := rxFrames; i < opts.NumFrames; i++ {
+		cb.UMEM.FreeFrame(uint64(i * opts.FrameSize))
+	}
+
+	sa := unix.SockaddrXDP{
+		Ifindex: ifaceIndex,
+		QueueID: queueID,
+	}
+	if err := unix.Bind(cb.fd, &sa); err != nil {
+		return fmt.Errorf("binding to queue %d of interface %d: %v", queueID, ifaceIndex, err)
+	}
+	return nil
+}
+
+// mapRing maps the ring at pgoff, with n descriptors of descSize bytes.
+func (cb *ControlBlock) mapRing(pgoff int64, off unix.XDPRingOffset, n uint32, descSize uint64) ([]byte, error) {
+	mem, err := unix.Mmap(cb.fd, pgoff, int(off.Desc+uint64(n)*descSize), unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+	if err != nil {
+		return nil, err
+	}
+	cb.mappings = append(cb.mappings, mem)
+	return mem, nil
+}
+
+// FD returns the fd of the socket.
+func (cb *ControlBlock) FD() int {
+	return cb.fd
+}
+
+// Wakeup notifies the kernel that packets were added to the TX queue.
+func (cb *ControlBlock) Wakeup() error {
+	// The kernel may be busy sending earlier packets, or out of buffers, in
+	// which case it picks up the new packets later.
+	if err := unix.Sendto(cb.fd, nil, unix.MSG_DONTWAIT, nil); err != nil && err != unix.EAGAIN && err != unix.EBUSY && err != unix.ENOBUFS {
+		return err
+	}
+	return nil
+}
+
+// Close unmaps the UMEM and the rings, and closes the socket.
+func (cb *ControlBlock) Close() {
+	// Close the socket first, so that the kernel stops using the memory.
+	unix.Close(cb.fd)
+	for _, mem := range cb.mappings {
+		unix.Munmap(mem)
+	}
+	cb.mappings = nil
+}
+
+// UMEM is the memory area holding the packets of an AF_XDP socket, split in
+// frames of equal size.
+type UMEM struct {
+	mem       []byte
+	frameSize uint32
+
+	// freeFrames are the addresses of the frames available to send packets.
+	freeFrames []uint64
+}
+
+// Get returns the bytes of the packet described by desc.
+func (u *UMEM) Get(desc unix.XDPDesc) []byte {
+	return u.mem[desc.Addr : desc.Addr+uint64(desc.Len)]
+}
+
+// Frame returns the frame at addr, which is the address of the start of the
+// frame.
+func (u *UMEM) Frame(addr uint64) []byte {
+	return u.mem[addr : addr+uint64(u.frameSize)]
+}
+
+// FrameAddr returns the address of the start of the frame containing addr.
+func (u *UMEM) FrameAddr(addr uint64) uint64 {
+	return addr &^ uint64(u.frameSize-1)
+}
+
+// AllocFrame returns the address of a frame available to send a packet.
+func (u *UMEM) AllocFrame() (uint64, bool) {
+	if len(u.freeFrames) == 0 {
+		return 0, false
+	}
+	addr := u.freeFrames[len(u.freeFrames)-1]
+	u.freeFrames = u.freeFrames[:len(u.freeFrames)-1]
+	return addr, true
+}
+
+// FreeFrame makes the frame at addr available to send packets again.
+func (u *UMEM) FreeFrame(addr uint64) {
+	u.freeFrames = append(u.freeFrames, u.FrameAddr(addr))
+}
+
+// NumFreeFrames returns the number of frames available to send packets.
+func (u *UMEM) NumFreeFrames() int {
+	return len(u.freeFrames)
+}
@@ -0,0 +1,90 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package xdp
+
+import (
+	"reflect"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// sizeofDesc is the size of the descriptors of the RX and TX rings.
+const sizeofDesc = uint64(unsafe.Sizeof(unix.XDPDesc{}))
+
+// registerUMEM registers mem as the UMEM of the socket fd.
+func registerUMEM(fd int, mem []byte, frameSize uint32) error {
+	reg := unix.XDPUmemReg{
+		Addr: uint64(uintptr(unsafe.Pointer(&mem[0]))),
+		Len:  uint64(len(mem)),
+		Size: frameSize,
+	}
+	if _, _, errno := unix.Syscall6(unix.SYS_SETSOCKOPT, uintptr(fd), unix.SOL_XDP, unix.XDP_UMEM_REG, uintptr(unsafe.Pointer(&reg)), unsafe.Sizeof(reg), 0); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// getMmapOffsets returns the offsets of the indices and descriptors in the
+// memory of each ring of the socket fd.
+func getMmapOffsets(fd int) (unix.XDPMmapOffsets, error) {
+	var off unix.XDPMmapOffsets
+	size := uint32(unsafe.Sizeof(off))
+	if _, _, errno := unix.Syscall6(unix.SYS_GETSOCKOPT, uintptr(fd), unix.SOL_XDP, unix.XDP_MMAP_OFFSETS, uintptr(unsafe.Pointer(&off)), uintptr(unsafe.Pointer(&size)), 0); errno != 0 {
+		return off, errno
+	}
+	return off, nil
+}
+
+// ringIndices returns the producer and consumer indices of the ring mapped at
+// mem.
+func ringIndices(mem []byte, off unix.XDPRingOffset) (*uint32, *uint32) {
+	return (*uint32)(unsafe.Pointer(&mem[off.Producer])), (*uint32)(unsafe.Pointer(&mem[off.Consumer]))
+}
+
+// setSlice points the slice header hdr to n elements at the descriptors of the
+// ring mapped at mem.
+func setSlice(hdr *reflect.SliceHeader, mem []byte, off unix.XDPRingOffset, n uint32) {
+	hdr.Data = uintptr(unsafe.Pointer(&mem[off.Desc]))
+	hdr.Len = int(n)
+	hdr.Cap = int(n)
+}
+
+func (q *FillQueue) init(mem []byte, off unix.XDPRingOffset, n uint32) {
+	producer, consumer := ringIndices(mem, off)
+	q.ring.init(producer, consumer, n)
+	setSlice((*reflect.SliceHeader)(unsafe.Pointer(&q.descs)), mem, off, n)
+}
+
+func (q *RXQueue) init(mem []byte, off unix.XDPRingOffset, n uint32) {
+	producer, consumer := ringIndices(mem, off)
+	q.ring.init(producer, consumer, n)
+	setSlice((*reflect.SliceHeader)(unsafe.Pointer(&q.descs)), mem, off, n)
+}
+
+func (q *TXQueue) init(mem []byte, off unix.XDPRingOffset, n uint32) {
+	producer, consumer := ringIndices(mem, off)
+	q.ring.init(producer, consumer, n)
+	setSlice((*reflect.SliceHeader)(unsafe.Pointer(&q.descs)), mem, off, n)
+}
+
+func (q *CompletionQueue) init(mem []byte, off unix.XDPRingOffset, n uint32) {
+	producer, consumer := ringIndices(mem, off)
+	q.ring.init(producer, consumer, n)
+	setSlice((*reflect.SliceHeader)(unsafe.Pointer(&q.descs)), mem, off, n)
+}
@@ -0,0 +1,165 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boot
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+	"gvisor.dev/gvisor/pkg/cleanup"
+	"gvisor.dev/gvisor/pkg/fd"
+	"gvisor.dev/gvisor/pkg/log"
+	hostvfs2 "gvisor.dev/gvisor/pkg/sentry/fsimpl/host"
+	"gvisor.dev/gvisor/pkg/sentry/kernel"
+	"gvisor.dev/gvisor/pkg/sentry/vfs"
+	"gvisor.dev/gvisor/pkg/urpc"
+)
+
+// AttachArgs are arguments to the Attach method.
+type AttachArgs struct {
+	// CID is the ID of the container to attach to.
+	CID string
+
+	// ReadOnly attaches to the container's output only. Data received from
+	// the stdin file is discarded.
+	ReadOnly bool
+
+	// FilePayload contains, in order: a stdin file to read input for the
+	// container from, and stdout and stderr files to write a copy of the
+	// container's output to. The attach session ends when the stdin file
+	// reaches EOF.
+	urpc.FilePayload
+}
+
+// Attach connects the given files to the stdio of the container's init
+// process.
+func (cm *containerManager) Attach(args *AttachArgs, _ *struct{}) error {
+	log.Debugf("containerManager.Attach, cid: %s, readOnly: %t", args.CID, args.ReadOnly)
+	if len(args.Files) != 3 {
+		return fmt.Errorf("attach requires 3 files, got: %d", len(args.Files))
+	}
+	fds, err := fd.NewFromFiles(args.Files)
+	if err != nil {
+		return err
+	}
+	return cm.l.attach(args.CID, args.ReadOnly, fds[0], fds[1], fds[2])
+}
+
+// attach mirrors the output of the container's init process to stdout and
+// stderr, and forwards data read from stdin to it unless readOnly is set. It
+// takes ownership of the given FDs.
+func (l *Loader) attach(cid string, readOnly bool, stdin, stdout, stderr *fd.FD) error {
+	cu := cleanup.Make(func() {
+		_ = stdin.Close()
+		_ = stdout.Close()
+		_ = stderr.Close()
+	})
+	defer cu.Clean()
+
+	if !kernel.VFS2Enabled {
+		return fmt.Errorf("attach is only supported with VFS2")
+	}
+	tg, err := l.threadGroupFromID(execID{cid: cid})
+	if err != nil {
+		return err
+	}
+
+	// Output is best effort, don't block the container if the client isn't
+	// keeping up.
+	for _, f := range []*fd.FD{stdout, stderr} {
+		if err := unix.SetNonblock(f.FD(), true); err != nil {
+			return err
+		}
+	}
+
+	files := stdioFiles(tg)
+	ctx := l.k.SupervisorContext()
+	for _, f := range files {
+		if f != nil {
+			f := f
+			cu.Add(func() { f.DecRef(ctx) })
+		}
+	}
+	if files[1] == nil || files[2] == nil {
+		return fmt.Errorf("container %q has no stdout or stderr", cid)
+	}
+
+	mirrors := map[*vfs.FileDescription]*fd.FD{files[1]: stdout}
+	if files[2] != files[1] {
+		// Don't duplicate output if stdout and stderr are the same file, e.g.
+		// when using a terminal.
+		mirrors[files[2]] = stderr
+	}
+	var detach []func()
+	for file, w := range mirrors {
+		remove, err := hostvfs2.AddOutputMirror(file, w)
+		if err != nil {
+			for _, d := range detach {
+				d()
+			}
+			return fmt.Errorf("attaching to container %q: %w", cid, err)
+		}
+		detach = append(detach, remove)
+	}
+
+	log.Infof("Attached to container %q, readOnly: %t", cid, readOnly)
+	cu.Release()
+	go func() {
+		defer func() {
+			for _, d := range detach {
+				d()
+			}
+			for _, f := range files {
+				if f != nil {
+					f.DecRef(ctx)
+				}
+			}
+			_ = stdin.Close()
+			_ = stdout.Close()
+			_ = stderr.Close()
+			log.Infof("Detached from container %q", cid)
+		}()
+
+		buf := make([]byte, 4096)
+		for {
+			n, err := stdin.Read(buf)
+			if n > 0 && !readOnly && files[0] != nil {
+				if err := hostvfs2.InjectInput(files[0], buf[:n]); err != nil {
+					log.Warningf("Attach to container %q: forwarding stdin: %v", cid, err)
+					readOnly = true
+				}
+			}
+			if err != nil || n == 0 {
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// stdioFiles returns the files at FDs 0, 1 and 2 of the thread group leader.
+// Missing files are returned as nil. The caller must DecRef the returned
+// files.
+func stdioFiles(tg *kernel.ThreadGroup) [3]*vfs.FileDescription {
+	var files [3]*vfs.FileDescription
+	tg.Leader().WithMuLocked(func(t *kernel.Task) {
+		if fdt := t.FDTable(); fdt != nil {
+			for i := range files {
+				files[i], _ = fdt.GetVFS2(int32(i))
+			}
+		}
+	})
+	return files
+}
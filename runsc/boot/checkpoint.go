@@ -0,0 +1,568 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"gvisor.dev/gvisor/pkg/abi"
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/context"
+	"gvisor.dev/gvisor/pkg/fspath"
+	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/pkg/sentry/arch"
+	"gvisor.dev/gvisor/pkg/sentry/fsimpl/tmpfs"
+	"gvisor.dev/gvisor/pkg/sentry/kernel"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/auth"
+	"gvisor.dev/gvisor/pkg/sentry/vfs"
+	"gvisor.dev/gvisor/pkg/state/statefile"
+	"gvisor.dev/gvisor/runsc/specutils"
+)
+
+// Keys of the metadata describing the sandbox in checkpoint images.
+const (
+	// MetadataContainers is the JSON list of the IDs of the containers in
+	// the sandbox, in the order they were started.
+	MetadataContainers = "containers"
+
+	// MetadataInits is the JSON map of the container IDs to the thread group
+	// ID of their init process, in the root PID namespace.
+	MetadataInits = "inits"
+
+	// MetadataFilesystemIDs is the JSON map of the subcontainer IDs to the
+	// prefix of the unique IDs of their gofer filesystems.
+	MetadataFilesystemIDs = "filesystem_ids"
+
+	// MetadataMounts is the JSON map of the container IDs to the mounts of
+	// their spec.
+	MetadataMounts = "mounts"
+
+	// MetadataMemorySize is the number of bytes of memory used by the
+	// sandbox.
+	MetadataMemorySize = "memory_size"
+
+	// MetadataKernelVersion is the Linux release advertised to the sandbox.
+	MetadataKernelVersion = "kernel_version"
+
+	// MetadataSnapshot is the sequence number of periodic snapshots, which
+	// identifies the latest one. It's not set for checkpoints.
+	MetadataSnapshot = "snapshot"
+)
+
+// CheckpointInfo describes the sandbox saved in a checkpoint image.
+type CheckpointInfo struct {
+	// Containers are the IDs of the containers in the sandbox, in the order
+	// they were started. Images written by older versions sort them instead.
+	Containers []string `json:"containers"`
+
+	// Inits maps container IDs to the thread group ID of their init process,
+	// in the root PID namespace. Images written by older versions don't have
+	// it, and can only restore the root container.
+	Inits map[string]int32 `json:"inits,omitempty"`
+
+	// FilesystemIDs maps subcontainer IDs to the prefix of the unique IDs of
+	// their gofer filesystems, which is the ID the container was first
+	// started with.
+	FilesystemIDs map[string]string `json:"filesystem_ids,omitempty"`
+
+	// Mounts maps container IDs to the mounts of their spec.
+	Mounts map[string][]specs.Mount `json:"mounts"`
+
+	// MemorySize is the number of bytes of memory used by the sandbox.
+	MemorySize uint64 `json:"memory_size"`
+
+	// KernelVersion is the Linux release advertised to the sandbox.
+	KernelVersion string `json:"kernel_version"`
+
+	// Snapshot is the sequence number of a periodic snapshot, or 0 for
+	// checkpoints.
+	Snapshot uint64 `json:"snapshot,omitempty"`
+}
+
+// ParseCheckpointInfo returns the description of the sandbox recorded in the
+// metadata of a checkpoint image. Images written by older versions don't
+// have it, and an empty CheckpointInfo is returned for them.
+func ParseCheckpointInfo(metadata map[string]string) (*CheckpointInfo, error) {
+	info := &CheckpointInfo{}
+	if v, ok := metadata[MetadataContainers]; ok {
+		if err := json.Unmarshal([]byte(v), &info.Containers); err != nil {
+			return nil, fmt.Errorf("invalid %q metadata: %v", MetadataContainers, err)
+		}
+	}
+	if v, ok := metadata[MetadataInits]; ok {
+		if err := json.Unmarshal([]byte(v), &info.Inits); err != nil {
+			return nil, fmt.Errorf("invalid %q metadata: %v", MetadataInits, err)
+		}
+	}
+	if v, ok := metadata[MetadataFilesystemIDs]; ok {
+		if err := json.Unmarshal([]byte(v), &info.FilesystemIDs); err != nil {
+			return nil, fmt.Errorf("invalid %q metadata: %v", MetadataFilesystemIDs, err)
+		}
+	}
+	if v, ok := metadata[MetadataMounts]; ok {
+		if err := json.Unmarshal([]byte(v), &info.Mounts); err != nil {
+			return nil, fmt.Errorf("invalid %q metadata: %v", MetadataMounts, err)
+		}
+	}
+	if v, ok := metadata[MetadataMemorySize]; ok {
+		size, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %q metadata: %v", MetadataMemorySize, err)
+		}
+		info.MemorySize = size
+	}
+	info.KernelVersion = metadata[MetadataKernelVersion]
+	if v, ok := metadata[MetadataSnapshot]; ok {
+		seq, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %q metadata: %v", MetadataSnapshot, err)
+		}
+		info.Snapshot = seq
+	}
+	return info, nil
+}
+
+// checkpointMetadata returns the metadata describing the sandbox to add to
+// checkpoint images.
+func (l *Loader) checkpointMetadata() (map[string]string, error) {
+	info := CheckpointInfo{
+		Mounts:        make(map[string][]specs.Mount),
+		Inits:         make(map[string]int32),
+		FilesystemIDs: make(map[string]string),
+	}
+	l.mu.Lock()
+	for _, cid := range l.startOrder {
+		ep := l.processes[execID{cid: cid}]
+		if ep == nil || ep.tg == nil {
+			continue
+		}
+		tgid := l.k.RootPIDNamespace().IDOfThreadGroup(ep.tg)
+		if tgid == 0 {
+			// The container has stopped.
+			continue
+		}
+		info.Containers = append(info.Containers, cid)
+		info.Mounts[cid] = ep.mounts
+		info.Inits[cid] = int32(tgid)
+		if ep.fsID != "" {
+			info.FilesystemIDs[cid] = ep.fsID
+		}
+	}
+	l.mu.Unlock()
+
+	if mf := l.k.MemoryFile(); mf != nil {
+		usage, err := mf.TotalUsage()
+		if err != nil {
+			log.Warningf("Error getting memory usage: %v", err)
+		}
+		info.MemorySize = usage
+	}
+	if st, ok := kernel.LookupSyscallTable(abi.Linux, arch.Host); ok {
+		info.KernelVersion = st.Version.Release
+	}
+
+	containers, err := json.Marshal(info.Containers)
+	if err != nil {
+		return nil, err
+	}
+	mounts, err := json.Marshal(info.Mounts)
+	if err != nil {
+		return nil, err
+	}
+	inits, err := json.Marshal(info.Inits)
+	if err != nil {
+		return nil, err
+	}
+	fsIDs, err := json.Marshal(info.FilesystemIDs)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{
+		MetadataContainers:    string(containers),
+		MetadataInits:         string(inits),
+		MetadataFilesystemIDs: string(fsIDs),
+		MetadataMounts:        string(mounts),
+		MetadataMemorySize:    strconv.FormatUint(info.MemorySize, 10),
+		MetadataKernelVersion: info.KernelVersion,
+	}, nil
+}
+
+// savedMounts returns the mounts of container cid saved in the image. If the
+// image has a single container, its mounts are used regardless of its ID,
+// since containers are often restored under a new ID. Likewise, the root
+// container is the first one started if the image records the start order.
+func (info *CheckpointInfo) savedMounts(cid string, root bool) ([]specs.Mount, error) {
+	if mounts, ok := info.Mounts[cid]; ok {
+		return mounts, nil
+	}
+	if len(info.Containers) == 1 || (root && info.Inits != nil && len(info.Containers) > 0) {
+		return info.Mounts[info.Containers[0]], nil
+	}
+	return nil, fmt.Errorf("container %q not found in image, containers: %v", cid, info.Containers)
+}
+
+// matchContainers maps the IDs of the containers saved in the image to the
+// IDs of the containers restoring them. The root container restores the first
+// container started. Subcontainers, given in the order they were restored,
+// restore the saved container with the same ID if any, or else the remaining
+// ones in the order they were started.
+func (info *CheckpointInfo) matchContainers(root string, subcontainers []string) (map[string]string, error) {
+	if len(subcontainers) > 0 && info.Inits == nil {
+		return nil, fmt.Errorf("image doesn't record the containers of the sandbox, only the root container can be restored")
+	}
+	if len(info.Containers) != len(subcontainers)+1 {
+		return nil, fmt.Errorf("image has %d containers, restoring %d", len(info.Containers), len(subcontainers)+1)
+	}
+	ids := map[string]string{info.Containers[0]: root}
+	var renamed []string
+	for _, cid := range subcontainers {
+		if _, ok := info.Mounts[cid]; ok && cid != info.Containers[0] {
+			ids[cid] = cid
+			continue
+		}
+		renamed = append(renamed, cid)
+	}
+	for _, old := range info.Containers[1:] {
+		if _, ok := ids[old]; ok {
+			continue
+		}
+		ids[old], renamed = renamed[0], renamed[1:]
+	}
+	return ids, nil
+}
+
+// matchRestoredContainers reads the description of the sandbox saved in the
+// state file, and matches the containers saved in it with the root container,
+// restored as root, and the subcontainers waiting to be restored. It returns
+// the description and the map of the saved container IDs to the new ones,
+// which is nil for images written by older versions.
+func (l *Loader) matchRestoredContainers(stateFile *os.File, root string) (*CheckpointInfo, map[string]string, error) {
+	st, err := stateFile.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	if st.Size() == 0 {
+		return nil, nil, fmt.Errorf("file cannot be empty")
+	}
+	// The state file is verified when it's loaded. Read the metadata without
+	// moving the file offset.
+	metadata, err := statefile.MetadataUnsafe(io.NewSectionReader(stateFile, 0, st.Size()))
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading image metadata: %v", err)
+	}
+	info, err := ParseCheckpointInfo(metadata)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if info.Inits == nil && len(l.restoring) == 0 {
+		return info, nil, nil
+	}
+	ids, err := info.matchContainers(root, l.restoring)
+	if err != nil {
+		return nil, nil, err
+	}
+	for old, cid := range ids {
+		if cid == root {
+			continue
+		}
+		ri := l.processes[execID{cid: cid}].restoreInfo
+		ri.fsID = old
+		if fsID, ok := info.FilesystemIDs[old]; ok {
+			ri.fsID = fsID
+		}
+		log.Infof("Restoring container %q as %q", old, cid)
+	}
+	return info, ids, nil
+}
+
+// restoringMounters returns the mounters of the subcontainers waiting to be
+// restored.
+func (l *Loader) restoringMounters() []*containerMounter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var mntrs []*containerMounter
+	for _, cid := range l.restoring {
+		ri := l.processes[execID{cid: cid}].restoreInfo
+		mntrs = append(mntrs, newContainerMounter(ri, l.k, l.mountHints, kernel.VFS2Enabled))
+	}
+	return mntrs
+}
+
+// restoreProcessesLocked resets l.processes once the sandbox is restored,
+// registering the init processes of the containers matched to the ones saved
+// in the image by ids. Exec processes aren't restored, while created
+// subcontainers can still be started.
+//
+// Preconditions: l.mu must be locked.
+func (l *Loader) restoreProcessesLocked(info *CheckpointInfo, ids map[string]string) error {
+	// Wake up the waiters of the restored subcontainers, which find them
+	// either started or gone.
+	prev := l.processes
+	defer func() {
+		for _, ep := range prev {
+			if ep.restored != nil {
+				close(ep.restored)
+				ep.restored = nil
+			}
+		}
+	}()
+
+	processes := map[execID]*execProcess{
+		{cid: l.sandboxID}: {tg: l.k.GlobalInit()},
+	}
+	for eid, ep := range l.processes {
+		if eid.pid == 0 && eid.cid != l.sandboxID && ep.tg == nil && ep.restoreInfo == nil {
+			processes[eid] = ep
+		}
+	}
+	startOrder := []string{l.sandboxID}
+	if ids != nil {
+		for _, old := range info.Containers[1:] {
+			cid := ids[old]
+			tg := l.k.RootPIDNamespace().ThreadGroupWithID(kernel.ThreadID(info.Inits[old]))
+			if tg == nil || tg.Leader() == nil || tg.Leader().ContainerID() != cid {
+				return fmt.Errorf("init process of container %q not found in image", old)
+			}
+			ep := l.processes[execID{cid: cid}]
+			ri := ep.restoreInfo
+			hc, err := healthCheckFromSpec(ri.spec)
+			if err != nil {
+				return err
+			}
+			ep.restoreInfo = nil
+			ep.tg = tg
+			ep.mounts = ri.spec.Mounts
+			ep.fsID = ri.fsID
+			if ns, ok := specutils.GetNS(specs.PIDNamespace, ri.spec); ok {
+				ep.pidnsPath = ns.Path
+			}
+			if ns, ok := specutils.GetNS(specs.UTSNamespace, ri.spec); ok {
+				ep.utsnsPath = ns.Path
+			}
+			if systemdEnabled(ri.spec, ri.conf) {
+				l.systemdContainers[cid] = struct{}{}
+			}
+			processes[execID{cid: cid}] = ep
+			startOrder = append(startOrder, cid)
+
+			l.startLogForwardingLocked(cid, ri.spec, tg)
+			l.startHealthCheckLocked(cid, ri.spec, hc)
+		}
+	}
+	l.processes = processes
+	l.startOrder = startOrder
+	l.restoring = nil
+	return nil
+}
+
+// remapSource returns the new location of source according to remap, which
+// maps old host paths to new ones. Paths under an old path are moved along
+// with it.
+func remapSource(source string, remap map[string]string) string {
+	best := ""
+	for old := range remap {
+		if (source == old || strings.HasPrefix(source, old+"/")) && len(old) > len(best) {
+			best = old
+		}
+	}
+	if best == "" {
+		return source
+	}
+	return remap[best] + strings.TrimPrefix(source, best)
+}
+
+// ValidateRestoreMounts checks that the mounts of spec match the mounts of
+// container cid saved in the image described by info. Mounts served by the
+// gofer must be in the same order, at the same destinations, and have the
+// same sources, after applying remap to the sources saved in the image. The
+// sources of spec that still use the old paths in remap are updated.
+//
+// Images that don't record mounts aren't validated.
+func ValidateRestoreMounts(info *CheckpointInfo, cid string, spec *specs.Spec, remap map[string]string, vfs2Enabled bool) error {
+	if info.Mounts == nil {
+		log.Warningf("Image doesn't record mounts, skipping mount validation")
+		return nil
+	}
+	root := specutils.SpecContainerType(spec) != specutils.ContainerTypeContainer
+	if !root && len(info.Containers) > 1 {
+		if _, ok := info.Mounts[cid]; !ok {
+			// Subcontainers restored under a new ID are matched to the saved
+			// ones in start order by the sandbox.
+			log.Infof("Container %q not found in image, skipping mount validation", cid)
+			return nil
+		}
+	}
+	saved, err := info.savedMounts(cid, root)
+	if err != nil {
+		return err
+	}
+
+	var savedGofer []specs.Mount
+	for _, m := range saved {
+		if specutils.IsGoferMount(m, vfs2Enabled) {
+			savedGofer = append(savedGofer, m)
+		}
+	}
+	var specGofer []*specs.Mount
+	for i := range spec.Mounts {
+		if specutils.IsGoferMount(spec.Mounts[i], vfs2Enabled) {
+			specGofer = append(specGofer, &spec.Mounts[i])
+		}
+	}
+	if len(savedGofer) != len(specGofer) {
+		return fmt.Errorf("image has %d bind mounts, spec has %d", len(savedGofer), len(specGofer))
+	}
+
+	for i, old := range savedGofer {
+		m := specGofer[i]
+		if m.Destination != old.Destination {
+			return fmt.Errorf("bind mount %d: destination is %q in image, %q in spec", i, old.Destination, m.Destination)
+		}
+		want := remapSource(old.Source, remap)
+		switch {
+		case m.Source == want:
+		case remapSource(m.Source, remap) == want:
+			log.Infof("Remapping mount %q source from %q to %q", m.Destination, m.Source, want)
+			m.Source = want
+		default:
+			if want != old.Source {
+				return fmt.Errorf("mount %q: source %q in image remaps to %q, but spec has %q", m.Destination, old.Source, want, m.Source)
+			}
+			return fmt.Errorf("mount %q: source is %q in image, %q in spec; remap %q to %q if it moved", m.Destination, old.Source, m.Source, old.Source, m.Source)
+		}
+	}
+	return nil
+}
+
+// removeExcludedContents removes the contents of the directories in exclude,
+// as seen by container cid, so that they are not saved in checkpoints.
+//
+// Directories must be on tmpfs, where contents only live in the sandbox, and
+// must not contain other mounts.
+func (l *Loader) removeExcludedContents(cid string, exclude []string) error {
+	if len(exclude) == 0 {
+		return nil
+	}
+	if !kernel.VFS2Enabled {
+		return fmt.Errorf("excluding paths from checkpoints requires VFS2")
+	}
+
+	l.mu.Lock()
+	tg, err := l.tryThreadGroupFromIDLocked(execID{cid: cid})
+	l.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if tg == nil {
+		return fmt.Errorf("container %q not started", cid)
+	}
+	mns := tg.Leader().MountNamespaceVFS2()
+	if mns == nil || !mns.TryIncRef() {
+		return fmt.Errorf("container %q has stopped", cid)
+	}
+	ctx := l.k.SupervisorContext()
+	defer mns.DecRef(ctx)
+	root := mns.Root()
+	root.IncRef()
+	defer root.DecRef(ctx)
+
+	creds := auth.NewRootCredentials(l.k.RootUserNamespace())
+	for _, dir := range exclude {
+		if !path.IsAbs(dir) {
+			return fmt.Errorf("excluded path %q must be absolute", dir)
+		}
+		dir = path.Clean(dir)
+		fd, err := l.k.VFS().OpenAt(ctx, creds, &vfs.PathOperation{
+			Root:  root,
+			Start: root,
+			Path:  fspath.Parse(dir),
+		}, &vfs.OpenOptions{Flags: linux.O_RDONLY | linux.O_DIRECTORY})
+		if err != nil {
+			return fmt.Errorf("opening excluded path %q: %v", dir, err)
+		}
+		mnt := fd.Mount()
+		fsName := mnt.Filesystem().FilesystemType().Name()
+		fd.DecRef(ctx)
+		if fsName != tmpfs.Name {
+			return fmt.Errorf("excluded path %q is on %s, only tmpfs is supported", dir, fsName)
+		}
+
+		log.Infof("Removing contents of %q from checkpoint", dir)
+		if err := removeContents(ctx, l.k.VFS(), creds, root, mnt, dir); err != nil {
+			return fmt.Errorf("removing contents of excluded path %q: %v", dir, err)
+		}
+	}
+	return nil
+}
+
+// removeContents recursively removes the contents of directory dir, which
+// must be on mount mnt.
+func removeContents(ctx context.Context, vfsObj *vfs.VirtualFilesystem, creds *auth.Credentials, root vfs.VirtualDentry, mnt *vfs.Mount, dir string) error {
+	fd, err := vfsObj.OpenAt(ctx, creds, &vfs.PathOperation{
+		Root:  root,
+		Start: root,
+		Path:  fspath.Parse(dir),
+	}, &vfs.OpenOptions{Flags: linux.O_RDONLY | linux.O_DIRECTORY | linux.O_NOFOLLOW})
+	if err != nil {
+		return err
+	}
+	if fd.Mount() != mnt {
+		fd.DecRef(ctx)
+		return fmt.Errorf("%q is a mount point", dir)
+	}
+	var dirents []vfs.Dirent
+	err = fd.IterDirents(ctx, vfs.IterDirentsCallbackFunc(func(dirent vfs.Dirent) error {
+		if dirent.Name != "." && dirent.Name != ".." {
+			dirents = append(dirents, dirent)
+		}
+		return nil
+	}))
+	fd.DecRef(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, dirent := range dirents {
+		child := path.Join(dir, dirent.Name)
+		pop := &vfs.PathOperation{
+			Root:  root,
+			Start: root,
+			Path:  fspath.Parse(child),
+		}
+		if dirent.Type == linux.DT_DIR {
+			if err := removeContents(ctx, vfsObj, creds, root, mnt, child); err != nil {
+				return err
+			}
+			if err := vfsObj.RmdirAt(ctx, creds, pop); err != nil {
+				return fmt.Errorf("removing %q: %v", child, err)
+			}
+			continue
+		}
+		if err := vfsObj.UnlinkAt(ctx, creds, pop); err != nil {
+			return fmt.Errorf("removing %q: %v", child, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boot
+
+import (
+	"reflect"
+	"testing"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func TestParseCheckpointInfo(t *testing.T) {
+	info, err := ParseCheckpointInfo(map[string]string{
+		MetadataContainers:    `["foo"]`,
+		MetadataInits:         `{"foo":1}`,
+		MetadataFilesystemIDs: `{}`,
+		MetadataMounts:        `{"foo":[{"destination":"/data","type":"bind","source":"/host/data"}]}`,
+		MetadataMemorySize:    "4096",
+		MetadataKernelVersion: "4.4.0",
+		MetadataSnapshot:      "3",
+	})
+	if err != nil {
+		t.Fatalf("ParseCheckpointInfo() failed: %v", err)
+	}
+	want := &CheckpointInfo{
+		Containers:    []string{"foo"},
+		Inits:         map[string]int32{"foo": 1},
+		FilesystemIDs: map[string]string{},
+		Mounts: map[string][]specs.Mount{
+			"foo": {{Destination: "/data", Type: "bind", Source: "/host/data"}},
+		},
+		MemorySize:    4096,
+		KernelVersion: "4.4.0",
+		Snapshot:      3,
+	}
+	if !reflect.DeepEqual(info, want) {
+		t.Errorf("ParseCheckpointInfo() = %+v, want %+v", info, want)
+	}
+
+	if _, err := ParseCheckpointInfo(map[string]string{MetadataMemorySize: "foo"}); err == nil {
+		t.Errorf("ParseCheckpointInfo() with invalid memory size succeeded")
+	}
+}
+
+func TestMatchContainers(t *testing.T) {
+	info := &CheckpointInfo{
+		Containers: []string{"root", "a", "b"},
+		Inits:      map[string]int32{"root": 1, "a": 5, "b": 9},
+		Mounts:     map[string][]specs.Mount{"root": nil, "a": nil, "b": nil},
+	}
+	for _, tc := range []struct {
+		name          string
+		root          string
+		subcontainers []string
+		want          map[string]string
+		wantErr       bool
+	}{
+		{
+			name:          "same IDs",
+			root:          "root",
+			subcontainers: []string{"b", "a"},
+			want:          map[string]string{"root": "root", "a": "a", "b": "b"},
+		},
+		{
+			name:          "new IDs",
+			root:          "new-root",
+			subcontainers: []string{"new-a", "new-b"},
+			want:          map[string]string{"root": "new-root", "a": "new-a", "b": "new-b"},
+		},
+		{
+			name:          "mixed",
+			root:          "new-root",
+			subcontainers: []string{"new-a", "a"},
+			want:          map[string]string{"root": "new-root", "a": "a", "b": "new-a"},
+		},
+		{
+			name:          "missing",
+			root:          "root",
+			subcontainers: []string{"a"},
+			wantErr:       true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := info.matchContainers(tc.root, tc.subcontainers)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("matchContainers() succeeded, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("matchContainers() failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("matchContainers() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	// Older images don't record the containers.
+	old := &CheckpointInfo{Containers: []string{"a", "root"}}
+	if _, err := old.matchContainers("root", []string{"a"}); err == nil {
+		t.Errorf("matchContainers() with old image succeeded, want error")
+	}
+}
+
+func TestRemapSource(t *testing.T) {
+	remap := map[string]string{
+		"/old":      "/new",
+		"/old/deep": "/elsewhere",
+	}
+	for _, tc := range []struct {
+		source string
+		want   string
+	}{
+		{source: "/old", want: "/new"},
+		{source: "/old/dir", want: "/new/dir"},
+		{source: "/old/deep/dir", want: "/elsewhere/dir"},
+		{source: "/older", want: "/older"},
+		{source: "/other", want: "/other"},
+	} {
+		if got := remapSource(tc.source, remap); got != tc.want {
+			t.Errorf("remapSource(%q) = %q, want %q", tc.source, got, tc.want)
+		}
+	}
+}
+
+func TestValidateRestoreMounts(t *testing.T) {
+	info := &CheckpointInfo{
+		Containers: []string{"old-id"},
+		Mounts: map[string][]specs.Mount{
+			"old-id": {
+				{Destination: "/proc", Type: "proc", Source: "proc"},
+				{Destination: "/data", Type: "bind", Source: "/host/data"},
+				{Destination: "/cache", Type: "bind", Source: "/host/cache"},
+			},
+		},
+	}
+	for _, tc := range []struct {
+		name    string
+		sources []string
+		remap   map[string]string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:    "same",
+			sources: []string{"/host/data", "/host/cache"},
+			want:    []string{"/host/data", "/host/cache"},
+		},
+		{
+			name:    "moved",
+			sources: []string{"/host/data", "/mnt/cache"},
+			wantErr: true,
+		},
+		{
+			name:    "remapped",
+			sources: []string{"/host/data", "/mnt/cache"},
+			remap:   map[string]string{"/host/cache": "/mnt/cache"},
+			want:    []string{"/host/data", "/mnt/cache"},
+		},
+		{
+			name:    "old sources in spec",
+			sources: []string{"/host/data", "/host/cache"},
+			remap:   map[string]string{"/host": "/mnt"},
+			want:    []string{"/mnt/data", "/mnt/cache"},
+		},
+		{
+			name:    "remap mismatch",
+			sources: []string{"/host/data", "/other/cache"},
+			remap:   map[string]string{"/host/cache": "/mnt/cache"},
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			spec := &specs.Spec{
+				Mounts: []specs.Mount{
+					{Destination: "/proc", Type: "proc", Source: "proc"},
+					{Destination: "/data", Type: "bind", Source: tc.sources[0]},
+					{Destination: "/cache", Type: "bind", Source: tc.sources[1]},
+				},
+			}
+			err := ValidateRestoreMounts(info, "new-id", spec, tc.remap, true /* vfs2Enabled */)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("ValidateRestoreMounts() succeeded, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ValidateRestoreMounts() failed: %v", err)
+			}
+			got := []string{spec.Mounts[1].Source, spec.Mounts[2].Source}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("sources after ValidateRestoreMounts() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	// Destinations must match.
+	spec := &specs.Spec{
+		Mounts: []specs.Mount{
+			{Destination: "/cache", Type: "bind", Source: "/host/cache"},
+			{Destination: "/data", Type: "bind", Source: "/host/data"},
+		},
+	}
+	if err := ValidateRestoreMounts(info, "new-id", spec, nil, true /* vfs2Enabled */); err == nil {
+		t.Errorf("ValidateRestoreMounts() with reordered mounts succeeded, want error")
+	}
+}
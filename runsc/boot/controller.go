@@ -22,6 +22,7 @@ import (
 
 	specs "github.com/opencontainers/runtime-spec/specs-go"
 	"golang.org/x/sys/unix"
+	"gvisor.dev/gvisor/pkg/abi/linux"
 	"gvisor.dev/gvisor/pkg/control/server"
 	"gvisor.dev/gvisor/pkg/fd"
 	"gvisor.dev/gvisor/pkg/log"
@@ -39,12 +40,23 @@ import (
 	"gvisor.dev/gvisor/runsc/boot/pprof"
 	"gvisor.dev/gvisor/runsc/config"
 	"gvisor.dev/gvisor/runsc/specutils"
+	"gvisor.dev/gvisor/runsc/tracing"
 )
 
 const (
+	// ContMgrAttach attaches to the stdio of a container.
+	ContMgrAttach = "containerManager.Attach"
+
+	// ContMgrBootTimeline gets the phases of the boot of a container that
+	// ran in the sandbox.
+	ContMgrBootTimeline = "containerManager.BootTimeline"
+
 	// ContMgrCheckpoint checkpoints a container.
 	ContMgrCheckpoint = "containerManager.Checkpoint"
 
+	// ContMgrCollectSpans collects the tracing spans of the sandbox.
+	ContMgrCollectSpans = "containerManager.CollectSpans"
+
 	// ContMgrCreateSubcontainer creates a sub-container.
 	ContMgrCreateSubcontainer = "containerManager.CreateSubcontainer"
 
@@ -55,21 +67,43 @@ const (
 	// ContMgrEvent gets stats about the container used by "runsc events".
 	ContMgrEvent = "containerManager.Event"
 
+	// ContMgrHealthCheck gets the health check status of a container.
+	ContMgrHealthCheck = "containerManager.HealthCheck"
+
 	// ContMgrExecuteAsync executes a command in a container.
 	ContMgrExecuteAsync = "containerManager.ExecuteAsync"
 
+	// ContMgrExecSessions lists the exec sessions running in a container.
+	ContMgrExecSessions = "containerManager.ExecSessions"
+
+	// ContMgrKillExecSession sends a signal to an exec session.
+	ContMgrKillExecSession = "containerManager.KillExecSession"
+
 	// ContMgrProcesses lists processes running in a container.
 	ContMgrProcesses = "containerManager.Processes"
 
+	// ContMgrResizeTTY changes the window size of the TTY of a process.
+	ContMgrResizeTTY = "containerManager.ResizeTTY"
+
 	// ContMgrRestore restores a container from a statefile.
 	ContMgrRestore = "containerManager.Restore"
 
+	// ContMgrRestoreSubcontainer prepares a sub-container to be restored
+	// along with the root container.
+	ContMgrRestoreSubcontainer = "containerManager.RestoreSubcontainer"
+
 	// ContMgrSignal sends a signal to a container.
 	ContMgrSignal = "containerManager.Signal"
 
+	// ContMgrStartSnapshots starts taking periodic snapshots of the sandbox.
+	ContMgrStartSnapshots = "containerManager.StartSnapshots"
+
 	// ContMgrStartSubcontainer starts a sub-container inside a running sandbox.
 	ContMgrStartSubcontainer = "containerManager.StartSubcontainer"
 
+	// ContMgrStopSnapshots stops taking periodic snapshots of the sandbox.
+	ContMgrStopSnapshots = "containerManager.StopSnapshots"
+
 	// ContMgrWait waits on the init process of the container and returns its
 	// ExitStatus.
 	ContMgrWait = "containerManager.Wait"
@@ -86,8 +120,24 @@ const (
 	// NetworkCreateLinksAndRoutes creates links and routes in a network stack.
 	NetworkCreateLinksAndRoutes = "Network.CreateLinksAndRoutes"
 
+	// NetworkPorts gets the ephemeral port configuration of a network stack.
+	NetworkPorts = "Network.Ports"
+
+	// NetworkSetPorts changes the ephemeral port configuration of a network
+	// stack.
+	NetworkSetPorts = "Network.SetPorts"
+
 	// DebugStacks collects sandbox stacks for debugging.
 	DebugStacks = "debug.Stacks"
+
+	// DebugDmesg collects the most recent log records of the sandbox.
+	DebugDmesg = "debug.Dmesg"
+
+	// TunablesGet gets the values of sentry tunables.
+	TunablesGet = "Tunables.Get"
+
+	// TunablesSet changes the value of a sentry tunable.
+	TunablesSet = "Tunables.Set"
 )
 
 // Profiling related commands (see pprof.go for more details).
@@ -101,7 +151,8 @@ const (
 
 // Logging related commands (see logging.go for more details).
 const (
-	LoggingChange = "Logging.Change"
+	LoggingChange       = "Logging.Change"
+	LoggingStraceFollow = "Logging.StraceFollow"
 )
 
 // Lifecycle related commands (see lifecycle.go for more details).
@@ -117,9 +168,11 @@ const (
 
 // Usage related commands (see usage.go for more details).
 const (
-	UsageCollect = "Usage.Collect"
-	UsageUsageFD = "Usage.UsageFD"
-	UsageReduce  = "Usage.Reduce"
+	UsageCollect      = "Usage.Collect"
+	UsageUsageFD      = "Usage.UsageFD"
+	UsageReduce       = "Usage.Reduce"
+	UsageBreakdown    = "Usage.Breakdown"
+	UsageCPUBreakdown = "Usage.CPUBreakdown"
 )
 
 // Events related commands (see events.go for more details).
@@ -142,15 +195,33 @@ type controller struct {
 	manager *containerManager
 }
 
+// usage returns the usage RPC stubs of the sandbox.
+func (l *Loader) usage() *control.Usage {
+	return &control.Usage{
+		Kernel:               l.k,
+		ApplicationInProcess: l.root.conf.Platform == "kvm",
+	}
+}
+
 // newController creates a new controller. The caller must call
 // controller.srv.StartServing() to start the controller.
-func newController(fd int, l *Loader) (*controller, error) {
+func newController(fd int, creds *server.Credentials, l *Loader) (*controller, error) {
 	ctrl := &controller{}
 	var err error
 	ctrl.srv, err = server.CreateFromFD(fd)
 	if err != nil {
 		return nil, err
 	}
+	if policy := l.root.conf.ControlPolicy; policy != "" {
+		p, err := server.ParsePolicy(policy)
+		if err != nil {
+			return nil, fmt.Errorf("parsing control policy: %v", err)
+		}
+		if creds == nil {
+			return nil, fmt.Errorf("control policy requires control credentials")
+		}
+		ctrl.srv.SetPolicy(p, creds)
+	}
 
 	ctrl.manager = &containerManager{
 		startChan:       make(chan struct{}),
@@ -182,13 +253,18 @@ func newController(fd int, l *Loader) (*controller, error) {
 					ctrl.srv.Register(control.NewProfile(l.k))
 				}
 			case controlpb.ControlConfig_USAGE:
-				ctrl.srv.Register(&control.Usage{Kernel: l.k})
+				ctrl.srv.Register(l.usage())
 			case controlpb.ControlConfig_PROC:
 				ctrl.srv.Register(&control.Proc{Kernel: l.k})
 			case controlpb.ControlConfig_STATE:
 				ctrl.srv.Register(&control.State{Kernel: l.k})
 			case controlpb.ControlConfig_DEBUG:
-				ctrl.srv.Register(&debug{})
+				ctrl.srv.Register(&debug{logRing: l.logRing})
+			case controlpb.ControlConfig_TUNABLES:
+				ctrl.srv.Register(&Tunables{
+					l:             l,
+					straceEnabled: l.root.conf.Strace && l.root.conf.StraceSyscalls == "" && !l.root.conf.StraceEvent,
+				})
 			}
 		}
 	}
@@ -218,9 +294,42 @@ type containerManager struct {
 	l *Loader
 }
 
+// startSpan starts the span of RPC name on container cid, child of the span
+// in traceParent, which is the span of the runsc command calling the RPC.
+func startSpan(traceParent, name, cid string) *tracing.ActiveSpan {
+	parent, err := tracing.ParseTraceParent(traceParent)
+	if err != nil {
+		log.Warningf("Ignoring trace parent: %v", err)
+	}
+	span := tracing.Start(parent, name)
+	if cid != "" {
+		span.SetAttribute("container.id", cid)
+	}
+	return span
+}
+
+// CollectSpans returns the spans of the sandbox ended since the last call, to
+// be exported by runsc.
+func (cm *containerManager) CollectSpans(_ *struct{}, out *[]*tracing.Span) error {
+	*out = tracing.Collect()
+	return nil
+}
+
+// StartRootArgs contains arguments to the StartRoot method.
+type StartRootArgs struct {
+	// CID is the ID of the root container.
+	CID string
+
+	// TraceParent is the W3C traceparent of the runsc span starting the
+	// container, if any.
+	TraceParent string
+}
+
 // StartRoot will start the root container process.
-func (cm *containerManager) StartRoot(cid *string, _ *struct{}) error {
-	log.Debugf("containerManager.StartRoot, cid: %s", *cid)
+func (cm *containerManager) StartRoot(args *StartRootArgs, _ *struct{}) (err error) {
+	log.Debugf("containerManager.StartRoot, cid: %s", args.CID)
+	span := startSpan(args.TraceParent, "sandbox start", args.CID)
+	defer func() { span.End(err) }()
 	// Tell the root container to start and wait for the result.
 	cm.startChan <- struct{}{}
 	if err := <-cm.startResultChan; err != nil {
@@ -240,13 +349,19 @@ type CreateArgs struct {
 	// CID is the ID of the container to start.
 	CID string
 
+	// TraceParent is the W3C traceparent of the runsc span creating the
+	// container, if any.
+	TraceParent string
+
 	// FilePayload may contain a TTY file for the terminal, if enabled.
 	urpc.FilePayload
 }
 
 // CreateSubcontainer creates a container within a sandbox.
-func (cm *containerManager) CreateSubcontainer(args *CreateArgs, _ *struct{}) error {
+func (cm *containerManager) CreateSubcontainer(args *CreateArgs, _ *struct{}) (err error) {
 	log.Debugf("containerManager.CreateSubcontainer: %s", args.CID)
+	span := startSpan(args.TraceParent, "sandbox create", args.CID)
+	defer func() { span.End(err) }()
 
 	if len(args.Files) > 1 {
 		return fmt.Errorf("start arguments must have at most 1 files for TTY")
@@ -273,6 +388,10 @@ type StartArgs struct {
 	// CID is the ID of the container to start.
 	CID string
 
+	// TraceParent is the W3C traceparent of the runsc span starting the
+	// container, if any.
+	TraceParent string
+
 	// FilePayload contains, in order:
 	//   * stdin, stdout, and stderr (optional: if terminal is disabled).
 	//   * file descriptors to connect to gofer to serve the root filesystem.
@@ -280,12 +399,14 @@ type StartArgs struct {
 }
 
 // StartSubcontainer runs a created container within a sandbox.
-func (cm *containerManager) StartSubcontainer(args *StartArgs, _ *struct{}) error {
+func (cm *containerManager) StartSubcontainer(args *StartArgs, _ *struct{}) (err error) {
 	// Validate arguments.
 	if args == nil {
 		return errors.New("start missing arguments")
 	}
 	log.Debugf("containerManager.StartSubcontainer, cid: %s, args: %+v", args.CID, args)
+	span := startSpan(args.TraceParent, "sandbox start", args.CID)
+	defer func() { span.End(err) }()
 	if args.Spec == nil {
 		return errors.New("start arguments missing spec")
 	}
@@ -341,6 +462,43 @@ func (cm *containerManager) StartSubcontainer(args *StartArgs, _ *struct{}) erro
 	return nil
 }
 
+// RestoreSubcontainer prepares a created container to be restored when the
+// root container is restored, which restores all containers of the sandbox.
+// Subcontainers must be restored before the root container.
+func (cm *containerManager) RestoreSubcontainer(args *StartArgs, _ *struct{}) (err error) {
+	if args == nil {
+		return errors.New("restore missing arguments")
+	}
+	log.Debugf("containerManager.RestoreSubcontainer, cid: %s", args.CID)
+	span := startSpan(args.TraceParent, "sandbox restore", args.CID)
+	defer func() { span.End(err) }()
+	if args.Spec == nil {
+		return errors.New("restore arguments missing spec")
+	}
+	if args.Conf == nil {
+		return errors.New("restore arguments missing config")
+	}
+	if args.CID == "" {
+		return errors.New("restore argument missing container ID")
+	}
+	// Restored processes keep their stdios, only gofer files are passed.
+	if len(args.Files) < 1 {
+		return fmt.Errorf("restore arguments must contain at least one file for the container root gofer")
+	}
+
+	goferFDs, err := fd.NewFromFiles(args.Files)
+	if err != nil {
+		return fmt.Errorf("error dup'ing gofer files: %w", err)
+	}
+	if err := cm.l.restoreSubcontainer(args.Spec, args.Conf, args.CID, goferFDs); err != nil {
+		for _, fd := range goferFDs {
+			_ = fd.Close()
+		}
+		return err
+	}
+	return nil
+}
+
 // DestroySubcontainer stops a container if it is still running and cleans up
 // its filesystem.
 func (cm *containerManager) DestroySubcontainer(cid *string, _ *struct{}) error {
@@ -350,9 +508,11 @@ func (cm *containerManager) DestroySubcontainer(cid *string, _ *struct{}) error
 
 // ExecuteAsync starts running a command on a created or running sandbox. It
 // returns the PID of the new process.
-func (cm *containerManager) ExecuteAsync(args *control.ExecArgs, pid *int32) error {
+func (cm *containerManager) ExecuteAsync(args *control.ExecArgs, pid *int32) (err error) {
 	log.Debugf("containerManager.ExecuteAsync, cid: %s, args: %+v", args.ContainerID, args)
-	tgid, err := cm.l.executeAsync(args)
+	span := startSpan(args.TraceParent, "sandbox exec", args.ContainerID)
+	defer func() { span.End(err) }()
+	tgid, err := cm.l.executeAsync(args, false /* healthCheck */)
 	if err != nil {
 		log.Debugf("containerManager.ExecuteAsync failed, cid: %s, args: %+v, err: %v", args.ContainerID, args, err)
 		return err
@@ -361,55 +521,229 @@ func (cm *containerManager) ExecuteAsync(args *control.ExecArgs, pid *int32) err
 	return nil
 }
 
+// ExecSession describes a process started with ExecuteAsync.
+type ExecSession struct {
+	// ID is the name of the session given in ExecArgs.ExecID, if any.
+	ID string `json:"id,omitempty"`
+
+	// PID is the PID of the process in the root PID namespace.
+	PID int32 `json:"pid"`
+
+	// Argv is the command line of the process.
+	Argv []string `json:"argv"`
+
+	// StartTime is the time the session was started.
+	StartTime gtime.Time `json:"startTime"`
+
+	// Exited is set if the process exited, but wasn't waited for yet.
+	Exited bool `json:"exited,omitempty"`
+
+	// ExitStatus is the wait status of the process, if it exited.
+	ExitStatus uint32 `json:"exitStatus,omitempty"`
+}
+
+// ExecSessions lists the exec sessions of the given container that weren't
+// waited for.
+func (cm *containerManager) ExecSessions(cid *string, out *[]ExecSession) error {
+	log.Debugf("containerManager.ExecSessions, cid: %s", *cid)
+	*out = cm.l.execSessions(*cid)
+	return nil
+}
+
+// KillExecSessionArgs are arguments to the KillExecSession method.
+type KillExecSessionArgs struct {
+	// CID is the container ID.
+	CID string
+
+	// ExecID is the name of the exec session.
+	ExecID string
+
+	// Signo is the signal to send.
+	Signo int32
+}
+
+// KillExecSession sends a signal to the process of an exec session.
+func (cm *containerManager) KillExecSession(args *KillExecSessionArgs, _ *struct{}) error {
+	log.Debugf("containerManager.KillExecSession, cid: %s, execID: %s, signal: %d", args.CID, args.ExecID, args.Signo)
+	return cm.l.killExecSession(args.CID, args.ExecID, args.Signo)
+}
+
+// ResizeTTYArgs are arguments to the ResizeTTY method.
+type ResizeTTYArgs struct {
+	// CID is the container ID.
+	CID string
+
+	// PID is the process whose TTY is resized, relative to the root PID
+	// namespace. If 0, the TTY of the container's init process is resized.
+	PID int32
+
+	// Winsize is the new window size.
+	Winsize linux.Winsize
+}
+
+// ResizeTTY changes the window size of the TTY attached to a process, which
+// must have been started with a TTY. SIGWINCH is sent to the foreground
+// process group of the TTY if the size changed.
+func (cm *containerManager) ResizeTTY(args *ResizeTTYArgs, _ *struct{}) error {
+	log.Debugf("containerManager.ResizeTTY, cid: %s, PID: %d, size: %dx%d", args.CID, args.PID, args.Winsize.Col, args.Winsize.Row)
+	return cm.l.resizeTTY(args.CID, kernel.ThreadID(args.PID), &args.Winsize)
+}
+
+// CheckpointOpts contains options for the Checkpoint call.
+type CheckpointOpts struct {
+	control.SaveOpts
+
+	// CID is the container being checkpointed.
+	CID string
+
+	// Exclude are directories of container CID whose contents are not saved.
+	// They must be on tmpfs, and are emptied before saving.
+	Exclude []string
+
+	// TraceParent is the W3C traceparent of the runsc span checkpointing
+	// the container, if any.
+	TraceParent string
+}
+
 // Checkpoint pauses a sandbox and saves its state.
-func (cm *containerManager) Checkpoint(o *control.SaveOpts, _ *struct{}) error {
-	log.Debugf("containerManager.Checkpoint")
-	// TODO(gvisor.dev/issues/6243): save/restore not supported w/ hostinet
-	if cm.l.root.conf.Network == config.NetworkHost {
-		return errors.New("checkpoint not supported when using hostinet")
+func (cm *containerManager) Checkpoint(o *CheckpointOpts, _ *struct{}) (err error) {
+	log.Debugf("containerManager.Checkpoint, cid: %s, exclude: %v", o.CID, o.Exclude)
+	span := startSpan(o.TraceParent, "sandbox checkpoint", o.CID)
+	defer func() { span.End(err) }()
+	// Host sockets are saved with TCP_REPAIR, which VFS1 doesn't support.
+	if cm.l.root.conf.Network == config.NetworkHost && !kernel.VFS2Enabled {
+		return errors.New("checkpoint with hostinet requires VFS2")
+	}
+	// The sandbox stops after the checkpoint.
+	cm.l.stopSnapshots()
+
+	metadata, err := cm.l.checkpointMetadata()
+	if err != nil {
+		return fmt.Errorf("getting checkpoint metadata: %v", err)
+	}
+	if o.Metadata == nil {
+		o.Metadata = make(map[string]string)
+	}
+	for k, v := range metadata {
+		o.Metadata[k] = v
+	}
+
+	// Keep tasks paused from the removal of excluded contents until the end
+	// of the save, so that they can't be filled again.
+	cm.l.k.Pause()
+	defer cm.l.k.Unpause()
+	if err := cm.l.removeExcludedContents(o.CID, o.Exclude); err != nil {
+		return err
 	}
 
 	state := control.State{
 		Kernel:   cm.l.k,
 		Watchdog: cm.l.watchdog,
 	}
-	return state.Save(o, nil)
+	return state.Save(&o.SaveOpts, nil)
+}
+
+// StartSnapshots starts taking snapshots of the sandbox periodically, leaving it
+// running, replacing the previous schedule if any.
+func (cm *containerManager) StartSnapshots(args *StartSnapshotsArgs, _ *struct{}) error {
+	log.Debugf("containerManager.StartSnapshots, interval: %v, files: %d", args.Interval, len(args.Files))
+	// Saving host TCP connections leaves them in repair mode, which only
+	// works if the sandbox stops after the save.
+	if cm.l.root.conf.Network == config.NetworkHost {
+		return errors.New("snapshots not supported when using hostinet")
+	}
+	// VFS1 can't keep running after a save, which drops its dirent caches and
+	// inode mappings.
+	if !kernel.VFS2Enabled {
+		return errors.New("snapshots require VFS2")
+	}
+
+	// The files of the call are closed once it returns.
+	files := make([]*os.File, 0, len(args.Files))
+	for _, f := range args.Files {
+		dup, err := fd.NewFromFile(f)
+		if err != nil {
+			closeFiles(files)
+			return fmt.Errorf("duplicating snapshot image: %v", err)
+		}
+		files = append(files, os.NewFile(uintptr(dup.Release()), f.Name()))
+	}
+	return cm.l.startSnapshots(args.Interval, files)
+}
+
+// StopSnapshots stops taking snapshots of the sandbox.
+func (cm *containerManager) StopSnapshots(_, _ *struct{}) error {
+	log.Debugf("containerManager.StopSnapshots")
+	cm.l.stopSnapshots()
+	return nil
 }
 
 // RestoreOpts contains options related to restoring a container's file system.
 type RestoreOpts struct {
 	// FilePayload contains the state file to be restored, followed by the
-	// platform device file if necessary.
+	// pages file if HasPagesFile is set, and the platform device file if
+	// necessary.
 	urpc.FilePayload
 
+	// HasPagesFile is set if memory pages were saved to a separate pages
+	// file, which is then loaded lazily.
+	HasPagesFile bool
+
 	// SandboxID contains the ID of the sandbox.
 	SandboxID string
+
+	// TraceParent is the W3C traceparent of the runsc span restoring the
+	// container, if any.
+	TraceParent string
 }
 
 // Restore loads a container from a statefile.
 // The container's current kernel is destroyed, a restore environment is
 // created, and the kernel is recreated with the restore state file. The
 // container then sends the signal to start.
-func (cm *containerManager) Restore(o *RestoreOpts, _ *struct{}) error {
+func (cm *containerManager) Restore(o *RestoreOpts, _ *struct{}) (err error) {
 	log.Debugf("containerManager.Restore")
+	span := startSpan(o.TraceParent, "sandbox restore", o.SandboxID)
+	defer func() { span.End(err) }()
 
-	var specFile, deviceFile *os.File
-	switch numFiles := len(o.Files); numFiles {
-	case 2:
+	var specFile, pagesFile, deviceFile *os.File
+	files := o.Files
+	if len(files) == 0 {
+		return fmt.Errorf("at least one file must be passed to Restore")
+	}
+	specFile, files = files[0], files[1:]
+	if o.HasPagesFile {
+		if len(files) == 0 {
+			return fmt.Errorf("pages file must be passed to Restore")
+		}
+		// The pages file is read after Restore returns, while urpc closes
+		// the files it received. dup it to get a new FD.
+		fd, err := unix.Dup(int(files[0].Fd()))
+		if err != nil {
+			return fmt.Errorf("failed to dup file: %v", err)
+		}
+		pagesFile = os.NewFile(uintptr(fd), files[0].Name())
+		files = files[1:]
+	}
+	switch len(files) {
+	case 1:
 		// The device file is donated to the platform.
 		// Can't take ownership away from os.File. dup them to get a new FD.
-		fd, err := unix.Dup(int(o.Files[1].Fd()))
+		fd, err := unix.Dup(int(files[0].Fd()))
 		if err != nil {
 			return fmt.Errorf("failed to dup file: %v", err)
 		}
 		deviceFile = os.NewFile(uintptr(fd), "platform device")
-		fallthrough
-	case 1:
-		specFile = o.Files[0]
 	case 0:
-		return fmt.Errorf("at least one file must be passed to Restore")
 	default:
-		return fmt.Errorf("at most two files may be passed to Restore")
+		return fmt.Errorf("too many files passed to Restore")
+	}
+
+	// Find the containers saved in the image matching the ones being
+	// restored.
+	saved, ids, err := cm.l.matchRestoredContainers(specFile, o.SandboxID)
+	if err != nil {
+		return err
 	}
 
 	// Pause the kernel while we build a new one.
@@ -434,7 +768,7 @@ func (cm *containerManager) Restore(o *RestoreOpts, _ *struct{}) error {
 	ctx := k.SupervisorContext()
 	mntr := newContainerMounter(&cm.l.root, cm.l.k, cm.l.mountHints, kernel.VFS2Enabled)
 	if kernel.VFS2Enabled {
-		ctx, err = mntr.configureRestore(ctx)
+		ctx, err = mntr.configureRestore(ctx, cm.l.restoringMounters())
 		if err != nil {
 			return fmt.Errorf("configuring filesystem restore: %v", err)
 		}
@@ -450,14 +784,6 @@ func (cm *containerManager) Restore(o *RestoreOpts, _ *struct{}) error {
 	if eps, ok := networkStack.(*netstack.Stack); ok {
 		stack.StackFromEnv = eps.Stack // FIXME(b/36201077)
 	}
-	info, err := specFile.Stat()
-	if err != nil {
-		return err
-	}
-	if info.Size() == 0 {
-		return fmt.Errorf("file cannot be empty")
-	}
-
 	if cm.l.root.conf.ProfileEnable {
 		// pprof.Initialize opens /proc/self/maps, so has to be called before
 		// installing seccomp filters.
@@ -470,10 +796,13 @@ func (cm *containerManager) Restore(o *RestoreOpts, _ *struct{}) error {
 	}
 
 	// Load the state.
-	loadOpts := state.LoadOpts{Source: specFile}
+	loadOpts := state.LoadOpts{Source: specFile, PagesFile: pagesFile}
 	if err := loadOpts.Load(ctx, k, nil, networkStack, time.NewCalibratedClocks(), &vfs.CompleteRestoreOptions{}); err != nil {
 		return err
 	}
+	if ids != nil {
+		k.RenameContainers(ids)
+	}
 
 	// Since we have a new kernel we also must make a new watchdog.
 	dogOpts := watchdog.DefaultOpts
@@ -487,16 +816,14 @@ func (cm *containerManager) Restore(o *RestoreOpts, _ *struct{}) error {
 	cm.l.restore = true
 
 	// Reinitialize the sandbox ID and processes map. Note that it doesn't
-	// restore the state of multiple containers, nor exec processes.
+	// restore exec processes.
 	cm.l.sandboxID = o.SandboxID
 	cm.l.mu.Lock()
-	eid := execID{cid: o.SandboxID}
-	cm.l.processes = map[execID]*execProcess{
-		eid: {
-			tg: cm.l.k.GlobalInit(),
-		},
-	}
+	err = cm.l.restoreProcessesLocked(saved, ids)
 	cm.l.mu.Unlock()
+	if err != nil {
+		return err
+	}
 
 	// Tell the root container to start and wait for the result.
 	cm.startChan <- struct{}{}
@@ -573,10 +900,15 @@ type SignalArgs struct {
 	Signo int32
 
 	// PID is the process ID in the given container that will be signaled,
-	// relative to the root PID namespace, not the container's.
+	// relative to the root PID namespace, not the container's, unless
+	// ContainerPID is set.
 	// If 0, the root container will be signalled.
 	PID int32
 
+	// ContainerPID indicates that PID is relative to the container's PID
+	// namespace.
+	ContainerPID bool
+
 	// Mode is the signal delivery mode.
 	Mode SignalDeliveryMode
 }
@@ -587,6 +919,13 @@ type SignalArgs struct {
 // indicated process, to all processes in the container, or to the foreground
 // process group.
 func (cm *containerManager) Signal(args *SignalArgs, _ *struct{}) error {
-	log.Debugf("containerManager.Signal: cid: %s, PID: %d, signal: %d, mode: %v", args.CID, args.PID, args.Signo, args.Mode)
-	return cm.l.signal(args.CID, args.PID, args.Signo, args.Mode)
+	log.Debugf("containerManager.Signal: cid: %s, PID: %d, container PID: %t, signal: %d, mode: %v", args.CID, args.PID, args.ContainerPID, args.Signo, args.Mode)
+	pid := args.PID
+	if args.ContainerPID && pid != 0 {
+		var err error
+		if pid, err = cm.l.rootPID(args.CID, pid); err != nil {
+			return err
+		}
+	}
+	return cm.l.signal(args.CID, pid, args.Signo, args.Mode)
 }
@@ -15,10 +15,14 @@
 package boot
 
 import (
+	"errors"
+
 	"gvisor.dev/gvisor/pkg/log"
 )
 
 type debug struct {
+	// logRing keeps the most recent log records. It may be nil.
+	logRing *log.Ring
 }
 
 // Stacks collects all sandbox stacks and copies them to 'stacks'.
@@ -27,3 +31,13 @@ func (*debug) Stacks(_ *struct{}, stacks *string) error {
 	*stacks = string(buf)
 	return nil
 }
+
+// Dmesg copies the most recent log records of the sandbox, oldest first, to
+// 'records'. They are kept even if logging to a file is disabled.
+func (d *debug) Dmesg(_ *struct{}, records *string) error {
+	if d.logRing == nil {
+		return errors.New("log records are not kept by the sandbox")
+	}
+	*records = string(d.logRing.Contents())
+	return nil
+}
@@ -0,0 +1,431 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boot
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/pkg/tcpip"
+	"gvisor.dev/gvisor/pkg/tcpip/adapters/gonet"
+	"gvisor.dev/gvisor/pkg/tcpip/network/ipv4"
+	"gvisor.dev/gvisor/pkg/tcpip/stack"
+)
+
+// DNSCacheAddr is the address of the caching DNS resolver started inside the
+// sandbox when DNS caching is enabled, see config.Config.DNSCache.
+var DNSCacheAddr = net.IPv4(127, 0, 0, 53).To4()
+
+const (
+	// dnsCacheMaxEntries is the maximum number of replies cached.
+	dnsCacheMaxEntries = 4096
+
+	// dnsCacheMaxTTL caps how long replies are cached, whatever their TTL.
+	dnsCacheMaxTTL = time.Hour
+
+	// dnsCacheMaxInFlight is the maximum number of queries being forwarded
+	// at the same time. Queries above the limit are dropped, and retried by
+	// the application.
+	dnsCacheMaxInFlight = 256
+
+	// dnsServerTimeout is how long a reply is waited for from each server.
+	dnsServerTimeout = 2 * time.Second
+
+	// dnsTypeOPT is the type of the EDNS pseudo-record, which has no TTL.
+	dnsTypeOPT = 41
+
+	// Response codes of replies that are cached.
+	dnsRcodeNoError  = 0
+	dnsRcodeNXDomain = 3
+)
+
+// dnsServer is a server that queries are forwarded to.
+type dnsServer struct {
+	addr  tcpip.FullAddress
+	proto tcpip.NetworkProtocolNumber
+}
+
+// dnsCacheEntry is a cached reply.
+type dnsCacheEntry struct {
+	// reply is the reply, as received from the server.
+	reply []byte
+
+	// ttlOffsets are the offsets of the TTL fields of the records in reply,
+	// which are decremented by the time spent in the cache when served.
+	ttlOffsets []int
+
+	// added is when the reply was cached.
+	added time.Time
+
+	// expires is when the reply must not be served anymore.
+	expires time.Time
+}
+
+// dnsCache is a DNS resolver that answers queries sent over UDP to
+// DNSCacheAddr from a cache, and forwards the other queries to a list of
+// servers through netstack. It saves round trips to the servers for
+// applications that resolve the same names over and over.
+//
+// Only standard queries with a single question are cached, keyed by the
+// question and the flags and EDNS options of the query. Replies are cached for
+// the lowest TTL of their records, including negative replies, which have the
+// SOA record of the zone in the authority section.
+type dnsCache struct {
+	// stack is the network stack used to reach the servers.
+	stack *stack.Stack
+
+	// conn is the netstack endpoint bound to DNSCacheAddr port 53.
+	conn *gonet.UDPConn
+
+	// servers are the servers that queries are forwarded to, in order.
+	servers []dnsServer
+
+	// inFlight limits the number of queries being forwarded.
+	inFlight chan struct{}
+
+	// mu protects entries.
+	mu sync.Mutex
+
+	// entries are the cached replies, keyed by dnsCacheKey.
+	entries map[string]*dnsCacheEntry
+}
+
+// newDNSCache returns a cache that forwards queries to servers, but doesn't
+// serve queries yet.
+func newDNSCache(s *stack.Stack, servers []net.UDPAddr) *dnsCache {
+	c := &dnsCache{
+		stack:    s,
+		inFlight: make(chan struct{}, dnsCacheMaxInFlight),
+		entries:  make(map[string]*dnsCacheEntry),
+	}
+	for _, server := range servers {
+		proto, addr := ipToAddressAndProto(server.IP)
+		c.servers = append(c.servers, dnsServer{
+			addr:  tcpip.FullAddress{Addr: addr, Port: uint16(server.Port)},
+			proto: proto,
+		})
+	}
+	return c
+}
+
+// startDNSCache adds DNSCacheAddr to the loopback NIC nicID and starts serving
+// queries sent to it, forwarding the ones that aren't cached to servers.
+func (n *Network) startDNSCache(nicID tcpip.NICID, servers []net.UDPAddr) error {
+	protocolAddr := tcpip.ProtocolAddress{
+		Protocol: ipv4.ProtocolNumber,
+		AddressWithPrefix: tcpip.AddressWithPrefix{
+			Address:   tcpip.Address(DNSCacheAddr),
+			PrefixLen: 32,
+		},
+	}
+	if err := n.Stack.AddProtocolAddress(nicID, protocolAddr, stack.AddressProperties{}); err != nil {
+		return fmt.Errorf("AddProtocolAddress(%d, %+v, {}) failed: %s", nicID, protocolAddr, err)
+	}
+	conn, err := gonet.DialUDP(n.Stack, &tcpip.FullAddress{
+		NIC:  nicID,
+		Addr: tcpip.Address(DNSCacheAddr),
+		Port: 53,
+	}, nil, ipv4.ProtocolNumber)
+	if err != nil {
+		return fmt.Errorf("binding to %v:53: %v", DNSCacheAddr, err)
+	}
+
+	c := newDNSCache(n.Stack, servers)
+	c.conn = conn
+	log.Infof("Caching DNS queries to %v:53, forwarding them to %v", DNSCacheAddr, servers)
+	go c.serve()
+	return nil
+}
+
+// serve reads queries from applications and answers them, either from the
+// cache or by forwarding them.
+func (c *dnsCache) serve() {
+	buf := make([]byte, dnsMaxMessageSize)
+	for {
+		n, addr, err := c.conn.ReadFrom(buf)
+		if err != nil {
+			log.Warningf("Reading DNS query: %v, stopping DNS cache", err)
+			return
+		}
+		if n < dnsHeaderSize {
+			continue
+		}
+		query := buf[:n]
+		key, ok := dnsCacheKey(query)
+		if ok {
+			if reply := c.lookup(key, query, time.Now()); reply != nil {
+				if _, err := c.conn.WriteTo(reply, addr); err != nil {
+					log.Debugf("Sending DNS reply to %v: %v", addr, err)
+				}
+				continue
+			}
+		}
+
+		select {
+		case c.inFlight <- struct{}{}:
+		default:
+			log.Debugf("Too many DNS queries in flight, dropping query from %v", addr)
+			continue
+		}
+		query = append([]byte(nil), query...)
+		go func() {
+			defer func() { <-c.inFlight }()
+			c.resolve(key, query, addr)
+		}()
+	}
+}
+
+// resolve forwards the query to the servers until one of them replies, caches
+// the reply under key unless it's empty, and sends the reply to addr.
+func (c *dnsCache) resolve(key string, query []byte, addr net.Addr) {
+	for _, server := range c.servers {
+		reply, err := c.exchange(server, query)
+		if err != nil {
+			log.Debugf("Forwarding DNS query to %s:%d: %v", server.addr.Addr, server.addr.Port, err)
+			continue
+		}
+		if key != "" {
+			c.store(key, reply, time.Now())
+		}
+		if _, err := c.conn.WriteTo(reply, addr); err != nil {
+			log.Debugf("Sending DNS reply to %v: %v", addr, err)
+		}
+		return
+	}
+}
+
+// exchange sends the query to the server and returns its reply.
+func (c *dnsCache) exchange(server dnsServer, query []byte) ([]byte, error) {
+	conn, err := gonet.DialUDP(c.stack, nil, &server.addr, server.proto)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(dnsServerTimeout)); err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, dnsMaxMessageSize)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		// Ignore anything that isn't a reply to the query.
+		if n >= dnsHeaderSize && binary.BigEndian.Uint16(buf) == binary.BigEndian.Uint16(query) && buf[2]&0x80 != 0 {
+			return buf[:n], nil
+		}
+	}
+}
+
+// lookup returns the cached reply for key, with the ID of the query, or nil if
+// there is none.
+func (c *dnsCache) lookup(key string, query []byte, now time.Time) []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	if !now.Before(e.expires) {
+		delete(c.entries, key)
+		return nil
+	}
+	reply := append([]byte(nil), e.reply...)
+	copy(reply, query[:2])
+	elapsed := uint32(now.Sub(e.added) / time.Second)
+	for _, off := range e.ttlOffsets {
+		ttl := binary.BigEndian.Uint32(reply[off:])
+		if ttl > elapsed {
+			ttl -= elapsed
+		} else {
+			ttl = 0
+		}
+		binary.BigEndian.PutUint32(reply[off:], ttl)
+	}
+	return reply
+}
+
+// store caches reply under key, if it can be cached.
+func (c *dnsCache) store(key string, reply []byte, now time.Time) {
+	ttl, ttlOffsets, ok := dnsReplyTTL(reply)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= dnsCacheMaxEntries {
+		for k, e := range c.entries {
+			if !now.Before(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		// Make room for the new entry by dropping an arbitrary one if
+		// none expired.
+		for k := range c.entries {
+			if len(c.entries) < dnsCacheMaxEntries {
+				break
+			}
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = &dnsCacheEntry{
+		reply:      append([]byte(nil), reply...),
+		ttlOffsets: ttlOffsets,
+		added:      now,
+		expires:    now.Add(ttl),
+	}
+}
+
+// dnsCacheKey returns the key that replies to the query in msg are cached
+// under. Returns false if replies to the query must not be cached.
+func dnsCacheKey(msg []byte) (string, bool) {
+	if len(msg) < dnsHeaderSize {
+		return "", false
+	}
+	// The query must be a standard query (QR and opcode are zero), with a
+	// single question and no answer or authority records.
+	if msg[2]&0xf8 != 0 {
+		return "", false
+	}
+	if binary.BigEndian.Uint16(msg[4:]) != 1 || binary.BigEndian.Uint16(msg[6:]) != 0 || binary.BigEndian.Uint16(msg[8:]) != 0 {
+		return "", false
+	}
+
+	// Names are compared case-insensitively. Queries don't use compression.
+	key := make([]byte, 0, len(msg)-dnsHeaderSize+2)
+	key = append(key, msg[2]&0x01, msg[3]&0x10) // RD and CD flags.
+	off := dnsHeaderSize
+	for {
+		if off >= len(msg) {
+			return "", false
+		}
+		l := int(msg[off])
+		if l&0xc0 != 0 || off+1+l > len(msg) {
+			return "", false
+		}
+		key = append(key, msg[off])
+		for _, b := range msg[off+1 : off+1+l] {
+			if 'A' <= b && b <= 'Z' {
+				b += 'a' - 'A'
+			}
+			key = append(key, b)
+		}
+		off += 1 + l
+		if l == 0 {
+			break
+		}
+	}
+	// The type and class of the question, and EDNS options in the additional
+	// section, which affect the reply.
+	if off+4 > len(msg) {
+		return "", false
+	}
+	key = append(key, msg[off:]...)
+	return string(key), true
+}
+
+// dnsSkipName returns the offset following the name at off in msg, or false if
+// the name is malformed.
+func dnsSkipName(msg []byte, off int) (int, bool) {
+	for off < len(msg) {
+		l := int(msg[off])
+		switch {
+		case l == 0:
+			return off + 1, true
+		case l&0xc0 == 0xc0:
+			// Compression pointer, which ends the name.
+			if off+2 > len(msg) {
+				return 0, false
+			}
+			return off + 2, true
+		case l&0xc0 != 0:
+			return 0, false
+		}
+		off += 1 + l
+	}
+	return 0, false
+}
+
+// dnsReplyTTL returns how long the reply in msg can be cached for, and the
+// offsets of the TTL fields of its records. Returns false if the reply must not
+// be cached.
+func dnsReplyTTL(msg []byte) (time.Duration, []int, bool) {
+	if len(msg) < dnsHeaderSize {
+		return 0, nil, false
+	}
+	// The message must be a reply. Truncated replies are retried over TCP by
+	// the application, don't cache them.
+	if msg[2]&0x80 == 0 || msg[2]&0x02 != 0 {
+		return 0, nil, false
+	}
+	if rcode := msg[3] & 0x0f; rcode != dnsRcodeNoError && rcode != dnsRcodeNXDomain {
+		return 0, nil, false
+	}
+	qdcount := int(binary.BigEndian.Uint16(msg[4:]))
+	records := int(binary.BigEndian.Uint16(msg[6:])) + int(binary.BigEndian.Uint16(msg[8:])) + int(binary.BigEndian.Uint16(msg[10:]))
+
+	off := dnsHeaderSize
+	for i := 0; i < qdcount; i++ {
+		var ok bool
+		if off, ok = dnsSkipName(msg, off); !ok {
+			return 0, nil, false
+		}
+		off += 4 // Type and class.
+	}
+
+	var (
+		minTTL     uint32
+		ttlOffsets []int
+	)
+	for i := 0; i < records; i++ {
+		var ok bool
+		if off, ok = dnsSkipName(msg, off); !ok {
+			return 0, nil, false
+		}
+		// Type, class, TTL and data length, followed by the data.
+		if off+10 > len(msg) {
+			return 0, nil, false
+		}
+		if binary.BigEndian.Uint16(msg[off:]) != dnsTypeOPT {
+			ttl := binary.BigEndian.Uint32(msg[off+4:])
+			if len(ttlOffsets) == 0 || ttl < minTTL {
+				minTTL = ttl
+			}
+			ttlOffsets = append(ttlOffsets, off+4)
+		}
+		off += 10 + int(binary.BigEndian.Uint16(msg[off+8:]))
+		if off > len(msg) {
+			return 0, nil, false
+		}
+	}
+	// Without records, there is nothing telling how long the reply is valid.
+	if len(ttlOffsets) == 0 || minTTL == 0 {
+		return 0, nil, false
+	}
+	ttl := time.Duration(minTTL) * time.Second
+	if ttl > dnsCacheMaxTTL {
+		ttl = dnsCacheMaxTTL
+	}
+	return ttl, ttlOffsets, true
+}
@@ -0,0 +1,175 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boot
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	dnsTypeA    = 1
+	dnsTypeAAAA = 28
+)
+
+// dnsTestQuery returns a query for name, with the RD flag set.
+func dnsTestQuery(id uint16, name string, qtype uint16) []byte {
+	msg := make([]byte, dnsHeaderSize)
+	binary.BigEndian.PutUint16(msg, id)
+	msg[2] = 0x01                          // RD.
+	binary.BigEndian.PutUint16(msg[4:], 1) // QDCOUNT.
+	for _, label := range strings.Split(name, ".") {
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+	msg = append(msg, 0, 0, 0, 0, 1) // Root label, type and class IN.
+	binary.BigEndian.PutUint16(msg[len(msg)-4:], qtype)
+	return msg
+}
+
+// dnsTestReply returns a reply to query with one A record per TTL, each
+// pointing to the name in the question.
+func dnsTestReply(query []byte, ttls ...uint32) []byte {
+	msg := append([]byte(nil), query...)
+	msg[2] |= 0x80                                         // QR.
+	binary.BigEndian.PutUint16(msg[6:], uint16(len(ttls))) // ANCOUNT.
+	for _, ttl := range ttls {
+		rr := make([]byte, 16)
+		binary.BigEndian.PutUint16(rr, 0xc000|dnsHeaderSize)
+		binary.BigEndian.PutUint16(rr[2:], dnsTypeA)
+		binary.BigEndian.PutUint16(rr[4:], 1)
+		binary.BigEndian.PutUint32(rr[6:], ttl)
+		binary.BigEndian.PutUint16(rr[10:], 4)
+		copy(rr[12:], []byte{10, 0, 0, 1})
+		msg = append(msg, rr...)
+	}
+	return msg
+}
+
+func TestDNSCacheKey(t *testing.T) {
+	key, ok := dnsCacheKey(dnsTestQuery(1, "example.com", dnsTypeA))
+	if !ok {
+		t.Fatalf("dnsCacheKey() failed")
+	}
+	// Query IDs and the case of names don't matter.
+	if got, ok := dnsCacheKey(dnsTestQuery(2, "ExAmple.COM", dnsTypeA)); !ok || got != key {
+		t.Errorf("dnsCacheKey() got different keys for the same question")
+	}
+	// Names and types do.
+	for _, query := range [][]byte{
+		dnsTestQuery(1, "example.org", dnsTypeA),
+		dnsTestQuery(1, "example.com", dnsTypeAAAA),
+	} {
+		if got, ok := dnsCacheKey(query); !ok || got == key {
+			t.Errorf("dnsCacheKey() got the same key for different questions")
+		}
+	}
+
+	// Only standard queries with a single question are cached.
+	reply := dnsTestReply(dnsTestQuery(1, "example.com", dnsTypeA), 60)
+	multi := dnsTestQuery(1, "example.com", dnsTypeA)
+	binary.BigEndian.PutUint16(multi[4:], 2)
+	query := dnsTestQuery(1, "example.com", dnsTypeA)
+	for name, msg := range map[string][]byte{
+		"reply":     reply,
+		"questions": multi,
+		"truncated": query[:len(query)-1],
+		"header":    query[:dnsHeaderSize-1],
+	} {
+		if _, ok := dnsCacheKey(msg); ok {
+			t.Errorf("dnsCacheKey(%s) succeeded", name)
+		}
+	}
+}
+
+func TestDNSReplyTTL(t *testing.T) {
+	query := dnsTestQuery(1, "example.com", dnsTypeA)
+	ttl, offsets, ok := dnsReplyTTL(dnsTestReply(query, 300, 60, 120))
+	if !ok {
+		t.Fatalf("dnsReplyTTL() failed")
+	}
+	if want := 60 * time.Second; ttl != want {
+		t.Errorf("dnsReplyTTL() got TTL %v, want %v", ttl, want)
+	}
+	if len(offsets) != 3 {
+		t.Errorf("dnsReplyTTL() got %d TTL offsets, want 3", len(offsets))
+	}
+	if ttl, _, ok := dnsReplyTTL(dnsTestReply(query, 1<<30)); !ok || ttl != dnsCacheMaxTTL {
+		t.Errorf("dnsReplyTTL() got TTL %v, want %v", ttl, dnsCacheMaxTTL)
+	}
+
+	truncated := dnsTestReply(query, 60)
+	truncated[2] |= 0x02
+	servfail := dnsTestReply(query, 60)
+	servfail[3] |= 2
+	short := dnsTestReply(query, 60)
+	for name, msg := range map[string][]byte{
+		"query":     query,
+		"empty":     dnsTestReply(query),
+		"zero TTL":  dnsTestReply(query, 60, 0),
+		"truncated": truncated,
+		"SERVFAIL":  servfail,
+		"short":     short[:len(short)-1],
+	} {
+		if _, _, ok := dnsReplyTTL(msg); ok {
+			t.Errorf("dnsReplyTTL(%s) succeeded", name)
+		}
+	}
+}
+
+func TestDNSCacheLookup(t *testing.T) {
+	c := newDNSCache(nil, nil)
+	now := time.Now()
+	query := dnsTestQuery(1, "example.com", dnsTypeA)
+	key, _ := dnsCacheKey(query)
+	c.store(key, dnsTestReply(query, 60), now)
+
+	// Cached replies get the ID of the query, and TTLs decremented by the
+	// time spent in the cache.
+	query = dnsTestQuery(2, "example.com", dnsTypeA)
+	reply := c.lookup(key, query, now.Add(10*time.Second))
+	if reply == nil {
+		t.Fatalf("lookup() found no reply")
+	}
+	if got := binary.BigEndian.Uint16(reply); got != 2 {
+		t.Errorf("lookup() got ID %d, want 2", got)
+	}
+	_, offsets, _ := dnsReplyTTL(reply)
+	if got := binary.BigEndian.Uint32(reply[offsets[0]:]); got != 50 {
+		t.Errorf("lookup() got TTL %d, want 50", got)
+	}
+
+	if reply := c.lookup(key, query, now.Add(time.Minute)); reply != nil {
+		t.Errorf("lookup() found an expired reply")
+	}
+	if _, ok := c.entries[key]; ok {
+		t.Errorf("expired reply wasn't removed from the cache")
+	}
+}
+
+func TestDNSCacheEviction(t *testing.T) {
+	c := newDNSCache(nil, nil)
+	now := time.Now()
+	for i := 0; i < dnsCacheMaxEntries+10; i++ {
+		query := dnsTestQuery(1, "example.com", uint16(i))
+		key, _ := dnsCacheKey(query)
+		c.store(key, dnsTestReply(query, 60), now)
+	}
+	if got := len(c.entries); got != dnsCacheMaxEntries {
+		t.Errorf("cache has %d entries, want %d", got, dnsCacheMaxEntries)
+	}
+}
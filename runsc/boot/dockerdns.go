@@ -0,0 +1,220 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boot
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+	"gvisor.dev/gvisor/pkg/fd"
+	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/pkg/tcpip"
+	"gvisor.dev/gvisor/pkg/tcpip/adapters/gonet"
+	"gvisor.dev/gvisor/pkg/tcpip/network/ipv4"
+	"gvisor.dev/gvisor/pkg/tcpip/stack"
+)
+
+// DockerDNSAddr is the address of Docker's embedded DNS server, which is used
+// by containers attached to user-defined networks. The server listens on the
+// loopback interface of the container's network namespace, which netstack
+// doesn't have access to, so queries are forwarded to it by the sandbox.
+var DockerDNSAddr = net.IPv4(127, 0, 0, 11).To4()
+
+const (
+	// dnsHeaderSize is the size of the fixed DNS message header.
+	dnsHeaderSize = 12
+
+	// dnsMaxMessageSize is the largest DNS message forwarded. UDP messages
+	// are limited to 512 bytes unless EDNS is used, which raises the limit
+	// up to the maximum UDP payload size.
+	dnsMaxMessageSize = 65535
+
+	// dnsQueryTimeout is how long queries are waited on for a reply before
+	// they are forgotten.
+	dnsQueryTimeout = 10 * time.Second
+)
+
+// dnsQuery is a query that has been forwarded and is waiting for a reply.
+type dnsQuery struct {
+	// id is the ID of the query, as sent by the application.
+	id uint16
+
+	// addr is the address the query came from.
+	addr net.Addr
+
+	// sent is when the query was forwarded.
+	sent time.Time
+}
+
+// dnsForwarder forwards DNS queries sent over UDP to DockerDNSAddr to Docker's
+// embedded DNS server, using a connected host UDP socket donated to the
+// sandbox.
+//
+// Since all queries share the host socket, query IDs are rewritten to be
+// unique among the queries in flight, and replies are matched back to the
+// application that sent the query using the rewritten ID.
+type dnsForwarder struct {
+	// conn is the netstack endpoint bound to DockerDNSAddr port 53.
+	conn *gonet.UDPConn
+
+	// host is the host socket connected to Docker's embedded DNS server.
+	host *fd.FD
+
+	// mu protects the fields below.
+	mu sync.Mutex
+
+	// nextID is the next ID to try to assign to a forwarded query.
+	nextID uint16
+
+	// pending are the queries waiting for a reply, keyed by the ID of the
+	// forwarded query.
+	pending map[uint16]dnsQuery
+}
+
+// startDockerDNSForwarder adds DockerDNSAddr to the loopback NIC nicID and
+// starts forwarding queries sent to it to the host socket hostFD, which the
+// forwarder takes ownership of.
+func (n *Network) startDockerDNSForwarder(nicID tcpip.NICID, hostFD int) error {
+	host := fd.New(hostFD)
+	protocolAddr := tcpip.ProtocolAddress{
+		Protocol: ipv4.ProtocolNumber,
+		AddressWithPrefix: tcpip.AddressWithPrefix{
+			Address:   tcpip.Address(DockerDNSAddr),
+			PrefixLen: 32,
+		},
+	}
+	if err := n.Stack.AddProtocolAddress(nicID, protocolAddr, stack.AddressProperties{}); err != nil {
+		host.Close()
+		return fmt.Errorf("AddProtocolAddress(%d, %+v, {}) failed: %s", nicID, protocolAddr, err)
+	}
+	conn, err := gonet.DialUDP(n.Stack, &tcpip.FullAddress{
+		NIC:  nicID,
+		Addr: tcpip.Address(DockerDNSAddr),
+		Port: 53,
+	}, nil, ipv4.ProtocolNumber)
+	if err != nil {
+		host.Close()
+		return fmt.Errorf("binding to %v:53: %v", DockerDNSAddr, err)
+	}
+
+	f := &dnsForwarder{
+		conn:    conn,
+		host:    host,
+		pending: make(map[uint16]dnsQuery),
+	}
+	log.Infof("Forwarding DNS queries to %v:53 to the host", DockerDNSAddr)
+	go f.forwardQueries()
+	go f.forwardReplies()
+	return nil
+}
+
+// forwardQueries reads queries from applications and sends them to the host.
+func (f *dnsForwarder) forwardQueries() {
+	buf := make([]byte, dnsMaxMessageSize)
+	for {
+		n, addr, err := f.conn.ReadFrom(buf)
+		if err != nil {
+			log.Warningf("Reading DNS query: %v, stopping DNS forwarding", err)
+			return
+		}
+		msg := buf[:n]
+		if !f.track(msg, addr, time.Now()) {
+			continue
+		}
+		if _, err := unix.Write(f.host.FD(), msg); err != nil {
+			log.Debugf("Forwarding DNS query: %v", err)
+		}
+	}
+}
+
+// forwardReplies reads replies from the host and sends them back to the
+// applications that sent the queries.
+func (f *dnsForwarder) forwardReplies() {
+	buf := make([]byte, dnsMaxMessageSize)
+	for {
+		n, err := unix.Read(f.host.FD(), buf)
+		if err != nil {
+			if err == unix.EINTR || err == unix.ECONNREFUSED {
+				// ECONNREFUSED is reported when a query couldn't be
+				// delivered, e.g. because dockerd is restarting.
+				continue
+			}
+			log.Warningf("Reading DNS reply: %v, stopping DNS forwarding", err)
+			return
+		}
+		msg := buf[:n]
+		addr, ok := f.untrack(msg)
+		if !ok {
+			continue
+		}
+		if _, err := f.conn.WriteTo(msg, addr); err != nil {
+			log.Debugf("Forwarding DNS reply to %v: %v", addr, err)
+		}
+	}
+}
+
+// track assigns a new ID to the query in msg, which is rewritten in place,
+// and records where the query came from. Returns false if the query must be
+// dropped.
+func (f *dnsForwarder) track(msg []byte, addr net.Addr, now time.Time) bool {
+	if len(msg) < dnsHeaderSize {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// Find an ID that isn't in use, expiring stale queries along the way.
+	for i := 0; i <= 0xffff; i++ {
+		id := f.nextID
+		f.nextID++
+		q, ok := f.pending[id]
+		if ok && now.Sub(q.sent) < dnsQueryTimeout {
+			continue
+		}
+		f.pending[id] = dnsQuery{
+			id:   binary.BigEndian.Uint16(msg),
+			addr: addr,
+			sent: now,
+		}
+		binary.BigEndian.PutUint16(msg, id)
+		return true
+	}
+	log.Debugf("Too many DNS queries in flight, dropping query from %v", addr)
+	return false
+}
+
+// untrack restores the original ID of the query the reply in msg is for,
+// rewriting it in place, and returns where the query came from. Returns false
+// if the reply doesn't match any query.
+func (f *dnsForwarder) untrack(msg []byte) (net.Addr, bool) {
+	if len(msg) < dnsHeaderSize {
+		return nil, false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	id := binary.BigEndian.Uint16(msg)
+	q, ok := f.pending[id]
+	if !ok {
+		return nil, false
+	}
+	delete(f.pending, id)
+	binary.BigEndian.PutUint16(msg, q.id)
+	return q.addr, true
+}
@@ -0,0 +1,99 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boot
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+func dnsMessage(id uint16) []byte {
+	msg := make([]byte, dnsHeaderSize+4)
+	binary.BigEndian.PutUint16(msg, id)
+	return msg
+}
+
+func TestDNSForwarderIDs(t *testing.T) {
+	f := &dnsForwarder{pending: make(map[uint16]dnsQuery)}
+	now := time.Now()
+	addr1 := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1000}
+	addr2 := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 2000}
+
+	// Two applications using the same query ID must get different IDs.
+	q1, q2 := dnsMessage(42), dnsMessage(42)
+	if !f.track(q1, addr1, now) || !f.track(q2, addr2, now) {
+		t.Fatalf("track() failed")
+	}
+	id1, id2 := binary.BigEndian.Uint16(q1), binary.BigEndian.Uint16(q2)
+	if id1 == id2 {
+		t.Fatalf("queries were assigned the same ID %d", id1)
+	}
+
+	// Replies are matched back to the right application, with the original ID.
+	for _, tc := range []struct {
+		id   uint16
+		addr net.Addr
+	}{
+		{id: id2, addr: addr2},
+		{id: id1, addr: addr1},
+	} {
+		reply := dnsMessage(tc.id)
+		addr, ok := f.untrack(reply)
+		if !ok {
+			t.Fatalf("untrack(%d) failed", tc.id)
+		}
+		if addr != tc.addr {
+			t.Errorf("untrack(%d) got address %v, want %v", tc.id, addr, tc.addr)
+		}
+		if got := binary.BigEndian.Uint16(reply); got != 42 {
+			t.Errorf("untrack(%d) got ID %d, want 42", tc.id, got)
+		}
+	}
+
+	// Replies are only delivered once.
+	if _, ok := f.untrack(dnsMessage(id1)); ok {
+		t.Errorf("untrack(%d) succeeded for a reply that was already delivered", id1)
+	}
+}
+
+func TestDNSForwarderExhaustion(t *testing.T) {
+	f := &dnsForwarder{pending: make(map[uint16]dnsQuery)}
+	now := time.Now()
+	addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1000}
+	for i := 0; i <= 0xffff; i++ {
+		if !f.track(dnsMessage(0), addr, now) {
+			t.Fatalf("track() failed after %d queries", i)
+		}
+	}
+	if f.track(dnsMessage(0), addr, now) {
+		t.Errorf("track() succeeded with all IDs in use")
+	}
+	// IDs of queries that timed out are reused.
+	if !f.track(dnsMessage(0), addr, now.Add(dnsQueryTimeout)) {
+		t.Errorf("track() failed after queries timed out")
+	}
+}
+
+func TestDNSForwarderShortMessage(t *testing.T) {
+	f := &dnsForwarder{pending: make(map[uint16]dnsQuery)}
+	if f.track(make([]byte, dnsHeaderSize-1), &net.UDPAddr{}, time.Now()) {
+		t.Errorf("track() succeeded with a truncated header")
+	}
+	if _, ok := f.untrack(make([]byte, dnsHeaderSize-1)); ok {
+		t.Errorf("untrack() succeeded with a truncated header")
+	}
+}
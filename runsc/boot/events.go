@@ -15,8 +15,17 @@
 package boot
 
 import (
+	"math"
+	"sort"
+
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/log"
 	"gvisor.dev/gvisor/pkg/sentry/control"
+	"gvisor.dev/gvisor/pkg/sentry/inet"
+	"gvisor.dev/gvisor/pkg/sentry/kernel"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/auth"
 	"gvisor.dev/gvisor/pkg/sentry/usage"
+	"gvisor.dev/gvisor/pkg/sentry/vfs"
 )
 
 // EventOut is the return type of the Event command.
@@ -25,6 +34,18 @@ type EventOut struct {
 
 	// ContainerUsage maps each container ID to its total CPU usage.
 	ContainerUsage map[string]uint64 `json:"containerUsage"`
+
+	// ContainerStats maps each container ID to the resources used by the
+	// container's processes, as accounted by the sentry.
+	ContainerStats map[string]Stats `json:"containerStats,omitempty"`
+
+	// ContainerHealth maps container IDs to the result of their health
+	// checks. Only containers with a health check configured are present.
+	ContainerHealth map[string]HealthStatus `json:"containerHealth,omitempty"`
+
+	// SandboxCPU is the CPU time used by the sandbox, split between the
+	// application and the sentry.
+	SandboxCPU *control.CPUBreakdown `json:"sandboxCPU,omitempty"`
 }
 
 // Event struct for encoding the event data to JSON. Corresponds to runc's
@@ -33,14 +54,92 @@ type Event struct {
 	Type string `json:"type"`
 	ID   string `json:"id"`
 	Data Stats  `json:"data"`
+
+	// Health is the health check status of the container, if configured.
+	Health *HealthStatus `json:"health,omitempty"`
 }
 
 // Stats is the runc specific stats structure for stability when encoding and
 // decoding stats.
 type Stats struct {
-	CPU    CPU    `json:"cpu"`
-	Memory Memory `json:"memory"`
-	Pids   Pids   `json:"pids"`
+	CPU               CPU                 `json:"cpu"`
+	Memory            Memory              `json:"memory"`
+	Pids              Pids                `json:"pids"`
+	NetworkInterfaces []*NetworkInterface `json:"network_interfaces,omitempty"`
+
+	// TCP contains statistics of the TCP protocol, shared by all interfaces.
+	// It isn't part of runc's stats.
+	TCP *TCP `json:"tcp,omitempty"`
+
+	// RootFS contains statistics of the writable layer of the container's
+	// root filesystem, if it's an overlay. It isn't part of runc's stats.
+	RootFS *Filesystem `json:"rootfs,omitempty"`
+
+	// Blkio contains stats on block IO. Only pressure stall information is
+	// reported.
+	Blkio *Blkio `json:"blkio,omitempty"`
+}
+
+// PSIData contains pressure stall information of a kind. Corresponds to
+// runc's types.PSIData.
+type PSIData struct {
+	// Avg10, Avg60 and Avg300 are the percentages of time spent stalled over
+	// the last 10, 60 and 300 seconds.
+	Avg10  float64 `json:"avg10"`
+	Avg60  float64 `json:"avg60"`
+	Avg300 float64 `json:"avg300"`
+
+	// Total is the total time spent stalled, in microseconds.
+	Total uint64 `json:"total"`
+}
+
+// PSIStats contains pressure stall information on a resource. Corresponds to
+// runc's types.PSIStats.
+type PSIStats struct {
+	Some PSIData `json:"some,omitempty"`
+	Full PSIData `json:"full,omitempty"`
+}
+
+// Blkio contains stats on block IO.
+type Blkio struct {
+	PSI *PSIStats `json:"psi,omitempty"`
+}
+
+// Filesystem contains stats on the writable layer of an overlay. Limits are 0
+// if unlimited.
+type Filesystem struct {
+	Bytes       uint64 `json:"bytes"`
+	BytesLimit  uint64 `json:"bytesLimit,omitempty"`
+	Inodes      uint64 `json:"inodes,omitempty"`
+	InodesLimit uint64 `json:"inodesLimit,omitempty"`
+}
+
+// NetworkInterface contains stats on a network interface. Corresponds to
+// runc's types.NetworkInterface, which has no JSON tags.
+type NetworkInterface struct {
+	// Name is the name of the network interface.
+	Name string
+
+	RxBytes   uint64
+	RxPackets uint64
+	RxErrors  uint64
+	RxDropped uint64
+	TxBytes   uint64
+	TxPackets uint64
+	TxErrors  uint64
+	TxDropped uint64
+}
+
+// TCP contains stats on the TCP protocol.
+type TCP struct {
+	ActiveOpens  uint64 `json:"activeOpens"`
+	PassiveOpens uint64 `json:"passiveOpens"`
+	CurrEstab    uint64 `json:"currEstab"`
+	InSegs       uint64 `json:"inSegs"`
+	OutSegs      uint64 `json:"outSegs"`
+	RetransSegs  uint64 `json:"retransSegs"`
+	InErrs       uint64 `json:"inErrs"`
+	OutRsts      uint64 `json:"outRsts"`
 }
 
 // Pids contains stats on processes.
@@ -65,11 +164,13 @@ type Memory struct {
 	Kernel    MemoryEntry       `json:"kernel,omitempty"`
 	KernelTCP MemoryEntry       `json:"kernelTCP,omitempty"`
 	Raw       map[string]uint64 `json:"raw,omitempty"`
+	PSI       *PSIStats         `json:"psi,omitempty"`
 }
 
 // CPU contains stats on the CPU.
 type CPU struct {
-	Usage CPUUsage `json:"usage"`
+	Usage CPUUsage  `json:"usage"`
+	PSI   *PSIStats `json:"psi,omitempty"`
 }
 
 // CPUUsage contains stats on CPU usage.
@@ -88,21 +189,196 @@ func (cm *containerManager) Event(_ *struct{}, out *EventOut) error {
 		},
 	}
 
-	// Memory usage.
-	// TODO(gvisor.dev/issue/172): Per-container accounting.
+	// Memory usage for the whole sandbox. This includes memory that is not
+	// attributed to any process, e.g. page cache and tmpfs files.
 	mem := cm.l.k.MemoryFile()
 	_ = mem.UpdateUsage() // best effort to update.
-	_, totalUsage := usage.MemoryAccounting.Copy()
-	out.Event.Data.Memory.Usage = MemoryEntry{
-		Usage: totalUsage,
-	}
+	memStats, totalUsage := usage.MemoryAccounting.Copy()
+	out.Event.Data.Memory = memoryStats(memStats, totalUsage)
 
 	// PIDs.
-	// TODO(gvisor.dev/issue/172): Per-container accounting.
 	out.Event.Data.Pids.Current = uint64(len(cm.l.k.TaskSet().Root.ThreadGroups()))
 
+	// Network usage of the sandbox.
+	if stack := cm.l.k.RootNetworkNamespace().Stack(); stack != nil {
+		out.Event.Data.NetworkInterfaces, out.Event.Data.TCP = networkStats(stack)
+	}
+
 	// CPU usage by container.
 	out.ContainerUsage = control.ContainerUsage(cm.l.k)
 
+	// Resource usage by container.
+	out.ContainerStats = make(map[string]Stats)
+	for cid, cs := range control.ContainerStats(cm.l.k) {
+		stats := Stats{
+			CPU: CPU{
+				Usage: CPUUsage{
+					Kernel: cs.SysTime,
+					User:   cs.UserTime,
+					Total:  cs.SysTime + cs.UserTime,
+				},
+			},
+			Memory: Memory{
+				Usage: MemoryEntry{
+					Usage: cs.RSS,
+				},
+			},
+			Pids: Pids{
+				Current: cs.Pids,
+			},
+			RootFS: cm.l.rootFSStats(cid),
+		}
+		if ps, ok := cm.l.k.ContainerPressure(cid); ok {
+			stats.CPU.PSI = psiStats(&ps[kernel.PressureCPU])
+			stats.Memory.PSI = psiStats(&ps[kernel.PressureMemory])
+			stats.Blkio = &Blkio{PSI: psiStats(&ps[kernel.PressureIO])}
+		}
+		out.ContainerStats[cid] = stats
+	}
+
+	// CPU usage split between the application and the sentry.
+	var cpu control.CPUBreakdown
+	if err := cm.l.usage().CPUBreakdown(nil, &cpu); err != nil {
+		log.Warningf("Error getting CPU breakdown: %v", err)
+	} else {
+		out.SandboxCPU = &cpu
+	}
+
+	// Health check results by container.
+	out.ContainerHealth = cm.l.healthStatus()
+
 	return nil
 }
+
+// memoryStats returns the memory statistics of the sandbox, with the
+// breakdown of its usage under the names of the memory.stat file of the host
+// memory cgroup, which tools like cAdvisor read from the raw statistics.
+func memoryStats(stats usage.MemoryStats, total uint64) Memory {
+	// Like the host kernel, count page cache and tmpfs files, which are
+	// backed by shared memory, as cache.
+	cache := stats.PageCache + stats.Tmpfs
+	return Memory{
+		Cache: cache,
+		Usage: MemoryEntry{
+			Usage: total,
+		},
+		Raw: map[string]uint64{
+			"cache":       cache,
+			"rss":         stats.Anonymous,
+			"mapped_file": stats.Mapped,
+			"shmem":       stats.Tmpfs,
+		},
+	}
+}
+
+// psiStats converts the pressure stall information of a resource.
+func psiStats(rp *kernel.ResourcePressure) *PSIStats {
+	data := func(l *kernel.PressureLine) PSIData {
+		return PSIData{
+			Avg10:  l.Avg10,
+			Avg60:  l.Avg60,
+			Avg300: l.Avg300,
+			Total:  uint64(l.Total.Microseconds()),
+		}
+	}
+	return &PSIStats{
+		Some: data(&rp.Some),
+		Full: data(&rp.Full),
+	}
+}
+
+// rootFSStats returns the usage of the writable layer of the root filesystem
+// of the container, or nil if it's not an overlay.
+func (l *Loader) rootFSStats(cid string) *Filesystem {
+	tg, err := l.threadGroupFromID(execID{cid: cid})
+	if err != nil || tg.Leader() == nil {
+		return nil
+	}
+	mns := tg.Leader().MountNamespaceVFS2()
+	if mns == nil || !mns.TryIncRef() {
+		return nil
+	}
+	ctx := l.k.SupervisorContext()
+	defer mns.DecRef(ctx)
+	root := mns.Root()
+	root.IncRef()
+	defer root.DecRef(ctx)
+
+	// The statfs(2) information of an overlay is the one of its writable
+	// layer, see overlay.filesystem.statFS.
+	creds := auth.NewRootCredentials(l.k.RootUserNamespace())
+	st, err := l.k.VFS().StatFSAt(ctx, creds, &vfs.PathOperation{
+		Root:  root,
+		Start: root,
+	})
+	if err != nil {
+		log.Warningf("Error getting root filesystem statistics of container %q: %v", cid, err)
+		return nil
+	}
+	if st.Type != linux.OVERLAYFS_SUPER_MAGIC {
+		return nil
+	}
+	fs := &Filesystem{
+		Bytes: (st.Blocks - st.BlocksFree) * uint64(st.BlockSize),
+	}
+	if st.Blocks < math.MaxInt64/uint64(st.BlockSize) {
+		// Without a size limit, tmpfs reports a very large but non-zero
+		// size, see tmpfs.globalStatfs.
+		fs.BytesLimit = st.Blocks * uint64(st.BlockSize)
+	}
+	if st.Files != 0 {
+		// Don't count the root of the writable layer, see
+		// overlayQuotaData.
+		fs.Inodes = st.Files - st.FilesFree - 1
+		fs.InodesLimit = st.Files - 1
+	}
+	return fs
+}
+
+// networkStats returns the statistics of the interfaces of stack, except
+// loopback, and the statistics of its TCP protocol.
+func networkStats(stack inet.Stack) ([]*NetworkInterface, *TCP) {
+	var ifaces []*NetworkInterface
+	for _, i := range stack.Interfaces() {
+		if i.Flags&linux.IFF_LOOPBACK != 0 {
+			continue
+		}
+		var dev inet.StatDev
+		if err := stack.Statistics(&dev, i.Name); err != nil {
+			log.Warningf("Error getting statistics of interface %q: %v", i.Name, err)
+			continue
+		}
+		// Fields are in the order of /proc/net/dev.
+		ifaces = append(ifaces, &NetworkInterface{
+			Name:      i.Name,
+			RxBytes:   dev[0],
+			RxPackets: dev[1],
+			RxErrors:  dev[2],
+			RxDropped: dev[3],
+			TxBytes:   dev[8],
+			TxPackets: dev[9],
+			TxErrors:  dev[10],
+			TxDropped: dev[11],
+		})
+	}
+	sort.Slice(ifaces, func(i, j int) bool {
+		return ifaces[i].Name < ifaces[j].Name
+	})
+
+	var snmp inet.StatSNMPTCP
+	if err := stack.Statistics(&snmp, ""); err != nil {
+		log.Warningf("Error getting TCP statistics: %v", err)
+		return ifaces, nil
+	}
+	// Fields are in the order of the Tcp line of /proc/net/snmp.
+	return ifaces, &TCP{
+		ActiveOpens:  snmp[4],
+		PassiveOpens: snmp[5],
+		CurrEstab:    snmp[8],
+		InSegs:       snmp[9],
+		OutSegs:      snmp[10],
+		RetransSegs:  snmp[11],
+		InErrs:       snmp[12],
+		OutRsts:      snmp[13],
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boot
+
+import (
+	"testing"
+
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/sentry/inet"
+	"gvisor.dev/gvisor/pkg/sentry/usage"
+)
+
+// statsStack is an inet.Stack returning fixed statistics.
+type statsStack struct {
+	*inet.TestStack
+	devs map[string]inet.StatDev
+	tcp  inet.StatSNMPTCP
+}
+
+// Statistics implements inet.Stack.Statistics.
+func (s *statsStack) Statistics(stat interface{}, arg string) error {
+	switch stat := stat.(type) {
+	case *inet.StatDev:
+		*stat = s.devs[arg]
+	case *inet.StatSNMPTCP:
+		*stat = s.tcp
+	}
+	return nil
+}
+
+func TestNetworkStats(t *testing.T) {
+	s := &statsStack{
+		TestStack: inet.NewTestStack(),
+		devs: map[string]inet.StatDev{
+			"lo":   {1, 1},
+			"eth0": {100, 10, 1, 2, 0, 0, 0, 0, 200, 20, 3, 4},
+			"eth1": {300, 30},
+		},
+	}
+	s.tcp[11] = 5 // RetransSegs.
+	s.InterfacesMap = map[int32]inet.Interface{
+		1: {Name: "lo", Flags: linux.IFF_UP | linux.IFF_LOOPBACK},
+		2: {Name: "eth1", Flags: linux.IFF_UP},
+		3: {Name: "eth0", Flags: linux.IFF_UP},
+	}
+
+	ifaces, tcp := networkStats(s)
+	want := []NetworkInterface{
+		{
+			Name:      "eth0",
+			RxBytes:   100,
+			RxPackets: 10,
+			RxErrors:  1,
+			RxDropped: 2,
+			TxBytes:   200,
+			TxPackets: 20,
+			TxErrors:  3,
+			TxDropped: 4,
+		},
+		{
+			Name:      "eth1",
+			RxBytes:   300,
+			RxPackets: 30,
+		},
+	}
+	if len(ifaces) != len(want) {
+		t.Fatalf("got %d interfaces, want %d", len(ifaces), len(want))
+	}
+	for i := range want {
+		if *ifaces[i] != want[i] {
+			t.Errorf("interface %d: got %+v, want %+v", i, *ifaces[i], want[i])
+		}
+	}
+	if tcp == nil || tcp.RetransSegs != 5 {
+		t.Errorf("got TCP stats %+v, want RetransSegs = 5", tcp)
+	}
+}
+
+func TestMemoryStats(t *testing.T) {
+	mem := memoryStats(usage.MemoryStats{
+		System:    1,
+		Anonymous: 10,
+		PageCache: 20,
+		Tmpfs:     30,
+		Mapped:    5,
+	}, 61)
+	if mem.Usage.Usage != 61 {
+		t.Errorf("got usage %d, want 61", mem.Usage.Usage)
+	}
+	if mem.Cache != 50 {
+		t.Errorf("got cache %d, want 50", mem.Cache)
+	}
+	for name, want := range map[string]uint64{
+		"cache":       50,
+		"rss":         10,
+		"mapped_file": 5,
+		"shmem":       30,
+	} {
+		if got := mem.Raw[name]; got != want {
+			t.Errorf("got raw %q = %d, want %d", name, got, want)
+		}
+	}
+}
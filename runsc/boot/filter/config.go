@@ -376,6 +376,11 @@ func hostInetFilters() seccomp.SyscallRules {
 				seccomp.EqualTo(unix.SOL_IP),
 				seccomp.EqualTo(unix.IP_RECVTOS),
 			},
+			{
+				seccomp.MatchAny{},
+				seccomp.EqualTo(unix.SOL_IP),
+				seccomp.EqualTo(unix.IP_RECVTTL),
+			},
 			{
 				seccomp.MatchAny{},
 				seccomp.EqualTo(unix.SOL_IP),
@@ -401,6 +406,11 @@ func hostInetFilters() seccomp.SyscallRules {
 				seccomp.EqualTo(unix.SOL_IPV6),
 				seccomp.EqualTo(unix.IPV6_RECVTCLASS),
 			},
+			{
+				seccomp.MatchAny{},
+				seccomp.EqualTo(unix.SOL_IPV6),
+				seccomp.EqualTo(unix.IPV6_RECVHOPLIMIT),
+			},
 			{
 				seccomp.MatchAny{},
 				seccomp.EqualTo(unix.SOL_IPV6),
@@ -471,6 +481,22 @@ func hostInetFilters() seccomp.SyscallRules {
 				seccomp.EqualTo(unix.SOL_TCP),
 				seccomp.EqualTo(linux.TCP_INQ),
 			},
+			// Used to save TCP connections.
+			{
+				seccomp.MatchAny{},
+				seccomp.EqualTo(unix.SOL_TCP),
+				seccomp.EqualTo(linux.TCP_QUEUE_SEQ),
+			},
+			{
+				seccomp.MatchAny{},
+				seccomp.EqualTo(unix.SOL_TCP),
+				seccomp.EqualTo(linux.TCP_TIMESTAMP),
+			},
+			{
+				seccomp.MatchAny{},
+				seccomp.EqualTo(unix.SOL_TCP),
+				seccomp.EqualTo(linux.TCP_REPAIR_WINDOW),
+			},
 		},
 		unix.SYS_IOCTL: []seccomp.Rule{
 			{
@@ -539,6 +565,47 @@ func hostInetFilters() seccomp.SyscallRules {
 				seccomp.MatchAny{},
 				seccomp.EqualTo(4),
 			},
+			// Used to save and restore TCP connections.
+			{
+				seccomp.MatchAny{},
+				seccomp.EqualTo(unix.SOL_TCP),
+				seccomp.EqualTo(linux.TCP_REPAIR),
+				seccomp.MatchAny{},
+				seccomp.EqualTo(4),
+			},
+			{
+				seccomp.MatchAny{},
+				seccomp.EqualTo(unix.SOL_TCP),
+				seccomp.EqualTo(linux.TCP_REPAIR_QUEUE),
+				seccomp.MatchAny{},
+				seccomp.EqualTo(4),
+			},
+			{
+				seccomp.MatchAny{},
+				seccomp.EqualTo(unix.SOL_TCP),
+				seccomp.EqualTo(linux.TCP_QUEUE_SEQ),
+				seccomp.MatchAny{},
+				seccomp.EqualTo(4),
+			},
+			{
+				seccomp.MatchAny{},
+				seccomp.EqualTo(unix.SOL_TCP),
+				seccomp.EqualTo(linux.TCP_REPAIR_OPTIONS),
+			},
+			{
+				seccomp.MatchAny{},
+				seccomp.EqualTo(unix.SOL_TCP),
+				seccomp.EqualTo(linux.TCP_TIMESTAMP),
+				seccomp.MatchAny{},
+				seccomp.EqualTo(4),
+			},
+			{
+				seccomp.MatchAny{},
+				seccomp.EqualTo(unix.SOL_TCP),
+				seccomp.EqualTo(linux.TCP_REPAIR_WINDOW),
+				seccomp.MatchAny{},
+				seccomp.EqualTo(linux.SizeOfTCPRepairWindow),
+			},
 			{
 				seccomp.MatchAny{},
 				seccomp.EqualTo(unix.SOL_IP),
@@ -553,6 +620,13 @@ func hostInetFilters() seccomp.SyscallRules {
 				seccomp.MatchAny{},
 				seccomp.EqualTo(4),
 			},
+			{
+				seccomp.MatchAny{},
+				seccomp.EqualTo(unix.SOL_IP),
+				seccomp.EqualTo(unix.IP_RECVTTL),
+				seccomp.MatchAny{},
+				seccomp.EqualTo(4),
+			},
 			{
 				seccomp.MatchAny{},
 				seccomp.EqualTo(unix.SOL_IP),
@@ -588,6 +662,13 @@ func hostInetFilters() seccomp.SyscallRules {
 				seccomp.MatchAny{},
 				seccomp.EqualTo(4),
 			},
+			{
+				seccomp.MatchAny{},
+				seccomp.EqualTo(unix.SOL_IPV6),
+				seccomp.EqualTo(unix.IPV6_RECVHOPLIMIT),
+				seccomp.MatchAny{},
+				seccomp.EqualTo(4),
+			},
 			{
 				seccomp.MatchAny{},
 				seccomp.EqualTo(unix.SOL_IPV6),
@@ -650,6 +731,115 @@ func hostInetFilters() seccomp.SyscallRules {
 	}
 }
 
+// vsockFilters contains syscalls that are needed for AF_VSOCK sockets, which
+// are implemented by sentry/socket/hostinet.
+func vsockFilters() seccomp.SyscallRules {
+	return seccomp.SyscallRules{
+		unix.SYS_ACCEPT4: []seccomp.Rule{
+			{
+				seccomp.MatchAny{},
+				seccomp.MatchAny{},
+				seccomp.MatchAny{},
+				seccomp.EqualTo(unix.SOCK_NONBLOCK | unix.SOCK_CLOEXEC),
+			},
+		},
+		unix.SYS_BIND:        {},
+		unix.SYS_CONNECT:     {},
+		unix.SYS_GETPEERNAME: {},
+		unix.SYS_GETSOCKNAME: {},
+		unix.SYS_LISTEN:      {},
+		unix.SYS_READV:       {},
+		unix.SYS_RECVFROM:    {},
+		unix.SYS_RECVMSG:     {},
+		unix.SYS_SENDMSG:     {},
+		unix.SYS_SHUTDOWN: []seccomp.Rule{
+			{
+				seccomp.MatchAny{},
+				seccomp.EqualTo(unix.SHUT_RD),
+			},
+			{
+				seccomp.MatchAny{},
+				seccomp.EqualTo(unix.SHUT_WR),
+			},
+			{
+				seccomp.MatchAny{},
+				seccomp.EqualTo(unix.SHUT_RDWR),
+			},
+		},
+		unix.SYS_SOCKET: []seccomp.Rule{
+			{
+				seccomp.EqualTo(unix.AF_VSOCK),
+				seccomp.EqualTo(unix.SOCK_STREAM | unix.SOCK_NONBLOCK | unix.SOCK_CLOEXEC),
+				seccomp.EqualTo(0),
+			},
+			{
+				seccomp.EqualTo(unix.AF_UNIX),
+				seccomp.EqualTo(unix.SOCK_STREAM | unix.SOCK_NONBLOCK | unix.SOCK_CLOEXEC),
+				seccomp.EqualTo(0),
+			},
+		},
+		unix.SYS_WRITEV: {},
+	}
+}
+
+// xdpFilters contains syscalls that are needed to set up and use the AF_XDP
+// sockets of network links, see pkg/xdp.
+func xdpFilters() seccomp.SyscallRules {
+	return seccomp.SyscallRules{
+		unix.SYS_BIND: []seccomp.Rule{
+			{
+				seccomp.MatchAny{},
+				seccomp.MatchAny{},
+				seccomp.EqualTo(unix.SizeofSockaddrXDP),
+			},
+		},
+		unix.SYS_GETSOCKOPT: []seccomp.Rule{
+			{
+				seccomp.MatchAny{},
+				seccomp.EqualTo(unix.SOL_XDP),
+				seccomp.EqualTo(unix.XDP_MMAP_OFFSETS),
+			},
+		},
+		unix.SYS_SENDTO: []seccomp.Rule{
+			{
+				seccomp.MatchAny{},
+				seccomp.MatchAny{},
+				seccomp.EqualTo(0),
+				seccomp.EqualTo(unix.MSG_DONTWAIT),
+				seccomp.EqualTo(0),
+				seccomp.EqualTo(0),
+			},
+		},
+		unix.SYS_SETSOCKOPT: []seccomp.Rule{
+			{
+				seccomp.MatchAny{},
+				seccomp.EqualTo(unix.SOL_XDP),
+				seccomp.EqualTo(unix.XDP_UMEM_REG),
+			},
+			{
+				seccomp.MatchAny{},
+				seccomp.EqualTo(unix.SOL_XDP),
+				seccomp.EqualTo(unix.XDP_UMEM_FILL_RING),
+			},
+			{
+				seccomp.MatchAny{},
+				seccomp.EqualTo(unix.SOL_XDP),
+				seccomp.EqualTo(unix.XDP_UMEM_COMPLETION_RING),
+			},
+			{
+				seccomp.MatchAny{},
+				seccomp.EqualTo(unix.SOL_XDP),
+				seccomp.EqualTo(unix.XDP_RX_RING),
+			},
+			{
+				seccomp.MatchAny{},
+				seccomp.EqualTo(unix.SOL_XDP),
+				seccomp.EqualTo(unix.XDP_TX_RING),
+			},
+		},
+	}
+}
+
 func controlServerFilters(fd int) seccomp.SyscallRules {
 	return seccomp.SyscallRules{
 		unix.SYS_ACCEPT4: []seccomp.Rule{
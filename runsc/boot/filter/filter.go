@@ -29,6 +29,8 @@ type Options struct {
 	HostNetwork   bool
 	ProfileEnable bool
 	ControllerFD  int
+	Vsock         bool
+	XDP           bool
 }
 
 // Install installs seccomp filters for based on the given platform.
@@ -44,6 +46,13 @@ func Install(opt Options) error {
 		Report("host networking enabled: syscall filters less restrictive!")
 		s.Merge(hostInetFilters())
 	}
+	if opt.Vsock {
+		Report("vsock enabled: syscall filters less restrictive!")
+		s.Merge(vsockFilters())
+	}
+	if opt.XDP {
+		s.Merge(xdpFilters())
+	}
 	if opt.ProfileEnable {
 		Report("profile enabled: syscall filters less restrictive!")
 		s.Merge(profileFilters())
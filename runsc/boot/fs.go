@@ -61,6 +61,23 @@ const (
 	// MountPrefix is the annotation prefix for mount hints.
 	MountPrefix = "dev.gvisor.spec.mount."
 
+	// devShmHintName is the name of the mount hint added to share /dev/shm
+	// among the containers of a pod.
+	devShmHintName = "dev-shm"
+
+	// TmpfsPathsAnnotation is the annotation with a comma-separated list of
+	// paths where tmpfs is mounted when the root filesystem is read-only,
+	// e.g. "/tmp,/var/run".
+	TmpfsPathsAnnotation = "dev.gvisor.spec.tmpfs-paths"
+
+	// TmpfsSizeAnnotation is the annotation with the size limit of the mounts
+	// created for TmpfsPathsAnnotation, e.g. "64m".
+	TmpfsSizeAnnotation = "dev.gvisor.spec.tmpfs-size"
+
+	// defaultTmpfsSize is the size limit used for TmpfsPathsAnnotation mounts
+	// when TmpfsSizeAnnotation is not set.
+	defaultTmpfsSize = "64m"
+
 	// Supported filesystems that map to different internal filesystem.
 	bind   = "bind"
 	nonefs = "none"
@@ -69,6 +86,10 @@ const (
 // tmpfs has some extra supported options that we must pass through.
 var tmpfsAllowedData = []string{"mode", "uid", "gid"}
 
+// tmpfsAllowedDataVFS2 is like tmpfsAllowedData, but also includes options
+// that are only supported by VFS2.
+var tmpfsAllowedDataVFS2 = []string{"mode", "uid", "gid", "size"}
+
 func addOverlay(ctx context.Context, lower *fs.Inode, name string, lowerFlags fs.MountSourceFlags) (*fs.Inode, error) {
 	// Upper layer uses the same flags as lower, but it must be read-write.
 	upperFlags := lowerFlags
@@ -108,6 +129,7 @@ func compileMounts(spec *specs.Spec, conf *config.Config, vfs2Enabled bool) []sp
 	// Keep track of whether proc and sys were mounted.
 	var procMounted, sysMounted, devMounted, devptsMounted bool
 	var mounts []specs.Mount
+	mounted := make(map[string]struct{})
 
 	// Mount all submounts from the spec.
 	for _, m := range spec.Mounts {
@@ -132,6 +154,7 @@ func compileMounts(spec *specs.Spec, conf *config.Config, vfs2Enabled bool) []sp
 			m.Type = devpts.Name
 			devptsMounted = true
 		}
+		mounted[filepath.Clean(m.Destination)] = struct{}{}
 		mounts = append(mounts, m)
 	}
 
@@ -139,7 +162,11 @@ func compileMounts(spec *specs.Spec, conf *config.Config, vfs2Enabled bool) []sp
 	// says we SHOULD.
 	var mandatoryMounts []specs.Mount
 
-	if conf.Cgroupfs {
+	if systemdEnabled(spec, conf) {
+		// systemd needs its own set of cgroup hierarchies, which supersede
+		// the ones mounted with --cgroupfs.
+		mandatoryMounts = append(mandatoryMounts, systemdMounts(mounted)...)
+	} else if conf.Cgroupfs {
 		mandatoryMounts = append(mandatoryMounts, specs.Mount{
 			Type:        tmpfsvfs2.Name,
 			Destination: "/sys/fs/cgroup",
@@ -180,6 +207,7 @@ func compileMounts(spec *specs.Spec, conf *config.Config, vfs2Enabled bool) []sp
 			Destination: "/dev/pts",
 		})
 	}
+	mandatoryMounts = append(mandatoryMounts, tmpfsPathMounts(spec, mounted, vfs2Enabled)...)
 
 	// The mandatory mounts should be ordered right after the root, in case
 	// there are submounts of these mandatory mounts already in the spec.
@@ -188,8 +216,53 @@ func compileMounts(spec *specs.Spec, conf *config.Config, vfs2Enabled bool) []sp
 	return mounts
 }
 
+// tmpfsPathMounts returns the tmpfs mounts requested with TmpfsPathsAnnotation.
+// They are only added when the root filesystem is read-only, and skip paths
+// that are already mounted by the spec.
+func tmpfsPathMounts(spec *specs.Spec, mounted map[string]struct{}, vfs2Enabled bool) []specs.Mount {
+	paths, ok := spec.Annotations[TmpfsPathsAnnotation]
+	if !ok || spec.Root == nil || !spec.Root.Readonly {
+		return nil
+	}
+	// Like docker, tmpfs mounts are world writable by default.
+	opts := []string{"mode=1777"}
+	if vfs2Enabled {
+		size := defaultTmpfsSize
+		if val, ok := spec.Annotations[TmpfsSizeAnnotation]; ok {
+			size = val
+		}
+		opts = append(opts, "size="+size)
+	} else {
+		log.Warningf("tmpfs size limit is not supported with VFS1, mounts from %q are not size-limited", TmpfsPathsAnnotation)
+	}
+
+	var rv []specs.Mount
+	for _, path := range strings.Split(paths, ",") {
+		path = strings.TrimSpace(path)
+		if len(path) == 0 {
+			continue
+		}
+		if !filepath.IsAbs(path) {
+			log.Warningf("ignoring relative path %q in %q annotation", path, TmpfsPathsAnnotation)
+			continue
+		}
+		path = filepath.Clean(path)
+		if _, ok := mounted[path]; ok {
+			log.Debugf("Explicit %q mount found, skipping %q tmpfs", path, TmpfsPathsAnnotation)
+			continue
+		}
+		mounted[path] = struct{}{}
+		rv = append(rv, specs.Mount{
+			Type:        tmpfsvfs2.Name,
+			Destination: path,
+			Options:     opts,
+		})
+	}
+	return rv
+}
+
 // goferMountData creates a slice of gofer mount data.
-func goferMountData(fd int, fa config.FileAccessType, attachPath string, vfs2 bool, lisafs bool) []string {
+func goferMountData(fd int, fa config.FileAccessType, fc config.FileCachePolicy, attachPath string, vfs2 bool, lisafs bool) []string {
 	opts := []string{
 		"trans=fd",
 		"rfdno=" + strconv.Itoa(fd),
@@ -201,7 +274,11 @@ func goferMountData(fd int, fa config.FileAccessType, attachPath string, vfs2 bo
 		opts = append(opts, "privateunixsocket=true")
 	}
 	if fa == config.FileAccessShared {
-		opts = append(opts, "cache=remote_revalidating")
+		if fc == config.FileCacheNone {
+			opts = append(opts, "cache=none")
+		} else {
+			opts = append(opts, "cache=remote_revalidating")
+		}
 	}
 	if vfs2 && lisafs {
 		opts = append(opts, "lisafs=true")
@@ -363,6 +440,10 @@ func adjustDirentCache(k *kernel.Kernel) error {
 
 type fdDispenser struct {
 	fds []*fd.FD
+
+	// extra is the number of additional FDs that follow each gofer FD, one
+	// per additional channel to the gofer (see config.Config.GoferChannels).
+	extra int
 }
 
 func (f *fdDispenser) remove() int {
@@ -374,6 +455,33 @@ func (f *fdDispenser) remove() int {
 	return rv
 }
 
+// removeExtra removes the additional FDs that follow the FD returned by the
+// last call to remove.
+func (f *fdDispenser) removeExtra() []int {
+	var fds []int
+	for i := 0; i < f.extra; i++ {
+		fds = append(fds, f.remove())
+	}
+	return fds
+}
+
+// removeLast removes the FDs of the last gofer mount: its gofer FD followed by
+// its additional FDs.
+func (f *fdDispenser) removeLast() (int, []int) {
+	n := 1 + f.extra
+	if len(f.fds) < n {
+		panic("fdDispenser out of fds")
+	}
+	last := f.fds[len(f.fds)-n:]
+	f.fds = f.fds[:len(f.fds)-n]
+	fd := last[0].Release()
+	var extraFDs []int
+	for _, extraFD := range last[1:] {
+		extraFDs = append(extraFDs, extraFD.Release())
+	}
+	return fd, extraFDs
+}
+
 func (f *fdDispenser) empty() bool {
 	return len(f.fds) == 0
 }
@@ -438,6 +546,11 @@ type mountHint struct {
 	// vfsMount is the master mount for the volume. For mounts with 'pod' share
 	// the master volume is bind mounted inside the containers.
 	vfsMount *vfs.Mount
+
+	// perMountFlags is true if mount only has the options of the filesystem,
+	// while mount flags (e.g. ro and noexec) are applied to the mount of each
+	// container and may differ among them. Only VFS2 supports it.
+	perMountFlags bool
 }
 
 func (m *mountHint) setField(key, val string) error {
@@ -496,6 +609,9 @@ func (m *mountHint) checkCompatible(mount *specs.Mount) error {
 	// Remove options that don't affect to mount's behavior.
 	masterOpts := filterUnsupportedOptions(&m.mount)
 	replicaOpts := filterUnsupportedOptions(mount)
+	if m.perMountFlags {
+		replicaOpts = filterMountFlags(replicaOpts)
+	}
 
 	if len(masterOpts) != len(replicaOpts) {
 		return fmt.Errorf("mount options in annotations differ from container mount, annotation: %s, mount: %s", masterOpts, replicaOpts)
@@ -528,6 +644,20 @@ func filterUnsupportedOptions(mount *specs.Mount) []string {
 	return rv
 }
 
+// filterMountFlags returns opts without the flags of the mount, which don't
+// apply to the filesystem.
+func filterMountFlags(opts []string) []string {
+	rv := make([]string, 0, len(opts))
+	for _, o := range opts {
+		switch o {
+		case "rw", "ro", "noatime", "noexec":
+			continue
+		}
+		rv = append(rv, o)
+	}
+	return rv
+}
+
 // podMountHints contains a collection of mountHints for the pod.
 type podMountHints struct {
 	mounts map[string]*mountHint
@@ -558,6 +688,8 @@ func newPodMountHints(spec *specs.Spec) (*podMountHints, error) {
 		}
 	}
 
+	addDevShmHint(spec, mnts)
+
 	// Validate all hints after done parsing.
 	for name, m := range mnts {
 		log.Infof("Mount annotation found, name: %s, source: %q, type: %s, share: %v", name, m.mount.Source, m.mount.Type, m.share)
@@ -582,6 +714,63 @@ func newPodMountHints(spec *specs.Spec) (*podMountHints, error) {
 	return &podMountHints{mounts: mnts}, nil
 }
 
+// addDevShmHint adds a hint to share /dev/shm among the containers of a pod,
+// unless one was provided with annotations. Containers which share the IPC
+// namespace of the pod have the same volume mounted at /dev/shm, e.g. the shm
+// directory of the sandbox with containerd. The volume is backed by a single
+// tmpfs in the sandbox, so that shared memory between containers works as
+// with runc, instead of giving each container its own copy of the volume.
+//
+// The hint is built from the mount of the sandbox, usually the pause
+// container, which may be mounted with different flags than the containers
+// (e.g. ro). Only the options of the filesystem are kept, and each container
+// mounts the volume with its own flags.
+func addDevShmHint(spec *specs.Spec, mnts map[string]*mountHint) {
+	if specutils.SpecContainerType(spec) != specutils.ContainerTypeSandbox {
+		return
+	}
+	// VFS1 mounts the volume itself in all containers, with the same flags.
+	if !kernel.VFS2Enabled {
+		return
+	}
+	// The IPC namespace of the host is used, nothing can be shared since
+	// /dev/shm of the host can't be mapped in the sandbox.
+	if _, ok := specutils.GetNS(specs.IPCNamespace, spec); !ok {
+		return
+	}
+	if _, ok := mnts[devShmHintName]; ok {
+		return
+	}
+	for _, m := range spec.Mounts {
+		if filepath.Clean(m.Destination) != "/dev/shm" || len(m.Source) == 0 {
+			continue
+		}
+		for _, hint := range mnts {
+			if hint.mount.Source == m.Source {
+				return
+			}
+		}
+		// Options are compared with the mounts of the containers, see
+		// mountHint.checkCompatible. Keep the ones that apply to the
+		// filesystem, which is shared.
+		opts := filterMountFlags(filterUnsupportedOptions(&m))
+		sort.Strings(opts)
+		log.Infof("Sharing /dev/shm from %q among containers", m.Source)
+		mnts[devShmHintName] = &mountHint{
+			name:  devShmHintName,
+			share: pod,
+			mount: specs.Mount{
+				Destination: m.Destination,
+				Source:      m.Source,
+				Type:        tmpfsvfs2.Name,
+				Options:     opts,
+			},
+			perMountFlags: true,
+		}
+		return
+	}
+}
+
 func (p *podMountHints) findMount(mount *specs.Mount) *mountHint {
 	for _, m := range p.mounts {
 		if m.mount.Source == mount.Source {
@@ -604,15 +793,24 @@ type containerMounter struct {
 	k *kernel.Kernel
 
 	hints *podMountHints
+
+	// fsID is the prefix of the unique IDs of gofer filesystems, see
+	// containerInfo.fsID.
+	fsID string
 }
 
 func newContainerMounter(info *containerInfo, k *kernel.Kernel, hints *podMountHints, vfs2Enabled bool) *containerMounter {
+	fds := fdDispenser{fds: info.goferFDs}
+	if info.conf.GoferChannels > 1 {
+		fds.extra = info.conf.GoferChannels - 1
+	}
 	return &containerMounter{
 		root:   info.spec.Root,
 		mounts: compileMounts(info.spec, info.conf, vfs2Enabled),
-		fds:    fdDispenser{fds: info.goferFDs},
+		fds:    fds,
 		k:      k,
 		hints:  hints,
+		fsID:   info.fsID,
 	}
 }
 
@@ -765,7 +963,7 @@ func (c *containerMounter) createRootMount(ctx context.Context, conf *config.Con
 	fd := c.fds.remove()
 	log.Infof("Mounting root over 9P, ioFD: %d", fd)
 	p9FS := mustFindFilesystem("9p")
-	opts := goferMountData(fd, conf.FileAccess, "/", false /* vfs2 */, false /* lisafs */)
+	opts := goferMountData(fd, conf.FileAccess, conf.FileCache, "/", false /* vfs2 */, false /* lisafs */)
 
 	// We can't check for overlayfs here because sandbox is chroot'ed and gofer
 	// can only send mount options for specs.Mounts (specs.Root is missing
@@ -826,7 +1024,7 @@ func (c *containerMounter) getMountNameAndOptions(conf *config.Config, m *specs.
 	case bind:
 		fd := c.fds.remove()
 		fsName = gofervfs2.Name
-		opts = goferMountData(fd, c.getMountAccessType(conf, m), m.Destination, conf.VFS2, conf.Lisafs)
+		opts = goferMountData(fd, c.getMountAccessType(conf, m), conf.FileCache, m.Destination, conf.VFS2, conf.Lisafs)
 		// If configured, add overlay to all writable mounts.
 		useOverlay = conf.Overlay && !mountFlags(m.Options).ReadOnly
 	case cgroupfs.Name:
@@ -984,7 +1182,7 @@ func (c *containerMounter) createRestoreEnvironment(conf *config.Config) (*fs.Re
 
 	// Add root mount.
 	fd := c.fds.remove()
-	opts := goferMountData(fd, conf.FileAccess, "/", conf.VFS2, false /* lisafs */)
+	opts := goferMountData(fd, conf.FileAccess, conf.FileCache, "/", conf.VFS2, false /* lisafs */)
 
 	mf := fs.MountSourceFlags{}
 	if c.root.Readonly || conf.Overlay {
@@ -20,7 +20,11 @@ import (
 	"testing"
 
 	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"gvisor.dev/gvisor/pkg/sentry/fsimpl/cgroupfs"
+	tmpfsvfs2 "gvisor.dev/gvisor/pkg/sentry/fsimpl/tmpfs"
+	"gvisor.dev/gvisor/pkg/sentry/kernel"
 	"gvisor.dev/gvisor/runsc/config"
+	"gvisor.dev/gvisor/runsc/specutils"
 )
 
 func TestPodMountHintsHappy(t *testing.T) {
@@ -250,3 +254,209 @@ func TestGetMountAccessType(t *testing.T) {
 		})
 	}
 }
+
+func TestPodMountHintsDevShm(t *testing.T) {
+	vfs2Enabled := kernel.VFS2Enabled
+	defer func() { kernel.VFS2Enabled = vfs2Enabled }()
+
+	const source = "/run/containerd/sandboxes/123/shm"
+	// The pause container mounts /dev/shm read-only, while the containers
+	// of the pod mount it read-write.
+	pauseMount := specs.Mount{
+		Destination: "/dev/shm",
+		Source:      source,
+		Type:        "bind",
+		Options:     []string{"rbind", "ro", "nosuid", "nodev", "noexec"},
+	}
+	shmMount := specs.Mount{
+		Destination: "/dev/shm",
+		Source:      source,
+		Type:        "bind",
+		Options:     []string{"rbind", "rw", "nosuid", "nodev", "noexec"},
+	}
+	roMount := specs.Mount{
+		Destination: "/dev/shm",
+		Source:      source,
+		Type:        "bind",
+		Options:     []string{"rbind", "ro"},
+	}
+	ipcNS := specs.LinuxNamespace{Type: specs.IPCNamespace}
+	for _, tc := range []struct {
+		name        string
+		annotations map[string]string
+		namespaces  []specs.LinuxNamespace
+		vfs1        bool
+		want        bool
+	}{
+		{
+			name: "sandbox",
+			annotations: map[string]string{
+				specutils.ContainerdContainerTypeAnnotation: specutils.ContainerdContainerTypeSandbox,
+			},
+			namespaces: []specs.LinuxNamespace{ipcNS},
+			want:       true,
+		},
+		{
+			name: "vfs1",
+			annotations: map[string]string{
+				specutils.ContainerdContainerTypeAnnotation: specutils.ContainerdContainerTypeSandbox,
+			},
+			namespaces: []specs.LinuxNamespace{ipcNS},
+			vfs1:       true,
+		},
+		{
+			name:       "no-pod",
+			namespaces: []specs.LinuxNamespace{ipcNS},
+		},
+		{
+			name: "host-ipc",
+			annotations: map[string]string{
+				specutils.ContainerdContainerTypeAnnotation: specutils.ContainerdContainerTypeSandbox,
+			},
+		},
+		{
+			name: "annotation",
+			annotations: map[string]string{
+				specutils.ContainerdContainerTypeAnnotation: specutils.ContainerdContainerTypeSandbox,
+				MountPrefix + "shm.source":                  source,
+				MountPrefix + "shm.type":                    "bind",
+				MountPrefix + "shm.share":                   "container",
+			},
+			namespaces: []specs.LinuxNamespace{ipcNS},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			kernel.VFS2Enabled = !tc.vfs1
+			spec := &specs.Spec{
+				Annotations: tc.annotations,
+				Linux:       &specs.Linux{Namespaces: tc.namespaces},
+				Mounts:      []specs.Mount{pauseMount},
+			}
+			podHints, err := newPodMountHints(spec)
+			if err != nil {
+				t.Fatalf("newPodMountHints failed: %v", err)
+			}
+			hint, ok := podHints.mounts[devShmHintName]
+			if ok != tc.want {
+				t.Fatalf("/dev/shm hint added: %t, want: %t", ok, tc.want)
+			}
+			if !ok {
+				return
+			}
+			if hint.share != pod || hint.mount.Type != tmpfsvfs2.Name || !hint.isSupported() {
+				t.Errorf("invalid /dev/shm hint: %+v", hint)
+			}
+			if len(hint.mount.Options) != 0 {
+				t.Errorf("/dev/shm hint has mount flags of the pause container: %v", hint.mount.Options)
+			}
+			for _, m := range []specs.Mount{pauseMount, shmMount, roMount} {
+				if got := podHints.findMount(&m); got != hint {
+					t.Errorf("findMount(%+v), want: %+v, got: %+v", m, hint, got)
+				}
+				if err := hint.checkCompatible(&m); err != nil {
+					t.Errorf("checkCompatible(%+v): %v", m, err)
+				}
+			}
+		})
+	}
+}
+
+func TestTmpfsPathMounts(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		readonly bool
+		mounts   []specs.Mount
+		want     []string
+	}{
+		{
+			name:     "readonly",
+			readonly: true,
+			want:     []string{"/tmp", "/var/run"},
+		},
+		{
+			name: "readwrite",
+		},
+		{
+			name:     "explicit-mount",
+			readonly: true,
+			mounts: []specs.Mount{
+				{Type: "bind", Destination: "/tmp/"},
+			},
+			want: []string{"/var/run"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			spec := &specs.Spec{
+				Root: &specs.Root{Readonly: tc.readonly},
+				Annotations: map[string]string{
+					TmpfsPathsAnnotation: "/tmp, /var/run/,relative",
+					TmpfsSizeAnnotation:  "1m",
+				},
+				Mounts: tc.mounts,
+			}
+			conf := &config.Config{}
+			var got []string
+			for _, m := range compileMounts(spec, conf, true /* vfs2Enabled */) {
+				if strings.Join(m.Options, ",") == "mode=1777,size=1m" {
+					got = append(got, m.Destination)
+				}
+			}
+			if !reflect.DeepEqual(tc.want, got) {
+				t.Errorf("tmpfs mounts, want: %v, got: %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestSystemdMounts(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		flag        bool
+		annotations map[string]string
+		mounts      []specs.Mount
+		want        []string
+	}{
+		{
+			name: "disabled",
+		},
+		{
+			name: "flag",
+			flag: true,
+			want: []string{"/sys/fs/cgroup/cpu", "/sys/fs/cgroup/cpuacct", "/sys/fs/cgroup/cpuset", "/sys/fs/cgroup/memory", "/sys/fs/cgroup/systemd", "/run", "/run/lock"},
+		},
+		{
+			name:        "annotation",
+			annotations: map[string]string{SystemdAnnotation: "true"},
+			want:        []string{"/sys/fs/cgroup/cpu", "/sys/fs/cgroup/cpuacct", "/sys/fs/cgroup/cpuset", "/sys/fs/cgroup/memory", "/sys/fs/cgroup/systemd", "/run", "/run/lock"},
+		},
+		{
+			name:        "annotation-override",
+			flag:        true,
+			annotations: map[string]string{SystemdAnnotation: "false"},
+		},
+		{
+			name:   "explicit-run",
+			flag:   true,
+			mounts: []specs.Mount{{Type: "bind", Destination: "/run"}},
+			want:   []string{"/sys/fs/cgroup/cpu", "/sys/fs/cgroup/cpuacct", "/sys/fs/cgroup/cpuset", "/sys/fs/cgroup/memory", "/sys/fs/cgroup/systemd", "/run/lock"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			spec := &specs.Spec{
+				Root:        &specs.Root{},
+				Annotations: tc.annotations,
+				Mounts:      tc.mounts,
+			}
+			conf := &config.Config{Systemd: tc.flag}
+			var got []string
+			for _, m := range compileMounts(spec, conf, true /* vfs2Enabled */) {
+				if m.Type == cgroupfs.Name || (m.Type == tmpfsvfs2.Name && strings.HasPrefix(m.Destination, "/run")) {
+					got = append(got, m.Destination)
+				}
+			}
+			if !reflect.DeepEqual(tc.want, got) {
+				t.Errorf("systemd mounts, want: %v, got: %v", tc.want, got)
+			}
+		})
+	}
+}
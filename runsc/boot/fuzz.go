@@ -0,0 +1,74 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build gofuzz
+// +build gofuzz
+
+package boot
+
+import (
+	"strings"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"gvisor.dev/gvisor/pkg/sentry/fsimpl/cgroupfs"
+)
+
+// Fuzz is the entry point for go-fuzz and libFuzzer. It parses the mount
+// options and mount annotations of a spec, which come from the bundle.
+//
+// The first line of data is a comma-separated list of mount options. Each of
+// the following lines is a mount annotation without the MountPrefix, e.g.
+// "vol.share=pod".
+func Fuzz(data []byte) int {
+	lines := strings.Split(string(data), "\n")
+	opts := strings.Split(lines[0], ",")
+
+	mountFlags(opts)
+	if _, err := parseAndFilterOptions(opts, tmpfsAllowedDataVFS2...); err != nil {
+		return 0
+	}
+	if _, err := parseAndFilterOptions(opts, cgroupfs.SupportedMountOptions...); err != nil {
+		return 0
+	}
+	if _, _, _, _, err := parseVerityMountOptions(opts); err != nil {
+		return 0
+	}
+
+	spec := &specs.Spec{Annotations: make(map[string]string)}
+	for _, line := range lines[1:] {
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		spec.Annotations[MountPrefix+kv[0]] = kv[1]
+	}
+	hints, err := newPodMountHints(spec)
+	if err != nil {
+		return 0
+	}
+	for _, hint := range hints.mounts {
+		hint.isSupported()
+		hint.fileAccessType()
+		mount := specs.Mount{
+			Type:    hint.mount.Type,
+			Source:  hint.mount.Source,
+			Options: opts,
+		}
+		if hints.findMount(&mount) != hint {
+			panic("mount hint not found by its source")
+		}
+		hint.checkCompatible(&mount)
+	}
+	return 1
+}
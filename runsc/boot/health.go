@@ -0,0 +1,405 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boot
+
+import (
+	gcontext "context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	gtime "time"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/pkg/sentry/control"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/auth"
+	"gvisor.dev/gvisor/pkg/sentry/socket/netstack"
+	"gvisor.dev/gvisor/pkg/sync"
+	"gvisor.dev/gvisor/pkg/tcpip"
+	"gvisor.dev/gvisor/pkg/tcpip/adapters/gonet"
+	"gvisor.dev/gvisor/pkg/tcpip/network/ipv4"
+	"gvisor.dev/gvisor/runsc/specutils"
+)
+
+// HealthCheckPrefix is the annotation prefix used to configure health checks
+// that are executed inside the sandbox, e.g.:
+//   dev.gvisor.spec.healthcheck.cmd: "/bin/check --quick"
+//   dev.gvisor.spec.healthcheck.tcp-port: "8080"
+//   dev.gvisor.spec.healthcheck.http-port: "8080"
+//   dev.gvisor.spec.healthcheck.http-path: "/healthz"
+//   dev.gvisor.spec.healthcheck.interval: "10s"
+//   dev.gvisor.spec.healthcheck.timeout: "1s"
+//   dev.gvisor.spec.healthcheck.retries: "3"
+const HealthCheckPrefix = "dev.gvisor.spec.healthcheck."
+
+const (
+	defaultHealthInterval = 30 * gtime.Second
+	defaultHealthTimeout  = 30 * gtime.Second
+	defaultHealthRetries  = 3
+)
+
+// Health check states reported in HealthStatus.Status. They match the values
+// used by docker.
+const (
+	HealthStarting  = "starting"
+	HealthHealthy   = "healthy"
+	HealthUnhealthy = "unhealthy"
+)
+
+// HealthCheck describes a probe that is executed periodically inside the
+// sandbox. Exactly one of Command, TCPPort or HTTPPort must be set.
+type HealthCheck struct {
+	// Command is executed inside the container. The probe succeeds if the
+	// command exits with status 0.
+	Command []string `json:"command,omitempty"`
+
+	// TCPPort is a port on the container loopback address. The probe succeeds
+	// if a TCP connection can be established.
+	TCPPort uint16 `json:"tcpPort,omitempty"`
+
+	// HTTPPort and HTTPPath are used to issue a GET request against the
+	// container loopback address. The probe succeeds if the response status
+	// code is 2xx or 3xx.
+	HTTPPort uint16 `json:"httpPort,omitempty"`
+	HTTPPath string `json:"httpPath,omitempty"`
+
+	// Interval is the time between probes.
+	Interval gtime.Duration `json:"interval"`
+
+	// Timeout is the maximum time a probe is allowed to take.
+	Timeout gtime.Duration `json:"timeout"`
+
+	// Retries is the number of consecutive failures needed to consider the
+	// container unhealthy.
+	Retries int `json:"retries"`
+}
+
+// HealthStatus is the result of the health checks for a container.
+type HealthStatus struct {
+	// Status is one of HealthStarting, HealthHealthy, or HealthUnhealthy.
+	Status string `json:"status"`
+
+	// FailingStreak is the number of consecutive failed probes.
+	FailingStreak int `json:"failingStreak"`
+
+	// LastCheck is the time the last probe completed.
+	LastCheck gtime.Time `json:"lastCheck,omitempty"`
+
+	// LastError is the error returned by the last probe, if any.
+	LastError string `json:"lastError,omitempty"`
+}
+
+// healthCheckFromSpec parses health check annotations from the spec. It
+// returns nil if no health check is configured.
+func healthCheckFromSpec(spec *specs.Spec) (*HealthCheck, error) {
+	hc := &HealthCheck{
+		Interval: defaultHealthInterval,
+		Timeout:  defaultHealthTimeout,
+		Retries:  defaultHealthRetries,
+	}
+	found := false
+	for k, v := range spec.Annotations {
+		if !strings.HasPrefix(k, HealthCheckPrefix) {
+			continue
+		}
+		found = true
+		var err error
+		switch name := k[len(HealthCheckPrefix):]; name {
+		case "cmd":
+			hc.Command = strings.Fields(v)
+		case "tcp-port":
+			hc.TCPPort, err = parsePort(v)
+		case "http-port":
+			hc.HTTPPort, err = parsePort(v)
+		case "http-path":
+			hc.HTTPPath = v
+		case "interval":
+			hc.Interval, err = gtime.ParseDuration(v)
+		case "timeout":
+			hc.Timeout, err = gtime.ParseDuration(v)
+		case "retries":
+			hc.Retries, err = strconv.Atoi(v)
+		default:
+			return nil, fmt.Errorf("invalid health check annotation: %s=%s", k, v)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid health check annotation: %s=%s: %w", k, v, err)
+		}
+	}
+	if !found {
+		return nil, nil
+	}
+	if err := hc.validate(); err != nil {
+		return nil, err
+	}
+	return hc, nil
+}
+
+func parsePort(v string) (uint16, error) {
+	port, err := strconv.ParseUint(v, 10, 16)
+	if err != nil {
+		return 0, err
+	}
+	return uint16(port), nil
+}
+
+func (hc *HealthCheck) validate() error {
+	probes := 0
+	if len(hc.Command) > 0 {
+		probes++
+	}
+	if hc.TCPPort != 0 {
+		probes++
+	}
+	if hc.HTTPPort != 0 {
+		probes++
+	}
+	if probes != 1 {
+		return fmt.Errorf("health check must have exactly one of cmd, tcp-port or http-port, got: %d", probes)
+	}
+	if hc.Interval <= 0 || hc.Timeout <= 0 {
+		return fmt.Errorf("health check interval and timeout must be > 0, got: %v, %v", hc.Interval, hc.Timeout)
+	}
+	if hc.Retries <= 0 {
+		return fmt.Errorf("health check retries must be > 0, got: %d", hc.Retries)
+	}
+	return nil
+}
+
+// healthChecker runs a HealthCheck periodically for a container.
+type healthChecker struct {
+	l     *Loader
+	cid   string
+	spec  *specs.Spec
+	check HealthCheck
+	stop  chan struct{}
+
+	// mu protects status.
+	mu     sync.Mutex
+	status HealthStatus
+}
+
+// startHealthCheckLocked starts the health checker for the container, with
+// the check returned by healthCheckFromSpec, if any. Caller must hold l.mu.
+func (l *Loader) startHealthCheckLocked(cid string, spec *specs.Spec, hc *HealthCheck) {
+	if hc == nil {
+		return
+	}
+	if l.healthCheckers == nil {
+		l.healthCheckers = make(map[string]*healthChecker)
+	}
+	if old, ok := l.healthCheckers[cid]; ok {
+		close(old.stop)
+	}
+	h := &healthChecker{
+		l:      l,
+		cid:    cid,
+		spec:   spec,
+		check:  *hc,
+		stop:   make(chan struct{}),
+		status: HealthStatus{Status: HealthStarting},
+	}
+	l.healthCheckers[cid] = h
+	log.Infof("Starting health check for container %q: %+v", cid, *hc)
+	go h.run()
+}
+
+// stopHealthCheckLocked stops the health checker for the container, if any.
+// Caller must hold l.mu.
+func (l *Loader) stopHealthCheckLocked(cid string) {
+	if h, ok := l.healthCheckers[cid]; ok {
+		close(h.stop)
+		delete(l.healthCheckers, cid)
+	}
+}
+
+// healthStatus returns the current health status of all containers that have
+// a health check configured.
+func (l *Loader) healthStatus() map[string]HealthStatus {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.healthCheckers) == 0 {
+		return nil
+	}
+	rv := make(map[string]HealthStatus, len(l.healthCheckers))
+	for cid, h := range l.healthCheckers {
+		rv[cid] = h.getStatus()
+	}
+	return rv
+}
+
+func (h *healthChecker) getStatus() HealthStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.status
+}
+
+func (h *healthChecker) run() {
+	ticker := gtime.NewTicker(h.check.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-h.stop:
+			return
+		case <-ticker.C:
+		}
+
+		err := h.probe()
+
+		h.mu.Lock()
+		h.status.LastCheck = gtime.Now()
+		if err == nil {
+			h.status.Status = HealthHealthy
+			h.status.FailingStreak = 0
+			h.status.LastError = ""
+		} else {
+			log.Debugf("Health check failed for container %q: %v", h.cid, err)
+			h.status.FailingStreak++
+			h.status.LastError = err.Error()
+			if h.status.FailingStreak >= h.check.Retries {
+				h.status.Status = HealthUnhealthy
+			}
+		}
+		h.mu.Unlock()
+	}
+}
+
+func (h *healthChecker) probe() error {
+	ctx, cancel := gcontext.WithTimeout(gcontext.Background(), h.check.Timeout)
+	defer cancel()
+
+	switch {
+	case len(h.check.Command) > 0:
+		return h.probeExec(ctx)
+	case h.check.TCPPort != 0:
+		conn, err := h.dial(ctx, h.check.TCPPort)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	case h.check.HTTPPort != 0:
+		return h.probeHTTP(ctx)
+	default:
+		panic(fmt.Sprintf("invalid health check: %+v", h.check))
+	}
+}
+
+// dial connects to the given port on the loopback address of the sandbox
+// network stack.
+func (h *healthChecker) dial(ctx gcontext.Context, port uint16) (net.Conn, error) {
+	if s, ok := h.l.k.RootNetworkNamespace().Stack().(*netstack.Stack); ok {
+		addr := tcpip.FullAddress{Addr: tcpip.Address("\x7f\x00\x00\x01"), Port: port}
+		return gonet.DialContextTCP(ctx, s.Stack, addr, ipv4.ProtocolNumber)
+	}
+	// Host network: the sandbox shares the network namespace with the
+	// container.
+	var d net.Dialer
+	return d.DialContext(ctx, "tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(int(port))))
+}
+
+func (h *healthChecker) probeHTTP(ctx gcontext.Context) error {
+	client := http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx gcontext.Context, _, _ string) (net.Conn, error) {
+				return h.dial(ctx, h.check.HTTPPort)
+			},
+			DisableKeepAlives: true,
+		},
+	}
+	path := h.check.HTTPPath
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("http://localhost:%d%s", h.check.HTTPPort, path), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return fmt.Errorf("HTTP probe returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (h *healthChecker) probeExec(ctx gcontext.Context) error {
+	caps, err := specutils.Capabilities(h.l.root.conf.EnableRaw, h.spec.Process.Capabilities)
+	if err != nil {
+		return err
+	}
+	extraKGIDs := make([]auth.KGID, 0, len(h.spec.Process.User.AdditionalGids))
+	for _, GID := range h.spec.Process.User.AdditionalGids {
+		extraKGIDs = append(extraKGIDs, auth.KGID(GID))
+	}
+	args := &control.ExecArgs{
+		Argv:             h.check.Command,
+		Envv:             h.spec.Process.Env,
+		WorkingDirectory: h.spec.Process.Cwd,
+		KUID:             auth.KUID(h.spec.Process.User.UID),
+		KGID:             auth.KGID(h.spec.Process.User.GID),
+		ExtraKGIDs:       extraKGIDs,
+		Capabilities:     caps,
+		ContainerID:      h.cid,
+	}
+	tgid, err := h.l.executeAsync(args, true /* healthCheck */)
+	if err != nil {
+		return err
+	}
+	eid := execID{cid: h.cid, pid: tgid}
+	defer func() {
+		h.l.mu.Lock()
+		delete(h.l.processes, eid)
+		h.l.mu.Unlock()
+	}()
+	tg, err := h.l.threadGroupFromID(eid)
+	if err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		tg.WaitExited()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		_ = h.l.k.SendExternalSignalThreadGroup(tg, &linux.SignalInfo{Signo: int32(linux.SIGKILL)})
+		<-done
+		return fmt.Errorf("health check command timed out after %v", h.check.Timeout)
+	}
+	if ws := tg.ExitStatus(); ws != 0 {
+		return fmt.Errorf("health check command failed, wait status: %#x", uint32(ws))
+	}
+	return nil
+}
+
+// HealthCheck returns the health status of the given container.
+func (cm *containerManager) HealthCheck(cid *string, out *HealthStatus) error {
+	log.Debugf("containerManager.HealthCheck, cid: %s", *cid)
+	status, ok := cm.l.healthStatus()[*cid]
+	if !ok {
+		return fmt.Errorf("no health check configured for container %q", *cid)
+	}
+	*out = status
+	return nil
+}
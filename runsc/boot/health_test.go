@@ -0,0 +1,129 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boot
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func TestHealthCheckFromSpec(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		annotations map[string]string
+		want        *HealthCheck
+	}{
+		{
+			name: "none",
+		},
+		{
+			name: "cmd",
+			annotations: map[string]string{
+				HealthCheckPrefix + "cmd": "/bin/check --quick",
+			},
+			want: &HealthCheck{
+				Command:  []string{"/bin/check", "--quick"},
+				Interval: defaultHealthInterval,
+				Timeout:  defaultHealthTimeout,
+				Retries:  defaultHealthRetries,
+			},
+		},
+		{
+			name: "http",
+			annotations: map[string]string{
+				HealthCheckPrefix + "http-port": "8080",
+				HealthCheckPrefix + "http-path": "/healthz",
+				HealthCheckPrefix + "interval":  "10s",
+				HealthCheckPrefix + "timeout":   "1s",
+				HealthCheckPrefix + "retries":   "5",
+			},
+			want: &HealthCheck{
+				HTTPPort: 8080,
+				HTTPPath: "/healthz",
+				Interval: 10 * time.Second,
+				Timeout:  time.Second,
+				Retries:  5,
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			spec := &specs.Spec{Annotations: tc.annotations}
+			got, err := healthCheckFromSpec(spec)
+			if err != nil {
+				t.Fatalf("healthCheckFromSpec(): %v", err)
+			}
+			if !reflect.DeepEqual(tc.want, got) {
+				t.Errorf("healthCheckFromSpec(), want: %+v, got: %+v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestHealthCheckFromSpecErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		annotations map[string]string
+	}{
+		{
+			name: "unknown",
+			annotations: map[string]string{
+				HealthCheckPrefix + "foo": "bar",
+			},
+		},
+		{
+			name: "no-probe",
+			annotations: map[string]string{
+				HealthCheckPrefix + "interval": "10s",
+			},
+		},
+		{
+			name: "multiple-probes",
+			annotations: map[string]string{
+				HealthCheckPrefix + "cmd":      "/bin/true",
+				HealthCheckPrefix + "tcp-port": "80",
+			},
+		},
+		{
+			name: "bad-port",
+			annotations: map[string]string{
+				HealthCheckPrefix + "tcp-port": "100000",
+			},
+		},
+		{
+			name: "bad-interval",
+			annotations: map[string]string{
+				HealthCheckPrefix + "cmd":      "/bin/true",
+				HealthCheckPrefix + "interval": "-1s",
+			},
+		},
+		{
+			name: "bad-retries",
+			annotations: map[string]string{
+				HealthCheckPrefix + "cmd":     "/bin/true",
+				HealthCheckPrefix + "retries": "0",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			spec := &specs.Spec{Annotations: tc.annotations}
+			if hc, err := healthCheckFromSpec(spec); err == nil {
+				t.Errorf("healthCheckFromSpec() should have failed, got: %+v", hc)
+			}
+		})
+	}
+}
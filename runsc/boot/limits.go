@@ -139,16 +139,36 @@ func createLimitSet(spec *specs.Spec) (*limits.LimitSet, error) {
 		return nil, err
 	}
 
-	// Then apply overwrites on top of defaults.
-	for _, rl := range spec.Process.Rlimits {
+	rlimits, err := RlimitsFromSpec(spec.Process.Rlimits)
+	if err != nil {
+		return nil, err
+	}
+
+	// Then apply overwrites on top of a copy of the defaults, which are shared
+	// by all containers.
+	ls = ls.GetCopy()
+	for lt, l := range rlimits {
+		ls.SetUnchecked(lt, l)
+	}
+	return ls, nil
+}
+
+// RlimitsFromSpec converts the given OCI rlimits to limits keyed by resource
+// type.
+func RlimitsFromSpec(rlimits []specs.POSIXRlimit) (map[limits.LimitType]limits.Limit, error) {
+	if len(rlimits) == 0 {
+		return nil, nil
+	}
+	ret := make(map[limits.LimitType]limits.Limit, len(rlimits))
+	for _, rl := range rlimits {
 		lt, ok := fromLinuxResource[rl.Type]
 		if !ok {
 			return nil, fmt.Errorf("unknown resource %q", rl.Type)
 		}
-		ls.SetUnchecked(lt, limits.Limit{
+		ret[lt] = limits.Limit{
 			Cur: rl.Soft,
 			Max: rl.Hard,
-		})
+		}
 	}
-	return ls, nil
+	return ret, nil
 }
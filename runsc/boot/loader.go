@@ -18,9 +18,12 @@ package boot
 import (
 	"errors"
 	"fmt"
+	"io"
 	mrand "math/rand"
 	"os"
 	"runtime"
+	"sort"
+	"strings"
 	"sync/atomic"
 	gtime "time"
 
@@ -29,6 +32,7 @@ import (
 	"gvisor.dev/gvisor/pkg/abi/linux"
 	"gvisor.dev/gvisor/pkg/bpf"
 	"gvisor.dev/gvisor/pkg/context"
+	"gvisor.dev/gvisor/pkg/control/server"
 	"gvisor.dev/gvisor/pkg/coverage"
 	"gvisor.dev/gvisor/pkg/cpuid"
 	"gvisor.dev/gvisor/pkg/fd"
@@ -38,6 +42,7 @@ import (
 	"gvisor.dev/gvisor/pkg/refs"
 	"gvisor.dev/gvisor/pkg/refsvfs2"
 	"gvisor.dev/gvisor/pkg/sentry/control"
+	"gvisor.dev/gvisor/pkg/sentry/devices/memdev"
 	"gvisor.dev/gvisor/pkg/sentry/fdimport"
 	"gvisor.dev/gvisor/pkg/sentry/fs"
 	"gvisor.dev/gvisor/pkg/sentry/fs/host"
@@ -49,6 +54,7 @@ import (
 	"gvisor.dev/gvisor/pkg/sentry/loader"
 	"gvisor.dev/gvisor/pkg/sentry/pgalloc"
 	"gvisor.dev/gvisor/pkg/sentry/platform"
+	"gvisor.dev/gvisor/pkg/sentry/replay"
 	"gvisor.dev/gvisor/pkg/sentry/socket/netfilter"
 	"gvisor.dev/gvisor/pkg/sentry/syscalls/linux/vfs2"
 	"gvisor.dev/gvisor/pkg/sentry/time"
@@ -101,6 +107,11 @@ type containerInfo struct {
 
 	// goferFDs are the FDs that attach the sandbox to the gofers.
 	goferFDs []*fd.FD
+
+	// fsID is the prefix of the unique IDs of the container's gofer
+	// filesystems, used to find them when restoring. It's empty for the root
+	// container.
+	fsID string
 }
 
 // Loader keeps state needed to start the kernel and run the container.
@@ -124,6 +135,10 @@ type Loader struct {
 	// should be called when a sandbox is destroyed.
 	stopProfiling func()
 
+	// logRing keeps the most recent log records, see debug.Dmesg. It may be
+	// nil.
+	logRing *log.Ring
+
 	// restore is set to true if we are restoring a container.
 	restore bool
 
@@ -143,6 +158,57 @@ type Loader struct {
 	// mountHints provides extra information about mounts for containers that
 	// apply to the entire pod.
 	mountHints *podMountHints
+
+	// healthCheckers maps container IDs to the health checker running for the
+	// container, if one was configured.
+	//
+	// healthCheckers is guarded by mu.
+	healthCheckers map[string]*healthChecker
+
+	// logForwarder forwards container output to the host's logging daemon.
+	// It's nil if log forwarding is disabled.
+	logForwarder *logForwarder
+
+	// timelineMu protects timelines.
+	timelineMu sync.Mutex
+
+	// timelines maps container IDs to the phases of their boot that ran in
+	// the sandbox, see runsc debug --boot-timeline.
+	timelines map[string]BootTimeline
+
+	// logForwards maps container IDs to functions that stop forwarding the
+	// container's output.
+	//
+	// logForwards is guarded by mu.
+	logForwards map[string][]func()
+
+	// timezone is the POSIX TZ string set in the environment of containers
+	// that don't set TZ. Empty if not set.
+	timezone string
+
+	// systemdContainers is the set of containers running in systemd
+	// compatibility mode.
+	//
+	// systemdContainers is guarded by mu.
+	systemdContainers map[string]struct{}
+
+	// snapshotter takes periodic snapshots of the sandbox. It's nil if
+	// snapshots are not enabled.
+	//
+	// snapshotter is guarded by mu.
+	snapshotter *snapshotter
+
+	// startOrder are the IDs of the started containers, in the order they
+	// were started.
+	//
+	// startOrder is guarded by mu.
+	startOrder []string
+
+	// restoring are the IDs of the subcontainers waiting for the sandbox to
+	// be restored, in the order they were restored.
+	//
+	// restoring is guarded by mu.
+	restoring []string
 }
 
 // execID uniquely identifies a sentry process that is executed in a container.
@@ -165,10 +231,41 @@ type execProcess struct {
 	// pidnsPath is the pid namespace path in spec
 	pidnsPath string
 
+	// utsnsPath is the UTS namespace path in spec.
+	utsnsPath string
+
+	// mounts are the mounts in the spec of the container. They are only set
+	// for the init process of containers, and recorded in checkpoints.
+	mounts []specs.Mount
+
+	// fsID is the prefix of the unique IDs of the gofer filesystems of the
+	// container, see containerInfo.fsID. It's only set for the init process
+	// of subcontainers, and is kept across restores.
+	fsID string
+
+	// restoreInfo is set for subcontainers waiting for the sandbox to be
+	// restored.
+	restoreInfo *containerInfo
+
+	// restored is closed once a subcontainer waiting for the sandbox to be
+	// restored is either restored or destroyed, so it can be waited on
+	// before it has a thread group. It's nil otherwise.
+	restored chan struct{}
+
 	// hostTTY is present when creating a sub-container with terminal enabled.
 	// TTY file is passed during container create and must be saved until
 	// container start.
 	hostTTY *fd.FD
+
+	// execID, argv and startTime describe processes started with
+	// executeAsync. execID is empty if the session wasn't named.
+	execID    string
+	argv      []string
+	startTime gtime.Time
+
+	// healthCheck is set for processes started by health checks, which
+	// aren't exec sessions.
+	healthCheck bool
 }
 
 func init() {
@@ -218,6 +315,43 @@ type Args struct {
 	// TraceFD is the file descriptor to write a Go execution trace to.
 	// Valid if >=0.
 	TraceFD int
+	// LogForwardFD is the file descriptor of a datagram socket connected to
+	// the host's logging daemon, used to forward container output. Valid if
+	// >=0.
+	LogForwardFD int
+	// VsockDirFD is the file descriptor of the directory containing UNIX
+	// sockets that back vsock ports, when vsock uses a UNIX socket backend.
+	// Valid if >=0.
+	VsockDirFD int
+	// ReplayLogFD is the file descriptor of the log nondeterministic inputs
+	// are recorded to, or replayed from. Valid if >=0.
+	ReplayLogFD int
+	// Timezone is the POSIX TZ string to set in the environment of
+	// containers. It may be empty.
+	Timezone string
+	// LogRing keeps the most recent log records of the sandbox. It may be
+	// nil.
+	LogRing *log.Ring
+	// EntropyFD is the file descriptor of the host device that /dev/random
+	// and /dev/urandom read from, instead of the sentry's random number
+	// generator. Valid if >=0.
+	EntropyFD int
+	// ControlCreds are the tokens that authenticate control clients. They're
+	// required if the control server has a policy.
+	ControlCreds *server.Credentials
+}
+
+// newReplayLog returns the log used to record or replay nondeterministic
+// inputs, depending on the configuration. It takes ownership of fd.
+func newReplayLog(conf *config.Config, fd int) (*replay.Log, error) {
+	f := os.NewFile(uintptr(fd), "replay-log")
+	if conf.Replay != "" {
+		defer f.Close()
+		log.Infof("Replaying nondeterministic inputs from %q", conf.Replay)
+		return replay.NewReplayer(f)
+	}
+	log.Infof("Recording nondeterministic inputs to %q", conf.Record)
+	return replay.NewRecorder(f), nil
 }
 
 // make sure stdioFDs are always the same on initial start and on restore
@@ -234,6 +368,22 @@ func New(args Args) (*Loader, error) {
 		return nil, fmt.Errorf("setting up rand: %w", err)
 	}
 
+	var replayLog *replay.Log
+	if args.Conf.Record != "" || args.Conf.Replay != "" {
+		if args.ReplayLogFD < 0 {
+			return nil, fmt.Errorf("replay log not provided")
+		}
+		var err error
+		replayLog, err = newReplayLog(args.Conf, args.ReplayLogFD)
+		if err != nil {
+			return nil, fmt.Errorf("setting up replay log: %w", err)
+		}
+		rand.Reader = replay.RandomReader(replayLog, rand.Reader)
+		// Reseeding the generator at times that differ between runs
+		// would desynchronize the recorded random bytes.
+		rand.DisableTimedReseed()
+	}
+
 	if err := usage.Init(); err != nil {
 		return nil, fmt.Errorf("setting up memory usage: %w", err)
 	}
@@ -243,9 +393,35 @@ func New(args Args) (*Loader, error) {
 		kernel.VFS2Enabled = true
 		kernel.FUSEEnabled = args.Conf.FUSE
 		kernel.LISAFSEnabled = args.Conf.Lisafs
+		vfs.IoctlAuditEnabled = args.Conf.IoctlAudit
 		vfs2.Override()
 	}
 
+	if args.Conf.Vsock != "" {
+		if !args.Conf.VFS2 {
+			return nil, fmt.Errorf("vsock is only supported with VFS2")
+		}
+		if args.Conf.Vsock != config.VsockHost && args.VsockDirFD < 0 {
+			return nil, fmt.Errorf("vsock directory %q not provided", args.Conf.Vsock)
+		}
+		hostinet.EnableVsock(args.VsockDirFD)
+	}
+
+	if args.Conf.EntropySource != "" {
+		if !args.Conf.VFS2 {
+			return nil, fmt.Errorf("entropy source is only supported with VFS2")
+		}
+		if args.EntropyFD < 0 {
+			return nil, fmt.Errorf("entropy source %q not provided", args.Conf.EntropySource)
+		}
+		var entropy io.Reader = os.NewFile(uintptr(args.EntropyFD), "entropy source")
+		if replayLog != nil {
+			entropy = replay.RandomReader(replayLog, entropy)
+		}
+		memdev.SetEntropySource(entropy)
+		log.Infof("Reading /dev/random and /dev/urandom from host %q", args.Conf.EntropySource)
+	}
+
 	// Make host FDs stable between invocations. Host FDs must map to the exact
 	// same number when the sandbox is restored. Otherwise the wrong FD will be
 	// used.
@@ -274,10 +450,13 @@ func New(args Args) (*Loader, error) {
 	}
 
 	// Create kernel and platform.
+	var timeline BootTimeline
+	start := gtime.Now()
 	p, err := createPlatform(args.Conf, args.Device)
 	if err != nil {
 		return nil, fmt.Errorf("creating platform: %w", err)
 	}
+	timeline.Record("platform init", start)
 	k := &kernel.Kernel{
 		Platform: p,
 	}
@@ -299,17 +478,24 @@ func New(args Args) (*Loader, error) {
 
 	// Create timekeeper.
 	tk := kernel.NewTimekeeper(k, vdso.ParamPage.FileRange())
-	tk.SetClocks(time.NewCalibratedClocks())
+	var clocks time.Clocks = time.NewCalibratedClocks()
+	if replayLog != nil {
+		clocks = replay.Clocks(replayLog, clocks)
+	}
+	tk.SetClocks(clocks)
 
 	if err := enableStrace(args.Conf); err != nil {
 		return nil, fmt.Errorf("enabling strace: %w", err)
 	}
+	setKernelVersion(args.Conf)
 
 	// Create root network namespace/stack.
+	start = gtime.Now()
 	netns, err := newRootNetworkNamespace(args.Conf, tk, k)
 	if err != nil {
 		return nil, fmt.Errorf("creating network: %w", err)
 	}
+	timeline.Record("netstack init", start)
 
 	// Create capabilities.
 	caps, err := specutils.Capabilities(args.Conf.EnableRaw, args.Spec.Process.Capabilities)
@@ -347,6 +533,7 @@ func New(args Args) (*Loader, error) {
 
 	// Initiate the Kernel object, which is required by the Context passed
 	// to createVFS in order to mount (among other things) procfs.
+	start = gtime.Now()
 	if err = k.Init(kernel.InitKernelArgs{
 		FeatureSet:                  cpuid.HostFeatureSet(),
 		Timekeeper:                  tk,
@@ -358,9 +545,12 @@ func New(args Args) (*Loader, error) {
 		RootIPCNamespace:            kernel.NewIPCNamespace(creds.UserNamespace),
 		RootAbstractSocketNamespace: kernel.NewAbstractSocketNamespace(),
 		PIDNamespace:                kernel.NewRootPIDNamespace(creds.UserNamespace),
+		TimerSlack:                  args.Conf.TimerSlack,
+		HighResolutionTimers:        args.Conf.HighResolutionTimers,
 	}); err != nil {
 		return nil, fmt.Errorf("initializing kernel: %w", err)
 	}
+	timeline.Record("kernel init", start)
 
 	if kernel.VFS2Enabled {
 		if err := registerFilesystems(k); err != nil {
@@ -390,6 +580,10 @@ func New(args Args) (*Loader, error) {
 	if err != nil {
 		return nil, fmt.Errorf("creating init process for root container: %w", err)
 	}
+	procArgs.Envv = timezoneEnv(procArgs.Envv, args.Timezone)
+	if systemdEnabled(args.Spec, args.Conf) {
+		procArgs.Envv = systemdEnv(procArgs.Envv)
+	}
 	info.procArgs = procArgs
 
 	if err := initCompatLogs(args.UserLogFD); err != nil {
@@ -423,6 +617,18 @@ func New(args Args) (*Loader, error) {
 		mountHints:    mountHints,
 		root:          info,
 		stopProfiling: stopProfiling,
+		timezone:      args.Timezone,
+		logRing:       args.LogRing,
+
+		timelines: map[string]BootTimeline{args.ID: timeline},
+
+		systemdContainers: make(map[string]struct{}),
+	}
+	if args.Conf.LogForward != "" && args.LogForwardFD >= 0 {
+		l.logForwarder, err = newLogForwarder(args.Conf.LogForward, args.LogForwardFD)
+		if err != nil {
+			return nil, fmt.Errorf("creating log forwarder: %w", err)
+		}
 	}
 
 	// We don't care about child signals; some platforms can generate a
@@ -435,7 +641,7 @@ func New(args Args) (*Loader, error) {
 	//
 	// This must be done *after* we have initialized the kernel since the
 	// controller is used to configure the kernel's network stack.
-	ctrl, err := newController(args.ControllerFD, l)
+	ctrl, err := newController(args.ControllerFD, args.ControlCreds, l)
 	if err != nil {
 		return nil, fmt.Errorf("creating control server: %w", err)
 	}
@@ -450,6 +656,26 @@ func New(args Args) (*Loader, error) {
 	return l, nil
 }
 
+// TimezoneAnnotation is the annotation that sets the timezone of all
+// containers in the sandbox. The value is either "host", to follow the host's
+// /etc/localtime, or an IANA zone name, e.g. "Europe/Berlin". The zone is
+// resolved on the host and passed to containers in the TZ environment
+// variable, so it works even if the image lacks tzdata.
+const TimezoneAnnotation = "dev.gvisor.spec.timezone"
+
+// timezoneEnv adds TZ to env, unless it's already set or tz is empty.
+func timezoneEnv(env []string, tz string) []string {
+	if tz == "" {
+		return env
+	}
+	for _, e := range env {
+		if strings.HasPrefix(e, "TZ=") {
+			return env
+		}
+	}
+	return append(env, "TZ="+tz)
+}
+
 // createProcessArgs creates args that can be used with kernel.CreateProcess.
 func createProcessArgs(id string, spec *specs.Spec, creds *auth.Credentials, k *kernel.Kernel, pidns *kernel.PIDNamespace) (kernel.CreateProcessArgs, error) {
 	// Create initial limits.
@@ -495,6 +721,15 @@ func (l *Loader) Destroy() {
 	if l.stopSignalForwarding != nil {
 		l.stopSignalForwarding()
 	}
+	l.stopSnapshots()
+	l.mu.Lock()
+	for cid := range l.healthCheckers {
+		l.stopHealthCheckLocked(cid)
+	}
+	for cid := range l.logForwards {
+		l.stopLogForwardingLocked(cid)
+	}
+	l.mu.Unlock()
 	l.watchdog.Stop()
 
 	// Stop the control server. This will indirectly stop any
@@ -550,12 +785,17 @@ func createMemoryFile() (*pgalloc.MemoryFile, error) {
 func (l *Loader) installSeccompFilters() error {
 	if l.root.conf.DisableSeccomp {
 		filter.Report("syscall filter is DISABLED. Running in less secure mode.")
+	} else if l.root.conf.TestOnlyInProcessGofer {
+		// The gofer needs syscalls that the sentry is not allowed to make.
+		filter.Report("syscall filter is DISABLED because the gofer runs in the sandbox process. Running in less secure mode.")
 	} else {
 		opts := filter.Options{
 			Platform:      l.k.Platform,
 			HostNetwork:   l.root.conf.Network == config.NetworkHost,
 			ProfileEnable: l.root.conf.ProfileEnable,
 			ControllerFD:  l.ctrl.srv.FD(),
+			Vsock:         l.root.conf.Vsock != "",
+			XDP:           l.root.conf.XDP,
 		}
 		if err := filter.Install(opts); err != nil {
 			return fmt.Errorf("installing seccomp filters: %w", err)
@@ -580,6 +820,15 @@ func (l *Loader) Run() error {
 }
 
 func (l *Loader) run() error {
+	// Validate the health check and the log tag before the container starts.
+	hc, err := healthCheckFromSpec(l.root.spec)
+	if err != nil {
+		return err
+	}
+	if _, err := logTagFromSpec(l.root.spec, l.sandboxID); err != nil {
+		return err
+	}
+
 	if l.root.conf.Network == config.NetworkHost {
 		// Delay host network configuration to this point because network namespace
 		// is configured after the loader is created and before Run() is called.
@@ -614,17 +863,29 @@ func (l *Loader) run() error {
 
 		// Create the root container init task. It will begin running
 		// when the kernel is started.
-		var err error
 		_, ep.tty, ep.ttyVFS2, err = l.createContainerProcess(true, l.sandboxID, &l.root)
 		if err != nil {
 			return err
 		}
 	}
 
+	l.startHealthCheckLocked(l.sandboxID, l.root.spec, hc)
+
 	ep.tg = l.k.GlobalInit()
+	if !l.restore {
+		l.startOrder = append(l.startOrder, l.sandboxID)
+	}
+	l.startLogForwardingLocked(l.sandboxID, l.root.spec, ep.tg)
+	if systemdEnabled(l.root.spec, l.root.conf) {
+		l.systemdContainers[l.sandboxID] = struct{}{}
+	}
 	if ns, ok := specutils.GetNS(specs.PIDNamespace, l.root.spec); ok {
 		ep.pidnsPath = ns.Path
 	}
+	if ns, ok := specutils.GetNS(specs.UTSNamespace, l.root.spec); ok {
+		ep.utsnsPath = ns.Path
+	}
+	ep.mounts = l.root.spec.Mounts
 
 	// Handle signals by forwarding them to the root container process
 	// (except for panic signal, which should cause a panic).
@@ -676,6 +937,16 @@ func (l *Loader) startSubcontainer(spec *specs.Spec, conf *config.Config, cid st
 		return fmt.Errorf("creating capabilities: %w", err)
 	}
 
+	// Validate the health check and the log tag before the process starts, so
+	// that they don't leave a running process behind the error.
+	hc, err := healthCheckFromSpec(spec)
+	if err != nil {
+		return err
+	}
+	if _, err := logTagFromSpec(spec, cid); err != nil {
+		return err
+	}
+
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
@@ -718,16 +989,29 @@ func (l *Loader) startSubcontainer(spec *specs.Spec, conf *config.Config, cid st
 	} else {
 		pidns = l.k.RootPIDNamespace()
 	}
+	utsns := l.subcontainerUTSNamespace(spec)
+	if ns, ok := specutils.GetNS(specs.UTSNamespace, spec); ok {
+		ep.utsnsPath = ns.Path
+	}
+	ep.mounts = spec.Mounts
+	ep.fsID = cid
 
 	info := &containerInfo{
 		conf:     conf,
 		spec:     spec,
 		goferFDs: goferFDs,
+		fsID:     ep.fsID,
 	}
 	info.procArgs, err = createProcessArgs(cid, spec, creds, l.k, pidns)
 	if err != nil {
 		return fmt.Errorf("creating new process: %w", err)
 	}
+	info.procArgs.UTSNamespace = utsns
+	info.procArgs.Envv = timezoneEnv(info.procArgs.Envv, l.timezone)
+	if systemdEnabled(spec, conf) {
+		info.procArgs.Envv = systemdEnv(info.procArgs.Envv)
+		l.systemdContainers[cid] = struct{}{}
+	}
 
 	// Use stdios or TTY depending on the spec configuration.
 	if spec.Process.Terminal {
@@ -747,11 +1031,78 @@ func (l *Loader) startSubcontainer(spec *specs.Spec, conf *config.Config, cid st
 	if err != nil {
 		return err
 	}
+	l.startOrder = append(l.startOrder, cid)
+	l.startLogForwardingLocked(cid, spec, ep.tg)
 	l.k.StartProcess(ep.tg)
+	l.startHealthCheckLocked(cid, spec, hc)
+	return nil
+}
+
+// subcontainerUTSNamespace returns the UTS namespace of a subcontainer. If
+// the spec requests a new UTS namespace, the subcontainer gets its own with
+// the hostname of the spec, such that containers sharing the sandbox can have
+// distinct hostnames. If it joins the UTS namespace of another container, e.g.
+// the pause container of a pod, the namespace of that container is shared.
+// Otherwise, the root UTS namespace of the sandbox is used.
+//
+// Preconditions: l.mu must be locked.
+func (l *Loader) subcontainerUTSNamespace(spec *specs.Spec) *kernel.UTSNamespace {
+	ns, ok := specutils.GetNS(specs.UTSNamespace, spec)
+	if !ok {
+		return l.k.RootUTSNamespace()
+	}
+	if ns.Path != "" {
+		for _, p := range l.processes {
+			if ns.Path == p.utsnsPath && p.tg != nil && p.tg.Leader() != nil {
+				return p.tg.Leader().UTSNamespace()
+			}
+		}
+		// The namespace doesn't belong to a container in the sandbox,
+		// e.g. it's the namespace of the sandbox process itself.
+		return l.k.RootUTSNamespace()
+	}
+	utsns := l.k.RootUTSNamespace().Clone(l.k.RootUserNamespace())
+	if spec.Hostname != "" {
+		utsns.SetHostName(spec.Hostname)
+	}
+	return utsns
+}
+
+// restoreSubcontainer prepares a created subcontainer to be restored along
+// with the sandbox, when the root container is restored. goferFDs are owned
+// by the loader if it succeeds.
+func (l *Loader) restoreSubcontainer(spec *specs.Spec, conf *config.Config, cid string, goferFDs []*fd.FD) error {
+	if !kernel.VFS2Enabled {
+		return fmt.Errorf("restoring subcontainers requires VFS2")
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	ep := l.processes[execID{cid: cid}]
+	if ep == nil {
+		return fmt.Errorf("trying to restore a deleted container %q", cid)
+	}
+	if ep.tg != nil || ep.restoreInfo != nil {
+		return fmt.Errorf("container %q already started", cid)
+	}
+	ep.restoreInfo = &containerInfo{
+		conf:     conf,
+		spec:     spec,
+		goferFDs: goferFDs,
+	}
+	ep.restored = make(chan struct{})
+	l.restoring = append(l.restoring, cid)
 	return nil
 }
 
 func (l *Loader) createContainerProcess(root bool, cid string, info *containerInfo) (*kernel.ThreadGroup, *host.TTYFileOperations, *hostvfs2.TTYFileDescription, error) {
+	if info.spec.Linux != nil {
+		if err := applySysctls(info.spec.Linux.Sysctl, l.k.RootNetworkNamespace(), info.procArgs.IPCNamespace); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
 	// Create the FD map, which will set stdin, stdout, and stderr.
 	ctx := info.procArgs.NewContext(l.k)
 	fdTable, ttyFile, ttyFileVFS2, err := createFDTable(ctx, info.spec.Process.Terminal, info.stdioFDs, info.spec.Process.User)
@@ -774,9 +1125,11 @@ func (l *Loader) createContainerProcess(root bool, cid string, info *containerIn
 			return nil, nil, nil, err
 		}
 	}
+	start := gtime.Now()
 	if err := setupContainerFS(ctx, info.conf, mntr, &info.procArgs); err != nil {
 		return nil, nil, nil, err
 	}
+	l.recordBootPhase(cid, "mount setup", start)
 
 	// Add the HOME environment variable if it is not already set.
 	var envv []string
@@ -794,10 +1147,12 @@ func (l *Loader) createContainerProcess(root bool, cid string, info *containerIn
 	info.procArgs.Envv = envv
 
 	// Create and start the new process.
+	start = gtime.Now()
 	tg, _, err := l.k.CreateProcess(info.procArgs)
 	if err != nil {
 		return nil, nil, nil, fmt.Errorf("creating process: %w", err)
 	}
+	l.recordBootPhase(cid, "init exec", start)
 	// CreateProcess takes a reference on FDTable if successful.
 	info.procArgs.FDTable.DecRef(ctx)
 
@@ -917,17 +1272,41 @@ func (l *Loader) destroySubcontainer(cid string) error {
 
 	// No more failure from this point on. Remove all container thread groups
 	// from the map.
-	for key := range l.processes {
+	l.stopHealthCheckLocked(cid)
+	l.stopLogForwardingLocked(cid)
+	l.k.ReleaseContainerPressure(cid)
+	delete(l.systemdContainers, cid)
+	l.timelineMu.Lock()
+	delete(l.timelines, cid)
+	l.timelineMu.Unlock()
+	for key, ep := range l.processes {
 		if key.cid == cid {
+			if ep.restoreInfo != nil {
+				for _, f := range ep.restoreInfo.goferFDs {
+					_ = f.Close()
+				}
+			}
+			if ep.restored != nil {
+				close(ep.restored)
+				ep.restored = nil
+			}
+			if ep.hostTTY != nil {
+				// The container was destroyed before being started.
+				_ = ep.hostTTY.Close()
+			}
 			delete(l.processes, key)
 		}
 	}
+	l.startOrder = removeString(l.startOrder, cid)
+	l.restoring = removeString(l.restoring, cid)
 
 	log.Debugf("Container destroyed, cid: %s", cid)
 	return nil
 }
 
-func (l *Loader) executeAsync(args *control.ExecArgs) (kernel.ThreadID, error) {
+// executeAsync starts a process in a container. healthCheck is set if the
+// process is started by a health check, rather than being an exec session.
+func (l *Loader) executeAsync(args *control.ExecArgs, healthCheck bool) (kernel.ThreadID, error) {
 	// Hold the lock for the entire operation to ensure that exec'd process is
 	// added to 'processes' in case it races with destroyContainer().
 	l.mu.Lock()
@@ -940,6 +1319,11 @@ func (l *Loader) executeAsync(args *control.ExecArgs) (kernel.ThreadID, error) {
 	if tg == nil {
 		return 0, fmt.Errorf("container %q not started", args.ContainerID)
 	}
+	if args.ExecID != "" {
+		if _, _, ok := l.findExecSessionLocked(args.ContainerID, args.ExecID); ok {
+			return 0, fmt.Errorf("exec session %q already exists in container %q", args.ExecID, args.ContainerID)
+		}
+	}
 
 	// Get the container MountNamespace from the Task. Try to acquire ref may fail
 	// in case it raced with task exit.
@@ -988,6 +1372,7 @@ func (l *Loader) executeAsync(args *control.ExecArgs) (kernel.ThreadID, error) {
 		args.Envv = envv
 	}
 	args.PIDNamespace = tg.PIDNamespace()
+	args.UTSNamespace = tg.Leader().UTSNamespace()
 
 	args.Limits, err = createLimitSet(l.root.spec)
 	if err != nil {
@@ -1003,17 +1388,95 @@ func (l *Loader) executeAsync(args *control.ExecArgs) (kernel.ThreadID, error) {
 
 	eid := execID{cid: args.ContainerID, pid: tgid}
 	l.processes[eid] = &execProcess{
-		tg:      newTG,
-		tty:     ttyFile,
-		ttyVFS2: ttyFileVFS2,
+		tg:          newTG,
+		tty:         ttyFile,
+		ttyVFS2:     ttyFileVFS2,
+		execID:      args.ExecID,
+		argv:        args.Argv,
+		startTime:   gtime.Now(),
+		healthCheck: healthCheck,
 	}
 	log.Debugf("updated processes: %v", l.processes)
 
 	return tgid, nil
 }
 
+// execSessions returns the exec sessions in the given container that haven't
+// been waited for yet, including the ones that exited.
+func (l *Loader) execSessions(cid string) []ExecSession {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var sessions []ExecSession
+	for eid, ep := range l.processes {
+		// Skip the container's init process, and health checks.
+		if eid.cid != cid || eid.pid == 0 || ep.healthCheck {
+			continue
+		}
+		s := ExecSession{
+			ID:        ep.execID,
+			PID:       int32(eid.pid),
+			Argv:      ep.argv,
+			StartTime: ep.startTime,
+		}
+		if ep.tg.Exited() {
+			s.Exited = true
+			s.ExitStatus = uint32(ep.tg.ExitStatus())
+		}
+		sessions = append(sessions, s)
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].PID < sessions[j].PID })
+	return sessions
+}
+
+// findExecSessionLocked returns the running exec session with the given name.
+//
+// Precondition: l.mu must be held.
+func (l *Loader) findExecSessionLocked(cid, id string) (execID, *execProcess, bool) {
+	for eid, ep := range l.processes {
+		if eid.cid == cid && eid.pid != 0 && !ep.healthCheck && ep.execID == id && !ep.tg.Exited() {
+			return eid, ep, true
+		}
+	}
+	return execID{}, nil, false
+}
+
+// killExecSession sends a signal to the exec session with the given name.
+func (l *Loader) killExecSession(cid, id string, signo int32) error {
+	l.mu.Lock()
+	_, ep, ok := l.findExecSessionLocked(cid, id)
+	l.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("exec session %q not found in container %q", id, cid)
+	}
+	return l.k.SendExternalSignalThreadGroup(ep.tg, &linux.SignalInfo{Signo: signo})
+}
+
+// resizeTTY changes the window size of the TTY attached to the given process.
+func (l *Loader) resizeTTY(cid string, tgid kernel.ThreadID, winsize *linux.Winsize) error {
+	l.mu.Lock()
+	tty, ttyVFS2, err := l.ttyFromIDLocked(execID{cid: cid, pid: tgid})
+	l.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("no thread group found: %w", err)
+	}
+
+	switch {
+	case ttyVFS2 != nil:
+		return ttyVFS2.SetWinsize(winsize)
+	case tty != nil:
+		return tty.SetWinsize(winsize)
+	default:
+		return fmt.Errorf("no TTY attached")
+	}
+}
+
 // waitContainer waits for the init process of a container to exit.
 func (l *Loader) waitContainer(cid string, waitStatus *uint32) error {
+	// Subcontainers being restored only get a thread group once the root
+	// container is restored.
+	l.waitRestored(cid)
+
 	// Don't defer unlock, as doing so would make it impossible for
 	// multiple clients to wait on the same container.
 	tg, err := l.threadGroupFromID(execID{cid: cid})
@@ -1037,6 +1500,21 @@ func (l *Loader) waitContainer(cid string, waitStatus *uint32) error {
 	return nil
 }
 
+// waitRestored blocks until container cid is restored, if it's waiting for
+// the sandbox to be restored.
+func (l *Loader) waitRestored(cid string) {
+	l.mu.Lock()
+	var restored chan struct{}
+	if ep := l.processes[execID{cid: cid}]; ep != nil {
+		restored = ep.restored
+	}
+	l.mu.Unlock()
+
+	if restored != nil {
+		<-restored
+	}
+}
+
 func (l *Loader) waitPID(tgid kernel.ThreadID, cid string, waitStatus *uint32) error {
 	if tgid <= 0 {
 		return fmt.Errorf("PID (%d) must be positive", tgid)
@@ -1126,7 +1604,18 @@ func newRootNetworkNamespace(conf *config.Config, clock tcpip.Clock, uniqueID st
 }
 
 func newEmptySandboxNetworkStack(clock tcpip.Clock, uniqueID stack.UniqueID, allowPacketEndpointWrite bool) (inet.Stack, error) {
-	netProtos := []stack.NetworkProtocolFactory{ipv4.NewProtocol, ipv6.NewProtocol, arp.NewProtocol}
+	netProtos := []stack.NetworkProtocolFactory{
+		ipv4.NewProtocol,
+		ipv6.NewProtocolWithOptions(ipv6.Options{
+			// Report the multicast groups joined by the sandbox, including the
+			// solicited-node groups of its addresses, so that bridges snooping
+			// MLD forward neighbor solicitations to it, as they do for Linux.
+			// Duplicate address detection isn't performed, since the addresses
+			// were already assigned to the host's device.
+			MLD: ipv6.MLDOptions{Enabled: true},
+		}),
+		arp.NewProtocol,
+	}
 	transProtos := []stack.TransportProtocolFactory{
 		tcp.NewProtocol,
 		udp.NewProtocol,
@@ -1217,6 +1706,11 @@ func (l *Loader) signal(cid string, pid, signo int32, mode SignalDeliveryMode) e
 
 	switch mode {
 	case DeliverToProcess:
+		if pid == 0 {
+			l.mu.Lock()
+			signo = l.translateSystemdSignalLocked(cid, signo)
+			l.mu.Unlock()
+		}
 		if err := l.signalProcess(cid, kernel.ThreadID(pid), signo); err != nil {
 			return fmt.Errorf("signaling process in container %q PID %d: %w", cid, pid, err)
 		}
@@ -1246,6 +1740,20 @@ func (l *Loader) signal(cid string, pid, signo int32, mode SignalDeliveryMode) e
 	}
 }
 
+// rootPID translates pid, relative to the PID namespace of the given
+// container, to the root PID namespace.
+func (l *Loader) rootPID(cid string, pid int32) (int32, error) {
+	initTG, err := l.threadGroupFromID(execID{cid: cid})
+	if err != nil {
+		return 0, err
+	}
+	tg := initTG.PIDNamespace().ThreadGroupWithID(kernel.ThreadID(pid))
+	if tg == nil {
+		return 0, fmt.Errorf("no such process with PID %d in container %q", pid, cid)
+	}
+	return int32(l.k.RootPIDNamespace().IDOfThreadGroup(tg)), nil
+}
+
 // signalProcess sends signal to process in the given container. tgid is
 // relative to the root PID namespace, not the container's.
 func (l *Loader) signalProcess(cid string, tgid kernel.ThreadID, signo int32) error {
@@ -1381,3 +1889,13 @@ func createFDTable(ctx context.Context, console bool, stdioFDs []*fd.FD, user sp
 	}
 	return fdTable, ttyFile, ttyFileVFS2, nil
 }
+
+// removeString returns ss without s.
+func removeString(ss []string, s string) []string {
+	for i, v := range ss {
+		if v == s {
+			return append(ss[:i], ss[i+1:]...)
+		}
+	}
+	return ss
+}
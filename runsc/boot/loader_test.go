@@ -238,7 +238,7 @@ func doStartSignal(t *testing.T, vfsEnabled bool) {
 
 	// Trigger the control server StartRoot method.
 	cid := "foo"
-	if err := l.ctrl.manager.StartRoot(&cid, nil); err != nil {
+	if err := l.ctrl.manager.StartRoot(&StartRootArgs{CID: cid}, nil); err != nil {
 		t.Errorf("error calling StartRoot: %v", err)
 	}
 
@@ -730,3 +730,36 @@ func TestRestoreEnvironment(t *testing.T) {
 		})
 	}
 }
+
+func TestTimezoneEnv(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		env  []string
+		tz   string
+		want []string
+	}{
+		{
+			name: "no-timezone",
+			env:  []string{"PATH=/bin"},
+			want: []string{"PATH=/bin"},
+		},
+		{
+			name: "add",
+			env:  []string{"PATH=/bin"},
+			tz:   "CET-1CEST,M3.5.0,M10.5.0/3",
+			want: []string{"PATH=/bin", "TZ=CET-1CEST,M3.5.0,M10.5.0/3"},
+		},
+		{
+			name: "already-set",
+			env:  []string{"TZ=UTC0", "PATH=/bin"},
+			tz:   "CET-1CEST,M3.5.0,M10.5.0/3",
+			want: []string{"TZ=UTC0", "PATH=/bin"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := timezoneEnv(tc.env, tc.tz); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("timezoneEnv(%v, %q), want: %v, got: %v", tc.env, tc.tz, tc.want, got)
+			}
+		})
+	}
+}
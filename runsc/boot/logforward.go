@@ -0,0 +1,213 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boot
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"golang.org/x/sys/unix"
+	"gvisor.dev/gvisor/pkg/fd"
+	"gvisor.dev/gvisor/pkg/log"
+	hostvfs2 "gvisor.dev/gvisor/pkg/sentry/fsimpl/host"
+	"gvisor.dev/gvisor/pkg/sentry/kernel"
+	"gvisor.dev/gvisor/runsc/config"
+)
+
+// LogTagAnnotation is the annotation used to set the identifier that is
+// attached to log messages forwarded from the container's stdout and stderr.
+// It defaults to the container ID, and can't contain control characters.
+const LogTagAnnotation = "dev.gvisor.spec.log-tag"
+
+const (
+	// maxLogLine is the maximum length of a forwarded log message. Longer
+	// lines are split into multiple messages.
+	maxLogLine = 2048
+
+	// Syslog priorities used for stdout and stderr, using the daemon
+	// facility.
+	stdoutPriority = 3<<3 | 6 // LOG_DAEMON | LOG_INFO
+	stderrPriority = 3<<3 | 3 // LOG_DAEMON | LOG_ERR
+)
+
+// logForwarder sends container output to the host's logging daemon over a
+// connected datagram socket donated to the sandbox.
+type logForwarder struct {
+	// driver is the log format, either config.LogForwardSyslog or
+	// config.LogForwardJournald.
+	driver string
+
+	// sock is the socket connected to the logging daemon. It's non-blocking,
+	// messages are dropped if the daemon isn't keeping up.
+	sock *fd.FD
+}
+
+func newLogForwarder(driver string, sockFD int) (*logForwarder, error) {
+	switch driver {
+	case config.LogForwardSyslog, config.LogForwardJournald:
+	default:
+		return nil, fmt.Errorf("invalid log forwarding driver %q", driver)
+	}
+	if err := unix.SetNonblock(sockFD, true); err != nil {
+		return nil, err
+	}
+	return &logForwarder{driver: driver, sock: fd.New(sockFD)}, nil
+}
+
+// logTagFromSpec returns the identifier attached to the log messages of
+// container cid. Tags with control characters are rejected: a newline would
+// let the container add arbitrary fields to its journald entries.
+func logTagFromSpec(spec *specs.Spec, cid string) (string, error) {
+	tag, ok := spec.Annotations[LogTagAnnotation]
+	if !ok || tag == "" {
+		return cid, nil
+	}
+	if strings.IndexFunc(tag, unicode.IsControl) >= 0 {
+		return "", fmt.Errorf("invalid annotation %s=%q: control characters aren't allowed", LogTagAnnotation, tag)
+	}
+	return tag, nil
+}
+
+// format returns the datagram for a single message.
+func (f *logForwarder) format(tag, cid string, priority int, msg []byte, now time.Time) []byte {
+	var b bytes.Buffer
+	switch f.driver {
+	case config.LogForwardJournald:
+		// See https://systemd.io/JOURNAL_NATIVE_PROTOCOL/. Messages never
+		// contain newlines, nor do tags, see logTagFromSpec, and container
+		// IDs, so the simple KEY=value format is sufficient.
+		fmt.Fprintf(&b, "MESSAGE=%s\nPRIORITY=%d\nSYSLOG_FACILITY=%d\nSYSLOG_IDENTIFIER=%s\nCONTAINER_ID=%s\n", msg, priority&7, priority>>3, tag, cid)
+	default:
+		// RFC 3164 format, as expected by /dev/log.
+		fmt.Fprintf(&b, "<%d>%s %s: %s", priority, now.Format(time.Stamp), tag, msg)
+	}
+	return b.Bytes()
+}
+
+// send writes msg to the logging daemon. Errors are ignored.
+func (f *logForwarder) send(tag, cid string, priority int, msg []byte) {
+	_, _ = unix.Write(f.sock.FD(), f.format(tag, cid, priority, msg, time.Now()))
+}
+
+// logWriter is an io.Writer that forwards each line written to it as a
+// separate log message.
+type logWriter struct {
+	fwd      *logForwarder
+	tag      string
+	cid      string
+	priority int
+
+	mu  sync.Mutex
+	buf []byte
+}
+
+// Write implements io.Writer.
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = append(w.buf, p...)
+	rest := w.buf
+	for {
+		i := bytes.IndexByte(rest, '\n')
+		if i < 0 {
+			break
+		}
+		if i > maxLogLine {
+			i = maxLogLine
+		}
+		w.fwd.send(w.tag, w.cid, w.priority, rest[:i])
+		if i < len(rest) && rest[i] == '\n' {
+			i++
+		}
+		rest = rest[i:]
+	}
+	for len(rest) >= maxLogLine {
+		w.fwd.send(w.tag, w.cid, w.priority, rest[:maxLogLine])
+		rest = rest[maxLogLine:]
+	}
+	w.buf = append(w.buf[:0], rest...)
+	return len(p), nil
+}
+
+// startLogForwardingLocked forwards the stdout and stderr of the container's
+// init process to the host's logging daemon, if log forwarding is enabled.
+// Forwarding is best effort, failures are logged but don't prevent the
+// container from running.
+//
+// Caller must hold l.mu.
+func (l *Loader) startLogForwardingLocked(cid string, spec *specs.Spec, tg *kernel.ThreadGroup) {
+	if l.logForwarder == nil {
+		return
+	}
+	if !kernel.VFS2Enabled {
+		log.Warningf("Log forwarding is only supported with VFS2, container %q output won't be forwarded", cid)
+		return
+	}
+	tag, err := logTagFromSpec(spec, cid)
+	if err != nil {
+		log.Warningf("Container %q output won't be forwarded: %v", cid, err)
+		return
+	}
+
+	files := stdioFiles(tg)
+	ctx := l.k.SupervisorContext()
+	defer func() {
+		for _, f := range files {
+			if f != nil {
+				f.DecRef(ctx)
+			}
+		}
+	}()
+
+	var stop []func()
+	for i, priority := range map[int]int{1: stdoutPriority, 2: stderrPriority} {
+		if files[i] == nil || (i == 2 && files[2] == files[1]) {
+			continue
+		}
+		w := &logWriter{fwd: l.logForwarder, tag: tag, cid: cid, priority: priority}
+		remove, err := hostvfs2.AddOutputMirror(files[i], w)
+		if err != nil {
+			log.Warningf("Forwarding output of container %q, fd %d: %v", cid, i, err)
+			continue
+		}
+		stop = append(stop, remove)
+	}
+	if len(stop) == 0 {
+		return
+	}
+	if l.logForwards == nil {
+		l.logForwards = make(map[string][]func())
+	}
+	l.stopLogForwardingLocked(cid)
+	l.logForwards[cid] = stop
+	log.Infof("Forwarding output of container %q to %s, tag: %q", cid, l.logForwarder.driver, tag)
+}
+
+// stopLogForwardingLocked stops forwarding the container's output, if
+// enabled.
+//
+// Caller must hold l.mu.
+func (l *Loader) stopLogForwardingLocked(cid string) {
+	for _, remove := range l.logForwards[cid] {
+		remove()
+	}
+	delete(l.logForwards, cid)
+}
@@ -0,0 +1,126 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boot
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"golang.org/x/sys/unix"
+	"gvisor.dev/gvisor/runsc/config"
+)
+
+func TestLogForwarderFormat(t *testing.T) {
+	now := time.Date(2021, time.March, 4, 5, 6, 7, 0, time.UTC)
+	for _, tc := range []struct {
+		driver string
+		want   string
+	}{
+		{
+			driver: config.LogForwardSyslog,
+			want:   "<30>Mar  4 05:06:07 web: hello",
+		},
+		{
+			driver: config.LogForwardJournald,
+			want:   "MESSAGE=hello\nPRIORITY=6\nSYSLOG_FACILITY=3\nSYSLOG_IDENTIFIER=web\nCONTAINER_ID=abc\n",
+		},
+	} {
+		t.Run(tc.driver, func(t *testing.T) {
+			f := &logForwarder{driver: tc.driver}
+			if got := string(f.format("web", "abc", stdoutPriority, []byte("hello"), now)); got != tc.want {
+				t.Errorf("format(), want: %q, got: %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestLogTagFromSpec(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		tag     string
+		want    string
+		wantErr bool
+	}{
+		{name: "default", want: "cid"},
+		{name: "tag", tag: "web-1", want: "web-1"},
+		{name: "newline", tag: "web\nPRIORITY=0", wantErr: true},
+		{name: "tab", tag: "web\t1", wantErr: true},
+		{name: "delete", tag: "web\x7f", wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			spec := &specs.Spec{}
+			if tc.tag != "" {
+				spec.Annotations = map[string]string{LogTagAnnotation: tc.tag}
+			}
+			got, err := logTagFromSpec(spec, "cid")
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("logTagFromSpec() succeeded with tag %q, want error", tc.tag)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("logTagFromSpec(): %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("logTagFromSpec(), want: %q, got: %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestLogWriter(t *testing.T) {
+	fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_DGRAM, 0)
+	if err != nil {
+		t.Fatalf("Socketpair(): %v", err)
+	}
+	defer unix.Close(fds[1])
+	fwd, err := newLogForwarder(config.LogForwardJournald, fds[0])
+	if err != nil {
+		t.Fatalf("newLogForwarder(): %v", err)
+	}
+	defer fwd.sock.Close()
+
+	w := &logWriter{fwd: fwd, tag: "tag", cid: "cid", priority: stderrPriority}
+	for _, data := range []string{"first line\nsec", "ond line\n", "partial"} {
+		if _, err := w.Write([]byte(data)); err != nil {
+			t.Fatalf("Write(%q): %v", data, err)
+		}
+	}
+	long := strings.Repeat("x", maxLogLine+10)
+	if _, err := w.Write([]byte(long)); err != nil {
+		t.Fatalf("Write(): %v", err)
+	}
+
+	buf := make([]byte, 2*maxLogLine)
+	for _, want := range []string{"first line", "second line", "partial" + long[:maxLogLine-len("partial")]} {
+		n, err := unix.Read(fds[1], buf)
+		if err != nil {
+			t.Fatalf("Read(): %v", err)
+		}
+		msg := string(buf[:n])
+		if !strings.HasPrefix(msg, "MESSAGE="+want+"\n") {
+			t.Errorf("got message %q, want message %q", msg, want)
+		}
+		if !strings.Contains(msg, "PRIORITY=3\n") {
+			t.Errorf("message %q doesn't have error priority", msg)
+		}
+	}
+	if got, want := len(w.buf), len("partial")+len(long)-maxLogLine; got != want {
+		t.Errorf("buffered data, want: %d bytes, got: %d bytes", want, got)
+	}
+}
@@ -22,12 +22,15 @@ import (
 
 	"golang.org/x/sys/unix"
 	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/pkg/sentry/socket/netstack"
 	"gvisor.dev/gvisor/pkg/tcpip"
 	"gvisor.dev/gvisor/pkg/tcpip/link/ethernet"
 	"gvisor.dev/gvisor/pkg/tcpip/link/fdbased"
 	"gvisor.dev/gvisor/pkg/tcpip/link/loopback"
 	"gvisor.dev/gvisor/pkg/tcpip/link/qdisc/fifo"
+	"gvisor.dev/gvisor/pkg/tcpip/link/sharedmem"
 	"gvisor.dev/gvisor/pkg/tcpip/link/sniffer"
+	"gvisor.dev/gvisor/pkg/tcpip/link/xdp"
 	"gvisor.dev/gvisor/pkg/tcpip/network/ipv4"
 	"gvisor.dev/gvisor/pkg/tcpip/network/ipv6"
 	"gvisor.dev/gvisor/pkg/tcpip/stack"
@@ -102,6 +105,37 @@ type FDBasedLink struct {
 	NumChannels int
 }
 
+// XDPLink configures a link backed by an AF_XDP socket, bound to the first
+// queue of a host device. The socket is created by the caller, and must be
+// inserted in the XSKMAP of the XDP program of the device once it's bound.
+type XDPLink struct {
+	Name           string
+	InterfaceIndex int
+	MTU            int
+	Addresses      []IPWithPrefix
+	Routes         []Route
+	LinkAddress    net.HardwareAddr
+	QDisc          config.QueueingDiscipline
+	Neighbors      []Neighbor
+}
+
+// SharedMemLink configures a link backed by shared memory queues, connected to
+// a port of a bridge shared with other sandboxes (see
+// pkg/tcpip/link/sharedmem/bridge).
+type SharedMemLink struct {
+	Name        string
+	MTU         int
+	BufferSize  int
+	Addresses   []IPWithPrefix
+	Routes      []Route
+	LinkAddress net.HardwareAddr
+}
+
+// SharedMemLinkFDs is the number of fds of a SharedMemLink: five for each of
+// the TX and RX queues, in the order of sharedmem.QueueConfig.FDs, followed by
+// the peer fd.
+const SharedMemLinkFDs = 11
+
 // LoopbackLink configures a loopback li nk.
 type LoopbackLink struct {
 	Name      string
@@ -111,16 +145,30 @@ type LoopbackLink struct {
 
 // CreateLinksAndRoutesArgs are arguments to CreateLinkAndRoutes.
 type CreateLinksAndRoutesArgs struct {
-	// FilePayload contains the fds associated with the FDBasedLinks. The
-	// number of fd's should match the sum of the NumChannels field of the
-	// FDBasedLink entries below.
+	// FilePayload contains the fds associated with the FDBasedLinks, followed
+	// by the AF_XDP sockets of the XDPLinks and the fds of the SharedMemLinks.
+	// The number of fd's should match the sum of the NumChannels field of the
+	// FDBasedLink entries below, plus one for each XDPLink, plus
+	// SharedMemLinkFDs for each SharedMemLink, plus one if DockerDNS is set.
 	urpc.FilePayload
 
-	LoopbackLinks []LoopbackLink
-	FDBasedLinks  []FDBasedLink
+	LoopbackLinks  []LoopbackLink
+	FDBasedLinks   []FDBasedLink
+	XDPLinks       []XDPLink
+	SharedMemLinks []SharedMemLink
 
 	Defaultv4Gateway DefaultRoute
 	Defaultv6Gateway DefaultRoute
+
+	// DockerDNS indicates that queries to Docker's embedded DNS server
+	// (DockerDNSAddr) must be forwarded to the host. If set, the last fd in
+	// FilePayload is a UDP socket connected to the server.
+	DockerDNS bool
+
+	// DNSCacheServers are the servers that a caching DNS resolver listening
+	// on DNSCacheAddr forwards queries to. The resolver is only started if
+	// it isn't empty.
+	DNSCacheServers []net.UDPAddr
 }
 
 // IPWithPrefix is an address with its subnet prefix length.
@@ -160,8 +208,13 @@ func (n *Network) CreateLinksAndRoutes(args *CreateLinksAndRoutesArgs, _ *struct
 	for _, l := range args.FDBasedLinks {
 		wantFDs += l.NumChannels
 	}
+	wantFDs += len(args.XDPLinks)
+	wantFDs += len(args.SharedMemLinks) * SharedMemLinkFDs
+	if args.DockerDNS {
+		wantFDs++
+	}
 	if got := len(args.FilePayload.Files); got != wantFDs {
-		return fmt.Errorf("args.FilePayload.Files has %d FD's but we need %d entries based on FDBasedLinks", got, wantFDs)
+		return fmt.Errorf("args.FilePayload.Files has %d FD's but we need %d entries based on FDBasedLinks, XDPLinks and SharedMemLinks", got, wantFDs)
 	}
 
 	var nicID tcpip.NICID
@@ -171,9 +224,13 @@ func (n *Network) CreateLinksAndRoutes(args *CreateLinksAndRoutesArgs, _ *struct
 	var routes []tcpip.Route
 
 	// Loopback normally appear before other interfaces.
+	var loopbackNICID tcpip.NICID
 	for _, link := range args.LoopbackLinks {
 		nicID++
 		nicids[link.Name] = nicID
+		if loopbackNICID == 0 {
+			loopbackNICID = nicID
+		}
 
 		linkEP := ethernet.New(loopback.New())
 
@@ -254,6 +311,131 @@ func (n *Network) CreateLinksAndRoutes(args *CreateLinksAndRoutesArgs, _ *struct
 		}
 	}
 
+	for _, link := range args.XDPLinks {
+		nicID++
+		nicids[link.Name] = nicID
+
+		oldFD := args.FilePayload.Files[fdOffset].Fd()
+		fd, err := unix.Dup(int(oldFD))
+		if err != nil {
+			return fmt.Errorf("failed to dup FD %v: %v", oldFD, err)
+		}
+		fdOffset++
+
+		mac := tcpip.LinkAddress(link.LinkAddress)
+		linkEP, err := xdp.New(&xdp.Options{
+			FD:             fd,
+			InterfaceIndex: link.InterfaceIndex,
+			MTU:            uint32(link.MTU),
+			Address:        mac,
+		})
+		if err != nil {
+			return err
+		}
+
+		switch link.QDisc {
+		case config.QDiscNone:
+		case config.QDiscFIFO:
+			log.Infof("Enabling FIFO QDisc on %q", link.Name)
+			linkEP = fifo.New(linkEP, runtime.GOMAXPROCS(0), 1000)
+		}
+
+		log.Infof("Enabling AF_XDP interface %q with id %d on addresses %+v (%v)", link.Name, nicID, link.Addresses, mac)
+		if err := n.createNICWithAddrs(nicID, link.Name, linkEP, link.Addresses); err != nil {
+			return err
+		}
+
+		for _, r := range link.Routes {
+			route, err := r.toTcpipRoute(nicID)
+			if err != nil {
+				return err
+			}
+			routes = append(routes, route)
+		}
+
+		for _, neigh := range link.Neighbors {
+			proto, tcpipAddr := ipToAddressAndProto(neigh.IP)
+			n.Stack.AddStaticNeighbor(nicID, proto, tcpipAddr, tcpip.LinkAddress(neigh.HardwareAddr))
+		}
+	}
+
+	for _, link := range args.SharedMemLinks {
+		nicID++
+		nicids[link.Name] = nicID
+
+		var fds []int
+		for j := 0; j < SharedMemLinkFDs; j++ {
+			oldFD := args.FilePayload.Files[fdOffset].Fd()
+			newFD, err := unix.Dup(int(oldFD))
+			if err != nil {
+				return fmt.Errorf("failed to dup FD %v: %v", oldFD, err)
+			}
+			fds = append(fds, newFD)
+			fdOffset++
+		}
+		tx, err := sharedmem.QueueConfigFromFDs(fds[0:5])
+		if err != nil {
+			return err
+		}
+		rx, err := sharedmem.QueueConfigFromFDs(fds[5:10])
+		if err != nil {
+			return err
+		}
+
+		name := link.Name
+		mac := tcpip.LinkAddress(link.LinkAddress)
+		linkEP, err := sharedmem.New(sharedmem.Options{
+			MTU:         uint32(link.MTU),
+			BufferSize:  uint32(link.BufferSize),
+			LinkAddress: mac,
+			TX:          tx,
+			RX:          rx,
+			PeerFD:      fds[10],
+			OnClosed: func(err tcpip.Error) {
+				log.Warningf("Shared memory bridge of interface %q went away: %v", name, err)
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("creating shared memory endpoint: %v", err)
+		}
+
+		log.Infof("Enabling shared memory interface %q with id %d on addresses %+v (%v)", link.Name, nicID, link.Addresses, mac)
+		if err := n.createNICWithAddrs(nicID, link.Name, linkEP, link.Addresses); err != nil {
+			return err
+		}
+
+		for _, r := range link.Routes {
+			route, err := r.toTcpipRoute(nicID)
+			if err != nil {
+				return err
+			}
+			routes = append(routes, route)
+		}
+	}
+
+	if args.DockerDNS {
+		if loopbackNICID == 0 {
+			return fmt.Errorf("DNS forwarding requires a loopback interface")
+		}
+		oldFD := args.FilePayload.Files[fdOffset].Fd()
+		newFD, err := unix.Dup(int(oldFD))
+		if err != nil {
+			return fmt.Errorf("failed to dup FD %v: %v", oldFD, err)
+		}
+		if err := n.startDockerDNSForwarder(loopbackNICID, newFD); err != nil {
+			return fmt.Errorf("forwarding DNS queries: %w", err)
+		}
+	}
+
+	if len(args.DNSCacheServers) > 0 {
+		if loopbackNICID == 0 {
+			return fmt.Errorf("DNS caching requires a loopback interface")
+		}
+		if err := n.startDNSCache(loopbackNICID, args.DNSCacheServers); err != nil {
+			return fmt.Errorf("starting DNS cache: %w", err)
+		}
+	}
+
 	if !args.Defaultv4Gateway.Route.Empty() {
 		nicID, ok := nicids[args.Defaultv4Gateway.Name]
 		if !ok {
@@ -283,6 +465,38 @@ func (n *Network) CreateLinksAndRoutes(args *CreateLinksAndRoutesArgs, _ *struct
 	return nil
 }
 
+// PortConfig is the ephemeral port configuration of a network stack, i.e. the
+// ports that are picked by bind(2) with port 0 or connect(2) on an unbound
+// socket.
+type PortConfig struct {
+	// Start and End are the inclusive range of ephemeral ports, like the
+	// net.ipv4.ip_local_port_range sysctl.
+	Start uint16
+	End   uint16
+
+	// Reserved are ports of the range that are never picked, but can still
+	// be bound explicitly, like the net.ipv4.ip_local_reserved_ports sysctl.
+	Reserved []uint16
+}
+
+// Ports returns the ephemeral port configuration of the network stack.
+func (n *Network) Ports(_ *struct{}, out *PortConfig) error {
+	out.Start, out.End = n.Stack.PortRange()
+	out.Reserved = n.Stack.ReservedPorts()
+	return nil
+}
+
+// SetPorts changes the ephemeral port configuration of the network stack.
+// Ports that are already in use are not affected.
+func (n *Network) SetPorts(args *PortConfig, _ *struct{}) error {
+	if err := n.Stack.SetPortRange(args.Start, args.End); err != nil {
+		return fmt.Errorf("setting port range %d-%d: %s", args.Start, args.End, err)
+	}
+	n.Stack.SetReservedPorts(args.Reserved)
+	log.Infof("Ephemeral ports set to %d-%d, reserved: %v", args.Start, args.End, args.Reserved)
+	return nil
+}
+
 // createNICWithAddrs creates a NIC in the network stack and adds the given
 // addresses.
 func (n *Network) createNICWithAddrs(id tcpip.NICID, name string, ep stack.LinkEndpoint, addrs []IPWithPrefix) error {
@@ -303,6 +517,7 @@ func (n *Network) createNICWithAddrs(id tcpip.NICID, name string, ep stack.LinkE
 		if err := n.Stack.AddProtocolAddress(id, protocolAddr, stack.AddressProperties{}); err != nil {
 			return fmt.Errorf("AddProtocolAddress(%d, %+v, {}) failed: %s", id, protocolAddr, err)
 		}
+		netstack.AnnounceAddress(n.Stack, id, protocolAddr)
 	}
 	return nil
 }
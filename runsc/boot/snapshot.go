@@ -0,0 +1,165 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boot
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/pkg/sentry/state"
+	"gvisor.dev/gvisor/pkg/urpc"
+)
+
+// StartSnapshotsArgs are the arguments to the StartSnapshots call.
+type StartSnapshotsArgs struct {
+	// Interval is the time between snapshots.
+	Interval time.Duration
+
+	// FilePayload contains the images snapshots are written to, in rotation.
+	// The number of files is the number of snapshots kept.
+	urpc.FilePayload
+}
+
+// snapshotter periodically saves the state of the sandbox to images, while
+// leaving it running. Unlike checkpoints, snapshots don't stop the sandbox:
+// tasks are paused while their state is written, and the kernel is resumed
+// from the preparation for the save before they are unpaused.
+type snapshotter struct {
+	l        *Loader
+	interval time.Duration
+
+	// files are the images, written in rotation. Each snapshot overwrites the
+	// oldest one.
+	files []*os.File
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// startSnapshots starts taking snapshots every interval to files, replacing
+// the previous schedule if any. It takes ownership of files.
+func (l *Loader) startSnapshots(interval time.Duration, files []*os.File) error {
+	if interval <= 0 {
+		closeFiles(files)
+		return fmt.Errorf("invalid snapshot interval %v", interval)
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("at least one snapshot image is required")
+	}
+	s := &snapshotter{
+		l:        l,
+		interval: interval,
+		files:    files,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+
+	log.Infof("Starting snapshots every %v, keeping %d", interval, len(files))
+	l.mu.Lock()
+	old := l.snapshotter
+	l.snapshotter = s
+	// The first snapshot is only taken after an interval, once old is
+	// stopped.
+	go s.run() // S/R-SAFE: stopped before checkpoints.
+	l.mu.Unlock()
+	if old != nil {
+		old.stopAndWait()
+	}
+	return nil
+}
+
+// stopSnapshots stops taking snapshots, and waits for the snapshot in
+// progress to complete if any.
+func (l *Loader) stopSnapshots() {
+	l.mu.Lock()
+	s := l.snapshotter
+	l.snapshotter = nil
+	l.mu.Unlock()
+
+	if s != nil {
+		s.stopAndWait()
+		log.Infof("Snapshots stopped")
+	}
+}
+
+func (s *snapshotter) stopAndWait() {
+	close(s.stop)
+	<-s.done
+}
+
+func (s *snapshotter) run() {
+	defer close(s.done)
+	defer closeFiles(s.files)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	next := 0
+	seq := uint64(1)
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+
+		start := time.Now()
+		if err := s.save(s.files[next], seq); err != nil {
+			// Retry in the same image next time, to keep the previous
+			// snapshots.
+			log.Warningf("Snapshot %d failed: %v", seq, err)
+			continue
+		}
+		log.Infof("Snapshot %d saved in %v", seq, time.Since(start))
+		next = (next + 1) % len(s.files)
+		seq++
+	}
+}
+
+// save writes the state of the sandbox to f, replacing its content.
+func (s *snapshotter) save(f *os.File, seq uint64) error {
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	metadata, err := s.l.checkpointMetadata()
+	if err != nil {
+		return err
+	}
+	metadata[MetadataSnapshot] = strconv.FormatUint(seq, 10)
+
+	opts := state.SaveOpts{
+		Destination: f,
+		Metadata:    metadata,
+		Callback:    func(error) {},
+		// Unlike checkpoints, the sandbox keeps running after the save.
+		Resume: true,
+	}
+	if err := opts.Save(s.l.k.SupervisorContext(), s.l.k, s.l.watchdog); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+func closeFiles(files []*os.File) {
+	for _, f := range files {
+		f.Close()
+	}
+}
@@ -0,0 +1,188 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boot
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/pkg/sentry/inet"
+	"gvisor.dev/gvisor/pkg/sentry/kernel"
+)
+
+// sysctlFunc applies the value of a sysctl to the namespaces it belongs to.
+type sysctlFunc func(netns *inet.Namespace, ipcns *kernel.IPCNamespace, val string) error
+
+// sysctls are the sysctls that can be set in the spec, named like in the OCI
+// spec and sysctl(8).
+var sysctls = map[string]sysctlFunc{
+	"kernel.shmall": func(_ *inet.Namespace, ipcns *kernel.IPCNamespace, val string) error {
+		return setShmParam(ipcns, val, func(p *linux.ShmParams, v uint64) { p.ShmAll = v })
+	},
+	"kernel.shmmax": func(_ *inet.Namespace, ipcns *kernel.IPCNamespace, val string) error {
+		return setShmParam(ipcns, val, func(p *linux.ShmParams, v uint64) { p.ShmMax = v })
+	},
+	"kernel.shmmni": func(_ *inet.Namespace, ipcns *kernel.IPCNamespace, val string) error {
+		return setShmParam(ipcns, val, func(p *linux.ShmParams, v uint64) { p.ShmMni = v })
+	},
+	"net.core.somaxconn": func(netns *inet.Namespace, _ *kernel.IPCNamespace, val string) error {
+		v, err := strconv.ParseUint(val, 10, 32)
+		if err != nil || v > math.MaxInt32 {
+			return fmt.Errorf("invalid value %q", val)
+		}
+		netns.SetSomaxconn(uint32(v))
+		return nil
+	},
+	"net.ipv4.ip_local_port_range": func(netns *inet.Namespace, _ *kernel.IPCNamespace, val string) error {
+		stack, err := sysctlStack(netns)
+		if err != nil {
+			return err
+		}
+		start, end, err := ParsePortRange(val)
+		if err != nil {
+			return err
+		}
+		return stack.SetPortRange(start, end)
+	},
+	"net.ipv4.ip_local_reserved_ports": func(netns *inet.Namespace, _ *kernel.IPCNamespace, val string) error {
+		stack, err := sysctlStack(netns)
+		if err != nil {
+			return err
+		}
+		ports, err := ParsePortList(val)
+		if err != nil {
+			return err
+		}
+		return stack.SetReservedPorts(ports)
+	},
+	"net.ipv4.tcp_sack": func(netns *inet.Namespace, _ *kernel.IPCNamespace, val string) error {
+		stack, err := sysctlStack(netns)
+		if err != nil {
+			return err
+		}
+		v, err := strconv.ParseUint(val, 10, 8)
+		if err != nil {
+			return fmt.Errorf("invalid value %q", val)
+		}
+		return stack.SetTCPSACKEnabled(v != 0)
+	},
+}
+
+// applySysctls applies the sysctls set in the spec, returning an error if
+// any of them isn't supported or has an invalid value. Sysctls apply to the
+// network and IPC namespaces of the container, which are shared by all
+// containers in the sandbox.
+func applySysctls(values map[string]string, netns *inet.Namespace, ipcns *kernel.IPCNamespace) error {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		// sysctl(8) accepts both '.' and '/' as separators.
+		fn, ok := sysctls[strings.ReplaceAll(name, "/", ".")]
+		if !ok {
+			return fmt.Errorf("sysctl %q is not supported", name)
+		}
+		val := strings.TrimSpace(values[name])
+		if err := fn(netns, ipcns, val); err != nil {
+			return fmt.Errorf("setting sysctl %q to %q: %w", name, val, err)
+		}
+		log.Infof("Set sysctl %q to %q", name, val)
+	}
+	return nil
+}
+
+// setShmParam parses val and sets it as one of the SysV shared memory limits
+// of ipcns.
+func setShmParam(ipcns *kernel.IPCNamespace, val string, set func(*linux.ShmParams, uint64)) error {
+	v, err := strconv.ParseUint(val, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid value %q", val)
+	}
+	reg := ipcns.ShmRegistry()
+	params := reg.IPCInfo()
+	set(params, v)
+	return reg.SetIPCInfo(params)
+}
+
+// ParsePortRange parses an ephemeral port range in the format of the
+// net.ipv4.ip_local_port_range sysctl, e.g. "32768 60999".
+func ParsePortRange(val string) (uint16, uint16, error) {
+	fields := strings.Fields(val)
+	if len(fields) != 2 {
+		return 0, 0, fmt.Errorf("invalid value %q, must be \"<start> <end>\"", val)
+	}
+	start, err := strconv.ParseUint(fields[0], 10, 16)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid start port %q", fields[0])
+	}
+	end, err := strconv.ParseUint(fields[1], 10, 16)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid end port %q", fields[1])
+	}
+	if start > end {
+		return 0, 0, fmt.Errorf("start port %d is greater than end port %d", start, end)
+	}
+	return uint16(start), uint16(end), nil
+}
+
+// ParsePortList parses a list of ports in the format of the
+// net.ipv4.ip_local_reserved_ports sysctl, i.e. comma-separated ports and
+// inclusive port ranges, e.g. "8080,9000-9010". An empty list is valid.
+func ParsePortList(val string) ([]uint16, error) {
+	var ports []uint16
+	for _, item := range strings.Split(val, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		first, last := item, item
+		if i := strings.Index(item, "-"); i >= 0 {
+			first, last = item[:i], item[i+1:]
+		}
+		start, err := strconv.ParseUint(first, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port %q", first)
+		}
+		end, err := strconv.ParseUint(last, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port %q", last)
+		}
+		if start > end {
+			return nil, fmt.Errorf("invalid port range %q", item)
+		}
+		for port := start; port <= end; port++ {
+			ports = append(ports, uint16(port))
+		}
+	}
+	return ports, nil
+}
+
+// sysctlStack returns the network stack of netns, or an error if there is
+// none.
+func sysctlStack(netns *inet.Namespace) (inet.Stack, error) {
+	stack := netns.Stack()
+	if stack == nil {
+		return nil, fmt.Errorf("network stack not available")
+	}
+	return stack, nil
+}
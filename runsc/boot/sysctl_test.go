@@ -0,0 +1,84 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boot
+
+import (
+	"reflect"
+	"testing"
+
+	"gvisor.dev/gvisor/pkg/sentry/inet"
+	"gvisor.dev/gvisor/pkg/sentry/kernel"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/auth"
+)
+
+func TestApplySysctls(t *testing.T) {
+	stack := inet.NewTestStack()
+	netns := inet.NewRootNamespace(stack, nil)
+	ipcns := kernel.NewIPCNamespace(auth.NewRootUserNamespace())
+
+	if err := applySysctls(map[string]string{
+		"kernel.shmmax":                    "4096",
+		"kernel/shmmni":                    "100",
+		"net.core.somaxconn":               "4096",
+		"net.ipv4.ip_local_port_range":     "10000 20000",
+		"net.ipv4.ip_local_reserved_ports": "8080,10000-10002",
+		"net.ipv4.tcp_sack":                "1",
+	}, netns, ipcns); err != nil {
+		t.Fatalf("applySysctls() failed: %v", err)
+	}
+
+	params := ipcns.ShmRegistry().IPCInfo()
+	if params.ShmMax != 4096 {
+		t.Errorf("ShmMax got %d, want 4096", params.ShmMax)
+	}
+	if params.ShmMni != 100 {
+		t.Errorf("ShmMni got %d, want 100", params.ShmMni)
+	}
+	if got := netns.Somaxconn(); got != 4096 {
+		t.Errorf("Somaxconn() got %d, want 4096", got)
+	}
+	if !stack.TCPSACKFlag {
+		t.Errorf("TCP SACK wasn't enabled")
+	}
+	if got, want := stack.ReservedPortList, []uint16{8080, 10000, 10001, 10002}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ReservedPorts() got %v, want %v", got, want)
+	}
+}
+
+func TestApplySysctlsErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		val  string
+	}{
+		{name: "kernel.msgmax", val: "1024"},
+		{name: "net.ipv4.conf.all.rp_filter", val: "1"},
+		{name: "kernel.shmmax", val: "-1"},
+		{name: "kernel.shmmni", val: "100000"},
+		{name: "net.core.somaxconn", val: "abc"},
+		{name: "net.ipv4.ip_local_port_range", val: "10000"},
+		{name: "net.ipv4.ip_local_port_range", val: "10000 70000"},
+		{name: "net.ipv4.ip_local_port_range", val: "20000 10000"},
+		{name: "net.ipv4.ip_local_reserved_ports", val: "8080,abc"},
+		{name: "net.ipv4.ip_local_reserved_ports", val: "9000-8000"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			netns := inet.NewRootNamespace(inet.NewTestStack(), nil)
+			ipcns := kernel.NewIPCNamespace(auth.NewRootUserNamespace())
+			if err := applySysctls(map[string]string{tc.name: tc.val}, netns, ipcns); err == nil {
+				t.Errorf("applySysctls(%q=%q) succeeded, want error", tc.name, tc.val)
+			}
+		})
+	}
+}
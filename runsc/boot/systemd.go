@@ -0,0 +1,115 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boot
+
+import (
+	"strconv"
+	"strings"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/pkg/sentry/fsimpl/cgroupfs"
+	tmpfsvfs2 "gvisor.dev/gvisor/pkg/sentry/fsimpl/tmpfs"
+	"gvisor.dev/gvisor/runsc/config"
+)
+
+// SystemdAnnotation enables systemd compatibility mode for a container, like
+// the --systemd flag does for all containers. Valid values are "true" and
+// "false".
+const SystemdAnnotation = "dev.gvisor.spec.systemd"
+
+// systemdShutdownSignal is SIGRTMIN+3, which requests systemd to halt the
+// system. SIGTERM makes systemd reexecute itself instead. Note that SIGRTMIN
+// is 34 in glibc, which reserves the first two real-time signals.
+const systemdShutdownSignal = linux.Signal(linux.FirstRTSignal + 2 + 3)
+
+// systemdControllers are the cgroup controllers mounted in systemd mode, each
+// in its own hierarchy.
+var systemdControllers = []string{"cpu", "cpuacct", "cpuset", "memory"}
+
+// systemdEnabled returns whether systemd compatibility mode is enabled for
+// the container. The annotation takes precedence over the flag.
+func systemdEnabled(spec *specs.Spec, conf *config.Config) bool {
+	if val, ok := spec.Annotations[SystemdAnnotation]; ok {
+		enabled, err := strconv.ParseBool(val)
+		if err == nil {
+			return enabled
+		}
+		log.Warningf("ignoring invalid %q annotation: %q", SystemdAnnotation, val)
+	}
+	return conf.Systemd
+}
+
+// systemdMounts returns the mounts systemd expects when running as PID 1. The
+// cgroup hierarchies are mounted read-write, with the "name=systemd"
+// hierarchy used by systemd to track services. /run and /run/lock are only
+// added if the spec doesn't mount them already.
+func systemdMounts(mounted map[string]struct{}) []specs.Mount {
+	mounts := []specs.Mount{
+		{
+			Type:        tmpfsvfs2.Name,
+			Destination: "/sys/fs/cgroup",
+			Options:     []string{"rw", "mode=755"},
+		},
+	}
+	for _, ctrl := range systemdControllers {
+		mounts = append(mounts, specs.Mount{
+			Type:        cgroupfs.Name,
+			Destination: "/sys/fs/cgroup/" + ctrl,
+			Options:     []string{"rw", ctrl},
+		})
+	}
+	mounts = append(mounts, specs.Mount{
+		Type:        cgroupfs.Name,
+		Destination: "/sys/fs/cgroup/systemd",
+		Options:     []string{"rw", "none", "name=systemd"},
+	})
+	for _, dst := range []string{"/run", "/run/lock"} {
+		if _, ok := mounted[dst]; ok {
+			continue
+		}
+		mounts = append(mounts, specs.Mount{
+			Type:        tmpfsvfs2.Name,
+			Destination: dst,
+			Options:     []string{"rw", "mode=755"},
+		})
+	}
+	return mounts
+}
+
+// systemdEnv sets the "container" environment variable, which systemd uses to
+// detect that it's running in a container, unless it's already set.
+func systemdEnv(env []string) []string {
+	for _, e := range env {
+		if strings.HasPrefix(e, "container=") {
+			return env
+		}
+	}
+	return append(env, "container=gvisor")
+}
+
+// translateSystemdSignalLocked translates a signal sent to the init process of
+// a container running systemd, so that SIGTERM shuts the container down
+// cleanly.
+//
+// Caller must hold l.mu.
+func (l *Loader) translateSystemdSignalLocked(cid string, signo int32) int32 {
+	if _, ok := l.systemdContainers[cid]; !ok || signo != int32(linux.SIGTERM) {
+		return signo
+	}
+	log.Infof("Translating SIGTERM to SIGRTMIN+3 for systemd in container %q", cid)
+	return int32(systemdShutdownSignal)
+}
@@ -0,0 +1,90 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boot
+
+import (
+	"sort"
+	"time"
+
+	"gvisor.dev/gvisor/pkg/log"
+)
+
+// BootPhase is a timed phase of the creation or start of a container, to tell
+// whether slow starts come from the host, the image or the sentry.
+type BootPhase struct {
+	// Name describes the phase, e.g. "mount setup".
+	Name string `json:"name"`
+
+	// Sandbox is true if the phase ran in the sandbox, and false if it ran
+	// in runsc.
+	Sandbox bool `json:"sandbox,omitempty"`
+
+	// Start is the time the phase started.
+	Start time.Time `json:"start"`
+
+	// Duration is the time the phase took.
+	Duration time.Duration `json:"duration"`
+}
+
+// BootTimeline is the phases of the creation and start of a container.
+type BootTimeline []BootPhase
+
+// Record adds the phase name, which started at start and ends now.
+func (t *BootTimeline) Record(name string, start time.Time) {
+	*t = append(*t, BootPhase{
+		Name:     name,
+		Start:    start,
+		Duration: time.Since(start),
+	})
+}
+
+// Sorted returns the phases of t sorted by start time. Phases recorded by
+// runsc may contain phases recorded by the sandbox.
+func (t BootTimeline) Sorted() BootTimeline {
+	sorted := append(BootTimeline(nil), t...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Start.Before(sorted[j].Start)
+	})
+	return sorted
+}
+
+// recordBootPhase adds the phase name of container cid, which started at start
+// and ends now.
+func (l *Loader) recordBootPhase(cid, name string, start time.Time) {
+	l.timelineMu.Lock()
+	defer l.timelineMu.Unlock()
+	t := l.timelines[cid]
+	t.Record(name, start)
+	l.timelines[cid] = t
+}
+
+// bootTimeline returns the phases of container cid that ran in the sandbox.
+func (l *Loader) bootTimeline(cid string) BootTimeline {
+	l.timelineMu.Lock()
+	defer l.timelineMu.Unlock()
+	t := append(BootTimeline(nil), l.timelines[cid]...)
+	for i := range t {
+		t[i].Sandbox = true
+	}
+	return t
+}
+
+// BootTimeline returns the phases of the boot of the given container that ran
+// in the sandbox.
+func (cm *containerManager) BootTimeline(cid *string, out *BootTimeline) error {
+	log.Debugf("containerManager.BootTimeline, cid: %s", *cid)
+	*out = cm.l.bootTimeline(*cid)
+	return nil
+}
@@ -0,0 +1,45 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBootTimeline(t *testing.T) {
+	now := time.Now()
+	var tl BootTimeline
+	tl.Record("start", now.Add(-time.Second))
+	tl.Record("gofer start", now.Add(-3*time.Second))
+	tl.Record("mount setup", now.Add(-2*time.Second))
+
+	sorted := tl.Sorted()
+	want := []string{"gofer start", "mount setup", "start"}
+	if len(sorted) != len(want) {
+		t.Fatalf("got %d phases, want %d", len(sorted), len(want))
+	}
+	for i, name := range want {
+		if sorted[i].Name != name {
+			t.Errorf("phase %d: got %q, want %q", i, sorted[i].Name, name)
+		}
+	}
+	if d := sorted[0].Duration; d < 3*time.Second {
+		t.Errorf("gofer start duration: got %v, want at least 3s", d)
+	}
+	if tl[0].Name != "start" {
+		t.Errorf("Sorted modified the timeline, first phase: %q", tl[0].Name)
+	}
+}
@@ -0,0 +1,249 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boot
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/pkg/sentry/inet"
+	"gvisor.dev/gvisor/pkg/sentry/pgalloc"
+	"gvisor.dev/gvisor/pkg/sentry/strace"
+	"gvisor.dev/gvisor/pkg/sync"
+)
+
+// Tunable is a sentry setting that can be changed while the sandbox runs.
+type Tunable struct {
+	// Name is the name of the tunable, e.g. "watchdog.task_timeout".
+	Name string
+
+	// Value is the current value of the tunable.
+	Value string
+
+	// Description explains what the tunable does and its format.
+	Description string
+}
+
+// SetTunableArgs are the arguments to the Tunables.Set RPC.
+type SetTunableArgs struct {
+	// Name is the name of the tunable to change.
+	Name string
+
+	// Value is the new value, in the same format as Tunable.Value.
+	Value string
+}
+
+// tunable describes how to get and set a tunable.
+type tunable struct {
+	desc string
+	get  func(t *Tunables) (string, error)
+	set  func(t *Tunables, value string) error
+}
+
+// tunables are all the tunables, by name.
+var tunables = map[string]tunable{
+	"watchdog.task_timeout": {
+		desc: "time after which a task stuck in the sentry is reported by the watchdog, 0 disables it, e.g. 3m",
+		get: func(t *Tunables) (string, error) {
+			return t.l.watchdog.CurrentTaskTimeout().String(), nil
+		},
+		set: func(t *Tunables, value string) error {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return err
+			}
+			if d < 0 {
+				return fmt.Errorf("timeout must not be negative")
+			}
+			t.l.watchdog.SetTaskTimeout(d)
+			return nil
+		},
+	},
+	"memory.delayed_eviction": {
+		desc: "whether reclaim of evictable memory (e.g. file caches) is delayed until memory is needed: enabled or disabled",
+		get: func(t *Tunables) (string, error) {
+			return delayedEvictionToString(t.l.k.MemoryFile().DelayedEviction()), nil
+		},
+		set: func(t *Tunables, value string) error {
+			de, err := delayedEvictionFromString(value)
+			if err != nil {
+				return err
+			}
+			return t.l.k.MemoryFile().SetDelayedEviction(de)
+		},
+	},
+	"net.ipv4.tcp_rmem": {
+		desc: `TCP receive buffer sizes, as "min default max" in bytes`,
+		get: func(t *Tunables) (string, error) {
+			size, err := t.l.k.RootNetworkNamespace().Stack().TCPReceiveBufferSize()
+			if err != nil {
+				return "", err
+			}
+			return formatTCPBufferSize(size), nil
+		},
+		set: func(t *Tunables, value string) error {
+			size, err := parseTCPBufferSize(value)
+			if err != nil {
+				return err
+			}
+			return t.l.k.RootNetworkNamespace().Stack().SetTCPReceiveBufferSize(size)
+		},
+	},
+	"net.ipv4.tcp_wmem": {
+		desc: `TCP send buffer sizes, as "min default max" in bytes`,
+		get: func(t *Tunables) (string, error) {
+			size, err := t.l.k.RootNetworkNamespace().Stack().TCPSendBufferSize()
+			if err != nil {
+				return "", err
+			}
+			return formatTCPBufferSize(size), nil
+		},
+		set: func(t *Tunables, value string) error {
+			size, err := parseTCPBufferSize(value)
+			if err != nil {
+				return err
+			}
+			return t.l.k.RootNetworkNamespace().Stack().SetTCPSendBufferSize(size)
+		},
+	},
+	"strace.enabled": {
+		desc: "whether all syscalls are traced to the log: true or false",
+		get: func(t *Tunables) (string, error) {
+			return strconv.FormatBool(t.straceEnabled), nil
+		},
+		set: func(t *Tunables, value string) error {
+			enable, err := strconv.ParseBool(value)
+			if err != nil {
+				return err
+			}
+			if enable {
+				strace.EnableAll(strace.SinkTypeLog)
+			} else {
+				strace.Disable(strace.SinkTypeLog)
+			}
+			t.straceEnabled = enable
+			return nil
+		},
+	},
+}
+
+// Tunables exposes the sentry tunables to the control server, for
+// "runsc tune".
+type Tunables struct {
+	l *Loader
+
+	// mu serializes changes to the tunables.
+	mu sync.Mutex
+
+	// straceEnabled is the last value of strace.enabled. It doesn't reflect
+	// changes made with "runsc debug --strace".
+	straceEnabled bool
+}
+
+// Get returns the tunables with the given names, or all of them if no names
+// are given.
+func (t *Tunables) Get(names *[]string, out *[]Tunable) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(*names) == 0 {
+		for name := range tunables {
+			*names = append(*names, name)
+		}
+		sort.Strings(*names)
+	}
+	for _, name := range *names {
+		tn, ok := tunables[name]
+		if !ok {
+			return fmt.Errorf("unknown tunable %q", name)
+		}
+		value, err := tn.get(t)
+		if err != nil {
+			return fmt.Errorf("getting %q: %v", name, err)
+		}
+		*out = append(*out, Tunable{Name: name, Value: value, Description: tn.desc})
+	}
+	return nil
+}
+
+// Set changes the value of a tunable.
+func (t *Tunables) Set(args *SetTunableArgs, _ *struct{}) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tn, ok := tunables[args.Name]
+	if !ok {
+		return fmt.Errorf("unknown tunable %q", args.Name)
+	}
+	if err := tn.set(t, strings.TrimSpace(args.Value)); err != nil {
+		return fmt.Errorf("setting %q to %q: %v", args.Name, args.Value, err)
+	}
+	log.Infof("Tunable %q set to %q", args.Name, args.Value)
+	return nil
+}
+
+// parseTCPBufferSize parses TCP buffer sizes in the format of the
+// net.ipv4.tcp_rmem and net.ipv4.tcp_wmem sysctls, "min default max".
+func parseTCPBufferSize(value string) (inet.TCPBufferSize, error) {
+	fields := strings.Fields(value)
+	if len(fields) != 3 {
+		return inet.TCPBufferSize{}, fmt.Errorf(`want "min default max", got %q`, value)
+	}
+	var sizes [3]int
+	for i, f := range fields {
+		v, err := strconv.Atoi(f)
+		if err != nil || v <= 0 {
+			return inet.TCPBufferSize{}, fmt.Errorf("invalid size %q", f)
+		}
+		sizes[i] = v
+	}
+	if sizes[0] > sizes[1] || sizes[1] > sizes[2] {
+		return inet.TCPBufferSize{}, fmt.Errorf("sizes must satisfy min <= default <= max, got %q", value)
+	}
+	return inet.TCPBufferSize{Min: sizes[0], Default: sizes[1], Max: sizes[2]}, nil
+}
+
+// formatTCPBufferSize is the inverse of parseTCPBufferSize.
+func formatTCPBufferSize(size inet.TCPBufferSize) string {
+	return fmt.Sprintf("%d %d %d", size.Min, size.Default, size.Max)
+}
+
+func delayedEvictionToString(de pgalloc.DelayedEvictionType) string {
+	switch de {
+	case pgalloc.DelayedEvictionEnabled:
+		return "enabled"
+	case pgalloc.DelayedEvictionDisabled:
+		return "disabled"
+	case pgalloc.DelayedEvictionManual:
+		return "manual"
+	default:
+		return fmt.Sprintf("unknown(%d)", de)
+	}
+}
+
+func delayedEvictionFromString(value string) (pgalloc.DelayedEvictionType, error) {
+	switch value {
+	case "enabled":
+		return pgalloc.DelayedEvictionEnabled, nil
+	case "disabled":
+		return pgalloc.DelayedEvictionDisabled, nil
+	default:
+		return 0, fmt.Errorf("want enabled or disabled, got %q", value)
+	}
+}
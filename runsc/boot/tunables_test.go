@@ -0,0 +1,63 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boot
+
+import (
+	"testing"
+
+	"gvisor.dev/gvisor/pkg/sentry/inet"
+)
+
+func TestParseTCPBufferSize(t *testing.T) {
+	got, err := parseTCPBufferSize(" 4096\t87380 6291456 ")
+	if err != nil {
+		t.Fatalf("parseTCPBufferSize() failed: %v", err)
+	}
+	want := inet.TCPBufferSize{Min: 4096, Default: 87380, Max: 6291456}
+	if got != want {
+		t.Errorf("parseTCPBufferSize() = %+v, want %+v", got, want)
+	}
+	if s := formatTCPBufferSize(got); s != "4096 87380 6291456" {
+		t.Errorf("formatTCPBufferSize(%+v) = %q", got, s)
+	}
+
+	for _, value := range []string{
+		"",
+		"4096 87380",
+		"4096 87380 6291456 1",
+		"4096 foo 6291456",
+		"0 87380 6291456",
+		"4096 87380 1024",
+	} {
+		if _, err := parseTCPBufferSize(value); err == nil {
+			t.Errorf("parseTCPBufferSize(%q) succeeded", value)
+		}
+	}
+}
+
+func TestDelayedEvictionString(t *testing.T) {
+	for _, value := range []string{"enabled", "disabled"} {
+		de, err := delayedEvictionFromString(value)
+		if err != nil {
+			t.Fatalf("delayedEvictionFromString(%q) failed: %v", value, err)
+		}
+		if got := delayedEvictionToString(de); got != value {
+			t.Errorf("delayedEvictionToString(%v) = %q, want %q", de, got, value)
+		}
+	}
+	if _, err := delayedEvictionFromString("manual"); err == nil {
+		t.Errorf("delayedEvictionFromString(\"manual\") succeeded")
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boot
+
+import (
+	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/pkg/sentry/kernel"
+	"gvisor.dev/gvisor/runsc/config"
+)
+
+// setKernelVersion overrides the kernel release and version reported by
+// uname(2) and /proc/version with the ones set in conf. It must be called
+// before the kernel starts.
+func setKernelVersion(conf *config.Config) {
+	if conf.KernelRelease == "" && conf.KernelVersion == "" {
+		return
+	}
+	for _, table := range kernel.SyscallTables() {
+		if conf.KernelRelease != "" {
+			table.Version.Release = conf.KernelRelease
+		}
+		if conf.KernelVersion != "" {
+			table.Version.Version = conf.KernelVersion
+		}
+	}
+	log.Infof("Reporting kernel release %q, version %q", conf.KernelRelease, conf.KernelVersion)
+}
@@ -18,9 +18,11 @@ import (
 	"fmt"
 	"path"
 	"sort"
+	"strconv"
 	"strings"
 
 	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"golang.org/x/sys/unix"
 	"gvisor.dev/gvisor/pkg/abi/linux"
 	"gvisor.dev/gvisor/pkg/cleanup"
 	"gvisor.dev/gvisor/pkg/context"
@@ -213,7 +215,9 @@ func (c *containerMounter) mountAll(conf *config.Config, procArgs *kernel.Create
 // createMountNamespaceVFS2 creates the container's root mount and namespace.
 func (c *containerMounter) createMountNamespaceVFS2(ctx context.Context, conf *config.Config, creds *auth.Credentials) (*vfs.MountNamespace, error) {
 	fd := c.fds.remove()
-	data := goferMountData(fd, conf.FileAccess, "/", true /* vfs2 */, conf.Lisafs)
+	data := goferMountData(fd, conf.FileAccess, conf.FileCache, "/", true /* vfs2 */, conf.Lisafs)
+	data = append(data, goferChannelsMountData(c.fds.removeExtra())...)
+	data = append(data, sharedMountData(conf, conf.FileAccess)...)
 
 	// We can't check for overlayfs here because sandbox is chroot'ed and gofer
 	// can only send mount options for specs.Mounts (specs.Root is missing
@@ -226,7 +230,7 @@ func (c *containerMounter) createMountNamespaceVFS2(ctx context.Context, conf *c
 		GetFilesystemOptions: vfs.GetFilesystemOptions{
 			Data: strings.Join(data, ","),
 			InternalData: gofer.InternalFilesystemOptions{
-				UniqueID: "/",
+				UniqueID: goferUniqueID(c.fsID, "/"),
 			},
 		},
 		InternalMount: true,
@@ -237,7 +241,7 @@ func (c *containerMounter) createMountNamespaceVFS2(ctx context.Context, conf *c
 		log.Infof("Adding overlay on top of root")
 		var err error
 		var cleanup func()
-		opts, cleanup, err = c.configureOverlay(ctx, creds, opts, fsName)
+		opts, cleanup, err = c.configureOverlay(ctx, conf, creds, opts, fsName, c.overlayUpperOpts(conf))
 		if err != nil {
 			return nil, fmt.Errorf("mounting root with overlay: %w", err)
 		}
@@ -253,10 +257,11 @@ func (c *containerMounter) createMountNamespaceVFS2(ctx context.Context, conf *c
 }
 
 // configureOverlay mounts the lower layer using "lowerOpts", mounts the upper
-// layer using tmpfs, and return overlay mount options. "cleanup" must be called
-// after the options have been used to mount the overlay, to release refs on
-// lower and upper mounts.
-func (c *containerMounter) configureOverlay(ctx context.Context, creds *auth.Credentials, lowerOpts *vfs.MountOptions, lowerFSName string) (*vfs.MountOptions, func(), error) {
+// layer using tmpfs, limited by the overlay-size and overlay-inodes flags, or
+// using "hostUpperOpts" if it's backed by a host directory, and return overlay
+// mount options. "cleanup" must be called after the options have been used to
+// mount the overlay, to release refs on lower and upper mounts.
+func (c *containerMounter) configureOverlay(ctx context.Context, conf *config.Config, creds *auth.Credentials, lowerOpts *vfs.MountOptions, lowerFSName string, hostUpperOpts *vfs.GetFilesystemOptions) (*vfs.MountOptions, func(), error) {
 	// First copy options from lower layer to upper layer and overlay. Clear
 	// filesystem specific options.
 	upperOpts := *lowerOpts
@@ -293,11 +298,23 @@ func (c *containerMounter) configureOverlay(ctx context.Context, creds *auth.Cre
 		return nil, nil, fmt.Errorf("lower layer's root has unsupported file type %v", rootType)
 	}
 
-	// Upper is a tmpfs mount to keep all modifications inside the sandbox.
-	upperOpts.GetFilesystemOptions.InternalData = tmpfs.FilesystemOpts{
-		RootFileType: uint16(rootType),
+	upperFSName := tmpfs.Name
+	if hostUpperOpts != nil {
+		// Upper is a gofer mount of a host directory, so that modifications
+		// persist across restarts of the container.
+		if rootType != linux.S_IFDIR {
+			return nil, nil, fmt.Errorf("overlay upper directory can't back a lower layer of type %v", rootType)
+		}
+		upperOpts.GetFilesystemOptions = *hostUpperOpts
+		upperFSName = gofer.Name
+	} else {
+		// Upper is a tmpfs mount to keep all modifications inside the sandbox.
+		upperOpts.GetFilesystemOptions.Data = overlayQuotaData(conf)
+		upperOpts.GetFilesystemOptions.InternalData = tmpfs.FilesystemOpts{
+			RootFileType: uint16(rootType),
+		}
 	}
-	upper, err := c.k.VFS().MountDisconnected(ctx, creds, "" /* source */, tmpfs.Name, &upperOpts)
+	upper, err := c.k.VFS().MountDisconnected(ctx, creds, "" /* source */, upperFSName, &upperOpts)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to create upper layer for overlay, opts: %+v: %v", upperOpts, err)
 	}
@@ -358,6 +375,47 @@ func (c *containerMounter) configureOverlay(ctx context.Context, creds *auth.Cre
 	return &overlayOpts, cu.Release(), nil
 }
 
+// overlayUpperOpts returns the options to mount the host directory backing the
+// upper layer of the root overlay, or nil if it's backed by memory. The gofer
+// serves the directory after the gofer mounts of the container.
+func (c *containerMounter) overlayUpperOpts(conf *config.Config) *vfs.GetFilesystemOptions {
+	if conf.OverlayUpperDir == "" {
+		return nil
+	}
+	fd, extraFDs := c.fds.removeLast()
+	// Writes go through to the host, so that they persist and the cached
+	// pages don't count against the memory of the sandbox until evicted.
+	data := []string{
+		"trans=fd",
+		"rfdno=" + strconv.Itoa(fd),
+		"wfdno=" + strconv.Itoa(fd),
+		"cache=fscache_writethrough",
+		"overlay_xattrs",
+	}
+	data = append(data, goferChannelsMountData(extraFDs)...)
+	log.Infof("Mounting overlay upper directory %q over 9P, ioFD: %d", conf.OverlayUpperDir, fd)
+	return &vfs.GetFilesystemOptions{
+		Data: strings.Join(data, ","),
+		InternalData: gofer.InternalFilesystemOptions{
+			UniqueID: goferUniqueID(c.fsID, "overlay-upper"),
+		},
+	}
+}
+
+// overlayQuotaData returns the tmpfs mount options limiting the upper layer of
+// overlay mounts, as configured by the overlay-size and overlay-inodes flags.
+func overlayQuotaData(conf *config.Config) string {
+	var data []string
+	if conf.OverlaySize != "" {
+		data = append(data, "size="+conf.OverlaySize)
+	}
+	if conf.OverlayInodes != 0 {
+		// The upper layer's root takes an inode.
+		data = append(data, fmt.Sprintf("nr_inodes=%d", conf.OverlayInodes+1))
+	}
+	return strings.Join(data, ",")
+}
+
 func (c *containerMounter) mountSubmountsVFS2(ctx context.Context, conf *config.Config, mns *vfs.MountNamespace, creds *auth.Credentials) error {
 	mounts, err := c.prepareMountsVFS2()
 	if err != nil {
@@ -407,6 +465,40 @@ func (c *containerMounter) mountSubmountsVFS2(ctx context.Context, conf *config.
 type mountAndFD struct {
 	mount *specs.Mount
 	fd    int
+
+	// extraFDs are the FDs of the additional channels to the gofer, if any.
+	extraFDs []int
+}
+
+// goferChannelsMountData returns the gofer mount data for the additional
+// channels to the gofer.
+func goferChannelsMountData(extraFDs []int) []string {
+	if len(extraFDs) == 0 {
+		return nil
+	}
+	fds := make([]string, 0, len(extraFDs))
+	for _, fd := range extraFDs {
+		fds = append(fds, strconv.Itoa(fd))
+	}
+	return []string{"extra_fds=" + strings.Join(fds, ":")}
+}
+
+// sharedMountData returns the gofer mount options specific to shared mounts:
+// polling files watched with inotify, so that changes made outside of the
+// sandbox (e.g. Kubernetes ConfigMap and Secret updates) generate inotify
+// events, and the revalidation interval of the loose caching policy.
+func sharedMountData(conf *config.Config, fa config.FileAccessType) []string {
+	if fa != config.FileAccessShared {
+		return nil
+	}
+	var opts []string
+	if conf.WatchPollInterval > 0 {
+		opts = append(opts, "watch_poll_interval="+conf.WatchPollInterval.String())
+	}
+	if conf.FileCache == config.FileCacheLoose && conf.FileCacheTimeout > 0 {
+		opts = append(opts, "revalidate_interval="+conf.FileCacheTimeout.String())
+	}
+	return opts
 }
 
 func (c *containerMounter) prepareMountsVFS2() ([]mountAndFD, error) {
@@ -421,12 +513,15 @@ func (c *containerMounter) prepareMountsVFS2() ([]mountAndFD, error) {
 		// Only bind mounts use host FDs; see
 		// containerMounter.getMountNameAndOptionsVFS2.
 		fd := -1
+		var extraFDs []int
 		if m.Type == bind {
 			fd = c.fds.remove()
+			extraFDs = c.fds.removeExtra()
 		}
 		mounts = append(mounts, mountAndFD{
-			mount: m,
-			fd:    fd,
+			mount:    m,
+			fd:       fd,
+			extraFDs: extraFDs,
 		})
 	}
 	if err := c.checkDispenser(); err != nil {
@@ -458,7 +553,7 @@ func (c *containerMounter) mountSubmountVFS2(ctx context.Context, conf *config.C
 	if useOverlay {
 		log.Infof("Adding overlay on top of mount %q", submount.mount.Destination)
 		var cleanup func()
-		opts, cleanup, err = c.configureOverlay(ctx, creds, opts, fsName)
+		opts, cleanup, err = c.configureOverlay(ctx, conf, creds, opts, fsName, nil /* hostUpperOpts */)
 		if err != nil {
 			return nil, fmt.Errorf("mounting volume with overlay at %q: %w", submount.mount.Destination, err)
 		}
@@ -508,7 +603,7 @@ func (c *containerMounter) getMountNameAndOptionsVFS2(conf *config.Config, m *mo
 
 	case tmpfs.Name:
 		var err error
-		data, err = parseAndFilterOptions(m.mount.Options, tmpfsAllowedData...)
+		data, err = parseAndFilterOptions(m.mount.Options, tmpfsAllowedDataVFS2...)
 		if err != nil {
 			return "", nil, false, err
 		}
@@ -520,9 +615,12 @@ func (c *containerMounter) getMountNameAndOptionsVFS2(conf *config.Config, m *mo
 			// but unlikely to be correct in this context.
 			return "", nil, false, fmt.Errorf("9P mount requires a connection FD")
 		}
-		data = goferMountData(m.fd, c.getMountAccessType(conf, m.mount), m.mount.Destination, true /* vfs2 */, conf.Lisafs)
+		fa := c.getMountAccessType(conf, m.mount)
+		data = goferMountData(m.fd, fa, conf.FileCache, m.mount.Destination, true /* vfs2 */, conf.Lisafs)
+		data = append(data, goferChannelsMountData(m.extraFDs)...)
+		data = append(data, sharedMountData(conf, fa)...)
 		internalData = gofer.InternalFilesystemOptions{
-			UniqueID: m.mount.Destination,
+			UniqueID: goferUniqueID(c.fsID, m.mount.Destination),
 		}
 
 		// If configured, add overlay to all writable mounts.
@@ -745,7 +843,7 @@ func (c *containerMounter) mountSharedMasterVFS2(ctx context.Context, conf *conf
 	if useOverlay {
 		log.Infof("Adding overlay on top of shared mount %q", mntFD.mount.Destination)
 		var cleanup func()
-		opts, cleanup, err = c.configureOverlay(ctx, creds, opts, fsName)
+		opts, cleanup, err = c.configureOverlay(ctx, conf, creds, opts, fsName, nil /* hostUpperOpts */)
 		if err != nil {
 			return nil, fmt.Errorf("mounting shared volume with overlay at %q: %w", mntFD.mount.Destination, err)
 		}
@@ -813,20 +911,55 @@ func (c *containerMounter) makeMountPoint(ctx context.Context, creds *auth.Crede
 	return c.k.VFS().MakeSyntheticMountpoint(ctx, dest, root, creds)
 }
 
+// closeFDs closes all FDs in fds.
+func closeFDs(fds []int) {
+	for _, fd := range fds {
+		_ = unix.Close(fd)
+	}
+}
+
+// goferUniqueID returns the unique ID of the gofer filesystem mounted at dest
+// in a container, given the prefix of the unique IDs of its filesystems. The
+// root container has no prefix.
+func goferUniqueID(fsID, dest string) string {
+	if fsID == "" {
+		return dest
+	}
+	return fsID + ":" + dest
+}
+
 // configureRestore returns an updated context.Context including filesystem
-// state used by restore defined by conf.
-func (c *containerMounter) configureRestore(ctx context.Context) (context.Context, error) {
+// state used by restore defined by conf. subcontainers are restored along
+// with the root container.
+func (c *containerMounter) configureRestore(ctx context.Context, subcontainers []*containerMounter) (context.Context, error) {
 	fdmap := make(map[string]int)
-	fdmap["/"] = c.fds.remove()
+	if err := c.addRestoreFDs(fdmap); err != nil {
+		return ctx, err
+	}
+	for _, sc := range subcontainers {
+		if err := sc.addRestoreFDs(fdmap); err != nil {
+			return ctx, err
+		}
+	}
+	return context.WithValue(ctx, gofer.CtxRestoreServerFDMap, fdmap), nil
+}
+
+// addRestoreFDs adds the FDs connected to the gofers of the container's
+// filesystems to fdmap, keyed by unique ID.
+func (c *containerMounter) addRestoreFDs(fdmap map[string]int) error {
+	fdmap[goferUniqueID(c.fsID, "/")] = c.fds.remove()
+	// Restored filesystems use a single channel to the gofer.
+	closeFDs(c.fds.removeExtra())
 	mounts, err := c.prepareMountsVFS2()
 	if err != nil {
-		return ctx, err
+		return err
 	}
 	for i := range c.mounts {
 		submount := &mounts[i]
 		if submount.fd >= 0 {
-			fdmap[submount.mount.Destination] = submount.fd
+			fdmap[goferUniqueID(c.fsID, submount.mount.Destination)] = submount.fd
 		}
+		closeFDs(submount.extraFDs)
 	}
-	return context.WithValue(ctx, gofer.CtxRestoreServerFDMap, fdmap), nil
+	return nil
 }
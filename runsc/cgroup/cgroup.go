@@ -290,6 +290,7 @@ func loadPathsHelper(cgroup, mountinfo io.Reader) (map[string]string, error) {
 // Cgroup represents a cgroup configuration.
 type Cgroup interface {
 	Install(res *specs.LinuxResources) error
+	Update(res *specs.LinuxResources) error
 	Uninstall() error
 	Join() (func(), error)
 	CPUQuota() (float64, error)
@@ -432,6 +433,64 @@ func (c *cgroupV1) Install(res *specs.LinuxResources) error {
 	return nil
 }
 
+// Update changes the CPU, memory and pids limits of the cgroup to the ones set
+// in res, leaving the others unchanged. Unlike Install, it also changes the
+// controllers that aren't owned by the cgroup. Missing controllers are
+// skipped.
+func (c *cgroupV1) Update(res *specs.LinuxResources) error {
+	log.Debugf("Updating cgroup path %q", c.Name)
+	for _, key := range []string{"cpu", "cpuset", "memory", "pids"} {
+		path := c.MakePath(key)
+		if _, err := os.Stat(path); err != nil {
+			if os.IsNotExist(err) {
+				log.Infof("Skipping update of missing cgroup %q: %q", key, path)
+				continue
+			}
+			return err
+		}
+		if err := updateController(key, res, path); err != nil {
+			return fmt.Errorf("updating cgroup %q: %v", key, err)
+		}
+	}
+	return nil
+}
+
+// updateController applies the limits set in res to the given controller.
+func updateController(key string, res *specs.LinuxResources, path string) error {
+	switch key {
+	case "cpuset":
+		// Unlike cpuSet.set, leave cpus and mems unchanged if they aren't set.
+		if res == nil || res.CPU == nil {
+			return nil
+		}
+		if res.CPU.Cpus != "" {
+			if err := setValue(path, "cpuset.cpus", res.CPU.Cpus); err != nil {
+				return err
+			}
+		}
+		if res.CPU.Mems != "" {
+			return setValue(path, "cpuset.mems", res.CPU.Mems)
+		}
+		return nil
+	case "memory":
+		// The memory limit can't be above the memory+swap limit, so raise the
+		// latter first.
+		if res != nil && res.Memory != nil && res.Memory.Swap != nil {
+			val, err := getValue(path, "memory.memsw.limit_in_bytes")
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			cur, err := strconv.ParseInt(strings.TrimSpace(val), 10, 64)
+			if err == nil && (*res.Memory.Swap == -1 || *res.Memory.Swap > cur) {
+				if err := setValue(path, "memory.memsw.limit_in_bytes", strconv.FormatInt(*res.Memory.Swap, 10)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return controllers[key].set(res, path)
+}
+
 // createController creates the controller directory, checking that the
 // controller is enabled in the system. It returns a boolean indicating whether
 // the controller should be skipped (e.g. controller is disabled). In case it
@@ -452,6 +452,65 @@ func TestCPUSetAncestor(t *testing.T) {
 	}
 }
 
+func TestUpdateCPUSet(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		spec  *specs.LinuxCPU
+		wants map[string]string
+	}{
+		{
+			name: "all",
+			spec: &specs.LinuxCPU{
+				Cpus: "1",
+				Mems: "1",
+			},
+			wants: map[string]string{
+				"cpuset.cpus": "1",
+				"cpuset.mems": "1",
+			},
+		},
+		{
+			// Unlike Install, cpus and mems aren't reset if unset.
+			name: "cpus",
+			spec: &specs.LinuxCPU{
+				Cpus: "1",
+			},
+			wants: map[string]string{
+				"cpuset.cpus": "1",
+				"cpuset.mems": "0",
+			},
+		},
+		{
+			name: "nil",
+			wants: map[string]string{
+				"cpuset.cpus": "0-3",
+				"cpuset.mems": "0",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir(testutil.TmpDir(), "cgroup")
+			if err != nil {
+				t.Fatalf("error creating temporary directory: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			for name, val := range map[string]string{"cpuset.cpus": "0-3", "cpuset.mems": "0"} {
+				if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(val), 0600); err != nil {
+					t.Fatalf("WriteFile(): %v", err)
+				}
+			}
+
+			spec := &specs.LinuxResources{
+				CPU: tc.spec,
+			}
+			if err := updateController("cpuset", spec, dir); err != nil {
+				t.Fatalf("updateController(): %v", err)
+			}
+			checkDir(t, dir, tc.wants)
+		})
+	}
+}
+
 func TestHugeTlb(t *testing.T) {
 	for _, tc := range []struct {
 		name  string
@@ -34,8 +34,10 @@ import (
 	"gvisor.dev/gvisor/pkg/sentry/platform"
 	"gvisor.dev/gvisor/runsc/cmd"
 	"gvisor.dev/gvisor/runsc/config"
+	"gvisor.dev/gvisor/runsc/container"
 	"gvisor.dev/gvisor/runsc/flag"
 	"gvisor.dev/gvisor/runsc/specutils"
+	"gvisor.dev/gvisor/runsc/tracing"
 )
 
 var (
@@ -65,28 +67,39 @@ func Main(version string) {
 
 	// Installation helpers.
 	const helperGroup = "helpers"
+	subcommands.Register(new(cmd.Bridge), helperGroup)
 	subcommands.Register(new(cmd.Install), helperGroup)
 	subcommands.Register(new(cmd.Uninstall), helperGroup)
 
 	// Register user-facing runsc commands.
+	subcommands.Register(new(cmd.Attach), "")
 	subcommands.Register(new(cmd.Checkpoint), "")
+	subcommands.Register(new(cmd.ControlAPI), "")
 	subcommands.Register(new(cmd.Create), "")
 	subcommands.Register(new(cmd.Delete), "")
 	subcommands.Register(new(cmd.Do), "")
 	subcommands.Register(new(cmd.Events), "")
 	subcommands.Register(new(cmd.Exec), "")
+	subcommands.Register(new(cmd.GC), "")
 	subcommands.Register(new(cmd.Gofer), "")
 	subcommands.Register(new(cmd.Kill), "")
 	subcommands.Register(new(cmd.List), "")
+	subcommands.Register(new(cmd.Migrate), "")
 	subcommands.Register(new(cmd.Pause), "")
 	subcommands.Register(new(cmd.PS), "")
+	subcommands.Register(new(cmd.Resize), "")
 	subcommands.Register(new(cmd.Restore), "")
 	subcommands.Register(new(cmd.Resume), "")
 	subcommands.Register(new(cmd.Run), "")
+	subcommands.Register(new(cmd.Snapshot), "")
 	subcommands.Register(new(cmd.Spec), "")
+	subcommands.Register(new(cmd.Standby), "")
 	subcommands.Register(new(cmd.State), "")
 	subcommands.Register(new(cmd.Start), "")
+	subcommands.Register(new(cmd.StateTool), "")
 	subcommands.Register(new(cmd.Symbolize), "")
+	subcommands.Register(new(cmd.Tune), "")
+	subcommands.Register(new(cmd.Update), "")
 	subcommands.Register(new(cmd.Wait), "")
 	subcommands.Register(new(cmd.Mitigate), "")
 	subcommands.Register(new(cmd.VerityPrepare), "")
@@ -175,7 +188,7 @@ func Main(version string) {
 		e = newEmitter(conf.DebugLogFormat, f)
 
 	} else if conf.DebugLog != "" {
-		f, err := specutils.DebugLogFile(conf.DebugLog, subcommand, "" /* name */)
+		f, err := specutils.DebugLogFile(conf.DebugLog, subcommand, containerID(subcommand), "" /* name */)
 		if err != nil {
 			cmd.Fatalf("error opening debug log file in %q: %v", conf.DebugLog, err)
 		}
@@ -239,12 +252,15 @@ func Main(version string) {
 		signal.Ignore(unix.SIGTERM)
 	}
 
+	span := startTrace(conf, subcommand)
+
 	// Call the subcommand and pass in the configuration.
 	var ws unix.WaitStatus
 	subcmdCode := subcommands.Execute(context.Background(), conf, &ws)
 	// Check for leaks and write coverage report before os.Exit().
 	refsvfs2.DoLeakCheck()
 	_ = coverage.Report()
+	endTrace(conf, span, subcmdCode)
 	if subcmdCode == subcommands.ExitSuccess {
 		log.Infof("Exiting with status: %v", ws)
 		if ws.Signaled() {
@@ -259,6 +275,114 @@ func Main(version string) {
 	os.Exit(128)
 }
 
+// containerCommands are the subcommands that take a container ID as their
+// first argument.
+var containerCommands = map[string]struct{}{
+	"attach":     {},
+	"boot":       {},
+	"checkpoint": {},
+	"create":     {},
+	"debug":      {},
+	"delete":     {},
+	"events":     {},
+	"exec":       {},
+	"kill":       {},
+	"migrate":    {},
+	"pause":      {},
+	"ps":         {},
+	"resize":     {},
+	"restore":    {},
+	"resume":     {},
+	"run":        {},
+	"snapshot":   {},
+	"standby":    {},
+	"start":      {},
+	"state":      {},
+	"tune":       {},
+	"update":     {},
+	"wait":       {},
+}
+
+// containerID returns the ID of the container that subcommand operates on, or
+// "" if there is none or it's invalid. It's used to substitute %ID% in the
+// debug log path before the subcommand runs, so an invalid ID must not be
+// returned as it could point the log outside of the log directory.
+func containerID(subcommand string) string {
+	if _, ok := containerCommands[subcommand]; !ok {
+		return ""
+	}
+	var id string
+	subcommands.DefaultCommander.VisitCommands(func(_ *subcommands.CommandGroup, c subcommands.Command) {
+		if id != "" || c.Name() != subcommand {
+			return
+		}
+		// Parse the arguments the same way the subcommand will, to skip
+		// its flags. Errors are reported when the subcommand runs.
+		f := flag.NewFlagSet(subcommand, flag.ContinueOnError)
+		f.SetOutput(ioutil.Discard)
+		c.SetFlags(f)
+		if err := f.Parse(flag.CommandLine.Args()[1:]); err != nil {
+			return
+		}
+		if arg := f.Arg(0); container.ValidateID(arg) == nil {
+			id = arg
+		}
+	})
+	return id
+}
+
+// tracedCommands are the subcommands that record spans when
+// --otlp-endpoint is set.
+var tracedCommands = map[string]struct{}{
+	"checkpoint": {},
+	"create":     {},
+	"delete":     {},
+	"exec":       {},
+	"migrate":    {},
+	"restore":    {},
+	"run":        {},
+	"start":      {},
+}
+
+// startTrace starts the span of subcommand, child of the span in the
+// TRACEPARENT environment variable if any. It returns nil if subcommand isn't
+// traced.
+func startTrace(conf *config.Config, subcommand string) *tracing.ActiveSpan {
+	if conf.OTLPEndpoint == "" {
+		return nil
+	}
+	if _, ok := tracedCommands[subcommand]; !ok {
+		return nil
+	}
+	parent, err := tracing.ParseTraceParent(os.Getenv(tracing.TraceParentEnv))
+	if err != nil {
+		log.Warningf("Ignoring %s: %v", tracing.TraceParentEnv, err)
+	}
+	tracing.Enable()
+	span := tracing.Start(parent, "runsc "+subcommand)
+	if id := containerID(subcommand); id != "" {
+		span.SetAttribute("container.id", id)
+	}
+	tracing.SetCurrent(span)
+	return span
+}
+
+// endTrace ends span, started by startTrace, and exports all spans recorded
+// while the subcommand ran.
+func endTrace(conf *config.Config, span *tracing.ActiveSpan, code subcommands.ExitStatus) {
+	if span == nil {
+		return
+	}
+	var err error
+	if code != subcommands.ExitSuccess {
+		err = fmt.Errorf("command failed with status %d", code)
+	}
+	span.End(err)
+	if err := tracing.Export(conf.OTLPEndpoint, "runsc", tracing.Collect()); err != nil {
+		log.Warningf("Error exporting traces: %v", err)
+	}
+}
+
 func newEmitter(format string, logFile io.Writer) log.Emitter {
 	switch format {
 	case "text":
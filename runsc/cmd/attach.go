@@ -0,0 +1,168 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+
+	"github.com/google/subcommands"
+	"golang.org/x/sys/unix"
+	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/runsc/config"
+	"gvisor.dev/gvisor/runsc/container"
+	"gvisor.dev/gvisor/runsc/flag"
+)
+
+// Attach implements subcommands.Command for the "attach" command.
+type Attach struct {
+	noStdin  bool
+	sigProxy bool
+}
+
+// Name implements subcommands.Command.Name.
+func (*Attach) Name() string {
+	return "attach"
+}
+
+// Synopsis implements subcommands.Command.Synopsis.
+func (*Attach) Synopsis() string {
+	return "attach to the stdio of a running container"
+}
+
+// Usage implements subcommands.Command.Usage.
+func (*Attach) Usage() string {
+	return `attach [flags] <container id> - attach local stdin, stdout and stderr to a running container.
+
+The container's output is copied to stdout and stderr until the container
+exits. Unless --no-stdin is set, local stdin is forwarded to the container's
+stdin and the session detaches when local stdin reaches EOF.
+`
+}
+
+// SetFlags implements subcommands.Command.SetFlags.
+func (a *Attach) SetFlags(f *flag.FlagSet) {
+	f.BoolVar(&a.noStdin, "no-stdin", false, "attach to the container's output only, without forwarding stdin")
+	f.BoolVar(&a.sigProxy, "sig-proxy", true, "forward received signals to the container. If false, SIGINT and SIGTERM detach from the container")
+}
+
+// Execute implements subcommands.Command.Execute.
+func (a *Attach) Execute(_ context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 1 {
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+
+	id := f.Arg(0)
+	conf := args[0].(*config.Config)
+	waitStatus := args[1].(*unix.WaitStatus)
+
+	c, err := container.Load(conf.RootDir, container.FullID{ContainerID: id}, container.LoadOpts{})
+	if err != nil {
+		Fatalf("loading container: %v", err)
+	}
+
+	// The sandbox reads the container's input from stdinR and writes a copy
+	// of its output to stdoutW and stderrW. Closing stdinW ends the session.
+	stdinR, stdinW, err := os.Pipe()
+	if err != nil {
+		Fatalf("creating stdin pipe: %v", err)
+	}
+	stdoutR, stdoutW, err := os.Pipe()
+	if err != nil {
+		Fatalf("creating stdout pipe: %v", err)
+	}
+	stderrR, stderrW, err := os.Pipe()
+	if err != nil {
+		Fatalf("creating stderr pipe: %v", err)
+	}
+	err = c.Attach(a.noStdin, stdinR, stdoutW, stderrW)
+	// The sandbox has its own copies of the donated files now.
+	stdinR.Close()
+	stdoutW.Close()
+	stderrW.Close()
+	if err != nil {
+		Fatalf("attaching to container: %v", err)
+	}
+
+	var detachOnce sync.Once
+	detached := make(chan struct{})
+	detach := func() {
+		detachOnce.Do(func() {
+			stdinW.Close()
+			close(detached)
+		})
+	}
+	defer detach()
+
+	var wg sync.WaitGroup
+	for _, p := range []struct {
+		dst *os.File
+		src *os.File
+	}{
+		{dst: os.Stdout, src: stdoutR},
+		{dst: os.Stderr, src: stderrR},
+	} {
+		p := p
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// The sandbox closes its end of the pipe when the session ends.
+			_, _ = io.Copy(p.dst, p.src)
+		}()
+	}
+
+	done := make(chan unix.WaitStatus, 1)
+	go func() {
+		ws, err := c.Wait()
+		if err != nil {
+			Fatalf("waiting for container: %v", err)
+		}
+		done <- ws
+	}()
+
+	if !a.noStdin {
+		go func() {
+			if _, err := io.Copy(stdinW, os.Stdin); err != nil {
+				log.Warningf("Copying stdin to container %q: %v", id, err)
+			}
+			detach()
+		}()
+	}
+	if a.sigProxy {
+		stopForwarding := c.ForwardSignals(0, false)
+		defer stopForwarding()
+	} else {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, unix.SIGINT, unix.SIGTERM)
+		defer signal.Stop(sigs)
+		go func() {
+			<-sigs
+			detach()
+		}()
+	}
+
+	select {
+	case ws := <-done:
+		*waitStatus = ws
+	case <-detached:
+	}
+	detach()
+	wg.Wait()
+	return subcommands.ExitSuccess
+}
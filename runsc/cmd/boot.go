@@ -16,6 +16,8 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"os"
 	"runtime/debug"
 	"strings"
@@ -23,12 +25,14 @@ import (
 	"github.com/google/subcommands"
 	specs "github.com/opencontainers/runtime-spec/specs-go"
 	"golang.org/x/sys/unix"
+	"gvisor.dev/gvisor/pkg/control/server"
 	"gvisor.dev/gvisor/pkg/log"
 	"gvisor.dev/gvisor/pkg/sentry/platform"
 	"gvisor.dev/gvisor/runsc/boot"
 	"gvisor.dev/gvisor/runsc/config"
 	"gvisor.dev/gvisor/runsc/flag"
 	"gvisor.dev/gvisor/runsc/specutils"
+	"gvisor.dev/gvisor/runsc/tracing"
 )
 
 // Boot implements subcommands.Command for the "boot" command which starts a
@@ -99,6 +103,40 @@ type Boot struct {
 	// Valid if >= 0.
 	traceFD int
 
+	// logForwardFD is the file descriptor of a socket connected to the
+	// host's logging daemon. Valid if >= 0.
+	logForwardFD int
+
+	// vsockDirFD is the file descriptor of the directory containing UNIX
+	// sockets that back vsock ports. Valid if >= 0.
+	vsockDirFD int
+
+	// replayLogFD is the file descriptor of the log that nondeterministic
+	// inputs are recorded to, or replayed from. Valid if >= 0.
+	replayLogFD int
+
+	// timezone is the POSIX TZ string to set in the environment of
+	// containers.
+	timezone string
+
+	// traceParent is the W3C traceparent of the runsc span creating the
+	// sandbox, used as the parent of the span of its creation.
+	traceParent string
+
+	// logRingFD is the file descriptor of the file mapped to keep the most
+	// recent log records, for crash reports. If not set, they are kept in
+	// memory.
+	logRingFD int
+
+	// entropyFD is the file descriptor of the host device that /dev/random
+	// and /dev/urandom read from. Valid if >= 0.
+	entropyFD int
+
+	// controlCredsFD is the file descriptor of the file with the tokens that
+	// authenticate control clients, when the control server has a policy.
+	// Valid if >= 0.
+	controlCredsFD int
+
 	// pidns is set if the sandbox is in its own pid namespace.
 	pidns bool
 
@@ -144,6 +182,14 @@ func (b *Boot) SetFlags(f *flag.FlagSet) {
 	f.IntVar(&b.profileHeapFD, "profile-heap-fd", -1, "file descriptor to write heap profile to. -1 disables profiling.")
 	f.IntVar(&b.profileMutexFD, "profile-mutex-fd", -1, "file descriptor to write mutex profile to. -1 disables profiling.")
 	f.IntVar(&b.traceFD, "trace-fd", -1, "file descriptor to write Go execution trace to. -1 disables tracing.")
+	f.IntVar(&b.logForwardFD, "log-forward-fd", -1, "file descriptor of a socket connected to the host's logging daemon. -1 disables log forwarding.")
+	f.IntVar(&b.vsockDirFD, "vsock-dir-fd", -1, "file descriptor of the directory with UNIX sockets that back vsock ports.")
+	f.IntVar(&b.replayLogFD, "replay-log-fd", -1, "file descriptor of the log to record nondeterministic inputs to, or replay them from.")
+	f.StringVar(&b.timezone, "timezone", "", "POSIX TZ string to set in the environment of containers that don't set TZ.")
+	f.IntVar(&b.logRingFD, "log-ring-fd", -1, "file descriptor of the file mapped to keep the most recent log records, for crash reports.")
+	f.IntVar(&b.entropyFD, "entropy-fd", -1, "file descriptor of the host device that /dev/random and /dev/urandom read from.")
+	f.IntVar(&b.controlCredsFD, "control-creds-fd", -1, "file descriptor of the file with the tokens that authenticate control clients.")
+	f.StringVar(&b.traceParent, "trace-parent", "", "W3C traceparent of the span creating the sandbox, if tracing is enabled.")
 	f.BoolVar(&b.attached, "attached", false, "if attached is true, kills the sandbox process when the parent process terminates")
 }
 
@@ -226,15 +272,52 @@ func (b *Boot) Execute(_ context.Context, f *flag.FlagSet, args ...interface{})
 		panic("unreachable")
 	}
 
-	// Read resolved mount list and replace the original one from the spec.
-	mountsFile := os.NewFile(uintptr(b.mountsFD), "mounts file")
-	cleanMounts, err := specutils.ReadMounts(mountsFile)
+	logRing, err := setUpLogRing(b.logRingFD)
 	if err != nil {
+		Fatalf("setting up log ring: %v", err)
+	}
+
+	if conf.OTLPEndpoint != "" {
+		// Spans are collected by runsc, which exports them.
+		tracing.Enable()
+	}
+	parent, err := tracing.ParseTraceParent(b.traceParent)
+	if err != nil {
+		log.Warningf("Ignoring trace parent: %v", err)
+	}
+	span := tracing.Start(parent, "sandbox boot")
+	span.SetAttribute("container.id", f.Arg(0))
+
+	if conf.TestOnlyInProcessGofer {
+		// There is no gofer process: serve the mounts from this process.
+		log.Warningf("Serving mounts from the sandbox process. This is only safe for development!")
+		ioFDs, err := serveInProcess(spec, conf, b.bundleDir, f.Arg(0))
+		if err != nil {
+			Fatalf("Error serving mounts: %v", err)
+		}
+		b.ioFDs = ioFDs
+	} else {
+		// Read resolved mount list and replace the original one from the spec.
+		mountsFile := os.NewFile(uintptr(b.mountsFD), "mounts file")
+		cleanMounts, err := specutils.ReadMounts(mountsFile)
+		if err != nil {
+			mountsFile.Close()
+			Fatalf("Error reading mounts file: %v", err)
+		}
 		mountsFile.Close()
-		Fatalf("Error reading mounts file: %v", err)
+		spec.Mounts = cleanMounts
+	}
+
+	var controlCreds *server.Credentials
+	if b.controlCredsFD >= 0 {
+		credsFile := os.NewFile(uintptr(b.controlCredsFD), "control credentials file")
+		controlCreds = &server.Credentials{}
+		err := json.NewDecoder(credsFile).Decode(controlCreds)
+		credsFile.Close()
+		if err != nil {
+			Fatalf("Error reading control credentials: %v", err)
+		}
 	}
-	mountsFile.Close()
-	spec.Mounts = cleanMounts
 
 	// Create the loader.
 	bootArgs := boot.Args{
@@ -253,8 +336,16 @@ func (b *Boot) Execute(_ context.Context, f *flag.FlagSet, args ...interface{})
 		ProfileHeapFD:  b.profileHeapFD,
 		ProfileMutexFD: b.profileMutexFD,
 		TraceFD:        b.traceFD,
+		LogForwardFD:   b.logForwardFD,
+		VsockDirFD:     b.vsockDirFD,
+		ReplayLogFD:    b.replayLogFD,
+		Timezone:       b.timezone,
+		LogRing:        logRing,
+		EntropyFD:      b.entropyFD,
+		ControlCreds:   controlCreds,
 	}
 	l, err := boot.New(bootArgs)
+	span.End(err)
 	if err != nil {
 		Fatalf("creating loader: %v", err)
 	}
@@ -308,3 +399,30 @@ func prepareArgs(attached bool, exclude ...string) []string {
 	}
 	return args
 }
+
+// logRingSize is the size of the ring of the most recent log records, when
+// it's kept in memory.
+const logRingSize = 256 << 10
+
+// setUpLogRing keeps the most recent log records, even if logging to a file is
+// disabled. If fd is valid, they are kept in the file fd, mapped shared such
+// that they outlive this process if it crashes. Otherwise they are kept in
+// memory.
+func setUpLogRing(fd int) (*log.Ring, error) {
+	buf := make([]byte, logRingSize)
+	if fd >= 0 {
+		f := os.NewFile(uintptr(fd), "log ring file")
+		defer f.Close()
+		st, err := f.Stat()
+		if err != nil {
+			return nil, err
+		}
+		buf, err = unix.Mmap(fd, 0, int(st.Size()), unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+		if err != nil {
+			return nil, fmt.Errorf("mapping log ring: %v", err)
+		}
+	}
+	ring := log.NewRing(buf)
+	log.SetTarget(&log.MultiEmitter{log.Log().Emitter, log.GoogleEmitter{Writer: &log.Writer{Next: ring}}})
+	return ring, nil
+}
@@ -0,0 +1,86 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"net"
+	"os"
+
+	"github.com/google/subcommands"
+	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/pkg/tcpip/link/sharedmem/bridge"
+	"gvisor.dev/gvisor/runsc/flag"
+)
+
+// Bridge implements subcommands.Command for the "bridge" command.
+type Bridge struct {
+	mtu        uint
+	bufferSize uint
+}
+
+// Name implements subcommands.Command.Name.
+func (*Bridge) Name() string {
+	return "bridge"
+}
+
+// Synopsis implements subcommands.Command.Synopsis.
+func (*Bridge) Synopsis() string {
+	return "runs a shared memory bridge connecting sandboxes"
+}
+
+// Usage implements subcommands.Command.Usage.
+func (*Bridge) Usage() string {
+	return `bridge [flags] <socket path>
+
+Runs a bridge that connects sandboxes started with
+--shared-mem-bridge=<socket path>,<address>/<prefix length>. Packets between
+them are exchanged over shared memory, without going through the host network
+stack.
+`
+}
+
+// SetFlags implements subcommands.Command.SetFlags.
+func (b *Bridge) SetFlags(f *flag.FlagSet) {
+	f.UintVar(&b.mtu, "mtu", 1500, "MTU of the bridge ports.")
+	f.UintVar(&b.bufferSize, "buffer-size", 1500, "size of the buffers of the shared memory queues.")
+}
+
+// Execute implements subcommands.Command.Execute.
+func (b *Bridge) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 1 {
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+	path := f.Arg(0)
+
+	// Remove the socket of a previous bridge.
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return Errorf("removing %q: %v", path, err)
+	}
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		return Errorf("listening on %q: %v", path, err)
+	}
+	defer l.Close()
+
+	br := bridge.New()
+	defer br.Close()
+	log.Infof("Serving shared memory bridge on %q", path)
+	if err := br.Serve(l, bridge.PortOptions{MTU: uint32(b.mtu), BufferSize: uint32(b.bufferSize)}); err != nil {
+		return Errorf("serving bridge: %v", err)
+	}
+	return subcommands.ExitSuccess
+}
@@ -16,25 +16,47 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 
 	"github.com/google/subcommands"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
 	"golang.org/x/sys/unix"
 	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/pkg/state/statefile"
 	"gvisor.dev/gvisor/runsc/config"
 	"gvisor.dev/gvisor/runsc/container"
 	"gvisor.dev/gvisor/runsc/flag"
+	"gvisor.dev/gvisor/runsc/sandbox"
 	"gvisor.dev/gvisor/runsc/specutils"
 )
 
 // File containing the container's saved image/state within the given image-path's directory.
 const checkpointFileName = "checkpoint.img"
 
+// File listing the containers saved in the image, within the given
+// image-path's directory.
+const checkpointManifestFileName = "containers.json"
+
+// checkpointedContainer is a container saved in an image. Checkpointing any
+// container of a sandbox saves all of its containers.
+type checkpointedContainer struct {
+	ID        string `json:"id"`
+	BundleDir string `json:"bundleDir"`
+	Root      bool   `json:"root,omitempty"`
+}
+
 // Checkpoint implements subcommands.Command for the "checkpoint" command.
 type Checkpoint struct {
 	imagePath    string
 	leaveRunning bool
+	exclude      stringSlice
+	lazyPages    bool
+	compression  string
+	dedup        bool
 }
 
 // Name implements subcommands.Command.Name.
@@ -57,6 +79,10 @@ func (*Checkpoint) Usage() string {
 func (c *Checkpoint) SetFlags(f *flag.FlagSet) {
 	f.StringVar(&c.imagePath, "image-path", "", "directory path to saved container image")
 	f.BoolVar(&c.leaveRunning, "leave-running", false, "restart the container after checkpointing")
+	f.Var(&c.exclude, "exclude", "directory on tmpfs whose contents are not saved, and are empty once restored. May be repeated.")
+	f.BoolVar(&c.lazyPages, "lazy-pages", false, "save memory pages to a separate file, from which they are loaded on demand when restoring")
+	f.StringVar(&c.compression, "compression", string(statefile.CompressionFlate), "compression algorithm of the image: flate or zstd")
+	f.BoolVar(&c.dedup, "dedup", false, "save identical memory pages once, e.g. zero pages and pages of shared libraries")
 
 	// Unimplemented flags necessary for compatibility with docker.
 	var wp string
@@ -79,10 +105,22 @@ func (c *Checkpoint) Execute(_ context.Context, f *flag.FlagSet, args ...interfa
 	if err != nil {
 		Fatalf("loading container: %v", err)
 	}
+	conts, err := sandboxContainers(conf, cont)
+	if err != nil {
+		Fatalf("loading containers of sandbox: %v", err)
+	}
 
 	if c.imagePath == "" {
 		Fatalf("image-path flag must be provided")
 	}
+	switch statefile.Compression(c.compression) {
+	case statefile.CompressionFlate, statefile.CompressionZstd:
+	default:
+		Fatalf("invalid compression %q, must be flate or zstd", c.compression)
+	}
+	if c.dedup && c.lazyPages {
+		Fatalf("dedup and lazy-pages flags can't be used together")
+	}
 
 	if err := os.MkdirAll(c.imagePath, 0755); err != nil {
 		Fatalf("making directories at path provided: %v", err)
@@ -97,62 +135,176 @@ func (c *Checkpoint) Execute(_ context.Context, f *flag.FlagSet, args ...interfa
 	}
 	defer file.Close()
 
-	if err := cont.Checkpoint(file); err != nil {
+	opts := sandbox.CheckpointOpts{
+		Exclude:     c.exclude,
+		Compression: c.compression,
+		Dedup:       c.dedup,
+	}
+	if c.lazyPages {
+		pagesPath := filepath.Join(c.imagePath, sandbox.PagesFileName)
+		pagesFile, err := os.OpenFile(pagesPath, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0644)
+		if err != nil {
+			Fatalf("os.OpenFile(%q) failed: %v", pagesPath, err)
+		}
+		defer pagesFile.Close()
+		opts.PagesFile = pagesFile
+	}
+
+	if err := cont.Checkpoint(file, opts); err != nil {
 		Fatalf("checkpoint failed: %v", err)
 	}
+	if err := writeCheckpointManifest(c.imagePath, conts); err != nil {
+		Fatalf("writing %q: %v", checkpointManifestFileName, err)
+	}
 
 	if !c.leaveRunning {
 		return subcommands.ExitSuccess
 	}
 
 	// TODO(b/110843694): Make it possible to restore into same container.
-	// For now, we can fake it by destroying the container and making a
-	// new container with the same ID. This hack does not work with docker
+	// For now, we can fake it by destroying the containers and making new
+	// containers with the same IDs. This hack does not work with docker
 	// which uses the container pid to ensure that the restore-container is
 	// actually the same as the checkpoint-container. By restoring into
 	// the same container, we will solve the docker incompatibility.
-
-	// Restore into new container with same ID.
-	bundleDir := cont.BundleDir
-	if bundleDir == "" {
-		Fatalf("setting bundleDir")
+	restored, err := restoreSandbox(conf, conts, fullImagePath)
+	for _, r := range restored {
+		defer r.Destroy()
 	}
-
-	spec, err := specutils.ReadSpec(bundleDir, conf)
 	if err != nil {
-		Fatalf("reading spec: %v", err)
+		Fatalf("%v", err)
 	}
-
-	specutils.LogSpec(spec)
-
-	if cont.ConsoleSocket != "" {
-		log.Warningf("ignoring console socket since it cannot be restored")
+	for _, r := range restored {
+		if r.ID != cont.ID {
+			continue
+		}
+		ws, err := r.Wait()
+		if err != nil {
+			Fatalf("Error waiting for container: %v", err)
+		}
+		*waitStatus = ws
 	}
+	return subcommands.ExitSuccess
+}
 
-	if err := cont.Destroy(); err != nil {
-		Fatalf("destroying container: %v", err)
+// sandboxContainers returns the containers of the sandbox of cont, starting
+// with its root container.
+func sandboxContainers(conf *config.Config, cont *container.Container) ([]*container.Container, error) {
+	ids, err := container.List(conf.RootDir)
+	if err != nil {
+		return nil, err
+	}
+	var conts []*container.Container
+	for _, id := range ids {
+		if id.SandboxID != cont.Sandbox.ID {
+			continue
+		}
+		c, err := container.Load(conf.RootDir, id, container.LoadOpts{Exact: true})
+		if err != nil {
+			if os.IsNotExist(err) {
+				// Raced with the container's deletion.
+				continue
+			}
+			return nil, fmt.Errorf("loading container %q: %v", id.ContainerID, err)
+		}
+		if c.IsSandboxRoot() {
+			conts = append([]*container.Container{c}, conts...)
+		} else {
+			conts = append(conts, c)
+		}
+	}
+	if len(conts) == 0 || !conts[0].IsSandboxRoot() {
+		return nil, fmt.Errorf("root container of sandbox %q not found", cont.Sandbox.ID)
 	}
+	return conts, nil
+}
 
-	contArgs := container.Args{
-		ID:        id,
-		Spec:      spec,
-		BundleDir: bundleDir,
+// writeCheckpointManifest writes the list of containers saved in the image at
+// imagePath.
+func writeCheckpointManifest(imagePath string, conts []*container.Container) error {
+	var manifest []checkpointedContainer
+	for _, c := range conts {
+		manifest = append(manifest, checkpointedContainer{
+			ID:        c.ID,
+			BundleDir: c.BundleDir,
+			Root:      c.IsSandboxRoot(),
+		})
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
 	}
-	cont, err = container.New(conf, contArgs)
+	return ioutil.WriteFile(filepath.Join(imagePath, checkpointManifestFileName), data, 0644)
+}
+
+// readCheckpointManifest returns the containers saved in the image at
+// imagePath, or nil if the image doesn't list them.
+func readCheckpointManifest(imagePath string) ([]checkpointedContainer, error) {
+	data, err := ioutil.ReadFile(filepath.Join(imagePath, checkpointManifestFileName))
 	if err != nil {
-		Fatalf("restoring container: %v", err)
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var manifest []checkpointedContainer
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("parsing %q: %v", checkpointManifestFileName, err)
 	}
-	defer cont.Destroy()
+	return manifest, nil
+}
 
-	if err := cont.Restore(spec, conf, fullImagePath); err != nil {
-		Fatalf("starting container: %v", err)
+// restoreSandbox destroys the containers conts of a sandbox, listed root
+// container first, and restores new containers with the same IDs from the
+// image at imagePath. It returns the containers created, which the caller must destroy
+// even if an error is returned.
+func restoreSandbox(conf *config.Config, conts []*container.Container, imagePath string) ([]*container.Container, error) {
+	contSpecs := make([]*specs.Spec, 0, len(conts))
+	for _, c := range conts {
+		// Restore into new containers with the same IDs.
+		if c.BundleDir == "" {
+			return nil, fmt.Errorf("container %q has no bundle directory", c.ID)
+		}
+		spec, err := specutils.ReadSpec(c.BundleDir, conf)
+		if err != nil {
+			return nil, fmt.Errorf("reading spec of container %q: %v", c.ID, err)
+		}
+		specutils.LogSpec(spec)
+		if c.ConsoleSocket != "" {
+			log.Warningf("ignoring console socket of container %q since it cannot be restored", c.ID)
+		}
+		contSpecs = append(contSpecs, spec)
 	}
 
-	ws, err := cont.Wait()
-	if err != nil {
-		Fatalf("Error waiting for container: %v", err)
+	// Sub-containers are destroyed before the root container, which destroys
+	// the sandbox.
+	for i := len(conts) - 1; i >= 0; i-- {
+		if err := conts[i].Destroy(); err != nil {
+			return nil, fmt.Errorf("destroying container %q: %v", conts[i].ID, err)
+		}
 	}
-	*waitStatus = ws
 
-	return subcommands.ExitSuccess
+	// The root container creates the sandbox, in which sub-containers are
+	// then created.
+	var restored []*container.Container
+	for i, c := range conts {
+		r, err := container.New(conf, container.Args{
+			ID:        c.ID,
+			Spec:      contSpecs[i],
+			BundleDir: c.BundleDir,
+		})
+		if err != nil {
+			return restored, fmt.Errorf("restoring container %q: %v", c.ID, err)
+		}
+		restored = append(restored, r)
+	}
+
+	// Sub-containers must be restored before the root container, which resumes
+	// the whole sandbox.
+	for i := len(restored) - 1; i >= 0; i-- {
+		if err := restored[i].Restore(contSpecs[i], conf, imagePath); err != nil {
+			return restored, fmt.Errorf("starting container %q: %v", restored[i].ID, err)
+		}
+	}
+	return restored, nil
 }
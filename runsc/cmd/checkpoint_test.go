@@ -0,0 +1,67 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"gvisor.dev/gvisor/runsc/container"
+	"gvisor.dev/gvisor/runsc/specutils"
+)
+
+func TestCheckpointManifest(t *testing.T) {
+	imagePath := t.TempDir()
+
+	// Images of older versions don't list their containers.
+	manifest, err := readCheckpointManifest(imagePath)
+	if err != nil {
+		t.Fatalf("readCheckpointManifest failed: %v", err)
+	}
+	if manifest != nil {
+		t.Errorf("readCheckpointManifest without manifest: got %+v, want nil", manifest)
+	}
+
+	sub := &specs.Spec{
+		Annotations: map[string]string{
+			specutils.ContainerdContainerTypeAnnotation: specutils.ContainerdContainerTypeContainer,
+		},
+	}
+	conts := []*container.Container{
+		{ID: "root", BundleDir: "/bundle/root", Spec: &specs.Spec{}},
+		{ID: "sub", BundleDir: "/bundle/sub", Spec: sub},
+	}
+	if err := writeCheckpointManifest(imagePath, conts); err != nil {
+		t.Fatalf("writeCheckpointManifest failed: %v", err)
+	}
+	manifest, err = readCheckpointManifest(imagePath)
+	if err != nil {
+		t.Fatalf("readCheckpointManifest failed: %v", err)
+	}
+	want := []checkpointedContainer{
+		{ID: "root", BundleDir: "/bundle/root", Root: true},
+		{ID: "sub", BundleDir: "/bundle/sub"},
+	}
+	if diff := cmp.Diff(want, manifest); diff != "" {
+		t.Errorf("readCheckpointManifest mismatch (-want +got):\n%s", diff)
+	}
+	if !inCheckpointManifest(manifest, "sub") {
+		t.Errorf("inCheckpointManifest(%q) = false, want true", "sub")
+	}
+	if inCheckpointManifest(manifest, "other") {
+		t.Errorf("inCheckpointManifest(%q) = true, want false", "other")
+	}
+}
@@ -0,0 +1,424 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"math"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"time"
+
+	"github.com/google/subcommands"
+	"golang.org/x/sys/unix"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/pkg/sentry/control"
+	"gvisor.dev/gvisor/pkg/sentry/kernel/auth"
+	"gvisor.dev/gvisor/pkg/urpc"
+	"gvisor.dev/gvisor/runsc/boot"
+	"gvisor.dev/gvisor/runsc/config"
+	"gvisor.dev/gvisor/runsc/container"
+	pb "gvisor.dev/gvisor/runsc/controlapi/v1/control_go_proto"
+	"gvisor.dev/gvisor/runsc/flag"
+	"gvisor.dev/gvisor/runsc/sandbox"
+	"gvisor.dev/gvisor/runsc/specutils"
+)
+
+// ControlAPI implements subcommands.Command for the "control-api" command.
+type ControlAPI struct {
+	address string
+}
+
+// Name implements subcommands.Command.Name.
+func (*ControlAPI) Name() string {
+	return "control-api"
+}
+
+// Synopsis implements subcommands.Command.Synopsis.
+func (*ControlAPI) Synopsis() string {
+	return "serve the control API of the containers over gRPC"
+}
+
+// Usage implements subcommands.Command.Usage.
+func (*ControlAPI) Usage() string {
+	return `--address=<path>
+
+Serves the versioned gRPC control API, defined in runsc/controlapi, on a UNIX
+socket until interrupted. The API can exec into, signal, list the processes of,
+get events from and checkpoint the containers in the root directory given by
+--root. The socket is only accessible to the user running this command.
+
+OPTIONS:
+`
+}
+
+// SetFlags implements subcommands.Command.SetFlags.
+func (c *ControlAPI) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&c.address, "address", "", "path of the UNIX socket to serve the API on")
+}
+
+// Execute implements subcommands.Command.Execute.
+func (c *ControlAPI) Execute(_ context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 0 || c.address == "" {
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+	conf := args[0].(*config.Config)
+
+	// Remove the socket left over by a previous instance, if any.
+	if err := os.Remove(c.address); err != nil && !os.IsNotExist(err) {
+		Fatalf("removing %q: %v", c.address, err)
+	}
+	l, err := net.Listen("unix", c.address)
+	if err != nil {
+		Fatalf("listening on %q: %v", c.address, err)
+	}
+	defer os.Remove(c.address)
+	if err := os.Chmod(c.address, 0600); err != nil {
+		Fatalf("chmod(%q): %v", c.address, err)
+	}
+
+	s := grpc.NewServer()
+	pb.RegisterControlServer(s, &controlAPIServer{conf: conf})
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, unix.SIGINT, unix.SIGTERM)
+	go func() {
+		sig := <-sigCh
+		log.Infof("Got signal %v, stopping control API server", sig)
+		s.GracefulStop()
+	}()
+
+	log.Infof("Serving control API on %q", c.address)
+	if err := s.Serve(l); err != nil {
+		Fatalf("serving control API: %v", err)
+	}
+	return subcommands.ExitSuccess
+}
+
+// controlAPIServer implements pb.ControlServer on top of container.Container.
+type controlAPIServer struct {
+	pb.UnimplementedControlServer
+
+	conf *config.Config
+}
+
+// load loads the container with the given ID.
+func (s *controlAPIServer) load(id string) (*container.Container, error) {
+	if id == "" {
+		return nil, status.Error(codes.InvalidArgument, "container_id is required")
+	}
+	c, err := container.Load(s.conf.RootDir, container.FullID{ContainerID: id}, container.LoadOpts{})
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, status.Errorf(codes.NotFound, "container %q not found", id)
+		}
+		return nil, status.Errorf(codes.Internal, "loading container %q: %v", id, err)
+	}
+	return c, nil
+}
+
+// Exec implements pb.ControlServer.Exec.
+func (s *controlAPIServer) Exec(_ context.Context, req *pb.ExecRequest) (*pb.ExecResponse, error) {
+	if len(req.GetArgv()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "argv is required")
+	}
+	c, err := s.load(req.GetContainerId())
+	if err != nil {
+		return nil, err
+	}
+
+	devNull, err := os.OpenFile(os.DevNull, os.O_RDWR, 0)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "opening %q: %v", os.DevNull, err)
+	}
+	defer devNull.Close()
+
+	e := &control.ExecArgs{
+		Argv:             req.GetArgv(),
+		Envv:             req.GetEnv(),
+		WorkingDirectory: req.GetCwd(),
+		KUID:             auth.KUID(req.GetUid()),
+		KGID:             auth.KGID(req.GetGid()),
+		ExecID:           req.GetExecId(),
+		FilePayload:      urpc.FilePayload{Files: []*os.File{devNull, devNull, devNull}},
+	}
+	for _, gid := range req.GetAdditionalGids() {
+		e.ExtraKGIDs = append(e.ExtraKGIDs, auth.KGID(gid))
+	}
+	// Use the defaults of the container, like "runsc exec".
+	if e.WorkingDirectory == "" {
+		e.WorkingDirectory = c.Spec.Process.Cwd
+	}
+	if len(e.Envv) == 0 {
+		e.Envv = c.Spec.Process.Env
+	}
+	e.Capabilities, err = specutils.Capabilities(s.conf.EnableRaw, c.Spec.Process.Capabilities)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "creating capabilities: %v", err)
+	}
+
+	pid, err := c.Execute(s.conf, e)
+	if err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "executing process: %v", err)
+	}
+	return &pb.ExecResponse{Pid: pid}, nil
+}
+
+// Wait implements pb.ControlServer.Wait.
+func (s *controlAPIServer) Wait(_ context.Context, req *pb.WaitRequest) (*pb.WaitResponse, error) {
+	c, err := s.load(req.GetContainerId())
+	if err != nil {
+		return nil, err
+	}
+	var ws unix.WaitStatus
+	if req.GetPid() == 0 {
+		ws, err = c.Wait()
+	} else {
+		ws, err = c.WaitPID(req.GetPid())
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "waiting: %v", err)
+	}
+	return waitStatusToProto(ws), nil
+}
+
+// Signal implements pb.ControlServer.Signal.
+func (s *controlAPIServer) Signal(_ context.Context, req *pb.SignalRequest) (*pb.SignalResponse, error) {
+	if req.GetPid() != 0 && req.GetAll() {
+		return nil, status.Error(codes.InvalidArgument, "pid and all are mutually exclusive")
+	}
+	c, err := s.load(req.GetContainerId())
+	if err != nil {
+		return nil, err
+	}
+	sig := unix.Signal(req.GetSignal())
+	if req.GetPid() != 0 {
+		err = c.SignalProcess(sig, req.GetPid())
+	} else {
+		err = c.SignalContainer(sig, req.GetAll())
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "sending signal %d: %v", sig, err)
+	}
+	return &pb.SignalResponse{}, nil
+}
+
+// ResizeTTY implements pb.ControlServer.ResizeTTY.
+func (s *controlAPIServer) ResizeTTY(_ context.Context, req *pb.ResizeTTYRequest) (*pb.ResizeTTYResponse, error) {
+	if req.GetRows() > math.MaxUint16 || req.GetCols() > math.MaxUint16 {
+		return nil, status.Error(codes.InvalidArgument, "window size is too large")
+	}
+	c, err := s.load(req.GetContainerId())
+	if err != nil {
+		return nil, err
+	}
+	ws := linux.Winsize{
+		Row: uint16(req.GetRows()),
+		Col: uint16(req.GetCols()),
+	}
+	if err := c.ResizeTTY(req.GetPid(), &ws); err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "resizing TTY: %v", err)
+	}
+	return &pb.ResizeTTYResponse{}, nil
+}
+
+// Processes implements pb.ControlServer.Processes.
+func (s *controlAPIServer) Processes(_ context.Context, req *pb.ProcessesRequest) (*pb.ProcessesResponse, error) {
+	c, err := s.load(req.GetContainerId())
+	if err != nil {
+		return nil, err
+	}
+	pl, err := c.Processes()
+	if err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "getting processes: %v", err)
+	}
+	return processesToProto(pl), nil
+}
+
+// Events implements pb.ControlServer.Events.
+func (s *controlAPIServer) Events(req *pb.EventsRequest, stream pb.Control_EventsServer) error {
+	c, err := s.load(req.GetContainerId())
+	if err != nil {
+		return err
+	}
+	for {
+		ev, err := c.Event()
+		if err != nil {
+			return status.Errorf(codes.FailedPrecondition, "getting events: %v", err)
+		}
+		if err := stream.Send(eventToProto(&ev.Event)); err != nil {
+			return err
+		}
+		if req.GetIntervalSec() == 0 {
+			return nil
+		}
+		select {
+		case <-time.After(time.Duration(req.GetIntervalSec()) * time.Second):
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		}
+	}
+}
+
+// Checkpoint implements pb.ControlServer.Checkpoint.
+func (s *controlAPIServer) Checkpoint(_ context.Context, req *pb.CheckpointRequest) (*pb.CheckpointResponse, error) {
+	if !filepath.IsAbs(req.GetImagePath()) {
+		return nil, status.Errorf(codes.InvalidArgument, "image_path %q must be an absolute path", req.GetImagePath())
+	}
+	c, err := s.load(req.GetContainerId())
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(req.GetImagePath(), 0755); err != nil {
+		return nil, status.Errorf(codes.Internal, "making directories at %q: %v", req.GetImagePath(), err)
+	}
+	path := filepath.Join(req.GetImagePath(), checkpointFileName)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0644)
+	if err != nil {
+		if os.IsExist(err) {
+			return nil, status.Errorf(codes.AlreadyExists, "image %q already exists", path)
+		}
+		return nil, status.Errorf(codes.Internal, "creating image %q: %v", path, err)
+	}
+	defer f.Close()
+	if err := c.Checkpoint(f, sandbox.CheckpointOpts{Exclude: req.GetExclude()}); err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "checkpoint failed: %v", err)
+	}
+	return &pb.CheckpointResponse{}, nil
+}
+
+// StartSnapshots implements pb.ControlServer.StartSnapshots.
+func (s *controlAPIServer) StartSnapshots(_ context.Context, req *pb.StartSnapshotsRequest) (*pb.StartSnapshotsResponse, error) {
+	if !filepath.IsAbs(req.GetImagePath()) {
+		return nil, status.Errorf(codes.InvalidArgument, "image_path %q must be an absolute path", req.GetImagePath())
+	}
+	if req.GetIntervalSec() == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "interval_sec must be set")
+	}
+	keep := int(req.GetKeep())
+	if keep == 0 {
+		keep = 1
+	}
+	c, err := s.load(req.GetContainerId())
+	if err != nil {
+		return nil, err
+	}
+	files, err := openSnapshotImages(req.GetImagePath(), keep)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "%v", err)
+	}
+	defer closeSnapshotImages(files)
+	if err := c.StartSnapshots(time.Duration(req.GetIntervalSec())*time.Second, files); err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "starting snapshots: %v", err)
+	}
+	return &pb.StartSnapshotsResponse{}, nil
+}
+
+// StopSnapshots implements pb.ControlServer.StopSnapshots.
+func (s *controlAPIServer) StopSnapshots(_ context.Context, req *pb.StopSnapshotsRequest) (*pb.StopSnapshotsResponse, error) {
+	c, err := s.load(req.GetContainerId())
+	if err != nil {
+		return nil, err
+	}
+	if err := c.StopSnapshots(); err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "stopping snapshots: %v", err)
+	}
+	return &pb.StopSnapshotsResponse{}, nil
+}
+
+// waitStatusToProto converts the status of an exited process.
+func waitStatusToProto(ws unix.WaitStatus) *pb.WaitResponse {
+	if ws.Signaled() {
+		return &pb.WaitResponse{Signal: int32(ws.Signal())}
+	}
+	return &pb.WaitResponse{ExitStatus: int32(ws.ExitStatus())}
+}
+
+// processesToProto converts a process list returned by the sandbox.
+func processesToProto(pl []*control.Process) *pb.ProcessesResponse {
+	resp := &pb.ProcessesResponse{}
+	for _, p := range pl {
+		proc := &pb.Process{
+			Uid:       uint32(p.UID),
+			Pid:       int32(p.PID),
+			Ppid:      int32(p.PPID),
+			Cpu:       p.C,
+			Tty:       p.TTY,
+			StartTime: p.STime,
+			CpuTime:   p.Time,
+			Cmd:       p.Cmd,
+		}
+		for _, tid := range p.Threads {
+			proc.Threads = append(proc.Threads, int32(tid))
+		}
+		resp.Processes = append(resp.Processes, proc)
+	}
+	return resp
+}
+
+// eventToProto converts a stats event of a container.
+func eventToProto(ev *boot.Event) *pb.Event {
+	pe := &pb.Event{
+		ContainerId: ev.ID,
+		Cpu: &pb.CPUUsage{
+			Kernel: ev.Data.CPU.Usage.Kernel,
+			User:   ev.Data.CPU.Usage.User,
+			Total:  ev.Data.CPU.Usage.Total,
+			PerCpu: ev.Data.CPU.Usage.PerCPU,
+		},
+		Memory: &pb.MemoryUsage{
+			Usage: ev.Data.Memory.Usage.Usage,
+			Limit: ev.Data.Memory.Usage.Limit,
+			Max:   ev.Data.Memory.Usage.Max,
+			Cache: ev.Data.Memory.Cache,
+		},
+		Pids: &pb.PidsUsage{
+			Current: ev.Data.Pids.Current,
+			Limit:   ev.Data.Pids.Limit,
+		},
+		CpuPressure:    pressureToProto(ev.Data.CPU.PSI),
+		MemoryPressure: pressureToProto(ev.Data.Memory.PSI),
+	}
+	if ev.Data.Blkio != nil {
+		pe.IoPressure = pressureToProto(ev.Data.Blkio.PSI)
+	}
+	return pe
+}
+
+// pressureToProto converts pressure stall information, which may be nil.
+func pressureToProto(psi *boot.PSIStats) *pb.Pressure {
+	if psi == nil {
+		return nil
+	}
+	data := func(d *boot.PSIData) *pb.PSIData {
+		return &pb.PSIData{
+			Avg10:  d.Avg10,
+			Avg60:  d.Avg60,
+			Avg300: d.Avg300,
+			Total:  d.Total,
+		}
+	}
+	return &pb.Pressure{
+		Some: data(&psi.Some),
+		Full: data(&psi.Full),
+	}
+}
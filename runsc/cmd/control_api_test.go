@@ -0,0 +1,152 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/sys/unix"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/testing/protocmp"
+	"gvisor.dev/gvisor/pkg/sentry/control"
+	"gvisor.dev/gvisor/pkg/sentry/kernel"
+	"gvisor.dev/gvisor/runsc/boot"
+	"gvisor.dev/gvisor/runsc/config"
+	pb "gvisor.dev/gvisor/runsc/controlapi/v1/control_go_proto"
+)
+
+func TestControlAPIProcesses(t *testing.T) {
+	pl := []*control.Process{
+		{
+			UID:     1000,
+			PID:     2,
+			PPID:    1,
+			Threads: []kernel.ThreadID{2, 3},
+			C:       10,
+			TTY:     "?",
+			STime:   "14:04",
+			Time:    "10ms",
+			Cmd:     "sleep",
+		},
+	}
+	want := &pb.ProcessesResponse{
+		Processes: []*pb.Process{
+			{
+				Uid:       1000,
+				Pid:       2,
+				Ppid:      1,
+				Threads:   []int32{2, 3},
+				Cpu:       10,
+				Tty:       "?",
+				StartTime: "14:04",
+				CpuTime:   "10ms",
+				Cmd:       "sleep",
+			},
+		},
+	}
+	if diff := cmp.Diff(want, processesToProto(pl), protocmp.Transform()); diff != "" {
+		t.Errorf("processesToProto() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestControlAPIEvent(t *testing.T) {
+	ev := &boot.Event{
+		Type: "stats",
+		ID:   "foo",
+		Data: boot.Stats{
+			CPU:    boot.CPU{Usage: boot.CPUUsage{Kernel: 1, User: 2, Total: 3, PerCPU: []uint64{3}}},
+			Memory: boot.Memory{Cache: 4, Usage: boot.MemoryEntry{Usage: 5, Limit: 6, Max: 7}},
+			Pids:   boot.Pids{Current: 8, Limit: 9},
+			Blkio: &boot.Blkio{PSI: &boot.PSIStats{
+				Some: boot.PSIData{Avg10: 1.5, Avg60: 0.5, Avg300: 0.25, Total: 10},
+				Full: boot.PSIData{Avg10: 0.5, Total: 11},
+			}},
+		},
+	}
+	want := &pb.Event{
+		ContainerId: "foo",
+		Cpu:         &pb.CPUUsage{Kernel: 1, User: 2, Total: 3, PerCpu: []uint64{3}},
+		Memory:      &pb.MemoryUsage{Usage: 5, Limit: 6, Max: 7, Cache: 4},
+		Pids:        &pb.PidsUsage{Current: 8, Limit: 9},
+		IoPressure: &pb.Pressure{
+			Some: &pb.PSIData{Avg10: 1.5, Avg60: 0.5, Avg300: 0.25, Total: 10},
+			Full: &pb.PSIData{Avg10: 0.5, Total: 11},
+		},
+	}
+	if diff := cmp.Diff(want, eventToProto(ev), protocmp.Transform()); diff != "" {
+		t.Errorf("eventToProto() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestControlAPIWaitStatus(t *testing.T) {
+	for _, tc := range []struct {
+		ws   unix.WaitStatus
+		want *pb.WaitResponse
+	}{
+		{ws: 0, want: &pb.WaitResponse{}},
+		{ws: 3 << 8, want: &pb.WaitResponse{ExitStatus: 3}},
+		{ws: unix.WaitStatus(unix.SIGKILL), want: &pb.WaitResponse{Signal: int32(unix.SIGKILL)}},
+	} {
+		if diff := cmp.Diff(tc.want, waitStatusToProto(tc.ws), protocmp.Transform()); diff != "" {
+			t.Errorf("waitStatusToProto(%#x) mismatch (-want +got):\n%s", uint32(tc.ws), diff)
+		}
+	}
+}
+
+func TestControlAPIInvalidArgument(t *testing.T) {
+	s := &controlAPIServer{conf: &config.Config{RootDir: t.TempDir()}}
+	ctx := context.Background()
+	for name, call := range map[string]func() error{
+		"exec without argv": func() error {
+			_, err := s.Exec(ctx, &pb.ExecRequest{ContainerId: "foo"})
+			return err
+		},
+		"exec without container": func() error {
+			_, err := s.Exec(ctx, &pb.ExecRequest{Argv: []string{"true"}})
+			return err
+		},
+		"signal pid and all": func() error {
+			_, err := s.Signal(ctx, &pb.SignalRequest{ContainerId: "foo", Pid: 2, All: true})
+			return err
+		},
+		"checkpoint relative path": func() error {
+			_, err := s.Checkpoint(ctx, &pb.CheckpointRequest{ContainerId: "foo", ImagePath: "image"})
+			return err
+		},
+		"snapshots relative path": func() error {
+			_, err := s.StartSnapshots(ctx, &pb.StartSnapshotsRequest{ContainerId: "foo", ImagePath: "image", IntervalSec: 60})
+			return err
+		},
+		"resize too large": func() error {
+			_, err := s.ResizeTTY(ctx, &pb.ResizeTTYRequest{ContainerId: "foo", Rows: 1 << 16, Cols: 80})
+			return err
+		},
+		"snapshots without interval": func() error {
+			_, err := s.StartSnapshots(ctx, &pb.StartSnapshotsRequest{ContainerId: "foo", ImagePath: "/image"})
+			return err
+		},
+	} {
+		if got := status.Code(call()); got != codes.InvalidArgument {
+			t.Errorf("%s: got code %v, want %v", name, got, codes.InvalidArgument)
+		}
+	}
+
+	if _, err := s.Processes(ctx, &pb.ProcessesRequest{ContainerId: "foo"}); status.Code(err) != codes.NotFound {
+		t.Errorf("Processes() of a missing container: got %v, want code %v", err, codes.NotFound)
+	}
+}
@@ -16,17 +16,23 @@ package cmd
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"os"
 	"os/signal"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"text/tabwriter"
 	"time"
 
 	"github.com/google/subcommands"
 	"golang.org/x/sys/unix"
 	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/pkg/p9/opstats"
 	"gvisor.dev/gvisor/pkg/sentry/control"
+	"gvisor.dev/gvisor/runsc/boot"
 	"gvisor.dev/gvisor/runsc/config"
 	"gvisor.dev/gvisor/runsc/container"
 	"gvisor.dev/gvisor/runsc/flag"
@@ -34,21 +40,33 @@ import (
 
 // Debug implements subcommands.Command for the "debug" command.
 type Debug struct {
-	pid          int
-	stacks       bool
-	signal       int
-	profileBlock string
-	profileCPU   string
-	profileHeap  string
-	profileMutex string
-	trace        string
-	strace       string
-	logLevel     string
-	logPackets   string
-	delay        time.Duration
-	duration     time.Duration
-	ps           bool
-	cat          stringSlice
+	pid           int
+	stacks        bool
+	stacksFile    string
+	dmesg         bool
+	signal        int
+	profileBlock  string
+	profileCPU    string
+	profileHeap   string
+	profileMutex  string
+	trace         string
+	strace        string
+	straceFollow  bool
+	stracePIDs    string
+	straceSys     string
+	logLevel      string
+	logPackets    string
+	delay         time.Duration
+	duration      time.Duration
+	ps            bool
+	cat           stringSlice
+	ports         bool
+	portRange     string
+	reservedPorts string
+	usage         bool
+	cpuUsage      bool
+	goferStats    bool
+	bootTimeline  bool
 }
 
 // Name implements subcommands.Command.
@@ -70,6 +88,8 @@ func (*Debug) Usage() string {
 func (d *Debug) SetFlags(f *flag.FlagSet) {
 	f.IntVar(&d.pid, "pid", 0, "sandbox process ID. Container ID is not necessary if this is set")
 	f.BoolVar(&d.stacks, "stacks", false, "if true, dumps all sandbox stacks to the log")
+	f.StringVar(&d.stacksFile, "stacks-file", "", "writes all sandbox stacks to the given file.")
+	f.BoolVar(&d.dmesg, "dmesg", false, "prints the most recent log records of the sandbox, which are kept even if debug logging is disabled")
 	f.StringVar(&d.profileBlock, "profile-block", "", "writes block profile to the given file.")
 	f.StringVar(&d.profileCPU, "profile-cpu", "", "writes CPU profile to the given file.")
 	f.StringVar(&d.profileHeap, "profile-heap", "", "writes heap profile to the given file.")
@@ -79,10 +99,20 @@ func (d *Debug) SetFlags(f *flag.FlagSet) {
 	f.StringVar(&d.trace, "trace", "", "writes an execution trace to the given file.")
 	f.IntVar(&d.signal, "signal", -1, "sends signal to the sandbox")
 	f.StringVar(&d.strace, "strace", "", `A comma separated list of syscalls to trace. "all" enables all traces, "off" disables all.`)
+	f.BoolVar(&d.straceFollow, "strace-follow", false, "streams straces to standard output as they happen, like strace -p, until interrupted. Independent of -strace.")
+	f.StringVar(&d.stracePIDs, "strace-pids", "", "A comma separated list of PIDs, as listed by -ps, to stream straces of with -strace-follow. Empty streams all processes.")
+	f.StringVar(&d.straceSys, "strace-syscalls", "", "A comma separated list of syscalls to stream straces of with -strace-follow. Empty streams all syscalls.")
 	f.StringVar(&d.logLevel, "log-level", "", "The log level to set: warning (0), info (1), or debug (2).")
 	f.StringVar(&d.logPackets, "log-packets", "", "A boolean value to enable or disable packet logging: true or false.")
 	f.BoolVar(&d.ps, "ps", false, "lists processes")
+	f.BoolVar(&d.ports, "ports", false, "shows the ephemeral port range and reserved ports of the sandbox network stack")
+	f.StringVar(&d.portRange, "port-range", "", `sets the ephemeral port range, like net.ipv4.ip_local_port_range, e.g. "32768 60999".`)
+	f.StringVar(&d.reservedPorts, "reserved-ports", "", `sets the ports excluded from the ephemeral port range, like net.ipv4.ip_local_reserved_ports, e.g. "8080,9000-9010". An empty value clears them.`)
 	f.Var(&d.cat, "cat", "reads files and print to standard output")
+	f.BoolVar(&d.usage, "usage", false, "prints a breakdown of the memory used by the sandbox")
+	f.BoolVar(&d.cpuUsage, "cpu-usage", false, "prints a breakdown of the CPU time used by the sandbox, between the application and the sentry")
+	f.BoolVar(&d.goferStats, "gofer-stats", false, "prints the count and latency of the operations served by the gofer of the container, and the last slow operations. Only supported with 9P")
+	f.BoolVar(&d.bootTimeline, "boot-timeline", false, "prints the time taken by the phases of the creation and start of the container, in runsc and in the sandbox")
 }
 
 // Execute implements subcommands.Command.Execute.
@@ -148,13 +178,28 @@ func (d *Debug) Execute(_ context.Context, f *flag.FlagSet, args ...interface{})
 			return Errorf("failed to send signal %d to processs %d", d.signal, c.Sandbox.Pid)
 		}
 	}
-	if d.stacks {
+	if d.stacks || d.stacksFile != "" {
 		log.Infof("Retrieving sandbox stacks")
 		stacks, err := c.Sandbox.Stacks()
 		if err != nil {
 			return Errorf("retrieving stacks: %v", err)
 		}
-		log.Infof("     *** Stack dump ***\n%s", stacks)
+		if d.stacks {
+			log.Infof("     *** Stack dump ***\n%s", stacks)
+		}
+		if d.stacksFile != "" {
+			if err := os.WriteFile(d.stacksFile, []byte(stacks), 0644); err != nil {
+				return Errorf("writing stacks to %q: %v", d.stacksFile, err)
+			}
+			log.Infof("Stacks written to %q", d.stacksFile)
+		}
+	}
+	if d.dmesg {
+		records, err := c.Sandbox.Dmesg()
+		if err != nil {
+			return Errorf(err.Error())
+		}
+		fmt.Print(records)
 	}
 	if d.strace != "" || len(d.logLevel) != 0 || len(d.logPackets) != 0 {
 		args := control.LoggingArgs{}
@@ -224,6 +269,65 @@ func (d *Debug) Execute(_ context.Context, f *flag.FlagSet, args ...interface{})
 		log.Infof(o)
 	}
 
+	if d.usage {
+		m, err := c.Sandbox.MemoryBreakdown()
+		if err != nil {
+			return Errorf(err.Error())
+		}
+		printMemoryBreakdown(os.Stdout, &m)
+	}
+	if d.cpuUsage {
+		b, err := c.CPUBreakdown()
+		if err != nil {
+			return Errorf(err.Error())
+		}
+		printCPUBreakdown(os.Stdout, &b)
+	}
+	if d.goferStats {
+		stats, err := c.GoferStats()
+		if err != nil {
+			return Errorf(err.Error())
+		}
+		printGoferStats(os.Stdout, stats)
+	}
+	if d.bootTimeline {
+		printBootTimeline(os.Stdout, c.BootTimeline)
+	}
+
+	setReservedPorts := false
+	f.Visit(func(fl *flag.Flag) {
+		if fl.Name == "reserved-ports" {
+			setReservedPorts = true
+		}
+	})
+	if d.ports || d.portRange != "" || setReservedPorts {
+		ports, err := c.Sandbox.Ports()
+		if err != nil {
+			return Errorf(err.Error())
+		}
+		if d.portRange != "" || setReservedPorts {
+			if d.portRange != "" {
+				ports.Start, ports.End, err = boot.ParsePortRange(d.portRange)
+				if err != nil {
+					return Errorf("invalid port range: %v", err)
+				}
+			}
+			if setReservedPorts {
+				ports.Reserved, err = boot.ParsePortList(d.reservedPorts)
+				if err != nil {
+					return Errorf("invalid reserved ports: %v", err)
+				}
+			}
+			if err := c.Sandbox.SetPorts(ports); err != nil {
+				return Errorf(err.Error())
+			}
+			log.Infof("Ephemeral ports changed")
+		}
+		if d.ports {
+			log.Infof("Ephemeral ports: %d-%d, reserved: %v", ports.Start, ports.End, ports.Reserved)
+		}
+	}
+
 	// Open profiling files.
 	var (
 		blockFile *os.File
@@ -376,6 +480,28 @@ func (d *Debug) Execute(_ context.Context, f *flag.FlagSet, args ...interface{})
 		return subcommands.ExitFailure
 	}
 
+	if d.straceFollow {
+		var pids []int32
+		if d.stracePIDs != "" {
+			for _, s := range strings.Split(d.stracePIDs, ",") {
+				pid, err := strconv.ParseInt(s, 10, 32)
+				if err != nil {
+					return Errorf("invalid PID %q: %v", s, err)
+				}
+				pids = append(pids, int32(pid))
+			}
+		}
+		var syscalls []string
+		if d.straceSys != "" {
+			syscalls = strings.Split(d.straceSys, ",")
+		}
+		// Stop catching signals above: interrupting stops following.
+		signal.Reset(unix.SIGTERM, unix.SIGINT)
+		if err := c.Sandbox.StraceFollow(pids, syscalls, os.Stdout); err != nil {
+			return Errorf(err.Error())
+		}
+	}
+
 	if d.cat != nil {
 		if err := c.Cat(d.cat, os.Stdout); err != nil {
 			return Errorf("Cat failed: %v", err)
@@ -384,3 +510,101 @@ func (d *Debug) Execute(_ context.Context, f *flag.FlagSet, args ...interface{})
 
 	return subcommands.ExitSuccess
 }
+
+// printMemoryBreakdown writes a memory breakdown in a human readable table.
+func printMemoryBreakdown(w io.Writer, m *control.MemoryBreakdown) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	for _, e := range []struct {
+		name  string
+		bytes uint64
+	}{
+		{"Application anonymous", m.Application.Anonymous},
+		{"Application mapped", m.Application.Mapped},
+		{"Page cache", m.Application.PageCache},
+		{"Tmpfs", m.Application.Tmpfs},
+		{"Ramdiskfs", m.Application.Ramdiskfs},
+		{"System", m.Application.System},
+		{"Application total", m.Application.Total},
+		{"Netstack buffers", m.NetstackBuffers},
+		{"Go heap in use", m.GoHeapInUse},
+		{"Go heap idle", m.GoHeapIdle},
+		{"Go stacks", m.GoStacks},
+		{"Go total", m.GoSys},
+	} {
+		fmt.Fprintf(tw, "%s:\t%d KiB\t\n", e.name, e.bytes/1024)
+	}
+	tw.Flush()
+}
+
+// printCPUBreakdown writes a CPU time breakdown in a human readable table.
+func printCPUBreakdown(w io.Writer, b *control.CPUBreakdown) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	for _, e := range []struct {
+		name string
+		ns   uint64
+	}{
+		{"Application user", b.ApplicationUser},
+		{"Application sys", b.ApplicationSys},
+		{"Sentry overhead", b.SentryOverhead},
+		{"Go GC (estimate)", b.GC},
+		{"Sandbox process user", b.ProcessUser},
+		{"Sandbox process sys", b.ProcessSys},
+		{"Gofer", b.Gofer},
+	} {
+		fmt.Fprintf(tw, "%s:\t%v\t\n", e.name, time.Duration(e.ns))
+	}
+	cids := make([]string, 0, len(b.Containers))
+	for cid := range b.Containers {
+		cids = append(cids, cid)
+	}
+	sort.Strings(cids)
+	for _, cid := range cids {
+		fmt.Fprintf(tw, "Container %s:\t%v\t\n", cid, time.Duration(b.Containers[cid]))
+	}
+	tw.Flush()
+}
+
+// printGoferStats writes gofer operation statistics in a human readable table,
+// followed by the slow operations.
+func printGoferStats(w io.Writer, s *opstats.Stats) {
+	ops := make([]string, 0, len(s.Ops))
+	for op := range s.Ops {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "Operation\tCount\tErrors\tTotal\tP50\tP90\tP99\tMax\t\n")
+	for _, op := range ops {
+		o := s.Ops[op]
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%v\t%v\t%v\t%v\t%v\t\n", op, o.Count, o.Errors, o.Total, o.P50, o.P90, o.P99, o.Max)
+	}
+	tw.Flush()
+
+	if s.SlowThreshold == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\nLast operations slower than %v:\n", s.SlowThreshold)
+	for _, op := range s.SlowOps {
+		fmt.Fprintf(w, "%s %s %q took %v", op.Time.Format(time.RFC3339Nano), op.Op, op.Path, op.Duration)
+		if op.Error != "" {
+			fmt.Fprintf(w, ", error: %s", op.Error)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// printBootTimeline writes the phases of the boot of a container in a human
+// readable table, sorted by start time with their offset from the first one.
+func printBootTimeline(w io.Writer, t boot.BootTimeline) {
+	t = t.Sorted()
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "Offset\tPhase\tIn\tDuration\t\n")
+	for _, p := range t {
+		in := "runsc"
+		if p.Sandbox {
+			in = "sandbox"
+		}
+		fmt.Fprintf(tw, "+%v\t%s\t%s\t%v\t\n", p.Start.Sub(t[0].Start), p.Name, in, p.Duration)
+	}
+	tw.Flush()
+}
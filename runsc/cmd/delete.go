@@ -18,6 +18,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/google/subcommands"
 	"gvisor.dev/gvisor/pkg/log"
@@ -30,6 +31,10 @@ import (
 type Delete struct {
 	// force indicates that the container should be terminated if running.
 	force bool
+
+	// keepLogs indicates that debug artifacts should be archived instead of
+	// being deleted with the container.
+	keepLogs bool
 }
 
 // Name implements subcommands.Command.Name.
@@ -50,6 +55,7 @@ func (*Delete) Usage() string {
 // SetFlags implements subcommands.Command.SetFlags.
 func (d *Delete) SetFlags(f *flag.FlagSet) {
 	f.BoolVar(&d.force, "force", false, "terminate container if running")
+	f.BoolVar(&d.keepLogs, "keep-logs", false, "move the container's debug logs, panic logs and final state to the directory set with --debug-archive, or <root>/archive if unset, instead of deleting them")
 }
 
 // Execute implements subcommands.Command.Execute.
@@ -79,9 +85,28 @@ func (d *Delete) execute(ids []string, conf *config.Config) error {
 		if !d.force && c.Status != container.Created && c.Status != container.Stopped {
 			return fmt.Errorf("cannot delete container that is not stopped without --force flag")
 		}
+		// Archive before destroying the container, while its sandbox, gofer
+		// and state still exist.
+		if dir := d.archiveDir(conf); dir != "" {
+			if _, err := c.ArchiveDebugArtifacts(dir); err != nil {
+				return fmt.Errorf("archiving debug artifacts: %v", err)
+			}
+		}
 		if err := c.Destroy(); err != nil {
 			return fmt.Errorf("destroying container: %v", err)
 		}
 	}
 	return nil
 }
+
+// archiveDir returns the directory where debug artifacts should be archived
+// to, or "" if they shouldn't be archived.
+func (d *Delete) archiveDir(conf *config.Config) string {
+	if conf.DebugArchive != "" {
+		return conf.DebugArchive
+	}
+	if d.keepLogs {
+		return filepath.Join(conf.RootDir, "archive")
+	}
+	return ""
+}
@@ -33,6 +33,7 @@ import (
 	"gvisor.dev/gvisor/pkg/sentry/control"
 	"gvisor.dev/gvisor/pkg/sentry/kernel/auth"
 	"gvisor.dev/gvisor/pkg/urpc"
+	"gvisor.dev/gvisor/runsc/boot"
 	"gvisor.dev/gvisor/runsc/config"
 	"gvisor.dev/gvisor/runsc/console"
 	"gvisor.dev/gvisor/runsc/container"
@@ -52,6 +53,7 @@ type Exec struct {
 	processPath     string
 	pidFile         string
 	internalPidFile string
+	execID          string
 
 	// consoleSocket is the path to an AF_UNIX socket which will receive a
 	// file descriptor referencing the master end of the console's
@@ -99,6 +101,7 @@ func (ex *Exec) SetFlags(f *flag.FlagSet) {
 	f.StringVar(&ex.processPath, "process", "", "path to the process.json")
 	f.StringVar(&ex.pidFile, "pid-file", "", "filename that the container pid will be written to")
 	f.StringVar(&ex.internalPidFile, "internal-pid-file", "", "filename that the container-internal pid will be written to")
+	f.StringVar(&ex.execID, "exec-id", "", "name of the exec session, which can be used to list and kill it later")
 	f.StringVar(&ex.consoleSocket, "console-socket", "", "path to an AF_UNIX socket which will receive a file descriptor referencing the master end of the console's pseudoterminal")
 }
 
@@ -117,6 +120,8 @@ func (ex *Exec) Execute(_ context.Context, f *flag.FlagSet, args ...interface{})
 		Fatalf("loading sandbox: %v", err)
 	}
 
+	e.ExecID = ex.execID
+
 	log.Debugf("Exec arguments: %+v", e)
 	log.Debugf("Exec capabilities: %+v", e.Capabilities)
 
@@ -369,6 +374,19 @@ func argsFromProcess(p *specs.Process, enableRaw bool) (*control.ExecArgs, error
 		extraKGIDs = append(extraKGIDs, auth.KGID(GID))
 	}
 
+	rlimits, err := boot.RlimitsFromSpec(p.Rlimits)
+	if err != nil {
+		return nil, fmt.Errorf("error creating rlimits: %v", err)
+	}
+
+	if len(p.SelinuxLabel) != 0 {
+		return nil, fmt.Errorf("SELinux is not supported: %s", p.SelinuxLabel)
+	}
+	// Docker uses AppArmor by default, so just log that it's being ignored.
+	if p.ApparmorProfile != "" {
+		log.Warningf("AppArmor profile %q is being ignored", p.ApparmorProfile)
+	}
+
 	return &control.ExecArgs{
 		Argv:             p.Args,
 		Envv:             p.Env,
@@ -377,6 +395,7 @@ func argsFromProcess(p *specs.Process, enableRaw bool) (*control.ExecArgs, error
 		KGID:             auth.KGID(p.User.GID),
 		ExtraKGIDs:       extraKGIDs,
 		Capabilities:     caps,
+		RLimits:          rlimits,
 		StdioIsPty:       p.Terminal,
 		FilePayload:      urpc.FilePayload{Files: []*os.File{os.Stdin, os.Stdout, os.Stderr}},
 	}, nil
@@ -24,6 +24,7 @@ import (
 	"gvisor.dev/gvisor/pkg/abi/linux"
 	"gvisor.dev/gvisor/pkg/sentry/control"
 	"gvisor.dev/gvisor/pkg/sentry/kernel/auth"
+	"gvisor.dev/gvisor/pkg/sentry/limits"
 	"gvisor.dev/gvisor/pkg/urpc"
 )
 
@@ -141,6 +142,32 @@ func TestJSONArgs(t *testing.T) {
 				},
 			},
 		},
+		{
+			p: specs.Process{
+				User:            specs.User{UID: 1000, GID: 1000, AdditionalGids: []uint32{10}},
+				Args:            []string{"sh"},
+				Env:             []string{"FOO=bar"},
+				Terminal:        true,
+				ApparmorProfile: "docker-default",
+				Rlimits: []specs.POSIXRlimit{
+					{Type: "RLIMIT_NOFILE", Hard: 1000, Soft: 100},
+					{Type: "RLIMIT_NPROC", Hard: 50, Soft: 10},
+				},
+			},
+			expected: control.ExecArgs{
+				Argv:        []string{"sh"},
+				Envv:        []string{"FOO=bar"},
+				FilePayload: urpc.FilePayload{Files: []*os.File{os.Stdin, os.Stdout, os.Stderr}},
+				KUID:        1000,
+				KGID:        1000,
+				ExtraKGIDs:  []auth.KGID{10},
+				RLimits: map[limits.LimitType]limits.Limit{
+					limits.NumberOfFiles: {Cur: 100, Max: 1000},
+					limits.ProcessCount:  {Cur: 10, Max: 50},
+				},
+				StdioIsPty: true,
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -152,3 +179,31 @@ func TestJSONArgs(t *testing.T) {
 		}
 	}
 }
+
+func TestJSONArgsErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		p    specs.Process
+	}{
+		{
+			name: "unknown rlimit",
+			p: specs.Process{
+				Args:    []string{"ls"},
+				Rlimits: []specs.POSIXRlimit{{Type: "RLIMIT_FOO", Hard: 1, Soft: 1}},
+			},
+		},
+		{
+			name: "selinux",
+			p: specs.Process{
+				Args:         []string{"ls"},
+				SelinuxLabel: "system_u:system_r:container_t:s0",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := argsFromProcess(&tc.p, true); err == nil {
+				t.Errorf("argsFromProcess(%+v): got no error, but wanted one", tc.p)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/subcommands"
+	"gvisor.dev/gvisor/runsc/config"
+	"gvisor.dev/gvisor/runsc/container"
+	"gvisor.dev/gvisor/runsc/flag"
+)
+
+// GC implements subcommands.Command for the "gc" command.
+type GC struct{}
+
+// Name implements subcommands.Command.Name.
+func (*GC) Name() string {
+	return "gc"
+}
+
+// Synopsis implements subcommands.Command.Synopsis.
+func (*GC) Synopsis() string {
+	return "destroy containers whose creation was interrupted"
+}
+
+// Usage implements subcommands.Command.Usage.
+func (*GC) Usage() string {
+	return `gc [flags] - destroy the containers left in the "creating" state by a runsc create that died, releasing the host mounts held by their gofer`
+}
+
+// SetFlags implements subcommands.Command.SetFlags.
+func (*GC) SetFlags(*flag.FlagSet) {}
+
+// Execute implements subcommands.Command.Execute.
+func (*GC) Execute(_ context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 0 {
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+
+	conf := args[0].(*config.Config)
+	destroyed, err := container.DestroyAbandoned(conf.RootDir)
+	for _, id := range destroyed {
+		fmt.Println(id.ContainerID)
+	}
+	if err != nil {
+		Fatalf("%v", err)
+	}
+	return subcommands.ExitSuccess
+}
@@ -25,8 +25,11 @@ import (
 	"github.com/google/subcommands"
 	specs "github.com/opencontainers/runtime-spec/specs-go"
 	"golang.org/x/sys/unix"
+	"gvisor.dev/gvisor/pkg/control/server"
 	"gvisor.dev/gvisor/pkg/log"
 	"gvisor.dev/gvisor/pkg/p9"
+	"gvisor.dev/gvisor/pkg/p9/faultinject"
+	"gvisor.dev/gvisor/pkg/p9/opstats"
 	"gvisor.dev/gvisor/pkg/sync"
 	"gvisor.dev/gvisor/pkg/unet"
 	"gvisor.dev/gvisor/runsc/config"
@@ -53,6 +56,37 @@ var goferCaps = &specs.LinuxCapabilities{
 	Permitted: caps,
 }
 
+// readonlyCaps are the capabilities needed by the Gofer when all the files it
+// serves are read-only. Capabilities to change the owner, mode and times of
+// files are dropped, so that a compromised sandbox can't use the Gofer to
+// modify read-only volumes.
+var readonlyCaps = []string{
+	"CAP_DAC_OVERRIDE",
+	"CAP_DAC_READ_SEARCH",
+	"CAP_SYS_CHROOT",
+}
+
+// goferReadonlyCaps is the set of capabilities of a Gofer serving read-only
+// mounts only.
+var goferReadonlyCaps = &specs.LinuxCapabilities{
+	Bounding:  readonlyCaps,
+	Effective: readonlyCaps,
+	Permitted: readonlyCaps,
+}
+
+// overlayUpperCaps are the capabilities needed by the Gofer when it serves the
+// directory backing the root overlay. Creating whiteouts requires CAP_MKNOD
+// before Linux 5.8.
+var overlayUpperCaps = append(append([]string(nil), caps...), "CAP_MKNOD")
+
+// goferOverlayUpperCaps is the set of capabilities of a Gofer serving the
+// directory backing the root overlay.
+var goferOverlayUpperCaps = &specs.LinuxCapabilities{
+	Bounding:  overlayUpperCaps,
+	Effective: overlayUpperCaps,
+	Permitted: overlayUpperCaps,
+}
+
 // Gofer implements subcommands.Command for the "gofer" command, which starts a
 // filesystem gofer.  This command should not be called directly.
 type Gofer struct {
@@ -61,8 +95,26 @@ type Gofer struct {
 	applyCaps bool
 	setUpRoot bool
 
-	specFD   int
-	mountsFD int
+	specFD    int
+	mountsFD  int
+	controlFD int
+
+	// faultsDirFD is the directory of the fault injection rules file, when
+	// faults are injected in tests. See config.TestOnlyGoferFaults.
+	faultsDirFD int
+
+	// overlayUpperDir is the host directory backing the root overlay of the
+	// container, or empty if it's backed by memory. See
+	// specutils.OverlayUpperDir.
+	overlayUpperDir string
+
+	// overlayUpperFD is the directory backing the root overlay, when it's
+	// backed by a host directory.
+	overlayUpperFD int
+
+	// throttleDisks maps the devices of the mounts to their disks for blkio
+	// limits, as formatted by fsgofer.FormatDisks. See fsgofer.MountDisks.
+	throttleDisks string
 }
 
 // Name implements subcommands.Command.
@@ -88,6 +140,11 @@ func (g *Gofer) SetFlags(f *flag.FlagSet) {
 	f.BoolVar(&g.setUpRoot, "setup-root", true, "if true, set up an empty root for the process")
 	f.IntVar(&g.specFD, "spec-fd", -1, "required fd with the container spec")
 	f.IntVar(&g.mountsFD, "mounts-fd", -1, "mountsFD is the file descriptor to write list of mounts after they have been resolved (direct paths, no symlinks).")
+	f.IntVar(&g.controlFD, "control-fd", -1, "fd of a stream socket for the control server of the gofer, returning operation statistics")
+	f.IntVar(&g.faultsDirFD, "faults-dir-fd", -1, "TEST ONLY: fd of the directory of the fault injection rules file, opened before the root is changed")
+	f.StringVar(&g.overlayUpperDir, "overlay-upper-path", "", "host directory backing the root overlay of the container, created if it doesn't exist")
+	f.IntVar(&g.overlayUpperFD, "overlay-upper-fd", -1, "fd of the directory backing the root overlay, opened before the root is changed")
+	f.StringVar(&g.throttleDisks, "throttle-disks", "", "disks of the devices of the mounts for blkio limits, found before the root is changed")
 }
 
 // Execute implements subcommands.Command.
@@ -106,6 +163,41 @@ func (g *Gofer) Execute(_ context.Context, f *flag.FlagSet, args ...interface{})
 		Fatalf("reading spec: %v", err)
 	}
 
+	// The fault injection rules file must be found after the root changes, so
+	// open its directory now. The FD is inherited when calling myself again.
+	if conf.TestOnlyGoferFaults != "" && g.faultsDirFD < 0 {
+		fd, err := unix.Open(filepath.Dir(conf.TestOnlyGoferFaults), unix.O_PATH|unix.O_DIRECTORY, 0)
+		if err != nil {
+			Fatalf("opening fault injection rules directory: %v", err)
+		}
+		g.faultsDirFD = fd
+	}
+
+	// The directory backing the root overlay is outside of the root, so open
+	// it now too.
+	if dir := g.overlayUpperDir; dir != "" && g.overlayUpperFD < 0 {
+		// The sandbox sets the owner and mode of the directory to the ones of
+		// the root.
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			Fatalf("creating overlay upper directory: %v", err)
+		}
+		fd, err := unix.Open(dir, unix.O_PATH|unix.O_DIRECTORY, 0)
+		if err != nil {
+			Fatalf("opening overlay upper directory: %v", err)
+		}
+		g.overlayUpperFD = fd
+	}
+
+	// The disks of the mounts are found using /sys, which isn't available
+	// once the root is changed.
+	if g.setUpRoot && hasThrottleLimits(spec) {
+		disks, err := fsgofer.MountDisks()
+		if err != nil {
+			log.Warningf("Finding the disks of mounts failed, only devices named by blkio limits are throttled: %v", err)
+		}
+		g.throttleDisks = fsgofer.FormatDisks(disks)
+	}
+
 	if g.setUpRoot {
 		if err := setupRootFS(spec, conf); err != nil {
 			Fatalf("Error setting up root FS: %v", err)
@@ -116,7 +208,23 @@ func (g *Gofer) Execute(_ context.Context, f *flag.FlagSet, args ...interface{})
 		// Note: minimal argument handling for the default case to keep it simple.
 		args := os.Args
 		args = append(args, "--apply-caps=false", "--setup-root=false")
-		Fatalf("setCapsAndCallSelf(%v, %v): %v", args, goferCaps, setCapsAndCallSelf(args, goferCaps))
+		if g.faultsDirFD >= 0 {
+			args = append(args, fmt.Sprintf("--faults-dir-fd=%d", g.faultsDirFD))
+		}
+		if g.overlayUpperFD >= 0 {
+			args = append(args, fmt.Sprintf("--overlay-upper-fd=%d", g.overlayUpperFD))
+		}
+		if g.throttleDisks != "" {
+			args = append(args, "--throttle-disks="+g.throttleDisks)
+		}
+		caps := goferCaps
+		if isReadonlyGofer(spec, conf, g.overlayUpperDir) {
+			log.Infof("All mounts are read-only, dropping capabilities to modify files")
+			caps = goferReadonlyCaps
+		} else if g.overlayUpperFD >= 0 {
+			caps = goferOverlayUpperCaps
+		}
+		Fatalf("setCapsAndCallSelf(%v, %v): %v", args, caps, setCapsAndCallSelf(args, caps))
 		panic("unreachable")
 	}
 
@@ -160,12 +268,30 @@ func (g *Gofer) Execute(_ context.Context, f *flag.FlagSet, args ...interface{})
 	}
 	log.Infof("Process chroot'd to %q", root)
 
+	// The control server returns the statistics of the operations served
+	// below, see runsc debug --gofer-stats.
+	recorder := opstats.NewRecorder(conf.GoferSlowOp)
+	if g.controlFD >= 0 {
+		ctrl, err := server.CreateFromFD(g.controlFD)
+		if err != nil {
+			Fatalf("creating control server: %v", err)
+		}
+		ctrl.Register(&fsgofer.Control{Recorder: recorder})
+		if err := ctrl.StartServing(); err != nil {
+			Fatalf("starting control server: %v", err)
+		}
+	}
+
 	// Initialize filters.
+	if g.controlFD >= 0 {
+		filter.InstallControlServerFilters(g.controlFD)
+	}
+
 	if conf.FSGoferHostUDS {
 		filter.InstallUDSFilters()
 	}
 
-	if conf.Verity {
+	if conf.Verity || g.overlayUpperFD >= 0 {
 		filter.InstallXattrFilters()
 	}
 
@@ -174,9 +300,44 @@ func (g *Gofer) Execute(_ context.Context, f *flag.FlagSet, args ...interface{})
 	}
 
 	if conf.Lisafs {
+		if conf.TestOnlyGoferFaults != "" {
+			log.Warningf("Fault injection is only supported with 9P, no faults will be injected")
+		}
+		log.Infof("Operation statistics are only supported with 9P, none will be recorded")
 		return g.serveLisafs(spec, conf, root)
 	}
-	return g.serve9P(spec, conf, root)
+	return g.serve9P(spec, conf, root, recorder)
+}
+
+// hasThrottleLimits returns true if spec has blkio limits, which are enforced
+// by ioThrottle.
+func hasThrottleLimits(spec *specs.Spec) bool {
+	return spec.Linux != nil && spec.Linux.Resources != nil && spec.Linux.Resources.BlockIO != nil
+}
+
+// ioThrottle returns the throttle enforcing the blkio limits of the container
+// on the I/O served by the gofer, which the blkio cgroup of the container
+// can't see. disks maps the devices of the mounts to their disks, see
+// fsgofer.MountDisks. It returns nil if there are none.
+func ioThrottle(spec *specs.Spec, disks map[uint64]uint64) *fsgofer.Throttle {
+	if !hasThrottleLimits(spec) {
+		return nil
+	}
+	t := fsgofer.NewThrottle(spec.Linux.Resources.BlockIO, disks)
+	if t != nil {
+		log.Infof("Throttling I/O with blkio limits: %+v, disks: %v", spec.Linux.Resources.BlockIO, disks)
+	}
+	return t
+}
+
+// throttle returns the throttle enforcing the blkio limits of the container,
+// with the disks found before the root changed.
+func (g *Gofer) throttle(spec *specs.Spec) *fsgofer.Throttle {
+	disks, err := fsgofer.ParseDisks(g.throttleDisks)
+	if err != nil {
+		Fatalf("parsing --throttle-disks: %v", err)
+	}
+	return ioThrottle(spec, disks)
 }
 
 func newSocket(ioFD int) *unet.Socket {
@@ -193,11 +354,13 @@ func (g *Gofer) serveLisafs(spec *specs.Spec, conf *config.Config, root string)
 		readonly bool
 	}
 	cfgs := make([]connectionConfig, 0, len(spec.Mounts)+1)
+	throttle := g.throttle(spec)
 	server := fsgofer.NewLisafsServer(fsgofer.Config{
 		// These are global options. Ignore readonly configuration, that is set on
 		// a per connection basis.
 		HostUDS:           conf.FSGoferHostUDS,
 		EnableVerityXattr: conf.Verity,
+		Throttle:          throttle,
 	})
 
 	// Start with root mount, then add any other additional mount as needed.
@@ -246,20 +409,30 @@ func (g *Gofer) serveLisafs(spec *specs.Spec, conf *config.Config, root string)
 	return subcommands.ExitSuccess
 }
 
-func (g *Gofer) serve9P(spec *specs.Spec, conf *config.Config, root string) subcommands.ExitStatus {
+func (g *Gofer) serve9P(spec *specs.Spec, conf *config.Config, root string, recorder *opstats.Recorder) subcommands.ExitStatus {
 	// Start with root mount, then add any other additional mount as needed.
 	ats := make([]p9.Attacher, 0, len(spec.Mounts)+1)
+	dests := make([]string, 0, len(spec.Mounts)+1)
+	throttle := g.throttle(spec)
 	ap, err := fsgofer.NewAttachPoint("/", fsgofer.Config{
 		ROMount:           spec.Root.Readonly || conf.Overlay,
 		HostUDS:           conf.FSGoferHostUDS,
 		EnableVerityXattr: conf.Verity,
+		Throttle:          throttle,
 	})
 	if err != nil {
 		Fatalf("creating attach point: %v", err)
 	}
 	ats = append(ats, ap)
+	dests = append(dests, "/")
 	log.Infof("Serving %q mapped to %q on FD %d (ro: %t)", "/", root, g.ioFDs[0], spec.Root.Readonly)
 
+	// Each mount has one FD per channel, see config.Config.GoferChannels.
+	channels := 1
+	if conf.GoferChannels > 1 {
+		channels = conf.GoferChannels
+	}
+
 	mountIdx := 1 // first one is the root
 	for _, m := range spec.Mounts {
 		if specutils.IsGoferMount(m, conf.VFS2) {
@@ -267,45 +440,177 @@ func (g *Gofer) serve9P(spec *specs.Spec, conf *config.Config, root string) subc
 				ROMount:           isReadonlyMount(m.Options) || conf.Overlay,
 				HostUDS:           conf.FSGoferHostUDS,
 				EnableVerityXattr: conf.Verity,
+				Throttle:          throttle,
 			}
 			ap, err := fsgofer.NewAttachPoint(m.Destination, cfg)
 			if err != nil {
 				Fatalf("creating attach point: %v", err)
 			}
 			ats = append(ats, ap)
+			dests = append(dests, m.Destination)
 
-			if mountIdx >= len(g.ioFDs) {
+			if (mountIdx+1)*channels > len(g.ioFDs) {
 				Fatalf("no FD found for mount. Did you forget --io-fd? mount: %d, %v", len(g.ioFDs), m)
 			}
-			log.Infof("Serving %q mapped on FD %d (ro: %t)", m.Destination, g.ioFDs[mountIdx], cfg.ROMount)
+			log.Infof("Serving %q mapped on FD %d (ro: %t)", m.Destination, g.ioFDs[mountIdx*channels], cfg.ROMount)
 			mountIdx++
 		}
 	}
-	if mountIdx != len(g.ioFDs) {
-		Fatalf("too many FDs passed for mounts. mounts: %d, FDs: %d", mountIdx, len(g.ioFDs))
+	if g.overlayUpperFD >= 0 {
+		ap, err := fsgofer.NewAttachPointFD(g.overlayUpperDir, g.overlayUpperFD, fsgofer.Config{
+			OverlayUpper: true,
+			Throttle:     throttle,
+		})
+		if err != nil {
+			Fatalf("creating attach point: %v", err)
+		}
+		ats = append(ats, ap)
+		dests = append(dests, g.overlayUpperDir)
+
+		if (mountIdx+1)*channels > len(g.ioFDs) {
+			Fatalf("no FD found for the overlay upper directory. Did you forget --io-fd? mount: %d", len(g.ioFDs))
+		}
+		log.Infof("Serving overlay upper directory %q on FD %d", g.overlayUpperDir, g.ioFDs[mountIdx*channels])
+		mountIdx++
+	}
+	if mountIdx*channels != len(g.ioFDs) {
+		Fatalf("too many FDs passed for mounts. mounts: %d, channels: %d, FDs: %d", mountIdx, channels, len(g.ioFDs))
+	}
+
+	if g.faultsDirFD >= 0 {
+		log.Warningf("Injecting faults from %q, this must only be used in tests", conf.TestOnlyGoferFaults)
+		injector := faultinject.NewDirInjector(g.faultsDirFD, filepath.Base(conf.TestOnlyGoferFaults))
+		for i, at := range ats {
+			ats[i] = faultinject.NewAttacher(at, injector)
+		}
+	}
+	for i, at := range ats {
+		ats[i] = opstats.NewAttacher(at, recorder, dests[i])
 	}
 
 	// Run the loops and wait for all to exit.
 	var wg sync.WaitGroup
-	for i, ioFD := range g.ioFDs {
+	for i, at := range ats {
 		wg.Add(1)
-		go func(ioFD int, at p9.Attacher) {
-			socket, err := unet.NewSocket(ioFD)
-			if err != nil {
-				Fatalf("creating server on FD %d: %v", ioFD, err)
+		go func(ioFDs []int, at p9.Attacher) {
+			sockets := make([]*unet.Socket, 0, len(ioFDs))
+			for _, ioFD := range ioFDs {
+				socket, err := unet.NewSocket(ioFD)
+				if err != nil {
+					Fatalf("creating server on FD %d: %v", ioFD, err)
+				}
+				sockets = append(sockets, socket)
 			}
 			s := p9.NewServer(at)
-			if err := s.Handle(socket); err != nil {
-				Fatalf("P9 server returned error. Gofer is shutting down. FD: %d, err: %v", ioFD, err)
+			if err := s.HandleGroup(sockets); err != nil {
+				Fatalf("P9 server returned error. Gofer is shutting down. FDs: %v, err: %v", ioFDs, err)
 			}
 			wg.Done()
-		}(ioFD, ats[i])
+		}(g.ioFDs[i*channels:(i+1)*channels], at)
 	}
 	wg.Wait()
 	log.Infof("All 9P servers exited.")
 	return subcommands.ExitSuccess
 }
 
+// serveInProcess serves the root and the gofer mounts of spec from the current
+// process, for --TESTONLY-in-process-gofer. It returns the FDs connected to the
+// servers, in the order expected by boot.Args.GoferFDs.
+//
+// Unlike the gofer process, it serves host paths directly: there is no
+// separate root, mount namespace or syscall filters.
+func serveInProcess(spec *specs.Spec, conf *config.Config, bundleDir, id string) ([]int, error) {
+	type attachPoint struct {
+		path string
+		cfg  fsgofer.Config
+	}
+	var disks map[uint64]uint64
+	if hasThrottleLimits(spec) {
+		var err error
+		if disks, err = fsgofer.MountDisks(); err != nil {
+			log.Warningf("Finding the disks of mounts failed, only devices named by blkio limits are throttled: %v", err)
+		}
+	}
+	throttle := ioThrottle(spec, disks)
+	aps := []attachPoint{
+		{
+			path: spec.Root.Path,
+			cfg: fsgofer.Config{
+				ROMount:           spec.Root.Readonly || conf.Overlay,
+				HostUDS:           conf.FSGoferHostUDS,
+				EnableVerityXattr: conf.Verity,
+				Throttle:          throttle,
+			},
+		},
+	}
+	for _, m := range spec.Mounts {
+		if !specutils.IsGoferMount(m, conf.VFS2) {
+			continue
+		}
+		src := m.Source
+		if !filepath.IsAbs(src) {
+			src = filepath.Join(bundleDir, src)
+		}
+		aps = append(aps, attachPoint{
+			path: src,
+			cfg: fsgofer.Config{
+				ROMount:           isReadonlyMount(m.Options) || conf.Overlay,
+				HostUDS:           conf.FSGoferHostUDS,
+				EnableVerityXattr: conf.Verity,
+				Throttle:          throttle,
+			},
+		})
+	}
+	if dir := specutils.OverlayUpperDir(spec, conf, id); dir != "" {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, err
+		}
+		aps = append(aps, attachPoint{
+			path: dir,
+			cfg: fsgofer.Config{
+				OverlayUpper: true,
+				Throttle:     throttle,
+			},
+		})
+	}
+
+	// Each mount has one FD per channel, see config.Config.GoferChannels.
+	channels := 1
+	if conf.GoferChannels > 1 {
+		channels = conf.GoferChannels
+	}
+
+	var ioFDs []int
+	for _, ap := range aps {
+		at, err := fsgofer.NewAttachPoint(ap.path, ap.cfg)
+		if err != nil {
+			return nil, fmt.Errorf("creating attach point for %q: %v", ap.path, err)
+		}
+		sockets := make([]*unet.Socket, 0, channels)
+		for i := 0; i < channels; i++ {
+			fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM|unix.SOCK_CLOEXEC, 0)
+			if err != nil {
+				return nil, err
+			}
+			socket, err := unet.NewSocket(fds[1])
+			if err != nil {
+				unix.Close(fds[0])
+				unix.Close(fds[1])
+				return nil, err
+			}
+			sockets = append(sockets, socket)
+			ioFDs = append(ioFDs, fds[0])
+		}
+		log.Infof("Serving %q in process (ro: %t)", ap.path, ap.cfg.ROMount)
+		go func(sockets []*unet.Socket, at p9.Attacher) { // S/R-SAFE: not relevant.
+			if err := p9.NewServer(at).HandleGroup(sockets); err != nil {
+				log.Warningf("In process P9 server returned error: %v", err)
+			}
+		}(sockets, at)
+	}
+	return ioFDs, nil
+}
+
 func (g *Gofer) writeMounts(mounts []specs.Mount) error {
 	bytes, err := json.Marshal(mounts)
 	if err != nil {
@@ -334,6 +639,26 @@ func isReadonlyMount(opts []string) bool {
 	return false
 }
 
+// isReadonlyGofer returns true if all the mounts served by the Gofer are
+// read-only. overlayUpperDir is the directory backing the root overlay, if
+// any.
+func isReadonlyGofer(spec *specs.Spec, conf *config.Config, overlayUpperDir string) bool {
+	if conf.Overlay {
+		// Writes are never sent to the Gofer, except to the directory backing
+		// the root overlay.
+		return overlayUpperDir == ""
+	}
+	if !spec.Root.Readonly {
+		return false
+	}
+	for _, m := range spec.Mounts {
+		if specutils.IsGoferMount(m, conf.VFS2) && !isReadonlyMount(m.Options) {
+			return false
+		}
+	}
+	return true
+}
+
 func setupRootFS(spec *specs.Spec, conf *config.Config) error {
 	// Convert all shared mounts into slaves to be sure that nothing will be
 	// propagated outside of our namespace.
@@ -433,6 +758,11 @@ func setupRootFS(spec *specs.Spec, conf *config.Config) error {
 // location inside root. It will resolve relative paths and symlinks. It also
 // creates directories as needed.
 func setupMounts(conf *config.Config, mounts []specs.Mount, root, procPath string) error {
+	type readonlyMount struct {
+		dst   string
+		flags uint32
+	}
+	var readonly []readonlyMount
 	for _, m := range mounts {
 		if !specutils.IsGoferMount(m, conf.VFS2) {
 			continue
@@ -453,6 +783,9 @@ func setupMounts(conf *config.Config, mounts []specs.Mount, root, procPath strin
 		if err := specutils.SafeSetupAndMount(m.Source, dst, m.Type, flags, procPath); err != nil {
 			return fmt.Errorf("mounting %+v: %v", m, err)
 		}
+		if flags&unix.MS_RDONLY != 0 {
+			readonly = append(readonly, readonlyMount{dst: dst, flags: flags})
+		}
 
 		// Set propagation options that cannot be set together with other options.
 		flags = specutils.PropOptionsToFlags(m.Options)
@@ -462,6 +795,18 @@ func setupMounts(conf *config.Config, mounts []specs.Mount, root, procPath strin
 			}
 		}
 	}
+
+	// Bind mounts ignore MS_RDONLY until they are remounted. Read-only mounts
+	// are remounted once all mounts are set up, since mount points of the
+	// following mounts may need to be created in them. This makes the host
+	// reject writes to read-only mounts even if the Gofer is compromised.
+	for _, m := range readonly {
+		flags := uintptr(m.flags | unix.MS_REMOUNT)
+		log.Infof("Remounting %q as read-only, flags: %#x", m.dst, flags)
+		if err := specutils.SafeMount(m.dst, m.dst, "bind", flags, "", procPath); err != nil {
+			return fmt.Errorf("remounting %q as read-only, flags: %#x, err: %v", m.dst, flags, err)
+		}
+	}
 	return nil
 }
 
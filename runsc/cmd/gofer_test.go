@@ -21,6 +21,9 @@ import (
 	"path"
 	"path/filepath"
 	"testing"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"gvisor.dev/gvisor/runsc/config"
 )
 
 func tmpDir() string {
@@ -161,3 +164,65 @@ func TestResolveSymlinksLoop(t *testing.T) {
 		t.Errorf("resolveSymlinks() should have failed")
 	}
 }
+
+func TestIsReadonlyGofer(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		root     bool
+		overlay  bool
+		upperDir string
+		mounts   []specs.Mount
+		want     bool
+	}{
+		{
+			name: "rw-root",
+			want: false,
+		},
+		{
+			name: "ro-root",
+			root: true,
+			want: true,
+		},
+		{
+			name:    "overlay",
+			overlay: true,
+			mounts:  []specs.Mount{{Destination: "/data", Source: "/data", Type: "bind"}},
+			want:    true,
+		},
+		{
+			name:     "overlay-upper-dir",
+			overlay:  true,
+			upperDir: "/upper/id",
+			want:     false,
+		},
+		{
+			name:   "ro-mount",
+			root:   true,
+			mounts: []specs.Mount{{Destination: "/data", Source: "/data", Type: "bind", Options: []string{"ro"}}},
+			want:   true,
+		},
+		{
+			name:   "rw-mount",
+			root:   true,
+			mounts: []specs.Mount{{Destination: "/data", Source: "/data", Type: "bind", Options: []string{"rw"}}},
+			want:   false,
+		},
+		{
+			name:   "rw-tmpfs",
+			root:   true,
+			mounts: []specs.Mount{{Destination: "/tmp", Type: "tmpfs"}},
+			want:   true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			spec := &specs.Spec{
+				Root:   &specs.Root{Readonly: tc.root},
+				Mounts: tc.mounts,
+			}
+			conf := &config.Config{Overlay: tc.overlay, VFS2: true}
+			if got := isReadonlyGofer(spec, conf, tc.upperDir); got != tc.want {
+				t.Errorf("isReadonlyGofer() = %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
@@ -29,8 +29,10 @@ import (
 
 // Kill implements subcommands.Command for the "kill" command.
 type Kill struct {
-	all bool
-	pid int
+	all          bool
+	pid          int
+	containerPID int
+	execID       string
 }
 
 // Name implements subcommands.Command.Name.
@@ -52,6 +54,8 @@ func (*Kill) Usage() string {
 func (k *Kill) SetFlags(f *flag.FlagSet) {
 	f.BoolVar(&k.all, "all", false, "send the specified signal to all processes inside the container")
 	f.IntVar(&k.pid, "pid", 0, "send the specified signal to a specific process. pid is relative to the root PID namespace")
+	f.IntVar(&k.containerPID, "container-pid", 0, "send the specified signal to a specific process. pid is relative to the container's PID namespace")
+	f.StringVar(&k.execID, "exec-id", "", "send the specified signal to the process of the named exec session")
 }
 
 // Execute implements subcommands.Command.Execute.
@@ -64,8 +68,14 @@ func (k *Kill) Execute(_ context.Context, f *flag.FlagSet, args ...interface{})
 	id := f.Arg(0)
 	conf := args[0].(*config.Config)
 
-	if k.pid != 0 && k.all {
-		Fatalf("it is invalid to specify both --all and --pid")
+	if k.pid != 0 && k.containerPID != 0 {
+		Fatalf("it is invalid to specify both --pid and --container-pid")
+	}
+	if (k.pid != 0 || k.containerPID != 0) && k.all {
+		Fatalf("it is invalid to specify --all with --pid or --container-pid")
+	}
+	if k.execID != "" && (k.pid != 0 || k.containerPID != 0 || k.all) {
+		Fatalf("it is invalid to specify --exec-id with --all, --pid or --container-pid")
 	}
 
 	c, err := container.Load(conf.RootDir, container.FullID{ContainerID: id}, container.LoadOpts{})
@@ -90,6 +100,14 @@ func (k *Kill) Execute(_ context.Context, f *flag.FlagSet, args ...interface{})
 		if err := c.SignalProcess(sig, int32(k.pid)); err != nil {
 			Fatalf("failed to signal pid %d: %v", k.pid, err)
 		}
+	} else if k.containerPID != 0 {
+		if err := c.SignalPID(sig, int32(k.containerPID)); err != nil {
+			Fatalf("failed to signal container pid %d: %v", k.containerPID, err)
+		}
+	} else if k.execID != "" {
+		if err := c.KillExecSession(k.execID, sig); err != nil {
+			Fatalf("failed to signal exec session %q: %v", k.execID, err)
+		}
 	} else {
 		if err := c.SignalContainer(sig, k.all); err != nil {
 			Fatalf("%v", err)
@@ -24,7 +24,6 @@ import (
 
 	"github.com/google/subcommands"
 	specs "github.com/opencontainers/runtime-spec/specs-go"
-	"gvisor.dev/gvisor/pkg/log"
 	"gvisor.dev/gvisor/runsc/config"
 	"gvisor.dev/gvisor/runsc/container"
 	"gvisor.dev/gvisor/runsc/flag"
@@ -34,6 +33,7 @@ import (
 type List struct {
 	quiet  bool
 	format string
+	index  bool
 }
 
 // Name implements subcommands.command.name.
@@ -55,6 +55,7 @@ func (*List) Usage() string {
 func (l *List) SetFlags(f *flag.FlagSet) {
 	f.BoolVar(&l.quiet, "quiet", false, "only list container ids")
 	f.StringVar(&l.format, "format", "text", "output format: 'text' (default) or 'json'")
+	f.BoolVar(&l.index, "index", false, "caches stopped containers in an index file shared by runsc commands using the root directory, to list them without loading their metadata files")
 }
 
 // Execute implements subcommands.Command.Execute.
@@ -65,12 +66,11 @@ func (l *List) Execute(_ context.Context, f *flag.FlagSet, args ...interface{})
 	}
 
 	conf := args[0].(*config.Config)
-	ids, err := container.List(conf.RootDir)
-	if err != nil {
-		Fatalf("%v", err)
-	}
-
 	if l.quiet {
+		ids, err := container.List(conf.RootDir)
+		if err != nil {
+			Fatalf("%v", err)
+		}
 		for _, id := range ids {
 			fmt.Println(id.ContainerID)
 		}
@@ -78,14 +78,9 @@ func (l *List) Execute(_ context.Context, f *flag.FlagSet, args ...interface{})
 	}
 
 	// Collect the containers.
-	var containers []*container.Container
-	for _, id := range ids {
-		c, err := container.Load(conf.RootDir, id, container.LoadOpts{Exact: true})
-		if err != nil {
-			log.Warningf("Skipping container %q: %v", id, err)
-			continue
-		}
-		containers = append(containers, c)
+	containers, err := container.LoadAll(conf.RootDir, container.LoadAllOpts{Index: l.index})
+	if err != nil {
+		Fatalf("%v", err)
 	}
 
 	switch l.format {
@@ -0,0 +1,827 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/subcommands"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"golang.org/x/sys/unix"
+	"gvisor.dev/gvisor/pkg/cleanup"
+	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/pkg/state/statefile"
+	"gvisor.dev/gvisor/runsc/config"
+	"gvisor.dev/gvisor/runsc/container"
+	"gvisor.dev/gvisor/runsc/flag"
+	"gvisor.dev/gvisor/runsc/sandbox"
+	"gvisor.dev/gvisor/runsc/specutils"
+)
+
+// migrateVersion is the version of the protocol between the source and the
+// destination of a migration.
+const migrateVersion = 1
+
+// Migrate implements subcommands.Command for the "migrate" command.
+type Migrate struct {
+	// Source flags.
+	imagePath       string
+	remoteRunsc     string
+	remoteBundleDir string
+	compression     string
+	dedup           bool
+
+	// Destination flags.
+	receive   bool
+	listen    string
+	bundleDir string
+	output    string
+
+	// TLS flags, required with the tcp transport.
+	tlsCert string
+	tlsKey  string
+	tlsCA   string
+}
+
+// Name implements subcommands.Command.Name.
+func (*Migrate) Name() string {
+	return "migrate"
+}
+
+// Synopsis implements subcommands.Command.Synopsis.
+func (*Migrate) Synopsis() string {
+	return "move a running container to another host (experimental)"
+}
+
+// Usage implements subcommands.Command.Usage.
+func (*Migrate) Usage() string {
+	return `migrate [flags] <container id> <destination>
+migrate --receive [flags]
+
+Moves a running container to a destination running "runsc migrate --receive",
+which restores it under the same ID. <destination> is either:
+
+  tcp://<host>:<port>    a destination listening with --listen=<host>:<port>.
+                         Both sides authenticate each other with TLS, using
+                         --tls-cert, --tls-key and --tls-ca.
+  ssh://[<user>@]<host>  runs the destination with ssh, using --remote-runsc
+                         and --remote-bundle.
+
+The destination creates the container while the source is still running, in
+the bundle directory given by --bundle, which is required with --receive. The
+spec is sent by the source. The destination rejects containers with OCI hooks.
+Bind mount sources must be at the same paths on the destination. The received
+container reads from /dev/null, and its output is appended to --output, or
+discarded.
+
+The container is then checkpointed, and its image is streamed to the
+destination while it's saved, and restored there once received. Memory is
+transferred while the container is stopped, so the downtime grows with the
+memory of the container. The image is also saved to --image-path on the
+source, where the container is restored if the migration fails.
+
+`
+}
+
+// SetFlags implements subcommands.Command.SetFlags.
+func (m *Migrate) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&m.imagePath, "image-path", "", "directory path to save the image to, used to restore the container if the migration fails. A temporary directory, removed once migrated, by default")
+	f.StringVar(&m.remoteRunsc, "remote-runsc", "runsc", "command running runsc on the destination with ssh, including its flags")
+	f.StringVar(&m.remoteBundleDir, "remote-bundle", "", "with the ssh transport, directory to write the bundle of the container to on the destination. Required")
+	f.StringVar(&m.compression, "compression", string(statefile.CompressionFlate), "compression algorithm of the image: flate or zstd")
+	f.BoolVar(&m.dedup, "dedup", false, "save identical memory pages once")
+	f.BoolVar(&m.receive, "receive", false, "receive a container, rather than migrating one")
+	f.StringVar(&m.listen, "listen", "", "with --receive, address to accept the source on, or stdin and stdout if empty")
+	f.StringVar(&m.bundleDir, "bundle", "", "with --receive, directory to write the bundle of the container to. Required")
+	f.StringVar(&m.output, "output", "", "with --receive, file to append the output of the container to, or discarded if empty")
+	f.StringVar(&m.tlsCert, "tls-cert", "", "with the tcp transport, path to the certificate presented to the other side")
+	f.StringVar(&m.tlsKey, "tls-key", "", "with the tcp transport, path to the private key of --tls-cert")
+	f.StringVar(&m.tlsCA, "tls-ca", "", "with the tcp transport, path to the CA certificates that the certificate of the other side must be signed by")
+}
+
+// Execute implements subcommands.Command.Execute.
+func (m *Migrate) Execute(_ context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	conf := args[0].(*config.Config)
+	if conf.Rootless {
+		return Errorf("Rootless mode not supported with %q", m.Name())
+	}
+
+	if m.receive {
+		if f.NArg() != 0 {
+			f.Usage()
+			return subcommands.ExitUsageError
+		}
+		if m.bundleDir == "" {
+			return Errorf("--bundle is required with --receive")
+		}
+		if err := m.receive(conf); err != nil {
+			return Errorf("%v", err)
+		}
+		return subcommands.ExitSuccess
+	}
+
+	if f.NArg() != 2 {
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+	switch statefile.Compression(m.compression) {
+	case statefile.CompressionFlate, statefile.CompressionZstd:
+	default:
+		return Errorf("invalid compression %q, must be flate or zstd", m.compression)
+	}
+	if err := m.migrate(conf, f.Arg(0), f.Arg(1)); err != nil {
+		return Errorf("%v", err)
+	}
+	return subcommands.ExitSuccess
+}
+
+// migrateHeader is sent by the source to start a migration.
+type migrateHeader struct {
+	Version int         `json:"version"`
+	ID      string      `json:"id"`
+	Spec    *specs.Spec `json:"spec"`
+}
+
+// migrateReply is sent by the destination once the container is created, and
+// once it's restored.
+type migrateReply struct {
+	Error string `json:"error,omitempty"`
+}
+
+// migrateConn is a connection between the source and the destination.
+// Messages are JSON lines, followed by the image in chunks.
+type migrateConn struct {
+	r *bufio.Reader
+	w io.Writer
+
+	// close closes the connection, and waits for the transport to exit.
+	close func() error
+}
+
+func (c *migrateConn) send(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = c.w.Write(append(b, '\n'))
+	return err
+}
+
+func (c *migrateConn) recv(v interface{}) error {
+	line, err := c.r.ReadBytes('\n')
+	if err != nil {
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	return json.Unmarshal(line, v)
+}
+
+// recvReply receives a reply, returning the error reported by the destination
+// separately from the errors receiving it.
+func (c *migrateConn) recvReply() (remoteErr error, err error) {
+	var reply migrateReply
+	if err := c.recv(&reply); err != nil {
+		return nil, err
+	}
+	if reply.Error != "" {
+		return errors.New(reply.Error), nil
+	}
+	return nil, nil
+}
+
+// Close closes the connection.
+func (c *migrateConn) Close() error {
+	return c.close()
+}
+
+// parseMigrateDestination returns the transport, "tcp" or "ssh", and the
+// address of a migration destination.
+func parseMigrateDestination(dest string) (string, string, error) {
+	parts := strings.SplitN(dest, "://", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid destination %q, must be tcp://<host>:<port> or ssh://[<user>@]<host>", dest)
+	}
+	switch parts[0] {
+	case "tcp":
+		if _, _, err := net.SplitHostPort(parts[1]); err != nil {
+			return "", "", fmt.Errorf("invalid destination %q: %v", dest, err)
+		}
+	case "ssh":
+		if strings.ContainsAny(parts[1], "/ ") {
+			return "", "", fmt.Errorf("invalid destination %q, must be ssh://[<user>@]<host>", dest)
+		}
+	default:
+		return "", "", fmt.Errorf("invalid destination %q, transport must be tcp or ssh", dest)
+	}
+	return parts[0], parts[1], nil
+}
+
+// tlsConfig returns the TLS configuration of the tcp transport, which
+// requires the other side to present a certificate signed by --tls-ca.
+func (m *Migrate) tlsConfig() (*tls.Config, error) {
+	if m.tlsCert == "" || m.tlsKey == "" || m.tlsCA == "" {
+		return nil, fmt.Errorf("--tls-cert, --tls-key and --tls-ca are required with the tcp transport")
+	}
+	cert, err := tls.LoadX509KeyPair(m.tlsCert, m.tlsKey)
+	if err != nil {
+		return nil, fmt.Errorf("loading TLS certificate: %v", err)
+	}
+	ca, err := ioutil.ReadFile(m.tlsCA)
+	if err != nil {
+		return nil, fmt.Errorf("reading TLS CA certificates: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("no certificate found in %q", m.tlsCA)
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      pool,
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// shellQuote quotes s as a single argument of a POSIX shell command, such as
+// the one run by ssh.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// dialMigration connects to the destination dest.
+func (m *Migrate) dialMigration(dest string) (*migrateConn, error) {
+	transport, addr, err := parseMigrateDestination(dest)
+	if err != nil {
+		return nil, err
+	}
+	if transport == "tcp" {
+		tlsConf, err := m.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		host, _, _ := net.SplitHostPort(addr)
+		tlsConf.ServerName = host
+		conn, err := tls.Dial("tcp", addr, tlsConf)
+		if err != nil {
+			return nil, err
+		}
+		return &migrateConn{r: bufio.NewReader(conn), w: conn, close: conn.Close}, nil
+	}
+
+	// The destination is authenticated by ssh, and trusts the source to
+	// choose the bundle directory.
+	if m.remoteBundleDir == "" {
+		return nil, fmt.Errorf("--remote-bundle is required with the ssh transport")
+	}
+	args := append([]string{"-T", addr}, strings.Fields(m.remoteRunsc)...)
+	args = append(args, "migrate", "--receive", "--bundle="+shellQuote(m.remoteBundleDir))
+	cmd := exec.Command("ssh", args...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Running %v", cmd.Args)
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("running ssh: %v", err)
+	}
+	return &migrateConn{
+		r: bufio.NewReader(stdout),
+		w: stdin,
+		close: func() error {
+			stdin.Close()
+			return cmd.Wait()
+		},
+	}, nil
+}
+
+// receive receives a container from the source.
+func (m *Migrate) receive(conf *config.Config) error {
+	stdin, stdout, restoreStderr, err := m.redirectStdio()
+	if err != nil {
+		return err
+	}
+	defer restoreStderr()
+	conn, err := m.acceptMigration(stdin, stdout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := m.receiveContainer(conf, conn); err != nil {
+		return fmt.Errorf("receiving container: %v", err)
+	}
+	return nil
+}
+
+// redirectStdio gives the received container its own stdio, since it inherits
+// the stdio of this process: with the ssh transport, stdin and stdout carry
+// the migration protocol, which the output of the container would corrupt, and
+// the container would keep the ssh session open. Stdin is pointed to
+// /dev/null, and stdout and stderr to --output. It returns the original stdin
+// and stdout, and a function that restores stderr once the container is
+// received.
+func (m *Migrate) redirectStdio() (*os.File, *os.File, func(), error) {
+	var files []*os.File
+	cu := cleanup.Make(func() {
+		for _, f := range files {
+			f.Close()
+		}
+	})
+	defer cu.Clean()
+
+	// The duplicates aren't inherited by the container.
+	dup := func(fd int, name string) (*os.File, error) {
+		nfd, err := unix.FcntlInt(uintptr(fd), unix.F_DUPFD_CLOEXEC, 0)
+		if err != nil {
+			return nil, fmt.Errorf("duplicating %s: %v", name, err)
+		}
+		f := os.NewFile(uintptr(nfd), name)
+		files = append(files, f)
+		return f, nil
+	}
+	stdin, err := dup(unix.Stdin, "stdin")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	stdout, err := dup(unix.Stdout, "stdout")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	stderr, err := dup(unix.Stderr, "stderr")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer devNull.Close()
+	outputPath := os.DevNull
+	if m.output != "" {
+		outputPath = m.output
+	}
+	output, err := os.OpenFile(outputPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("opening output: %v", err)
+	}
+	defer output.Close()
+	for _, r := range []struct {
+		from *os.File
+		to   int
+	}{
+		{from: devNull, to: unix.Stdin},
+		{from: output, to: unix.Stdout},
+		{from: output, to: unix.Stderr},
+	} {
+		if err := unix.Dup3(int(r.from.Fd()), r.to, 0); err != nil {
+			// Errors may not be visible anymore.
+			unix.Dup3(int(stderr.Fd()), unix.Stderr, 0)
+			return nil, nil, nil, fmt.Errorf("redirecting stdio: %v", err)
+		}
+	}
+
+	cu.Release()
+	restoreStderr := func() {
+		if err := unix.Dup3(int(stderr.Fd()), unix.Stderr, 0); err != nil {
+			log.Warningf("Restoring stderr: %v", err)
+		}
+		stderr.Close()
+	}
+	return stdin, stdout, restoreStderr, nil
+}
+
+// acceptMigration waits for the source to connect, on stdin and stdout unless
+// --listen is set.
+func (m *Migrate) acceptMigration(stdin, stdout *os.File) (*migrateConn, error) {
+	if m.listen == "" {
+		return &migrateConn{
+			r: bufio.NewReader(stdin),
+			w: stdout,
+			close: func() error {
+				stdin.Close()
+				return stdout.Close()
+			},
+		}, nil
+	}
+	stdin.Close()
+	stdout.Close()
+	tlsConf, err := m.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	l, err := tls.Listen("tcp", m.listen, tlsConf)
+	if err != nil {
+		return nil, err
+	}
+	defer l.Close()
+	log.Infof("Waiting for the source on %s", l.Addr())
+	conn, err := l.Accept()
+	if err != nil {
+		return nil, err
+	}
+	// Authenticate the source before reading anything from it.
+	if err := conn.(*tls.Conn).Handshake(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("TLS handshake with %s: %v", conn.RemoteAddr(), err)
+	}
+	log.Infof("Receiving container from %s", conn.RemoteAddr())
+	return &migrateConn{r: bufio.NewReader(conn), w: conn, close: conn.Close}, nil
+}
+
+// chunkWriter writes the image as chunks prefixed by their length, followed
+// by an empty chunk. The destination can then tell a complete image from a
+// source that failed.
+type chunkWriter struct {
+	w       io.Writer
+	scratch [binary.MaxVarintLen64]byte
+}
+
+// Write implements io.Writer.Write.
+func (c *chunkWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	n := binary.PutUvarint(c.scratch[:], uint64(len(p)))
+	if _, err := c.w.Write(c.scratch[:n]); err != nil {
+		return 0, err
+	}
+	return c.w.Write(p)
+}
+
+// Close writes the end of the image.
+func (c *chunkWriter) Close() error {
+	_, err := c.w.Write([]byte{0})
+	return err
+}
+
+// chunkReader reads the image written by chunkWriter.
+type chunkReader struct {
+	r *bufio.Reader
+
+	// remaining is the number of bytes left in the current chunk.
+	remaining uint64
+
+	// done is set once the end of the image is read.
+	done bool
+}
+
+// Read implements io.Reader.Read.
+func (c *chunkReader) Read(p []byte) (int, error) {
+	if c.done {
+		return 0, io.EOF
+	}
+	if c.remaining == 0 {
+		n, err := binary.ReadUvarint(c.r)
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+		if n == 0 {
+			c.done = true
+			return 0, io.EOF
+		}
+		c.remaining = n
+	}
+	if uint64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+	n, err := c.r.Read(p)
+	c.remaining -= uint64(n)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// migrateWriter writes the image to the local image file, and to the
+// destination until it fails. The local image must be complete even if the
+// transfer fails, so that the container can be restored locally.
+type migrateWriter struct {
+	local  io.Writer
+	remote io.Writer
+
+	// remoteErr is the error writing to the destination, if any.
+	remoteErr error
+
+	// sent is the number of bytes sent to the destination, accessed
+	// atomically.
+	sent int64
+}
+
+// Write implements io.Writer.Write.
+func (w *migrateWriter) Write(p []byte) (int, error) {
+	if w.remoteErr == nil {
+		if _, err := w.remote.Write(p); err != nil {
+			log.Warningf("Sending image to the destination failed: %v", err)
+			w.remoteErr = err
+		} else {
+			atomic.AddInt64(&w.sent, int64(len(p)))
+		}
+	}
+	return w.local.Write(p)
+}
+
+// reportProgress prints the number of bytes sent every second, until stop is
+// closed.
+func (w *migrateWriter) reportProgress(stop <-chan struct{}) {
+	const mib = 1 << 20
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	var last int64
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			sent := atomic.LoadInt64(&w.sent)
+			fmt.Printf("Sent %d MiB (%d MiB/s)\n", sent/mib, (sent-last)/mib)
+			last = sent
+		}
+	}
+}
+
+// migrate migrates container id to dest.
+func (m *Migrate) migrate(conf *config.Config, id, dest string) error {
+	start := time.Now()
+	cont, err := container.Load(conf.RootDir, container.FullID{ContainerID: id}, container.LoadOpts{})
+	if err != nil {
+		return fmt.Errorf("loading container: %v", err)
+	}
+	if err := requireSingleContainer(conf, cont); err != nil {
+		return err
+	}
+	spec, err := specutils.ReadSpec(cont.BundleDir, conf)
+	if err != nil {
+		return fmt.Errorf("reading spec: %v", err)
+	}
+	if spec.Process.Terminal {
+		return fmt.Errorf("containers with a terminal can't be migrated")
+	}
+
+	imagePath := m.imagePath
+	if imagePath == "" {
+		imagePath, err = ioutil.TempDir("", "runsc-migrate")
+		if err != nil {
+			return err
+		}
+	} else if err := os.MkdirAll(imagePath, 0755); err != nil {
+		return fmt.Errorf("making directories at %q: %v", imagePath, err)
+	}
+	imageFile := filepath.Join(imagePath, checkpointFileName)
+
+	fmt.Printf("Preparing container %q on %s\n", id, dest)
+	conn, err := m.dialMigration(dest)
+	if err != nil {
+		return fmt.Errorf("connecting to %s: %v", dest, err)
+	}
+	defer conn.Close()
+	if err := conn.send(&migrateHeader{
+		Version: migrateVersion,
+		ID:      id,
+		Spec:    spec,
+	}); err != nil {
+		return fmt.Errorf("sending container to %s: %v", dest, err)
+	}
+	remoteErr, err := conn.recvReply()
+	if err != nil {
+		return fmt.Errorf("receiving reply from %s: %v", dest, err)
+	}
+	if remoteErr != nil {
+		return fmt.Errorf("creating container on %s: %v", dest, remoteErr)
+	}
+
+	fmt.Printf("Checkpointing container %q to %s\n", id, dest)
+	file, err := os.OpenFile(imageFile, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("os.OpenFile(%q) failed: %v", imageFile, err)
+	}
+	defer file.Close()
+	cw := &chunkWriter{w: conn.w}
+	w := &migrateWriter{local: file, remote: cw}
+	if err := m.streamCheckpoint(cont, w); err != nil {
+		// The sandbox stops even if the checkpoint fails, and the image is
+		// incomplete.
+		os.Remove(imageFile)
+		return fmt.Errorf("checkpoint failed, the container is stopped: %v", err)
+	}
+	if w.remoteErr == nil {
+		w.remoteErr = cw.Close()
+	}
+	if w.remoteErr != nil {
+		return m.rollback(conf, cont, spec, imageFile, fmt.Errorf("sending image to %s: %v", dest, w.remoteErr))
+	}
+
+	fmt.Printf("Restoring container %q on %s\n", id, dest)
+	remoteErr, err = conn.recvReply()
+	if err != nil {
+		// The container may have been restored on the destination: restoring
+		// it here could run it twice.
+		return fmt.Errorf("receiving reply from %s, the container may not be running, image kept in %q: %v", dest, imagePath, err)
+	}
+	if remoteErr != nil {
+		return m.rollback(conf, cont, spec, imageFile, fmt.Errorf("restoring container on %s: %v", dest, remoteErr))
+	}
+
+	if err := cont.Destroy(); err != nil {
+		log.Warningf("Destroying migrated container %q: %v", id, err)
+	}
+	if m.imagePath == "" {
+		os.RemoveAll(imagePath)
+	}
+	fmt.Printf("Container %q migrated to %s in %v\n", id, dest, time.Since(start))
+	return nil
+}
+
+// streamCheckpoint checkpoints cont to w.
+func (m *Migrate) streamCheckpoint(cont *container.Container, w *migrateWriter) error {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- cont.Checkpoint(pw, sandbox.CheckpointOpts{
+			Compression: m.compression,
+			Dedup:       m.dedup,
+		})
+		pw.Close()
+	}()
+
+	stop := make(chan struct{})
+	go w.reportProgress(stop)
+	_, copyErr := io.Copy(w, pr)
+	// Unblock the checkpoint if the image couldn't be written.
+	pr.Close()
+	close(stop)
+	if err := <-errCh; err != nil {
+		return err
+	}
+	return copyErr
+}
+
+// rollback restores the container locally from imageFile, after the
+// migration failed with err.
+func (m *Migrate) rollback(conf *config.Config, cont *container.Container, spec *specs.Spec, imageFile string, err error) error {
+	fmt.Printf("Migration failed, restoring container %q locally: %v\n", cont.ID, err)
+	bundleDir := cont.BundleDir
+	if destroyErr := cont.Destroy(); destroyErr != nil {
+		return fmt.Errorf("%v; destroying container to restore it: %v", err, destroyErr)
+	}
+	restored, newErr := container.New(conf, container.Args{
+		ID:        cont.ID,
+		Spec:      spec,
+		BundleDir: bundleDir,
+	})
+	if newErr != nil {
+		return fmt.Errorf("%v; creating container to restore it from %q: %v", err, imageFile, newErr)
+	}
+	if restoreErr := restored.Restore(spec, conf, imageFile); restoreErr != nil {
+		restored.Destroy()
+		return fmt.Errorf("%v; restoring container from %q: %v", err, imageFile, restoreErr)
+	}
+	return fmt.Errorf("%v; container restored locally", err)
+}
+
+// receiveContainer creates the container sent by the source on conn, and
+// restores it from the image that follows.
+func (m *Migrate) receiveContainer(conf *config.Config, conn *migrateConn) error {
+	var hdr migrateHeader
+	if err := conn.recv(&hdr); err != nil {
+		return fmt.Errorf("receiving container: %v", err)
+	}
+	if hdr.Version != migrateVersion {
+		err := fmt.Errorf("unsupported migration version %d, want %d", hdr.Version, migrateVersion)
+		conn.send(&migrateReply{Error: err.Error()})
+		return err
+	}
+	cont, err := m.createReceived(conf, &hdr)
+	if err != nil {
+		conn.send(&migrateReply{Error: err.Error()})
+		return err
+	}
+	cu := cleanup.Make(func() {
+		cont.Destroy()
+	})
+	defer cu.Clean()
+	if err := conn.send(&migrateReply{}); err != nil {
+		return err
+	}
+
+	if err := m.restoreReceived(conf, conn, cont, hdr.Spec); err != nil {
+		conn.send(&migrateReply{Error: err.Error()})
+		return err
+	}
+	if err := conn.send(&migrateReply{}); err != nil {
+		return err
+	}
+	cu.Release()
+	log.Infof("Container %q restored", hdr.ID)
+	return nil
+}
+
+// validateReceivedSpec checks that the spec sent by the source doesn't run
+// commands on the destination host, outside of the sandbox.
+func validateReceivedSpec(spec *specs.Spec) error {
+	if spec == nil {
+		return fmt.Errorf("no spec")
+	}
+	if h := spec.Hooks; h != nil && len(h.Prestart)+len(h.CreateRuntime)+len(h.CreateContainer)+len(h.StartContainer)+len(h.Poststart)+len(h.Poststop) > 0 {
+		return fmt.Errorf("containers with OCI hooks can't be received")
+	}
+	return nil
+}
+
+// createReceived writes the bundle of the container described by hdr to the
+// local bundle directory, and creates it.
+func (m *Migrate) createReceived(conf *config.Config, hdr *migrateHeader) (*container.Container, error) {
+	if err := validateReceivedSpec(hdr.Spec); err != nil {
+		return nil, fmt.Errorf("container %q: %v", hdr.ID, err)
+	}
+	bundleDir := m.bundleDir
+	if err := os.MkdirAll(bundleDir, 0755); err != nil {
+		return nil, fmt.Errorf("making directories at %q: %v", bundleDir, err)
+	}
+	b, err := json.MarshalIndent(hdr.Spec, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(bundleDir, "config.json"), b, 0644); err != nil {
+		return nil, fmt.Errorf("writing spec: %v", err)
+	}
+	log.Infof("Creating container %q, bundle: %q", hdr.ID, bundleDir)
+	return container.New(conf, container.Args{
+		ID:        hdr.ID,
+		Spec:      hdr.Spec,
+		BundleDir: bundleDir,
+	})
+}
+
+// restoreReceived receives the image of cont on conn, and restores it.
+func (m *Migrate) restoreReceived(conf *config.Config, conn *migrateConn, cont *container.Container, spec *specs.Spec) error {
+	imagePath := m.imagePath
+	if imagePath == "" {
+		dir, err := ioutil.TempDir("", "runsc-migrate")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+		imagePath = dir
+	} else if err := os.MkdirAll(imagePath, 0755); err != nil {
+		return fmt.Errorf("making directories at %q: %v", imagePath, err)
+	}
+	imageFile := filepath.Join(imagePath, checkpointFileName)
+	file, err := os.OpenFile(imageFile, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("os.OpenFile(%q) failed: %v", imageFile, err)
+	}
+	defer file.Close()
+	n, err := io.Copy(file, &chunkReader{r: conn.r})
+	if err != nil {
+		return fmt.Errorf("receiving image: %v", err)
+	}
+	log.Infof("Received image of %d bytes", n)
+
+	conf.RestoreFile = imageFile
+	if err := validateRestoreMounts(conf, cont.ID, spec, nil); err != nil {
+		return err
+	}
+	return cont.Restore(spec, conf, imageFile)
+}
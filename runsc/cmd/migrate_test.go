@@ -0,0 +1,151 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func TestChunks(t *testing.T) {
+	var buf bytes.Buffer
+	w := &chunkWriter{w: &buf}
+	var want []byte
+	for _, size := range []int{1, 0, 127, 128, 1 << 16} {
+		data := bytes.Repeat([]byte{byte(size)}, size)
+		want = append(want, data...)
+		if _, err := w.Write(data); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	// Data following the image is left unread.
+	buf.WriteString("trailer\n")
+
+	r := bufio.NewReader(&buf)
+	got, err := ioutil.ReadAll(&chunkReader{r: r})
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("got %d bytes, want %d", len(got), len(want))
+	}
+	if rest, _ := r.ReadString('\n'); rest != "trailer\n" {
+		t.Errorf("data following the image: got %q, want %q", rest, "trailer\n")
+	}
+}
+
+func TestChunksTruncated(t *testing.T) {
+	var buf bytes.Buffer
+	w := &chunkWriter{w: &buf}
+	if _, err := w.Write([]byte("image")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	full := buf.Bytes()
+	// The source failing before the end of the image must not be mistaken
+	// for a complete image.
+	for n := 0; n <= len(full); n++ {
+		r := &chunkReader{r: bufio.NewReader(bytes.NewReader(full[:n]))}
+		if _, err := ioutil.ReadAll(r); err != io.ErrUnexpectedEOF {
+			t.Errorf("reading %d bytes: got error %v, want %v", n, err, io.ErrUnexpectedEOF)
+		}
+	}
+}
+
+func TestParseMigrateDestination(t *testing.T) {
+	for _, tc := range []struct {
+		dest      string
+		transport string
+		addr      string
+	}{
+		{dest: "tcp://10.0.0.1:9000", transport: "tcp", addr: "10.0.0.1:9000"},
+		{dest: "tcp://[::1]:9000", transport: "tcp", addr: "[::1]:9000"},
+		{dest: "ssh://host", transport: "ssh", addr: "host"},
+		{dest: "ssh://user@host", transport: "ssh", addr: "user@host"},
+	} {
+		transport, addr, err := parseMigrateDestination(tc.dest)
+		if err != nil {
+			t.Errorf("parseMigrateDestination(%q) failed: %v", tc.dest, err)
+			continue
+		}
+		if transport != tc.transport || addr != tc.addr {
+			t.Errorf("parseMigrateDestination(%q) = %q, %q, want %q, %q", tc.dest, transport, addr, tc.transport, tc.addr)
+		}
+	}
+
+	for _, dest := range []string{
+		"",
+		"host",
+		"tcp://",
+		"tcp://host",
+		"ssh://host/path",
+		"udp://host:9000",
+	} {
+		if _, _, err := parseMigrateDestination(dest); err == nil {
+			t.Errorf("parseMigrateDestination(%q) succeeded, want error", dest)
+		}
+	}
+}
+
+func TestValidateReceivedSpec(t *testing.T) {
+	if err := validateReceivedSpec(&specs.Spec{Hooks: &specs.Hooks{}}); err != nil {
+		t.Errorf("validateReceivedSpec(no hooks) failed: %v", err)
+	}
+	for _, spec := range []*specs.Spec{
+		nil,
+		{Hooks: &specs.Hooks{Prestart: []specs.Hook{{Path: "/bin/true"}}}},
+		{Hooks: &specs.Hooks{CreateRuntime: []specs.Hook{{Path: "/bin/true"}}}},
+		{Hooks: &specs.Hooks{Poststop: []specs.Hook{{Path: "/bin/true"}}}},
+	} {
+		if err := validateReceivedSpec(spec); err == nil {
+			t.Errorf("validateReceivedSpec(%+v) succeeded, want error", spec)
+		}
+	}
+}
+
+func TestMigrateTLSRequired(t *testing.T) {
+	m := &Migrate{tlsCert: "cert.pem", tlsKey: "key.pem"}
+	if _, err := m.tlsConfig(); err == nil {
+		t.Errorf("tlsConfig() without --tls-ca succeeded, want error")
+	}
+	if _, err := m.dialMigration("tcp://127.0.0.1:1"); err == nil {
+		t.Errorf("dialMigration() without --tls-ca succeeded, want error")
+	}
+	if _, err := m.dialMigration("ssh://host"); err == nil {
+		t.Errorf("dialMigration() without --remote-bundle succeeded, want error")
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want string
+	}{
+		{in: "/bundle", want: `'/bundle'`},
+		{in: "/my bundle", want: `'/my bundle'`},
+		{in: "/it's", want: `'/it'\''s'`},
+	} {
+		if got := shellQuote(tc.in); got != tc.want {
+			t.Errorf("shellQuote(%q) = %s, want %s", tc.in, got, tc.want)
+		}
+	}
+}
@@ -15,8 +15,14 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/google/subcommands"
 	"gvisor.dev/gvisor/pkg/sentry/control"
@@ -28,6 +34,7 @@ import (
 // PS implements subcommands.Command for the "ps" command.
 type PS struct {
 	format string
+	execs  bool
 }
 
 // Name implements subcommands.Command.Name.
@@ -48,6 +55,7 @@ func (*PS) Usage() string {
 // SetFlags implements subcommands.Command.SetFlags.
 func (ps *PS) SetFlags(f *flag.FlagSet) {
 	f.StringVar(&ps.format, "format", "table", "output format. Select one of: table or json (default: table)")
+	f.BoolVar(&ps.execs, "execs", false, "list exec sessions instead of processes")
 }
 
 // Execute implements subcommands.Command.Execute.
@@ -64,6 +72,9 @@ func (ps *PS) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{})
 	if err != nil {
 		Fatalf("loading sandbox: %v", err)
 	}
+	if ps.execs {
+		return ps.printExecSessions(c)
+	}
 	pList, err := c.Processes()
 	if err != nil {
 		Fatalf("getting processes for container: %v", err)
@@ -84,3 +95,34 @@ func (ps *PS) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{})
 
 	return subcommands.ExitSuccess
 }
+
+func (ps *PS) printExecSessions(c *container.Container) subcommands.ExitStatus {
+	sessions, err := c.ExecSessions()
+	if err != nil {
+		Fatalf("getting exec sessions for container: %v", err)
+	}
+
+	switch ps.format {
+	case "table":
+		var buf bytes.Buffer
+		tw := tabwriter.NewWriter(&buf, 10, 1, 3, ' ', 0)
+		fmt.Fprint(tw, "ID\tPID\tSTARTED\tSTATUS\tCMD")
+		for _, s := range sessions {
+			status := "running"
+			if s.Exited {
+				status = fmt.Sprintf("exited (%#x)", s.ExitStatus)
+			}
+			fmt.Fprintf(tw, "\n%s\t%d\t%s\t%s\t%s", s.ID, s.PID, s.StartTime.Format(time.RFC3339), status, strings.Join(s.Argv, " "))
+		}
+		tw.Flush()
+		fmt.Println(buf.String())
+	case "json":
+		if err := json.NewEncoder(os.Stdout).Encode(sessions); err != nil {
+			Fatalf("generating JSON: %v", err)
+		}
+	default:
+		Fatalf("unsupported format: %s", ps.format)
+	}
+
+	return subcommands.ExitSuccess
+}
@@ -0,0 +1,88 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"math"
+
+	"github.com/google/subcommands"
+	"gvisor.dev/gvisor/pkg/abi/linux"
+	"gvisor.dev/gvisor/runsc/config"
+	"gvisor.dev/gvisor/runsc/container"
+	"gvisor.dev/gvisor/runsc/flag"
+)
+
+// Resize implements subcommands.Command for the "resize" command.
+type Resize struct {
+	pid  int
+	rows uint
+	cols uint
+}
+
+// Name implements subcommands.Command.Name.
+func (*Resize) Name() string {
+	return "resize"
+}
+
+// Synopsis implements subcommands.Command.Synopsis.
+func (*Resize) Synopsis() string {
+	return "resize changes the window size of the TTY of a process in a container"
+}
+
+// Usage implements subcommands.Command.Usage.
+func (*Resize) Usage() string {
+	return `resize [flags] <container id> - change the window size of the TTY of a process in a container.
+
+The TTY is the one the process was started with, and its foreground process
+group gets SIGWINCH if the size changes.
+`
+}
+
+// SetFlags implements subcommands.Command.SetFlags.
+func (r *Resize) SetFlags(f *flag.FlagSet) {
+	f.IntVar(&r.pid, "pid", 0, "process whose TTY is resized, relative to the root PID namespace. Defaults to the container's init process")
+	f.UintVar(&r.rows, "rows", 0, "number of rows of the window")
+	f.UintVar(&r.cols, "cols", 0, "number of columns of the window")
+}
+
+// Execute implements subcommands.Command.Execute.
+func (r *Resize) Execute(_ context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 1 {
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+
+	id := f.Arg(0)
+	conf := args[0].(*config.Config)
+
+	if r.rows > math.MaxUint16 || r.cols > math.MaxUint16 {
+		Fatalf("window size is too large")
+	}
+
+	c, err := container.Load(conf.RootDir, container.FullID{ContainerID: id}, container.LoadOpts{})
+	if err != nil {
+		Fatalf("loading container: %v", err)
+	}
+
+	ws := linux.Winsize{
+		Row: uint16(r.rows),
+		Col: uint16(r.cols),
+	}
+	if err := c.ResizeTTY(int32(r.pid), &ws); err != nil {
+		Fatalf("resize failed: %v", err)
+	}
+	return subcommands.ExitSuccess
+}
@@ -16,10 +16,17 @@ package cmd
 
 import (
 	"context"
+	"fmt"
+	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/google/subcommands"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
 	"golang.org/x/sys/unix"
+	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/pkg/state/statefile"
+	"gvisor.dev/gvisor/runsc/boot"
 	"gvisor.dev/gvisor/runsc/config"
 	"gvisor.dev/gvisor/runsc/container"
 	"gvisor.dev/gvisor/runsc/flag"
@@ -36,6 +43,10 @@ type Restore struct {
 
 	// detach indicates that runsc has to start a process and exit without waiting it.
 	detach bool
+
+	// remapMounts are the bind mounts that moved on the host since the
+	// checkpoint, as <old source>=<new source>.
+	remapMounts stringSlice
 }
 
 // Name implements subcommands.Command.Name.
@@ -51,6 +62,10 @@ func (*Restore) Synopsis() string {
 // Usage implements subcommands.Command.Usage.
 func (*Restore) Usage() string {
 	return `restore [flags] <container id> - restore saved state of container.
+
+The bind mounts of the spec must match the ones of the checkpointed container.
+If their sources moved on the host, use --remap-mount=<old source>=<new source>
+for each of them, or for a parent directory.
 `
 }
 
@@ -59,6 +74,7 @@ func (r *Restore) SetFlags(f *flag.FlagSet) {
 	r.Create.SetFlags(f)
 	f.StringVar(&r.imagePath, "image-path", "", "directory path to saved container image")
 	f.BoolVar(&r.detach, "detach", false, "detach from the container's process")
+	f.Var(&r.remapMounts, "remap-mount", "<old source>=<new source> of a bind mount that moved since the checkpoint. May be repeated.")
 
 	// Unimplemented flags necessary for compatibility with docker.
 
@@ -100,6 +116,26 @@ func (r *Restore) Execute(_ context.Context, f *flag.FlagSet, args ...interface{
 
 	conf.RestoreFile = filepath.Join(r.imagePath, checkpointFileName)
 
+	manifest, err := readCheckpointManifest(r.imagePath)
+	if err != nil {
+		return Errorf("reading image: %v", err)
+	}
+	if manifest != nil && !inCheckpointManifest(manifest, id) {
+		log.Warningf("Container %q isn't saved in the image, it restores a container saved under another ID", id)
+	}
+
+	remap := make(map[string]string)
+	for _, arg := range r.remapMounts {
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) != 2 || !filepath.IsAbs(parts[0]) || !filepath.IsAbs(parts[1]) {
+			return Errorf("invalid --remap-mount %q, must be <old source>=<new source> with absolute paths", arg)
+		}
+		remap[filepath.Clean(parts[0])] = filepath.Clean(parts[1])
+	}
+	if err := validateRestoreMounts(conf, id, spec, remap); err != nil {
+		return Errorf("%v", err)
+	}
+
 	runArgs := container.Args{
 		ID:            id,
 		Spec:          spec,
@@ -117,3 +153,37 @@ func (r *Restore) Execute(_ context.Context, f *flag.FlagSet, args ...interface{
 
 	return subcommands.ExitSuccess
 }
+
+// validateRestoreMounts checks that the mounts of spec match the ones saved in
+// conf.RestoreFile, and updates the sources of spec according to remap.
+func validateRestoreMounts(conf *config.Config, id string, spec *specs.Spec, remap map[string]string) error {
+	f, err := os.Open(conf.RestoreFile)
+	if err != nil {
+		return fmt.Errorf("opening image: %v", err)
+	}
+	defer f.Close()
+	// The image is verified once restored.
+	metadata, err := statefile.MetadataUnsafe(f)
+	if err != nil {
+		return fmt.Errorf("reading image metadata: %v", err)
+	}
+	info, err := boot.ParseCheckpointInfo(metadata)
+	if err != nil {
+		return err
+	}
+	if err := boot.ValidateRestoreMounts(info, id, spec, remap, conf.VFS2); err != nil {
+		return fmt.Errorf("mounts don't match the image: %v", err)
+	}
+	return nil
+}
+
+// inCheckpointManifest returns true if the container id is saved in the image
+// listing manifest.
+func inCheckpointManifest(manifest []checkpointedContainer, id string) bool {
+	for _, c := range manifest {
+		if c.ID == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/subcommands"
+	"gvisor.dev/gvisor/runsc/config"
+	"gvisor.dev/gvisor/runsc/container"
+	"gvisor.dev/gvisor/runsc/flag"
+)
+
+// Snapshot implements subcommands.Command for the "snapshot" command.
+type Snapshot struct {
+	imagePath string
+	interval  time.Duration
+	keep      int
+	stop      bool
+}
+
+// Name implements subcommands.Command.Name.
+func (*Snapshot) Name() string {
+	return "snapshot"
+}
+
+// Synopsis implements subcommands.Command.Synopsis.
+func (*Snapshot) Synopsis() string {
+	return "periodically save the state of a running container (experimental)"
+}
+
+// Usage implements subcommands.Command.Usage.
+func (*Snapshot) Usage() string {
+	return `snapshot [flags] <container id>
+
+Saves the state of the sandbox every --interval, while leaving it running. The
+last --keep snapshots are kept in <image-path>/snapshot-<n>, which can be given
+to restore as --image-path. Use "runsc state-tool info" to find the latest one.
+
+`
+}
+
+// SetFlags implements subcommands.Command.SetFlags.
+func (s *Snapshot) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&s.imagePath, "image-path", "", "directory path to save snapshots to")
+	f.DurationVar(&s.interval, "interval", time.Hour, "time between snapshots")
+	f.IntVar(&s.keep, "keep", 3, "number of snapshots to keep")
+	f.BoolVar(&s.stop, "stop", false, "stop taking snapshots")
+}
+
+// Execute implements subcommands.Command.Execute.
+func (s *Snapshot) Execute(_ context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 1 {
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+
+	id := f.Arg(0)
+	conf := args[0].(*config.Config)
+
+	c, err := container.Load(conf.RootDir, container.FullID{ContainerID: id}, container.LoadOpts{})
+	if err != nil {
+		return Errorf("loading container %q: %v", id, err)
+	}
+
+	if s.stop {
+		if err := c.StopSnapshots(); err != nil {
+			return Errorf("%v", err)
+		}
+		return subcommands.ExitSuccess
+	}
+
+	if s.imagePath == "" {
+		return Errorf("image-path flag must be provided")
+	}
+	files, err := openSnapshotImages(s.imagePath, s.keep)
+	if err != nil {
+		return Errorf("%v", err)
+	}
+	defer closeSnapshotImages(files)
+	if err := c.StartSnapshots(s.interval, files); err != nil {
+		return Errorf("%v", err)
+	}
+	return subcommands.ExitSuccess
+}
+
+// openSnapshotImages opens the images of keep snapshots in imagePath, creating
+// them if needed.
+func openSnapshotImages(imagePath string, keep int) ([]*os.File, error) {
+	if keep < 1 {
+		return nil, fmt.Errorf("at least one snapshot must be kept, got %d", keep)
+	}
+	var files []*os.File
+	for i := 0; i < keep; i++ {
+		dir := filepath.Join(imagePath, fmt.Sprintf("snapshot-%d", i))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			closeSnapshotImages(files)
+			return nil, fmt.Errorf("making directories at %q: %v", dir, err)
+		}
+		path := filepath.Join(dir, checkpointFileName)
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+		if err != nil {
+			closeSnapshotImages(files)
+			return nil, fmt.Errorf("opening snapshot image %q: %v", path, err)
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+func closeSnapshotImages(files []*os.File) {
+	for _, f := range files {
+		f.Close()
+	}
+}
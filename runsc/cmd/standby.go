@@ -0,0 +1,450 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/subcommands"
+	"golang.org/x/sys/unix"
+	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/pkg/state/statefile"
+	"gvisor.dev/gvisor/pkg/sync"
+	"gvisor.dev/gvisor/runsc/config"
+	"gvisor.dev/gvisor/runsc/container"
+	"gvisor.dev/gvisor/runsc/flag"
+	"gvisor.dev/gvisor/runsc/sandbox"
+	"gvisor.dev/gvisor/runsc/specutils"
+)
+
+const (
+	// standbyCheckInterval is how often the activity of the container is
+	// checked.
+	standbyCheckInterval = time.Second
+
+	// standbyDialTimeout is the timeout to connect to the container.
+	standbyDialTimeout = 10 * time.Second
+)
+
+// Standby implements subcommands.Command for the "standby" command.
+type Standby struct {
+	imagePath   string
+	forwards    stringSlice
+	idleTimeout time.Duration
+	idleCPU     float64
+	compression string
+}
+
+// Name implements subcommands.Command.Name.
+func (*Standby) Name() string {
+	return "standby"
+}
+
+// Synopsis implements subcommands.Command.Synopsis.
+func (*Standby) Synopsis() string {
+	return "checkpoint a container when it's idle, and restore it on the next connection (experimental)"
+}
+
+// Usage implements subcommands.Command.Usage.
+func (*Standby) Usage() string {
+	return `standby [flags] <container id>
+
+Proxies connections to the addresses given with --forward to the running
+container. Once the container has had no connections and used less than
+--idle-cpu for --idle-timeout, it's checkpointed to --image-path and destroyed,
+freeing its memory. The next connection restores it, and is held until the
+container is running again.
+
+The container must be the only one in its sandbox, and the runsc flags must be
+the ones it was created with. standby runs until the container exits or it's
+interrupted. If the container is checkpointed then, it can be restored with
+runsc restore.
+`
+}
+
+// SetFlags implements subcommands.Command.SetFlags.
+func (s *Standby) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&s.imagePath, "image-path", "", "directory path where the container image is saved while it's idle")
+	f.Var(&s.forwards, "forward", "<listen address>=<container address>, e.g. :8080=10.0.0.2:80, of TCP connections proxied to the container. May be repeated.")
+	f.DurationVar(&s.idleTimeout, "idle-timeout", 5*time.Minute, "time without activity after which the container is checkpointed")
+	f.Float64Var(&s.idleCPU, "idle-cpu", 0.01, "CPU usage, as a fraction of one CPU, below which the container is idle")
+	f.StringVar(&s.compression, "compression", string(statefile.CompressionFlate), "compression algorithm of the image: flate or zstd")
+}
+
+// Execute implements subcommands.Command.Execute.
+func (s *Standby) Execute(_ context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 1 {
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+	id := f.Arg(0)
+	conf := args[0].(*config.Config)
+
+	if s.imagePath == "" {
+		return Errorf("image-path flag must be provided")
+	}
+	if len(s.forwards) == 0 {
+		return Errorf("at least one forward flag must be provided")
+	}
+	if s.idleTimeout <= 0 {
+		return Errorf("idle-timeout must be > 0, got: %v", s.idleTimeout)
+	}
+	switch statefile.Compression(s.compression) {
+	case statefile.CompressionFlate, statefile.CompressionZstd:
+	default:
+		return Errorf("invalid compression %q, must be flate or zstd", s.compression)
+	}
+
+	var listeners []net.Listener
+	defer func() {
+		for _, l := range listeners {
+			l.Close()
+		}
+	}()
+	targets := make(map[net.Listener]string)
+	for _, fwd := range s.forwards {
+		parts := strings.SplitN(fwd, "=", 2)
+		if len(parts) != 2 {
+			return Errorf("invalid --forward %q, must be <listen address>=<container address>", fwd)
+		}
+		if _, _, err := net.SplitHostPort(parts[1]); err != nil {
+			return Errorf("invalid --forward %q: %v", fwd, err)
+		}
+		l, err := net.Listen("tcp", parts[0])
+		if err != nil {
+			return Errorf("listening on %q: %v", parts[0], err)
+		}
+		listeners = append(listeners, l)
+		targets[l] = parts[1]
+	}
+
+	cont, err := container.Load(conf.RootDir, container.FullID{ContainerID: id}, container.LoadOpts{})
+	if err != nil {
+		return Errorf("loading container: %v", err)
+	}
+	if err := requireSingleContainer(conf, cont); err != nil {
+		return Errorf("%v", err)
+	}
+	bundleDir := cont.BundleDir
+	spec, err := specutils.ReadSpec(bundleDir, conf)
+	if err != nil {
+		return Errorf("reading spec: %v", err)
+	}
+	if spec.Process.Terminal {
+		return Errorf("containers with a terminal can't be put on standby")
+	}
+	if err := os.MkdirAll(s.imagePath, 0755); err != nil {
+		return Errorf("making directories at %q: %v", s.imagePath, err)
+	}
+	imageFile := filepath.Join(s.imagePath, checkpointFileName)
+
+	p := &standbyProxy{
+		idleTimeout: s.idleTimeout,
+		idleCPU:     s.idleCPU,
+		suspend: func() error {
+			start := time.Now()
+			if err := os.Remove(imageFile); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			file, err := os.OpenFile(imageFile, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0644)
+			if err != nil {
+				return fmt.Errorf("os.OpenFile(%q) failed: %v", imageFile, err)
+			}
+			defer file.Close()
+			if err := cont.Checkpoint(file, sandbox.CheckpointOpts{Compression: s.compression}); err != nil {
+				return fmt.Errorf("checkpoint failed, the container is stopped: %v", err)
+			}
+			sb := cont.Sandbox
+			if err := cont.Destroy(); err != nil {
+				return fmt.Errorf("destroying container: %v", err)
+			}
+			// The sandbox moved the addresses and routes of the network
+			// namespace to netstack. Give them back, so that the restored
+			// sandbox finds them again.
+			if err := sb.RestoreHostNetwork(spec); err != nil {
+				return fmt.Errorf("restoring network configuration: %v", err)
+			}
+			log.Infof("Container %q idle, checkpointed in %v", id, time.Since(start))
+			return nil
+		},
+		resume: func() error {
+			start := time.Now()
+			restored, err := container.New(conf, container.Args{
+				ID:        id,
+				Spec:      spec,
+				BundleDir: bundleDir,
+			})
+			if err != nil {
+				return fmt.Errorf("creating container to restore it from %q: %v", imageFile, err)
+			}
+			if err := restored.Restore(spec, conf, imageFile); err != nil {
+				restored.Destroy()
+				return fmt.Errorf("restoring container from %q: %v", imageFile, err)
+			}
+			cont = restored
+			log.Infof("Container %q restored in %v", id, time.Since(start))
+			return nil
+		},
+		usage: func() (time.Duration, error) {
+			event, err := cont.Event()
+			if err != nil {
+				return 0, err
+			}
+			return time.Duration(event.ContainerUsage[id]), nil
+		},
+	}
+	if err := p.init(); err != nil {
+		return Errorf("getting container usage: %v", err)
+	}
+
+	for _, l := range listeners {
+		go p.serve(l, targets[l])
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, unix.SIGINT, unix.SIGTERM)
+	defer signal.Stop(sigCh)
+	ticker := time.NewTicker(standbyCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case sig := <-sigCh:
+			log.Infof("Standby of container %q stopped by signal %v", id, sig)
+			p.close()
+			if p.isSuspended() {
+				fmt.Printf("Container %q is checkpointed in %q\n", id, s.imagePath)
+			}
+			return subcommands.ExitSuccess
+		case now := <-ticker.C:
+			if err := p.check(now); err != nil {
+				p.close()
+				if p.isSuspended() {
+					return Errorf("%v, image kept in %q", err, s.imagePath)
+				}
+				return Errorf("%v", err)
+			}
+		}
+	}
+}
+
+// requireSingleContainer returns an error if cont isn't the only container in
+// its sandbox.
+func requireSingleContainer(conf *config.Config, cont *container.Container) error {
+	ids, err := container.List(conf.RootDir)
+	if err != nil {
+		return err
+	}
+	for _, other := range ids {
+		if other.SandboxID == cont.Sandbox.ID && other.ContainerID != cont.ID {
+			return fmt.Errorf("sandbox %q has other containers, only single-container sandboxes are supported", cont.Sandbox.ID)
+		}
+	}
+	return nil
+}
+
+// standbyProxy proxies connections to a container, which it suspends when
+// idle and resumes on the next connection.
+type standbyProxy struct {
+	// idleTimeout is the time without activity after which the container
+	// is suspended.
+	idleTimeout time.Duration
+
+	// idleCPU is the CPU usage, as a fraction of one CPU, below which the
+	// container is idle.
+	idleCPU float64
+
+	// suspend checkpoints and destroys the container.
+	suspend func() error
+
+	// resume restores the container suspended by suspend.
+	resume func() error
+
+	// usage returns the CPU time used by the container. It fails if the
+	// container isn't running anymore.
+	usage func() (time.Duration, error)
+
+	// mu protects the fields below. It's held while the container is
+	// suspended or resumed, such that connections wait for it.
+	mu sync.Mutex
+
+	// suspended is true if the container is checkpointed.
+	suspended bool
+
+	// closed is true once the proxy stopped.
+	closed bool
+
+	// conns is the number of connections being proxied.
+	conns int
+
+	// lastActive is the last time the container was active.
+	lastActive time.Time
+
+	// lastCheck is the last time the activity of the container was checked.
+	lastCheck time.Time
+
+	// lastUsage is the CPU time used by the container at lastCheck.
+	lastUsage time.Duration
+}
+
+// init starts tracking the activity of the running container.
+func (p *standbyProxy) init() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.resetLocked(time.Now())
+}
+
+// resetLocked considers the container active at now.
+//
+// Preconditions: p.mu is locked.
+func (p *standbyProxy) resetLocked(now time.Time) error {
+	usage, err := p.usage()
+	if err != nil {
+		return err
+	}
+	p.lastActive = now
+	p.lastCheck = now
+	p.lastUsage = usage
+	return nil
+}
+
+// isSuspended returns true if the container is checkpointed.
+func (p *standbyProxy) isSuspended() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.suspended
+}
+
+// close stops proxying new connections.
+func (p *standbyProxy) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+}
+
+// check suspends the container if it has been idle for the idle timeout at
+// now. It returns an error if the container stopped, or couldn't be suspended.
+func (p *standbyProxy) check(now time.Time) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.suspended || p.closed {
+		return nil
+	}
+	usage, err := p.usage()
+	if err != nil {
+		return fmt.Errorf("container stopped: %v", err)
+	}
+	if elapsed := now.Sub(p.lastCheck); elapsed > 0 {
+		if float64(usage-p.lastUsage) > p.idleCPU*float64(elapsed) {
+			p.lastActive = now
+		}
+	}
+	p.lastCheck = now
+	p.lastUsage = usage
+	if p.conns > 0 {
+		p.lastActive = now
+	}
+	if now.Sub(p.lastActive) < p.idleTimeout {
+		return nil
+	}
+	if err := p.suspend(); err != nil {
+		// The container may be stopped, don't try to resume it.
+		p.closed = true
+		return err
+	}
+	p.suspended = true
+	return nil
+}
+
+// acquire marks a connection as proxied, resuming the container if it's
+// suspended.
+func (p *standbyProxy) acquire() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return fmt.Errorf("proxy closed")
+	}
+	if p.suspended {
+		if err := p.resume(); err != nil {
+			return err
+		}
+		p.suspended = false
+		if err := p.resetLocked(time.Now()); err != nil {
+			return err
+		}
+	}
+	p.conns++
+	return nil
+}
+
+// release marks a connection as done.
+func (p *standbyProxy) release() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.conns--
+	p.lastActive = time.Now()
+}
+
+// serve proxies the connections accepted by l to target, until l is closed.
+func (p *standbyProxy) serve(l net.Listener, target string) {
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Warningf("Accepting connection on %v: %v", l.Addr(), err)
+			}
+			return
+		}
+		go p.handle(c, target)
+	}
+}
+
+// handle proxies c to target.
+func (p *standbyProxy) handle(c net.Conn, target string) {
+	defer c.Close()
+	if err := p.acquire(); err != nil {
+		log.Warningf("Dropping connection from %v: %v", c.RemoteAddr(), err)
+		return
+	}
+	defer p.release()
+	dst, err := net.DialTimeout("tcp", target, standbyDialTimeout)
+	if err != nil {
+		log.Warningf("Connecting to %s: %v", target, err)
+		return
+	}
+	defer dst.Close()
+
+	done := make(chan struct{})
+	go func() {
+		io.Copy(dst, c)
+		if tc, ok := dst.(*net.TCPConn); ok {
+			tc.CloseWrite()
+		}
+		close(done)
+	}()
+	io.Copy(c, dst)
+	if tc, ok := c.(*net.TCPConn); ok {
+		tc.CloseWrite()
+	}
+	<-done
+}
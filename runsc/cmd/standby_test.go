@@ -0,0 +1,178 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeStandby is the container proxied by a standbyProxy in tests. Its fields
+// are protected by standbyProxy.mu once the proxy serves connections.
+type fakeStandby struct {
+	suspends int
+	resumes  int
+	cpu      time.Duration
+}
+
+func newTestStandbyProxy(t *testing.T, c *fakeStandby) *standbyProxy {
+	p := &standbyProxy{
+		idleTimeout: time.Minute,
+		idleCPU:     0.01,
+		suspend: func() error {
+			c.suspends++
+			return nil
+		},
+		resume: func() error {
+			c.resumes++
+			return nil
+		},
+		usage: func() (time.Duration, error) {
+			if c.suspends > c.resumes {
+				return 0, fmt.Errorf("container suspended")
+			}
+			return c.cpu, nil
+		},
+	}
+	if err := p.init(); err != nil {
+		t.Fatalf("init failed: %v", err)
+	}
+	return p
+}
+
+// echoServer returns the address of a server echoing lines back.
+func echoServer(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				io.Copy(c, c)
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestStandbyIdle(t *testing.T) {
+	c := &fakeStandby{}
+	p := newTestStandbyProxy(t, c)
+	now := time.Now()
+
+	if err := p.check(now.Add(30 * time.Second)); err != nil {
+		t.Fatalf("check failed: %v", err)
+	}
+	if c.suspends != 0 {
+		t.Errorf("container suspended before the idle timeout")
+	}
+
+	// CPU usage above the threshold is activity.
+	c.cpu += time.Second
+	if err := p.check(now.Add(61 * time.Second)); err != nil {
+		t.Fatalf("check failed: %v", err)
+	}
+	if c.suspends != 0 {
+		t.Errorf("container suspended while using CPU")
+	}
+
+	// CPU usage below the threshold isn't.
+	c.cpu += time.Millisecond
+	if err := p.check(now.Add(122 * time.Second)); err != nil {
+		t.Fatalf("check failed: %v", err)
+	}
+	if c.suspends != 1 || !p.isSuspended() {
+		t.Errorf("container not suspended once idle, suspends: %d", c.suspends)
+	}
+
+	// Checks don't query the suspended container.
+	if err := p.check(now.Add(time.Hour)); err != nil {
+		t.Errorf("check of suspended container failed: %v", err)
+	}
+}
+
+func TestStandbyResume(t *testing.T) {
+	c := &fakeStandby{}
+	p := newTestStandbyProxy(t, c)
+	if err := p.check(time.Now().Add(2 * time.Minute)); err != nil {
+		t.Fatalf("check failed: %v", err)
+	}
+	if !p.isSuspended() {
+		t.Fatalf("container not suspended once idle")
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer l.Close()
+	go p.serve(l, echoServer(t))
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("hello\n")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatalf("ReadString failed: %v", err)
+	}
+	if line != "hello\n" {
+		t.Errorf("got %q, want %q", line, "hello\n")
+	}
+	p.mu.Lock()
+	resumes := c.resumes
+	p.mu.Unlock()
+	if resumes != 1 || p.isSuspended() {
+		t.Errorf("container not resumed by connection, resumes: %d", resumes)
+	}
+
+	// The container isn't suspended while connections are open.
+	if err := p.check(time.Now().Add(2 * time.Minute)); err != nil {
+		t.Fatalf("check failed: %v", err)
+	}
+	p.mu.Lock()
+	suspends := c.suspends
+	p.mu.Unlock()
+	if suspends != 1 {
+		t.Errorf("container suspended with an open connection")
+	}
+}
+
+func TestStandbyStopped(t *testing.T) {
+	p := &standbyProxy{
+		idleTimeout: time.Minute,
+		usage: func() (time.Duration, error) {
+			return 0, fmt.Errorf("sandbox not running")
+		},
+	}
+	if err := p.check(time.Now()); err == nil {
+		t.Errorf("check of stopped container succeeded, want error")
+	}
+}
@@ -57,7 +57,8 @@ func (*State) Execute(_ context.Context, f *flag.FlagSet, args ...interface{}) s
 	id := f.Arg(0)
 	conf := args[0].(*config.Config)
 
-	c, err := container.Load(conf.RootDir, container.FullID{ContainerID: id}, container.LoadOpts{})
+	// Don't wait for containers being created, they're reported as such.
+	c, err := container.Load(conf.RootDir, container.FullID{ContainerID: id}, container.LoadOpts{TryLock: true})
 	if err != nil {
 		Fatalf("loading container: %v", err)
 	}
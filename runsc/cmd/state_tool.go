@@ -0,0 +1,271 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/subcommands"
+	"gvisor.dev/gvisor/pkg/state/statefile"
+	"gvisor.dev/gvisor/runsc/boot"
+	"gvisor.dev/gvisor/runsc/flag"
+)
+
+// StateTool implements subcommands.Command for the "state-tool" command.
+type StateTool struct {
+	key         string
+	json        bool
+	version     int
+	compression string
+}
+
+// Name implements subcommands.Command.
+func (*StateTool) Name() string {
+	return "state-tool"
+}
+
+// Synopsis implements subcommands.Command.
+func (*StateTool) Synopsis() string {
+	return "inspects, verifies and converts checkpoint images"
+}
+
+// Usage implements subcommands.Command.
+func (*StateTool) Usage() string {
+	return `state-tool [flags] <command> <image> [output]
+
+<image> is either the image file, or the --image-path directory given to
+checkpoint. Commands:
+
+  info <image>             prints the format version and the sandbox saved in
+                           the image: container IDs, mounts, memory size and
+                           kernel version. The image is not verified.
+  verify <image>           checks the integrity of the whole image.
+  convert <image> <output> verifies the image and writes it to output using
+                           the format version given by --version, and the
+                           compression given by --compression.
+
+`
+}
+
+// SetFlags implements subcommands.Command.
+func (s *StateTool) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&s.key, "key", "", "the integrity key for the image.")
+	f.BoolVar(&s.json, "json", false, "prints info in JSON format.")
+	f.IntVar(&s.version, "version", statefile.CurrentVersion, "the format version to convert to.")
+	f.StringVar(&s.compression, "compression", string(statefile.CompressionFlate), "the compression algorithm to convert to: flate, or zstd for version 3 and later.")
+}
+
+// Execute implements subcommands.Command.Execute.
+func (s *StateTool) Execute(_ context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if f.NArg() < 2 {
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+	var key []byte
+	if s.key != "" {
+		key = []byte(s.key)
+	}
+	path := imageFile(f.Arg(1))
+
+	switch cmd := f.Arg(0); cmd {
+	case "info":
+		if f.NArg() != 2 {
+			f.Usage()
+			return subcommands.ExitUsageError
+		}
+		return s.info(path)
+	case "verify":
+		if f.NArg() != 2 {
+			f.Usage()
+			return subcommands.ExitUsageError
+		}
+		input, err := os.Open(path)
+		if err != nil {
+			return Errorf("opening image: %v", err)
+		}
+		defer input.Close()
+		size, err := copyState(ioutil.Discard, input, key)
+		if err != nil {
+			return Errorf("image %q is corrupted: %v", path, err)
+		}
+		fmt.Printf("image %q is valid, %d bytes of state\n", path, size)
+		return subcommands.ExitSuccess
+	case "convert":
+		if f.NArg() != 3 {
+			f.Usage()
+			return subcommands.ExitUsageError
+		}
+		if err := s.convert(path, imageFile(f.Arg(2)), key); err != nil {
+			return Errorf("%v", err)
+		}
+		return subcommands.ExitSuccess
+	default:
+		return Errorf("unknown command %q", cmd)
+	}
+}
+
+// imageFile returns the path of the image file given either the file or the
+// directory containing it.
+func imageFile(path string) string {
+	if fi, err := os.Stat(path); err == nil && fi.IsDir() {
+		return filepath.Join(path, checkpointFileName)
+	}
+	return path
+}
+
+// stateInfo is the output of the "info" command.
+type stateInfo struct {
+	Version     int    `json:"version"`
+	Compression string `json:"compression"`
+	boot.CheckpointInfo
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+func (s *StateTool) info(path string) subcommands.ExitStatus {
+	input, err := os.Open(path)
+	if err != nil {
+		return Errorf("opening image: %v", err)
+	}
+	defer input.Close()
+
+	metadata, err := statefile.MetadataUnsafe(input)
+	if err != nil {
+		return Errorf("reading metadata: %v", err)
+	}
+	version, err := statefile.Version(metadata)
+	if err != nil {
+		return Errorf("%v", err)
+	}
+	ci, err := boot.ParseCheckpointInfo(metadata)
+	if err != nil {
+		return Errorf("%v", err)
+	}
+	info := stateInfo{
+		Version:        version,
+		Compression:    string(statefile.CompressionOf(metadata)),
+		CheckpointInfo: *ci,
+		Metadata:       make(map[string]string),
+	}
+	// Keep the metadata not described above, e.g. the timestamp.
+	for k, v := range metadata {
+		switch k {
+		case boot.MetadataContainers, boot.MetadataInits, boot.MetadataFilesystemIDs, boot.MetadataMounts, boot.MetadataMemorySize, boot.MetadataKernelVersion, boot.MetadataSnapshot:
+		default:
+			info.Metadata[k] = v
+		}
+	}
+
+	if s.json {
+		b, err := json.MarshalIndent(&info, "", "  ")
+		if err != nil {
+			return Errorf("marshaling info: %v", err)
+		}
+		fmt.Println(string(b))
+		return subcommands.ExitSuccess
+	}
+
+	fmt.Printf("Format version: %d\n", info.Version)
+	fmt.Printf("Compression: %s\n", info.Compression)
+	if info.Snapshot != 0 {
+		fmt.Printf("Snapshot: %d\n", info.Snapshot)
+	}
+	if info.KernelVersion != "" {
+		fmt.Printf("Kernel version: %s\n", info.KernelVersion)
+	}
+	if info.MemorySize != 0 {
+		fmt.Printf("Memory size: %d bytes\n", info.MemorySize)
+	}
+	for _, cid := range info.Containers {
+		fmt.Printf("Container %s:\n", cid)
+		for _, m := range info.Mounts[cid] {
+			fmt.Printf("  %s on %s type %s (%s)\n", m.Source, m.Destination, m.Type, strings.Join(m.Options, ","))
+		}
+	}
+	keys := make([]string, 0, len(info.Metadata))
+	for k := range info.Metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Printf("%s: %s\n", k, info.Metadata[k])
+	}
+	return subcommands.ExitSuccess
+}
+
+// convert rewrites the image at path to output, using the format version of
+// s.version and the compression of s.compression. The state itself is copied as is.
+func (s *StateTool) convert(path, output string, key []byte) error {
+	input, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening image: %v", err)
+	}
+	defer input.Close()
+	metadata, err := statefile.MetadataUnsafe(input)
+	if err != nil {
+		return fmt.Errorf("reading metadata: %v", err)
+	}
+	if _, err := input.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	// Internal keys are written by the writer.
+	dedup := statefile.Deduplicated(metadata)
+	for k := range metadata {
+		if strings.HasPrefix(k, "_") {
+			delete(metadata, k)
+		}
+	}
+
+	out, err := os.OpenFile(output, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("creating output: %v", err)
+	}
+	defer out.Close()
+	w, err := statefile.NewWriterOpts(out, key, metadata, statefile.WriterOpts{
+		Version:     s.version,
+		Compression: statefile.Compression(s.compression),
+		Dedup:       dedup,
+	})
+	if err != nil {
+		os.Remove(output)
+		return fmt.Errorf("creating output: %v", err)
+	}
+	if _, err := copyState(w, input, key); err != nil {
+		os.Remove(output)
+		return fmt.Errorf("converting image: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		os.Remove(output)
+		return fmt.Errorf("writing output: %v", err)
+	}
+	return nil
+}
+
+// copyState verifies the statefile read from r and copies its state to w. It
+// returns the size of the state.
+func copyState(w io.Writer, r io.Reader, key []byte) (int64, error) {
+	sr, _, err := statefile.NewReader(r, key)
+	if err != nil {
+		return 0, err
+	}
+	return io.Copy(w, sr)
+}
@@ -0,0 +1,102 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/subcommands"
+	"gvisor.dev/gvisor/runsc/config"
+	"gvisor.dev/gvisor/runsc/container"
+	"gvisor.dev/gvisor/runsc/flag"
+)
+
+// Tune implements subcommands.Command for the "tune" command.
+type Tune struct {
+	describe bool
+}
+
+// Name implements subcommands.Command.Name.
+func (*Tune) Name() string {
+	return "tune"
+}
+
+// Synopsis implements subcommands.Command.Synopsis.
+func (*Tune) Synopsis() string {
+	return "get or set sentry tunables of a running sandbox"
+}
+
+// Usage implements subcommands.Command.Usage.
+func (*Tune) Usage() string {
+	return `tune [flags] <container id> [name[=value]...]
+
+Without names, prints all tunables. With names, prints the given tunables,
+and changes the ones followed by =value, similarly to sysctl(8). For example:
+
+  runsc tune <container id> watchdog.task_timeout=5m "net.ipv4.tcp_rmem=4096 131072 6291456"
+`
+}
+
+// SetFlags implements subcommands.Command.SetFlags.
+func (t *Tune) SetFlags(f *flag.FlagSet) {
+	f.BoolVar(&t.describe, "describe", false, "prints the description of each tunable along with its value")
+}
+
+// Execute implements subcommands.Command.Execute.
+func (t *Tune) Execute(_ context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if f.NArg() < 1 {
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+
+	id := f.Arg(0)
+	conf := args[0].(*config.Config)
+
+	c, err := container.Load(conf.RootDir, container.FullID{ContainerID: id}, container.LoadOpts{})
+	if err != nil {
+		return Errorf("loading container %q: %v", id, err)
+	}
+	if !c.IsSandboxRunning() {
+		return Errorf("container sandbox is not running")
+	}
+
+	// Set values first, then print all requested tunables, so that the output
+	// shows the values in effect.
+	var names []string
+	for _, arg := range f.Args()[1:] {
+		parts := strings.SplitN(arg, "=", 2)
+		name := strings.TrimSpace(parts[0])
+		if len(parts) == 2 {
+			if err := c.Sandbox.SetTunable(name, parts[1]); err != nil {
+				return Errorf("%v", err)
+			}
+		}
+		names = append(names, name)
+	}
+
+	tunables, err := c.Sandbox.Tunables(names)
+	if err != nil {
+		return Errorf("%v", err)
+	}
+	for _, tn := range tunables {
+		if t.describe {
+			fmt.Printf("# %s\n", tn.Description)
+		}
+		fmt.Printf("%s = %s\n", tn.Name, tn.Value)
+	}
+	return subcommands.ExitSuccess
+}
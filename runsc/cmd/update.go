@@ -0,0 +1,146 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/google/subcommands"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"gvisor.dev/gvisor/runsc/config"
+	"gvisor.dev/gvisor/runsc/container"
+	"gvisor.dev/gvisor/runsc/flag"
+)
+
+// Update implements subcommands.Command for the "update" command.
+type Update struct {
+	resources         string
+	memory            int64
+	memorySwap        int64
+	memoryReservation int64
+	cpuShares         uint64
+	cpuQuota          int64
+	cpuPeriod         uint64
+	cpusetCpus        string
+	cpusetMems        string
+	pidsLimit         int64
+}
+
+// Name implements subcommands.Command.Name.
+func (*Update) Name() string {
+	return "update"
+}
+
+// Synopsis implements subcommands.Command.Synopsis.
+func (*Update) Synopsis() string {
+	return "update the resource limits of a container"
+}
+
+// Usage implements subcommands.Command.Usage.
+func (*Update) Usage() string {
+	return `update [flags] <container id> - update the CPU, memory and pids limits of a running container.
+
+The limits are read from a file containing OCI LinuxResources JSON with
+-resources, or set with the flags below. Limits that aren't set are unchanged.
+`
+}
+
+// SetFlags implements subcommands.Command.SetFlags.
+func (u *Update) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&u.resources, "resources", "", "path of a file containing the OCI LinuxResources JSON to update, or '-' to read it from stdin. Other flags are ignored when set.")
+	f.Int64Var(&u.memory, "memory", 0, "memory limit in bytes, -1 for unlimited.")
+	f.Int64Var(&u.memorySwap, "memory-swap", 0, "memory plus swap limit in bytes, -1 for unlimited.")
+	f.Int64Var(&u.memoryReservation, "memory-reservation", 0, "memory soft limit in bytes.")
+	f.Uint64Var(&u.cpuShares, "cpu-shares", 0, "CPU shares, a relative weight.")
+	f.Int64Var(&u.cpuQuota, "cpu-quota", 0, "CPU time in microseconds the container can use in each period, -1 for unlimited.")
+	f.Uint64Var(&u.cpuPeriod, "cpu-period", 0, "length of the CPU quota period in microseconds.")
+	f.StringVar(&u.cpusetCpus, "cpuset-cpus", "", "CPUs the container can run on, e.g. 0-3.")
+	f.StringVar(&u.cpusetMems, "cpuset-mems", "", "memory nodes the container can use, e.g. 0.")
+	f.Int64Var(&u.pidsLimit, "pids-limit", 0, "maximum number of tasks.")
+}
+
+// Execute implements subcommands.Command.Execute.
+func (u *Update) Execute(_ context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if f.NArg() != 1 {
+		f.Usage()
+		return subcommands.ExitUsageError
+	}
+
+	id := f.Arg(0)
+	conf := args[0].(*config.Config)
+
+	res, err := u.linuxResources()
+	if err != nil {
+		return Errorf("%v", err)
+	}
+	c, err := container.Load(conf.RootDir, container.FullID{ContainerID: id}, container.LoadOpts{})
+	if err != nil {
+		return Errorf("loading container: %v", err)
+	}
+	if err := c.Update(res); err != nil {
+		return Errorf("%v", err)
+	}
+	return subcommands.ExitSuccess
+}
+
+// linuxResources returns the resources to update, read from the -resources
+// file if set, and from the other flags otherwise.
+func (u *Update) linuxResources() (*specs.LinuxResources, error) {
+	if u.resources != "" {
+		var r io.Reader = os.Stdin
+		if u.resources != "-" {
+			f, err := os.Open(u.resources)
+			if err != nil {
+				return nil, fmt.Errorf("opening resources file: %v", err)
+			}
+			defer f.Close()
+			r = f
+		}
+		res := &specs.LinuxResources{}
+		if err := json.NewDecoder(r).Decode(res); err != nil {
+			return nil, fmt.Errorf("parsing resources: %v", err)
+		}
+		return res, nil
+	}
+
+	res := &specs.LinuxResources{
+		CPU:    &specs.LinuxCPU{Cpus: u.cpusetCpus, Mems: u.cpusetMems},
+		Memory: &specs.LinuxMemory{},
+		Pids:   &specs.LinuxPids{Limit: u.pidsLimit},
+	}
+	if u.memory != 0 {
+		res.Memory.Limit = &u.memory
+	}
+	if u.memorySwap != 0 {
+		res.Memory.Swap = &u.memorySwap
+	}
+	if u.memoryReservation != 0 {
+		res.Memory.Reservation = &u.memoryReservation
+	}
+	if u.cpuShares != 0 {
+		res.CPU.Shares = &u.cpuShares
+	}
+	if u.cpuQuota != 0 {
+		res.CPU.Quota = &u.cpuQuota
+	}
+	if u.cpuPeriod != 0 {
+		res.CPU.Period = &u.cpuPeriod
+	}
+	return res, nil
+}
@@ -19,8 +19,14 @@ package config
 
 import (
 	"fmt"
+	"math"
+	"net"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
+	"gvisor.dev/gvisor/pkg/control/server"
 	"gvisor.dev/gvisor/pkg/refs"
 	controlpb "gvisor.dev/gvisor/pkg/sentry/control/control_go_proto"
 	"gvisor.dev/gvisor/pkg/sentry/watchdog"
@@ -57,9 +63,22 @@ type Config struct {
 	// PanicLog is the path to log GO's runtime messages, if not empty.
 	PanicLog string `flag:"panic-log"`
 
+	// DebugArchive is the directory where debug logs, panic logs and the final
+	// state of containers are moved to when the container is deleted. If
+	// empty, they are only archived with `runsc delete --keep-logs`.
+	DebugArchive string `flag:"debug-archive"`
+
+	// CrashReportDir is the directory where a crash report is written when
+	// the sandbox panics, if not empty.
+	CrashReportDir string `flag:"crash-report-dir"`
+
 	// CoverageReport is the path to write Go coverage information, if not empty.
 	CoverageReport string `flag:"coverage-report"`
 
+	// OTLPEndpoint is the URL of the OpenTelemetry collector that traces of
+	// container operations are exported to, if not empty.
+	OTLPEndpoint string `flag:"otlp-endpoint"`
+
 	// DebugLogFormat is the log format for debug.
 	DebugLogFormat string `flag:"debug-log-format"`
 
@@ -69,9 +88,30 @@ type Config struct {
 	// FileAccessMounts indicates how non-root volumes are accessed.
 	FileAccessMounts FileAccessType `flag:"file-access-mounts"`
 
+	// FileCache is the caching policy of shared volumes, i.e. how often their
+	// files are checked for external changes.
+	FileCache FileCachePolicy `flag:"file-cache"`
+
+	// FileCacheTimeout is the time for which files of shared volumes are
+	// assumed not to have changed with the loose caching policy.
+	FileCacheTimeout time.Duration `flag:"file-cache-timeout"`
+
 	// Overlay is whether to wrap the root filesystem in an overlay.
 	Overlay bool `flag:"overlay"`
 
+	// OverlaySize is the maximum size of the writable layer of each overlay
+	// mount, e.g. 1g, or empty if unlimited. See ParseOverlaySize.
+	OverlaySize string `flag:"overlay-size"`
+
+	// OverlayInodes is the maximum number of files in the writable layer of
+	// each overlay mount, or 0 if unlimited.
+	OverlayInodes uint64 `flag:"overlay-inodes"`
+
+	// OverlayUpperDir is the host directory backing the writable layer of the
+	// root overlay, or empty if it's backed by memory. Writes to the root
+	// filesystem are persisted in it across restarts of the container.
+	OverlayUpperDir string `flag:"overlay-upper-dir"`
+
 	// Verity is whether there's one or more verity file system to mount.
 	Verity bool `flag:"verity"`
 
@@ -105,12 +145,47 @@ type Config struct {
 	// for non-loopback interfaces.
 	QDisc QueueingDiscipline `flag:"qdisc"`
 
+	// XDP uses AF_XDP sockets instead of AF_PACKET sockets to exchange
+	// packets with the network devices of the sandbox, which bypasses the
+	// host network stack. Segmentation and checksum offloads are not
+	// supported with AF_XDP.
+	XDP bool `flag:"xdp"`
+
 	// LogPackets indicates that all network packets should be logged.
 	LogPackets bool `flag:"log-packets"`
 
+	// DNSCache is a comma-separated list of DNS servers, e.g.
+	// "8.8.8.8,[2001:4860:4860::8888]:53". If set, a caching resolver listens
+	// on 127.0.0.53 inside the sandbox and forwards queries it can't answer
+	// from its cache to these servers.
+	DNSCache string `flag:"dns-cache"`
+
+	// SharedMemBridge connects the sandbox to a shared memory bridge, see
+	// ParseSharedMemBridge for the format. The bridge links sandboxes on the
+	// same host without going through the host network stack.
+	SharedMemBridge string `flag:"shared-mem-bridge"`
+
+	// Record is the path of a file where nondeterministic inputs to the
+	// sandbox are recorded, to be replayed later with Replay. Only random
+	// bytes and clock reads are recorded, see package replay.
+	Record string `flag:"record"`
+
+	// Replay is the path of a file created with Record. Nondeterministic
+	// inputs are replayed from it, to rerun the container deterministically.
+	Replay string `flag:"replay"`
+
+	// Vsock enables AF_VSOCK sockets. It's either VsockHost, to use the
+	// host's vsock transport, or the absolute path of a directory containing
+	// UNIX sockets that back vsock ports. Empty disables vsock.
+	Vsock string `flag:"vsock"`
+
 	// Platform is the platform to run on.
 	Platform string `flag:"platform"`
 
+	// PlatformFallback makes sandboxes use the ptrace platform when Platform
+	// is kvm but the KVM device doesn't exist.
+	PlatformFallback bool `flag:"platform-fallback"`
+
 	// Strace indicates that strace should be enabled.
 	Strace bool `flag:"strace"`
 
@@ -160,9 +235,28 @@ type Config struct {
 	// for the duration of the container execution.
 	TraceFile string `flag:"trace"`
 
+	// LogForward forwards the stdout and stderr of containers to the host's
+	// logging daemon. Valid values are LogForwardSyslog and
+	// LogForwardJournald. Empty disables forwarding.
+	LogForward string `flag:"log-forward"`
+
+	// HookTimeout is the time OCI hooks that don't set a timeout can run
+	// before they are killed. Zero disables it.
+	HookTimeout time.Duration `flag:"hook-timeout"`
+
+	// HookRestricted runs OCI hooks with only the environment set in the
+	// spec, and without inheriting file descriptors from runsc.
+	HookRestricted bool `flag:"hook-restricted"`
+
 	// Controls defines the controls that may be enabled.
 	Controls controlConfig `flag:"controls"`
 
+	// ControlPolicy grants host users and groups access to some methods of
+	// the sandbox control server. It also applies to root and to the user
+	// running the sandbox if it names them. See server.Policy for the
+	// format.
+	ControlPolicy string `flag:"control-policy"`
+
 	// RestoreFile is the path to the saved container image
 	RestoreFile string
 
@@ -190,24 +284,83 @@ type Config struct {
 	// E.g. 0.2 CPU quota will result in 1, and 1.9 in 2.
 	CPUNumFromQuota bool `flag:"cpu-num-from-quota"`
 
+	// TimerSlack is the initial timer slack of tasks, by which their
+	// blocking timeouts may be delayed so that nearby expirations are
+	// coalesced into a single wakeup.
+	TimerSlack time.Duration `flag:"timer-slack"`
+
+	// HighResolutionTimers makes the timeouts of tasks with no timer slack
+	// expire precisely, at the cost of extra CPU usage. It requires
+	// TimerSlack to be 0.
+	HighResolutionTimers bool `flag:"high-resolution-timers"`
+
 	// Enables VFS2.
 	VFS2 bool `flag:"vfs2"`
 
 	// Enable lisafs.
 	Lisafs bool `flag:"lisafs"`
 
+	// GoferChannels is the number of connections opened to the gofer for each
+	// mount. Requests to the gofer are distributed over them.
+	GoferChannels int `flag:"gofer-channels"`
+
+	// GoferSlowOp is the latency above which gofer operations are logged,
+	// and kept in the slow operation log returned by runsc debug
+	// --gofer-stats. Zero disables it.
+	GoferSlowOp time.Duration `flag:"gofer-slow-op"`
+
+	// WatchPollInterval is the interval at which files watched with inotify
+	// on shared mounts are checked for changes made outside of the sandbox,
+	// which then generate inotify events. Zero disables it.
+	WatchPollInterval time.Duration `flag:"watch-poll-interval"`
+
 	// Enables FUSE usage.
 	FUSE bool `flag:"fuse"`
 
+	// IoctlAudit logs every ioctl request that isn't handled by the sandbox,
+	// together with the device and process that issued it.
+	IoctlAudit bool `flag:"ioctl-audit"`
+
 	// Allows overriding of flags in OCI annotations.
 	AllowFlagOverride bool `flag:"allow-flag-override"`
 
+	// OverridableFlags is the comma-separated list of flags that can be
+	// overridden in OCI annotations without AllowFlagOverride. Flags must be
+	// in the package's OverridableFlags.
+	OverridableFlags string `flag:"overridable-flags"`
+
 	// Enables seccomp inside the sandbox.
 	OCISeccomp bool `flag:"oci-seccomp"`
 
 	// Mounts the cgroup filesystem backed by the sentry's cgroupfs.
 	Cgroupfs bool `flag:"cgroupfs"`
 
+	// Systemd enables compatibility mode for running systemd as the
+	// container's init process. It mounts writable cgroup hierarchies,
+	// including the "name=systemd" hierarchy, and translates SIGTERM sent to
+	// the container into systemd's shutdown signal.
+	Systemd bool `flag:"systemd"`
+
+	// EntropySource is the path of a host device, e.g. /dev/urandom, that
+	// /dev/random and /dev/urandom read from instead of the sentry's random
+	// number generator. If empty, the sentry's generator is used.
+	EntropySource string `flag:"entropy-source"`
+
+	// GenerateHosts generates /etc/hosts for containers that don't mount
+	// one, mapping localhost and the IP addresses of the sandbox to the
+	// hostname of the container, like kubelet does for pods.
+	GenerateHosts bool `flag:"generate-hosts"`
+
+	// KernelRelease is the kernel release reported by uname(2) and
+	// /proc/version, e.g. "5.4.0". If empty, the release of the syscall
+	// table is used. See ParseKernelRelease for the supported releases.
+	KernelRelease string `flag:"kernel-release"`
+
+	// KernelVersion is the kernel version reported by uname(2) and
+	// /proc/version, e.g. "#1 SMP Sun Jan 10 15:06:54 PST 2016". If empty,
+	// the version of the syscall table is used.
+	KernelVersion string `flag:"kernel-version"`
+
 	// TestOnlyAllowRunAsCurrentUserWithoutChroot should only be used in
 	// tests. It allows runsc to start the sandbox process as the current
 	// user, and without chrooting the sandbox process. This can be
@@ -221,15 +374,86 @@ type Config struct {
 	// multiple tests are run in parallel, since there is no way to pass
 	// parameters to the runtime from docker.
 	TestOnlyTestNameEnv string `flag:"TESTONLY-test-name-env"`
+
+	// TestOnlyGoferFaults should only be used in tests. It's the path of a
+	// file with rules to inject errors into 9P operations served by the
+	// gofer, which is reloaded when it changes. See pkg/p9/faultinject for
+	// the format.
+	TestOnlyGoferFaults string `flag:"TESTONLY-gofer-faults"`
+
+	// TestOnlyInProcessGofer should only be used for development. It runs the
+	// gofer inside the sandbox process instead of a separate process, which
+	// removes the isolation between the sentry and the host filesystem. It
+	// simplifies debugging, and allows measuring the cost of the isolation.
+	TestOnlyInProcessGofer bool `flag:"TESTONLY-in-process-gofer"`
 }
 
 func (c *Config) validate() error {
+	for _, name := range c.overridableFlags() {
+		allowed := false
+		for _, f := range OverridableFlags {
+			if f == name {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("overridable-flags can't contain %q, only: %s", name, strings.Join(OverridableFlags, ", "))
+		}
+	}
 	if c.FileAccess == FileAccessShared && c.Overlay {
 		return fmt.Errorf("overlay flag is incompatible with shared file access")
 	}
+	if c.OverlaySize != "" {
+		if _, err := ParseOverlaySize(c.OverlaySize); err != nil {
+			return fmt.Errorf("invalid overlay-size %q: %v", c.OverlaySize, err)
+		}
+	}
+	if c.OverlaySize != "" || c.OverlayInodes != 0 {
+		if !c.Overlay {
+			return fmt.Errorf("overlay-size and overlay-inodes flags require --overlay")
+		}
+		if !c.VFS2 {
+			return fmt.Errorf("overlay-size and overlay-inodes flags require VFS2")
+		}
+	}
+	if c.OverlayUpperDir != "" {
+		if !c.Overlay {
+			return fmt.Errorf("overlay-upper-dir flag requires --overlay")
+		}
+		if !c.VFS2 {
+			return fmt.Errorf("overlay-upper-dir flag requires VFS2")
+		}
+		if c.Lisafs {
+			return fmt.Errorf("overlay-upper-dir flag isn't supported with lisafs")
+		}
+		if !filepath.IsAbs(c.OverlayUpperDir) {
+			return fmt.Errorf("overlay-upper-dir must be an absolute path, got: %q", c.OverlayUpperDir)
+		}
+	}
 	if c.NumNetworkChannels <= 0 {
 		return fmt.Errorf("num_network_channels must be > 0, got: %d", c.NumNetworkChannels)
 	}
+	if c.GoferChannels <= 0 {
+		return fmt.Errorf("gofer-channels must be > 0, got: %d", c.GoferChannels)
+	}
+	if c.GoferChannels > 1 && (!c.VFS2 || c.Lisafs) {
+		return fmt.Errorf("gofer-channels > 1 requires VFS2 and 9P (--vfs2=true --lisafs=false)")
+	}
+	if c.GoferSlowOp < 0 {
+		return fmt.Errorf("gofer-slow-op must be >= 0, got: %v", c.GoferSlowOp)
+	}
+	if c.HookTimeout < 0 {
+		return fmt.Errorf("hook-timeout must be >= 0, got: %v", c.HookTimeout)
+	}
+	if c.TimerSlack < 0 {
+		return fmt.Errorf("timer-slack must be >= 0, got: %v", c.TimerSlack)
+	}
+	if c.HighResolutionTimers && c.TimerSlack != 0 {
+		// Tasks would never have zero slack, since PR_SET_TIMERSLACK(0)
+		// resets it to the default.
+		return fmt.Errorf("high-resolution-timers flag requires --timer-slack=0, got: %v", c.TimerSlack)
+	}
 	// Require profile flags to explicitly opt-in to profiling with
 	// -profile rather than implying it since these options have security
 	// implications.
@@ -245,9 +469,220 @@ func (c *Config) validate() error {
 	if c.ProfileMutex != "" && !c.ProfileEnable {
 		return fmt.Errorf("profile-mutex flag requires enabling profiling with profile flag")
 	}
+	switch c.LogForward {
+	case "", LogForwardSyslog, LogForwardJournald:
+	default:
+		return fmt.Errorf("invalid log-forward %q, must be one of: %s, %s", c.LogForward, LogForwardSyslog, LogForwardJournald)
+	}
+	if c.Vsock != "" && c.Vsock != VsockHost && !filepath.IsAbs(c.Vsock) {
+		return fmt.Errorf("invalid vsock %q, must be %q or an absolute path", c.Vsock, VsockHost)
+	}
+	if c.Record != "" && c.Replay != "" {
+		return fmt.Errorf("record and replay flags are mutually exclusive")
+	}
+	if (c.Record != "" || c.Replay != "") && c.Network != NetworkNone {
+		return fmt.Errorf("record and replay flags require --network=none, network packets are not recorded")
+	}
+	if c.DNSCache != "" {
+		if c.Network != NetworkSandbox {
+			return fmt.Errorf("dns-cache flag requires --network=sandbox")
+		}
+		if _, err := ParseDNSServers(c.DNSCache); err != nil {
+			return fmt.Errorf("invalid dns-cache %q: %v", c.DNSCache, err)
+		}
+	}
+	if c.XDP {
+		if c.Network != NetworkSandbox {
+			return fmt.Errorf("xdp flag requires --network=sandbox")
+		}
+		if c.NumNetworkChannels != 1 {
+			return fmt.Errorf("xdp flag requires --num-network-channels=1")
+		}
+	}
+	if c.SharedMemBridge != "" {
+		if c.Network == NetworkHost {
+			return fmt.Errorf("shared-mem-bridge flag requires --network=sandbox or --network=none")
+		}
+		if _, _, err := ParseSharedMemBridge(c.SharedMemBridge); err != nil {
+			return fmt.Errorf("invalid shared-mem-bridge %q: %v", c.SharedMemBridge, err)
+		}
+	}
+	if c.ControlPolicy != "" {
+		if _, err := server.ParsePolicy(c.ControlPolicy); err != nil {
+			return fmt.Errorf("invalid control-policy %q: %v", c.ControlPolicy, err)
+		}
+	}
+	if c.EntropySource != "" {
+		if !filepath.IsAbs(c.EntropySource) {
+			return fmt.Errorf("invalid entropy-source %q, must be an absolute path", c.EntropySource)
+		}
+		if !c.VFS2 {
+			return fmt.Errorf("entropy-source flag requires VFS2")
+		}
+	}
+	if c.KernelRelease != "" {
+		if _, _, err := ParseKernelRelease(c.KernelRelease); err != nil {
+			return fmt.Errorf("invalid kernel-release %q: %v", c.KernelRelease, err)
+		}
+	}
+	if strings.ContainsAny(c.KernelVersion, "\x00\n") || len(c.KernelVersion) >= kernelVersionMaxLen {
+		return fmt.Errorf("invalid kernel-version %q: must be a single line shorter than %d bytes", c.KernelVersion, kernelVersionMaxLen)
+	}
+	if c.TestOnlyGoferFaults != "" && !filepath.IsAbs(c.TestOnlyGoferFaults) {
+		return fmt.Errorf("invalid TESTONLY-gofer-faults %q, must be an absolute path", c.TestOnlyGoferFaults)
+	}
+	if c.TestOnlyInProcessGofer {
+		// The sandbox process must be able to access the host filesystem.
+		if !c.TestOnlyAllowRunAsCurrentUserWithoutChroot {
+			return fmt.Errorf("TESTONLY-in-process-gofer requires TESTONLY-unsafe-nonroot")
+		}
+		if c.Lisafs {
+			return fmt.Errorf("TESTONLY-in-process-gofer doesn't support lisafs")
+		}
+	}
 	return nil
 }
 
+// ParseDNSServers parses a comma-separated list of DNS servers, as IP
+// addresses with an optional port. The port defaults to 53.
+func ParseDNSServers(s string) ([]net.UDPAddr, error) {
+	var servers []net.UDPAddr
+	for _, server := range strings.Split(s, ",") {
+		server = strings.TrimSpace(server)
+		if ip := net.ParseIP(server); ip != nil {
+			servers = append(servers, net.UDPAddr{IP: ip, Port: 53})
+			continue
+		}
+		host, port, err := net.SplitHostPort(server)
+		if err != nil {
+			return nil, err
+		}
+		ip := net.ParseIP(host)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address %q", host)
+		}
+		p, err := strconv.ParseUint(port, 10, 16)
+		if err != nil || p == 0 {
+			return nil, fmt.Errorf("invalid port %q", port)
+		}
+		servers = append(servers, net.UDPAddr{IP: ip, Port: int(p)})
+	}
+	return servers, nil
+}
+
+// ParseSharedMemBridge parses the value of the shared-mem-bridge flag,
+// "<socket path>,<address>/<prefix length>". The socket is served by
+// "runsc bridge", and the address is assigned to the sandbox interface
+// connected to the bridge.
+func ParseSharedMemBridge(s string) (string, *net.IPNet, error) {
+	i := strings.LastIndex(s, ",")
+	if i < 0 {
+		return "", nil, fmt.Errorf("want <socket path>,<address>/<prefix length>")
+	}
+	path := s[:i]
+	if !filepath.IsAbs(path) {
+		return "", nil, fmt.Errorf("socket path %q must be absolute", path)
+	}
+	ip, subnet, err := net.ParseCIDR(s[i+1:])
+	if err != nil {
+		return "", nil, err
+	}
+	subnet.IP = ip
+	return path, subnet, nil
+}
+
+// The range of kernel releases that can be reported, see ParseKernelRelease.
+// Releases older than the one the syscall table implements would hide
+// features that are supported, and much newer ones would advertise features
+// that aren't.
+const (
+	MinKernelMajor = 4
+	MinKernelMinor = 4
+	MaxKernelMajor = 5
+)
+
+// ParseOverlaySize parses the value of the overlay-size flag, a number of bytes
+// with an optional k, m or g suffix like the size option of tmpfs.
+func ParseOverlaySize(s string) (uint64, error) {
+	if s == "" {
+		return 0, fmt.Errorf("empty size")
+	}
+	shift := 0
+	switch s[len(s)-1] {
+	case 'k', 'K':
+		shift = 10
+	case 'm', 'M':
+		shift = 20
+	case 'g', 'G':
+		shift = 30
+	}
+	if shift != 0 {
+		s = s[:len(s)-1]
+	}
+	size, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if size == 0 {
+		return 0, fmt.Errorf("size must be > 0")
+	}
+	if size > math.MaxInt64>>shift {
+		return 0, fmt.Errorf("size too large")
+	}
+	return size << shift, nil
+}
+
+// kernelVersionMaxLen is the size of the release and version fields of struct
+// utsname, including the terminating NUL.
+const kernelVersionMaxLen = 65
+
+// ParseKernelRelease parses a kernel release, like "5.4.0" or
+// "5.10.0-18-amd64", and returns its major and minor numbers. Releases older
+// than MinKernelMajor.MinKernelMinor, or newer than MaxKernelMajor, are
+// rejected.
+func ParseKernelRelease(s string) (int, int, error) {
+	if len(s) >= kernelVersionMaxLen {
+		return 0, 0, fmt.Errorf("longer than %d bytes", kernelVersionMaxLen-1)
+	}
+	if strings.ContainsAny(s, " \t\n\x00") {
+		return 0, 0, fmt.Errorf("must not contain whitespace")
+	}
+	// The release starts with "<major>.<minor>", followed by anything, e.g.
+	// ".<patch>" or "-<flavor>".
+	parts := strings.SplitN(s, ".", 3)
+	if len(parts) < 2 {
+		return 0, 0, fmt.Errorf("want <major>.<minor>[.<patch>][<suffix>]")
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid major number %q", parts[0])
+	}
+	minorStr := parts[1]
+	if i := strings.IndexFunc(minorStr, func(r rune) bool { return r < '0' || r > '9' }); i >= 0 {
+		minorStr = minorStr[:i]
+	}
+	minor, err := strconv.Atoi(minorStr)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid minor number %q", parts[1])
+	}
+	if major < MinKernelMajor || (major == MinKernelMajor && minor < MinKernelMinor) || major > MaxKernelMajor {
+		return 0, 0, fmt.Errorf("release %d.%d not supported, must be between %d.%d and %d.x", major, minor, MinKernelMajor, MinKernelMinor, MaxKernelMajor)
+	}
+	return major, minor, nil
+}
+
+// VsockHost passes AF_VSOCK sockets through to the host's vsock transport.
+const VsockHost = "host"
+
+const (
+	// LogForwardSyslog forwards container output to syslog using /dev/log.
+	LogForwardSyslog = "syslog"
+
+	// LogForwardJournald forwards container output to journald using its
+	// native protocol.
+	LogForwardJournald = "journald"
+)
+
 // FileAccessType tells how the filesystem is accessed.
 type FileAccessType int
 
@@ -301,6 +736,63 @@ func (f FileAccessType) String() string {
 	panic(fmt.Sprintf("Invalid file access type %d", f))
 }
 
+// FileCachePolicy tells how files of shared volumes are cached.
+type FileCachePolicy int
+
+const (
+	// FileCacheExact checks cached files for external changes every time
+	// they are accessed. This is the default.
+	FileCacheExact FileCachePolicy = iota
+
+	// FileCacheLoose checks cached files for external changes at most once
+	// per FileCacheTimeout. External changes may not be visible until then,
+	// but repeated accesses to the same files don't require a round trip to
+	// the gofer.
+	FileCacheLoose
+
+	// FileCacheNone checks files for external changes like FileCacheExact,
+	// and also disables the page cache of regular files, so that reads and
+	// writes always go to the gofer.
+	FileCacheNone
+)
+
+func fileCachePolicyPtr(v FileCachePolicy) *FileCachePolicy {
+	return &v
+}
+
+// Set implements flag.Value.
+func (f *FileCachePolicy) Set(v string) error {
+	switch v {
+	case "exact":
+		*f = FileCacheExact
+	case "loose":
+		*f = FileCacheLoose
+	case "none":
+		*f = FileCacheNone
+	default:
+		return fmt.Errorf("invalid file cache policy %q", v)
+	}
+	return nil
+}
+
+// Get implements flag.Value.
+func (f *FileCachePolicy) Get() interface{} {
+	return *f
+}
+
+// String implements flag.Value.
+func (f FileCachePolicy) String() string {
+	switch f {
+	case FileCacheExact:
+		return "exact"
+	case FileCacheLoose:
+		return "loose"
+	case FileCacheNone:
+		return "none"
+	}
+	panic(fmt.Sprintf("Invalid file cache policy %d", f))
+}
+
 // NetworkType tells which network stack to use.
 type NetworkType int
 
@@ -426,6 +918,8 @@ func (c *controlConfig) Set(v string) error {
 			controlList = append(controlList, controlpb.ControlConfig_STATE)
 		case "DEBUG":
 			controlList = append(controlList, controlpb.ControlConfig_DEBUG)
+		case "TUNABLES":
+			controlList = append(controlList, controlpb.ControlConfig_TUNABLES)
 		default:
 			return fmt.Errorf("invalid control %q", control)
 		}
@@ -465,6 +959,8 @@ func (c *controlConfig) String() string {
 			v += "STATE"
 		case controlpb.ControlConfig_DEBUG:
 			v += "DEBUG"
+		case controlpb.ControlConfig_TUNABLES:
+			v += "TUNABLES"
 		default:
 			panic(fmt.Sprintf("Invalid control %d", control))
 		}
@@ -484,6 +980,7 @@ func defaultControlConfig() *controlConfig {
 	c.Controls.AllowedControls = append(c.Controls.AllowedControls, controlpb.ControlConfig_PROC)
 	c.Controls.AllowedControls = append(c.Controls.AllowedControls, controlpb.ControlConfig_STATE)
 	c.Controls.AllowedControls = append(c.Controls.AllowedControls, controlpb.ControlConfig_DEBUG)
+	c.Controls.AllowedControls = append(c.Controls.AllowedControls, controlpb.ControlConfig_TUNABLES)
 	return &c
 }
 
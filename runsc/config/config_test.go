@@ -157,6 +157,10 @@ func TestInvalidFlags(t *testing.T) {
 			name:  "file-access",
 			error: "invalid file access type",
 		},
+		{
+			name:  "file-cache",
+			error: "invalid file cache policy",
+		},
 		{
 			name:  "network",
 			error: "invalid network type",
@@ -189,6 +193,13 @@ func TestValidationFail(t *testing.T) {
 		flags map[string]string
 		error string
 	}{
+		{
+			name: "overridable-flags",
+			flags: map[string]string{
+				"overridable-flags": "debug,root",
+			},
+			error: "overridable-flags can't contain \"root\"",
+		},
 		{
 			name: "shared+overlay",
 			flags: map[string]string{
@@ -197,6 +208,36 @@ func TestValidationFail(t *testing.T) {
 			},
 			error: "overlay flag is incompatible",
 		},
+		{
+			name: "overlay-size",
+			flags: map[string]string{
+				"overlay":      "true",
+				"overlay-size": "1t",
+			},
+			error: "invalid overlay-size",
+		},
+		{
+			name: "overlay-inodes-no-overlay",
+			flags: map[string]string{
+				"overlay-inodes": "1000",
+			},
+			error: "require --overlay",
+		},
+		{
+			name: "overlay-upper-dir-no-overlay",
+			flags: map[string]string{
+				"overlay-upper-dir": "/upper",
+			},
+			error: "overlay-upper-dir flag requires --overlay",
+		},
+		{
+			name: "overlay-upper-dir-relative",
+			flags: map[string]string{
+				"overlay":           "true",
+				"overlay-upper-dir": "upper",
+			},
+			error: "overlay-upper-dir must be an absolute path",
+		},
 		{
 			name: "network-channels",
 			flags: map[string]string{
@@ -204,6 +245,177 @@ func TestValidationFail(t *testing.T) {
 			},
 			error: "num_network_channels must be > 0",
 		},
+		{
+			name: "log-forward",
+			flags: map[string]string{
+				"log-forward": "stderr",
+			},
+			error: "invalid log-forward",
+		},
+		{
+			name: "vsock",
+			flags: map[string]string{
+				"vsock": "relative/dir",
+			},
+			error: "invalid vsock",
+		},
+		{
+			name: "dns-cache-network",
+			flags: map[string]string{
+				"dns-cache": "8.8.8.8",
+				"network":   "host",
+			},
+			error: "dns-cache flag requires --network=sandbox",
+		},
+		{
+			name: "dns-cache-servers",
+			flags: map[string]string{
+				"dns-cache": "8.8.8.8,dns.google",
+			},
+			error: "invalid dns-cache",
+		},
+		{
+			name: "xdp-network",
+			flags: map[string]string{
+				"xdp":     "true",
+				"network": "none",
+			},
+			error: "xdp flag requires --network=sandbox",
+		},
+		{
+			name: "xdp-channels",
+			flags: map[string]string{
+				"xdp":                  "true",
+				"num-network-channels": "2",
+			},
+			error: "xdp flag requires --num-network-channels=1",
+		},
+		{
+			name: "shared-mem-bridge-network",
+			flags: map[string]string{
+				"shared-mem-bridge": "/run/bridge.sock,10.0.0.2/24",
+				"network":           "host",
+			},
+			error: "shared-mem-bridge flag requires --network=sandbox or --network=none",
+		},
+		{
+			name: "shared-mem-bridge-address",
+			flags: map[string]string{
+				"shared-mem-bridge": "/run/bridge.sock,10.0.0.2",
+			},
+			error: "invalid shared-mem-bridge",
+		},
+		{
+			name: "gofer-channels",
+			flags: map[string]string{
+				"gofer-channels": "0",
+			},
+			error: "gofer-channels must be > 0",
+		},
+		{
+			name: "gofer-channels-lisafs",
+			flags: map[string]string{
+				"gofer-channels": "2",
+				"lisafs":         "true",
+			},
+			error: "gofer-channels > 1 requires VFS2 and 9P",
+		},
+		{
+			name: "hook-timeout",
+			flags: map[string]string{
+				"hook-timeout": "-1s",
+			},
+			error: "hook-timeout must be >= 0",
+		},
+		{
+			name: "timer-slack",
+			flags: map[string]string{
+				"timer-slack": "-1us",
+			},
+			error: "timer-slack must be >= 0",
+		},
+		{
+			name: "high-resolution-timers",
+			flags: map[string]string{
+				"high-resolution-timers": "true",
+			},
+			error: "high-resolution-timers flag requires --timer-slack=0",
+		},
+		{
+			name: "in-process-gofer",
+			flags: map[string]string{
+				"TESTONLY-in-process-gofer": "true",
+			},
+			error: "TESTONLY-in-process-gofer requires TESTONLY-unsafe-nonroot",
+		},
+		{
+			name: "control-policy",
+			flags: map[string]string{
+				"control-policy": "user=1000:*",
+			},
+			error: "invalid control-policy",
+		},
+		{
+			name: "record-replay",
+			flags: map[string]string{
+				"record":  "/tmp/record",
+				"replay":  "/tmp/record",
+				"network": "none",
+			},
+			error: "record and replay flags are mutually exclusive",
+		},
+		{
+			name: "replay-network",
+			flags: map[string]string{
+				"replay":  "/tmp/record",
+				"network": "sandbox",
+			},
+			error: "record and replay flags require --network=none",
+		},
+		{
+			name: "record-network",
+			flags: map[string]string{
+				"record":  "/tmp/record",
+				"network": "sandbox",
+			},
+			error: "record and replay flags require --network=none",
+		},
+		{
+			name: "gofer-faults",
+			flags: map[string]string{
+				"TESTONLY-gofer-faults": "relative/rules",
+			},
+			error: "invalid TESTONLY-gofer-faults",
+		},
+		{
+			name: "entropy-source",
+			flags: map[string]string{
+				"entropy-source": "dev/urandom",
+			},
+			error: "invalid entropy-source",
+		},
+		{
+			name: "entropy-source-vfs1",
+			flags: map[string]string{
+				"entropy-source": "/dev/urandom",
+				"vfs2":           "false",
+			},
+			error: "entropy-source flag requires VFS2",
+		},
+		{
+			name: "kernel-release",
+			flags: map[string]string{
+				"kernel-release": "3.10.0",
+			},
+			error: "invalid kernel-release",
+		},
+		{
+			name: "kernel-version",
+			flags: map[string]string{
+				"kernel-version": "#1 SMP\nfoo",
+			},
+			error: "invalid kernel-version",
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			for name, val := range tc.flags {
@@ -269,6 +481,54 @@ func TestOverrideDisabled(t *testing.T) {
 	if err := c.Override("root", "path"); err == nil || !strings.Contains(err.Error(), errMsg) {
 		t.Errorf("Override() wrong error: %v", err)
 	}
+	// The host directory backing the root overlay isn't overridable, otherwise
+	// annotations could make the gofer write anywhere on the host.
+	if err := c.Override("overlay-upper-dir", "/etc"); err == nil || !strings.Contains(err.Error(), errMsg) {
+		t.Errorf("Override(overlay-upper-dir) wrong error: %v", err)
+	}
+	// Flags must be allowed by the operator to be overridden.
+	if err := c.Override("network", "host"); err == nil || !strings.Contains(err.Error(), errMsg) {
+		t.Errorf("Override(network) wrong error: %v", err)
+	}
+	c.OverridableFlags = "debug"
+	if err := c.Override("network", "host"); err == nil || !strings.Contains(err.Error(), errMsg) {
+		t.Errorf("Override(network) with --overridable-flags=debug wrong error: %v", err)
+	}
+}
+
+func TestOverrideAllowed(t *testing.T) {
+	c, err := NewFromFlags()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.AllowFlagOverride = false
+	c.OverridableFlags = "debug,file-access,network,platform"
+
+	for _, tc := range []struct {
+		name  string
+		value string
+	}{
+		{name: "debug", value: "true"},
+		{name: "file-access", value: "shared"},
+		{name: "network", value: "none"},
+		{name: "platform", value: "kvm"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := c.Override(tc.name, tc.value); err != nil {
+				t.Errorf("Override(%q, %q) failed: %v", tc.name, tc.value, err)
+			}
+			defer setDefault(tc.name)
+		})
+	}
+	if c.Network != NetworkNone || c.Platform != "kvm" || c.FileAccess != FileAccessShared || !c.Debug {
+		t.Errorf("Override() didn't work: %+v", c)
+	}
+
+	// Overrides are validated.
+	c.Overlay = true
+	if err := c.Override("file-access", "shared"); err == nil || !strings.Contains(err.Error(), "overlay flag is incompatible") {
+		t.Errorf("Override(file-access, shared) with overlay wrong error: %v", err)
+	}
 }
 
 func TestOverrideError(t *testing.T) {
@@ -305,3 +565,81 @@ func TestOverrideError(t *testing.T) {
 		})
 	}
 }
+
+func TestParseDNSServers(t *testing.T) {
+	got, err := ParseDNSServers("8.8.8.8, 10.0.0.1:5353,[2001:db8::1]:53,2001:db8::2")
+	if err != nil {
+		t.Fatalf("ParseDNSServers() failed: %v", err)
+	}
+	want := []string{"8.8.8.8:53", "10.0.0.1:5353", "[2001:db8::1]:53", "[2001:db8::2]:53"}
+	if len(got) != len(want) {
+		t.Fatalf("ParseDNSServers() got %v, want %v", got, want)
+	}
+	for i := range got {
+		if s := got[i].String(); s != want[i] {
+			t.Errorf("ParseDNSServers()[%d] got %q, want %q", i, s, want[i])
+		}
+	}
+
+	for _, s := range []string{"", "dns.google", "8.8.8.8:0", "8.8.8.8:dns", "8.8.8.8:65536"} {
+		if _, err := ParseDNSServers(s); err == nil {
+			t.Errorf("ParseDNSServers(%q) succeeded, want error", s)
+		}
+	}
+}
+
+func TestParseKernelRelease(t *testing.T) {
+	for _, tc := range []struct {
+		release string
+		major   int
+		minor   int
+	}{
+		{release: "4.4.0", major: 4, minor: 4},
+		{release: "4.19", major: 4, minor: 19},
+		{release: "5.4.0-1059-gke", major: 5, minor: 4},
+		{release: "5.10.0-18-amd64", major: 5, minor: 10},
+		{release: "5.15+", major: 5, minor: 15},
+	} {
+		major, minor, err := ParseKernelRelease(tc.release)
+		if err != nil {
+			t.Errorf("ParseKernelRelease(%q) failed: %v", tc.release, err)
+			continue
+		}
+		if major != tc.major || minor != tc.minor {
+			t.Errorf("ParseKernelRelease(%q) got %d.%d, want %d.%d", tc.release, major, minor, tc.major, tc.minor)
+		}
+	}
+
+	for _, s := range []string{"", "5", "4.3.0", "3.10.0", "6.0.0", "x.4", "5.x", "5.4 .0", strings.Repeat("5.4", 30)} {
+		if _, _, err := ParseKernelRelease(s); err == nil {
+			t.Errorf("ParseKernelRelease(%q) succeeded, want error", s)
+		}
+	}
+}
+
+func TestParseOverlaySize(t *testing.T) {
+	for _, tc := range []struct {
+		size string
+		want uint64
+	}{
+		{size: "4096", want: 4096},
+		{size: "512k", want: 512 << 10},
+		{size: "64M", want: 64 << 20},
+		{size: "1g", want: 1 << 30},
+	} {
+		got, err := ParseOverlaySize(tc.size)
+		if err != nil {
+			t.Errorf("ParseOverlaySize(%q) failed: %v", tc.size, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("ParseOverlaySize(%q) got %d, want %d", tc.size, got, tc.want)
+		}
+	}
+
+	for _, s := range []string{"", "0", "g", "-1", "1t", "1.5g", "99999999999g"} {
+		if _, err := ParseOverlaySize(s); err == nil {
+			t.Errorf("ParseOverlaySize(%q) succeeded, want error", s)
+		}
+	}
+}
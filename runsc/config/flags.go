@@ -20,6 +20,8 @@ import (
 	"path/filepath"
 	"reflect"
 	"strconv"
+	"strings"
+	"time"
 
 	"gvisor.dev/gvisor/pkg/refs"
 	"gvisor.dev/gvisor/pkg/sentry/watchdog"
@@ -43,13 +45,19 @@ func RegisterFlags() {
 		// system that are not covered by the runtime spec.
 
 		// Debugging flags.
-		flag.String("debug-log", "", "additional location for logs. If it ends with '/', log files are created inside the directory with default names. The following variables are available: %TIMESTAMP%, %COMMAND%.")
+		flag.String("debug-log", "", "additional location for logs. If it ends with '/', log files are created inside the directory with default names. The following variables are available: %TIMESTAMP%, %COMMAND%, %ID% (container ID).")
 		flag.String("panic-log", "", "file path where panic reports and other Go's runtime messages are written.")
+		flag.String("debug-archive", "", "directory where debug logs, panic logs and the final container state are moved to when the container is deleted, for post-mortem analysis.")
+		flag.String("crash-report-dir", "", "directory where a compressed crash report, with the panic message, goroutine stacks, recent log records and configuration, is written when the sandbox panics.")
 		flag.String("coverage-report", "", "file path where Go coverage reports are written. Reports will only be generated if runsc is built with --collect_code_coverage and --instrumentation_filter Bazel flags.")
+		flag.String("otlp-endpoint", "", "URL of an OpenTelemetry collector, e.g. http://localhost:4318, to export traces of container operations to using OTLP over HTTP. The parent span is read from the TRACEPARENT environment variable.")
 		flag.Bool("log-packets", false, "enable network packet logging.")
+		flag.String("record", "", "file path where nondeterministic inputs to the sandbox are recorded, to be replayed with --replay. Only random bytes and time are recorded, not file contents or host FD I/O. Requires --network=none.")
+		flag.String("replay", "", "file path of inputs recorded with --record, to replay them and rerun the container. The run is deterministic only if files and host FDs read by the container are the same as when recording. Requires --network=none.")
 		flag.String("debug-log-format", "text", "log format: text (default), json, or json-k8s.")
 		flag.Bool("alsologtostderr", false, "send log messages to stderr.")
-		flag.Bool("allow-flag-override", false, "allow OCI annotations (dev.gvisor.flag.<name>) to override flags for debugging.")
+		flag.Bool("allow-flag-override", false, "allow OCI annotations (dev.gvisor.flag/<name>) to override any flag for debugging.")
+		flag.String("overridable-flags", "", "comma-separated list of flags that OCI annotations (dev.gvisor.flag/<name>) can override per container, without --allow-flag-override. Only the following flags can be listed: "+strings.Join(OverridableFlags, ", ")+".")
 		flag.String("traceback", "system", "golang runtime's traceback level")
 
 		// Debugging flags: strace related
@@ -60,6 +68,7 @@ func RegisterFlags() {
 
 		// Flags that control sandbox runtime behavior.
 		flag.String("platform", "ptrace", "specifies which platform to use: ptrace (default), kvm.")
+		flag.Bool("platform-fallback", true, "use the ptrace platform if --platform=kvm but /dev/kvm doesn't exist.")
 		flag.Var(watchdogActionPtr(watchdog.LogWarning), "watchdog-action", "sets what action the watchdog takes when triggered: log (default), panic.")
 		flag.Int("panic-signal", -1, "register signal handling that panics. Usually set to SIGUSR2(12) to troubleshoot hangs. -1 disables it.")
 		flag.Bool("profile", false, "prepares the sandbox to use Golang profiler. Note that enabling profiler loosens the seccomp protection added to the sandbox (DO NOT USE IN PRODUCTION).")
@@ -68,22 +77,42 @@ func RegisterFlags() {
 		flag.String("profile-heap", "", "collects a heap profile to this file path for the duration of the container execution. Requires -profile=true.")
 		flag.String("profile-mutex", "", "collects a mutex profile to this file path for the duration of the container execution. Requires -profile=true.")
 		flag.String("trace", "", "collects a Go runtime execution trace to this file path for the duration of the container execution.")
+		flag.String("log-forward", "", "forwards container stdout and stderr to the host's logging daemon: syslog, journald. Use the dev.gvisor.spec.log-tag annotation to set the identifier of messages, which defaults to the container ID.")
+		flag.Duration("hook-timeout", 2*time.Minute, "time OCI hooks that don't set a timeout in the spec can run before they are killed and fail. 0 disables it.")
+		flag.Bool("hook-restricted", false, "runs OCI hooks with only the environment set in the spec, in the root directory, and without inheriting file descriptors from runsc.")
 		flag.Bool("rootless", false, "it allows the sandbox to be started with a user that is not root. Sandbox and Gofer processes may run with same privileges as current user.")
 		flag.Var(leakModePtr(refs.NoLeakChecking), "ref-leak-mode", "sets reference leak check mode: disabled (default), log-names, log-traces.")
 		flag.Bool("cpu-num-from-quota", false, "set cpu number to cpu quota (least integer greater or equal to quota value, but not less than 2)")
+		flag.Duration("timer-slack", 50*time.Microsecond, "initial timer slack of tasks, as set by prctl(PR_SET_TIMERSLACK). Blocking timeouts are rounded up to a multiple of it, so that nearby expirations wake up together. 0 disables coalescing.")
+		flag.Bool("high-resolution-timers", false, "makes blocking timeouts of tasks with no timer slack expire precisely. Requires --timer-slack=0. The task goroutine spins, yielding to other goroutines, for the last millisecond of every timed wait, which uses CPU for that time on each timeout.")
 		flag.Bool("oci-seccomp", false, "Enables loading OCI seccomp filters inside the sandbox.")
 		flag.Var(defaultControlConfig(), "controls", "Sentry control endpoints.")
+		flag.String("control-policy", "", "grants host users and groups access to the sandbox control server, including root and the user running the sandbox if named, e.g. 'uid=1000,gid=100:containerManager.Processes,containerManager.Event;uid=1001:*'.")
 
 		// Flags that control sandbox runtime behavior: FS related.
 		flag.Var(fileAccessTypePtr(FileAccessExclusive), "file-access", "specifies which filesystem validation to use for the root mount: exclusive (default), shared.")
 		flag.Var(fileAccessTypePtr(FileAccessShared), "file-access-mounts", "specifies which filesystem validation to use for volumes other than the root mount: shared (default), exclusive.")
+		flag.Var(fileCachePolicyPtr(FileCacheExact), "file-cache", "caching policy of shared mounts: exact (default) checks cached files for external changes on every access, loose checks them at most once per --file-cache-timeout, none also disables the page cache of regular files.")
+		flag.Duration("file-cache-timeout", time.Second, "time for which files of shared mounts are assumed not to have changed with --file-cache=loose. Only supported with VFS2.")
 		flag.Bool("overlay", false, "wrap filesystem mounts with writable overlay. All modifications are stored in memory inside the sandbox.")
+		flag.String("overlay-size", "", "maximum size of the writable layer of each overlay mount, e.g. 512m or 1g. Writes past it fail with ENOSPC. Requires --overlay and VFS2. Can be set per container with the dev.gvisor.flag.overlay-size annotation.")
+		flag.Uint64("overlay-inodes", 0, "maximum number of files in the writable layer of each overlay mount, 0 for unlimited. Creating files past it fails with ENOSPC. Requires --overlay and VFS2. Can be set per container with the dev.gvisor.flag.overlay-inodes annotation.")
+		flag.String("overlay-upper-dir", "", "host directory backing the writable layer of the root overlay instead of memory, so that writes to the root filesystem persist across restarts of the container. Each container uses the subdirectory named after its ID, which is created if it doesn't exist. Requires --overlay and VFS2.")
 		flag.Bool("verity", false, "specifies whether a verity file system will be mounted.")
 		flag.Bool("fsgofer-host-uds", false, "allow the gofer to mount Unix Domain Sockets.")
 		flag.Bool("vfs2", true, "enables VFSv2. This uses the new VFS layer that is faster than the previous one.")
 		flag.Bool("fuse", false, "TEST ONLY; use while FUSE in VFSv2 is landing. This allows the use of the new experimental FUSE filesystem.")
+		flag.Bool("ioctl-audit", false, "logs all ioctls that aren't handled by the sandbox, with the device and caller. Only supported with VFS2.")
 		flag.Bool("lisafs", false, "Enables lisafs protocol instead of 9P. This is only effective with VFS2.")
+		flag.Int("gofer-channels", 1, "number of connections to the gofer for each mount. Requests are distributed over them, so that concurrent file operations aren't serialized on a single connection. Only supported with VFS2 and 9P.")
+		flag.Duration("gofer-slow-op", 100*time.Millisecond, "gofer operations taking longer than this are logged with the path of their file, and returned by runsc debug --gofer-stats. 0 disables it. Only supported with 9P.")
+		flag.Duration("watch-poll-interval", 0, "interval at which files watched with inotify on shared mounts are checked for changes made outside of the sandbox, e.g. Kubernetes ConfigMap and Secret updates, so that they generate inotify events. 0 disables it. Only supported with VFS2.")
 		flag.Bool("cgroupfs", false, "Automatically mount cgroupfs.")
+		flag.String("entropy-source", "", "path of a host device, e.g. /dev/urandom, that /dev/random and /dev/urandom read from instead of the sentry's random number generator, for entropy source requirements. Only supported with VFS2.")
+		flag.Bool("generate-hosts", false, "generates /etc/hosts for containers that don't mount one, mapping the IP addresses of the sandbox to the container's hostname, like kubelet does for pods.")
+		flag.String("kernel-release", "", "kernel release reported by uname(2) and /proc/version, e.g. 5.4.0, for applications that check the kernel version to select features. Must be between 4.4 and 5.x. Can be set per sandbox with the dev.gvisor.flag.kernel-release annotation.")
+		flag.String("kernel-version", "", "kernel version reported by uname(2) and /proc/version, e.g. \"#1 SMP Sun Jan 10 15:06:54 PST 2016\". Can be set per sandbox with the dev.gvisor.flag.kernel-version annotation.")
+		flag.Bool("systemd", false, "enables compatibility mode to run systemd as the container's init process. Can be set per container with the dev.gvisor.spec.systemd annotation.")
 
 		// Flags that control sandbox runtime behavior: network related.
 		flag.Var(networkTypePtr(NetworkSandbox), "network", "specifies which network to use: sandbox (default), host, none. Using network inside the sandbox is more secure because it's isolated from the host network.")
@@ -94,11 +123,17 @@ func RegisterFlags() {
 		flag.Bool("rx-checksum-offload", true, "enable RX checksum offload.")
 		flag.Var(queueingDisciplinePtr(QDiscFIFO), "qdisc", "specifies which queueing discipline to apply by default to the non loopback nics used by the sandbox.")
 		flag.Int("num-network-channels", 1, "number of underlying channels(FDs) to use for network link endpoints.")
+		flag.Bool("xdp", false, "use AF_XDP sockets instead of AF_PACKET sockets for network devices, bypassing the host network stack. Packets must be received on the first queue of each device.")
+		flag.String("dns-cache", "", "comma-separated list of DNS servers, with optional ports. If set, a caching DNS resolver listening on 127.0.0.53 inside the sandbox forwards queries to them. Applications must be configured to use it, e.g. with docker run --dns=127.0.0.53.")
+		flag.String("shared-mem-bridge", "", "connects the sandbox to a shared memory bridge served by runsc bridge, as <socket path>,<address>/<prefix length>. The address is assigned to the interface connected to the bridge.")
+		flag.String("vsock", "", "enables AF_VSOCK sockets: host, to use the host's vsock transport, or the path of a directory with UNIX sockets named vsock_<port> that back connections to the host's ports.")
 
 		// Test flags, not to be used outside tests, ever.
 		flag.Bool("TESTONLY-unsafe-nonroot", false, "TEST ONLY; do not ever use! This skips many security measures that isolate the host from the sandbox.")
 		flag.String("TESTONLY-test-name-env", "", "TEST ONLY; do not ever use! Used for automated tests to improve logging.")
 		flag.Bool("TESTONLY-allow-packet-endpoint-write", false, "TEST ONLY; do not ever use! Used for tests to allow writes on packet sockets.")
+		flag.String("TESTONLY-gofer-faults", "", "TEST ONLY; do not ever use! Path of a file with rules to inject errors into gofer operations.")
+		flag.Bool("TESTONLY-in-process-gofer", false, "TEST ONLY; do not ever use! Runs the gofer inside the sandbox process, without isolation or syscall filters. Used for debugging and to measure the gofer isolation overhead.")
 	})
 }
 
@@ -164,10 +199,44 @@ func (c *Config) ToFlags() []string {
 	return rv
 }
 
-// Override writes a new value to a flag.
+// OverridableFlags are the flags that operators can allow to be overridden per
+// container with annotations, by listing them in --overridable-flags, without
+// setting AllowFlagOverride.
+var OverridableFlags = []string{
+	"debug",
+	"file-access",
+	"kernel-release",
+	"kernel-version",
+	"network",
+	"overlay-inodes",
+	"overlay-size",
+	"platform",
+}
+
+// overridableFlags returns the flags listed in --overridable-flags.
+func (c *Config) overridableFlags() []string {
+	if c.OverridableFlags == "" {
+		return nil
+	}
+	return strings.Split(c.OverridableFlags, ",")
+}
+
+// isOverridable returns true if the flag can be overridden without
+// AllowFlagOverride.
+func (c *Config) isOverridable(name string) bool {
+	for _, f := range c.overridableFlags() {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Override writes a new value to a flag. Unless AllowFlagOverride is set, only
+// the flags listed in --overridable-flags can be overridden.
 func (c *Config) Override(name string, value string) error {
-	if !c.AllowFlagOverride {
-		return fmt.Errorf("flag override disabled, use --allow-flag-override to enable it")
+	if !c.AllowFlagOverride && !c.isOverridable(name) {
+		return fmt.Errorf("flag override disabled for %q, add it to --overridable-flags or use --allow-flag-override to enable it", name)
 	}
 
 	obj := reflect.ValueOf(c).Elem()
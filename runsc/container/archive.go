@@ -0,0 +1,101 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package container
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/sys/unix"
+	"gvisor.dev/gvisor/pkg/log"
+)
+
+// ArchiveDebugArtifacts moves the debug logs and panic logs owned by the
+// container into a new directory under dir, together with a snapshot of the
+// container's final state. It's meant to be called right before the container
+// is destroyed, to allow for post-mortem analysis. Processes that still write
+// to the logs keep writing to the archived files, unless they had to be copied
+// to another filesystem. Returns the directory where artifacts were archived.
+//
+// Logs for the sandbox are owned by the root container, subcontainers only
+// own their gofer logs.
+func (c *Container) ArchiveDebugArtifacts(dir string) (string, error) {
+	archive := filepath.Join(dir, fmt.Sprintf("%s-%s", c.ID, time.Now().Format("20060102-150405.000000")))
+	if err := os.MkdirAll(archive, 0750); err != nil {
+		return "", fmt.Errorf("creating archive directory: %v", err)
+	}
+	log.Infof("Archiving debug artifacts for container %q to %q", c.ID, archive)
+
+	state, err := json.MarshalIndent(c.State(), "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := ioutil.WriteFile(filepath.Join(archive, "state.json"), state, 0640); err != nil {
+		return "", fmt.Errorf("writing state snapshot: %v", err)
+	}
+	meta, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := ioutil.WriteFile(filepath.Join(archive, "container.json"), meta, 0640); err != nil {
+		return "", fmt.Errorf("writing container metadata: %v", err)
+	}
+
+	for _, src := range c.DebugLogs {
+		dst := filepath.Join(archive, filepath.Base(src))
+		if err := moveFile(src, dst); err != nil {
+			if os.IsNotExist(err) {
+				// Logs may have been rotated or removed already.
+				log.Warningf("Debug log %q not found, skipping", src)
+				continue
+			}
+			return "", fmt.Errorf("archiving %q: %v", src, err)
+		}
+	}
+	return archive, nil
+}
+
+// moveFile renames src to dst, falling back to copying the file when they are
+// on different filesystems.
+func moveFile(src, dst string) error {
+	err := os.Rename(src, dst)
+	if err == nil || !errors.Is(err, unix.EXDEV) {
+		return err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0640)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
@@ -0,0 +1,235 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package container
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"golang.org/x/sys/unix"
+	"gvisor.dev/gvisor/pkg/p9/faultinject"
+	"gvisor.dev/gvisor/pkg/sentry/control"
+	"gvisor.dev/gvisor/pkg/test/testutil"
+	"gvisor.dev/gvisor/runsc/boot"
+	"gvisor.dev/gvisor/runsc/config"
+)
+
+// startChaosContainer creates and starts a container running spec. The
+// returned function destroys it.
+func startChaosContainer(t *testing.T, spec *specs.Spec, conf *config.Config) (*Container, func()) {
+	t.Helper()
+	_, bundleDir, cleanup, err := testutil.SetupContainer(spec, conf)
+	if err != nil {
+		t.Fatalf("error setting up container: %v", err)
+	}
+	args := Args{
+		ID:        testutil.RandomContainerID(),
+		Spec:      spec,
+		BundleDir: bundleDir,
+	}
+	c, err := New(conf, args)
+	if err != nil {
+		cleanup()
+		t.Fatalf("error creating container: %v", err)
+	}
+	destroy := func() {
+		c.Destroy()
+		cleanup()
+	}
+	if err := c.Start(conf); err != nil {
+		destroy()
+		t.Fatalf("error starting container: %v", err)
+	}
+	expectedPL := []*control.Process{newProcessBuilder().Cmd("sleep").Process()}
+	if err := waitForProcessList(c, expectedPL); err != nil {
+		destroy()
+		t.Fatalf("waiting for sleep to start: %v", err)
+	}
+	return c, destroy
+}
+
+// TestChaosGoferFaults checks that errors injected into 9P operations reach
+// the application, and that the sandbox keeps working once they stop.
+func TestChaosGoferFaults(t *testing.T) {
+	for name, conf := range configs(t, noOverlay...) {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir(testutil.TmpDir(), "gofer-faults")
+			if err != nil {
+				t.Fatalf("ioutil.TempDir() failed: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			if err := os.Chmod(dir, 0777); err != nil {
+				t.Fatalf("chmod(%q) failed: %v", dir, err)
+			}
+			mnt := filepath.Join(dir, "mnt")
+			if err := os.Mkdir(mnt, 0777); err != nil {
+				t.Fatalf("mkdir(%q) failed: %v", mnt, err)
+			}
+			for _, name := range []string{"faulty", "healthy"} {
+				if err := ioutil.WriteFile(filepath.Join(mnt, name), []byte(name), 0644); err != nil {
+					t.Fatalf("error writing file: %v", err)
+				}
+			}
+			rules := filepath.Join(dir, "rules")
+			conf.TestOnlyGoferFaults = rules
+
+			spec, _ := sleepSpecConf(t)
+			spec.Mounts = append(spec.Mounts, specs.Mount{
+				Destination: mnt,
+				Source:      mnt,
+				Type:        "bind",
+			})
+			c, destroy := startChaosContainer(t, spec, conf)
+			defer destroy()
+
+			// Run cat once, so that the binary doesn't need to be looked
+			// up while faults are injected.
+			if _, err := executeCombinedOutput(conf, c, "/bin/cat", "/dev/null"); err != nil {
+				t.Fatalf("exec failed: %v", err)
+			}
+
+			// Files that weren't looked up yet can't be read while walks fail.
+			if err := faultinject.WriteRules(rules,
+				faultinject.Rule{Op: "walk", Errno: unix.EIO},
+				faultinject.Rule{Op: "walkgetattr", Errno: unix.EIO},
+			); err != nil {
+				t.Fatalf("error writing rules: %v", err)
+			}
+			ws, err := execute(conf, c, "/bin/cat", filepath.Join(mnt, "faulty"))
+			if err == nil && ws.ExitStatus() == 0 {
+				t.Errorf("cat succeeded while walks fail")
+			}
+
+			// Once the rules are removed, the gofer serves files normally.
+			if err := os.Remove(rules); err != nil {
+				t.Fatalf("error removing rules: %v", err)
+			}
+			out, err := executeCombinedOutput(conf, c, "/bin/cat", filepath.Join(mnt, "healthy"))
+			if err != nil {
+				t.Fatalf("cat failed after faults stopped: %v", err)
+			}
+			if got, want := string(out), "healthy"; got != want {
+				t.Errorf("cat got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+// TestChaosGoferKilled checks that the sandbox goes down when the gofer of the
+// root container is killed, instead of hanging on filesystem operations.
+func TestChaosGoferKilled(t *testing.T) {
+	for name, conf := range configs(t, noOverlay...) {
+		t.Run(name, func(t *testing.T) {
+			spec, _ := sleepSpecConf(t)
+			c, destroy := startChaosContainer(t, spec, conf)
+			defer destroy()
+
+			if err := testutil.KillProcess(c.GoferPid); err != nil {
+				t.Fatalf("error killing gofer: %v", err)
+			}
+
+			// Wait until the sandbox exits and RPCs fail.
+			err := testutil.Poll(func() error {
+				if _, err := c.Processes(); err == nil {
+					return fmt.Errorf("sandbox is still running")
+				}
+				return nil
+			}, 10*time.Second)
+			if err != nil {
+				t.Errorf("sandbox was not stopped after gofer death: %v", err)
+			}
+		})
+	}
+}
+
+// TestChaosSandboxStopped checks that the sandbox keeps working after all its
+// threads were stopped for a while, e.g. as if the host was overloaded.
+func TestChaosSandboxStopped(t *testing.T) {
+	for name, conf := range configs(t, noOverlay...) {
+		t.Run(name, func(t *testing.T) {
+			spec, _ := sleepSpecConf(t)
+			c, destroy := startChaosContainer(t, spec, conf)
+			defer destroy()
+
+			resume, err := testutil.StopProcess(c.Sandbox.Pid)
+			if err != nil {
+				t.Fatalf("error stopping sandbox: %v", err)
+			}
+			// Long enough for timers and the watchdog to notice.
+			time.Sleep(2 * time.Second)
+			if err := resume(); err != nil {
+				t.Fatalf("error resuming sandbox: %v", err)
+			}
+
+			if _, err := executeCombinedOutput(conf, c, "/bin/true"); err != nil {
+				t.Errorf("exec failed after sandbox was resumed: %v", err)
+			}
+			expectedPL := []*control.Process{newProcessBuilder().Cmd("sleep").Process()}
+			if err := waitForProcessList(c, expectedPL); err != nil {
+				t.Errorf("sleep isn't running after sandbox was resumed: %v", err)
+			}
+		})
+	}
+}
+
+// TestChaosControlSocketDropped checks that commands fail instead of hanging
+// when the control socket of the sandbox stops accepting connections, and
+// that the container can still be destroyed.
+func TestChaosControlSocketDropped(t *testing.T) {
+	for name, conf := range configs(t, noOverlay...) {
+		t.Run(name, func(t *testing.T) {
+			spec, _ := sleepSpecConf(t)
+			c, destroy := startChaosContainer(t, spec, conf)
+			defer destroy()
+
+			// Destroy clears c.Sandbox.
+			sandboxPid := c.Sandbox.Pid
+			if err := testutil.DropSocket(sandboxPid, boot.ControlSocketAddr(c.Sandbox.ID)); err != nil {
+				t.Fatalf("error dropping control socket: %v", err)
+			}
+
+			done := make(chan error, 1)
+			go func() {
+				_, err := c.Processes()
+				done <- err
+			}()
+			select {
+			case err := <-done:
+				if err == nil {
+					t.Errorf("Processes() succeeded after control socket was dropped")
+				}
+			case <-time.After(10 * time.Second):
+				t.Fatalf("Processes() hung after control socket was dropped")
+			}
+
+			if err := c.Destroy(); err != nil {
+				t.Errorf("error destroying container: %v", err)
+			}
+			if err := testutil.Poll(func() error {
+				if err := unix.Kill(sandboxPid, 0); err == nil {
+					return fmt.Errorf("sandbox %d is still running", sandboxPid)
+				}
+				return nil
+			}, 10*time.Second); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
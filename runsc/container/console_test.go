@@ -26,6 +26,7 @@ import (
 
 	"github.com/kr/pty"
 	"golang.org/x/sys/unix"
+	"gvisor.dev/gvisor/pkg/abi/linux"
 	"gvisor.dev/gvisor/pkg/sentry/control"
 	"gvisor.dev/gvisor/pkg/sync"
 	"gvisor.dev/gvisor/pkg/test/testutil"
@@ -161,6 +162,50 @@ func TestConsoleSocket(t *testing.T) {
 	}
 }
 
+// Test that subcontainers using a terminal without a console socket use the
+// terminal runsc is attached to.
+func TestSubcontainerTTY(t *testing.T) {
+	stdin := os.Stdin
+	defer func() { os.Stdin = stdin }()
+
+	c := &Container{
+		ID:   "sub",
+		Spec: testutil.NewSpecWithArgs("true"),
+	}
+	if tty, err := c.subcontainerTTY(); err != nil || tty != nil {
+		t.Errorf("subcontainerTTY without terminal: got (%v, %v), want (nil, nil)", tty, err)
+	}
+
+	c.Spec.Process.Terminal = true
+	ptyMaster, ptyReplica, err := pty.Open()
+	if err != nil {
+		t.Fatalf("pty.Open failed: %v", err)
+	}
+	defer ptyMaster.Close()
+	defer ptyReplica.Close()
+	os.Stdin = ptyReplica
+	tty, err := c.subcontainerTTY()
+	if err != nil {
+		t.Fatalf("subcontainerTTY with terminal stdin failed: %v", err)
+	}
+	if _, err := unix.IoctlGetTermios(int(tty.Fd()), unix.TCGETS); err != nil {
+		t.Errorf("subcontainerTTY returned a file which isn't a terminal: %v", err)
+	}
+	tty.Close()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("os.Pipe failed: %v", err)
+	}
+	defer r.Close()
+	defer w.Close()
+	os.Stdin = r
+	if tty, err := c.subcontainerTTY(); err == nil {
+		tty.Close()
+		t.Errorf("subcontainerTTY with pipe stdin succeeded, want error")
+	}
+}
+
 // Test that an pty FD is sent over the console socket if one is provided.
 func TestMultiContainerConsoleSocket(t *testing.T) {
 	for name, conf := range configs(t, all...) {
@@ -362,6 +407,82 @@ func TestJobControlSignalExec(t *testing.T) {
 	}
 }
 
+// TestResizeTTYExec checks that resizing the TTY of an exec session changes
+// its window size and sends SIGWINCH to the foreground process group.
+func TestResizeTTYExec(t *testing.T) {
+	spec := testutil.NewSpecWithArgs("/bin/sleep", "10000")
+	conf := testutil.TestConfig(t)
+
+	_, bundleDir, cleanup, err := testutil.SetupContainer(spec, conf)
+	if err != nil {
+		t.Fatalf("error setting up container: %v", err)
+	}
+	defer cleanup()
+
+	// Create and start the container.
+	args := Args{
+		ID:        testutil.RandomContainerID(),
+		Spec:      spec,
+		BundleDir: bundleDir,
+	}
+	c, err := New(conf, args)
+	if err != nil {
+		t.Fatalf("error creating container: %v", err)
+	}
+	defer c.Destroy()
+	if err := c.Start(conf); err != nil {
+		t.Fatalf("error starting container: %v", err)
+	}
+
+	ptyMaster, ptyReplica, err := pty.Open()
+	if err != nil {
+		t.Fatalf("error opening pty: %v", err)
+	}
+	defer ptyMaster.Close()
+	defer ptyReplica.Close()
+
+	// The shell prints the window size when it gets SIGWINCH.
+	execArgs := &control.ExecArgs{
+		Filename: "/bin/sh",
+		Argv:     []string{"/bin/sh", "-c", "trap 'echo size: $(stty size); exit' WINCH; echo ready; while true; do sleep 0.1; done"},
+		FilePayload: urpc.FilePayload{
+			Files: []*os.File{ptyReplica, ptyReplica, ptyReplica},
+		},
+		StdioIsPty: true,
+	}
+	pid, err := c.Execute(conf, execArgs)
+	if err != nil {
+		t.Fatalf("error executing: %v", err)
+	}
+	if err := testutil.WaitUntilRead(ptyMaster, "ready", 5*time.Second); err != nil {
+		t.Fatalf("shell did not start: %v", err)
+	}
+
+	// Resize the pty first like containerd's shim does, which must not
+	// prevent the sandbox from sending SIGWINCH.
+	if err := pty.Setsize(ptyMaster, &pty.Winsize{Rows: 40, Cols: 100}); err != nil {
+		t.Fatalf("error resizing pty: %v", err)
+	}
+	if err := c.ResizeTTY(pid, &linux.Winsize{Row: 40, Col: 100}); err != nil {
+		t.Fatalf("ResizeTTY failed: %v", err)
+	}
+	if err := testutil.WaitUntilRead(ptyMaster, "size: 40 100", 5*time.Second); err != nil {
+		t.Fatalf("shell did not get new window size: %v", err)
+	}
+	ws, err := c.WaitPID(pid)
+	if err != nil {
+		t.Fatalf("waiting on exec failed: %v", err)
+	}
+	if ws.ExitStatus() != 0 {
+		t.Errorf("exec exited with status %v, want 0", ws)
+	}
+
+	// The container's init process has no TTY.
+	if err := c.ResizeTTY(0, &linux.Winsize{Row: 40, Col: 100}); err == nil {
+		t.Errorf("ResizeTTY on process without a TTY succeeded, want error")
+	}
+}
+
 // Test that job control signals work on a console created with "run -ti".
 func TestJobControlSignalRootContainer(t *testing.T) {
 	conf := testutil.TestConfig(t)
@@ -33,21 +33,30 @@ import (
 	"golang.org/x/sys/unix"
 	"gvisor.dev/gvisor/pkg/abi/linux"
 	"gvisor.dev/gvisor/pkg/cleanup"
+	"gvisor.dev/gvisor/pkg/control/client"
+	"gvisor.dev/gvisor/pkg/control/server"
 	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/pkg/p9/opstats"
 	"gvisor.dev/gvisor/pkg/sentry/control"
 	"gvisor.dev/gvisor/pkg/sighandling"
 	"gvisor.dev/gvisor/runsc/boot"
 	"gvisor.dev/gvisor/runsc/cgroup"
 	"gvisor.dev/gvisor/runsc/config"
 	"gvisor.dev/gvisor/runsc/console"
+	"gvisor.dev/gvisor/runsc/fsgofer"
 	"gvisor.dev/gvisor/runsc/sandbox"
 	"gvisor.dev/gvisor/runsc/specutils"
+	"gvisor.dev/gvisor/runsc/tracing"
 )
 
 const cgroupParentAnnotation = "dev.gvisor.spec.cgroup-parent"
 
-// validateID validates the container id.
-func validateID(id string) error {
+// CrashReportAnnotation is the annotation of the container state with the path
+// of the crash report written when the sandbox panicked.
+const CrashReportAnnotation = "dev.gvisor.crash-report"
+
+// ValidateID validates the container id.
+func ValidateID(id string) error {
 	// See libcontainer/factory_linux.go.
 	idRegex := regexp.MustCompile(`^[\w+-\.]+$`)
 	if !idRegex.MatchString(id) {
@@ -129,6 +138,21 @@ type Container struct {
 	// processes.
 	Saver StateFile `json:"saver"`
 
+	// DebugLogs contains the paths of the debug and panic logs owned by the
+	// container. The root container owns the sandbox logs.
+	DebugLogs []string `json:"debugLogs,omitempty"`
+
+	// BootTimeline is the phases of the creation and start of the container,
+	// in runsc and in the sandbox. See runsc debug --boot-timeline.
+	BootTimeline boot.BootTimeline `json:"bootTimeline,omitempty"`
+
+	// CrashReport is the path of the crash report written when the sandbox
+	// panicked, if any.
+	CrashReport string `json:"crashReport,omitempty"`
+
+	// HookOpts configures the execution of the hooks of the container.
+	HookOpts HookOpts `json:"hookOpts"`
+
 	//
 	// Fields below this line are not saved in the state file and will not
 	// be preserved across commands.
@@ -177,7 +201,10 @@ type Args struct {
 // Destroy() on the container.
 func New(conf *config.Config, args Args) (*Container, error) {
 	log.Debugf("Create container, cid: %s, rootDir: %q", args.ID, conf.RootDir)
-	if err := validateID(args.ID); err != nil {
+	if err := ValidateID(args.ID); err != nil {
+		return nil, err
+	}
+	if err := validateHooks(args.Spec.Hooks); err != nil {
 		return nil, err
 	}
 
@@ -202,6 +229,7 @@ func New(conf *config.Config, args Args) (*Container, error) {
 		Status:        Creating,
 		CreatedAt:     time.Now(),
 		Owner:         os.Getenv("USER"),
+		HookOpts:      hookOpts(conf),
 		Saver: StateFile{
 			RootDir: conf.RootDir,
 			ID: FullID{
@@ -250,10 +278,38 @@ func New(conf *config.Config, args Args) (*Container, error) {
 			return nil, err
 		}
 		c.CompatCgroup = cgroup.CgroupJSON{Cgroup: subCgroup}
+		ips, err := podIPs(conf, args.Spec)
+		if err != nil {
+			return nil, fmt.Errorf("reading sandbox IP addresses: %v", err)
+		}
+		if err := c.setupHosts(conf, args.Spec, ips); err != nil {
+			return nil, err
+		}
 		if err := runInCgroup(parentCgroup, func() error {
-			ioFiles, specFile, err := c.createGoferProcess(args.Spec, conf, args.BundleDir, args.Attached)
-			if err != nil {
-				return err
+			var (
+				ioFiles  []*os.File
+				specFile *os.File
+			)
+			if conf.TestOnlyInProcessGofer {
+				// The sandbox serves its own mounts, see cmd.Boot.
+				log.Warningf("Running the gofer inside the sandbox process. This is only safe for development!")
+			} else {
+				var err error
+				start := time.Now()
+				span := tracing.StartChild("start gofer")
+				ioFiles, specFile, err = c.createGoferProcess(args.Spec, conf, args.BundleDir, args.Attached)
+				span.End(err)
+				if err != nil {
+					return err
+				}
+				c.BootTimeline.Record("gofer start", start)
+
+				// Record the gofer, which holds the host bind mounts of the
+				// container, in case runsc dies before the container is created.
+				// See DestroyAbandoned.
+				if err := c.saveLocked(); err != nil {
+					return err
+				}
 			}
 
 			// Start a new sandbox for this container. Any errors after this point
@@ -268,19 +324,27 @@ func New(conf *config.Config, args Args) (*Container, error) {
 				MountsFile:    specFile,
 				Cgroup:        parentCgroup,
 				Attached:      args.Attached,
+				PodIPs:        ips,
 			}
+			span := tracing.StartChild("create sandbox")
 			sand, err := sandbox.New(conf, sandArgs)
+			span.End(err)
 			if err != nil {
 				return err
 			}
 			c.Sandbox = sand
-			return nil
+			c.DebugLogs = append(c.DebugLogs, sand.DebugLogs...)
+			c.BootTimeline = append(c.BootTimeline, sand.TakeBootTimeline()...)
+			return c.saveLocked()
 
 		}); err != nil {
 			return nil, err
 		}
 	} else {
 		log.Debugf("Creating new container, cid: %s, sandbox: %s", c.ID, sandboxID)
+		if conf.TestOnlyInProcessGofer {
+			return nil, fmt.Errorf("TESTONLY-in-process-gofer doesn't support multiple containers per sandbox")
+		}
 
 		// Find the sandbox associated with this ID.
 		fullID := FullID{
@@ -293,22 +357,21 @@ func New(conf *config.Config, args Args) (*Container, error) {
 		}
 		c.Sandbox = sb.Sandbox
 
+		if err := c.setupHosts(conf, args.Spec, c.Sandbox.PodIPs); err != nil {
+			return nil, err
+		}
+
 		subCgroup, err := c.setupCgroupForSubcontainer(conf, args.Spec)
 		if err != nil {
 			return nil, err
 		}
 		c.CompatCgroup = cgroup.CgroupJSON{Cgroup: subCgroup}
 
-		// If the console control socket file is provided, then create a new
-		// pty master/slave pair and send the TTY to the sandbox process.
-		var tty *os.File
-		if c.ConsoleSocket != "" {
-			// Create a new TTY pair and send the master on the provided socket.
-			var err error
-			tty, err = console.NewWithSocket(c.ConsoleSocket)
-			if err != nil {
-				return nil, fmt.Errorf("setting up console with socket %q: %w", c.ConsoleSocket, err)
-			}
+		tty, err := c.subcontainerTTY()
+		if err != nil {
+			return nil, err
+		}
+		if tty != nil {
 			// tty file is transferred to the sandbox, then it can be closed here.
 			defer tty.Close()
 		}
@@ -336,6 +399,35 @@ func New(conf *config.Config, args Args) (*Container, error) {
 	return c, nil
 }
 
+// subcontainerTTY returns the TTY of a subcontainer using a terminal, which is
+// sent to the sandbox when the subcontainer is created, or nil if it doesn't
+// use a terminal.
+func (c *Container) subcontainerTTY() (*os.File, error) {
+	if !c.Spec.Process.Terminal {
+		return nil, nil
+	}
+	if c.ConsoleSocket != "" {
+		// Create a new pty master/replica pair and send the master on the
+		// provided socket.
+		tty, err := console.NewWithSocket(c.ConsoleSocket)
+		if err != nil {
+			return nil, fmt.Errorf("setting up console with socket %q: %w", c.ConsoleSocket, err)
+		}
+		return tty, nil
+	}
+
+	// Like the root container, use the terminal runsc is attached to if no
+	// console socket is provided.
+	if _, err := unix.IoctlGetTermios(int(os.Stdin.Fd()), unix.TCGETS); err != nil {
+		return nil, fmt.Errorf("terminal enabled but stdin isn't a terminal, did you set --console-socket on create?")
+	}
+	fd, err := unix.Dup(int(os.Stdin.Fd()))
+	if err != nil {
+		return nil, fmt.Errorf("duplicating stdin: %w", err)
+	}
+	return os.NewFile(uintptr(fd), "tty"), nil
+}
+
 // Start starts running the containerized process inside the sandbox.
 func (c *Container) Start(conf *config.Config) error {
 	log.Debugf("Start container, cid: %s", c.ID)
@@ -353,37 +445,19 @@ func (c *Container) Start(conf *config.Config) error {
 	// "If any prestart hook fails, the runtime MUST generate an error,
 	// stop and destroy the container" -OCI spec.
 	if c.Spec.Hooks != nil {
-		if err := executeHooks(c.Spec.Hooks.Prestart, c.State()); err != nil {
+		if err := executeHooks(c.Spec.Hooks.Prestart, c.State(), c.HookOpts); err != nil {
 			return err
 		}
 	}
 
+	start := time.Now()
 	if isRoot(c.Spec) {
 		if err := c.Sandbox.StartRoot(c.Spec, conf); err != nil {
 			return err
 		}
+		c.BootTimeline = append(c.BootTimeline, c.Sandbox.TakeBootTimeline()...)
 	} else {
-		// Join cgroup to start gofer process to ensure it's part of the cgroup from
-		// the start (and all their children processes).
-		if err := runInCgroup(c.Sandbox.CgroupJSON.Cgroup, func() error {
-			// Create the gofer process.
-			goferFiles, mountsFile, err := c.createGoferProcess(c.Spec, conf, c.BundleDir, false)
-			if err != nil {
-				return err
-			}
-			defer func() {
-				_ = mountsFile.Close()
-				for _, f := range goferFiles {
-					_ = f.Close()
-				}
-			}()
-
-			cleanMounts, err := specutils.ReadMounts(mountsFile)
-			if err != nil {
-				return fmt.Errorf("reading mounts file: %v", err)
-			}
-			c.Spec.Mounts = cleanMounts
-
+		if err := c.startSubcontainerGofer(conf, func(goferFiles []*os.File) error {
 			// Setup stdios if the container is not using terminal. Otherwise TTY was
 			// already setup in create.
 			var stdios []*os.File
@@ -401,7 +475,14 @@ func (c *Container) Start(conf *config.Config) error {
 	// the remaining hooks and lifecycle continue as if the hook had
 	// succeeded" -OCI spec.
 	if c.Spec.Hooks != nil {
-		executeHooksBestEffort(c.Spec.Hooks.Poststart, c.State())
+		executeHooksBestEffort(c.Spec.Hooks.Poststart, c.State(), c.HookOpts)
+	}
+
+	c.BootTimeline.Record("start", start)
+	if t, err := c.Sandbox.BootTimeline(c.ID); err != nil {
+		log.Warningf("Failed to get boot timeline of container %q: %v", c.ID, err)
+	} else {
+		c.BootTimeline = append(c.BootTimeline, t...)
 	}
 
 	c.changeStatus(Running)
@@ -422,8 +503,44 @@ func (c *Container) Start(conf *config.Config) error {
 	return c.adjustGoferOOMScoreAdj()
 }
 
+// startSubcontainerGofer starts the gofer process of a sub-container and calls
+// fn with the files connected to it, which are closed once fn returns.
+func (c *Container) startSubcontainerGofer(conf *config.Config, fn func(goferFiles []*os.File) error) error {
+	// Join cgroup to start gofer process to ensure it's part of the cgroup from
+	// the start (and all their children processes).
+	return runInCgroup(c.Sandbox.CgroupJSON.Cgroup, func() error {
+		// Create the gofer process.
+		start := time.Now()
+		span := tracing.StartChild("start gofer")
+		goferFiles, mountsFile, err := c.createGoferProcess(c.Spec, conf, c.BundleDir, false)
+		span.End(err)
+		if err != nil {
+			return err
+		}
+		c.BootTimeline.Record("gofer start", start)
+		defer func() {
+			_ = mountsFile.Close()
+			for _, f := range goferFiles {
+				_ = f.Close()
+			}
+		}()
+		if err := c.saveLocked(); err != nil {
+			return err
+		}
+
+		cleanMounts, err := specutils.ReadMounts(mountsFile)
+		if err != nil {
+			return fmt.Errorf("reading mounts file: %v", err)
+		}
+		c.Spec.Mounts = cleanMounts
+
+		return fn(goferFiles)
+	})
+}
+
 // Restore takes a container and replaces its kernel and file system
-// to restore a container from its state file.
+// to restore a container from its state file. Sub-containers are restored
+// along with the root container, and must be restored before it.
 func (c *Container) Restore(spec *specs.Spec, conf *config.Config, restoreFile string) error {
 	log.Debugf("Restore container, cid: %s", c.ID)
 	if err := c.Saver.lock(); err != nil {
@@ -438,13 +555,21 @@ func (c *Container) Restore(spec *specs.Spec, conf *config.Config, restoreFile s
 	// "If any prestart hook fails, the runtime MUST generate an error,
 	// stop and destroy the container" -OCI spec.
 	if c.Spec.Hooks != nil {
-		if err := executeHooks(c.Spec.Hooks.Prestart, c.State()); err != nil {
+		if err := executeHooks(c.Spec.Hooks.Prestart, c.State(), c.HookOpts); err != nil {
 			return err
 		}
 	}
 
-	if err := c.Sandbox.Restore(c.ID, spec, conf, restoreFile); err != nil {
-		return err
+	if isRoot(c.Spec) {
+		if err := c.Sandbox.Restore(c.ID, spec, conf, restoreFile); err != nil {
+			return err
+		}
+	} else {
+		if err := c.startSubcontainerGofer(conf, func(goferFiles []*os.File) error {
+			return c.Sandbox.RestoreSubcontainer(c.Spec, conf, c.ID, goferFiles)
+		}); err != nil {
+			return err
+		}
 	}
 	c.changeStatus(Running)
 	return c.saveLocked()
@@ -475,6 +600,12 @@ func Run(conf *config.Config, args Args) (unix.WaitStatus, error) {
 		}
 	}
 	if args.Attached {
+		// Forward signals received by runsc to the container, so that stopping
+		// runsc (e.g. by systemd or docker) stops the container gracefully and
+		// window resizes reach the container's terminal. With a terminal, job
+		// control signals like ^C go to the foreground process group.
+		stopForwarding := c.ForwardSignals(0 /* pid */, args.Spec.Process.Terminal /* fgProcess */)
+		defer stopForwarding()
 		return c.Wait()
 	}
 	cu.Release()
@@ -503,12 +634,42 @@ func (c *Container) Event() (*boot.EventOut, error) {
 		return nil, err
 	}
 
+	// Report the container's own usage rather than the totals for the
+	// sandbox. Containers without processes have no stats, and use nothing.
+	cs := event.ContainerStats[c.ID]
+	event.Event.Data.CPU.Usage.User = cs.CPU.Usage.User
+	event.Event.Data.CPU.Usage.Kernel = cs.CPU.Usage.Kernel
+	event.Event.Data.CPU.PSI = cs.CPU.PSI
+	event.Event.Data.Memory = cs.Memory
+	event.Event.Data.Pids = cs.Pids
+	event.Event.Data.Blkio = cs.Blkio
+	event.Event.Data.RootFS = cs.RootFS
+
 	// Some stats can utilize host cgroups for accuracy.
 	c.populateStats(event)
+	c.populateLimits(event)
+
+	if event.SandboxCPU != nil {
+		c.addGoferCPU(event.SandboxCPU)
+	}
+
+	if status, ok := event.ContainerHealth[c.ID]; ok {
+		event.Event.Health = &status
+	}
 
 	return event, nil
 }
 
+// HealthCheck returns the status of the health check configured for the
+// container.
+func (c *Container) HealthCheck() (*boot.HealthStatus, error) {
+	log.Debugf("Getting health status for container, cid: %s", c.ID)
+	if err := c.requireStatus("get health status for", Created, Running, Paused); err != nil {
+		return nil, err
+	}
+	return c.Sandbox.HealthCheck(c.ID)
+}
+
 // SandboxPid returns the Pid of the sandbox the container is running in, or -1 if the
 // container is not running.
 func (c *Container) SandboxPid() int {
@@ -571,7 +732,8 @@ func (c *Container) SignalContainer(sig unix.Signal, all bool) error {
 	return c.Sandbox.SignalContainer(c.ID, sig, all)
 }
 
-// SignalProcess sends sig to a specific process in the container.
+// SignalProcess sends sig to process 'pid' in the sandbox's PID namespace,
+// which must belong to the container.
 func (c *Container) SignalProcess(sig unix.Signal, pid int32) error {
 	log.Debugf("Signal process %d in container, cid: %s, signal: %v (%d)", pid, c.ID, sig, sig)
 	if err := c.requireStatus("signal a process inside", Running); err != nil {
@@ -583,12 +745,73 @@ func (c *Container) SignalProcess(sig unix.Signal, pid int32) error {
 	return c.Sandbox.SignalProcess(c.ID, int32(pid), sig, false)
 }
 
+// SignalPID sends sig to process 'pid' in the container's PID namespace.
+func (c *Container) SignalPID(sig unix.Signal, pid int32) error {
+	log.Debugf("Signal process %d in container's PID namespace, cid: %s, signal: %v (%d)", pid, c.ID, sig, sig)
+	if err := c.requireStatus("signal a process inside", Running); err != nil {
+		return err
+	}
+	if !c.IsSandboxRunning() {
+		return fmt.Errorf("sandbox is not running")
+	}
+	return c.Sandbox.SignalPID(c.ID, pid, sig)
+}
+
+// ExecSessions returns the exec sessions of the container that weren't waited
+// for.
+func (c *Container) ExecSessions() ([]boot.ExecSession, error) {
+	log.Debugf("Getting exec sessions in container, cid: %s", c.ID)
+	if err := c.requireStatus("get exec sessions for", Created, Running, Paused); err != nil {
+		return nil, err
+	}
+	return c.Sandbox.ExecSessions(c.ID)
+}
+
+// Attach connects the given files to the stdio of the container's init
+// process. Input from stdin is discarded if readOnly is set.
+func (c *Container) Attach(readOnly bool, stdin, stdout, stderr *os.File) error {
+	log.Debugf("Attach to container, cid: %s, readOnly: %t", c.ID, readOnly)
+	if err := c.requireStatus("attach to", Running); err != nil {
+		return err
+	}
+	return c.Sandbox.Attach(c.ID, readOnly, stdin, stdout, stderr)
+}
+
+// KillExecSession sends a signal to the process of the given exec session.
+func (c *Container) KillExecSession(execID string, sig unix.Signal) error {
+	log.Debugf("Signal exec session %q in container, cid: %s, signal: %v (%d)", execID, c.ID, sig, sig)
+	if err := c.requireStatus("signal an exec session inside", Running); err != nil {
+		return err
+	}
+	if !c.IsSandboxRunning() {
+		return fmt.Errorf("sandbox is not running")
+	}
+	return c.Sandbox.KillExecSession(c.ID, execID, sig)
+}
+
+// ResizeTTY changes the window size of the TTY of the given process, or of the
+// init process if pid is 0. The process must have been started with a TTY.
+func (c *Container) ResizeTTY(pid int32, winsize *linux.Winsize) error {
+	log.Debugf("Resize TTY in container, cid: %s, PID: %d, size: %dx%d", c.ID, pid, winsize.Col, winsize.Row)
+	if err := c.requireStatus("resize a TTY inside", Running); err != nil {
+		return err
+	}
+	if !c.IsSandboxRunning() {
+		return fmt.Errorf("sandbox is not running")
+	}
+	return c.Sandbox.ResizeTTY(c.ID, pid, winsize)
+}
+
 // ForwardSignals forwards all signals received by the current process to the
-// container process inside the sandbox. It returns a function that will stop
-// forwarding signals.
+// container process inside the sandbox. SIGCHLD is not forwarded, since it
+// refers to children of the current process, e.g. the gofer. It returns a
+// function that will stop forwarding signals.
 func (c *Container) ForwardSignals(pid int32, fgProcess bool) func() {
 	log.Debugf("Forwarding all signals to container, cid: %s, PIDPID: %d, fgProcess: %t", c.ID, pid, fgProcess)
 	stop := sighandling.StartSignalForwarding(func(sig linux.Signal) {
+		if sig == linux.SIGCHLD {
+			return
+		}
 		log.Debugf("Forwarding signal %d to container, cid: %s, PID: %d, fgProcess: %t", sig, c.ID, pid, fgProcess)
 		if err := c.Sandbox.SignalProcess(c.ID, pid, unix.Signal(sig), fgProcess); err != nil {
 			log.Warningf("error forwarding signal %d to container %q: %v", sig, c.ID, err)
@@ -602,12 +825,31 @@ func (c *Container) ForwardSignals(pid int32, fgProcess bool) func() {
 
 // Checkpoint sends the checkpoint call to the container.
 // The statefile will be written to f, the file at the specified image-path.
-func (c *Container) Checkpoint(f *os.File) error {
+func (c *Container) Checkpoint(f *os.File, opts sandbox.CheckpointOpts) error {
 	log.Debugf("Checkpoint container, cid: %s", c.ID)
 	if err := c.requireStatus("checkpoint", Created, Running, Paused); err != nil {
 		return err
 	}
-	return c.Sandbox.Checkpoint(c.ID, f)
+	return c.Sandbox.Checkpoint(c.ID, f, opts)
+}
+
+// StartSnapshots starts taking snapshots of the sandbox of the container every
+// interval, to files in rotation. The sandbox keeps running.
+func (c *Container) StartSnapshots(interval time.Duration, files []*os.File) error {
+	log.Debugf("Start snapshots, cid: %s", c.ID)
+	if err := c.requireStatus("snapshot", Running); err != nil {
+		return err
+	}
+	return c.Sandbox.StartSnapshots(interval, files)
+}
+
+// StopSnapshots stops taking snapshots of the sandbox of the container.
+func (c *Container) StopSnapshots() error {
+	log.Debugf("Stop snapshots, cid: %s", c.ID)
+	if err := c.requireStatus("stop snapshots of", Running, Paused); err != nil {
+		return err
+	}
+	return c.Sandbox.StopSnapshots()
 }
 
 // Pause suspends the container and its kernel.
@@ -649,6 +891,105 @@ func (c *Container) Resume() error {
 	return c.saveLocked()
 }
 
+// Update changes the CPU, memory and pids limits of the container to the ones
+// set in res, leaving the others unchanged. The limits of the root container
+// are the limits of the sandbox cgroup. Subcontainers run inside the sandbox,
+// so only their host cgroup, used by monitoring tools, is changed.
+func (c *Container) Update(res *specs.LinuxResources) error {
+	log.Debugf("Update container, cid: %s, resources: %+v", c.ID, res)
+	if err := c.Saver.lock(); err != nil {
+		return err
+	}
+	defer c.Saver.unlockOrDie()
+
+	if err := c.requireStatus("update", Created, Running, Paused); err != nil {
+		return err
+	}
+
+	cg := c.CompatCgroup.Cgroup
+	if isRoot(c.Spec) {
+		cg = c.Sandbox.CgroupJSON.Cgroup
+	}
+	if cg == nil {
+		return fmt.Errorf("container %q has no cgroup to update", c.ID)
+	}
+	if err := cg.Update(res); err != nil {
+		return fmt.Errorf("updating cgroup of container %q: %v", c.ID, err)
+	}
+
+	if c.Spec.Linux == nil {
+		c.Spec.Linux = &specs.Linux{}
+	}
+	c.Spec.Linux.Resources = mergeResources(c.Spec.Linux.Resources, res)
+	return c.saveLocked()
+}
+
+// mergeResources returns dst with the CPU, memory and pids limits set in res.
+func mergeResources(dst, res *specs.LinuxResources) *specs.LinuxResources {
+	if dst == nil {
+		dst = &specs.LinuxResources{}
+	}
+	if res == nil {
+		return dst
+	}
+	if cpu := res.CPU; cpu != nil {
+		if dst.CPU == nil {
+			dst.CPU = &specs.LinuxCPU{}
+		}
+		if cpu.Shares != nil {
+			dst.CPU.Shares = cpu.Shares
+		}
+		if cpu.Quota != nil {
+			dst.CPU.Quota = cpu.Quota
+		}
+		if cpu.Period != nil {
+			dst.CPU.Period = cpu.Period
+		}
+		if cpu.RealtimeRuntime != nil {
+			dst.CPU.RealtimeRuntime = cpu.RealtimeRuntime
+		}
+		if cpu.RealtimePeriod != nil {
+			dst.CPU.RealtimePeriod = cpu.RealtimePeriod
+		}
+		if cpu.Cpus != "" {
+			dst.CPU.Cpus = cpu.Cpus
+		}
+		if cpu.Mems != "" {
+			dst.CPU.Mems = cpu.Mems
+		}
+	}
+	if mem := res.Memory; mem != nil {
+		if dst.Memory == nil {
+			dst.Memory = &specs.LinuxMemory{}
+		}
+		if mem.Limit != nil {
+			dst.Memory.Limit = mem.Limit
+		}
+		if mem.Reservation != nil {
+			dst.Memory.Reservation = mem.Reservation
+		}
+		if mem.Swap != nil {
+			dst.Memory.Swap = mem.Swap
+		}
+		if mem.Kernel != nil {
+			dst.Memory.Kernel = mem.Kernel
+		}
+		if mem.KernelTCP != nil {
+			dst.Memory.KernelTCP = mem.KernelTCP
+		}
+		if mem.Swappiness != nil {
+			dst.Memory.Swappiness = mem.Swappiness
+		}
+		if mem.DisableOOMKiller != nil {
+			dst.Memory.DisableOOMKiller = mem.DisableOOMKiller
+		}
+	}
+	if res.Pids != nil && res.Pids.Limit > 0 {
+		dst.Pids = &specs.LinuxPids{Limit: res.Pids.Limit}
+	}
+	return dst
+}
+
 // Cat prints out the content of the files.
 func (c *Container) Cat(files []string, out *os.File) error {
 	log.Debugf("Cat in container, cid: %s, files: %+v", c.ID, files)
@@ -661,6 +1002,51 @@ func (c *Container) Usage(full bool) (control.MemoryUsage, error) {
 	return c.Sandbox.Usage(c.ID, full)
 }
 
+// CPUBreakdown returns a breakdown of the CPU time used by the sandbox of the
+// container, including the time used by the container's gofer.
+func (c *Container) CPUBreakdown() (control.CPUBreakdown, error) {
+	log.Debugf("CPU breakdown in container, cid: %s", c.ID)
+	b, err := c.Sandbox.CPUBreakdown()
+	if err != nil {
+		return control.CPUBreakdown{}, err
+	}
+	c.addGoferCPU(&b)
+	return b, nil
+}
+
+// addGoferCPU sets the CPU time used by the container's gofer in b.
+func (c *Container) addGoferCPU(b *control.CPUBreakdown) {
+	if c.GoferPid == 0 {
+		return
+	}
+	user, sys, err := specutils.GetCPUTime(c.GoferPid)
+	if err != nil {
+		log.Warningf("Error getting CPU time of gofer %d: %v", c.GoferPid, err)
+		return
+	}
+	b.Gofer = uint64(user.Nanoseconds() + sys.Nanoseconds())
+}
+
+// GoferStats returns the statistics of the operations served by the
+// container's gofer.
+func (c *Container) GoferStats() (*opstats.Stats, error) {
+	log.Debugf("Gofer stats in container, cid: %s", c.ID)
+	if c.GoferPid == 0 {
+		return nil, fmt.Errorf("container %q has no gofer running", c.ID)
+	}
+	conn, err := client.ConnectTo(fsgofer.ControlSocketAddr(c.ID))
+	if err != nil {
+		return nil, fmt.Errorf("connecting to gofer control server at PID %d: %v", c.GoferPid, err)
+	}
+	defer conn.Close()
+
+	var stats opstats.Stats
+	if err := conn.Call(fsgofer.ControlStats, nil, &stats); err != nil {
+		return nil, fmt.Errorf("getting gofer stats of container %q: %v", c.ID, err)
+	}
+	return &stats, nil
+}
+
 // UsageFD shows application memory usage using two donated FDs.
 func (c *Container) UsageFD() (*control.MemoryUsageRecord, error) {
 	log.Debugf("UsageFD in container, cid: %s", c.ID)
@@ -681,13 +1067,45 @@ func (c *Container) Stream(filters []string, out *os.File) error {
 
 // State returns the metadata of the container.
 func (c *Container) State() specs.State {
-	return specs.State{
+	state := specs.State{
 		Version: specs.Version,
 		ID:      c.ID,
 		Status:  c.Status.String(),
 		Pid:     c.SandboxPid(),
 		Bundle:  c.BundleDir,
 	}
+	if c.CrashReport != "" {
+		state.Annotations = map[string]string{CrashReportAnnotation: c.CrashReport}
+	}
+	return state
+}
+
+// recordCrashReport records the path of the crash report of the sandbox, if it
+// panicked. If save is true, the path is saved to the container metadata.
+func (c *Container) recordCrashReport(sb *sandbox.Sandbox, save bool) {
+	if sb == nil || c.CrashReport != "" {
+		return
+	}
+	report, err := sb.CrashReport()
+	if err != nil {
+		log.Warningf("Error writing crash report of sandbox %q: %v", sb.ID, err)
+		return
+	}
+	if report == "" {
+		return
+	}
+	c.CrashReport = report
+	if !save {
+		return
+	}
+	if err := c.Saver.lock(); err != nil {
+		log.Warningf("Error saving crash report of container %q: %v", c.ID, err)
+		return
+	}
+	defer c.Saver.unlockOrDie()
+	if err := c.saveLocked(); err != nil {
+		log.Warningf("Error saving crash report of container %q: %v", c.ID, err)
+	}
 }
 
 // Processes retrieves the list of processes and associated metadata inside a
@@ -729,6 +1147,8 @@ func (c *Container) Destroy() error {
 		log.Warningf("%v", err)
 		errs = append(errs, err.Error())
 	}
+	// Keep the crash report in the final state, e.g. for the debug archive.
+	c.recordCrashReport(sb, false /* save */)
 
 	if err := c.Saver.destroy(); err != nil {
 		err = fmt.Errorf("deleting container state files: %v", err)
@@ -760,7 +1180,7 @@ func (c *Container) Destroy() error {
 	// 2) Make sure it only runs once, because the root has been deleted, the
 	// container can't be loaded again.
 	if c.Spec.Hooks != nil {
-		executeHooksBestEffort(c.Spec.Hooks.Poststop, c.State())
+		executeHooksBestEffort(c.Spec.Hooks.Poststop, c.State(), c.HookOpts)
 	}
 
 	if len(errs) == 0 {
@@ -891,17 +1311,21 @@ func (c *Container) createGoferProcess(spec *specs.Spec, conf *config.Config, bu
 				test = t
 			}
 		}
-		debugLogFile, err := specutils.DebugLogFile(conf.DebugLog, "gofer", test)
+		debugLogFile, err := specutils.DebugLogFile(conf.DebugLog, "gofer", c.ID, test)
 		if err != nil {
 			return nil, nil, fmt.Errorf("opening debug log file in %q: %v", conf.DebugLog, err)
 		}
 		defer debugLogFile.Close()
+		c.DebugLogs = append(c.DebugLogs, debugLogFile.Name())
 		goferEnds = append(goferEnds, debugLogFile)
 		args = append(args, "--debug-log-fd="+strconv.Itoa(nextFD))
 		nextFD++
 	}
 
 	args = append(args, "gofer", "--bundle", bundleDir)
+	if dir := specutils.OverlayUpperDir(spec, conf, c.ID); dir != "" {
+		args = append(args, "--overlay-upper-path", dir)
+	}
 
 	// Open the spec file to donate to the sandbox.
 	specFile, err := specutils.OpenSpec(bundleDir)
@@ -924,6 +1348,18 @@ func (c *Container) createGoferProcess(spec *specs.Spec, conf *config.Config, bu
 	args = append(args, fmt.Sprintf("--mounts-fd=%d", nextFD))
 	nextFD++
 
+	// Create a socket for the control server of the gofer. It's bound here
+	// since the gofer runs in its own network namespace.
+	controlFD, err := server.CreateSocket(fsgofer.ControlSocketAddr(c.ID))
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating control server socket for gofer: %v", err)
+	}
+	controlFile := os.NewFile(uintptr(controlFD), "gofer control server socket")
+	defer controlFile.Close()
+	goferEnds = append(goferEnds, controlFile)
+	args = append(args, fmt.Sprintf("--control-fd=%d", nextFD))
+	nextFD++
+
 	// Add root mount and then add any other additional mounts.
 	mountCount := 1
 	for _, m := range spec.Mounts {
@@ -931,9 +1367,19 @@ func (c *Container) createGoferProcess(spec *specs.Spec, conf *config.Config, bu
 			mountCount++
 		}
 	}
+	// The directory backing the root overlay is served last.
+	if specutils.OverlayUpperDir(spec, conf, c.ID) != "" {
+		mountCount++
+	}
 
-	sandEnds := make([]*os.File, 0, mountCount)
-	for i := 0; i < mountCount; i++ {
+	// Each mount gets one connection per gofer channel, see
+	// config.Config.GoferChannels.
+	channels := 1
+	if conf.GoferChannels > 1 {
+		channels = conf.GoferChannels
+	}
+	sandEnds := make([]*os.File, 0, mountCount*channels)
+	for i := 0; i < mountCount*channels; i++ {
 		fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM|unix.SOCK_CLOEXEC, 0)
 		if err != nil {
 			return nil, nil, err
@@ -1053,6 +1499,11 @@ func (c *Container) requireStatus(action string, statuses ...Status) error {
 	return fmt.Errorf("cannot %s container %q in state %s", action, c.ID, c.Status)
 }
 
+// IsSandboxRoot returns true if c is the root container of its sandbox.
+func (c *Container) IsSandboxRoot() bool {
+	return isRoot(c.Spec)
+}
+
 func isRoot(spec *specs.Spec) bool {
 	return specutils.SpecContainerType(spec) != specutils.ContainerTypeContainer
 }
@@ -1219,6 +1670,37 @@ func (c *Container) populateStats(event *boot.EventOut) {
 	return
 }
 
+// populateLimits sets the memory and pids limits of the container, which are
+// enforced by the host and unknown to the sentry, in event.
+func (c *Container) populateLimits(event *boot.EventOut) {
+	data := &event.Event.Data
+	if c.Spec.Linux != nil && c.Spec.Linux.Resources != nil {
+		res := c.Spec.Linux.Resources
+		if res.Memory != nil && res.Memory.Limit != nil && *res.Memory.Limit > 0 {
+			data.Memory.Usage.Limit = uint64(*res.Memory.Limit)
+		}
+		if res.Pids != nil && res.Pids.Limit > 0 {
+			data.Pids.Limit = uint64(res.Pids.Limit)
+		}
+	}
+	if data.Memory.Usage.Limit != 0 || !isRoot(c.Spec) {
+		return
+	}
+
+	// The root container is limited by the cgroup of the sandbox, which may
+	// be set by a parent cgroup, e.g. the one of a pod.
+	cg, err := c.Sandbox.NewCGroup()
+	if err != nil || cg == nil {
+		return
+	}
+	limit, err := cg.MemoryLimit()
+	if err != nil {
+		log.Warningf("events: failed to get cgroup memory limit: %v", err)
+		return
+	}
+	data.Memory.Usage.Limit = limit
+}
+
 // setupCgroupForRoot configures and returns cgroup for the sandbox and the
 // root container. If `cgroupParentAnnotation` is set, use that path as the
 // sandbox cgroup and use Spec.Linux.CgroupsPath as the root container cgroup.
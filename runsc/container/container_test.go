@@ -16,6 +16,7 @@ package container
 
 import (
 	"bytes"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
@@ -39,11 +40,13 @@ import (
 	"gvisor.dev/gvisor/pkg/sentry/control"
 	"gvisor.dev/gvisor/pkg/sentry/kernel"
 	"gvisor.dev/gvisor/pkg/sentry/kernel/auth"
+	"gvisor.dev/gvisor/pkg/sentry/limits"
 	"gvisor.dev/gvisor/pkg/sync"
 	"gvisor.dev/gvisor/pkg/test/testutil"
 	"gvisor.dev/gvisor/pkg/urpc"
 	"gvisor.dev/gvisor/runsc/boot/platforms"
 	"gvisor.dev/gvisor/runsc/config"
+	"gvisor.dev/gvisor/runsc/sandbox"
 	"gvisor.dev/gvisor/runsc/specutils"
 )
 
@@ -1044,6 +1047,157 @@ func TestKillPid(t *testing.T) {
 	}
 }
 
+// TestExecSessions verifies that named exec sessions can be listed and killed.
+func TestExecSessions(t *testing.T) {
+	for name, conf := range configs(t, all...) {
+		t.Run(name, func(t *testing.T) {
+			spec, _ := sleepSpecConf(t)
+			_, bundleDir, cleanup, err := testutil.SetupContainer(spec, conf)
+			if err != nil {
+				t.Fatalf("error setting up container: %v", err)
+			}
+			defer cleanup()
+
+			// Create and start the container.
+			args := Args{
+				ID:        testutil.RandomContainerID(),
+				Spec:      spec,
+				BundleDir: bundleDir,
+			}
+			cont, err := New(conf, args)
+			if err != nil {
+				t.Fatalf("error creating container: %v", err)
+			}
+			defer cont.Destroy()
+			if err := cont.Start(conf); err != nil {
+				t.Fatalf("error starting container: %v", err)
+			}
+
+			execArgs := &control.ExecArgs{
+				Filename:         "/bin/sleep",
+				Argv:             []string{"/bin/sleep", "100"},
+				WorkingDirectory: "/",
+				ExecID:           "session",
+			}
+			pid, err := cont.Execute(conf, execArgs)
+			if err != nil {
+				t.Fatalf("error executing: %v", err)
+			}
+
+			// Session names must be unique.
+			if _, err := cont.Execute(conf, execArgs); err == nil {
+				t.Errorf("Execute with duplicate exec ID should have failed")
+			}
+
+			sessions, err := cont.ExecSessions()
+			if err != nil {
+				t.Fatalf("error listing exec sessions: %v", err)
+			}
+			if len(sessions) != 1 || sessions[0].ID != "session" || sessions[0].PID != pid {
+				t.Fatalf("wrong exec sessions, want: [{ID: session, PID: %d}], got: %+v", pid, sessions)
+			}
+
+			if err := cont.KillExecSession("session", unix.SIGKILL); err != nil {
+				t.Fatalf("error killing exec session: %v", err)
+			}
+
+			// Exited sessions are reported with their exit status until
+			// they're waited for, and can't be killed.
+			cb := func() error {
+				sessions, err := cont.ExecSessions()
+				if err != nil {
+					return &backoff.PermanentError{Err: err}
+				}
+				if len(sessions) != 1 || !sessions[0].Exited {
+					return fmt.Errorf("exec session didn't exit, got: %+v", sessions)
+				}
+				return nil
+			}
+			if err := testutil.Poll(cb, 30*time.Second); err != nil {
+				t.Fatal(err)
+			}
+			if err := cont.KillExecSession("session", unix.SIGKILL); err == nil {
+				t.Errorf("killing an exited exec session should have failed")
+			}
+			ws, err := cont.WaitPID(pid)
+			if err != nil {
+				t.Fatalf("error waiting for exec session: %v", err)
+			}
+			if !ws.Signaled() || ws.Signal() != unix.SIGKILL {
+				t.Errorf("exec session should have been killed by SIGKILL, got: %v", ws)
+			}
+
+			// Sessions are removed once waited for.
+			sessions, err = cont.ExecSessions()
+			if err != nil {
+				t.Fatalf("error listing exec sessions: %v", err)
+			}
+			if len(sessions) != 0 {
+				t.Errorf("exec sessions should be empty, got: %+v", sessions)
+			}
+		})
+	}
+}
+
+// TestArchiveDebugArtifacts checks that debug logs and the container's final
+// state are moved to the archive directory.
+func TestArchiveDebugArtifacts(t *testing.T) {
+	spec := testutil.NewSpecWithArgs("/bin/true")
+	conf := testutil.TestConfig(t)
+	logDir, err := ioutil.TempDir(testutil.TmpDir(), "logs")
+	if err != nil {
+		t.Fatalf("error creating log dir: %v", err)
+	}
+	defer os.RemoveAll(logDir)
+	conf.DebugLog = logDir + "/"
+
+	_, bundleDir, cleanup, err := testutil.SetupContainer(spec, conf)
+	if err != nil {
+		t.Fatalf("error setting up container: %v", err)
+	}
+	defer cleanup()
+
+	args := Args{
+		ID:        testutil.RandomContainerID(),
+		Spec:      spec,
+		BundleDir: bundleDir,
+	}
+	cont, err := New(conf, args)
+	if err != nil {
+		t.Fatalf("error creating container: %v", err)
+	}
+	if err := cont.Start(conf); err != nil {
+		t.Fatalf("error starting container: %v", err)
+	}
+	if _, err := cont.Wait(); err != nil {
+		t.Fatalf("error waiting for container: %v", err)
+	}
+	if len(cont.DebugLogs) != 2 {
+		t.Errorf("container should own boot and gofer logs, got: %v", cont.DebugLogs)
+	}
+
+	archive, err := cont.ArchiveDebugArtifacts(filepath.Join(logDir, "archive"))
+	if err != nil {
+		t.Fatalf("error archiving debug artifacts: %v", err)
+	}
+	if err := cont.Destroy(); err != nil {
+		t.Fatalf("error destroying container: %v", err)
+	}
+	for _, log := range cont.DebugLogs {
+		if _, err := os.Stat(log); !os.IsNotExist(err) {
+			t.Errorf("debug log %q should have been moved, stat: %v", log, err)
+		}
+		if _, err := os.Stat(filepath.Join(archive, filepath.Base(log))); err != nil {
+			t.Errorf("debug log %q not archived: %v", log, err)
+		}
+	}
+	for _, name := range []string{"state.json", "container.json"} {
+		if _, err := os.Stat(filepath.Join(archive, name)); err != nil {
+			t.Errorf("%s not archived: %v", name, err)
+		}
+	}
+}
+
 // TestCheckpointRestore creates a container that continuously writes successive
 // integers to a file. To test checkpoint and restore functionality, the
 // container is checkpointed and the last number printed to the file is
@@ -1109,7 +1263,7 @@ func TestCheckpointRestore(t *testing.T) {
 			}
 
 			// Checkpoint running container; save state into new file.
-			if err := cont.Checkpoint(file); err != nil {
+			if err := cont.Checkpoint(file, sandbox.CheckpointOpts{}); err != nil {
 				t.Fatalf("error checkpointing container to empty file: %v", err)
 			}
 			defer os.RemoveAll(imagePath)
@@ -1288,7 +1442,7 @@ func TestUnixDomainSockets(t *testing.T) {
 			}
 
 			// Checkpoint running container; save state into new file.
-			if err := cont.Checkpoint(file); err != nil {
+			if err := cont.Checkpoint(file, sandbox.CheckpointOpts{}); err != nil {
 				t.Fatalf("error checkpointing container to empty file: %v", err)
 			}
 
@@ -2384,6 +2538,247 @@ func TestNetRaw(t *testing.T) {
 	}
 }
 
+// TestHTTP checks that an HTTP server in the sandbox can be reached over
+// loopback.
+func TestHTTP(t *testing.T) {
+	app, err := testutil.FindFile("test/cmd/test_app/test_app")
+	if err != nil {
+		t.Fatal("error finding test_app:", err)
+	}
+
+	for name, conf := range configs(t, all...) {
+		t.Run(name, func(t *testing.T) {
+			const body = "hello from the sandbox"
+			spec := testutil.NewSpecWithArgs(app, "http", "--addr=127.0.0.1:8080", "--body="+body)
+			_, bundleDir, cleanup, err := testutil.SetupContainer(spec, conf)
+			if err != nil {
+				t.Fatalf("error setting up container: %v", err)
+			}
+			defer cleanup()
+
+			args := Args{
+				ID:        testutil.RandomContainerID(),
+				Spec:      spec,
+				BundleDir: bundleDir,
+			}
+			cont, err := New(conf, args)
+			if err != nil {
+				t.Fatalf("error creating container: %v", err)
+			}
+			defer cont.Destroy()
+			if err := cont.Start(conf); err != nil {
+				t.Fatalf("error starting container: %v", err)
+			}
+
+			// The client retries until the server is up.
+			out, err := executeCombinedOutput(conf, cont, app, "http", "--url=http://127.0.0.1:8080/", "--retries=30", "--retry-delay=100ms")
+			if err != nil {
+				t.Fatalf("exec failed: %v, output: %s", err, out)
+			}
+			if !strings.Contains(string(out), body) {
+				t.Errorf("client output got %q, want it to contain %q", out, body)
+			}
+		})
+	}
+}
+
+// TestExecDoesNotLeakFDs checks that exec doesn't leak or close file
+// descriptors of the container's init process.
+func TestExecDoesNotLeakFDs(t *testing.T) {
+	app, err := testutil.FindFile("test/cmd/test_app/test_app")
+	if err != nil {
+		t.Fatal("error finding test_app:", err)
+	}
+
+	for name, conf := range configs(t, noOverlay...) {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir(testutil.TmpDir(), "resources")
+			if err != nil {
+				t.Fatalf("ioutil.TempDir failed: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			if err := os.Chmod(dir, 0777); err != nil {
+				t.Fatalf("error chmoding file: %q, %v", dir, err)
+			}
+			output := filepath.Join(dir, "report")
+
+			spec := testutil.NewSpecWithArgs(app, "resources", "--files=3", "--pipes=2", "--sockets=2", "--mmaps=4", "--output="+output)
+			spec.Mounts = append(spec.Mounts, specs.Mount{
+				Type:        "bind",
+				Destination: dir,
+				Source:      dir,
+			})
+			_, bundleDir, cleanup, err := testutil.SetupContainer(spec, conf)
+			if err != nil {
+				t.Fatalf("error setting up container: %v", err)
+			}
+			defer cleanup()
+
+			args := Args{
+				ID:        testutil.RandomContainerID(),
+				Spec:      spec,
+				BundleDir: bundleDir,
+			}
+			cont, err := New(conf, args)
+			if err != nil {
+				t.Fatalf("error creating container: %v", err)
+			}
+			defer cont.Destroy()
+			if err := cont.Start(conf); err != nil {
+				t.Fatalf("error starting container: %v", err)
+			}
+
+			readReport := func() map[int]string {
+				var report struct {
+					FDs map[int]string `json:"fds"`
+				}
+				cb := func() error {
+					data, err := ioutil.ReadFile(output)
+					if err != nil {
+						return err
+					}
+					return json.Unmarshal(data, &report)
+				}
+				if err := testutil.Poll(cb, 10*time.Second); err != nil {
+					t.Fatalf("error reading report: %v", err)
+				}
+				if err := os.Remove(output); err != nil {
+					t.Fatalf("error removing report: %v", err)
+				}
+				return report.FDs
+			}
+
+			before := readReport()
+			if ws, err := execute(conf, cont, "/bin/true"); err != nil || ws != 0 {
+				t.Fatalf("exec failed, status: %v, err: %v", ws, err)
+			}
+			if err := cont.SignalContainer(unix.SIGUSR1, false); err != nil {
+				t.Fatalf("error signaling container: %v", err)
+			}
+			after := readReport()
+
+			if !reflect.DeepEqual(before, after) {
+				t.Errorf("file descriptors changed after exec, before: %v, after: %v", before, after)
+			}
+		})
+	}
+}
+
+// TestSignalDelivery checks that signals sent to the container are delivered
+// to the application in order.
+func TestSignalDelivery(t *testing.T) {
+	app, err := testutil.FindFile("test/cmd/test_app/test_app")
+	if err != nil {
+		t.Fatal("error finding test_app:", err)
+	}
+
+	for name, conf := range configs(t, noOverlay...) {
+		t.Run(name, func(t *testing.T) {
+			dir, err := ioutil.TempDir(testutil.TmpDir(), "signals")
+			if err != nil {
+				t.Fatalf("ioutil.TempDir failed: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			if err := os.Chmod(dir, 0777); err != nil {
+				t.Fatalf("error chmoding file: %q, %v", dir, err)
+			}
+			output := filepath.Join(dir, "signals")
+
+			spec := testutil.NewSpecWithArgs(app, "signal-echo", "--output="+output, fmt.Sprintf("--exit-on=%d", unix.SIGTERM))
+			spec.Mounts = append(spec.Mounts, specs.Mount{
+				Type:        "bind",
+				Destination: dir,
+				Source:      dir,
+			})
+			_, bundleDir, cleanup, err := testutil.SetupContainer(spec, conf)
+			if err != nil {
+				t.Fatalf("error setting up container: %v", err)
+			}
+			defer cleanup()
+
+			args := Args{
+				ID:        testutil.RandomContainerID(),
+				Spec:      spec,
+				BundleDir: bundleDir,
+			}
+			cont, err := New(conf, args)
+			if err != nil {
+				t.Fatalf("error creating container: %v", err)
+			}
+			defer cont.Destroy()
+			if err := cont.Start(conf); err != nil {
+				t.Fatalf("error starting container: %v", err)
+			}
+			if err := waitForFileExist(output); err != nil {
+				t.Fatalf("error waiting for signal handlers: %v", err)
+			}
+
+			// Signals are sent one at a time and waited for, since the order
+			// of pending standard signals isn't guaranteed.
+			sigs := []unix.Signal{unix.SIGHUP, unix.SIGUSR1, unix.SIGUSR2, unix.SIGCHLD, unix.SIGWINCH, unix.Signal(40), unix.SIGTERM}
+			for i, sig := range sigs {
+				if err := cont.SignalContainer(sig, false); err != nil {
+					t.Fatalf("error signaling container with %v: %v", sig, err)
+				}
+				var got int
+				cb := func() error {
+					var err error
+					got, err = readOutputNum(output, i)
+					return err
+				}
+				if err := testutil.Poll(cb, 10*time.Second); err != nil {
+					t.Fatalf("error reading signal %d: %v", i, err)
+				}
+				if got != int(sig) {
+					t.Errorf("signal %d: got %d, want %d", i, got, sig)
+				}
+			}
+
+			// SIGTERM makes the application exit.
+			ws, err := cont.Wait()
+			if err != nil {
+				t.Fatalf("error waiting for container: %v", err)
+			}
+			if !ws.Exited() || ws.ExitStatus() != 0 {
+				t.Errorf("container failed, waitStatus: %v", ws)
+			}
+		})
+	}
+}
+
+// TestPdeathsig checks that a process gets its parent death signal when its
+// parent exits, and that it's reparented to the container's init or to a
+// subreaper.
+func TestPdeathsig(t *testing.T) {
+	app, err := testutil.FindFile("test/cmd/test_app/test_app")
+	if err != nil {
+		t.Fatal("error finding test_app:", err)
+	}
+
+	for name, conf := range configs(t, all...) {
+		t.Run(name, func(t *testing.T) {
+			for _, tc := range []struct {
+				name string
+				args []string
+			}{
+				// test_app is PID 1, so the orphan is reparented to it.
+				{name: "init", args: []string{app, "pdeathsig"}},
+				// test_app isn't PID 1, so it must be a subreaper to get the
+				// orphan.
+				{name: "subreaper", args: []string{"/bin/sh", "-c", app + " pdeathsig --subreaper --signal=15; exit $?"}},
+				{name: "orphan", args: []string{"/bin/sh", "-c", app + " pdeathsig; exit $?"}},
+			} {
+				t.Run(tc.name, func(t *testing.T) {
+					spec := testutil.NewSpecWithArgs(tc.args...)
+					if err := run(spec, conf); err != nil {
+						t.Fatalf("pdeathsig failed: %v", err)
+					}
+				})
+			}
+		})
+	}
+}
+
 // TestTTYField checks TTY field returned by container.Processes().
 func TestTTYField(t *testing.T) {
 	stop := testutil.StartReaper()
@@ -2600,6 +2995,74 @@ func TestRlimitsExec(t *testing.T) {
 	}
 }
 
+// TestRlimitsExecOverride checks that rlimits given to exec override the ones
+// from the container spec.
+func TestRlimitsExecOverride(t *testing.T) {
+	spec, conf := sleepSpecConf(t)
+	spec.Process.Rlimits = []specs.POSIXRlimit{
+		{Type: "RLIMIT_NOFILE", Hard: 1000, Soft: 100},
+	}
+
+	_, bundleDir, cleanup, err := testutil.SetupContainer(spec, conf)
+	if err != nil {
+		t.Fatalf("error setting up container: %v", err)
+	}
+	defer cleanup()
+
+	args := Args{
+		ID:        testutil.RandomContainerID(),
+		Spec:      spec,
+		BundleDir: bundleDir,
+	}
+	cont, err := New(conf, args)
+	if err != nil {
+		t.Fatalf("error creating container: %v", err)
+	}
+	defer cont.Destroy()
+	if err := cont.Start(conf); err != nil {
+		t.Fatalf("error starting container: %v", err)
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	execArgs := &control.ExecArgs{
+		Filename:    "/bin/sh",
+		Argv:        []string{"/bin/sh", "-c", "ulimit -n"},
+		FilePayload: urpc.FilePayload{Files: []*os.File{os.Stdin, w, w}},
+		RLimits: map[limits.LimitType]limits.Limit{
+			limits.NumberOfFiles: {Cur: 50, Max: 500},
+		},
+	}
+	ws, err := cont.executeSync(conf, execArgs)
+	w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ws != 0 {
+		t.Fatalf("exec failed, status: %v", ws)
+	}
+	got, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "50\n"; string(got) != want {
+		t.Errorf("ulimit result, got: %q, want: %q", got, want)
+	}
+
+	// Other execs must not be affected by the override.
+	got, err = executeCombinedOutput(conf, cont, "/bin/sh", "-c", "ulimit -n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "100\n"; string(got) != want {
+		t.Errorf("ulimit result, got: %q, want: %q", got, want)
+	}
+}
+
 // TestCat creates a file and checks that cat generates the expected output.
 func TestCat(t *testing.T) {
 	f, err := ioutil.TempFile(testutil.TmpDir(), "test-case")
@@ -0,0 +1,80 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build gofuzz
+// +build gofuzz
+
+package container
+
+import (
+	"encoding/json"
+	"io/ioutil"
+
+	"gvisor.dev/gvisor/pkg/sync"
+	"gvisor.dev/gvisor/runsc/specutils"
+)
+
+var (
+	fuzzOnce sync.Once
+
+	// fuzzRootDir is the root directory where Fuzz writes metadata files.
+	fuzzRootDir string
+)
+
+// fuzzID is the ID of the container whose metadata is fuzzed.
+var fuzzID = FullID{SandboxID: "fuzz", ContainerID: "fuzz"}
+
+func fuzzInit() {
+	dir, err := ioutil.TempDir("", "runsc-fuzz")
+	if err != nil {
+		panic(err)
+	}
+	fuzzRootDir = dir
+}
+
+// Fuzz is the entry point for go-fuzz and libFuzzer. It loads data as the
+// metadata file of a container, like all commands do before acting on a
+// container, and uses the loaded container the way that read-only commands
+// like "runsc state" do.
+//
+// Checks that signal or connect to the sandbox are skipped, since they depend
+// on the host.
+func Fuzz(data []byte) int {
+	fuzzOnce.Do(fuzzInit)
+	state := StateFile{RootDir: fuzzRootDir, ID: fuzzID}
+	if err := ioutil.WriteFile(state.statePath(), data, 0640); err != nil {
+		panic(err)
+	}
+
+	c, err := Load(fuzzRootDir, fuzzID, LoadOpts{Exact: true, SkipCheck: true})
+	if err != nil {
+		return 0
+	}
+	c.State()
+	if c.Spec != nil {
+		specutils.ValidateSpec(c.Spec)
+		specutils.SpecContainerType(c.Spec)
+	}
+
+	// Loaded metadata must be saved and loaded again without errors.
+	meta, err := json.Marshal(c)
+	if err != nil {
+		panic(err)
+	}
+	var reloaded Container
+	if err := json.Unmarshal(meta, &reloaded); err != nil {
+		panic(err)
+	}
+	return 1
+}
@@ -0,0 +1,38 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build gofuzz
+// +build gofuzz
+
+package container
+
+import "testing"
+
+// TestFuzz runs Fuzz on a seed corpus, so that the fuzzer is built and its
+// invariants checked by regular test runs.
+func TestFuzz(t *testing.T) {
+	for _, data := range []string{
+		``,
+		`not json`,
+		`{}`,
+		`{"id":"fuzz","spec":{"ociVersion":"1.0.0","process":{"args":["/bin/true"]},"root":{"path":"/"}},"status":1}`,
+		`{"id":"fuzz","spec":{"ociVersion":"1.0.0"},"status":2,"sandbox":{"id":"fuzz","pid":-1}}`,
+	} {
+		// Fuzz panics if an invariant is broken.
+		Fuzz([]byte(data))
+	}
+	if got := Fuzz([]byte("not json")); got != 0 {
+		t.Errorf("Fuzz(%q): got %d, want 0", "not json", got)
+	}
+}
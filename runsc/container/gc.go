@@ -0,0 +1,80 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package container
+
+import (
+	"fmt"
+	"os"
+
+	"gvisor.dev/gvisor/pkg/log"
+)
+
+// DestroyAbandoned destroys the containers of the given root directory that
+// are left in the Creating state by a runsc create that died, e.g. because it
+// was killed. Their gofer, which holds the host bind mounts of the container
+// in its mount namespace, and sandbox would otherwise never be stopped. It
+// returns the ids of the destroyed containers.
+func DestroyAbandoned(rootDir string) ([]FullID, error) {
+	ids, err := List(rootDir)
+	if err != nil {
+		return nil, err
+	}
+	var destroyed []FullID
+	for _, id := range ids {
+		c, err := loadAbandoned(rootDir, id)
+		if err != nil {
+			log.Warningf("Skipping container %q: %v", id, err)
+			continue
+		}
+		if c == nil {
+			continue
+		}
+		log.Infof("Destroying abandoned container %q, gofer PID: %d", c.ID, c.GoferPid)
+		if err := c.Destroy(); err != nil {
+			return destroyed, fmt.Errorf("destroying container %q: %v", c.ID, err)
+		}
+		destroyed = append(destroyed, id)
+	}
+	return destroyed, nil
+}
+
+// loadAbandoned loads the container with the given id if its creation was
+// abandoned, and returns nil otherwise. runsc create holds the lock of the
+// container until the container is created, so a container in the Creating
+// state whose lock is free was abandoned.
+func loadAbandoned(rootDir string, id FullID) (*Container, error) {
+	state := StateFile{
+		RootDir: rootDir,
+		ID:      id,
+	}
+	defer state.close()
+	if ok, err := state.tryLock(); err != nil || !ok {
+		return nil, err
+	}
+	defer state.unlockOrDie()
+
+	c := &Container{}
+	if err := state.loadLocked(c); err != nil {
+		if os.IsNotExist(err) {
+			// Deleted since listed.
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading container metadata file %q: %v", state.statePath(), err)
+	}
+	if c.Status != Creating {
+		return nil, nil
+	}
+	return c, nil
+}
@@ -0,0 +1,120 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package container
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/gofrs/flock"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func TestDestroyAbandoned(t *testing.T) {
+	rootDir := t.TempDir()
+	var ids []FullID
+	for _, id := range []string{"abandoned", "creating", "created"} {
+		c := &Container{
+			ID:     id,
+			Spec:   &specs.Spec{},
+			Status: Creating,
+			Saver: StateFile{
+				RootDir: rootDir,
+				ID:      FullID{SandboxID: id, ContainerID: id},
+			},
+		}
+		if id == "created" {
+			c.Status = Created
+		}
+		if err := c.Saver.lock(); err != nil {
+			t.Fatalf("lock failed: %v", err)
+		}
+		if err := c.saveLocked(); err != nil {
+			t.Fatalf("saveLocked failed: %v", err)
+		}
+		c.Saver.unlockOrDie()
+		ids = append(ids, c.Saver.ID)
+	}
+
+	// The creation of "creating" is still in progress.
+	creating := StateFile{RootDir: rootDir, ID: ids[1]}
+	lock := flock.New(creating.lockPath())
+	if err := lock.Lock(); err != nil {
+		t.Fatalf("Lock failed: %v", err)
+	}
+	defer lock.Unlock()
+
+	destroyed, err := DestroyAbandoned(rootDir)
+	if err != nil {
+		t.Fatalf("DestroyAbandoned failed: %v", err)
+	}
+	if len(destroyed) != 1 || destroyed[0] != ids[0] {
+		t.Errorf("DestroyAbandoned destroyed %v, want [%v]", destroyed, ids[0])
+	}
+	for i, id := range ids {
+		state := StateFile{RootDir: rootDir, ID: id}
+		_, err := os.Stat(state.statePath())
+		if exists := err == nil; exists != (i != 0) {
+			t.Errorf("container %q metadata file exists: %t, want %t", id.ContainerID, exists, i != 0)
+		}
+	}
+}
+
+func TestLoadTryLock(t *testing.T) {
+	rootDir := t.TempDir()
+	id := FullID{SandboxID: "creating", ContainerID: "creating"}
+	save(t, &Container{
+		ID:     id.ContainerID,
+		Spec:   &specs.Spec{},
+		Status: Creating,
+		Saver:  StateFile{RootDir: rootDir, ID: id},
+	})
+
+	// runsc create holds the lock until the container is created.
+	state := StateFile{RootDir: rootDir, ID: id}
+	lock := flock.New(state.lockPath())
+	if err := lock.Lock(); err != nil {
+		t.Fatalf("Lock failed: %v", err)
+	}
+	defer lock.Unlock()
+
+	c, err := Load(rootDir, id, LoadOpts{Exact: true, TryLock: true})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if c.Status != Creating {
+		t.Errorf("got status %v, want %v", c.Status, Creating)
+	}
+	containers, err := LoadAll(rootDir, LoadAllOpts{})
+	if err != nil {
+		t.Fatalf("LoadAll failed: %v", err)
+	}
+	if len(containers) != 1 || containers[0].Status != Creating {
+		t.Errorf("LoadAll got %v, want a creating container", containers)
+	}
+
+	// The metadata file is being written.
+	if err := ioutil.WriteFile(state.statePath(), []byte(`{"id":"crea`), 0640); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	c, err = Load(rootDir, id, LoadOpts{Exact: true, TryLock: true})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if c.ID != id.ContainerID || c.Status != Creating {
+		t.Errorf("got container %q with status %v, want %q with status %v", c.ID, c.Status, id.ContainerID, Creating)
+	}
+}
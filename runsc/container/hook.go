@@ -18,13 +18,18 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"golang.org/x/sys/unix"
 	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/runsc/config"
 )
 
 // This file implements hooks as defined in OCI spec:
@@ -39,35 +44,85 @@ import (
 // 		}]
 // },
 
+// HookOpts configures the execution of hooks.
+type HookOpts struct {
+	// Timeout is the time hooks that don't set a timeout can run before they
+	// are killed. Zero disables it.
+	Timeout time.Duration `json:"timeout,omitempty"`
+
+	// Restricted runs hooks with only the environment set in the spec, in the
+	// root directory, and without inheriting file descriptors from runsc.
+	Restricted bool `json:"restricted,omitempty"`
+}
+
+// hookOpts returns the options of hooks set in conf.
+func hookOpts(conf *config.Config) HookOpts {
+	return HookOpts{
+		Timeout:    conf.HookTimeout,
+		Restricted: conf.HookRestricted,
+	}
+}
+
 // executeHooksBestEffort executes hooks and logs warning in case they fail.
 // Runs all hooks, always.
-func executeHooksBestEffort(hooks []specs.Hook, s specs.State) {
+func executeHooksBestEffort(hooks []specs.Hook, s specs.State, opts HookOpts) {
 	for _, h := range hooks {
-		if err := executeHook(h, s); err != nil {
+		if err := executeHook(h, s, opts); err != nil {
 			log.Warningf("Failure to execute hook %+v, err: %v", h, err)
 		}
 	}
 }
 
 // executeHooks executes hooks until the first one fails or they all execute.
-func executeHooks(hooks []specs.Hook, s specs.State) error {
+func executeHooks(hooks []specs.Hook, s specs.State, opts HookOpts) error {
 	for _, h := range hooks {
-		if err := executeHook(h, s); err != nil {
+		if err := executeHook(h, s, opts); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func executeHook(h specs.Hook, s specs.State) error {
-	log.Debugf("Executing hook %+v, state: %+v", h, s)
+// validateHooks checks that the hooks of a spec can be executed, so that
+// invalid hooks fail the creation of the container rather than its start.
+func validateHooks(hooks *specs.Hooks) error {
+	if hooks == nil {
+		return nil
+	}
+	for _, hs := range [][]specs.Hook{hooks.Prestart, hooks.Poststart, hooks.Poststop} {
+		for _, h := range hs {
+			if err := validateHook(h); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
 
+func validateHook(h specs.Hook) error {
 	if strings.TrimSpace(h.Path) == "" {
 		return fmt.Errorf("empty path for hook")
 	}
 	if !filepath.IsAbs(h.Path) {
 		return fmt.Errorf("path for hook is not absolute: %q", h.Path)
 	}
+	if h.Timeout != nil && *h.Timeout <= 0 {
+		return fmt.Errorf("timeout for hook %q must be > 0, got: %d", h.Path, *h.Timeout)
+	}
+	return nil
+}
+
+func executeHook(h specs.Hook, s specs.State, opts HookOpts) error {
+	log.Debugf("Executing hook %+v, state: %+v", h, s)
+
+	if err := validateHook(h); err != nil {
+		return err
+	}
+	if fi, err := os.Stat(h.Path); err != nil {
+		return fmt.Errorf("hook %q: %v", h.Path, err)
+	} else if !fi.Mode().IsRegular() || fi.Mode().Perm()&0111 == 0 {
+		return fmt.Errorf("hook %q is not an executable file, mode: %v", h.Path, fi.Mode())
+	}
 
 	b, err := json.Marshal(s)
 	if err != nil {
@@ -81,6 +136,19 @@ func executeHook(h specs.Hook, s specs.State) error {
 		Stdin:  bytes.NewReader(b),
 		Stdout: &stdout,
 		Stderr: &stderr,
+		// Run the hook in its own process group, so that the processes it
+		// starts are killed with it on timeout. They would otherwise keep its
+		// output open, and cmd.Wait() would wait for them.
+		SysProcAttr: &unix.SysProcAttr{Setpgid: true},
+	}
+	if opts.Restricted {
+		if cmd.Env == nil {
+			cmd.Env = []string{}
+		}
+		cmd.Dir = "/"
+		if err := closeOnExec(); err != nil {
+			return fmt.Errorf("restricting file descriptors of hook %q: %v", h.Path, err)
+		}
 	}
 	if err := cmd.Start(); err != nil {
 		return err
@@ -91,21 +159,51 @@ func executeHook(h specs.Hook, s specs.State) error {
 		c <- cmd.Wait()
 	}()
 
-	var timer <-chan time.Time
+	timeout := opts.Timeout
 	if h.Timeout != nil {
-		timer = time.After(time.Duration(*h.Timeout) * time.Second)
+		timeout = time.Duration(*h.Timeout) * time.Second
+	}
+	var timer <-chan time.Time
+	if timeout > 0 {
+		timer = time.After(timeout)
 	}
 	select {
-	case err := <-c:
-		if err != nil {
-			return fmt.Errorf("failure executing hook %q, err: %v\nstdout: %s\nstderr: %s", h.Path, err, stdout.String(), stderr.String())
-		}
+	case err = <-c:
 	case <-timer:
-		_ = cmd.Process.Kill()
-		_ = cmd.Wait()
-		return fmt.Errorf("timeout executing hook %q\nstdout: %s\nstderr: %s", h.Path, stdout.String(), stderr.String())
+		_ = unix.Kill(-cmd.Process.Pid, unix.SIGKILL)
+		<-c
+		err = fmt.Errorf("timed out after %v", timeout)
+	}
+	if stdout.Len() > 0 || stderr.Len() > 0 {
+		log.Debugf("Hook %q output:\nstdout: %s\nstderr: %s", h.Path, stdout.String(), stderr.String())
+	}
+	if err != nil {
+		return fmt.Errorf("failure executing hook %q, err: %v\nstdout: %s\nstderr: %s", h.Path, err, stdout.String(), stderr.String())
 	}
 
 	log.Debugf("Execute hook %q success!", h.Path)
 	return nil
 }
+
+// closeOnExec sets FD_CLOEXEC on the file descriptors of runsc above stderr,
+// so that they aren't inherited by the processes it executes. Files opened by
+// runsc already have it, but the ones inherited from its parent may not. runsc
+// passes files to its children with exec.Cmd.ExtraFiles, which isn't
+// affected.
+func closeOnExec() error {
+	fds, err := ioutil.ReadDir("/proc/self/fd")
+	if err != nil {
+		return err
+	}
+	for _, fd := range fds {
+		n, err := strconv.Atoi(fd.Name())
+		if err != nil || n <= 2 {
+			continue
+		}
+		// The file descriptor of the directory read above is closed by now.
+		if _, err := unix.FcntlInt(uintptr(n), unix.F_SETFD, unix.FD_CLOEXEC); err != nil && err != unix.EBADF {
+			return fmt.Errorf("setting FD_CLOEXEC on %d: %v", n, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package container
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"golang.org/x/sys/unix"
+)
+
+// writeHook writes a shell script hook and returns its path.
+func writeHook(t *testing.T, script string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "hook")
+	if err := ioutil.WriteFile(path, []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	return path
+}
+
+func TestHookTimeout(t *testing.T) {
+	// The hook starts a process keeping its output open, which is killed
+	// with it.
+	path := writeHook(t, "echo started\nsleep 1000 &\nsleep 1000\n")
+	start := time.Now()
+	err := executeHook(specs.Hook{Path: path}, specs.State{}, HookOpts{Timeout: 100 * time.Millisecond})
+	if err == nil {
+		t.Fatalf("executeHook succeeded, want timeout")
+	}
+	if !strings.Contains(err.Error(), "timed out") || !strings.Contains(err.Error(), "stdout: started") {
+		t.Errorf("executeHook error %q doesn't report the timeout and the output of the hook", err)
+	}
+	if d := time.Since(start); d > 10*time.Second {
+		t.Errorf("executeHook took %v", d)
+	}
+
+	// The timeout of the hook takes precedence.
+	path = writeHook(t, "sleep 0.5\n")
+	timeout := 10
+	if err := executeHook(specs.Hook{Path: path, Timeout: &timeout}, specs.State{}, HookOpts{Timeout: 100 * time.Millisecond}); err != nil {
+		t.Errorf("executeHook failed: %v", err)
+	}
+}
+
+func TestHookRestricted(t *testing.T) {
+	// Inherited file descriptors don't have FD_CLOEXEC.
+	f, err := os.Open("/dev/null")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer f.Close()
+	fd, err := unix.Dup(int(f.Fd()))
+	if err != nil {
+		t.Fatalf("Dup failed: %v", err)
+	}
+	defer unix.Close(fd)
+
+	if err := os.Setenv("HOOK_TEST", "1"); err != nil {
+		t.Fatalf("Setenv failed: %v", err)
+	}
+	defer os.Unsetenv("HOOK_TEST")
+
+	script := "test -z \"$HOOK_TEST\" || exit 1\ntest \"$(pwd)\" = / || exit 2\nls /proc/self/fd | grep -qx " + strconv.Itoa(fd) + " && exit 3\nexit 0\n"
+	path := writeHook(t, script)
+	if err := executeHook(specs.Hook{Path: path}, specs.State{}, HookOpts{Restricted: true}); err != nil {
+		t.Errorf("restricted hook failed: %v", err)
+	}
+}
+
+func TestValidateHooks(t *testing.T) {
+	zero := 0
+	for _, tc := range []struct {
+		name  string
+		hook  specs.Hook
+		error string
+	}{
+		{name: "empty", hook: specs.Hook{}, error: "empty path"},
+		{name: "relative", hook: specs.Hook{Path: "bin/hook"}, error: "not absolute"},
+		{name: "timeout", hook: specs.Hook{Path: "/bin/true", Timeout: &zero}, error: "must be > 0"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateHooks(&specs.Hooks{Poststop: []specs.Hook{tc.hook}})
+			if err == nil || !strings.Contains(err.Error(), tc.error) {
+				t.Errorf("validateHooks() = %v, want error containing %q", err, tc.error)
+			}
+		})
+	}
+
+	path := filepath.Join(t.TempDir(), "hook")
+	if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if err := executeHook(specs.Hook{Path: path}, specs.State{}, HookOpts{}); err == nil || !strings.Contains(err.Error(), "not an executable") {
+		t.Errorf("executeHook(%q) = %v, want not executable error", path, err)
+	}
+}
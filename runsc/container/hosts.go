@@ -0,0 +1,104 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package container
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/runsc/config"
+	"gvisor.dev/gvisor/runsc/sandbox"
+	"gvisor.dev/gvisor/runsc/specutils"
+)
+
+// hostsPath is the path of the hosts file in containers.
+const hostsPath = "/etc/hosts"
+
+// hostsContent returns a hosts file mapping ips to hostname. The entries are
+// the same as the ones of the hosts file that kubelet manages for pods.
+func hostsContent(hostname string, ips []net.IP) []byte {
+	var b bytes.Buffer
+	b.WriteString("# Generated by runsc.\n")
+	b.WriteString("127.0.0.1\tlocalhost\n")
+	b.WriteString("::1\tlocalhost ip6-localhost ip6-loopback\n")
+	b.WriteString("fe00::0\tip6-localnet\n")
+	b.WriteString("fe00::0\tip6-mcastprefix\n")
+	b.WriteString("fe00::1\tip6-allnodes\n")
+	b.WriteString("fe00::2\tip6-allrouters\n")
+	if hostname != "" {
+		for _, ip := range ips {
+			fmt.Fprintf(&b, "%s\t%s\n", ip, hostname)
+		}
+	}
+	return b.Bytes()
+}
+
+// mountsHosts returns true if the spec mounts a hosts file.
+func mountsHosts(spec *specs.Spec) bool {
+	for _, m := range spec.Mounts {
+		if filepath.Clean(m.Destination) == hostsPath {
+			return true
+		}
+	}
+	return false
+}
+
+// podIPs returns the IP addresses of a new sandbox for the spec of its root
+// container, which are mapped to the hostname of containers in the hosts file.
+func podIPs(conf *config.Config, spec *specs.Spec) ([]net.IP, error) {
+	if !conf.GenerateHosts {
+		return nil, nil
+	}
+	switch conf.Network {
+	case config.NetworkSandbox:
+		// The sandbox process joins the network namespace of the spec, if
+		// any, see sandbox.createSandboxProcess.
+		var nsPath string
+		if ns, ok := specutils.GetNS(specs.NetworkNamespace, spec); ok {
+			nsPath = ns.Path
+		}
+		return sandbox.PodIPs(nsPath)
+	case config.NetworkHost:
+		return sandbox.PodIPs("")
+	default:
+		return nil, nil
+	}
+}
+
+// setupHosts generates the hosts file of the container, unless its spec
+// already mounts one, and adds a bind mount of it to the spec. It's a no-op
+// if conf.GenerateHosts isn't set.
+func (c *Container) setupHosts(conf *config.Config, spec *specs.Spec, ips []net.IP) error {
+	if !conf.GenerateHosts || mountsHosts(spec) {
+		return nil
+	}
+	path := c.Saver.hostsPath()
+	if err := ioutil.WriteFile(path, hostsContent(spec.Hostname, ips), 0644); err != nil {
+		return fmt.Errorf("writing hosts file: %v", err)
+	}
+	log.Infof("Generated hosts file %q for container %q", path, c.ID)
+	spec.Mounts = append(spec.Mounts, specs.Mount{
+		Destination: hostsPath,
+		Source:      path,
+		Type:        "bind",
+		Options:     []string{"rbind"},
+	})
+	return nil
+}
@@ -0,0 +1,57 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package container
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func TestHostsContent(t *testing.T) {
+	ips := []net.IP{net.ParseIP("10.0.0.5"), net.ParseIP("fd00::5")}
+	got := string(hostsContent("web-0", ips))
+	for _, want := range []string{
+		"127.0.0.1\tlocalhost\n",
+		"::1\tlocalhost ip6-localhost ip6-loopback\n",
+		"10.0.0.5\tweb-0\n",
+		"fd00::5\tweb-0\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("hostsContent() = %q, missing %q", got, want)
+		}
+	}
+
+	// Without hostname, only the localhost entries are present.
+	got = string(hostsContent("", ips))
+	if strings.Contains(got, "10.0.0.5") {
+		t.Errorf("hostsContent() = %q, want no entry for the pod IP", got)
+	}
+}
+
+func TestMountsHosts(t *testing.T) {
+	spec := &specs.Spec{
+		Mounts: []specs.Mount{{Destination: "/etc/resolv.conf"}},
+	}
+	if mountsHosts(spec) {
+		t.Errorf("mountsHosts() = true, want false")
+	}
+	spec.Mounts = append(spec.Mounts, specs.Mount{Destination: "/etc/hosts/"})
+	if !mountsHosts(spec) {
+		t.Errorf("mountsHosts() = false, want true")
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package container
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"gvisor.dev/gvisor/pkg/log"
+)
+
+// indexFileName is the name of the index file in the root directory.
+const indexFileName = "containers.index"
+
+// indexEntry is a container in the index, along with the metadata file it was
+// loaded from.
+type indexEntry struct {
+	ModTime   int64      `json:"modTime"`
+	Size      int64      `json:"size"`
+	Container *Container `json:"container"`
+}
+
+// index caches the stopped containers of a root directory, keyed by the name
+// of their metadata file, so that listing thousands of mostly stopped
+// containers doesn't require loading them and checking their sandboxes.
+// Stopped containers never run again, so a container in the index is valid
+// as long as its metadata file doesn't change.
+//
+// The index file is shared by all runsc commands using the root directory. It
+// is replaced atomically, and is rebuilt if it's missing or corrupted.
+type index struct {
+	Containers map[string]indexEntry `json:"containers"`
+}
+
+func newIndex() *index {
+	return &index{Containers: make(map[string]indexEntry)}
+}
+
+func indexPath(rootDir string) string {
+	return filepath.Join(rootDir, indexFileName)
+}
+
+// loadIndex loads the index of the given root directory. It returns an empty
+// index if the file doesn't exist or can't be read.
+func loadIndex(rootDir string) *index {
+	data, err := ioutil.ReadFile(indexPath(rootDir))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warningf("Failed to read the index of %q, rebuilding it: %v", rootDir, err)
+		}
+		return newIndex()
+	}
+	idx := newIndex()
+	if err := json.Unmarshal(data, idx); err != nil {
+		log.Warningf("Failed to parse the index of %q, rebuilding it: %v", rootDir, err)
+		return newIndex()
+	}
+	if idx.Containers == nil {
+		idx.Containers = make(map[string]indexEntry)
+	}
+	return idx
+}
+
+// lookup returns the container loaded from the given metadata file, or nil if
+// it's not in the index or the file changed since. idx may be nil.
+func (idx *index) lookup(info os.FileInfo) *Container {
+	if idx == nil {
+		return nil
+	}
+	e, ok := idx.Containers[info.Name()]
+	if !ok || e.Container == nil || e.ModTime != info.ModTime().UnixNano() || e.Size != info.Size() {
+		return nil
+	}
+	return e.Container
+}
+
+// add adds the stopped container c, loaded from the given metadata file.
+func (idx *index) add(info os.FileInfo, c *Container) {
+	idx.Containers[info.Name()] = indexEntry{
+		ModTime:   info.ModTime().UnixNano(),
+		Size:      info.Size(),
+		Container: c,
+	}
+}
+
+// save atomically replaces the index file of the given root directory.
+func (idx *index) save(rootDir string) error {
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(rootDir, indexFileName+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), 0640); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), indexPath(rootDir))
+}
@@ -0,0 +1,152 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package container
+
+import (
+	"fmt"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"gvisor.dev/gvisor/runsc/sandbox"
+)
+
+// saveStopped saves the metadata file of a stopped container.
+func saveStopped(t *testing.T, rootDir, id, bundle string) {
+	t.Helper()
+	save(t, &Container{
+		ID:        id,
+		Status:    Stopped,
+		BundleDir: bundle,
+		Saver: StateFile{
+			RootDir: rootDir,
+			ID:      FullID{SandboxID: id, ContainerID: id},
+		},
+	})
+}
+
+// save saves the metadata file of c.
+func save(t *testing.T, c *Container) {
+	t.Helper()
+	if err := c.Saver.lock(); err != nil {
+		t.Fatalf("lock failed: %v", err)
+	}
+	defer c.Saver.unlockOrDie()
+	if err := c.saveLocked(); err != nil {
+		t.Fatalf("saveLocked failed: %v", err)
+	}
+}
+
+func TestLoadAllIndex(t *testing.T) {
+	rootDir := t.TempDir()
+	const count = 100
+	for i := 0; i < count; i++ {
+		saveStopped(t, rootDir, fmt.Sprintf("c%03d", i), "/bundle")
+	}
+
+	containers, err := LoadAll(rootDir, LoadAllOpts{Index: true})
+	if err != nil {
+		t.Fatalf("LoadAll failed: %v", err)
+	}
+	if len(containers) != count {
+		t.Fatalf("LoadAll returned %d containers, want %d", len(containers), count)
+	}
+	for i, c := range containers {
+		if want := fmt.Sprintf("c%03d", i); c.ID != want {
+			t.Errorf("container %d: got ID %q, want %q", i, c.ID, want)
+		}
+	}
+	if idx := loadIndex(rootDir); len(idx.Containers) != count {
+		t.Errorf("index has %d containers, want %d", len(idx.Containers), count)
+	}
+
+	// Changed metadata files are loaded again, and deleted ones are removed
+	// from the index.
+	time.Sleep(10 * time.Millisecond)
+	saveStopped(t, rootDir, "c000", "/other-bundle")
+	deleted := StateFile{RootDir: rootDir, ID: FullID{SandboxID: "c001", ContainerID: "c001"}}
+	if err := deleted.destroy(); err != nil {
+		t.Fatalf("destroy failed: %v", err)
+	}
+	containers, err = LoadAll(rootDir, LoadAllOpts{Index: true})
+	if err != nil {
+		t.Fatalf("LoadAll failed: %v", err)
+	}
+	if len(containers) != count-1 {
+		t.Fatalf("LoadAll returned %d containers, want %d", len(containers), count-1)
+	}
+	if got := containers[0].BundleDir; got != "/other-bundle" {
+		t.Errorf("changed container has bundle %q, want %q", got, "/other-bundle")
+	}
+	if idx := loadIndex(rootDir); len(idx.Containers) != count-1 {
+		t.Errorf("index has %d containers, want %d", len(idx.Containers), count-1)
+	}
+
+	// A corrupted index is rebuilt.
+	if err := ioutil.WriteFile(indexPath(rootDir), []byte("garbage"), 0640); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	containers, err = LoadAll(rootDir, LoadAllOpts{Index: true})
+	if err != nil {
+		t.Fatalf("LoadAll failed: %v", err)
+	}
+	if len(containers) != count-1 {
+		t.Errorf("LoadAll returned %d containers, want %d", len(containers), count-1)
+	}
+	if idx := loadIndex(rootDir); len(idx.Containers) != count-1 {
+		t.Errorf("index has %d containers, want %d", len(idx.Containers), count-1)
+	}
+}
+
+// TestLoadAllIndexRunning checks that containers found stopped only by the
+// status check aren't indexed, as they are still running in their metadata
+// file.
+func TestLoadAllIndexRunning(t *testing.T) {
+	rootDir := t.TempDir()
+	saveStopped(t, rootDir, "stopped", "/bundle")
+	// The sandbox has no process, so the container is found stopped.
+	save(t, &Container{
+		ID:        "running",
+		Status:    Running,
+		BundleDir: "/bundle",
+		Sandbox:   &sandbox.Sandbox{ID: "running"},
+		Saver: StateFile{
+			RootDir: rootDir,
+			ID:      FullID{SandboxID: "running", ContainerID: "running"},
+		},
+	})
+
+	containers, err := LoadAll(rootDir, LoadAllOpts{Index: true})
+	if err != nil {
+		t.Fatalf("LoadAll failed: %v", err)
+	}
+	if len(containers) != 2 {
+		t.Fatalf("LoadAll returned %d containers, want 2", len(containers))
+	}
+	for _, c := range containers {
+		if c.Status != Stopped {
+			t.Errorf("container %q has status %v, want %v", c.ID, c.Status, Stopped)
+		}
+	}
+	idx := loadIndex(rootDir)
+	if len(idx.Containers) != 1 {
+		t.Errorf("index has %d containers, want 1", len(idx.Containers))
+	}
+	for name, e := range idx.Containers {
+		if e.Container.ID != "stopped" {
+			t.Errorf("index has container %q from %q, want only %q", e.Container.ID, name, "stopped")
+		}
+	}
+}
@@ -35,6 +35,7 @@ import (
 	"gvisor.dev/gvisor/pkg/test/testutil"
 	"gvisor.dev/gvisor/runsc/boot"
 	"gvisor.dev/gvisor/runsc/config"
+	"gvisor.dev/gvisor/runsc/sandbox"
 	"gvisor.dev/gvisor/runsc/specutils"
 )
 
@@ -445,6 +446,59 @@ func TestMultiPIDNSKill(t *testing.T) {
 	}
 }
 
+// TestMultiPIDNSSignalPID checks that processes can be signaled by their PID
+// in the container's PID namespace.
+func TestMultiPIDNSSignalPID(t *testing.T) {
+	for name, conf := range configs(t, all...) {
+		t.Run(name, func(t *testing.T) {
+			rootDir, cleanup, err := testutil.SetupRootDir()
+			if err != nil {
+				t.Fatalf("error creating root dir: %v", err)
+			}
+			defer cleanup()
+			conf.RootDir = rootDir
+
+			// Setup the containers.
+			sleep := []string{"sleep", "100"}
+			testSpecs, ids := createSpecs(sleep, sleep)
+			testSpecs[1].Linux = &specs.Linux{
+				Namespaces: []specs.LinuxNamespace{
+					{
+						Type: "pid",
+					},
+				},
+			}
+
+			containers, cleanup, err := startContainers(conf, testSpecs, ids)
+			if err != nil {
+				t.Fatalf("error starting containers: %v", err)
+			}
+			defer cleanup()
+
+			// The init process of the second container is PID 1 in its own PID
+			// namespace, unlike in the root PID namespace.
+			if err := containers[1].SignalPID(unix.SIGKILL, 1); err != nil {
+				t.Fatalf("container.SignalPID: %v", err)
+			}
+			ws, err := containers[1].Wait()
+			if err != nil {
+				t.Fatalf("container.Wait(): %v", err)
+			}
+			if !ws.Signaled() || ws.Signal() != unix.SIGKILL {
+				t.Errorf("container %q exited with %v, want SIGKILL", containers[1].ID, ws)
+			}
+
+			// The first container must still be running.
+			if err := waitForProcessCount(containers[0], 1); err != nil {
+				t.Errorf("error waiting for processes: %v", err)
+			}
+			if got := containers[0].Status; got != Running {
+				t.Errorf("container %q status: got %v, want %v", containers[0].ID, got, Running)
+			}
+		})
+	}
+}
+
 func TestMultiContainerWait(t *testing.T) {
 	rootDir, cleanup, err := testutil.SetupRootDir()
 	if err != nil {
@@ -1548,6 +1602,76 @@ func TestMultiContainerSharedMountUnsupportedOptions(t *testing.T) {
 	}
 }
 
+// Test that /dev/shm is shared between containers in the same pod, and that
+// it's backed by memory in the sandbox instead of the host directory.
+func TestMultiContainerSharedDevShm(t *testing.T) {
+	rootDir, cleanup, err := testutil.SetupRootDir()
+	if err != nil {
+		t.Fatalf("error creating root dir: %v", err)
+	}
+	defer cleanup()
+
+	conf := testutil.TestConfig(t)
+	conf.VFS2 = true
+	conf.RootDir = rootDir
+
+	shmDir, err := ioutil.TempDir(testutil.TmpDir(), "shm")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir() failed: %v", err)
+	}
+	defer os.RemoveAll(shmDir)
+
+	// Setup the containers like containerd does, with the shm directory of
+	// the sandbox mounted in all containers. The first container, like the
+	// pause container, mounts it read-only.
+	sleep := []string{"sleep", "100"}
+	podSpec, ids := createSpecs(sleep, sleep)
+	podSpec[0].Linux = &specs.Linux{
+		Namespaces: []specs.LinuxNamespace{{Type: specs.IPCNamespace}},
+	}
+	for i, spec := range podSpec {
+		mode := "rw"
+		if i == 0 {
+			mode = "ro"
+		}
+		spec.Mounts = append(spec.Mounts, specs.Mount{
+			Destination: "/dev/shm",
+			Source:      shmDir,
+			Type:        "bind",
+			Options:     []string{"rbind", mode, "nosuid", "nodev", "noexec"},
+		})
+	}
+
+	containers, cleanup, err := startContainers(conf, podSpec, ids)
+	if err != nil {
+		t.Fatalf("error starting containers: %v", err)
+	}
+	defer cleanup()
+
+	execs := []execDesc{
+		{
+			c:    containers[1],
+			cmd:  []string{"/bin/sh", "-c", "echo shared > /dev/shm/abc"},
+			name: "create file in container1",
+		},
+		{
+			c:    containers[0],
+			cmd:  []string{"/bin/grep", "-q", "shared", "/dev/shm/abc"},
+			name: "file appears in container0",
+		},
+		{
+			c:    containers[0],
+			cmd:  []string{"/bin/sh", "-c", "! touch /dev/shm/def"},
+			name: "container0 can't write to its read-only mount",
+		},
+	}
+	execMany(t, conf, execs)
+
+	if _, err := os.Stat(filepath.Join(shmDir, "abc")); !os.IsNotExist(err) {
+		t.Errorf("file was created in the host directory, stat: %v", err)
+	}
+}
+
 // Test that one container can send an FD to another container, even though
 // they have distinct MountNamespaces.
 func TestMultiContainerMultiRootCanHandleFDs(t *testing.T) {
@@ -1957,10 +2081,13 @@ func TestMultiContainerEvent(t *testing.T) {
 		if cont.ID != evt.ID {
 			t.Errorf("Wrong container ID, want: %s, got: %s", cont.ID, evt.ID)
 		}
-		// One process per remaining container.
-		if got, want := evt.Data.Pids.Current, uint64(2); got != want {
+		// One process per container.
+		if got, want := evt.Data.Pids.Current, uint64(1); got != want {
 			t.Errorf("Wrong number of PIDs, cid: %q, want: %d, got: %d", cont.ID, want, got)
 		}
+		if evt.Data.Memory.Usage.Usage == 0 {
+			t.Errorf("Container should report memory usage, cid: %q", cont.ID)
+		}
 
 		// The exited container should always have a usage of zero.
 		if exited := ret.ContainerUsage[containers[2].ID]; exited != 0 {
@@ -2088,3 +2215,86 @@ func TestDuplicateEnvVariable(t *testing.T) {
 		}
 	}
 }
+
+// TestMultiContainerCheckpointRestore checks that a sandbox is restored with
+// its subcontainers, and that a subcontainer can be waited on before the root
+// container is restored.
+func TestMultiContainerCheckpointRestore(t *testing.T) {
+	rootDir, cleanup, err := testutil.SetupRootDir()
+	if err != nil {
+		t.Fatalf("error creating root dir: %v", err)
+	}
+	defer cleanup()
+
+	conf := testutil.TestConfig(t)
+	conf.VFS2 = true
+	conf.RootDir = rootDir
+
+	sleep := []string{"sleep", "100"}
+	podSpec, ids := createSpecs(sleep, sleep)
+	containers, cleanup, err := startContainers(conf, podSpec, ids)
+	if err != nil {
+		t.Fatalf("error starting containers: %v", err)
+	}
+	defer cleanup()
+
+	dir, err := ioutil.TempDir(testutil.TmpDir(), "checkpoint-test")
+	if err != nil {
+		t.Fatalf("ioutil.TempDir failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	imagePath := filepath.Join(dir, "test-image-file")
+	file, err := os.OpenFile(imagePath, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0644)
+	if err != nil {
+		t.Fatalf("error opening new file at imagePath: %v", err)
+	}
+	defer file.Close()
+	if err := containers[0].Checkpoint(file, sandbox.CheckpointOpts{}); err != nil {
+		t.Fatalf("error checkpointing sandbox: %v", err)
+	}
+
+	// Restore into a new sandbox, subcontainers first.
+	restoreSpec, restoreIDs := createSpecs(sleep, sleep)
+	var restored []*Container
+	for i, spec := range restoreSpec {
+		bundleDir, cleanup, err := testutil.SetupBundleDir(spec)
+		if err != nil {
+			t.Fatalf("error setting up container: %v", err)
+		}
+		defer cleanup()
+		cont, err := New(conf, Args{
+			ID:        restoreIDs[i],
+			Spec:      spec,
+			BundleDir: bundleDir,
+		})
+		if err != nil {
+			t.Fatalf("error creating container: %v", err)
+		}
+		defer cont.Destroy()
+		restored = append(restored, cont)
+	}
+	if err := restored[1].Restore(restoreSpec[1], conf, imagePath); err != nil {
+		t.Fatalf("error restoring subcontainer: %v", err)
+	}
+
+	// Wait on the subcontainer like an attached restore does, before the
+	// root container is restored.
+	waitErr := make(chan error, 1)
+	go func() {
+		ws, err := restored[1].Wait()
+		if err == nil && ws.Signal() != unix.SIGKILL {
+			err = fmt.Errorf("got wait status %v, want SIGKILL", ws)
+		}
+		waitErr <- err
+	}()
+
+	if err := restored[0].Restore(restoreSpec[0], conf, imagePath); err != nil {
+		t.Fatalf("error restoring root container: %v", err)
+	}
+	if err := restored[1].SignalContainer(unix.SIGKILL, false); err != nil {
+		t.Fatalf("error killing restored subcontainer: %v", err)
+	}
+	if err := <-waitErr; err != nil {
+		t.Errorf("error waiting on restored subcontainer: %v", err)
+	}
+}
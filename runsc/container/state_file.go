@@ -38,6 +38,13 @@ type LoadOpts struct {
 
 	// SkipCheck tells Load() to skip checking if container is runnning.
 	SkipCheck bool
+
+	// TryLock tells Load() not to wait for the lock of the container, which
+	// runsc create holds until the container is created. If the lock is
+	// held, the metadata file is read without it and the status check is
+	// skipped. If the metadata file is being written, the container is
+	// reported as Creating.
+	TryLock bool
 }
 
 // Load loads a container with the given id from a metadata file. "id" may
@@ -69,33 +76,165 @@ func Load(rootDir string, id FullID, opts LoadOpts) (*Container, error) {
 	defer state.close()
 
 	c := &Container{}
-	if err := state.load(c); err != nil {
+	unlocked := false
+	var err error
+	if opts.TryLock {
+		unlocked, err = state.tryLoad(c)
+	} else {
+		err = state.load(c)
+	}
+	if err != nil {
 		if os.IsNotExist(err) {
 			// Preserve error so that callers can distinguish 'not found' errors.
 			return nil, err
 		}
+		if unlocked {
+			// The metadata file was read while being written.
+			log.Debugf("Container %+v is locked and its metadata file is incomplete, reporting it as creating: %v", id, err)
+			return &Container{ID: id.ContainerID, Status: Creating, Saver: StateFile{RootDir: rootDir, ID: id}}, nil
+		}
 		return nil, fmt.Errorf("reading container metadata file %q: %v", state.statePath(), err)
 	}
+	if c.Sandbox == nil && c.requireStatus("load", Created, Running, Paused) == nil {
+		// Only stopped containers can have no sandbox, callers rely on it.
+		return nil, fmt.Errorf("invalid container metadata file %q: container is %s without a sandbox", state.statePath(), c.Status)
+	}
 
-	if !opts.SkipCheck {
-		// If the status is "Running" or "Created", check that the sandbox/container
-		// is still running, setting it to Stopped if not.
-		//
-		// This is inherently racy.
-		switch c.Status {
-		case Created:
-			if !c.IsSandboxRunning() {
-				// Sandbox no longer exists, so this container definitely does not exist.
-				c.changeStatus(Stopped)
-			}
-		case Running:
-			if err := c.SignalContainer(unix.Signal(0), false); err != nil {
-				c.changeStatus(Stopped)
+	if !opts.SkipCheck && !unlocked {
+		c.checkStatus()
+	}
+
+	return c, nil
+}
+
+// checkStatus checks that a "Running" or "Created" container is still
+// running, setting it to Stopped if not.
+//
+// This is inherently racy.
+func (c *Container) checkStatus() {
+	switch c.Status {
+	case Created:
+		if !c.IsSandboxRunning() {
+			// Sandbox no longer exists, so this container definitely does not exist.
+			c.changeStatus(Stopped)
+			c.recordCrashReport(c.Sandbox, true /* save */)
+		}
+	case Running:
+		if !c.IsSandboxRunning() {
+			// Don't bother connecting to a sandbox that no longer exists.
+			c.changeStatus(Stopped)
+			c.recordCrashReport(c.Sandbox, true /* save */)
+		} else if err := c.SignalContainer(unix.Signal(0), false); err != nil {
+			c.changeStatus(Stopped)
+		}
+	}
+}
+
+// LoadAllOpts provides options for LoadAll().
+type LoadAllOpts struct {
+	// Index tells LoadAll() to use the index file of the root directory, see
+	// index.
+	Index bool
+}
+
+// loadConcurrency is the number of containers loaded concurrently by
+// LoadAll().
+const loadConcurrency = 32
+
+// LoadAll loads all containers in the given root directory, like Load() with
+// LoadOpts.Exact and LoadOpts.TryLock set, in the order of List(). Containers
+// are loaded concurrently, and the ones that fail to load are skipped.
+func LoadAll(rootDir string, opts LoadAllOpts) ([]*Container, error) {
+	ids, err := List(rootDir)
+	if err != nil {
+		return nil, err
+	}
+	var idx *index
+	if opts.Index {
+		idx = loadIndex(rootDir)
+	}
+
+	loaded := make([]*Container, len(ids))
+	infos := make([]os.FileInfo, len(ids))
+	hits := make([]bool, len(ids))
+	work := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < loadConcurrency && w < len(ids); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				loaded[i], infos[i], hits[i] = loadIndexed(rootDir, ids[i], idx)
 			}
+		}()
+	}
+	for i := range ids {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+
+	var containers []*Container
+	updated := newIndex()
+	changed := false
+	for i, c := range loaded {
+		if c == nil {
+			continue
+		}
+		containers = append(containers, c)
+		if idx != nil && infos[i] != nil {
+			updated.add(infos[i], c)
+			changed = changed || !hits[i]
+		}
+	}
+	if idx != nil && (changed || len(updated.Containers) != len(idx.Containers)) {
+		if err := updated.save(rootDir); err != nil {
+			log.Warningf("Failed to save the index of %q: %v", rootDir, err)
 		}
 	}
+	return containers, nil
+}
 
-	return c, nil
+// loadIndexed loads the container with the given id from idx if it's there and
+// up to date, and from its metadata file otherwise. It returns the container,
+// or nil if it failed to load, the metadata file if the container can be
+// indexed, and whether the container was found in idx.
+//
+// Only containers that are stopped in their metadata file can be indexed.
+// Containers found stopped by the status check, e.g. because their sandbox
+// didn't answer, are checked again by the next LoadAll().
+func loadIndexed(rootDir string, id FullID, idx *index) (*Container, os.FileInfo, bool) {
+	// The metadata file is checked before loading the container, so that
+	// changes made while loading are seen by the next LoadAll().
+	state := StateFile{RootDir: rootDir, ID: id}
+	info, err := os.Stat(state.statePath())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warningf("Skipping container %q: %v", id, err)
+		}
+		return nil, nil, false
+	}
+	if c := idx.lookup(info); c != nil {
+		return c, info, true
+	}
+	c, err := Load(rootDir, id, LoadOpts{Exact: true, SkipCheck: true, TryLock: true})
+	if err != nil {
+		// Container file may not exist if it raced with deletion.
+		if !os.IsNotExist(err) {
+			log.Warningf("Skipping container %q: %v", id, err)
+		}
+		return nil, nil, false
+	}
+	if c.Status == Creating {
+		// The container may still be locked by runsc create, see
+		// LoadOpts.TryLock.
+		return c, nil, false
+	}
+	if c.Status != Stopped {
+		c.checkStatus()
+		return c, nil, false
+	}
+	return c, info, false
 }
 
 // List returns all container ids in the given root directory.
@@ -215,10 +354,10 @@ func (f *FullID) String() string {
 }
 
 func (f *FullID) validate() error {
-	if err := validateID(f.SandboxID); err != nil {
+	if err := ValidateID(f.SandboxID); err != nil {
 		return err
 	}
-	return validateID(f.ContainerID)
+	return ValidateID(f.ContainerID)
 }
 
 // StateFile handles load from/save to container state safely from multiple
@@ -313,12 +452,52 @@ func (s *StateFile) saveLocked(v interface{}) error {
 	return nil
 }
 
+// tryLock is like lock, but returns false instead of waiting if the lock is
+// held by another process.
+func (s *StateFile) tryLock() (bool, error) {
+	s.once.Do(func() {
+		s.flock = flock.New(s.lockPath())
+	})
+
+	ok, err := s.flock.TryLock()
+	if err != nil {
+		return false, fmt.Errorf("acquiring lock on %q: %v", s.flock, err)
+	}
+	return ok, nil
+}
+
 func (s *StateFile) load(v interface{}) error {
 	if err := s.lock(); err != nil {
 		return err
 	}
 	defer s.unlockOrDie()
+	return s.loadLocked(v)
+}
+
+// tryLoad is like load, but doesn't wait for the lock if it's held by another
+// process. In that case, 'v' is loaded without the lock and true is returned.
+// The state file may then be partially written, causing an error.
+func (s *StateFile) tryLoad(v interface{}) (bool, error) {
+	ok, err := s.tryLock()
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		return true, s.read(v)
+	}
+	defer s.unlockOrDie()
+	return false, s.loadLocked(v)
+}
 
+// loadLocked loads 'v' from the state file.
+//
+// Preconditions: lock() must been called before.
+func (s *StateFile) loadLocked(v interface{}) error {
+	return s.read(v)
+}
+
+// read reads 'v' from the state file.
+func (s *StateFile) read(v interface{}) error {
 	metaBytes, err := ioutil.ReadFile(s.statePath())
 	if err != nil {
 		return err
@@ -352,6 +531,12 @@ func (s *StateFile) lockPath() string {
 	return buildPath(s.RootDir, s.ID, "lock")
 }
 
+// hostsPath is the full path to the hosts file generated for the container,
+// see Container.setupHosts.
+func (s *StateFile) hostsPath() string {
+	return buildPath(s.RootDir, s.ID, "hosts")
+}
+
 // destroy deletes all state created by the stateFile. It may be called with the
 // lock file held. In that case, the lock file must still be unlocked and
 // properly closed after destroy returns.
@@ -359,6 +544,9 @@ func (s *StateFile) destroy() error {
 	if err := os.Remove(s.statePath()); err != nil && !os.IsNotExist(err) {
 		return err
 	}
+	if err := os.Remove(s.hostsPath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
 	if err := os.Remove(s.lockPath()); err != nil && !os.IsNotExist(err) {
 		return err
 	}
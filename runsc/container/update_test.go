@@ -0,0 +1,52 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package container
+
+import (
+	"testing"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func TestMergeResources(t *testing.T) {
+	limit, newLimit := int64(1<<30), int64(2<<30)
+	shares, quota := uint64(1024), int64(50000)
+	dst := &specs.LinuxResources{
+		CPU:    &specs.LinuxCPU{Shares: &shares, Cpus: "0-3"},
+		Memory: &specs.LinuxMemory{Limit: &limit},
+		Pids:   &specs.LinuxPids{Limit: 100},
+	}
+	got := mergeResources(dst, &specs.LinuxResources{
+		CPU:    &specs.LinuxCPU{Quota: &quota},
+		Memory: &specs.LinuxMemory{Limit: &newLimit},
+		Pids:   &specs.LinuxPids{},
+	})
+	if got.CPU.Shares == nil || *got.CPU.Shares != shares || got.CPU.Cpus != "0-3" {
+		t.Errorf("CPU limits not updated were changed: %+v", got.CPU)
+	}
+	if got.CPU.Quota == nil || *got.CPU.Quota != quota {
+		t.Errorf("CPU quota not updated: %+v", got.CPU)
+	}
+	if got.Memory.Limit == nil || *got.Memory.Limit != newLimit {
+		t.Errorf("memory limit not updated: %+v", got.Memory)
+	}
+	if got.Pids.Limit != 100 {
+		t.Errorf("pids limit changed by an unset limit: %d", got.Pids.Limit)
+	}
+
+	if got := mergeResources(nil, &specs.LinuxResources{Pids: &specs.LinuxPids{Limit: 10}}); got.Pids == nil || got.Pids.Limit != 10 {
+		t.Errorf("mergeResources(nil) = %+v, want pids limit 10", got)
+	}
+}
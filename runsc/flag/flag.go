@@ -25,15 +25,20 @@ import (
 // FlagSet is an alias for flag.FlagSet.
 type FlagSet = flag.FlagSet
 
+// Flag is an alias for flag.Flag.
+type Flag = flag.Flag
+
 // Aliases for flag functions.
 var (
 	Bool        = flag.Bool
 	CommandLine = flag.CommandLine
+	Duration    = flag.Duration
 	Int         = flag.Int
 	NewFlagSet  = flag.NewFlagSet
 	Parse       = flag.Parse
 	String      = flag.String
 	Uint        = flag.Uint
+	Uint64      = flag.Uint64
 	Var         = flag.Var
 )
 
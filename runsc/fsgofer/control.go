@@ -0,0 +1,43 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsgofer
+
+import (
+	"fmt"
+
+	"gvisor.dev/gvisor/pkg/p9/opstats"
+)
+
+// ControlStats is the control RPC returning the operation statistics of the
+// gofer.
+const ControlStats = "Control.Stats"
+
+// ControlSocketAddr returns the abstract unix socket name of the control
+// server of the gofer of container id.
+func ControlSocketAddr(id string) string {
+	return fmt.Sprintf("\x00runsc-gofer.%s", id)
+}
+
+// Control is the RPC interface served on the control socket of the gofer.
+type Control struct {
+	// Recorder records the operations served by the gofer.
+	Recorder *opstats.Recorder
+}
+
+// Stats returns the operation statistics of the gofer.
+func (c *Control) Stats(_ *struct{}, out *opstats.Stats) error {
+	*out = c.Recorder.Stats()
+	return nil
+}
@@ -252,3 +252,26 @@ var xattrSyscalls = seccomp.SyscallRules{
 	unix.SYS_FGETXATTR: {},
 	unix.SYS_FSETXATTR: {},
 }
+
+func controlServerFilters(fd int) seccomp.SyscallRules {
+	return seccomp.SyscallRules{
+		unix.SYS_ACCEPT4: []seccomp.Rule{
+			{
+				seccomp.EqualTo(fd),
+			},
+		},
+		unix.SYS_LISTEN: []seccomp.Rule{
+			{
+				seccomp.EqualTo(fd),
+				seccomp.EqualTo(16 /* unet.backlog */),
+			},
+		},
+		unix.SYS_GETSOCKOPT: []seccomp.Rule{
+			{
+				seccomp.MatchAny{},
+				seccomp.EqualTo(unix.SOL_SOCKET),
+				seccomp.EqualTo(unix.SO_PEERCRED),
+			},
+		},
+	}
+}
@@ -38,7 +38,13 @@ func InstallUDSFilters() {
 }
 
 // InstallXattrFilters extends the allowed syscalls to include xattr calls that
-// are necessary for Verity enabled file systems.
+// are necessary for Verity enabled file systems and overlay upper layers.
 func InstallXattrFilters() {
 	allowedSyscalls.Merge(xattrSyscalls)
 }
+
+// InstallControlServerFilters extends the allowed syscalls to include those
+// necessary to serve the control server listening on fd.
+func InstallControlServerFilters(fd int) {
+	allowedSyscalls.Merge(controlServerFilters(fd))
+}
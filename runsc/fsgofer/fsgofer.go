@@ -57,6 +57,14 @@ var verityXattrs = map[string]struct{}{
 	"user.merkle.childrenSize":   {},
 }
 
+// overlayXattrs maps the extended attributes used by the overlay file system
+// to the ones storing them on the host. The trusted namespace requires
+// CAP_SYS_ADMIN on the host, so they are stored in the user namespace, like
+// Linux's overlayfs does with the userxattr mount option.
+var overlayXattrs = map[string]string{
+	"trusted.overlay.opaque": "user.overlay.opaque",
+}
+
 // join is equivalent to path.Join() but skips path.Clean() which is expensive.
 func join(parent, child string) string {
 	return parent + "/" + child
@@ -76,12 +84,24 @@ type Config struct {
 	// EnableVerityXattr allows access to extended attributes used by the
 	// verity file system.
 	EnableVerityXattr bool
+
+	// OverlayUpper allows creating the whiteouts and accessing the extended
+	// attributes used by the overlay file system, for attach points backing
+	// the upper layer of an overlay.
+	OverlayUpper bool
+
+	// Throttle limits the rate of reads and writes. It may be nil.
+	Throttle *Throttle
 }
 
 type attachPoint struct {
 	prefix string
 	conf   Config
 
+	// dirFD is the directory served by the attach point, or -1 if prefix is
+	// the path to it. See NewAttachPointFD.
+	dirFD int
+
 	// attachedMu protects attached.
 	attachedMu sync.Mutex
 	attached   bool
@@ -107,6 +127,23 @@ func NewAttachPoint(prefix string, c Config) (p9.Attacher, error) {
 	return &attachPoint{
 		prefix:  prefix,
 		conf:    c,
+		dirFD:   -1,
+		devices: make(map[uint64]uint8),
+	}, nil
+}
+
+// NewAttachPointFD creates a new attacher that gives local file access to all
+// files under the directory dirFD, which is typically opened before the Gofer
+// changes its root and is then unreachable by path. name is used for logging
+// only.
+func NewAttachPointFD(name string, dirFD int, c Config) (p9.Attacher, error) {
+	if dirFD < 0 {
+		return nil, fmt.Errorf("invalid FD %d for attach point %q", dirFD, name)
+	}
+	return &attachPoint{
+		prefix:  name,
+		conf:    c,
+		dirFD:   dirFD,
 		devices: make(map[uint64]uint8),
 	}, nil
 }
@@ -121,6 +158,13 @@ func (a *attachPoint) Attach() (p9.File, error) {
 	}
 
 	f, readable, err := openAnyFile(a.prefix, func(mode int) (*fd.FD, error) {
+		if a.dirFD >= 0 {
+			hostFD, err := unix.Openat(a.dirFD, ".", openFlags|mode, 0)
+			if err != nil {
+				return nil, err
+			}
+			return fd.New(hostFD), nil
+		}
 		return fd.Open(a.prefix, openFlags|mode, 0)
 	})
 	if err != nil {
@@ -240,6 +284,9 @@ type localFile struct {
 
 	qid p9.QID
 
+	// throttle limits the I/O to the host device of the file. It may be nil.
+	throttle *DeviceThrottle
+
 	// readDirMu protects against concurrent Readdir calls.
 	readDirMu sync.Mutex
 
@@ -353,6 +400,7 @@ func newLocalFile(a *attachPoint, file *fd.FD, path string, readable bool, stat
 		mode:            invalidMode,
 		fileType:        stat.Mode & unix.S_IFMT,
 		qid:             a.makeQID(stat),
+		throttle:        a.conf.Throttle.Device(stat.Dev),
 		controlReadable: readable,
 	}, nil
 }
@@ -774,7 +822,15 @@ func (l *localFile) SetAttr(valid p9.SetAttrMask, attr p9.SetAttr) error {
 			}
 		}
 
-		if l.fileType == unix.S_IFLNK {
+		if l.fileType == unix.S_IFLNK && l.attachPoint.dirFD >= 0 {
+			// The parent can't be opened by path, see NewAttachPointFD. Following
+			// the magic link of the symlink's FD in /proc/self/fd leads to the
+			// symlink itself.
+			if tErr := utimensat(int(procSelfFD.FD()), strconv.Itoa(f.FD()), utimes, 0); tErr != nil {
+				log.Debugf("SetAttr utimens failed %q, err: %v", l.hostPath, tErr)
+				err = extractErrno(tErr)
+			}
+		} else if l.fileType == unix.S_IFLNK {
 			// utimensat operates different that other syscalls. To operate on a
 			// symlink it *requires* AT_SYMLINK_NOFOLLOW with dirFD and a non-empty
 			// name.
@@ -816,28 +872,40 @@ func (l *localFile) SetAttr(valid p9.SetAttrMask, attr p9.SetAttr) error {
 	return err
 }
 
-func (l *localFile) GetXattr(name string, size uint64) (string, error) {
-	if !l.attachPoint.conf.EnableVerityXattr {
-		return "", unix.EOPNOTSUPP
+// hostXattr returns the name of the host extended attribute storing the
+// extended attribute name, or false if it can't be accessed.
+func (l *localFile) hostXattr(name string) (string, bool) {
+	if l.attachPoint.conf.EnableVerityXattr {
+		if _, ok := verityXattrs[name]; ok {
+			return name, true
+		}
+	}
+	if l.attachPoint.conf.OverlayUpper {
+		if hostName, ok := overlayXattrs[name]; ok {
+			return hostName, true
+		}
 	}
-	if _, ok := verityXattrs[name]; !ok {
+	return "", false
+}
+
+func (l *localFile) GetXattr(name string, size uint64) (string, error) {
+	hostName, ok := l.hostXattr(name)
+	if !ok {
 		return "", unix.EOPNOTSUPP
 	}
 	buffer := make([]byte, size)
-	if _, err := unix.Fgetxattr(l.file.FD(), name, buffer); err != nil {
+	if _, err := unix.Fgetxattr(l.file.FD(), hostName, buffer); err != nil {
 		return "", err
 	}
 	return string(buffer), nil
 }
 
 func (l *localFile) SetXattr(name string, value string, flags uint32) error {
-	if !l.attachPoint.conf.EnableVerityXattr {
-		return unix.EOPNOTSUPP
-	}
-	if _, ok := verityXattrs[name]; !ok {
+	hostName, ok := l.hostXattr(name)
+	if !ok {
 		return unix.EOPNOTSUPP
 	}
-	return unix.Fsetxattr(l.file.FD(), name, []byte(value), int(flags))
+	return unix.Fsetxattr(l.file.FD(), hostName, []byte(value), int(flags))
 }
 
 func (*localFile) ListXattr(uint64) (map[string]struct{}, error) {
@@ -887,6 +955,7 @@ func (l *localFile) ReadAt(p []byte, offset uint64) (int, error) {
 		return 0, unix.EBADF
 	}
 
+	l.throttle.Read(len(p))
 	r, err := l.file.ReadAt(p, int64(offset))
 	switch err {
 	case nil, io.EOF:
@@ -905,6 +974,7 @@ func (l *localFile) WriteAt(p []byte, offset uint64) (int, error) {
 		return 0, unix.EBADF
 	}
 
+	l.throttle.Write(len(p))
 	w, err := l.file.WriteAt(p, int64(offset))
 	if err != nil {
 		return w, extractErrno(err)
@@ -959,7 +1029,7 @@ func (l *localFile) Link(target p9.File, newName string) error {
 }
 
 // Mknod implements p9.File.
-func (l *localFile) Mknod(name string, mode p9.FileMode, _ uint32, _ uint32, uid p9.UID, gid p9.GID) (p9.QID, error) {
+func (l *localFile) Mknod(name string, mode p9.FileMode, major uint32, minor uint32, uid p9.UID, gid p9.GID) (p9.QID, error) {
 	if err := l.checkROMount(); err != nil {
 		return p9.QID{}, err
 	}
@@ -967,11 +1037,15 @@ func (l *localFile) Mknod(name string, mode p9.FileMode, _ uint32, _ uint32, uid
 	// From mknod(2) man page:
 	// "EPERM: [...] if the filesystem containing pathname does not support
 	// the type of node requested."
-	if mode.FileType() != p9.ModeRegular {
+	//
+	// Overlay whiteouts, i.e. character devices with device number 0/0, are
+	// allowed in the upper layers of overlays.
+	isWhiteout := l.attachPoint.conf.OverlayUpper && mode.FileType() == p9.ModeCharacterDevice && major == 0 && minor == 0
+	if mode.FileType() != p9.ModeRegular && !isWhiteout {
 		return p9.QID{}, unix.EPERM
 	}
 
-	// Allow Mknod to create regular files.
+	// Allow Mknod to create regular files and whiteouts.
 	if err := unix.Mknodat(l.file.FD(), name, uint32(mode), 0); err != nil {
 		return p9.QID{}, err
 	}
@@ -433,7 +433,7 @@ func (fd *controlFDLisa) Open(c *lisafs.Connection, comm lisafs.Communicator, fl
 	if err != nil {
 		return 0, err
 	}
-	newFD := fd.newOpenFDLisa(newHostFD, flags)
+	newFD := fd.newOpenFDLisa(c, newHostFD, flags)
 
 	if fd.IsRegular() {
 		// Donate FD for regular files only. Since FD donation is a destructive
@@ -496,7 +496,7 @@ func (fd *controlFDLisa) OpenCreate(c *lisafs.Connection, comm lisafs.Communicat
 		}
 		cu.Release()
 
-		newFD = childFD.newOpenFDLisa(newHostFD, uint32(flags))
+		newFD = childFD.newOpenFDLisa(c, newHostFD, uint32(flags))
 		resp.NewFD = newFD.ID()
 		return nil
 	}); err != nil {
@@ -832,14 +832,25 @@ type openFDLisa struct {
 
 	// hostFD is the host file descriptor which can be used to make syscalls.
 	hostFD int
+
+	// throttle limits the I/O to the host device of the file. It may be nil.
+	throttle *DeviceThrottle
 }
 
 var _ lisafs.OpenFDImpl = (*openFDLisa)(nil)
 
-func (fd *controlFDLisa) newOpenFDLisa(hostFD int, flags uint32) *openFDLisa {
+func (fd *controlFDLisa) newOpenFDLisa(c *lisafs.Connection, hostFD int, flags uint32) *openFDLisa {
 	newFD := &openFDLisa{
 		hostFD: hostFD,
 	}
+	if t := c.ServerImpl().(*LisafsServer).config.Throttle; t != nil {
+		var stat unix.Stat_t
+		if err := unix.Fstat(hostFD, &stat); err != nil {
+			log.Warningf("fstat(%d) failed, not throttling I/O: %v", hostFD, err)
+		} else {
+			newFD.throttle = t.Device(stat.Dev)
+		}
+	}
 	newFD.OpenFD.Init(fd.FD(), flags, newFD)
 	return newFD
 }
@@ -879,6 +890,7 @@ func (fd *openFDLisa) Sync(c *lisafs.Connection) error {
 
 // Write implements lisafs.OpenFDImpl.Write.
 func (fd *openFDLisa) Write(c *lisafs.Connection, comm lisafs.Communicator, buf []byte, off uint64) (uint32, error) {
+	fd.throttle.Write(len(buf))
 	rw := rwfd.NewReadWriter(fd.hostFD)
 	n, err := rw.WriteAt(buf, int64(off))
 	if err != nil {
@@ -900,6 +912,7 @@ func (fd *openFDLisa) Read(c *lisafs.Connection, comm lisafs.Communicator, off u
 	maxRespLen := respMetaSize + count
 
 	payloadBuf := comm.PayloadBuf(maxRespLen)
+	fd.throttle.Read(int(count))
 	rw := rwfd.NewReadWriter(fd.hostFD)
 	n, err := rw.ReadAt(payloadBuf[respMetaSize:], int64(off))
 	if err != nil && err != io.EOF {
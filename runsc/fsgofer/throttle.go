@@ -0,0 +1,383 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsgofer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"golang.org/x/sys/unix"
+	"gvisor.dev/gvisor/pkg/sync"
+)
+
+// Throttle limits the rate of reads and writes of files, per host device,
+// like the blkio cgroup controller does. The I/O of all containers in a
+// sandbox goes through their gofers, so the blkio cgroups of containers can't
+// tell it apart, and the limits of each container are enforced by its gofer
+// instead.
+//
+// Limits name disks, while the device of files reported by stat(2) is often a
+// partition, or an anonymous device for filesystems like overlayfs. Those are
+// mapped to their disk, see MountDisks.
+type Throttle struct {
+	// devices maps host disks to their limits. It's immutable.
+	devices map[uint64]*DeviceThrottle
+
+	// disks maps the devices of files to the disk they're on, for devices
+	// that aren't disks themselves. It's immutable.
+	disks map[uint64]uint64
+}
+
+// DeviceThrottle holds the limits of a disk. Limits that aren't set are nil.
+type DeviceThrottle struct {
+	readBytes  *tokenBucket
+	writeBytes *tokenBucket
+	readOps    *tokenBucket
+	writeOps   *tokenBucket
+}
+
+// NewThrottle returns a Throttle enforcing the throttling limits of blockIO,
+// or nil if there are none. disks maps the devices of files to their disk, as
+// returned by MountDisks. It may be nil.
+func NewThrottle(blockIO *specs.LinuxBlockIO, disks map[uint64]uint64) *Throttle {
+	if blockIO == nil {
+		return nil
+	}
+	t := &Throttle{
+		devices: make(map[uint64]*DeviceThrottle),
+		disks:   disks,
+	}
+	add := func(limits []specs.LinuxThrottleDevice, set func(*DeviceThrottle, *tokenBucket)) {
+		for _, l := range limits {
+			if l.Rate == 0 {
+				// Like the blkio controller, 0 removes the limit.
+				continue
+			}
+			dev := unix.Mkdev(uint32(l.Major), uint32(l.Minor))
+			d, ok := t.devices[dev]
+			if !ok {
+				d = &DeviceThrottle{}
+				t.devices[dev] = d
+			}
+			set(d, newTokenBucket(l.Rate, time.Now))
+		}
+	}
+	add(blockIO.ThrottleReadBpsDevice, func(d *DeviceThrottle, b *tokenBucket) { d.readBytes = b })
+	add(blockIO.ThrottleWriteBpsDevice, func(d *DeviceThrottle, b *tokenBucket) { d.writeBytes = b })
+	add(blockIO.ThrottleReadIOPSDevice, func(d *DeviceThrottle, b *tokenBucket) { d.readOps = b })
+	add(blockIO.ThrottleWriteIOPSDevice, func(d *DeviceThrottle, b *tokenBucket) { d.writeOps = b })
+	if len(t.devices) == 0 {
+		return nil
+	}
+	return t
+}
+
+// Device returns the limits of I/O to files of dev, the device reported by
+// stat(2) for them, or nil if it isn't limited. t may be nil.
+func (t *Throttle) Device(dev uint64) *DeviceThrottle {
+	if t == nil {
+		return nil
+	}
+	if disk, ok := t.disks[dev]; ok {
+		dev = disk
+	}
+	return t.devices[dev]
+}
+
+// Read blocks until reading size bytes is allowed. d may be nil.
+func (d *DeviceThrottle) Read(size int) {
+	if d == nil {
+		return
+	}
+	d.readOps.wait(1)
+	d.readBytes.wait(uint64(size))
+}
+
+// Write blocks until writing size bytes is allowed. d may be nil.
+func (d *DeviceThrottle) Write(size int) {
+	if d == nil {
+		return
+	}
+	d.writeOps.wait(1)
+	d.writeBytes.wait(uint64(size))
+}
+
+// sysDevBlock is the directory of block devices in sysfs, keyed by device
+// number. It's a variable for tests.
+var sysDevBlock = "/sys/dev/block"
+
+// mountInfo is a mount of /proc/self/mountinfo.
+type mountInfo struct {
+	dev    uint64
+	fstype string
+	source string
+	opts   []string
+}
+
+// MountDisks returns the disks of the mounts of the current mount namespace,
+// keyed by the device of their files, for the devices that aren't disks:
+//   - Partitions are mapped to their disk.
+//   - Filesystems using anonymous devices are mapped to the disk of their
+//     source device, like btrfs, or to the disk of their upper layer for
+//     overlayfs.
+//
+// It requires /proc and /sys, so it must be called before the root changes.
+func MountDisks() (map[uint64]uint64, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	mounts, err := parseMountInfo(f)
+	if err != nil {
+		return nil, err
+	}
+	byDev := make(map[uint64]*mountInfo)
+	for i := range mounts {
+		byDev[mounts[i].dev] = &mounts[i]
+	}
+	disks := make(map[uint64]uint64)
+	for dev := range byDev {
+		if disk, ok := deviceDisk(byDev, dev, 0); ok && disk != dev {
+			disks[dev] = disk
+		}
+	}
+	return disks, nil
+}
+
+// parseMountInfo parses the contents of /proc/self/mountinfo.
+func parseMountInfo(r io.Reader) ([]mountInfo, error) {
+	var mounts []mountInfo
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		// See Linux's Documentation/filesystems/proc.rst: the optional fields
+		// end with "-", followed by the filesystem type, source and super
+		// options.
+		fields := strings.Fields(s.Text())
+		sep := -1
+		for i := 6; i < len(fields); i++ {
+			if fields[i] == "-" {
+				sep = i
+				break
+			}
+		}
+		if len(fields) < 3 || sep < 0 || sep+3 >= len(fields) {
+			return nil, fmt.Errorf("invalid mountinfo line %q", s.Text())
+		}
+		dev, err := parseDev(fields[2])
+		if err != nil {
+			return nil, err
+		}
+		var opts []string
+		for _, opt := range strings.Split(fields[sep+3], ",") {
+			opts = append(opts, unescapeMountInfo(opt))
+		}
+		mounts = append(mounts, mountInfo{
+			dev:    dev,
+			fstype: fields[sep+1],
+			source: unescapeMountInfo(fields[sep+2]),
+			opts:   opts,
+		})
+	}
+	return mounts, s.Err()
+}
+
+// unescapeMountInfo replaces the octal escapes of mountinfo fields.
+func unescapeMountInfo(s string) string {
+	return strings.NewReplacer(`\040`, " ", `\011`, "\t", `\012`, "\n", `\134`, `\`).Replace(s)
+}
+
+// deviceDisk returns the disk of the files of dev. mounts are the mounts by
+// device, and depth is the number of stacked filesystems resolved so far.
+func deviceDisk(mounts map[uint64]*mountInfo, dev uint64, depth int) (uint64, bool) {
+	if unix.Major(dev) != 0 {
+		return blockDisk(dev)
+	}
+	m, ok := mounts[dev]
+	if !ok || depth > 4 {
+		return 0, false
+	}
+	path := m.source
+	if m.fstype == "overlay" {
+		// Writes go to the upper layer, if any.
+		path = ""
+		for _, opt := range m.opts {
+			if strings.HasPrefix(opt, "upperdir=") {
+				path = strings.TrimPrefix(opt, "upperdir=")
+			} else if strings.HasPrefix(opt, "lowerdir=") && path == "" {
+				path = strings.Split(strings.TrimPrefix(opt, "lowerdir="), ":")[0]
+			}
+		}
+	}
+	if !filepath.IsAbs(path) {
+		return 0, false
+	}
+	var stat unix.Stat_t
+	if err := unix.Stat(path, &stat); err != nil {
+		return 0, false
+	}
+	if stat.Mode&unix.S_IFMT == unix.S_IFBLK {
+		return blockDisk(stat.Rdev)
+	}
+	return deviceDisk(mounts, stat.Dev, depth+1)
+}
+
+// blockDisk returns the disk of the block device dev, which is dev itself
+// unless it's a partition.
+func blockDisk(dev uint64) (uint64, bool) {
+	dir, err := filepath.EvalSymlinks(filepath.Join(sysDevBlock, formatDev(dev)))
+	if err != nil {
+		return 0, false
+	}
+	if _, err := os.Stat(filepath.Join(dir, "partition")); err != nil {
+		return dev, true
+	}
+	// Partitions are in the directory of their disk.
+	b, err := ioutil.ReadFile(filepath.Join(filepath.Dir(dir), "dev"))
+	if err != nil {
+		return 0, false
+	}
+	disk, err := parseDev(strings.TrimSpace(string(b)))
+	if err != nil {
+		return 0, false
+	}
+	return disk, true
+}
+
+// FormatDisks formats disks, as returned by MountDisks, to be parsed by
+// ParseDisks.
+func FormatDisks(disks map[uint64]uint64) string {
+	var parts []string
+	for dev, disk := range disks {
+		parts = append(parts, formatDev(dev)+"="+formatDev(disk))
+	}
+	return strings.Join(parts, ",")
+}
+
+// ParseDisks parses disks formatted by FormatDisks.
+func ParseDisks(s string) (map[uint64]uint64, error) {
+	disks := make(map[uint64]uint64)
+	if s == "" {
+		return disks, nil
+	}
+	for _, part := range strings.Split(s, ",") {
+		devs := strings.SplitN(part, "=", 2)
+		if len(devs) != 2 {
+			return nil, fmt.Errorf("invalid device mapping %q", part)
+		}
+		dev, err := parseDev(devs[0])
+		if err != nil {
+			return nil, err
+		}
+		disk, err := parseDev(devs[1])
+		if err != nil {
+			return nil, err
+		}
+		disks[dev] = disk
+	}
+	return disks, nil
+}
+
+// formatDev formats dev as "major:minor".
+func formatDev(dev uint64) string {
+	return fmt.Sprintf("%d:%d", unix.Major(dev), unix.Minor(dev))
+}
+
+// parseDev parses a device formatted as "major:minor".
+func parseDev(s string) (uint64, error) {
+	parts := strings.SplitN(s, ":", 2)
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("invalid device %q", s)
+	}
+	major, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid device %q: %v", s, err)
+	}
+	minor, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid device %q: %v", s, err)
+	}
+	return unix.Mkdev(uint32(major), uint32(minor)), nil
+}
+
+// tokenBucket allows rate units per second, with bursts of up to one second
+// worth of units.
+type tokenBucket struct {
+	// rate is the number of units allowed per second. It's immutable.
+	rate uint64
+
+	// now returns the current time. It's immutable.
+	now func() time.Time
+
+	// mu protects the fields below.
+	mu sync.Mutex
+
+	// tokens is the number of units available at last. It's negative when
+	// units were reserved ahead of time by waiters.
+	tokens float64
+
+	// last is the time tokens was last updated.
+	last time.Time
+}
+
+// newTokenBucket returns a full tokenBucket allowing rate units per second.
+func newTokenBucket(rate uint64, now func() time.Time) *tokenBucket {
+	return &tokenBucket{
+		rate:   rate,
+		now:    now,
+		tokens: float64(rate),
+		last:   now(),
+	}
+}
+
+// reserve takes n units from b, and returns how long to wait until they're
+// available.
+func (b *tokenBucket) reserve(n uint64) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * float64(b.rate)
+		if max := float64(b.rate); b.tokens > max {
+			b.tokens = max
+		}
+		b.last = now
+	}
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / float64(b.rate) * float64(time.Second))
+}
+
+// wait blocks until n units are available. b may be nil, in which case there
+// is no limit.
+func (b *tokenBucket) wait(n uint64) {
+	if b == nil {
+		return
+	}
+	if d := b.reserve(n); d > 0 {
+		time.Sleep(d)
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fsgofer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"golang.org/x/sys/unix"
+)
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Unix(0, 0)
+	b := newTokenBucket(100, func() time.Time { return now })
+
+	// The bucket starts full.
+	if d := b.reserve(100); d != 0 {
+		t.Errorf("reserve(100) on a full bucket = %v, want 0", d)
+	}
+	// Units beyond the burst must wait for their refill.
+	if d, want := b.reserve(50), 500*time.Millisecond; d != want {
+		t.Errorf("reserve(50) on an empty bucket = %v, want %v", d, want)
+	}
+	// Waiting reservations are accounted for.
+	now = now.Add(time.Second)
+	if d, want := b.reserve(100), 500*time.Millisecond; d != want {
+		t.Errorf("reserve(100) = %v, want %v", d, want)
+	}
+	// Refills are capped to one second worth of units.
+	now = now.Add(time.Hour)
+	if d := b.reserve(100); d != 0 {
+		t.Errorf("reserve(100) after an hour = %v, want 0", d)
+	}
+	if d := b.reserve(1); d == 0 {
+		t.Errorf("reserve(1) after the burst = 0, want > 0")
+	}
+}
+
+func TestNewThrottle(t *testing.T) {
+	if th := NewThrottle(nil, nil); th != nil {
+		t.Errorf("NewThrottle(nil) = %+v, want nil", th)
+	}
+	weight := uint16(500)
+	if th := NewThrottle(&specs.LinuxBlockIO{Weight: &weight}, nil); th != nil {
+		t.Errorf("NewThrottle() without limits = %+v, want nil", th)
+	}
+
+	var limit specs.LinuxThrottleDevice
+	limit.Major = 8
+	limit.Minor = 16
+	limit.Rate = 1 << 20
+	// 8:17 is a partition of 8:16, and 0:50 an overlay on it.
+	disks := map[uint64]uint64{
+		unix.Mkdev(8, 17): unix.Mkdev(8, 16),
+		unix.Mkdev(0, 50): unix.Mkdev(8, 16),
+	}
+	th := NewThrottle(&specs.LinuxBlockIO{ThrottleWriteBpsDevice: []specs.LinuxThrottleDevice{limit}}, disks)
+	d := th.Device(unix.Mkdev(8, 16))
+	if d == nil {
+		t.Fatalf("throttle not enabled for device 8:16")
+	}
+	for _, dev := range []uint64{unix.Mkdev(8, 17), unix.Mkdev(0, 50)} {
+		if got := th.Device(dev); got != d {
+			t.Errorf("Device(%s) = %p, want the limits of 8:16 %p", formatDev(dev), got, d)
+		}
+	}
+	if th.Device(unix.Mkdev(8, 0)) != nil {
+		t.Errorf("throttle enabled for device 8:0")
+	}
+	if d.writeBytes == nil || d.readBytes != nil || d.readOps != nil || d.writeOps != nil {
+		t.Errorf("wrong limits for device 8:16: %+v", d)
+	}
+
+	// Reads aren't limited, and nil throttles don't limit anything.
+	d.Read(1 << 30)
+	var nilThrottle *Throttle
+	nilThrottle.Device(unix.Mkdev(8, 16)).Write(1 << 30)
+}
+
+func TestParseMountInfo(t *testing.T) {
+	const contents = `22 1 8:2 / / rw,relatime shared:1 - ext4 /dev/sda2 rw
+40 22 0:45 / /var/lib/docker/overlay2/x/merged rw,relatime - overlay overlay rw,lowerdir=/l1:/l2,upperdir=/var/lib/docker/overlay2/x/diff,workdir=/w
+41 22 0:46 / /mnt/with\040space rw - btrfs /dev/sdb1 rw
+`
+	mounts, err := parseMountInfo(strings.NewReader(contents))
+	if err != nil {
+		t.Fatalf("parseMountInfo() failed: %v", err)
+	}
+	want := []mountInfo{
+		{dev: unix.Mkdev(8, 2), fstype: "ext4", source: "/dev/sda2", opts: []string{"rw"}},
+		{dev: unix.Mkdev(0, 45), fstype: "overlay", source: "overlay", opts: []string{"rw", "lowerdir=/l1:/l2", "upperdir=/var/lib/docker/overlay2/x/diff", "workdir=/w"}},
+		{dev: unix.Mkdev(0, 46), fstype: "btrfs", source: "/dev/sdb1", opts: []string{"rw"}},
+	}
+	if !reflect.DeepEqual(mounts, want) {
+		t.Errorf("parseMountInfo() = %+v, want %+v", mounts, want)
+	}
+
+	if _, err := parseMountInfo(strings.NewReader("22 1 8:2 / / rw\n")); err == nil {
+		t.Errorf("parseMountInfo() succeeded on a line without separator")
+	}
+}
+
+func TestBlockDisk(t *testing.T) {
+	// Build a sysfs tree where 8:1 is a partition of 8:0.
+	sys := t.TempDir()
+	sda := filepath.Join(sys, "devices", "sda")
+	sda1 := filepath.Join(sda, "sda1")
+	if err := os.MkdirAll(sda1, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for path, data := range map[string]string{
+		filepath.Join(sda, "dev"):        "8:0\n",
+		filepath.Join(sda1, "dev"):       "8:1\n",
+		filepath.Join(sda1, "partition"): "1\n",
+	} {
+		if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	block := filepath.Join(sys, "dev", "block")
+	if err := os.MkdirAll(block, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, target := range map[string]string{"8:0": sda, "8:1": sda1} {
+		if err := os.Symlink(target, filepath.Join(block, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func(old string) { sysDevBlock = old }(sysDevBlock)
+	sysDevBlock = block
+
+	for _, tc := range []struct {
+		dev  uint64
+		disk uint64
+		ok   bool
+	}{
+		{dev: unix.Mkdev(8, 0), disk: unix.Mkdev(8, 0), ok: true},
+		{dev: unix.Mkdev(8, 1), disk: unix.Mkdev(8, 0), ok: true},
+		{dev: unix.Mkdev(8, 2)},
+	} {
+		disk, ok := blockDisk(tc.dev)
+		if disk != tc.disk || ok != tc.ok {
+			t.Errorf("blockDisk(%s) = %s, %t, want %s, %t", formatDev(tc.dev), formatDev(disk), ok, formatDev(tc.disk), tc.ok)
+		}
+	}
+}
+
+func TestFormatDisks(t *testing.T) {
+	disks := map[uint64]uint64{
+		unix.Mkdev(8, 1):  unix.Mkdev(8, 0),
+		unix.Mkdev(0, 45): unix.Mkdev(259, 0),
+	}
+	got, err := ParseDisks(FormatDisks(disks))
+	if err != nil {
+		t.Fatalf("ParseDisks(%q) failed: %v", FormatDisks(disks), err)
+	}
+	if !reflect.DeepEqual(got, disks) {
+		t.Errorf("ParseDisks(FormatDisks(%v)) = %v", disks, got)
+	}
+	if got, err := ParseDisks(""); err != nil || len(got) != 0 {
+		t.Errorf("ParseDisks(\"\") = %v, %v, want empty", got, err)
+	}
+	for _, s := range []string{"8:1", "8:1=8", "a:1=8:0"} {
+		if _, err := ParseDisks(s); err == nil {
+			t.Errorf("ParseDisks(%q) succeeded", s)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sandbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"gvisor.dev/gvisor/pkg/control/client"
+	"gvisor.dev/gvisor/pkg/control/server"
+	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/pkg/urpc"
+	"gvisor.dev/gvisor/runsc/boot"
+	"gvisor.dev/gvisor/runsc/config"
+)
+
+// createControlCreds generates the tokens that authenticate the clients of the
+// control server when it has a policy. Each principal's token is written to a
+// file in the token directory that only the principal can read, named after
+// it, e.g. "uid=1000". It returns a file with all tokens for the sandbox
+// process.
+//
+// Root can read all tokens, and the owner of the sandbox can read its own
+// token, so the policy can only restrict them as long as they use runsc.
+func (s *Sandbox) createControlCreds(conf *config.Config) (*os.File, error) {
+	p, err := server.ParsePolicy(conf.ControlPolicy)
+	if err != nil {
+		return nil, fmt.Errorf("parsing control policy: %v", err)
+	}
+	owner := os.Getuid()
+	creds, err := server.NewCredentials(p, uint32(owner))
+	if err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Join(conf.RootDir, s.ID+".control")
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, fmt.Errorf("removing control token directory: %v", err)
+	}
+	if err := os.Mkdir(dir, 0711); err != nil {
+		return nil, fmt.Errorf("creating control token directory: %v", err)
+	}
+	s.ControlTokenDir = dir
+
+	for token, pr := range creds.Tokens {
+		uid, gid, mode := int(pr.ID), -1, os.FileMode(0400)
+		if pr.Group {
+			uid, gid, mode = owner, int(pr.ID), 0040
+		}
+		path := filepath.Join(dir, pr.String())
+		if err := ioutil.WriteFile(path, []byte(token), 0400); err != nil {
+			return nil, fmt.Errorf("writing control token of %s: %v", pr, err)
+		}
+		if err := os.Chown(path, uid, gid); err != nil {
+			return nil, fmt.Errorf("granting %s access to the control server: %v", pr, err)
+		}
+		if err := os.Chmod(path, mode); err != nil {
+			return nil, fmt.Errorf("granting %s access to the control server: %v", pr, err)
+		}
+	}
+
+	// The sandbox process can't open files by path, pass the tokens in an
+	// unlinked file.
+	f, err := ioutil.TempFile(dir, "creds")
+	if err != nil {
+		return nil, fmt.Errorf("creating control credentials file: %v", err)
+	}
+	if err := os.Remove(f.Name()); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("removing control credentials file: %v", err)
+	}
+	if err := json.NewEncoder(f).Encode(creds); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("writing control credentials file: %v", err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("seeking control credentials file: %v", err)
+	}
+	return f, nil
+}
+
+// controlToken returns the token that this process authenticates to the
+// control server with: the token of its user if it can read it, or else the
+// token of one of its groups.
+func (s *Sandbox) controlToken() (string, error) {
+	prs := []server.Principal{
+		{ID: uint32(os.Getuid())},
+		{Group: true, ID: uint32(os.Getegid())},
+	}
+	groups, err := os.Getgroups()
+	if err != nil {
+		return "", fmt.Errorf("getting groups: %v", err)
+	}
+	for _, g := range groups {
+		prs = append(prs, server.Principal{Group: true, ID: uint32(g)})
+	}
+	for _, pr := range prs {
+		token, err := ioutil.ReadFile(filepath.Join(s.ControlTokenDir, pr.String()))
+		if err == nil {
+			return string(token), nil
+		}
+		if !os.IsNotExist(err) && !os.IsPermission(err) {
+			return "", fmt.Errorf("reading control token of %s: %v", pr, err)
+		}
+	}
+	return "", fmt.Errorf("the control policy doesn't grant this user or its groups access to sandbox %q", s.ID)
+}
+
+// connectWithToken connects to the control server of a sandbox that has a
+// control policy.
+func (s *Sandbox) connectWithToken() (*urpc.Client, error) {
+	token, err := s.controlToken()
+	if err != nil {
+		return nil, err
+	}
+	return client.ConnectWithToken(boot.ControlSocketAddr(s.ID), token)
+}
+
+// removeControlTokenDir removes the control tokens of the sandbox.
+func (s *Sandbox) removeControlTokenDir() {
+	if s.ControlTokenDir == "" {
+		return
+	}
+	if err := os.RemoveAll(s.ControlTokenDir); err != nil {
+		log.Warningf("Failed to remove control token directory %q: %v", s.ControlTokenDir, err)
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sandbox
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/runsc/config"
+)
+
+const (
+	// crashLogRing is the file, in the crash directory, of the ring of the
+	// most recent log records of the sandbox process.
+	crashLogRing = "log.ring"
+
+	// crashPanicLog is the file, in the crash directory, where Go's runtime
+	// messages are written if --panic-log isn't set.
+	crashPanicLog = "panic.log"
+
+	// crashConfig is the file, in the crash directory, with the flags of
+	// the sandbox.
+	crashConfig = "config"
+
+	// crashLogRingSize is the size of the ring of log records.
+	crashLogRingSize = 1 << 20
+)
+
+// crashFile is a file of a crash report.
+type crashFile struct {
+	name string
+	data []byte
+}
+
+// panicRE matches Go's runtime messages when the process panics.
+var panicRE = regexp.MustCompile(`(?m)^(panic|fatal error): `)
+
+// createCrashDir creates the directory where the sandbox process keeps what's
+// needed for a crash report, see CrashReport.
+func (s *Sandbox) createCrashDir(conf *config.Config) error {
+	dir := filepath.Join(conf.CrashReportDir, fmt.Sprintf("%s-%s", s.ID, time.Now().Format("20060102-150405.000000")))
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return fmt.Errorf("creating crash directory: %v", err)
+	}
+	flags := strings.Join(conf.ToFlags(), "\n") + "\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, crashConfig), []byte(flags), 0640); err != nil {
+		os.RemoveAll(dir)
+		return fmt.Errorf("writing crash configuration: %v", err)
+	}
+	s.CrashDir = dir
+	return nil
+}
+
+// openCrashPanicLog opens the file in the crash directory where Go's runtime
+// messages are written.
+func (s *Sandbox) openCrashPanicLog() (*os.File, error) {
+	f, err := os.OpenFile(filepath.Join(s.CrashDir, crashPanicLog), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0640)
+	if err != nil {
+		return nil, fmt.Errorf("opening crash panic log: %v", err)
+	}
+	return f, nil
+}
+
+// openCrashLogRing opens the file in the crash directory that the sandbox
+// process maps to keep its most recent log records, which outlive it.
+func (s *Sandbox) openCrashLogRing() (*os.File, error) {
+	f, err := os.OpenFile(filepath.Join(s.CrashDir, crashLogRing), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0640)
+	if err != nil {
+		return nil, fmt.Errorf("opening crash log ring: %v", err)
+	}
+	if err := f.Truncate(crashLogRingSize); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("sizing crash log ring: %v", err)
+	}
+	return f, nil
+}
+
+// CrashReport returns the path of the crash report of the sandbox, writing it
+// if the sandbox process panicked and it wasn't written yet. It returns an
+// empty path if crash reports are disabled, or if the sandbox didn't panic.
+//
+// The crash report is a gzipped tarball with Go's runtime messages, which hold
+// the panic message and goroutine stacks, the most recent log records, and the
+// flags of the sandbox.
+func (s *Sandbox) CrashReport() (string, error) {
+	if s.CrashDir == "" {
+		return "", nil
+	}
+	report := s.CrashDir + ".tar.gz"
+	if _, err := os.Stat(report); err == nil {
+		return report, nil
+	}
+	if s.IsRunning() {
+		return "", nil
+	}
+	panicLog, err := ioutil.ReadFile(s.PanicLog)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// Already cleaned up.
+			return "", nil
+		}
+		return "", fmt.Errorf("reading panic log: %v", err)
+	}
+	if !panicRE.Match(panicLog) {
+		return "", nil
+	}
+
+	files := []crashFile{{name: "panic.log", data: panicLog}}
+	if ring, err := ioutil.ReadFile(filepath.Join(s.CrashDir, crashLogRing)); err == nil {
+		files = append(files, crashFile{name: "log.txt", data: log.ReadRing(ring)})
+	} else {
+		log.Warningf("Skipping log records in crash report: %v", err)
+	}
+	if flags, err := ioutil.ReadFile(filepath.Join(s.CrashDir, crashConfig)); err == nil {
+		files = append(files, crashFile{name: "config", data: flags})
+	} else {
+		log.Warningf("Skipping configuration in crash report: %v", err)
+	}
+
+	// Write to a temporary file first, such that the report is complete
+	// once it exists.
+	tmp, err := ioutil.TempFile(filepath.Dir(report), filepath.Base(report)+".*")
+	if err != nil {
+		return "", fmt.Errorf("creating crash report: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	gz := gzip.NewWriter(tmp)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+	for _, f := range files {
+		hdr := &tar.Header{
+			Name:    f.name,
+			Mode:    0640,
+			Size:    int64(len(f.data)),
+			ModTime: now,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			tmp.Close()
+			return "", fmt.Errorf("writing crash report: %v", err)
+		}
+		if _, err := tw.Write(f.data); err != nil {
+			tmp.Close()
+			return "", fmt.Errorf("writing crash report: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("writing crash report: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("writing crash report: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("writing crash report: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), report); err != nil {
+		return "", fmt.Errorf("writing crash report: %v", err)
+	}
+	log.Warningf("Sandbox %q panicked, crash report written to %q", s.ID, report)
+	return report, nil
+}
+
+// removeCrashDir writes the crash report of the stopped sandbox, if it
+// panicked, and removes the crash directory.
+func (s *Sandbox) removeCrashDir() {
+	if s.CrashDir == "" {
+		return
+	}
+	if _, err := s.CrashReport(); err != nil {
+		log.Warningf("Error writing crash report of sandbox %q: %v", s.ID, err)
+	}
+	if err := os.RemoveAll(s.CrashDir); err != nil {
+		log.Warningf("Error removing crash directory %q: %v", s.CrashDir, err)
+	}
+}
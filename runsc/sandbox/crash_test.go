@@ -0,0 +1,147 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sandbox
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/runsc/config"
+)
+
+// readCrashReport returns the files in the crash report at path.
+func readCrashReport(t *testing.T, path string) map[string]string {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("opening crash report: %v", err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("reading crash report: %v", err)
+	}
+	files := make(map[string]string)
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		if err != nil {
+			t.Fatalf("reading crash report: %v", err)
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			t.Fatalf("reading crash report: %v", err)
+		}
+		files[hdr.Name] = string(data)
+	}
+}
+
+func TestCrashReport(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		panicLog string
+		want     bool
+	}{
+		{
+			name:     "panic",
+			panicLog: "panic: runtime error: invalid memory address\n\ngoroutine 1 [running]:\n",
+			want:     true,
+		},
+		{
+			name:     "fatal error",
+			panicLog: "fatal error: concurrent map writes\n",
+			want:     true,
+		},
+		{
+			name:     "no crash",
+			panicLog: "",
+			want:     false,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			config.RegisterFlags()
+			conf, err := config.NewFromFlags()
+			if err != nil {
+				t.Fatalf("NewFromFlags(): %v", err)
+			}
+			conf.CrashReportDir = t.TempDir()
+			s := &Sandbox{ID: "sandbox"}
+			if err := s.createCrashDir(conf); err != nil {
+				t.Fatalf("createCrashDir(): %v", err)
+			}
+
+			panicLog, err := s.openCrashPanicLog()
+			if err != nil {
+				t.Fatalf("openCrashPanicLog(): %v", err)
+			}
+			s.PanicLog = panicLog.Name()
+			if _, err := panicLog.WriteString(tc.panicLog); err != nil {
+				t.Fatalf("writing panic log: %v", err)
+			}
+			panicLog.Close()
+
+			ringFile, err := s.openCrashLogRing()
+			if err != nil {
+				t.Fatalf("openCrashLogRing(): %v", err)
+			}
+			buf := make([]byte, crashLogRingSize)
+			ring := log.NewRing(buf)
+			ring.Write([]byte("last record\n"))
+			if _, err := ringFile.WriteAt(buf, 0); err != nil {
+				t.Fatalf("writing log ring: %v", err)
+			}
+			ringFile.Close()
+
+			s.removeCrashDir()
+			if _, err := os.Stat(s.CrashDir); !os.IsNotExist(err) {
+				t.Errorf("crash directory not removed: %v", err)
+			}
+			report, err := s.CrashReport()
+			if err != nil {
+				t.Fatalf("CrashReport(): %v", err)
+			}
+			if !tc.want {
+				if report != "" {
+					t.Fatalf("CrashReport() = %q, want no report", report)
+				}
+				return
+			}
+			if want := s.CrashDir + ".tar.gz"; report != want {
+				t.Fatalf("CrashReport() = %q, want %q", report, want)
+			}
+			if dir := filepath.Dir(report); dir != conf.CrashReportDir {
+				t.Errorf("crash report in %q, want %q", dir, conf.CrashReportDir)
+			}
+			files := readCrashReport(t, report)
+			if got := files["panic.log"]; got != tc.panicLog {
+				t.Errorf("panic.log = %q, want %q", got, tc.panicLog)
+			}
+			if got, want := files["log.txt"], "last record\n"; got != want {
+				t.Errorf("log.txt = %q, want %q", got, want)
+			}
+			if files["config"] == "" {
+				t.Errorf("config missing from crash report")
+			}
+		})
+	}
+}
@@ -15,20 +15,26 @@
 package sandbox
 
 import (
+	"crypto/rand"
+	"encoding/binary"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strconv"
+	"strings"
 
 	specs "github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/vishvananda/netlink"
 	"golang.org/x/sys/unix"
 	"gvisor.dev/gvisor/pkg/log"
 	"gvisor.dev/gvisor/pkg/tcpip/header"
+	"gvisor.dev/gvisor/pkg/tcpip/link/sharedmem/bridge"
 	"gvisor.dev/gvisor/pkg/tcpip/stack"
 	"gvisor.dev/gvisor/pkg/urpc"
+	"gvisor.dev/gvisor/pkg/xdp"
 	"gvisor.dev/gvisor/runsc/boot"
 	"gvisor.dev/gvisor/runsc/config"
 	"gvisor.dev/gvisor/runsc/specutils"
@@ -45,43 +51,207 @@ import (
 // device.
 //
 // If 'conf.Network' is NoNetwork, skips local configuration and creates a
-// loopback interface only.
+// loopback interface only, along with the shared memory bridge interface if
+// 'conf.SharedMemBridge' is set.
+//
+// It returns the configuration of the interfaces moved from the host to the
+// sandbox, see RestoreHostNetwork.
 //
 // Run the following container to test it:
 //  docker run -di --runtime=runsc -p 8080:80 -v $PWD:/usr/local/apache2/htdocs/ httpd:2.4
-func setupNetwork(conn *urpc.Client, pid int, conf *config.Config) error {
+func setupNetwork(conn *urpc.Client, pid int, conf *config.Config) ([]HostLink, error) {
 	log.Infof("Setting up network")
 
 	switch conf.Network {
 	case config.NetworkNone:
 		log.Infof("Network is disabled, create loopback interface only")
-		if err := createDefaultLoopbackInterface(conn); err != nil {
-			return fmt.Errorf("creating default loopback interface: %v", err)
+		if err := createDefaultLoopbackInterface(conn, conf.SharedMemBridge); err != nil {
+			return nil, fmt.Errorf("creating default loopback interface: %v", err)
 		}
 	case config.NetworkSandbox:
 		// Build the path to the net namespace of the sandbox process.
 		// This is what we will copy.
 		nsPath := filepath.Join("/proc", strconv.Itoa(pid), "ns/net")
-		if err := createInterfacesAndRoutesFromNS(conn, nsPath, conf.HardwareGSO, conf.SoftwareGSO, conf.TXChecksumOffload, conf.RXChecksumOffload, conf.NumNetworkChannels, conf.QDisc); err != nil {
-			return fmt.Errorf("creating interfaces from net namespace %q: %v", nsPath, err)
+		var dnsServers []net.UDPAddr
+		if conf.DNSCache != "" {
+			var err error
+			if dnsServers, err = config.ParseDNSServers(conf.DNSCache); err != nil {
+				return nil, fmt.Errorf("parsing DNS servers %q: %v", conf.DNSCache, err)
+			}
+		}
+		if conf.XDP {
+			// BPF objects created here and the UMEMs registered by the
+			// sandbox count as locked memory.
+			unlimited := unix.Rlimit{Cur: unix.RLIM_INFINITY, Max: unix.RLIM_INFINITY}
+			for _, p := range []int{0, pid} {
+				if err := unix.Prlimit(p, unix.RLIMIT_MEMLOCK, &unlimited, nil); err != nil {
+					return nil, fmt.Errorf("raising RLIMIT_MEMLOCK of PID %d: %v", p, err)
+				}
+			}
 		}
+		links, err := createInterfacesAndRoutesFromNS(conn, nsPath, conf.HardwareGSO, conf.SoftwareGSO, conf.TXChecksumOffload, conf.RXChecksumOffload, conf.NumNetworkChannels, conf.QDisc, conf.XDP, dnsServers, conf.SharedMemBridge)
+		if err != nil {
+			return nil, fmt.Errorf("creating interfaces from net namespace %q: %v", nsPath, err)
+		}
+		return links, nil
 	case config.NetworkHost:
 		// Nothing to do here.
 	default:
-		return fmt.Errorf("invalid network type: %v", conf.Network)
+		return nil, fmt.Errorf("invalid network type: %v", conf.Network)
+	}
+	return nil, nil
+}
+
+// HostLink is the configuration of a network interface of the host, which was
+// moved to the sandbox when its network was set up.
+type HostLink struct {
+	// Name is the name of the interface.
+	Name string `json:"name"`
+
+	// Addresses are the addresses removed from the interface.
+	Addresses []boot.IPWithPrefix `json:"addresses"`
+
+	// Routes are the routes through the interface, including the default
+	// routes, which were removed along with the addresses.
+	Routes []boot.Route `json:"routes,omitempty"`
+
+	// IPv6Disabled is true if IPv6 was disabled on the interface.
+	IPv6Disabled bool `json:"ipv6Disabled,omitempty"`
+}
+
+func newHostLink(name string, addrs []boot.IPWithPrefix, routes []boot.Route, defv4, defv6 *boot.Route) HostLink {
+	link := HostLink{
+		Name:      name,
+		Addresses: addrs,
+		Routes:    append([]boot.Route(nil), routes...),
+	}
+	for _, def := range []*boot.Route{defv4, defv6} {
+		if def != nil {
+			link.Routes = append(link.Routes, *def)
+		}
+	}
+	for _, addr := range addrs {
+		if addr.Address.To4() == nil {
+			// See stealAddresses.
+			link.IPv6Disabled = true
+		}
+	}
+	return link
+}
+
+// restoreHostLinks adds the addresses and routes of links back to the
+// interfaces of the network namespace at nsPath, and re-enables IPv6 where it
+// was disabled. Addresses and routes that already exist are left alone.
+func restoreHostLinks(nsPath string, links []HostLink) error {
+	restore, err := joinNetNS(nsPath)
+	if err != nil {
+		return err
+	}
+	defer restore()
+
+	for _, l := range links {
+		link, err := netlink.LinkByName(l.Name)
+		if err != nil {
+			return fmt.Errorf("getting link for interface %q: %w", l.Name, err)
+		}
+		if l.IPv6Disabled {
+			path := filepath.Join("/proc/sys/net/ipv6/conf", l.Name, "disable_ipv6")
+			if err := ioutil.WriteFile(path, []byte("0"), 0); err != nil {
+				return fmt.Errorf("enabling IPv6 on device %q: %w", l.Name, err)
+			}
+		}
+		for _, addr := range l.Addresses {
+			bits := 8 * net.IPv6len
+			if addr.Address.To4() != nil {
+				bits = 8 * net.IPv4len
+			}
+			nlAddr := &netlink.Addr{IPNet: &net.IPNet{IP: addr.Address, Mask: net.CIDRMask(addr.PrefixLen, bits)}}
+			if err := netlink.AddrAdd(link, nlAddr); err != nil && err != unix.EEXIST {
+				return fmt.Errorf("adding address %v to device %q: %w", addr, l.Name, err)
+			}
+		}
+		for _, r := range l.Routes {
+			dst := r.Destination
+			route := &netlink.Route{
+				LinkIndex: link.Attrs().Index,
+				Dst:       &dst,
+				Gw:        r.Gateway,
+			}
+			if err := netlink.RouteReplace(route); err != nil {
+				return fmt.Errorf("adding route %v to device %q: %w", r, l.Name, err)
+			}
+		}
 	}
 	return nil
 }
 
-func createDefaultLoopbackInterface(conn *urpc.Client) error {
-	if err := conn.Call(boot.NetworkCreateLinksAndRoutes, &boot.CreateLinksAndRoutesArgs{
+// createDefaultLoopbackInterface creates the default loopback interface in the
+// sandbox and, if sharedMemBridge is set, an interface connected to the shared
+// memory bridge.
+func createDefaultLoopbackInterface(conn *urpc.Client, sharedMemBridge string) error {
+	args := boot.CreateLinksAndRoutesArgs{
 		LoopbackLinks: []boot.LoopbackLink{boot.DefaultLoopbackLink},
-	}, nil); err != nil {
+	}
+	if sharedMemBridge != "" {
+		if err := addSharedMemBridgeLink(&args, sharedMemBridge); err != nil {
+			return err
+		}
+		defer closeFiles(args.FilePayload.Files)
+	}
+	if err := conn.Call(boot.NetworkCreateLinksAndRoutes, &args, nil); err != nil {
 		return fmt.Errorf("creating loopback link and routes: %v", err)
 	}
 	return nil
 }
 
+// addSharedMemBridgeLink connects to the shared memory bridge configured with
+// the shared-mem-bridge flag, and adds an interface connected to it to args.
+// Its fds are appended to args.FilePayload.
+func addSharedMemBridgeLink(args *boot.CreateLinksAndRoutesArgs, sharedMemBridge string) error {
+	path, addr, err := config.ParseSharedMemBridge(sharedMemBridge)
+	if err != nil {
+		return err
+	}
+	port, err := bridge.Dial(path)
+	if err != nil {
+		return fmt.Errorf("connecting to shared memory bridge %q: %w", path, err)
+	}
+	for _, fd := range port.FDs {
+		args.FilePayload.Files = append(args.FilePayload.Files, os.NewFile(uintptr(fd), "shared memory bridge"))
+	}
+
+	// Use a random locally administered address, like veth devices do.
+	mac := make(net.HardwareAddr, header.EthernetAddressSize)
+	if _, err := rand.Read(mac); err != nil {
+		return err
+	}
+	mac[0] = (mac[0] &^ 0x01) | 0x02
+
+	prefixLen, _ := addr.Mask.Size()
+	link := boot.SharedMemLink{
+		Name:       fmt.Sprintf("shm%d", len(args.SharedMemLinks)),
+		MTU:        int(port.MTU),
+		BufferSize: int(port.BufferSize),
+		Addresses: []boot.IPWithPrefix{
+			{Address: addr.IP, PrefixLen: prefixLen},
+		},
+		Routes: []boot.Route{
+			{Destination: net.IPNet{IP: addr.IP.Mask(addr.Mask), Mask: addr.Mask}},
+		},
+		LinkAddress: mac,
+	}
+	log.Infof("Connected to shared memory bridge %q: %+v", path, link)
+	args.SharedMemLinks = append(args.SharedMemLinks, link)
+	return nil
+}
+
+// closeFiles closes all files.
+func closeFiles(files []*os.File) {
+	for _, f := range files {
+		f.Close()
+	}
+}
+
 func joinNetNS(nsPath string) (func(), error) {
 	runtime.LockOSThread()
 	restoreNS, err := specutils.ApplyNS(specs.LinuxNamespace{
@@ -114,69 +284,82 @@ func isRootNS() (bool, error) {
 
 // createInterfacesAndRoutesFromNS scrapes the interface and routes from the
 // net namespace with the given path, creates them in the sandbox, and removes
-// them from the host.
-func createInterfacesAndRoutesFromNS(conn *urpc.Client, nsPath string, hardwareGSO bool, softwareGSO bool, txChecksumOffload bool, rxChecksumOffload bool, numNetworkChannels int, qDisc config.QueueingDiscipline) error {
+// them from the host. If dnsServers isn't empty, a caching DNS resolver
+// forwarding queries to them is started in the sandbox. If sharedMemBridge
+// isn't empty, an interface connected to the shared memory bridge is also
+// created.
+func createInterfacesAndRoutesFromNS(conn *urpc.Client, nsPath string, hardwareGSO bool, softwareGSO bool, txChecksumOffload bool, rxChecksumOffload bool, numNetworkChannels int, qDisc config.QueueingDiscipline, useXDP bool, dnsServers []net.UDPAddr, sharedMemBridge string) ([]HostLink, error) {
 	// Join the network namespace that we will be copying.
 	restore, err := joinNetNS(nsPath)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer restore()
 
 	// Get all interfaces in the namespace.
 	ifaces, err := net.Interfaces()
 	if err != nil {
-		return fmt.Errorf("querying interfaces: %w", err)
+		return nil, fmt.Errorf("querying interfaces: %w", err)
 	}
 
 	isRoot, err := isRootNS()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if isRoot {
-		return fmt.Errorf("cannot run with network enabled in root network namespace")
+		return nil, fmt.Errorf("cannot run with network enabled in root network namespace")
 	}
 
 	// Collect addresses and routes from the interfaces.
 	var args boot.CreateLinksAndRoutesArgs
+	var hostLinks []HostLink
+	// xdpSockets are the AF_XDP sockets of args.XDPLinks, and the XSKMAPs of
+	// the XDP programs redirecting packets to them.
+	var xdpSockets []xdpSocket
+	defer func() {
+		for _, s := range xdpSockets {
+			unix.Close(s.xskMap)
+		}
+	}()
 	for _, iface := range ifaces {
 		if iface.Flags&net.FlagUp == 0 {
 			log.Infof("Skipping down interface: %+v", iface)
 			continue
 		}
 
-		allAddrs, err := iface.Addrs()
-		if err != nil {
-			return fmt.Errorf("fetching interface addresses for %q: %w", iface.Name, err)
-		}
-
 		// We build our own loopback device.
 		if iface.Flags&net.FlagLoopback != 0 {
+			allAddrs, err := iface.Addrs()
+			if err != nil {
+				return nil, fmt.Errorf("fetching interface addresses for %q: %w", iface.Name, err)
+			}
 			link, err := loopbackLink(iface, allAddrs)
 			if err != nil {
-				return fmt.Errorf("getting loopback link for iface %q: %w", iface.Name, err)
+				return nil, fmt.Errorf("getting loopback link for iface %q: %w", iface.Name, err)
 			}
 			args.LoopbackLinks = append(args.LoopbackLinks, link)
 			continue
 		}
 
-		var ipAddrs []*net.IPNet
-		for _, ifaddr := range allAddrs {
-			ipNet, ok := ifaddr.(*net.IPNet)
-			if !ok {
-				return fmt.Errorf("address is not IPNet: %+v", ifaddr)
-			}
-			ipAddrs = append(ipAddrs, ipNet)
+		// Get the link for the interface.
+		ifaceLink, err := netlink.LinkByName(iface.Name)
+		if err != nil {
+			return nil, fmt.Errorf("getting link for interface %q: %w", iface.Name, err)
+		}
+
+		ipAddrs, err := addressesForLink(ifaceLink)
+		if err != nil {
+			return nil, fmt.Errorf("fetching interface addresses for %q: %w", iface.Name, err)
 		}
 		if len(ipAddrs) == 0 {
 			log.Warningf("No usable IP addresses found for interface %q, skipping", iface.Name)
 			continue
 		}
 
-		// Collect data from the ARP table.
-		dump, err := netlink.NeighList(iface.Index, 0)
+		// Collect data from the ARP and NDP tables.
+		dump, err := netlink.NeighList(iface.Index, netlink.FAMILY_ALL)
 		if err != nil {
-			return fmt.Errorf("fetching ARP table for %q: %w", iface.Name, err)
+			return nil, fmt.Errorf("fetching neighbor table for %q: %w", iface.Name, err)
 		}
 
 		var neighbors []boot.Neighbor
@@ -184,7 +367,7 @@ func createInterfacesAndRoutesFromNS(conn *urpc.Client, nsPath string, hardwareG
 			// There are only two "good" states NUD_PERMANENT and NUD_REACHABLE,
 			// but NUD_REACHABLE is fully dynamic and will be re-probed anyway.
 			if n.State == netlink.NUD_PERMANENT {
-				log.Debugf("Copying a static ARP entry: %+v %+v", n.IP, n.HardwareAddr)
+				log.Debugf("Copying a static neighbor entry: %+v %+v", n.IP, n.HardwareAddr)
 				// No flags are copied because Stack.AddStaticNeighbor does not support flags right now.
 				neighbors = append(neighbors, boot.Neighbor{IP: n.IP, HardwareAddr: n.HardwareAddr})
 			}
@@ -194,11 +377,11 @@ func createInterfacesAndRoutesFromNS(conn *urpc.Client, nsPath string, hardwareG
 		// will remove the routes as well.
 		routes, defv4, defv6, err := routesForIface(iface)
 		if err != nil {
-			return fmt.Errorf("getting routes for interface %q: %v", iface.Name, err)
+			return nil, fmt.Errorf("getting routes for interface %q: %v", iface.Name, err)
 		}
 		if defv4 != nil {
 			if !args.Defaultv4Gateway.Route.Empty() {
-				return fmt.Errorf("more than one default route found, interface: %v, route: %v, default route: %+v", iface.Name, defv4, args.Defaultv4Gateway)
+				return nil, fmt.Errorf("more than one default route found, interface: %v, route: %v, default route: %+v", iface.Name, defv4, args.Defaultv4Gateway)
 			}
 			args.Defaultv4Gateway.Route = *defv4
 			args.Defaultv4Gateway.Name = iface.Name
@@ -206,12 +389,37 @@ func createInterfacesAndRoutesFromNS(conn *urpc.Client, nsPath string, hardwareG
 
 		if defv6 != nil {
 			if !args.Defaultv6Gateway.Route.Empty() {
-				return fmt.Errorf("more than one default route found, interface: %v, route: %v, default route: %+v", iface.Name, defv6, args.Defaultv6Gateway)
+				return nil, fmt.Errorf("more than one default route found, interface: %v, route: %v, default route: %+v", iface.Name, defv6, args.Defaultv6Gateway)
 			}
 			args.Defaultv6Gateway.Route = *defv6
 			args.Defaultv6Gateway.Name = iface.Name
 		}
 
+		if useXDP {
+			sockFile, mapFD, err := createXDPSocket(ifaceLink)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create AF_XDP socket for %s : %w", iface.Name, err)
+			}
+			args.FilePayload.Files = append(args.FilePayload.Files, sockFile)
+			xdpSockets = append(xdpSockets, xdpSocket{file: sockFile, xskMap: mapFD})
+
+			link := boot.XDPLink{
+				Name:           iface.Name,
+				InterfaceIndex: iface.Index,
+				MTU:            iface.MTU,
+				Routes:         routes,
+				LinkAddress:    ifaceLink.Attrs().HardwareAddr,
+				QDisc:          qDisc,
+				Neighbors:      neighbors,
+			}
+			if link.Addresses, err = stealAddresses(iface, ifaceLink, ipAddrs); err != nil {
+				return nil, err
+			}
+			hostLinks = append(hostLinks, newHostLink(iface.Name, link.Addresses, routes, defv4, defv6))
+			args.XDPLinks = append(args.XDPLinks, link)
+			continue
+		}
+
 		link := boot.FDBasedLink{
 			Name:              iface.Name,
 			MTU:               iface.MTU,
@@ -223,11 +431,6 @@ func createInterfacesAndRoutesFromNS(conn *urpc.Client, nsPath string, hardwareG
 			Neighbors:         neighbors,
 		}
 
-		// Get the link for the interface.
-		ifaceLink, err := netlink.LinkByName(iface.Name)
-		if err != nil {
-			return fmt.Errorf("getting link for interface %q: %w", iface.Name, err)
-		}
 		link.LinkAddress = ifaceLink.Attrs().HardwareAddr
 
 		log.Debugf("Setting up network channels")
@@ -236,13 +439,13 @@ func createInterfacesAndRoutesFromNS(conn *urpc.Client, nsPath string, hardwareG
 			log.Debugf("Creating Channel %d", i)
 			socketEntry, err := createSocket(iface, ifaceLink, hardwareGSO)
 			if err != nil {
-				return fmt.Errorf("failed to createSocket for %s : %w", iface.Name, err)
+				return nil, fmt.Errorf("failed to createSocket for %s : %w", iface.Name, err)
 			}
 			if i == 0 {
 				link.GSOMaxSize = socketEntry.gsoMaxSize
 			} else {
 				if link.GSOMaxSize != socketEntry.gsoMaxSize {
-					return fmt.Errorf("inconsistent gsoMaxSize %d and %d when creating multiple channels for same interface: %s",
+					return nil, fmt.Errorf("inconsistent gsoMaxSize %d and %d when creating multiple channels for same interface: %s",
 						link.GSOMaxSize, socketEntry.gsoMaxSize, iface.Name)
 				}
 			}
@@ -255,26 +458,116 @@ func createInterfacesAndRoutesFromNS(conn *urpc.Client, nsPath string, hardwareG
 			link.SoftwareGSOEnabled = true
 		}
 
-		// Collect the addresses for the interface, enable forwarding,
-		// and remove them from the host.
-		for _, addr := range ipAddrs {
-			prefix, _ := addr.Mask.Size()
-			link.Addresses = append(link.Addresses, boot.IPWithPrefix{Address: addr.IP, PrefixLen: prefix})
-
-			// Steal IP address from NIC.
-			if err := removeAddress(ifaceLink, addr.String()); err != nil {
-				return fmt.Errorf("removing address %v from device %q: %w", addr, iface.Name, err)
-			}
+		if link.Addresses, err = stealAddresses(iface, ifaceLink, ipAddrs); err != nil {
+			return nil, err
 		}
+		hostLinks = append(hostLinks, newHostLink(iface.Name, link.Addresses, routes, defv4, defv6))
 
 		args.FDBasedLinks = append(args.FDBasedLinks, link)
 	}
 
+	if sharedMemBridge != "" {
+		// The fds of the shared memory link follow those of the FD-based
+		// links.
+		if err := addSharedMemBridgeLink(&args, sharedMemBridge); err != nil {
+			return nil, err
+		}
+	}
+
+	// Docker's embedded DNS server listens on the loopback interface of the
+	// namespace, which netstack replaces with its own. Let the sandbox forward
+	// queries to it through a host socket.
+	if len(args.LoopbackLinks) > 0 {
+		dnsFile, err := dockerDNSSocket()
+		if err != nil {
+			return nil, fmt.Errorf("creating socket for Docker's embedded DNS server: %w", err)
+		}
+		if dnsFile != nil {
+			args.FilePayload.Files = append(args.FilePayload.Files, dnsFile)
+			args.DockerDNS = true
+		}
+	}
+	args.DNSCacheServers = dnsServers
+
 	log.Debugf("Setting up network, config: %+v", args)
 	if err := conn.Call(boot.NetworkCreateLinksAndRoutes, &args, nil); err != nil {
-		return fmt.Errorf("creating links and routes: %w", err)
+		return nil, fmt.Errorf("creating links and routes: %w", err)
 	}
-	return nil
+
+	// The AF_XDP sockets are bound now, start redirecting packets to them.
+	for i, s := range xdpSockets {
+		if err := xdp.UpdateXSKMap(s.xskMap, 0, int(s.file.Fd())); err != nil {
+			return nil, fmt.Errorf("inserting AF_XDP socket of interface %q in XSKMAP: %w", args.XDPLinks[i].Name, err)
+		}
+	}
+	return hostLinks, nil
+}
+
+// stealAddresses returns the addresses of the interface, and removes them from
+// the host.
+func stealAddresses(iface net.Interface, ifaceLink netlink.Link, ipAddrs []*net.IPNet) ([]boot.IPWithPrefix, error) {
+	var addrs []boot.IPWithPrefix
+	hasIPv6 := false
+	for _, addr := range ipAddrs {
+		prefix, _ := addr.Mask.Size()
+		addrs = append(addrs, boot.IPWithPrefix{Address: addr.IP, PrefixLen: prefix})
+		if addr.IP.To4() == nil {
+			hasIPv6 = true
+		}
+
+		// Steal IP address from NIC.
+		if err := removeAddress(ifaceLink, addr.String()); err != nil {
+			return nil, fmt.Errorf("removing address %v from device %q: %w", addr, iface.Name, err)
+		}
+	}
+
+	// The host doesn't own IPv6 addresses of the device anymore, but it
+	// would still configure new ones from router advertisements.
+	if hasIPv6 {
+		if err := disableIPv6(iface.Name); err != nil {
+			return nil, fmt.Errorf("disabling IPv6 on device %q: %w", iface.Name, err)
+		}
+	}
+	return addrs, nil
+}
+
+// dockerDNSSocket returns a UDP socket connected to Docker's embedded DNS
+// server, or nil if the server isn't running in the current network namespace.
+// The server is detected by looking for a UDP socket bound to its address,
+// which is then reached through the DNAT rule Docker installs for port 53.
+func dockerDNSSocket() (*os.File, error) {
+	// /proc/thread-self/net reflects the network namespace of the calling
+	// thread, which is locked to the container's namespace.
+	udp, err := ioutil.ReadFile("/proc/thread-self/net/udp")
+	if err != nil {
+		return nil, err
+	}
+	// Addresses are in hex, in host byte order, e.g. "0B00007F:A2B4".
+	addr := fmt.Sprintf("%08X:", binary.LittleEndian.Uint32(boot.DockerDNSAddr))
+	found := false
+	for _, line := range strings.Split(string(udp), "\n")[1:] {
+		fields := strings.Fields(line)
+		if len(fields) > 1 && strings.HasPrefix(fields[1], addr) {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, nil
+	}
+
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create UDP socket: %v", err)
+	}
+	sa := &unix.SockaddrInet4{Port: 53}
+	copy(sa.Addr[:], boot.DockerDNSAddr)
+	if err := unix.Connect(fd, sa); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("unable to connect to %v:53: %v", boot.DockerDNSAddr, err)
+	}
+	log.Infof("Docker's embedded DNS server found, forwarding queries to %v:53", boot.DockerDNSAddr)
+	return os.NewFile(uintptr(fd), "docker-dns-fd"), nil
 }
 
 type socketEntry struct {
@@ -344,6 +637,43 @@ func createSocket(iface net.Interface, ifaceLink netlink.Link, enableGSO bool) (
 	return &socketEntry{deviceFile, gsoMaxSize}, nil
 }
 
+type xdpSocket struct {
+	file   *os.File
+	xskMap int
+}
+
+// createXDPSocket creates an AF_XDP socket, and attaches an XDP program to the
+// device that redirects the packets received on its first queue to the
+// socket. The socket is bound by the sandbox, after which it must be inserted
+// in the returned XSKMAP.
+func createXDPSocket(ifaceLink netlink.Link) (*os.File, int, error) {
+	fd, err := unix.Socket(unix.AF_XDP, unix.SOCK_RAW, 0)
+	if err != nil {
+		return nil, -1, fmt.Errorf("unable to create AF_XDP socket: %v", err)
+	}
+	sockFile := os.NewFile(uintptr(fd), "xdp-device-fd")
+
+	mapFD, err := xdp.NewXSKMap(1)
+	if err != nil {
+		sockFile.Close()
+		return nil, -1, fmt.Errorf("creating XSKMAP: %v", err)
+	}
+	progFD, err := xdp.LoadRedirectProgram(mapFD)
+	if err != nil {
+		sockFile.Close()
+		unix.Close(mapFD)
+		return nil, -1, fmt.Errorf("loading XDP program: %v", err)
+	}
+	// The program stays attached to the device after its fd is closed.
+	defer unix.Close(progFD)
+	if err := netlink.LinkSetXdpFd(ifaceLink, progFD); err != nil {
+		sockFile.Close()
+		unix.Close(mapFD)
+		return nil, -1, fmt.Errorf("attaching XDP program: %v", err)
+	}
+	return sockFile, mapFD, nil
+}
+
 // loopbackLink returns the link with addresses and routes for a loopback
 // interface.
 func loopbackLink(iface net.Interface, addrs []net.Addr) (boot.LoopbackLink, error) {
@@ -386,6 +716,13 @@ func routesForIface(iface net.Interface) ([]boot.Route, *boot.Route, *boot.Route
 	var defv4, defv6 *boot.Route
 	var routes []boot.Route
 	for _, r := range rs {
+		// Skip routes that netstack can't express, e.g. unreachable or
+		// blackhole routes.
+		if r.Type != unix.RTN_UNICAST {
+			log.Warningf("Skipping non-unicast route on %q: %+v", iface.Name, r)
+			continue
+		}
+
 		// Is it a default route?
 		if r.Dst == nil {
 			if r.Gw == nil {
@@ -432,6 +769,79 @@ func routesForIface(iface net.Interface) ([]boot.Route, *boot.Route, *boot.Route
 	return routes, defv4, defv6, nil
 }
 
+// addressesForLink returns the IPv4 and IPv6 addresses of the link. IPv6
+// addresses that failed duplicate address detection are skipped, since the
+// host doesn't use them either.
+func addressesForLink(link netlink.Link) ([]*net.IPNet, error) {
+	addrs, err := netlink.AddrList(link, netlink.FAMILY_ALL)
+	if err != nil {
+		return nil, err
+	}
+	var ipAddrs []*net.IPNet
+	for _, addr := range addrs {
+		if addr.Flags&unix.IFA_F_DADFAILED != 0 {
+			log.Warningf("Skipping address that failed duplicate address detection on %q: %v", link.Attrs().Name, addr.IPNet)
+			continue
+		}
+		ipAddrs = append(ipAddrs, addr.IPNet)
+	}
+	return ipAddrs, nil
+}
+
+// PodIPs returns the global unicast IP addresses of the interfaces that are
+// up in the network namespace at nsPath, or in the current network namespace
+// if nsPath is empty. They must be read before the sandbox network is set up,
+// which removes them from the host.
+func PodIPs(nsPath string) ([]net.IP, error) {
+	if nsPath != "" {
+		restore, err := joinNetNS(nsPath)
+		if err != nil {
+			return nil, err
+		}
+		defer restore()
+	}
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("querying interfaces: %w", err)
+	}
+	var ips []net.IP
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			return nil, fmt.Errorf("fetching interface addresses for %q: %w", iface.Name, err)
+		}
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok || !ipNet.IP.IsGlobalUnicast() {
+				continue
+			}
+			ips = append(ips, ipNet.IP)
+		}
+	}
+	return ips, nil
+}
+
+// disableIPv6 disables IPv6 on the network device. It's equivalent to:
+//   sysctl net.ipv6.conf.<name>.disable_ipv6=1
+//
+// Packets are still received by the sandbox, since it reads them from a packet
+// socket.
+func disableIPv6(name string) error {
+	path := filepath.Join("/proc/sys/net/ipv6/conf", name, "disable_ipv6")
+	if err := ioutil.WriteFile(path, []byte("1"), 0); err != nil {
+		if os.IsNotExist(err) {
+			// IPv6 isn't supported by the host.
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
 // removeAddress removes IP address from network device. It's equivalent to:
 //   ip addr del <ipAndMask> dev <name>
 func removeAddress(source netlink.Link, ipAndMask string) error {
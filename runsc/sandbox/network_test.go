@@ -0,0 +1,76 @@
+// Copyright 2022 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sandbox
+
+import (
+	"encoding/json"
+	"net"
+	"reflect"
+	"testing"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"gvisor.dev/gvisor/runsc/boot"
+)
+
+func TestNewHostLink(t *testing.T) {
+	addrs := []boot.IPWithPrefix{{Address: net.ParseIP("10.0.0.2").To4(), PrefixLen: 24}}
+	subnet := boot.Route{Destination: net.IPNet{IP: net.ParseIP("10.1.0.0").To4(), Mask: net.CIDRMask(16, 32)}, Gateway: net.ParseIP("10.0.0.254").To4()}
+	defv4 := &boot.Route{Destination: net.IPNet{IP: net.IPv4zero, Mask: net.IPMask(net.IPv4zero)}, Gateway: net.ParseIP("10.0.0.1").To4()}
+
+	link := newHostLink("eth0", addrs, []boot.Route{subnet}, defv4, nil)
+	want := HostLink{
+		Name:      "eth0",
+		Addresses: addrs,
+		// Default routes come last, after the routes to their gateway.
+		Routes: []boot.Route{subnet, *defv4},
+	}
+	if !reflect.DeepEqual(link, want) {
+		t.Errorf("newHostLink() = %+v, want %+v", link, want)
+	}
+
+	// IPv6 is disabled by stealAddresses when the interface has IPv6
+	// addresses, and must be enabled again.
+	link = newHostLink("eth0", append(addrs, boot.IPWithPrefix{Address: net.ParseIP("fd00::2"), PrefixLen: 64}), nil, nil, nil)
+	if !link.IPv6Disabled {
+		t.Errorf("newHostLink() with IPv6 address: IPv6Disabled = false, want true")
+	}
+}
+
+func TestHostLinksSaved(t *testing.T) {
+	s := &Sandbox{
+		ID: "foo",
+		HostLinks: []HostLink{{
+			Name:      "eth0",
+			Addresses: []boot.IPWithPrefix{{Address: net.ParseIP("10.0.0.2"), PrefixLen: 24}},
+		}},
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		t.Fatalf("json.Marshal() failed: %v", err)
+	}
+	var got Sandbox
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("json.Unmarshal() failed: %v", err)
+	}
+	if !reflect.DeepEqual(got.HostLinks, s.HostLinks) {
+		t.Errorf("HostLinks = %+v after reload, want %+v", got.HostLinks, s.HostLinks)
+	}
+
+	// Without a network namespace path, the namespace is destroyed along
+	// with the sandbox.
+	if err := s.RestoreHostNetwork(&specs.Spec{Linux: &specs.Linux{}}); err != nil {
+		t.Errorf("RestoreHostNetwork() without network namespace failed: %v", err)
+	}
+}
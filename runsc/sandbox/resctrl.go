@@ -0,0 +1,138 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sandbox
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"gvisor.dev/gvisor/pkg/log"
+)
+
+// resctrlRoot is where the resctrl filesystem, which controls Intel RDT, is
+// mounted.
+var resctrlRoot = "/sys/fs/resctrl"
+
+// resctrlMaxPasses is the number of times the threads of the sandbox process
+// are listed to move them to its resctrl group. Threads created while they're
+// moved may be missed, since they inherit the group of the thread that
+// created them.
+const resctrlMaxPasses = 10
+
+// setupResctrl moves the sandbox process to the resctrl group requested by
+// spec.Linux.IntelRdt, if any, which bounds the L3 cache and memory bandwidth
+// it can use. The group is named after the CLOS ID of the spec, or after the
+// sandbox if it has none. It's created if it doesn't exist, and then removed
+// when the sandbox is destroyed.
+func (s *Sandbox) setupResctrl(spec *specs.Spec) error {
+	if spec.Linux == nil || spec.Linux.IntelRdt == nil {
+		return nil
+	}
+	rdt := spec.Linux.IntelRdt
+	name := rdt.ClosID
+	if name == "" {
+		name = "runsc-" + s.ID
+	}
+	created, err := joinResctrlGroup(resctrlRoot, name, rdt, s.Pid)
+	if created {
+		s.ResctrlGroup = filepath.Join(resctrlRoot, name)
+	}
+	if err != nil {
+		return fmt.Errorf("applying Intel RDT settings: %v", err)
+	}
+	log.Infof("Sandbox %q moved to resctrl group %q", s.ID, name)
+	return nil
+}
+
+// joinResctrlGroup moves the threads of pid to the resctrl group name, after
+// creating it and programming its schemata if needed. It returns true if the
+// group was created.
+func joinResctrlGroup(root, name string, rdt *specs.LinuxIntelRdt, pid int) (bool, error) {
+	if strings.ContainsRune(name, '/') || name == "." || name == ".." {
+		return false, fmt.Errorf("invalid CLOS ID %q", name)
+	}
+	if _, err := os.Stat(filepath.Join(root, "schemata")); err != nil {
+		return false, fmt.Errorf("resctrl isn't mounted at %q: %v", root, err)
+	}
+
+	dir := filepath.Join(root, name)
+	created := false
+	if err := os.Mkdir(dir, 0755); err == nil {
+		created = true
+	} else if !os.IsExist(err) {
+		return false, fmt.Errorf("creating resctrl group: %v", err)
+	}
+
+	var schemata []string
+	if rdt.L3CacheSchema != "" {
+		schemata = append(schemata, rdt.L3CacheSchema)
+	}
+	if rdt.MemBwSchema != "" {
+		schemata = append(schemata, rdt.MemBwSchema)
+	}
+	for _, schema := range schemata {
+		// Each write must be a single line for the kernel to parse it.
+		if err := ioutil.WriteFile(filepath.Join(dir, "schemata"), []byte(schema+"\n"), 0644); err != nil {
+			return created, fmt.Errorf("writing schemata %q: %v", schema, err)
+		}
+	}
+
+	tasks, err := os.OpenFile(filepath.Join(dir, "tasks"), os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return created, fmt.Errorf("opening tasks: %v", err)
+	}
+	defer tasks.Close()
+	moved := make(map[string]struct{})
+	for pass := 0; pass < resctrlMaxPasses; pass++ {
+		tids, err := ioutil.ReadDir(filepath.Join("/proc", strconv.Itoa(pid), "task"))
+		if err != nil {
+			return created, fmt.Errorf("listing threads: %v", err)
+		}
+		done := true
+		for _, tid := range tids {
+			if _, ok := moved[tid.Name()]; ok {
+				continue
+			}
+			done = false
+			// The kernel only accepts one TID per write.
+			if _, err := tasks.WriteString(tid.Name() + "\n"); err != nil {
+				return created, fmt.Errorf("moving thread %s: %v", tid.Name(), err)
+			}
+			moved[tid.Name()] = struct{}{}
+		}
+		if done {
+			return created, nil
+		}
+	}
+	return created, fmt.Errorf("threads of PID %d kept changing", pid)
+}
+
+// removeResctrlGroup removes the resctrl group created for the sandbox, if
+// any. Its threads are moved back to the default group by the kernel.
+func (s *Sandbox) removeResctrlGroup() {
+	if s.ResctrlGroup == "" {
+		return
+	}
+	if err := os.Remove(s.ResctrlGroup); err != nil && !os.IsNotExist(err) {
+		log.Warningf("Error removing resctrl group %q: %v", s.ResctrlGroup, err)
+		return
+	}
+	s.ResctrlGroup = ""
+}
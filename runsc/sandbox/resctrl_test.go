@@ -0,0 +1,80 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sandbox
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+func TestJoinResctrlGroup(t *testing.T) {
+	root := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(root, "schemata"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	rdt := &specs.LinuxIntelRdt{
+		L3CacheSchema: "L3:0=f",
+		MemBwSchema:   "MB:0=50",
+	}
+	created, err := joinResctrlGroup(root, "test", rdt, os.Getpid())
+	if err != nil {
+		t.Fatalf("joinResctrlGroup() failed: %v", err)
+	}
+	if !created {
+		t.Errorf("joinResctrlGroup() didn't create the group")
+	}
+
+	// The test file keeps the last write only, unlike the kernel.
+	schemata, err := ioutil.ReadFile(filepath.Join(root, "test", "schemata"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(schemata), "MB:0=50\n"; got != want {
+		t.Errorf("schemata got %q, want %q", got, want)
+	}
+	tasks, err := ioutil.ReadFile(filepath.Join(root, "test", "tasks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pid := strconv.Itoa(os.Getpid()); !strings.Contains("\n"+string(tasks), "\n"+pid+"\n") {
+		t.Errorf("tasks %q doesn't contain the main thread %s", tasks, pid)
+	}
+
+	// Joining an existing group doesn't create it.
+	if created, err := joinResctrlGroup(root, "test", &specs.LinuxIntelRdt{}, os.Getpid()); err != nil || created {
+		t.Errorf("joinResctrlGroup() = %t, %v, want false, nil", created, err)
+	}
+}
+
+func TestJoinResctrlGroupErrors(t *testing.T) {
+	root := t.TempDir()
+	if _, err := joinResctrlGroup(root, "test", &specs.LinuxIntelRdt{}, os.Getpid()); err == nil {
+		t.Errorf("joinResctrlGroup() succeeded without resctrl")
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, "schemata"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"../test", ".."} {
+		if _, err := joinResctrlGroup(root, name, &specs.LinuxIntelRdt{}, os.Getpid()); err == nil {
+			t.Errorf("joinResctrlGroup(%q) succeeded, want error", name)
+		}
+	}
+}
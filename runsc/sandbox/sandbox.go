@@ -17,11 +17,14 @@ package sandbox
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"math"
+	"net"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"syscall"
@@ -31,6 +34,7 @@ import (
 	specs "github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/syndtr/gocapability/capability"
 	"golang.org/x/sys/unix"
+	"gvisor.dev/gvisor/pkg/abi/linux"
 	"gvisor.dev/gvisor/pkg/cleanup"
 	"gvisor.dev/gvisor/pkg/control/client"
 	"gvisor.dev/gvisor/pkg/control/server"
@@ -48,6 +52,7 @@ import (
 	"gvisor.dev/gvisor/runsc/config"
 	"gvisor.dev/gvisor/runsc/console"
 	"gvisor.dev/gvisor/runsc/specutils"
+	"gvisor.dev/gvisor/runsc/tracing"
 )
 
 // Sandbox wraps a sandbox process.
@@ -80,12 +85,49 @@ type Sandbox struct {
 	// started, before it may be modified.
 	OriginalOOMScoreAdj int `json:"originalOomScoreAdj"`
 
+	// DebugLogs contains the paths of the debug and panic logs written by the
+	// sandbox process.
+	DebugLogs []string `json:"debugLogs,omitempty"`
+
+	// PanicLog is the path of the file where Go's runtime messages of the
+	// sandbox process are written, if any.
+	PanicLog string `json:"panicLog,omitempty"`
+
+	// CrashDir is the directory where the sandbox process keeps what's
+	// needed for a crash report, see CrashReport. Empty if crash reports are
+	// disabled.
+	CrashDir string `json:"crashDir,omitempty"`
+
+	// ControlTokenDir is the directory with the tokens that authenticate the
+	// clients of the control server, see createControlCreds. Empty if the
+	// control server has no policy.
+	ControlTokenDir string `json:"controlTokenDir,omitempty"`
+
+	// PodIPs are the IP addresses of the sandbox, as found in its network
+	// namespace before it was set up. They're used to generate /etc/hosts
+	// for containers, see config.Config.GenerateHosts.
+	PodIPs []net.IP `json:"podIPs,omitempty"`
+
+	// HostLinks are the network interfaces of the sandbox's network namespace
+	// whose configuration was moved to the sandbox when its network was set
+	// up. See RestoreHostNetwork.
+	HostLinks []HostLink `json:"hostLinks,omitempty"`
+
+	// ResctrlGroup is the resctrl group created for the sandbox process, if
+	// any, see setupResctrl.
+	ResctrlGroup string `json:"resctrlGroup,omitempty"`
+
 	// child is set if a sandbox process is a child of the current process.
 	//
 	// This field isn't saved to json, because only a creator of sandbox
 	// will have it as a child process.
 	child bool
 
+	// timeline is the phases of the boot of the root container that ran in
+	// this process, see TakeBootTimeline. This field isn't saved to json,
+	// the container saves the phases instead.
+	timeline boot.BootTimeline
+
 	// statusMu protects status.
 	statusMu sync.Mutex
 
@@ -121,7 +163,8 @@ type Args struct {
 
 	// MountsFile is a file container mount information from the spec. It's
 	// equivalent to the mounts from the spec, except that all paths have been
-	// resolved to their final absolute location.
+	// resolved to their final absolute location. It's nil when the gofer runs
+	// in the sandbox process (--TESTONLY-in-process-gofer).
 	MountsFile *os.File
 
 	// Gcgroup is the cgroup that the sandbox is part of.
@@ -130,12 +173,15 @@ type Args struct {
 	// Attached indicates that the sandbox lifecycle is attached with the caller.
 	// If the caller exits, the sandbox should exit too.
 	Attached bool
+
+	// PodIPs are the IP addresses of the sandbox, see Sandbox.PodIPs.
+	PodIPs []net.IP
 }
 
 // New creates the sandbox process. The caller must call Destroy() on the
 // sandbox.
 func New(conf *config.Config, args *Args) (*Sandbox, error) {
-	s := &Sandbox{ID: args.ID, CgroupJSON: cgroup.CgroupJSON{Cgroup: args.Cgroup}}
+	s := &Sandbox{ID: args.ID, CgroupJSON: cgroup.CgroupJSON{Cgroup: args.Cgroup}, PodIPs: args.PodIPs}
 	// The Cleanup object cleans up partially created sandboxes when an error
 	// occurs. Any errors occurring during cleanup itself are ignored.
 	c := cleanup.Make(func() {
@@ -153,6 +199,7 @@ func New(conf *config.Config, args *Args) (*Sandbox, error) {
 	defer clientSyncFile.Close()
 
 	// Create the sandbox process.
+	start := time.Now()
 	err = s.createSandboxProcess(conf, args, sandboxSyncFile)
 	// sandboxSyncFile has to be closed to be able to detect when the sandbox
 	// process exits unexpectedly.
@@ -160,8 +207,10 @@ func New(conf *config.Config, args *Args) (*Sandbox, error) {
 	if err != nil {
 		return nil, err
 	}
+	s.timeline.Record("sandbox process start", start)
 
 	// Wait until the sandbox has booted.
+	start = time.Now()
 	b := make([]byte, 1)
 	if l, err := clientSyncFile.Read(b); err != nil || l != 1 {
 		err := fmt.Errorf("waiting for sandbox to start: %v", err)
@@ -178,6 +227,13 @@ func New(conf *config.Config, args *Args) (*Sandbox, error) {
 		}
 		return nil, err
 	}
+	s.timeline.Record("sandbox boot", start)
+
+	// Move all threads of the sandbox process, which are all started by
+	// now.
+	if err := s.setupResctrl(args.Spec); err != nil {
+		return nil, err
+	}
 
 	c.Release()
 	return s, nil
@@ -203,8 +259,10 @@ func (s *Sandbox) CreateSubcontainer(conf *config.Config, cid string, tty *os.Fi
 
 	args := boot.CreateArgs{
 		CID:         cid,
+		TraceParent: tracing.Current().TraceParent(),
 		FilePayload: urpc.FilePayload{Files: files},
 	}
+	defer collectSpans(sandboxConn)
 	if err := sandboxConn.Call(boot.ContMgrCreateSubcontainer, &args, nil); err != nil {
 		return fmt.Errorf("creating sub-container %q: %v", cid, err)
 	}
@@ -220,14 +278,25 @@ func (s *Sandbox) StartRoot(spec *specs.Spec, conf *config.Config) error {
 	}
 	defer conn.Close()
 
+	defer collectSpans(conn)
+
 	// Configure the network.
-	if err := setupNetwork(conn, s.Pid, conf); err != nil {
+	start := time.Now()
+	span := tracing.StartChild("setup network")
+	s.HostLinks, err = setupNetwork(conn, s.Pid, conf)
+	span.End(err)
+	if err != nil {
 		return fmt.Errorf("setting up network: %v", err)
 	}
+	s.timeline.Record("network setup", start)
 
 	// Send a message to the sandbox control server to start the root
 	// container.
-	if err := conn.Call(boot.ContMgrRootContainerStart, &s.ID, nil); err != nil {
+	args := boot.StartRootArgs{
+		CID:         s.ID,
+		TraceParent: tracing.Current().TraceParent(),
+	}
+	if err := conn.Call(boot.ContMgrRootContainerStart, &args, nil); err != nil {
 		return fmt.Errorf("starting root container: %v", err)
 	}
 
@@ -259,8 +328,10 @@ func (s *Sandbox) StartSubcontainer(spec *specs.Spec, conf *config.Config, cid s
 		Spec:        spec,
 		Conf:        conf,
 		CID:         cid,
+		TraceParent: tracing.Current().TraceParent(),
 		FilePayload: payload,
 	}
+	defer collectSpans(sandboxConn)
 	if err := sandboxConn.Call(boot.ContMgrStartSubcontainer, &args, nil); err != nil {
 		return fmt.Errorf("starting sub-container %v: %v", spec.Process.Args, err)
 	}
@@ -281,11 +352,24 @@ func (s *Sandbox) Restore(cid string, spec *specs.Spec, conf *config.Config, fil
 		FilePayload: urpc.FilePayload{
 			Files: []*os.File{rf},
 		},
-		SandboxID: s.ID,
+		SandboxID:   s.ID,
+		TraceParent: tracing.Current().TraceParent(),
+	}
+
+	// Memory pages may have been saved to a separate file, next to the state
+	// file.
+	pagesPath := filepath.Join(filepath.Dir(filename), PagesFileName)
+	if pf, err := os.Open(pagesPath); err == nil {
+		defer pf.Close()
+		log.Infof("Restoring memory pages lazily from %q", pagesPath)
+		opt.FilePayload.Files = append(opt.FilePayload.Files, pf)
+		opt.HasPagesFile = true
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("opening pages file %q failed: %v", pagesPath, err)
 	}
 
 	// If the platform needs a device FD we must pass it in.
-	if deviceFile, err := deviceFileForPlatform(conf.Platform); err != nil {
+	if deviceFile, err := openPlatformDevice(conf); err != nil {
 		return err
 	} else if deviceFile != nil {
 		defer deviceFile.Close()
@@ -298,10 +382,17 @@ func (s *Sandbox) Restore(cid string, spec *specs.Spec, conf *config.Config, fil
 	}
 	defer conn.Close()
 
+	defer collectSpans(conn)
+
 	// Configure the network.
-	if err := setupNetwork(conn, s.Pid, conf); err != nil {
+	start := time.Now()
+	span := tracing.StartChild("setup network")
+	s.HostLinks, err = setupNetwork(conn, s.Pid, conf)
+	span.End(err)
+	if err != nil {
 		return fmt.Errorf("setting up network: %v", err)
 	}
+	s.timeline.Record("network setup", start)
 
 	// Restore the container and start the root container.
 	if err := conn.Call(boot.ContMgrRestore, &opt, nil); err != nil {
@@ -311,6 +402,33 @@ func (s *Sandbox) Restore(cid string, spec *specs.Spec, conf *config.Config, fil
 	return nil
 }
 
+// RestoreSubcontainer prepares a sub-container to be restored along with the
+// root container. goferFiles connect to the gofer serving the sub-container.
+func (s *Sandbox) RestoreSubcontainer(spec *specs.Spec, conf *config.Config, cid string, goferFiles []*os.File) error {
+	log.Debugf("Restore sub-container %q in sandbox %q, PID: %d", cid, s.ID, s.Pid)
+
+	conn, err := s.sandboxConnect()
+	if err != nil {
+		return fmt.Errorf("couldn't connect to sandbox: %v", err)
+	}
+	defer conn.Close()
+
+	args := boot.StartArgs{
+		Spec:        spec,
+		Conf:        conf,
+		CID:         cid,
+		TraceParent: tracing.Current().TraceParent(),
+		FilePayload: urpc.FilePayload{
+			Files: goferFiles,
+		},
+	}
+	defer collectSpans(conn)
+	if err := conn.Call(boot.ContMgrRestoreSubcontainer, &args, nil); err != nil {
+		return fmt.Errorf("restoring sub-container %q: %v", cid, err)
+	}
+	return nil
+}
+
 // Processes retrieves the list of processes and associated metadata for a
 // given container in this sandbox.
 func (s *Sandbox) Processes(cid string) ([]*control.Process, error) {
@@ -349,6 +467,8 @@ func (s *Sandbox) Execute(conf *config.Config, args *control.ExecArgs) (int32, e
 	defer conn.Close()
 
 	// Send a message to the sandbox control server to start the container.
+	args.TraceParent = tracing.Current().TraceParent()
+	defer collectSpans(conn)
 	var pid int32
 	if err := conn.Call(boot.ContMgrExecuteAsync, args, &pid); err != nil {
 		return 0, fmt.Errorf("executing command %q in sandbox: %v", args, err)
@@ -356,6 +476,85 @@ func (s *Sandbox) Execute(conf *config.Config, args *control.ExecArgs) (int32, e
 	return pid, nil
 }
 
+// ExecSessions lists the exec sessions of the given container that weren't
+// waited for.
+func (s *Sandbox) ExecSessions(cid string) ([]boot.ExecSession, error) {
+	log.Debugf("Getting exec sessions for container %q in sandbox %q", cid, s.ID)
+	conn, err := s.sandboxConnect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var sessions []boot.ExecSession
+	if err := conn.Call(boot.ContMgrExecSessions, &cid, &sessions); err != nil {
+		return nil, fmt.Errorf("retrieving exec sessions from sandbox: %v", err)
+	}
+	return sessions, nil
+}
+
+// Attach connects the given stdin, stdout and stderr files to the stdio of
+// the container's init process. The attach session ends when stdin reaches
+// EOF.
+func (s *Sandbox) Attach(cid string, readOnly bool, stdin, stdout, stderr *os.File) error {
+	log.Debugf("Attach to container %q in sandbox %q, readOnly: %t", cid, s.ID, readOnly)
+	conn, err := s.sandboxConnect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	args := boot.AttachArgs{
+		CID:         cid,
+		ReadOnly:    readOnly,
+		FilePayload: urpc.FilePayload{Files: []*os.File{stdin, stdout, stderr}},
+	}
+	if err := conn.Call(boot.ContMgrAttach, &args, nil); err != nil {
+		return fmt.Errorf("attaching to container %q: %v", cid, err)
+	}
+	return nil
+}
+
+// KillExecSession sends a signal to the given exec session.
+func (s *Sandbox) KillExecSession(cid, execID string, sig unix.Signal) error {
+	log.Debugf("Signal exec session %q in container %q in sandbox %q", execID, cid, s.ID)
+	conn, err := s.sandboxConnect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	args := boot.KillExecSessionArgs{
+		CID:    cid,
+		ExecID: execID,
+		Signo:  int32(sig),
+	}
+	if err := conn.Call(boot.ContMgrKillExecSession, &args, nil); err != nil {
+		return fmt.Errorf("signaling exec session %q in container %q: %v", execID, cid, err)
+	}
+	return nil
+}
+
+// ResizeTTY changes the window size of the TTY of the given process.
+func (s *Sandbox) ResizeTTY(cid string, pid int32, winsize *linux.Winsize) error {
+	log.Debugf("Resize TTY of PID %d in container %q in sandbox %q to %dx%d", pid, cid, s.ID, winsize.Col, winsize.Row)
+	conn, err := s.sandboxConnect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	args := boot.ResizeTTYArgs{
+		CID:     cid,
+		PID:     pid,
+		Winsize: *winsize,
+	}
+	if err := conn.Call(boot.ContMgrResizeTTY, &args, nil); err != nil {
+		return fmt.Errorf("resizing TTY of PID %d in container %q: %v", pid, cid, err)
+	}
+	return nil
+}
+
 // Event retrieves stats about the sandbox such as memory and CPU utilization.
 func (s *Sandbox) Event(cid string) (*boot.EventOut, error) {
 	log.Debugf("Getting events for container %q in sandbox %q", cid, s.ID)
@@ -375,15 +574,78 @@ func (s *Sandbox) Event(cid string) (*boot.EventOut, error) {
 	return &e, nil
 }
 
+// HealthCheck retrieves the health check status of the given container.
+func (s *Sandbox) HealthCheck(cid string) (*boot.HealthStatus, error) {
+	log.Debugf("Getting health status for container %q in sandbox %q", cid, s.ID)
+	conn, err := s.sandboxConnect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var status boot.HealthStatus
+	if err := conn.Call(boot.ContMgrHealthCheck, &cid, &status); err != nil {
+		return nil, fmt.Errorf("retrieving health status from sandbox: %v", err)
+	}
+	return &status, nil
+}
+
+// BootTimeline retrieves the phases of the boot of the given container that ran
+// in the sandbox.
+func (s *Sandbox) BootTimeline(cid string) (boot.BootTimeline, error) {
+	log.Debugf("Getting boot timeline for container %q in sandbox %q", cid, s.ID)
+	conn, err := s.sandboxConnect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var t boot.BootTimeline
+	if err := conn.Call(boot.ContMgrBootTimeline, &cid, &t); err != nil {
+		return nil, fmt.Errorf("retrieving boot timeline from sandbox: %v", err)
+	}
+	return t, nil
+}
+
+// TakeBootTimeline returns the phases of the boot of the root container that
+// ran in this process since the last call.
+func (s *Sandbox) TakeBootTimeline() boot.BootTimeline {
+	t := s.timeline
+	s.timeline = nil
+	return t
+}
+
 func (s *Sandbox) sandboxConnect() (*urpc.Client, error) {
 	log.Debugf("Connecting to sandbox %q", s.ID)
-	conn, err := client.ConnectTo(boot.ControlSocketAddr(s.ID))
+	var (
+		conn *urpc.Client
+		err  error
+	)
+	if s.ControlTokenDir != "" {
+		conn, err = s.connectWithToken()
+	} else {
+		conn, err = client.ConnectTo(boot.ControlSocketAddr(s.ID))
+	}
 	if err != nil {
 		return nil, s.connError(err)
 	}
 	return conn, nil
 }
 
+// collectSpans adds the spans recorded by the sandbox to the spans exported by
+// runsc, if the running command is traced.
+func collectSpans(conn *urpc.Client) {
+	if tracing.Current() == nil {
+		return
+	}
+	var spans []*tracing.Span
+	if err := conn.Call(boot.ContMgrCollectSpans, nil, &spans); err != nil {
+		log.Warningf("Error collecting spans from sandbox: %v", err)
+		return
+	}
+	tracing.Add(spans)
+}
+
 func (s *Sandbox) connError(err error) error {
 	return fmt.Errorf("connecting to control server at PID %d: %v", s.Pid, err)
 }
@@ -395,6 +657,16 @@ func (s *Sandbox) createSandboxProcess(conf *config.Config, args *Args, startSyn
 	// starts at 3 because 0, 1, and 2 are taken by stdin/out/err.
 	nextFD := 3
 
+	// Open the device file of the platform first, since it may change the
+	// platform passed to the sandbox.
+	deviceFile, err := openPlatformDevice(conf)
+	if err != nil {
+		return err
+	}
+	if deviceFile != nil {
+		defer deviceFile.Close()
+	}
+
 	binPath := specutils.ExePath
 	cmd := exec.Command(binPath, conf.ToFlags()...)
 	cmd.SysProcAttr = &unix.SysProcAttr{}
@@ -421,21 +693,40 @@ func (s *Sandbox) createSandboxProcess(conf *config.Config, args *Args, startSyn
 		}
 	}
 	if conf.DebugLog != "" {
-		debugLogFile, err := specutils.DebugLogFile(conf.DebugLog, "boot", test)
+		debugLogFile, err := specutils.DebugLogFile(conf.DebugLog, "boot", s.ID, test)
 		if err != nil {
 			return fmt.Errorf("opening debug log file in %q: %v", conf.DebugLog, err)
 		}
 		defer debugLogFile.Close()
+		s.DebugLogs = append(s.DebugLogs, debugLogFile.Name())
 		cmd.ExtraFiles = append(cmd.ExtraFiles, debugLogFile)
 		cmd.Args = append(cmd.Args, "--debug-log-fd="+strconv.Itoa(nextFD))
 		nextFD++
 	}
+	if conf.CrashReportDir != "" {
+		if err := s.createCrashDir(conf); err != nil {
+			return err
+		}
+	}
 	if conf.PanicLog != "" {
-		panicLogFile, err := specutils.DebugLogFile(conf.PanicLog, "panic", test)
+		panicLogFile, err := specutils.DebugLogFile(conf.PanicLog, "panic", s.ID, test)
 		if err != nil {
 			return fmt.Errorf("opening panic log file in %q: %v", conf.PanicLog, err)
 		}
 		defer panicLogFile.Close()
+		s.DebugLogs = append(s.DebugLogs, panicLogFile.Name())
+		s.PanicLog = panicLogFile.Name()
+		cmd.ExtraFiles = append(cmd.ExtraFiles, panicLogFile)
+		cmd.Args = append(cmd.Args, "--panic-log-fd="+strconv.Itoa(nextFD))
+		nextFD++
+	} else if s.CrashDir != "" {
+		// Crash reports need Go's runtime messages.
+		panicLogFile, err := s.openCrashPanicLog()
+		if err != nil {
+			return err
+		}
+		defer panicLogFile.Close()
+		s.PanicLog = panicLogFile.Name()
 		cmd.ExtraFiles = append(cmd.ExtraFiles, panicLogFile)
 		cmd.Args = append(cmd.Args, "--panic-log-fd="+strconv.Itoa(nextFD))
 		nextFD++
@@ -445,7 +736,7 @@ func (s *Sandbox) createSandboxProcess(conf *config.Config, args *Args, startSyn
 		covFilename = os.Getenv("GO_COVERAGE_FILE")
 	}
 	if covFilename != "" && coverage.Available() {
-		covFile, err := specutils.DebugLogFile(covFilename, "cov", test)
+		covFile, err := specutils.DebugLogFile(covFilename, "cov", s.ID, test)
 		if err != nil {
 			return fmt.Errorf("opening debug log file in %q: %v", covFilename, err)
 		}
@@ -460,6 +751,17 @@ func (s *Sandbox) createSandboxProcess(conf *config.Config, args *Args, startSyn
 	// All flags after this must be for the boot command
 	cmd.Args = append(cmd.Args, "boot", "--bundle="+args.BundleDir)
 
+	if s.CrashDir != "" {
+		ringFile, err := s.openCrashLogRing()
+		if err != nil {
+			return err
+		}
+		defer ringFile.Close()
+		cmd.ExtraFiles = append(cmd.ExtraFiles, ringFile)
+		cmd.Args = append(cmd.Args, "--log-ring-fd="+strconv.Itoa(nextFD))
+		nextFD++
+	}
+
 	// Create a socket for the control server and donate it to the sandbox.
 	addr := boot.ControlSocketAddr(s.ID)
 	sockFD, err := server.CreateSocket(addr)
@@ -473,10 +775,23 @@ func (s *Sandbox) createSandboxProcess(conf *config.Config, args *Args, startSyn
 	cmd.Args = append(cmd.Args, "--controller-fd="+strconv.Itoa(nextFD))
 	nextFD++
 
-	defer args.MountsFile.Close()
-	cmd.ExtraFiles = append(cmd.ExtraFiles, args.MountsFile)
-	cmd.Args = append(cmd.Args, "--mounts-fd="+strconv.Itoa(nextFD))
-	nextFD++
+	if conf.ControlPolicy != "" {
+		credsFile, err := s.createControlCreds(conf)
+		if err != nil {
+			return err
+		}
+		defer credsFile.Close()
+		cmd.ExtraFiles = append(cmd.ExtraFiles, credsFile)
+		cmd.Args = append(cmd.Args, "--control-creds-fd="+strconv.Itoa(nextFD))
+		nextFD++
+	}
+
+	if args.MountsFile != nil {
+		defer args.MountsFile.Close()
+		cmd.ExtraFiles = append(cmd.ExtraFiles, args.MountsFile)
+		cmd.Args = append(cmd.Args, "--mounts-fd="+strconv.Itoa(nextFD))
+		nextFD++
+	}
 
 	specFile, err := specutils.OpenSpec(args.BundleDir)
 	if err != nil {
@@ -546,6 +861,69 @@ func (s *Sandbox) createSandboxProcess(conf *config.Config, args *Args, startSyn
 		nextFD++
 	}
 
+	if zone, ok := args.Spec.Annotations[boot.TimezoneAnnotation]; ok {
+		tz, err := specutils.ResolveTimezone(zone)
+		if err != nil {
+			return err
+		}
+		cmd.Args = append(cmd.Args, "--timezone="+tz)
+	}
+
+	if tp := tracing.Current().TraceParent(); tp != "" {
+		cmd.Args = append(cmd.Args, "--trace-parent="+tp)
+	}
+
+	if conf.LogForward != "" {
+		logFile, err := openLogForwardSocket(conf.LogForward)
+		if err != nil {
+			return err
+		}
+		defer logFile.Close()
+		cmd.ExtraFiles = append(cmd.ExtraFiles, logFile)
+		cmd.Args = append(cmd.Args, "--log-forward-fd="+strconv.Itoa(nextFD))
+		nextFD++
+	}
+
+	if conf.Vsock != "" && conf.Vsock != config.VsockHost {
+		vsockDir, err := os.OpenFile(conf.Vsock, unix.O_PATH|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+		if err != nil {
+			return fmt.Errorf("opening vsock directory: %v", err)
+		}
+		defer vsockDir.Close()
+		cmd.ExtraFiles = append(cmd.ExtraFiles, vsockDir)
+		cmd.Args = append(cmd.Args, "--vsock-dir-fd="+strconv.Itoa(nextFD))
+		nextFD++
+	}
+
+	if conf.Record != "" || conf.Replay != "" {
+		var replayLog *os.File
+		var err error
+		if conf.Replay != "" {
+			replayLog, err = os.Open(conf.Replay)
+		} else {
+			replayLog, err = os.OpenFile(conf.Record, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+		}
+		if err != nil {
+			return fmt.Errorf("opening replay log: %v", err)
+		}
+		defer replayLog.Close()
+		cmd.ExtraFiles = append(cmd.ExtraFiles, replayLog)
+		cmd.Args = append(cmd.Args, "--replay-log-fd="+strconv.Itoa(nextFD))
+		nextFD++
+	}
+
+	if conf.EntropySource != "" {
+		// The sandbox can't open the device itself once it's chrooted.
+		entropy, err := os.OpenFile(conf.EntropySource, os.O_RDONLY|unix.O_CLOEXEC, 0)
+		if err != nil {
+			return fmt.Errorf("opening entropy source: %v", err)
+		}
+		defer entropy.Close()
+		cmd.ExtraFiles = append(cmd.ExtraFiles, entropy)
+		cmd.Args = append(cmd.Args, "--entropy-fd="+strconv.Itoa(nextFD))
+		nextFD++
+	}
+
 	// If there is a gofer, sends all socket ends to the sandbox.
 	for _, f := range args.IOFiles {
 		defer f.Close()
@@ -554,15 +932,7 @@ func (s *Sandbox) createSandboxProcess(conf *config.Config, args *Args, startSyn
 		nextFD++
 	}
 
-	gPlatform, err := platform.Lookup(conf.Platform)
-	if err != nil {
-		return err
-	}
-
-	if deviceFile, err := gPlatform.OpenDevice(); err != nil {
-		return fmt.Errorf("opening device file for platform %q: %v", conf.Platform, err)
-	} else if deviceFile != nil {
-		defer deviceFile.Close()
+	if deviceFile != nil {
 		cmd.ExtraFiles = append(cmd.ExtraFiles, deviceFile)
 		cmd.Args = append(cmd.Args, "--device-fd="+strconv.Itoa(nextFD))
 		nextFD++
@@ -952,10 +1322,31 @@ func (s *Sandbox) destroy() error {
 			return fmt.Errorf("waiting sandbox %q stop: %v", s.ID, err)
 		}
 	}
+	s.removeCrashDir()
+	s.removeControlTokenDir()
+	s.removeResctrlGroup()
 
 	return nil
 }
 
+// RestoreHostNetwork gives the configuration of the network interfaces moved
+// to the sandbox back to the network namespace of spec, so that a new sandbox
+// can be set up in it, e.g. to restore this one. It's a no-op if the sandbox
+// had its own network namespace, which is destroyed along with it.
+//
+// Preconditions: The sandbox must be destroyed.
+func (s *Sandbox) RestoreHostNetwork(spec *specs.Spec) error {
+	if len(s.HostLinks) == 0 {
+		return nil
+	}
+	ns, ok := specutils.GetNS(specs.NetworkNamespace, spec)
+	if !ok || ns.Path == "" {
+		return nil
+	}
+	log.Infof("Restoring network configuration of sandbox %q to %q", s.ID, ns.Path)
+	return restoreHostLinks(ns.Path, s.HostLinks)
+}
+
 // SignalContainer sends the signal to a container in the sandbox. If all is
 // true and signal is SIGKILL, then waits for all processes to exit before
 // returning.
@@ -983,6 +1374,29 @@ func (s *Sandbox) SignalContainer(cid string, sig unix.Signal, all bool) error {
 	return nil
 }
 
+// SignalPID sends the signal to process 'pid' in the container's PID
+// namespace.
+func (s *Sandbox) SignalPID(cid string, pid int32, sig unix.Signal) error {
+	log.Debugf("Signal sandbox %q", s.ID)
+	conn, err := s.sandboxConnect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	args := boot.SignalArgs{
+		CID:          cid,
+		Signo:        int32(sig),
+		PID:          pid,
+		ContainerPID: true,
+		Mode:         boot.DeliverToProcess,
+	}
+	if err := conn.Call(boot.ContMgrSignal, &args, nil); err != nil {
+		return fmt.Errorf("signaling container %q PID %d: %v", cid, pid, err)
+	}
+	return nil
+}
+
 // SignalProcess sends the signal to a particular process in the container. If
 // fgProcess is true, then the signal is sent to the foreground process group
 // in the same session that PID belongs to. This is only valid if the process
@@ -1012,28 +1426,101 @@ func (s *Sandbox) SignalProcess(cid string, pid int32, sig unix.Signal, fgProces
 	return nil
 }
 
+// PagesFileName is the name of the file memory pages are saved to, next to
+// the state file, when they are saved separately.
+const PagesFileName = "pages.img"
+
+// CheckpointOpts contains options for checkpoints.
+type CheckpointOpts struct {
+	// Exclude are directories of the container whose contents are not saved.
+	// They must be on tmpfs, and are empty once restored.
+	Exclude []string
+
+	// PagesFile is the optional destination of memory pages. If it is set,
+	// pages are saved to it rather than to the state file, and are loaded
+	// lazily on restore.
+	PagesFile *os.File
+
+	// Compression is the compression algorithm of the state file, "flate"
+	// or "zstd". It defaults to flate.
+	Compression string
+
+	// Dedup saves memory pages identical to a page saved before as a
+	// reference to it. It can't be used with PagesFile.
+	Dedup bool
+}
+
 // Checkpoint sends the checkpoint call for a container in the sandbox.
 // The statefile will be written to f.
-func (s *Sandbox) Checkpoint(cid string, f *os.File) error {
-	log.Debugf("Checkpoint sandbox %q", s.ID)
+func (s *Sandbox) Checkpoint(cid string, f *os.File, opts CheckpointOpts) error {
+	log.Debugf("Checkpoint sandbox %q, exclude: %v", s.ID, opts.Exclude)
 	conn, err := s.sandboxConnect()
 	if err != nil {
 		return err
 	}
 	defer conn.Close()
 
-	opt := control.SaveOpts{
-		FilePayload: urpc.FilePayload{
-			Files: []*os.File{f},
+	opt := boot.CheckpointOpts{
+		SaveOpts: control.SaveOpts{
+			Compression: opts.Compression,
+			Dedup:       opts.Dedup,
+			FilePayload: urpc.FilePayload{
+				Files: []*os.File{f},
+			},
 		},
+		CID:         cid,
+		Exclude:     opts.Exclude,
+		TraceParent: tracing.Current().TraceParent(),
+	}
+	if opts.PagesFile != nil {
+		opt.FilePayload.Files = append(opt.FilePayload.Files, opts.PagesFile)
 	}
 
+	defer collectSpans(conn)
 	if err := conn.Call(boot.ContMgrCheckpoint, &opt, nil); err != nil {
 		return fmt.Errorf("checkpointing container %q: %v", cid, err)
 	}
 	return nil
 }
 
+// StartSnapshots starts taking snapshots of the sandbox every interval, leaving
+// it running. Snapshots are written to files in rotation, overwriting the
+// oldest one.
+func (s *Sandbox) StartSnapshots(interval time.Duration, files []*os.File) error {
+	log.Debugf("Start snapshots of sandbox %q, interval: %v, files: %d", s.ID, interval, len(files))
+	conn, err := s.sandboxConnect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	args := boot.StartSnapshotsArgs{
+		Interval: interval,
+		FilePayload: urpc.FilePayload{
+			Files: files,
+		},
+	}
+	if err := conn.Call(boot.ContMgrStartSnapshots, &args, nil); err != nil {
+		return fmt.Errorf("starting snapshots of sandbox %q: %v", s.ID, err)
+	}
+	return nil
+}
+
+// StopSnapshots stops taking snapshots of the sandbox.
+func (s *Sandbox) StopSnapshots() error {
+	log.Debugf("Stop snapshots of sandbox %q", s.ID)
+	conn, err := s.sandboxConnect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := conn.Call(boot.ContMgrStopSnapshots, nil, nil); err != nil {
+		return fmt.Errorf("stopping snapshots of sandbox %q: %v", s.ID, err)
+	}
+	return nil
+}
+
 // Pause sends the pause call for a container in the sandbox.
 func (s *Sandbox) Pause(cid string) error {
 	log.Debugf("Pause sandbox %q", s.ID)
@@ -1135,6 +1622,39 @@ func (s *Sandbox) Reduce(cid string, wait bool) error {
 	}, nil)
 }
 
+// MemoryBreakdown returns a breakdown of the memory used by the sandbox.
+func (s *Sandbox) MemoryBreakdown() (control.MemoryBreakdown, error) {
+	log.Debugf("Memory breakdown sandbox %q", s.ID)
+	conn, err := s.sandboxConnect()
+	if err != nil {
+		return control.MemoryBreakdown{}, err
+	}
+	defer conn.Close()
+
+	var m control.MemoryBreakdown
+	if err := conn.Call(boot.UsageBreakdown, nil, &m); err != nil {
+		return control.MemoryBreakdown{}, fmt.Errorf("getting sandbox %q memory breakdown: %v", s.ID, err)
+	}
+	return m, nil
+}
+
+// CPUBreakdown returns a breakdown of the CPU time used by the sandbox,
+// between the application and the sentry.
+func (s *Sandbox) CPUBreakdown() (control.CPUBreakdown, error) {
+	log.Debugf("CPU breakdown sandbox %q", s.ID)
+	conn, err := s.sandboxConnect()
+	if err != nil {
+		return control.CPUBreakdown{}, err
+	}
+	defer conn.Close()
+
+	var b control.CPUBreakdown
+	if err := conn.Call(boot.UsageCPUBreakdown, nil, &b); err != nil {
+		return control.CPUBreakdown{}, fmt.Errorf("getting sandbox %q CPU breakdown: %v", s.ID, err)
+	}
+	return b, nil
+}
+
 // Stream sends the AttachDebugEmitter call for a container in the sandbox, and
 // dumps filtered events to out.
 func (s *Sandbox) Stream(cid string, filters []string, out *os.File) error {
@@ -1194,6 +1714,22 @@ func (s *Sandbox) Stacks() (string, error) {
 	return stacks, nil
 }
 
+// Dmesg returns the most recent log records of the sandbox, oldest first.
+func (s *Sandbox) Dmesg() (string, error) {
+	log.Debugf("Dmesg sandbox %q", s.ID)
+	conn, err := s.sandboxConnect()
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	var records string
+	if err := conn.Call(boot.DebugDmesg, nil, &records); err != nil {
+		return "", fmt.Errorf("getting sandbox %q log records: %v", s.ID, err)
+	}
+	return records, nil
+}
+
 // HeapProfile writes a heap profile to the given file.
 func (s *Sandbox) HeapProfile(f *os.File, delay time.Duration) error {
 	log.Debugf("Heap profile %q", s.ID)
@@ -1289,6 +1825,106 @@ func (s *Sandbox) ChangeLogging(args control.LoggingArgs) error {
 	return nil
 }
 
+// StraceFollow writes the straces of processes pids, or all processes if
+// empty, to out as they happen, until the sandbox exits or writing fails.
+// Only syscalls are traced, or all if empty.
+func (s *Sandbox) StraceFollow(pids []int32, syscalls []string, out io.Writer) error {
+	log.Debugf("Strace follow sandbox %q, PIDs: %v, syscalls: %v", s.ID, pids, syscalls)
+	conn, err := s.sandboxConnect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	args := control.StraceFollowArgs{
+		PIDs:        pids,
+		Syscalls:    syscalls,
+		FilePayload: urpc.FilePayload{Files: []*os.File{w}},
+	}
+	err = conn.Call(boot.LoggingStraceFollow, &args, nil)
+	// The sandbox has its own copy of w, if any.
+	w.Close()
+	if err != nil {
+		return fmt.Errorf("following straces of sandbox %q: %v", s.ID, err)
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		return fmt.Errorf("reading straces of sandbox %q: %v", s.ID, err)
+	}
+	return nil
+}
+
+// Ports returns the ephemeral port configuration of the sandbox network
+// stack.
+func (s *Sandbox) Ports() (boot.PortConfig, error) {
+	log.Debugf("Getting ports of sandbox %q", s.ID)
+	conn, err := s.sandboxConnect()
+	if err != nil {
+		return boot.PortConfig{}, err
+	}
+	defer conn.Close()
+
+	var ports boot.PortConfig
+	if err := conn.Call(boot.NetworkPorts, nil, &ports); err != nil {
+		return boot.PortConfig{}, fmt.Errorf("getting sandbox %q ports: %v", s.ID, err)
+	}
+	return ports, nil
+}
+
+// SetPorts changes the ephemeral port configuration of the sandbox network
+// stack.
+func (s *Sandbox) SetPorts(ports boot.PortConfig) error {
+	log.Debugf("Setting ports of sandbox %q: %+v", s.ID, ports)
+	conn, err := s.sandboxConnect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := conn.Call(boot.NetworkSetPorts, &ports, nil); err != nil {
+		return fmt.Errorf("setting sandbox %q ports: %v", s.ID, err)
+	}
+	return nil
+}
+
+// Tunables returns the sentry tunables with the given names, or all of them if
+// names is empty.
+func (s *Sandbox) Tunables(names []string) ([]boot.Tunable, error) {
+	log.Debugf("Getting tunables %v of sandbox %q", names, s.ID)
+	conn, err := s.sandboxConnect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var tunables []boot.Tunable
+	if err := conn.Call(boot.TunablesGet, &names, &tunables); err != nil {
+		return nil, fmt.Errorf("getting sandbox %q tunables: %v", s.ID, err)
+	}
+	return tunables, nil
+}
+
+// SetTunable changes the value of a sentry tunable.
+func (s *Sandbox) SetTunable(name, value string) error {
+	log.Debugf("Setting tunable %q of sandbox %q to %q", name, s.ID, value)
+	conn, err := s.sandboxConnect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	args := boot.SetTunableArgs{Name: name, Value: value}
+	if err := conn.Call(boot.TunablesSet, &args, nil); err != nil {
+		return fmt.Errorf("setting sandbox %q tunable: %v", s.ID, err)
+	}
+	return nil
+}
+
 // DestroyContainer destroys the given container. If it is the root container,
 // then the entire sandbox is destroyed.
 func (s *Sandbox) DestroyContainer(cid string) error {
@@ -1365,6 +2001,29 @@ func (s *Sandbox) configureStdios(conf *config.Config, stdios []*os.File) error
 	return nil
 }
 
+// openLogForwardSocket returns a datagram socket connected to the host's
+// logging daemon for the given driver.
+func openLogForwardSocket(driver string) (*os.File, error) {
+	var path string
+	switch driver {
+	case config.LogForwardSyslog:
+		path = "/dev/log"
+	case config.LogForwardJournald:
+		path = "/run/systemd/journal/socket"
+	default:
+		return nil, fmt.Errorf("invalid log forwarding driver %q", driver)
+	}
+	sock, err := unix.Socket(unix.AF_UNIX, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("creating log forwarding socket: %v", err)
+	}
+	if err := unix.Connect(sock, &unix.SockaddrUnix{Name: path}); err != nil {
+		unix.Close(sock)
+		return nil, fmt.Errorf("connecting to %s at %q: %v", driver, path, err)
+	}
+	return os.NewFile(uintptr(sock), path), nil
+}
+
 // deviceFileForPlatform opens the device file for the given platform. If the
 // platform does not need a device file, then nil is returned.
 func deviceFileForPlatform(name string) (*os.File, error) {
@@ -1380,6 +2039,19 @@ func deviceFileForPlatform(name string) (*os.File, error) {
 	return f, nil
 }
 
+// openPlatformDevice is like deviceFileForPlatform for conf.Platform, but if
+// the KVM device doesn't exist and conf.PlatformFallback is set, it changes
+// conf.Platform to ptrace instead of failing.
+func openPlatformDevice(conf *config.Config) (*os.File, error) {
+	f, err := deviceFileForPlatform(conf.Platform)
+	if err == nil || conf.Platform != platforms.KVM || !conf.PlatformFallback || !errors.Is(err, os.ErrNotExist) {
+		return f, err
+	}
+	log.Warningf("%v, falling back to platform %q", err, platforms.Ptrace)
+	conf.Platform = platforms.Ptrace
+	return deviceFileForPlatform(conf.Platform)
+}
+
 // checkBinaryPermissions verifies that the required binary bits are set on
 // the runsc executable.
 func checkBinaryPermissions(conf *config.Config) error {
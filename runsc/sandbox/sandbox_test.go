@@ -0,0 +1,70 @@
+// Copyright 2022 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sandbox
+
+import (
+	"path/filepath"
+	"testing"
+
+	"gvisor.dev/gvisor/runsc/boot/platforms"
+	"gvisor.dev/gvisor/runsc/config"
+)
+
+func TestPlatformFallback(t *testing.T) {
+	// Point the KVM platform to a device that doesn't exist.
+	t.Setenv("GVISOR_KVM_DEV", filepath.Join(t.TempDir(), "kvm"))
+
+	for _, tc := range []struct {
+		name     string
+		fallback bool
+		want     string
+	}{
+		{
+			name:     "fallback",
+			fallback: true,
+			want:     platforms.Ptrace,
+		},
+		{
+			name:     "no-fallback",
+			fallback: false,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			conf := &config.Config{
+				Platform:         platforms.KVM,
+				PlatformFallback: tc.fallback,
+			}
+			f, err := openPlatformDevice(conf)
+			if f != nil {
+				f.Close()
+			}
+			if tc.want == "" {
+				if err == nil {
+					t.Fatalf("openPlatformDevice() succeeded, want error")
+				}
+				if conf.Platform != platforms.KVM {
+					t.Errorf("platform changed to %q without fallback", conf.Platform)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("openPlatformDevice(): %v", err)
+			}
+			if conf.Platform != tc.want {
+				t.Errorf("platform got %q, want %q", conf.Platform, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build gofuzz
+// +build gofuzz
+
+package specutils
+
+import (
+	"io/ioutil"
+	"os"
+
+	"gvisor.dev/gvisor/pkg/sync"
+	"gvisor.dev/gvisor/runsc/config"
+)
+
+var (
+	fuzzOnce sync.Once
+
+	// fuzzSpecFile is where Fuzz writes the spec being parsed.
+	fuzzSpecFile *os.File
+
+	// fuzzConf is the configuration copied by each Fuzz call, since flag
+	// annotations modify it.
+	fuzzConf *config.Config
+)
+
+func fuzzInit() {
+	f, err := ioutil.TempFile("", "config.json")
+	if err != nil {
+		panic(err)
+	}
+	fuzzSpecFile = f
+
+	config.RegisterFlags()
+	conf, err := config.NewFromFlags()
+	if err != nil {
+		panic(err)
+	}
+	// Exercise flag annotations too.
+	conf.AllowFlagOverride = true
+	fuzzConf = conf
+}
+
+// Fuzz is the entry point for go-fuzz and libFuzzer. It parses data as the OCI
+// spec of a bundle, and interprets the parts of it that are used before the
+// sandbox starts.
+func Fuzz(data []byte) int {
+	fuzzOnce.Do(fuzzInit)
+	if err := fuzzSpecFile.Truncate(0); err != nil {
+		panic(err)
+	}
+	if _, err := fuzzSpecFile.WriteAt(data, 0); err != nil {
+		panic(err)
+	}
+
+	conf := *fuzzConf
+	spec, err := ReadSpecFromFile("/bundle", fuzzSpecFile, &conf)
+	if err != nil {
+		return 0
+	}
+
+	SpecContainerType(spec)
+	SandboxID(spec)
+	Capabilities(conf.EnableRaw, spec.Process.Capabilities)
+	ResolveEnvs(spec.Process.Env)
+	for _, m := range spec.Mounts {
+		ValidateMountOptions(m.Options)
+		OptionsToFlags(m.Options)
+		PropOptionsToFlags(m.Options)
+		IsGoferMount(m, conf.VFS2)
+		IsSupportedDevMount(m, conf.VFS2)
+		MaybeConvertToBindMount(&m)
+	}
+	return 1
+}
@@ -0,0 +1,48 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build gofuzz
+// +build gofuzz
+
+package specutils
+
+import "testing"
+
+// TestFuzz runs Fuzz on a seed corpus, so that the fuzzer is built and its
+// invariants checked by regular test runs.
+func TestFuzz(t *testing.T) {
+	for _, tc := range []struct {
+		data string
+		want int
+	}{
+		{
+			data: `{"ociVersion":"1.0.0","process":{"args":["/bin/true"],"env":["PATH=/bin"]},"root":{"path":"rootfs"},"mounts":[{"destination":"/tmp","type":"tmpfs","options":["rw","nosuid","rprivate"]}]}`,
+			want: 1,
+		},
+		{
+			data: `{"ociVersion":"1.0.0","process":{"args":["/bin/true"]},"root":{"path":"/"},"annotations":{"dev.gvisor.flag/debug":"true"}}`,
+			want: 1,
+		},
+		{data: `{}`, want: 0},
+		{data: `not json`, want: 0},
+		{
+			data: `{"ociVersion":"1.0.0","process":{"args":["/bin/true"]},"root":{"path":"/"},"annotations":{"dev.gvisor.flag/no-such-flag":"true"}}`,
+			want: 0,
+		},
+	} {
+		if got := Fuzz([]byte(tc.data)); got != tc.want {
+			t.Errorf("Fuzz(%q): got %d, want %d", tc.data, got, tc.want)
+		}
+	}
+}
@@ -193,15 +193,12 @@ func ReadSpecFromFile(bundleDir string, specFile *os.File, conf *config.Config)
 		}
 	}
 
-	// Override flags using annotation to allow customization per sandbox
-	// instance.
+	// Override flags using annotation to allow customization per container.
 	for annotation, val := range spec.Annotations {
-		const flagPrefix = "dev.gvisor.flag."
-		if strings.HasPrefix(annotation, flagPrefix) {
-			name := annotation[len(flagPrefix):]
+		if name, ok := flagFromAnnotation(annotation); ok {
 			log.Infof("Overriding flag: %s=%q", name, val)
 			if err := conf.Override(name, val); err != nil {
-				return nil, err
+				return nil, fmt.Errorf("annotation %q: %w", annotation, err)
 			}
 		}
 	}
@@ -209,6 +206,24 @@ func ReadSpecFromFile(bundleDir string, specFile *os.File, conf *config.Config)
 	return &spec, nil
 }
 
+// Prefixes of annotations that override flags. The first one follows the
+// syntax of Kubernetes annotations, the second one is kept for compatibility.
+const (
+	annotationFlagPrefix       = "dev.gvisor.flag/"
+	annotationFlagPrefixLegacy = "dev.gvisor.flag."
+)
+
+// flagFromAnnotation returns the name of the flag overridden by the
+// annotation, if any.
+func flagFromAnnotation(annotation string) (string, bool) {
+	for _, prefix := range []string{annotationFlagPrefix, annotationFlagPrefixLegacy} {
+		if strings.HasPrefix(annotation, prefix) {
+			return annotation[len(prefix):], true
+		}
+	}
+	return "", false
+}
+
 // ReadMounts reads mount list from a file.
 func ReadMounts(f *os.File) ([]specs.Mount, error) {
 	bytes, err := ioutil.ReadAll(f)
@@ -339,6 +354,18 @@ func IsGoferMount(m specs.Mount, vfs2Enabled bool) bool {
 	return m.Type == "bind" && m.Source != "" && IsSupportedDevMount(m, vfs2Enabled)
 }
 
+// OverlayUpperDir returns the host directory backing the writable layer of
+// the root overlay of container id, or an empty string if it's backed by
+// memory. Each container gets its own subdirectory of conf.OverlayUpperDir,
+// named after its ID. The Gofer serves it after the gofer mounts of the
+// container.
+func OverlayUpperDir(spec *specs.Spec, conf *config.Config, id string) string {
+	if !conf.Overlay || spec.Root.Readonly || conf.OverlayUpperDir == "" {
+		return ""
+	}
+	return filepath.Join(conf.OverlayUpperDir, id)
+}
+
 // MaybeConvertToBindMount converts mount type to "bind" in case any of the
 // mount options are either "bind" or "rbind" as required by the OCI spec.
 //
@@ -417,14 +444,16 @@ func WaitForReady(pid int, timeout time.Duration, ready func() (bool, error)) er
 //   - %TIMESTAMP%: is replaced with a timestamp using the following format:
 //			<yyyymmdd-hhmmss.uuuuuu>
 //	 - %COMMAND%: is replaced with 'command'
+//	 - %ID%: is replaced with 'id', the container ID (omitted by default)
 //	 - %TEST%: is replaced with 'test' (omitted by default)
-func DebugLogFile(logPattern, command, test string) (*os.File, error) {
+func DebugLogFile(logPattern, command, id, test string) (*os.File, error) {
 	if strings.HasSuffix(logPattern, "/") {
 		// Default format: <debug-log>/runsc.log.<yyyymmdd-hhmmss.uuuuuu>.<command>
 		logPattern += "runsc.log.%TIMESTAMP%.%COMMAND%"
 	}
 	logPattern = strings.Replace(logPattern, "%TIMESTAMP%", time.Now().Format("20060102-150405.000000"), -1)
 	logPattern = strings.Replace(logPattern, "%COMMAND%", command, -1)
+	logPattern = strings.Replace(logPattern, "%ID%", id, -1)
 	logPattern = strings.Replace(logPattern, "%TEST%", test, -1)
 
 	dir := filepath.Dir(logPattern)
@@ -544,6 +573,43 @@ func GetOOMScoreAdj(pid int) (int, error) {
 	return strconv.Atoi(strings.TrimSpace(string(data)))
 }
 
+// userHZ is the unit of the CPU times in /proc/[pid]/stat.
+const userHZ = 100
+
+// GetCPUTime returns the user and system CPU time used by the given process.
+func GetCPUTime(pid int) (user, sys time.Duration, err error) {
+	data, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0, 0, err
+	}
+	return parseStatCPUTime(string(data))
+}
+
+// parseStatCPUTime returns the user and system CPU time in the contents of a
+// /proc/[pid]/stat file.
+func parseStatCPUTime(stat string) (time.Duration, time.Duration, error) {
+	// The command name may contain spaces and parentheses.
+	i := strings.LastIndexByte(stat, ')')
+	if i < 0 {
+		return 0, 0, fmt.Errorf("invalid stat %q", stat)
+	}
+	// The fields following the command name start with the 3rd one, state.
+	// utime and stime are the 14th and 15th fields.
+	fields := strings.Fields(stat[i+1:])
+	if len(fields) < 13 {
+		return 0, 0, fmt.Errorf("invalid stat %q", stat)
+	}
+	utime, err := strconv.ParseUint(fields[11], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid utime %q: %v", fields[11], err)
+	}
+	stime, err := strconv.ParseUint(fields[12], 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid stime %q: %v", fields[12], err)
+	}
+	return time.Duration(utime) * time.Second / userHZ, time.Duration(stime) * time.Second / userHZ, nil
+}
+
 // EnvVar looks for a varible value in the env slice assuming the following
 // format: "NAME=VALUE".
 func EnvVar(env []string, name string) (string, bool) {
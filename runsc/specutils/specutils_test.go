@@ -17,11 +17,13 @@ package specutils
 import (
 	"fmt"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
 	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"gvisor.dev/gvisor/runsc/config"
 )
 
 func TestWaitForReadyHappy(t *testing.T) {
@@ -269,3 +271,99 @@ func TestSpecInvalid(t *testing.T) {
 		}
 	}
 }
+
+func TestDebugLogFile(t *testing.T) {
+	dir := t.TempDir()
+	f, err := DebugLogFile(filepath.Join(dir, "%ID%", "runsc.%COMMAND%.log"), "create", "abc", "")
+	if err != nil {
+		t.Fatalf("DebugLogFile() failed: %v", err)
+	}
+	defer f.Close()
+	if want := filepath.Join(dir, "abc", "runsc.create.log"); f.Name() != want {
+		t.Errorf("DebugLogFile() created %q, want %q", f.Name(), want)
+	}
+}
+
+func TestParseStatCPUTime(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		stat string
+		user time.Duration
+		sys  time.Duration
+	}{
+		{
+			name: "simple",
+			stat: "1234 (runsc-gofer) S 1 1234 1234 0 -1 4194560 2201 0 0 0 250 37 0 0 20 0 8 0 1865 1502093312 4562 18446744073709551615",
+			user: 2500 * time.Millisecond,
+			sys:  370 * time.Millisecond,
+		},
+		{
+			name: "name with spaces and parentheses",
+			stat: "1234 (a) b (c)) R 1 1234 1234 0 -1 4194560 2201 0 0 0 1 2 0 0 20 0 8 0 1865 1502093312 4562 18446744073709551615",
+			user: 10 * time.Millisecond,
+			sys:  20 * time.Millisecond,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			user, sys, err := parseStatCPUTime(tc.stat)
+			if err != nil {
+				t.Fatalf("parseStatCPUTime(%q) failed: %v", tc.stat, err)
+			}
+			if user != tc.user || sys != tc.sys {
+				t.Errorf("parseStatCPUTime(%q) = %v, %v, want %v, %v", tc.stat, user, sys, tc.user, tc.sys)
+			}
+		})
+	}
+
+	for _, stat := range []string{"", "1234 (name", "1234 (name) S 1 2 3"} {
+		if _, _, err := parseStatCPUTime(stat); err == nil {
+			t.Errorf("parseStatCPUTime(%q) succeeded, want error", stat)
+		}
+	}
+}
+
+func TestOverlayUpperDir(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		overlay  bool
+		upperDir string
+		readonly bool
+		want     string
+	}{
+		{
+			name:     "per-container",
+			overlay:  true,
+			upperDir: "/upper",
+			want:     "/upper/foo",
+		},
+		{
+			name:    "memory",
+			overlay: true,
+		},
+		{
+			name:     "no-overlay",
+			upperDir: "/upper",
+		},
+		{
+			name:     "ro-root",
+			overlay:  true,
+			upperDir: "/upper",
+			readonly: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			spec := &specs.Spec{Root: &specs.Root{Readonly: tc.readonly}}
+			conf := &config.Config{Overlay: tc.overlay, OverlayUpperDir: tc.upperDir}
+			if got := OverlayUpperDir(spec, conf, "foo"); got != tc.want {
+				t.Errorf("OverlayUpperDir() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+
+	// Containers never share a directory.
+	spec := &specs.Spec{Root: &specs.Root{}}
+	conf := &config.Config{Overlay: true, OverlayUpperDir: "/upper"}
+	if a, b := OverlayUpperDir(spec, conf, "a"), OverlayUpperDir(spec, conf, "b"); a == b {
+		t.Errorf("OverlayUpperDir() = %q for both containers", a)
+	}
+}
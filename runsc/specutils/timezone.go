@@ -0,0 +1,85 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package specutils
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// HostTimezone selects the host's timezone, from /etc/localtime.
+	HostTimezone = "host"
+
+	hostLocaltime = "/etc/localtime"
+	zoneinfoDir   = "/usr/share/zoneinfo"
+)
+
+// ResolveTimezone returns the POSIX TZ string (e.g. "CET-1CEST,M3.5.0,M10.5.0/3")
+// for the given zone, which is either HostTimezone or an IANA zone name (e.g.
+// "Europe/Berlin") that is looked up in the host's zoneinfo database. The TZ
+// string describes the zone's current rules and can be used by applications
+// without access to tzdata.
+func ResolveTimezone(zone string) (string, error) {
+	path := hostLocaltime
+	if zone != HostTimezone {
+		if zone == "" || filepath.IsAbs(zone) || strings.Contains(zone, "..") {
+			return "", fmt.Errorf("invalid timezone %q", zone)
+		}
+		path = filepath.Join(zoneinfoDir, zone)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading timezone %q: %v", zone, err)
+	}
+	tz, err := TZStringFromTZif(data)
+	if err != nil {
+		return "", fmt.Errorf("parsing timezone file %q: %v", path, err)
+	}
+	return tz, nil
+}
+
+// TZStringFromTZif returns the POSIX TZ string from the footer of TZif data,
+// as described in RFC 8536. The footer is only present in version 2 files and
+// later.
+func TZStringFromTZif(data []byte) (string, error) {
+	if len(data) < 5 || string(data[:4]) != "TZif" {
+		return "", fmt.Errorf("not a TZif file")
+	}
+	if data[4] < '2' {
+		return "", fmt.Errorf("TZif version 1 doesn't include a TZ string")
+	}
+	if data[len(data)-1] != '\n' {
+		return "", fmt.Errorf("missing TZ string footer")
+	}
+	footer := data[:len(data)-1]
+	i := bytes.LastIndexByte(footer, '\n')
+	if i < 0 {
+		return "", fmt.Errorf("missing TZ string footer")
+	}
+	tz := string(footer[i+1:])
+	if tz == "" {
+		return "", fmt.Errorf("timezone can't be represented by a TZ string")
+	}
+	for _, c := range tz {
+		if c < ' ' || c > '~' {
+			return "", fmt.Errorf("invalid TZ string %q", tz)
+		}
+	}
+	return tz, nil
+}
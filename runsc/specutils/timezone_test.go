@@ -0,0 +1,83 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package specutils
+
+import (
+	"testing"
+)
+
+func TestTZStringFromTZif(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		data    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "v2",
+			data: "TZif2\x00\x00data\nCET-1CEST,M3.5.0,M10.5.0/3\n",
+			want: "CET-1CEST,M3.5.0,M10.5.0/3",
+		},
+		{
+			name: "v3",
+			data: "TZif3\x00\x00data\n<+0330>-3:30\n",
+			want: "<+0330>-3:30",
+		},
+		{
+			name:    "v1",
+			data:    "TZif\x00\x00\x00data",
+			wantErr: true,
+		},
+		{
+			name:    "bad-magic",
+			data:    "TZfoo\nUTC0\n",
+			wantErr: true,
+		},
+		{
+			name:    "no-footer",
+			data:    "TZif2\x00\x00data",
+			wantErr: true,
+		},
+		{
+			name:    "empty-footer",
+			data:    "TZif2\x00\x00data\n\n",
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := TZStringFromTZif([]byte(tc.data))
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("TZStringFromTZif() should have failed, got: %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("TZStringFromTZif(): %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("TZStringFromTZif(), want: %q, got: %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestResolveTimezoneInvalid(t *testing.T) {
+	for _, zone := range []string{"", "/etc/passwd", "../../etc/passwd", "Europe/../../x"} {
+		if tz, err := ResolveTimezone(zone); err == nil {
+			t.Errorf("ResolveTimezone(%q) should have failed, got: %q", zone, tz)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tracing records spans of container operations, in runsc and in the
+// sandbox, and exports them to an OpenTelemetry collector.
+//
+// The sandbox can't reach the collector: its spans are collected by runsc over
+// the control socket, and exported with the spans of runsc. Spans are linked
+// across processes with W3C trace context, see ParseTraceParent.
+package tracing
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/pkg/rand"
+	"gvisor.dev/gvisor/pkg/sync"
+)
+
+// TraceParentEnv is the environment variable that runsc reads the parent of
+// its spans from, e.g. set by the container manager.
+const TraceParentEnv = "TRACEPARENT"
+
+// TraceID identifies a trace.
+type TraceID [16]byte
+
+// SpanID identifies a span in a trace.
+type SpanID [8]byte
+
+// SpanContext identifies a span across processes.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+}
+
+// IsValid returns true if sc identifies a span.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+// TraceParent returns sc as a W3C traceparent header value, or an empty string
+// if sc isn't valid.
+func (sc SpanContext) TraceParent() string {
+	if !sc.IsValid() {
+		return ""
+	}
+	return fmt.Sprintf("00-%x-%x-01", sc.TraceID[:], sc.SpanID[:])
+}
+
+// ParseTraceParent parses a W3C traceparent header value, as returned by
+// SpanContext.TraceParent. An empty string returns an invalid SpanContext.
+func ParseTraceParent(s string) (SpanContext, error) {
+	var sc SpanContext
+	if s == "" {
+		return sc, nil
+	}
+	parts := strings.Split(s, "-")
+	// Future versions may append fields.
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return sc, fmt.Errorf("invalid traceparent %q", s)
+	}
+	if len(parts[1]) != 2*len(sc.TraceID) || len(parts[2]) != 2*len(sc.SpanID) {
+		return sc, fmt.Errorf("invalid traceparent %q", s)
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, fmt.Errorf("invalid trace ID in traceparent %q: %v", s, err)
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, fmt.Errorf("invalid span ID in traceparent %q: %v", s, err)
+	}
+	if !sc.IsValid() {
+		return SpanContext{}, fmt.Errorf("invalid traceparent %q", s)
+	}
+	return sc, nil
+}
+
+// Span is an operation in a trace. Spans of the sandbox are sent to runsc,
+// hence the exported fields.
+type Span struct {
+	// Name is the name of the operation.
+	Name string
+
+	// Context identifies the span.
+	Context SpanContext
+
+	// ParentID is the ID of the parent span, or zero for the root span of
+	// a trace.
+	ParentID SpanID
+
+	// Start and End are the times the operation started and ended.
+	Start time.Time
+	End   time.Time
+
+	// Attributes describe the operation, e.g. the container ID.
+	Attributes map[string]string
+
+	// Error is the error the operation failed with, if any.
+	Error string
+}
+
+var (
+	// mu protects the fields below.
+	mu sync.Mutex
+
+	// enabled is set once spans are recorded.
+	enabled bool
+
+	// current is the span of the running runsc command, if any.
+	current *ActiveSpan
+
+	// ended are the spans ended, or added, since the last Collect.
+	ended []*Span
+)
+
+// maxEnded is the maximum number of spans kept until they are collected. The
+// sandbox doesn't record any spans once it's reached, e.g. if runsc doesn't
+// collect them.
+const maxEnded = 1024
+
+// Enable enables recording spans. Until it's called, spans aren't recorded.
+func Enable() {
+	mu.Lock()
+	defer mu.Unlock()
+	enabled = true
+}
+
+// ActiveSpan is a span that hasn't ended. Methods of a nil ActiveSpan, which is
+// returned when tracing isn't enabled, do nothing.
+type ActiveSpan struct {
+	span Span
+}
+
+// Start starts a span called name, child of parent. If parent isn't valid,
+// the span starts a new trace. It returns nil if tracing isn't enabled.
+func Start(parent SpanContext, name string) *ActiveSpan {
+	mu.Lock()
+	on := enabled
+	mu.Unlock()
+	if !on {
+		return nil
+	}
+
+	s := &ActiveSpan{
+		span: Span{
+			Name:       name,
+			Context:    parent,
+			ParentID:   parent.SpanID,
+			Start:      time.Now(),
+			Attributes: make(map[string]string),
+		},
+	}
+	if !parent.IsValid() {
+		s.span.ParentID = SpanID{}
+		randomID(s.span.Context.TraceID[:])
+	}
+	randomID(s.span.Context.SpanID[:])
+	return s
+}
+
+// StartChild starts a span called name, child of the span of the running
+// runsc command. See Start.
+func StartChild(name string) *ActiveSpan {
+	return Start(Current().Context(), name)
+}
+
+// randomID fills id with random bytes.
+func randomID(id []byte) {
+	if _, err := rand.Read(id); err != nil {
+		// IDs only need to be unique, the time is good enough.
+		log.Warningf("Error generating span ID: %v", err)
+		for i, b := 0, uint64(time.Now().UnixNano()); i < len(id); i, b = i+1, b>>8 {
+			id[i] = byte(b)
+		}
+	}
+}
+
+// Context returns the SpanContext of s, to start child spans.
+func (s *ActiveSpan) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.span.Context
+}
+
+// TraceParent returns the W3C traceparent of s, to start child spans in
+// another process, or an empty string if s is nil.
+func (s *ActiveSpan) TraceParent() string {
+	return s.Context().TraceParent()
+}
+
+// SetAttribute sets the attribute key of s to value.
+func (s *ActiveSpan) SetAttribute(key, value string) {
+	if s == nil {
+		return
+	}
+	s.span.Attributes[key] = value
+}
+
+// End ends s, with err if the operation failed. s must not be used afterwards.
+func (s *ActiveSpan) End(err error) {
+	if s == nil {
+		return
+	}
+	s.span.End = time.Now()
+	if err != nil {
+		s.span.Error = err.Error()
+	}
+	Add([]*Span{&s.span})
+}
+
+// SetCurrent sets the span of the running runsc command, used as the parent of
+// the spans of the operations it runs.
+func SetCurrent(s *ActiveSpan) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = s
+}
+
+// Current returns the span of the running runsc command, or nil.
+func Current() *ActiveSpan {
+	mu.Lock()
+	defer mu.Unlock()
+	return current
+}
+
+// Add adds ended spans, e.g. collected from the sandbox, to the spans returned
+// by Collect.
+func Add(spans []*Span) {
+	mu.Lock()
+	defer mu.Unlock()
+	if !enabled {
+		return
+	}
+	for _, s := range spans {
+		if len(ended) >= maxEnded {
+			log.Warningf("Too many spans not collected, dropping span %q", s.Name)
+			continue
+		}
+		ended = append(ended, s)
+	}
+}
+
+// Collect returns the spans ended since the last call.
+func Collect() []*Span {
+	mu.Lock()
+	defer mu.Unlock()
+	spans := ended
+	ended = nil
+	return spans
+}
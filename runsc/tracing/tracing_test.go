@@ -0,0 +1,182 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tracing
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTraceParent(t *testing.T) {
+	const tp = "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+	sc, err := ParseTraceParent(tp)
+	if err != nil {
+		t.Fatalf("ParseTraceParent(%q) failed: %v", tp, err)
+	}
+	if !sc.IsValid() {
+		t.Fatalf("ParseTraceParent(%q) returned an invalid context", tp)
+	}
+	if got := sc.TraceParent(); got != tp {
+		t.Errorf("TraceParent() = %q, want %q", got, tp)
+	}
+
+	if sc, err := ParseTraceParent(""); err != nil || sc.IsValid() {
+		t.Errorf("ParseTraceParent(\"\") = %+v, %v, want invalid context and no error", sc, err)
+	}
+
+	for _, tp := range []string{
+		"00-0af7651916cd43dd8448eb211c80319c",
+		"00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01-extra",
+		"ff-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+		"00-0af7651916cd43dd8448eb211c8031-b7ad6b7169203331-01",
+		"00-0af7651916cd43dd8448eb211c80319z-b7ad6b7169203331-01",
+		"00-00000000000000000000000000000000-b7ad6b7169203331-01",
+		"00-0af7651916cd43dd8448eb211c80319c-0000000000000000-01",
+	} {
+		if _, err := ParseTraceParent(tp); err == nil {
+			t.Errorf("ParseTraceParent(%q) succeeded, want error", tp)
+		}
+	}
+}
+
+// reset resets the global state of the package.
+func reset() {
+	mu.Lock()
+	defer mu.Unlock()
+	enabled = false
+	current = nil
+	ended = nil
+}
+
+func TestDisabled(t *testing.T) {
+	reset()
+	s := Start(SpanContext{}, "op")
+	if s != nil {
+		t.Fatalf("Start returned a span while tracing is disabled")
+	}
+	s.SetAttribute("key", "value")
+	s.End(fmt.Errorf("error"))
+	if tp := s.TraceParent(); tp != "" {
+		t.Errorf("TraceParent() = %q, want empty", tp)
+	}
+	if spans := Collect(); len(spans) != 0 {
+		t.Errorf("Collect() returned %d spans, want none", len(spans))
+	}
+}
+
+func TestSpans(t *testing.T) {
+	reset()
+	Enable()
+
+	root := Start(SpanContext{}, "root")
+	SetCurrent(root)
+	child := StartChild("child")
+	child.SetAttribute("container.id", "foo")
+	child.End(fmt.Errorf("failed"))
+
+	// A span started in another process from the traceparent of root.
+	parent, err := ParseTraceParent(root.TraceParent())
+	if err != nil {
+		t.Fatalf("ParseTraceParent failed: %v", err)
+	}
+	remote := Start(parent, "remote")
+	remote.End(nil)
+	root.End(nil)
+
+	spans := Collect()
+	if len(spans) != 3 {
+		t.Fatalf("Collect() returned %d spans, want 3", len(spans))
+	}
+	c, r, rt := spans[0], spans[1], spans[2]
+	if rt.ParentID != (SpanID{}) {
+		t.Errorf("root span has parent %x", rt.ParentID)
+	}
+	for _, s := range []*Span{c, r} {
+		if s.Context.TraceID != rt.Context.TraceID {
+			t.Errorf("span %q: trace ID %x, want %x", s.Name, s.Context.TraceID, rt.Context.TraceID)
+		}
+		if s.ParentID != rt.Context.SpanID {
+			t.Errorf("span %q: parent ID %x, want %x", s.Name, s.ParentID, rt.Context.SpanID)
+		}
+		if s.Context.SpanID == rt.Context.SpanID {
+			t.Errorf("span %q has the ID of its parent", s.Name)
+		}
+	}
+	if c.Attributes["container.id"] != "foo" || c.Error != "failed" {
+		t.Errorf("child span: got attributes %v, error %q", c.Attributes, c.Error)
+	}
+	if spans := Collect(); len(spans) != 0 {
+		t.Errorf("second Collect() returned %d spans, want none", len(spans))
+	}
+}
+
+func TestExport(t *testing.T) {
+	reset()
+	Enable()
+	s := Start(SpanContext{}, "runsc create")
+	s.SetAttribute("container.id", "foo")
+	s.End(fmt.Errorf("failed"))
+	spans := Collect()
+
+	var got otlpRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := json.Unmarshal(body, &got); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}))
+	defer srv.Close()
+
+	if err := Export(srv.URL, "runsc", spans); err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if len(got.ResourceSpans) != 1 || len(got.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("unexpected request: %+v", got)
+	}
+	if attrs := got.ResourceSpans[0].Resource.Attributes; len(attrs) != 1 || attrs[0].Value.StringValue != "runsc" {
+		t.Errorf("resource attributes: got %+v, want service.name=runsc", attrs)
+	}
+	otlpSpans := got.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(otlpSpans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(otlpSpans))
+	}
+	o := otlpSpans[0]
+	if o.Name != "runsc create" || o.TraceID != fmt.Sprintf("%x", spans[0].Context.TraceID[:]) || o.ParentSpanID != "" {
+		t.Errorf("unexpected span: %+v", o)
+	}
+	if o.Status.Code != otlpStatusError || o.Status.Message != "failed" {
+		t.Errorf("status: got %+v, want error %q", o.Status, "failed")
+	}
+	if len(o.Attributes) != 1 || o.Attributes[0].Key != "container.id" || o.Attributes[0].Value.StringValue != "foo" {
+		t.Errorf("attributes: got %+v, want container.id=foo", o.Attributes)
+	}
+
+	if err := Export(srv.URL+"/bad", "runsc", spans); err == nil {
+		t.Errorf("Export to a bad endpoint succeeded, want error")
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package forkexec_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"gvisor.dev/gvisor/pkg/test/dockerutil"
+	"gvisor.dev/gvisor/test/benchmarks/harness"
+	"gvisor.dev/gvisor/test/benchmarks/tools"
+)
+
+// BenchmarkForkExec measures the throughput of fork and exec inside a running
+// container, by having a shell run /bin/true b.N times.
+func BenchmarkForkExec(b *testing.B) {
+	machine, err := harness.GetMachine()
+	if err != nil {
+		b.Fatalf("failed to get machine: %v", err)
+	}
+	defer machine.CleanUp()
+
+	ctx := context.Background()
+	container := machine.GetContainer(ctx, b)
+	defer container.CleanUp(ctx)
+	if err := container.Spawn(ctx, dockerutil.RunOpts{
+		Image: "benchmarks/alpine",
+	}, "sleep", "1000"); err != nil {
+		b.Fatalf("failed to start container: %v", err)
+	}
+
+	script := fmt.Sprintf("i=0; while [ $i -lt %d ]; do /bin/true; i=$((i+1)); done", b.N)
+	b.ResetTimer()
+	start := time.Now()
+	if _, err := container.Exec(ctx, dockerutil.ExecOpts{}, "sh", "-c", script); err != nil {
+		b.Fatalf("exec failed: %v", err)
+	}
+	elapsed := time.Since(start)
+	b.StopTimer()
+	tools.ReportCustomMetric(b, float64(b.N)/elapsed.Seconds(), "fork_exec_rate", "per_second")
+}
+
+// TestMain is the main method for package forkexec.
+func TestMain(m *testing.M) {
+	harness.Init()
+	os.Exit(m.Run())
+}
@@ -0,0 +1,139 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/google/subcommands"
+	"gvisor.dev/gvisor/runsc/flag"
+)
+
+// httpCmd is an HTTP server that serves a fixed response, or, with --url, an
+// HTTP client that fetches a URL.
+type httpCmd struct {
+	// Server flags.
+	addr   string
+	status int
+	body   string
+	delay  time.Duration
+
+	// Client flags.
+	url        string
+	retries    int
+	retryDelay time.Duration
+	timeout    time.Duration
+	wantStatus int
+}
+
+// Name implements subcommands.Command.Name.
+func (*httpCmd) Name() string {
+	return "http"
+}
+
+// Synopsis implements subcommands.Command.Synopsys.
+func (*httpCmd) Synopsis() string {
+	return "runs an HTTP server that serves a fixed response, or an HTTP client if --url is set"
+}
+
+// Usage implements subcommands.Command.Usage.
+func (*httpCmd) Usage() string {
+	return "http [--addr=<addr>] [--status=<code>] [--body=<body>] | http --url=<url> [--retries=<n>]"
+}
+
+// SetFlags implements subcommands.Command.SetFlags.
+func (c *httpCmd) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&c.addr, "addr", ":8080", "address to listen on")
+	f.IntVar(&c.status, "status", http.StatusOK, "status code of responses")
+	f.StringVar(&c.body, "body", "hello", "body of responses")
+	f.DurationVar(&c.delay, "delay", 0, "time to wait before responding")
+
+	f.StringVar(&c.url, "url", "", "URL to fetch, enables client mode")
+	f.IntVar(&c.retries, "retries", 10, "number of times to retry failed requests, e.g. while the server starts")
+	f.DurationVar(&c.retryDelay, "retry-delay", time.Second, "time to wait between retries")
+	f.DurationVar(&c.timeout, "timeout", 10*time.Second, "timeout of each request")
+	f.IntVar(&c.wantStatus, "want-status", http.StatusOK, "expected status code, other codes are retried")
+}
+
+// Execute implements subcommands.Command.Execute.
+func (c *httpCmd) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if c.url != "" {
+		return c.client()
+	}
+	return c.server()
+}
+
+func (c *httpCmd) server() subcommands.ExitStatus {
+	l, err := net.Listen("tcp", c.addr)
+	if err != nil {
+		log.Fatalf("error listening on %q: %v", c.addr, err)
+	}
+	// Print the address, which includes the port when --addr=:0 is used, to
+	// let tests know that the server is ready.
+	fmt.Printf("Listening on %s\n", l.Addr())
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c.delay > 0 {
+			time.Sleep(c.delay)
+		}
+		w.WriteHeader(c.status)
+		fmt.Fprint(w, c.body)
+	})
+	if err := http.Serve(l, handler); err != nil {
+		log.Fatalf("error serving: %v", err)
+	}
+	return subcommands.ExitSuccess
+}
+
+func (c *httpCmd) client() subcommands.ExitStatus {
+	client := &http.Client{Timeout: c.timeout}
+	for i := 0; ; i++ {
+		body, err := c.fetch(client)
+		if err == nil {
+			os.Stdout.Write(body)
+			return subcommands.ExitSuccess
+		}
+		if i >= c.retries {
+			log.Fatalf("error fetching %q after %d attempts: %v", c.url, i+1, err)
+		}
+		log.Printf("error fetching %q, retrying: %v", c.url, err)
+		time.Sleep(c.retryDelay)
+	}
+}
+
+// fetch fetches c.url, returning an error if the response doesn't have the
+// expected status.
+func (c *httpCmd) fetch(client *http.Client) ([]byte, error) {
+	resp, err := client.Get(c.url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != c.wantStatus {
+		return nil, fmt.Errorf("got status %d, want %d", resp.StatusCode, c.wantStatus)
+	}
+	return body, nil
+}
@@ -0,0 +1,225 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/subcommands"
+	"golang.org/x/sys/unix"
+	"gvisor.dev/gvisor/runsc/flag"
+)
+
+// resources opens a set of resources and reports the resources held by the
+// process once they are ready, and then every time it receives SIGUSR1, so
+// that tests can check that operations on the container, e.g. exec or
+// checkpoint/restore, don't leak or lose resources.
+type resources struct {
+	files    int
+	pipes    int
+	sockets  int
+	mmaps    int
+	mmapSize int
+	output   string
+
+	// openFiles holds the temporary files, so that their finalizers don't
+	// close them while the process is running.
+	openFiles []*os.File
+}
+
+// resourceReport is the JSON report of the resources held by the process.
+type resourceReport struct {
+	// FDs maps open file descriptors to what they refer to, as reported by
+	// /proc/self/fd.
+	FDs map[int]string `json:"fds"`
+
+	// Maps are the memory mappings of the process, as reported by
+	// /proc/self/maps.
+	Maps []string `json:"maps"`
+
+	// Rlimits maps resource names to their soft and hard limits.
+	Rlimits map[string][2]uint64 `json:"rlimits"`
+}
+
+var rlimits = map[string]int{
+	"RLIMIT_AS":         unix.RLIMIT_AS,
+	"RLIMIT_CORE":       unix.RLIMIT_CORE,
+	"RLIMIT_CPU":        unix.RLIMIT_CPU,
+	"RLIMIT_DATA":       unix.RLIMIT_DATA,
+	"RLIMIT_FSIZE":      unix.RLIMIT_FSIZE,
+	"RLIMIT_LOCKS":      unix.RLIMIT_LOCKS,
+	"RLIMIT_MEMLOCK":    unix.RLIMIT_MEMLOCK,
+	"RLIMIT_MSGQUEUE":   unix.RLIMIT_MSGQUEUE,
+	"RLIMIT_NICE":       unix.RLIMIT_NICE,
+	"RLIMIT_NOFILE":     unix.RLIMIT_NOFILE,
+	"RLIMIT_NPROC":      unix.RLIMIT_NPROC,
+	"RLIMIT_RSS":        unix.RLIMIT_RSS,
+	"RLIMIT_RTPRIO":     unix.RLIMIT_RTPRIO,
+	"RLIMIT_SIGPENDING": unix.RLIMIT_SIGPENDING,
+	"RLIMIT_STACK":      unix.RLIMIT_STACK,
+}
+
+// Name implements subcommands.Command.Name.
+func (*resources) Name() string {
+	return "resources"
+}
+
+// Synopsis implements subcommands.Command.Synopsys.
+func (*resources) Synopsis() string {
+	return "opens resources and reports the fds, memory maps and rlimits of the process as JSON, again on every SIGUSR1"
+}
+
+// Usage implements subcommands.Command.Usage.
+func (*resources) Usage() string {
+	return "resources [--files=<n>] [--pipes=<n>] [--sockets=<n>] [--mmaps=<n>] [--output=<file>]"
+}
+
+// SetFlags implements subcommands.Command.SetFlags.
+func (r *resources) SetFlags(f *flag.FlagSet) {
+	f.IntVar(&r.files, "files", 0, "number of temporary files to open")
+	f.IntVar(&r.pipes, "pipes", 0, "number of pipes to create")
+	f.IntVar(&r.sockets, "sockets", 0, "number of UNIX socket pairs to create")
+	f.IntVar(&r.mmaps, "mmaps", 0, "number of anonymous memory mappings to create")
+	f.IntVar(&r.mmapSize, "mmap-size", os.Getpagesize(), "size of each memory mapping")
+	f.StringVar(&r.output, "output", "", "file to write reports to, stdout if empty. Each report replaces the previous one")
+}
+
+// Execute implements subcommands.Command.Execute.
+func (r *resources) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	// Setup signal handling before opening resources, so that tests can
+	// signal the process as soon as the resources are ready.
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, unix.SIGUSR1)
+
+	dir, err := ioutil.TempDir("", "resources")
+	if err != nil {
+		log.Fatalf("TempDir failed: %v", err)
+	}
+	for i := 0; i < r.files; i++ {
+		file, err := os.Create(filepath.Join(dir, strconv.Itoa(i)))
+		if err != nil {
+			log.Fatalf("error creating file: %v", err)
+		}
+		r.openFiles = append(r.openFiles, file)
+	}
+	for i := 0; i < r.pipes; i++ {
+		var fds [2]int
+		if err := unix.Pipe2(fds[:], unix.O_CLOEXEC); err != nil {
+			log.Fatalf("error creating pipe: %v", err)
+		}
+	}
+	for i := 0; i < r.sockets; i++ {
+		if _, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM|unix.SOCK_CLOEXEC, 0); err != nil {
+			log.Fatalf("error creating socket pair: %v", err)
+		}
+	}
+	for i := 0; i < r.mmaps; i++ {
+		if _, err := unix.Mmap(-1, 0, r.mmapSize, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_PRIVATE|unix.MAP_ANONYMOUS); err != nil {
+			log.Fatalf("error creating memory mapping: %v", err)
+		}
+	}
+
+	// Report once the resources are ready, and then every time SIGUSR1 is
+	// received.
+	for {
+		report, err := newResourceReport()
+		if err != nil {
+			log.Fatalf("error collecting resources: %v", err)
+		}
+		if err := r.write(report); err != nil {
+			log.Fatalf("error writing report: %v", err)
+		}
+		<-ch
+	}
+}
+
+// write writes the report to the output file, or to stdout.
+func (r *resources) write(report *resourceReport) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if r.output == "" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+	// Write atomically, so that readers never see a partial report.
+	tmp := r.output + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, r.output)
+}
+
+func newResourceReport() (*resourceReport, error) {
+	report := &resourceReport{
+		FDs:     make(map[int]string),
+		Rlimits: make(map[string][2]uint64),
+	}
+
+	// Open the directory before listing it, and skip the FD used to do so.
+	fdDir, err := os.Open("/proc/self/fd")
+	if err != nil {
+		return nil, err
+	}
+	defer fdDir.Close()
+	names, err := fdDir.Readdirnames(-1)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		fd, err := strconv.Atoi(name)
+		if err != nil || fd == int(fdDir.Fd()) {
+			continue
+		}
+		target, err := os.Readlink(filepath.Join("/proc/self/fd", name))
+		if err != nil {
+			// The FD may have been closed concurrently, e.g. by the Go
+			// runtime.
+			continue
+		}
+		report.FDs[fd] = target
+	}
+
+	maps, err := ioutil.ReadFile("/proc/self/maps")
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(maps), "\n") {
+		if line != "" {
+			report.Maps = append(report.Maps, line)
+		}
+	}
+	sort.Strings(report.Maps)
+
+	for name, resource := range rlimits {
+		var rlim unix.Rlimit
+		if err := unix.Getrlimit(resource, &rlim); err != nil {
+			return nil, err
+		}
+		report.Rlimits[name] = [2]uint64{rlim.Cur, rlim.Max}
+	}
+	return report, nil
+}
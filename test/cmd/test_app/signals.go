@@ -0,0 +1,325 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	sys "syscall"
+	"time"
+
+	"github.com/google/subcommands"
+	"golang.org/x/sys/unix"
+	"gvisor.dev/gvisor/runsc/flag"
+)
+
+// maxSignal is the highest signal number on Linux.
+const maxSignal = 64
+
+// signalEcho installs handlers for all catchable signals and appends the
+// number of each signal received to a file, so that tests can check which
+// signals are delivered to the process and in which order.
+type signalEcho struct {
+	output string
+	exitOn int
+}
+
+// Name implements subcommands.Command.Name.
+func (*signalEcho) Name() string {
+	return "signal-echo"
+}
+
+// Synopsis implements subcommands.Command.Synopsys.
+func (*signalEcho) Synopsis() string {
+	return "reports all signals received to a file, one signal number per line"
+}
+
+// Usage implements subcommands.Command.Usage.
+func (*signalEcho) Usage() string {
+	return "signal-echo --output=<file> [--exit-on=<signo>]"
+}
+
+// SetFlags implements subcommands.Command.SetFlags.
+func (s *signalEcho) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&s.output, "output", "", "file to append received signals to. It's created once handlers are installed")
+	f.IntVar(&s.exitOn, "exit-on", 0, "signal number that makes the process exit after reporting it, 0 to run forever")
+}
+
+// Execute implements subcommands.Command.Execute.
+func (s *signalEcho) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	if s.output == "" {
+		log.Fatalf("--output is required")
+	}
+
+	// All signals except SIGKILL and SIGSTOP can be caught. SIGURG is skipped
+	// because the Go runtime uses it for preemption, which would add
+	// spurious deliveries.
+	var sigs []os.Signal
+	for i := 1; i <= maxSignal; i++ {
+		switch sys.Signal(i) {
+		case unix.SIGKILL, unix.SIGSTOP, unix.SIGURG:
+			continue
+		}
+		sigs = append(sigs, sys.Signal(i))
+	}
+	ch := make(chan os.Signal, maxSignal)
+	signal.Notify(ch, sigs...)
+
+	// Create the file only now, so that tests can wait for it to exist before
+	// sending signals.
+	out, err := os.OpenFile(s.output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC|os.O_APPEND, 0644)
+	if err != nil {
+		log.Fatalf("error opening output: %v", err)
+	}
+	defer out.Close()
+
+	for sig := range ch {
+		signo := int(sig.(sys.Signal))
+		if _, err := fmt.Fprintf(out, "%d\n", signo); err != nil {
+			log.Fatalf("error writing output: %v", err)
+		}
+		if signo == s.exitOn {
+			return subcommands.ExitSuccess
+		}
+	}
+	panic("unreachable")
+}
+
+// pdeathsig checks parent death signal and orphan reparenting semantics. It
+// starts a middle process, which starts a child with PR_SET_PDEATHSIG and then
+// exits. The child reports the signal it receives and its parent before and
+// after the middle process exits. The report is printed as JSON, and the
+// command fails if the results aren't the expected ones.
+type pdeathsig struct {
+	role      string
+	signal    int
+	subreaper bool
+	timeout   time.Duration
+}
+
+// pdeathsigReport is the JSON report of the pdeathsig command.
+type pdeathsigReport struct {
+	// MiddlePID is the PID of the middle process.
+	MiddlePID int `json:"middle_pid"`
+
+	// ChildPID is the PID of the child.
+	ChildPID int `json:"child_pid"`
+
+	// ParentBefore is the parent of the child before the middle process
+	// exited, which should be the middle process.
+	ParentBefore int `json:"parent_before"`
+
+	// Signal is the signal the child received when the middle process exited.
+	Signal int `json:"signal"`
+
+	// ParentAfter is the parent of the child after the middle process exited.
+	ParentAfter int `json:"parent_after"`
+
+	// WantParentAfter is the expected value of ParentAfter: the command's
+	// process if it's a subreaper or init, otherwise init.
+	WantParentAfter int `json:"want_parent_after"`
+
+	// Reaped is true if the command reaped the child.
+	Reaped bool `json:"reaped"`
+}
+
+// Name implements subcommands.Command.Name.
+func (*pdeathsig) Name() string {
+	return "pdeathsig"
+}
+
+// Synopsis implements subcommands.Command.Synopsys.
+func (*pdeathsig) Synopsis() string {
+	return "checks that a child gets its parent death signal and is reparented when its parent exits"
+}
+
+// Usage implements subcommands.Command.Usage.
+func (*pdeathsig) Usage() string {
+	return "pdeathsig [--signal=<signo>] [--subreaper]"
+}
+
+// SetFlags implements subcommands.Command.SetFlags.
+func (p *pdeathsig) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&p.role, "role", "", "internal: role of the process, middle or child")
+	f.IntVar(&p.signal, "signal", int(unix.SIGUSR1), "parent death signal of the child")
+	f.BoolVar(&p.subreaper, "subreaper", false, "make the command a child subreaper, so that the child is reparented to it")
+	f.DurationVar(&p.timeout, "timeout", 10*time.Second, "how long the child waits for the signal")
+}
+
+// Execute implements subcommands.Command.Execute.
+func (p *pdeathsig) Execute(ctx context.Context, f *flag.FlagSet, args ...interface{}) subcommands.ExitStatus {
+	switch p.role {
+	case "":
+		return p.runParent()
+	case "middle":
+		return p.runMiddle()
+	case "child":
+		return p.runChild()
+	default:
+		log.Fatalf("invalid role %q", p.role)
+	}
+	panic("unreachable")
+}
+
+// command returns the command that runs the given role.
+func (p *pdeathsig) command(role string) *exec.Cmd {
+	cmd := exec.Command("/proc/self/exe", "pdeathsig",
+		"--role="+role,
+		"--signal="+strconv.Itoa(p.signal),
+		"--timeout="+p.timeout.String())
+	cmd.Stderr = os.Stderr
+	return cmd
+}
+
+// runParent starts the middle process and collects the report from the child.
+// The child writes its report to fd 3, which is the write end of a pipe that
+// is closed when the child exits.
+func (p *pdeathsig) runParent() subcommands.ExitStatus {
+	if p.subreaper {
+		if err := unix.Prctl(unix.PR_SET_CHILD_SUBREAPER, 1, 0, 0, 0); err != nil {
+			log.Fatalf("PR_SET_CHILD_SUBREAPER failed: %v", err)
+		}
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		log.Fatalf("error creating pipe: %v", err)
+	}
+	middle := p.command("middle")
+	middle.ExtraFiles = []*os.File{w}
+	if err := middle.Start(); err != nil {
+		log.Fatalf("error starting middle process: %v", err)
+	}
+	w.Close()
+	if err := middle.Wait(); err != nil {
+		log.Fatalf("middle process failed: %v", err)
+	}
+
+	report := pdeathsigReport{MiddlePID: middle.Process.Pid}
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) != 2 {
+			log.Fatalf("invalid line from child: %q", s.Text())
+		}
+		val, err := strconv.Atoi(fields[1])
+		if err != nil {
+			log.Fatalf("invalid line from child: %q", s.Text())
+		}
+		switch fields[0] {
+		case "pid":
+			report.ChildPID = val
+		case "ppid-before":
+			report.ParentBefore = val
+		case "signal":
+			report.Signal = val
+		case "ppid-after":
+			report.ParentAfter = val
+		}
+	}
+
+	report.WantParentAfter = 1
+	if p.subreaper || os.Getpid() == 1 {
+		report.WantParentAfter = os.Getpid()
+		var ws unix.WaitStatus
+		if _, err := unix.Wait4(report.ChildPID, &ws, 0, nil); err == nil {
+			report.Reaped = true
+		}
+	}
+
+	b, err := json.Marshal(&report)
+	if err != nil {
+		log.Fatalf("json.Marshal failed: %v", err)
+	}
+	fmt.Println(string(b))
+
+	if report.ParentBefore != report.MiddlePID {
+		log.Printf("child's parent was %d, want %d", report.ParentBefore, report.MiddlePID)
+		return subcommands.ExitFailure
+	}
+	if report.Signal != p.signal {
+		log.Printf("child got signal %d, want %d", report.Signal, p.signal)
+		return subcommands.ExitFailure
+	}
+	if report.ParentAfter != report.WantParentAfter {
+		log.Printf("child was reparented to %d, want %d", report.ParentAfter, report.WantParentAfter)
+		return subcommands.ExitFailure
+	}
+	if report.WantParentAfter == os.Getpid() && !report.Reaped {
+		log.Printf("child couldn't be reaped after being reparented")
+		return subcommands.ExitFailure
+	}
+	return subcommands.ExitSuccess
+}
+
+// runMiddle starts the child with the parent death signal set, waits for it
+// to be ready, and exits.
+func (p *pdeathsig) runMiddle() subcommands.ExitStatus {
+	report := os.NewFile(3, "report")
+	r, w, err := os.Pipe()
+	if err != nil {
+		log.Fatalf("error creating pipe: %v", err)
+	}
+	child := p.command("child")
+	child.ExtraFiles = []*os.File{report, w}
+	child.SysProcAttr = &sys.SysProcAttr{Pdeathsig: sys.Signal(p.signal)}
+	if err := child.Start(); err != nil {
+		log.Fatalf("error starting child: %v", err)
+	}
+	w.Close()
+	// The child closes the pipe once it's ready.
+	if _, err := ioutil.ReadAll(r); err != nil {
+		log.Fatalf("error waiting for child: %v", err)
+	}
+	return subcommands.ExitSuccess
+}
+
+// runChild reports its parent, waits for the parent death signal, and reports
+// the signal and its new parent.
+func (p *pdeathsig) runChild() subcommands.ExitStatus {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sys.Signal(p.signal))
+
+	report := os.NewFile(3, "report")
+	before := os.Getppid()
+	fmt.Fprintf(report, "pid %d\n", os.Getpid())
+	fmt.Fprintf(report, "ppid-before %d\n", before)
+	// Let the middle process exit.
+	os.NewFile(4, "ready").Close()
+
+	select {
+	case sig := <-ch:
+		fmt.Fprintf(report, "signal %d\n", int(sig.(sys.Signal)))
+	case <-time.After(p.timeout):
+		log.Printf("timed out waiting for signal %d", p.signal)
+	}
+	// The signal may be sent before the child is reparented, wait for the
+	// reparenting to be visible.
+	ppid := os.Getppid()
+	for deadline := time.Now().Add(p.timeout); ppid == before && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+		ppid = os.Getppid()
+	}
+	fmt.Fprintf(report, "ppid-after %d\n", ppid)
+	return subcommands.ExitSuccess
+}
@@ -0,0 +1,101 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Binary fd exercises syscalls operating on file descriptors that aren't
+// backed by a filesystem, e.g. pipes and eventfds, for differential testing.
+package main
+
+import (
+	"golang.org/x/sys/unix"
+	"gvisor.dev/gvisor/test/difftest/probe"
+)
+
+// badFD is a file descriptor that isn't open.
+const badFD = 1 << 20
+
+func main() {
+	// pipe2(2).
+	var p [2]int
+	probe.Err("pipe2", "O_CLOEXEC", unix.Pipe2(p[:], unix.O_CLOEXEC))
+	probe.Err("pipe2", "invalid flags", unix.Pipe2(make([]int, 2), 0x1))
+	flags, err := unix.FcntlInt(uintptr(p[0]), unix.F_GETFD, 0)
+	probe.Call("fcntl", "F_GETFD on O_CLOEXEC pipe", uintptr(flags), probe.Errno(err))
+	flags, err = unix.FcntlInt(uintptr(p[1]), unix.F_GETFL, 0)
+	probe.Call("fcntl", "F_GETFL on pipe write end", uintptr(flags), probe.Errno(err))
+	size, err := unix.FcntlInt(uintptr(p[0]), unix.F_GETPIPE_SZ, 0)
+	probe.Call("fcntl", "F_GETPIPE_SZ", uintptr(size), probe.Errno(err))
+	_, err = unix.FcntlInt(uintptr(p[0]), unix.F_SETPIPE_SZ, 0)
+	probe.Err("fcntl", "F_SETPIPE_SZ to 0", err)
+	_, err = unix.FcntlInt(badFD, unix.F_GETFD, 0)
+	probe.Err("fcntl", "bad fd", err)
+
+	// read(2) and write(2) on pipes.
+	n, err := unix.Write(p[0], []byte("x"))
+	probe.Call("write", "pipe read end", uintptr(n), probe.Errno(err))
+	n, err = unix.Write(p[1], []byte("hello"))
+	probe.Call("write", "pipe", uintptr(n), probe.Errno(err))
+	buf := make([]byte, 16)
+	n, err = unix.Read(p[0], buf)
+	probe.Call("read", "pipe", uintptr(n), probe.Errno(err))
+	probe.Observe("read", "pipe data", string(buf[:n]))
+	probe.Err("fcntl", "set O_NONBLOCK", setNonblock(p[0]))
+	_, err = unix.Read(p[0], buf)
+	probe.Err("read", "empty nonblocking pipe", err)
+	_, err = unix.Seek(p[0], 0, unix.SEEK_SET)
+	probe.Err("lseek", "pipe", err)
+
+	// dup(2), dup3(2).
+	fd, err := unix.Dup(p[0])
+	probe.Err("dup", "pipe", err)
+	flags, err = unix.FcntlInt(uintptr(fd), unix.F_GETFD, 0)
+	probe.Call("fcntl", "F_GETFD on dup of O_CLOEXEC fd", uintptr(flags), probe.Errno(err))
+	probe.Err("dup3", "same fd", unix.Dup3(fd, fd, 0))
+	probe.Err("dup3", "invalid flags", unix.Dup3(p[0], fd, 0x1))
+	_, err = unix.Dup(badFD)
+	probe.Err("dup", "bad fd", err)
+	probe.Err("close", "dup", unix.Close(fd))
+
+	// Closing the read end makes writes fail with EPIPE. SIGPIPE is ignored
+	// by the Go runtime for non-stdio fds.
+	probe.Err("close", "pipe read end", unix.Close(p[0]))
+	_, err = unix.Write(p[1], []byte("x"))
+	probe.Err("write", "pipe without readers", err)
+	probe.Err("close", "pipe write end", unix.Close(p[1]))
+
+	// eventfd2(2).
+	efd, err := unix.Eventfd(3, unix.EFD_NONBLOCK)
+	probe.Err("eventfd2", "initial value 3", err)
+	n, err = unix.Read(efd, buf[:4])
+	probe.Call("read", "eventfd short buffer", uintptr(n), probe.Errno(err))
+	n, err = unix.Read(efd, buf[:8])
+	probe.Call("read", "eventfd", uintptr(n), probe.Errno(err))
+	probe.Observe("read", "eventfd value", buf[0])
+	_, err = unix.Read(efd, buf[:8])
+	probe.Err("read", "eventfd zero nonblocking", err)
+	n, err = unix.Write(efd, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+	probe.Call("write", "eventfd max value", uintptr(n), probe.Errno(err))
+	_, err = unix.Eventfd(0, 0x1)
+	probe.Err("eventfd2", "invalid flags", err)
+	probe.Err("close", "eventfd", unix.Close(efd))
+}
+
+// setNonblock sets O_NONBLOCK on fd.
+func setNonblock(fd int) error {
+	flags, err := unix.FcntlInt(uintptr(fd), unix.F_GETFL, 0)
+	if err != nil {
+		return err
+	}
+	_, err = unix.FcntlInt(uintptr(fd), unix.F_SETFL, flags|unix.O_NONBLOCK)
+	return err
+}
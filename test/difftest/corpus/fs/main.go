@@ -0,0 +1,97 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Binary fs exercises filesystem syscalls for differential testing.
+package main
+
+import (
+	"path/filepath"
+
+	"golang.org/x/sys/unix"
+	"gvisor.dev/gvisor/test/difftest/probe"
+)
+
+func main() {
+	dir, cleanup := probe.TempDir()
+	defer cleanup()
+
+	file := filepath.Join(dir, "file")
+	missing := filepath.Join(dir, "missing")
+	sub := filepath.Join(dir, "sub")
+
+	// open(2).
+	fd, err := unix.Open(file, unix.O_RDWR|unix.O_CREAT|unix.O_EXCL, 0644)
+	probe.Err("open", "O_CREAT|O_EXCL new file", err)
+	_, err = unix.Open(file, unix.O_RDWR|unix.O_CREAT|unix.O_EXCL, 0644)
+	probe.Err("open", "O_CREAT|O_EXCL existing file", err)
+	_, err = unix.Open(missing, unix.O_RDONLY, 0)
+	probe.Err("open", "nonexistent file", err)
+	_, err = unix.Open(file, unix.O_RDONLY|unix.O_DIRECTORY, 0)
+	probe.Err("open", "O_DIRECTORY on file", err)
+	_, err = unix.Open(filepath.Join(file, "x"), unix.O_RDONLY, 0)
+	probe.Err("open", "file as directory", err)
+
+	// write(2), lseek(2), read(2).
+	n, err := unix.Write(fd, []byte("hello world"))
+	probe.Call("write", "11 bytes", uintptr(n), probe.Errno(err))
+	off, err := unix.Seek(fd, 0, unix.SEEK_END)
+	probe.Call("lseek", "SEEK_END", uintptr(off), probe.Errno(err))
+	_, err = unix.Seek(fd, -100, unix.SEEK_SET)
+	probe.Err("lseek", "negative offset", err)
+	off, err = unix.Seek(fd, 100, unix.SEEK_SET)
+	probe.Call("lseek", "past EOF", uintptr(off), probe.Errno(err))
+	buf := make([]byte, 32)
+	n, err = unix.Read(fd, buf)
+	probe.Call("read", "past EOF", uintptr(n), probe.Errno(err))
+	n, err = unix.Pread(fd, buf, 6)
+	probe.Call("pread64", "middle", uintptr(n), probe.Errno(err))
+	probe.Observe("pread64", "middle data", string(buf[:n]))
+	_, err = unix.Pread(fd, buf, -1)
+	probe.Err("pread64", "negative offset", err)
+
+	// ftruncate(2), fstat(2).
+	probe.Err("ftruncate", "shrink", unix.Ftruncate(fd, 5))
+	var st unix.Stat_t
+	probe.Err("fstat", "after ftruncate", unix.Fstat(fd, &st))
+	probe.Observe("fstat", "size after ftruncate", st.Size)
+	probe.Observe("fstat", "mode after ftruncate", st.Mode&unix.S_IFMT)
+	probe.Err("ftruncate", "negative length", unix.Ftruncate(fd, -1))
+	probe.Err("close", "file", unix.Close(fd))
+	probe.Err("close", "closed fd", unix.Close(fd))
+
+	// mkdir(2), rmdir(2), rename(2), unlink(2).
+	probe.Err("mkdir", "new", unix.Mkdir(sub, 0755))
+	probe.Err("mkdir", "existing", unix.Mkdir(sub, 0755))
+	probe.Err("mkdir", "missing parent", unix.Mkdir(filepath.Join(missing, "x"), 0755))
+	probe.Err("rename", "file over directory", unix.Rename(file, sub))
+	probe.Err("rename", "directory into itself", unix.Rename(sub, filepath.Join(sub, "x")))
+	probe.Err("rename", "missing source", unix.Rename(missing, file))
+	probe.Err("unlink", "directory", unix.Unlink(sub))
+	probe.Err("rmdir", "file", unix.Rmdir(file))
+	probe.Err("rmdir", "non-empty", unix.Rmdir(dir))
+	probe.Err("rmdir", "empty", unix.Rmdir(sub))
+	probe.Err("unlink", "file", unix.Unlink(file))
+	probe.Err("unlink", "missing", unix.Unlink(file))
+
+	// symlink(2), readlink(2).
+	link := filepath.Join(dir, "link")
+	probe.Err("symlink", "dangling", unix.Symlink("target", link))
+	n, err = unix.Readlink(link, buf)
+	probe.Call("readlink", "dangling", uintptr(n), probe.Errno(err))
+	probe.Observe("readlink", "dangling target", string(buf[:n]))
+	_, err = unix.Readlink(dir, buf)
+	probe.Err("readlink", "directory", err)
+	_, err = unix.Open(link, unix.O_RDONLY|unix.O_NOFOLLOW, 0)
+	probe.Err("open", "O_NOFOLLOW on symlink", err)
+}
@@ -0,0 +1,226 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package difftest compares the syscall results of programs run on the host
+// and in the sandbox.
+//
+// Test programs record the results of the syscalls they make using package
+// probe. The runner runs each program on the host and in runsc, and the
+// records of both runs are compared here to build a compatibility report per
+// syscall. Unlike the syscall tests, which assert the expected behavior, this
+// takes the host as the reference, so that differences are found even where
+// nobody wrote an assertion.
+package difftest
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Record is the result of a single syscall, or a side effect observed after
+// it.
+type Record struct {
+	// Syscall is the name of the syscall.
+	Syscall string `json:"syscall"`
+
+	// Desc identifies the call among the calls to the same syscall made by
+	// the program.
+	Desc string `json:"desc,omitempty"`
+
+	// Ret is the return value, if Errno is 0.
+	Ret int64 `json:"ret,omitempty"`
+
+	// Errno is the error number returned, or 0.
+	Errno int `json:"errno,omitempty"`
+
+	// Observed is the side effect observed, for records created with
+	// probe.Observe.
+	Observed string `json:"observed,omitempty"`
+}
+
+// key identifies the record among the records of a run.
+func (r *Record) key() string {
+	return r.Syscall + "(" + r.Desc + ")"
+}
+
+// String implements fmt.Stringer.
+func (r *Record) String() string {
+	switch {
+	case r.Observed != "":
+		return fmt.Sprintf("%s observed %q", r.key(), r.Observed)
+	case r.Errno != 0:
+		return fmt.Sprintf("%s = errno %d", r.key(), r.Errno)
+	default:
+		return fmt.Sprintf("%s = %d", r.key(), r.Ret)
+	}
+}
+
+// Parse reads the records written by a test program. Lines that aren't records
+// are skipped, so that programs can print diagnostics.
+func Parse(r io.Reader) ([]Record, error) {
+	var records []Record
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if !strings.HasPrefix(line, "{") {
+			continue
+		}
+		var rec Record
+		if err := json.Unmarshal([]byte(line), &rec); err != nil || rec.Syscall == "" {
+			continue
+		}
+		records = append(records, rec)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// Mismatch is a difference between the host and the sandbox.
+type Mismatch struct {
+	// Program is the test program that made the syscall.
+	Program string `json:"program"`
+
+	// Native and Sandbox are the records from the host and the sandbox. One
+	// of them is nil if the syscall was only recorded in one run, e.g.
+	// because the program stopped early.
+	Native  *Record `json:"native,omitempty"`
+	Sandbox *Record `json:"sandbox,omitempty"`
+}
+
+// Syscall returns the syscall the mismatch is for.
+func (m *Mismatch) Syscall() string {
+	if m.Native != nil {
+		return m.Native.Syscall
+	}
+	return m.Sandbox.Syscall
+}
+
+// String implements fmt.Stringer.
+func (m *Mismatch) String() string {
+	switch {
+	case m.Sandbox == nil:
+		return fmt.Sprintf("%s: %v only on the host", m.Program, m.Native)
+	case m.Native == nil:
+		return fmt.Sprintf("%s: %v only in the sandbox", m.Program, m.Sandbox)
+	default:
+		return fmt.Sprintf("%s: host %v, sandbox %v", m.Program, m.Native, m.Sandbox)
+	}
+}
+
+// Compare returns the differences between the records of the runs of program
+// on the host and in the sandbox. Records are matched by syscall and
+// description, in order, so that an extra or missing record doesn't cause all
+// the following ones to mismatch.
+func Compare(program string, native, sandbox []Record) []Mismatch {
+	sandboxByKey := make(map[string][]int)
+	for i := range sandbox {
+		k := sandbox[i].key()
+		sandboxByKey[k] = append(sandboxByKey[k], i)
+	}
+
+	var mismatches []Mismatch
+	matched := make([]bool, len(sandbox))
+	for i := range native {
+		n := &native[i]
+		k := n.key()
+		if len(sandboxByKey[k]) == 0 {
+			mismatches = append(mismatches, Mismatch{Program: program, Native: n})
+			continue
+		}
+		j := sandboxByKey[k][0]
+		sandboxByKey[k] = sandboxByKey[k][1:]
+		matched[j] = true
+		if s := &sandbox[j]; *n != *s {
+			mismatches = append(mismatches, Mismatch{Program: program, Native: n, Sandbox: s})
+		}
+	}
+	for j := range sandbox {
+		if !matched[j] {
+			mismatches = append(mismatches, Mismatch{Program: program, Sandbox: &sandbox[j]})
+		}
+	}
+	return mismatches
+}
+
+// SyscallReport is the compatibility report for a syscall.
+type SyscallReport struct {
+	// Calls is the number of results recorded on the host.
+	Calls int `json:"calls"`
+
+	// Mismatches are the differences found.
+	Mismatches []Mismatch `json:"mismatches,omitempty"`
+}
+
+// Report is a compatibility report, keyed by syscall.
+type Report map[string]*SyscallReport
+
+// Add adds the results of a program to the report.
+func (r Report) Add(program string, native, sandbox []Record) {
+	for _, n := range native {
+		r.syscall(n.Syscall).Calls++
+	}
+	for _, m := range Compare(program, native, sandbox) {
+		s := r.syscall(m.Syscall())
+		s.Mismatches = append(s.Mismatches, m)
+	}
+}
+
+func (r Report) syscall(name string) *SyscallReport {
+	s, ok := r[name]
+	if !ok {
+		s = &SyscallReport{}
+		r[name] = s
+	}
+	return s
+}
+
+// Mismatches returns the total number of mismatches.
+func (r Report) Mismatches() int {
+	total := 0
+	for _, s := range r {
+		total += len(s.Mismatches)
+	}
+	return total
+}
+
+// WriteText writes the report in a human-readable format, with a summary line
+// per syscall followed by the mismatches found.
+func (r Report) WriteText(w io.Writer) error {
+	names := make([]string, 0, len(r))
+	for name := range r {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "%-24s %8s %10s\n", "SYSCALL", "CALLS", "MISMATCHES")
+	for _, name := range names {
+		s := r[name]
+		fmt.Fprintf(bw, "%-24s %8d %10d\n", name, s.Calls, len(s.Mismatches))
+	}
+	for _, name := range names {
+		for _, m := range r[name].Mismatches {
+			fmt.Fprintf(bw, "\n%v", &m)
+		}
+	}
+	fmt.Fprintln(bw)
+	return bw.Flush()
+}
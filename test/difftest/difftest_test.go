@@ -0,0 +1,118 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package difftest
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	out := `starting
+{"syscall":"open","desc":"missing","errno":2}
+{"syscall":"write","desc":"pipe","ret":5}
+{not json}
+{"desc":"no syscall"}
+`
+	got, err := Parse(strings.NewReader(out))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	want := []Record{
+		{Syscall: "open", Desc: "missing", Errno: 2},
+		{Syscall: "write", Desc: "pipe", Ret: 5},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Parse returned %d records, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("record %d: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	native := []Record{
+		{Syscall: "open", Desc: "a"},
+		{Syscall: "read", Desc: "a", Ret: 5},
+		{Syscall: "read", Desc: "a", Ret: 0},
+		{Syscall: "close", Desc: "a"},
+		{Syscall: "unlink", Desc: "a", Errno: 2},
+	}
+	sandbox := []Record{
+		{Syscall: "open", Desc: "a"},
+		// Extra record, e.g. from a retry.
+		{Syscall: "fstat", Desc: "a"},
+		{Syscall: "read", Desc: "a", Ret: 5},
+		{Syscall: "read", Desc: "a", Ret: 0},
+		{Syscall: "close", Desc: "a", Errno: 9},
+		// unlink is missing.
+	}
+	mismatches := Compare("prog", native, sandbox)
+	got := make(map[string]bool)
+	for _, m := range mismatches {
+		got[m.String()] = true
+	}
+	for _, want := range []string{
+		"prog: host close(a) = 0, sandbox close(a) = errno 9",
+		"prog: unlink(a) = errno 2 only on the host",
+		"prog: fstat(a) = 0 only in the sandbox",
+	} {
+		if !got[want] {
+			t.Errorf("mismatch %q not found in %v", want, got)
+		}
+	}
+	if len(mismatches) != 3 {
+		t.Errorf("got %d mismatches, want 3: %v", len(mismatches), got)
+	}
+}
+
+func TestReport(t *testing.T) {
+	r := make(Report)
+	r.Add("a", []Record{
+		{Syscall: "open", Desc: "x"},
+		{Syscall: "open", Desc: "y", Errno: 2},
+	}, []Record{
+		{Syscall: "open", Desc: "x"},
+		{Syscall: "open", Desc: "y", Errno: 13},
+	})
+	r.Add("b", []Record{
+		{Syscall: "read", Desc: "x", Ret: 1},
+		{Syscall: "read", Observed: "data"},
+	}, []Record{
+		{Syscall: "read", Desc: "x", Ret: 1},
+		{Syscall: "read", Observed: "data"},
+	})
+
+	if got := r["open"].Calls; got != 2 {
+		t.Errorf("open calls: got %d, want 2", got)
+	}
+	if got := len(r["read"].Mismatches); got != 0 {
+		t.Errorf("read mismatches: got %d, want 0", got)
+	}
+	if got := r.Mismatches(); got != 1 {
+		t.Errorf("Mismatches(): got %d, want 1", got)
+	}
+
+	var buf bytes.Buffer
+	if err := r.WriteText(&buf); err != nil {
+		t.Fatalf("WriteText failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), "a: host open(y) = errno 2, sandbox open(y) = errno 13") {
+		t.Errorf("report doesn't contain the mismatch:\n%s", buf.String())
+	}
+}
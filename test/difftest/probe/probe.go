@@ -0,0 +1,101 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package probe is used by differential test programs to record the results
+// of the syscalls they make, so that they can be compared between runs on the
+// host and in the sandbox.
+//
+// Each result is written to stdout as a JSON-encoded difftest.Record, one per
+// line. Programs must only record results that are deterministic on the host,
+// e.g. not PIDs or timestamps.
+package probe
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"golang.org/x/sys/unix"
+	"gvisor.dev/gvisor/test/difftest"
+)
+
+var mu sync.Mutex
+
+// write writes r to stdout.
+func write(r *difftest.Record) {
+	b, err := json.Marshal(r)
+	if err != nil {
+		panic(fmt.Sprintf("json.Marshal(%+v): %v", r, err))
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	os.Stdout.Write(append(b, '\n'))
+}
+
+// Call records the result of a syscall. name is the name of the syscall and
+// desc identifies the call among others to the same syscall, e.g.
+// "nonexistent path". ret is only compared if errno is 0.
+func Call(name, desc string, ret uintptr, errno unix.Errno) {
+	r := &difftest.Record{
+		Syscall: name,
+		Desc:    desc,
+		Errno:   int(errno),
+	}
+	if errno == 0 {
+		r.Ret = int64(ret)
+	}
+	write(r)
+}
+
+// Err records the result of a syscall made through a wrapper that only
+// returns an error, like most functions of the unix package.
+func Err(name, desc string, err error) {
+	Call(name, desc, 0, Errno(err))
+}
+
+// Errno converts an error returned by the unix package to an errno. It panics
+// if err isn't an errno.
+func Errno(err error) unix.Errno {
+	if err == nil {
+		return 0
+	}
+	errno, ok := err.(unix.Errno)
+	if !ok {
+		panic(fmt.Sprintf("%v isn't an errno", err))
+	}
+	return errno
+}
+
+// Observe records a side effect of a previous syscall, e.g. the size of a file
+// after it has been truncated. value is compared using its default format,
+// as printed by fmt's %v.
+func Observe(name, desc string, value interface{}) {
+	write(&difftest.Record{
+		Syscall:  name,
+		Desc:     desc,
+		Observed: fmt.Sprintf("%v", value),
+	})
+}
+
+// TempDir returns a new empty directory for the program to use. It's removed
+// when the returned function is called.
+func TempDir() (string, func()) {
+	dir, err := ioutil.TempDir(os.Getenv("TEST_TMPDIR"), "difftest")
+	if err != nil {
+		panic(fmt.Sprintf("creating temporary directory: %v", err))
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
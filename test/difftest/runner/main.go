@@ -0,0 +1,186 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Binary difftest_runner runs differential test programs on the host and in
+// runsc, and reports the differences in syscall results.
+//
+// Usage: difftest_runner [flags] [program...]
+//
+// Without programs, the programs in test/difftest/corpus are run. When run by
+// bazel, runsc is found in the runfiles unless --runsc is given.
+//
+// The sandbox is started with "runsc do", which makes the host filesystem
+// available through an overlay. Programs run as root in the sandbox, so they
+// should avoid syscalls whose results depend on permissions.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gvisor.dev/gvisor/pkg/log"
+	"gvisor.dev/gvisor/pkg/test/testutil"
+	"gvisor.dev/gvisor/test/difftest"
+)
+
+var (
+	runscPath  = flag.String("runsc", "", "path to runsc binary, found in the runfiles if empty")
+	platform   = flag.String("platform", "ptrace", "platform to run on")
+	vfs2       = flag.Bool("vfs2", true, "enable VFS2")
+	runscFlags = flag.String("runsc-flags", "", "additional flags passed to runsc, space separated")
+	timeout    = flag.Duration("timeout", time.Minute, "timeout for each run of a program")
+	reportPath = flag.String("report", "", "path of the JSON report to write, in addition to the text report written to stdout")
+	mustMatch  = flag.Bool("must-match", false, "exit with a non-zero status if any mismatch is found")
+)
+
+// corpus are the test programs run by default, relative to the runfiles root.
+var corpus = []string{
+	"test/difftest/corpus/fd/fd",
+	"test/difftest/corpus/fs/fs",
+}
+
+// findFile finds a file in the runfiles, which are either in the working
+// directory, when run by bazel test, or next to the binary, when run by bazel
+// run or directly.
+func findFile(path string) (string, error) {
+	if p, err := testutil.FindFile(path); err == nil {
+		return p, nil
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	p := filepath.Join(exe+".runfiles", "__main__", path)
+	if _, err := os.Stat(p); err != nil {
+		return "", err
+	}
+	return p, nil
+}
+
+// run runs args and returns the records written to stdout. A non-zero exit
+// status isn't an error, since the program may fail in the same way on the
+// host and in the sandbox.
+func run(args []string) ([]difftest.Record, error) {
+	tmpDir, err := ioutil.TempDir("", "difftest")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Env = append(os.Environ(), "TEST_TMPDIR="+tmpDir)
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	select {
+	case err := <-done:
+		if _, ok := err.(*exec.ExitError); err != nil && !ok {
+			return nil, err
+		}
+	case <-time.After(*timeout):
+		cmd.Process.Kill()
+		<-done
+		return nil, fmt.Errorf("timed out after %v", *timeout)
+	}
+	return difftest.Parse(&stdout)
+}
+
+// runscArgs returns the command that runs program in the sandbox.
+func runscArgs(program string) []string {
+	args := []string{
+		*runscPath,
+		"--rootless",
+		"--network=none",
+		"--platform=" + *platform,
+		fmt.Sprintf("--vfs2=%t", *vfs2),
+	}
+	args = append(args, strings.Fields(*runscFlags)...)
+	return append(args, "do", "--quiet", "--", program)
+}
+
+func main() {
+	flag.Parse()
+	if *runscPath == "" {
+		path, err := findFile("runsc/runsc")
+		if err != nil {
+			log.Warningf("runsc not found, use --runsc: %v", err)
+			os.Exit(2)
+		}
+		*runscPath = path
+	}
+	programs := flag.Args()
+	if len(programs) == 0 {
+		for _, p := range corpus {
+			path, err := findFile(p)
+			if err != nil {
+				log.Warningf("Test program not found, pass programs as arguments: %v", err)
+				os.Exit(2)
+			}
+			programs = append(programs, path)
+		}
+	}
+
+	report := make(difftest.Report)
+	for _, program := range programs {
+		abs, err := filepath.Abs(program)
+		if err != nil {
+			log.Warningf("%s: %v", program, err)
+			os.Exit(1)
+		}
+		name := filepath.Base(program)
+		native, err := run([]string{abs})
+		if err != nil {
+			log.Warningf("%s: running on the host: %v", name, err)
+			os.Exit(1)
+		}
+		sandbox, err := run(runscArgs(abs))
+		if err != nil {
+			log.Warningf("%s: running in the sandbox: %v", name, err)
+			os.Exit(1)
+		}
+		report.Add(name, native, sandbox)
+	}
+
+	if err := report.WriteText(os.Stdout); err != nil {
+		log.Warningf("Writing report: %v", err)
+		os.Exit(1)
+	}
+	if *reportPath != "" {
+		b, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			log.Warningf("Encoding report: %v", err)
+			os.Exit(1)
+		}
+		if err := ioutil.WriteFile(*reportPath, b, 0644); err != nil {
+			log.Warningf("Writing report: %v", err)
+			os.Exit(1)
+		}
+	}
+	if *mustMatch && report.Mismatches() != 0 {
+		os.Exit(1)
+	}
+}
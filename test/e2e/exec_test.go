@@ -24,14 +24,17 @@ package integration
 import (
 	"context"
 	"fmt"
+	"regexp"
 	"strconv"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/cenkalti/backoff"
 	"gvisor.dev/gvisor/pkg/abi/linux"
 	"gvisor.dev/gvisor/pkg/bits"
 	"gvisor.dev/gvisor/pkg/test/dockerutil"
+	"gvisor.dev/gvisor/pkg/test/testutil"
 	"gvisor.dev/gvisor/runsc/specutils"
 )
 
@@ -156,6 +159,97 @@ func TestExecJobControl(t *testing.T) {
 	}
 }
 
+// Test that exec with a TTY behaves like an interactive terminal: input is
+// echoed, control characters generate signals, escape sequences are passed
+// through and the window size is propagated.
+func TestExecTTYInteractive(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	d := dockerutil.MakeContainer(ctx, t)
+	defer d.CleanUp(ctx)
+
+	// Start the container.
+	if err := d.Spawn(ctx, dockerutil.RunOpts{
+		Image: "basic/alpine",
+	}, "sleep", "1000"); err != nil {
+		t.Fatalf("docker run failed: %v", err)
+	}
+
+	p, err := d.ExecTTY(ctx, dockerutil.ExecOpts{Env: []string{"PS1=prompt$ "}}, "/bin/sh")
+	if err != nil {
+		t.Fatalf("docker exec failed: %v", err)
+	}
+	defer p.Close()
+	if _, err := p.Expect(ctx, "prompt$ "); err != nil {
+		t.Fatalf("no prompt: %v", err)
+	}
+
+	// Escape sequences reach the terminal unchanged.
+	if err := p.Write(`printf '\033[1;31m%s\033[0m\n' red` + dockerutil.KeyEnter); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if _, err := p.Expect(ctx, "\x1b[1;31mred\x1b[0m\r\n"); err != nil {
+		t.Fatalf("escape sequence not found: %v", err)
+	}
+	if _, err := p.Expect(ctx, "prompt$ "); err != nil {
+		t.Fatalf("no prompt: %v", err)
+	}
+
+	// Ctrl+C interrupts the foreground process.
+	if err := p.Write("sleep 100" + dockerutil.KeyEnter); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if _, err := p.Expect(ctx, "sleep 100\r\n"); err != nil {
+		t.Fatalf("input not echoed: %v", err)
+	}
+	if err := p.Write(dockerutil.KeyCtrlC); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if _, err := p.Expect(ctx, "^C"); err != nil {
+		t.Fatalf("interrupt not echoed: %v", err)
+	}
+	if _, err := p.Expect(ctx, "prompt$ "); err != nil {
+		t.Fatalf("sleep not interrupted: %v", err)
+	}
+	if err := p.Write("echo status=$?" + dockerutil.KeyEnter); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if _, err := p.Expect(ctx, "status=130"); err != nil {
+		t.Fatalf("wrong exit status after interrupt: %v", err)
+	}
+
+	// The new window size is visible to the process.
+	if err := p.Resize(40, 120); err != nil {
+		t.Fatalf("resize failed: %v", err)
+	}
+	err = testutil.Poll(func() error {
+		if err := p.Write("stty size" + dockerutil.KeyEnter); err != nil {
+			return &backoff.PermanentError{Err: err}
+		}
+		m, err := p.ExpectRegexp(ctx, regexp.MustCompile(`\n(\d+) (\d+)\r\n`))
+		if err != nil {
+			return &backoff.PermanentError{Err: err}
+		}
+		if m[1] != "40" || m[2] != "120" {
+			return fmt.Errorf("got size %sx%s, want 40x120", m[1], m[2])
+		}
+		return nil
+	}, 10*time.Second)
+	if err != nil {
+		t.Fatalf("window size not propagated: %v", err)
+	}
+
+	// The exit status of the shell is the one of the exec.
+	if err := p.Write("exit 3" + dockerutil.KeyEnter); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+	if got, err := p.Wait(ctx); err != nil {
+		t.Fatalf("wait failed: %v", err)
+	} else if got != 3 {
+		t.Errorf("got exit status %d, want 3", got)
+	}
+}
+
 // Test that failure to exec returns proper error message.
 func TestExecError(t *testing.T) {
 	ctx := context.Background()
@@ -23,6 +23,7 @@ package integration
 
 import (
 	"context"
+	"crypto/rand"
 	"flag"
 	"fmt"
 	"io/ioutil"
@@ -57,7 +58,7 @@ func TestMain(m *testing.M) {
 
 // httpRequestSucceeds sends a request to a given url and checks that the status is OK.
 func httpRequestSucceeds(client http.Client, server string, port int) error {
-	url := fmt.Sprintf("http://%s:%d", server, port)
+	url := fmt.Sprintf("http://%s", net.JoinHostPort(server, strconv.Itoa(port)))
 	// Ensure that content is being served.
 	resp, err := client.Get(url)
 	if err != nil {
@@ -224,40 +225,43 @@ func TestCheckpointRestore(t *testing.T) {
 	}
 }
 
-// Create client and server that talk to each other using the local IP.
+// Create client and server that talk to each other using the local IP, with
+// every runtime in the configuration matrix.
 func TestConnectToSelf(t *testing.T) {
-	ctx := context.Background()
-	d := dockerutil.MakeContainer(ctx, t)
-	defer d.CleanUp(ctx)
+	dockerutil.RunMatrix(t, func(t *testing.T, _ dockerutil.RuntimeConfig) {
+		ctx := context.Background()
+		d := dockerutil.MakeContainer(ctx, t)
+		defer d.CleanUp(ctx)
 
-	// Creates server that replies "server" and exists. Sleeps at the end because
-	// 'docker exec' gets killed if the init process exists before it can finish.
-	if err := d.Spawn(ctx, dockerutil.RunOpts{
-		Image: "basic/ubuntu",
-	}, "/bin/sh", "-c", "echo server | nc -l -p 8080 && sleep 1"); err != nil {
-		t.Fatalf("docker run failed: %v", err)
-	}
+		// Creates server that replies "server" and exists. Sleeps at the end because
+		// 'docker exec' gets killed if the init process exists before it can finish.
+		if err := d.Spawn(ctx, dockerutil.RunOpts{
+			Image: "basic/ubuntu",
+		}, "/bin/sh", "-c", "echo server | nc -l -p 8080 && sleep 1"); err != nil {
+			t.Fatalf("docker run failed: %v", err)
+		}
 
-	// Finds IP address for host.
-	ip, err := d.Exec(ctx, dockerutil.ExecOpts{}, "/bin/sh", "-c", "cat /etc/hosts | grep ${HOSTNAME} | awk '{print $1}'")
-	if err != nil {
-		t.Fatalf("docker exec failed: %v", err)
-	}
-	ip = strings.TrimRight(ip, "\n")
+		// Finds IP address for host.
+		ip, err := d.Exec(ctx, dockerutil.ExecOpts{}, "/bin/sh", "-c", "cat /etc/hosts | grep ${HOSTNAME} | awk '{print $1}'")
+		if err != nil {
+			t.Fatalf("docker exec failed: %v", err)
+		}
+		ip = strings.TrimRight(ip, "\n")
 
-	// Runs client that sends "client" to the server and exits.
-	reply, err := d.Exec(ctx, dockerutil.ExecOpts{}, "/bin/sh", "-c", fmt.Sprintf("echo client | nc %s 8080", ip))
-	if err != nil {
-		t.Fatalf("docker exec failed: %v", err)
-	}
+		// Runs client that sends "client" to the server and exits.
+		reply, err := d.Exec(ctx, dockerutil.ExecOpts{}, "/bin/sh", "-c", fmt.Sprintf("echo client | nc %s 8080", ip))
+		if err != nil {
+			t.Fatalf("docker exec failed: %v", err)
+		}
 
-	// Ensure both client and server got the message from each other.
-	if want := "server\n"; reply != want {
-		t.Errorf("Error on server, want: %q, got: %q", want, reply)
-	}
-	if _, err := d.WaitForOutput(ctx, "^client\n$", defaultWait); err != nil {
-		t.Fatalf("docker.WaitForOutput(client) timeout: %v", err)
-	}
+		// Ensure both client and server got the message from each other.
+		if want := "server\n"; reply != want {
+			t.Errorf("Error on server, want: %q, got: %q", want, reply)
+		}
+		if _, err := d.WaitForOutput(ctx, "^client\n$", defaultWait); err != nil {
+			t.Fatalf("docker.WaitForOutput(client) timeout: %v", err)
+		}
+	})
 }
 
 func TestMemory(t *testing.T) {
@@ -598,6 +602,114 @@ func TestPing6Loopback(t *testing.T) {
 	runIntegrationTest(t, []string{"NET_ADMIN"}, "./ping6.sh")
 }
 
+// TestDualStackNetwork checks that the IPv4 and IPv6 configuration of a
+// container attached to a dual-stack network is moved into the sandbox.
+func TestDualStackNetwork(t *testing.T) {
+	if testutil.IsRunningWithHostNet() {
+		t.Skip("hostnet uses the network configuration of the host directly")
+	}
+
+	ctx := context.Background()
+	n := newDualStackNetwork(ctx, t)
+	defer n.Cleanup(ctx)
+
+	d := dockerutil.MakeContainer(ctx, t)
+	defer d.CleanUp(ctx)
+
+	// The server listens on both IPv4 and IPv6.
+	port := 8080
+	if err := d.Spawn(ctx, dockerutil.RunOpts{
+		Image:   "basic/python",
+		Network: n.Name,
+	}, "--bind", "::"); err != nil {
+		t.Fatalf("docker run failed: %v", err)
+	}
+
+	for _, ipv6 := range []bool{false, true} {
+		ip, err := d.FindNetworkIP(ctx, n, ipv6)
+		if err != nil {
+			t.Fatalf("docker.FindNetworkIP(ipv6=%t) failed: %v", ipv6, err)
+		}
+		if err := testutil.WaitForHTTP(ip.String(), port, defaultWait); err != nil {
+			t.Fatalf("WaitForHTTP(%s) timeout: %v", ip, err)
+		}
+		client := http.Client{Timeout: defaultWait}
+		if err := httpRequestSucceeds(client, ip.String(), port); err != nil {
+			t.Errorf("http request to %s failed: %v", ip, err)
+		}
+	}
+
+	// Connecting a UDP socket to an address outside of the subnet requires
+	// the IPv6 default route. No packets are sent.
+	ipv6, err := d.FindNetworkIP(ctx, n, true)
+	if err != nil {
+		t.Fatalf("docker.FindNetworkIP failed: %v", err)
+	}
+	const script = `import socket
+s = socket.socket(socket.AF_INET6, socket.SOCK_DGRAM)
+s.connect(("2001:db8::1", 53))
+print(s.getsockname()[0])`
+	got, err := d.Exec(ctx, dockerutil.ExecOpts{}, "python", "-c", script)
+	if err != nil {
+		t.Fatalf("docker exec failed: %v, output: %s", err, got)
+	}
+	if got := net.ParseIP(strings.TrimSpace(got)); !got.Equal(ipv6) {
+		t.Errorf("source address for default route got %v, want %v", got, ipv6)
+	}
+}
+
+// TestDualStackPing6 checks that sandboxes attached to a dual-stack network
+// can resolve each other's IPv6 addresses with neighbor discovery and exchange
+// ICMPv6 packets.
+func TestDualStackPing6(t *testing.T) {
+	if testutil.IsRunningWithHostNet() {
+		t.Skip("hostnet uses the network configuration of the host directly")
+	}
+
+	ctx := context.Background()
+	n := newDualStackNetwork(ctx, t)
+	defer n.Cleanup(ctx)
+
+	server := dockerutil.MakeContainer(ctx, t)
+	defer server.CleanUp(ctx)
+	if err := server.Spawn(ctx, dockerutil.RunOpts{
+		Image:   "basic/alpine",
+		Network: n.Name,
+	}, "sleep", "1000"); err != nil {
+		t.Fatalf("docker run failed: %v", err)
+	}
+	ip, err := server.FindNetworkIP(ctx, n, true /* ipv6 */)
+	if err != nil {
+		t.Fatalf("docker.FindNetworkIP failed: %v", err)
+	}
+
+	client := dockerutil.MakeContainer(ctx, t)
+	defer client.CleanUp(ctx)
+	if out, err := client.Run(ctx, dockerutil.RunOpts{
+		Image:   "basic/alpine",
+		Network: n.Name,
+	}, "ping", "-6", "-c", "3", "-w", "10", ip.String()); err != nil {
+		t.Fatalf("ping %s failed: %v, output: %s", ip, err, out)
+	}
+}
+
+// newDualStackNetwork creates a Docker network with both IPv4 and IPv6
+// subnets. The caller must clean it up.
+func newDualStackNetwork(ctx context.Context, t *testing.T) *dockerutil.Network {
+	n := dockerutil.NewNetwork(ctx, t)
+	// Use a random unique local subnet, to not collide with other networks.
+	subnet := make(net.IP, net.IPv6len)
+	subnet[0] = 0xfd
+	if _, err := rand.Read(subnet[1:8]); err != nil {
+		t.Fatalf("rand.Read failed: %v", err)
+	}
+	n.Subnetv6 = &net.IPNet{IP: subnet, Mask: net.CIDRMask(64, 8*net.IPv6len)}
+	if err := n.Create(ctx); err != nil {
+		t.Fatalf("docker network create failed: %v", err)
+	}
+	return n
+}
+
 // This test checks that the owner of the sticky directory can delete files
 // inside it belonging to other users. It also checks that the owner of a file
 // can always delete its file when the file is inside a sticky directory owned
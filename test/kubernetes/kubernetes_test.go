@@ -0,0 +1,328 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package kubernetes provides end-to-end tests for runsc running in a
+// Kubernetes cluster.
+//
+// Each test creates pods in its own namespace using the RuntimeClass given by
+// --runtime-class, and checks their behavior through the Kubernetes API. This
+// exercises the CRI code paths, e.g. through containerd and the shim, that
+// tests using docker don't.
+//
+// The cluster can be created with tools/installers/kind.sh, see the
+// kubernetes-tests Makefile target.
+package kubernetes
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"gvisor.dev/gvisor/pkg/test/k8sutil"
+	"gvisor.dev/gvisor/pkg/test/testutil"
+)
+
+// podTimeout is how long to wait for pods to change state. It's generous to
+// accommodate slow image loading in freshly created clusters.
+const podTimeout = 2 * time.Minute
+
+// newKubectl returns a Kubectl for a new namespace, which is deleted when the
+// test completes.
+func newKubectl(t *testing.T) *k8sutil.Kubectl {
+	k, err := k8sutil.NewKubectl(t)
+	if err != nil {
+		t.Fatalf("NewKubectl failed: %v", err)
+	}
+	t.Cleanup(k.CleanUp)
+	return k
+}
+
+// sleepPod returns a pod with a single container that sleeps forever.
+func sleepPod(name string) *k8sutil.Pod {
+	return &k8sutil.Pod{
+		Name: name,
+		Containers: []k8sutil.Container{
+			{
+				Name:    "sleep",
+				Image:   "basic/alpine",
+				Command: []string{"sleep", "1000000"},
+			},
+		},
+	}
+}
+
+// checkGVisor checks that the container runs in a gVisor sandbox, as opposed
+// to the cluster's default runtime.
+func checkGVisor(t *testing.T, k *k8sutil.Kubectl, pod, container string) {
+	t.Helper()
+	out, err := k.Exec(pod, container, "dmesg")
+	if err != nil {
+		t.Fatalf("dmesg failed: %v", err)
+	}
+	if !strings.Contains(out, "gVisor") {
+		t.Errorf("container %q of pod %q isn't running in gVisor, dmesg: %q", container, pod, out)
+	}
+}
+
+// TestPodLifecycle checks that pods start and that their exit status is
+// reported, and that running pods can be deleted.
+func TestPodLifecycle(t *testing.T) {
+	k := newKubectl(t)
+
+	for _, tc := range []struct {
+		name      string
+		cmd       string
+		wantPhase string
+		wantCode  int
+	}{
+		{name: "success", cmd: "exit 0", wantPhase: "Succeeded", wantCode: 0},
+		{name: "failure", cmd: "exit 42", wantPhase: "Failed", wantCode: 42},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			pod := &k8sutil.Pod{
+				Name: tc.name,
+				Containers: []k8sutil.Container{
+					{
+						Name:    "main",
+						Image:   "basic/alpine",
+						Command: []string{"sh", "-c", tc.cmd},
+					},
+				},
+			}
+			if err := k.CreatePod(pod); err != nil {
+				t.Fatalf("CreatePod failed: %v", err)
+			}
+			var status *k8sutil.PodStatus
+			err := testutil.Poll(func() error {
+				var err error
+				status, err = k.Status(pod.Name)
+				if err != nil {
+					return err
+				}
+				if status.Phase != "Succeeded" && status.Phase != "Failed" {
+					return fmt.Errorf("pod is %s", status.Phase)
+				}
+				return nil
+			}, podTimeout)
+			if err != nil {
+				t.Fatalf("pod didn't complete: %v", err)
+			}
+			if status.Phase != tc.wantPhase {
+				t.Errorf("pod phase: got %q, want %q", status.Phase, tc.wantPhase)
+			}
+			c := status.Container("main")
+			if c == nil || c.State.Terminated == nil {
+				t.Fatalf("container isn't terminated: %+v", status)
+			}
+			if got := c.State.Terminated.ExitCode; got != tc.wantCode {
+				t.Errorf("exit code: got %d, want %d", got, tc.wantCode)
+			}
+		})
+	}
+
+	t.Run("delete", func(t *testing.T) {
+		pod := sleepPod("delete")
+		if err := k.CreatePod(pod); err != nil {
+			t.Fatalf("CreatePod failed: %v", err)
+		}
+		if err := k.Wait("pod/"+pod.Name, "condition=Ready", podTimeout); err != nil {
+			t.Fatal(err)
+		}
+		if err := k.Delete("pod/"+pod.Name, podTimeout); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+// TestExec checks that commands can be executed in running containers, and
+// that their output and exit status are reported.
+func TestExec(t *testing.T) {
+	k := newKubectl(t)
+
+	pod := sleepPod("exec")
+	if err := k.CreatePod(pod); err != nil {
+		t.Fatalf("CreatePod failed: %v", err)
+	}
+	if err := k.Wait("pod/"+pod.Name, "condition=Ready", podTimeout); err != nil {
+		t.Fatal(err)
+	}
+	checkGVisor(t, k, pod.Name, "sleep")
+
+	out, err := k.Exec(pod.Name, "sleep", "sh", "-c", "echo $((6*7))")
+	if err != nil {
+		t.Fatalf("exec failed: %v", err)
+	}
+	if got, want := strings.TrimSpace(out), "42"; got != want {
+		t.Errorf("exec output: got %q, want %q", got, want)
+	}
+
+	if _, err := k.Exec(pod.Name, "sleep", "sh", "-c", "exit 3"); err == nil {
+		t.Errorf("exec of failing command succeeded")
+	} else if !strings.Contains(err.Error(), "exit status 3") && !strings.Contains(err.Error(), "exit code 3") {
+		t.Errorf("exec of failing command returned unexpected error: %v", err)
+	}
+}
+
+// TestLogs checks that the output of containers is available in their logs.
+func TestLogs(t *testing.T) {
+	k := newKubectl(t)
+
+	pod := &k8sutil.Pod{
+		Name: "logs",
+		Containers: []k8sutil.Container{
+			{
+				Name:    "main",
+				Image:   "basic/alpine",
+				Command: []string{"sh", "-c", "echo to-stdout; echo to-stderr >&2; for i in 1 2 3; do echo line-$i; done"},
+			},
+		},
+	}
+	if err := k.CreatePod(pod); err != nil {
+		t.Fatalf("CreatePod failed: %v", err)
+	}
+	if _, err := k.WaitForPhase(pod.Name, "Succeeded", podTimeout); err != nil {
+		t.Fatal(err)
+	}
+	out, err := k.Logs(pod.Name, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"to-stdout", "to-stderr", "line-1", "line-2", "line-3"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("logs don't contain %q: %q", want, out)
+		}
+	}
+}
+
+// TestProbes checks that readiness and liveness probes are run in the
+// container.
+func TestProbes(t *testing.T) {
+	k := newKubectl(t)
+
+	t.Run("readiness", func(t *testing.T) {
+		pod := &k8sutil.Pod{
+			Name: "readiness",
+			Containers: []k8sutil.Container{
+				{
+					Name:  "httpd",
+					Image: "basic/httpd",
+					Port:  80,
+					ReadinessProbe: &k8sutil.Probe{
+						HTTPPort:      80,
+						PeriodSeconds: 1,
+					},
+				},
+			},
+		}
+		if err := k.CreatePod(pod); err != nil {
+			t.Fatalf("CreatePod failed: %v", err)
+		}
+		if err := k.Wait("pod/"+pod.Name, "condition=Ready", podTimeout); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("liveness", func(t *testing.T) {
+		// The probe fails once the file is removed, which must make the
+		// kubelet restart the container.
+		pod := &k8sutil.Pod{
+			Name:          "liveness",
+			RestartPolicy: "Always",
+			Containers: []k8sutil.Container{
+				{
+					Name:    "main",
+					Image:   "basic/alpine",
+					Command: []string{"sh", "-c", "touch /tmp/alive; sleep 5; rm /tmp/alive; sleep 1000000"},
+					LivenessProbe: &k8sutil.Probe{
+						Exec:             []string{"cat", "/tmp/alive"},
+						PeriodSeconds:    1,
+						FailureThreshold: 1,
+					},
+				},
+			},
+		}
+		if err := k.CreatePod(pod); err != nil {
+			t.Fatalf("CreatePod failed: %v", err)
+		}
+		err := testutil.Poll(func() error {
+			status, err := k.Status(pod.Name)
+			if err != nil {
+				return err
+			}
+			if c := status.Container("main"); c == nil || c.RestartCount == 0 {
+				return fmt.Errorf("container not restarted, pod is %s", status.Phase)
+			}
+			return nil
+		}, podTimeout)
+		if err != nil {
+			t.Fatalf("container wasn't restarted after liveness probe failure: %v", err)
+		}
+	})
+}
+
+// TestMultiContainerPod checks that containers of the same pod run in the
+// same sandbox and share the network namespace.
+func TestMultiContainerPod(t *testing.T) {
+	k := newKubectl(t)
+
+	pod := &k8sutil.Pod{
+		Name: "multi",
+		Containers: []k8sutil.Container{
+			{
+				Name:  "httpd",
+				Image: "basic/httpd",
+				Port:  80,
+				ReadinessProbe: &k8sutil.Probe{
+					HTTPPort:      80,
+					PeriodSeconds: 1,
+				},
+			},
+			{
+				Name:    "client",
+				Image:   "basic/alpine",
+				Command: []string{"sleep", "1000000"},
+			},
+		},
+	}
+	if err := k.CreatePod(pod); err != nil {
+		t.Fatalf("CreatePod failed: %v", err)
+	}
+	if err := k.Wait("pod/"+pod.Name, "condition=Ready", podTimeout); err != nil {
+		t.Fatal(err)
+	}
+	checkGVisor(t, k, pod.Name, "client")
+
+	// The server is reachable over loopback only if the containers share the
+	// network stack.
+	out, err := k.Exec(pod.Name, "client", "wget", "-q", "-O", "-", "http://localhost:80/")
+	if err != nil {
+		t.Fatalf("wget failed: %v", err)
+	}
+	if !strings.Contains(out, "It works!") {
+		t.Errorf("unexpected response from httpd: %q", out)
+	}
+
+	// Deleting the pod stops all of its containers.
+	if err := k.Delete("pod/"+pod.Name, podTimeout); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	os.Exit(m.Run())
+}
@@ -12,8 +12,12 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
+#include <sys/mman.h>
+#include <sys/wait.h>
 #include <unistd.h>
 
+#include <cstring>
+
 #include "gtest/gtest.h"
 #include "absl/synchronization/barrier.h"
 #include "benchmark/benchmark.h"
@@ -344,6 +348,61 @@ void BM_ProcessLifecycle(benchmark::State& state) {
 
 BENCHMARK(BM_ProcessLifecycle)->Range(1, 512)->UseRealTime();
 
+// ForkAndWait forks a child that exits immediately, and waits for it.
+void ForkAndWait() {
+  int pid = fork();
+  if (pid == 0) {
+    _exit(0);
+  }
+  ASSERT_THAT(pid, SyscallSucceeds());
+  ASSERT_THAT(RetryEINTR(waitpid)(pid, nullptr, 0),
+              SyscallSucceedsWithValue(pid));
+}
+
+// Benchmark fork + exit + wait with the given number of MB of private memory
+// written to before forking, which must be made copy-on-write by fork.
+void BM_ForkPrivateMemory(benchmark::State& state) {
+  const size_t size = state.range(0) << 20;
+
+  void* addr = mmap(nullptr, size, PROT_READ | PROT_WRITE,
+                    MAP_PRIVATE | MAP_ANONYMOUS, -1, 0);
+  TEST_CHECK(addr != MAP_FAILED);
+  auto cleanup = Cleanup([&] { munmap(addr, size); });
+  memset(addr, 1, size);
+
+  for (auto _ : state) {
+    ForkAndWait();
+  }
+}
+
+BENCHMARK(BM_ForkPrivateMemory)->Range(1, 1024)->UseRealTime();
+
+// Benchmark fork + exit + wait with the given number of private mappings,
+// each of which has been written to before forking.
+void BM_ForkPrivateMappings(benchmark::State& state) {
+  const int num_mappings = state.range(0);
+  const size_t page_size = getpagesize();
+
+  // Alternate the protection of pages in a single mapping to create separate
+  // mappings, since adjacent mappings with the same protection are merged.
+  const size_t size = 2 * num_mappings * page_size;
+  char* addr = static_cast<char*>(mmap(nullptr, size, PROT_READ | PROT_WRITE,
+                                       MAP_PRIVATE | MAP_ANONYMOUS, -1, 0));
+  TEST_CHECK(addr != MAP_FAILED);
+  auto cleanup = Cleanup([&] { munmap(addr, size); });
+  memset(addr, 1, size);
+  for (int i = 0; i < num_mappings; i++) {
+    TEST_PCHECK(mprotect(addr + (2 * i + 1) * page_size, page_size,
+                         PROT_READ) == 0);
+  }
+
+  for (auto _ : state) {
+    ForkAndWait();
+  }
+}
+
+BENCHMARK(BM_ForkPrivateMappings)->Range(1, 4096)->UseRealTime();
+
 }  // namespace
 
 }  // namespace testing
@@ -196,6 +196,36 @@ TEST(Cgroup, MoptAllMustBeExclusive) {
       SyscallFailsWithErrno(EINVAL));
 }
 
+TEST(Cgroup, NamedHierarchy) {
+  SKIP_IF(!CgroupsAvailable());
+
+  Mounter m(ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateDir()));
+  Cgroup c = ASSERT_NO_ERRNO_AND_VALUE(m.MountCgroupfs("none,name=systemd"));
+  EXPECT_NO_ERRNO(c.ContainsCallingProcess());
+  // Named hierarchies have no controller files.
+  EXPECT_THAT(Exists(c.Relpath("memory.usage_in_bytes")),
+              IsPosixErrorOkAndHolds(false));
+
+  absl::flat_hash_map<std::string, PIDCgroupEntry> entries =
+      ASSERT_NO_ERRNO_AND_VALUE(ProcPIDCgroupEntries(getpid()));
+  EXPECT_TRUE(entries.contains("name=systemd"));
+
+  // Named hierarchies aren't listed in /proc/cgroups.
+  absl::flat_hash_map<std::string, CgroupsEntry> cgroups_entries =
+      ASSERT_NO_ERRNO_AND_VALUE(ProcCgroupsEntries());
+  EXPECT_FALSE(cgroups_entries.contains("name=systemd"));
+}
+
+TEST(Cgroup, MoptNoneRequiresName) {
+  SKIP_IF(!CgroupsAvailable());
+
+  TempPath mountpoint = ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateDir());
+  std::string mopts = "none";
+  EXPECT_THAT(
+      mount("none", mountpoint.path().c_str(), "cgroup", 0, mopts.c_str()),
+      SyscallFailsWithErrno(EINVAL));
+}
+
 TEST(Cgroup, MountRace) {
   SKIP_IF(!CgroupsAvailable());
 
@@ -103,6 +103,22 @@ TEST(ClockGettime, RealtimeWorks) {
   EXPECT_THAT(clock_gettime(CLOCK_REALTIME, &tp), SyscallSucceeds());
 }
 
+// CLOCK_TAI is ahead of CLOCK_REALTIME by a whole number of seconds, which is
+// 0 if the TAI offset hasn't been set by an NTP daemon.
+TEST(ClockGettime, TAIWorks) {
+  struct timespec real, tai;
+  ASSERT_THAT(clock_gettime(CLOCK_REALTIME, &real), SyscallSucceeds());
+  ASSERT_THAT(clock_gettime(CLOCK_TAI, &tai), SyscallSucceeds());
+
+  absl::Duration offset =
+      absl::TimeFromTimespec(tai) - absl::TimeFromTimespec(real);
+  EXPECT_GE(offset, absl::ZeroDuration());
+  absl::Duration rem;
+  absl::IDivDuration(offset, absl::Seconds(1), &rem);
+  // Allow for the time elapsed between both calls.
+  EXPECT_LT(rem, absl::Milliseconds(100));
+}
+
 class MonotonicClockTest : public ::testing::TestWithParam<clockid_t> {};
 
 TEST_P(MonotonicClockTest, IsMonotonic) {
@@ -0,0 +1,163 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#include <errno.h>
+#include <fcntl.h>
+#include <sys/syscall.h>
+#include <unistd.h>
+
+#include <vector>
+
+#include "gtest/gtest.h"
+#include "test/util/posix_error.h"
+#include "test/util/temp_path.h"
+#include "test/util/test_util.h"
+#include "test/util/thread_util.h"
+
+#ifndef SYS_close_range
+#define SYS_close_range 436
+#endif
+
+#ifndef CLOSE_RANGE_UNSHARE
+#define CLOSE_RANGE_UNSHARE (1U << 1)
+#endif
+
+#ifndef CLOSE_RANGE_CLOEXEC
+#define CLOSE_RANGE_CLOEXEC (1U << 2)
+#endif
+
+namespace gvisor {
+namespace testing {
+
+namespace {
+
+int close_range(unsigned int first, unsigned int last, unsigned int flags) {
+  return syscall(SYS_close_range, first, last, flags);
+}
+
+class CloseRangeTest : public ::testing::Test {
+ protected:
+  void SetUp() override {
+    SKIP_IF(IsRunningWithVFS1());
+    if (close_range(~0U, ~0U, 0) < 0 && errno == ENOSYS) {
+      GTEST_SKIP() << "close_range not supported";
+    }
+    file_ = ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateFile());
+  }
+
+  // OpenFiles opens n files, and returns their file descriptors.
+  std::vector<int> OpenFiles(int n) {
+    std::vector<int> fds;
+    for (int i = 0; i < n; i++) {
+      int fd = open(file_.path().c_str(), O_RDONLY);
+      EXPECT_THAT(fd, SyscallSucceeds());
+      fds.push_back(fd);
+    }
+    return fds;
+  }
+
+  // CloseFiles closes the file descriptors in fds that are still open.
+  void CloseFiles(const std::vector<int>& fds) {
+    for (int fd : fds) {
+      close(fd);
+    }
+  }
+
+  TempPath file_;
+};
+
+TEST_F(CloseRangeTest, ContiguousRange) {
+  const std::vector<int> fds = OpenFiles(10);
+  ASSERT_THAT(close_range(fds[2], fds[7], 0), SyscallSucceeds());
+  for (int i = 0; i < fds.size(); i++) {
+    if (i >= 2 && i <= 7) {
+      EXPECT_THAT(fcntl(fds[i], F_GETFD), SyscallFailsWithErrno(EBADF));
+    } else {
+      EXPECT_THAT(fcntl(fds[i], F_GETFD), SyscallSucceeds());
+    }
+  }
+  CloseFiles(fds);
+}
+
+TEST_F(CloseRangeTest, RangeWithHoles) {
+  const std::vector<int> fds = OpenFiles(10);
+  ASSERT_THAT(close(fds[3]), SyscallSucceeds());
+  ASSERT_THAT(close(fds[5]), SyscallSucceeds());
+  ASSERT_THAT(close_range(fds[1], fds[8], 0), SyscallSucceeds());
+  for (int i = 1; i <= 8; i++) {
+    EXPECT_THAT(fcntl(fds[i], F_GETFD), SyscallFailsWithErrno(EBADF));
+  }
+  EXPECT_THAT(fcntl(fds[0], F_GETFD), SyscallSucceeds());
+  EXPECT_THAT(fcntl(fds[9], F_GETFD), SyscallSucceeds());
+  CloseFiles(fds);
+}
+
+TEST_F(CloseRangeTest, RangeToMax) {
+  const std::vector<int> fds = OpenFiles(10);
+  ASSERT_THAT(close_range(fds[5], ~0U, 0), SyscallSucceeds());
+  for (int i = 0; i < fds.size(); i++) {
+    if (i >= 5) {
+      EXPECT_THAT(fcntl(fds[i], F_GETFD), SyscallFailsWithErrno(EBADF));
+    } else {
+      EXPECT_THAT(fcntl(fds[i], F_GETFD), SyscallSucceeds());
+    }
+  }
+  CloseFiles(fds);
+}
+
+TEST_F(CloseRangeTest, CloseOnExec) {
+  const std::vector<int> fds = OpenFiles(10);
+  ASSERT_THAT(close_range(fds[2], fds[7], CLOSE_RANGE_CLOEXEC),
+              SyscallSucceeds());
+  for (int i = 0; i < fds.size(); i++) {
+    if (i >= 2 && i <= 7) {
+      EXPECT_THAT(fcntl(fds[i], F_GETFD), SyscallSucceedsWithValue(FD_CLOEXEC));
+    } else {
+      EXPECT_THAT(fcntl(fds[i], F_GETFD), SyscallSucceedsWithValue(0));
+    }
+  }
+  CloseFiles(fds);
+}
+
+TEST_F(CloseRangeTest, Unshare) {
+  const std::vector<int> fds = OpenFiles(10);
+
+  // Close the fds in a thread that shares the fd table, after unsharing it.
+  ScopedThread([&] {
+    EXPECT_THAT(close_range(fds[0], fds[9], CLOSE_RANGE_UNSHARE),
+                SyscallSucceeds());
+    EXPECT_THAT(fcntl(fds[0], F_GETFD), SyscallFailsWithErrno(EBADF));
+  });
+
+  // The fds are still open in this thread.
+  for (int fd : fds) {
+    EXPECT_THAT(fcntl(fd, F_GETFD), SyscallSucceeds());
+  }
+  CloseFiles(fds);
+}
+
+TEST_F(CloseRangeTest, InvalidArguments) {
+  EXPECT_THAT(close_range(10, 5, 0), SyscallFailsWithErrno(EINVAL));
+  EXPECT_THAT(close_range(5, 10, 1U << 0), SyscallFailsWithErrno(EINVAL));
+}
+
+TEST_F(CloseRangeTest, EmptyRange) {
+  // Closing a range without any open file descriptor succeeds.
+  EXPECT_THAT(close_range(~0U - 1, ~0U, 0), SyscallSucceeds());
+}
+
+}  // namespace
+
+}  // namespace testing
+}  // namespace gvisor
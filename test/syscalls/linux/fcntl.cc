@@ -1704,6 +1704,69 @@ TEST_F(FcntlSignalTest, SetSigDupUnregisterNew) {
   // siginfo is undefined in this case.
 }
 
+TEST_F(FcntlSignalTest, DirNotify) {
+  SKIP_IF(IsRunningWithVFS1());
+
+  const auto signal_cleanup =
+      ASSERT_NO_ERRNO_AND_VALUE(RegisterSignalHandler(SIGUSR1));
+  const TempPath dir = ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateDir());
+  const FileDescriptor fd =
+      ASSERT_NO_ERRNO_AND_VALUE(Open(dir.path(), O_RDONLY | O_DIRECTORY));
+  ASSERT_THAT(fcntl(fd.get(), F_SETSIG, SIGUSR1), SyscallSucceeds());
+  ASSERT_THAT(fcntl(fd.get(), F_NOTIFY, DN_CREATE), SyscallSucceeds());
+
+  const TempPath file =
+      ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateFileIn(dir.path()));
+  WaitForSignalDelivery(absl::Seconds(1));
+  ASSERT_EQ(num_signals_received_, 1);
+  SignalDelivery sig = signals_received_.front();
+  signals_received_.pop_front();
+  EXPECT_EQ(sig.num, SIGUSR1);
+  EXPECT_EQ(sig.info.si_fd, fd.get());
+
+  // Without DN_MULTISHOT, only the first event is signaled.
+  const TempPath file2 =
+      ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateFileIn(dir.path()));
+  absl::SleepFor(absl::Milliseconds(100));
+  EXPECT_EQ(num_signals_received_, 1);
+}
+
+TEST_F(FcntlSignalTest, DirNotifyMultishot) {
+  SKIP_IF(IsRunningWithVFS1());
+
+  const auto signal_cleanup =
+      ASSERT_NO_ERRNO_AND_VALUE(RegisterSignalHandler(SIGUSR1));
+  const TempPath dir = ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateDir());
+  const FileDescriptor fd =
+      ASSERT_NO_ERRNO_AND_VALUE(Open(dir.path(), O_RDONLY | O_DIRECTORY));
+  ASSERT_THAT(fcntl(fd.get(), F_SETSIG, SIGUSR1), SyscallSucceeds());
+  ASSERT_THAT(fcntl(fd.get(), F_NOTIFY, DN_CREATE | DN_DELETE | DN_MULTISHOT),
+              SyscallSucceeds());
+
+  TempPath file = ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateFileIn(dir.path()));
+  ASSERT_THAT(unlink(file.release().c_str()), SyscallSucceeds());
+  max_expected_signals = 2;
+  WaitForSignalDelivery(absl::Seconds(1));
+  EXPECT_EQ(num_signals_received_, 2);
+
+  // Notifications stop once no events are requested.
+  ASSERT_THAT(fcntl(fd.get(), F_NOTIFY, 0), SyscallSucceeds());
+  const TempPath file2 =
+      ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateFileIn(dir.path()));
+  absl::SleepFor(absl::Milliseconds(100));
+  EXPECT_EQ(num_signals_received_, 2);
+}
+
+TEST(FcntlTest, DirNotifyNotDir) {
+  SKIP_IF(IsRunningWithVFS1());
+
+  const TempPath file = ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateFile());
+  const FileDescriptor fd =
+      ASSERT_NO_ERRNO_AND_VALUE(Open(file.path(), O_RDONLY));
+  EXPECT_THAT(fcntl(fd.get(), F_NOTIFY, DN_MODIFY),
+              SyscallFailsWithErrno(ENOTDIR));
+}
+
 // Make sure that making multiple concurrent changes to async signal generation
 // does not cause any race issues.
 TEST(FcntlTest, SetFlSetOwnSetSigDoNotRace) {
@@ -0,0 +1,222 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#include <errno.h>
+#include <fcntl.h>
+#include <sys/stat.h>
+#include <unistd.h>
+
+#include <cstring>
+#include <string>
+#include <vector>
+
+#include "gtest/gtest.h"
+#include "test/util/capability_util.h"
+#include "test/util/file_descriptor.h"
+#include "test/util/fs_util.h"
+#include "test/util/temp_path.h"
+#include "test/util/test_util.h"
+
+namespace gvisor {
+namespace testing {
+
+namespace {
+
+// FileHandle is a struct file_handle with room for the largest handle.
+class FileHandle {
+ public:
+  FileHandle() : buf_(sizeof(struct file_handle) + MAX_HANDLE_SZ) {
+    get()->handle_bytes = MAX_HANDLE_SZ;
+  }
+
+  struct file_handle* get() {
+    return reinterpret_cast<struct file_handle*>(buf_.data());
+  }
+
+ private:
+  std::vector<char> buf_;
+};
+
+// NAME_TO_HANDLE_OR_SKIP gets a handle for path, or skips the test if the
+// filesystem containing path doesn't support file handles on Linux. gVisor
+// runs the test on gofer mounts, which must support them.
+#define NAME_TO_HANDLE_OR_SKIP(path, handle, mount_id)                      \
+  do {                                                                      \
+    int ret = name_to_handle_at(AT_FDCWD, (path), (handle), (mount_id), 0); \
+    if (ret < 0 && errno == EOPNOTSUPP && !IsRunningOnGvisor()) {           \
+      GTEST_SKIP() << "File handles not supported";                         \
+    }                                                                       \
+    ASSERT_THAT(ret, SyscallSucceeds());                                    \
+  } while (0)
+
+TEST(FileHandleTest, OpenByHandle) {
+  SKIP_IF(!ASSERT_NO_ERRNO_AND_VALUE(HaveCapability(CAP_DAC_READ_SEARCH)));
+
+  const TempPath file = ASSERT_NO_ERRNO_AND_VALUE(
+      TempPath::CreateFileWith(GetAbsoluteTestTmpdir(), "foo", 0644));
+  FileHandle handle;
+  int mount_id;
+  NAME_TO_HANDLE_OR_SKIP(file.path().c_str(), handle.get(), &mount_id);
+
+  const FileDescriptor dir =
+      ASSERT_NO_ERRNO_AND_VALUE(Open(GetAbsoluteTestTmpdir(), O_RDONLY));
+  int ret;
+  ASSERT_THAT(ret = open_by_handle_at(dir.get(), handle.get(), O_RDONLY),
+              SyscallSucceeds());
+  const FileDescriptor fd(ret);
+  char buf[3];
+  ASSERT_THAT(ReadFd(fd.get(), buf, sizeof(buf)),
+              SyscallSucceedsWithValue(sizeof(buf)));
+  EXPECT_EQ(std::string(buf, sizeof(buf)), "foo");
+}
+
+TEST(FileHandleTest, RenamedFile) {
+  SKIP_IF(!ASSERT_NO_ERRNO_AND_VALUE(HaveCapability(CAP_DAC_READ_SEARCH)));
+
+  const TempPath dir = ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateDir());
+  const std::string old_path = JoinPath(dir.path(), "old");
+  ASSERT_NO_ERRNO(CreateWithContents(old_path, "foo", 0644));
+  FileHandle handle;
+  int mount_id;
+  NAME_TO_HANDLE_OR_SKIP(old_path.c_str(), handle.get(), &mount_id);
+
+  // Handles don't depend on the path to the file.
+  const std::string new_path = JoinPath(dir.path(), "new");
+  ASSERT_THAT(rename(old_path.c_str(), new_path.c_str()), SyscallSucceeds());
+
+  const FileDescriptor mount_fd =
+      ASSERT_NO_ERRNO_AND_VALUE(Open(GetAbsoluteTestTmpdir(), O_RDONLY));
+  int ret;
+  ASSERT_THAT(ret = open_by_handle_at(mount_fd.get(), handle.get(), O_RDONLY),
+              SyscallSucceeds());
+  const FileDescriptor fd(ret);
+  char buf[3];
+  ASSERT_THAT(ReadFd(fd.get(), buf, sizeof(buf)),
+              SyscallSucceedsWithValue(sizeof(buf)));
+  EXPECT_EQ(std::string(buf, sizeof(buf)), "foo");
+  ASSERT_NO_ERRNO(Unlink(new_path));
+}
+
+TEST(FileHandleTest, LongPath) {
+  SKIP_IF(!ASSERT_NO_ERRNO_AND_VALUE(HaveCapability(CAP_DAC_READ_SEARCH)));
+
+  // The path is longer than MAX_HANDLE_SZ.
+  const TempPath dir = ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateDir());
+  std::string path = dir.path();
+  std::vector<TempPath> dirs;
+  while (path.size() <= MAX_HANDLE_SZ) {
+    path = JoinPath(path, std::string(32, 'd'));
+    ASSERT_NO_ERRNO(Mkdir(path));
+    dirs.emplace_back(path);
+  }
+  const TempPath file =
+      ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateFileWith(path, "foo", 0644));
+  FileHandle handle;
+  int mount_id;
+  NAME_TO_HANDLE_OR_SKIP(file.path().c_str(), handle.get(), &mount_id);
+
+  const FileDescriptor mount_fd =
+      ASSERT_NO_ERRNO_AND_VALUE(Open(GetAbsoluteTestTmpdir(), O_RDONLY));
+  int ret;
+  ASSERT_THAT(ret = open_by_handle_at(mount_fd.get(), handle.get(), O_RDONLY),
+              SyscallSucceeds());
+  const FileDescriptor fd(ret);
+  char buf[3];
+  ASSERT_THAT(ReadFd(fd.get(), buf, sizeof(buf)),
+              SyscallSucceedsWithValue(sizeof(buf)));
+  EXPECT_EQ(std::string(buf, sizeof(buf)), "foo");
+}
+
+TEST(FileHandleTest, HandleIsStable) {
+  const TempPath file = ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateFile());
+  FileHandle handle1;
+  int mount_id1;
+  NAME_TO_HANDLE_OR_SKIP(file.path().c_str(), handle1.get(), &mount_id1);
+  FileHandle handle2;
+  int mount_id2;
+  NAME_TO_HANDLE_OR_SKIP(file.path().c_str(), handle2.get(), &mount_id2);
+
+  EXPECT_EQ(mount_id1, mount_id2);
+  EXPECT_EQ(handle1.get()->handle_type, handle2.get()->handle_type);
+  ASSERT_EQ(handle1.get()->handle_bytes, handle2.get()->handle_bytes);
+  EXPECT_EQ(memcmp(handle1.get()->f_handle, handle2.get()->f_handle,
+                   handle1.get()->handle_bytes),
+            0);
+}
+
+TEST(FileHandleTest, HandleTooSmall) {
+  const TempPath file = ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateFile());
+  FileHandle handle;
+  int mount_id;
+  NAME_TO_HANDLE_OR_SKIP(file.path().c_str(), handle.get(), &mount_id);
+  const unsigned int size = handle.get()->handle_bytes;
+
+  handle.get()->handle_bytes = 0;
+  EXPECT_THAT(name_to_handle_at(AT_FDCWD, file.path().c_str(), handle.get(),
+                                &mount_id, 0),
+              SyscallFailsWithErrno(EOVERFLOW));
+  EXPECT_EQ(handle.get()->handle_bytes, size);
+}
+
+TEST(FileHandleTest, HandleTooLarge) {
+  const TempPath file = ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateFile());
+  FileHandle handle;
+  handle.get()->handle_bytes = MAX_HANDLE_SZ + 1;
+  int mount_id;
+  EXPECT_THAT(name_to_handle_at(AT_FDCWD, file.path().c_str(), handle.get(),
+                                &mount_id, 0),
+              SyscallFailsWithErrno(EINVAL));
+}
+
+TEST(FileHandleTest, InvalidFlags) {
+  const TempPath file = ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateFile());
+  FileHandle handle;
+  int mount_id;
+  EXPECT_THAT(name_to_handle_at(AT_FDCWD, file.path().c_str(), handle.get(),
+                                &mount_id, AT_REMOVEDIR),
+              SyscallFailsWithErrno(EINVAL));
+}
+
+TEST(FileHandleTest, DeletedFileIsStale) {
+  SKIP_IF(!ASSERT_NO_ERRNO_AND_VALUE(HaveCapability(CAP_DAC_READ_SEARCH)));
+
+  TempPath file = ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateFile());
+  FileHandle handle;
+  int mount_id;
+  NAME_TO_HANDLE_OR_SKIP(file.path().c_str(), handle.get(), &mount_id);
+
+  const FileDescriptor dir =
+      ASSERT_NO_ERRNO_AND_VALUE(Open(GetAbsoluteTestTmpdir(), O_RDONLY));
+  ASSERT_NO_ERRNO(Unlink(file.release()));
+  EXPECT_THAT(open_by_handle_at(dir.get(), handle.get(), O_RDONLY),
+              SyscallFailsWithErrno(ESTALE));
+}
+
+TEST(FileHandleTest, OpenByHandleRequiresCapability) {
+  const TempPath file = ASSERT_NO_ERRNO_AND_VALUE(TempPath::CreateFile());
+  FileHandle handle;
+  int mount_id;
+  NAME_TO_HANDLE_OR_SKIP(file.path().c_str(), handle.get(), &mount_id);
+
+  AutoCapability cap(CAP_DAC_READ_SEARCH, false);
+  const FileDescriptor dir =
+      ASSERT_NO_ERRNO_AND_VALUE(Open(GetAbsoluteTestTmpdir(), O_RDONLY));
+  EXPECT_THAT(open_by_handle_at(dir.get(), handle.get(), O_RDONLY),
+              SyscallFailsWithErrno(EPERM));
+}
+
+}  // namespace
+
+}  // namespace testing
+}  // namespace gvisor
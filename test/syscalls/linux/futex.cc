@@ -15,9 +15,11 @@
 #include <errno.h>
 #include <linux/futex.h>
 #include <linux/types.h>
+#include <pthread.h>
 #include <sys/syscall.h>
 #include <sys/time.h>
 #include <sys/types.h>
+#include <sys/wait.h>
 #include <syscall.h>
 #include <unistd.h>
 
@@ -829,6 +831,64 @@ TEST(RobustFutexTest, PthreadMutexAttr) {
   }
 }
 
+// SharedRobustMutex is placed in shared memory by RobustFutexInterprocessTest.
+struct SharedRobustMutex {
+  pthread_mutex_t mtx;
+  std::atomic<int> locked;
+};
+
+class RobustFutexInterprocessTest : public ::testing::TestWithParam<int> {};
+
+// Tests that a process blocked on a process-shared robust mutex is woken and
+// gets EOWNERDEAD when the owner in another process dies.
+TEST_P(RobustFutexInterprocessTest, OwnerDied) {
+  auto const mapping = ASSERT_NO_ERRNO_AND_VALUE(
+      MmapAnon(kPageSize, PROT_READ | PROT_WRITE, MAP_SHARED));
+  auto* const shared = static_cast<SharedRobustMutex*>(mapping.ptr());
+  shared->locked.store(0);
+
+  pthread_mutexattr_t attr;
+  ASSERT_EQ(pthread_mutexattr_init(&attr), 0);
+  ASSERT_EQ(pthread_mutexattr_setpshared(&attr, PTHREAD_PROCESS_SHARED), 0);
+  ASSERT_EQ(pthread_mutexattr_setrobust(&attr, PTHREAD_MUTEX_ROBUST), 0);
+  ASSERT_EQ(pthread_mutexattr_setprotocol(&attr, GetParam()), 0);
+  ASSERT_EQ(pthread_mutex_init(&shared->mtx, &attr), 0);
+  ASSERT_EQ(pthread_mutexattr_destroy(&attr), 0);
+
+  DisableSave ds;
+  pid_t const child_pid = fork();
+  if (child_pid == 0) {
+    TEST_PCHECK(pthread_mutex_lock(&shared->mtx) == 0);
+    shared->locked.store(1);
+    // Give the parent time to block on the mutex, then die while holding it.
+    absl::SleepFor(kWaiterStartupDelay);
+    _exit(0);
+  }
+  ASSERT_THAT(child_pid, SyscallSucceeds());
+
+  while (shared->locked.load() == 0) {
+    absl::SleepFor(absl::Milliseconds(10));
+  }
+  EXPECT_EQ(pthread_mutex_lock(&shared->mtx), EOWNERDEAD);
+  EXPECT_EQ(pthread_mutex_consistent(&shared->mtx), 0);
+  EXPECT_EQ(pthread_mutex_unlock(&shared->mtx), 0);
+
+  int status;
+  ASSERT_THAT(RetryEINTR(waitpid)(child_pid, &status, 0),
+              SyscallSucceedsWithValue(child_pid));
+  EXPECT_TRUE(WIFEXITED(status) && WEXITSTATUS(status) == 0)
+      << " status " << status;
+
+  // The mutex is usable again.
+  EXPECT_EQ(pthread_mutex_lock(&shared->mtx), 0);
+  EXPECT_EQ(pthread_mutex_unlock(&shared->mtx), 0);
+  EXPECT_EQ(pthread_mutex_destroy(&shared->mtx), 0);
+}
+
+INSTANTIATE_TEST_SUITE_P(Protocols, RobustFutexInterprocessTest,
+                         ::testing::Values(PTHREAD_PRIO_NONE,
+                                           PTHREAD_PRIO_INHERIT));
+
 }  // namespace
 }  // namespace testing
 }  // namespace gvisor
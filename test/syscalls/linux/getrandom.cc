@@ -16,6 +16,8 @@
 #include <sys/types.h>
 #include <unistd.h>
 
+#include <vector>
+
 #include "gtest/gtest.h"
 #include "test/util/test_util.h"
 
@@ -36,6 +38,16 @@ namespace {
 #endif
 #endif  // SYS_getrandom
 
+#ifndef GRND_NONBLOCK
+#define GRND_NONBLOCK 0x1
+#endif
+#ifndef GRND_RANDOM
+#define GRND_RANDOM 0x2
+#endif
+#ifndef GRND_INSECURE
+#define GRND_INSECURE 0x4
+#endif
+
 bool SomeByteIsNonZero(char* random_bytes, int length) {
   for (int i = 0; i < length; i++) {
     if (random_bytes[i] != 0) {
@@ -57,6 +69,46 @@ TEST(GetrandomTest, IsRandom) {
   EXPECT_TRUE(SomeByteIsNonZero(random_bytes, n));
 }
 
+TEST(GetrandomTest, Flags) {
+  char random_bytes[64] = {};
+  for (int flags : {GRND_NONBLOCK, GRND_RANDOM, GRND_NONBLOCK | GRND_RANDOM}) {
+    int n = syscall(SYS_getrandom, random_bytes, sizeof(random_bytes), flags);
+    SKIP_IF(!IsRunningOnGvisor() && n < 0 && errno == ENOSYS);
+    EXPECT_THAT(n, SyscallSucceedsWithValue(sizeof(random_bytes)))
+        << "flags: " << flags;
+  }
+}
+
+TEST(GetrandomTest, Insecure) {
+  char random_bytes[64] = {};
+  int n = syscall(SYS_getrandom, random_bytes, sizeof(random_bytes),
+                  GRND_INSECURE);
+  // GRND_INSECURE was added in Linux 5.6.
+  SKIP_IF(!IsRunningOnGvisor() && n < 0 &&
+          (errno == ENOSYS || errno == EINVAL));
+  EXPECT_THAT(n, SyscallSucceedsWithValue(sizeof(random_bytes)));
+
+  EXPECT_THAT(syscall(SYS_getrandom, random_bytes, sizeof(random_bytes),
+                      GRND_INSECURE | GRND_RANDOM),
+              SyscallFailsWithErrno(EINVAL));
+}
+
+TEST(GetrandomTest, InvalidFlags) {
+  char random_bytes[64] = {};
+  int n = syscall(SYS_getrandom, random_bytes, sizeof(random_bytes), 0x80);
+  SKIP_IF(!IsRunningOnGvisor() && n < 0 && errno == ENOSYS);
+  EXPECT_THAT(n, SyscallFailsWithErrno(EINVAL));
+}
+
+TEST(GetrandomTest, LargeRead) {
+  std::vector<char> random_bytes(1 << 20);
+  int n = syscall(SYS_getrandom, random_bytes.data(), random_bytes.size(), 0);
+  SKIP_IF(!IsRunningOnGvisor() && n < 0 && errno == ENOSYS);
+  ASSERT_THAT(n, SyscallSucceeds());
+  EXPECT_GE(n, 256);
+  EXPECT_TRUE(SomeByteIsNonZero(random_bytes.data() + n - 64, 64));
+}
+
 }  // namespace
 
 }  // namespace testing
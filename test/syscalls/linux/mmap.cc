@@ -740,6 +740,40 @@ TEST_F(MMapTest, ExceedLimitAS) {
       SyscallFailsWithErrno(ENOMEM));
 }
 
+TEST_F(MMapTest, ExceedLimitDataMmap) {
+  constexpr uint64_t kAllocBytes = 200 << 20;
+
+  struct rlimit oldlim;
+  ASSERT_THAT(getrlimit(RLIMIT_DATA, &oldlim), SyscallSucceeds());
+  auto restore_limit = Cleanup(
+      [&] { EXPECT_THAT(setrlimit(RLIMIT_DATA, &oldlim), SyscallSucceeds()); });
+
+  struct rlimit setlim;
+  setlim.rlim_cur = kPageSize;
+  setlim.rlim_max = oldlim.rlim_max;
+  ASSERT_THAT(setrlimit(RLIMIT_DATA, &setlim), SyscallSucceeds());
+  EXPECT_THAT(Map(0, kAllocBytes, PROT_READ | PROT_WRITE,
+                  MAP_PRIVATE | MAP_ANONYMOUS, -1, 0),
+              SyscallFailsWithErrno(ENOMEM));
+}
+
+TEST_F(MMapTest, LimitDataIgnoresReadOnlyMappings) {
+  constexpr uint64_t kAllocBytes = 200 << 20;
+
+  struct rlimit oldlim;
+  ASSERT_THAT(getrlimit(RLIMIT_DATA, &oldlim), SyscallSucceeds());
+  auto restore_limit = Cleanup(
+      [&] { EXPECT_THAT(setrlimit(RLIMIT_DATA, &oldlim), SyscallSucceeds()); });
+
+  struct rlimit setlim;
+  setlim.rlim_cur = kPageSize;
+  setlim.rlim_max = oldlim.rlim_max;
+  ASSERT_THAT(setrlimit(RLIMIT_DATA, &setlim), SyscallSucceeds());
+  EXPECT_THAT(
+      Map(0, kAllocBytes, PROT_READ, MAP_PRIVATE | MAP_ANONYMOUS, -1, 0),
+      SyscallSucceedsWithValue(Gt(0)));
+}
+
 // Tests that setting an anonymous mmap to PROT_NONE doesn't free the memory.
 TEST_F(MMapTest, SettingProtNoneDoesntFreeMemory) {
   uintptr_t addr;
@@ -0,0 +1,294 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+#include <errno.h>
+#include <fcntl.h>
+#include <poll.h>
+#include <sched.h>
+#include <signal.h>
+#include <sys/syscall.h>
+#include <sys/types.h>
+#include <sys/wait.h>
+#include <unistd.h>
+
+#include "gtest/gtest.h"
+#include "test/util/file_descriptor.h"
+#include "test/util/posix_error.h"
+#include "test/util/test_util.h"
+#include "test/util/thread_util.h"
+
+#ifndef SYS_pidfd_send_signal
+#define SYS_pidfd_send_signal 424
+#endif
+
+#ifndef SYS_pidfd_open
+#define SYS_pidfd_open 434
+#endif
+
+#ifndef CLONE_PIDFD
+#define CLONE_PIDFD 0x1000
+#endif
+
+#ifndef P_PIDFD
+#define P_PIDFD 3
+#endif
+
+namespace gvisor {
+namespace testing {
+
+namespace {
+
+int pidfd_open(pid_t pid, unsigned int flags) {
+  return syscall(SYS_pidfd_open, pid, flags);
+}
+
+int pidfd_send_signal(int pidfd, int sig, siginfo_t* info,
+                      unsigned int flags) {
+  return syscall(SYS_pidfd_send_signal, pidfd, sig, info, flags);
+}
+
+// SKIP_IF_NO_PIDFD skips the test if pidfds aren't supported.
+#define SKIP_IF_NO_PIDFD()                                       \
+  do {                                                           \
+    SKIP_IF(IsRunningWithVFS1());                                \
+    int fd = pidfd_open(getpid(), 0);                            \
+    if (fd < 0 && errno == ENOSYS) {                             \
+      GTEST_SKIP() << "pidfd_open not supported";                \
+    }                                                            \
+    ASSERT_THAT(fd, SyscallSucceeds());                          \
+    close(fd);                                                   \
+  } while (0)
+
+// ForkPaused forks a child that waits to be killed, and returns its PID.
+PosixErrorOr<pid_t> ForkPaused() {
+  pid_t pid = fork();
+  if (pid == 0) {
+    while (true) {
+      pause();
+    }
+  }
+  if (pid < 0) {
+    return PosixError(errno, "fork");
+  }
+  return pid;
+}
+
+TEST(PidfdTest, Open) {
+  SKIP_IF_NO_PIDFD();
+
+  int ret;
+  ASSERT_THAT(ret = pidfd_open(getpid(), 0), SyscallSucceeds());
+  const FileDescriptor pidfd(ret);
+  EXPECT_THAT(fcntl(pidfd.get(), F_GETFD),
+              SyscallSucceedsWithValue(FD_CLOEXEC));
+  EXPECT_THAT(fcntl(pidfd.get(), F_GETFL),
+              SyscallSucceedsWithValue(O_RDWR));
+
+  // A pidfd can't be read.
+  char c;
+  EXPECT_THAT(read(pidfd.get(), &c, 1), SyscallFailsWithErrno(EINVAL));
+}
+
+TEST(PidfdTest, OpenNonblock) {
+  SKIP_IF_NO_PIDFD();
+
+  int ret;
+  ASSERT_THAT(ret = pidfd_open(getpid(), O_NONBLOCK), SyscallSucceeds());
+  const FileDescriptor pidfd(ret);
+  EXPECT_THAT(fcntl(pidfd.get(), F_GETFL),
+              SyscallSucceedsWithValue(O_RDWR | O_NONBLOCK));
+}
+
+TEST(PidfdTest, OpenInvalid) {
+  SKIP_IF_NO_PIDFD();
+
+  EXPECT_THAT(pidfd_open(getpid(), O_CLOEXEC), SyscallFailsWithErrno(EINVAL));
+  EXPECT_THAT(pidfd_open(0, 0), SyscallFailsWithErrno(EINVAL));
+  EXPECT_THAT(pidfd_open(-1, 0), SyscallFailsWithErrno(EINVAL));
+}
+
+TEST(PidfdTest, OpenThread) {
+  SKIP_IF_NO_PIDFD();
+
+  ScopedThread([] {
+    EXPECT_THAT(pidfd_open(syscall(SYS_gettid), 0),
+                SyscallFailsWithErrno(EINVAL));
+  });
+}
+
+TEST(PidfdTest, OpenReaped) {
+  SKIP_IF_NO_PIDFD();
+
+  pid_t child = fork();
+  if (child == 0) {
+    _exit(0);
+  }
+  ASSERT_THAT(child, SyscallSucceeds());
+  int status;
+  ASSERT_THAT(RetryEINTR(waitpid)(child, &status, 0),
+              SyscallSucceedsWithValue(child));
+  EXPECT_THAT(pidfd_open(child, 0), SyscallFailsWithErrno(ESRCH));
+}
+
+TEST(PidfdTest, PollAndWait) {
+  SKIP_IF_NO_PIDFD();
+
+  const pid_t child = ASSERT_NO_ERRNO_AND_VALUE(ForkPaused());
+  int ret;
+  ASSERT_THAT(ret = pidfd_open(child, 0), SyscallSucceeds());
+  const FileDescriptor pidfd(ret);
+
+  struct pollfd pfd = {.fd = pidfd.get(), .events = POLLIN};
+  EXPECT_THAT(RetryEINTR(poll)(&pfd, 1, 0), SyscallSucceedsWithValue(0));
+
+  ASSERT_THAT(pidfd_send_signal(pidfd.get(), SIGKILL, nullptr, 0),
+              SyscallSucceeds());
+  EXPECT_THAT(RetryEINTR(poll)(&pfd, 1, -1), SyscallSucceedsWithValue(1));
+  EXPECT_EQ(pfd.revents, POLLIN);
+
+  siginfo_t info = {};
+  ASSERT_THAT(RetryEINTR(waitid)(static_cast<idtype_t>(P_PIDFD), pidfd.get(),
+                                 &info, WEXITED),
+              SyscallSucceeds());
+  EXPECT_EQ(info.si_pid, child);
+  EXPECT_EQ(info.si_code, CLD_KILLED);
+  EXPECT_EQ(info.si_status, SIGKILL);
+
+  // The child has been reaped.
+  EXPECT_THAT(pidfd_send_signal(pidfd.get(), SIGKILL, nullptr, 0),
+              SyscallFailsWithErrno(ESRCH));
+  EXPECT_THAT(waitid(static_cast<idtype_t>(P_PIDFD), pidfd.get(), &info,
+                     WEXITED | WNOHANG),
+              SyscallFailsWithErrno(ECHILD));
+}
+
+TEST(PidfdTest, WaitNonblock) {
+  SKIP_IF_NO_PIDFD();
+
+  const pid_t child = ASSERT_NO_ERRNO_AND_VALUE(ForkPaused());
+  int ret;
+  ASSERT_THAT(ret = pidfd_open(child, O_NONBLOCK), SyscallSucceeds());
+  const FileDescriptor pidfd(ret);
+
+  siginfo_t info = {};
+  EXPECT_THAT(waitid(static_cast<idtype_t>(P_PIDFD), pidfd.get(), &info,
+                     WEXITED),
+              SyscallFailsWithErrno(EAGAIN));
+  EXPECT_THAT(waitid(static_cast<idtype_t>(P_PIDFD), pidfd.get(), &info,
+                     WEXITED | WNOHANG),
+              SyscallSucceeds());
+  EXPECT_EQ(info.si_pid, 0);
+
+  ASSERT_THAT(kill(child, SIGKILL), SyscallSucceeds());
+  int status;
+  ASSERT_THAT(RetryEINTR(waitpid)(child, &status, 0),
+              SyscallSucceedsWithValue(child));
+}
+
+TEST(PidfdTest, WaitNotPidfd) {
+  SKIP_IF_NO_PIDFD();
+
+  siginfo_t info;
+  EXPECT_THAT(waitid(static_cast<idtype_t>(P_PIDFD), STDIN_FILENO, &info,
+                     WEXITED | WNOHANG),
+              SyscallFailsWithErrno(EINVAL));
+}
+
+TEST(PidfdTest, SendSignalInvalid) {
+  SKIP_IF_NO_PIDFD();
+
+  int ret;
+  ASSERT_THAT(ret = pidfd_open(getpid(), 0), SyscallSucceeds());
+  const FileDescriptor pidfd(ret);
+
+  EXPECT_THAT(pidfd_send_signal(pidfd.get(), 0, nullptr, 1),
+              SyscallFailsWithErrno(EINVAL));
+  EXPECT_THAT(pidfd_send_signal(STDIN_FILENO, 0, nullptr, 0),
+              SyscallFailsWithErrno(EBADF));
+
+  // info must match the signal.
+  siginfo_t info = {};
+  info.si_signo = SIGUSR1;
+  info.si_code = SI_QUEUE;
+  EXPECT_THAT(pidfd_send_signal(pidfd.get(), SIGUSR2, &info, 0),
+              SyscallFailsWithErrno(EINVAL));
+}
+
+TEST(PidfdTest, SendSignalInfoToOther) {
+  SKIP_IF_NO_PIDFD();
+
+  const pid_t child = ASSERT_NO_ERRNO_AND_VALUE(ForkPaused());
+  int ret;
+  ASSERT_THAT(ret = pidfd_open(child, 0), SyscallSucceeds());
+  const FileDescriptor pidfd(ret);
+
+  // Other processes can't be sent signals that appear to come from the
+  // kernel.
+  siginfo_t info = {};
+  info.si_signo = SIGKILL;
+  info.si_code = SI_KERNEL;
+  EXPECT_THAT(pidfd_send_signal(pidfd.get(), SIGKILL, &info, 0),
+              SyscallFailsWithErrno(EPERM));
+
+  info.si_code = SI_QUEUE;
+  ASSERT_THAT(pidfd_send_signal(pidfd.get(), SIGKILL, &info, 0),
+              SyscallSucceeds());
+  int status;
+  ASSERT_THAT(RetryEINTR(waitpid)(child, &status, 0),
+              SyscallSucceedsWithValue(child));
+  EXPECT_TRUE(WIFSIGNALED(status) && WTERMSIG(status) == SIGKILL)
+      << "status = " << status;
+}
+
+TEST(PidfdTest, ClonePidfd) {
+  SKIP_IF_NO_PIDFD();
+
+  int pidfd = -1;
+  // The parent TID argument is the third on all architectures.
+  pid_t child = syscall(SYS_clone, CLONE_PIDFD | SIGCHLD, nullptr, &pidfd,
+                        nullptr, nullptr);
+  if (child == 0) {
+    _exit(42);
+  }
+  ASSERT_THAT(child, SyscallSucceeds());
+  const FileDescriptor fd(pidfd);
+  EXPECT_THAT(fcntl(fd.get(), F_GETFD), SyscallSucceedsWithValue(FD_CLOEXEC));
+
+  siginfo_t info = {};
+  ASSERT_THAT(
+      RetryEINTR(waitid)(static_cast<idtype_t>(P_PIDFD), fd.get(), &info,
+                         WEXITED),
+      SyscallSucceeds());
+  EXPECT_EQ(info.si_pid, child);
+  EXPECT_EQ(info.si_code, CLD_EXITED);
+  EXPECT_EQ(info.si_status, 42);
+}
+
+TEST(PidfdTest, ClonePidfdInvalid) {
+  SKIP_IF_NO_PIDFD();
+
+  int pidfd = -1;
+  EXPECT_THAT(syscall(SYS_clone, CLONE_PIDFD | CLONE_PARENT_SETTID | SIGCHLD,
+                      nullptr, &pidfd, nullptr, nullptr),
+              SyscallFailsWithErrno(EINVAL));
+  EXPECT_THAT(syscall(SYS_clone,
+                      CLONE_PIDFD | CLONE_THREAD | CLONE_SIGHAND | CLONE_VM,
+                      nullptr, &pidfd, nullptr, nullptr),
+              SyscallFailsWithErrno(EINVAL));
+}
+
+}  // namespace
+
+}  // namespace testing
+}  // namespace gvisor
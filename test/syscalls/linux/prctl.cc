@@ -220,6 +220,51 @@ TEST(PrctlTest, SetGetSubreaper) {
   EXPECT_THAT(prctl(PR_SET_CHILD_SUBREAPER, 1), SyscallSucceeds());
 }
 
+TEST(PrctlTest, SetGetTimerSlack) {
+  int before;
+  ASSERT_THAT(before = prctl(PR_GET_TIMERSLACK), SyscallSucceeds());
+  auto cleanup = Cleanup([before] {
+    ASSERT_THAT(prctl(PR_SET_TIMERSLACK, before), SyscallSucceeds());
+  });
+
+  constexpr unsigned long kSlackNanos = 1000;
+  EXPECT_THAT(prctl(PR_SET_TIMERSLACK, kSlackNanos), SyscallSucceeds());
+  EXPECT_THAT(prctl(PR_GET_TIMERSLACK), SyscallSucceedsWithValue(kSlackNanos));
+
+  // Setting the timer slack to 0 resets it to the default, which is the value
+  // it had when this process was created.
+  EXPECT_THAT(prctl(PR_SET_TIMERSLACK, 0), SyscallSucceeds());
+  EXPECT_THAT(prctl(PR_GET_TIMERSLACK), SyscallSucceedsWithValue(before));
+}
+
+TEST(PrctlTest, TimerSlackInheritedOnFork) {
+  int before;
+  ASSERT_THAT(before = prctl(PR_GET_TIMERSLACK), SyscallSucceeds());
+  auto cleanup = Cleanup([before] {
+    ASSERT_THAT(prctl(PR_SET_TIMERSLACK, before), SyscallSucceeds());
+  });
+
+  constexpr unsigned long kSlackNanos = 1000;
+  ASSERT_THAT(prctl(PR_SET_TIMERSLACK, kSlackNanos), SyscallSucceeds());
+
+  pid_t child_pid = fork();
+  TEST_PCHECK(child_pid >= 0);
+  if (child_pid == 0) {
+    TEST_CHECK(prctl(PR_GET_TIMERSLACK) == kSlackNanos);
+    // The child's default timer slack is the parent's current timer slack.
+    TEST_PCHECK(prctl(PR_SET_TIMERSLACK, 2 * kSlackNanos) == 0);
+    TEST_PCHECK(prctl(PR_SET_TIMERSLACK, 0) == 0);
+    TEST_CHECK(prctl(PR_GET_TIMERSLACK) == kSlackNanos);
+    _exit(0);
+  }
+
+  int status;
+  ASSERT_THAT(waitpid(child_pid, &status, 0),
+              SyscallSucceedsWithValue(child_pid));
+  EXPECT_TRUE(WIFEXITED(status) && WEXITSTATUS(status) == 0)
+      << "status =" << status;
+}
+
 }  // namespace
 
 }  // namespace testing
@@ -15,6 +15,7 @@
 #include <elf.h>
 #include <errno.h>
 #include <fcntl.h>
+#include <inttypes.h>
 #include <limits.h>
 #include <linux/magic.h>
 #include <sched.h>
@@ -1409,6 +1410,42 @@ TEST(ProcLoadavg, Fields) {
   EXPECT_TRUE(absl::SimpleAtoi(fields[5], &val2)) << proc_loadvg;
 }
 
+class ProcPressureTest : public ::testing::TestWithParam<std::string> {};
+
+TEST_P(ProcPressureTest, Fields) {
+  const std::string path = absl::StrCat("/proc/pressure/", GetParam());
+  // Linux only has /proc/pressure with CONFIG_PSI.
+  const bool exists = ASSERT_NO_ERRNO_AND_VALUE(Exists(path));
+  SKIP_IF(!IsRunningOnGvisor() && !exists);
+
+  std::string contents = ASSERT_NO_ERRNO_AND_VALUE(GetContents(path));
+  EXPECT_EQ(contents.back(), '\n');
+  std::vector<std::string> lines =
+      absl::StrSplit(contents, '\n', absl::SkipEmpty());
+  // Linux omits the "full" line of /proc/pressure/cpu before 5.13.
+  ASSERT_GE(lines.size(), 1) << contents;
+  ASSERT_LE(lines.size(), 2) << contents;
+
+  for (size_t i = 0; i < lines.size(); i++) {
+    double avg10, avg60, avg300;
+    uint64_t total;
+    char kind[5];
+    ASSERT_EQ(sscanf(lines[i].c_str(),
+                     "%4s avg10=%lf avg60=%lf avg300=%lf total=%" SCNu64, kind,
+                     &avg10, &avg60, &avg300, &total),
+              5)
+        << lines[i];
+    EXPECT_STREQ(kind, i == 0 ? "some" : "full");
+    for (double avg : {avg10, avg60, avg300}) {
+      EXPECT_GE(avg, 0) << lines[i];
+      EXPECT_LE(avg, 100) << lines[i];
+    }
+  }
+}
+
+INSTANTIATE_TEST_SUITE_P(Resources, ProcPressureTest,
+                         ::testing::Values("cpu", "memory", "io"));
+
 // NOTE: Tests in priority.cc also check certain priority related fields in
 // /proc/self/stat.
 
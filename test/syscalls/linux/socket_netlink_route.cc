@@ -599,6 +599,104 @@ TEST(NetlinkRouteTest, GetAddrDump) {
       false));
 }
 
+TEST(NetlinkRouteTest, GetNeighDump) {
+  FileDescriptor fd =
+      ASSERT_NO_ERRNO_AND_VALUE(NetlinkBoundSocket(NETLINK_ROUTE));
+  uint32_t port = ASSERT_NO_ERRNO_AND_VALUE(NetlinkPortID(fd.get()));
+
+  struct request {
+    struct nlmsghdr hdr;
+    struct ndmsg ndm;
+  };
+
+  struct request req = {};
+  req.hdr.nlmsg_len = sizeof(req);
+  req.hdr.nlmsg_type = RTM_GETNEIGH;
+  req.hdr.nlmsg_flags = NLM_F_REQUEST | NLM_F_DUMP;
+  req.hdr.nlmsg_seq = kSeq;
+  req.ndm.ndm_family = AF_INET;
+
+  ASSERT_NO_ERRNO(NetlinkRequestResponse(
+      fd, &req, sizeof(req),
+      [&](const struct nlmsghdr* hdr) {
+        EXPECT_THAT(hdr->nlmsg_type, AnyOf(Eq(RTM_NEWNEIGH), Eq(NLMSG_DONE)));
+
+        EXPECT_TRUE((hdr->nlmsg_flags & NLM_F_MULTI) == NLM_F_MULTI)
+            << std::hex << hdr->nlmsg_flags;
+
+        EXPECT_EQ(hdr->nlmsg_seq, kSeq);
+        EXPECT_EQ(hdr->nlmsg_pid, port);
+
+        if (hdr->nlmsg_type != RTM_NEWNEIGH) {
+          return;
+        }
+
+        // RTM_NEWNEIGH contains at least the header and ndmsg.
+        ASSERT_GE(hdr->nlmsg_len, NLMSG_SPACE(sizeof(struct ndmsg)));
+        const struct ndmsg* msg =
+            reinterpret_cast<const struct ndmsg*>(NLMSG_DATA(hdr));
+        EXPECT_EQ(msg->ndm_family, AF_INET);
+      },
+      false));
+}
+
+TEST(NetlinkRouteTest, AddAndRemoveNeigh) {
+  SKIP_IF(!ASSERT_NO_ERRNO_AND_VALUE(HaveCapability(CAP_NET_ADMIN)));
+  // Don't do cooperative save/restore because netstack state is not restored.
+  // TODO(gvisor.dev/issue/4595): enable cooperative save tests.
+  const DisableSave ds;
+
+  auto link_or = EthernetLink();
+  if (!link_or.ok()) {
+    GTEST_SKIP() << "no Ethernet link: " << link_or.error();
+  }
+  Link link = link_or.ValueOrDie();
+
+  struct in_addr addr;
+  ASSERT_EQ(inet_pton(AF_INET, "10.0.0.42", &addr), 1);
+  const uint8_t old_lladdr[] = {0x02, 0x00, 0x00, 0x00, 0x00, 0x41};
+  const uint8_t lladdr[] = {0x02, 0x00, 0x00, 0x00, 0x00, 0x42};
+
+  // Create should succeed, as no such entry in the neighbor table.
+  ASSERT_NO_ERRNO(NeighAdd(link.index, AF_INET, &addr, sizeof(addr),
+                           old_lladdr, sizeof(old_lladdr)));
+
+  // Adding again should replace the link-layer address of the entry.
+  ASSERT_NO_ERRNO(NeighAdd(link.index, AF_INET, &addr, sizeof(addr), lladdr,
+                           sizeof(lladdr)));
+
+  const std::string want_addr(reinterpret_cast<const char*>(&addr),
+                              sizeof(addr));
+  const std::string want_lladdr(reinterpret_cast<const char*>(lladdr),
+                                sizeof(lladdr));
+  std::vector<Neighbor> neighbors =
+      ASSERT_NO_ERRNO_AND_VALUE(DumpNeighbors(AF_INET));
+  bool found = false;
+  for (const auto& neigh : neighbors) {
+    if (neigh.addr != want_addr) {
+      continue;
+    }
+    found = true;
+    EXPECT_EQ(neigh.index, link.index);
+    EXPECT_TRUE((neigh.state & NUD_PERMANENT) == NUD_PERMANENT)
+        << std::hex << neigh.state;
+    EXPECT_EQ(neigh.lladdr, want_lladdr);
+  }
+  EXPECT_TRUE(found);
+
+  // Remove should succeed, as the entry was added above.
+  ASSERT_NO_ERRNO(NeighDel(link.index, AF_INET, &addr, sizeof(addr)));
+
+  neighbors = ASSERT_NO_ERRNO_AND_VALUE(DumpNeighbors(AF_INET));
+  for (const auto& neigh : neighbors) {
+    EXPECT_NE(neigh.addr, want_addr);
+  }
+
+  // Remove again should fail, as the entry was removed above.
+  EXPECT_THAT(NeighDel(link.index, AF_INET, &addr, sizeof(addr)),
+              PosixErrorIs(ENOENT, _));
+}
+
 TEST(NetlinkRouteTest, LookupAll) {
   struct ifaddrs* if_addr_list = nullptr;
   auto cleanup = Cleanup([&if_addr_list]() { freeifaddrs(if_addr_list); });
@@ -655,6 +753,86 @@ TEST(NetlinkRouteTest, AddAndRemoveAddr) {
               PosixErrorIs(EEXIST, _));
 }
 
+TEST(NetlinkRouteTest, AddAndRemoveRoute) {
+  SKIP_IF(!ASSERT_NO_ERRNO_AND_VALUE(HaveCapability(CAP_NET_ADMIN)));
+  // Don't do cooperative save/restore because netstack state is not restored.
+  // TODO(gvisor.dev/issue/4595): enable cooperative save tests.
+  const DisableSave ds;
+
+  Link loopback_link = ASSERT_NO_ERRNO_AND_VALUE(LoopbackLink());
+
+  struct in_addr dst;
+  ASSERT_EQ(inet_pton(AF_INET, "10.1.0.0", &dst), 1);
+
+  // Create should succeed, as no such route in kernel.
+  ASSERT_NO_ERRNO(RouteAddExclusive(loopback_link.index, AF_INET,
+                                    /*dst_len=*/16, &dst, sizeof(dst)));
+
+  Cleanup defer_route_removal = Cleanup(
+      [loopback_link = std::move(loopback_link), dst = std::move(dst)] {
+        // First delete should succeed, as route exists.
+        EXPECT_NO_ERRNO(RouteDel(loopback_link.index, AF_INET,
+                                 /*dst_len=*/16, &dst, sizeof(dst)));
+
+        // Second delete should fail, as route no longer exists.
+        EXPECT_THAT(RouteDel(loopback_link.index, AF_INET,
+                             /*dst_len=*/16, &dst, sizeof(dst)),
+                    PosixErrorIs(ESRCH, _));
+      });
+
+  // Create exclusive should fail, as we created the route above.
+  EXPECT_THAT(RouteAddExclusive(loopback_link.index, AF_INET,
+                                /*dst_len=*/16, &dst, sizeof(dst)),
+              PosixErrorIs(EEXIST, _));
+
+  // Replace an existing route should succeed.
+  EXPECT_NO_ERRNO(RouteReplace(loopback_link.index, AF_INET,
+                               /*dst_len=*/16, &dst, sizeof(dst),
+                               /*create=*/false));
+}
+
+TEST(NetlinkRouteTest, ReplaceRoute) {
+  SKIP_IF(!ASSERT_NO_ERRNO_AND_VALUE(HaveCapability(CAP_NET_ADMIN)));
+  // Don't do cooperative save/restore because netstack state is not restored.
+  // TODO(gvisor.dev/issue/4595): enable cooperative save tests.
+  const DisableSave ds;
+
+  Link loopback_link = ASSERT_NO_ERRNO_AND_VALUE(LoopbackLink());
+
+  struct in_addr dst;
+  ASSERT_EQ(inet_pton(AF_INET, "10.2.0.0", &dst), 1);
+
+  // Replace without NLM_F_CREATE should fail, as no such route in kernel.
+  EXPECT_THAT(RouteReplace(loopback_link.index, AF_INET,
+                           /*dst_len=*/16, &dst, sizeof(dst),
+                           /*create=*/false),
+              PosixErrorIs(ENOENT, _));
+
+  // Replace with NLM_F_CREATE should create the route.
+  ASSERT_NO_ERRNO(RouteReplace(loopback_link.index, AF_INET,
+                               /*dst_len=*/16, &dst, sizeof(dst),
+                               /*create=*/true));
+  EXPECT_NO_ERRNO(RouteDel(loopback_link.index, AF_INET,
+                           /*dst_len=*/16, &dst, sizeof(dst)));
+}
+
+TEST(NetlinkRouteTest, AddRouteHostBits) {
+  SKIP_IF(!ASSERT_NO_ERRNO_AND_VALUE(HaveCapability(CAP_NET_ADMIN)));
+  // Don't do cooperative save/restore because netstack state is not restored.
+  // TODO(gvisor.dev/issue/4595): enable cooperative save tests.
+  const DisableSave ds;
+
+  Link loopback_link = ASSERT_NO_ERRNO_AND_VALUE(LoopbackLink());
+
+  struct in_addr dst;
+  ASSERT_EQ(inet_pton(AF_INET, "10.3.0.1", &dst), 1);
+
+  // The destination has host bits set.
+  EXPECT_THAT(RouteAddExclusive(loopback_link.index, AF_INET,
+                                /*dst_len=*/16, &dst, sizeof(dst)),
+              PosixErrorIs(EINVAL, _));
+}
+
 // GetRouteDump tests a RTM_GETROUTE + NLM_F_DUMP request.
 TEST(NetlinkRouteTest, GetRouteDump) {
   FileDescriptor fd =
@@ -92,6 +92,89 @@ PosixError LinkModifyLocalAddr(int index, int family, int prefixlen,
   return NetlinkRequestAckOrError(fd, kSeq, &req, req.hdr.nlmsg_len);
 }
 
+// Adds or removes the route to the destination through the interface.
+PosixError RouteModify(int index, int family, int dst_len, const void* dst,
+                       int addrlen, uint16_t type, uint16_t flags) {
+  ASSIGN_OR_RETURN_ERRNO(FileDescriptor fd, NetlinkBoundSocket(NETLINK_ROUTE));
+
+  struct request {
+    struct nlmsghdr hdr;
+    struct rtmsg rtm;
+    char attrbuf[512];
+  };
+
+  struct request req = {};
+  req.hdr.nlmsg_len = NLMSG_LENGTH(sizeof(req.rtm));
+  req.hdr.nlmsg_type = type;
+  req.hdr.nlmsg_flags = NLM_F_REQUEST | NLM_F_ACK | flags;
+  req.hdr.nlmsg_seq = kSeq;
+  req.rtm.rtm_family = family;
+  req.rtm.rtm_dst_len = dst_len;
+  req.rtm.rtm_table = RT_TABLE_MAIN;
+  req.rtm.rtm_protocol = RTPROT_BOOT;
+  req.rtm.rtm_scope = RT_SCOPE_LINK;
+  req.rtm.rtm_type = RTN_UNICAST;
+
+  struct rtattr* rta = reinterpret_cast<struct rtattr*>(
+      reinterpret_cast<int8_t*>(&req) + NLMSG_ALIGN(req.hdr.nlmsg_len));
+  rta->rta_type = RTA_DST;
+  rta->rta_len = RTA_LENGTH(addrlen);
+  req.hdr.nlmsg_len = NLMSG_ALIGN(req.hdr.nlmsg_len) + RTA_LENGTH(addrlen);
+  memcpy(RTA_DATA(rta), dst, addrlen);
+
+  rta = reinterpret_cast<struct rtattr*>(reinterpret_cast<int8_t*>(&req) +
+                                         NLMSG_ALIGN(req.hdr.nlmsg_len));
+  rta->rta_type = RTA_OIF;
+  rta->rta_len = RTA_LENGTH(sizeof(index));
+  req.hdr.nlmsg_len =
+      NLMSG_ALIGN(req.hdr.nlmsg_len) + RTA_LENGTH(sizeof(index));
+  memcpy(RTA_DATA(rta), &index, sizeof(index));
+
+  return NetlinkRequestAckOrError(fd, kSeq, &req, req.hdr.nlmsg_len);
+}
+
+// NeighModify modifies the neighbor table of the interface with a
+// RTM_NEWNEIGH or RTM_DELNEIGH request. lladdr is only set if lladdrlen > 0.
+PosixError NeighModify(int index, int family, const void* addr, int addrlen,
+                       const void* lladdr, int lladdrlen, uint16_t type,
+                       uint16_t flags) {
+  ASSIGN_OR_RETURN_ERRNO(FileDescriptor fd, NetlinkBoundSocket(NETLINK_ROUTE));
+
+  struct request {
+    struct nlmsghdr hdr;
+    struct ndmsg ndm;
+    char attrbuf[512];
+  };
+
+  struct request req = {};
+  req.hdr.nlmsg_len = NLMSG_LENGTH(sizeof(req.ndm));
+  req.hdr.nlmsg_type = type;
+  req.hdr.nlmsg_flags = NLM_F_REQUEST | NLM_F_ACK | flags;
+  req.hdr.nlmsg_seq = kSeq;
+  req.ndm.ndm_family = family;
+  req.ndm.ndm_ifindex = index;
+  req.ndm.ndm_state = NUD_PERMANENT;
+
+  struct rtattr* rta = reinterpret_cast<struct rtattr*>(
+      reinterpret_cast<int8_t*>(&req) + NLMSG_ALIGN(req.hdr.nlmsg_len));
+  rta->rta_type = NDA_DST;
+  rta->rta_len = RTA_LENGTH(addrlen);
+  req.hdr.nlmsg_len = NLMSG_ALIGN(req.hdr.nlmsg_len) + RTA_LENGTH(addrlen);
+  memcpy(RTA_DATA(rta), addr, addrlen);
+
+  if (lladdrlen > 0) {
+    rta = reinterpret_cast<struct rtattr*>(reinterpret_cast<int8_t*>(&req) +
+                                           NLMSG_ALIGN(req.hdr.nlmsg_len));
+    rta->rta_type = NDA_LLADDR;
+    rta->rta_len = RTA_LENGTH(lladdrlen);
+    req.hdr.nlmsg_len =
+        NLMSG_ALIGN(req.hdr.nlmsg_len) + RTA_LENGTH(lladdrlen);
+    memcpy(RTA_DATA(rta), lladdr, lladdrlen);
+  }
+
+  return NetlinkRequestAckOrError(fd, kSeq, &req, req.hdr.nlmsg_len);
+}
+
 }  // namespace
 
 PosixError DumpLinks(
@@ -148,6 +231,64 @@ PosixErrorOr<Link> LoopbackLink() {
   return PosixError(ENOENT, "loopback link not found");
 }
 
+PosixErrorOr<Link> EthernetLink() {
+  ASSIGN_OR_RETURN_ERRNO(auto links, DumpLinks());
+  for (const auto& link : links) {
+    if (link.type == ARPHRD_ETHER) {
+      return link;
+    }
+  }
+  return PosixError(ENOENT, "Ethernet link not found");
+}
+
+PosixErrorOr<std::vector<Neighbor>> DumpNeighbors(int family) {
+  ASSIGN_OR_RETURN_ERRNO(FileDescriptor fd, NetlinkBoundSocket(NETLINK_ROUTE));
+
+  struct request {
+    struct nlmsghdr hdr;
+    struct ndmsg ndm;
+  };
+
+  struct request req = {};
+  req.hdr.nlmsg_len = sizeof(req);
+  req.hdr.nlmsg_type = RTM_GETNEIGH;
+  req.hdr.nlmsg_flags = NLM_F_REQUEST | NLM_F_DUMP;
+  req.hdr.nlmsg_seq = kSeq;
+  req.ndm.ndm_family = family;
+
+  std::vector<Neighbor> neighbors;
+  RETURN_IF_ERRNO(NetlinkRequestResponse(
+      fd, &req, sizeof(req),
+      [&](const struct nlmsghdr* hdr) {
+        if (hdr->nlmsg_type != RTM_NEWNEIGH ||
+            hdr->nlmsg_len < NLMSG_SPACE(sizeof(struct ndmsg))) {
+          return;
+        }
+        const struct ndmsg* msg =
+            reinterpret_cast<const struct ndmsg*>(NLMSG_DATA(hdr));
+        Neighbor neigh = {};
+        neigh.index = msg->ndm_ifindex;
+        neigh.state = msg->ndm_state;
+        int len = RTM_PAYLOAD(hdr);
+        for (struct rtattr* rta = NDA_RTA(msg); RTA_OK(rta, len);
+             rta = RTA_NEXT(rta, len)) {
+          std::string value(reinterpret_cast<const char*>(RTA_DATA(rta)),
+                            RTA_PAYLOAD(rta));
+          switch (rta->rta_type) {
+            case NDA_DST:
+              neigh.addr = value;
+              break;
+            case NDA_LLADDR:
+              neigh.lladdr = value;
+              break;
+          }
+        }
+        neighbors.push_back(neigh);
+      },
+      false));
+  return neighbors;
+}
+
 PosixError LinkAddLocalAddr(int index, int family, int prefixlen,
                             const void* addr, int addrlen) {
   return LinkModifyLocalAddr(index, family, prefixlen, addr, addrlen,
@@ -172,6 +313,34 @@ PosixError LinkDelLocalAddr(int index, int family, int prefixlen,
                              LinkAddrModification::kDelete);
 }
 
+PosixError RouteAddExclusive(int index, int family, int dst_len,
+                             const void* dst, int addrlen) {
+  return RouteModify(index, family, dst_len, dst, addrlen, RTM_NEWROUTE,
+                     NLM_F_CREATE | NLM_F_EXCL);
+}
+
+PosixError RouteReplace(int index, int family, int dst_len, const void* dst,
+                        int addrlen, bool create) {
+  return RouteModify(index, family, dst_len, dst, addrlen, RTM_NEWROUTE,
+                     NLM_F_REPLACE | (create ? NLM_F_CREATE : 0));
+}
+
+PosixError RouteDel(int index, int family, int dst_len, const void* dst,
+                    int addrlen) {
+  return RouteModify(index, family, dst_len, dst, addrlen, RTM_DELROUTE, 0);
+}
+
+PosixError NeighAdd(int index, int family, const void* addr, int addrlen,
+                    const void* lladdr, int lladdrlen) {
+  return NeighModify(index, family, addr, addrlen, lladdr, lladdrlen,
+                     RTM_NEWNEIGH, NLM_F_CREATE | NLM_F_REPLACE);
+}
+
+PosixError NeighDel(int index, int family, const void* addr, int addrlen) {
+  return NeighModify(index, family, addr, addrlen, nullptr, 0, RTM_DELNEIGH,
+                     0);
+}
+
 PosixError LinkChangeFlags(int index, unsigned int flags, unsigned int change) {
   ASSIGN_OR_RETURN_ERRNO(FileDescriptor fd, NetlinkBoundSocket(NETLINK_ROUTE));
 
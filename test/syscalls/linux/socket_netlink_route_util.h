@@ -39,6 +39,19 @@ PosixErrorOr<std::vector<Link>> DumpLinks();
 // Returns the loopback link on the system. ENOENT if not found.
 PosixErrorOr<Link> LoopbackLink();
 
+// Returns the first Ethernet link on the system. ENOENT if not found.
+PosixErrorOr<Link> EthernetLink();
+
+struct Neighbor {
+  int index;
+  uint16_t state;
+  std::string addr;
+  std::string lladdr;
+};
+
+// DumpNeighbors returns the entries of the neighbor tables of the family.
+PosixErrorOr<std::vector<Neighbor>> DumpNeighbors(int family);
+
 // LinkAddLocalAddr adds a new IFA_LOCAL address to the interface.
 PosixError LinkAddLocalAddr(int index, int family, int prefixlen,
                             const void* addr, int addrlen);
@@ -56,6 +69,32 @@ PosixError LinkReplaceLocalAddr(int index, int family, int prefixlen,
 PosixError LinkDelLocalAddr(int index, int family, int prefixlen,
                             const void* addr, int addrlen);
 
+// RouteAddExclusive adds a unicast route to the destination in the main
+// routing table through the interface, with NLM_F_EXCL flag.
+PosixError RouteAddExclusive(int index, int family, int dst_len,
+                             const void* dst, int addrlen);
+
+// RouteReplace replaces the route to the destination in the main routing
+// table with a unicast route through the interface, with NLM_F_REPLACE flag.
+// If create is set, NLM_F_CREATE flag is also set.
+PosixError RouteReplace(int index, int family, int dst_len, const void* dst,
+                        int addrlen, bool create);
+
+// RouteDel removes the route to the destination through the interface from
+// the main routing table.
+PosixError RouteDel(int index, int family, int dst_len, const void* dst,
+                    int addrlen);
+
+// NeighAdd adds a permanent entry mapping the address to the link-layer
+// address to the neighbor table of the interface, replacing any existing entry
+// for the address.
+PosixError NeighAdd(int index, int family, const void* addr, int addrlen,
+                    const void* lladdr, int lladdrlen);
+
+// NeighDel removes the entry of the address from the neighbor table of the
+// interface.
+PosixError NeighDel(int index, int family, const void* addr, int addrlen);
+
 // LinkChangeFlags changes interface flags. E.g. IFF_UP.
 PosixError LinkChangeFlags(int index, unsigned int flags, unsigned int change);
 
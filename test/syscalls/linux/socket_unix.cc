@@ -29,6 +29,7 @@
 #include "test/syscalls/linux/unix_domain_socket_test_util.h"
 #include "test/util/file_descriptor.h"
 #include "test/util/memory_util.h"
+#include "test/util/multiprocess_util.h"
 #include "test/util/socket_util.h"
 #include "test/util/temp_path.h"
 #include "test/util/test_util.h"
@@ -283,6 +284,48 @@ TEST_P(UnixSocketPairTest, SendFromMmapBeyondEof) {
               SyscallFailsWithErrno(EFAULT));
 }
 
+// Returns true if sockets are datagram sockets, which only have peer
+// credentials if they were created by socketpair(2).
+bool IsDatagram(const SocketPair& sockets) {
+  int type;
+  socklen_t typelen = sizeof(type);
+  TEST_PCHECK(getsockopt(sockets.first_fd(), SOL_SOCKET, SO_TYPE, &type,
+                         &typelen) == 0);
+  return type == SOCK_DGRAM;
+}
+
+TEST_P(UnixSocketPairTest, PeerCred) {
+  auto sockets = ASSERT_NO_ERRNO_AND_VALUE(NewSocketPair());
+  SKIP_IF(IsDatagram(*sockets));
+
+  struct ucred cred = {};
+  socklen_t credlen = sizeof(cred);
+  ASSERT_THAT(
+      getsockopt(sockets->first_fd(), SOL_SOCKET, SO_PEERCRED, &cred, &credlen),
+      SyscallSucceeds());
+  EXPECT_EQ(credlen, sizeof(cred));
+  EXPECT_EQ(cred.pid, getpid());
+  EXPECT_EQ(cred.uid, geteuid());
+  EXPECT_EQ(cred.gid, getegid());
+}
+
+// SO_PEERCRED returns the credentials of the peer when the sockets were
+// connected, not those of the caller.
+TEST_P(UnixSocketPairTest, PeerCredFromOtherProcess) {
+  auto sockets = ASSERT_NO_ERRNO_AND_VALUE(NewSocketPair());
+  SKIP_IF(IsDatagram(*sockets));
+  const pid_t pid = getpid();
+
+  const auto rest = [&] {
+    struct ucred cred = {};
+    socklen_t credlen = sizeof(cred);
+    TEST_PCHECK(getsockopt(sockets->second_fd(), SOL_SOCKET, SO_PEERCRED,
+                           &cred, &credlen) == 0);
+    TEST_CHECK(cred.pid == pid);
+  };
+  EXPECT_THAT(InForkedProcess(rest), IsPosixErrorOkAndHolds(0));
+}
+
 }  // namespace
 
 }  // namespace testing
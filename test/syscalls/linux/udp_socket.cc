@@ -1960,6 +1960,74 @@ TEST_P(UdpSocketTest, SetAndReceiveTOS) {
   EXPECT_EQ(received_tos, sent_tos);
 }
 
+// Test that a receiving socket with IP_RECVTTL or IPV6_RECVHOPLIMIT will
+// create the corresponding control message.
+TEST_P(UdpSocketTest, ReceiveTTL) {
+  if (GetParam() == AddressFamily::kDualStack) {
+    GTEST_SKIP() << "IPv4-mapped packets are received with IPv4 options";
+  }
+
+  ASSERT_NO_ERRNO(BindLoopback());
+  ASSERT_THAT(connect(sock_.get(), bind_addr_, addrlen_), SyscallSucceeds());
+
+  // Allow socket to receive control message.
+  int recv_level = SOL_IP;
+  int recv_type = IP_RECVTTL;
+  int cmsg_type = IP_TTL;
+  if (GetParam() != AddressFamily::kIpv4) {
+    recv_level = SOL_IPV6;
+    recv_type = IPV6_RECVHOPLIMIT;
+    cmsg_type = IPV6_HOPLIMIT;
+  }
+  ASSERT_THAT(setsockopt(bind_.get(), recv_level, recv_type, &kSockOptOn,
+                         sizeof(kSockOptOn)),
+              SyscallSucceeds());
+
+  int get = -1;
+  socklen_t get_len = sizeof(get);
+  ASSERT_THAT(getsockopt(bind_.get(), recv_level, recv_type, &get, &get_len),
+              SyscallSucceedsWithValue(0));
+  EXPECT_EQ(get_len, sizeof(get));
+  EXPECT_EQ(get, kSockOptOn);
+
+  // The TTL is only set for IPv4, the default hop limit is used for IPv6.
+  int sent_ttl = 64;
+  if (GetParam() == AddressFamily::kIpv4) {
+    sent_ttl = 42;
+    ASSERT_THAT(setsockopt(sock_.get(), SOL_IP, IP_TTL, &sent_ttl,
+                           sizeof(sent_ttl)),
+                SyscallSucceeds());
+  }
+
+  constexpr size_t kDataLength = 1024;
+  char sent_data[kDataLength];
+  ASSERT_THAT(RetryEINTR(send)(sock_.get(), sent_data, kDataLength, 0),
+              SyscallSucceedsWithValue(kDataLength));
+
+  // Receive message.
+  struct msghdr received_msg = {};
+  struct iovec received_iov = {};
+  char received_data[kDataLength];
+  received_iov.iov_base = &received_data[0];
+  received_iov.iov_len = kDataLength;
+  received_msg.msg_iov = &received_iov;
+  received_msg.msg_iovlen = 1;
+  std::vector<char> received_cmsgbuf(CMSG_SPACE(sizeof(int)));
+  received_msg.msg_control = &received_cmsgbuf[0];
+  received_msg.msg_controllen = received_cmsgbuf.size();
+  ASSERT_THAT(RetryEINTR(recvmsg)(bind_.get(), &received_msg, 0),
+              SyscallSucceedsWithValue(kDataLength));
+
+  struct cmsghdr* cmsg = CMSG_FIRSTHDR(&received_msg);
+  ASSERT_NE(cmsg, nullptr);
+  EXPECT_EQ(cmsg->cmsg_len, CMSG_LEN(sizeof(int)));
+  EXPECT_EQ(cmsg->cmsg_level, recv_level);
+  EXPECT_EQ(cmsg->cmsg_type, cmsg_type);
+  int received_ttl = 0;
+  memcpy(&received_ttl, CMSG_DATA(cmsg), sizeof(received_ttl));
+  EXPECT_EQ(received_ttl, sent_ttl);
+}
+
 // Test that sendmsg with IP_TOS and IPV6_TCLASS control messages will set the
 // TOS byte on outgoing packets, and that a receiving socket with IP_RECVTOS or
 // IPV6_RECVTCLASS will create the corresponding control message.
@@ -0,0 +1,80 @@
+#!/bin/bash
+
+# Copyright 2021 The gVisor Authors.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+# Create a local Kubernetes cluster using kind, with runsc installed on the
+# nodes and available as the "gvisor" RuntimeClass.
+#
+# Usage: kind.sh [image...]
+#
+# The given images are loaded from the local docker daemon into the nodes,
+# since the tests don't pull images. An existing cluster with the same name is
+# deleted first.
+
+set -xeuo pipefail
+
+declare -r CLUSTER=${KIND_CLUSTER:-gvisor}
+declare -r RUNTIME_CLASS=${RUNTIME_CLASS:-gvisor}
+
+for tool in kind kubectl docker; do
+  if ! command -v "${tool}" > /dev/null; then
+    echo "${tool} is required" >&2
+    exit 1
+  fi
+done
+
+# Find the binaries in the runfiles.
+runfiles=.
+if [[ -d "$0.runfiles" ]]; then
+  runfiles="$0.runfiles"
+fi
+declare -r runsc="$(find -L "${runfiles}" -executable -type f -name runsc | head -n 1)"
+declare -r shim="$(find -L "${runfiles}" -executable -type f -name containerd-shim-runsc-v1 | head -n 1)"
+if [[ -z "${runsc}" ]] || [[ -z "${shim}" ]]; then
+  echo "runsc and containerd-shim-runsc-v1 must be in the runfiles" >&2
+  exit 1
+fi
+
+# Create the cluster, with the runsc handler configured in containerd.
+kind delete cluster --name "${CLUSTER}" || true
+cat <<EOF | kind create cluster --name "${CLUSTER}" --wait 5m --config -
+kind: Cluster
+apiVersion: kind.x-k8s.io/v1alpha4
+containerdConfigPatches:
+- |-
+  [plugins."io.containerd.grpc.v1.cri".containerd.runtimes.runsc]
+    runtime_type = "io.containerd.runsc.v1"
+EOF
+
+# Install the binaries in all nodes. containerd finds the shim in its PATH.
+for node in $(kind get nodes --name "${CLUSTER}"); do
+  docker cp -L "${runsc}" "${node}":/usr/local/bin/runsc
+  docker cp -L "${shim}" "${node}":/usr/local/bin/containerd-shim-runsc-v1
+  docker exec "${node}" chmod 0755 /usr/local/bin/runsc /usr/local/bin/containerd-shim-runsc-v1
+done
+
+# Load the test images.
+for image in "$@"; do
+  kind load docker-image --name "${CLUSTER}" "${image}"
+done
+
+# Register the RuntimeClass.
+cat <<EOF | kubectl --context "kind-${CLUSTER}" apply -f -
+apiVersion: node.k8s.io/v1
+kind: RuntimeClass
+metadata:
+  name: ${RUNTIME_CLASS}
+handler: runsc
+EOF
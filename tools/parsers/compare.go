@@ -0,0 +1,236 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parsers
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gvisor.dev/gvisor/tools/bigquery"
+)
+
+// Threshold bounds the ratio between a metric measured with the runtime under
+// test and the same metric measured with the baseline runtime, e.g. runc.
+type Threshold struct {
+	// Benchmark matches the full names of the benchmarks the threshold
+	// applies to, e.g. "BenchmarkIperf/operation.Upload".
+	Benchmark *regexp.Regexp
+
+	// Metric is the name of the metric, e.g. "ns/op".
+	Metric string
+
+	// Max is true if the ratio must not be above Ratio, i.e. lower values are
+	// better, and false if the ratio must not be below Ratio.
+	Max bool
+
+	// Ratio is the bound.
+	Ratio float64
+}
+
+// String implements fmt.Stringer.
+func (t *Threshold) String() string {
+	bound := "min"
+	if t.Max {
+		bound = "max"
+	}
+	return fmt.Sprintf("%s %s %s %g", t.Benchmark, t.Metric, bound, t.Ratio)
+}
+
+// ParseThresholds parses thresholds, one per line, in the format:
+//
+//	<benchmark regexp> <metric> <max|min> <ratio>
+//
+// For example, "BenchmarkStartupEmpty ns/op max 3" fails if starting a
+// container takes more than three times as long as with the baseline runtime.
+// Empty lines and lines starting with '#' are ignored. The regexp must match
+// the whole benchmark name.
+func ParseThresholds(r io.Reader) ([]*Threshold, error) {
+	var thresholds []*Threshold
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("line %d: expected 4 fields, got %d: %q", n, len(fields), line)
+		}
+		re, err := regexp.Compile("^(?:" + fields[0] + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid benchmark regexp: %v", n, err)
+		}
+		t := &Threshold{Benchmark: re, Metric: fields[1]}
+		switch fields[2] {
+		case "max":
+			t.Max = true
+		case "min":
+		default:
+			return nil, fmt.Errorf("line %d: bound must be max or min, got %q", n, fields[2])
+		}
+		t.Ratio, err = strconv.ParseFloat(fields[3], 64)
+		if err != nil || t.Ratio <= 0 {
+			return nil, fmt.Errorf("line %d: invalid ratio %q", n, fields[3])
+		}
+		thresholds = append(thresholds, t)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return thresholds, nil
+}
+
+// Comparison is the result of comparing a metric between runtimes.
+type Comparison struct {
+	// Benchmark is the full name of the benchmark.
+	Benchmark string
+
+	// Metric is the name of the metric.
+	Metric string
+
+	// Value and Baseline are the values measured with the runtime under test
+	// and the baseline runtime.
+	Value    float64
+	Baseline float64
+
+	// Threshold is the threshold that applies to the metric, if any.
+	Threshold *Threshold
+}
+
+// Ratio returns the ratio between the value and the baseline.
+func (c *Comparison) Ratio() float64 {
+	return c.Value / c.Baseline
+}
+
+// Regressed returns true if the ratio is outside of the threshold.
+func (c *Comparison) Regressed() bool {
+	if c.Threshold == nil {
+		return false
+	}
+	if c.Threshold.Max {
+		return c.Ratio() > c.Threshold.Ratio
+	}
+	return c.Ratio() < c.Threshold.Ratio
+}
+
+// String implements fmt.Stringer.
+func (c *Comparison) String() string {
+	s := fmt.Sprintf("%s %s: %g vs %g (x%.2f)", c.Benchmark, c.Metric, c.Value, c.Baseline, c.Ratio())
+	if c.Threshold != nil {
+		s += fmt.Sprintf(" [%s]", c.Threshold)
+	}
+	if c.Regressed() {
+		s += " REGRESSION"
+	}
+	return s
+}
+
+// FullName returns the name of the benchmark as printed by go test, without
+// GOMAXPROCS, e.g. "BenchmarkRuby/server_threads.1". The conditions added by
+// ParseOutput that aren't part of the name are skipped.
+func FullName(bm *bigquery.Benchmark) string {
+	parts := []string{bm.Name}
+	for _, c := range bm.Condition {
+		if c.Name == "GOMAXPROCS" || c.Name == "iterations" {
+			continue
+		}
+		if c.Name == c.Value {
+			parts = append(parts, c.Name)
+		} else {
+			parts = append(parts, c.Name+"."+c.Value)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// Compare compares the metrics of the benchmarks in suite with those of the
+// same benchmarks in baseline. If a benchmark was run multiple times, e.g.
+// with -test.count, the median of its samples is used, to reduce noise.
+// Metrics that are missing from either suite are skipped, except if a
+// threshold applies to them, in which case an error is returned since the
+// regression check couldn't be done.
+func Compare(suite, baseline *bigquery.Suite, thresholds []*Threshold) ([]*Comparison, error) {
+	values := medians(suite)
+	baselines := medians(baseline)
+
+	var comparisons []*Comparison
+	for _, k := range values.keys {
+		c := &Comparison{
+			Benchmark: k.benchmark,
+			Metric:    k.metric,
+			Value:     values.values[k],
+		}
+		for _, t := range thresholds {
+			if t.Metric == k.metric && t.Benchmark.MatchString(k.benchmark) {
+				c.Threshold = t
+				break
+			}
+		}
+		base, ok := baselines.values[k]
+		if !ok || base == 0 {
+			if c.Threshold != nil {
+				return nil, fmt.Errorf("%s %s: no baseline to compare to", k.benchmark, k.metric)
+			}
+			continue
+		}
+		c.Baseline = base
+		comparisons = append(comparisons, c)
+	}
+	return comparisons, nil
+}
+
+// metricKey identifies a metric of a benchmark.
+type metricKey struct {
+	benchmark string
+	metric    string
+}
+
+// metricValues are the values of the metrics of a suite.
+type metricValues struct {
+	// keys are the metrics, in the order they were first found.
+	keys   []metricKey
+	values map[metricKey]float64
+}
+
+// medians returns the median of the samples of each metric in suite.
+func medians(suite *bigquery.Suite) metricValues {
+	var keys []metricKey
+	samples := make(map[metricKey][]float64)
+	for _, bm := range suite.Benchmarks {
+		name := FullName(bm)
+		for _, m := range bm.Metric {
+			k := metricKey{benchmark: name, metric: m.Name}
+			if _, ok := samples[k]; !ok {
+				keys = append(keys, k)
+			}
+			samples[k] = append(samples[k], m.Sample)
+		}
+	}
+	values := make(map[metricKey]float64)
+	for k, s := range samples {
+		sort.Float64s(s)
+		if n := len(s); n%2 == 1 {
+			values[k] = s[n/2]
+		} else {
+			values[k] = (s[n/2-1] + s[n/2]) / 2
+		}
+	}
+	return metricValues{keys: keys, values: values}
+}
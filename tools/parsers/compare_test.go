@@ -0,0 +1,101 @@
+// Copyright 2021 The gVisor Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parsers
+
+import (
+	"strings"
+	"testing"
+
+	"gvisor.dev/gvisor/tools/bigquery"
+)
+
+const thresholds = `
+# Startup may be up to 3 times slower.
+BenchmarkStartupEmpty ns/op max 3
+BenchmarkIperf/operation\..* bandwidth min 0.5
+`
+
+func TestCompare(t *testing.T) {
+	ts, err := ParseThresholds(strings.NewReader(thresholds))
+	if err != nil {
+		t.Fatalf("ParseThresholds failed: %v", err)
+	}
+
+	baseline, err := ParseOutput(`
+BenchmarkStartupEmpty-6 10 100000000 ns/op
+BenchmarkIperf/operation.Upload-6 1 1000 ns/op 4000 bandwidth.bytes_per_second
+BenchmarkIperf/operation.Download-6 1 1000 ns/op 4000 bandwidth.bytes_per_second
+`, "runc", false)
+	if err != nil {
+		t.Fatalf("ParseOutput failed: %v", err)
+	}
+	suite, err := ParseOutput(`
+BenchmarkStartupEmpty-6 10 250000000 ns/op
+BenchmarkStartupEmpty-6 10 900000000 ns/op
+BenchmarkStartupEmpty-6 10 200000000 ns/op
+BenchmarkIperf/operation.Upload-6 1 1000 ns/op 1000 bandwidth.bytes_per_second
+BenchmarkIperf/operation.Download-6 1 1000 ns/op 3000 bandwidth.bytes_per_second
+BenchmarkNew-6 1 1000 ns/op
+`, "runsc", false)
+	if err != nil {
+		t.Fatalf("ParseOutput failed: %v", err)
+	}
+
+	// The median of multiple runs is used, ignoring the outlier.
+	comparisons, err := Compare(suite, baseline, ts)
+	if err != nil {
+		t.Fatalf("Compare failed: %v", err)
+	}
+	regressed := make(map[string]bool)
+	for _, c := range comparisons {
+		regressed[c.Benchmark+" "+c.Metric] = c.Regressed()
+	}
+	for key, want := range map[string]bool{
+		"BenchmarkStartupEmpty ns/op":                 false,
+		"BenchmarkIperf/operation.Upload bandwidth":   true,
+		"BenchmarkIperf/operation.Download bandwidth": false,
+		"BenchmarkIperf/operation.Upload ns/op":       false,
+	} {
+		got, ok := regressed[key]
+		if !ok {
+			t.Errorf("no comparison for %q: %v", key, regressed)
+			continue
+		}
+		if got != want {
+			t.Errorf("%s regressed: got %t, want %t", key, got, want)
+		}
+	}
+	if _, ok := regressed["BenchmarkNew ns/op"]; ok {
+		t.Errorf("benchmark without baseline was compared")
+	}
+
+	// A threshold without baseline is an error.
+	if _, err := Compare(suite, &bigquery.Suite{}, ts); err == nil {
+		t.Errorf("Compare with empty baseline succeeded")
+	}
+}
+
+func TestParseThresholdsInvalid(t *testing.T) {
+	for _, input := range []string{
+		"BenchmarkFoo ns/op max",
+		"BenchmarkFoo ns/op above 2",
+		"BenchmarkFoo ns/op max -1",
+		"Benchmark( ns/op max 2",
+	} {
+		if _, err := ParseThresholds(strings.NewReader(input)); err == nil {
+			t.Errorf("ParseThresholds(%q) succeeded", input)
+		}
+	}
+}
@@ -30,10 +30,12 @@ import (
 )
 
 const (
-	initString       = "init"
-	initDescription  = "initializes a new table with benchmarks schema"
-	parseString      = "parse"
-	parseDescription = "parses given benchmarks file and sends it to BigQuery table."
+	initString         = "init"
+	initDescription    = "initializes a new table with benchmarks schema"
+	parseString        = "parse"
+	parseDescription   = "parses given benchmarks file and sends it to BigQuery table."
+	compareString      = "compare"
+	compareDescription = "compares given benchmarks file to a baseline and fails on regressions."
 )
 
 var (
@@ -56,6 +58,14 @@ var (
 	official     = parseCmd.Bool("official", false, "mark input data as official.")
 	runtime      = parseCmd.String("runtime", "", "runtime used to run the benchmark")
 	debug        = parseCmd.Bool("debug", false, "print debug logs")
+
+	// The compare command compares benchmark data in `file` to the data of the
+	// same benchmarks in `baseline`, typically run with runc on the same host,
+	// and fails if any metric crosses its threshold.
+	compareCmd        = flag.NewFlagSet(compareString, flag.ContinueOnError)
+	compareFile       = compareCmd.String("file", "", "file to parse for benchmarks")
+	compareBaseline   = compareCmd.String("baseline", "", "file to parse for baseline benchmarks")
+	compareThresholds = compareCmd.String("thresholds", "", "file with regression thresholds, see parsers.ParseThresholds")
 )
 
 // initBenchmarks initializes a dataset/table in a BigQuery project.
@@ -99,6 +109,59 @@ func parseBenchmarks(ctx context.Context) error {
 	return bq.SendBenchmarks(ctx, suite, *parseProject, *parseDataset, *parseTable, nil)
 }
 
+// compareBenchmarks compares the benchmarks in the given file to the baseline
+// and prints the results. It returns an error if any metric regressed.
+func compareBenchmarks() error {
+	suite, err := parseFile(*compareFile)
+	if err != nil {
+		return err
+	}
+	baseline, err := parseFile(*compareBaseline)
+	if err != nil {
+		return err
+	}
+	var thresholds []*parsers.Threshold
+	if *compareThresholds != "" {
+		f, err := os.Open(*compareThresholds)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		thresholds, err = parsers.ParseThresholds(f)
+		if err != nil {
+			return fmt.Errorf("failed to parse thresholds %s: %v", *compareThresholds, err)
+		}
+	}
+	comparisons, err := parsers.Compare(suite, baseline, thresholds)
+	if err != nil {
+		return err
+	}
+	regressions := 0
+	for _, c := range comparisons {
+		fmt.Println(c)
+		if c.Regressed() {
+			regressions++
+		}
+	}
+	if regressions > 0 {
+		return fmt.Errorf("%d metrics regressed", regressions)
+	}
+	return nil
+}
+
+// parseFile parses the benchmarks in the given file.
+func parseFile(path string) (*bq.Suite, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %v", path, err)
+	}
+	suite, err := parsers.ParseOutput(string(data), path, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse file %s: %v", path, err)
+	}
+	return suite, nil
+}
+
 func main() {
 	ctx := context.Background()
 	switch {
@@ -123,6 +186,16 @@ func main() {
 			log.Fatalf("Failed parse benchmarks: %v\n", err)
 			os.Exit(1)
 		}
+	// the "compare" command.
+	case len(os.Args) >= 2 && os.Args[1] == compareString:
+		if err := compareCmd.Parse(os.Args[2:]); err != nil {
+			log.Fatalf("Failed parse flags: %v\n", err)
+			os.Exit(1)
+		}
+		if err := compareBenchmarks(); err != nil {
+			log.Fatalf("Failed compare benchmarks: %v\n", err)
+			os.Exit(1)
+		}
 	default:
 		printUsage()
 		os.Exit(1)
@@ -136,8 +209,9 @@ func printUsage() {
 Available commands:
   %s     %s
   %s     %s
+  %s     %s
 `
-	log.Printf(usage, initCmd.Name(), initDescription, parseCmd.Name(), parseDescription)
+	log.Printf(usage, initCmd.Name(), initDescription, parseCmd.Name(), parseDescription, compareCmd.Name(), compareDescription)
 }
 
 func debugLog(msg string, args ...interface{}) {